@@ -0,0 +1,35 @@
+// Command ctxkeycheck reports context.WithValue calls with built-in key
+// types in the package directories given as arguments. It exits with
+// status 1 when it found any, like go vet.
+//
+//	go run ./analysis/ctxkey/cmd/ctxkeycheck ./idioms/ctxkeys
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/crazybber/go-patterns/analysis/ctxkey"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: ctxkeycheck dir...")
+		os.Exit(2)
+	}
+	found := false
+	for _, dir := range os.Args[1:] {
+		diags, err := ctxkey.CheckDir(dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		for _, d := range diags {
+			fmt.Println(d)
+			found = true
+		}
+	}
+	if found {
+		os.Exit(1)
+	}
+}
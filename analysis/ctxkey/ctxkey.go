@@ -0,0 +1,112 @@
+// Package ctxkey reports calls to context.WithValue whose key has a
+// built-in type. Such keys are shared by every package that happens to use
+// the same value, so "user" set by one package can be read or overwritten
+// by another. Keys should have an unexported type of their own; see
+// idioms/ctxkeys.
+//
+// The check works like a go vet pass but only needs the standard library:
+// Check runs on type-checked files, and CheckDir loads a directory itself.
+package ctxkey
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Diagnostic is a reported call.
+type Diagnostic struct {
+	Pos     token.Position
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// Check reports the calls in files. info must have Types and Uses filled
+// in.
+func Check(fset *token.FileSet, files []*ast.File, info *types.Info) []Diagnostic {
+	var diags []Diagnostic
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 3 || !isWithValue(call.Fun, info) {
+				return true
+			}
+			key := call.Args[1]
+			if t, ok := info.TypeOf(key).(*types.Basic); ok {
+				diags = append(diags, Diagnostic{
+					Pos: fset.Position(key.Pos()),
+					Message: fmt.Sprintf("context.WithValue key has built-in type %s; "+
+						"use an unexported key type to avoid collisions", types.Default(t)),
+				})
+			}
+			return true
+		})
+	}
+	return diags
+}
+
+func isWithValue(fun ast.Expr, info *types.Info) bool {
+	var id *ast.Ident
+	switch f := fun.(type) {
+	case *ast.SelectorExpr:
+		id = f.Sel
+	case *ast.Ident:
+		id = f
+	default:
+		return false
+	}
+	fn, ok := info.Uses[id].(*types.Func)
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == "context" && fn.Name() == "WithValue"
+}
+
+// CheckDir parses and type-checks the package in dir, test files included,
+// and reports its calls. Imports are type-checked from source.
+func CheckDir(dir string) ([]Diagnostic, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return strings.HasSuffix(fi.Name(), ".go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diags []Diagnostic
+	for _, name := range names {
+		var files []*ast.File
+		for _, f := range pkgs[name].Files {
+			files = append(files, f)
+		}
+		info := &types.Info{
+			Types: make(map[ast.Expr]types.TypeAndValue),
+			Uses:  make(map[*ast.Ident]types.Object),
+		}
+		conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+		if _, err := conf.Check(filepath.Join(dir, name), fset, files, info); err != nil {
+			return nil, err
+		}
+		diags = append(diags, Check(fset, files, info)...)
+	}
+	sort.Slice(diags, func(i, j int) bool {
+		a, b := diags[i].Pos, diags[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	return diags, nil
+}
@@ -0,0 +1,66 @@
+package ctxkey
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var wantRE = regexp.MustCompile(`// want "([^"]+)"`)
+
+// TestCheckDir compares the diagnostics with the // want comments in
+// testdata, in the manner of analysistest.
+func TestCheckDir(t *testing.T) {
+	diags, err := CheckDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[int]string)
+	for _, d := range diags {
+		got[d.Pos.Line] = d.Message
+	}
+
+	f, err := os.Open(filepath.Join("testdata", "keys.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want := make(map[int]string)
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		if m := wantRE.FindStringSubmatch(s.Text()); m != nil {
+			want[line] = m[1]
+		}
+	}
+
+	for line, pattern := range want {
+		msg, ok := got[line]
+		if !ok {
+			t.Errorf("line %d: missing diagnostic matching %q", line, pattern)
+		} else if !strings.Contains(msg, pattern) {
+			t.Errorf("line %d: diagnostic %q does not match %q", line, msg, pattern)
+		}
+	}
+	for line, msg := range got {
+		if _, ok := want[line]; !ok {
+			t.Errorf("line %d: unexpected diagnostic %q", line, msg)
+		}
+	}
+}
+
+func TestCtxkeysIsClean(t *testing.T) {
+	for _, dir := range []string{"requestid", "auth", ""} {
+		diags, err := CheckDir(filepath.Join("..", "..", "idioms", "ctxkeys", dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range diags {
+			if !strings.HasSuffix(d.Pos.Filename, "_test.go") {
+				t.Errorf("unexpected diagnostic %s", d)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package testdata
+
+import (
+	"context"
+	ctxpkg "context"
+)
+
+type key struct{}
+
+type legacyKey string
+
+const userKey = "user"
+
+func values(ctx context.Context) {
+	_ = context.WithValue(ctx, "user", 1)  // want "built-in type string"
+	_ = context.WithValue(ctx, userKey, 1) // want "built-in type string"
+	_ = ctxpkg.WithValue(ctx, 42, 1)       // want "built-in type int"
+	_ = context.WithValue(ctx, key{}, 1)
+	_ = context.WithValue(ctx, legacyKey("user"), 1)
+
+	var anyKey interface{} = "user"
+	_ = context.WithValue(ctx, anyKey, 1)
+}
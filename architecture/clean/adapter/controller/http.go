@@ -0,0 +1,83 @@
+// Package controller turns HTTP requests into calls of the use cases:
+//
+//	GET  /todos             the open todos, most urgent first; ?all=1 for all
+//	POST /todos             {"title": "...", "priority": "high", "due": "2024-05-01"}
+//	POST /todos/{id}/done   completes a todo
+//
+// It parses requests into request models and writes whatever view model a
+// presenter.JSON made of the response; it formats nothing itself.
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/clean/adapter/presenter"
+	"github.com/crazybber/go-patterns/architecture/clean/usecase"
+)
+
+// Handler returns the HTTP API of in.
+func Handler(in *usecase.Interactor) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /todos", func(w http.ResponseWriter, r *http.Request) {
+		var p presenter.JSON
+		in.Agenda(r.Context(), r.URL.Query().Get("all") != "", &p)
+		write(w, &p)
+	})
+	mux.HandleFunc("POST /todos", func(w http.ResponseWriter, r *http.Request) {
+		var p presenter.JSON
+		req, err := addRequest(r)
+		if err != nil {
+			p.Status, p.Body = http.StatusBadRequest, map[string]string{"error": err.Error()}
+		} else {
+			in.Add(r.Context(), req, &p)
+		}
+		write(w, &p)
+	})
+	mux.HandleFunc("POST /todos/{id}/done", func(w http.ResponseWriter, r *http.Request) {
+		var p presenter.JSON
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			p.Status, p.Body = http.StatusNotFound, map[string]string{"error": "no todo " + r.PathValue("id")}
+		} else {
+			in.Complete(r.Context(), id, &p)
+		}
+		write(w, &p)
+	})
+	return mux
+}
+
+var priorities = map[string]int{"high": 1, "normal": 2, "": 2, "low": 3}
+
+func addRequest(r *http.Request) (usecase.AddRequest, error) {
+	var body struct {
+		Title    string `json:"title"`
+		Priority string `json:"priority"`
+		Due      string `json:"due"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return usecase.AddRequest{}, fmt.Errorf("bad JSON: %v", err)
+	}
+	req := usecase.AddRequest{Title: body.Title, Priority: priorities[body.Priority]}
+	if req.Priority == 0 {
+		return usecase.AddRequest{}, fmt.Errorf("bad priority %q", body.Priority)
+	}
+	if body.Due != "" {
+		due, err := time.Parse(time.DateOnly, body.Due)
+		if err != nil {
+			return usecase.AddRequest{}, fmt.Errorf("bad due date %q", body.Due)
+		}
+		// Due by the end of the day.
+		req.Due = due.Add(24*time.Hour - time.Nanosecond)
+	}
+	return req, nil
+}
+
+func write(w http.ResponseWriter, p *presenter.JSON) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p.Body)
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/clean/adapter/gateway"
+	"github.com/crazybber/go-patterns/architecture/clean/usecase"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+func TestHandler(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	h := Handler(usecase.New(gateway.NewMemory(), fixedClock(now)))
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"POST", "/todos", `{"title": "file taxes", "due": "2024-05-09"}`, 200,
+			`{"id":1,"title":"file taxes","priority":"normal","due":"2024-05-09","status":"overdue"}`},
+		{"POST", "/todos", `{"title": "call mum", "priority": "high", "due": "2024-05-10"}`, 200,
+			`{"id":2,"title":"call mum","priority":"high","due":"2024-05-10","status":"open"}`},
+		{"POST", "/todos", `{"title": "", "priority": "low"}`, 400, `{"error":"entity: empty title"}`},
+		{"POST", "/todos", `{"title": "x", "priority": "urgent"}`, 400, `{"error":"bad priority \"urgent\""}`},
+		{"POST", "/todos", `{"title": "x", "due": "tomorrow"}`, 400, `{"error":"bad due date \"tomorrow\""}`},
+		{"POST", "/todos", `{`, 400, `{"error":"bad JSON: unexpected EOF"}`},
+		{"GET", "/todos", "", 200, `[{"id":1,"title":"file taxes","priority":"normal","due":"2024-05-09","status":"overdue"},` +
+			`{"id":2,"title":"call mum","priority":"high","due":"2024-05-10","status":"open"}]`},
+		{"POST", "/todos/1/done", "", 200, `{"id":1,"title":"file taxes","priority":"normal","due":"2024-05-09","status":"done"}`},
+		{"POST", "/todos/1/done", "", 409, `{"error":"entity: todo already done"}`},
+		{"POST", "/todos/7/done", "", 404, `{"error":"usecase: todo not found"}`},
+		{"POST", "/todos/x/done", "", 404, `{"error":"no todo x"}`},
+		{"GET", "/todos", "", 200, `[{"id":2,"title":"call mum","priority":"high","due":"2024-05-10","status":"open"}]`},
+		{"GET", "/todos?all=1", "", 200, `[{"id":2,"title":"call mum","priority":"high","due":"2024-05-10","status":"open"},` +
+			`{"id":1,"title":"file taxes","priority":"normal","due":"2024-05-09","status":"done"}]`},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if got := strings.TrimSpace(w.Body.String()); w.Code != tc.status || got != tc.want {
+			t.Errorf("%s %s %s = %d %s", tc.method, tc.path, tc.body, w.Code, got)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type %q", ct)
+		}
+	}
+}
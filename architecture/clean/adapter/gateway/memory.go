@@ -0,0 +1,69 @@
+// Package gateway implements usecase.Gateway.
+package gateway
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/crazybber/go-patterns/architecture/clean/entity"
+	"github.com/crazybber/go-patterns/architecture/clean/usecase"
+)
+
+// Memory keeps todos in a map. It is safe for concurrent use.
+type Memory struct {
+	mu     sync.Mutex
+	nextID int64
+	todos  map[int64]entity.Todo
+}
+
+var _ usecase.Gateway = (*Memory)(nil)
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{todos: map[int64]entity.Todo{}}
+}
+
+// Insert stores a new todo and returns it with its ID set.
+func (m *Memory) Insert(_ context.Context, t entity.Todo) (entity.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	t.ID = m.nextID
+	m.todos[t.ID] = t
+	return t, nil
+}
+
+// Get returns the todo with id.
+func (m *Memory) Get(_ context.Context, id int64) (entity.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.todos[id]
+	if !ok {
+		return entity.Todo{}, usecase.ErrNotFound
+	}
+	return t, nil
+}
+
+// Update replaces the stored todo with t's ID.
+func (m *Memory) Update(_ context.Context, t entity.Todo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.todos[t.ID]; !ok {
+		return usecase.ErrNotFound
+	}
+	m.todos[t.ID] = t
+	return nil
+}
+
+// All returns every todo in ID order.
+func (m *Memory) All(context.Context) ([]entity.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ts := make([]entity.Todo, 0, len(m.todos))
+	for _, id := range slices.Sorted(maps.Keys(m.todos)) {
+		ts = append(ts, m.todos[id])
+	}
+	return ts, nil
+}
@@ -0,0 +1,79 @@
+// Package presenter turns the response models of the use cases into view
+// models for a JSON API.
+package presenter
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/clean/entity"
+	"github.com/crazybber/go-patterns/architecture/clean/usecase"
+)
+
+// Todo is the view model of a todo. Dates are days, as the client shows
+// them.
+type Todo struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Priority string `json:"priority"`
+	Due      string `json:"due,omitempty"`
+	Status   string `json:"status"`
+}
+
+// JSON is a usecase.Presenter recording the view model of what it was
+// given: a status code and a body to encode as JSON.
+type JSON struct {
+	Status int
+	Body   any
+}
+
+var _ usecase.Presenter = (*JSON)(nil)
+
+// Todo presents one todo.
+func (p *JSON) Todo(t usecase.Todo) {
+	p.Status, p.Body = http.StatusOK, view(t)
+}
+
+// List presents a list of todos.
+func (p *JSON) List(ts []usecase.Todo) {
+	views := make([]Todo, len(ts))
+	for i, t := range ts {
+		views[i] = view(t)
+	}
+	p.Status, p.Body = http.StatusOK, views
+}
+
+// Error presents an error with the status code it deserves.
+func (p *JSON) Error(err error) {
+	p.Status = http.StatusInternalServerError
+	switch {
+	case errors.Is(err, usecase.ErrNotFound):
+		p.Status = http.StatusNotFound
+	case errors.Is(err, entity.ErrEmptyTitle), errors.Is(err, entity.ErrBadPriority):
+		p.Status = http.StatusBadRequest
+	case errors.Is(err, entity.ErrDone):
+		p.Status = http.StatusConflict
+	}
+	msg := err.Error()
+	if p.Status == http.StatusInternalServerError {
+		msg = "internal error"
+	}
+	p.Body = map[string]string{"error": msg}
+}
+
+var priorities = map[int]string{1: "high", 2: "normal", 3: "low"}
+
+func view(t usecase.Todo) Todo {
+	v := Todo{ID: t.ID, Title: t.Title, Priority: priorities[t.Priority], Status: "open"}
+	if !t.Due.IsZero() {
+		v.Due = t.Due.Format(time.DateOnly)
+	}
+	switch {
+	case t.Done:
+		v.Status = "done"
+	case t.Overdue:
+		v.Status = "overdue"
+	}
+	return v
+}
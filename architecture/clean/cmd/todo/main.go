@@ -0,0 +1,37 @@
+// Command todo is the frameworks-and-drivers ring of the clean to-do
+// example: it wires the adapters to the use cases and serves the HTTP API.
+//
+//	go run ./architecture/clean/cmd/todo -http :8080
+//	curl localhost:8080/todos
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/clean/adapter/controller"
+	"github.com/crazybber/go-patterns/architecture/clean/adapter/gateway"
+	"github.com/crazybber/go-patterns/architecture/clean/adapter/presenter"
+	"github.com/crazybber/go-patterns/architecture/clean/usecase"
+)
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func main() {
+	addr := flag.String("http", ":8080", "serve the HTTP API on `addr`")
+	flag.Parse()
+
+	in := usecase.New(gateway.NewMemory(), systemClock{})
+	var p presenter.JSON
+	ctx := context.Background()
+	in.Add(ctx, usecase.AddRequest{Title: "draw the rings", Priority: 2}, &p)
+	in.Add(ctx, usecase.AddRequest{Title: "point imports inwards", Priority: 1, Due: time.Now().AddDate(0, 0, 1)}, &p)
+
+	log.Printf("serving on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, controller.Handler(in)))
+}
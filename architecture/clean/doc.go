@@ -0,0 +1,22 @@
+// Package clean is a small to-do service laid out in the rings of the
+// clean architecture, each a directory whose code may only depend on the
+// rings inside it:
+//
+//   - entity holds the Todo and the business rules that would hold in any
+//     application, such as which of two todos is more urgent.
+//   - usecase holds the interactors, one per thing a user can do. Each
+//     takes a request model through its input boundary and hands a
+//     response model to the Presenter at its output boundary; it reaches
+//     storage through the Gateway interface it declares.
+//   - adapter converts between the use cases and the outside: controller
+//     turns HTTP requests into request models, presenter turns response
+//     models into JSON view models, gateway implements Gateway in memory.
+//   - cmd/todo is the frameworks-and-drivers ring: it wires the adapters
+//     and runs the HTTP server.
+//
+// Unlike the hexagonal example, use cases do not return their results:
+// they push them to a presenter, so that the controller never formats
+// output and the use case never knows about status codes. The dependency
+// rule is enforced by a test of this package that parses the imports of
+// every package below it.
+package clean
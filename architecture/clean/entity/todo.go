@@ -0,0 +1,77 @@
+// Package entity holds the to-do entity and the rules it obeys wherever
+// it is used. It imports nothing of the application.
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Errors of the entities.
+var (
+	ErrEmptyTitle  = errors.New("entity: empty title")
+	ErrBadPriority = errors.New("entity: priority not between 1 and 3")
+	ErrDone        = errors.New("entity: todo already done")
+)
+
+// Priority runs from 1, the highest, to 3.
+type Priority int
+
+// Todo is something to do, perhaps by a due date.
+type Todo struct {
+	ID       int64
+	Title    string
+	Priority Priority
+	// Due and Done are zero when unset.
+	Due  time.Time
+	Done time.Time
+}
+
+// New returns an open todo, with its title trimmed.
+func New(title string, p Priority, due time.Time) (Todo, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return Todo{}, ErrEmptyTitle
+	}
+	if p < 1 || p > 3 {
+		return Todo{}, ErrBadPriority
+	}
+	return Todo{Title: title, Priority: p, Due: due}, nil
+}
+
+// IsDone reports whether the todo was completed.
+func (t Todo) IsDone() bool { return !t.Done.IsZero() }
+
+// Complete marks the todo done at now.
+func (t *Todo) Complete(now time.Time) error {
+	if t.IsDone() {
+		return ErrDone
+	}
+	t.Done = now
+	return nil
+}
+
+// Overdue reports whether the todo is open past its due date.
+func (t Todo) Overdue(now time.Time) bool {
+	return !t.IsDone() && !t.Due.IsZero() && now.After(t.Due)
+}
+
+// MoreUrgent reports whether t should be done before u: open todos come
+// before done ones, overdue before the rest, then by priority, by due
+// date with undated ones last, and by ID.
+func (t Todo) MoreUrgent(u Todo, now time.Time) bool {
+	if t.IsDone() != u.IsDone() {
+		return !t.IsDone()
+	}
+	if t.Overdue(now) != u.Overdue(now) {
+		return t.Overdue(now)
+	}
+	if t.Priority != u.Priority {
+		return t.Priority < u.Priority
+	}
+	if !t.Due.Equal(u.Due) {
+		return u.Due.IsZero() || (!t.Due.IsZero() && t.Due.Before(u.Due))
+	}
+	return t.ID < u.ID
+}
@@ -0,0 +1,117 @@
+package clean
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const module = "github.com/crazybber/go-patterns/architecture/clean"
+
+// ring returns the ring of a package of this tree by its path relative to
+// the tree, 0 being the innermost.
+func ring(rel string) int {
+	switch {
+	case rel == "entity":
+		return 0
+	case rel == "usecase":
+		return 1
+	case rel == "adapter" || strings.HasPrefix(rel, "adapter/"):
+		return 2
+	}
+	return 3
+}
+
+// frameworks are the imports the inner rings must do without.
+var frameworks = []string{"database/sql", "encoding/json", "net/http"}
+
+// imports returns the imports of every package of the tree under root,
+// by path relative to the tree, leaving out tests.
+func imports(t *testing.T, root string) map[string][]string {
+	graph := map[string][]string{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") {
+			return err
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), p, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, filepath.Dir(p))
+		rel = filepath.ToSlash(rel)
+		for _, imp := range f.Imports {
+			ip, _ := strconv.Unquote(imp.Path.Value)
+			if !slices.Contains(graph[rel], ip) {
+				graph[rel] = append(graph[rel], ip)
+			}
+		}
+		if _, ok := graph[rel]; !ok {
+			graph[rel] = nil
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return graph
+}
+
+// violations returns the imports of graph that break the dependency rule.
+func violations(graph map[string][]string) []string {
+	var bad []string
+	for pkg, imps := range graph {
+		for _, imp := range imps {
+			switch {
+			case strings.HasPrefix(imp, module+"/"):
+				dep := strings.TrimPrefix(imp, module+"/")
+				if ring(dep) > ring(pkg) {
+					bad = append(bad, pkg+" -> "+dep+": imports an outer ring")
+				}
+			case imp == module:
+				bad = append(bad, pkg+" -> "+imp+": imports the outermost ring")
+			case strings.HasPrefix(imp, "github.com/crazybber/go-patterns/"):
+				bad = append(bad, pkg+" -> "+imp+": leaves the example")
+			case ring(pkg) < 2 && slices.Contains(frameworks, imp):
+				bad = append(bad, pkg+" -> "+imp+": uses a framework")
+			}
+		}
+	}
+	slices.Sort(bad)
+	return bad
+}
+
+func TestDependencyRule(t *testing.T) {
+	graph := imports(t, ".")
+	for _, pkg := range []string{"entity", "usecase", "adapter/controller", "adapter/gateway", "adapter/presenter", "cmd/todo"} {
+		if _, ok := graph[pkg]; !ok {
+			t.Errorf("package %s not found", pkg)
+		}
+	}
+	for _, v := range violations(graph) {
+		t.Error(v)
+	}
+}
+
+func TestViolations(t *testing.T) {
+	graph := map[string][]string{
+		"entity":            {"time", module + "/usecase"},
+		"usecase":           {module + "/entity", "encoding/json"},
+		"adapter/presenter": {"encoding/json", module + "/usecase", module + "/adapter/gateway"},
+		"adapter/gateway":   {module + "/cmd/todo", "github.com/crazybber/go-patterns/architecture/hexagonal/domain"},
+		"cmd/todo":          {module + "/adapter/controller", module + "/entity"},
+	}
+	want := []string{
+		"adapter/gateway -> cmd/todo: imports an outer ring",
+		"adapter/gateway -> github.com/crazybber/go-patterns/architecture/hexagonal/domain: leaves the example",
+		"entity -> usecase: imports an outer ring",
+		"usecase -> encoding/json: uses a framework",
+	}
+	if got := violations(graph); !slices.Equal(got, want) {
+		t.Errorf("violations:\n%s", strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,132 @@
+// Package usecase holds the interactors of the to-do service. It depends
+// on the entities and on the interfaces it declares, which outer rings
+// implement: Gateway for storage, Presenter for output, Clock for time.
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/clean/entity"
+)
+
+// ErrNotFound is returned by a Gateway for a missing todo.
+var ErrNotFound = errors.New("usecase: todo not found")
+
+// Gateway stores todos.
+type Gateway interface {
+	// Insert stores a new todo and returns it with its ID set.
+	Insert(ctx context.Context, t entity.Todo) (entity.Todo, error)
+	Get(ctx context.Context, id int64) (entity.Todo, error)
+	Update(ctx context.Context, t entity.Todo) error
+	All(ctx context.Context) ([]entity.Todo, error)
+}
+
+// Clock tells the time.
+type Clock interface{ Now() time.Time }
+
+// Todo is the response model of a todo: what a use case tells its
+// presenter, with the rules already applied.
+type Todo struct {
+	ID       int64
+	Title    string
+	Priority int
+	Due      time.Time
+	Done     bool
+	Overdue  bool
+}
+
+// Presenter is the output boundary. A use case calls exactly one of its
+// methods.
+type Presenter interface {
+	Todo(t Todo)
+	List(ts []Todo)
+	Error(err error)
+}
+
+// AddRequest is the request model of Add.
+type AddRequest struct {
+	Title    string
+	Priority int
+	Due      time.Time
+}
+
+// Interactor implements the use cases.
+type Interactor struct {
+	todos Gateway
+	clock Clock
+}
+
+// New returns an Interactor storing todos in g.
+func New(g Gateway, clock Clock) *Interactor {
+	return &Interactor{todos: g, clock: clock}
+}
+
+// Add creates a todo and presents it.
+func (in *Interactor) Add(ctx context.Context, req AddRequest, out Presenter) {
+	t, err := entity.New(req.Title, entity.Priority(req.Priority), req.Due)
+	if err == nil {
+		t, err = in.todos.Insert(ctx, t)
+	}
+	if err != nil {
+		out.Error(err)
+		return
+	}
+	out.Todo(in.response(t))
+}
+
+// Complete marks the todo with id done and presents it.
+func (in *Interactor) Complete(ctx context.Context, id int64, out Presenter) {
+	t, err := in.todos.Get(ctx, id)
+	if err == nil {
+		err = t.Complete(in.clock.Now())
+	}
+	if err == nil {
+		err = in.todos.Update(ctx, t)
+	}
+	if err != nil {
+		out.Error(err)
+		return
+	}
+	out.Todo(in.response(t))
+}
+
+// Agenda presents the todos most urgent first, leaving out done ones
+// unless all is set.
+func (in *Interactor) Agenda(ctx context.Context, all bool, out Presenter) {
+	ts, err := in.todos.All(ctx)
+	if err != nil {
+		out.Error(err)
+		return
+	}
+	now := in.clock.Now()
+	slices.SortFunc(ts, func(a, b entity.Todo) int {
+		switch {
+		case a.MoreUrgent(b, now):
+			return -1
+		case b.MoreUrgent(a, now):
+			return 1
+		}
+		return 0
+	})
+	res := make([]Todo, 0, len(ts))
+	for _, t := range ts {
+		if all || !t.IsDone() {
+			res = append(res, in.response(t))
+		}
+	}
+	out.List(res)
+}
+
+func (in *Interactor) response(t entity.Todo) Todo {
+	return Todo{
+		ID:       t.ID,
+		Title:    t.Title,
+		Priority: int(t.Priority),
+		Due:      t.Due,
+		Done:     t.IsDone(),
+		Overdue:  t.Overdue(in.clock.Now()),
+	}
+}
@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/clean/entity"
+)
+
+// fakeGateway keeps todos in a slice and fails when told to.
+type fakeGateway struct {
+	todos []entity.Todo
+	err   error
+}
+
+func (g *fakeGateway) Insert(_ context.Context, t entity.Todo) (entity.Todo, error) {
+	if g.err != nil {
+		return entity.Todo{}, g.err
+	}
+	t.ID = int64(len(g.todos) + 1)
+	g.todos = append(g.todos, t)
+	return t, nil
+}
+
+func (g *fakeGateway) Get(_ context.Context, id int64) (entity.Todo, error) {
+	if id < 1 || id > int64(len(g.todos)) {
+		return entity.Todo{}, ErrNotFound
+	}
+	return g.todos[id-1], nil
+}
+
+func (g *fakeGateway) Update(_ context.Context, t entity.Todo) error {
+	g.todos[t.ID-1] = t
+	return g.err
+}
+
+func (g *fakeGateway) All(context.Context) ([]entity.Todo, error) {
+	return append([]entity.Todo(nil), g.todos...), g.err
+}
+
+// recorder is a Presenter remembering what it was given.
+type recorder struct {
+	calls int
+	todo  Todo
+	list  []Todo
+	err   error
+}
+
+func (r *recorder) Todo(t Todo)     { r.calls++; r.todo = t }
+func (r *recorder) List(ts []Todo)  { r.calls++; r.list = ts }
+func (r *recorder) Error(err error) { r.calls++; r.err = err }
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+var noon = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+func day(d int) time.Time { return time.Date(2024, 5, d, 23, 59, 0, 0, time.UTC) }
+
+func TestAdd(t *testing.T) {
+	ctx := context.Background()
+	in := New(&fakeGateway{}, fixedClock(noon))
+	var r recorder
+	in.Add(ctx, AddRequest{Title: " pay rent ", Priority: 1, Due: day(1)}, &r)
+	want := Todo{ID: 1, Title: "pay rent", Priority: 1, Due: day(1), Overdue: true}
+	if r.calls != 1 || r.todo != want {
+		t.Errorf("presented %+v", r)
+	}
+	for _, tc := range []struct {
+		req  AddRequest
+		want error
+	}{
+		{AddRequest{Title: " ", Priority: 1}, entity.ErrEmptyTitle},
+		{AddRequest{Title: "x", Priority: 4}, entity.ErrBadPriority},
+	} {
+		r := recorder{}
+		in.Add(ctx, tc.req, &r)
+		if r.calls != 1 || !errors.Is(r.err, tc.want) {
+			t.Errorf("Add(%+v) presented %+v", tc.req, r)
+		}
+	}
+	down := errors.New("down")
+	r = recorder{}
+	New(&fakeGateway{err: down}, fixedClock(noon)).Add(ctx, AddRequest{Title: "x", Priority: 2}, &r)
+	if r.err != down {
+		t.Errorf("gateway down: presented %+v", r)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	ctx := context.Background()
+	g := &fakeGateway{}
+	in := New(g, fixedClock(noon))
+	in.Add(ctx, AddRequest{Title: "a", Priority: 2}, &recorder{})
+
+	var r recorder
+	in.Complete(ctx, 1, &r)
+	if !r.todo.Done || !g.todos[0].Done.Equal(noon) {
+		t.Errorf("presented %+v, stored %+v", r.todo, g.todos[0])
+	}
+	for id, want := range map[int64]error{1: entity.ErrDone, 9: ErrNotFound} {
+		r := recorder{}
+		in.Complete(ctx, id, &r)
+		if !errors.Is(r.err, want) {
+			t.Errorf("Complete(%d) presented %+v", id, r)
+		}
+	}
+}
+
+func TestAgenda(t *testing.T) {
+	ctx := context.Background()
+	in := New(&fakeGateway{}, fixedClock(noon))
+	for _, req := range []AddRequest{
+		{Title: "low, undated", Priority: 3},
+		{Title: "normal, due later", Priority: 2, Due: day(20)},
+		{Title: "low, overdue", Priority: 3, Due: day(9)},
+		{Title: "high, done", Priority: 1},
+		{Title: "normal, due sooner", Priority: 2, Due: day(12)},
+		{Title: "normal, undated", Priority: 2},
+	} {
+		in.Add(ctx, req, &recorder{})
+	}
+	in.Complete(ctx, 4, &recorder{})
+
+	titles := func(all bool) string {
+		var r recorder
+		in.Agenda(ctx, all, &r)
+		var s []string
+		for _, t := range r.list {
+			s = append(s, t.Title)
+		}
+		return fmt.Sprintf("%q", s)
+	}
+	want := `["low, overdue" "normal, due sooner" "normal, due later" "normal, undated" "low, undated"]`
+	if got := titles(false); got != want {
+		t.Errorf("agenda %s", got)
+	}
+	if got := titles(true); got != want[:len(want)-1]+` "high, done"]` {
+		t.Errorf("full agenda %s", got)
+	}
+}
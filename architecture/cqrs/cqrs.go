@@ -0,0 +1,157 @@
+// Package cqrs separates the model that takes commands from the model
+// that answers queries, on a small order system.
+//
+// The write side, Orders, holds each order as an aggregate with its
+// status and enforces the rules: an order ships once, a shipped order
+// cannot be cancelled. Every accepted command becomes an Event, appended
+// to a log and published on an eventbus.EventBus in Async mode. The read
+// side, Projection, subscribes and folds the events into views shaped for
+// the questions asked of them, such as per customer totals, which the
+// write side never needs.
+//
+// The price is eventual consistency: a query right after a command may
+// not see it yet. Each command returns the sequence number of its event,
+// and Projection.WaitFor blocks until the view has caught up with it, for
+// callers that must read their own writes. A projection can also be
+// rebuilt from the log, which is how a new view is added to a running
+// system.
+package cqrs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/behavioral/observer/eventbus"
+)
+
+// Errors of the write side.
+var (
+	ErrExists   = errors.New("cqrs: order exists")
+	ErrNotFound = errors.New("cqrs: order not found")
+	ErrState    = errors.New("cqrs: not allowed in this state")
+	ErrInvalid  = errors.New("cqrs: invalid command")
+)
+
+// Status is the state of an order.
+type Status string
+
+// Statuses of an order.
+const (
+	Placed    Status = "placed"
+	Shipped   Status = "shipped"
+	Cancelled Status = "cancelled"
+)
+
+// Line is an item of an order.
+type Line struct {
+	SKU   string
+	Qty   int
+	Price int64 // cents per unit
+}
+
+// PlaceOrder is the command to place an order.
+type PlaceOrder struct {
+	OrderID  string
+	Customer string
+	Lines    []Line
+}
+
+// Event is something that happened to an order. Seq numbers the events
+// of an Orders from 1 without gaps.
+type Event struct {
+	Seq      uint64
+	OrderID  string
+	Kind     Status
+	Customer string
+	Total    int64
+	Lines    []Line
+	At       time.Time
+}
+
+// order is the aggregate of the write side: just what the rules need.
+type order struct {
+	customer string
+	status   Status
+}
+
+// Orders is the write model. It is safe for concurrent use.
+type Orders struct {
+	bus *eventbus.EventBus[Event]
+	now func() time.Time
+
+	mu     sync.Mutex
+	orders map[string]*order
+	log    []Event
+}
+
+// NewOrders returns an empty write model publishing on bus.
+func NewOrders(bus *eventbus.EventBus[Event]) *Orders {
+	return &Orders{bus: bus, now: time.Now, orders: map[string]*order{}}
+}
+
+// Place places an order and returns the sequence number of its event.
+func (o *Orders) Place(c PlaceOrder) (uint64, error) {
+	if c.OrderID == "" || c.Customer == "" || len(c.Lines) == 0 {
+		return 0, fmt.Errorf("%w: order needs an ID, a customer and lines", ErrInvalid)
+	}
+	var total int64
+	for _, l := range c.Lines {
+		if l.Qty <= 0 || l.Price < 0 {
+			return 0, fmt.Errorf("%w: line %s", ErrInvalid, l.SKU)
+		}
+		total += int64(l.Qty) * l.Price
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.orders[c.OrderID]; ok {
+		return 0, fmt.Errorf("%w: %s", ErrExists, c.OrderID)
+	}
+	o.orders[c.OrderID] = &order{customer: c.Customer, status: Placed}
+	return o.emit(Event{
+		OrderID: c.OrderID, Kind: Placed, Customer: c.Customer, Total: total,
+		Lines: append([]Line(nil), c.Lines...),
+	}), nil
+}
+
+// Ship ships a placed order.
+func (o *Orders) Ship(id string) (uint64, error) {
+	return o.transition(id, Shipped)
+}
+
+// Cancel cancels a placed order.
+func (o *Orders) Cancel(id string) (uint64, error) {
+	return o.transition(id, Cancelled)
+}
+
+func (o *Orders) transition(id string, to Status) (uint64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ord, ok := o.orders[id]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if ord.status != Placed {
+		return 0, fmt.Errorf("%w: %s is %s", ErrState, id, ord.status)
+	}
+	ord.status = to
+	return o.emit(Event{OrderID: id, Kind: to, Customer: ord.customer}), nil
+}
+
+// emit appends ev to the log and publishes it. It runs with o.mu held,
+// so events reach the bus in sequence order.
+func (o *Orders) emit(ev Event) uint64 {
+	ev.Seq = uint64(len(o.log)) + 1
+	ev.At = o.now()
+	o.log = append(o.log, ev)
+	o.bus.Publish(ev)
+	return ev.Seq
+}
+
+// Events returns the events so far, in order.
+func (o *Orders) Events() []Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Event(nil), o.log...)
+}
@@ -0,0 +1,227 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/behavioral/observer/eventbus"
+)
+
+func newBus(t *testing.T) *eventbus.EventBus[Event] {
+	bus := eventbus.New[Event](eventbus.Options{Mode: eventbus.Async})
+	t.Cleanup(bus.Close)
+	return bus
+}
+
+func place(id, customer string, qty int, price int64) PlaceOrder {
+	return PlaceOrder{OrderID: id, Customer: customer, Lines: []Line{{SKU: "sku-" + id, Qty: qty, Price: price}}}
+}
+
+func wait(t *testing.T, p *Projection, seq uint64) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.WaitFor(ctx, seq); err != nil {
+		t.Fatalf("projection stuck at %d waiting for %d: %v", p.Applied(), seq, err)
+	}
+}
+
+func TestRules(t *testing.T) {
+	orders := NewOrders(newBus(t))
+	for _, c := range []PlaceOrder{
+		{},
+		{OrderID: "o1", Customer: "ann"},
+		{OrderID: "o1", Customer: "ann", Lines: []Line{{SKU: "x", Qty: 0, Price: 1}}},
+	} {
+		if _, err := orders.Place(c); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Place(%+v) = %v", c, err)
+		}
+	}
+	orders.Place(place("o1", "ann", 1, 100))
+	if _, err := orders.Place(place("o1", "bob", 1, 100)); !errors.Is(err, ErrExists) {
+		t.Errorf("second Place = %v", err)
+	}
+	if _, err := orders.Ship("o2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ship(o2) = %v", err)
+	}
+	orders.Ship("o1")
+	if _, err := orders.Cancel("o1"); !errors.Is(err, ErrState) {
+		t.Errorf("Cancel of a shipped order = %v", err)
+	}
+	if n := len(orders.Events()); n != 2 {
+		t.Errorf("%d events, want 2: rejected commands must not emit", n)
+	}
+}
+
+// TestStaleRead holds the events back from the read side to show a query
+// that does not see a command yet, and WaitFor catching up.
+func TestStaleRead(t *testing.T) {
+	writes, reads := newBus(t), newBus(t)
+	gate := make(chan struct{})
+	writes.Subscribe(func(ev Event) {
+		<-gate
+		reads.Publish(ev)
+	})
+	orders := NewOrders(writes)
+	view := NewProjection(reads, nil)
+	defer view.Close()
+
+	seq, _ := orders.Place(place("o1", "ann", 2, 500))
+	if _, ok := view.Order("o1"); ok {
+		t.Fatal("the read side saw the order before the event reached it")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := view.WaitFor(ctx, seq); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitFor = %v", err)
+	}
+
+	close(gate)
+	wait(t, view, seq)
+	if o, ok := view.Order("o1"); !ok || o != (OrderView{"o1", "ann", Placed, 1000, 2}) {
+		t.Errorf("Order = %+v, %v", o, ok)
+	}
+}
+
+// reference folds events the plain way, to compare projections with.
+func reference(events []Event) (map[string]OrderView, map[string]CustomerView) {
+	orders := map[string]OrderView{}
+	for _, ev := range events {
+		o := orders[ev.OrderID]
+		if ev.Kind == Placed {
+			o = OrderView{ID: ev.OrderID, Customer: ev.Customer, Total: ev.Total}
+			for _, l := range ev.Lines {
+				o.Items += l.Qty
+			}
+		}
+		o.Status = ev.Kind
+		orders[ev.OrderID] = o
+	}
+	customers := map[string]CustomerView{}
+	for _, o := range orders {
+		c := customers[o.Customer]
+		c.Customer = o.Customer
+		c.Orders++
+		if o.Status == Placed {
+			c.Open++
+		}
+		if o.Status != Cancelled {
+			c.Spent += o.Total
+		}
+		customers[o.Customer] = c
+	}
+	return orders, customers
+}
+
+func check(t *testing.T, name string, p *Projection, events []Event) {
+	t.Helper()
+	orders, customers := reference(events)
+	for id, want := range orders {
+		if got, _ := p.Order(id); got != want {
+			t.Errorf("%s: order %s is %+v, want %+v", name, id, got, want)
+		}
+	}
+	for who, want := range customers {
+		if got, _ := p.Customer(who); got != want {
+			t.Errorf("%s: customer %s is %+v, want %+v", name, who, got, want)
+		}
+	}
+}
+
+// TestEventualConsistency runs commands from many goroutines and checks
+// that, once caught up, the projections agree with the write side: the
+// one following the bus from the start, and one built halfway through
+// from the log while commands keep coming.
+func TestEventualConsistency(t *testing.T) {
+	bus := newBus(t)
+	orders := NewOrders(bus)
+	live := NewProjection(bus, nil)
+	defer live.Close()
+
+	var (
+		wg   sync.WaitGroup
+		late *Projection
+		once sync.Once
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < 100; i++ {
+				id := fmt.Sprint("o", rng.Intn(200))
+				switch rng.Intn(3) {
+				case 0:
+					orders.Place(place(id, fmt.Sprint("c", rng.Intn(10)), 1+rng.Intn(3), int64(rng.Intn(1000))))
+				case 1:
+					orders.Ship(id)
+				case 2:
+					orders.Cancel(id)
+				}
+				if g == 0 && i == 50 {
+					once.Do(func() { late = NewProjection(bus, orders.Events()) })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	defer late.Close()
+
+	events := orders.Events()
+	last := events[len(events)-1].Seq
+	wait(t, live, last)
+	wait(t, late, last)
+	check(t, "live", live, events)
+	check(t, "late", late, events)
+
+	rebuilt := NewProjection(newBus(t), events)
+	check(t, "rebuilt", rebuilt, events)
+	if rebuilt.Applied() != last {
+		t.Errorf("rebuilt projection at %d, want %d", rebuilt.Applied(), last)
+	}
+}
+
+func TestApplyOrder(t *testing.T) {
+	p := NewProjection(newBus(t), nil)
+	placed := Event{Seq: 1, OrderID: "o1", Kind: Placed, Customer: "ann", Total: 300}
+	shipped := Event{Seq: 2, OrderID: "o1", Kind: Shipped, Customer: "ann"}
+	// Out of order and repeated.
+	p.Apply(shipped)
+	if p.Applied() != 0 {
+		t.Fatalf("applied %d before the event it follows", p.Applied())
+	}
+	p.Apply(placed)
+	p.Apply(placed)
+	if o, _ := p.Order("o1"); p.Applied() != 2 || o.Status != Shipped {
+		t.Errorf("applied %d, order %+v", p.Applied(), o)
+	}
+	if c, _ := p.Customer("ann"); c != (CustomerView{"ann", 1, 0, 300}) {
+		t.Errorf("customer %+v", c)
+	}
+}
+
+func Example() {
+	bus := eventbus.New[Event](eventbus.Options{Mode: eventbus.Async})
+	defer bus.Close()
+	orders := NewOrders(bus)
+	view := NewProjection(bus, nil)
+
+	orders.Place(PlaceOrder{OrderID: "o1", Customer: "ann", Lines: []Line{{"tea", 2, 450}}})
+	orders.Place(PlaceOrder{OrderID: "o2", Customer: "bob", Lines: []Line{{"mug", 1, 1200}}})
+	orders.Place(PlaceOrder{OrderID: "o3", Customer: "ann", Lines: []Line{{"pot", 1, 3000}}})
+	seq, _ := orders.Cancel("o3")
+
+	// Read your own writes: wait for the view to apply the last event.
+	view.WaitFor(context.Background(), seq)
+	for _, c := range view.TopCustomers(2) {
+		fmt.Printf("%s: %d orders, %d cents\n", c.Customer, c.Orders, c.Spent)
+	}
+	// Output:
+	// bob: 1 orders, 1200 cents
+	// ann: 2 orders, 900 cents
+}
@@ -0,0 +1,176 @@
+package cqrs
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/crazybber/go-patterns/behavioral/observer/eventbus"
+)
+
+// OrderView is an order as the read side shows it.
+type OrderView struct {
+	ID       string
+	Customer string
+	Status   Status
+	Total    int64
+	Items    int
+}
+
+// CustomerView sums up the orders of a customer.
+type CustomerView struct {
+	Customer string
+	Orders   int
+	Open     int
+	// Spent is the total of the orders not cancelled.
+	Spent int64
+}
+
+// Projection is a read model built from events. It is safe for
+// concurrent use.
+type Projection struct {
+	mu        sync.Mutex
+	changed   *sync.Cond
+	applied   uint64
+	pending   map[uint64]Event
+	orders    map[string]*OrderView
+	customers map[string]*CustomerView
+	sub       *eventbus.Subscription[Event]
+}
+
+// NewProjection returns a projection of the events in history followed by
+// those published on bus from now on. Pass the write model's Events to
+// build it for a running system, or nil for a new one.
+func NewProjection(bus *eventbus.EventBus[Event], history []Event) *Projection {
+	p := &Projection{
+		pending:   map[uint64]Event{},
+		orders:    map[string]*OrderView{},
+		customers: map[string]*CustomerView{},
+	}
+	p.changed = sync.NewCond(&p.mu)
+	// Subscribe first so no event falls between the history and the bus;
+	// Apply puts the events in order.
+	p.sub = bus.Subscribe(p.Apply)
+	for _, ev := range history {
+		p.Apply(ev)
+	}
+	return p
+}
+
+// Apply folds ev into the views. Events are applied in sequence order:
+// one that arrives early waits for those before it, and one already
+// applied is ignored, so replaying a log that overlaps the live stream is
+// harmless.
+func (p *Projection) Apply(ev Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ev.Seq <= p.applied {
+		return
+	}
+	p.pending[ev.Seq] = ev
+	for {
+		next, ok := p.pending[p.applied+1]
+		if !ok {
+			break
+		}
+		delete(p.pending, next.Seq)
+		p.apply(next)
+		p.applied = next.Seq
+	}
+	p.changed.Broadcast()
+}
+
+func (p *Projection) apply(ev Event) {
+	c := p.customers[ev.Customer]
+	if c == nil {
+		c = &CustomerView{Customer: ev.Customer}
+		p.customers[ev.Customer] = c
+	}
+	if ev.Kind == Placed {
+		items := 0
+		for _, l := range ev.Lines {
+			items += l.Qty
+		}
+		p.orders[ev.OrderID] = &OrderView{ID: ev.OrderID, Customer: ev.Customer, Status: Placed, Total: ev.Total, Items: items}
+		c.Orders++
+		c.Open++
+		c.Spent += ev.Total
+		return
+	}
+	o := p.orders[ev.OrderID]
+	o.Status = ev.Kind
+	c.Open--
+	if ev.Kind == Cancelled {
+		c.Spent -= o.Total
+	}
+}
+
+// Applied returns the sequence number of the last event applied.
+func (p *Projection) Applied() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.applied
+}
+
+// WaitFor blocks until the event numbered seq has been applied, or ctx is
+// done.
+func (p *Projection) WaitFor(ctx context.Context, seq uint64) error {
+	stop := context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.changed.Broadcast()
+	})
+	defer stop()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.applied < seq {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p.changed.Wait()
+	}
+	return nil
+}
+
+// Order returns the view of an order.
+func (p *Projection) Order(id string) (OrderView, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	o, ok := p.orders[id]
+	if !ok {
+		return OrderView{}, false
+	}
+	return *o, true
+}
+
+// Customer returns the summary of a customer.
+func (p *Projection) Customer(name string) (CustomerView, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.customers[name]
+	if !ok {
+		return CustomerView{}, false
+	}
+	return *c, true
+}
+
+// TopCustomers returns the n customers who spent most, by name on ties.
+func (p *Projection) TopCustomers(n int) []CustomerView {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all := make([]CustomerView, 0, len(p.customers))
+	for _, c := range p.customers {
+		all = append(all, *c)
+	}
+	slices.SortFunc(all, func(a, b CustomerView) int {
+		if c := cmp.Compare(b.Spent, a.Spent); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Customer, b.Customer)
+	})
+	return all[:min(n, len(all))]
+}
+
+// Close stops following the bus.
+func (p *Projection) Close() { p.sub.Unsubscribe() }
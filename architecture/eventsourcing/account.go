@@ -0,0 +1,121 @@
+package eventsourcing
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Errors of an Account.
+var (
+	ErrClosed            = errors.New("eventsourcing: account closed")
+	ErrOpened            = errors.New("eventsourcing: account already opened")
+	ErrInsufficientFunds = errors.New("eventsourcing: insufficient funds")
+	ErrAmount            = errors.New("eventsourcing: amount must be positive")
+	ErrBalance           = errors.New("eventsourcing: balance not zero")
+)
+
+// Events of an Account.
+type (
+	Opened    struct{ Owner string }
+	Deposited struct{ Amount int64 }
+	Withdrawn struct{ Amount int64 }
+	Closed    struct{}
+)
+
+func (Opened) EventType() string    { return "account.opened" }
+func (Deposited) EventType() string { return "account.deposited" }
+func (Withdrawn) EventType() string { return "account.withdrawn" }
+func (Closed) EventType() string    { return "account.closed" }
+
+// Account is a bank account rebuilt from its events.
+type Account struct {
+	Base
+	state accountState
+}
+
+type accountState struct {
+	Owner   string `json:"owner"`
+	Balance int64  `json:"balance"`
+	Open    bool   `json:"open"`
+}
+
+// NewAccount returns an empty Account, for a Repository.
+func NewAccount() *Account { return &Account{} }
+
+// Owner returns the account's owner.
+func (a *Account) Owner() string { return a.state.Owner }
+
+// Balance returns the balance in cents.
+func (a *Account) Balance() int64 { return a.state.Balance }
+
+// Open opens the account for owner.
+func (a *Account) Open(owner string) error {
+	if a.state.Open || a.Version() > 0 {
+		return ErrOpened
+	}
+	Raise(a, Opened{Owner: owner})
+	return nil
+}
+
+// Deposit adds amount cents.
+func (a *Account) Deposit(amount int64) error {
+	if err := a.check(amount); err != nil {
+		return err
+	}
+	Raise(a, Deposited{Amount: amount})
+	return nil
+}
+
+// Withdraw takes amount cents out, if the balance covers it.
+func (a *Account) Withdraw(amount int64) error {
+	if err := a.check(amount); err != nil {
+		return err
+	}
+	if amount > a.state.Balance {
+		return ErrInsufficientFunds
+	}
+	Raise(a, Withdrawn{Amount: amount})
+	return nil
+}
+
+// Close closes an account with nothing in it.
+func (a *Account) Close() error {
+	if !a.state.Open {
+		return ErrClosed
+	}
+	if a.state.Balance != 0 {
+		return ErrBalance
+	}
+	Raise(a, Closed{})
+	return nil
+}
+
+func (a *Account) check(amount int64) error {
+	if !a.state.Open {
+		return ErrClosed
+	}
+	if amount <= 0 {
+		return ErrAmount
+	}
+	return nil
+}
+
+// Apply changes the state by one event.
+func (a *Account) Apply(ev Event) {
+	switch ev := ev.(type) {
+	case Opened:
+		a.state = accountState{Owner: ev.Owner, Open: true}
+	case Deposited:
+		a.state.Balance += ev.Amount
+	case Withdrawn:
+		a.state.Balance -= ev.Amount
+	case Closed:
+		a.state.Open = false
+	}
+}
+
+// MarshalState encodes the state for a snapshot.
+func (a *Account) MarshalState() ([]byte, error) { return json.Marshal(a.state) }
+
+// UnmarshalState restores the state from a snapshot.
+func (a *Account) UnmarshalState(data []byte) error { return json.Unmarshal(data, &a.state) }
@@ -0,0 +1,134 @@
+package eventsourcing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by Repository.Load for a stream with no events.
+var ErrNotFound = errors.New("eventsourcing: aggregate not found")
+
+// Aggregate is state rebuilt from events. Embed a Base to get the
+// bookkeeping and implement Apply.
+type Aggregate interface {
+	// Apply changes the state by one event. It must not fail: events are
+	// facts, already checked when they were recorded.
+	Apply(Event)
+	base() *Base
+}
+
+// Base tracks the version of an aggregate and the events it recorded but
+// did not save yet.
+type Base struct {
+	id      string
+	version int
+	pending []Event
+}
+
+func (b *Base) base() *Base { return b }
+
+// ID returns the aggregate's ID, the name of its stream.
+func (b *Base) ID() string { return b.id }
+
+// Version returns the version of the stream the aggregate was loaded or
+// saved at, not counting pending events.
+func (b *Base) Version() int { return b.version }
+
+// Pending returns the events recorded since the aggregate was loaded.
+func (b *Base) Pending() []Event { return b.pending }
+
+// Raise applies ev to a and keeps it for the next save. Commands call
+// it once they have checked that ev may happen.
+func Raise(a Aggregate, ev Event) {
+	a.Apply(ev)
+	b := a.base()
+	b.pending = append(b.pending, ev)
+}
+
+// Snapshotter is an Aggregate whose state can be saved in a snapshot.
+type Snapshotter interface {
+	Aggregate
+	MarshalState() ([]byte, error)
+	UnmarshalState([]byte) error
+}
+
+// Repository loads and saves aggregates of type A.
+type Repository[A Aggregate] struct {
+	events EventStore
+	newA   func() A
+	// Snapshots, if set, receives a snapshot whenever a save crosses a
+	// multiple of SnapshotEvery versions. A must be a Snapshotter.
+	Snapshots     SnapshotStore
+	SnapshotEvery int
+}
+
+// NewRepository returns a Repository keeping events in store and making
+// empty aggregates with newA.
+func NewRepository[A Aggregate](store EventStore, newA func() A) *Repository[A] {
+	return &Repository[A]{events: store, newA: newA}
+}
+
+// New returns an empty aggregate for a new stream id.
+func (r *Repository[A]) New(id string) A {
+	a := r.newA()
+	a.base().id = id
+	return a
+}
+
+// Load rehydrates the aggregate of stream id: from its latest snapshot,
+// if there is one, and the events after it.
+func (r *Repository[A]) Load(ctx context.Context, id string) (A, error) {
+	a := r.New(id)
+	b := a.base()
+	if r.Snapshots != nil {
+		snap, ok, err := r.Snapshots.LoadSnapshot(ctx, id)
+		if err != nil {
+			return a, err
+		}
+		if s, canRestore := any(a).(Snapshotter); ok && canRestore {
+			if err := s.UnmarshalState(snap.State); err != nil {
+				return a, fmt.Errorf("eventsourcing: snapshot of %s: %w", id, err)
+			}
+			b.version = snap.Version
+		}
+	}
+	recs, err := r.events.Load(ctx, id, b.version)
+	if err != nil {
+		return a, err
+	}
+	for _, rec := range recs {
+		a.Apply(rec.Event)
+		b.version = rec.Version
+	}
+	if b.version == 0 {
+		return a, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return a, nil
+}
+
+// Save appends the pending events of a, expecting its stream to be at the
+// version a was loaded at. It fails with ErrConflict if the stream moved
+// on; reload the aggregate and run the command again.
+func (r *Repository[A]) Save(ctx context.Context, a A) error {
+	b := a.base()
+	if len(b.pending) == 0 {
+		return nil
+	}
+	version, err := r.events.Append(ctx, b.id, b.version, b.pending...)
+	if err != nil {
+		return err
+	}
+	crossed := r.SnapshotEvery > 0 && version/r.SnapshotEvery > b.version/r.SnapshotEvery
+	b.version, b.pending = version, nil
+	if crossed && r.Snapshots != nil {
+		if s, ok := any(a).(Snapshotter); ok {
+			state, err := s.MarshalState()
+			if err != nil {
+				return err
+			}
+			return r.Snapshots.SaveSnapshot(ctx, b.id, Snapshot{Version: version, State: state})
+		}
+	}
+	return nil
+}
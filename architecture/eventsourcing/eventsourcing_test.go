@@ -0,0 +1,234 @@
+package eventsourcing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// countingStore counts the records loaded, to see what snapshots save.
+type countingStore struct {
+	EventStore
+	loaded int
+}
+
+func (s *countingStore) Load(ctx context.Context, stream string, from int) ([]Record, error) {
+	recs, err := s.EventStore.Load(ctx, stream, from)
+	s.loaded += len(recs)
+	return recs, err
+}
+
+func TestAppendAndLoad(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	v, err := s.Append(ctx, "a", NoStream, Opened{"ann"}, Deposited{100})
+	if err != nil || v != 2 {
+		t.Fatalf("Append = %d, %v", v, err)
+	}
+	_, err = s.Append(ctx, "a", NoStream, Opened{"bob"})
+	var ce *ConflictError
+	if !errors.As(err, &ce) || !errors.Is(err, ErrConflict) || ce.Actual != 2 || ce.Expected != 0 {
+		t.Errorf("Append to an existing stream = %v", err)
+	}
+	if v, err := s.Append(ctx, "a", Any, Withdrawn{30}); err != nil || v != 3 {
+		t.Errorf("Append with Any = %d, %v", v, err)
+	}
+	recs, _ := s.Load(ctx, "a", 1)
+	if len(recs) != 2 || recs[0].Version != 2 || recs[1].Event != (Withdrawn{30}) {
+		t.Errorf("Load from 1 = %+v", recs)
+	}
+	if recs, _ := s.Load(ctx, "a", 9); len(recs) != 0 {
+		t.Errorf("Load past the end = %+v", recs)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepository(NewMemoryStore(), NewAccount)
+	a := repo.New("acc-1")
+	a.Open("ann")
+	a.Deposit(500)
+	a.Withdraw(200)
+	if err := a.Withdraw(400); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("overdraft: %v", err)
+	}
+	if len(a.Pending()) != 3 {
+		t.Fatalf("%d pending events, want 3: a refused command must not record", len(a.Pending()))
+	}
+	if err := repo.Save(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := repo.Load(ctx, "acc-1")
+	if err != nil || b.Owner() != "ann" || b.Balance() != 300 || b.Version() != 3 || len(b.Pending()) != 0 {
+		t.Errorf("Load = %s %d at %d, %v", b.Owner(), b.Balance(), b.Version(), err)
+	}
+	if _, err := repo.Load(ctx, "acc-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load of a missing stream = %v", err)
+	}
+
+	b.Withdraw(300)
+	b.Close()
+	repo.Save(ctx, b)
+	c, _ := repo.Load(ctx, "acc-1")
+	if err := c.Deposit(1); !errors.Is(err, ErrClosed) {
+		t.Errorf("Deposit to a closed account = %v", err)
+	}
+	if err := c.Open("bob"); !errors.Is(err, ErrOpened) {
+		t.Errorf("reopening = %v", err)
+	}
+}
+
+// TestConflict loads one account twice and saves both: the second save
+// is refused, and retrying after a reload decides on fresh state.
+func TestConflict(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepository(NewMemoryStore(), NewAccount)
+	a := repo.New("acc")
+	a.Open("ann")
+	a.Deposit(100)
+	repo.Save(ctx, a)
+
+	x, _ := repo.Load(ctx, "acc")
+	y, _ := repo.Load(ctx, "acc")
+	x.Withdraw(80)
+	y.Withdraw(80)
+	if err := repo.Save(ctx, x); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save(ctx, y); !errors.Is(err, ErrConflict) {
+		t.Fatalf("second Save = %v", err)
+	}
+	y, _ = repo.Load(ctx, "acc")
+	if err := y.Withdraw(80); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("retry = %v", err)
+	}
+}
+
+// TestConcurrentWithdrawals races goroutines for one account, each
+// reloading and retrying on conflict. The balance never goes negative
+// and every cent is accounted for.
+func TestConcurrentWithdrawals(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepository(NewMemoryStore(), NewAccount)
+	a := repo.New("acc")
+	a.Open("ann")
+	a.Deposit(1000)
+	repo.Save(ctx, a)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		withdrawn int64
+		conflicts int
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				for {
+					acc, err := repo.Load(ctx, "acc")
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if acc.Withdraw(10) != nil {
+						return
+					}
+					err = repo.Save(ctx, acc)
+					mu.Lock()
+					if err == nil {
+						withdrawn += 10
+					} else if errors.Is(err, ErrConflict) {
+						conflicts++
+					}
+					mu.Unlock()
+					if err == nil {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	acc, _ := repo.Load(ctx, "acc")
+	if acc.Balance() != 0 || withdrawn != 1000 {
+		t.Errorf("balance %d after withdrawing %d", acc.Balance(), withdrawn)
+	}
+	t.Logf("%d conflicts retried", conflicts)
+}
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	events := &countingStore{EventStore: NewMemoryStore()}
+	snaps := NewMemorySnapshots()
+	repo := NewRepository(events, NewAccount)
+	repo.Snapshots, repo.SnapshotEvery = snaps, 10
+	plain := NewRepository(events.EventStore, NewAccount)
+
+	rng := rand.New(rand.NewSource(1))
+	a := repo.New("acc")
+	a.Open("ann")
+	repo.Save(ctx, a)
+	for i := 0; i < 95; i++ {
+		a, _ = repo.Load(ctx, "acc")
+		// Several events per save now and then, to cross multiples of
+		// SnapshotEvery in the middle of a batch.
+		for n := 1 + rng.Intn(3); n > 0; n-- {
+			if rng.Intn(3) == 0 {
+				a.Withdraw(int64(rng.Intn(50)) + 1)
+			} else {
+				a.Deposit(int64(rng.Intn(100)) + 1)
+			}
+		}
+		if err := repo.Save(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snap, ok, _ := snaps.LoadSnapshot(ctx, "acc")
+	if !ok || a.Version()-snap.Version >= 10 {
+		t.Fatalf("snapshot at %d for version %d", snap.Version, a.Version())
+	}
+	events.loaded = 0
+	fromSnap, err := repo.Load(ctx, "acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := a.Version() - snap.Version; events.loaded != want {
+		t.Errorf("loaded %d events after the snapshot, want %d", events.loaded, want)
+	}
+	full, _ := plain.Load(ctx, "acc")
+	if fromSnap.Balance() != full.Balance() || fromSnap.Version() != full.Version() || fromSnap.Owner() != "ann" {
+		t.Errorf("from snapshot %d at %d, replayed %d at %d",
+			fromSnap.Balance(), fromSnap.Version(), full.Balance(), full.Version())
+	}
+}
+
+func Example() {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	accounts := NewRepository(store, NewAccount)
+
+	a := accounts.New("acc-42")
+	a.Open("ann")
+	a.Deposit(1000)
+	a.Withdraw(250)
+	accounts.Save(ctx, a)
+
+	recs, _ := store.Load(ctx, "acc-42", 0)
+	for _, r := range recs {
+		fmt.Printf("%d %s %+v\n", r.Version, r.Event.EventType(), r.Event)
+	}
+	a, _ = accounts.Load(ctx, "acc-42")
+	fmt.Println(a.Balance())
+	// Output:
+	// 1 account.opened {Owner:ann}
+	// 2 account.deposited {Amount:1000}
+	// 3 account.withdrawn {Amount:250}
+	// 750
+}
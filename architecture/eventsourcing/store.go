@@ -0,0 +1,149 @@
+// Package eventsourcing stores the state of an aggregate as the sequence
+// of events that changed it, on the example of a bank account.
+//
+// An EventStore keeps one stream of events per aggregate. Append takes the
+// version the writer last saw and fails with a *ConflictError if somebody
+// else appended since, which is optimistic concurrency: no locks are held
+// between loading an aggregate and saving it, and a writer that lost the
+// race reloads and decides again. Repository loads an aggregate by
+// replaying its stream onto a fresh value, rehydration, and saves the
+// events it recorded since.
+//
+// Replaying gets slower as a stream grows, so a Repository given a
+// SnapshotStore saves the aggregate's state every so many versions and
+// then loads the latest snapshot and only the events after it.
+package eventsourcing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event is a fact about an aggregate, named by its type.
+type Event interface {
+	EventType() string
+}
+
+// Record is an event as stored, at a version of its stream. The first
+// event of a stream is version 1.
+type Record struct {
+	Stream  string
+	Version int
+	Event   Event
+	At      time.Time
+}
+
+// Expected versions for Append besides a stream's version.
+const (
+	// Any appends whatever the version of the stream.
+	Any = -1
+	// NoStream appends only to a stream that does not exist yet.
+	NoStream = 0
+)
+
+// ErrConflict is matched by a *ConflictError.
+var ErrConflict = errors.New("eventsourcing: version conflict")
+
+// ConflictError reports an Append whose expected version was not the
+// version of the stream.
+type ConflictError struct {
+	Stream           string
+	Expected, Actual int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("eventsourcing: stream %s is at version %d, expected %d", e.Stream, e.Actual, e.Expected)
+}
+
+// Is makes errors.Is(err, ErrConflict) hold.
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// EventStore keeps streams of events.
+type EventStore interface {
+	// Append adds events to stream if it is at version expected, or
+	// expected is Any, and returns the new version.
+	Append(ctx context.Context, stream string, expected int, events ...Event) (int, error)
+	// Load returns the records of stream after version from, in order.
+	Load(ctx context.Context, stream string, from int) ([]Record, error)
+}
+
+// MemoryStore is an EventStore in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	streams map[string][]Record
+	now     func() time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{streams: map[string][]Record{}, now: time.Now}
+}
+
+// Append adds events to stream if it is at version expected.
+func (s *MemoryStore) Append(_ context.Context, stream string, expected int, events ...Event) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recs := s.streams[stream]
+	if expected != Any && expected != len(recs) {
+		return len(recs), &ConflictError{Stream: stream, Expected: expected, Actual: len(recs)}
+	}
+	at := s.now()
+	for _, ev := range events {
+		recs = append(recs, Record{Stream: stream, Version: len(recs) + 1, Event: ev, At: at})
+	}
+	s.streams[stream] = recs
+	return len(recs), nil
+}
+
+// Load returns the records of stream after version from.
+func (s *MemoryStore) Load(_ context.Context, stream string, from int) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	recs := s.streams[stream]
+	return append([]Record(nil), recs[min(max(from, 0), len(recs)):]...), nil
+}
+
+// Snapshot is the state of an aggregate at a version.
+type Snapshot struct {
+	Version int
+	State   []byte
+}
+
+// SnapshotStore keeps the latest snapshot of each stream.
+type SnapshotStore interface {
+	SaveSnapshot(ctx context.Context, stream string, s Snapshot) error
+	// LoadSnapshot returns false if stream has no snapshot.
+	LoadSnapshot(ctx context.Context, stream string) (Snapshot, bool, error)
+}
+
+// MemorySnapshots is a SnapshotStore in memory.
+type MemorySnapshots struct {
+	mu        sync.Mutex
+	snapshots map[string]Snapshot
+}
+
+// NewMemorySnapshots returns an empty MemorySnapshots.
+func NewMemorySnapshots() *MemorySnapshots {
+	return &MemorySnapshots{snapshots: map[string]Snapshot{}}
+}
+
+// SaveSnapshot keeps s unless a later snapshot is kept already.
+func (m *MemorySnapshots) SaveSnapshot(_ context.Context, stream string, s Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.snapshots[stream]; !ok || s.Version > old.Version {
+		m.snapshots[stream] = s
+	}
+	return nil
+}
+
+// LoadSnapshot returns the latest snapshot of stream.
+func (m *MemorySnapshots) LoadSnapshot(_ context.Context, stream string) (Snapshot, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.snapshots[stream]
+	return s, ok, nil
+}
@@ -0,0 +1,71 @@
+// Package cli drives the to-do application from command-line arguments:
+//
+//	add TITLE...   creates a task
+//	done ID        completes a task
+//	list [-a]      lists the open tasks, or with -a all of them
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/crazybber/go-patterns/architecture/hexagonal/domain"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/ports"
+)
+
+// ErrUsage is returned for arguments Run does not understand.
+var ErrUsage = errors.New("usage: add TITLE... | done ID | list [-a]")
+
+// Run runs the command in args, writing its output to out.
+func Run(ctx context.Context, tasks ports.Tasks, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "add" && len(args) > 0:
+		t, err := tasks.Add(ctx, strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "added %d\n", t.ID)
+		return err
+	case cmd == "done" && len(args) == 1:
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return ErrUsage
+		}
+		if _, err := tasks.Complete(ctx, id); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "done %d\n", id)
+		return err
+	case cmd == "list" && len(args) == 0, cmd == "list" && len(args) == 1 && args[0] == "-a":
+		list := tasks.Open
+		if len(args) == 1 {
+			list = tasks.All
+		}
+		ts, err := list(ctx)
+		if err != nil {
+			return err
+		}
+		for _, t := range ts {
+			if _, err := fmt.Fprintln(out, line(t)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return ErrUsage
+}
+
+func line(t domain.Task) string {
+	mark := " "
+	if t.IsDone() {
+		mark = "x"
+	}
+	return fmt.Sprintf("[%s] %d %s", mark, t.ID, t.Title)
+}
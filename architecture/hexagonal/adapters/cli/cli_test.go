@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/hexagonal/adapters/memstore"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/core"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/domain"
+)
+
+type clock struct{}
+
+func (clock) Now() time.Time { return time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC) }
+
+// TestSession wires the command line to the real core and store, as the
+// composition root does.
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	tasks := core.New(memstore.New(), clock{})
+	var out strings.Builder
+	for _, args := range [][]string{
+		{"add", "buy", "milk"},
+		{"add", "call mum"},
+		{"done", "1"},
+		{"list"},
+		{"list", "-a"},
+	} {
+		if err := Run(ctx, tasks, args, &out); err != nil {
+			t.Fatalf("%q: %v", args, err)
+		}
+	}
+	want := "added 1\nadded 2\ndone 1\n[ ] 2 call mum\n[x] 1 buy milk\n[ ] 2 call mum\n"
+	if out.String() != want {
+		t.Errorf("output:\n%s\nwant\n%s", out.String(), want)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want error
+	}{
+		{nil, ErrUsage},
+		{[]string{"add"}, ErrUsage},
+		{[]string{"done", "one"}, ErrUsage},
+		{[]string{"list", "-x"}, ErrUsage},
+		{[]string{"done", "1"}, domain.ErrAlreadyDone},
+		{[]string{"done", "5"}, domain.ErrTaskNotFound},
+		{[]string{"add", " "}, domain.ErrEmptyTitle},
+	} {
+		if err := Run(ctx, tasks, tc.args, &out); !errors.Is(err, tc.want) {
+			t.Errorf("%q: %v, want %v", tc.args, err, tc.want)
+		}
+	}
+}
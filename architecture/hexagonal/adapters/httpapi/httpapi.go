@@ -0,0 +1,103 @@
+// Package httpapi drives the to-do application over HTTP with JSON:
+//
+//	GET  /tasks             every task; ?open=1 for the open ones
+//	POST /tasks             {"title": "..."} creates a task
+//	POST /tasks/{id}/done   completes a task
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/hexagonal/domain"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/ports"
+)
+
+// Task is the JSON form of a domain.Task.
+type Task struct {
+	ID      int64      `json:"id"`
+	Title   string     `json:"title"`
+	Created time.Time  `json:"created"`
+	Done    *time.Time `json:"done,omitempty"`
+}
+
+func fromDomain(t domain.Task) Task {
+	res := Task{ID: t.ID, Title: t.Title, Created: t.Created}
+	if t.IsDone() {
+		res.Done = &t.Done
+	}
+	return res
+}
+
+// Handler returns the HTTP API of tasks.
+func Handler(tasks ports.Tasks) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks", func(w http.ResponseWriter, r *http.Request) {
+		list := tasks.All
+		if r.URL.Query().Get("open") != "" {
+			list = tasks.Open
+		}
+		ts, err := list(r.Context())
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		res := make([]Task, len(ts))
+		for i, t := range ts {
+			res[i] = fromDomain(t)
+		}
+		reply(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("POST /tasks", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Title string `json:"title"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			reply(w, http.StatusBadRequest, map[string]string{"error": "bad JSON: " + err.Error()})
+			return
+		}
+		t, err := tasks.Add(r.Context(), req.Title)
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		reply(w, http.StatusCreated, fromDomain(t))
+	})
+	mux.HandleFunc("POST /tasks/{id}/done", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			reply(w, http.StatusBadRequest, map[string]string{"error": "bad task ID"})
+			return
+		}
+		t, err := tasks.Complete(r.Context(), id)
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		reply(w, http.StatusOK, fromDomain(t))
+	})
+	return mux
+}
+
+// fail maps the errors of the domain to HTTP status codes.
+func fail(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, domain.ErrTaskNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, domain.ErrEmptyTitle), errors.Is(err, domain.ErrTitleTooLong):
+		code = http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrAlreadyDone), errors.Is(err, domain.ErrTooManyActive):
+		code = http.StatusConflict
+	}
+	reply(w, code, map[string]string{"error": err.Error()})
+}
+
+func reply(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
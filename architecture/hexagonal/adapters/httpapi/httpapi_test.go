@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/hexagonal/domain"
+)
+
+var created = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// stubTasks answers like the core would, so the adapter is tested on its
+// own: does it turn requests into calls and results into responses.
+type stubTasks struct{ calls []string }
+
+func (s *stubTasks) Add(_ context.Context, title string) (domain.Task, error) {
+	s.calls = append(s.calls, "Add "+title)
+	if title == "" {
+		return domain.Task{}, domain.ErrEmptyTitle
+	}
+	return domain.Task{ID: 7, Title: title, Created: created}, nil
+}
+
+func (s *stubTasks) Complete(_ context.Context, id int64) (domain.Task, error) {
+	s.calls = append(s.calls, fmt.Sprint("Complete ", id))
+	switch id {
+	case 1:
+		return domain.Task{}, domain.ErrAlreadyDone
+	case 7:
+		return domain.Task{ID: 7, Title: "t", Created: created, Done: created.Add(time.Hour)}, nil
+	}
+	return domain.Task{}, domain.ErrTaskNotFound
+}
+
+func (s *stubTasks) Open(context.Context) ([]domain.Task, error) {
+	s.calls = append(s.calls, "Open")
+	return []domain.Task{{ID: 2, Title: "open", Created: created}}, nil
+}
+
+func (s *stubTasks) All(context.Context) ([]domain.Task, error) {
+	s.calls = append(s.calls, "All")
+	return nil, fmt.Errorf("store down")
+}
+
+func TestHandler(t *testing.T) {
+	for _, tc := range []struct {
+		method, target, body string
+		code                 int
+		want, call           string
+	}{
+		{"POST", "/tasks", `{"title":"buy milk"}`, 201,
+			`{"id":7,"title":"buy milk","created":"2024-01-01T09:00:00Z"}`, "Add buy milk"},
+		{"POST", "/tasks", `{"title":""}`, 422, `{"error":"domain: empty title"}`, "Add "},
+		{"POST", "/tasks", `{`, 400, `"error":"bad JSON`, ""},
+		{"POST", "/tasks/7/done", "", 200, `"done":"2024-01-01T10:00:00Z"`, "Complete 7"},
+		{"POST", "/tasks/1/done", "", 409, `already done`, "Complete 1"},
+		{"POST", "/tasks/9/done", "", 404, `not found`, "Complete 9"},
+		{"POST", "/tasks/x/done", "", 400, `bad task ID`, ""},
+		{"GET", "/tasks?open=1", "", 200, `[{"id":2,"title":"open","created":"2024-01-01T09:00:00Z"}]`, "Open"},
+		{"GET", "/tasks", "", 500, `store down`, "All"},
+		{"DELETE", "/tasks", "", 405, ``, ""},
+	} {
+		stub := &stubTasks{}
+		rec := httptest.NewRecorder()
+		Handler(stub).ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		name := tc.method + " " + tc.target
+		if rec.Code != tc.code || !strings.Contains(rec.Body.String(), tc.want) {
+			t.Errorf("%s: %d %s, want %d %s", name, rec.Code, rec.Body, tc.code, tc.want)
+		}
+		if got := strings.Join(stub.calls, ";"); got != tc.call {
+			t.Errorf("%s: called %q, want %q", name, got, tc.call)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler(&stubTasks{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks?open=1", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q", ct)
+	}
+}
@@ -0,0 +1,69 @@
+// Package memstore is a ports.Store keeping tasks in memory.
+package memstore
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/crazybber/go-patterns/architecture/hexagonal/domain"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/ports"
+)
+
+// Store keeps tasks in a map. It is safe for concurrent use.
+type Store struct {
+	mu     sync.Mutex
+	nextID int64
+	tasks  map[int64]domain.Task
+}
+
+var _ ports.Store = (*Store)(nil)
+
+// New returns an empty Store.
+func New() *Store {
+	return &Store{tasks: map[int64]domain.Task{}}
+}
+
+// Insert stores t under a new ID.
+func (s *Store) Insert(_ context.Context, t domain.Task) (domain.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	t.ID = s.nextID
+	s.tasks[t.ID] = t
+	return t, nil
+}
+
+// Get returns the task with the given ID.
+func (s *Store) Get(_ context.Context, id int64) (domain.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return domain.Task{}, domain.ErrTaskNotFound
+	}
+	return t, nil
+}
+
+// Update replaces the task with t's ID.
+func (s *Store) Update(_ context.Context, t domain.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[t.ID]; !ok {
+		return domain.ErrTaskNotFound
+	}
+	s.tasks[t.ID] = t
+	return nil
+}
+
+// List returns every task in ID order.
+func (s *Store) List(_ context.Context) ([]domain.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := make([]domain.Task, 0, len(s.tasks))
+	for _, id := range slices.Sorted(maps.Keys(s.tasks)) {
+		tasks = append(tasks, s.tasks[id])
+	}
+	return tasks, nil
+}
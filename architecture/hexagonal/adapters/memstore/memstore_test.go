@@ -0,0 +1,35 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/crazybber/go-patterns/architecture/hexagonal/domain"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	a, _ := s.Insert(ctx, domain.Task{Title: "a"})
+	b, _ := s.Insert(ctx, domain.Task{Title: "b"})
+	if a.ID != 1 || b.ID != 2 {
+		t.Fatalf("IDs %d and %d", a.ID, b.ID)
+	}
+	b.Title = "b2"
+	if err := s.Update(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Get(ctx, 2); err != nil || got.Title != "b2" {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+	if _, err := s.Get(ctx, 3); !errors.Is(err, domain.ErrTaskNotFound) {
+		t.Errorf("Get(3) = %v", err)
+	}
+	if err := s.Update(ctx, domain.Task{ID: 3}); !errors.Is(err, domain.ErrTaskNotFound) {
+		t.Errorf("Update(3) = %v", err)
+	}
+	if ts, _ := s.List(ctx); len(ts) != 2 || ts[0].ID != 1 || ts[1].ID != 2 {
+		t.Errorf("List = %+v", ts)
+	}
+}
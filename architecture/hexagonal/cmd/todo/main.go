@@ -0,0 +1,47 @@
+// Command todo is the composition root of the hexagonal to-do example: it
+// picks the adapters and wires them to the core. With arguments it runs
+// them as a command against a store it seeds with examples; with -http it
+// serves the HTTP API instead.
+//
+//	go run ./architecture/hexagonal/cmd/todo list -a
+//	go run ./architecture/hexagonal/cmd/todo -http :8080
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/hexagonal/adapters/cli"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/adapters/httpapi"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/adapters/memstore"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/core"
+)
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func main() {
+	addr := flag.String("http", "", "serve the HTTP API on `addr`")
+	flag.Parse()
+
+	ctx := context.Background()
+	tasks := core.New(memstore.New(), systemClock{})
+	for _, title := range []string{"write the domain", "define the ports", "plug in adapters"} {
+		tasks.Add(ctx, title)
+	}
+	tasks.Complete(ctx, 1)
+
+	if *addr != "" {
+		log.Fatal(http.ListenAndServe(*addr, httpapi.Handler(tasks)))
+	}
+	if err := cli.Run(ctx, tasks, flag.Args(), os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
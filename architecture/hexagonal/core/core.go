@@ -0,0 +1,76 @@
+// Package core implements the use cases of the to-do application on top
+// of its driven ports.
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crazybber/go-patterns/architecture/hexagonal/domain"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/ports"
+)
+
+// Service implements ports.Tasks.
+type Service struct {
+	store ports.Store
+	clock ports.Clock
+}
+
+var _ ports.Tasks = (*Service)(nil)
+
+// New returns a Service keeping tasks in store.
+func New(store ports.Store, clock ports.Clock) *Service {
+	return &Service{store: store, clock: clock}
+}
+
+// Add creates an open task, failing with domain.ErrTooManyActive once
+// domain.MaxOpen tasks are open.
+func (s *Service) Add(ctx context.Context, title string) (domain.Task, error) {
+	t, err := domain.NewTask(title, s.clock.Now())
+	if err != nil {
+		return domain.Task{}, err
+	}
+	open, err := s.Open(ctx)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if len(open) >= domain.MaxOpen {
+		return domain.Task{}, domain.ErrTooManyActive
+	}
+	return s.store.Insert(ctx, t)
+}
+
+// Complete marks the task with the given ID done.
+func (s *Service) Complete(ctx context.Context, id int64) (domain.Task, error) {
+	t, err := s.store.Get(ctx, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := t.Complete(s.clock.Now()); err != nil {
+		return domain.Task{}, fmt.Errorf("task %d: %w", id, err)
+	}
+	if err := s.store.Update(ctx, t); err != nil {
+		return domain.Task{}, err
+	}
+	return t, nil
+}
+
+// Open returns the open tasks, oldest first.
+func (s *Service) Open(ctx context.Context) ([]domain.Task, error) {
+	all, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	open := all[:0]
+	for _, t := range all {
+		if !t.IsDone() {
+			open = append(open, t)
+		}
+	}
+	return open, nil
+}
+
+// All returns every task, oldest first.
+func (s *Service) All(ctx context.Context) ([]domain.Task, error) {
+	return s.store.List(ctx)
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/hexagonal/domain"
+)
+
+// fakeStore is the least Store the core can run on, with a switch to make
+// it fail. No adapter is involved.
+type fakeStore struct {
+	tasks []domain.Task
+	err   error
+}
+
+func (s *fakeStore) Insert(_ context.Context, t domain.Task) (domain.Task, error) {
+	if s.err != nil {
+		return domain.Task{}, s.err
+	}
+	t.ID = int64(len(s.tasks) + 1)
+	s.tasks = append(s.tasks, t)
+	return t, nil
+}
+
+func (s *fakeStore) Get(_ context.Context, id int64) (domain.Task, error) {
+	if id < 1 || int(id) > len(s.tasks) {
+		return domain.Task{}, domain.ErrTaskNotFound
+	}
+	return s.tasks[id-1], nil
+}
+
+func (s *fakeStore) Update(_ context.Context, t domain.Task) error {
+	s.tasks[t.ID-1] = t
+	return s.err
+}
+
+func (s *fakeStore) List(context.Context) ([]domain.Task, error) {
+	return append([]domain.Task(nil), s.tasks...), s.err
+}
+
+// fakeClock advances a minute each time it is read.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time {
+	c.now = c.now.Add(time.Minute)
+	return c.now
+}
+
+func newService() (*Service, *fakeStore) {
+	store := &fakeStore{}
+	return New(store, &fakeClock{time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)}), store
+}
+
+func titles(ts []domain.Task) string {
+	var s []string
+	for _, t := range ts {
+		s = append(s, t.Title)
+	}
+	return fmt.Sprint(s)
+}
+
+func TestAddAndComplete(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newService()
+	a, err := s.Add(ctx, "  water plants ")
+	if err != nil || a.Title != "water plants" || a.ID != 1 || a.IsDone() {
+		t.Fatalf("Add = %+v, %v", a, err)
+	}
+	s.Add(ctx, "pay rent")
+	done, err := s.Complete(ctx, a.ID)
+	if err != nil || !done.Done.After(done.Created) {
+		t.Fatalf("Complete = %+v, %v", done, err)
+	}
+	if open, _ := s.Open(ctx); titles(open) != "[pay rent]" {
+		t.Errorf("Open = %s", titles(open))
+	}
+	if all, _ := s.All(ctx); titles(all) != "[water plants pay rent]" {
+		t.Errorf("All = %s", titles(all))
+	}
+}
+
+func TestRules(t *testing.T) {
+	ctx := context.Background()
+	s, store := newService()
+	for _, tc := range []struct {
+		title string
+		want  error
+	}{
+		{"", domain.ErrEmptyTitle},
+		{"   ", domain.ErrEmptyTitle},
+		{string(make([]rune, 201)), domain.ErrTitleTooLong},
+	} {
+		if _, err := s.Add(ctx, tc.title); !errors.Is(err, tc.want) {
+			t.Errorf("Add(%q) = %v, want %v", tc.title, err, tc.want)
+		}
+	}
+
+	a, _ := s.Add(ctx, "once")
+	s.Complete(ctx, a.ID)
+	if _, err := s.Complete(ctx, a.ID); !errors.Is(err, domain.ErrAlreadyDone) {
+		t.Errorf("second Complete = %v", err)
+	}
+	if _, err := s.Complete(ctx, 99); !errors.Is(err, domain.ErrTaskNotFound) {
+		t.Errorf("Complete(99) = %v", err)
+	}
+
+	for i := len(store.tasks); i <= domain.MaxOpen; i++ {
+		s.Add(ctx, fmt.Sprint("task ", i))
+	}
+	if _, err := s.Add(ctx, "one too many"); !errors.Is(err, domain.ErrTooManyActive) {
+		t.Errorf("Add over the limit = %v", err)
+	}
+	// Completing a task makes room.
+	s.Complete(ctx, 2)
+	if _, err := s.Add(ctx, "fits again"); err != nil {
+		t.Errorf("Add after Complete = %v", err)
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	s, store := newService()
+	s.Add(ctx, "a")
+	store.err = errors.New("disk full")
+	if _, err := s.Add(ctx, "b"); err != store.err {
+		t.Errorf("Add = %v", err)
+	}
+	if _, err := s.Complete(ctx, 1); err != store.err {
+		t.Errorf("Complete = %v", err)
+	}
+	if _, err := s.Open(ctx); err != store.err {
+		t.Errorf("Open = %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+// Package hexagonal is a small to-do application laid out as ports and
+// adapters, the hexagonal architecture.
+//
+// The core knows nothing of how it is driven or what it drives:
+//
+//   - domain holds the Task entity and its rules.
+//   - ports declares the interfaces at the core's edge: Tasks is the
+//     driving port that callers use, Store and Clock are the driven ports
+//     the core needs filled.
+//   - core implements Tasks on top of a Store and a Clock.
+//
+// Adapters plug into the ports: httpapi and cli drive the core from HTTP
+// requests and command lines, memstore is a Store in memory. cmd/todo is
+// the composition root that picks them and wires them together. Imports
+// only point inwards, adapters to ports to domain, which cmd/wiring can
+// check; so the tests of core run it with fakes and no adapter at all,
+// and a database or a gRPC server would be new adapters with no change to
+// the core.
+package hexagonal
@@ -0,0 +1,54 @@
+// Package domain holds the entities of the to-do application and the
+// rules they obey. It imports nothing of the application.
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Errors of the domain.
+var (
+	ErrEmptyTitle    = errors.New("domain: empty title")
+	ErrTitleTooLong  = errors.New("domain: title longer than 200 characters")
+	ErrAlreadyDone   = errors.New("domain: task already done")
+	ErrTaskNotFound  = errors.New("domain: task not found")
+	ErrTooManyActive = errors.New("domain: too many open tasks")
+)
+
+// MaxOpen is how many tasks may be open at once.
+const MaxOpen = 100
+
+// Task is something to do.
+type Task struct {
+	ID      int64
+	Title   string
+	Created time.Time
+	// Done is when the task was completed, zero while it is open.
+	Done time.Time
+}
+
+// NewTask returns an open task with the given title, trimmed.
+func NewTask(title string, now time.Time) (Task, error) {
+	title = strings.TrimSpace(title)
+	switch {
+	case title == "":
+		return Task{}, ErrEmptyTitle
+	case len([]rune(title)) > 200:
+		return Task{}, ErrTitleTooLong
+	}
+	return Task{Title: title, Created: now}, nil
+}
+
+// IsDone reports whether the task was completed.
+func (t Task) IsDone() bool { return !t.Done.IsZero() }
+
+// Complete marks the task done at now.
+func (t *Task) Complete(now time.Time) error {
+	if t.IsDone() {
+		return ErrAlreadyDone
+	}
+	t.Done = now
+	return nil
+}
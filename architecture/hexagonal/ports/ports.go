@@ -0,0 +1,37 @@
+// Package ports declares the interfaces at the edge of the to-do
+// application's core: the one it offers and the ones it needs.
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/hexagonal/domain"
+)
+
+// Tasks is the driving port, through which adapters such as an HTTP
+// handler or a command line use the application.
+type Tasks interface {
+	Add(ctx context.Context, title string) (domain.Task, error)
+	Complete(ctx context.Context, id int64) (domain.Task, error)
+	// Open returns the open tasks, oldest first.
+	Open(ctx context.Context) ([]domain.Task, error)
+	// All returns every task, oldest first.
+	All(ctx context.Context) ([]domain.Task, error)
+}
+
+// Store is a driven port keeping tasks.
+type Store interface {
+	// Insert stores a new task and returns it with its ID set.
+	Insert(ctx context.Context, t domain.Task) (domain.Task, error)
+	// Get fails with domain.ErrTaskNotFound for an unknown ID.
+	Get(ctx context.Context, id int64) (domain.Task, error)
+	Update(ctx context.Context, t domain.Task) error
+	// List returns every task in ID order.
+	List(ctx context.Context) ([]domain.Task, error)
+}
+
+// Clock is a driven port telling the time.
+type Clock interface {
+	Now() time.Time
+}
@@ -0,0 +1,117 @@
+// Package idempotency makes a message consumer safe to deliver to more than
+// once. Brokers deliver at least once: a message whose acknowledgement is
+// lost comes again, and so does one published twice by a relay that
+// crashed at the wrong moment. A Consumer keys every message, by an
+// idempotency key the producer chose or a message ID, and records the keys
+// it has handled in a Store, so that a redelivery is acknowledged without
+// its side effects running again.
+//
+// The check and the record are one atomic Claim. A key is first claimed
+// for a lease while the handler runs: a duplicate arriving meanwhile is
+// told to come back later instead of running alongside. Success marks the
+// key done for a TTL, after which it is forgotten; failure releases it, so
+// that the next delivery tries again; a consumer that crashes leaves the
+// claim to expire with its lease.
+//
+// Effectively-once needs one thing more than this package can give: if
+// the handler's effects and the Complete are not committed together, a
+// crash between the two runs the effects again on redelivery. A real
+// consumer keeps its Store in the database its handler writes to, and
+// records the key in the same transaction.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/crazybber/go-patterns/behavioral/observer/eventbus"
+)
+
+// ErrInProgress is returned for a message whose key another delivery is
+// handling. The broker should deliver it again later.
+var ErrInProgress = errors.New("idempotency: message being handled by another delivery")
+
+// Options tune a Consumer.
+type Options struct {
+	// TTL is how long a handled key is remembered, default 24h. A
+	// duplicate arriving later is handled again.
+	TTL time.Duration
+	// Lease bounds how long a delivery holds a key while it is handled,
+	// default 1m. The key of a consumer that crashed is free after it.
+	Lease time.Duration
+	// OnError receives the errors of deliveries from a bus the consumer
+	// subscribed to.
+	OnError func(error)
+}
+
+func (o *Options) defaults() {
+	if o.TTL <= 0 {
+		o.TTL = 24 * time.Hour
+	}
+	if o.Lease <= 0 {
+		o.Lease = time.Minute
+	}
+}
+
+// Consumer handles messages of type T at most once per key and TTL.
+type Consumer[T any] struct {
+	store  Store
+	key    func(T) string
+	handle func(context.Context, T) error
+	opts   Options
+
+	handled, duplicates atomic.Int64
+}
+
+// NewConsumer returns a Consumer that records the keys of messages in
+// store and calls handle for those it has not handled yet.
+func NewConsumer[T any](store Store, key func(T) string, handle func(context.Context, T) error, opts Options) *Consumer[T] {
+	opts.defaults()
+	return &Consumer[T]{store: store, key: key, handle: handle, opts: opts}
+}
+
+// Handle handles m unless its key is done, in which case it returns nil
+// at once, or in progress, in which case it returns ErrInProgress. An
+// error of the handler is returned after releasing the key.
+func (c *Consumer[T]) Handle(ctx context.Context, m T) error {
+	key := c.key(m)
+	status, err := c.store.Claim(ctx, key, c.opts.Lease)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case Done:
+		c.duplicates.Add(1)
+		return nil
+	case InProgress:
+		return fmt.Errorf("%w: %s", ErrInProgress, key)
+	}
+	// The record must be written even if ctx ended while the handler ran:
+	// its effects have happened.
+	record := context.WithoutCancel(ctx)
+	if err := c.handle(ctx, m); err != nil {
+		return errors.Join(err, c.store.Release(record, key))
+	}
+	c.handled.Add(1)
+	return c.store.Complete(record, key, c.opts.TTL)
+}
+
+// Subscribe handles the messages published on bus, reporting errors to
+// Options.OnError.
+func (c *Consumer[T]) Subscribe(bus *eventbus.EventBus[T]) *eventbus.Subscription[T] {
+	return bus.Subscribe(func(m T) {
+		if err := c.Handle(context.Background(), m); err != nil && c.opts.OnError != nil {
+			c.opts.OnError(err)
+		}
+	})
+}
+
+// Handled returns the number of messages handled.
+func (c *Consumer[T]) Handled() int64 { return c.handled.Load() }
+
+// Duplicates returns the number of deliveries acknowledged without
+// handling because their key was done.
+func (c *Consumer[T]) Duplicates() int64 { return c.duplicates.Load() }
@@ -0,0 +1,184 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/behavioral/observer/eventbus"
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+type charge struct {
+	Key    string
+	Amount int
+}
+
+func chargeKey(c charge) string { return c.Key }
+
+// ledger is the side effect: money taken from a card.
+type ledger struct {
+	mu      sync.Mutex
+	charges []string
+}
+
+func (l *ledger) charge(_ context.Context, c charge) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.charges = append(l.charges, fmt.Sprintf("%s:%d", c.Key, c.Amount))
+	return nil
+}
+
+func (l *ledger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fmt.Sprint(l.charges)
+}
+
+func TestRedelivery(t *testing.T) {
+	var l ledger
+	c := NewConsumer(NewMemory(nil), chargeKey, l.charge, Options{})
+	bus := eventbus.New[charge](eventbus.Options{})
+	defer bus.Close()
+	c.Subscribe(bus)
+	for _, m := range []charge{{"p1", 10}, {"p1", 10}, {"p2", 5}, {"p1", 10}} {
+		bus.Publish(m)
+	}
+	if got := l.String(); got != "[p1:10 p2:5]" {
+		t.Errorf("charged %s", got)
+	}
+	if c.Handled() != 2 || c.Duplicates() != 2 {
+		t.Errorf("handled %d, duplicates %d", c.Handled(), c.Duplicates())
+	}
+}
+
+func TestConcurrentDuplicate(t *testing.T) {
+	var l ledger
+	gate := make(chan struct{})
+	handled := make(chan struct{})
+	errs := make(chan error, 2)
+	c := NewConsumer(NewMemory(nil), chargeKey, func(ctx context.Context, m charge) error {
+		<-gate
+		defer close(handled)
+		return l.charge(ctx, m)
+	}, Options{OnError: func(err error) { errs <- err }})
+
+	// Two instances of the consumer share the store and receive every
+	// message at the same time.
+	bus := eventbus.New[charge](eventbus.Options{Mode: eventbus.Async})
+	defer bus.Close()
+	c.Subscribe(bus)
+	c.Subscribe(bus)
+	bus.Publish(charge{"p1", 10})
+	// One instance holds the claim while the handler waits; the other is
+	// told to try later rather than charge alongside it.
+	if err := <-errs; !errors.Is(err, ErrInProgress) {
+		t.Fatalf("second delivery: %v", err)
+	}
+	close(gate)
+	<-handled
+
+	bus.Publish(charge{"p1", 10})
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Duplicates() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := l.String(); got != "[p1:10]" || c.Duplicates() != 2 {
+		t.Errorf("charged %s, %d duplicates", got, c.Duplicates())
+	}
+}
+
+func TestFailureReleases(t *testing.T) {
+	var l ledger
+	fail := true
+	c := NewConsumer(NewMemory(nil), chargeKey, func(ctx context.Context, m charge) error {
+		if fail {
+			fail = false
+			return errors.New("card service unavailable")
+		}
+		return l.charge(ctx, m)
+	}, Options{})
+	if err := c.Handle(context.Background(), charge{"p1", 10}); err == nil {
+		t.Fatal("no error from the failing handler")
+	}
+	for range 2 {
+		if err := c.Handle(context.Background(), charge{"p1", 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := l.String(); got != "[p1:10]" {
+		t.Errorf("charged %s", got)
+	}
+}
+
+func TestLeaseAndTTL(t *testing.T) {
+	f := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewMemory(f)
+	var l ledger
+	c := NewConsumer(store, chargeKey, l.charge, Options{TTL: time.Hour, Lease: time.Minute})
+	ctx := context.Background()
+
+	// A consumer claimed p1 and crashed before finishing.
+	if s, _ := store.Claim(ctx, "p1", time.Minute); s != Claimed {
+		t.Fatal(s)
+	}
+	if err := c.Handle(ctx, charge{"p1", 10}); !errors.Is(err, ErrInProgress) {
+		t.Fatalf("Handle during the lease = %v", err)
+	}
+	f.Advance(time.Minute)
+	if err := c.Handle(ctx, charge{"p1", 10}); err != nil {
+		t.Fatal(err)
+	}
+	f.Advance(59 * time.Minute)
+	c.Handle(ctx, charge{"p1", 10})
+	if got := l.String(); got != "[p1:10]" {
+		t.Fatalf("charged %s within the TTL", got)
+	}
+	// Past the TTL the key is forgotten, and a late duplicate is handled
+	// again: the TTL must outlast the broker's redelivery window.
+	f.Advance(time.Minute)
+	c.Handle(ctx, charge{"p1", 10})
+	if got := l.String(); got != "[p1:10 p1:10]" {
+		t.Errorf("charged %s after the TTL", got)
+	}
+}
+
+func TestMemorySweeps(t *testing.T) {
+	f := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewMemory(f)
+	ctx := context.Background()
+	for i := range 100 {
+		store.Claim(ctx, fmt.Sprint("old", i), time.Minute)
+	}
+	f.Advance(time.Minute)
+	for i := range 100 {
+		store.Claim(ctx, fmt.Sprint("new", i), time.Minute)
+	}
+	if n := store.Len(); n != 100 {
+		t.Errorf("%d keys held, want only the 100 unexpired ones", n)
+	}
+}
+
+func Example() {
+	type order struct{ ID, Item string }
+	c := NewConsumer(NewMemory(nil), func(o order) string { return o.ID },
+		func(_ context.Context, o order) error {
+			fmt.Println("shipping", o.Item)
+			return nil
+		}, Options{})
+	bus := eventbus.New[order](eventbus.Options{})
+	defer bus.Close()
+	c.Subscribe(bus)
+
+	bus.Publish(order{"o1", "tea"})
+	bus.Publish(order{"o1", "tea"}) // redelivered
+	bus.Publish(order{"o2", "cups"})
+	fmt.Println("duplicates:", c.Duplicates())
+	// Output:
+	// shipping tea
+	// shipping cups
+	// duplicates: 1
+}
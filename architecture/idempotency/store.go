@@ -0,0 +1,120 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// Status is what a Store knows about a key.
+type Status int
+
+const (
+	// Claimed means the key was free and is now held by the caller.
+	Claimed Status = iota
+	// InProgress means another delivery holds the key and has not
+	// finished yet.
+	InProgress
+	// Done means a delivery with the key has been handled.
+	Done
+)
+
+func (s Status) String() string {
+	return [...]string{"claimed", "in progress", "done"}[s]
+}
+
+// Store records the keys of handled messages. Claim must check and record
+// in one atomic step: two deliveries of a message racing through a check
+// followed by a separate write would both be handled.
+type Store interface {
+	// Claim records key as in progress for lease, unless it is already
+	// recorded, and returns Claimed if it did, or what is recorded.
+	Claim(ctx context.Context, key string, lease time.Duration) (Status, error)
+	// Complete records key as done for ttl.
+	Complete(ctx context.Context, key string, ttl time.Duration) error
+	// Release forgets key, so that the next delivery claims it again.
+	Release(ctx context.Context, key string) error
+}
+
+// Clock is the source of time for a Memory store. Every clock.Clock is
+// one.
+type Clock interface {
+	Now() time.Time
+}
+
+// Memory is a Store in a map, the stand-in for a table with a unique key,
+// or Redis' SET NX with an expiry, in a real deployment. Expired keys are
+// swept as the map grows, so it stays proportional to the keys seen
+// within their TTL.
+type Memory struct {
+	clock Clock
+
+	mu        sync.Mutex
+	keys      map[string]entry
+	sweepSize int // len(keys) at which the next Claim sweeps
+}
+
+type entry struct {
+	status  Status
+	expires time.Time
+}
+
+// NewMemory returns an empty Memory store. A nil clock uses clock.Real.
+func NewMemory(c Clock) *Memory {
+	if c == nil {
+		c = clock.Real
+	}
+	return &Memory{clock: c, keys: make(map[string]entry), sweepSize: 64}
+}
+
+// Claim implements Store.
+func (m *Memory) Claim(_ context.Context, key string, lease time.Duration) (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	if e, ok := m.keys[key]; ok && now.Before(e.expires) {
+		return e.status, nil
+	}
+	if len(m.keys) >= m.sweepSize {
+		m.sweep(now)
+	}
+	m.keys[key] = entry{status: InProgress, expires: now.Add(lease)}
+	return Claimed, nil
+}
+
+// sweep deletes the expired keys. Sweeping once the map has doubled since
+// the last sweep keeps the cost per Claim constant on average.
+func (m *Memory) sweep(now time.Time) {
+	for k, e := range m.keys {
+		if !now.Before(e.expires) {
+			delete(m.keys, k)
+		}
+	}
+	m.sweepSize = max(64, 2*len(m.keys))
+}
+
+// Complete implements Store.
+func (m *Memory) Complete(_ context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key] = entry{status: Done, expires: m.clock.Now().Add(ttl)}
+	return nil
+}
+
+// Release implements Store.
+func (m *Memory) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, key)
+	return nil
+}
+
+// Len returns the number of keys held, expired ones not yet swept
+// included.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.keys)
+}
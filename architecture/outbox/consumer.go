@@ -0,0 +1,42 @@
+package outbox
+
+import "sync"
+
+// Consumer handles each message once, however many times it is delivered,
+// by remembering the IDs it handled. A real consumer stores them in its
+// own database, in the same transaction as the effects of the handler.
+type Consumer struct {
+	mu         sync.Mutex
+	handle     func(Message) error
+	seen       map[int64]bool
+	duplicates int
+}
+
+// NewConsumer returns a Consumer calling handle.
+func NewConsumer(handle func(Message) error) *Consumer {
+	return &Consumer{handle: handle, seen: map[int64]bool{}}
+}
+
+// Handle calls the handler for m unless it already handled m's ID. A
+// message the handler fails on is not remembered, so a redelivery is
+// handled again.
+func (c *Consumer) Handle(m Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen[m.ID] {
+		c.duplicates++
+		return nil
+	}
+	if err := c.handle(m); err != nil {
+		return err
+	}
+	c.seen[m.ID] = true
+	return nil
+}
+
+// Duplicates returns how many deliveries were dropped as duplicates.
+func (c *Consumer) Duplicates() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.duplicates
+}
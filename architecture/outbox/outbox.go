@@ -0,0 +1,120 @@
+// Package outbox publishes messages about changes to a database without
+// losing any or publishing one for a change that was rolled back.
+//
+// Writing a record and then publishing a message about it fails both ways:
+// a crash between the two loses the message, and publishing first
+// announces a write that may never commit. The transactional outbox
+// writes the message to an outbox table in the same transaction as the
+// record, so that both commit or neither does. A Relay then reads the
+// outbox, publishes what it finds and marks it sent.
+//
+// The relay publishes at least once, not exactly once: if it is stopped,
+// or the broker's acknowledgement is lost, after a message went out but
+// before it was marked sent, it publishes the message again. Consumers
+// therefore deduplicate by message ID, which a Consumer does.
+package outbox
+
+import (
+	"sync"
+	"time"
+)
+
+// Message is a row of the outbox. IDs increase in the order messages were
+// committed.
+type Message struct {
+	ID      int64
+	Topic   string
+	Payload []byte
+	Created time.Time
+}
+
+// DB is an in-memory database with a table of records by key and an outbox
+// table. It is safe for concurrent use; transactions run one at a time.
+type DB struct {
+	mu      sync.Mutex
+	records map[string][]byte
+	outbox  []Message
+	nextID  int64
+}
+
+// NewDB returns an empty DB.
+func NewDB() *DB {
+	return &DB{records: map[string][]byte{}}
+}
+
+// Tx is a transaction. Its writes are seen by its own reads, and by
+// nobody else until it commits.
+type Tx struct {
+	db   *DB
+	puts map[string][]byte
+	msgs []Message
+}
+
+// Get returns the record stored under key.
+func (tx *Tx) Get(key string) ([]byte, bool) {
+	if v, ok := tx.puts[key]; ok {
+		return v, true
+	}
+	v, ok := tx.db.records[key]
+	return v, ok
+}
+
+// Put stores value under key.
+func (tx *Tx) Put(key string, value []byte) {
+	tx.puts[key] = value
+}
+
+// Publish adds a message to the outbox, to be published once the
+// transaction has committed.
+func (tx *Tx) Publish(topic string, payload []byte) {
+	tx.msgs = append(tx.msgs, Message{Topic: topic, Payload: payload})
+}
+
+// Update runs fn in a transaction and commits its records and messages
+// together if it returns nil. Otherwise, or if fn panics, nothing of it is
+// kept.
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	tx := &Tx{db: db, puts: map[string][]byte{}}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for k, v := range tx.puts {
+		db.records[k] = v
+	}
+	now := time.Now()
+	for _, m := range tx.msgs {
+		db.nextID++
+		m.ID, m.Created = db.nextID, now
+		db.outbox = append(db.outbox, m)
+	}
+	return nil
+}
+
+// Get returns the committed record stored under key.
+func (db *DB) Get(key string) ([]byte, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	v, ok := db.records[key]
+	return v, ok
+}
+
+// Pending returns up to limit messages not yet marked sent, oldest first.
+func (db *DB) Pending(limit int) []Message {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]Message(nil), db.outbox[:min(limit, len(db.outbox))]...)
+}
+
+// MarkSent removes the message with id from the outbox.
+func (db *DB) MarkSent(id int64) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, m := range db.outbox {
+		if m.ID == id {
+			db.outbox = append(db.outbox[:i], db.outbox[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/behavioral/observer/eventbus"
+	"github.com/crazybber/go-patterns/concurrency/scheduler"
+)
+
+// place stores an order and the message announcing it in one
+// transaction, refusing an ID that is taken.
+func place(db *DB, id string) error {
+	return db.Update(func(tx *Tx) error {
+		if _, ok := tx.Get("order/" + id); ok {
+			return fmt.Errorf("order %s exists", id)
+		}
+		tx.Put("order/"+id, []byte("placed"))
+		tx.Publish("order.placed", []byte(id))
+		return nil
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	db := NewDB()
+	if err := place(db, "o1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := place(db, "o1"); err == nil {
+		t.Error("placed o1 twice")
+	}
+	func() {
+		defer func() { recover() }()
+		db.Update(func(tx *Tx) error {
+			tx.Put("order/o2", nil)
+			tx.Publish("order.placed", []byte("o2"))
+			panic("crash")
+		})
+	}()
+	// The failed and the panicking transactions left nothing behind.
+	if _, ok := db.Get("order/o2"); ok {
+		t.Error("o2 stored")
+	}
+	if got := db.Pending(10); len(got) != 1 || string(got[0].Payload) != "o1" || got[0].ID != 1 {
+		t.Errorf("outbox %v", got)
+	}
+}
+
+// lossy delivers every message to the bus but reports every n-th as
+// failed, as if the broker's acknowledgement was lost.
+func lossy(bus *eventbus.EventBus[Message], n int) Publisher {
+	calls := 0
+	return func(ctx context.Context, m Message) error {
+		bus.Publish(m)
+		if calls++; calls%n == 0 {
+			return errors.New("ack lost")
+		}
+		return nil
+	}
+}
+
+func TestAtLeastOnce(t *testing.T) {
+	db := NewDB()
+	for i := range 10 {
+		place(db, fmt.Sprint("o", i))
+	}
+	bus := eventbus.New[Message](eventbus.Options{})
+	defer bus.Close()
+	var delivered, handled []string
+	c := NewConsumer(func(m Message) error {
+		handled = append(handled, string(m.Payload))
+		return nil
+	})
+	bus.Subscribe(func(m Message) {
+		delivered = append(delivered, string(m.Payload))
+		c.Handle(m)
+	})
+
+	r := NewRelay(db, lossy(bus, 3), 4)
+	for flushes := 0; len(db.Pending(1)) > 0; flushes++ {
+		if flushes > 20 {
+			t.Fatal("outbox never drained")
+		}
+		r.Flush(context.Background())
+	}
+	// Every message was delivered, some twice, and handled once, in order.
+	want := []string{"o0", "o1", "o2", "o3", "o4", "o5", "o6", "o7", "o8", "o9"}
+	if !slices.Equal(handled, want) {
+		t.Errorf("handled %v", handled)
+	}
+	dups := len(delivered) - len(want)
+	if dups == 0 || c.Duplicates() != dups {
+		t.Errorf("%d duplicates delivered, %d dropped", dups, c.Duplicates())
+	}
+	if published, failed := r.Stats(); published != 10 || failed != int64(dups) {
+		t.Errorf("stats %d, %d", published, failed)
+	}
+}
+
+func TestFlushStopsAtFailure(t *testing.T) {
+	db := NewDB()
+	for i := range 4 {
+		place(db, fmt.Sprint("o", i))
+	}
+	var got []string
+	down := errors.New("broker down")
+	r := NewRelay(db, func(_ context.Context, m Message) error {
+		if string(m.Payload) == "o2" {
+			return down
+		}
+		got = append(got, string(m.Payload))
+		return nil
+	}, 10)
+	if n, err := r.Flush(context.Background()); n != 2 || err != down {
+		t.Errorf("Flush = %d, %v", n, err)
+	}
+	// o3 waits behind o2 rather than overtaking it.
+	if p := db.Pending(10); len(p) != 2 || string(p[0].Payload) != "o2" || fmt.Sprint(got) != "[o0 o1]" {
+		t.Errorf("pending %v after publishing %v", p, got)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if n, err := r.Flush(ctx); n != 0 || err != context.Canceled {
+		t.Errorf("cancelled Flush = %d, %v", n, err)
+	}
+}
+
+func TestScheduled(t *testing.T) {
+	const orders = 50
+	db := NewDB()
+	bus := eventbus.New[Message](eventbus.Options{Mode: eventbus.Async})
+	defer bus.Close()
+	var mu sync.Mutex
+	handled := map[string]int{}
+	all := make(chan struct{})
+	c := NewConsumer(func(m Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[string(m.Payload)]++
+		if len(handled) == orders {
+			close(all)
+		}
+		return nil
+	})
+	bus.Subscribe(func(m Message) { c.Handle(m) })
+
+	s := scheduler.New(nil)
+	r := NewRelay(db, lossy(bus, 5), 8)
+	if err := r.Schedule(s, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Stop()
+
+	var wg sync.WaitGroup
+	for i := range orders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			place(db, fmt.Sprint("o", i))
+		}()
+	}
+	wg.Wait()
+	select {
+	case <-all:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not every order was handled")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for id, n := range handled {
+		if n != 1 {
+			t.Errorf("%s handled %d times", id, n)
+		}
+	}
+}
+
+func ExampleRelay() {
+	db := NewDB()
+	db.Update(func(tx *Tx) error {
+		tx.Put("order/o1", []byte("placed"))
+		tx.Publish("order.placed", []byte("o1"))
+		return nil
+	})
+	bus := eventbus.New[Message](eventbus.Options{})
+	defer bus.Close()
+	c := NewConsumer(func(m Message) error {
+		fmt.Printf("%s %s\n", m.Topic, m.Payload)
+		return nil
+	})
+	bus.Subscribe(func(m Message) { c.Handle(m) })
+
+	r := NewRelay(db, BusPublisher(bus), 10)
+	n, _ := r.Flush(context.Background())
+	fmt.Println(n, len(db.Pending(10)))
+	// Output:
+	// order.placed o1
+	// 1 0
+}
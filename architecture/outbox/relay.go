@@ -0,0 +1,78 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crazybber/go-patterns/behavioral/observer/eventbus"
+	"github.com/crazybber/go-patterns/concurrency/scheduler"
+)
+
+// Publisher hands a message to a broker. An error means the message may
+// or may not have reached it.
+type Publisher func(ctx context.Context, m Message) error
+
+// BusPublisher publishes to an event bus, which stands in for a broker.
+func BusPublisher(bus *eventbus.EventBus[Message]) Publisher {
+	return func(_ context.Context, m Message) error {
+		bus.Publish(m)
+		return nil
+	}
+}
+
+// Relay moves messages from an outbox to a broker.
+type Relay struct {
+	db      *DB
+	publish Publisher
+	batch   int
+
+	// mu makes flushes take turns, so that messages go out in order.
+	mu        sync.Mutex
+	published atomic.Int64
+	failed    atomic.Int64
+}
+
+// NewRelay returns a Relay publishing the outbox of db, up to batch
+// messages a flush.
+func NewRelay(db *DB, publish Publisher, batch int) *Relay {
+	return &Relay{db: db, publish: publish, batch: max(batch, 1)}
+}
+
+// Flush publishes pending messages in order, marking each sent once it is
+// published, and returns how many it published. It stops at the first
+// that fails, which stays in the outbox for the next flush, so a message
+// never overtakes an earlier one.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, m := range r.db.Pending(r.batch) {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		if err := r.publish(ctx, m); err != nil {
+			r.failed.Add(1)
+			return n, err
+		}
+		r.db.MarkSent(m.ID)
+		r.published.Add(1)
+		n++
+	}
+	return n, nil
+}
+
+// Schedule adds a job named outbox-relay to s, flushing the outbox every
+// interval. A flush still running when the next is due makes that one
+// skip.
+func (r *Relay) Schedule(s *scheduler.Scheduler, every time.Duration) error {
+	return s.Add("outbox-relay", scheduler.Every(every), scheduler.Skip, func(ctx context.Context) {
+		r.Flush(ctx)
+	})
+}
+
+// Stats returns how many publishes succeeded and failed.
+func (r *Relay) Stats() (published, failed int64) {
+	return r.published.Load(), r.failed.Load()
+}
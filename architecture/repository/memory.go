@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Memory is a UserRepository keeping users in a map.
+type Memory struct {
+	mu      sync.RWMutex
+	nextID  int64
+	users   map[int64]User
+	byEmail map[string]int64
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{users: map[int64]User{}, byEmail: map[string]int64{}}
+}
+
+// Create stores u and sets its ID.
+func (m *Memory) Create(_ context.Context, u *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.byEmail[u.Email]; ok {
+		return ErrDuplicateEmail
+	}
+	m.nextID++
+	u.ID = m.nextID
+	m.users[u.ID] = *u
+	m.byEmail[u.Email] = u.ID
+	return nil
+}
+
+// Get returns the user with the given ID.
+func (m *Memory) Get(_ context.Context, id int64) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+// ByEmail returns the user with the given email.
+func (m *Memory) ByEmail(_ context.Context, email string) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	id, ok := m.byEmail[email]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return m.users[id], nil
+}
+
+// Update replaces the stored user with u's ID.
+func (m *Memory) Update(_ context.Context, u User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.users[u.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if id, ok := m.byEmail[u.Email]; ok && id != u.ID {
+		return ErrDuplicateEmail
+	}
+	delete(m.byEmail, old.Email)
+	m.users[u.ID] = u
+	m.byEmail[u.Email] = u.ID
+	return nil
+}
+
+// Delete removes the user with the given ID.
+func (m *Memory) Delete(_ context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.users, id)
+	delete(m.byEmail, u.Email)
+	return nil
+}
+
+// List returns up to limit users in ID order, skipping offset.
+func (m *Memory) List(_ context.Context, offset, limit int) ([]User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ids := slices.Sorted(maps.Keys(m.users))
+	ids = ids[min(max(offset, 0), len(ids)):]
+	ids = ids[:min(max(limit, 0), len(ids))]
+	users := make([]User, len(ids))
+	for i, id := range ids {
+		users[i] = m.users[id]
+	}
+	return users, nil
+}
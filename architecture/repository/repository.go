@@ -0,0 +1,45 @@
+// Package repository hides how users are stored behind a UserRepository
+// interface, so that the code using it reads like it works on a
+// collection in memory and never sees a query.
+//
+// Two implementations come with it: Memory keeps users in a map and suits
+// tests and prototypes, SQL keeps them in a table through database/sql.
+// Both pass the same conformance tests, which is what lets a caller switch
+// between them: the interface promises behaviour, such as ErrNotFound for
+// a missing user and ErrDuplicateEmail for a taken address, not just
+// method signatures.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Errors of a UserRepository.
+var (
+	ErrNotFound       = errors.New("repository: user not found")
+	ErrDuplicateEmail = errors.New("repository: email taken")
+)
+
+// User is a stored user. The repository assigns the ID.
+type User struct {
+	ID      int64
+	Email   string
+	Name    string
+	Created time.Time
+}
+
+// UserRepository stores users. Implementations are safe for concurrent
+// use.
+type UserRepository interface {
+	// Create stores u and sets its ID.
+	Create(ctx context.Context, u *User) error
+	Get(ctx context.Context, id int64) (User, error)
+	ByEmail(ctx context.Context, email string) (User, error)
+	// Update replaces the stored user with u's ID.
+	Update(ctx context.Context, u User) error
+	Delete(ctx context.Context, id int64) error
+	// List returns up to limit users in ID order, skipping offset.
+	List(ctx context.Context, offset, limit int) ([]User, error)
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testRepository is the conformance suite every UserRepository passes.
+func testRepository(t *testing.T, open func(t *testing.T) UserRepository) {
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	t.Run("create and get", func(t *testing.T) {
+		r := open(t)
+		u := &User{Email: "ann@example.com", Name: "Ann", Created: at}
+		if err := r.Create(ctx, u); err != nil || u.ID == 0 {
+			t.Fatalf("Create = %v, ID %d", err, u.ID)
+		}
+		got, err := r.Get(ctx, u.ID)
+		if err != nil || got != *u {
+			t.Errorf("Get = %+v, %v, want %+v", got, err, *u)
+		}
+		got, err = r.ByEmail(ctx, "ann@example.com")
+		if err != nil || got != *u {
+			t.Errorf("ByEmail = %+v, %v", got, err)
+		}
+		v := &User{Email: "bob@example.com", Name: "Bob", Created: at}
+		if err := r.Create(ctx, v); err != nil || v.ID == u.ID {
+			t.Errorf("second Create = %v, ID %d", err, v.ID)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		r := open(t)
+		if _, err := r.Get(ctx, 42); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get = %v", err)
+		}
+		if _, err := r.ByEmail(ctx, "nobody@example.com"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ByEmail = %v", err)
+		}
+		if err := r.Update(ctx, User{ID: 42, Email: "x"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Update = %v", err)
+		}
+		if err := r.Delete(ctx, 42); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Delete = %v", err)
+		}
+	})
+
+	t.Run("duplicate email", func(t *testing.T) {
+		r := open(t)
+		a := &User{Email: "ann@example.com", Created: at}
+		b := &User{Email: "bob@example.com", Created: at}
+		r.Create(ctx, a)
+		r.Create(ctx, b)
+		if err := r.Create(ctx, &User{Email: "ann@example.com", Created: at}); !errors.Is(err, ErrDuplicateEmail) {
+			t.Errorf("Create = %v", err)
+		}
+		b.Email = "ann@example.com"
+		if err := r.Update(ctx, *b); !errors.Is(err, ErrDuplicateEmail) {
+			t.Errorf("Update = %v", err)
+		}
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		r := open(t)
+		u := &User{Email: "ann@example.com", Name: "Ann", Created: at}
+		r.Create(ctx, u)
+		u.Email, u.Name = "ann@example.org", "Ann B."
+		if err := r.Update(ctx, *u); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := r.ByEmail(ctx, "ann@example.org"); got != *u {
+			t.Errorf("after Update: %+v", got)
+		}
+		if _, err := r.ByEmail(ctx, "ann@example.com"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("old email still found: %v", err)
+		}
+		// The old address is free again.
+		if err := r.Create(ctx, &User{Email: "ann@example.com", Created: at}); err != nil {
+			t.Errorf("reusing the old email: %v", err)
+		}
+		if err := r.Delete(ctx, u.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Get(ctx, u.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get after Delete = %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		r := open(t)
+		var ids []int64
+		for i := 0; i < 5; i++ {
+			u := &User{Email: fmt.Sprint("u", i, "@example.com"), Created: at}
+			r.Create(ctx, u)
+			ids = append(ids, u.ID)
+		}
+		r.Delete(ctx, ids[1])
+		for _, tc := range []struct {
+			offset, limit int
+			want          []int64
+		}{
+			{0, 10, []int64{ids[0], ids[2], ids[3], ids[4]}},
+			{1, 2, []int64{ids[2], ids[3]}},
+			{3, 2, []int64{ids[4]}},
+			{9, 2, nil},
+		} {
+			users, err := r.List(ctx, tc.offset, tc.limit)
+			var got []int64
+			for _, u := range users {
+				got = append(got, u.ID)
+			}
+			if err != nil || !slices.Equal(got, tc.want) {
+				t.Errorf("List(%d, %d) = %v, %v, want %v", tc.offset, tc.limit, got, err, tc.want)
+			}
+		}
+	})
+
+	t.Run("concurrent creates", func(t *testing.T) {
+		r := open(t)
+		var wg sync.WaitGroup
+		errs := make([]error, 20)
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// Two goroutines race for each address.
+				errs[i] = r.Create(ctx, &User{Email: fmt.Sprint("u", i/2, "@example.com"), Created: at})
+			}()
+		}
+		wg.Wait()
+		dups := 0
+		for _, err := range errs {
+			if errors.Is(err, ErrDuplicateEmail) {
+				dups++
+			} else if err != nil {
+				t.Error(err)
+			}
+		}
+		users, _ := r.List(ctx, 0, 100)
+		if dups != 10 || len(users) != 10 {
+			t.Errorf("%d duplicates and %d users, want 10 and 10", dups, len(users))
+		}
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testRepository(t, func(*testing.T) UserRepository { return NewMemory() })
+}
+
+func TestSQL(t *testing.T) {
+	testRepository(t, func(t *testing.T) UserRepository {
+		db, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Every connection to :memory: opens a database of its own, so
+		// the pool must hold on to one.
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() { db.Close() })
+		r, err := NewSQL(context.Background(), db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
+
+func ExampleMemory() {
+	ctx := context.Background()
+	var users UserRepository = NewMemory()
+	u := &User{Email: "ann@example.com", Name: "Ann"}
+	users.Create(ctx, u)
+	got, _ := users.ByEmail(ctx, "ann@example.com")
+	fmt.Println(got.ID, got.Name)
+	_, err := users.Get(ctx, 2)
+	fmt.Println(err)
+	// Output:
+	// 1 Ann
+	// repository: user not found
+}
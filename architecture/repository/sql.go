@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Schema creates the table SQL uses. It is written for SQLite and needs
+// little change for other databases.
+const Schema = `CREATE TABLE IF NOT EXISTS users (
+	id      INTEGER PRIMARY KEY AUTOINCREMENT,
+	email   TEXT NOT NULL UNIQUE,
+	name    TEXT NOT NULL,
+	created INTEGER NOT NULL
+)`
+
+const columns = "id, email, name, created"
+
+// SQL is a UserRepository keeping users in the users table of a database.
+// Created times are stored as Unix milliseconds in UTC.
+type SQL struct {
+	db *sql.DB
+}
+
+// NewSQL returns a repository using db, creating the table if needed.
+func NewSQL(ctx context.Context, db *sql.DB) (*SQL, error) {
+	if _, err := db.ExecContext(ctx, Schema); err != nil {
+		return nil, err
+	}
+	return &SQL{db: db}, nil
+}
+
+// Create stores u and sets its ID.
+func (s *SQL) Create(ctx context.Context, u *User) error {
+	res, err := s.db.ExecContext(ctx,
+		"INSERT INTO users (email, name, created) VALUES (?, ?, ?)",
+		u.Email, u.Name, u.Created.UnixMilli())
+	if err != nil {
+		return mapError(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	u.ID = id
+	return nil
+}
+
+// Get returns the user with the given ID.
+func (s *SQL) Get(ctx context.Context, id int64) (User, error) {
+	return scan(s.db.QueryRowContext(ctx, "SELECT "+columns+" FROM users WHERE id = ?", id))
+}
+
+// ByEmail returns the user with the given email.
+func (s *SQL) ByEmail(ctx context.Context, email string) (User, error) {
+	return scan(s.db.QueryRowContext(ctx, "SELECT "+columns+" FROM users WHERE email = ?", email))
+}
+
+// Update replaces the stored user with u's ID.
+func (s *SQL) Update(ctx context.Context, u User) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET email = ?, name = ?, created = ? WHERE id = ?",
+		u.Email, u.Name, u.Created.UnixMilli(), u.ID)
+	if err != nil {
+		return mapError(err)
+	}
+	return affected(res)
+}
+
+// Delete removes the user with the given ID.
+func (s *SQL) Delete(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+// List returns up to limit users in ID order, skipping offset.
+func (s *SQL) List(ctx context.Context, offset, limit int) ([]User, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+columns+" FROM users ORDER BY id LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []User{}
+	for rows.Next() {
+		u, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func scan(row interface{ Scan(...any) error }) (User, error) {
+	var (
+		u       User
+		created int64
+	)
+	err := row.Scan(&u.ID, &u.Email, &u.Name, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	u.Created = time.UnixMilli(created).UTC()
+	return u, nil
+}
+
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// mapError turns a unique constraint failure into ErrDuplicateEmail. The
+// drivers report it in their own error types; the message is the one
+// thing they share.
+func mapError(err error) error {
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "unique") || strings.Contains(msg, "duplicate") {
+		return ErrDuplicateEmail
+	}
+	return err
+}
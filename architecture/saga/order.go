@@ -0,0 +1,11 @@
+package saga
+
+// PlaceOrder returns the saga placing an order: reserve its stock, charge
+// the customer, then ship it.
+func PlaceOrder(inv *Inventory, pay *Payments, ship *Shipping, opts Options) *Saga[Order] {
+	return New(opts,
+		Step[Order]{Name: "reserve", Do: inv.Reserve, Undo: inv.Release},
+		Step[Order]{Name: "charge", Do: pay.Charge, Undo: pay.Refund},
+		Step[Order]{Name: "ship", Do: ship.Ship, Undo: ship.Cancel},
+	)
+}
@@ -0,0 +1,202 @@
+// Package saga runs a transaction spanning several services as a sequence
+// of local steps, each paired with a compensating action that undoes it.
+//
+// There is no distributed lock or two-phase commit: every step commits on
+// its own service. When a step fails, the orchestrator runs the
+// compensations of the steps before it in reverse order, leaving the
+// services as if the saga had never started. A step that failed may still
+// have taken effect, such as a charge whose reply was lost, so it is
+// compensated too; compensations must therefore be idempotent, and undo
+// nothing when there is nothing to undo.
+//
+// A compensation cannot be abandoned the way a step can. It is retried,
+// and runs even after the saga's context is cancelled; if it keeps
+// failing the saga is stuck, and the error says which step needs someone
+// to look at it.
+//
+// Hooks given in Options see every call of a step or a compensation start
+// and end, and can put what they like in the context it gets.
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors of a Saga, matched by the *Error it returns.
+var (
+	ErrAborted = errors.New("saga: aborted")
+	ErrStuck   = errors.New("saga: compensation failed")
+)
+
+// Step is one local transaction of a saga and its compensation.
+type Step[T any] struct {
+	Name string
+	Do   func(ctx context.Context, data T) error
+	// Undo compensates Do. It is called after Do failed as well as after
+	// it succeeded, and must be idempotent.
+	Undo func(ctx context.Context, data T) error
+}
+
+// Hooks are called around every call of Do or Undo.
+type Hooks interface {
+	// StepStart is called before the call. It, and StepEnd, get the
+	// context StepStart returns.
+	StepStart(ctx context.Context, step string, undo bool) context.Context
+	// StepEnd is called once the call has returned err.
+	StepEnd(ctx context.Context, err error)
+}
+
+type noHooks struct{}
+
+func (noHooks) StepStart(ctx context.Context, _ string, _ bool) context.Context { return ctx }
+func (noHooks) StepEnd(context.Context, error)                                  {}
+
+// Options tune a Saga.
+type Options struct {
+	// StepTimeout bounds each call of Do or Undo, default 1s.
+	StepTimeout time.Duration
+	// UndoAttempts bounds the calls of each Undo, default 3, waiting
+	// UndoBackoff, default 10ms, between them.
+	UndoAttempts int
+	UndoBackoff  time.Duration
+	// Hooks default to none.
+	Hooks Hooks
+}
+
+func (o *Options) defaults() {
+	if o.StepTimeout <= 0 {
+		o.StepTimeout = time.Second
+	}
+	if o.UndoAttempts <= 0 {
+		o.UndoAttempts = 3
+	}
+	if o.UndoBackoff <= 0 {
+		o.UndoBackoff = 10 * time.Millisecond
+	}
+	if o.Hooks == nil {
+		o.Hooks = noHooks{}
+	}
+}
+
+// Entry records a call of a step or of its compensation.
+type Entry struct {
+	Step string
+	Undo bool
+	Err  error
+}
+
+func (e Entry) String() string {
+	s := e.Step
+	if e.Undo {
+		s = "undo " + s
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// Error is returned by Run for a saga that did not complete. It matches
+// ErrAborted if every compensation succeeded, and ErrStuck if one did not.
+type Error struct {
+	// Step is the step that failed, and Err why.
+	Step string
+	Err  error
+	// Undo is the last error of the compensation that gave up, and
+	// Stuck the step it compensates.
+	Undo  error
+	Stuck string
+	// Log holds every call made, in order.
+	Log []Entry
+}
+
+func (e *Error) Error() string {
+	if e.Undo != nil {
+		return fmt.Sprintf("saga: %s failed: %v; compensating %s failed: %v", e.Step, e.Err, e.Stuck, e.Undo)
+	}
+	return fmt.Sprintf("saga: %s failed: %v; compensated", e.Step, e.Err)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Undo != nil {
+		return []error{ErrStuck, e.Err}
+	}
+	return []error{ErrAborted, e.Err}
+}
+
+// Saga is a sequence of steps. It is safe for concurrent use; each Run is
+// one instance of the transaction.
+type Saga[T any] struct {
+	steps []Step[T]
+	opts  Options
+}
+
+// New returns a Saga running steps in order.
+func New[T any](opts Options, steps ...Step[T]) *Saga[T] {
+	opts.defaults()
+	return &Saga[T]{steps: steps, opts: opts}
+}
+
+// Run runs the steps on data. It returns nil if they all succeeded, and
+// otherwise an *Error after compensating. A cancelled ctx stops the saga
+// before its next step, which counts as that step failing.
+func (s *Saga[T]) Run(ctx context.Context, data T) error {
+	var log []Entry
+	for i, step := range s.steps {
+		// The failed step is compensated too, in case it took effect,
+		// unless the saga was cancelled before calling it.
+		last := i - 1
+		err := ctx.Err()
+		if err == nil {
+			last = i
+			err = s.call(ctx, step.Name, false, step.Do, data)
+			log = append(log, Entry{Step: step.Name, Err: err})
+		}
+		if err != nil {
+			e := &Error{Step: step.Name, Err: err}
+			e.Log = s.compensate(context.WithoutCancel(ctx), data, last, log, e)
+			return e
+		}
+	}
+	return nil
+}
+
+// compensate undoes steps last down to 0, recording the calls in log and
+// the first compensation to give up in e.
+func (s *Saga[T]) compensate(ctx context.Context, data T, last int, log []Entry, e *Error) []Entry {
+	for i := last; i >= 0; i-- {
+		step := s.steps[i]
+		if step.Undo == nil {
+			continue
+		}
+		var err error
+		for attempt := range s.opts.UndoAttempts {
+			if attempt > 0 {
+				time.Sleep(s.opts.UndoBackoff)
+			}
+			err = s.call(ctx, step.Name, true, step.Undo, data)
+			log = append(log, Entry{Step: step.Name, Undo: true, Err: err})
+			if err == nil {
+				break
+			}
+		}
+		if err != nil && e.Undo == nil {
+			// Later compensations still run: leaving more undone than
+			// must be would only make the repair larger.
+			e.Undo, e.Stuck = err, step.Name
+		}
+	}
+	return log
+}
+
+func (s *Saga[T]) call(ctx context.Context, step string, undo bool, f func(context.Context, T) error, data T) error {
+	ctx = s.opts.Hooks.StepStart(ctx, step, undo)
+	ctx, cancel := context.WithTimeout(ctx, s.opts.StepTimeout)
+	defer cancel()
+	err := f(ctx, data)
+	s.opts.Hooks.StepEnd(ctx, err)
+	return err
+}
@@ -0,0 +1,280 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type shop struct {
+	inv  *Inventory
+	pay  *Payments
+	ship *Shipping
+	saga *Saga[Order]
+}
+
+func newShop(t *testing.T, stock int) *shop {
+	s := &shop{
+		inv:  NewInventory(map[string]int{"tea": stock}),
+		pay:  NewPayments(1000),
+		ship: NewShipping(),
+	}
+	t.Cleanup(func() {
+		s.inv.Close()
+		s.pay.Close()
+		s.ship.Close()
+	})
+	s.saga = PlaceOrder(s.inv, s.pay, s.ship, Options{StepTimeout: 20 * time.Millisecond, UndoBackoff: time.Millisecond})
+	return s
+}
+
+// effects describes what an order left behind.
+func (s *shop) effects(id string) string {
+	return fmt.Sprintf("reserved=%v charged=%d shipped=%v",
+		s.inv.Reserved(id), s.pay.Charged(id), s.ship.Shipped(id))
+}
+
+func order(id string) Order {
+	return Order{ID: id, SKU: "tea", Qty: 2, Amount: 900, Address: "1 Main St"}
+}
+
+func TestCompleted(t *testing.T) {
+	s := newShop(t, 10)
+	if err := s.saga.Run(context.Background(), order("o1")); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.effects("o1"); got != "reserved=true charged=900 shipped=true" {
+		t.Error(got)
+	}
+	if n := s.inv.Stock("tea"); n != 8 {
+		t.Errorf("stock %d", n)
+	}
+}
+
+func TestAborted(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		order  func(Order) Order
+		inject func(*shop)
+		step   string
+		want   error
+		log    string
+	}{
+		{"out of stock", func(o Order) Order { o.Qty = 11; return o }, nil,
+			"reserve", ErrOutOfStock, "[reserve: saga: out of stock undo reserve]"},
+		{"declined", func(o Order) Order { o.Amount = 5000; return o }, nil,
+			"charge", ErrDeclined, "[reserve charge: saga: card declined undo charge undo reserve]"},
+		{"undeliverable", func(o Order) Order { o.Address = ""; return o }, nil,
+			"ship", ErrUndeliverable, "[reserve charge ship: saga: undeliverable undo ship undo charge undo reserve]"},
+		{"injected", nil, func(s *shop) { s.pay.Inject("charge", Fail) },
+			"charge", ErrInjected, "[reserve charge: saga: injected failure undo charge undo reserve]"},
+		// The charge goes through but its reply is lost: the refund must
+		// undo it.
+		{"lost reply", nil, func(s *shop) { s.pay.Inject("charge", Lose) },
+			"charge", context.DeadlineExceeded, "[reserve charge: context deadline exceeded undo charge undo reserve]"},
+		{"undo retried", nil, func(s *shop) {
+			s.ship.Inject("ship", Fail)
+			s.inv.Inject("release", Fail, Fail)
+		}, "ship", ErrInjected, "[reserve charge ship: saga: injected failure undo ship undo charge " +
+			"undo reserve: saga: injected failure undo reserve: saga: injected failure undo reserve]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newShop(t, 10)
+			o := order("o1")
+			if tc.order != nil {
+				o = tc.order(o)
+			}
+			if tc.inject != nil {
+				tc.inject(s)
+			}
+			err := s.saga.Run(context.Background(), o)
+			var e *Error
+			if !errors.As(err, &e) || !errors.Is(err, ErrAborted) || !errors.Is(err, tc.want) || e.Step != tc.step {
+				t.Fatalf("Run = %v", err)
+			}
+			if got := fmt.Sprint(e.Log); got != tc.log {
+				t.Errorf("log %s", got)
+			}
+			if got := s.effects("o1"); got != "reserved=false charged=0 shipped=false" {
+				t.Error(got)
+			}
+			if n := s.inv.Stock("tea"); n != 10 {
+				t.Errorf("stock %d", n)
+			}
+		})
+	}
+}
+
+func TestStuck(t *testing.T) {
+	s := newShop(t, 10)
+	s.ship.Inject("ship", Fail)
+	s.pay.Inject("refund", Fail, Fail, Fail)
+	err := s.saga.Run(context.Background(), order("o1"))
+	var e *Error
+	if !errors.As(err, &e) || !errors.Is(err, ErrStuck) || errors.Is(err, ErrAborted) {
+		t.Fatalf("Run = %v", err)
+	}
+	if e.Stuck != "charge" || e.Undo != ErrInjected {
+		t.Errorf("stuck at %s: %v", e.Stuck, e.Undo)
+	}
+	// The other compensations still ran.
+	if got := s.effects("o1"); got != "reserved=false charged=900 shipped=false" {
+		t.Error(got)
+	}
+	if !strings.Contains(err.Error(), "compensating charge failed") {
+		t.Error(err)
+	}
+}
+
+func TestCancelled(t *testing.T) {
+	s := newShop(t, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := s.saga.Run(ctx, order("o1"))
+	var e *Error
+	if !errors.As(err, &e) || !errors.Is(err, context.Canceled) || len(e.Log) != 0 {
+		t.Fatalf("Run = %v", err)
+	}
+
+	// Cancelled midway, the saga stops and still compensates.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	saga := New(Options{},
+		Step[Order]{Name: "reserve", Do: s.inv.Reserve, Undo: s.inv.Release},
+		Step[Order]{Name: "cancel", Do: func(context.Context, Order) error { cancel(); return nil }},
+		Step[Order]{Name: "charge", Do: s.pay.Charge, Undo: s.pay.Refund},
+	)
+	err = saga.Run(ctx, order("o2"))
+	if !errors.As(err, &e) || e.Step != "charge" || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run = %v", err)
+	}
+	if got := fmt.Sprint(e.Log); got != "[reserve cancel undo reserve]" {
+		t.Errorf("log %s", got)
+	}
+	if got := s.effects("o2"); got != "reserved=false charged=0 shipped=false" {
+		t.Error(got)
+	}
+}
+
+// TestInjected runs many sagas at once against services failing at
+// random, and checks that each order completed or left nothing behind.
+func TestInjected(t *testing.T) {
+	const orders, stock = 60, 100
+	s := newShop(t, stock)
+	rng := rand.New(rand.NewSource(1))
+	ops := map[string]*service{
+		"reserve": s.inv.service, "release": s.inv.service,
+		"charge": s.pay.service, "refund": s.pay.service,
+		"ship": s.ship.service, "cancel": s.ship.service,
+	}
+	for op, svc := range ops {
+		for range 10 {
+			svc.Inject(op, Fault(1+rng.Intn(2)), 0, 0)
+		}
+	}
+	// Compensations outlast the faults, however they interleave.
+	saga := PlaceOrder(s.inv, s.pay, s.ship, Options{StepTimeout: 20 * time.Millisecond, UndoAttempts: 30, UndoBackoff: time.Millisecond})
+
+	errs := make([]error, orders)
+	var wg sync.WaitGroup
+	for i := range orders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = saga.Run(context.Background(), order(fmt.Sprint("o", i)))
+		}()
+	}
+	wg.Wait()
+
+	completed := 0
+	for i, err := range errs {
+		id := fmt.Sprint("o", i)
+		got := s.effects(id)
+		switch {
+		case err == nil:
+			completed++
+			if got != "reserved=true charged=900 shipped=true" {
+				t.Errorf("%s completed with %s", id, got)
+			}
+		case errors.Is(err, ErrAborted):
+			if got != "reserved=false charged=0 shipped=false" {
+				t.Errorf("%s aborted with %s", id, got)
+			}
+		default:
+			t.Errorf("%s: %v", id, err)
+		}
+	}
+	if n := s.inv.Stock("tea"); n != stock-2*completed {
+		t.Errorf("stock %d after %d orders", n, completed)
+	}
+	if completed == 0 || completed == orders {
+		t.Errorf("%d of %d orders completed", completed, orders)
+	}
+}
+
+type stepKey struct{}
+
+// stepRecorder is Hooks that tags the context with the call and keeps the
+// calls that ended.
+type stepRecorder struct{ calls []string }
+
+func (r *stepRecorder) StepStart(ctx context.Context, step string, undo bool) context.Context {
+	if undo {
+		step = "undo " + step
+	}
+	return context.WithValue(ctx, stepKey{}, step)
+}
+
+func (r *stepRecorder) StepEnd(ctx context.Context, err error) {
+	r.calls = append(r.calls, fmt.Sprintf("%v=%v", ctx.Value(stepKey{}), err))
+}
+
+func TestHooks(t *testing.T) {
+	hooks := &stepRecorder{}
+	var seen []any
+	step := func(name string, err error) Step[int] {
+		return Step[int]{
+			Name: name,
+			Do: func(ctx context.Context, _ int) error {
+				seen = append(seen, ctx.Value(stepKey{}))
+				return err
+			},
+			Undo: func(ctx context.Context, _ int) error {
+				seen = append(seen, ctx.Value(stepKey{}))
+				return nil
+			},
+		}
+	}
+	saga := New(Options{Hooks: hooks}, step("a", nil), step("b", errors.New("no")))
+	saga.Run(context.Background(), 0)
+	if got := fmt.Sprint(seen); got != "[a b undo b undo a]" {
+		t.Errorf("calls saw %s", got)
+	}
+	if got := fmt.Sprint(hooks.calls); got != "[a=<nil> b=no undo b=<nil> undo a=<nil>]" {
+		t.Errorf("StepEnd got %s", got)
+	}
+}
+
+func ExamplePlaceOrder() {
+	inv := NewInventory(map[string]int{"tea": 5})
+	pay := NewPayments(1000)
+	ship := NewShipping()
+	defer inv.Close()
+	defer pay.Close()
+	defer ship.Close()
+	saga := PlaceOrder(inv, pay, ship, Options{})
+
+	err := saga.Run(context.Background(), Order{ID: "o1", SKU: "tea", Qty: 2, Amount: 900})
+	fmt.Println(err)
+	fmt.Println(err.(*Error).Log)
+	fmt.Println(inv.Stock("tea"), pay.Charged("o1"))
+	// Output:
+	// saga: ship failed: saga: undeliverable; compensated
+	// [reserve charge ship: saga: undeliverable undo ship undo charge undo reserve]
+	// 5 0
+}
@@ -0,0 +1,290 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Errors of the services.
+var (
+	ErrClosed        = errors.New("saga: service closed")
+	ErrInjected      = errors.New("saga: injected failure")
+	ErrOutOfStock    = errors.New("saga: out of stock")
+	ErrDeclined      = errors.New("saga: card declined")
+	ErrUndeliverable = errors.New("saga: undeliverable")
+)
+
+// Order is what the order saga reserves, charges and ships.
+type Order struct {
+	ID      string
+	SKU     string
+	Qty     int
+	Amount  int64
+	Address string
+}
+
+// Fault is a failure a service can be made to have.
+type Fault int
+
+const (
+	// Fail refuses the request with ErrInjected, without effect.
+	Fail Fault = iota + 1
+	// Lose carries out the request but loses the reply, so that the
+	// caller times out not knowing whether it took effect.
+	Lose
+)
+
+// service runs on its own goroutine and takes requests over a channel, as
+// a remote service takes them over the network. Its state is only touched
+// by that goroutine.
+type service struct {
+	reqs chan request
+	done chan struct{}
+	once sync.Once
+	ops  map[string]func(Order) error
+
+	mu     sync.Mutex
+	faults map[string][]Fault
+}
+
+type request struct {
+	op    string
+	order Order
+	// query, if set, is run instead of an op, to read the state.
+	query func()
+	reply chan error
+}
+
+func newService(ops map[string]func(Order) error) *service {
+	s := &service{reqs: make(chan request), done: make(chan struct{}), ops: ops, faults: map[string][]Fault{}}
+	go s.loop()
+	return s
+}
+
+func (s *service) loop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case req := <-s.reqs:
+			if req.query != nil {
+				req.query()
+				req.reply <- nil
+				continue
+			}
+			switch s.fault(req.op) {
+			case Fail:
+				req.reply <- ErrInjected
+			case Lose:
+				s.ops[req.op](req.order)
+			default:
+				req.reply <- s.ops[req.op](req.order)
+			}
+		}
+	}
+}
+
+func (s *service) fault(op string) Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.faults[op]
+	if len(q) == 0 {
+		return 0
+	}
+	s.faults[op] = q[1:]
+	return q[0]
+}
+
+// Inject makes the next requests for op have faults, one each.
+func (s *service) Inject(op string, faults ...Fault) {
+	s.mu.Lock()
+	s.faults[op] = append(s.faults[op], faults...)
+	s.mu.Unlock()
+}
+
+// call sends a request for op and waits for the reply or for ctx.
+func (s *service) call(ctx context.Context, op string, o Order) error {
+	return s.send(ctx, request{op: op, order: o, reply: make(chan error, 1)})
+}
+
+// query runs f on the service's goroutine.
+func (s *service) query(f func()) {
+	s.send(context.Background(), request{query: f, reply: make(chan error, 1)})
+}
+
+func (s *service) send(ctx context.Context, req request) error {
+	select {
+	case s.reqs <- req:
+	case <-s.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the service.
+func (s *service) Close() { s.once.Do(func() { close(s.done) }) }
+
+// Inventory reserves stock for orders. Its operations are "reserve" and
+// "release".
+type Inventory struct {
+	*service
+	stock    map[string]int
+	reserved map[string]Order
+}
+
+// NewInventory returns an Inventory holding stock, by SKU.
+func NewInventory(stock map[string]int) *Inventory {
+	inv := &Inventory{stock: map[string]int{}, reserved: map[string]Order{}}
+	for sku, n := range stock {
+		inv.stock[sku] = n
+	}
+	inv.service = newService(map[string]func(Order) error{
+		"reserve": inv.reserve,
+		"release": inv.release,
+	})
+	return inv
+}
+
+func (inv *Inventory) reserve(o Order) error {
+	if _, ok := inv.reserved[o.ID]; ok {
+		return nil
+	}
+	if inv.stock[o.SKU] < o.Qty {
+		return ErrOutOfStock
+	}
+	inv.stock[o.SKU] -= o.Qty
+	inv.reserved[o.ID] = o
+	return nil
+}
+
+func (inv *Inventory) release(o Order) error {
+	if r, ok := inv.reserved[o.ID]; ok {
+		inv.stock[r.SKU] += r.Qty
+		delete(inv.reserved, o.ID)
+	}
+	return nil
+}
+
+// Reserve reserves the stock of o.
+func (inv *Inventory) Reserve(ctx context.Context, o Order) error {
+	return inv.call(ctx, "reserve", o)
+}
+
+// Release releases the stock reserved for o, if any.
+func (inv *Inventory) Release(ctx context.Context, o Order) error {
+	return inv.call(ctx, "release", o)
+}
+
+// Stock returns the units of sku not reserved.
+func (inv *Inventory) Stock(sku string) (n int) {
+	inv.query(func() { n = inv.stock[sku] })
+	return n
+}
+
+// Reserved reports whether stock is reserved for the order with id.
+func (inv *Inventory) Reserved(id string) (ok bool) {
+	inv.query(func() { _, ok = inv.reserved[id] })
+	return ok
+}
+
+// Payments charges orders. Its operations are "charge" and "refund".
+type Payments struct {
+	*service
+	limit   int64
+	charges map[string]int64
+}
+
+// NewPayments returns Payments declining charges over limit.
+func NewPayments(limit int64) *Payments {
+	p := &Payments{limit: limit, charges: map[string]int64{}}
+	p.service = newService(map[string]func(Order) error{
+		"charge": p.charge,
+		"refund": p.refund,
+	})
+	return p
+}
+
+func (p *Payments) charge(o Order) error {
+	if _, ok := p.charges[o.ID]; ok {
+		return nil
+	}
+	if o.Amount > p.limit {
+		return ErrDeclined
+	}
+	p.charges[o.ID] = o.Amount
+	return nil
+}
+
+func (p *Payments) refund(o Order) error {
+	delete(p.charges, o.ID)
+	return nil
+}
+
+// Charge charges the amount of o.
+func (p *Payments) Charge(ctx context.Context, o Order) error {
+	return p.call(ctx, "charge", o)
+}
+
+// Refund refunds the charge for o, if any.
+func (p *Payments) Refund(ctx context.Context, o Order) error {
+	return p.call(ctx, "refund", o)
+}
+
+// Charged returns what the order with id was charged.
+func (p *Payments) Charged(id string) (amount int64) {
+	p.query(func() { amount = p.charges[id] })
+	return amount
+}
+
+// Shipping ships orders. Its operations are "ship" and "cancel".
+type Shipping struct {
+	*service
+	shipments map[string]string
+}
+
+// NewShipping returns an empty Shipping.
+func NewShipping() *Shipping {
+	s := &Shipping{shipments: map[string]string{}}
+	s.service = newService(map[string]func(Order) error{
+		"ship":   s.ship,
+		"cancel": s.cancel,
+	})
+	return s
+}
+
+func (s *Shipping) ship(o Order) error {
+	if o.Address == "" {
+		return ErrUndeliverable
+	}
+	s.shipments[o.ID] = o.Address
+	return nil
+}
+
+func (s *Shipping) cancel(o Order) error {
+	delete(s.shipments, o.ID)
+	return nil
+}
+
+// Ship schedules the shipment of o.
+func (s *Shipping) Ship(ctx context.Context, o Order) error {
+	return s.call(ctx, "ship", o)
+}
+
+// Cancel cancels the shipment of o, if any.
+func (s *Shipping) Cancel(ctx context.Context, o Order) error {
+	return s.call(ctx, "cancel", o)
+}
+
+// Shipped reports whether the order with id is to be shipped.
+func (s *Shipping) Shipped(id string) (ok bool) {
+	s.query(func() { _, ok = s.shipments[id] })
+	return ok
+}
@@ -0,0 +1,286 @@
+package twophasecommit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// Errors of a Participant.
+var (
+	ErrClosed   = errors.New("twophasecommit: participant closed")
+	ErrInjected = errors.New("twophasecommit: injected failure")
+	ErrLocked   = errors.New("twophasecommit: key locked by another transaction")
+	// ErrCommitted is the reply to an abort of a transaction the
+	// participant has committed, which a correct coordinator never sends.
+	ErrCommitted = errors.New("twophasecommit: already committed")
+)
+
+// Kind is the kind of a message from the coordinator.
+type Kind int
+
+const (
+	Prepare Kind = iota + 1
+	Commit
+	Abort
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Prepare:
+		return "prepare"
+	case Commit:
+		return "commit"
+	case Abort:
+		return "abort"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Fault is a failure a participant can be made to have.
+type Fault int
+
+const (
+	// Refuse answers with ErrInjected, without effect. For a prepare,
+	// that is a vote to abort.
+	Refuse Fault = iota + 1
+	// Lose handles the message but loses the reply, so the coordinator
+	// times out not knowing that it took effect.
+	Lose
+	// Drop loses the message itself, as a participant that has crashed
+	// or is cut off does.
+	Drop
+)
+
+// State is where a transaction stands at a participant.
+type State int
+
+const (
+	// Unknown is a transaction the participant has not heard of.
+	Unknown State = iota
+	// Prepared is a transaction that voted to commit. Its writes are
+	// staged and its keys locked until the decision arrives: the
+	// participant may not decide on its own, and is blocked, or in doubt,
+	// for as long as the coordinator is unreachable.
+	Prepared
+	Committed
+	Aborted
+)
+
+func (s State) String() string {
+	return [...]string{"unknown", "prepared", "committed", "aborted"}[s]
+}
+
+// Participant is a key-value store taking part in transactions. It runs
+// on its own goroutine and takes messages over a channel, as a remote
+// resource manager takes them over the network. Its state is only touched
+// by that goroutine.
+type Participant struct {
+	name string
+	msgs chan message
+	done chan struct{}
+	once sync.Once
+
+	mu     sync.Mutex
+	faults map[Kind][]Fault
+
+	data   map[string]string
+	locks  map[string]string // key to the transaction holding it
+	staged map[string]map[string]string
+	states map[string]State
+	log    []string
+}
+
+type message struct {
+	kind   Kind
+	tx     string
+	writes map[string]string
+	// query, if set, is run instead of a message, to read the state.
+	query func()
+	reply chan error
+}
+
+// NewParticipant starts a participant named name with no data.
+func NewParticipant(name string) *Participant {
+	p := &Participant{
+		name:   name,
+		msgs:   make(chan message),
+		done:   make(chan struct{}),
+		faults: map[Kind][]Fault{},
+		data:   map[string]string{},
+		locks:  map[string]string{},
+		staged: map[string]map[string]string{},
+		states: map[string]State{},
+	}
+	go p.loop()
+	return p
+}
+
+// Name returns the participant's name.
+func (p *Participant) Name() string { return p.name }
+
+func (p *Participant) loop() {
+	for {
+		select {
+		case <-p.done:
+			return
+		case m := <-p.msgs:
+			if m.query != nil {
+				m.query()
+				m.reply <- nil
+				continue
+			}
+			fault := p.fault(m.kind)
+			entry := fmt.Sprintf("%s %s", m.kind, m.tx)
+			switch fault {
+			case Refuse:
+				p.log = append(p.log, entry+": refused")
+				m.reply <- ErrInjected
+				continue
+			case Drop:
+				p.log = append(p.log, entry+": dropped")
+				continue
+			}
+			var err error
+			switch m.kind {
+			case Prepare:
+				err = p.prepare(m.tx, m.writes)
+			case Commit:
+				err = p.commit(m.tx)
+			case Abort:
+				err = p.abort(m.tx)
+			}
+			if err != nil {
+				entry += ": " + err.Error()
+			}
+			if fault == Lose {
+				p.log = append(p.log, entry+" (reply lost)")
+				continue
+			}
+			p.log = append(p.log, entry)
+			m.reply <- err
+		}
+	}
+}
+
+func (p *Participant) fault(k Kind) Fault {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	q := p.faults[k]
+	if len(q) == 0 {
+		return 0
+	}
+	p.faults[k] = q[1:]
+	return q[0]
+}
+
+// Inject makes the next messages of kind k have faults, one each.
+func (p *Participant) Inject(k Kind, faults ...Fault) {
+	p.mu.Lock()
+	p.faults[k] = append(p.faults[k], faults...)
+	p.mu.Unlock()
+}
+
+// prepare stages writes and locks their keys, voting to commit, or votes
+// to abort if another transaction holds one of the keys. A repeated
+// prepare gets the same vote.
+func (p *Participant) prepare(tx string, writes map[string]string) error {
+	switch p.states[tx] {
+	case Prepared, Committed:
+		return nil
+	case Aborted:
+		return fmt.Errorf("twophasecommit: %s already aborted", tx)
+	}
+	for k := range writes {
+		if holder, ok := p.locks[k]; ok && holder != tx {
+			return fmt.Errorf("%w: %s held by %s", ErrLocked, k, holder)
+		}
+	}
+	for k := range writes {
+		p.locks[k] = tx
+	}
+	p.staged[tx] = maps.Clone(writes)
+	p.states[tx] = Prepared
+	return nil
+}
+
+// commit applies the staged writes. Committing again does nothing, so the
+// coordinator can resend a commit whose reply it did not get.
+func (p *Participant) commit(tx string) error {
+	switch p.states[tx] {
+	case Committed:
+		return nil
+	case Prepared:
+	default:
+		return fmt.Errorf("twophasecommit: commit of %s, which is %s", tx, p.states[tx])
+	}
+	maps.Copy(p.data, p.staged[tx])
+	p.release(tx)
+	p.states[tx] = Committed
+	return nil
+}
+
+// abort drops the staged writes. A transaction the participant never
+// prepared, because the prepare was lost, is recorded as aborted too, so a
+// late prepare cannot revive it.
+func (p *Participant) abort(tx string) error {
+	if p.states[tx] == Committed {
+		return ErrCommitted
+	}
+	p.release(tx)
+	p.states[tx] = Aborted
+	return nil
+}
+
+func (p *Participant) release(tx string) {
+	for k := range p.staged[tx] {
+		delete(p.locks, k)
+	}
+	delete(p.staged, tx)
+}
+
+// send delivers m and waits for the reply or for ctx.
+func (p *Participant) send(ctx context.Context, m message) error {
+	m.reply = make(chan error, 1)
+	select {
+	case p.msgs <- m:
+	case <-p.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-m.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// query runs f on the participant's goroutine.
+func (p *Participant) query(f func()) {
+	p.send(context.Background(), message{query: f})
+}
+
+// Get returns the committed value of key.
+func (p *Participant) Get(key string) (v string, ok bool) {
+	p.query(func() { v, ok = p.data[key] })
+	return v, ok
+}
+
+// State returns where tx stands at the participant.
+func (p *Participant) State(tx string) (s State) {
+	p.query(func() { s = p.states[tx] })
+	return s
+}
+
+// Log returns the messages the participant has handled, in order.
+func (p *Participant) Log() (log []string) {
+	p.query(func() { log = append(log, p.log...) })
+	return log
+}
+
+// Close stops the participant.
+func (p *Participant) Close() { p.once.Do(func() { close(p.done) }) }
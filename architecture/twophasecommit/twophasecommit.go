@@ -0,0 +1,218 @@
+// Package twophasecommit simulates the two-phase commit protocol, which
+// makes a transaction spanning several resource managers commit on all of
+// them or on none.
+//
+// In the first phase the coordinator asks every participant to prepare:
+// to stage the writes, lock what they touch and promise to commit if told
+// to. A participant votes to commit by preparing, and to abort by refusing.
+// In the second phase the coordinator decides, commit if every vote was
+// yes and abort otherwise, records the decision, and sends it to every
+// participant until each has acknowledged it.
+//
+// A participant that does not answer in time counts as a vote to abort,
+// so one slow or crashed node aborts the transaction instead of stalling
+// it. The weak spot is the other way round: a participant that has
+// prepared may not decide alone, so if the decision does not reach it, it
+// stays in doubt with its keys locked until the coordinator, going through
+// its log of decisions, delivers it. That blocking is what the saga
+// pattern, in architecture/saga, trades atomicity to avoid.
+//
+// Participants run on goroutines of their own and take messages over
+// channels; faults injected into them lose replies or messages, so every
+// failure can be reproduced in tests.
+package twophasecommit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors of a Coordinator.
+var (
+	ErrAborted = errors.New("twophasecommit: aborted")
+	// ErrInDoubt reports a decision some participants have not
+	// acknowledged. They may be blocked in the prepared state until
+	// Redeliver reaches them.
+	ErrInDoubt = errors.New("twophasecommit: decision not delivered")
+	ErrUnknown = errors.New("twophasecommit: unknown participant")
+)
+
+// Options tune a Coordinator.
+type Options struct {
+	// Timeout bounds the wait for each reply, default 1s.
+	Timeout time.Duration
+	// Retry is the wait before resending a decision that has not been
+	// acknowledged, default 10ms.
+	Retry time.Duration
+}
+
+func (o *Options) defaults() {
+	if o.Timeout <= 0 {
+		o.Timeout = time.Second
+	}
+	if o.Retry <= 0 {
+		o.Retry = 10 * time.Millisecond
+	}
+}
+
+// Decision is an entry of the coordinator's log.
+type Decision struct {
+	Tx     string
+	Commit bool
+	// Pending lists the participants that have not acknowledged it.
+	Pending []string
+}
+
+// Coordinator runs transactions across participants. It is safe for
+// concurrent use.
+type Coordinator struct {
+	parts map[string]*Participant
+	opts  Options
+
+	mu  sync.Mutex
+	log []*Decision
+}
+
+// NewCoordinator returns a coordinator of parts.
+func NewCoordinator(opts Options, parts ...*Participant) *Coordinator {
+	opts.defaults()
+	c := &Coordinator{parts: make(map[string]*Participant, len(parts)), opts: opts}
+	for _, p := range parts {
+		c.parts[p.Name()] = p
+	}
+	return c
+}
+
+// Run runs the transaction tx, whose writes are given by participant name.
+// It returns nil if tx committed everywhere, an error matching ErrAborted
+// and saying which vote aborted it otherwise, and in either case an error
+// matching ErrInDoubt if the decision could not be delivered to everyone
+// before ctx was done. Every participant gets at least one try, even if
+// ctx is already done.
+func (c *Coordinator) Run(ctx context.Context, tx string, writes map[string]map[string]string) error {
+	names := slices.Sorted(maps.Keys(writes))
+	for _, name := range names {
+		if c.parts[name] == nil {
+			return fmt.Errorf("%w: %s", ErrUnknown, name)
+		}
+	}
+
+	votes := make([]error, len(names))
+	c.each(names, func(i int, p *Participant) {
+		vctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+		defer cancel()
+		votes[i] = p.send(vctx, message{kind: Prepare, tx: tx, writes: writes[names[i]]})
+	})
+	var abort error
+	for i, err := range votes {
+		if err != nil {
+			abort = fmt.Errorf("%w: %s voted no: %w", ErrAborted, names[i], err)
+			break
+		}
+	}
+
+	// From here on the decision is final. A real coordinator writes it
+	// to stable storage before telling anyone.
+	d := &Decision{Tx: tx, Commit: abort == nil, Pending: names}
+	c.mu.Lock()
+	c.log = append(c.log, d)
+	c.mu.Unlock()
+	return errors.Join(abort, c.deliver(ctx, d))
+}
+
+// Redeliver sends every logged decision that is still pending, as a
+// coordinator does when it restarts, and returns an error matching
+// ErrInDoubt for those it could not deliver before ctx was done.
+func (c *Coordinator) Redeliver(ctx context.Context) error {
+	var errs []error
+	for _, d := range c.Decisions() {
+		if len(d.Pending) > 0 {
+			errs = append(errs, c.deliver(ctx, c.find(d.Tx)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Decisions returns a copy of the log of decisions, in the order they
+// were taken.
+func (c *Coordinator) Decisions() []Decision {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	log := make([]Decision, len(c.log))
+	for i, d := range c.log {
+		log[i] = *d
+		log[i].Pending = slices.Clone(d.Pending)
+	}
+	return log
+}
+
+func (c *Coordinator) find(tx string) *Decision {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range c.log {
+		if d.Tx == tx {
+			return d
+		}
+	}
+	return nil
+}
+
+// deliver sends d to its pending participants, resending to those that do
+// not acknowledge it every Retry until ctx is done.
+func (c *Coordinator) deliver(ctx context.Context, d *Decision) error {
+	kind := Abort
+	if d.Commit {
+		kind = Commit
+	}
+	// The first round is sent even if ctx is done: a participant told
+	// nothing stays in doubt.
+	send := context.WithoutCancel(ctx)
+	for {
+		c.mu.Lock()
+		pending := slices.Clone(d.Pending)
+		c.mu.Unlock()
+		var acked sync.Map
+		c.each(pending, func(_ int, p *Participant) {
+			sctx, cancel := context.WithTimeout(send, c.opts.Timeout)
+			defer cancel()
+			if p.send(sctx, message{kind: kind, tx: d.Tx}) == nil {
+				acked.Store(p.Name(), true)
+			}
+		})
+		c.mu.Lock()
+		d.Pending = slices.DeleteFunc(d.Pending, func(name string) bool {
+			_, ok := acked.Load(name)
+			return ok
+		})
+		pending = slices.Clone(d.Pending)
+		c.mu.Unlock()
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %s %s to %s", ErrInDoubt, kind, d.Tx, strings.Join(pending, ", "))
+		case <-time.After(c.opts.Retry):
+		}
+	}
+}
+
+// each calls f for the named participants concurrently and waits for the
+// calls to return.
+func (c *Coordinator) each(names []string, f func(i int, p *Participant)) {
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(i, c.parts[name])
+		}()
+	}
+	wg.Wait()
+}
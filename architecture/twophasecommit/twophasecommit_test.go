@@ -0,0 +1,172 @@
+package twophasecommit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bank struct {
+	a, b *Participant
+	c    *Coordinator
+}
+
+func newBank(t *testing.T) *bank {
+	bk := &bank{a: NewParticipant("a"), b: NewParticipant("b")}
+	t.Cleanup(func() {
+		bk.a.Close()
+		bk.b.Close()
+	})
+	bk.c = NewCoordinator(Options{Timeout: 20 * time.Millisecond, Retry: time.Millisecond}, bk.a, bk.b)
+	return bk
+}
+
+// transfer moves money between an account at a and one at b.
+func transfer(from, to string) map[string]map[string]string {
+	return map[string]map[string]string{"a": {"ann": from}, "b": {"bob": to}}
+}
+
+// state describes tx and the committed balances at both participants.
+func (bk *bank) state(tx string) string {
+	ann, _ := bk.a.Get("ann")
+	bob, _ := bk.b.Get("bob")
+	return fmt.Sprintf("a:%s b:%s ann=%q bob=%q", bk.a.State(tx), bk.b.State(tx), ann, bob)
+}
+
+func TestCommit(t *testing.T) {
+	bk := newBank(t)
+	if err := bk.c.Run(context.Background(), "t1", transfer("90", "110")); err != nil {
+		t.Fatal(err)
+	}
+	if got := bk.state("t1"); got != `a:committed b:committed ann="90" bob="110"` {
+		t.Error(got)
+	}
+	if d := bk.c.Decisions(); len(d) != 1 || !d[0].Commit || len(d[0].Pending) != 0 {
+		t.Errorf("decisions %+v", d)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		fault Fault
+		err   string
+		log   string
+	}{
+		{"vote no", Refuse, "b voted no: twophasecommit: injected failure",
+			"[prepare t1: refused abort t1]"},
+		// A prepare that never arrives times out, and the abort finds
+		// nothing to undo.
+		{"prepare lost", Drop, "b voted no: context deadline exceeded",
+			"[prepare t1: dropped abort t1]"},
+		// A participant that prepared but whose vote was lost is told to
+		// abort, which releases its locks.
+		{"vote lost", Lose, "b voted no: context deadline exceeded",
+			"[prepare t1 (reply lost) abort t1]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bk := newBank(t)
+			bk.b.Inject(Prepare, tc.fault)
+			err := bk.c.Run(context.Background(), "t1", transfer("90", "110"))
+			if !errors.Is(err, ErrAborted) || !strings.HasSuffix(err.Error(), tc.err) {
+				t.Errorf("Run = %v", err)
+			}
+			if got := bk.state("t1"); got != `a:aborted b:aborted ann="" bob=""` {
+				t.Error(got)
+			}
+			if got := fmt.Sprint(bk.b.Log()); got != tc.log {
+				t.Errorf("b handled %s, want %s", got, tc.log)
+			}
+			// Nothing stays locked.
+			if err := bk.c.Run(context.Background(), "t2", transfer("90", "110")); err != nil {
+				t.Errorf("next transaction: %v", err)
+			}
+		})
+	}
+}
+
+func TestCommitResent(t *testing.T) {
+	bk := newBank(t)
+	// The first commit takes effect but its reply is lost, the second is
+	// refused: the coordinator keeps sending until b acknowledges, and b
+	// commits once.
+	bk.b.Inject(Commit, Lose, Refuse)
+	if err := bk.c.Run(context.Background(), "t1", transfer("90", "110")); err != nil {
+		t.Fatal(err)
+	}
+	want := "[prepare t1 commit t1 (reply lost) commit t1: refused commit t1]"
+	if got := fmt.Sprint(bk.b.Log()); got != want {
+		t.Errorf("b handled %s, want %s", got, want)
+	}
+	if got := bk.state("t1"); got != `a:committed b:committed ann="90" bob="110"` {
+		t.Error(got)
+	}
+}
+
+func TestInDoubt(t *testing.T) {
+	bk := newBank(t)
+	bk.b.Inject(Commit, Drop, Drop, Drop, Drop, Drop, Drop, Drop, Drop, Drop, Drop)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := bk.c.Run(ctx, "t1", transfer("90", "110"))
+	if !errors.Is(err, ErrInDoubt) || errors.Is(err, ErrAborted) {
+		t.Fatalf("Run = %v", err)
+	}
+	// b voted yes and may not decide alone: it holds bob locked, and a
+	// second transaction touching bob is refused.
+	if got := bk.state("t1"); got != `a:committed b:prepared ann="90" bob=""` {
+		t.Error(got)
+	}
+	err = bk.c.Run(context.Background(), "t2", map[string]map[string]string{"b": {"bob": "0"}})
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("t2 = %v, want the lock held by t1", err)
+	}
+	// The log still holds t1's decision for b, and delivering it later
+	// completes the transaction once the drops have run out.
+	if err := bk.c.Redeliver(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := bk.state("t1"); got != `a:committed b:committed ann="90" bob="110"` {
+		t.Error(got)
+	}
+	for _, d := range bk.c.Decisions() {
+		if len(d.Pending) != 0 {
+			t.Errorf("%s still pending at %v", d.Tx, d.Pending)
+		}
+	}
+}
+
+func TestUnknownParticipant(t *testing.T) {
+	bk := newBank(t)
+	err := bk.c.Run(context.Background(), "t1", map[string]map[string]string{"z": {"k": "v"}})
+	if !errors.Is(err, ErrUnknown) || len(bk.c.Decisions()) != 0 {
+		t.Errorf("Run = %v", err)
+	}
+}
+
+func Example() {
+	orders, stock := NewParticipant("orders"), NewParticipant("stock")
+	defer orders.Close()
+	defer stock.Close()
+	c := NewCoordinator(Options{Timeout: 50 * time.Millisecond}, orders, stock)
+
+	err := c.Run(context.Background(), "tx1", map[string]map[string]string{
+		"orders": {"o1": "2 tea"},
+		"stock":  {"tea": "8"},
+	})
+	fmt.Println("tx1:", err)
+
+	stock.Inject(Prepare, Refuse)
+	err = c.Run(context.Background(), "tx2", map[string]map[string]string{
+		"orders": {"o2": "9 tea"},
+		"stock":  {"tea": "-1"},
+	})
+	_, placed := orders.Get("o2")
+	fmt.Println("tx2 aborted:", errors.Is(err, ErrAborted), "o2 placed:", placed)
+	// Output:
+	// tx1: <nil>
+	// tx2 aborted: true o2 placed: false
+}
@@ -0,0 +1,144 @@
+package unitofwork
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a Store keeping accounts and the ledger in memory. It is safe
+// for concurrent use.
+type Memory struct {
+	mu       sync.Mutex
+	accounts map[string]versioned
+	ledger   []Entry
+}
+
+// versioned is a stored account and how many times it was saved.
+type versioned struct {
+	Account
+	version int
+}
+
+// NewMemory returns a Memory holding accounts.
+func NewMemory(accounts ...Account) *Memory {
+	m := &Memory{accounts: map[string]versioned{}}
+	for _, a := range accounts {
+		m.accounts[a.ID] = versioned{a, 1}
+	}
+	return m
+}
+
+// Begin begins a unit of work.
+func (m *Memory) Begin(context.Context) (UnitOfWork, error) {
+	return &memoryUnit{m: m, read: map[string]int{}, dirty: map[string]Account{}}, nil
+}
+
+// memoryUnit records the version of each account it read, the accounts it
+// changed and the entries it appended, and applies them at Commit.
+type memoryUnit struct {
+	m       *Memory
+	read    map[string]int
+	dirty   map[string]Account
+	order   []string // the dirty accounts, in the order first saved
+	entries []Entry
+	done    bool
+}
+
+func (u *memoryUnit) Accounts() Accounts { return memoryAccounts{u} }
+func (u *memoryUnit) Ledger() Ledger     { return memoryLedger{u} }
+
+func (u *memoryUnit) Commit() error {
+	if u.done {
+		return ErrDone
+	}
+	u.done = true
+	m := u.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, version := range u.read {
+		if m.accounts[id].version != version {
+			return ErrConflict
+		}
+	}
+	for _, id := range u.order {
+		m.accounts[id] = versioned{u.dirty[id], m.accounts[id].version + 1}
+	}
+	m.ledger = append(m.ledger, u.entries...)
+	return nil
+}
+
+func (u *memoryUnit) Rollback() error {
+	if u.done {
+		return ErrDone
+	}
+	u.done = true
+	return nil
+}
+
+type memoryAccounts struct{ u *memoryUnit }
+
+func (r memoryAccounts) Get(_ context.Context, id string) (Account, error) {
+	u := r.u
+	if u.done {
+		return Account{}, ErrDone
+	}
+	if a, ok := u.dirty[id]; ok {
+		return a, nil
+	}
+	u.m.mu.Lock()
+	v, ok := u.m.accounts[id]
+	u.m.mu.Unlock()
+	if !ok {
+		return Account{}, ErrNotFound
+	}
+	if _, ok := u.read[id]; !ok {
+		u.read[id] = v.version
+	}
+	return v.Account, nil
+}
+
+func (r memoryAccounts) Save(_ context.Context, a Account) error {
+	u := r.u
+	if u.done {
+		return ErrDone
+	}
+	if _, ok := u.dirty[a.ID]; !ok {
+		u.order = append(u.order, a.ID)
+	}
+	if _, ok := u.read[a.ID]; !ok {
+		// Saved without being read: it must still be as absent, or at
+		// the version, it is now.
+		u.m.mu.Lock()
+		u.read[a.ID] = u.m.accounts[a.ID].version
+		u.m.mu.Unlock()
+	}
+	u.dirty[a.ID] = a
+	return nil
+}
+
+type memoryLedger struct{ u *memoryUnit }
+
+func (l memoryLedger) Append(_ context.Context, e Entry) error {
+	if l.u.done {
+		return ErrDone
+	}
+	l.u.entries = append(l.u.entries, e)
+	return nil
+}
+
+func (l memoryLedger) Entries(_ context.Context, account string) ([]Entry, error) {
+	u := l.u
+	if u.done {
+		return nil, ErrDone
+	}
+	u.m.mu.Lock()
+	all := append(u.m.ledger[:len(u.m.ledger):len(u.m.ledger)], u.entries...)
+	u.m.mu.Unlock()
+	var entries []Entry
+	for _, e := range all {
+		if e.Account == account {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,104 @@
+package unitofwork
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Schema creates the tables SQL uses. It is written for SQLite and needs
+// little change for other databases.
+const Schema = `CREATE TABLE IF NOT EXISTS accounts (
+	id      TEXT PRIMARY KEY,
+	owner   TEXT NOT NULL,
+	balance INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS ledger (
+	id      INTEGER PRIMARY KEY AUTOINCREMENT,
+	account TEXT NOT NULL,
+	amount  INTEGER NOT NULL,
+	memo    TEXT NOT NULL
+)`
+
+// SQL is a Store keeping accounts and the ledger in a database. Each unit
+// of work is a transaction, so the database provides the isolation.
+type SQL struct {
+	db *sql.DB
+}
+
+// NewSQL returns a Store using db, creating the tables if needed.
+func NewSQL(ctx context.Context, db *sql.DB) (*SQL, error) {
+	if _, err := db.ExecContext(ctx, Schema); err != nil {
+		return nil, err
+	}
+	return &SQL{db: db}, nil
+}
+
+// Begin begins a transaction.
+func (s *SQL) Begin(ctx context.Context) (UnitOfWork, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return sqlUnit{tx}, nil
+}
+
+type sqlUnit struct{ tx *sql.Tx }
+
+func (u sqlUnit) Accounts() Accounts { return sqlAccounts(u) }
+func (u sqlUnit) Ledger() Ledger     { return sqlLedger(u) }
+func (u sqlUnit) Commit() error      { return mapError(u.tx.Commit()) }
+func (u sqlUnit) Rollback() error    { return mapError(u.tx.Rollback()) }
+
+type sqlAccounts sqlUnit
+
+func (r sqlAccounts) Get(ctx context.Context, id string) (Account, error) {
+	var a Account
+	err := r.tx.QueryRowContext(ctx, "SELECT id, owner, balance FROM accounts WHERE id = ?", id).
+		Scan(&a.ID, &a.Owner, &a.Balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Account{}, ErrNotFound
+	}
+	return a, mapError(err)
+}
+
+func (r sqlAccounts) Save(ctx context.Context, a Account) error {
+	_, err := r.tx.ExecContext(ctx,
+		"INSERT INTO accounts (id, owner, balance) VALUES (?, ?, ?) "+
+			"ON CONFLICT(id) DO UPDATE SET owner = excluded.owner, balance = excluded.balance",
+		a.ID, a.Owner, a.Balance)
+	return mapError(err)
+}
+
+type sqlLedger sqlUnit
+
+func (l sqlLedger) Append(ctx context.Context, e Entry) error {
+	_, err := l.tx.ExecContext(ctx, "INSERT INTO ledger (account, amount, memo) VALUES (?, ?, ?)",
+		e.Account, e.Amount, e.Memo)
+	return mapError(err)
+}
+
+func (l sqlLedger) Entries(ctx context.Context, account string) ([]Entry, error) {
+	rows, err := l.tx.QueryContext(ctx,
+		"SELECT account, amount, memo FROM ledger WHERE account = ? ORDER BY id", account)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Account, &e.Amount, &e.Memo); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func mapError(err error) error {
+	if errors.Is(err, sql.ErrTxDone) {
+		return ErrDone
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+// Package unitofwork groups changes to several repositories into one unit
+// that is committed or rolled back as a whole.
+//
+// A transfer between two accounts changes both balances and appends two
+// ledger entries. Done through repositories that each write straight to
+// storage, a failure halfway leaves money debited and never credited. A
+// UnitOfWork hands out repositories whose changes it holds back: none is
+// seen outside the unit until Commit, and Rollback drops them all. Do runs
+// a function in a unit, committing if it returns nil and rolling back if
+// it fails or panics.
+//
+// Two Stores come with it. Memory tracks the accounts a unit read and
+// changed, the way Fowler describes the pattern, and applies the changes
+// at Commit unless another unit changed those accounts first. SQL maps a
+// unit onto a database/sql transaction.
+package unitofwork
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Errors of a UnitOfWork.
+var (
+	ErrNotFound          = errors.New("unitofwork: account not found")
+	ErrInsufficientFunds = errors.New("unitofwork: insufficient funds")
+	ErrConflict          = errors.New("unitofwork: account changed by another unit")
+	ErrDone              = errors.New("unitofwork: unit already committed or rolled back")
+)
+
+// Account is an account and its balance, in cents.
+type Account struct {
+	ID      string
+	Owner   string
+	Balance int64
+}
+
+// Entry is a line of the ledger.
+type Entry struct {
+	Account string
+	Amount  int64
+	Memo    string
+}
+
+// Accounts stores accounts.
+type Accounts interface {
+	Get(ctx context.Context, id string) (Account, error)
+	// Save stores a, adding it if it is new.
+	Save(ctx context.Context, a Account) error
+}
+
+// Ledger is the append-only record of the changes to balances.
+type Ledger interface {
+	Append(ctx context.Context, e Entry) error
+	// Entries returns the entries of an account in the order appended.
+	Entries(ctx context.Context, account string) ([]Entry, error)
+}
+
+// UnitOfWork is a group of changes to the repositories it returns. A unit
+// is used by one goroutine, and is done after Commit or Rollback, which
+// then both return ErrDone.
+type UnitOfWork interface {
+	Accounts() Accounts
+	Ledger() Ledger
+	Commit() error
+	Rollback() error
+}
+
+// Store begins units of work.
+type Store interface {
+	Begin(ctx context.Context) (UnitOfWork, error)
+}
+
+// Do runs fn in a new unit of work. It commits the unit if fn returns nil,
+// and otherwise rolls it back and returns fn's error. A panic in fn rolls
+// the unit back before it goes on.
+func Do(ctx context.Context, s Store, fn func(uow UnitOfWork) error) (err error) {
+	uow, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			uow.Rollback()
+		}
+	}()
+	if err := fn(uow); err != nil {
+		return err
+	}
+	committed = true
+	return uow.Commit()
+}
+
+// Transfer is a payment from one account to another.
+type Transfer struct {
+	From, To string
+	Amount   int64
+	Memo     string
+}
+
+// Apply makes t in uow: it moves the amount and records both sides in
+// the ledger.
+func (t Transfer) Apply(ctx context.Context, uow UnitOfWork) error {
+	accounts, ledger := uow.Accounts(), uow.Ledger()
+	from, err := accounts.Get(ctx, t.From)
+	if err != nil {
+		return err
+	}
+	to, err := accounts.Get(ctx, t.To)
+	if err != nil {
+		return err
+	}
+	if t.Amount <= 0 || from.Balance < t.Amount {
+		return fmt.Errorf("%w: %s has %d, needs %d", ErrInsufficientFunds, from.ID, from.Balance, t.Amount)
+	}
+	from.Balance -= t.Amount
+	to.Balance += t.Amount
+	for _, a := range []Account{from, to} {
+		if err := accounts.Save(ctx, a); err != nil {
+			return err
+		}
+	}
+	if err := ledger.Append(ctx, Entry{Account: t.From, Amount: -t.Amount, Memo: t.Memo}); err != nil {
+		return err
+	}
+	return ledger.Append(ctx, Entry{Account: t.To, Amount: t.Amount, Memo: t.Memo})
+}
+
+// Settle makes all of transfers in one unit of work: either every one is
+// made or, if one fails, none is.
+func Settle(ctx context.Context, s Store, transfers []Transfer) error {
+	return Do(ctx, s, func(uow UnitOfWork) error {
+		for i, t := range transfers {
+			if err := t.Apply(ctx, uow); err != nil {
+				return fmt.Errorf("transfer %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,381 @@
+package unitofwork
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func accounts() []Account {
+	return []Account{{"a", "Ann", 100}, {"b", "Bob", 50}, {"c", "Cy", 0}}
+}
+
+// state reads every balance and ledger entry in a unit of its own.
+func state(t *testing.T, s Store) string {
+	t.Helper()
+	var b strings.Builder
+	err := Do(context.Background(), s, func(uow UnitOfWork) error {
+		for _, a := range accounts() {
+			got, err := uow.Accounts().Get(context.Background(), a.ID)
+			if err != nil {
+				return err
+			}
+			entries, err := uow.Ledger().Entries(context.Background(), a.ID)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&b, "%s=%d%v ", got.ID, got.Balance, entries)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+const initial = "a=100[] b=50[] c=0[]"
+
+// testStore is the behaviour every Store must have.
+func testStore(t *testing.T, open func(t *testing.T) Store) {
+	ctx := context.Background()
+
+	t.Run("Settle", func(t *testing.T) {
+		s := open(t)
+		err := Settle(ctx, s, []Transfer{{"a", "b", 30, "rent"}, {"b", "c", 60, "loan"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "a=70[{a -30 rent}] b=20[{b 30 rent} {b -60 loan}] c=60[{c 60 loan}]"
+		if got := state(t, s); got != want {
+			t.Errorf("got  %s\nwant %s", got, want)
+		}
+	})
+
+	t.Run("RollbackMidBatch", func(t *testing.T) {
+		s := open(t)
+		err := Settle(ctx, s, []Transfer{
+			{"a", "b", 30, "rent"},
+			{"b", "c", 60, "loan"},
+			{"c", "a", 100, "overdraft"},
+		})
+		if !errors.Is(err, ErrInsufficientFunds) || !strings.HasPrefix(err.Error(), "transfer 2: ") {
+			t.Fatalf("Settle = %v", err)
+		}
+		if got := state(t, s); got != initial {
+			t.Errorf("after a failed batch: %s", got)
+		}
+		if err := Settle(ctx, s, []Transfer{{"a", "x", 1, ""}}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("to a missing account: %v", err)
+		}
+	})
+
+	t.Run("RollbackOnPanic", func(t *testing.T) {
+		s := open(t)
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("panic swallowed")
+				}
+			}()
+			Do(ctx, s, func(uow UnitOfWork) error {
+				uow.Accounts().Save(ctx, Account{"a", "Ann", 1e6})
+				panic("boom")
+			})
+		}()
+		if got := state(t, s); got != initial {
+			t.Errorf("after a panic: %s", got)
+		}
+	})
+
+	t.Run("Done", func(t *testing.T) {
+		s := open(t)
+		uow, err := s.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := uow.Accounts().Save(ctx, Account{"d", "Dee", 5}); err != nil {
+			t.Fatal(err)
+		}
+		if err := uow.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if err := uow.Commit(); err != ErrDone {
+			t.Errorf("second Commit = %v", err)
+		}
+		if err := uow.Rollback(); err != ErrDone {
+			t.Errorf("Rollback after Commit = %v", err)
+		}
+		if _, err := uow.Accounts().Get(ctx, "d"); !errors.Is(err, ErrDone) {
+			t.Errorf("Get after Commit = %v", err)
+		}
+		Do(ctx, s, func(uow UnitOfWork) error {
+			if a, err := uow.Accounts().Get(ctx, "d"); err != nil || a.Balance != 5 {
+				t.Errorf("Get = %+v, %v", a, err)
+			}
+			return nil
+		})
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		s := open(t)
+		var wg sync.WaitGroup
+		for i := range 30 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ids := []string{"a", "b", "c"}
+				tr := Transfer{ids[i%3], ids[(i+1)%3], int64(1 + i%7), fmt.Sprint(i)}
+				for {
+					err := Settle(ctx, s, []Transfer{tr})
+					if !errors.Is(err, ErrConflict) {
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		// Money moves but is never made or lost, and every balance is its
+		// ledger applied to the opening one.
+		var total int64
+		Do(ctx, s, func(uow UnitOfWork) error {
+			for _, a := range accounts() {
+				got, _ := uow.Accounts().Get(ctx, a.ID)
+				entries, _ := uow.Ledger().Entries(ctx, a.ID)
+				sum := a.Balance
+				for _, e := range entries {
+					sum += e.Amount
+				}
+				if sum != got.Balance || got.Balance < 0 {
+					t.Errorf("%s: balance %d, ledger says %d", a.ID, got.Balance, sum)
+				}
+				total += got.Balance
+			}
+			return nil
+		})
+		if total != 150 {
+			t.Errorf("total %d", total)
+		}
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, func(*testing.T) Store { return NewMemory(accounts()...) })
+}
+
+func TestMemoryIsolation(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(accounts()...)
+	u1, _ := m.Begin(ctx)
+	u2, _ := m.Begin(ctx)
+	if err := (Transfer{"a", "b", 10, ""}).Apply(ctx, u1); err != nil {
+		t.Fatal(err)
+	}
+	// u2 does not see what u1 has not committed.
+	if a, _ := u2.Accounts().Get(ctx, "a"); a.Balance != 100 {
+		t.Errorf("u2 sees %d", a.Balance)
+	}
+	if err := (Transfer{"a", "c", 95, ""}).Apply(ctx, u2); err != nil {
+		t.Fatal(err)
+	}
+	if err := u1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// Both spent the same money: the second to commit loses.
+	if err := u2.Commit(); err != ErrConflict {
+		t.Errorf("u2.Commit = %v", err)
+	}
+	if got := state(t, m); got != "a=90[{a -10 }] b=60[{b 10 }] c=0[]" {
+		t.Error(got)
+	}
+}
+
+// dsn numbers the databases, so that a test run again gets a fresh one.
+var dsn atomic.Int64
+
+func TestSQL(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		db, err := sql.Open("txsql", fmt.Sprint(t.Name(), dsn.Add(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		s, err := NewSQL(context.Background(), db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		Do(context.Background(), s, func(uow UnitOfWork) error {
+			for _, a := range accounts() {
+				uow.Accounts().Save(context.Background(), a)
+			}
+			return nil
+		})
+		return s
+	})
+}
+
+// txsql is a database/sql driver for the statements SQL issues, standing
+// in for SQLite, which is not a dependency of this module. A transaction
+// works on a copy of the tables, which Commit installs; transactions run
+// one at a time, which makes them serializable. Each data source name is
+// a separate database.
+
+func init() { sql.Register("txsql", txDriver{}) }
+
+type txDriver struct{}
+
+var (
+	txMu  sync.Mutex
+	txDBs = map[string]*txDB{}
+)
+
+type txDB struct {
+	lock   chan struct{} // held by the running transaction
+	tables tables
+}
+
+type tables struct {
+	accounts map[string][]driver.Value // id, owner, balance
+	ledger   [][]driver.Value          // account, amount, memo
+}
+
+func (t tables) clone() tables {
+	return tables{maps.Clone(t.accounts), slices.Clone(t.ledger)}
+}
+
+func (txDriver) Open(name string) (driver.Conn, error) {
+	txMu.Lock()
+	defer txMu.Unlock()
+	db, ok := txDBs[name]
+	if !ok {
+		db = &txDB{lock: make(chan struct{}, 1), tables: tables{accounts: map[string][]driver.Value{}}}
+		txDBs[name] = db
+	}
+	return &txConn{db: db}, nil
+}
+
+type txConn struct {
+	db *txDB
+	tx *tables // the working copy of the open transaction
+}
+
+func (c *txConn) Prepare(query string) (driver.Stmt, error) { return txStmt{c, query}, nil }
+func (c *txConn) Close() error                              { return nil }
+func (c *txConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *txConn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	select {
+	case c.db.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	t := c.db.tables.clone()
+	c.tx = &t
+	return c, nil
+}
+
+func (c *txConn) Commit() error {
+	c.db.tables = *c.tx
+	return c.Rollback()
+}
+
+func (c *txConn) Rollback() error {
+	c.tx = nil
+	<-c.db.lock
+	return nil
+}
+
+type txStmt struct {
+	c     *txConn
+	query string
+}
+
+func (txStmt) Close() error  { return nil }
+func (txStmt) NumInput() int { return -1 }
+
+func (s txStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.HasPrefix(s.query, "CREATE TABLE") {
+		return driver.RowsAffected(0), nil
+	}
+	t := s.c.tx
+	if t == nil {
+		return nil, fmt.Errorf("txsql: %q outside a transaction", s.query)
+	}
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO accounts"):
+		t.accounts[args[0].(string)] = slices.Clone(args)
+	case strings.HasPrefix(s.query, "INSERT INTO ledger"):
+		t.ledger = append(t.ledger, slices.Clone(args))
+	default:
+		return nil, fmt.Errorf("txsql: cannot execute %q", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s txStmt) Query(args []driver.Value) (driver.Rows, error) {
+	t := s.c.tx
+	if t == nil {
+		return nil, fmt.Errorf("txsql: %q outside a transaction", s.query)
+	}
+	rows := &txRows{}
+	switch {
+	case strings.HasPrefix(s.query, "SELECT id, owner, balance FROM accounts"):
+		if row, ok := t.accounts[args[0].(string)]; ok {
+			rows.rows = append(rows.rows, row)
+		}
+	case strings.HasPrefix(s.query, "SELECT account, amount, memo FROM ledger"):
+		for _, row := range t.ledger {
+			if row[0] == args[0] {
+				rows.rows = append(rows.rows, row)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("txsql: cannot query %q", s.query)
+	}
+	return rows, nil
+}
+
+type txRows struct {
+	rows [][]driver.Value
+}
+
+func (*txRows) Columns() []string { return []string{"a", "b", "c"} }
+func (*txRows) Close() error      { return nil }
+
+func (r *txRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func ExampleSettle() {
+	ctx := context.Background()
+	s := NewMemory(Account{"a", "Ann", 100}, Account{"b", "Bob", 0})
+	err := Settle(ctx, s, []Transfer{
+		{From: "a", To: "b", Amount: 60},
+		{From: "a", To: "b", Amount: 60},
+	})
+	fmt.Println(err)
+	Do(ctx, s, func(uow UnitOfWork) error {
+		a, _ := uow.Accounts().Get(ctx, "a")
+		fmt.Println(a.Balance)
+		return nil
+	})
+	// Output:
+	// transfer 1: unitofwork: insufficient funds: a has 40, needs 60
+	// 100
+}
@@ -0,0 +1,83 @@
+// Package chain implements the chain of responsibility pattern.
+//
+// A request travels along a list of handlers. Each one looks at it and
+// decides: handle it and stop, change it and pass it on, or refuse it with
+// an error, which stops the chain as well. The sender only knows the chain,
+// not which handler ends up taking the request.
+//
+// Handlers receive the rest of the chain as next and call it to pass the
+// request along, so a handler can also act after the ones behind it, like
+// HTTP middleware.
+package chain
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnhandled is returned when the request passed every handler.
+var ErrUnhandled = errors.New("chain: request not handled")
+
+// Next passes a request to the rest of the chain.
+type Next[R any] func(ctx context.Context, req R) error
+
+// Handler is a link of the chain. It handles req, returning without
+// calling next, or passes it on by returning next(ctx, req).
+type Handler[R any] interface {
+	Handle(ctx context.Context, req R, next Next[R]) error
+}
+
+// HandlerFunc adapts a function to a Handler.
+type HandlerFunc[R any] func(ctx context.Context, req R, next Next[R]) error
+
+func (f HandlerFunc[R]) Handle(ctx context.Context, req R, next Next[R]) error {
+	return f(ctx, req, next)
+}
+
+// Chain is a sequence of handlers.
+type Chain[R any] []Handler[R]
+
+// New returns a chain of the handlers, in order.
+func New[R any](handlers ...Handler[R]) Chain[R] {
+	return Chain[R](handlers)
+}
+
+// Handle sends req down the chain. It returns ErrUnhandled when the last
+// handler passes it on.
+func (c Chain[R]) Handle(ctx context.Context, req R) error {
+	return c.at(0)(ctx, req)
+}
+
+func (c Chain[R]) at(i int) Next[R] {
+	return func(ctx context.Context, req R) error {
+		if i == len(c) {
+			return ErrUnhandled
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return c[i].Handle(ctx, req, c.at(i+1))
+	}
+}
+
+// Filter returns a handler that passes on the requests for which check
+// returns nil, and stops the others with its error.
+func Filter[R any](check func(R) error) Handler[R] {
+	return HandlerFunc[R](func(ctx context.Context, req R, next Next[R]) error {
+		if err := check(req); err != nil {
+			return err
+		}
+		return next(ctx, req)
+	})
+}
+
+// When returns a handler that handles the requests matching match with
+// handle, and passes on the others.
+func When[R any](match func(R) bool, handle func(context.Context, R) error) Handler[R] {
+	return HandlerFunc[R](func(ctx context.Context, req R, next Next[R]) error {
+		if match(req) {
+			return handle(ctx, req)
+		}
+		return next(ctx, req)
+	})
+}
@@ -0,0 +1,151 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// spy passes every request on and counts them.
+type spy struct{ calls int }
+
+func (s *spy) Handle(ctx context.Context, t *Ticket, next Next[*Ticket]) error {
+	s.calls++
+	return next(ctx, t)
+}
+
+var (
+	tiers    = map[string]Tier{"acme": Enterprise, "bob": Pro}
+	keywords = map[string]string{"refund": "billing", "invoice": "billing", "password": "security", "crash": "bug"}
+)
+
+func support(extra ...Handler[*Ticket]) Chain[*Ticket] {
+	return New(append([]Handler[*Ticket]{
+		Validate(),
+		Enrich(tiers, keywords),
+		Route("security", "security"),
+		Escalate(Enterprise, "account-manager"),
+		Route("billing", "billing"),
+	}, extra...)...)
+}
+
+func TestRouting(t *testing.T) {
+	for _, tc := range []struct {
+		ticket Ticket
+		team   string
+	}{
+		{Ticket{Customer: "bob", Subject: "Refund please"}, "billing"},
+		{Ticket{Customer: "acme", Subject: "Wrong invoice"}, "account-manager"},
+		// Security outranks escalation.
+		{Ticket{Customer: "acme", Subject: "Password leaked"}, "security"},
+		{Ticket{Customer: "eve", Subject: "App crash", Body: "on start"}, "tier1"},
+	} {
+		tk := tc.ticket
+		if err := support(Fallback("tier1")).Handle(context.Background(), &tk); err != nil {
+			t.Fatal(err)
+		}
+		if tk.AssignedTo != tc.team {
+			t.Errorf("%q went to %q, want %q", tk.Subject, tk.AssignedTo, tc.team)
+		}
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	tk := Ticket{Customer: "bob", Subject: "Refund", Body: "the invoice after the crash"}
+	support(Fallback("tier1")).Handle(context.Background(), &tk)
+	if tk.Tier != Pro || !slices.Equal(tk.Tags, []string{"billing", "bug"}) {
+		t.Errorf("tier %v, tags %v", tk.Tier, tk.Tags)
+	}
+}
+
+func TestValidationShortCircuits(t *testing.T) {
+	after := &spy{}
+	c := New(Validate(), after, Fallback("tier1"))
+	for _, tk := range []Ticket{{Subject: "no customer"}, {Customer: "bob", Subject: "  "}} {
+		err := c.Handle(context.Background(), &tk)
+		if !errors.Is(err, ErrInvalidTicket) {
+			t.Errorf("Handle(%+v) = %v", tk, err)
+		}
+		if tk.AssignedTo != "" {
+			t.Errorf("invalid ticket assigned to %q", tk.AssignedTo)
+		}
+	}
+	if after.calls != 0 {
+		t.Errorf("handlers after validation ran %d times", after.calls)
+	}
+}
+
+func TestHandledStopsChain(t *testing.T) {
+	after := &spy{}
+	tk := Ticket{Customer: "bob", Subject: "refund"}
+	if err := support(after).Handle(context.Background(), &tk); err != nil {
+		t.Fatal(err)
+	}
+	if after.calls != 0 {
+		t.Error("the chain went on after billing took the ticket")
+	}
+}
+
+func TestUnhandled(t *testing.T) {
+	tk := Ticket{Customer: "eve", Subject: "hello"}
+	if err := support().Handle(context.Background(), &tk); !errors.Is(err, ErrUnhandled) {
+		t.Errorf("Handle = %v", err)
+	}
+	if err := New[*Ticket]().Handle(context.Background(), &tk); !errors.Is(err, ErrUnhandled) {
+		t.Errorf("empty chain: %v", err)
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tk := Ticket{Customer: "bob", Subject: "refund"}
+	if err := support().Handle(ctx, &tk); !errors.Is(err, context.Canceled) {
+		t.Errorf("Handle = %v", err)
+	}
+}
+
+func TestWrapping(t *testing.T) {
+	// A handler can act on the way back, after the rest of the chain.
+	var log []string
+	audit := HandlerFunc[*Ticket](func(ctx context.Context, tk *Ticket, next Next[*Ticket]) error {
+		err := next(ctx, tk)
+		log = append(log, fmt.Sprintf("%s -> %s (%v)", tk.Subject, tk.AssignedTo, err))
+		return err
+	})
+	c := New(audit, Validate(), Fallback("tier1"))
+	c.Handle(context.Background(), &Ticket{Customer: "bob", Subject: "hi"})
+	c.Handle(context.Background(), &Ticket{Customer: "bob"})
+	if len(log) != 2 || log[0] != "hi -> tier1 (<nil>)" {
+		t.Errorf("audit log %q", log)
+	}
+}
+
+func Example() {
+	c := New(
+		Validate(),
+		Enrich(map[string]Tier{"acme": Enterprise}, map[string]string{"refund": "billing"}),
+		Escalate(Enterprise, "account-manager"),
+		Route("billing", "billing"),
+		Fallback("tier1"),
+	)
+	for _, tk := range []*Ticket{
+		{Customer: "bob", Subject: "Refund for order 42"},
+		{Customer: "acme", Subject: "Refund for order 43"},
+		{Customer: "bob", Subject: "How do I log in?"},
+		{Customer: "bob"},
+	} {
+		if err := c.Handle(context.Background(), tk); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%s: %s\n", tk.Subject, tk.AssignedTo)
+	}
+	// Output:
+	// Refund for order 42: billing
+	// Refund for order 43: account-manager
+	// How do I log in?: tier1
+	// chain: invalid ticket: no subject
+}
@@ -0,0 +1,87 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidTicket is returned by the validation step of the support chain.
+var ErrInvalidTicket = errors.New("chain: invalid ticket")
+
+// Tier is a customer's support plan.
+type Tier int
+
+const (
+	Free Tier = iota
+	Pro
+	Enterprise
+)
+
+// Ticket is a support request, the example request of this package.
+type Ticket struct {
+	Customer string
+	Subject  string
+	Body     string
+
+	// Filled in by the chain.
+	Tier       Tier
+	Tags       []string
+	AssignedTo string
+}
+
+// Validate refuses tickets without a customer or subject.
+func Validate() Handler[*Ticket] {
+	return Filter(func(t *Ticket) error {
+		switch {
+		case t.Customer == "":
+			return fmt.Errorf("%w: no customer", ErrInvalidTicket)
+		case strings.TrimSpace(t.Subject) == "":
+			return fmt.Errorf("%w: no subject", ErrInvalidTicket)
+		}
+		return nil
+	})
+}
+
+// Enrich looks up the customer's tier and tags the ticket by the keywords
+// it contains.
+func Enrich(tiers map[string]Tier, keywords map[string]string) Handler[*Ticket] {
+	return HandlerFunc[*Ticket](func(ctx context.Context, t *Ticket, next Next[*Ticket]) error {
+		t.Tier = tiers[t.Customer]
+		text := strings.ToLower(t.Subject + " " + t.Body)
+		for word, tag := range keywords {
+			if strings.Contains(text, word) && !slices.Contains(t.Tags, tag) {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+		slices.Sort(t.Tags)
+		return next(ctx, t)
+	})
+}
+
+// Route assigns tickets tagged tag to team.
+func Route(tag, team string) Handler[*Ticket] {
+	return When(
+		func(t *Ticket) bool { return slices.Contains(t.Tags, tag) },
+		assign(team),
+	)
+}
+
+// Escalate assigns every ticket of customers on tier or above to team.
+func Escalate(tier Tier, team string) Handler[*Ticket] {
+	return When(func(t *Ticket) bool { return t.Tier >= tier }, assign(team))
+}
+
+// Fallback assigns every ticket that reaches it to team.
+func Fallback(team string) Handler[*Ticket] {
+	return When(func(*Ticket) bool { return true }, assign(team))
+}
+
+func assign(team string) func(context.Context, *Ticket) error {
+	return func(_ context.Context, t *Ticket) error {
+		t.AssignedTo = team
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+package undo
+
+import (
+	"errors"
+	"slices"
+	"unicode/utf8"
+)
+
+// ErrRange is returned for positions outside the document.
+var ErrRange = errors.New("undo: position out of range")
+
+// Document is the receiver of the editing commands: text addressed by
+// rune position.
+type Document struct {
+	text []rune
+}
+
+// String returns the text.
+func (d *Document) String() string { return string(d.text) }
+
+// Len returns the length in runes.
+func (d *Document) Len() int { return len(d.text) }
+
+func (d *Document) insert(pos int, s []rune) error {
+	if pos < 0 || pos > len(d.text) {
+		return ErrRange
+	}
+	d.text = slices.Insert(d.text, pos, s...)
+	return nil
+}
+
+func (d *Document) delete(pos, n int) ([]rune, error) {
+	if pos < 0 || n < 0 || pos+n > len(d.text) {
+		return nil, ErrRange
+	}
+	cut := slices.Clone(d.text[pos : pos+n])
+	d.text = slices.Delete(d.text, pos, pos+n)
+	return cut, nil
+}
+
+// Insert inserts Text at Pos.
+type Insert struct {
+	Doc  *Document
+	Pos  int
+	Text string
+}
+
+func (c *Insert) Execute() error { return c.Doc.insert(c.Pos, []rune(c.Text)) }
+
+func (c *Insert) Undo() error {
+	_, err := c.Doc.delete(c.Pos, utf8.RuneCountInString(c.Text))
+	return err
+}
+
+// Delete deletes N runes at Pos, remembering them for Undo.
+type Delete struct {
+	Doc *Document
+	Pos int
+	N   int
+
+	deleted []rune
+}
+
+func (c *Delete) Execute() error {
+	cut, err := c.Doc.delete(c.Pos, c.N)
+	c.deleted = cut
+	return err
+}
+
+func (c *Delete) Undo() error { return c.Doc.insert(c.Pos, c.deleted) }
+
+// Replace returns a macro that replaces n runes at pos by text.
+func Replace(d *Document, pos, n int, text string) Macro {
+	return Macro{&Delete{Doc: d, Pos: pos, N: n}, &Insert{Doc: d, Pos: pos, Text: text}}
+}
@@ -0,0 +1,110 @@
+// Package undo implements the command pattern with undo and redo.
+//
+// Every change to a receiver is a Command that knows how to apply itself
+// and how to take itself back. A History executes commands and keeps them
+// on two stacks, so the changes can be walked back and forth; a Macro
+// bundles commands into one step that is undone as a whole.
+package undo
+
+import "errors"
+
+var (
+	// ErrNothingToUndo is returned by Undo on an empty history.
+	ErrNothingToUndo = errors.New("undo: nothing to undo")
+	// ErrNothingToRedo is returned by Redo when no undone command is left,
+	// including after a new command discarded them.
+	ErrNothingToRedo = errors.New("undo: nothing to redo")
+)
+
+// Command is a reversible change. Undo is only called after a successful
+// Execute, and Execute again only after Undo.
+type Command interface {
+	Execute() error
+	Undo() error
+}
+
+// Macro is a command made of others, executed in order and undone in
+// reverse.
+type Macro []Command
+
+// Execute runs the commands in order. If one fails, those already run are
+// undone, so the macro either happens completely or not at all.
+func (m Macro) Execute() error {
+	for i, c := range m {
+		if err := c.Execute(); err != nil {
+			return errors.Join(err, m[:i].Undo())
+		}
+	}
+	return nil
+}
+
+// Undo undoes the commands in reverse order.
+func (m Macro) Undo() error {
+	for i := len(m) - 1; i >= 0; i-- {
+		if err := m[i].Undo(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History runs commands and remembers them for Undo and Redo. It is not
+// safe for concurrent use.
+type History struct {
+	limit        int
+	undos, redos []Command
+}
+
+// NewHistory returns a History that remembers up to limit commands, or any
+// number if limit is 0.
+func NewHistory(limit int) *History {
+	return &History{limit: limit}
+}
+
+// Do executes c and records it. Commands undone earlier can no longer be
+// redone.
+func (h *History) Do(c Command) error {
+	if err := c.Execute(); err != nil {
+		return err
+	}
+	h.undos = append(h.undos, c)
+	if h.limit > 0 && len(h.undos) > h.limit {
+		h.undos = h.undos[len(h.undos)-h.limit:]
+	}
+	h.redos = h.redos[:0]
+	return nil
+}
+
+// Undo undoes the last command.
+func (h *History) Undo() error {
+	if len(h.undos) == 0 {
+		return ErrNothingToUndo
+	}
+	c := h.undos[len(h.undos)-1]
+	if err := c.Undo(); err != nil {
+		return err
+	}
+	h.undos = h.undos[:len(h.undos)-1]
+	h.redos = append(h.redos, c)
+	return nil
+}
+
+// Redo executes the last undone command again.
+func (h *History) Redo() error {
+	if len(h.redos) == 0 {
+		return ErrNothingToRedo
+	}
+	c := h.redos[len(h.redos)-1]
+	if err := c.Execute(); err != nil {
+		return err
+	}
+	h.redos = h.redos[:len(h.redos)-1]
+	h.undos = append(h.undos, c)
+	return nil
+}
+
+// CanUndo reports whether Undo has a command to undo.
+func (h *History) CanUndo() bool { return len(h.undos) > 0 }
+
+// CanRedo reports whether Redo has a command to redo.
+func (h *History) CanRedo() bool { return len(h.redos) > 0 }
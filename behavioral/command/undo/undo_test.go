@@ -0,0 +1,159 @@
+package undo
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	d := &Document{}
+	h := NewHistory(0)
+	h.Do(&Insert{Doc: d, Pos: 0, Text: "hello"})
+	h.Do(&Insert{Doc: d, Pos: 5, Text: " world"})
+	h.Do(&Delete{Doc: d, Pos: 0, N: 1})
+
+	for _, step := range []struct {
+		do   func() error
+		want string
+	}{
+		{h.Undo, "hello world"},
+		{h.Undo, "hello"},
+		{h.Redo, "hello world"},
+		{h.Redo, "ello world"},
+		{h.Undo, "hello world"},
+	} {
+		if err := step.do(); err != nil {
+			t.Fatal(err)
+		}
+		if d.String() != step.want {
+			t.Fatalf("got %q, want %q", d, step.want)
+		}
+	}
+
+	// A new command discards what could be redone.
+	h.Do(&Insert{Doc: d, Pos: 11, Text: "!"})
+	if err := h.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Redo = %v", err)
+	}
+}
+
+func TestFailedCommandIsNotRecorded(t *testing.T) {
+	d := &Document{}
+	h := NewHistory(0)
+	if err := h.Do(&Delete{Doc: d, Pos: 0, N: 3}); !errors.Is(err, ErrRange) {
+		t.Fatalf("Do = %v", err)
+	}
+	if h.CanUndo() {
+		t.Error("a failed command must not be undoable")
+	}
+	if err := h.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undo = %v", err)
+	}
+}
+
+func TestMacroIsAtomic(t *testing.T) {
+	d := &Document{}
+	h := NewHistory(0)
+	h.Do(&Insert{Doc: d, Pos: 0, Text: "abc"})
+
+	h.Do(Replace(d, 1, 1, "BBB"))
+	if d.String() != "aBBBc" {
+		t.Fatalf("after Replace: %q", d)
+	}
+	h.Undo()
+	if d.String() != "abc" {
+		t.Fatalf("Undo of the macro: %q", d)
+	}
+
+	// The second command fails, so the first is rolled back.
+	bad := Macro{&Insert{Doc: d, Pos: 0, Text: "x"}, &Delete{Doc: d, Pos: 2, N: 9}}
+	if err := h.Do(bad); !errors.Is(err, ErrRange) {
+		t.Fatalf("Do = %v", err)
+	}
+	if d.String() != "abc" {
+		t.Errorf("failed macro left %q", d)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	d := &Document{}
+	h := NewHistory(2)
+	for _, s := range []string{"a", "b", "c"} {
+		h.Do(&Insert{Doc: d, Pos: d.Len(), Text: s})
+	}
+	h.Undo()
+	h.Undo()
+	if err := h.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("third Undo = %v", err)
+	}
+	if d.String() != "a" {
+		t.Errorf("got %q", d)
+	}
+}
+
+// TestReplay runs seeded random sequences of edits, undos and redos and
+// checks every state against a model that keeps a snapshot per step.
+func TestReplay(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		d := &Document{}
+		h := NewHistory(0)
+		states, cur := []string{""}, 0
+
+		for step := 0; step < 300; step++ {
+			switch r := rng.Intn(10); {
+			case r < 2:
+				if err := h.Undo(); (err == nil) != (cur > 0) {
+					t.Fatalf("seed %d step %d: Undo = %v", seed, step, err)
+				}
+				cur = max(cur-1, 0)
+			case r < 4:
+				if err := h.Redo(); (err == nil) != (cur < len(states)-1) {
+					t.Fatalf("seed %d step %d: Redo = %v", seed, step, err)
+				}
+				cur = min(cur+1, len(states)-1)
+			default:
+				if err := h.Do(randomCommand(rng, d)); err != nil {
+					t.Fatalf("seed %d step %d: %v", seed, step, err)
+				}
+				states = append(states[:cur+1], d.String())
+				cur++
+			}
+			if d.String() != states[cur] {
+				t.Fatalf("seed %d step %d: got %q, want %q", seed, step, d, states[cur])
+			}
+		}
+	}
+}
+
+func randomCommand(rng *rand.Rand, d *Document) Command {
+	n := d.Len()
+	pos := rng.Intn(n + 1)
+	text := string(rune('a'+rng.Intn(26))) + "é"
+	switch {
+	case n > 0 && rng.Intn(3) == 0:
+		return &Delete{Doc: d, Pos: pos % n, N: rng.Intn(n-pos%n) + 1}
+	case n > 0 && rng.Intn(3) == 0:
+		return Replace(d, pos%n, 1, text)
+	default:
+		return &Insert{Doc: d, Pos: pos, Text: text}
+	}
+}
+
+func Example() {
+	d := &Document{}
+	h := NewHistory(100)
+	h.Do(&Insert{Doc: d, Pos: 0, Text: "Hello, world"})
+	h.Do(Replace(d, 7, 5, "Gophers"))
+	fmt.Println(d)
+	h.Undo()
+	fmt.Println(d)
+	h.Redo()
+	fmt.Println(d)
+	// Output:
+	// Hello, Gophers
+	// Hello, world
+	// Hello, Gophers
+}
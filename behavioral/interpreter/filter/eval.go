@@ -0,0 +1,201 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Node is a node of a parsed expression.
+type Node interface {
+	Eval(env Env) (any, error)
+	// String returns the node fully parenthesized.
+	String() string
+}
+
+// Lit is a literal number, string or boolean.
+type Lit struct{ V any }
+
+// Var is a variable.
+type Var string
+
+// Unary is ! or - applied to X.
+type Unary struct {
+	Op string
+	X  Node
+}
+
+// Binary is a binary operator applied to L and R.
+type Binary struct {
+	Op   string
+	L, R Node
+}
+
+func (l Lit) Eval(Env) (any, error) { return l.V, nil }
+
+func (l Lit) String() string {
+	switch v := l.V.(type) {
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(l.V)
+}
+
+func (v Var) Eval(env Env) (any, error) {
+	x, ok := env.Lookup(string(v))
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownVar, string(v))
+	}
+	switch x := x.(type) {
+	case bool, string, float64:
+		return x, nil
+	case int:
+		return float64(x), nil
+	case int8:
+		return float64(x), nil
+	case int16:
+		return float64(x), nil
+	case int32:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case uint:
+		return float64(x), nil
+	case uint8:
+		return float64(x), nil
+	case uint16:
+		return float64(x), nil
+	case uint32:
+		return float64(x), nil
+	case uint64:
+		return float64(x), nil
+	case float32:
+		return float64(x), nil
+	}
+	return nil, fmt.Errorf("%w: variable %q is %T", ErrType, string(v), x)
+}
+
+func (v Var) String() string { return string(v) }
+
+func (u *Unary) Eval(env Env) (any, error) {
+	x, err := u.X.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case bool:
+		if u.Op == "!" {
+			return !x, nil
+		}
+	case float64:
+		if u.Op == "-" {
+			return -x, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s of %s", ErrType, u.Op, typeName(x))
+}
+
+func (u *Unary) String() string { return "(" + u.Op + u.X.String() + ")" }
+
+func (b *Binary) Eval(env Env) (any, error) {
+	l, err := b.L.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if b.Op == "&&" || b.Op == "||" {
+		lb, ok := l.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s %s", ErrType, typeName(l), b.Op)
+		}
+		if lb == (b.Op == "||") {
+			return lb, nil
+		}
+		r, err := b.R.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := r.(bool); !ok {
+			return nil, fmt.Errorf("%w: %s %s", ErrType, b.Op, typeName(r))
+		}
+		return r, nil
+	}
+
+	r, err := b.R.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch b.Op {
+	case "==":
+		return l == r, nil
+	case "!=":
+		return l != r, nil
+	}
+	switch l := l.(type) {
+	case float64:
+		if r, ok := r.(float64); ok {
+			return arith(b.Op, l, r)
+		}
+	case string:
+		if r, ok := r.(string); ok {
+			switch b.Op {
+			case "+":
+				return l + r, nil
+			case "<":
+				return l < r, nil
+			case "<=":
+				return l <= r, nil
+			case ">":
+				return l > r, nil
+			case ">=":
+				return l >= r, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s %s %s", ErrType, typeName(l), b.Op, typeName(r))
+}
+
+func arith(op string, l, r float64) (any, error) {
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/", "%":
+		if r == 0 {
+			return nil, ErrDivByZero
+		}
+		if op == "/" {
+			return l / r, nil
+		}
+		return math.Mod(l, r), nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	panic("filter: unknown operator " + op)
+}
+
+func (b *Binary) String() string {
+	return "(" + b.L.String() + " " + b.Op + " " + b.R.String() + ")"
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case bool:
+		return "a boolean"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	}
+	return fmt.Sprintf("%T", v)
+}
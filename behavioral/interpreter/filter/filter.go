@@ -0,0 +1,93 @@
+// Package filter is an interpreter for a small language of filter
+// expressions such as
+//
+//	age > 30 && name == "bob"
+//	(priority >= 2 || queue == "urgent") && !paused
+//
+// An expression is lexed, parsed into a tree of nodes and then evaluated
+// against an Env, which supplies the values of its variables. Values are
+// numbers (float64), strings and booleans. The operators, loosest first,
+// are ||, &&, the comparisons == != < <= > >=, + and -, * / and %, and
+// the unary ! and -. + also joins strings, and < and friends compare
+// strings too. && and || stop early, so `n != 0 && total / n > 2` never
+// divides by zero.
+package filter
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrType is returned when an operator gets a value of the wrong type.
+	ErrType = errors.New("filter: type mismatch")
+	// ErrUnknownVar is returned for a variable the Env does not have.
+	ErrUnknownVar = errors.New("filter: unknown variable")
+	// ErrDivByZero is returned for division or remainder by zero.
+	ErrDivByZero = errors.New("filter: division by zero")
+)
+
+// SyntaxError reports an expression that does not parse.
+type SyntaxError struct {
+	Pos int // byte offset in the source
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: syntax error at %d: %s", e.Pos, e.Msg)
+}
+
+// Env supplies the values of variables.
+type Env interface {
+	Lookup(name string) (any, bool)
+}
+
+// Vars is an Env backed by a map. Its values may be any integer or float
+// type, strings or booleans.
+type Vars map[string]any
+
+func (v Vars) Lookup(name string) (any, bool) {
+	x, ok := v[name]
+	return x, ok
+}
+
+// Filter is a compiled expression that evaluates to a boolean.
+type Filter struct {
+	src  string
+	root Node
+}
+
+// Compile parses src.
+func Compile(src string) (*Filter, error) {
+	n, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{src: src, root: n}, nil
+}
+
+// MustCompile is like Compile but panics on error.
+func MustCompile(src string) *Filter {
+	f, err := Compile(src)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Match evaluates f against env. An expression that is not boolean is an
+// ErrType.
+func (f *Filter) Match(env Env) (bool, error) {
+	v, err := f.root.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: %s is %s, not a boolean", ErrType, f.root, typeName(v))
+	}
+	return b, nil
+}
+
+// String returns the source f was compiled from.
+func (f *Filter) String() string { return f.src }
@@ -0,0 +1,182 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{`age > 30 && name == "bob"`, `((age > 30) && (name == "bob"))`},
+		{`a || b && c`, `(a || (b && c))`},
+		{`(a || b) && c`, `((a || b) && c)`},
+		{`1 + 2 * 3 - 4`, `((1 + (2 * 3)) - 4)`},
+		{`10 / 2 / 5`, `((10 / 2) / 5)`},
+		{`-x * 2 >= .5`, `(((-x) * 2) >= 0.5)`},
+		{`!!done`, `(!(!done))`},
+		{`!a == b`, `((!a) == b)`},
+		{`task.queue != "mail\t\"x\""`, `(task.queue != "mail\t\"x\"")`},
+		{`true && !false`, `(true && (!false))`},
+		{`  n%3==0  `, `((n % 3) == 0)`},
+	} {
+		n, err := Parse(tc.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.src, err)
+			continue
+		}
+		if got := n.String(); got != tc.want {
+			t.Errorf("Parse(%q) = %s, want %s", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src string
+		pos int
+	}{
+		{``, 0},
+		{`age >`, 5},
+		{`(a && b`, 7},
+		{`a b`, 2},
+		{`a < b < c`, 6},
+		{`name == "bob`, 8},
+		{`x = 1`, 2},
+		{`1.2.3 > x`, 0},
+		{`a && )`, 5},
+		{`#`, 0},
+	} {
+		_, err := Parse(tc.src)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("Parse(%q) = %v, want a SyntaxError", tc.src, err)
+			continue
+		}
+		if se.Pos != tc.pos {
+			t.Errorf("Parse(%q) fails at %d (%v), want %d", tc.src, se.Pos, err, tc.pos)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	env := Vars{"age": 42, "name": "bob", "score": 2.5, "admin": false, "n": 0, "tags": []string{"x"}}
+	for _, tc := range []struct {
+		src  string
+		want bool
+		err  error
+	}{
+		{`age > 30 && name == "bob"`, true, nil},
+		{`age > 50 || admin`, false, nil},
+		{`score * 2 == 5`, true, nil},
+		{`age % 5 == 2 && -age < 0`, true, nil},
+		{`name + "!" == "bob!"`, true, nil},
+		{`name < "carol"`, true, nil},
+		{`name == 3`, false, nil},
+		{`n != 0 && age / n > 1`, false, nil},
+		{`n == 0 || missing`, true, nil},
+		{`age / n > 1`, false, ErrDivByZero},
+		{`age`, false, ErrType},
+		{`missing == 1`, false, ErrUnknownVar},
+		{`name > 1`, false, ErrType},
+		{`!name`, false, ErrType},
+		{`age && true`, false, ErrType},
+		{`true && age`, false, ErrType},
+		{`tags == "x"`, false, ErrType},
+	} {
+		got, err := MustCompile(tc.src).Match(env)
+		if !errors.Is(err, tc.err) || got != tc.want {
+			t.Errorf("%s = %v, %v; want %v, %v", tc.src, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+// job is a worker pool task with attributes to filter on.
+type job struct {
+	id    int
+	queue string
+	prio  int
+	ran   *sync.Map
+}
+
+func (j job) Lookup(name string) (any, bool) {
+	switch name {
+	case "queue":
+		return j.queue, true
+	case "priority":
+		return j.prio, true
+	}
+	return nil, false
+}
+
+func (j job) Task(context.Context) error {
+	j.ran.Store(j.id, true)
+	return nil
+}
+
+// TestWorkerFilter picks the tasks to run on a worker pool with a filter.
+func TestWorkerFilter(t *testing.T) {
+	pool := workerpool.New(3)
+	defer pool.Shutdown()
+	f := MustCompile(`queue == "mail" && priority >= 2 || queue == "urgent"`)
+
+	var ran sync.Map
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		j := job{id: i, queue: []string{"mail", "urgent", "batch"}[i%3], prio: i % 4, ran: &ran}
+		ok, err := f.Match(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.Run(context.Background(), j)
+		}()
+	}
+	wg.Wait()
+
+	var got []int
+	ran.Range(func(k, _ any) bool {
+		got = append(got, k.(int))
+		return true
+	})
+	slices.Sort(got)
+	// Mail jobs 0, 3, 6, 9 have priorities 0, 3, 2, 1.
+	if want := []int{1, 3, 4, 6, 7, 10}; !slices.Equal(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
+
+func Example() {
+	f, err := Compile(`age > 30 && name == "bob"`)
+	if err != nil {
+		panic(err)
+	}
+	for _, user := range []Vars{
+		{"name": "bob", "age": 42},
+		{"name": "bob", "age": 25},
+		{"name": "alice", "age": 42},
+	} {
+		ok, _ := f.Match(user)
+		fmt.Println(user["name"], user["age"], ok)
+	}
+
+	_, err = Compile(`age > `)
+	fmt.Println(err)
+	// Output:
+	// bob 42 true
+	// bob 25 false
+	// alice 42 false
+	// filter: syntax error at 6: expected an operand but found end of input
+}
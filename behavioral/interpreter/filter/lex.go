@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type kind int
+
+const (
+	tEOF kind = iota
+	tNum
+	tStr
+	tIdent
+	tOp // operators and parentheses
+)
+
+type token struct {
+	kind kind
+	text string // the operator or identifier, or the unquoted string
+	num  float64
+	pos  int
+}
+
+// operators lists the operators, longest first so that "<=" wins over "<".
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%", "!", "(", ")"}
+
+// lex splits src into tokens, ending with a tEOF.
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r >= '0' && r <= '9' || r == '.':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			n, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, &SyntaxError{i, "bad number " + strconv.Quote(src[i:j])}
+			}
+			toks = append(toks, token{kind: tNum, num: n, text: src[i:j], pos: i})
+			i = j
+		case r == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, &SyntaxError{i, "unterminated string"}
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return nil, &SyntaxError{i, "bad string " + src[i:j+1]}
+			}
+			toks = append(toks, token{kind: tStr, text: s, pos: i})
+			i = j + 1
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(src) {
+				r, size := utf8.DecodeRuneInString(src[j:])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += size
+			}
+			toks = append(toks, token{kind: tIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{i, "unexpected " + strconv.QuoteRune(r)}
+			}
+			toks = append(toks, token{kind: tOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tEOF, pos: len(src)}), nil
+}
@@ -0,0 +1,146 @@
+package filter
+
+import (
+	"slices"
+	"strconv"
+)
+
+// levels lists the binary operators by precedence, loosest first.
+var levels = [][]string{
+	{"||"},
+	{"&&"},
+	comparisons,
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+var comparisons = []string{"==", "!=", "<", "<=", ">", ">="}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+// Parse parses src into a tree of nodes.
+func Parse(src string) (Node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tEOF {
+		return nil, &SyntaxError{t.pos, "unexpected " + describe(t)}
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of ops.
+func (p *parser) accept(ops []string) (string, bool) {
+	t := p.peek()
+	if t.kind != tOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.i++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// binary parses the operators of levels[level] and tighter. Operators of
+// one level associate to the left; comparisons do not chain.
+func (p *parser) binary(level int) (Node, error) {
+	if level == len(levels) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(levels[level])
+		if !ok {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: op, L: left, R: right}
+		if slices.Contains(comparisons, op) {
+			if t := p.peek(); t.kind == tOp && slices.Contains(comparisons, t.text) {
+				return nil, &SyntaxError{t.pos, "comparisons do not chain"}
+			}
+		}
+	}
+}
+
+func (p *parser) unary() (Node, error) {
+	if op, ok := p.accept([]string{"!", "-"}); ok {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: op, X: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tNum:
+		return Lit{t.num}, nil
+	case tStr:
+		return Lit{t.text}, nil
+	case tIdent:
+		switch t.text {
+		case "true":
+			return Lit{true}, nil
+		case "false":
+			return Lit{false}, nil
+		}
+		return Var(t.text), nil
+	case tOp:
+		if t.text == "(" {
+			n, err := p.binary(0)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept([]string{")"}); !ok {
+				c := p.peek()
+				return nil, &SyntaxError{c.pos, "expected ) but found " + describe(c)}
+			}
+			return n, nil
+		}
+	}
+	return nil, &SyntaxError{t.pos, "expected an operand but found " + describe(t)}
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tEOF:
+		return "end of input"
+	case tStr:
+		return "string " + strconv.Quote(t.text)
+	case tNum:
+		return "number " + t.text
+	}
+	return strconv.Quote(t.text)
+}
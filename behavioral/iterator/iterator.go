@@ -0,0 +1,63 @@
+// Package iterator implements the iterator pattern with the standard
+// iterator types of package iter.
+//
+// Each collection has methods returning an iter.Seq or iter.Seq2, so it
+// can be ranged over with a for loop while hiding how it is stored: a
+// linked list, a binary search tree, or a paginated API whose pages are
+// fetched only as the loop reaches them. Breaking out of the loop stops the
+// iterator, so a loop that only wants the first few items of a big tree or
+// a remote listing does no more work than that.
+//
+// Merge shows the other direction: it turns push iterators into pull
+// iterators with iter.Pull to step through two sequences in lockstep,
+// which a range loop cannot do.
+package iterator
+
+import (
+	"cmp"
+	"iter"
+)
+
+// Merge yields the elements of a and b, both sorted, in sorted order.
+func Merge[T cmp.Ordered](a, b iter.Seq[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		nextA, stopA := iter.Pull(a)
+		defer stopA()
+		nextB, stopB := iter.Pull(b)
+		defer stopB()
+
+		va, okA := nextA()
+		vb, okB := nextB()
+		for okA || okB {
+			if okA && (!okB || va <= vb) {
+				if !yield(va) {
+					return
+				}
+				va, okA = nextA()
+			} else {
+				if !yield(vb) {
+					return
+				}
+				vb, okB = nextB()
+			}
+		}
+	}
+}
+
+// Take yields the first n elements of seq.
+func Take[T any](seq iter.Seq[T], n int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for v := range seq {
+			if !yield(v) {
+				return
+			}
+			if i++; i == n {
+				return
+			}
+		}
+	}
+}
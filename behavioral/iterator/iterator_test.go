@@ -0,0 +1,249 @@
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	var l List[int]
+	for i := 1; i <= 3; i++ {
+		l.PushBack(i)
+	}
+	l.PushFront(0)
+	if got := slices.Collect(l.All()); !slices.Equal(got, []int{0, 1, 2, 3}) {
+		t.Errorf("All = %v", got)
+	}
+	if got := slices.Collect(l.Backward()); !slices.Equal(got, []int{3, 2, 1, 0}) {
+		t.Errorf("Backward = %v", got)
+	}
+	var got []int
+	for v := range l.All() {
+		if v == 2 {
+			break
+		}
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{0, 1}) {
+		t.Errorf("loop up to break = %v", got)
+	}
+}
+
+func TestTreeMatchesMap(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 50; round++ {
+		var tr Tree[int, string]
+		m := map[int]string{}
+		for i := rng.Intn(200); i > 0; i-- {
+			k := rng.Intn(100)
+			tr.Set(k, strconv.Itoa(i))
+			m[k] = strconv.Itoa(i)
+		}
+		keys := slices.Sorted(maps.Keys(m))
+		if got := slices.Collect(tr.Keys()); !slices.Equal(got, keys) || tr.Len() != len(m) {
+			t.Fatalf("Keys = %v, want %v", got, keys)
+		}
+		for k, v := range tr.All() {
+			if m[k] != v {
+				t.Fatalf("All yields %d=%q, want %q", k, v, m[k])
+			}
+		}
+
+		lo, hi := rng.Intn(100), rng.Intn(100)
+		var want []int
+		for _, k := range keys {
+			if k >= lo && k < hi {
+				want = append(want, k)
+			}
+		}
+		var got []int
+		for k := range tr.Range(lo, hi) {
+			got = append(got, k)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("Range(%d, %d) = %v, want %v", lo, hi, got, want)
+		}
+	}
+}
+
+func TestTreeEarlyBreak(t *testing.T) {
+	var tr Tree[int, int]
+	for _, k := range rand.New(rand.NewSource(2)).Perm(1000) {
+		tr.Set(k, k*k)
+	}
+	var got []int
+	for k, v := range tr.All() {
+		if k == 3 {
+			break
+		}
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{0, 1, 4}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+// api serves items 0..n-1 in pages of size and counts its requests.
+type api struct {
+	n, size int
+	fail    string // token whose request fails
+	calls   int
+}
+
+func (a *api) fetch(ctx context.Context, token string) (Page[int], error) {
+	if err := ctx.Err(); err != nil {
+		return Page[int]{}, err
+	}
+	a.calls++
+	if token != "" && token == a.fail {
+		return Page[int]{}, errors.New("503 from api")
+	}
+	start, _ := strconv.Atoi(token)
+	var p Page[int]
+	for i := start; i < min(start+a.size, a.n); i++ {
+		p.Items = append(p.Items, i)
+	}
+	if start+a.size < a.n {
+		p.Next = strconv.Itoa(start + a.size)
+	}
+	return p, nil
+}
+
+func TestPages(t *testing.T) {
+	ctx := context.Background()
+	a := &api{n: 95, size: 10}
+	var got []int
+	for v, err := range Pages(ctx, a.fetch) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if len(got) != 95 || got[94] != 94 || a.calls != 10 {
+		t.Errorf("got %d items in %d calls", len(got), a.calls)
+	}
+
+	// Stopping early stops fetching.
+	a = &api{n: 95, size: 10}
+	for v := range Pages(ctx, a.fetch) {
+		if v == 25 {
+			break
+		}
+	}
+	if a.calls != 3 {
+		t.Errorf("fetched %d pages to reach item 25, want 3", a.calls)
+	}
+}
+
+func TestPagesError(t *testing.T) {
+	a := &api{n: 95, size: 10, fail: "30"}
+	n := 0
+	var last error
+	for _, err := range Pages(context.Background(), a.fetch) {
+		if err != nil {
+			last = err
+			continue
+		}
+		n++
+	}
+	if n != 30 || last == nil {
+		t.Errorf("got %d items then %v, want 30 then the error", n, last)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, err := range Pages(ctx, (&api{n: 5, size: 2}).fetch) {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("cancelled listing yields %v", err)
+		}
+	}
+}
+
+// tracked yields vs and records whether it ran to its end or was stopped.
+func tracked[T any](vs []T, stopped *bool) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range vs {
+			if !yield(v) {
+				*stopped = true
+				return
+			}
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for round := 0; round < 100; round++ {
+		a := make([]int, rng.Intn(10))
+		b := make([]int, rng.Intn(10))
+		for i := range a {
+			a[i] = rng.Intn(20)
+		}
+		for i := range b {
+			b[i] = rng.Intn(20)
+		}
+		slices.Sort(a)
+		slices.Sort(b)
+		want := slices.Sorted(slices.Values(append(slices.Clone(a), b...)))
+		if got := slices.Collect(Merge(slices.Values(a), slices.Values(b))); !slices.Equal(got, want) {
+			t.Fatalf("Merge(%v, %v) = %v", a, b, got)
+		}
+	}
+}
+
+func TestMergeStopsInputs(t *testing.T) {
+	var stopA, stopB bool
+	got := slices.Collect(Take(Merge(tracked([]int{1, 3, 5}, &stopA), tracked([]int{2, 4, 6}, &stopB)), 3))
+	if !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("got %v", got)
+	}
+	if !stopA || !stopB {
+		t.Errorf("inputs stopped: %v, %v; want both", stopA, stopB)
+	}
+}
+
+func TestTake(t *testing.T) {
+	var l List[int]
+	for i := range 5 {
+		l.PushBack(i)
+	}
+	for _, n := range []int{-1, 0, 2, 5, 9} {
+		want := slices.Collect(l.All())[:max(0, min(n, 5))]
+		if got := slices.Collect(Take(l.All(), n)); !slices.Equal(got, want) {
+			t.Errorf("Take %d = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func Example() {
+	var prices Tree[string, float64]
+	prices.Set("pear", 1.20)
+	prices.Set("apple", 0.50)
+	prices.Set("fig", 2.10)
+	prices.Set("banana", 0.25)
+
+	for fruit, price := range prices.Range("b", "g") {
+		fmt.Printf("%s %.2f\n", fruit, price)
+	}
+
+	// Merge pulls from both lists in lockstep.
+	var morning, evening List[int]
+	for _, v := range []int{1, 4, 9} {
+		morning.PushBack(v)
+	}
+	for _, v := range []int{2, 3, 10} {
+		evening.PushBack(v)
+	}
+	fmt.Println(slices.Collect(Merge(morning.All(), evening.All())))
+	// Output:
+	// banana 0.25
+	// fig 2.10
+	// [1 2 3 4 9 10]
+}
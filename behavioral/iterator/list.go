@@ -0,0 +1,63 @@
+package iterator
+
+import "iter"
+
+// List is a doubly linked list.
+type List[T any] struct {
+	head, tail *node[T]
+	len        int
+}
+
+type node[T any] struct {
+	v          T
+	prev, next *node[T]
+}
+
+// PushBack appends v to l.
+func (l *List[T]) PushBack(v T) {
+	n := &node[T]{v: v, prev: l.tail}
+	if l.tail == nil {
+		l.head = n
+	} else {
+		l.tail.next = n
+	}
+	l.tail = n
+	l.len++
+}
+
+// PushFront prepends v to l.
+func (l *List[T]) PushFront(v T) {
+	n := &node[T]{v: v, next: l.head}
+	if l.head == nil {
+		l.tail = n
+	} else {
+		l.head.prev = n
+	}
+	l.head = n
+	l.len++
+}
+
+// Len returns the number of elements in l.
+func (l *List[T]) Len() int { return l.len }
+
+// All yields the elements of l from front to back.
+func (l *List[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := l.head; n != nil; n = n.next {
+			if !yield(n.v) {
+				return
+			}
+		}
+	}
+}
+
+// Backward yields the elements of l from back to front.
+func (l *List[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := l.tail; n != nil; n = n.prev {
+			if !yield(n.v) {
+				return
+			}
+		}
+	}
+}
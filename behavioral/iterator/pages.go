@@ -0,0 +1,43 @@
+package iterator
+
+import (
+	"context"
+	"iter"
+)
+
+// Page is one page of a paginated listing.
+type Page[T any] struct {
+	Items []T
+	// Next is the token of the next page; it is empty on the last page.
+	Next string
+}
+
+// Fetcher fetches the page with the given token. The first page has the
+// empty token.
+type Fetcher[T any] func(ctx context.Context, token string) (Page[T], error)
+
+// Pages yields the items of a paginated listing. It fetches a page only
+// when the loop reaches it, so breaking out early saves the requests for
+// the rest. A failed fetch is yielded as an error and ends the listing.
+func Pages[T any](ctx context.Context, fetch Fetcher[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		token := ""
+		for {
+			page, err := fetch(ctx, token)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, v := range page.Items {
+				if !yield(v, nil) {
+					return
+				}
+			}
+			if page.Next == "" {
+				return
+			}
+			token = page.Next
+		}
+	}
+}
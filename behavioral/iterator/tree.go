@@ -0,0 +1,104 @@
+package iterator
+
+import (
+	"cmp"
+	"iter"
+)
+
+// Tree is a binary search tree. It is not balanced.
+type Tree[K cmp.Ordered, V any] struct {
+	root *tnode[K, V]
+	len  int
+}
+
+type tnode[K cmp.Ordered, V any] struct {
+	k           K
+	v           V
+	left, right *tnode[K, V]
+}
+
+// Set stores v under k.
+func (t *Tree[K, V]) Set(k K, v V) {
+	p := &t.root
+	for *p != nil {
+		switch c := cmp.Compare(k, (*p).k); {
+		case c < 0:
+			p = &(*p).left
+		case c > 0:
+			p = &(*p).right
+		default:
+			(*p).v = v
+			return
+		}
+	}
+	*p = &tnode[K, V]{k: k, v: v}
+	t.len++
+}
+
+// Get returns the value stored under k.
+func (t *Tree[K, V]) Get(k K) (V, bool) {
+	for n := t.root; n != nil; {
+		switch c := cmp.Compare(k, n.k); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n.v, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Len returns the number of keys in t.
+func (t *Tree[K, V]) Len() int { return t.len }
+
+// All yields the keys and values of t in key order.
+func (t *Tree[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		t.root.walk(yield, nil, nil)
+	}
+}
+
+// Range yields the keys in [lo, hi) and their values in key order. It
+// skips the subtrees outside the range.
+func (t *Tree[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		t.root.walk(yield, &lo, &hi)
+	}
+}
+
+// Keys yields the keys of t in order.
+func (t *Tree[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range t.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// walk yields the nodes of the subtree at n in order, between lo
+// inclusive and hi exclusive when they are not nil. It reports whether to
+// go on.
+func (n *tnode[K, V]) walk(yield func(K, V) bool, lo, hi *K) bool {
+	if n == nil {
+		return true
+	}
+	if lo == nil || n.k > *lo {
+		if !n.left.walk(yield, lo, hi) {
+			return false
+		}
+	}
+	if (lo == nil || n.k >= *lo) && (hi == nil || n.k < *hi) {
+		if !yield(n.k, n.v) {
+			return false
+		}
+	}
+	if hi == nil || n.k < *hi {
+		return n.right.walk(yield, lo, hi)
+	}
+	return true
+}
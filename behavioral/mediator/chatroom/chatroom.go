@@ -0,0 +1,160 @@
+// Package chatroom implements the mediator pattern as a chat room.
+//
+// Members never hold references to each other. They only know the Room,
+// which routes every message: to one member by name, or broadcast to all
+// the others. Members can come and go without anyone else noticing beyond
+// the room's announcements, and rules such as who may talk to whom live
+// in one place.
+//
+// Routing is safe for concurrent use. Every member has a buffered inbox;
+// a member that does not keep up loses messages, counted by Dropped,
+// rather than stalling the room for everybody else.
+package chatroom
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrNameTaken is returned by Join for a name already in the room.
+	ErrNameTaken = errors.New("chatroom: name taken")
+	// ErrNoMember is returned for messages to a name not in the room.
+	ErrNoMember = errors.New("chatroom: no such member")
+	// ErrLeft is returned for messages from a member that left.
+	ErrLeft = errors.New("chatroom: member left")
+)
+
+// Message is a chat message. From is empty for announcements of the
+// room itself.
+type Message struct {
+	From, To string // To is empty for broadcasts
+	Text     string
+}
+
+// Room is the mediator.
+type Room struct {
+	buffer int
+
+	mu      sync.RWMutex
+	members map[string]*Member
+}
+
+// NewRoom returns an empty room whose members' inboxes hold buffer
+// messages.
+func NewRoom(buffer int) *Room {
+	return &Room{buffer: buffer, members: map[string]*Member{}}
+}
+
+// Member is a participant, a colleague in the pattern's terms.
+type Member struct {
+	name    string
+	room    *Room
+	inbox   chan Message
+	left    bool // guarded by room.mu
+	dropped atomic.Uint64
+}
+
+// Join adds a member called name and announces it to the others.
+func (r *Room) Join(name string) (*Member, error) {
+	r.mu.Lock()
+	if _, ok := r.members[name]; ok {
+		r.mu.Unlock()
+		return nil, ErrNameTaken
+	}
+	m := &Member{name: name, room: r, inbox: make(chan Message, r.buffer)}
+	r.members[name] = m
+	r.mu.Unlock()
+
+	r.broadcast(Message{Text: name + " joined"}, m)
+	return m, nil
+}
+
+// Members returns the number of members.
+func (r *Room) Members() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.members)
+}
+
+// broadcast delivers msg to every member other than except.
+func (r *Room) broadcast(msg Message, except *Member) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, m := range r.members {
+		if m != except {
+			m.deliver(msg)
+		}
+	}
+}
+
+// deliver queues msg without blocking. It is called with room.mu held,
+// which keeps Leave from closing the inbox meanwhile.
+func (m *Member) deliver(msg Message) {
+	select {
+	case m.inbox <- msg:
+	default:
+		m.dropped.Add(1)
+	}
+}
+
+// Name returns the member's name.
+func (m *Member) Name() string { return m.name }
+
+// Inbox returns the messages for the member. It is closed when the member
+// leaves.
+func (m *Member) Inbox() <-chan Message { return m.inbox }
+
+// Dropped returns the number of messages lost because the inbox was full.
+func (m *Member) Dropped() uint64 { return m.dropped.Load() }
+
+// Send sends text to the member called to.
+func (m *Member) Send(to, text string) error {
+	r := m.room
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if m.left {
+		return ErrLeft
+	}
+	dst, ok := r.members[to]
+	if !ok {
+		return ErrNoMember
+	}
+	dst.deliver(Message{From: m.name, To: to, Text: text})
+	return nil
+}
+
+// Broadcast sends text to every other member.
+func (m *Member) Broadcast(text string) error {
+	r := m.room
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if m.left {
+		return ErrLeft
+	}
+	msg := Message{From: m.name, Text: text}
+	for _, dst := range r.members {
+		if dst != m {
+			dst.deliver(msg)
+		}
+	}
+	return nil
+}
+
+// Leave removes the member, closes its inbox and announces it to the
+// others. Leaving twice is a no-op.
+func (m *Member) Leave() {
+	r := m.room
+	r.mu.Lock()
+	if m.left {
+		r.mu.Unlock()
+		return
+	}
+	m.left = true
+	delete(r.members, m.name)
+	close(m.inbox)
+	r.mu.Unlock()
+
+	r.broadcast(Message{Text: m.name + " left"}, nil)
+}
@@ -0,0 +1,194 @@
+package chatroom
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func join(t *testing.T, r *Room, names ...string) []*Member {
+	t.Helper()
+	var ms []*Member
+	for _, name := range names {
+		m, err := r.Join(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ms = append(ms, m)
+	}
+	// Drop the join announcements.
+	for _, m := range ms {
+		drain(m)
+	}
+	return ms
+}
+
+func drain(m *Member) []Message {
+	var msgs []Message
+	for {
+		select {
+		case msg, ok := <-m.Inbox():
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	r := NewRoom(8)
+	ms := join(t, r, "ann", "bob", "cy")
+	ms[0].Broadcast("hi all")
+	for _, m := range ms[1:] {
+		got := drain(m)
+		if len(got) != 1 || got[0] != (Message{From: "ann", Text: "hi all"}) {
+			t.Errorf("%s got %v", m.Name(), got)
+		}
+	}
+	if got := drain(ms[0]); len(got) != 0 {
+		t.Errorf("the sender got its own broadcast: %v", got)
+	}
+}
+
+func TestDirect(t *testing.T) {
+	r := NewRoom(8)
+	ms := join(t, r, "ann", "bob", "cy")
+	if err := ms[0].Send("bob", "psst"); err != nil {
+		t.Fatal(err)
+	}
+	if got := drain(ms[1]); len(got) != 1 || got[0] != (Message{From: "ann", To: "bob", Text: "psst"}) {
+		t.Errorf("bob got %v", got)
+	}
+	if got := drain(ms[2]); len(got) != 0 {
+		t.Errorf("cy overheard %v", got)
+	}
+	if err := ms[0].Send("dan", "hello?"); !errors.Is(err, ErrNoMember) {
+		t.Errorf("Send to a stranger = %v", err)
+	}
+}
+
+func TestJoinLeave(t *testing.T) {
+	r := NewRoom(8)
+	ms := join(t, r, "ann", "bob")
+	if _, err := r.Join("ann"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("second ann: %v", err)
+	}
+
+	ms[1].Leave()
+	ms[1].Leave()
+	if got := drain(ms[0]); len(got) != 1 || got[0].Text != "bob left" {
+		t.Errorf("ann got %v", got)
+	}
+	if _, ok := <-ms[1].Inbox(); ok {
+		t.Error("bob's inbox is still open")
+	}
+	if err := ms[0].Send("bob", "hi"); !errors.Is(err, ErrNoMember) {
+		t.Errorf("Send to bob = %v", err)
+	}
+	if err := ms[1].Broadcast("hi"); !errors.Is(err, ErrLeft) {
+		t.Errorf("Broadcast from bob = %v", err)
+	}
+	if r.Members() != 1 {
+		t.Errorf("%d members", r.Members())
+	}
+	// The name is free again.
+	if _, err := r.Join("bob"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlowMemberDoesNotBlock(t *testing.T) {
+	r := NewRoom(2)
+	ms := join(t, r, "ann", "bob")
+	for i := 0; i < 5; i++ {
+		ms[0].Broadcast(fmt.Sprint(i))
+	}
+	if got := drain(ms[1]); len(got) != 2 || ms[1].Dropped() != 3 {
+		t.Errorf("bob got %d, dropped %d", len(got), ms[1].Dropped())
+	}
+}
+
+// TestConcurrent has members talk, join and leave at once. Every member
+// still sees each other sender's messages in the order they were sent.
+func TestConcurrent(t *testing.T) {
+	const senders, msgs = 4, 200
+	r := NewRoom(senders * msgs * 2)
+	var ms []*Member
+	for i := 0; i < senders; i++ {
+		m, _ := r.Join(fmt.Sprint("m", i))
+		ms = append(ms, m)
+	}
+
+	var wg sync.WaitGroup
+	for i, m := range ms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < msgs; n++ {
+				if n%2 == 0 {
+					m.Broadcast(fmt.Sprint(n))
+				} else {
+					m.Send(ms[(i+1)%senders].Name(), fmt.Sprint(n))
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 50; n++ {
+			if v, err := r.Join("visitor"); err == nil {
+				v.Broadcast("hello")
+				v.Leave()
+			}
+		}
+	}()
+	wg.Wait()
+
+	for i, m := range ms {
+		last := map[string]int{}
+		for _, msg := range drain(m) {
+			if msg.From == "" || msg.From == "visitor" {
+				continue
+			}
+			var n int
+			fmt.Sscan(msg.Text, &n)
+			if prev, ok := last[msg.From]; ok && n <= prev {
+				t.Fatalf("%s got %d from %s after %d", m.Name(), n, msg.From, prev)
+			}
+			last[msg.From] = n
+		}
+		from := ms[(i+senders-1)%senders].Name()
+		if last[from] != msgs-1 {
+			t.Errorf("%s: last message from %s is %d", m.Name(), from, last[from])
+		}
+		if m.Dropped() != 0 {
+			t.Errorf("%s dropped %d", m.Name(), m.Dropped())
+		}
+	}
+}
+
+func Example() {
+	room := NewRoom(16)
+	ann, _ := room.Join("ann")
+	bob, _ := room.Join("bob")
+	ann.Broadcast("morning!")
+	bob.Send("ann", "hi ann")
+	bob.Leave()
+
+	for _, msg := range drain(ann) {
+		if msg.From == "" {
+			fmt.Println("*", msg.Text)
+		} else {
+			fmt.Printf("%s: %s\n", msg.From, msg.Text)
+		}
+	}
+	// Output:
+	// * bob joined
+	// bob: hi ann
+	// * bob left
+}
@@ -0,0 +1,155 @@
+// Package editor implements the memento pattern for a text editor.
+//
+// The Editor, the originator, hands out its state as a Memento: an opaque
+// value only the Editor can read back. The History, the caretaker, keeps
+// mementos and gives them back for restoring, without ever looking inside.
+// Mementos are deep copies, so neither later edits nor restoring one and
+// editing on change what it holds.
+//
+// Compared with undoing commands one by one, snapshots trade memory for
+// simplicity: restoring is a copy, whatever happened in between.
+package editor
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrPosition is returned for cursor positions outside the text.
+	ErrPosition = errors.New("editor: position out of range")
+	// ErrNoSnapshot is returned by Undo on an empty history.
+	ErrNoSnapshot = errors.New("editor: no snapshot")
+)
+
+// Pos is a cursor position, zero-based.
+type Pos struct{ Line, Col int }
+
+// Editor is a text buffer with a cursor and named bookmarks.
+type Editor struct {
+	lines [][]rune
+	cur   Pos
+	marks map[string]Pos
+}
+
+// New returns an editor holding text, with the cursor at the start.
+func New(text string) *Editor {
+	e := &Editor{marks: map[string]Pos{}}
+	for _, l := range strings.Split(text, "\n") {
+		e.lines = append(e.lines, []rune(l))
+	}
+	return e
+}
+
+// Text returns the text.
+func (e *Editor) Text() string {
+	s := make([]string, len(e.lines))
+	for i, l := range e.lines {
+		s[i] = string(l)
+	}
+	return strings.Join(s, "\n")
+}
+
+// Cursor returns the cursor position.
+func (e *Editor) Cursor() Pos { return e.cur }
+
+// MoveTo moves the cursor.
+func (e *Editor) MoveTo(p Pos) error {
+	if p.Line < 0 || p.Line >= len(e.lines) || p.Col < 0 || p.Col > len(e.lines[p.Line]) {
+		return ErrPosition
+	}
+	e.cur = p
+	return nil
+}
+
+// Insert inserts s at the cursor and moves the cursor past it.
+func (e *Editor) Insert(s string) {
+	parts := strings.Split(s, "\n")
+	line := e.lines[e.cur.Line]
+	head, tail := slices.Clone(line[:e.cur.Col]), slices.Clone(line[e.cur.Col:])
+
+	newLines := make([][]rune, len(parts))
+	for i, p := range parts {
+		newLines[i] = []rune(p)
+	}
+	newLines[0] = append(head, newLines[0]...)
+	last := len(newLines) - 1
+	col := len(newLines[last])
+	newLines[last] = append(newLines[last], tail...)
+
+	e.lines = slices.Replace(e.lines, e.cur.Line, e.cur.Line+1, newLines...)
+	e.cur = Pos{e.cur.Line + last, col}
+}
+
+// Mark sets the bookmark name to the cursor.
+func (e *Editor) Mark(name string) { e.marks[name] = e.cur }
+
+// Bookmark returns the position of a bookmark.
+func (e *Editor) Bookmark(name string) (Pos, bool) {
+	p, ok := e.marks[name]
+	return p, ok
+}
+
+// Memento is a snapshot of an Editor's state. Only the Editor can read
+// it.
+type Memento struct {
+	lines [][]rune
+	cur   Pos
+	marks map[string]Pos
+}
+
+// Save returns a snapshot of the editor's state.
+func (e *Editor) Save() *Memento {
+	return &Memento{lines: cloneLines(e.lines), cur: e.cur, marks: maps.Clone(e.marks)}
+}
+
+// Restore sets the editor's state to the snapshot m. m stays usable: it is
+// copied, not adopted.
+func (e *Editor) Restore(m *Memento) {
+	e.lines, e.cur, e.marks = cloneLines(m.lines), m.cur, maps.Clone(m.marks)
+}
+
+func cloneLines(lines [][]rune) [][]rune {
+	out := make([][]rune, len(lines))
+	for i, l := range lines {
+		out[i] = slices.Clone(l)
+	}
+	return out
+}
+
+// History is the caretaker: it keeps snapshots of an editor and restores
+// them newest first.
+type History struct {
+	editor *Editor
+	limit  int
+	snaps  []*Memento
+}
+
+// NewHistory returns a history for e keeping up to limit snapshots, or any
+// number if limit is 0.
+func NewHistory(e *Editor, limit int) *History {
+	return &History{editor: e, limit: limit}
+}
+
+// Checkpoint saves the editor's current state.
+func (h *History) Checkpoint() {
+	h.snaps = append(h.snaps, h.editor.Save())
+	if h.limit > 0 && len(h.snaps) > h.limit {
+		h.snaps = slices.Delete(h.snaps, 0, len(h.snaps)-h.limit)
+	}
+}
+
+// Undo restores the last checkpoint and forgets it.
+func (h *History) Undo() error {
+	if len(h.snaps) == 0 {
+		return ErrNoSnapshot
+	}
+	h.editor.Restore(h.snaps[len(h.snaps)-1])
+	h.snaps = h.snaps[:len(h.snaps)-1]
+	return nil
+}
+
+// Len returns the number of snapshots kept.
+func (h *History) Len() int { return len(h.snaps) }
@@ -0,0 +1,104 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestInsert(t *testing.T) {
+	e := New("hello world")
+	e.MoveTo(Pos{0, 5})
+	e.Insert(",\nbig")
+	if e.Text() != "hello,\nbig world" || e.Cursor() != (Pos{1, 3}) {
+		t.Errorf("text %q, cursor %v", e.Text(), e.Cursor())
+	}
+	if err := e.MoveTo(Pos{2, 0}); !errors.Is(err, ErrPosition) {
+		t.Errorf("MoveTo past the end = %v", err)
+	}
+}
+
+// TestSnapshotIsolated checks that edits after Save do not reach the
+// memento, through any of the editor's slices and maps.
+func TestSnapshotIsolated(t *testing.T) {
+	e := New("one\ntwo")
+	e.MoveTo(Pos{1, 3})
+	e.Mark("end")
+	m := e.Save()
+
+	e.MoveTo(Pos{0, 1})
+	e.Insert("X")
+	e.Insert("\nY")
+	e.Mark("end")
+	e.Mark("other")
+
+	e.Restore(m)
+	if e.Text() != "one\ntwo" || e.Cursor() != (Pos{1, 3}) {
+		t.Errorf("restored text %q, cursor %v", e.Text(), e.Cursor())
+	}
+	if p, _ := e.Bookmark("end"); p != (Pos{1, 3}) {
+		t.Errorf("bookmark end at %v", p)
+	}
+	if _, ok := e.Bookmark("other"); ok {
+		t.Error("bookmark other survived the restore")
+	}
+}
+
+// TestRestoreCopies checks that editing after a restore leaves the memento
+// intact for another restore.
+func TestRestoreCopies(t *testing.T) {
+	e := New("abc")
+	m := e.Save()
+	for i := 0; i < 2; i++ {
+		e.Restore(m)
+		e.MoveTo(Pos{0, 1})
+		e.Insert("-")
+		e.Mark("m")
+		if e.Text() != "a-bc" {
+			t.Fatalf("round %d: %q", i, e.Text())
+		}
+	}
+	e.Restore(m)
+	if _, ok := e.Bookmark("m"); ok || e.Text() != "abc" {
+		t.Errorf("memento changed: %q", e.Text())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	e := New("")
+	h := NewHistory(e, 2)
+	for _, s := range []string{"a", "b", "c"} {
+		h.Checkpoint()
+		e.Insert(s)
+	}
+	if h.Len() != 2 {
+		t.Fatalf("%d snapshots, want 2", h.Len())
+	}
+	h.Undo()
+	if e.Text() != "ab" {
+		t.Errorf("after one Undo: %q", e.Text())
+	}
+	h.Undo()
+	if e.Text() != "a" {
+		t.Errorf("after two: %q", e.Text())
+	}
+	if err := h.Undo(); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("third Undo = %v", err)
+	}
+}
+
+func Example() {
+	e := New("Dear Sir,")
+	h := NewHistory(e, 10)
+
+	h.Checkpoint()
+	e.MoveTo(Pos{0, 9})
+	e.Insert("\nI regret to inform you")
+	fmt.Printf("%q\n", e.Text())
+
+	h.Undo()
+	fmt.Printf("%q\n", e.Text())
+	// Output:
+	// "Dear Sir,\nI regret to inform you"
+	// "Dear Sir,"
+}
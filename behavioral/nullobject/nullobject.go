@@ -0,0 +1,59 @@
+// Package nullobject provides do-nothing implementations of the
+// collaborators most components take optionally: a logger and metrics.
+//
+// A component that accepts an optional collaborator has two choices. It
+// can keep a nil and check it before every call, and a forgotten check is
+// a panic waiting to happen. Or it can put a null object in its place when
+// none is given, once, in its constructor, and call it unconditionally
+// from then on. The null object does nothing, but it does so through the
+// same interface, so the code using it has no special case.
+//
+// The interfaces here are small enough that any package can declare its
+// own with the same methods, as patterns/workerpool does, and still use
+// these implementations as its defaults.
+//
+// A null object is not the same as a nil pointer inside an interface. A
+// (*MyLogger)(nil) stored in a Logger is not a nil Logger, and calling it
+// still panics unless its methods handle a nil receiver. Use NopLogger{},
+// not a typed nil.
+package nullobject
+
+// Logger is the logging a component does.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// Metrics is the measuring a component does.
+type Metrics interface {
+	// Add adds delta to the counter name.
+	Add(name string, delta float64)
+	// Observe records value in the distribution name.
+	Observe(name string, value float64)
+}
+
+// NopLogger is a Logger that discards everything.
+type NopLogger struct{}
+
+func (NopLogger) Printf(string, ...any) {}
+
+// NopMetrics is a Metrics that records nothing.
+type NopMetrics struct{}
+
+func (NopMetrics) Add(string, float64)     {}
+func (NopMetrics) Observe(string, float64) {}
+
+// LoggerOr returns l, or a NopLogger if l is nil.
+func LoggerOr(l Logger) Logger {
+	if l == nil {
+		return NopLogger{}
+	}
+	return l
+}
+
+// MetricsOr returns m, or a NopMetrics if m is nil.
+func MetricsOr(m Metrics) Metrics {
+	if m == nil {
+		return NopMetrics{}
+	}
+	return m
+}
@@ -0,0 +1,79 @@
+package nullobject
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// importer takes an optional logger and metrics.
+type importer struct {
+	log     Logger
+	metrics Metrics
+}
+
+func newImporter(log Logger, metrics Metrics) *importer {
+	return &importer{log: LoggerOr(log), metrics: MetricsOr(metrics)}
+}
+
+// run uses its collaborators without checking for nil.
+func (im *importer) run(rows []string) int {
+	n := 0
+	for _, r := range rows {
+		if r == "" {
+			im.log.Printf("skipping an empty row")
+			im.metrics.Add("rows_skipped", 1)
+			continue
+		}
+		n++
+		im.metrics.Observe("row_bytes", float64(len(r)))
+	}
+	im.metrics.Add("rows_imported", float64(n))
+	return n
+}
+
+func TestDefaults(t *testing.T) {
+	im := newImporter(nil, nil)
+	if n := im.run([]string{"a", "", "bc"}); n != 2 {
+		t.Errorf("imported %d rows", n)
+	}
+	if _, ok := im.log.(NopLogger); !ok {
+		t.Errorf("default logger is %T", im.log)
+	}
+	if _, ok := im.metrics.(NopMetrics); !ok {
+		t.Errorf("default metrics is %T", im.metrics)
+	}
+}
+
+type lineLogger struct{ b strings.Builder }
+
+func (l *lineLogger) Printf(format string, args ...any) {
+	fmt.Fprintf(&l.b, format+"\n", args...)
+}
+
+func TestGivenCollaboratorsAreKept(t *testing.T) {
+	l := &lineLogger{}
+	im := newImporter(l, nil)
+	im.run([]string{"", ""})
+	if got := l.b.String(); got != "skipping an empty row\nskipping an empty row\n" {
+		t.Errorf("logged %q", got)
+	}
+}
+
+func Example() {
+	// No logger given: the importer falls back to a NopLogger and logs
+	// nothing, without ever checking for nil.
+	quiet := newImporter(nil, nil)
+	fmt.Println(quiet.run([]string{"x", ""}))
+
+	loud := newImporter(printLogger{}, nil)
+	fmt.Println(loud.run([]string{"x", ""}))
+	// Output:
+	// 1
+	// log: skipping an empty row
+	// 1
+}
+
+type printLogger struct{}
+
+func (printLogger) Printf(format string, args ...any) { fmt.Printf("log: "+format+"\n", args...) }
@@ -0,0 +1,214 @@
+// Package eventbus is the observer pattern with typed events.
+//
+// An EventBus[T] carries events of one type T from publishers to every
+// subscribed handler, so handlers take a T instead of an interface{} they
+// have to assert. Delivery is synchronous, on the publisher's goroutine in
+// subscription order, or asynchronous, through a queue and a goroutine per
+// subscriber so that a slow handler only delays itself. When such a queue
+// is full, the Slow policy decides between waiting, dropping the event and
+// disconnecting the subscriber.
+//
+// Subscribing and unsubscribing are safe at any time, also from inside a
+// handler while a Publish is running. Once Unsubscribe has returned, events
+// published afterwards are not delivered to the handler.
+package eventbus
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Mode selects how events are delivered.
+type Mode int
+
+const (
+	// Sync calls the handlers from Publish, one after another.
+	Sync Mode = iota
+	// Async queues the event for each subscriber and returns.
+	Async
+)
+
+// Slow decides what an asynchronous Publish does when a subscriber's queue
+// is full.
+type Slow int
+
+const (
+	// Block waits for room, so a slow subscriber holds up the publisher.
+	Block Slow = iota
+	// Drop discards the event for that subscriber.
+	Drop
+	// Disconnect unsubscribes the subscriber.
+	Disconnect
+)
+
+// Options configures an EventBus.
+type Options struct {
+	Mode Mode
+	// Buffer is the queue length of each subscriber in Async mode. It
+	// defaults to 16.
+	Buffer int
+	// Slow applies to full queues in Async mode.
+	Slow Slow
+}
+
+// EventBus delivers events of type T to subscribers.
+type EventBus[T any] struct {
+	opts Options
+
+	mu     sync.RWMutex
+	subs   []*Subscription[T]
+	closed bool
+	// workers counts the goroutines of asynchronous subscribers.
+	workers sync.WaitGroup
+}
+
+// New returns an EventBus.
+func New[T any](opts Options) *EventBus[T] {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 16
+	}
+	return &EventBus[T]{opts: opts}
+}
+
+// Subscription is the registration of one handler.
+type Subscription[T any] struct {
+	bus     *EventBus[T]
+	handler func(T)
+	queue   chan T
+	quit    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// Subscribe registers handler for every event published from now on.
+// After Close it returns a subscription that is already done.
+func (b *EventBus[T]) Subscribe(handler func(T)) *Subscription[T] {
+	s := &Subscription[T]{bus: b, handler: handler, quit: make(chan struct{})}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.quit)
+		return s
+	}
+	if b.opts.Mode == Async {
+		s.queue = make(chan T, b.opts.Buffer)
+		b.workers.Add(1)
+		go s.work()
+	}
+	// Copy on write: Publish iterates over a snapshot without the lock.
+	b.subs = append(b.subs[:len(b.subs):len(b.subs)], s)
+	return s
+}
+
+func (s *Subscription[T]) work() {
+	defer s.bus.workers.Done()
+	for {
+		select {
+		case ev := <-s.queue:
+			// Unsubscribe may have raced with the receive.
+			if s.active() {
+				s.handler(ev)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *Subscription[T]) active() bool {
+	select {
+	case <-s.quit:
+		return false
+	default:
+		return true
+	}
+}
+
+// Unsubscribe removes the subscription. It may be called more than once,
+// and from any goroutine, including the handler's. A handler call already
+// running is not waited for; events still queued are discarded.
+func (s *Subscription[T]) Unsubscribe() {
+	s.once.Do(func() {
+		b := s.bus
+		b.mu.Lock()
+		for i, other := range b.subs {
+			if other == s {
+				subs := make([]*Subscription[T], 0, len(b.subs)-1)
+				b.subs = append(append(subs, b.subs[:i]...), b.subs[i+1:]...)
+				break
+			}
+		}
+		b.mu.Unlock()
+		// Publish calls that took their snapshot before the removal see
+		// this and skip the handler.
+		close(s.quit)
+	})
+}
+
+// Done is closed once the subscription has ended, by Unsubscribe, by
+// Close or by being disconnected as a slow subscriber.
+func (s *Subscription[T]) Done() <-chan struct{} { return s.quit }
+
+// Dropped returns the number of events the Drop policy discarded for this
+// subscriber.
+func (s *Subscription[T]) Dropped() uint64 { return s.dropped.Load() }
+
+// Publish delivers ev to every current subscriber.
+func (b *EventBus[T]) Publish(ev T) {
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		if !s.active() {
+			// Unsubscribed by an earlier handler of this Publish.
+			continue
+		}
+		if b.opts.Mode == Sync {
+			s.handler(ev)
+			continue
+		}
+		b.enqueue(s, ev)
+	}
+}
+
+func (b *EventBus[T]) enqueue(s *Subscription[T], ev T) {
+	select {
+	case s.queue <- ev:
+		return
+	case <-s.quit:
+		return
+	default:
+	}
+	switch b.opts.Slow {
+	case Drop:
+		s.dropped.Add(1)
+	case Disconnect:
+		s.Unsubscribe()
+	default:
+		select {
+		case s.queue <- ev:
+		case <-s.quit:
+		}
+	}
+}
+
+// Len returns the number of subscribers.
+func (b *EventBus[T]) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Close unsubscribes everybody and waits for asynchronous handlers that
+// are running to return. It must not be called from a handler.
+func (b *EventBus[T]) Close() {
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.mu.Unlock()
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+	b.workers.Wait()
+}
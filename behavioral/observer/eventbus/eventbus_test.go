@@ -0,0 +1,283 @@
+package eventbus
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// log records handler calls from any goroutine.
+type log struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *log) add(format string, args ...any) {
+	l.mu.Lock()
+	l.calls = append(l.calls, fmt.Sprintf(format, args...))
+	l.mu.Unlock()
+}
+
+func (l *log) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.calls)
+}
+
+var modes = map[string]Mode{"sync": Sync, "async": Async}
+
+func TestSyncOrder(t *testing.T) {
+	b := New[int](Options{})
+	var l log
+	for _, name := range []string{"a", "b", "c"} {
+		b.Subscribe(func(n int) { l.add("%s%d", name, n) })
+	}
+	b.Publish(1)
+	b.Publish(2)
+	want := []string{"a1", "b1", "c1", "a2", "b2", "c2"}
+	if got := l.get(); !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestAsyncOrderPerSubscriber(t *testing.T) {
+	b := New[int](Options{Mode: Async, Buffer: 4})
+	got := make([][]int, 3)
+	var mu sync.Mutex
+	for i := range got {
+		b.Subscribe(func(n int) {
+			mu.Lock()
+			got[i] = append(got[i], n)
+			mu.Unlock()
+		})
+	}
+	for n := 0; n < 100; n++ {
+		b.Publish(n)
+	}
+	// Block is the default policy, so nothing was dropped; wait for the
+	// queues to drain before closing.
+	for i := range got {
+		for {
+			mu.Lock()
+			n := len(got[i])
+			mu.Unlock()
+			if n == 100 {
+				break
+			}
+			runtime.Gosched()
+		}
+	}
+	b.Close()
+	for i, g := range got {
+		for n, v := range g {
+			if v != n {
+				t.Fatalf("subscriber %d got %d at %d", i, v, n)
+			}
+		}
+	}
+}
+
+// TestUnsubscribeDuringPublish removes handlers from inside handlers.
+func TestUnsubscribeDuringPublish(t *testing.T) {
+	for name, mode := range modes {
+		t.Run(name, func(t *testing.T) {
+			b := New[int](Options{Mode: mode})
+			defer b.Close()
+			var calls atomic.Int32
+			var self *Subscription[int]
+			self = b.Subscribe(func(int) {
+				calls.Add(1)
+				self.Unsubscribe()
+			})
+			for n := 1; n <= 3; n++ {
+				b.Publish(n)
+			}
+			<-self.Done()
+			if calls.Load() != 1 {
+				t.Errorf("self-removing handler called %d times", calls.Load())
+			}
+			if b.Len() != 0 {
+				t.Errorf("Len = %d, want 0", b.Len())
+			}
+		})
+	}
+
+	// In sync mode, a handler removed by an earlier one in the same Publish
+	// is skipped, and one added is not called for the current event.
+	b := New[string](Options{})
+	var l log
+	var second *Subscription[string]
+	b.Subscribe(func(ev string) {
+		l.add("first %s", ev)
+		if ev == "x" {
+			second.Unsubscribe()
+			b.Subscribe(func(ev string) { l.add("third %s", ev) })
+		}
+	})
+	second = b.Subscribe(func(ev string) { l.add("second %s", ev) })
+	b.Publish("x")
+	b.Publish("y")
+	want := []string{"first x", "first y", "third y"}
+	if got := l.get(); !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+// TestUnsubscribeRace churns subscriptions while events are published.
+// An event whose Publish began after Unsubscribe returned must never reach
+// the handler.
+func TestUnsubscribeRace(t *testing.T) {
+	for name, mode := range modes {
+		t.Run(name, func(t *testing.T) {
+			b := New[int64](Options{Mode: mode, Slow: Drop})
+			var seq atomic.Int64
+			stop := make(chan struct{})
+			var publishers, churn sync.WaitGroup
+			for p := 0; p < 2; p++ {
+				publishers.Add(1)
+				go func() {
+					defer publishers.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						b.Publish(seq.Add(1))
+						runtime.Gosched()
+					}
+				}()
+			}
+
+			var violations atomic.Int64
+			for c := 0; c < 4; c++ {
+				churn.Add(1)
+				go func() {
+					defer churn.Done()
+					for round := 0; round < 100; round++ {
+						var limit atomic.Int64
+						limit.Store(1 << 62)
+						s := b.Subscribe(func(n int64) {
+							if n > limit.Load() {
+								violations.Add(1)
+							}
+						})
+						runtime.Gosched()
+						s.Unsubscribe()
+						limit.Store(seq.Load())
+					}
+				}()
+			}
+			churn.Wait()
+			close(stop)
+			publishers.Wait()
+			b.Close()
+			if v := violations.Load(); v > 0 {
+				t.Errorf("%d events delivered after Unsubscribe", v)
+			}
+			if b.Len() != 0 {
+				t.Errorf("Len = %d after churn", b.Len())
+			}
+		})
+	}
+}
+
+// blocked subscribes a handler that blocks on gate, and returns once it is
+// blocked handling ev 0.
+func blocked(b *EventBus[int], gate chan struct{}, handled *atomic.Int32) *Subscription[int] {
+	entered := make(chan struct{}, 1)
+	s := b.Subscribe(func(n int) {
+		if n == 0 {
+			entered <- struct{}{}
+		}
+		<-gate
+		handled.Add(1)
+	})
+	b.Publish(0)
+	<-entered
+	return s
+}
+
+func TestSlowDrop(t *testing.T) {
+	b := New[int](Options{Mode: Async, Buffer: 2, Slow: Drop})
+	defer b.Close()
+	gate := make(chan struct{})
+	var handled atomic.Int32
+	s := blocked(b, gate, &handled)
+	for n := 1; n < 10; n++ {
+		b.Publish(n)
+	}
+	if s.Dropped() != 7 {
+		t.Errorf("dropped %d, want 7", s.Dropped())
+	}
+	close(gate)
+	for handled.Load() != 3 {
+		runtime.Gosched()
+	}
+}
+
+func TestSlowDisconnect(t *testing.T) {
+	b := New[int](Options{Mode: Async, Buffer: 2, Slow: Disconnect})
+	defer b.Close()
+	var fast atomic.Int32
+	b.Subscribe(func(int) { fast.Add(1) })
+	gate := make(chan struct{})
+	defer close(gate)
+	var handled atomic.Int32
+	slow := blocked(b, gate, &handled)
+	for n := 1; n < 4; n++ {
+		b.Publish(n)
+		// The fast subscriber keeps up.
+		for fast.Load() != int32(n+1) {
+			runtime.Gosched()
+		}
+	}
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("slow subscriber still connected")
+	}
+	if b.Len() != 1 {
+		t.Errorf("Len = %d, want the fast subscriber only", b.Len())
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := New[int](Options{Mode: Async})
+	s := b.Subscribe(func(int) {})
+	b.Close()
+	select {
+	case <-s.Done():
+	default:
+		t.Error("subscription still open after Close")
+	}
+	late := b.Subscribe(func(int) { t.Error("called after Close") })
+	b.Publish(1)
+	select {
+	case <-late.Done():
+	default:
+		t.Error("Subscribe after Close should return a done subscription")
+	}
+}
+
+type OrderPlaced struct {
+	ID    string
+	Total int
+}
+
+func Example() {
+	orders := New[OrderPlaced](Options{})
+	orders.Subscribe(func(o OrderPlaced) { fmt.Println("email receipt for", o.ID) })
+	audit := orders.Subscribe(func(o OrderPlaced) { fmt.Println("audit", o.ID, o.Total) })
+
+	orders.Publish(OrderPlaced{ID: "A1", Total: 30})
+	audit.Unsubscribe()
+	orders.Publish(OrderPlaced{ID: "A2", Total: 12})
+	// Output:
+	// email receipt for A1
+	// audit A1 30
+	// email receipt for A2
+}
@@ -95,5 +95,5 @@ func (reg *registry) RegisterName(data interface{}, identifier string) error {
 
 // New returns a new Registry interface
 func New() Registry {
-	return &registry{}
+	return &registry{registry: map[string]interface{}{}}
 }
@@ -0,0 +1,40 @@
+package specification
+
+import "fmt"
+
+// Order is an order in a shop.
+type Order struct {
+	ID       string
+	Customer string
+	Total    int64 // in cents
+	Country  string
+	Status   string
+	Express  bool
+}
+
+// MinTotal is satisfied by orders of at least cents.
+func MinTotal(cents int64) Spec[Order] {
+	return New(fmt.Sprintf("total >= %d", cents), func(o Order) bool { return o.Total >= cents })
+}
+
+// HasStatus is satisfied by orders in status.
+func HasStatus(status string) Spec[Order] {
+	return New("status "+status, func(o Order) bool { return o.Status == status })
+}
+
+// ShipsTo is satisfied by orders going to country.
+func ShipsTo(country string) Spec[Order] {
+	return New("ships to "+country, func(o Order) bool { return o.Country == country })
+}
+
+// Express is satisfied by orders with express shipping.
+var Express = New("express", func(o Order) bool { return o.Express })
+
+// NeedsReview is the rule for orders a person checks before they ship:
+// large orders abroad, and any express order that is not yet paid.
+func NeedsReview(home string) Spec[Order] {
+	return Or(
+		And(MinTotal(50_000), Not(ShipsTo(home))),
+		And(Express, Not(HasStatus("paid"))),
+	)
+}
@@ -0,0 +1,95 @@
+// Package specification implements the specification pattern: business
+// rules as values that can be named, combined and reused.
+//
+// A Spec answers whether a candidate satisfies a rule. Instead of writing
+// the condition out inline wherever it applies, the rule is made once,
+// such as "order is large" or "ships abroad", and composed with And, Or
+// and Not into the rule a particular screen or job needs. And and Or stop
+// at the first spec that decides the outcome, so an expensive check put
+// last only runs when it matters. Every Spec also describes itself, so a
+// composed rule can be logged or shown to whoever asks why an order was
+// picked.
+package specification
+
+import "strings"
+
+// Spec is a rule candidates of type T satisfy or not.
+type Spec[T any] interface {
+	IsSatisfiedBy(candidate T) bool
+	// String describes the rule.
+	String() string
+}
+
+type predicate[T any] struct {
+	name string
+	pred func(T) bool
+}
+
+func (p predicate[T]) IsSatisfiedBy(c T) bool { return p.pred(c) }
+func (p predicate[T]) String() string         { return p.name }
+
+// New returns a Spec named name that pred decides.
+func New[T any](name string, pred func(T) bool) Spec[T] {
+	return predicate[T]{name, pred}
+}
+
+type and[T any] []Spec[T]
+
+func (a and[T]) IsSatisfiedBy(c T) bool {
+	for _, s := range a {
+		if !s.IsSatisfiedBy(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a and[T]) String() string { return join(a, " and ") }
+
+type or[T any] []Spec[T]
+
+func (o or[T]) IsSatisfiedBy(c T) bool {
+	for _, s := range o {
+		if s.IsSatisfiedBy(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o or[T]) String() string { return join(o, " or ") }
+
+type not[T any] struct{ s Spec[T] }
+
+func (n not[T]) IsSatisfiedBy(c T) bool { return !n.s.IsSatisfiedBy(c) }
+func (n not[T]) String() string         { return "not " + n.s.String() }
+
+// And is satisfied when all of specs are, checking them in order and
+// stopping at the first that is not. And() is always satisfied.
+func And[T any](specs ...Spec[T]) Spec[T] { return and[T](specs) }
+
+// Or is satisfied when one of specs is, checking them in order and
+// stopping at the first that is. Or() is never satisfied.
+func Or[T any](specs ...Spec[T]) Spec[T] { return or[T](specs) }
+
+// Not is satisfied when s is not.
+func Not[T any](s Spec[T]) Spec[T] { return not[T]{s} }
+
+func join[T any](specs []Spec[T], sep string) string {
+	parts := make([]string, len(specs))
+	for i, s := range specs {
+		parts[i] = s.String()
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// Filter returns the candidates that satisfy s, in order.
+func Filter[T any](candidates []T, s Spec[T]) []T {
+	var out []T
+	for _, c := range candidates {
+		if s.IsSatisfiedBy(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
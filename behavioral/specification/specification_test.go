@@ -0,0 +1,96 @@
+package specification
+
+import (
+	"fmt"
+	"testing"
+)
+
+var orders = []Order{
+	{ID: "o1", Total: 80_000, Country: "FR", Status: "paid"},
+	{ID: "o2", Total: 80_000, Country: "DE", Status: "paid"},
+	{ID: "o3", Total: 2_000, Country: "DE", Status: "new", Express: true},
+	{ID: "o4", Total: 2_000, Country: "DE", Status: "paid", Express: true},
+	{ID: "o5", Total: 60_000, Country: "US", Status: "cancelled"},
+}
+
+func ids(os []Order) string {
+	var s []string
+	for _, o := range os {
+		s = append(s, o.ID)
+	}
+	return fmt.Sprint(s)
+}
+
+func TestCompose(t *testing.T) {
+	for _, tc := range []struct {
+		spec Spec[Order]
+		want string
+	}{
+		{MinTotal(50_000), "[o1 o2 o5]"},
+		{Not(MinTotal(50_000)), "[o3 o4]"},
+		{And(MinTotal(50_000), Not(HasStatus("cancelled"))), "[o1 o2]"},
+		{Or(ShipsTo("FR"), ShipsTo("US")), "[o1 o5]"},
+		{Not(Not(Express)), "[o3 o4]"},
+		{And[Order](), "[o1 o2 o3 o4 o5]"},
+		{Or[Order](), "[]"},
+		{NeedsReview("DE"), "[o1 o3 o5]"},
+	} {
+		if got := ids(Filter(orders, tc.spec)); got != tc.want {
+			t.Errorf("%s: %s, want %s", tc.spec, got, tc.want)
+		}
+	}
+}
+
+// counting returns a spec with a fixed answer that counts its checks.
+func counting(answer bool, calls *int) Spec[Order] {
+	return New(fmt.Sprint(answer), func(Order) bool {
+		*calls++
+		return answer
+	})
+}
+
+func TestShortCircuit(t *testing.T) {
+	var first, second int
+	if And(counting(false, &first), counting(true, &second)).IsSatisfiedBy(Order{}) {
+		t.Error("false and true is satisfied")
+	}
+	if first != 1 || second != 0 {
+		t.Errorf("And checked %d and %d times, want 1 and 0", first, second)
+	}
+
+	first, second = 0, 0
+	if !Or(counting(true, &first), counting(false, &second)).IsSatisfiedBy(Order{}) {
+		t.Error("true or false is not satisfied")
+	}
+	if first != 1 || second != 0 {
+		t.Errorf("Or checked %d and %d times, want 1 and 0", first, second)
+	}
+
+	first, second = 0, 0
+	And(counting(true, &first), counting(false, &second)).IsSatisfiedBy(Order{})
+	if first != 1 || second != 1 {
+		t.Errorf("And checked %d and %d times, want 1 and 1", first, second)
+	}
+}
+
+func TestString(t *testing.T) {
+	want := "((total >= 50000 and not ships to DE) or (express and not status paid))"
+	if got := NeedsReview("DE").String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func Example() {
+	large := MinTotal(50_000)
+	open := Not(HasStatus("cancelled"))
+	spec := And(large, open)
+
+	fmt.Println(spec)
+	for _, o := range Filter(orders, spec) {
+		fmt.Println(o.ID, o.Total)
+	}
+	// Output:
+	// (total >= 50000 and not status cancelled)
+	// o1 80000
+	// o2 80000
+}
@@ -0,0 +1,204 @@
+// Package cache is a size-bounded byte cache whose compression and
+// eviction are strategies picked at runtime.
+//
+// The Cache only knows the Compressor and Evictor interfaces. Which
+// implementation sits behind them is decided by a Config, typically read
+// from a file or flags, so changing policy needs no change to the code
+// that uses the cache. The strategies are looked up by name in a registry
+// that other packages can extend with Register functions.
+//
+// The indirection pays off because no strategy wins everywhere: LFU keeps
+// the hot keys of a skewed workload that LRU and FIFO keep losing, and
+// compression trades CPU for room. The benchmarks in this package run one
+// workload against every combination to make that visible.
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrUnknownStrategy is returned by New for a name nobody registered.
+	ErrUnknownStrategy = errors.New("cache: unknown strategy")
+	// ErrTooLarge is returned by Set for a value larger than the cache.
+	ErrTooLarge = errors.New("cache: value larger than capacity")
+)
+
+var (
+	mu          sync.RWMutex
+	compressors = map[string]func() Compressor{
+		"none":  func() Compressor { return None{} },
+		"flate": func() Compressor { return Flate{} },
+		"gzip":  func() Compressor { return Gzip{} },
+		"zlib":  func() Compressor { return Zlib{} },
+	}
+	evictors = map[string]func() Evictor{
+		"fifo": func() Evictor { return NewFIFO() },
+		"lru":  func() Evictor { return NewLRU() },
+		"lfu":  func() Evictor { return NewLFU() },
+	}
+)
+
+// RegisterCompressor makes a Compressor available to Config by name.
+func RegisterCompressor(name string, f func() Compressor) {
+	mu.Lock()
+	defer mu.Unlock()
+	compressors[name] = f
+}
+
+// RegisterEvictor makes an Evictor available to Config by name. f must
+// return a new Evictor on every call.
+func RegisterEvictor(name string, f func() Evictor) {
+	mu.Lock()
+	defer mu.Unlock()
+	evictors[name] = f
+}
+
+// Compressors returns the registered compressor names, sorted.
+func Compressors() []string { return names(compressors) }
+
+// Evictors returns the registered evictor names, sorted.
+func Evictors() []string { return names(evictors) }
+
+func names[F any](m map[string]F) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	s := make([]string, 0, len(m))
+	for name := range m {
+		s = append(s, name)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Config selects the strategies by name.
+type Config struct {
+	// Compression defaults to "none", Eviction to "lru".
+	Compression string `json:"compression"`
+	Eviction    string `json:"eviction"`
+	// Capacity is the number of stored bytes, after compression.
+	Capacity int `json:"capacity"`
+}
+
+// Stats counts what the cache did.
+type Stats struct {
+	Hits, Misses, Evictions int
+	// Bytes is the stored size of all entries.
+	Bytes int
+}
+
+// Cache maps keys to byte values. It is safe for concurrent use.
+type Cache struct {
+	capacity int
+	comp     Compressor
+
+	mu      sync.Mutex
+	evict   Evictor
+	entries map[string][]byte
+	stats   Stats
+}
+
+// New returns a cache with the strategies named in cfg.
+func New(cfg Config) (*Cache, error) {
+	if cfg.Compression == "" {
+		cfg.Compression = "none"
+	}
+	if cfg.Eviction == "" {
+		cfg.Eviction = "lru"
+	}
+	mu.RLock()
+	newComp, okc := compressors[cfg.Compression]
+	newEvict, oke := evictors[cfg.Eviction]
+	mu.RUnlock()
+	if !okc {
+		return nil, fmt.Errorf("%w: compression %q", ErrUnknownStrategy, cfg.Compression)
+	}
+	if !oke {
+		return nil, fmt.Errorf("%w: eviction %q", ErrUnknownStrategy, cfg.Eviction)
+	}
+	return NewWith(cfg.Capacity, newComp(), newEvict()), nil
+}
+
+// NewWith returns a cache using the given strategies directly.
+func NewWith(capacity int, c Compressor, e Evictor) *Cache {
+	return &Cache{capacity: capacity, comp: c, evict: e, entries: map[string][]byte{}}
+}
+
+// Get returns the value stored under key.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	stored, ok := c.entries[key]
+	if ok {
+		c.stats.Hits++
+		c.evict.Touch(key)
+	} else {
+		c.stats.Misses++
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+	v, err := c.comp.Decompress(stored)
+	return v, err == nil, err
+}
+
+// Set stores value under key, evicting entries until it fits.
+func (c *Cache) Set(key string, value []byte) error {
+	stored, err := c.comp.Compress(value)
+	if err != nil {
+		return err
+	}
+	if len(stored) > c.capacity {
+		return ErrTooLarge
+	}
+	// The stored slice may alias value when nothing is compressed.
+	stored = append([]byte(nil), stored...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(key)
+	for c.stats.Bytes+len(stored) > c.capacity {
+		victim, ok := c.evict.Victim()
+		if !ok {
+			break
+		}
+		c.remove(victim)
+		c.stats.Evictions++
+	}
+	c.entries[key] = stored
+	c.stats.Bytes += len(stored)
+	c.evict.Add(key)
+	return nil
+}
+
+// Delete removes key.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(key)
+}
+
+func (c *Cache) remove(key string) {
+	if old, ok := c.entries[key]; ok {
+		c.stats.Bytes -= len(old)
+		delete(c.entries, key)
+		c.evict.Remove(key)
+	}
+}
+
+// Len returns the number of entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Stats returns the counters so far.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
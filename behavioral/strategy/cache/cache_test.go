@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	value := []byte(strings.Repeat("the quick brown fox ", 50))
+	for _, comp := range Compressors() {
+		for _, ev := range Evictors() {
+			c, err := New(Config{Compression: comp, Eviction: ev, Capacity: 1 << 16})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Set("k", value); err != nil {
+				t.Fatalf("%s/%s: %v", comp, ev, err)
+			}
+			got, ok, err := c.Get("k")
+			if !ok || err != nil || string(got) != string(value) {
+				t.Errorf("%s/%s: Get = %d bytes, %v, %v", comp, ev, len(got), ok, err)
+			}
+		}
+	}
+}
+
+func TestUnknownStrategy(t *testing.T) {
+	for _, cfg := range []Config{{Compression: "brotli"}, {Eviction: "random"}} {
+		if _, err := New(cfg); !errors.Is(err, ErrUnknownStrategy) {
+			t.Errorf("New(%+v) = %v", cfg, err)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	RegisterEvictor("newest", func() Evictor { return &newest{} })
+	c, err := New(Config{Eviction: "newest", Capacity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("a", []byte("1"))
+	c.Set("b", []byte("2"))
+	c.Set("c", []byte("3"))
+	if _, ok, _ := c.Get("a"); !ok {
+		t.Error(`"a" was evicted, want "b"`)
+	}
+}
+
+// newest evicts the last key added.
+type newest struct{ keys []string }
+
+func (n *newest) Add(key string) { n.keys = append(n.keys, key) }
+func (n *newest) Touch(string)   {}
+func (n *newest) Remove(key string) {
+	for i, k := range n.keys {
+		if k == key {
+			n.keys = append(n.keys[:i], n.keys[i+1:]...)
+			return
+		}
+	}
+}
+func (n *newest) Victim() (string, bool) {
+	if len(n.keys) == 0 {
+		return "", false
+	}
+	return n.keys[len(n.keys)-1], true
+}
+
+func TestEvictionOrder(t *testing.T) {
+	// Capacity for three one-byte values. "a" is read twice, "b" once;
+	// adding "d" makes room for one.
+	for ev, want := range map[string]string{"fifo": "a", "lru": "c", "lfu": "c"} {
+		c, _ := New(Config{Eviction: ev, Capacity: 3})
+		for _, k := range []string{"a", "b", "c"} {
+			c.Set(k, []byte(k))
+		}
+		c.Get("a")
+		c.Get("b")
+		c.Get("a")
+		c.Set("d", []byte("d"))
+		if _, ok, _ := c.Get(want); ok || c.Len() != 3 {
+			t.Errorf("%s: %q should be evicted, %d entries", ev, want, c.Len())
+		}
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	c, _ := New(Config{Capacity: 4})
+	if err := c.Set("k", []byte("too large")); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Set = %v", err)
+	}
+}
+
+// workload replays a skewed stream of reads: a few keys are hot, most are
+// rarely used, and every miss loads the value into the cache.
+type workload struct {
+	keys, ops, size int
+}
+
+func (w workload) run(c *Cache) error {
+	rng := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rng, 1.1, 1, uint64(w.keys-1))
+	for i := 0; i < w.ops; i++ {
+		key := fmt.Sprint("key", zipf.Uint64())
+		_, ok, err := c.Get(key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err := c.Set(key, w.value(key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// value is a JSON-like document, compressible as such documents are.
+func (w workload) value(key string) []byte {
+	var b strings.Builder
+	for i := 0; b.Len() < w.size; i++ {
+		fmt.Fprintf(&b, `{"id":%q,"field":%d,"status":"active"},`, key, i)
+	}
+	return []byte(b.String())
+}
+
+var load = workload{keys: 2000, ops: 20000, size: 1024}
+
+func hitRatio(s Stats) float64 { return float64(s.Hits) / float64(s.Hits+s.Misses) }
+
+func TestStrategiesDiffer(t *testing.T) {
+	stats := map[string]Stats{}
+	for _, cfg := range []Config{
+		{Eviction: "fifo"}, {Eviction: "lru"}, {Eviction: "lfu"},
+		{Eviction: "lru", Compression: "flate"},
+	} {
+		cfg.Capacity = 50 << 10
+		c, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := (workload{keys: 1000, ops: 5000, size: 1024}).run(c); err != nil {
+			t.Fatal(err)
+		}
+		name := cfg.Eviction + "/" + cfg.Compression
+		stats[name] = c.Stats()
+		t.Logf("%-10s hit ratio %.2f, %d entries", name, hitRatio(c.Stats()), c.Len())
+	}
+	if hitRatio(stats["lfu/"]) <= hitRatio(stats["fifo/"]) {
+		t.Error("LFU should beat FIFO on a skewed workload")
+	}
+	// Compressed entries are smaller, so more of them fit.
+	if hitRatio(stats["lru/flate"]) <= hitRatio(stats["lru/"]) {
+		t.Error("compression should raise the hit ratio of a full cache")
+	}
+}
+
+// BenchmarkStrategies runs the same workload against every combination of
+// strategies and reports the hit ratio next to the time it took.
+func BenchmarkStrategies(b *testing.B) {
+	for _, comp := range Compressors() {
+		for _, ev := range Evictors() {
+			b.Run(comp+"/"+ev, func(b *testing.B) {
+				var s Stats
+				for i := 0; i < b.N; i++ {
+					c, _ := New(Config{Compression: comp, Eviction: ev, Capacity: 100 << 10})
+					if err := load.run(c); err != nil {
+						b.Fatal(err)
+					}
+					s = c.Stats()
+				}
+				b.ReportMetric(hitRatio(s), "hits/op")
+				b.ReportMetric(float64(s.Evictions), "evictions")
+			})
+		}
+	}
+}
+
+func Example() {
+	// The configuration would come from a file or flags.
+	cfg := Config{Compression: "gzip", Eviction: "lfu", Capacity: 1 << 20}
+	c, err := New(cfg)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	c.Set("greeting", []byte("hello"))
+	v, ok, _ := c.Get("greeting")
+	fmt.Println(string(v), ok)
+
+	_, err = New(Config{Eviction: "random"})
+	fmt.Println(err)
+	// Output:
+	// hello true
+	// cache: unknown strategy: eviction "random"
+}
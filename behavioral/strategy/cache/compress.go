@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+// Compressor is the strategy for storing values.
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// None stores values as they are.
+type None struct{}
+
+func (None) Compress(data []byte) ([]byte, error)   { return data, nil }
+func (None) Decompress(data []byte) ([]byte, error) { return data, nil }
+
+// Flate compresses with DEFLATE at Level; the zero value uses the default
+// level.
+type Flate struct{ Level int }
+
+func (f Flate) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, f.level())
+	if err != nil {
+		return nil, err
+	}
+	return finish(&buf, w, data)
+}
+
+func (Flate) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (f Flate) level() int {
+	if f.Level == 0 {
+		return flate.DefaultCompression
+	}
+	return f.Level
+}
+
+// Gzip compresses with gzip, which adds a header and a checksum to
+// DEFLATE.
+type Gzip struct{ Level int }
+
+func (g Gzip) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, Flate(g).level())
+	if err != nil {
+		return nil, err
+	}
+	return finish(&buf, w, data)
+}
+
+func (Gzip) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Zlib compresses with zlib, a smaller wrapper around DEFLATE.
+type Zlib struct{ Level int }
+
+func (z Zlib) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := zlib.NewWriterLevel(&buf, Flate(z).level())
+	if err != nil {
+		return nil, err
+	}
+	return finish(&buf, w, data)
+}
+
+func (Zlib) Decompress(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func finish(buf *bytes.Buffer, w io.WriteCloser, data []byte) ([]byte, error) {
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// Evictor is the strategy for choosing which entry to drop when the cache
+// is full. The cache tells it about every key added, read and removed.
+type Evictor interface {
+	Add(key string)
+	Touch(key string)
+	Remove(key string)
+	// Victim returns the key to evict next.
+	Victim() (string, bool)
+}
+
+// FIFO evicts the oldest entry, however often it is read.
+type FIFO struct {
+	order *list.List
+	elems map[string]*list.Element
+}
+
+func NewFIFO() *FIFO {
+	return &FIFO{order: list.New(), elems: map[string]*list.Element{}}
+}
+
+func (f *FIFO) Add(key string) { f.elems[key] = f.order.PushBack(key) }
+func (f *FIFO) Touch(string)   {}
+
+func (f *FIFO) Remove(key string) {
+	if e, ok := f.elems[key]; ok {
+		f.order.Remove(e)
+		delete(f.elems, key)
+	}
+}
+
+func (f *FIFO) Victim() (string, bool) {
+	if e := f.order.Front(); e != nil {
+		return e.Value.(string), true
+	}
+	return "", false
+}
+
+// LRU evicts the entry read least recently.
+type LRU struct{ FIFO }
+
+func NewLRU() *LRU { return &LRU{*NewFIFO()} }
+
+func (l *LRU) Touch(key string) {
+	if e, ok := l.elems[key]; ok {
+		l.order.MoveToBack(e)
+	}
+}
+
+// LFU evicts the entry read least often, the oldest among equals.
+type LFU struct {
+	h     lfuHeap
+	items map[string]*lfuItem
+	seq   uint64
+}
+
+func NewLFU() *LFU { return &LFU{items: map[string]*lfuItem{}} }
+
+func (l *LFU) Add(key string) {
+	l.seq++
+	it := &lfuItem{key: key, seq: l.seq}
+	l.items[key] = it
+	heap.Push(&l.h, it)
+}
+
+func (l *LFU) Touch(key string) {
+	if it, ok := l.items[key]; ok {
+		it.count++
+		heap.Fix(&l.h, it.index)
+	}
+}
+
+func (l *LFU) Remove(key string) {
+	if it, ok := l.items[key]; ok {
+		heap.Remove(&l.h, it.index)
+		delete(l.items, key)
+	}
+}
+
+func (l *LFU) Victim() (string, bool) {
+	if len(l.h) == 0 {
+		return "", false
+	}
+	return l.h[0].key, true
+}
+
+type lfuItem struct {
+	key        string
+	count, seq uint64
+	index      int
+}
+
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].seq < h[j].seq
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *lfuHeap) Push(x any) {
+	it := x.(*lfuItem)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+func (h *lfuHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
@@ -0,0 +1,131 @@
+// Package templatemethod shows the template method pattern the way Go
+// does it.
+//
+// The classic version puts the algorithm in a base class and lets
+// subclasses override its steps. Go has no inheritance, so the algorithm
+// becomes a plain function, Export, and the steps an interface it calls.
+// Defaults is a struct with the usual implementation of the optional
+// steps; an exporter embeds it and defines only the steps it changes,
+// which reads much like overriding.
+//
+// The workflow is a data export: fetch the records, transform each, write
+// them out. Export owns the order, the bookkeeping and the error
+// handling; the steps own nothing but their own work.
+package templatemethod
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// ErrSkip is returned by Transform to leave a record out of the export.
+var ErrSkip = errors.New("templatemethod: skip record")
+
+// Record is one row of exported data.
+type Record map[string]string
+
+// Steps are the hooks Export calls.
+type Steps interface {
+	// Fetch returns the records to export.
+	Fetch(ctx context.Context) ([]Record, error)
+	// Transform converts one record, or returns ErrSkip to drop it.
+	Transform(r Record) (Record, error)
+	// Write writes the records under the given columns.
+	Write(w io.Writer, columns []string, records []Record) error
+}
+
+// PassThrough is a Transform step that keeps records as they are.
+type PassThrough struct{}
+
+func (PassThrough) Transform(r Record) (Record, error) { return r, nil }
+
+// CSV is a Write step that writes CSV with a header line.
+type CSV struct{}
+
+func (CSV) Write(w io.Writer, columns []string, records []Record) error {
+	cw := csv.NewWriter(w)
+	cw.Write(columns)
+	row := make([]string, len(columns))
+	for _, r := range records {
+		for i, c := range columns {
+			row[i] = r[c]
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// JSONLines is a Write step that writes one JSON object per record.
+type JSONLines struct{}
+
+func (JSONLines) Write(w io.Writer, _ []string, records []Record) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Defaults holds the default optional steps: PassThrough and CSV. Embed
+// it and define Fetch. A step defined on the exporter, or on another
+// embedded struct such as JSONLines, is shallower and wins.
+type Defaults struct {
+	PassThrough
+	CSV
+}
+
+// Stats tells what an export did.
+type Stats struct {
+	Fetched, Skipped, Written int
+}
+
+// Export runs the steps: fetch, transform every record, write the ones
+// left. The columns are the union of the transformed records' keys,
+// sorted. Nothing is written if a step before Write fails.
+func Export(ctx context.Context, s Steps, w io.Writer) (Stats, error) {
+	var st Stats
+	records, err := s.Fetch(ctx)
+	if err != nil {
+		return st, fmt.Errorf("fetch: %w", err)
+	}
+	st.Fetched = len(records)
+
+	out := make([]Record, 0, len(records))
+	seen := map[string]bool{}
+	var columns []string
+	for i, r := range records {
+		if err := ctx.Err(); err != nil {
+			return st, err
+		}
+		r, err := s.Transform(r)
+		if errors.Is(err, ErrSkip) {
+			st.Skipped++
+			continue
+		}
+		if err != nil {
+			return st, fmt.Errorf("transform record %d: %w", i, err)
+		}
+		for k := range r {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+		out = append(out, r)
+	}
+	slices.Sort(columns)
+
+	if err := s.Write(w, columns, out); err != nil {
+		return st, fmt.Errorf("write: %w", err)
+	}
+	st.Written = len(out)
+	return st, nil
+}
@@ -0,0 +1,145 @@
+package templatemethod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+type User struct {
+	Name, Email string
+	Active      bool
+}
+
+// Users exports users with the default steps.
+type Users struct {
+	Defaults
+	List []User
+	Err  error
+}
+
+func (u Users) Fetch(context.Context) ([]Record, error) {
+	if u.Err != nil {
+		return nil, u.Err
+	}
+	var rs []Record
+	for _, user := range u.List {
+		rs = append(rs, Record{"name": user.Name, "email": user.Email, "active": fmt.Sprint(user.Active)})
+	}
+	return rs, nil
+}
+
+// ActiveMasked overrides Transform: only active users, emails masked.
+type ActiveMasked struct{ Users }
+
+func (ActiveMasked) Transform(r Record) (Record, error) {
+	if r["active"] != "true" {
+		return nil, ErrSkip
+	}
+	user, domain, _ := strings.Cut(r["email"], "@")
+	return Record{"name": r["name"], "email": user[:1] + "***@" + domain}, nil
+}
+
+// ActiveJSON overrides Transform through ActiveMasked and Write through
+// the embedded JSONLines.
+type ActiveJSON struct {
+	ActiveMasked
+	JSONLines
+}
+
+var users = []User{
+	{"Ann", "ann@example.com", true},
+	{"Bob", "bob@example.com", false},
+	{"Cy", "cy@example.org", true},
+}
+
+func TestDefaults(t *testing.T) {
+	var b strings.Builder
+	st, err := Export(context.Background(), Users{List: users}, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "active,email,name\ntrue,ann@example.com,Ann\nfalse,bob@example.com,Bob\ntrue,cy@example.org,Cy\n"
+	if b.String() != want || st != (Stats{Fetched: 3, Written: 3}) {
+		t.Errorf("got %q, %+v", b.String(), st)
+	}
+}
+
+func TestOverrideTransform(t *testing.T) {
+	var b strings.Builder
+	st, err := Export(context.Background(), ActiveMasked{Users{List: users}}, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "email,name\na***@example.com,Ann\nc***@example.org,Cy\n"
+	if b.String() != want || st != (Stats{Fetched: 3, Skipped: 1, Written: 2}) {
+		t.Errorf("got %q, %+v", b.String(), st)
+	}
+}
+
+func TestOverrideWrite(t *testing.T) {
+	var b strings.Builder
+	if _, err := Export(context.Background(), ActiveJSON{ActiveMasked: ActiveMasked{Users{List: users}}}, &b); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"email":"a***@example.com","name":"Ann"}` + "\n" + `{"email":"c***@example.org","name":"Cy"}` + "\n"
+	if b.String() != want {
+		t.Errorf("got %q", b.String())
+	}
+}
+
+// failingTransform fails on the second record.
+type failingTransform struct {
+	Users
+	calls int
+}
+
+func (f *failingTransform) Transform(r Record) (Record, error) {
+	f.calls++
+	if f.calls == 2 {
+		return nil, errors.New("bad record")
+	}
+	return r, nil
+}
+
+// countingWrite records whether Write ran.
+type countingWrite struct {
+	Steps
+	writes int
+}
+
+func (c *countingWrite) Write(w io.Writer, cols []string, rs []Record) error {
+	c.writes++
+	return c.Steps.Write(w, cols, rs)
+}
+
+func TestErrorsStopBeforeWrite(t *testing.T) {
+	boom := errors.New("db down")
+	for name, s := range map[string]Steps{
+		"fetch":     Users{Err: boom},
+		"transform": &failingTransform{Users: Users{List: users}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cw := &countingWrite{Steps: s}
+			_, err := Export(context.Background(), cw, io.Discard)
+			if err == nil || !strings.HasPrefix(err.Error(), name) {
+				t.Errorf("Export = %v", err)
+			}
+			if cw.writes != 0 {
+				t.Error("Write ran after a failed step")
+			}
+		})
+	}
+}
+
+func Example() {
+	Export(context.Background(), ActiveMasked{Users{List: users}}, os.Stdout)
+	// Output:
+	// email,name
+	// a***@example.com,Ann
+	// c***@example.org,Cy
+}
@@ -0,0 +1,68 @@
+// Package expr demonstrates the visitor pattern on a small expression AST.
+//
+// The node types are fixed: numbers, variables and binary operations.
+// Operations on them are not: evaluation, printing and constant folding
+// are each a Visitor, and adding another one touches no node type.
+//
+// Go has no method overloading, so dispatch on both the node and the
+// operation takes two calls: node.Accept(v) picks the node type and calls
+// the matching VisitXxx method of v, which picks the operation. A visitor
+// keeps its result in its own fields, since Accept has to return the same
+// thing for every visitor.
+package expr
+
+// Node is a node of the AST.
+type Node interface {
+	// Accept calls the method of v for the node's type.
+	Accept(v Visitor)
+}
+
+// Visitor is an operation on the AST, with a method per node type.
+type Visitor interface {
+	VisitNum(n *Num)
+	VisitVar(n *Var)
+	VisitBinary(n *Binary)
+}
+
+// Num is a number literal.
+type Num struct{ Value float64 }
+
+// Var is a variable, looked up at evaluation.
+type Var struct{ Name string }
+
+// Op is a binary operator.
+type Op byte
+
+const (
+	Add Op = '+'
+	Sub Op = '-'
+	Mul Op = '*'
+	Div Op = '/'
+)
+
+// precedence returns the binding strength of op.
+func (op Op) precedence() int {
+	if op == Mul || op == Div {
+		return 2
+	}
+	return 1
+}
+
+// Binary is an operation on two operands.
+type Binary struct {
+	Op          Op
+	Left, Right Node
+}
+
+func (n *Num) Accept(v Visitor)    { v.VisitNum(n) }
+func (n *Var) Accept(v Visitor)    { v.VisitVar(n) }
+func (n *Binary) Accept(v Visitor) { v.VisitBinary(n) }
+
+// N returns a number node.
+func N(v float64) *Num { return &Num{v} }
+
+// V returns a variable node.
+func V(name string) *Var { return &Var{name} }
+
+// B returns a binary operation node.
+func B(op Op, left, right Node) *Binary { return &Binary{op, left, right} }
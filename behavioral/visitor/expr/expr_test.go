@@ -0,0 +1,123 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// (x + 2) * (3 - 1) / y
+var sample = B(Div, B(Mul, B(Add, V("x"), N(2)), B(Sub, N(3), N(1))), V("y"))
+
+func TestEval(t *testing.T) {
+	got, err := Eval(sample, map[string]float64{"x": 4, "y": 3})
+	if err != nil || got != 4 {
+		t.Errorf("Eval = %v, %v", got, err)
+	}
+	if _, err := Eval(sample, map[string]float64{"x": 4}); !errors.Is(err, ErrUnbound) {
+		t.Errorf("without y: %v", err)
+	}
+	if _, err := Eval(sample, map[string]float64{"x": 4, "y": 0}); !errors.Is(err, ErrDivByZero) {
+		t.Errorf("y = 0: %v", err)
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, tc := range []struct {
+		n    Node
+		want string
+	}{
+		{sample, "(x + 2) * (3 - 1) / y"},
+		{B(Sub, V("a"), B(Sub, V("b"), V("c"))), "a - (b - c)"},
+		{B(Sub, B(Sub, V("a"), V("b")), V("c")), "a - b - c"},
+		{B(Add, V("a"), B(Mul, V("b"), N(0.5))), "a + b * 0.5"},
+		{B(Div, V("a"), B(Mul, V("b"), V("c"))), "a / (b * c)"},
+	} {
+		if got := String(tc.n); got != tc.want {
+			t.Errorf("String = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	for _, tc := range []struct {
+		n    Node
+		want string
+	}{
+		{sample, "(x + 2) * 2 / y"},
+		{B(Mul, B(Add, N(1), N(2)), N(4)), "12"},
+		{B(Add, B(Mul, V("x"), B(Sub, N(3), N(2))), N(0)), "x"},
+		{B(Div, V("x"), B(Sub, N(1), N(1))), "x / 0"},
+	} {
+		if got := String(Fold(tc.n)); got != tc.want {
+			t.Errorf("Fold(%s) = %q, want %q", String(tc.n), got, tc.want)
+		}
+	}
+	// The original tree is unchanged.
+	if String(sample) != "(x + 2) * (3 - 1) / y" {
+		t.Error("Fold modified its input")
+	}
+}
+
+func randomNode(rng *rand.Rand, depth int) Node {
+	if depth == 0 || rng.Intn(3) == 0 {
+		if rng.Intn(2) == 0 {
+			return V([]string{"x", "y"}[rng.Intn(2)])
+		}
+		return N(float64(rng.Intn(4)))
+	}
+	ops := []Op{Add, Sub, Mul, Div}
+	return B(ops[rng.Intn(4)], randomNode(rng, depth-1), randomNode(rng, depth-1))
+}
+
+// TestFoldPreservesValue checks on random trees that folding does not
+// change what an expression evaluates to.
+func TestFoldPreservesValue(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vars := map[string]float64{"x": 1.5, "y": -2}
+	for i := 0; i < 2000; i++ {
+		n := randomNode(rng, 4)
+		want, werr := Eval(n, vars)
+		got, gerr := Eval(Fold(n), vars)
+		if werr != nil {
+			// Folding may drop a division by zero with x * 0, but never
+			// adds one.
+			continue
+		}
+		if gerr != nil || got != want {
+			t.Fatalf("%s = %v; folded %s = %v, %v", String(n), want, String(Fold(n)), got, gerr)
+		}
+	}
+}
+
+// varCounter is a visitor defined outside the package's own set: new
+// operations need no change to the node types.
+type varCounter map[string]int
+
+func (c varCounter) VisitNum(*Num)   {}
+func (c varCounter) VisitVar(n *Var) { c[n.Name]++ }
+func (c varCounter) VisitBinary(n *Binary) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+}
+
+func TestCustomVisitor(t *testing.T) {
+	c := varCounter{}
+	B(Add, sample, V("x")).Accept(c)
+	if c["x"] != 2 || c["y"] != 1 {
+		t.Errorf("counted %v", c)
+	}
+}
+
+func Example() {
+	e := B(Mul, B(Add, V("price"), N(5)), B(Add, N(1), N(0.25)))
+	fmt.Println(String(e))
+	fmt.Println(String(Fold(e)))
+	v, _ := Eval(e, map[string]float64{"price": 15})
+	fmt.Println(v)
+	// Output:
+	// (price + 5) * (1 + 0.25)
+	// (price + 5) * 1.25
+	// 25
+}
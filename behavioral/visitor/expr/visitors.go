@@ -0,0 +1,154 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnbound is returned by Eval for variables without a value.
+	ErrUnbound = errors.New("expr: unbound variable")
+	// ErrDivByZero is returned by Eval for a division by zero.
+	ErrDivByZero = errors.New("expr: division by zero")
+)
+
+// Evaluator computes the value of an expression.
+type Evaluator struct {
+	Vars map[string]float64
+
+	Result float64
+	Err    error
+}
+
+// Eval returns the value of n with the variables in vars.
+func Eval(n Node, vars map[string]float64) (float64, error) {
+	e := &Evaluator{Vars: vars}
+	n.Accept(e)
+	return e.Result, e.Err
+}
+
+func (e *Evaluator) VisitNum(n *Num) { e.Result = n.Value }
+
+func (e *Evaluator) VisitVar(n *Var) {
+	v, ok := e.Vars[n.Name]
+	if !ok {
+		e.Err = fmt.Errorf("%w: %s", ErrUnbound, n.Name)
+	}
+	e.Result = v
+}
+
+func (e *Evaluator) VisitBinary(n *Binary) {
+	n.Left.Accept(e)
+	l := e.Result
+	if e.Err != nil {
+		return
+	}
+	n.Right.Accept(e)
+	r := e.Result
+	if e.Err != nil {
+		return
+	}
+	e.Result, e.Err = compute(n.Op, l, r)
+}
+
+func compute(op Op, l, r float64) (float64, error) {
+	switch op {
+	case Add:
+		return l + r, nil
+	case Sub:
+		return l - r, nil
+	case Mul:
+		return l * r, nil
+	case Div:
+		if r == 0 {
+			return 0, ErrDivByZero
+		}
+		return l / r, nil
+	}
+	return 0, fmt.Errorf("expr: unknown operator %q", op)
+}
+
+// Printer writes an expression in infix notation with as few parentheses
+// as the precedence rules allow.
+type Printer struct {
+	strings.Builder
+}
+
+// String returns n in infix notation.
+func String(n Node) string {
+	p := &Printer{}
+	n.Accept(p)
+	return p.String()
+}
+
+func (p *Printer) VisitNum(n *Num) {
+	p.WriteString(strconv.FormatFloat(n.Value, 'g', -1, 64))
+}
+
+func (p *Printer) VisitVar(n *Var) { p.WriteString(n.Name) }
+
+func (p *Printer) VisitBinary(n *Binary) {
+	p.operand(n.Left, n.Op, false)
+	fmt.Fprintf(p, " %c ", n.Op)
+	p.operand(n.Right, n.Op, true)
+}
+
+// operand prints an operand of op, in parentheses when it binds weaker,
+// or as strongly on the right: a - (b - c) is not a - b - c.
+func (p *Printer) operand(n Node, op Op, right bool) {
+	b, ok := n.(*Binary)
+	paren := ok && (b.Op.precedence() < op.precedence() ||
+		right && b.Op.precedence() == op.precedence() && (op == Sub || op == Div))
+	if paren {
+		p.WriteByte('(')
+	}
+	n.Accept(p)
+	if paren {
+		p.WriteByte(')')
+	}
+}
+
+// Folder computes the constant parts of an expression ahead of time. It
+// builds a new tree and leaves the original alone.
+type Folder struct {
+	Result Node
+}
+
+// Fold returns n with constant subexpressions replaced by their value and
+// additions of 0 and multiplications by 1 removed. Divisions by zero are
+// left for Eval to report.
+func Fold(n Node) Node {
+	f := &Folder{}
+	n.Accept(f)
+	return f.Result
+}
+
+func (f *Folder) VisitNum(n *Num) { f.Result = n }
+func (f *Folder) VisitVar(n *Var) { f.Result = n }
+
+func (f *Folder) VisitBinary(n *Binary) {
+	n.Left.Accept(f)
+	l := f.Result
+	n.Right.Accept(f)
+	r := f.Result
+
+	ln, lok := l.(*Num)
+	rn, rok := r.(*Num)
+	if lok && rok {
+		if v, err := compute(n.Op, ln.Value, rn.Value); err == nil {
+			f.Result = N(v)
+			return
+		}
+	}
+	switch {
+	case n.Op == Add && lok && ln.Value == 0, n.Op == Mul && lok && ln.Value == 1:
+		f.Result = r
+	case (n.Op == Add || n.Op == Sub) && rok && rn.Value == 0,
+		(n.Op == Mul || n.Op == Div) && rok && rn.Value == 1:
+		f.Result = l
+	default:
+		f.Result = B(n.Op, l, r)
+	}
+}
@@ -0,0 +1,126 @@
+package backpressure
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var csvOut = flag.String("csv", "", "write the samples of a run with the default Config to this file")
+
+// overload offers twice what the workers can do, for long enough to fill
+// the queue.
+var overload = Config{Rate: 800, Duration: 300 * time.Millisecond, Workers: 2, Task: 5 * time.Millisecond, Queue: 20, Interval: 25 * time.Millisecond}
+
+func peak(samples []Sample, f func(Sample) int) int {
+	m := 0
+	for _, s := range samples {
+		m = max(m, f(s))
+	}
+	return m
+}
+
+func blocked(samples []Sample) time.Duration {
+	var d time.Duration
+	for _, s := range samples {
+		d += s.Blocked
+	}
+	return d
+}
+
+// Overloaded, the pool never holds a task it has not started and pushes
+// back on the producer at once; the queue fills first.
+func TestOverload(t *testing.T) {
+	total := int(overload.Rate * overload.Duration.Seconds())
+	for _, d := range Designs {
+		samples, err := Simulate(context.Background(), d, overload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last := samples[len(samples)-1]
+		if last.Done != total || last.Backlog != 0 || last.Depth != 0 {
+			t.Errorf("%s ended with %+v, want %d done", d.Name, last, total)
+		}
+		depth := peak(samples, func(s Sample) int { return s.Depth })
+		backlog := peak(samples, func(s Sample) int { return s.Backlog })
+		switch d.Name {
+		case "pool":
+			if depth != 0 {
+				t.Errorf("pool held %d tasks", depth)
+			}
+		case "queue":
+			if depth < overload.Queue-overload.Workers {
+				t.Errorf("queue depth peaked at %d of %d", depth, overload.Queue)
+			}
+		}
+		if backlog == 0 || blocked(samples) < overload.Duration/4 {
+			t.Errorf("%s did not push back: backlog %d, blocked %v", d.Name, backlog, blocked(samples))
+		}
+	}
+}
+
+// Under its capacity, neither design makes the producer wait long or
+// lets work pile up.
+func TestUnderload(t *testing.T) {
+	c := overload
+	c.Rate = 100
+	for _, d := range Designs {
+		samples, err := Simulate(context.Background(), d, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if depth := peak(samples, func(s Sample) int { return s.Depth }); depth > 2 {
+			t.Errorf("%s depth peaked at %d", d.Name, depth)
+		}
+		if b := blocked(samples); b > c.Duration/4 {
+			t.Errorf("%s blocked the producer for %v", d.Name, b)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := overload
+	c.Duration = time.Minute
+	start := time.Now()
+	if _, err := Compare(ctx, c); err != context.DeadlineExceeded {
+		t.Errorf("Compare = %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("took %v", time.Since(start))
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b strings.Builder
+	WriteCSV(&b, []Sample{
+		{Design: "pool", At: 50 * time.Millisecond, Backlog: 10, Blocked: 17500 * time.Microsecond, Delay: 2 * time.Millisecond, Done: 18},
+		{Design: "queue", At: 100 * time.Millisecond, Depth: 40, Done: 36},
+	})
+	want := `design,t_ms,depth,backlog,blocked_ms,delay_ms,done
+pool,50,0,10,17.5,2.0,18
+queue,100,40,0,0.0,0.0,36
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+	if *csvOut == "" {
+		return
+	}
+	samples, err := Compare(context.Background(), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(*csvOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := WriteCSV(f, samples); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,101 @@
+// Package pooling compares three ways to get a scratch buffer per request:
+// allocate a new one, take one from a channel based free list, or take one
+// from a sync.Pool.
+//
+// The benchmarks in this package report allocations and garbage
+// collections per operation for several buffer sizes, serially and in
+// parallel:
+//
+//	go test -run NONE -bench . -benchmem ./benchmarks/pooling
+//
+// For small buffers all three are within a few nanoseconds of each other.
+// For larger buffers every allocation has to be zeroed and later collected,
+// and reuse pays off. The free list is bounded and keeps its buffers
+// forever, but every Get and Put is a channel operation that contends
+// under parallel load. sync.Pool scales with per-P caches and lets the
+// collector shrink it, at the cost of losing buffers across collections.
+package pooling
+
+import "sync"
+
+// Buffers hands out byte buffers of one size. Buffers travel as *[]byte so
+// that putting one back does not allocate a slice header on the heap.
+type Buffers interface {
+	Get() *[]byte
+	Put(*[]byte)
+}
+
+// Alloc allocates a new buffer for every Get and drops it on Put.
+type Alloc struct {
+	Size int
+}
+
+func (a Alloc) Get() *[]byte {
+	b := make([]byte, a.Size)
+	return &b
+}
+
+func (Alloc) Put(*[]byte) {}
+
+// FreeList keeps up to a fixed number of buffers in a channel. Get
+// allocates when the list is empty and Put drops buffers when it is full.
+type FreeList struct {
+	size int
+	free chan *[]byte
+}
+
+// NewFreeList returns a FreeList of buffers of size bytes holding at most
+// capacity idle buffers.
+func NewFreeList(size, capacity int) *FreeList {
+	return &FreeList{size: size, free: make(chan *[]byte, capacity)}
+}
+
+func (l *FreeList) Get() *[]byte {
+	select {
+	case b := <-l.free:
+		return b
+	default:
+		b := make([]byte, l.size)
+		return &b
+	}
+}
+
+func (l *FreeList) Put(b *[]byte) {
+	select {
+	case l.free <- b:
+	default:
+	}
+}
+
+// SyncPool is a sync.Pool of buffers.
+type SyncPool struct {
+	pool sync.Pool
+}
+
+// NewSyncPool returns a SyncPool of buffers of size bytes.
+func NewSyncPool(size int) *SyncPool {
+	return &SyncPool{pool: sync.Pool{New: func() any {
+		b := make([]byte, size)
+		return &b
+	}}}
+}
+
+func (p *SyncPool) Get() *[]byte  { return p.pool.Get().(*[]byte) }
+func (p *SyncPool) Put(b *[]byte) { p.pool.Put(b) }
+
+// Handle is the request the benchmarks run: it fills a buffer from bufs
+// with a payload derived from seed and returns a checksum, so the work
+// cannot be optimised away.
+func Handle(bufs Buffers, seed byte) byte {
+	b := bufs.Get()
+	buf := *b
+	for i := range buf {
+		buf[i] = seed + byte(i)
+	}
+	var sum byte
+	for i := 0; i < len(buf); i += 64 {
+		sum ^= buf[i]
+	}
+	bufs.Put(b)
+	return sum
+}
@@ -0,0 +1,99 @@
+package pooling
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+var sizes = []int{64, 4 << 10, 64 << 10, 1 << 20}
+
+type strategy struct {
+	name string
+	new  func(size int) Buffers
+}
+
+var strategies = []strategy{
+	{"alloc", func(size int) Buffers { return Alloc{Size: size} }},
+	{"freelist", func(size int) Buffers { return NewFreeList(size, 64) }},
+	{"syncpool", func(size int) Buffers { return NewSyncPool(size) }},
+}
+
+func TestStrategiesAgree(t *testing.T) {
+	for _, size := range sizes {
+		want := Handle(Alloc{Size: size}, 7)
+		for _, s := range strategies {
+			bufs := s.new(size)
+			for i := 0; i < 3; i++ {
+				if got := Handle(bufs, 7); got != want {
+					t.Errorf("%s/%d: checksum %d, want %d", s.name, size, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestFreeListReuses(t *testing.T) {
+	l := NewFreeList(16, 1)
+	a := l.Get()
+	l.Put(a)
+	if b := l.Get(); b != a {
+		t.Errorf("free list did not hand back the idle buffer")
+	}
+	l.Put(a)
+	l.Put(&[]byte{}) // full, dropped
+	if len(l.free) != 1 {
+		t.Errorf("free list holds %d buffers, want at most 1", len(l.free))
+	}
+}
+
+// reportGC adds the number of collections per operation since before.
+func reportGC(b *testing.B, before *runtime.MemStats) {
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gcs/op")
+}
+
+var sink byte
+
+func BenchmarkSerial(b *testing.B) {
+	for _, size := range sizes {
+		for _, s := range strategies {
+			b.Run(fmt.Sprintf("%s/%d", s.name, size), func(b *testing.B) {
+				bufs := s.new(size)
+				var ms runtime.MemStats
+				runtime.ReadMemStats(&ms)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					sink ^= Handle(bufs, byte(i))
+				}
+				b.StopTimer()
+				reportGC(b, &ms)
+			})
+		}
+	}
+}
+
+func BenchmarkParallel(b *testing.B) {
+	for _, size := range sizes {
+		for _, s := range strategies {
+			b.Run(fmt.Sprintf("%s/%d", s.name, size), func(b *testing.B) {
+				bufs := s.new(size)
+				var ms runtime.MemStats
+				runtime.ReadMemStats(&ms)
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					var sum byte
+					for i := 0; pb.Next(); i++ {
+						sum ^= Handle(bufs, byte(i))
+					}
+					_ = sum
+				})
+				b.StopTimer()
+				reportGC(b, &ms)
+			})
+		}
+	}
+}
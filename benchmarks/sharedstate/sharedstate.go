@@ -0,0 +1,207 @@
+// Package sharedstate compares three ways for goroutines to share a
+// counter: guard it with a sync.Mutex, confine it to an owner goroutine
+// that receives increments over a channel, or update it with sync/atomic.
+//
+// The benchmarks split b.N increments across 1 to 64 goroutines:
+//
+//	go test -run NONE -bench . ./benchmarks/sharedstate
+//
+// Compare runs the same measurements from ordinary code and WriteTable
+// prints them side by side:
+//
+//	sharedstate.WriteTable(os.Stdout, sharedstate.Compare([]int{1, 4, 16, 64}))
+//
+// An atomic add is a single instruction and wins at every count. A mutex
+// costs a little more while uncontended, and falls further behind when
+// goroutines on several cores queue on it. The channel pays for a send, a
+// receive and a goroutine handoff on every increment; it is the slowest
+// way to count, and earns its place only when the owner does more than
+// count, such as keeping several fields consistent without any lock.
+package sharedstate
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"text/tabwriter"
+)
+
+// Counter is a counter safe for concurrent use.
+type Counter interface {
+	Inc()
+	Load() int64
+}
+
+// Mutex is a Counter guarded by a sync.Mutex.
+type Mutex struct {
+	mu sync.Mutex
+	n  int64
+}
+
+func (c *Mutex) Inc() {
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+}
+
+func (c *Mutex) Load() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+// Atomic is a Counter updated with sync/atomic.
+type Atomic struct {
+	n atomic.Int64
+}
+
+func (c *Atomic) Inc()        { c.n.Add(1) }
+func (c *Atomic) Load() int64 { return c.n.Load() }
+
+// Channel is a Counter owned by a goroutine of its own, the only one to
+// touch the count. Close stops the goroutine.
+type Channel struct {
+	incs  chan struct{}
+	loads chan chan int64
+	done  chan struct{}
+}
+
+// NewChannel returns a Channel with its owner goroutine running.
+func NewChannel() *Channel {
+	c := &Channel{incs: make(chan struct{}), loads: make(chan chan int64), done: make(chan struct{})}
+	go c.own()
+	return c
+}
+
+func (c *Channel) own() {
+	var n int64
+	for {
+		select {
+		case <-c.incs:
+			n++
+		case reply := <-c.loads:
+			reply <- n
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Channel) Inc() { c.incs <- struct{}{} }
+
+func (c *Channel) Load() int64 {
+	reply := make(chan int64)
+	c.loads <- reply
+	return <-reply
+}
+
+// Close stops the owner goroutine. The counter must not be used after.
+func (c *Channel) Close() { close(c.done) }
+
+// Impl is a Counter implementation under comparison.
+type Impl struct {
+	Name string
+	// New returns a counter and a function releasing it.
+	New func() (Counter, func())
+}
+
+// Impls are the implementations compared, in table order.
+var Impls = []Impl{
+	{"mutex", func() (Counter, func()) { return new(Mutex), func() {} }},
+	{"channel", func() (Counter, func()) { c := NewChannel(); return c, c.Close }},
+	{"atomic", func() (Counter, func()) { return new(Atomic), func() {} }},
+}
+
+// Hammer increments c n times in total from the given number of
+// goroutines and waits for them.
+func Hammer(c Counter, goroutines, n int) {
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		// Spread the remainder over the first goroutines.
+		share := n / goroutines
+		if g < n%goroutines {
+			share++
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range share {
+				c.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Result is the cost of one increment with a number of goroutines.
+type Result struct {
+	Impl       string
+	Goroutines int
+	NsPerOp    float64
+}
+
+// Compare benchmarks every implementation with each goroutine count.
+func Compare(goroutines []int) []Result {
+	var results []Result
+	for _, g := range goroutines {
+		for _, impl := range Impls {
+			r := testing.Benchmark(func(b *testing.B) {
+				c, release := impl.New()
+				defer release()
+				b.ResetTimer()
+				Hammer(c, g, b.N)
+			})
+			results = append(results, Result{impl.Name, g, float64(r.T.Nanoseconds()) / float64(max(r.N, 1))})
+		}
+	}
+	return results
+}
+
+// WriteTable prints results with a row per goroutine count and a column
+// per implementation, marking the fastest of each row with a star.
+func WriteTable(w io.Writer, results []Result) error {
+	var impls []string
+	var counts []int
+	ns := map[string]map[int]float64{}
+	for _, r := range results {
+		if ns[r.Impl] == nil {
+			impls = append(impls, r.Impl)
+			ns[r.Impl] = map[int]float64{}
+		}
+		if !slices.Contains(counts, r.Goroutines) {
+			counts = append(counts, r.Goroutines)
+		}
+		ns[r.Impl][r.Goroutines] = r.NsPerOp
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "goroutines\t")
+	for _, impl := range impls {
+		fmt.Fprintf(tw, "%s\t", impl)
+	}
+	fmt.Fprintln(tw)
+	for _, g := range counts {
+		best := ""
+		for _, impl := range impls {
+			if v, ok := ns[impl][g]; ok && (best == "" || v < ns[best][g]) {
+				best = impl
+			}
+		}
+		fmt.Fprintf(tw, "%d\t", g)
+		for _, impl := range impls {
+			v, ok := ns[impl][g]
+			switch {
+			case !ok:
+				fmt.Fprint(tw, "-\t")
+			case impl == best:
+				fmt.Fprintf(tw, "*%.1f ns\t", v)
+			default:
+				fmt.Fprintf(tw, "%.1f ns\t", v)
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
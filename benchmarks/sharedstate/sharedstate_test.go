@@ -0,0 +1,75 @@
+package sharedstate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+var goroutines = []int{1, 4, 16, 64}
+
+func TestCountersAgree(t *testing.T) {
+	for _, impl := range Impls {
+		for _, g := range goroutines {
+			c, release := impl.New()
+			Hammer(c, g, 1001)
+			if n := c.Load(); n != 1001 {
+				t.Errorf("%s with %d goroutines counted %d, want 1001", impl.Name, g, n)
+			}
+			release()
+		}
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var b strings.Builder
+	WriteTable(&b, []Result{
+		{"mutex", 1, 12.5}, {"atomic", 1, 5},
+		{"mutex", 8, 80}, {"atomic", 8, 20},
+	})
+	want := `  goroutines    mutex    atomic
+           1  12.5 ns   *5.0 ns
+           8  80.0 ns  *20.0 ns
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a benchmark per implementation")
+	}
+	results := Compare([]int{2})
+	if len(results) != len(Impls) {
+		t.Fatalf("%d results", len(results))
+	}
+	for _, r := range results {
+		if r.Goroutines != 2 || r.NsPerOp <= 0 {
+			t.Errorf("result %+v", r)
+		}
+	}
+}
+
+func BenchmarkCounter(b *testing.B) {
+	for _, impl := range Impls {
+		for _, g := range goroutines {
+			b.Run(fmt.Sprintf("%s/%d", impl.Name, g), func(b *testing.B) {
+				c, release := impl.New()
+				defer release()
+				b.ResetTimer()
+				Hammer(c, g, b.N)
+			})
+		}
+	}
+}
+
+func ExampleWriteTable() {
+	WriteTable(os.Stdout, []Result{
+		{"mutex", 4, 30}, {"channel", 4, 250}, {"atomic", 4, 9},
+	})
+	// Output:
+	//   goroutines    mutex   channel   atomic
+	//            4  30.0 ns  250.0 ns  *9.0 ns
+}
@@ -0,0 +1,124 @@
+package workerpool
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Every design runs every task once, and the bounded ones never more than
+// their bound at a time.
+func TestDesignsRunEveryTask(t *testing.T) {
+	const workers, tasks = 3, 200
+	for _, d := range Designs {
+		e := d.New(workers)
+		var (
+			ran           [tasks]atomic.Int32
+			running, most atomic.Int32
+			mu            sync.Mutex
+		)
+		r := Run(e, Profile{"test", workers, func(i int) {
+			n := running.Add(1)
+			mu.Lock()
+			most.Store(max(most.Load(), n))
+			mu.Unlock()
+			time.Sleep(50 * time.Microsecond)
+			running.Add(-1)
+			ran[i].Add(1)
+		}}, tasks)
+		e.Close()
+		for i := range ran {
+			if n := ran[i].Load(); n != 1 {
+				t.Errorf("%s ran task %d %d times", d.Name, i, n)
+			}
+		}
+		if bounded := d.Name != "unlimited"; bounded && most.Load() > workers {
+			t.Errorf("%s ran %d tasks at once, bound %d", d.Name, most.Load(), workers)
+		}
+		if r.Tasks != tasks || r.P50 <= 0 || r.P99 < r.P50 || r.Throughput() <= 0 {
+			t.Errorf("%s: %+v", d.Name, r)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i))
+	}
+	for _, tt := range []struct {
+		p    float64
+		want time.Duration
+	}{{0, 1}, {50, 50}, {99, 99}, {99.5, 100}, {100, 100}} {
+		if got := Percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("p%v = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := Percentile(sorted[:1], 99); got != 1 {
+		t.Errorf("p99 of one = %v", got)
+	}
+	if got := Percentile(nil, 99); got != 0 {
+		t.Errorf("p99 of none = %v", got)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var b strings.Builder
+	WriteTable(&b, []Result{
+		{"pool", "cpu", 1000, time.Second, 2 * time.Millisecond, 3 * time.Millisecond},
+		{"unlimited", "cpu", 1000, 2 * time.Second, time.Millisecond, 9 * time.Millisecond},
+	})
+	want := `  profile     design  tasks/s  p50   p99
+      cpu       pool    *1000  2ms  *3ms
+      cpu  unlimited      500  1ms   9ms
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs every design on every profile")
+	}
+	results := Compare(10 * time.Millisecond)
+	if len(results) != len(Designs)*len(Profiles) {
+		t.Fatalf("%d results", len(results))
+	}
+	for _, r := range results {
+		if r.Design == "" || r.Tasks == 0 || r.P99 <= 0 {
+			t.Errorf("result %+v", r)
+		}
+	}
+}
+
+func BenchmarkDesigns(b *testing.B) {
+	for _, p := range Profiles {
+		for _, d := range Designs {
+			b.Run(fmt.Sprintf("%s/%s", p.Name, d.Name), func(b *testing.B) {
+				e := d.New(p.Workers)
+				defer e.Close()
+				b.ResetTimer()
+				r := Run(e, p, b.N)
+				b.StopTimer()
+				b.ReportMetric(r.Throughput(), "tasks/s")
+				b.ReportMetric(float64(r.P99.Microseconds()), "p99-µs")
+			})
+		}
+	}
+}
+
+func ExampleWriteTable() {
+	WriteTable(os.Stdout, []Result{
+		{"pool", "io", 4000, time.Second, 600 * time.Microsecond, 900 * time.Microsecond},
+		{"queue", "io", 4100, time.Second, 590 * time.Microsecond, 950 * time.Microsecond},
+	})
+	// Output:
+	//   profile  design  tasks/s    p50     p99
+	//        io    pool     4000  600µs  *900µs
+	//        io   queue    *4100  590µs   950µs
+}
@@ -0,0 +1,301 @@
+// Command patterns is the entry point to the runnable parts of this
+// repository.
+//
+//	patterns -version
+//	patterns version [-json]
+//	patterns list
+//	patterns run NAME [FLAGS]...
+//	patterns repl
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/crazybber/go-patterns/behavioral/interpreter/filter"
+	"github.com/crazybber/go-patterns/pattern"
+	_ "github.com/crazybber/go-patterns/pattern/catalog"
+	"github.com/crazybber/go-patterns/patterns/buildinfo"
+	"github.com/crazybber/go-patterns/patterns/cli"
+	"github.com/crazybber/go-patterns/patterns/repl"
+)
+
+type globalFlags struct {
+	Version bool `flag:"version" usage:"print version information and exit"`
+}
+
+type versionFlags struct {
+	JSON bool `flag:"json" usage:"print as JSON"`
+}
+
+func main() {
+	cli.Main(root())
+}
+
+func root() *cli.Command {
+	globals := &globalFlags{}
+	versionOpts := &versionFlags{}
+	return &cli.Command{
+		Name:  "patterns",
+		Usage: "Explore the patterns in this repository.",
+		Flags: globals,
+		Run: func(ctx context.Context, args []string) error {
+			if globals.Version {
+				fmt.Println(buildinfo.Get())
+				return nil
+			}
+			return cli.Usagef("missing command")
+		},
+		Commands: []*cli.Command{
+			{
+				Name:  "version",
+				Usage: "print version information",
+				Flags: versionOpts,
+				Run: func(ctx context.Context, args []string) error {
+					if versionOpts.JSON {
+						return json.NewEncoder(os.Stdout).Encode(buildinfo.Get())
+					}
+					fmt.Println(buildinfo.Get())
+					return nil
+				},
+			},
+			{
+				Name:  "list",
+				Usage: "list the runnable patterns",
+				Run: func(ctx context.Context, args []string) error {
+					for _, r := range pattern.All() {
+						fmt.Printf("%-32s %s\n", r.Name(), r.Describe())
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "run",
+				Usage: "run patterns by name, each followed by its own flags",
+				Args:  "NAME [FLAGS]...",
+				Run: func(ctx context.Context, args []string) error {
+					if len(args) == 0 {
+						return cli.Usagef("missing pattern name")
+					}
+					for len(args) > 0 {
+						var err error
+						if args, err = runPattern(ctx, os.Stdout, args); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "repl",
+				Usage: "explore interactively",
+				Run: func(ctx context.Context, args []string) error {
+					return newREPL(os.Stdin, os.Stdout).Run(ctx)
+				},
+			},
+		},
+	}
+}
+
+// runPattern runs the pattern named by args[0] with the flags that follow
+// it, writing to w, and returns the arguments left after them.
+func runPattern(ctx context.Context, w io.Writer, args []string) ([]string, error) {
+	name := args[0]
+	r, ok := pattern.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown pattern %q; see patterns list", name)
+	}
+	cmd := &cli.Command{
+		Name:  "patterns run " + name,
+		Usage: r.Describe(),
+		Run: func(ctx context.Context, rest []string) error {
+			args = rest
+			if err := r.Run(ctx, w); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			return nil
+		},
+	}
+	if c, ok := r.(pattern.Configurable); ok {
+		cmd.Flags = c.Flags()
+		// Put the defaults back afterwards, or the next run in the same
+		// REPL session would start from this one's flags.
+		opts := reflect.ValueOf(cmd.Flags).Elem()
+		defaults := reflect.New(opts.Type()).Elem()
+		defaults.Set(opts)
+		defer opts.Set(defaults)
+	}
+	err := cmd.Execute(ctx, args[1:])
+	return args, err
+}
+
+// newREPL returns a REPL over the catalog and the filter interpreter of
+// behavioral/interpreter/filter. The variables given with set are the
+// environment the expressions of filter are evaluated in.
+func newREPL(in io.Reader, out io.Writer) *repl.REPL {
+	r := repl.New(in, out)
+	r.Prompt = "patterns> "
+	r.Register(repl.Command{
+		Name: "version",
+		Help: "print version information",
+		Run: func(_ context.Context, w io.Writer, _ []string) error {
+			_, err := fmt.Fprintln(w, buildinfo.Get())
+			return err
+		},
+	})
+	r.Register(repl.Command{
+		Name: "list",
+		Help: "list the runnable patterns: list [PREFIX]",
+		Run: func(_ context.Context, w io.Writer, args []string) error {
+			prefix := ""
+			if len(args) > 0 {
+				prefix = args[0]
+			}
+			for _, p := range pattern.All() {
+				if strings.HasPrefix(p.Name(), prefix) {
+					fmt.Fprintf(w, "%-32s %s\n", p.Name(), p.Describe())
+				}
+			}
+			return nil
+		},
+		Complete: completeNames,
+	})
+	r.Register(repl.Command{
+		Name: "describe",
+		Help: "show what a pattern does and its flags: describe NAME",
+		Run: func(ctx context.Context, w io.Writer, args []string) error {
+			if len(args) != 1 {
+				return errors.New("usage: describe NAME")
+			}
+			_, err := runPattern(ctx, w, []string{args[0], "-h"})
+			return printUsage(w, err)
+		},
+		Complete: completeNames,
+	})
+	r.Register(repl.Command{
+		Name: "run",
+		Help: "run patterns: run NAME [FLAGS]...",
+		Run: func(ctx context.Context, w io.Writer, args []string) error {
+			if len(args) == 0 {
+				return errors.New("usage: run NAME [FLAGS]...")
+			}
+			for len(args) > 0 {
+				var err error
+				if args, err = runPattern(ctx, w, args); err != nil {
+					return printUsage(w, err)
+				}
+			}
+			return nil
+		},
+		Complete: completeNames,
+	})
+
+	vars := filter.Vars{}
+	r.Register(repl.Command{
+		Name: "set",
+		Help: "set filter variables, or list them: set [NAME=VALUE]...",
+		Run: func(_ context.Context, w io.Writer, args []string) error {
+			if len(args) == 0 {
+				names := make([]string, 0, len(vars))
+				for name := range vars {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					fmt.Fprintf(w, "%s = %#v\n", name, vars[name])
+				}
+				return nil
+			}
+			for _, a := range args {
+				name, value, ok := strings.Cut(a, "=")
+				if !ok || name == "" {
+					return fmt.Errorf("%q is not NAME=VALUE", a)
+				}
+				vars[name] = parseValue(value)
+			}
+			return nil
+		},
+	})
+	r.Register(repl.Command{
+		Name: "unset",
+		Help: "remove filter variables: unset NAME...",
+		Run: func(_ context.Context, _ io.Writer, args []string) error {
+			for _, name := range args {
+				delete(vars, name)
+			}
+			return nil
+		},
+	})
+	r.Register(repl.Command{
+		Name: "filter",
+		Help: "evaluate a filter expression against the variables: filter EXPR",
+		Run: func(_ context.Context, w io.Writer, args []string) error {
+			f, err := filter.Compile(strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+			ok, err := f.Match(vars)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(w, ok)
+			return err
+		},
+	})
+	return r
+}
+
+// completeNames offers the pattern names starting with the last argument.
+// Flags are not completed.
+func completeNames(args []string) []string {
+	last := args[len(args)-1]
+	if strings.HasPrefix(last, "-") {
+		return nil
+	}
+	var names []string
+	for _, p := range pattern.All() {
+		if strings.HasPrefix(p.Name(), last) {
+			names = append(names, p.Name())
+		}
+	}
+	return names
+}
+
+// printUsage writes the usage text of a usage error to w, where the REPL
+// would otherwise print only the error. A request for help is not an
+// error.
+func printUsage(w io.Writer, err error) error {
+	var ue *cli.UsageError
+	if !errors.As(err, &ue) {
+		return err
+	}
+	fmt.Fprint(w, ue.Usage)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return ue.Err
+}
+
+// parseValue reads the VALUE of set as a number, true or false or a
+// quoted string, and takes anything else as it is.
+func parseValue(s string) any {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if s == "true" || s == "false" {
+		return s == "true"
+	}
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
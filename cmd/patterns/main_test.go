@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestREPLFilter(t *testing.T) {
+	var out bytes.Buffer
+	script := `set age=42 name="bob" paused=false
+filter age > 30 && name == "bob"
+filter age > 30 && paused
+set
+unset paused
+filter !paused
+filter age +
+filter age / 0 > 1
+`
+	if err := newREPL(strings.NewReader(script), &out).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := `patterns> patterns> true
+patterns> false
+patterns> age = 42
+name = "bob"
+paused = false
+patterns> patterns> error: filter: unknown variable "paused"
+patterns> error: filter: syntax error at 5: expected an operand but found end of input
+patterns> error: filter: division by zero
+patterns> ` + "\n"
+	if out.String() != want {
+		t.Errorf("transcript:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestREPLCatalog(t *testing.T) {
+	var out bytes.Buffer
+	script := `list concurrency/gen
+run concurrency/generator -n 3
+run concurrency/generator
+describe concurrency/generator
+run concurrency/generator -x
+run nope
+`
+	if err := newREPL(strings.NewReader(script), &out).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	usage := `usage: patterns run concurrency/generator [flags]
+
+chains channel generators and ranges over the result
+
+flags:
+  -n int
+    	number of values to take (default 6)
+`
+	want := "patterns> concurrency/generator            chains channel generators and ranges over the result\n" +
+		"patterns> 1 4 9 \n" +
+		"patterns> 1 4 9 16 1 4 \n" +
+		"patterns> " + usage + `patterns> ` + usage + `error: flag provided but not defined: -x
+patterns> error: unknown pattern "nope"; see patterns list
+patterns> ` + "\n"
+	if out.String() != want {
+		t.Errorf("transcript:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestREPLCompletion(t *testing.T) {
+	r := newREPL(strings.NewReader(""), io.Discard)
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"de", []string{"describe"}},
+		{"run concurrency/gen", []string{"concurrency/generator"}},
+		{"run concurrency/generator -", nil},
+		{"describe behavioral/interpreter/f", []string{"behavioral/interpreter/filter"}},
+		{"filter ", nil},
+	}
+	for _, tt := range tests {
+		if got := r.Complete(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
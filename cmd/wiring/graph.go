@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Graph is the import graph of the packages of one module. Packages are
+// named by their path relative to the module root, "." for the root.
+type Graph struct {
+	Module   string
+	Packages []string
+	// Imports maps a package to the packages of the module it imports.
+	Imports map[string][]string
+}
+
+// Load parses the non-test Go files under the module rooted at dir and
+// returns its import graph. It reads only import declarations, so the
+// packages need not build. Directories named testdata or vendor, or
+// starting with . or _, are skipped, as the go command does, and so are
+// nested modules.
+func Load(dir string) (*Graph, error) {
+	mod, err := modulePath(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	g := &Graph{Module: mod, Imports: map[string][]string{}}
+	fset := token.NewFileSet()
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if p != dir && (name == "testdata" || name == "vendor" ||
+				strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); p != dir && err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, p, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, filepath.Dir(p))
+		pkg := filepath.ToSlash(rel)
+		if _, ok := g.Imports[pkg]; !ok {
+			g.Imports[pkg] = nil
+			g.Packages = append(g.Packages, pkg)
+		}
+		for _, imp := range f.Imports {
+			ip, _ := strconv.Unquote(imp.Path.Value)
+			var to string
+			switch {
+			case ip == mod:
+				to = "."
+			case strings.HasPrefix(ip, mod+"/"):
+				to = strings.TrimPrefix(ip, mod+"/")
+			default:
+				continue
+			}
+			if !slices.Contains(g.Imports[pkg], to) {
+				g.Imports[pkg] = append(g.Imports[pkg], to)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(g.Packages)
+	for _, imps := range g.Imports {
+		slices.Sort(imps)
+	}
+	return g, nil
+}
+
+func modulePath(gomod string) (string, error) {
+	data, err := os.ReadFile(gomod)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if f := strings.Fields(line); len(f) == 2 && f[0] == "module" {
+			return strings.Trim(f[1], `"`), nil
+		}
+	}
+	return "", fmt.Errorf("wiring: no module line in %s", gomod)
+}
+
+// Select returns the subgraph of the packages matching any of patterns.
+func (g *Graph) Select(patterns ...string) *Graph {
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		res[i] = compile(p)
+	}
+	match := func(pkg string) bool {
+		return slices.ContainsFunc(res, func(re *regexp.Regexp) bool { return re.MatchString(pkg) })
+	}
+	sub := &Graph{Module: g.Module, Imports: map[string][]string{}}
+	for _, pkg := range g.Packages {
+		if !match(pkg) {
+			continue
+		}
+		sub.Packages = append(sub.Packages, pkg)
+		sub.Imports[pkg] = nil
+		for _, to := range g.Imports[pkg] {
+			if match(to) {
+				sub.Imports[pkg] = append(sub.Imports[pkg], to)
+			}
+		}
+	}
+	return sub
+}
+
+// compile turns a package pattern into a regular expression. As with the
+// go command, "..." matches any string and a trailing "/..." also matches
+// the empty string, so "x/..." matches x and every package under it. "*"
+// matches one path element.
+func compile(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	rest := pattern
+	if strings.HasPrefix(rest, ".../") {
+		b.WriteString("(.*/)?")
+		rest = rest[len(".../"):]
+	}
+	tail := strings.HasSuffix(rest, "/...")
+	if tail {
+		rest = strings.TrimSuffix(rest, "/...")
+	}
+	for i, part := range strings.Split(rest, "...") {
+		if i > 0 {
+			b.WriteString(".*")
+		}
+		for j, seg := range strings.Split(part, "*") {
+			if j > 0 {
+				b.WriteString("[^/]*")
+			}
+			b.WriteString(regexp.QuoteMeta(seg))
+		}
+	}
+	if tail {
+		b.WriteString("(/.*)?")
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Rule forbids, or with Allow permits, packages matching From to import
+// packages matching To. An import is a violation if a deny rule matches it
+// and no allow rule does.
+type Rule struct {
+	Allow    bool
+	From, To string
+	Reason   string
+
+	from, to *regexp.Regexp
+}
+
+func (r *Rule) matches(from, to string) bool {
+	if r.from == nil {
+		r.from, r.to = compile(r.From), compile(r.To)
+	}
+	return r.from.MatchString(from) && r.to.MatchString(to)
+}
+
+// DefaultRules are the layering rules of this repository: a domain knows
+// nothing of the adapters and infrastructure that serve it, commands are
+// never imported, and test helpers stay in tests or the catalog that
+// demonstrates them.
+func DefaultRules() []Rule {
+	return []Rule{
+		{From: ".../domain/...", To: ".../adapters/...", Reason: "the domain must not depend on its adapters"},
+		{From: ".../domain/...", To: ".../infrastructure/...", Reason: "the domain must not depend on infrastructure"},
+		{From: "...", To: "cmd/...", Reason: "commands are entry points, not libraries"},
+		{From: "...", To: ".../cmd/...", Reason: "commands are entry points, not libraries"},
+		{Allow: true, From: "testing/...", To: "testing/..."},
+		{Allow: true, From: "pattern/catalog", To: "testing/..."},
+		{From: "...", To: "testing/...", Reason: "test helpers belong in _test.go files"},
+	}
+}
+
+// ParseRules reads rules, one per line:
+//
+//	deny  FROM TO  reason...
+//	allow FROM TO
+//
+// Blank lines and lines starting with # are ignored.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) < 3 || (f[0] != "deny" && f[0] != "allow") {
+			return nil, fmt.Errorf("wiring: rules line %d: want deny|allow FROM TO [reason]", n)
+		}
+		rules = append(rules, Rule{Allow: f[0] == "allow", From: f[1], To: f[2], Reason: strings.Join(f[3:], " ")})
+	}
+	return rules, s.Err()
+}
+
+// Violation is an import a rule forbids.
+type Violation struct {
+	From, To string
+	Reason   string
+}
+
+func (v Violation) String() string {
+	if v.Reason == "" {
+		return fmt.Sprintf("%s imports %s", v.From, v.To)
+	}
+	return fmt.Sprintf("%s imports %s: %s", v.From, v.To, v.Reason)
+}
+
+// Check returns the imports of g that rules forbid, in package order.
+func (g *Graph) Check(rules []Rule) []Violation {
+	var vs []Violation
+	for _, from := range g.Packages {
+		for _, to := range g.Imports[from] {
+			if v, ok := check(rules, from, to); ok {
+				vs = append(vs, v)
+			}
+		}
+	}
+	return vs
+}
+
+func check(rules []Rule, from, to string) (Violation, bool) {
+	var deny *Rule
+	for i := range rules {
+		r := &rules[i]
+		if !r.matches(from, to) {
+			continue
+		}
+		if r.Allow {
+			return Violation{}, false
+		}
+		if deny == nil {
+			deny = r
+		}
+	}
+	if deny == nil {
+		return Violation{}, false
+	}
+	return Violation{From: from, To: to, Reason: deny.Reason}, true
+}
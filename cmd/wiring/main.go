@@ -0,0 +1,79 @@
+// Command wiring draws the import graph of the packages of a module and
+// checks it against layering rules, such as that a domain package never
+// imports an adapter. It exits with status 1 when an import breaks a rule,
+// so that the architecture is enforced like a test.
+//
+//	go run ./cmd/wiring -format mermaid behavioral/... patterns/...
+//	go run ./cmd/wiring -format dot -rules layers.txt | dot -Tsvg > graph.svg
+//
+// The arguments are package patterns relative to the module root, in the
+// syntax of the go command; they default to every package. The graph is
+// built from import declarations alone with go/parser, so it needs only
+// the standard library and works on a tree that does not build.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fl := flag.NewFlagSet("wiring", flag.ContinueOnError)
+	fl.SetOutput(stderr)
+	root := fl.String("root", ".", "module root `dir`")
+	format := fl.String("format", "none", "graph output: dot, mermaid or none")
+	rulesFile := fl.String("rules", "", "layering rules `file` instead of the defaults")
+	if err := fl.Parse(args); err != nil {
+		return 2
+	}
+	render, ok := map[string]func(io.Writer, *Graph, []Violation) error{
+		"dot":     DOT,
+		"mermaid": Mermaid,
+		"none":    func(io.Writer, *Graph, []Violation) error { return nil },
+	}[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "wiring: unknown format %q\n", *format)
+		return 2
+	}
+	rules := DefaultRules()
+	if *rulesFile != "" {
+		f, err := os.Open(*rulesFile)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		rules, err = ParseRules(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	g, err := Load(*root)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if patterns := fl.Args(); len(patterns) > 0 {
+		g = g.Select(patterns...)
+	}
+	vs := g.Check(rules)
+	if err := render(stdout, g, vs); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	for _, v := range vs {
+		fmt.Fprintln(stderr, v)
+	}
+	if len(vs) > 0 {
+		return 1
+	}
+	return 0
+}
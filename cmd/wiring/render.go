@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DOT writes g in Graphviz's DOT language. Forbidden imports are drawn in
+// red and labelled with the rule's reason.
+func DOT(w io.Writer, g *Graph, vs []Violation) error {
+	bad := index(vs)
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n\trankdir=LR;\n\tnode [shape=box];\n", g.Module)
+	for _, pkg := range g.Packages {
+		fmt.Fprintf(&b, "\t%q;\n", pkg)
+	}
+	for _, from := range g.Packages {
+		for _, to := range g.Imports[from] {
+			if v, ok := bad[[2]string{from, to}]; ok {
+				fmt.Fprintf(&b, "\t%q -> %q [color=red, label=%q];\n", from, to, v.Reason)
+			} else {
+				fmt.Fprintf(&b, "\t%q -> %q;\n", from, to)
+			}
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Mermaid writes g as a Mermaid flowchart. Forbidden imports are drawn as
+// red dotted links.
+func Mermaid(w io.Writer, g *Graph, vs []Violation) error {
+	bad := index(vs)
+	ids := make(map[string]string, len(g.Packages))
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for i, pkg := range g.Packages {
+		ids[pkg] = fmt.Sprint("p", i)
+		fmt.Fprintf(&b, "\t%s[%q]\n", ids[pkg], pkg)
+	}
+	var red []string
+	link := 0
+	for _, from := range g.Packages {
+		for _, to := range g.Imports[from] {
+			if _, ok := bad[[2]string{from, to}]; ok {
+				fmt.Fprintf(&b, "\t%s -.->|violation| %s\n", ids[from], ids[to])
+				red = append(red, fmt.Sprint(link))
+			} else {
+				fmt.Fprintf(&b, "\t%s --> %s\n", ids[from], ids[to])
+			}
+			link++
+		}
+	}
+	if len(red) > 0 {
+		fmt.Fprintf(&b, "\tlinkStyle %s stroke:red\n", strings.Join(red, ","))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func index(vs []Violation) map[[2]string]Violation {
+	m := make(map[[2]string]Violation, len(vs))
+	for _, v := range vs {
+		m[[2]string{v.From, v.To}] = v
+	}
+	return m
+}
@@ -0,0 +1,7 @@
+package sqlstore
+
+import "database/sql"
+
+const Table = "orders"
+
+var _ *sql.DB
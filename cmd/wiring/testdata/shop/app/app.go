@@ -0,0 +1,8 @@
+package app
+
+import (
+	"example.com/shop/adapters/sqlstore"
+	"example.com/shop/domain"
+)
+
+func Place(o domain.Order) string { return sqlstore.Table + o.ID }
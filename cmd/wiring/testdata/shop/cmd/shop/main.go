@@ -0,0 +1,10 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/shop/app"
+	"example.com/shop/domain"
+)
+
+func main() { fmt.Println(app.Place(domain.Order{ID: "1"})) }
@@ -0,0 +1,7 @@
+package domain
+
+import "example.com/shop/adapters/sqlstore"
+
+type Order struct{ ID string }
+
+var _ = sqlstore.Table
@@ -0,0 +1,3 @@
+module example.com/shop/nested
+
+go 1.23
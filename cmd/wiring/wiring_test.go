@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	g, err := Load("testdata/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Test files, testdata and the nested module are left out.
+	want := "[adapters/sqlstore app cmd/shop domain] map[adapters/sqlstore:[] " +
+		"app:[adapters/sqlstore domain] cmd/shop:[app domain] domain:[adapters/sqlstore]]"
+	if got := fmt.Sprint(g.Packages, " ", g.Imports); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if g.Module != "example.com/shop" {
+		t.Errorf("module %q", g.Module)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		match   []string
+		nomatch []string
+	}{
+		{"...", []string{".", "a", "a/b"}, nil},
+		{"a/...", []string{"a", "a/b", "a/b/c"}, []string{"ab", "b/a"}},
+		{".../domain/...", []string{"domain", "x/domain", "x/domain/y"}, []string{"domains", "x/subdomain"}},
+		{"a/*/c", []string{"a/b/c", "a/xyz/c"}, []string{"a/c", "a/b/b/c"}},
+		{"a.b", []string{"a.b"}, []string{"axb"}},
+	} {
+		re := compile(tc.pattern)
+		for _, s := range tc.match {
+			if !re.MatchString(s) {
+				t.Errorf("%q does not match %q", tc.pattern, s)
+			}
+		}
+		for _, s := range tc.nomatch {
+			if re.MatchString(s) {
+				t.Errorf("%q matches %q", tc.pattern, s)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	g, err := Load("testdata/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(g.Check(DefaultRules())); got !=
+		"[domain imports adapters/sqlstore: the domain must not depend on its adapters]" {
+		t.Errorf("default rules: %s", got)
+	}
+
+	f, err := os.Open("testdata/layers.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rules, err := ParseRules(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the first matching deny rule is reported, and the allow rule
+	// lets app use the adapter.
+	if got := fmt.Sprint(g.Check(rules)); got !=
+		"[domain imports adapters/sqlstore: the domain must not depend on its adapters]" {
+		t.Errorf("rules file: %s", got)
+	}
+
+	if _, err := ParseRules(strings.NewReader("forbid a b")); err == nil {
+		t.Error("parsed a bad rule")
+	}
+}
+
+// TestRepository holds this repository to its own rules.
+func TestRepository(t *testing.T) {
+	g, err := Load("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Module != "github.com/crazybber/go-patterns" || len(g.Packages) < 50 {
+		t.Fatalf("loaded %s with %d packages", g.Module, len(g.Packages))
+	}
+	for _, v := range g.Check(DefaultRules()) {
+		t.Error(v)
+	}
+}
+
+func TestRender(t *testing.T) {
+	g, err := Load("testdata/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = g.Select("app", "domain", "adapters/...")
+	vs := g.Check(DefaultRules())
+
+	var dot strings.Builder
+	DOT(&dot, g, vs)
+	for _, want := range []string{
+		`digraph "example.com/shop" {`,
+		`"app" -> "domain";`,
+		`"domain" -> "adapters/sqlstore" [color=red, label="the domain must not depend on its adapters"];`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("DOT lacks %s:\n%s", want, dot.String())
+		}
+	}
+	if strings.Contains(dot.String(), "cmd/shop") {
+		t.Errorf("DOT has an unselected package:\n%s", dot.String())
+	}
+
+	var mm strings.Builder
+	Mermaid(&mm, g, vs)
+	want := `graph LR
+	p0["adapters/sqlstore"]
+	p1["app"]
+	p2["domain"]
+	p1 --> p0
+	p1 --> p2
+	p2 -.->|violation| p0
+	linkStyle 2 stroke:red
+`
+	if mm.String() != want {
+		t.Errorf("Mermaid:\n%s\nwant\n%s", mm.String(), want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, tc := range []struct {
+		args   []string
+		status int
+		out    string
+		errout string
+	}{
+		{[]string{"-root", "testdata/shop", "-format", "mermaid", "cmd/..."}, 0, "graph LR\n\tp0[\"cmd/shop\"]\n", ""},
+		{[]string{"-root", "testdata/shop"}, 1, "", "domain imports adapters/sqlstore"},
+		{[]string{"-root", "testdata/shop", "-format", "svg"}, 2, "", "unknown format"},
+		{[]string{"-root", "testdata/none"}, 2, "", "go.mod"},
+	} {
+		var out, errout strings.Builder
+		status := run(tc.args, &out, &errout)
+		if status != tc.status || out.String() != tc.out || !strings.Contains(errout.String(), tc.errout) {
+			t.Errorf("%v: status %d, out %q, stderr %q", tc.args, status, out.String(), errout.String())
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package cyclic implements a reusable (cyclic) barrier. A fixed number of
+// goroutines call Await at the end of every phase; all of them block until
+// the last one arrives, and then all are released together into the next
+// phase. Unlike the one-shot barrier in ../n_barrier, the same Barrier can
+// be used for any number of phases.
+package cyclic
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrBroken is returned by Await once the barrier has been broken.
+var ErrBroken = errors.New("cyclic: barrier is broken")
+
+// Barrier is a cyclic barrier for a fixed number of parties.
+type Barrier struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	parties int
+	action  func()
+
+	waiting    int
+	generation uint64
+	broken     bool
+}
+
+// New creates a barrier for the given number of parties.
+func New(parties int) *Barrier {
+	return NewWithAction(parties, nil)
+}
+
+// NewWithAction creates a barrier that runs action once per phase, in the
+// goroutine of the last party to arrive, before anyone is released. The
+// action is the natural place to merge the results of a phase.
+func NewWithAction(parties int, action func()) *Barrier {
+	if parties < 1 {
+		panic("cyclic: parties must be at least 1")
+	}
+	b := &Barrier{parties: parties, action: action}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Await blocks until all parties have called Await for the current phase.
+func (b *Barrier) Await() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.broken {
+		return ErrBroken
+	}
+
+	gen := b.generation
+	b.waiting++
+	if b.waiting == b.parties {
+		if b.action != nil {
+			b.action()
+		}
+		b.waiting = 0
+		b.generation++
+		b.cond.Broadcast()
+		return nil
+	}
+
+	// Wake-ups can be spurious and a fast party may already be waiting
+	// in the next phase, so wait for this phase's generation to change.
+	for gen == b.generation && !b.broken {
+		b.cond.Wait()
+	}
+	if gen == b.generation {
+		return ErrBroken
+	}
+	return nil
+}
+
+// Break releases every waiting party with ErrBroken and makes all future
+// calls to Await fail. It is how one party gives up without leaving the
+// others blocked forever.
+func (b *Barrier) Break() {
+	b.mu.Lock()
+	b.broken = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+}
+
+// Parties returns the number of parties the barrier waits for.
+func (b *Barrier) Parties() int {
+	return b.parties
+}
+
+// Waiting returns the number of parties waiting in the current phase.
+func (b *Barrier) Waiting() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.waiting
+}
@@ -0,0 +1,127 @@
+package cyclic
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func TestPhasesStayInStep(t *testing.T) {
+	const parties, phases = 5, 50
+	b := New(parties)
+
+	var mu sync.Mutex
+	arrived := make([]int, phases)
+
+	var wg sync.WaitGroup
+	for p := 0; p < parties; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for phase := 0; phase < phases; phase++ {
+				mu.Lock()
+				arrived[phase]++
+				mu.Unlock()
+				if err := b.Await(); err != nil {
+					t.Error(err)
+					return
+				}
+				// Nobody leaves a phase before everyone reached it.
+				mu.Lock()
+				if arrived[phase] != parties {
+					t.Errorf("phase %d released with %d of %d parties", phase, arrived[phase], parties)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestActionRunsOncePerPhase(t *testing.T) {
+	var runs int
+	b := NewWithAction(3, func() { runs++ })
+
+	var wg sync.WaitGroup
+	for p := 0; p < 3; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 4; i++ {
+				b.Await()
+			}
+		}()
+	}
+	wg.Wait()
+	if runs != 4 {
+		t.Errorf("action ran %d times, want 4", runs)
+	}
+}
+
+func TestBreak(t *testing.T) {
+	b := New(3)
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- b.Await() }()
+	}
+	for b.Waiting() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	b.Break()
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != ErrBroken {
+			t.Errorf("expected ErrBroken, got %v", err)
+		}
+	}
+	if err := b.Await(); err != ErrBroken {
+		t.Errorf("expected ErrBroken after Break, got %v", err)
+	}
+}
+
+// ExampleBarrier smooths a row of values over several phases. Every worker
+// owns a slice of the row; in each phase it reads the previous row and
+// writes its part of the next, and the barrier action swaps the buffers
+// once all workers are done.
+func ExampleBarrier() {
+	cur := []float64{0, 0, 0, 90, 0, 0, 0, 0}
+	next := make([]float64, len(cur))
+
+	const workers, phases = 4, 3
+	b := NewWithAction(workers, func() { cur, next = next, cur })
+
+	var wg sync.WaitGroup
+	chunk := len(cur) / workers
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			for phase := 0; phase < phases; phase++ {
+				for i := lo; i < hi; i++ {
+					sum, n := cur[i], 1.0
+					if i > 0 {
+						sum, n = sum+cur[i-1], n+1
+					}
+					if i < len(cur)-1 {
+						sum, n = sum+cur[i+1], n+1
+					}
+					next[i] = sum / n
+				}
+				b.Await()
+			}
+		}(w*chunk, (w+1)*chunk)
+	}
+	wg.Wait()
+
+	for _, v := range cur {
+		fmt.Printf("%.1f ", v)
+	}
+	fmt.Println()
+	// Output:
+	// 5.0 10.0 20.0 23.3 20.0 10.0 3.3 0.0
+}
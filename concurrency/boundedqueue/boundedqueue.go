@@ -0,0 +1,180 @@
+// Package boundedqueue is a blocking FIFO queue of fixed capacity for any
+// number of producers and consumers, built on sync.Cond.
+//
+// It is what a buffered channel does, spelled out: a mutex guards a ring
+// of values, producers wait on one condition variable until there is
+// room and consumers on another until there is a value. Each push wakes
+// one consumer and each pop one producer with Signal, so that a burst of
+// values does not wake every waiter to fight over the lock, the wakeup
+// storm that Broadcast causes. Close wakes everyone: producers fail with
+// ErrClosed, and consumers drain what is left before failing too.
+//
+// A condition variable cannot be selected on together with a context,
+// so PushContext and PopContext use context.AfterFunc to wake the waiters
+// when the context ends.
+package boundedqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Errors of a Queue.
+var (
+	ErrClosed = errors.New("boundedqueue: closed")
+	ErrFull   = errors.New("boundedqueue: full")
+	ErrEmpty  = errors.New("boundedqueue: empty")
+)
+
+// Queue is a bounded blocking queue of values of type T.
+type Queue[T any] struct {
+	mu       sync.Mutex
+	notEmpty sync.Cond
+	notFull  sync.Cond
+	buf      []T
+	head, n  int
+	closed   bool
+}
+
+// New returns an empty Queue with room for capacity values, at least one.
+func New[T any](capacity int) *Queue[T] {
+	q := &Queue[T]{buf: make([]T, max(capacity, 1))}
+	q.notEmpty.L = &q.mu
+	q.notFull.L = &q.mu
+	return q
+}
+
+// Cap returns the capacity.
+func (q *Queue[T]) Cap() int { return len(q.buf) }
+
+// Len returns the number of values queued.
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+// push adds v; q.mu must be held and the queue not be full.
+func (q *Queue[T]) push(v T) {
+	q.buf[(q.head+q.n)%len(q.buf)] = v
+	q.n++
+	q.notEmpty.Signal()
+}
+
+// pop removes the oldest value; q.mu must be held and the queue not be
+// empty.
+func (q *Queue[T]) pop() T {
+	var zero T
+	v := q.buf[q.head]
+	q.buf[q.head] = zero
+	q.head = (q.head + 1) % len(q.buf)
+	q.n--
+	q.notFull.Signal()
+	return v
+}
+
+// TryPush queues v if there is room. It returns ErrFull if there is not.
+func (q *Queue[T]) TryPush(v T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch {
+	case q.closed:
+		return ErrClosed
+	case q.n == len(q.buf):
+		return ErrFull
+	}
+	q.push(v)
+	return nil
+}
+
+// Push queues v, waiting for room.
+func (q *Queue[T]) Push(v T) error { return q.PushContext(context.Background(), v) }
+
+// PushContext queues v, waiting for room until ctx is done.
+func (q *Queue[T]) PushContext(ctx context.Context, v T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for waited := false; !q.closed && q.n == len(q.buf); waited = true {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !waited {
+			defer q.wakeOnDone(ctx, &q.notFull)()
+		}
+		q.notFull.Wait()
+	}
+	if q.closed {
+		return ErrClosed
+	}
+	q.push(v)
+	return nil
+}
+
+// TryPop removes and returns the oldest value if there is one. It
+// returns ErrEmpty if there is not, and ErrClosed once the queue is
+// closed and drained.
+func (q *Queue[T]) TryPop() (T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.n == 0 {
+		var zero T
+		if q.closed {
+			return zero, ErrClosed
+		}
+		return zero, ErrEmpty
+	}
+	return q.pop(), nil
+}
+
+// Pop removes and returns the oldest value, waiting for one.
+func (q *Queue[T]) Pop() (T, error) { return q.PopContext(context.Background()) }
+
+// PopContext removes and returns the oldest value, waiting for one until
+// ctx is done. Values queued before Close are still returned after it;
+// once they are gone it returns ErrClosed.
+func (q *Queue[T]) PopContext(ctx context.Context) (T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for waited := false; !q.closed && q.n == 0; waited = true {
+		if err := ctx.Err(); err != nil {
+			var zero T
+			return zero, err
+		}
+		if !waited {
+			defer q.wakeOnDone(ctx, &q.notEmpty)()
+		}
+		q.notEmpty.Wait()
+	}
+	if q.n == 0 {
+		var zero T
+		return zero, ErrClosed
+	}
+	return q.pop(), nil
+}
+
+// wakeOnDone arranges for every waiter on c to be woken when ctx is
+// done, so that the one waiting for ctx notices; the others find their
+// condition unchanged and wait again. It is Broadcast rather than Signal
+// because a signal taken by a waiter that then leaves would be lost to
+// the ones that stay. It returns a function undoing the arrangement.
+func (q *Queue[T]) wakeOnDone(ctx context.Context, c *sync.Cond) (stop func() bool) {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+	return context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		c.Broadcast()
+		q.mu.Unlock()
+	})
+}
+
+// Close makes pushes fail and wakes every waiter. Consumers receive the
+// values still queued before they see ErrClosed. Close is idempotent.
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.mu.Unlock()
+}
@@ -0,0 +1,259 @@
+package boundedqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// blocked waits until n goroutines are waiting on a condition variable.
+func blocked(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		waiting := 0
+		for _, g := range leaks.Snapshot() {
+			if g.State == "sync.Cond.Wait" {
+				waiting++
+			}
+		}
+		if waiting == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines blocked, want %d", waiting, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFIFO(t *testing.T) {
+	q := New[int](3)
+	for i := range 3 {
+		if err := q.TryPush(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.TryPush(3); err != ErrFull {
+		t.Errorf("TryPush on full = %v", err)
+	}
+	// Wrap around the ring.
+	for i := range 10 {
+		v, err := q.TryPop()
+		if v != i || err != nil {
+			t.Fatalf("TryPop = %d, %v, want %d", v, err, i)
+		}
+		q.Push(i + 3)
+	}
+	if q.Len() != 3 || q.Cap() != 3 {
+		t.Errorf("Len %d, Cap %d", q.Len(), q.Cap())
+	}
+	if New[int](0).Cap() != 1 {
+		t.Error("capacity below one")
+	}
+	empty := New[int](1)
+	if _, err := empty.TryPop(); err != ErrEmpty {
+		t.Errorf("TryPop on empty = %v", err)
+	}
+}
+
+func TestWakeupStorm(t *testing.T) {
+	leaks.Check(t)
+	const consumers, producers, each = 50, 10, 200
+	q := New[int](4)
+	var (
+		mu   sync.Mutex
+		seen = map[int]int{}
+		wg   sync.WaitGroup
+	)
+	for range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				v, err := q.Pop()
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				seen[v]++
+				mu.Unlock()
+			}
+		}()
+	}
+	blocked(t, consumers)
+	var pwg sync.WaitGroup
+	for p := range producers {
+		pwg.Add(1)
+		go func() {
+			defer pwg.Done()
+			for i := range each {
+				if err := q.Push(p*each + i); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	pwg.Wait()
+	q.Close()
+	wg.Wait()
+	if len(seen) != producers*each {
+		t.Errorf("%d distinct values received, want %d", len(seen), producers*each)
+	}
+	for v, n := range seen {
+		if n != 1 {
+			t.Errorf("value %d received %d times", v, n)
+		}
+	}
+}
+
+func TestCloseWakesBlockedConsumers(t *testing.T) {
+	leaks.Check(t)
+	q := New[int](2)
+	errs := make(chan error, 5)
+	for range 5 {
+		go func() {
+			_, err := q.Pop()
+			errs <- err
+		}()
+	}
+	blocked(t, 5)
+	q.Close()
+	q.Close()
+	for range 5 {
+		if err := <-errs; err != ErrClosed {
+			t.Errorf("Pop = %v", err)
+		}
+	}
+}
+
+func TestCloseWakesBlockedProducers(t *testing.T) {
+	leaks.Check(t)
+	q := New[int](2)
+	q.Push(1)
+	q.Push(2)
+	errs := make(chan error, 3)
+	for i := range 3 {
+		go func() { errs <- q.Push(10 + i) }()
+	}
+	blocked(t, 3)
+	q.Close()
+	for range 3 {
+		if err := <-errs; err != ErrClosed {
+			t.Errorf("Push = %v", err)
+		}
+	}
+	if err := q.TryPush(3); err != ErrClosed {
+		t.Errorf("TryPush after Close = %v", err)
+	}
+	// What was queued before Close is drained first.
+	for _, want := range []int{1, 2} {
+		if v, err := q.Pop(); v != want || err != nil {
+			t.Errorf("Pop = %d, %v, want %d", v, err, want)
+		}
+	}
+	if _, err := q.TryPop(); err != ErrClosed {
+		t.Errorf("TryPop after drain = %v", err)
+	}
+}
+
+func TestContextCancelsOnlyItsWaiter(t *testing.T) {
+	leaks.Check(t)
+	q := New[int](1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := q.PopContext(ctx)
+		cancelled <- err
+	}()
+	other := make(chan int, 1)
+	go func() {
+		v, _ := q.Pop()
+		other <- v
+	}()
+	blocked(t, 2)
+	cancel()
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("PopContext = %v", err)
+	}
+	blocked(t, 1)
+	q.Push(7)
+	if v := <-other; v != 7 {
+		t.Errorf("other consumer got %d", v)
+	}
+
+	q.Push(1)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := q.PushContext(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("PushContext on full = %v", err)
+	}
+}
+
+func BenchmarkQueue(b *testing.B) {
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprint("queue/", workers), func(b *testing.B) {
+			q := New[int](64)
+			var wg sync.WaitGroup
+			for range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						if _, err := q.Pop(); err != nil {
+							return
+						}
+					}
+				}()
+			}
+			for i := range b.N {
+				q.Push(i)
+			}
+			q.Close()
+			wg.Wait()
+		})
+		b.Run(fmt.Sprint("chan/", workers), func(b *testing.B) {
+			c := make(chan int, 64)
+			var wg sync.WaitGroup
+			for range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range c {
+					}
+				}()
+			}
+			for i := range b.N {
+				c <- i
+			}
+			close(c)
+			wg.Wait()
+		})
+	}
+}
+
+func Example() {
+	q := New[string](2)
+	q.Push("first")
+	q.Push("second")
+	q.Close()
+	for {
+		v, err := q.Pop()
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		fmt.Println(v)
+	}
+	// Output:
+	// first
+	// second
+	// boundedqueue: closed
+}
@@ -0,0 +1,181 @@
+package boundedqueue
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"testing/quick"
+)
+
+// op is a step of a random history run against a Queue and a model.
+type op struct {
+	kind string // "push", "pop", "len" or "close"
+	v    int
+}
+
+func (o op) String() string {
+	if o.kind == "push" {
+		return fmt.Sprintf("push(%d)", o.v)
+	}
+	return o.kind
+}
+
+// history is a random sequence of ops for testing/quick. Closes are rare,
+// so that most histories have something happening after them.
+type history struct {
+	capacity int
+	ops      []op
+}
+
+func (history) Generate(r *rand.Rand, size int) reflect.Value {
+	h := history{capacity: 1 + r.Intn(5)}
+	for i := range r.Intn(size * 4) {
+		switch n := r.Intn(20); {
+		case n == 0:
+			h.ops = append(h.ops, op{kind: "close"})
+		case n < 9:
+			h.ops = append(h.ops, op{kind: "push", v: i})
+		case n < 17:
+			h.ops = append(h.ops, op{kind: "pop"})
+		default:
+			h.ops = append(h.ops, op{kind: "len"})
+		}
+	}
+	return reflect.ValueOf(h)
+}
+
+// GoString keeps the input testing/quick reports on failure readable.
+func (h history) GoString() string { return fmt.Sprintf("cap %d: %v", h.capacity, h.ops) }
+
+// model is a queue too simple to be wrong.
+type model struct {
+	capacity int
+	values   []int
+	closed   bool
+}
+
+// Every sequential history gives the results and leaves the contents the
+// model predicts.
+func TestPropertySequential(t *testing.T) {
+	check := func(h history) bool {
+		q, m := New[int](h.capacity), &model{capacity: h.capacity}
+		for i, o := range h.ops {
+			var got, want string
+			switch o.kind {
+			case "push":
+				got = fmt.Sprint(q.TryPush(o.v))
+				switch {
+				case m.closed:
+					want = fmt.Sprint(ErrClosed)
+				case len(m.values) == m.capacity:
+					want = fmt.Sprint(ErrFull)
+				default:
+					want = fmt.Sprint(nil)
+					m.values = append(m.values, o.v)
+				}
+			case "pop":
+				v, err := q.TryPop()
+				got = fmt.Sprint(v, err)
+				switch {
+				case len(m.values) > 0:
+					want = fmt.Sprint(m.values[0], nil)
+					m.values = m.values[1:]
+				case m.closed:
+					want = fmt.Sprint(0, ErrClosed)
+				default:
+					want = fmt.Sprint(0, ErrEmpty)
+				}
+			case "len":
+				got, want = fmt.Sprint(q.Len()), fmt.Sprint(len(m.values))
+			case "close":
+				q.Close()
+				m.closed = true
+			}
+			if got != want {
+				t.Logf("cap %d: %v gave %s, want %s", h.capacity, h.ops[:i+1], got, want)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(check, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// load is a random number of producers, each pushing a random number of
+// values, and of consumers.
+type load struct {
+	capacity, consumers int
+	producers           []int
+}
+
+func (load) Generate(r *rand.Rand, size int) reflect.Value {
+	l := load{capacity: 1 + r.Intn(4), consumers: 1 + r.Intn(4)}
+	for range 1 + r.Intn(4) {
+		l.producers = append(l.producers, r.Intn(size*10))
+	}
+	return reflect.ValueOf(l)
+}
+
+// Under concurrent producers and consumers, no value is lost or
+// duplicated, and every consumer receives the values of each producer in
+// the order they were pushed.
+func TestPropertyConcurrent(t *testing.T) {
+	type value struct{ producer, seq int }
+	check := func(l load) bool {
+		q := New[value](l.capacity)
+		var producers sync.WaitGroup
+		for p, n := range l.producers {
+			producers.Add(1)
+			go func() {
+				defer producers.Done()
+				for i := range n {
+					q.Push(value{p, i})
+				}
+			}()
+		}
+		received := make([][]value, l.consumers)
+		var consumers sync.WaitGroup
+		for c := range received {
+			consumers.Add(1)
+			go func() {
+				defer consumers.Done()
+				for {
+					v, err := q.Pop()
+					if err != nil {
+						return
+					}
+					received[c] = append(received[c], v)
+				}
+			}()
+		}
+		producers.Wait()
+		q.Close()
+		consumers.Wait()
+
+		seen := make([]int, len(l.producers))
+		for c, vs := range received {
+			last := slices.Repeat([]int{-1}, len(l.producers))
+			for _, v := range vs {
+				if v.seq <= last[v.producer] {
+					t.Logf("%+v: consumer %d got %v after seq %d", l, c, v, last[v.producer])
+					return false
+				}
+				last[v.producer] = v.seq
+				seen[v.producer]++
+			}
+		}
+		if !slices.Equal(seen, l.producers) {
+			t.Logf("%+v: received %v values per producer", l, seen)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 50}); err != nil {
+		t.Error(err)
+	}
+}
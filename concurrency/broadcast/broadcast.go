@@ -0,0 +1,147 @@
+// Package broadcast notifies many goroutines at once. Sending on a channel
+// wakes one receiver; closing it wakes them all, and every receive after
+// that succeeds at once. Event is that idiom for a one-off notification.
+//
+// Broadcaster repeats it: every broadcast closes the channel of the
+// current generation and opens the next. The generations are linked, and
+// each Subscription walks the chain at its own pace, so a subscriber that
+// is busy when a broadcast happens still receives it the next time it
+// asks, and never has to register again between two values.
+//
+// sync.Cond.Broadcast does the same waking, but only for the goroutines
+// already waiting: one that is busy, or between two calls to Wait,
+// misses the broadcast. A Cond also cannot be waited on in a select, so
+// waiting on one cannot be abandoned when a context is done.
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned by Next once a subscription has received every
+// value broadcast before the Broadcaster was closed.
+var ErrClosed = errors.New("broadcast: closed")
+
+// Event is a notification that happens once. The zero value is not ready
+// to use; call NewEvent.
+type Event struct {
+	once sync.Once
+	c    chan struct{}
+}
+
+// NewEvent returns an event that has not happened yet.
+func NewEvent() *Event {
+	return &Event{c: make(chan struct{})}
+}
+
+// Fire wakes every goroutine waiting on Done, now and later. Calls after
+// the first do nothing.
+func (e *Event) Fire() {
+	e.once.Do(func() { close(e.c) })
+}
+
+// Done is closed once the event has fired.
+func (e *Event) Done() <-chan struct{} {
+	return e.c
+}
+
+// Fired reports whether the event has fired.
+func (e *Event) Fired() bool {
+	select {
+	case <-e.c:
+		return true
+	default:
+		return false
+	}
+}
+
+// generation holds the value of one broadcast. val, closed and next are
+// written before ready is closed and read only after.
+type generation[T any] struct {
+	ready  chan struct{}
+	val    T
+	closed bool
+	next   *generation[T]
+}
+
+func newGeneration[T any]() *generation[T] {
+	return &generation[T]{ready: make(chan struct{})}
+}
+
+// Broadcaster sends every value it is given to all of its subscribers.
+// Broadcasting never blocks: a subscriber that falls behind holds on to
+// the values it has not received yet, not the broadcaster.
+type Broadcaster[T any] struct {
+	mu  sync.Mutex
+	cur *generation[T]
+}
+
+// New returns a Broadcaster with no subscribers.
+func New[T any]() *Broadcaster[T] {
+	return &Broadcaster[T]{cur: newGeneration[T]()}
+}
+
+// Broadcast sends v to every current subscriber. It panics if b is
+// closed.
+func (b *Broadcaster[T]) Broadcast(v T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cur.closed {
+		panic("broadcast: Broadcast after Close")
+	}
+	g := b.cur
+	g.val, g.next = v, newGeneration[T]()
+	b.cur = g.next
+	close(g.ready)
+}
+
+// Close ends the broadcasts. Subscriptions receive the values they have
+// not received yet and then ErrClosed. Closing twice does nothing.
+func (b *Broadcaster[T]) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cur.closed {
+		return
+	}
+	b.cur.closed = true
+	close(b.cur.ready)
+}
+
+// Subscribe returns a subscription to the values broadcast from now on.
+func (b *Broadcaster[T]) Subscribe() *Subscription[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return &Subscription[T]{next: b.cur}
+}
+
+// Subscription is one subscriber's place in the broadcasts. It is meant
+// for one goroutine at a time.
+type Subscription[T any] struct {
+	next *generation[T]
+}
+
+// Ready is closed when Next has a value, or ErrClosed, to return without
+// blocking. It changes after every call to Next.
+func (s *Subscription[T]) Ready() <-chan struct{} {
+	return s.next.ready
+}
+
+// Next waits for the next value broadcast after the previous one s
+// received. It returns ErrClosed once the broadcaster is closed and all
+// values have been received, and ctx.Err() if ctx is done first.
+func (s *Subscription[T]) Next(ctx context.Context) (T, error) {
+	var zero T
+	select {
+	case <-s.next.ready:
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+	g := s.next
+	if g.closed {
+		return zero, ErrClosed
+	}
+	s.next = g.next
+	return g.val, nil
+}
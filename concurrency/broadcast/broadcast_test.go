@@ -0,0 +1,279 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func TestEvent(t *testing.T) {
+	leaks.Check(t)
+	e := NewEvent()
+	if e.Fired() {
+		t.Fatal("fired before Fire")
+	}
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-e.Done()
+		}()
+	}
+	e.Fire()
+	e.Fire()
+	wg.Wait()
+	if !e.Fired() {
+		t.Error("not fired after Fire")
+	}
+}
+
+func TestEverySubscriberGetsEveryValue(t *testing.T) {
+	leaks.Check(t)
+	const values, subscribers = 1000, 8
+	b := New[int]()
+	var wg sync.WaitGroup
+	for n := range subscribers {
+		s := b.Subscribe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for want := 0; ; want++ {
+				v, err := s.Next(context.Background())
+				if errors.Is(err, ErrClosed) {
+					if want != values {
+						t.Errorf("subscriber %d got %d values", n, want)
+					}
+					return
+				}
+				if v != want {
+					t.Errorf("subscriber %d got %d, want %d", n, v, want)
+					return
+				}
+				// Subscribers fall behind and catch up at different times.
+				if v%(n+1) == 0 {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	for i := range values {
+		b.Broadcast(i)
+	}
+	b.Close()
+	wg.Wait()
+}
+
+// condBroadcaster is what a broadcaster built on sync.Cond looks like: it
+// can only hold on to the latest value.
+type condBroadcaster struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	gen    int
+	latest int
+}
+
+func newCondBroadcaster() *condBroadcaster {
+	c := &condBroadcaster{}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *condBroadcaster) Broadcast(v int) {
+	c.mu.Lock()
+	c.gen++
+	c.latest = v
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+// next waits for a generation after seen and returns it with its value.
+func (c *condBroadcaster) next(seen int) (int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.gen == seen {
+		c.cond.Wait()
+	}
+	return c.gen, c.latest
+}
+
+func TestNoMissWhileBusy(t *testing.T) {
+	b, c := New[int](), newCondBroadcaster()
+	s := b.Subscribe()
+	b.Broadcast(1)
+	c.Broadcast(1)
+	v, _ := s.Next(context.Background())
+	gen, cv := c.next(0)
+	if v != 1 || cv != 1 {
+		t.Fatalf("first values %d and %d", v, cv)
+	}
+
+	// Both subscribers are busy while two more values are broadcast, and
+	// then ask for the next one without having registered in between.
+	b.Broadcast(2)
+	b.Broadcast(3)
+	c.Broadcast(2)
+	c.Broadcast(3)
+	for _, want := range []int{2, 3} {
+		if v, err := s.Next(context.Background()); v != want || err != nil {
+			t.Errorf("Next = %d, %v, want %d", v, err, want)
+		}
+	}
+	if _, cv := c.next(gen); cv != 3 {
+		t.Errorf("cond got %d", cv)
+	} else {
+		t.Log("the sync.Cond subscriber skipped 2")
+	}
+}
+
+func TestSubscribeSeesOnlyLaterValues(t *testing.T) {
+	b := New[string]()
+	b.Broadcast("before")
+	s := b.Subscribe()
+	b.Broadcast("after")
+	if v, _ := s.Next(context.Background()); v != "after" {
+		t.Errorf("Next = %q", v)
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := New[int]()
+	s := b.Subscribe()
+	b.Broadcast(1)
+	b.Close()
+	b.Close()
+	if v, err := s.Next(context.Background()); v != 1 || err != nil {
+		t.Errorf("Next = %d, %v", v, err)
+	}
+	for range 2 {
+		if _, err := s.Next(context.Background()); !errors.Is(err, ErrClosed) {
+			t.Errorf("Next after Close = %v", err)
+		}
+	}
+	if _, err := b.Subscribe().Next(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Next on a subscription after Close = %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Broadcast after Close did not panic")
+		}
+	}()
+	b.Broadcast(2)
+}
+
+func TestNextHonoursContext(t *testing.T) {
+	s := New[int]().Subscribe()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := s.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Next = %v", err)
+	}
+}
+
+func TestReady(t *testing.T) {
+	b := New[int]()
+	s := b.Subscribe()
+	select {
+	case <-s.Ready():
+		t.Fatal("ready before a broadcast")
+	default:
+	}
+	b.Broadcast(7)
+	<-s.Ready()
+	s.Next(context.Background())
+	select {
+	case <-s.Ready():
+		t.Error("still ready after Next")
+	default:
+	}
+}
+
+// BenchmarkFanOut sends values to eight subscribers that each wait for
+// every one of them. The cond version cannot promise that, so its
+// broadcaster waits for all subscribers to take a value before sending
+// the next, which is the handshake the Broadcaster does without.
+func BenchmarkFanOut(b *testing.B) {
+	const subscribers = 8
+	b.Run("broadcaster", func(b *testing.B) {
+		br := New[int]()
+		var wg sync.WaitGroup
+		for range subscribers {
+			s := br.Subscribe()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					if _, err := s.Next(context.Background()); err != nil {
+						return
+					}
+				}
+			}()
+		}
+		for i := range b.N {
+			br.Broadcast(i)
+		}
+		br.Close()
+		wg.Wait()
+	})
+	b.Run("cond", func(b *testing.B) {
+		c := newCondBroadcaster()
+		var taken sync.WaitGroup
+		var wg sync.WaitGroup
+		for range subscribers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				seen := 0
+				for {
+					gen, v := c.next(seen)
+					seen = gen
+					taken.Done()
+					if v < 0 {
+						return
+					}
+				}
+			}()
+		}
+		for i := range b.N {
+			taken.Add(subscribers)
+			c.Broadcast(i)
+			taken.Wait()
+		}
+		taken.Add(subscribers)
+		c.Broadcast(-1)
+		wg.Wait()
+	})
+}
+
+func Example() {
+	config := New[string]()
+	s := config.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			v, err := s.Next(context.Background())
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println("reload with", v)
+		}
+	}()
+	config.Broadcast("v1")
+	config.Broadcast("v2")
+	config.Close()
+	<-done
+	// Output:
+	// reload with v1
+	// reload with v2
+	// broadcast: closed
+}
@@ -0,0 +1,148 @@
+// Package cache is a read-through cache that loads each missing key once,
+// however many goroutines miss it at the same time.
+//
+// Reads take the read side of a sync.RWMutex, so hits proceed in
+// parallel. A miss goes through a singleflight.Group: when a popular key
+// expires or the cache starts cold, the first goroutine to miss loads it
+// and the others that miss meanwhile wait for that load instead of
+// stampeding the backend with identical queries. Each waiter still honours
+// its own context; the load itself runs detached from the cancellation of
+// the caller that started it, so that one impatient caller does not fail
+// the others. Errors are returned to every waiter and not cached.
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/crazybber/go-patterns/concurrency/singleflight"
+)
+
+// Loader fetches the value of key from the backend. It is called without
+// the cancellation of the caller's context, and should bound its own work.
+type Loader[V any] func(ctx context.Context, key string) (V, error)
+
+// Stats counts what a Cache has done.
+type Stats struct {
+	Hits, Misses int64
+	// Loads is the number of calls of the Loader; Misses - Loads misses
+	// were served by a load another caller had started.
+	Loads int64
+}
+
+// Cache is a read-through cache of values of type V.
+type Cache[V any] struct {
+	load  Loader[V]
+	group singleflight.Group
+
+	mu    sync.RWMutex
+	items map[string]V
+	// gens[key] changes with every Set and Delete of key while loads of
+	// key are in flight, so that a load that started before one does not
+	// store its older value after it. flights counts those loads; both
+	// entries go once the last one ends, so keys set or deleted with no
+	// load under way cost nothing.
+	gens    map[string]uint64
+	flights map[string]int
+
+	hits, misses, loads atomic.Int64
+}
+
+// New returns an empty Cache that fills itself with load.
+func New[V any](load Loader[V]) *Cache[V] {
+	return &Cache[V]{load: load, items: map[string]V{}, gens: map[string]uint64{}, flights: map[string]int{}}
+}
+
+// Get returns the value of key, loading it on a miss.
+func (c *Cache[V]) Get(ctx context.Context, key string) (V, error) {
+	c.mu.RLock()
+	v, ok := c.items[key]
+	c.mu.RUnlock()
+	if ok {
+		c.hits.Add(1)
+		return v, nil
+	}
+	c.misses.Add(1)
+
+	detached := context.WithoutCancel(ctx)
+	select {
+	case r := <-c.group.DoChan(key, func() (interface{}, error) { return c.fill(detached, key) }):
+		if r.Err != nil {
+			var zero V
+			return zero, r.Err
+		}
+		// Not r.Val.(V), which panics on a nil interface value.
+		v, _ := r.Val.(V)
+		return v, nil
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// fill loads key and stores it, unless another flight stored it between
+// the caller's miss and the start of this one.
+func (c *Cache[V]) fill(ctx context.Context, key string) (V, error) {
+	c.mu.Lock()
+	v, ok := c.items[key]
+	if ok {
+		c.mu.Unlock()
+		return v, nil
+	}
+	c.flights[key]++
+	gen := c.gens[key]
+	c.mu.Unlock()
+
+	c.loads.Add(1)
+	v, err := c.load(ctx, key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil && c.gens[key] == gen {
+		c.items[key] = v
+	}
+	if c.flights[key]--; c.flights[key] == 0 {
+		delete(c.flights, key)
+		delete(c.gens, key)
+	}
+	return v, err
+}
+
+// changed records a Set or Delete of key for the loads in flight. c.mu
+// must be held.
+func (c *Cache[V]) changed(key string) {
+	if c.flights[key] > 0 {
+		c.gens[key]++
+	}
+}
+
+// Set stores v for key, replacing any value and any load in flight.
+func (c *Cache[V]) Set(key string, v V) {
+	c.mu.Lock()
+	c.items[key] = v
+	c.changed(key)
+	c.mu.Unlock()
+}
+
+// Delete drops key, so that the next Get loads it again. A load in
+// flight for key is forgotten rather than stored.
+func (c *Cache[V]) Delete(key string) {
+	c.mu.Lock()
+	delete(c.items, key)
+	c.changed(key)
+	c.mu.Unlock()
+	c.group.Forget(key)
+}
+
+// Len returns the number of cached keys.
+func (c *Cache[V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.items)
+}
+
+// Stats returns the counts so far.
+func (c *Cache[V]) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Loads: c.loads.Load()}
+}
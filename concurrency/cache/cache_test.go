@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// backend is a Loader that counts its calls per key and holds every load
+// until gate is closed.
+type backend struct {
+	gate  chan struct{}
+	mu    sync.Mutex
+	calls map[string]int
+	err   error
+}
+
+func newBackend() *backend { return &backend{gate: make(chan struct{}), calls: map[string]int{}} }
+
+func (b *backend) load(ctx context.Context, key string) (string, error) {
+	b.mu.Lock()
+	b.calls[key]++
+	err := b.err
+	b.mu.Unlock()
+	<-b.gate
+	if err != nil {
+		return "", err
+	}
+	return "value of " + key, nil
+}
+
+func (b *backend) callsOf(key string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls[key]
+}
+
+// getAll calls Get for each key from its own goroutine once all of them
+// have missed, then opens the gate and returns the results.
+func getAll[V any](t *testing.T, c *Cache[V], b *backend, keys ...string) ([]V, []error) {
+	t.Helper()
+	vals, errs := make([]V, len(keys)), make([]error, len(keys))
+	misses := c.Stats().Misses
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vals[i], errs[i] = c.Get(context.Background(), key)
+		}()
+	}
+	for c.Stats().Misses < misses+int64(len(keys)) {
+		time.Sleep(time.Millisecond)
+	}
+	close(b.gate)
+	wg.Wait()
+	return vals, errs
+}
+
+func TestConcurrentMissesLoadOnce(t *testing.T) {
+	b := newBackend()
+	c := New(b.load)
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = "hot"
+	}
+	vals, errs := getAll(t, c, b, keys...)
+	for i := range keys {
+		if vals[i] != "value of hot" || errs[i] != nil {
+			t.Fatalf("Get %d = %q, %v", i, vals[i], errs[i])
+		}
+	}
+	if n := b.callsOf("hot"); n != 1 {
+		t.Errorf("%d loads for 50 concurrent misses", n)
+	}
+	if s := c.Stats(); s.Misses != 50 || s.Loads != 1 || s.Hits != 0 {
+		t.Errorf("stats %+v", s)
+	}
+	if v, _ := c.Get(context.Background(), "hot"); v != "value of hot" || c.Stats().Hits != 1 {
+		t.Errorf("no hit after the load: %q, %+v", v, c.Stats())
+	}
+}
+
+func TestKeysLoadIndependently(t *testing.T) {
+	b := newBackend()
+	c := New(b.load)
+	getAll(t, c, b, "a", "b", "a", "c", "b")
+	for _, key := range []string{"a", "b", "c"} {
+		if n := b.callsOf(key); n != 1 {
+			t.Errorf("%s loaded %d times", key, n)
+		}
+	}
+	if c.Len() != 3 {
+		t.Errorf("Len = %d", c.Len())
+	}
+}
+
+func TestErrorsAreShared(t *testing.T) {
+	b := newBackend()
+	b.err = errors.New("backend down")
+	c := New(b.load)
+	_, errs := getAll(t, c, b, "k", "k", "k")
+	for _, err := range errs {
+		if err != b.err {
+			t.Errorf("Get = %v", err)
+		}
+	}
+	if c.Len() != 0 {
+		t.Error("an error was cached")
+	}
+	// The next Get tries again.
+	b.err = nil
+	if v, err := c.Get(context.Background(), "k"); err != nil || v != "value of k" {
+		t.Errorf("retry = %q, %v", v, err)
+	}
+}
+
+func TestCallerCancelDoesNotFailOthers(t *testing.T) {
+	b := newBackend()
+	c := New(b.load)
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.Get(ctx, "k")
+		first <- err
+	}()
+	for c.Stats().Misses < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan string, 1)
+	go func() {
+		v, _ := c.Get(context.Background(), "k")
+		second <- v
+	}()
+	for c.Stats().Misses < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller got %v", err)
+	}
+	close(b.gate)
+	if v := <-second; v != "value of k" {
+		t.Errorf("other caller got %q", v)
+	}
+}
+
+func TestDeleteDropsLoadInFlight(t *testing.T) {
+	b := newBackend()
+	c := New(b.load)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Get(context.Background(), "k")
+	}()
+	for b.callsOf("k") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Delete("k")
+	close(b.gate)
+	<-done
+	if c.Len() != 0 {
+		t.Error("load that started before Delete was stored")
+	}
+	c.Set("k", "set")
+	if v, _ := c.Get(context.Background(), "k"); v != "set" {
+		t.Errorf("after Set: %q", v)
+	}
+}
+
+// Set and Delete of one key leave the loads of the others alone.
+func TestOtherKeysDoNotDropLoad(t *testing.T) {
+	b := newBackend()
+	c := New(b.load)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Get(context.Background(), "k")
+	}()
+	for b.callsOf("k") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Set("other", "set")
+	c.Delete("another")
+	close(b.gate)
+	<-done
+	if v, _ := c.Get(context.Background(), "k"); v != "value of k" || b.callsOf("k") != 1 {
+		t.Errorf("Get = %q after %d loads", v, b.callsOf("k"))
+	}
+	if len(c.gens) != 0 || len(c.flights) != 0 {
+		t.Errorf("left %v and %v behind", c.gens, c.flights)
+	}
+}
+
+func TestNilInterfaceValue(t *testing.T) {
+	c := New(func(context.Context, string) (error, error) { return nil, nil })
+	if v, err := c.Get(context.Background(), "k"); v != nil || err != nil {
+		t.Errorf("Get = %v, %v", v, err)
+	}
+}
+
+func BenchmarkHit(b *testing.B) {
+	c := New(func(context.Context, string) (int, error) { return 1, nil })
+	ctx := context.Background()
+	c.Get(ctx, "k")
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Get(ctx, "k")
+		}
+	})
+}
+
+func Example() {
+	var queries atomic.Int32
+	profiles := New(func(_ context.Context, user string) (string, error) {
+		queries.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "profile of " + user, nil
+	})
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			profiles.Get(context.Background(), "gopher")
+		}()
+	}
+	wg.Wait()
+	v, _ := profiles.Get(context.Background(), "gopher")
+	fmt.Println(v, "after", queries.Load(), "query")
+	// Output:
+	// profile of gopher after 1 query
+}
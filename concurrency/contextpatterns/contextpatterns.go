@@ -0,0 +1,89 @@
+// Package contextpatterns collects the ways a context.Context is passed
+// down a program: deadlines layered so a callee never gets more time than
+// its caller has left, contexts detached from their caller's cancellation
+// for work that must outlive it, and request-scoped values carried into the
+// tasks of a worker pool.
+//
+// Values are stored under the typed keys of idioms/ctxkeys. They are for
+// request-scoped data that crosses API boundaries, such as request IDs and
+// the authenticated user, not for optional parameters.
+package contextpatterns
+
+import (
+	"context"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/ctxkeys"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// Remaining returns the time left before ctx's deadline, and false if it
+// has none.
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// WithBudget gives a callee at most d, and never more than the caller has
+// left: a child's deadline is already capped by its parent's, and
+// WithBudget is context.WithTimeout named for that use.
+func WithBudget(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, d)
+}
+
+// WithReserve returns a context whose deadline comes reserve before ctx's,
+// keeping that much time back for the caller to handle a callee that runs
+// out of time, such as answering with a fallback. Without a deadline on
+// ctx, the result has none either. A reserve larger than the time left
+// yields a context that is already done.
+func WithReserve(ctx context.Context, reserve time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-reserve))
+}
+
+// Detach returns a context that keeps ctx's values but not its
+// cancellation or deadline, bounded by a timeout of its own, for work that
+// must finish after the request that started it has returned, such as
+// writing an audit record.
+func Detach(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
+}
+
+// Go runs fn on a new goroutine with a detached copy of ctx, and returns
+// a channel that receives its error.
+func Go(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) <-chan error {
+	errc := make(chan error, 1)
+	ctx, cancel := Detach(ctx, timeout)
+	go func() {
+		defer cancel()
+		errc <- fn(ctx)
+	}()
+	return errc
+}
+
+// Inject wraps w so that its Task sees the context it is run with,
+// decorated by each of with in turn. workerpool.Pool passes the caller's
+// context to Task; Inject adds what the caller's context does not carry,
+// for instance values bound to the request a batch of tasks was submitted
+// for.
+func Inject(w workerpool.Worker, with ...func(context.Context) context.Context) workerpool.Worker {
+	return workerpool.WorkerFunc(func(ctx context.Context) error {
+		for _, f := range with {
+			ctx = f(ctx)
+		}
+		return w.Task(ctx)
+	})
+}
+
+// Bind returns a decorator for Inject that stores v under k.
+func Bind[T any](k *ctxkeys.Key[T], v T) func(context.Context) context.Context {
+	return func(ctx context.Context) context.Context {
+		return k.WithValue(ctx, v)
+	}
+}
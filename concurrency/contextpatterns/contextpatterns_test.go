@@ -0,0 +1,152 @@
+package contextpatterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/idioms/ctxkeys"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+var (
+	requestID = ctxkeys.NewKey[string]("request ID")
+	userID    = ctxkeys.NewKey[int]("user ID")
+)
+
+func TestWithBudgetNeverExtends(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	child, cancel := WithBudget(parent, time.Hour)
+	defer cancel()
+	pd, _ := parent.Deadline()
+	cd, _ := child.Deadline()
+	if !cd.Equal(pd) {
+		t.Errorf("child deadline %v, parent %v", cd, pd)
+	}
+	short, cancel := WithBudget(parent, time.Millisecond)
+	defer cancel()
+	if left, _ := Remaining(short); left > time.Millisecond {
+		t.Errorf("Remaining = %v", left)
+	}
+}
+
+func TestWithReserve(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	child, cancel := WithReserve(parent, 10*time.Minute)
+	defer cancel()
+	pd, _ := parent.Deadline()
+	if cd, _ := child.Deadline(); !cd.Equal(pd.Add(-10 * time.Minute)) {
+		t.Errorf("child deadline %v, parent %v", cd, pd)
+	}
+
+	late, cancel := WithReserve(parent, 2*time.Hour)
+	defer cancel()
+	if !errors.Is(late.Err(), context.DeadlineExceeded) {
+		t.Errorf("reserve beyond the deadline: Err = %v", late.Err())
+	}
+
+	open, cancel := WithReserve(context.Background(), time.Minute)
+	defer cancel()
+	if _, ok := open.Deadline(); ok {
+		t.Error("a deadline appeared from nowhere")
+	}
+	if _, ok := Remaining(open); ok {
+		t.Error("Remaining without a deadline")
+	}
+}
+
+func TestDetach(t *testing.T) {
+	leaks.Check(t)
+	parent, cancel := context.WithCancel(requestID.WithValue(context.Background(), "r-2"))
+	started, release := make(chan struct{}), make(chan struct{})
+	errc := Go(parent, time.Second, func(ctx context.Context) error {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if v, _ := requestID.Get(ctx); v != "r-2" {
+			return fmt.Errorf("request ID %q", v)
+		}
+		return nil
+	})
+	<-started
+	cancel()
+	close(release)
+	if err := <-errc; err != nil {
+		t.Errorf("detached work: %v", err)
+	}
+
+	ctx, cancel := Detach(parent, time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("Detach did not keep its own timeout: %v", ctx.Err())
+	}
+}
+
+func TestInjectIntoPool(t *testing.T) {
+	leaks.Check(t)
+	p := workerpool.New(3)
+	defer p.Shutdown()
+	task := workerpool.WorkerFunc(func(ctx context.Context) error {
+		id, ok := requestID.Get(ctx)
+		if !ok {
+			return errors.New("no request ID")
+		}
+		if user := userID.MustGet(ctx); fmt.Sprint("r-", user) != id {
+			return fmt.Errorf("user %d in request %s", user, id)
+		}
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for user := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each request adds its own values to the tasks it submits.
+			ctx := requestID.WithValue(context.Background(), fmt.Sprint("r-", user))
+			if err := p.Run(ctx, Inject(task, Bind(userID, user))); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := p.Run(context.Background(), task); err == nil {
+		t.Error("task ran without a request ID")
+	}
+}
+
+func Example() {
+	traceID := ctxkeys.NewKey[string]("trace ID")
+	ctx := traceID.WithValue(context.Background(), "abc123")
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	// The handler keeps 100ms back to answer with a fallback.
+	call, cancelCall := WithReserve(ctx, 100*time.Millisecond)
+	defer cancelCall()
+	left, _ := Remaining(call)
+	fmt.Println(left < 900*time.Millisecond, traceID.MustGet(call))
+
+	// The audit record is written after the handler returns.
+	cancel()
+	err := <-Go(ctx, time.Second, func(ctx context.Context) error {
+		fmt.Println("audit", traceID.MustGet(ctx), ctx.Err())
+		return nil
+	})
+	fmt.Println(err)
+	// Output:
+	// true abc123
+	// audit abc123 <nil>
+	// <nil>
+}
@@ -0,0 +1,82 @@
+// Package cooperative makes long CPU-bound loops respond to cancellation.
+//
+// A loop that never blocks never looks at its context, so cancelling it
+// does nothing until the loop is done. The fix is cooperative: check
+// ctx.Err() every so many iterations and stop when it is set. Checking is
+// not free, which is what the every parameter trades off: a small value
+// reacts sooner and costs more per iteration. At each check the loop also
+// yields the processor with runtime.Gosched. Since Go 1.14 the scheduler
+// preempts tight loops by itself, but yielding still lets goroutines
+// waiting for the same P run sooner.
+package cooperative
+
+import (
+	"context"
+	"runtime"
+)
+
+// Checkpoint counts iterations of a hand-written loop and checks the
+// context every few of them.
+type Checkpoint struct {
+	ctx   context.Context
+	every int
+	n     int
+}
+
+// NewCheckpoint returns a Checkpoint checking ctx every iterations. every
+// is at least 1.
+func NewCheckpoint(ctx context.Context, every int) *Checkpoint {
+	return &Checkpoint{ctx: ctx, every: max(every, 1)}
+}
+
+// Check is called once per iteration. It returns ctx.Err() at the checks
+// where the context is done, and nil otherwise.
+func (c *Checkpoint) Check() error {
+	c.n++
+	if c.n < c.every {
+		return nil
+	}
+	c.n = 0
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	runtime.Gosched()
+	return nil
+}
+
+// Loop calls body for i from 0 to n-1, checking ctx every iterations. It
+// returns the number of iterations done and ctx.Err() if it stopped early.
+func Loop(ctx context.Context, n, every int, body func(i int)) (int, error) {
+	cp := NewCheckpoint(ctx, every)
+	for i := 0; i < n; i++ {
+		if err := cp.Check(); err != nil {
+			return i, err
+		}
+		body(i)
+	}
+	return n, nil
+}
+
+// CountPrimes counts the primes below n by trial division, a deliberately
+// slow computation that can be cancelled.
+func CountPrimes(ctx context.Context, n, every int) (int, error) {
+	count := 0
+	_, err := Loop(ctx, n, every, func(i int) {
+		if isPrime(i) {
+			count++
+		}
+	})
+	return count, err
+}
+
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for d := 2; d*d <= n; d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+	return true
+}
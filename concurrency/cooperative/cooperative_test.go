@@ -0,0 +1,87 @@
+package cooperative
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func TestLoopCompletes(t *testing.T) {
+	n, err := CountPrimes(context.Background(), 100, 7)
+	if err != nil || n != 25 {
+		t.Errorf("CountPrimes(100) = %d, %v; want 25", n, err)
+	}
+}
+
+func TestLoopStopsAtCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done, err := Loop(ctx, 1000, 10, func(i int) {
+		if i == 42 {
+			cancel()
+		}
+	})
+	// The cancel happens in iteration 42; the next check is before 50.
+	if err != context.Canceled || done != 49 {
+		t.Errorf("Loop = %d, %v; want 49, %v", done, err, context.Canceled)
+	}
+}
+
+// TestCancellationLatency cancels a computation that would run for many
+// seconds and requires it to stop within a bound set by the check
+// interval, not by the length of the computation.
+func TestCancellationLatency(t *testing.T) {
+	const (
+		every = 1000
+		bound = 250 * time.Millisecond
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := CountPrimes(ctx, 1<<40, every)
+		result <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancelled := time.Now()
+	cancel()
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Errorf("err = %v", err)
+		}
+		t.Logf("stopped %v after cancel", time.Since(cancelled))
+	case <-time.After(bound):
+		t.Fatalf("still running %v after cancel", bound)
+	}
+}
+
+// BenchmarkCheckFrequency reports the cost per iteration of checking the
+// context every N iterations, against a loop that never checks.
+func BenchmarkCheckFrequency(b *testing.B) {
+	const n = 1 << 16
+	var sink int
+	body := func(i int) { sink += i * i }
+
+	b.Run("never", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < n; j++ {
+				body(j)
+			}
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/iter")
+	})
+	for _, every := range []int{1, 16, 256, 4096} {
+		b.Run(fmt.Sprintf("every-%d", every), func(b *testing.B) {
+			ctx := context.Background()
+			for i := 0; i < b.N; i++ {
+				Loop(ctx, n, every, body)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/iter")
+		})
+	}
+}
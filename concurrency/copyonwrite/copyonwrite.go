@@ -0,0 +1,134 @@
+// Package copyonwrite shares read-mostly state as immutable snapshots
+// swapped in atomically.
+//
+// Readers of a Value load a pointer and use what it points to without
+// any lock: the snapshot is never modified, only replaced. A writer
+// copies the current snapshot, changes the copy and publishes it with
+// one atomic store; readers that loaded the old one finish with it
+// undisturbed and the collector frees it after them. Writers are
+// serialised by a mutex so that no update is lost.
+//
+// This suits configuration and routing tables, read on every request and
+// changed a few times a day. Reads cost an atomic load however many
+// goroutines make them, where even the read side of a sync.RWMutex
+// writes to a shared counter, which contends across cores. Writes cost a
+// full copy, so the pattern is wrong for state that changes often or is
+// large and changed a little at a time.
+package copyonwrite
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Value holds a snapshot of T. The T values it stores must not be
+// modified once stored, including through maps or slices they share
+// with other snapshots.
+type Value[T any] struct {
+	mu  sync.Mutex
+	cur atomic.Pointer[T]
+}
+
+// NewValue returns a Value holding initial.
+func NewValue[T any](initial T) *Value[T] {
+	v := new(Value[T])
+	v.cur.Store(&initial)
+	return v
+}
+
+// Load returns the current snapshot, which must be treated as read-only.
+// It is nil until the first Store or Update of a zero Value.
+func (v *Value[T]) Load() *T { return v.cur.Load() }
+
+// Store replaces the snapshot.
+func (v *Value[T]) Store(x T) {
+	v.mu.Lock()
+	v.cur.Store(&x)
+	v.mu.Unlock()
+}
+
+// Update replaces the snapshot with what fn returns given the current
+// one, which is nil for a zero Value. fn must copy what it changes
+// rather than modify old. Updates are serialised; loads are not blocked.
+func (v *Value[T]) Update(fn func(old *T) T) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	next := fn(v.cur.Load())
+	v.cur.Store(&next)
+}
+
+// Table maps path prefixes to backends.
+type Table map[string]string
+
+// Router routes request paths by longest matching prefix, reading its
+// table lock-free.
+type Router struct {
+	routes Value[routes]
+}
+
+// routes is a snapshot of a Router: its table and the prefixes, longest
+// first, derived from it once per change rather than once per lookup.
+type routes struct {
+	table    Table
+	prefixes []string
+}
+
+func newRoutes(t Table) routes {
+	prefixes := slices.Collect(maps.Keys(t))
+	slices.SortFunc(prefixes, func(a, b string) int { return len(b) - len(a) })
+	return routes{table: t, prefixes: prefixes}
+}
+
+// clone copies t, never returning nil.
+func clone(t Table) Table {
+	if t == nil {
+		return Table{}
+	}
+	return maps.Clone(t)
+}
+
+// NewRouter returns a Router with a copy of t.
+func NewRouter(t Table) *Router {
+	r := new(Router)
+	r.routes.Store(newRoutes(clone(t)))
+	return r
+}
+
+// Route returns the backend of the longest prefix of path in the table.
+func (r *Router) Route(path string) (backend string, ok bool) {
+	rs := r.routes.Load()
+	for _, p := range rs.prefixes {
+		if strings.HasPrefix(path, p) {
+			return rs.table[p], true
+		}
+	}
+	return "", false
+}
+
+// Set routes prefix to backend from now on.
+func (r *Router) Set(prefix, backend string) {
+	r.routes.Update(func(old *routes) routes {
+		t := clone(old.table)
+		t[prefix] = backend
+		return newRoutes(t)
+	})
+}
+
+// Remove stops routing prefix.
+func (r *Router) Remove(prefix string) {
+	r.routes.Update(func(old *routes) routes {
+		t := clone(old.table)
+		delete(t, prefix)
+		return newRoutes(t)
+	})
+}
+
+// Replace swaps in a copy of t as the whole table at once, so that no
+// request sees half of an old table and half of a new one.
+func (r *Router) Replace(t Table) { r.routes.Store(newRoutes(clone(t))) }
+
+// Table returns a copy of the current table.
+func (r *Router) Table() Table { return maps.Clone(r.routes.Load().table) }
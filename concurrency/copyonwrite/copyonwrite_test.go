@@ -0,0 +1,166 @@
+package copyonwrite
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func TestValue(t *testing.T) {
+	var zero Value[[]int]
+	if zero.Load() != nil {
+		t.Error("zero Value has a snapshot")
+	}
+	zero.Update(func(old *[]int) []int {
+		if old != nil {
+			t.Error("Update of a zero Value got a snapshot")
+		}
+		return []int{1}
+	})
+
+	v := NewValue([]int{1, 2})
+	before := v.Load()
+	v.Update(func(old *[]int) []int { return append(append([]int(nil), *old...), 3) })
+	if len(*before) != 2 || len(*v.Load()) != 3 {
+		t.Errorf("old snapshot %v, new %v", *before, *v.Load())
+	}
+	v.Store(nil)
+	if *v.Load() != nil {
+		t.Error("Store did not replace the snapshot")
+	}
+}
+
+func TestConcurrentUpdatesAreNotLost(t *testing.T) {
+	v := NewValue(0)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 500 {
+				v.Update(func(old *int) int { return *old + 1 })
+				_ = *v.Load()
+			}
+		}()
+	}
+	wg.Wait()
+	if *v.Load() != 4000 {
+		t.Errorf("count %d, want 4000", *v.Load())
+	}
+}
+
+func TestRouter(t *testing.T) {
+	table := Table{"/": "web", "/api/": "api-v1", "/api/v2/": "api-v2"}
+	r := NewRouter(table)
+	table["/"] = "changed"
+	for path, want := range map[string]string{
+		"/index.html":   "web",
+		"/api/users":    "api-v1",
+		"/api/v2/users": "api-v2",
+	} {
+		if got, ok := r.Route(path); got != want || !ok {
+			t.Errorf("Route(%s) = %q, %v, want %q", path, got, ok, want)
+		}
+	}
+
+	r.Remove("/")
+	if _, ok := r.Route("/index.html"); ok {
+		t.Error("removed route still matches")
+	}
+	r.Set("/static/", "cdn")
+	if got, _ := r.Route("/static/app.js"); got != "cdn" {
+		t.Errorf("new route: %q", got)
+	}
+	r.Table()["/static/"] = "changed"
+	if got, _ := r.Route("/static/app.js"); got != "cdn" {
+		t.Error("Table returned the live table")
+	}
+
+	empty := NewRouter(nil)
+	empty.Set("/", "web")
+	if got, _ := empty.Route("/"); got != "web" {
+		t.Errorf("router from nil table: %q", got)
+	}
+}
+
+// TestReplaceIsAtomic checks that a reader never sees a mix of two
+// tables: every table maps both prefixes to the same generation.
+func TestReplaceIsAtomic(t *testing.T) {
+	r := NewRouter(Table{"/a": "0", "/b": "0"})
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			gen := fmt.Sprint(i)
+			r.Replace(Table{"/a": gen, "/b": gen})
+		}
+	}()
+	for range 2000 {
+		if tb := r.Table(); tb["/a"] != tb["/b"] {
+			t.Fatalf("mixed table %v", tb)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+// lockedRouter is Router with its table behind a sync.RWMutex instead.
+type lockedRouter struct {
+	mu     sync.RWMutex
+	routes routes
+}
+
+func (l *lockedRouter) Route(path string) (string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, p := range l.routes.prefixes {
+		if strings.HasPrefix(path, p) {
+			return l.routes.table[p], true
+		}
+	}
+	return "", false
+}
+
+var benchTable = Table{"/": "web", "/api/": "api", "/api/v2/": "api-v2", "/static/": "cdn"}
+
+func BenchmarkRoute(b *testing.B) {
+	b.Run("copyonwrite", func(b *testing.B) {
+		r := NewRouter(benchTable)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				r.Route("/api/v2/users")
+			}
+		})
+	})
+	b.Run("rwmutex", func(b *testing.B) {
+		r := &lockedRouter{routes: newRoutes(benchTable)}
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				r.Route("/api/v2/users")
+			}
+		})
+	})
+}
+
+func ExampleRouter() {
+	r := NewRouter(Table{"/": "web", "/api/": "api-v1"})
+	fmt.Println(r.Route("/api/users"))
+	// Moving the API is one atomic swap; lookups in flight are unaffected.
+	r.Set("/api/", "api-v2")
+	fmt.Println(r.Route("/api/users"))
+	// Output:
+	// api-v1 true
+	// api-v2 true
+}
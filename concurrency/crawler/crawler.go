@@ -0,0 +1,263 @@
+// Package crawler crawls web pages with a bounded number of concurrent
+// fetches, following links breadth first to a depth limit.
+//
+// A coordinator goroutine owns the set of URLs seen and the queue of URLs
+// to fetch, so neither needs a lock: it hands URLs to a fixed set of
+// workers, takes back the links they found, queues the ones it has not
+// seen and sends each page to the caller. Workers wait on a rate limiter
+// per host before fetching, so that a crawl spread over many hosts goes
+// fast without hammering any one of them.
+//
+// Links are found with a regular expression on href attributes, which is
+// enough for this example; a real crawler would use an HTML parser and
+// honour robots.txt.
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+
+	"github.com/crazybber/go-patterns/resiliency/ratelimit"
+)
+
+// Page is a fetched page, or why it could not be fetched.
+type Page struct {
+	URL string
+	// Depth is how many links away from a seed the page is.
+	Depth  int
+	Status int
+	// Links are the absolute URLs the page links to, in order, once
+	// each.
+	Links []string
+	Err   error
+}
+
+// Options tune a crawl.
+type Options struct {
+	// Workers is how many pages are fetched at once, default 8.
+	Workers int
+	// MaxDepth is how many links are followed from a seed, default 2.
+	// A negative depth fetches the seeds alone.
+	MaxDepth int
+	// Limiter, if set, returns the limiter for a host. Each host's
+	// limiter is made once, on its first fetch.
+	Limiter func(host string) ratelimit.Limiter
+	// Follow decides which links are crawled. It defaults to links to the
+	// hosts of the seeds.
+	Follow func(u *url.URL) bool
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// MaxBody bounds the bytes read of a page, default 1MiB.
+	MaxBody int64
+}
+
+func (o *Options) defaults(seeds []*url.URL) {
+	if o.Workers <= 0 {
+		o.Workers = 8
+	}
+	if o.MaxDepth == 0 {
+		o.MaxDepth = 2
+	}
+	if o.Follow == nil {
+		hosts := map[string]bool{}
+		for _, u := range seeds {
+			hosts[u.Host] = true
+		}
+		o.Follow = func(u *url.URL) bool { return hosts[u.Host] }
+	}
+	if o.Client == nil {
+		o.Client = http.DefaultClient
+	}
+	if o.MaxBody <= 0 {
+		o.MaxBody = 1 << 20
+	}
+}
+
+// Crawl crawls from seeds and sends every page fetched on the returned
+// channel, which is closed when there is nothing left to fetch or ctx is
+// done. Seeds that do not parse as absolute URLs come out as pages with
+// an error.
+func Crawl(ctx context.Context, seeds []string, opts Options) <-chan Page {
+	c := &crawler{ctx: ctx, limiters: map[string]ratelimit.Limiter{}}
+	var bad []Page
+	var start []*url.URL
+	for _, s := range seeds {
+		u, err := url.Parse(s)
+		if err == nil && !u.IsAbs() {
+			err = fmt.Errorf("crawler: %q is not an absolute URL", s)
+		}
+		if err != nil {
+			bad = append(bad, Page{URL: s, Err: err})
+			continue
+		}
+		start = append(start, clean(u))
+	}
+	opts.defaults(start)
+	c.opts = opts
+	out := make(chan Page)
+	go c.coordinate(start, bad, out)
+	return out
+}
+
+type crawler struct {
+	ctx  context.Context
+	opts Options
+
+	mu       sync.Mutex
+	limiters map[string]ratelimit.Limiter
+}
+
+type job struct {
+	u     *url.URL
+	depth int
+}
+
+func (c *crawler) coordinate(seeds []*url.URL, bad []Page, out chan<- Page) {
+	defer close(out)
+	jobs := make(chan job)
+	results := make(chan Page)
+	var wg sync.WaitGroup
+	for range c.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				p := c.fetch(j)
+				select {
+				case results <- p:
+				case <-c.ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(jobs)
+
+	seen := map[string]bool{}
+	var queue []job
+	for _, u := range seeds {
+		if !seen[u.String()] {
+			seen[u.String()] = true
+			queue = append(queue, job{u, 0})
+		}
+	}
+	pages := bad
+	fetching := 0
+	for len(queue) > 0 || fetching > 0 || len(pages) > 0 {
+		// Sends are enabled only when there is something to send.
+		var jobsC chan<- job
+		var next job
+		if len(queue) > 0 {
+			jobsC, next = jobs, queue[0]
+		}
+		var outC chan<- Page
+		var page Page
+		if len(pages) > 0 {
+			outC, page = out, pages[0]
+		}
+		select {
+		case jobsC <- next:
+			queue = queue[1:]
+			fetching++
+		case p := <-results:
+			fetching--
+			pages = append(pages, p)
+			if p.Depth >= c.opts.MaxDepth {
+				continue
+			}
+			for _, link := range p.Links {
+				u, _ := url.Parse(link)
+				if !seen[link] && c.opts.Follow(u) {
+					seen[link] = true
+					queue = append(queue, job{u, p.Depth + 1})
+				}
+			}
+		case outC <- page:
+			pages = pages[1:]
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *crawler) limiter(host string) ratelimit.Limiter {
+	if c.opts.Limiter == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.limiters[host]
+	if !ok {
+		l = c.opts.Limiter(host)
+		c.limiters[host] = l
+	}
+	return l
+}
+
+func (c *crawler) fetch(j job) Page {
+	p := Page{URL: j.u.String(), Depth: j.depth}
+	if l := c.limiter(j.u.Host); l != nil {
+		if p.Err = l.Wait(c.ctx); p.Err != nil {
+			return p
+		}
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		p.Err = err
+		return p
+	}
+	resp, err := c.opts.Client.Do(req)
+	if err != nil {
+		p.Err = err
+		return p
+	}
+	defer resp.Body.Close()
+	p.Status = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		p.Err = fmt.Errorf("crawler: %s: %s", p.URL, resp.Status)
+		return p
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.opts.MaxBody))
+	if err != nil {
+		p.Err = err
+		return p
+	}
+	p.Links = links(resp.Request.URL, body)
+	return p
+}
+
+var href = regexp.MustCompile(`(?i)\bhref\s*=\s*["']([^"'#]*)(#[^"']*)?["']`)
+
+// links returns the http and https links of a page at base, made
+// absolute and without fragments, once each.
+func links(base *url.URL, body []byte) []string {
+	var res []string
+	seen := map[string]bool{}
+	for _, m := range href.FindAllSubmatch(body, -1) {
+		u, err := base.Parse(string(m[1]))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if s := clean(u).String(); !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// clean drops the fragment of u and gives it a path, so that one page
+// has one URL.
+func clean(u *url.URL) *url.URL {
+	u.Fragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u
+}
@@ -0,0 +1,175 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/resiliency/ratelimit"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// site serves a small link graph and counts the requests for each page
+// and the most served at once.
+type site struct {
+	*httptest.Server
+	delay time.Duration
+
+	mu      sync.Mutex
+	hits    map[string]int
+	serving atomic.Int64
+	peak    atomic.Int64
+}
+
+var graph = map[string]string{
+	"/":       `<a href="/a">a</a> <a href='b'>b</a> <a href="/#top">top</a> <a href="http://elsewhere.test/">x</a>`,
+	"/a":      `<a href="/a/deep">deep</a> <a href="/">home</a> <a href="/missing">?</a> <a href="mailto:me@x.test">mail</a>`,
+	"/b":      `<A HREF="/a#section">a again</A> <a href="/c">c</a>`,
+	"/c":      `<a href="/c/d">d</a>`,
+	"/a/deep": `<a href="/a/deeper">deeper</a>`,
+	"/c/d":    `no links`,
+}
+
+func newSite(t *testing.T, delay time.Duration) *site {
+	s := &site{hits: map[string]int{}, delay: delay}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := s.serving.Add(1)
+		defer s.serving.Add(-1)
+		for p := s.peak.Load(); n > p && !s.peak.CompareAndSwap(p, n); p = s.peak.Load() {
+		}
+		s.mu.Lock()
+		s.hits[r.URL.Path]++
+		s.mu.Unlock()
+		time.Sleep(s.delay)
+		body, ok := graph[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<html><body>", body, "</body></html>")
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// crawl returns "path depth status" for every page, sorted.
+func crawl(t *testing.T, s *site, opts Options) []string {
+	var got []string
+	for p := range Crawl(context.Background(), []string{s.URL + "/"}, opts) {
+		got = append(got, fmt.Sprint(strings.TrimPrefix(p.URL, s.URL), " ", p.Depth, " ", p.Status))
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestCrawl(t *testing.T) {
+	leaks.Check(t)
+	s := newSite(t, 0)
+	got := crawl(t, s, Options{MaxDepth: 2})
+	want := []string{"/ 0 200", "/a 1 200", "/a/deep 2 200", "/b 1 200", "/c 2 200", "/missing 2 404"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	for path, n := range s.hits {
+		if n != 1 {
+			t.Errorf("%s fetched %d times", path, n)
+		}
+	}
+
+	got = crawl(t, newSite(t, 0), Options{MaxDepth: -1})
+	if fmt.Sprint(got) != "[/ 0 200]" {
+		t.Errorf("seeds only: %q", got)
+	}
+	got = crawl(t, newSite(t, 0), Options{MaxDepth: 10})
+	want = []string{"/ 0 200", "/a 1 200", "/a/deep 2 200", "/a/deeper 3 404", "/b 1 200", "/c 2 200", "/c/d 3 200", "/missing 2 404"}
+	if !slices.Equal(got, want) {
+		t.Errorf("whole site: %q", got)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	base, _ := url.Parse("http://x.test/dir/page")
+	got := links(base, []byte(`<a href="other">1</a> <a href = '/root#f'>2</a> <a href="other#g">3</a>
+		<a href="https://y.test/">4</a> <a href="javascript:void(0)">5</a> <link href="style.css">`))
+	want := []string{"http://x.test/dir/other", "http://x.test/root", "https://y.test/", "http://x.test/dir/style.css"}
+	if !slices.Equal(got, want) {
+		t.Errorf("links %q", got)
+	}
+}
+
+func TestBounded(t *testing.T) {
+	leaks.Check(t)
+	s := newSite(t, 5*time.Millisecond)
+	crawl(t, s, Options{Workers: 2, MaxDepth: 10})
+	if p := s.peak.Load(); p != 2 {
+		t.Errorf("%d requests served at once", p)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	leaks.Check(t)
+	s := newSite(t, 0)
+	var made []string
+	start := time.Now()
+	got := crawl(t, s, Options{MaxDepth: 10, Limiter: func(host string) ratelimit.Limiter {
+		made = append(made, host)
+		return ratelimit.NewTokenBucket(200, 1)
+	}})
+	// Eight pages from one host at 200 a second, the first one free.
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("%d pages in %v", len(got), elapsed)
+	}
+	if len(made) != 1 || made[0] != strings.TrimPrefix(s.URL, "http://") {
+		t.Errorf("limiters made for %q", made)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	leaks.Check(t)
+	s := newSite(t, 20*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	pages := Crawl(ctx, []string{s.URL + "/"}, Options{MaxDepth: 10})
+	<-pages
+	cancel()
+	for range pages {
+	}
+}
+
+func TestBadSeeds(t *testing.T) {
+	leaks.Check(t)
+	var errs []string
+	for p := range Crawl(context.Background(), []string{"relative/path", "http://%zz"}, Options{}) {
+		errs = append(errs, fmt.Sprint(p.Err != nil))
+	}
+	if fmt.Sprint(errs) != "[true true]" {
+		t.Errorf("errors %v", errs)
+	}
+}
+
+func ExampleCrawl() {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/about">about</a>`)
+		case "/about":
+			fmt.Fprint(w, `<a href="/">home</a>`)
+		}
+	}))
+	defer site.Close()
+	for p := range Crawl(context.Background(), []string{site.URL}, Options{Workers: 1}) {
+		fmt.Println(strings.TrimPrefix(p.URL, site.URL), p.Depth, len(p.Links))
+	}
+	// Output:
+	// / 0 1
+	// /about 1 1
+}
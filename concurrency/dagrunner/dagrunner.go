@@ -0,0 +1,241 @@
+// Package dagrunner runs jobs that depend on each other, such as the steps
+// of a build or a data pipeline. A job starts as soon as every job it
+// depends on has succeeded, so independent branches of the graph run in
+// parallel, up to an optional bound.
+//
+// What a failure does depends on the Policy. FailFast cancels the context
+// of the running jobs and starts no more. ContinueOnError keeps running
+// every job that does not depend on the failed one, and skips those that
+// do, which is what a build does to report as many errors as it can in one
+// go.
+//
+// The graph is checked before anything runs: a dependency on a job that
+// does not exist, and a cycle, which would leave its jobs waiting for each
+// other forever, are reported as errors.
+package dagrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// Errors of Run.
+var (
+	ErrDuplicate = errors.New("dagrunner: job defined twice")
+	ErrUnknown   = errors.New("dagrunner: unknown dependency")
+	ErrCycle     = errors.New("dagrunner: dependency cycle")
+	// ErrSkipped is the result of a job that did not run because a job it
+	// depends on failed or, under FailFast, because another job failed.
+	ErrSkipped = errors.New("dagrunner: skipped")
+)
+
+// Job is a named unit of work that runs after the jobs named in Deps have
+// succeeded. A panic in Run counts as a failure.
+type Job struct {
+	Name string
+	Deps []string
+	Run  func(ctx context.Context) error
+}
+
+// Policy says what a failure does to the rest of the graph.
+type Policy int
+
+const (
+	// FailFast cancels the running jobs and skips the others.
+	FailFast Policy = iota
+	// ContinueOnError skips only the jobs that depend on the failed one.
+	ContinueOnError
+)
+
+// Options configure Run.
+type Options struct {
+	Policy Policy
+	// MaxParallel bounds the number of jobs running at once; zero means no
+	// bound.
+	MaxParallel int
+}
+
+// Results holds the outcome of every job by name: nil for a success, the
+// job's error for a failure, and an error wrapping ErrSkipped for a job
+// that did not run.
+type Results map[string]error
+
+type node struct {
+	job        Job
+	dependents []int
+	waiting    int // dependencies that have not succeeded yet
+}
+
+// graph indexes jobs and checks that they form a DAG.
+func graph(jobs []Job) ([]*node, error) {
+	index := make(map[string]int, len(jobs))
+	nodes := make([]*node, len(jobs))
+	for i, j := range jobs {
+		if _, ok := index[j.Name]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicate, j.Name)
+		}
+		index[j.Name] = i
+		nodes[i] = &node{job: j, waiting: len(j.Deps)}
+	}
+	for i, n := range nodes {
+		for _, dep := range n.job.Deps {
+			d, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s needs %s", ErrUnknown, n.job.Name, dep)
+			}
+			nodes[d].dependents = append(nodes[d].dependents, i)
+		}
+	}
+
+	// Depth-first search; a dependency found on the current path closes
+	// a cycle.
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make([]int, len(nodes))
+	var path []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case onPath:
+			start := 0
+			for path[start] != nodes[i].job.Name {
+				start++
+			}
+			return fmt.Errorf("%w: %s", ErrCycle, strings.Join(append(path[start:], nodes[i].job.Name), " -> "))
+		case done:
+			return nil
+		}
+		state[i] = onPath
+		path = append(path, nodes[i].job.Name)
+		for _, dep := range nodes[i].job.Deps {
+			if err := visit(index[dep]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = done
+		return nil
+	}
+	for i := range nodes {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+type outcome struct {
+	i   int
+	err error
+}
+
+// Run runs the jobs and returns the outcome of each. The error is nil if
+// every job succeeded. Otherwise it is the first failure under FailFast,
+// and all the failures, in the order of jobs, under ContinueOnError; in
+// both cases each failure names its job. An invalid graph is reported
+// before any job runs, with nil Results. If ctx is done, no more jobs
+// start, and Run returns ctx.Err() once the running ones have returned.
+func Run(ctx context.Context, opts Options, jobs ...Job) (Results, error) {
+	nodes, err := graph(jobs)
+	if err != nil {
+		return nil, err
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := ctx.Done()
+
+	results := make(Results, len(nodes))
+	var ready []int
+	for i, n := range nodes {
+		if n.waiting == 0 {
+			ready = append(ready, i)
+		}
+	}
+	outcomes := make(chan outcome)
+	running := 0
+	var first error
+	stopped := false
+
+	// skip marks the jobs that depend on i, directly or not, as skipped
+	// because failed failed.
+	var skip func(i int, failed string)
+	skip = func(i int, failed string) {
+		for _, d := range nodes[i].dependents {
+			if _, ok := results[nodes[d].job.Name]; !ok {
+				results[nodes[d].job.Name] = fmt.Errorf("%w: %s failed", ErrSkipped, failed)
+				skip(d, failed)
+			}
+		}
+	}
+
+	for {
+		for !stopped && len(ready) > 0 && (opts.MaxParallel <= 0 || running < opts.MaxParallel) {
+			i := ready[0]
+			ready = ready[1:]
+			running++
+			go func() {
+				err := recovery.Do(func() error { return nodes[i].job.Run(ctx) })
+				outcomes <- outcome{i, err}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+		select {
+		case o := <-outcomes:
+			running--
+			name := nodes[o.i].job.Name
+			results[name] = o.err
+			if o.err == nil {
+				for _, d := range nodes[o.i].dependents {
+					if nodes[d].waiting--; nodes[d].waiting == 0 {
+						ready = append(ready, d)
+					}
+				}
+				continue
+			}
+			if first == nil {
+				first = fmt.Errorf("%s: %w", name, o.err)
+			}
+			skip(o.i, name)
+			if opts.Policy == FailFast {
+				stopped = true
+				cancel()
+			}
+		case <-done:
+			// Start no more jobs, but collect the running ones.
+			stopped = true
+			done = nil
+		}
+	}
+
+	var failures []error
+	for _, n := range nodes {
+		err, ok := results[n.job.Name]
+		if !ok {
+			results[n.job.Name] = fmt.Errorf("%w: not started", ErrSkipped)
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrSkipped) {
+			failures = append(failures, fmt.Errorf("%s: %w", n.job.Name, err))
+		}
+	}
+	switch {
+	case parent.Err() != nil:
+		return results, parent.Err()
+	case len(failures) == 0:
+		return results, nil
+	case opts.Policy == FailFast:
+		return results, first
+	default:
+		return results, errors.Join(failures...)
+	}
+}
@@ -0,0 +1,235 @@
+package dagrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+var errBroken = errors.New("broken")
+
+// journal records the order in which jobs start and finish.
+type journal struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (j *journal) add(event string) {
+	j.mu.Lock()
+	j.events = append(j.events, event)
+	j.mu.Unlock()
+}
+
+func (j *journal) index(event string) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, e := range j.events {
+		if e == event {
+			return i
+		}
+	}
+	return -1
+}
+
+// job returns a job that journals its start and end and returns err.
+func (j *journal) job(name string, err error, deps ...string) Job {
+	return Job{Name: name, Deps: deps, Run: func(ctx context.Context) error {
+		j.add("start " + name)
+		defer j.add("end " + name)
+		return err
+	}}
+}
+
+// diamond is a -> b, c -> d.
+func diamond(j *journal, b, c Job) []Job {
+	return []Job{j.job("a", nil), b, c, j.job("d", nil, "b", "c")}
+}
+
+func TestDiamondOrder(t *testing.T) {
+	leaks.Check(t)
+	j := &journal{}
+	// b and c only finish once both have started, so they must run in
+	// parallel.
+	var started sync.WaitGroup
+	started.Add(2)
+	meet := func(name string) Job {
+		return Job{Name: name, Deps: []string{"a"}, Run: func(ctx context.Context) error {
+			j.add("start " + name)
+			started.Done()
+			started.Wait()
+			j.add("end " + name)
+			return nil
+		}}
+	}
+	results, err := Run(context.Background(), Options{}, diamond(j, meet("b"), meet("c"))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, err := range results {
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, before := range [][2]string{{"end a", "start b"}, {"end a", "start c"}, {"end b", "start d"}, {"end c", "start d"}} {
+		if j.index(before[0]) > j.index(before[1]) {
+			t.Errorf("%s after %s: %v", before[0], before[1], j.events)
+		}
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	leaks.Check(t)
+	j := &journal{}
+	slow := Job{Name: "c", Deps: []string{"a"}, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	jobs := append(diamond(j, j.job("b", errBroken, "a"), slow), j.job("e", nil, "c"))
+	results, err := Run(context.Background(), Options{Policy: FailFast}, jobs...)
+	if !errors.Is(err, errBroken) || err.Error() != "b: broken" {
+		t.Errorf("Run = %v", err)
+	}
+	if !errors.Is(results["c"], context.Canceled) {
+		t.Errorf("c = %v, want cancelled", results["c"])
+	}
+	for _, name := range []string{"d", "e"} {
+		if !errors.Is(results[name], ErrSkipped) {
+			t.Errorf("%s = %v, want skipped", name, results[name])
+		}
+	}
+}
+
+func TestContinueOnError(t *testing.T) {
+	leaks.Check(t)
+	j := &journal{}
+	jobs := append(diamond(j, j.job("b", errBroken, "a"), j.job("c", nil, "a")),
+		j.job("e", nil, "c"),
+		j.job("f", errors.New("lint"), "a"),
+		j.job("g", nil, "d"),
+	)
+	results, err := Run(context.Background(), Options{Policy: ContinueOnError}, jobs...)
+	if got := fmt.Sprint(err); got != "b: broken\nf: lint" {
+		t.Errorf("Run = %q", got)
+	}
+	var got []string
+	for name, err := range results {
+		got = append(got, fmt.Sprintf("%s=%v", name, err))
+	}
+	sort.Strings(got)
+	want := "[a=<nil> b=broken c=<nil> d=dagrunner: skipped: b failed e=<nil> f=lint g=dagrunner: skipped: b failed]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("results %v\nwant    %s", got, want)
+	}
+}
+
+func TestPanicIsAFailure(t *testing.T) {
+	leaks.Check(t)
+	_, err := Run(context.Background(), Options{}, Job{Name: "x", Run: func(context.Context) error { panic("bug") }})
+	var p *recovery.PanicError
+	if !errors.As(err, &p) {
+		t.Errorf("Run = %v", err)
+	}
+}
+
+func TestMaxParallel(t *testing.T) {
+	leaks.Check(t)
+	var running, peak atomic.Int32
+	var jobs []Job
+	for i := range 8 {
+		jobs = append(jobs, Job{Name: fmt.Sprint(i), Run: func(context.Context) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			return nil
+		}})
+	}
+	if _, err := Run(context.Background(), Options{MaxParallel: 3}, jobs...); err != nil {
+		t.Fatal(err)
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("%d jobs ran at once", p)
+	}
+}
+
+func TestInvalidGraphs(t *testing.T) {
+	run := func(context.Context) error { t.Error("a job of an invalid graph ran"); return nil }
+	for _, tc := range []struct {
+		name string
+		jobs []Job
+		want error
+		msg  string
+	}{
+		{"duplicate", []Job{{Name: "a", Run: run}, {Name: "a", Run: run}}, ErrDuplicate, "a"},
+		{"unknown", []Job{{Name: "a", Deps: []string{"z"}, Run: run}}, ErrUnknown, "a needs z"},
+		{"self", []Job{{Name: "a", Deps: []string{"a"}, Run: run}}, ErrCycle, "a -> a"},
+		{"cycle", []Job{
+			{Name: "root", Run: run},
+			{Name: "a", Deps: []string{"root", "c"}, Run: run},
+			{Name: "b", Deps: []string{"a"}, Run: run},
+			{Name: "c", Deps: []string{"b"}, Run: run},
+		}, ErrCycle, "a -> c -> b -> a"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := Run(context.Background(), Options{}, tc.jobs...)
+			if !errors.Is(err, tc.want) || !strings.HasSuffix(err.Error(), tc.msg) || results != nil {
+				t.Errorf("Run = %v, %v", results, err)
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	leaks.Check(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &journal{}
+	first := Job{Name: "first", Run: func(context.Context) error {
+		cancel()
+		return nil
+	}}
+	results, err := Run(ctx, Options{}, first, j.job("second", nil, "first"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v", err)
+	}
+	if results["first"] != nil || !errors.Is(results["second"], ErrSkipped) {
+		t.Errorf("results %v", results)
+	}
+}
+
+func Example() {
+	step := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			fmt.Println(name)
+			return nil
+		}
+	}
+	_, err := Run(context.Background(), Options{MaxParallel: 1},
+		Job{Name: "deploy", Deps: []string{"test", "build"}, Run: step("deploy")},
+		Job{Name: "build", Deps: []string{"fetch"}, Run: step("build")},
+		Job{Name: "test", Deps: []string{"build"}, Run: step("test")},
+		Job{Name: "fetch", Run: step("fetch")},
+	)
+	fmt.Println(err)
+	// Output:
+	// fetch
+	// build
+	// test
+	// deploy
+	// <nil>
+}
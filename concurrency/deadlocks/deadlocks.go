@@ -0,0 +1,284 @@
+// Package deadlocks shows the usual deadlocks, each next to a version
+// without one, with tests that fail within a second rather than hang when
+// the fixed versions get one back.
+//
+// A deadlock is goroutines each waiting for another of them to move, so
+// that none does. The runtime reports one only when every goroutine of
+// the program is stuck, "all goroutines are asleep"; in a server, where
+// some goroutine is always waiting on the network, a deadlock is silent:
+// requests hang and goroutines pile up. Three shapes come up again and
+// again:
+//
+//   - lock ordering, TransferDeadlocking: two goroutines take the same two
+//     locks in opposite orders, and each gets the one the other wants
+//     next;
+//   - a channel waiting on itself, DeadlockingMailbox: the goroutine that
+//     receives from an unbuffered channel sends on it, and there is nobody
+//     else to receive;
+//   - WaitGroup misuse, GatherDeadlocking and DeadlockingPool: Wait is
+//     called before what the goroutines need to return, draining their
+//     results or closing their input, which only happens after Wait.
+//
+// Like races, the broken versions work as long as nothing provokes them,
+// which is how they get past review. Unlike races there is no detector
+// to run: the tests bound every wait with a timeout instead, and run the
+// broken versions in a child process that a deadlock cannot hang.
+package deadlocks
+
+import "sync"
+
+// Account is a bank account for the transfer examples.
+type Account struct {
+	ID      int
+	mu      sync.Mutex
+	balance int
+}
+
+// NewAccount returns an account holding balance. IDs order the locks in
+// Transfer, so accounts must not share one.
+func NewAccount(id, balance int) *Account {
+	return &Account{ID: id, balance: balance}
+}
+
+// Balance returns what the account holds.
+func (a *Account) Balance() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.balance
+}
+
+// move debits from and credits to, both locked, if from holds amount.
+func move(from, to *Account, amount int) bool {
+	if from.balance < amount {
+		return false
+	}
+	from.balance -= amount
+	to.balance += amount
+	return true
+}
+
+// TransferDeadlocking moves amount from one account to the other if the
+// first holds that much, holding both locks so that no one sees the money
+// in neither or both. It locks from, then to: a transfer from a to b and
+// one from b to a at the same time can each lock their first account and
+// wait forever for their second. Do not use it: it is here to show the
+// deadlock.
+func TransferDeadlocking(from, to *Account, amount int) bool {
+	from.mu.Lock()
+	defer from.mu.Unlock()
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	return move(from, to, amount)
+}
+
+// Transfer is TransferDeadlocking with the locks taken in the order of the
+// account IDs whichever way the money goes, so that every goroutine
+// waiting for a lock holds only lower ones and the goroutine holding the
+// highest can always finish. A transfer from an account to itself is
+// refused: a sync.Mutex is not reentrant, and locking it twice is a
+// deadlock of one goroutine.
+func Transfer(from, to *Account, amount int) bool {
+	if from == to {
+		return false
+	}
+	first, second := from, to
+	if second.ID < first.ID {
+		first, second = second, first
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+	return move(from, to, amount)
+}
+
+// Handler handles a message on a mailbox's goroutine. It may post further
+// messages to the mailbox with post.
+type Handler func(msg string, post func(msg string))
+
+// DeadlockingMailbox handles the messages posted to it one at a time on a
+// goroutine of its own, which receives them from an unbuffered channel.
+// A handler that posts a message, a retry or a follow-up, sends on that
+// channel from the only goroutine that receives from it, and waits for
+// itself forever. Do not use it: it is here to show the deadlock.
+type DeadlockingMailbox struct {
+	inbox chan string
+	done  chan struct{}
+}
+
+// NewDeadlockingMailbox starts a mailbox handling messages with h.
+func NewDeadlockingMailbox(h Handler) *DeadlockingMailbox {
+	m := &DeadlockingMailbox{inbox: make(chan string), done: make(chan struct{})}
+	go func() {
+		defer close(m.done)
+		for msg := range m.inbox {
+			h(msg, m.Post)
+		}
+	}()
+	return m
+}
+
+// Post hands msg to the mailbox's goroutine, waiting until it takes it.
+func (m *DeadlockingMailbox) Post(msg string) { m.inbox <- msg }
+
+// Close waits for the messages posted to be handled and stops the
+// mailbox. Posting after Close panics.
+func (m *DeadlockingMailbox) Close() {
+	close(m.inbox)
+	<-m.done
+}
+
+// Mailbox is DeadlockingMailbox giving its handler a post that queues the
+// message on the mailbox's goroutine, to be handled before the next one
+// from outside is taken, instead of sending it on the channel. A buffered
+// channel would only move the deadlock to the post that finds it full.
+type Mailbox struct {
+	inbox chan string
+	done  chan struct{}
+}
+
+// NewMailbox starts a mailbox handling messages with h.
+func NewMailbox(h Handler) *Mailbox {
+	m := &Mailbox{inbox: make(chan string), done: make(chan struct{})}
+	go func() {
+		defer close(m.done)
+		var pending []string
+		post := func(msg string) { pending = append(pending, msg) }
+		for msg := range m.inbox {
+			for pending = append(pending, msg); len(pending) > 0; {
+				msg, pending = pending[0], pending[1:]
+				h(msg, post)
+			}
+		}
+	}()
+	return m
+}
+
+// Post hands msg to the mailbox's goroutine, waiting until it takes it.
+// Handlers must use the post they are given instead.
+func (m *Mailbox) Post(msg string) { m.inbox <- msg }
+
+// Close waits for the messages posted to be handled and stops the
+// mailbox. Posting after Close panics.
+func (m *Mailbox) Close() {
+	close(m.inbox)
+	<-m.done
+}
+
+// GatherDeadlocking calls fn on every input, each on a goroutine of its
+// own, and returns the results in no particular order. It waits for the
+// goroutines before it receives their results, but a goroutine sending on
+// the unbuffered channel cannot return until its result is received, so
+// Wait never returns. Do not use it: it is here to show the deadlock.
+func GatherDeadlocking(inputs []int, fn func(int) int) []int {
+	results := make(chan int)
+	var wg sync.WaitGroup
+	for _, in := range inputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- fn(in)
+		}()
+	}
+	wg.Wait()
+	close(results)
+	var out []int
+	for r := range results {
+		out = append(out, r)
+	}
+	return out
+}
+
+// Gather is GatherDeadlocking with Wait and the close moved onto a
+// goroutine of their own, so that the caller receives while the
+// goroutines send and the range ends once the last of them has returned.
+// A channel buffered for every result would work too, at the cost of
+// holding them all at once.
+func Gather(inputs []int, fn func(int) int) []int {
+	results := make(chan int)
+	var wg sync.WaitGroup
+	for _, in := range inputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- fn(in)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	var out []int
+	for r := range results {
+		out = append(out, r)
+	}
+	return out
+}
+
+// DeadlockingPool runs tasks on a fixed number of goroutines ranging over
+// a channel. Its Shutdown waits for the goroutines and then closes the
+// channel, but they only return when the channel is closed. Do not use
+// it: it is here to show the deadlock.
+type DeadlockingPool struct {
+	work chan func()
+	wg   sync.WaitGroup
+}
+
+// NewDeadlockingPool starts a pool of n goroutines.
+func NewDeadlockingPool(n int) *DeadlockingPool {
+	p := &DeadlockingPool{work: make(chan func())}
+	p.wg.Add(n)
+	for range n {
+		go func() {
+			defer p.wg.Done()
+			for task := range p.work {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+// Run hands task to a pool goroutine, waiting until one takes it.
+func (p *DeadlockingPool) Run(task func()) { p.work <- task }
+
+// Shutdown waits for the goroutines before closing their channel.
+func (p *DeadlockingPool) Shutdown() {
+	p.wg.Wait()
+	close(p.work)
+}
+
+// Pool is DeadlockingPool with Shutdown closing the channel first, which
+// lets the goroutines finish their tasks and leave the range, and then
+// waiting for them, the order patterns/workerpool keeps too. Calling
+// Shutdown from a task deadlocks in either order: Wait would wait for
+// the goroutine calling it.
+type Pool struct {
+	work chan func()
+	wg   sync.WaitGroup
+}
+
+// NewPool starts a pool of n goroutines.
+func NewPool(n int) *Pool {
+	p := &Pool{work: make(chan func())}
+	p.wg.Add(n)
+	for range n {
+		go func() {
+			defer p.wg.Done()
+			for task := range p.work {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+// Run hands task to a pool goroutine, waiting until one takes it.
+func (p *Pool) Run(task func()) { p.work <- task }
+
+// Shutdown stops the pool and waits for the tasks running to finish.
+// Running a task after Shutdown panics.
+func (p *Pool) Shutdown() {
+	close(p.work)
+	p.wg.Wait()
+}
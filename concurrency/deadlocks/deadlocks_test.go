@@ -0,0 +1,246 @@
+package deadlocks
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+// envHelper makes the test binary run the deadlocking program it names
+// instead of the tests.
+const envHelper = "DEADLOCKS_TEST_HELPER"
+
+// timeout bounds every wait in these tests. The programs here take
+// milliseconds; one that takes a second is stuck.
+const timeout = time.Second
+
+// deadlocking are programs that provoke the broken versions. They call
+// step whenever they make progress.
+var deadlocking = map[string]func(step func()){
+	"lock ordering": func(step func()) {
+		// Not every interleaving of two transfers deadlocks, so they go
+		// on until one does: on one core it takes a preemption between
+		// the two locks, which may take a few hundred thousand transfers.
+		a, b := NewAccount(1, 1000), NewAccount(2, 1000)
+		var wg sync.WaitGroup
+		for _, pair := range [][2]*Account{{a, b}, {b, a}} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					TransferDeadlocking(pair[0], pair[1], 1)
+					step()
+				}
+			}()
+		}
+		wg.Wait()
+	},
+	"channel waiting on itself": func(func()) {
+		m := NewDeadlockingMailbox(func(msg string, post func(string)) {
+			if msg == "ping" {
+				post("pong")
+			}
+		})
+		// The second post finds the mailbox's goroutine still posting
+		// the first one's pong.
+		m.Post("ping")
+		m.Post("ping")
+	},
+	"wait before draining": func(func()) {
+		GatherDeadlocking([]int{1, 2, 3}, square)
+	},
+	"wait before closing": func(func()) {
+		p := NewDeadlockingPool(2)
+		p.Run(func() {})
+		p.Shutdown()
+	},
+}
+
+func square(n int) int { return n * n }
+
+// helper runs the deadlocking program name and prints whether it
+// finished, stopped making progress, the sign of a deadlock, or was still
+// going after 10s.
+func helper(name string) {
+	var steps atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		deadlocking[name](func() { steps.Add(1) })
+		close(done)
+	}()
+	tick := time.NewTicker(timeout / 4)
+	limit := time.After(10 * time.Second)
+	for last := int64(-1); ; {
+		select {
+		case <-done:
+			fmt.Println("finished")
+			return
+		case <-tick.C:
+			n := steps.Load()
+			if n == last {
+				fmt.Printf("deadlocked after %d steps\n", n)
+				return
+			}
+			last = n
+		case <-limit:
+			fmt.Println("still running")
+			return
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	if name := os.Getenv(envHelper); name != "" {
+		helper(name)
+		os.Exit(0)
+	}
+	leaks.VerifyTestMain(m)
+}
+
+// finishes fails the test if f has not returned within the timeout,
+// instead of letting the test binary hang until go test kills it. A
+// deadlocked f stays stuck, but the test fails in a second, naming it.
+func finishes(t *testing.T, what string, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatalf("%s: deadlocked", what)
+	}
+}
+
+// TestBrokenVersionsDeadlock runs each deadlocking program in a child
+// process, which a deadlock cannot hang, and expects it to stop making
+// progress.
+func TestBrokenVersionsDeadlock(t *testing.T) {
+	for name := range deadlocking {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cmd := exec.Command(os.Args[0], "-test.run=NONE")
+			cmd.Env = append(os.Environ(), envHelper+"="+name)
+			out, err := cmd.CombinedOutput()
+			if err != nil || !strings.HasPrefix(string(out), "deadlocked") {
+				t.Errorf("no deadlock (%v):\n%s", err, out)
+			}
+		})
+	}
+}
+
+// The broken versions are right as long as nothing provokes them, which
+// is how they get past ordinary tests.
+func TestBrokenVersionsUnprovoked(t *testing.T) {
+	a, b := NewAccount(1, 10), NewAccount(2, 0)
+	if !TransferDeadlocking(a, b, 7) || TransferDeadlocking(a, b, 7) || a.Balance() != 3 || b.Balance() != 7 {
+		t.Errorf("balances %d and %d", a.Balance(), b.Balance())
+	}
+	var got []string
+	m := NewDeadlockingMailbox(func(msg string, _ func(string)) { got = append(got, msg) })
+	m.Post("a")
+	m.Post("b")
+	m.Close()
+	if fmt.Sprint(got) != "[a b]" {
+		t.Errorf("handled %v", got)
+	}
+	if out := GatherDeadlocking(nil, square); out != nil {
+		t.Errorf("gathered %v", out)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	a, b := NewAccount(1, 1000), NewAccount(2, 1000)
+	var moved atomic.Int32
+	finishes(t, "transfers both ways", func() {
+		var wg sync.WaitGroup
+		for _, pair := range [][2]*Account{{a, b}, {b, a}, {a, b}, {b, a}} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 10_000 {
+					if Transfer(pair[0], pair[1], 1) {
+						moved.Add(1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+	if a.Balance()+b.Balance() != 2000 || moved.Load() == 0 {
+		t.Errorf("balances %d and %d after %d transfers", a.Balance(), b.Balance(), moved.Load())
+	}
+	finishes(t, "transfer to self", func() {
+		if Transfer(a, a, 1) {
+			t.Error("transfer to self accepted")
+		}
+	})
+}
+
+func TestMailbox(t *testing.T) {
+	var got []string
+	m := NewMailbox(func(msg string, post func(string)) {
+		got = append(got, msg)
+		if n := strings.Count(msg, "!"); n < 2 {
+			post(msg + "!")
+		}
+	})
+	finishes(t, "handler posting", func() {
+		m.Post("a")
+		m.Post("b")
+		m.Close()
+	})
+	if want := "[a a! a!! b b! b!!]"; fmt.Sprint(got) != want {
+		t.Errorf("handled %v, want %v", got, want)
+	}
+}
+
+func TestGather(t *testing.T) {
+	var out []int
+	finishes(t, "gather", func() { out = Gather([]int{1, 2, 3, 4}, square) })
+	slices.Sort(out)
+	if fmt.Sprint(out) != "[1 4 9 16]" {
+		t.Errorf("gathered %v", out)
+	}
+}
+
+func TestPool(t *testing.T) {
+	p := NewPool(2)
+	var ran atomic.Int32
+	finishes(t, "run and shut down", func() {
+		for range 10 {
+			p.Run(func() { ran.Add(1) })
+		}
+		p.Shutdown()
+	})
+	if ran.Load() != 10 {
+		t.Errorf("ran %d tasks", ran.Load())
+	}
+}
+
+func Example() {
+	a, b := NewAccount(1, 100), NewAccount(2, 100)
+	var wg sync.WaitGroup
+	for _, pair := range [][2]*Account{{a, b}, {b, a}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				Transfer(pair[0], pair[1], 1)
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Println(a.Balance() + b.Balance())
+	// Output: 200
+}
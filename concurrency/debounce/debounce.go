@@ -0,0 +1,153 @@
+// Package debounce rate-limits calls to a function from any number of
+// goroutines.
+//
+// Debounce collapses a burst of calls into one: the function runs once the
+// calls have stopped for the wait duration (trailing edge), or right at the
+// start of the burst (leading edge), or both. Throttle lets the function run
+// at most once per wait duration while calls keep coming, which suits API
+// bursts better than UI-style events.
+package debounce
+
+import (
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// Timer is the part of *time.Timer the package needs.
+type Timer = clock.Timer
+
+// Clock schedules the delayed calls. Every clock.Clock is one.
+type Clock interface {
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Options selects the edges a wrapper fires on and the clock it uses.
+type Options struct {
+	Leading  bool
+	Trailing bool
+	Clock    Clock
+}
+
+// Option sets an Option.
+type Option func(*Options)
+
+// Leading sets whether the function runs at the start of a burst.
+func Leading(on bool) Option {
+	return func(o *Options) { o.Leading = on }
+}
+
+// Trailing sets whether the function runs at the end of a burst.
+func Trailing(on bool) Option {
+	return func(o *Options) { o.Trailing = on }
+}
+
+// WithClock replaces the wall clock, for tests.
+func WithClock(c Clock) Option {
+	return func(o *Options) { o.Clock = c }
+}
+
+func options(defaults Options, setters []Option) Options {
+	for _, set := range setters {
+		set(&defaults)
+	}
+	if defaults.Clock == nil {
+		defaults.Clock = clock.Real
+	}
+	return defaults
+}
+
+// Debounce returns a function that delays calling fn until wait has passed
+// without another call. By default only the trailing edge fires.
+func Debounce(fn func(), wait time.Duration, setters ...Option) func() {
+	opts := options(Options{Trailing: true}, setters)
+
+	var (
+		mu      sync.Mutex
+		timer   Timer
+		gen     uint64
+		pending bool
+	)
+
+	expire := func(g uint64) func() {
+		return func() {
+			mu.Lock()
+			if g != gen {
+				// Superseded by a later call that could not stop us.
+				mu.Unlock()
+				return
+			}
+			timer = nil
+			run := pending && opts.Trailing
+			pending = false
+			mu.Unlock()
+			if run {
+				fn()
+			}
+		}
+	}
+
+	return func() {
+		mu.Lock()
+		lead := false
+		if timer == nil {
+			lead = opts.Leading
+			pending = !lead
+		} else {
+			timer.Stop()
+			pending = true
+		}
+		gen++
+		timer = opts.Clock.AfterFunc(wait, expire(gen))
+		mu.Unlock()
+		if lead {
+			fn()
+		}
+	}
+}
+
+// Throttle returns a function that calls fn at most once per wait. By
+// default both edges fire: the first call runs fn right away, and calls
+// made during the wait are folded into one more run when it ends.
+func Throttle(fn func(), wait time.Duration, setters ...Option) func() {
+	opts := options(Options{Leading: true, Trailing: true}, setters)
+
+	var (
+		mu      sync.Mutex
+		open    bool // a wait window is running
+		pending bool
+	)
+
+	var expire func()
+	expire = func() {
+		mu.Lock()
+		if !pending || !opts.Trailing {
+			open, pending = false, false
+			mu.Unlock()
+			return
+		}
+		// The trailing run starts a window of its own.
+		pending = false
+		opts.Clock.AfterFunc(wait, expire)
+		mu.Unlock()
+		fn()
+	}
+
+	return func() {
+		mu.Lock()
+		if open {
+			pending = true
+			mu.Unlock()
+			return
+		}
+		open = true
+		opts.Clock.AfterFunc(wait, expire)
+		lead := opts.Leading
+		pending = !lead
+		mu.Unlock()
+		if lead {
+			fn()
+		}
+	}
+}
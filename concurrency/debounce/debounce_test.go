@@ -0,0 +1,137 @@
+package debounce
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// epoch is where the fake clocks of the tests start; calls are recorded
+// as offsets from it.
+var epoch = time.Unix(0, 0)
+
+func newFakeClock() *clock.Fake { return clock.NewFake(epoch) }
+
+// recorder records the fake time of every call.
+type recorder struct {
+	clock *clock.Fake
+	calls []time.Duration
+}
+
+func (r *recorder) fn() {
+	r.calls = append(r.calls, r.clock.Since(epoch))
+}
+
+func (r *recorder) expect(t *testing.T, want ...time.Duration) {
+	t.Helper()
+	if len(r.calls) != len(want) {
+		t.Fatalf("calls at %v, want %v", r.calls, want)
+	}
+	for i := range want {
+		if r.calls[i] != want[i] {
+			t.Fatalf("calls at %v, want %v", r.calls, want)
+		}
+	}
+}
+
+const ms = time.Millisecond
+
+// burst calls f every 10ms, count times.
+func burst(c *clock.Fake, f func(), count int) {
+	for i := 0; i < count; i++ {
+		f()
+		c.Advance(10 * ms)
+	}
+}
+
+func TestDebounceTrailing(t *testing.T) {
+	c := newFakeClock()
+	r := &recorder{clock: c}
+	f := Debounce(r.fn, 50*ms, WithClock(c))
+
+	burst(c, f, 5) // calls at 0..40ms
+	c.Advance(100 * ms)
+	r.expect(t, 90*ms)
+}
+
+func TestDebounceLeading(t *testing.T) {
+	c := newFakeClock()
+	r := &recorder{clock: c}
+	f := Debounce(r.fn, 50*ms, WithClock(c), Leading(true), Trailing(false))
+
+	burst(c, f, 5)
+	c.Advance(100 * ms)
+	burst(c, f, 1)
+	r.expect(t, 0, 150*ms)
+}
+
+func TestDebounceBothEdges(t *testing.T) {
+	c := newFakeClock()
+	r := &recorder{clock: c}
+	f := Debounce(r.fn, 50*ms, WithClock(c), Leading(true))
+
+	burst(c, f, 5)
+	c.Advance(100 * ms)
+	r.expect(t, 0, 90*ms)
+
+	// A single call only fires on the leading edge.
+	f()
+	c.Advance(100 * ms)
+	r.expect(t, 0, 90*ms, 150*ms)
+}
+
+func TestThrottle(t *testing.T) {
+	c := newFakeClock()
+	r := &recorder{clock: c}
+	f := Throttle(r.fn, 30*ms, WithClock(c))
+
+	burst(c, f, 10) // calls at 0..90ms
+	c.Advance(100 * ms)
+	r.expect(t, 0, 30*ms, 60*ms, 90*ms, 120*ms)
+}
+
+func TestThrottleLeadingOnly(t *testing.T) {
+	c := newFakeClock()
+	r := &recorder{clock: c}
+	f := Throttle(r.fn, 30*ms, WithClock(c), Trailing(false))
+
+	burst(c, f, 10)
+	c.Advance(100 * ms)
+	r.expect(t, 0, 30*ms, 60*ms, 90*ms)
+}
+
+func TestThrottleTrailingOnly(t *testing.T) {
+	c := newFakeClock()
+	r := &recorder{clock: c}
+	f := Throttle(r.fn, 30*ms, WithClock(c), Leading(false))
+
+	f()
+	c.Advance(100 * ms)
+	r.expect(t, 30*ms)
+}
+
+func TestConcurrentCallers(t *testing.T) {
+	var calls int32
+	f := Debounce(func() { atomic.AddInt32(&calls, 1) }, 20*ms)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	wg.Wait()
+	time.Sleep(100 * ms)
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected one trailing call, got %d", n)
+	}
+}
@@ -0,0 +1,162 @@
+// Package donechannel collects the conventions that keep channel code
+// from leaking, as small helpers.
+//
+// The goroutine that creates a channel owns it: it alone sends on it and
+// closes it, and it hands out only the receive end. Produce is that rule
+// as a function. A done channel, a chan struct{} that is only ever
+// closed, tells goroutines to stop; it is owned by whoever decides when
+// to stop, and is passed down the call stack as a receive-only
+// <-chan struct{} to every function that may block, which then waits on
+// it next to its real work, as Send, Recv, Sleep and OrDone do. Quit is a
+// done channel that is safe to close more than once, and Or merges
+// several into one.
+//
+// Code built on done channels meets code built on context.Context at the
+// edges: Context derives a context that is cancelled when a done channel
+// closes, and a context's Done method already is a done channel.
+package donechannel
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrClosed is the cause of a context from Context whose done channel
+// was closed.
+var ErrClosed = errors.New("donechannel: done channel closed")
+
+// Produce starts a goroutine sending the values of seq on a channel that
+// it owns, and returns the receive end. The channel is closed when seq
+// runs out or done is closed, whichever comes first.
+func Produce[T any](done <-chan struct{}, seq iter.Seq[T]) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for v := range seq {
+			if !Send(done, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Send sends v on c and reports true, or reports false if done is closed
+// first.
+func Send[T any](done <-chan struct{}, c chan<- T, v T) bool {
+	select {
+	case c <- v:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// Recv receives from c. It reports false if c is closed or done is
+// closed first.
+func Recv[T any](done <-chan struct{}, c <-chan T) (T, bool) {
+	select {
+	case v, ok := <-c:
+		return v, ok
+	case <-done:
+		var zero T
+		return zero, false
+	}
+}
+
+// Sleep pauses for d and reports true, or reports false as soon as done
+// is closed.
+func Sleep(done <-chan struct{}, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// OrDone forwards the values of c until c or done is closed, so that a
+// plain range loop over the result also stops on done.
+func OrDone[T any](done <-chan struct{}, c <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := Recv(done, c)
+			if !ok || !Send(done, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Or returns a channel that is closed once any of dones is closed. It
+// waits on a goroutine of its own, which runs until then: at least one
+// of dones must be closed eventually. With no channels it returns nil,
+// which is never closed.
+func Or(dones ...<-chan struct{}) <-chan struct{} {
+	switch len(dones) {
+	case 0:
+		return nil
+	case 1:
+		return dones[0]
+	}
+	out := make(chan struct{})
+	cases := make([]reflect.SelectCase, len(dones))
+	for i, d := range dones {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(d)}
+	}
+	go func() {
+		defer close(out)
+		reflect.Select(cases)
+	}()
+	return out
+}
+
+// Quit is a done channel owned by whoever stops the work. It may be
+// closed any number of times, from any goroutine.
+type Quit struct {
+	once sync.Once
+	c    chan struct{}
+}
+
+// NewQuit returns an open Quit.
+func NewQuit() *Quit { return &Quit{c: make(chan struct{})} }
+
+// Done returns the receive end, to be passed to the workers.
+func (q *Quit) Done() <-chan struct{} { return q.c }
+
+// Close closes the channel. Calls after the first do nothing.
+func (q *Quit) Close() { q.once.Do(func() { close(q.c) }) }
+
+// Closed reports whether Close has been called.
+func (q *Quit) Closed() bool {
+	select {
+	case <-q.c:
+		return true
+	default:
+		return false
+	}
+}
+
+// Context returns a context derived from parent that is also cancelled,
+// with cause ErrClosed, when done is closed. The caller must call cancel
+// to release the goroutine watching done.
+func Context(parent context.Context, done <-chan struct{}) (ctx context.Context, cancel context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(parent)
+	go func() {
+		select {
+		case <-done:
+			cancelCause(ErrClosed)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancelCause(context.Canceled) }
+}
@@ -0,0 +1,214 @@
+package donechannel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func TestProduce(t *testing.T) {
+	leaks.Check(t)
+	done := make(chan struct{})
+	defer close(done)
+	var got []int
+	for v := range Produce(done, slices.Values([]int{1, 2, 3})) {
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestProduceStopsOnDone(t *testing.T) {
+	leaks.Check(t)
+	done := make(chan struct{})
+	forever := func(yield func(int) bool) {
+		for i := 0; yield(i); i++ {
+		}
+	}
+	c := Produce(done, forever)
+	if v := <-c; v != 0 {
+		t.Errorf("first value %d", v)
+	}
+	close(done)
+	// The producer closes its channel; at most one value was in flight.
+	n := 0
+	for range c {
+		n++
+	}
+	if n > 1 {
+		t.Errorf("%d values after done", n)
+	}
+}
+
+func TestSendRecv(t *testing.T) {
+	done := make(chan struct{})
+	c := make(chan int, 1)
+	if !Send(done, c, 7) {
+		t.Error("Send to a buffered channel failed")
+	}
+	if v, ok := Recv(done, c); v != 7 || !ok {
+		t.Errorf("Recv = %d, %v", v, ok)
+	}
+	close(done)
+	if Send(done, make(chan int), 1) {
+		t.Error("Send with nobody receiving succeeded after done")
+	}
+	if _, ok := Recv(done, make(chan int)); ok {
+		t.Error("Recv with nobody sending succeeded after done")
+	}
+	closed := make(chan int)
+	close(closed)
+	if _, ok := Recv(make(chan struct{}), closed); ok {
+		t.Error("Recv on a closed channel reported a value")
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if !Sleep(nil, time.Millisecond) {
+		t.Error("Sleep was interrupted")
+	}
+	done := make(chan struct{})
+	close(done)
+	start := time.Now()
+	if Sleep(done, time.Hour) || time.Since(start) > time.Second {
+		t.Error("Sleep ignored done")
+	}
+}
+
+func TestOrDone(t *testing.T) {
+	leaks.Check(t)
+	done := make(chan struct{})
+	in := make(chan int)
+	out := OrDone(done, in)
+	in <- 1
+	if v := <-out; v != 1 {
+		t.Errorf("got %d", v)
+	}
+	// in is never closed; done alone ends the range.
+	close(done)
+	for v := range out {
+		t.Errorf("value %d after done", v)
+	}
+}
+
+func TestOr(t *testing.T) {
+	if Or() != nil {
+		t.Error("Or() is not nil")
+	}
+	one := make(chan struct{})
+	if Or(one) != (<-chan struct{})(one) {
+		t.Error("Or of one channel is not that channel")
+	}
+	for i := range 3 {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			leaks.Check(t)
+			dones := []chan struct{}{make(chan struct{}), make(chan struct{}), make(chan struct{})}
+			or := Or(dones[0], dones[1], dones[2])
+			select {
+			case <-or:
+				t.Fatal("closed before any input")
+			default:
+			}
+			close(dones[i])
+			<-or
+		})
+	}
+}
+
+func TestQuit(t *testing.T) {
+	q := NewQuit()
+	if q.Closed() {
+		t.Error("new Quit is closed")
+	}
+	q.Close()
+	q.Close()
+	<-q.Done()
+	if !q.Closed() {
+		t.Error("Quit not closed")
+	}
+}
+
+func TestContext(t *testing.T) {
+	leaks.Check(t)
+	t.Run("done closed", func(t *testing.T) {
+		done := make(chan struct{})
+		ctx, cancel := Context(context.Background(), done)
+		defer cancel()
+		close(done)
+		<-ctx.Done()
+		if !errors.Is(ctx.Err(), context.Canceled) || context.Cause(ctx) != ErrClosed {
+			t.Errorf("Err = %v, Cause = %v", ctx.Err(), context.Cause(ctx))
+		}
+	})
+	t.Run("parent cancelled", func(t *testing.T) {
+		parent, stop := context.WithCancel(context.Background())
+		ctx, cancel := Context(parent, make(chan struct{}))
+		defer cancel()
+		stop()
+		<-ctx.Done()
+		if context.Cause(ctx) != context.Canceled {
+			t.Errorf("Cause = %v", context.Cause(ctx))
+		}
+	})
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := Context(context.Background(), make(chan struct{}))
+		cancel()
+		<-ctx.Done()
+	})
+}
+
+// fetch, parse and index show a done channel passed down a call stack:
+// every level that can block hands it to the next.
+func fetch(done <-chan struct{}, pages int) <-chan string {
+	return Produce(done, func(yield func(string) bool) {
+		for i := range pages {
+			if !yield(fmt.Sprintf("page %d", i)) {
+				return
+			}
+		}
+	})
+}
+
+func parse(done <-chan struct{}, pages <-chan string) <-chan int {
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		for p := range OrDone(done, pages) {
+			if !Send(done, out, len(p)) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func index(done <-chan struct{}, pages int) (total int) {
+	for n := range parse(done, fetch(done, pages)) {
+		total += n
+	}
+	return total
+}
+
+func Example() {
+	quit := NewQuit()
+	defer quit.Close()
+	fmt.Println(index(quit.Done(), 3))
+
+	// Code that takes a context joins in through Context.
+	ctx, cancel := Context(context.Background(), quit.Done())
+	defer cancel()
+	quit.Close()
+	<-ctx.Done()
+	fmt.Println(context.Cause(ctx))
+	// Output:
+	// 18
+	// donechannel: done channel closed
+}
@@ -0,0 +1,189 @@
+// Package eventloop runs tasks one at a time on a single goroutine.
+//
+// Code that shares state between goroutines usually guards it with a
+// mutex, and every access has to remember to take it. An event loop turns
+// that around: the state belongs to the loop's goroutine, and other
+// goroutines do not touch it but Post a task that the loop runs in turn.
+// Tasks never run concurrently, so they need no locks, and they run in
+// the order they were posted. This is how JavaScript runtimes, GUI
+// toolkits and the reactor in networking libraries work. The price is
+// that one slow task holds up all the others, so tasks must not block.
+//
+// A Loop also runs delayed tasks, posted with PostAfter, on the same
+// goroutine. Stop is graceful: tasks already posted still run, delayed
+// tasks that are not yet due are dropped, and Run returns.
+package eventloop
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStopped is returned when posting to a loop that has been stopped.
+var ErrStopped = errors.New("eventloop: stopped")
+
+// Loop is an event loop. Create it with New, run it with Run on the
+// goroutine that is to own its state, and post tasks from anywhere.
+type Loop struct {
+	wake chan struct{}
+
+	mu      sync.Mutex
+	tasks   []func()
+	stopped bool
+
+	// timers is touched only by the loop goroutine.
+	timers timerHeap
+	seq    uint64
+}
+
+// New returns a Loop that is not running yet. Tasks posted before Run
+// run once it starts.
+func New() *Loop {
+	return &Loop{wake: make(chan struct{}, 1)}
+}
+
+// Post queues fn to run on the loop. It never blocks.
+func (l *Loop) Post(fn func()) error {
+	l.mu.Lock()
+	if l.stopped {
+		l.mu.Unlock()
+		return ErrStopped
+	}
+	l.tasks = append(l.tasks, fn)
+	l.mu.Unlock()
+	l.signal()
+	return nil
+}
+
+func (l *Loop) signal() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Do runs fn on the loop and waits for it to finish, or for ctx to be
+// done. It must not be called from a task, which would wait for itself.
+func (l *Loop) Do(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	if err := l.Post(func() { defer close(done); fn() }); err != nil {
+		return err
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Timer is a delayed task.
+type Timer struct {
+	when      time.Time
+	seq       uint64
+	fn        func()
+	cancelled atomic.Bool
+}
+
+// Cancel stops the task from running and reports whether it had not run
+// or been cancelled yet.
+func (t *Timer) Cancel() bool { return t.cancelled.CompareAndSwap(false, true) }
+
+// PostAfter queues fn to run on the loop once d has passed. Delayed tasks
+// that fall due together run in the order they were posted.
+func (l *Loop) PostAfter(d time.Duration, fn func()) (*Timer, error) {
+	t := &Timer{when: time.Now().Add(d)}
+	t.fn = func() {
+		if t.Cancel() {
+			fn()
+		}
+	}
+	// The heap belongs to the loop, so the timer is added by a task.
+	err := l.Post(func() {
+		l.seq++
+		t.seq = l.seq
+		heap.Push(&l.timers, t)
+	})
+	return t, err
+}
+
+// Stop makes the loop finish the tasks already posted and return from
+// Run. Posting fails from now on.
+func (l *Loop) Stop() {
+	l.mu.Lock()
+	l.stopped = true
+	l.mu.Unlock()
+	l.signal()
+}
+
+// Run runs tasks on the calling goroutine until Stop is called, when it
+// returns nil, or ctx is done, when it returns ctx's error without
+// running the tasks left. A task that panics panics Run.
+func (l *Loop) Run(ctx context.Context) error {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		l.mu.Lock()
+		tasks, stopped := l.tasks, l.stopped
+		l.tasks = nil
+		l.mu.Unlock()
+		for _, fn := range tasks {
+			fn()
+		}
+		if stopped {
+			// Tasks may have been posted between the swap and the stop.
+			l.mu.Lock()
+			tasks, l.tasks = l.tasks, nil
+			l.mu.Unlock()
+			for _, fn := range tasks {
+				fn()
+			}
+			return nil
+		}
+
+		now := time.Now()
+		for len(l.timers) > 0 && !l.timers[0].when.After(now) {
+			heap.Pop(&l.timers).(*Timer).fn()
+		}
+		var due <-chan time.Time
+		if len(l.timers) > 0 {
+			timer.Reset(l.timers[0].when.Sub(now))
+			due = timer.C
+		}
+		if len(tasks) > 0 {
+			// Tasks may have posted more; look again before sleeping.
+			continue
+		}
+		select {
+		case <-l.wake:
+		case <-due:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// timerHeap orders timers by when they are due, then by when they were
+// posted.
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int { return len(h) }
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].when.Equal(h[j].when) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].when.Before(h[j].when)
+}
+func (h timerHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *timerHeap) Push(x any)   { *h = append(*h, x.(*Timer)) }
+func (h *timerHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return t
+}
@@ -0,0 +1,188 @@
+package eventloop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// start runs l on a new goroutine and returns a channel receiving Run's
+// result.
+func start(l *Loop) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- l.Run(context.Background()) }()
+	return done
+}
+
+func TestTasksAreSerialised(t *testing.T) {
+	l := New()
+	done := start(l)
+	// count is only ever touched by tasks, so it needs no lock; the race
+	// detector would object otherwise.
+	count := 0
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				l.Post(func() { count++ })
+			}
+		}()
+	}
+	wg.Wait()
+	var got int
+	if err := l.Do(context.Background(), func() { got = count }); err != nil {
+		t.Fatal(err)
+	}
+	if got != 1000 {
+		t.Errorf("count = %d, want 1000", got)
+	}
+	l.Stop()
+	if err := <-done; err != nil {
+		t.Errorf("Run = %v", err)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	l := New()
+	var order []int
+	for i := range 5 {
+		l.Post(func() {
+			order = append(order, i)
+			// A task may post more; they run after those already queued.
+			if i == 0 {
+				l.Post(func() { order = append(order, 10) })
+			}
+		})
+	}
+	done := start(l)
+	if err := l.Do(context.Background(), func() {}); err != nil {
+		t.Fatal(err)
+	}
+	l.Stop()
+	<-done
+	if want := []int{0, 1, 2, 3, 4, 10}; !slices.Equal(order, want) {
+		t.Errorf("order %v, want %v", order, want)
+	}
+}
+
+func TestPostAfter(t *testing.T) {
+	l := New()
+	done := start(l)
+	var (
+		mu    sync.Mutex
+		order []string
+		fired = make(chan struct{})
+	)
+	record := func(s string) func() {
+		return func() {
+			mu.Lock()
+			order = append(order, s)
+			mu.Unlock()
+			if s == "30ms" {
+				close(fired)
+			}
+		}
+	}
+	l.PostAfter(30*time.Millisecond, record("30ms"))
+	l.PostAfter(10*time.Millisecond, record("10ms"))
+	cancelled, _ := l.PostAfter(20*time.Millisecond, record("cancelled"))
+	l.Post(record("now"))
+	if !cancelled.Cancel() || cancelled.Cancel() {
+		t.Error("Cancel did not report the first cancellation only")
+	}
+	<-fired
+	l.Stop()
+	<-done
+	if want := []string{"now", "10ms", "30ms"}; !slices.Equal(order, want) {
+		t.Errorf("order %v, want %v", order, want)
+	}
+}
+
+func TestStop(t *testing.T) {
+	l := New()
+	ran := 0
+	for range 3 {
+		l.Post(func() { ran++ })
+	}
+	l.PostAfter(time.Hour, func() { t.Error("delayed task ran after Stop") })
+	l.Stop()
+	if err := l.Post(func() {}); err != ErrStopped {
+		t.Errorf("Post after Stop = %v", err)
+	}
+	if _, err := l.PostAfter(0, func() {}); err != ErrStopped {
+		t.Errorf("PostAfter after Stop = %v", err)
+	}
+	if err := l.Do(context.Background(), func() {}); err != ErrStopped {
+		t.Errorf("Do after Stop = %v", err)
+	}
+	if err := l.Run(context.Background()); err != nil || ran != 3 {
+		t.Errorf("Run = %v after %d tasks, want 3", err, ran)
+	}
+}
+
+func TestContext(t *testing.T) {
+	l := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- l.Run(ctx) }()
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v", err)
+	}
+
+	// Do gives up waiting when its own context ends.
+	idle := New()
+	dctx, dcancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer dcancel()
+	if err := idle.Do(dctx, func() {}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do on a loop that is not running = %v", err)
+	}
+}
+
+func BenchmarkPost(b *testing.B) {
+	l := New()
+	done := start(l)
+	var wg sync.WaitGroup
+	wg.Add(b.N)
+	for range b.N {
+		l.Post(wg.Done)
+	}
+	wg.Wait()
+	l.Stop()
+	<-done
+}
+
+// Example keeps a map of sessions on the loop: handlers post to it
+// instead of locking it.
+func Example() {
+	l := New()
+	done := start(l)
+	sessions := map[string]int{}
+
+	var wg sync.WaitGroup
+	for _, user := range []string{"ann", "bob", "ann", "ann"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Post(func() { sessions[user]++ })
+		}()
+	}
+	wg.Wait()
+	l.Do(context.Background(), func() {
+		fmt.Println("ann:", sessions["ann"], "bob:", sessions["bob"])
+	})
+	l.Stop()
+	<-done
+	// Output:
+	// ann: 3 bob: 1
+}
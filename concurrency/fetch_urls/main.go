@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/scope"
 )
 
 func main() {
@@ -13,35 +17,48 @@ func main() {
 		"http://www.reddit.com/r/programming.json",
 	}
 
-	resc, errc := make(chan string), make(chan error)
-
-	for _, url := range urls {
-		go func(url string) {
-			body, err := fetch(url)
-			if err != nil {
-				errc <- err
-				return
-			}
-			resc <- string(body)
-		}(url)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	for i := 0; i < len(urls); i++ {
-		select {
-		case res := <-resc:
-			fmt.Println(res)
-		case err := <-errc:
-			fmt.Println(err)
+	// Every fetch runs in the scope, so none of them is still running when
+	// main returns, even if one fails or the timeout hits.
+	resc := make(chan string, len(urls))
+	errc := make(chan error, len(urls))
+	scope.Run(ctx, func(s *scope.Scope) error {
+		for _, url := range urls {
+			s.Go(func(ctx context.Context) error {
+				body, err := fetch(ctx, url)
+				if err != nil {
+					errc <- err
+					return nil
+				}
+				resc <- body
+				return nil
+			})
 		}
+		return nil
+	})
+	close(resc)
+	close(errc)
+
+	for res := range resc {
+		fmt.Println(res)
+	}
+	for err := range errc {
+		fmt.Println(err)
 	}
 }
 
-func fetch(url string) (string, error) {
-	res, err := http.Get(url)
+func fetch(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
-	body, err := ioutil.ReadAll(res.Body)
+	body, err := io.ReadAll(res.Body)
 	res.Body.Close()
 	if err != nil {
 		return "", err
@@ -0,0 +1,190 @@
+// Package filewalker walks directory trees on a bounded number of
+// goroutines and streams what it finds over a channel.
+//
+// It is the disk-usage example of The Go Programming Language made into
+// an API. Reading a directory blocks on the disk, so reading many at once
+// is faster than filepath.WalkDir reading them one by one; but a goroutine
+// per directory opens as many files as the tree has directories. Walk
+// instead keeps a queue of directories to read, shared by a fixed number
+// of workers. A worker reads a directory, sends its entries to the
+// caller, queues the subdirectories and takes the next. The walk is over
+// when the queue is empty and no worker is reading; then the channel is
+// closed.
+//
+// Entries come in no particular order. Symbolic links are reported and
+// not followed, so a walk always ends. Cancelling the context stops the
+// workers and closes the channel, which is how a caller that stops
+// reading must end a walk.
+package filewalker
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Result is an entry of the tree or an error reading a directory.
+type Result struct {
+	Path string
+	// Info describes the entry, as os.Lstat would. It is nil with Err.
+	Info fs.FileInfo
+	Err  error
+}
+
+// Options tune a walk.
+type Options struct {
+	// Workers is how many directories are read at once, default 16.
+	Workers int
+	// Skip, if set, is asked about each subdirectory before it is queued;
+	// a skipped directory is still reported, but not read.
+	Skip func(path string, d fs.DirEntry) bool
+}
+
+func (o *Options) defaults() {
+	if o.Workers <= 0 {
+		o.Workers = 16
+	}
+}
+
+// readDir is os.ReadDir, replaced by tests.
+var readDir = os.ReadDir
+
+// Walk walks the tree at root and sends every entry under it, but not
+// root itself, on the returned channel. The channel is closed when the
+// walk is over or ctx is done.
+func Walk(ctx context.Context, root string, opts Options) <-chan Result {
+	opts.defaults()
+	w := &walker{ctx: ctx, opts: opts, out: make(chan Result, opts.Workers), queue: []string{root}, pending: 1}
+	w.cond = sync.NewCond(&w.mu)
+	stop := context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	})
+	var wg sync.WaitGroup
+	for range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		stop()
+		close(w.out)
+	}()
+	return w.out
+}
+
+type walker struct {
+	ctx  context.Context
+	opts Options
+	out  chan Result
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	queue []string
+	// pending counts the directories queued or being read.
+	pending int
+}
+
+func (w *walker) work() {
+	for {
+		dir, ok := w.next()
+		if !ok {
+			return
+		}
+		w.read(dir)
+		w.mu.Lock()
+		if w.pending--; w.pending == 0 {
+			w.cond.Broadcast()
+		}
+		w.mu.Unlock()
+	}
+}
+
+// next waits for a directory to read. It returns false once the walk is
+// over or cancelled.
+func (w *walker) next() (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.queue) == 0 && w.pending > 0 && w.ctx.Err() == nil {
+		w.cond.Wait()
+	}
+	if len(w.queue) == 0 || w.ctx.Err() != nil {
+		return "", false
+	}
+	dir := w.queue[len(w.queue)-1]
+	w.queue = w.queue[:len(w.queue)-1]
+	return dir, true
+}
+
+func (w *walker) read(dir string) {
+	entries, err := readDir(dir)
+	if err != nil {
+		w.send(Result{Path: dir, Err: err})
+		// ReadDir returns the entries it read before the error.
+	}
+	for _, d := range entries {
+		path := filepath.Join(dir, d.Name())
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // removed since the directory was read
+		}
+		if !w.send(Result{Path: path, Info: info, Err: err}) {
+			return
+		}
+		if d.IsDir() && (w.opts.Skip == nil || !w.opts.Skip(path, d)) {
+			w.mu.Lock()
+			w.queue = append(w.queue, path)
+			w.pending++
+			w.cond.Signal()
+			w.mu.Unlock()
+		}
+	}
+}
+
+func (w *walker) send(r Result) bool {
+	select {
+	case w.out <- r:
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+// Usage is what DiskUsage found.
+type Usage struct {
+	Files, Dirs int
+	// Bytes is the total size of the regular files.
+	Bytes int64
+}
+
+// DiskUsage walks the trees at roots and adds up their files and sizes.
+// It carries on past directories it cannot read and returns their errors
+// joined, with the usage of the rest.
+func DiskUsage(ctx context.Context, opts Options, roots ...string) (Usage, error) {
+	var u Usage
+	var errs []error
+	for _, root := range roots {
+		for r := range Walk(ctx, root, opts) {
+			switch {
+			case r.Err != nil:
+				errs = append(errs, r.Err)
+			case r.Info.IsDir():
+				u.Dirs++
+			case r.Info.Mode().IsRegular():
+				u.Files++
+				u.Bytes += r.Info.Size()
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return u, err
+	}
+	return u, errors.Join(errs...)
+}
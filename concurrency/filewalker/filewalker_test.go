@@ -0,0 +1,163 @@
+package filewalker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// tree makes a tree of depth levels with fanout directories and two
+// files of known sizes in each, and returns its root.
+func tree(t *testing.T, depth, fanout int) string {
+	root := t.TempDir()
+	var mk func(dir string, level int)
+	mk = func(dir string, level int) {
+		os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 10), 0o644)
+		os.WriteFile(filepath.Join(dir, "b.txt"), make([]byte, 100), 0o644)
+		if level == depth {
+			return
+		}
+		for i := range fanout {
+			sub := filepath.Join(dir, fmt.Sprint("d", i))
+			os.Mkdir(sub, 0o755)
+			mk(sub, level+1)
+		}
+	}
+	mk(root, 0)
+	return root
+}
+
+// paths returns the paths of the results relative to root, sorted.
+func paths(t *testing.T, root string, results <-chan Result) []string {
+	var got []string
+	for r := range results {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Path, r.Err)
+			continue
+		}
+		rel, _ := filepath.Rel(root, r.Path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestWalk(t *testing.T) {
+	leaks.Check(t)
+	root := tree(t, 3, 3)
+	os.Symlink(root, filepath.Join(root, "loop"))
+	var want []string
+	filepath.WalkDir(root, func(p string, _ fs.DirEntry, _ error) error {
+		if p != root {
+			rel, _ := filepath.Rel(root, p)
+			want = append(want, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	slices.Sort(want)
+	for _, workers := range []int{1, 4, 64} {
+		got := paths(t, root, Walk(context.Background(), root, Options{Workers: workers}))
+		if !slices.Equal(got, want) {
+			t.Errorf("%d workers: %d entries, want %d", workers, len(got), len(want))
+		}
+	}
+}
+
+func TestSkip(t *testing.T) {
+	leaks.Check(t)
+	root := tree(t, 2, 2)
+	got := paths(t, root, Walk(context.Background(), root, Options{
+		Skip: func(_ string, d fs.DirEntry) bool { return d.Name() == "d1" },
+	}))
+	want := "[a.txt b.txt d0 d0/a.txt d0/b.txt d0/d0 d0/d0/a.txt d0/d0/b.txt d0/d1 d1]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestBounded(t *testing.T) {
+	leaks.Check(t)
+	root := tree(t, 3, 4)
+	var reading, peak atomic.Int64
+	defer func(f func(string) ([]fs.DirEntry, error)) { readDir = f }(readDir)
+	readDir = func(dir string) ([]fs.DirEntry, error) {
+		n := reading.Add(1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(200 * time.Microsecond)
+		defer reading.Add(-1)
+		return os.ReadDir(dir)
+	}
+	u, err := DiskUsage(context.Background(), Options{Workers: 3}, root)
+	if err != nil || peak.Load() != 3 {
+		t.Errorf("peak %d reads at once, %v", peak.Load(), err)
+	}
+	// 1+4+16+64 directories, less the root, with two files each.
+	if u != (Usage{Files: 170, Dirs: 84, Bytes: 85 * 110}) {
+		t.Errorf("usage %+v", u)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	leaks.Check(t)
+	root := tree(t, 1, 3)
+	denied := errors.New("permission denied")
+	defer func(f func(string) ([]fs.DirEntry, error)) { readDir = f }(readDir)
+	readDir = func(dir string) ([]fs.DirEntry, error) {
+		if filepath.Base(dir) == "d1" {
+			return nil, &fs.PathError{Op: "open", Path: dir, Err: denied}
+		}
+		return os.ReadDir(dir)
+	}
+	u, err := DiskUsage(context.Background(), Options{}, root, filepath.Join(root, "missing"))
+	if !errors.Is(err, denied) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("err = %v", err)
+	}
+	if u != (Usage{Files: 6, Dirs: 3, Bytes: 330}) {
+		t.Errorf("usage %+v", u)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	leaks.Check(t)
+	root := tree(t, 4, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	results := Walk(ctx, root, Options{Workers: 4})
+	<-results
+	cancel()
+	// The channel is closed soon, without the rest of the tree being read.
+	n := 0
+	for range results {
+		n++
+	}
+	if n > 100 {
+		t.Errorf("%d results after cancelling", n)
+	}
+	if _, err := DiskUsage(ctx, Options{}, root); err != context.Canceled {
+		t.Errorf("DiskUsage = %v", err)
+	}
+}
+
+func ExampleDiskUsage() {
+	root, _ := os.MkdirTemp("", "example")
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "src", "pkg"), 0o755)
+	os.WriteFile(filepath.Join(root, "README"), []byte(strings.Repeat("x", 1000)), 0o644)
+	os.WriteFile(filepath.Join(root, "src", "pkg", "main.go"), []byte("package main\n"), 0o644)
+
+	u, err := DiskUsage(context.Background(), Options{}, root)
+	fmt.Printf("%d files in %d directories, %d bytes, err %v\n", u.Files, u.Dirs, u.Bytes, err)
+	// Output: 2 files in 2 directories, 1013 bytes, err <nil>
+}
@@ -0,0 +1,219 @@
+// Package futures holds results that are still being computed, and
+// composes them the way JavaScript composes promises: All waits for every
+// result, Any for the first success, Race for the first result of either
+// kind, and AllSettled for every result without failing fast.
+//
+// A Future is started with Go, on a goroutine of its own, or with Submit,
+// on a workerpool.Pool. The combinators only wait; they never cancel the
+// futures they stop waiting for. Start the futures with a context that is
+// cancelled once the combinator returns to stop the losers of Any or Race,
+// or the rest of All after a failure.
+package futures
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// Errors of the combinators.
+var (
+	// ErrEmpty is returned by Any and Race when given no futures.
+	ErrEmpty = errors.New("futures: no futures")
+	// ErrAllFailed is returned, joined with every failure, by Any when no
+	// future succeeds.
+	ErrAllFailed = errors.New("futures: all futures failed")
+)
+
+// Future is a value of type T, or an error, that becomes available once.
+type Future[T any] struct {
+	done chan struct{}
+	once sync.Once
+	val  T
+	err  error
+}
+
+// New returns a pending future and the function that completes it. Calls
+// after the first are ignored.
+func New[T any]() (*Future[T], func(T, error)) {
+	f := &Future[T]{done: make(chan struct{})}
+	return f, f.complete
+}
+
+func (f *Future[T]) complete(v T, err error) {
+	f.once.Do(func() {
+		f.val, f.err = v, err
+		close(f.done)
+	})
+}
+
+// Go runs fn on a new goroutine and returns its future. A panic in fn
+// completes the future with a *recovery.PanicError.
+func Go[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) *Future[T] {
+	f, complete := New[T]()
+	go func() {
+		var v T
+		err := recovery.Do(func() error {
+			var err error
+			v, err = fn(ctx)
+			return err
+		})
+		complete(v, err)
+	}()
+	return f
+}
+
+// Submit runs fn on p and returns its future, which fails with whatever
+// Run returns when the pool does not take the work, such as ctx.Err() or
+// workerpool.ErrClosed.
+func Submit[T any](ctx context.Context, p *workerpool.Pool, fn func(ctx context.Context) (T, error)) *Future[T] {
+	f, complete := New[T]()
+	go func() {
+		var v T
+		err := p.Run(ctx, workerpool.WorkerFunc(func(ctx context.Context) error {
+			var err error
+			v, err = fn(ctx)
+			return err
+		}))
+		complete(v, err)
+	}()
+	return f
+}
+
+// Done is closed once the future is complete.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the future and returns its result, or ctx.Err() if ctx
+// is done first. The future keeps running either way.
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Result is the outcome of one future in AllSettled.
+type Result[T any] struct {
+	Val T
+	Err error
+}
+
+// settled sends the index of each future as it completes, until stop is
+// closed. The channel has room for every index, so nothing is left
+// blocked when the caller stops listening.
+func settled[T any](fs []*Future[T], stop <-chan struct{}) <-chan int {
+	c := make(chan int, len(fs))
+	for i, f := range fs {
+		go func() {
+			select {
+			case <-f.done:
+				c <- i
+			case <-stop:
+			}
+		}()
+	}
+	return c
+}
+
+// All waits for every future and returns their values in order. It fails
+// fast: the first failure to complete is returned without waiting for
+// the rest.
+func All[T any](ctx context.Context, fs ...*Future[T]) ([]T, error) {
+	stop := make(chan struct{})
+	defer close(stop)
+	c := settled(fs, stop)
+	vals := make([]T, len(fs))
+	for range fs {
+		select {
+		case i := <-c:
+			if fs[i].err != nil {
+				return nil, fmt.Errorf("future %d: %w", i, fs[i].err)
+			}
+			vals[i] = fs[i].val
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return vals, nil
+}
+
+// AllSettled waits for every future and returns their results in order,
+// failures included. If ctx is done first, it returns ctx.Err() along
+// with the results so far; those of unfinished futures carry ctx.Err()
+// too.
+func AllSettled[T any](ctx context.Context, fs ...*Future[T]) ([]Result[T], error) {
+	stop := make(chan struct{})
+	defer close(stop)
+	c := settled(fs, stop)
+	results := make([]Result[T], len(fs))
+	for range fs {
+		select {
+		case i := <-c:
+			results[i] = Result[T]{fs[i].val, fs[i].err}
+		case <-ctx.Done():
+			for i, f := range fs {
+				select {
+				case <-f.done:
+					results[i] = Result[T]{f.val, f.err}
+				default:
+					results[i].Err = ctx.Err()
+				}
+			}
+			return results, ctx.Err()
+		}
+	}
+	return results, nil
+}
+
+// Any returns the value of the first future to succeed. If all of them
+// fail, it returns ErrAllFailed joined with their errors, in order.
+func Any[T any](ctx context.Context, fs ...*Future[T]) (T, error) {
+	var zero T
+	if len(fs) == 0 {
+		return zero, ErrEmpty
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	c := settled(fs, stop)
+	for range fs {
+		select {
+		case i := <-c:
+			if fs[i].err == nil {
+				return fs[i].val, nil
+			}
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+	errs := []error{ErrAllFailed}
+	for _, f := range fs {
+		errs = append(errs, f.err)
+	}
+	return zero, errors.Join(errs...)
+}
+
+// Race returns the result of the first future to complete, whether it
+// succeeded or not.
+func Race[T any](ctx context.Context, fs ...*Future[T]) (T, error) {
+	var zero T
+	if len(fs) == 0 {
+		return zero, ErrEmpty
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	select {
+	case i := <-settled(fs, stop):
+		return fs[i].val, fs[i].err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,208 @@
+package futures
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+var errDown = errors.New("backend down")
+
+// manual returns n pending futures and their completion functions, so a
+// test decides the order in which they complete.
+func manual(n int) ([]*Future[int], []func(int, error)) {
+	fs := make([]*Future[int], n)
+	complete := make([]func(int, error), n)
+	for i := range fs {
+		fs[i], complete[i] = New[int]()
+	}
+	return fs, complete
+}
+
+func TestGo(t *testing.T) {
+	leaks.Check(t)
+	f := Go(context.Background(), func(context.Context) (int, error) { return 42, nil })
+	if v, err := f.Wait(context.Background()); v != 42 || err != nil {
+		t.Errorf("Wait = %d, %v", v, err)
+	}
+	var p *recovery.PanicError
+	f = Go(context.Background(), func(context.Context) (int, error) { panic("boom") })
+	if _, err := f.Wait(context.Background()); !errors.As(err, &p) {
+		t.Errorf("Wait = %v, want a panic", err)
+	}
+}
+
+func TestWaitGivesUp(t *testing.T) {
+	f, complete := New[int]()
+	defer complete(0, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := f.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v", err)
+	}
+	select {
+	case <-f.Done():
+		t.Error("future completed by giving up on it")
+	default:
+	}
+}
+
+func TestCompleteOnce(t *testing.T) {
+	f, complete := New[int]()
+	complete(1, nil)
+	complete(2, errDown)
+	if v, err := f.Wait(context.Background()); v != 1 || err != nil {
+		t.Errorf("Wait = %d, %v", v, err)
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	leaks.Check(t)
+	p := workerpool.New(2)
+	var fs []*Future[int]
+	for i := range 5 {
+		fs = append(fs, Submit(context.Background(), p, func(context.Context) (int, error) { return i * i, nil }))
+	}
+	got, err := All(context.Background(), fs...)
+	if want := []int{0, 1, 4, 9, 16}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("All = %v, %v", got, err)
+	}
+	p.Shutdown()
+	if _, err := Submit(context.Background(), p, func(context.Context) (int, error) { return 0, nil }).Wait(context.Background()); !errors.Is(err, workerpool.ErrClosed) {
+		t.Errorf("Submit after Shutdown = %v", err)
+	}
+}
+
+func TestAllInOrder(t *testing.T) {
+	leaks.Check(t)
+	fs, complete := manual(3)
+	complete[2](30, nil)
+	complete[0](10, nil)
+	complete[1](20, nil)
+	got, err := All(context.Background(), fs...)
+	if err != nil || !reflect.DeepEqual(got, []int{10, 20, 30}) {
+		t.Errorf("All = %v, %v", got, err)
+	}
+	if got, err := All[int](context.Background()); err != nil || len(got) != 0 {
+		t.Errorf("All() = %v, %v", got, err)
+	}
+}
+
+func TestAllFailsFast(t *testing.T) {
+	leaks.Check(t)
+	fs, complete := manual(3)
+	defer complete[2](0, nil)
+	complete[0](10, nil)
+	complete[1](0, errDown)
+	// fs[2] never completes while All is waiting.
+	_, err := All(context.Background(), fs...)
+	if !errors.Is(err, errDown) || err.Error() != "future 1: backend down" {
+		t.Errorf("All = %v", err)
+	}
+}
+
+func TestAllSettled(t *testing.T) {
+	leaks.Check(t)
+	fs, complete := manual(3)
+	defer complete[2](0, nil)
+	complete[0](10, nil)
+	complete[1](0, errDown)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	got, err := AllSettled(ctx, fs...)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("AllSettled err = %v", err)
+	}
+	want := []Result[int]{{10, nil}, {0, errDown}, {0, context.DeadlineExceeded}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AllSettled = %v, want %v", got, want)
+	}
+}
+
+func TestAnySkipsFailures(t *testing.T) {
+	leaks.Check(t)
+	fs, complete := manual(3)
+	defer complete[0](0, nil)
+	complete[2](0, errDown)
+	complete[1](20, nil)
+	if v, err := Any(context.Background(), fs...); v != 20 || err != nil {
+		t.Errorf("Any = %d, %v", v, err)
+	}
+}
+
+func TestAnyAllFailed(t *testing.T) {
+	leaks.Check(t)
+	fs, complete := manual(2)
+	errTimeout := errors.New("timeout")
+	complete[1](0, errTimeout)
+	complete[0](0, errDown)
+	_, err := Any(context.Background(), fs...)
+	if !errors.Is(err, ErrAllFailed) || !errors.Is(err, errDown) || !errors.Is(err, errTimeout) {
+		t.Errorf("Any = %v", err)
+	}
+	if _, err := Any[int](context.Background()); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Any() = %v", err)
+	}
+}
+
+func TestRaceTakesFirstFailure(t *testing.T) {
+	leaks.Check(t)
+	fs, complete := manual(2)
+	defer complete[0](10, nil)
+	complete[1](0, errDown)
+	if _, err := Race(context.Background(), fs...); !errors.Is(err, errDown) {
+		t.Errorf("Race = %v", err)
+	}
+	if _, err := Race[int](context.Background()); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Race() = %v", err)
+	}
+}
+
+func TestCombinatorsHonourContext(t *testing.T) {
+	leaks.Check(t)
+	fs, complete := manual(2)
+	defer complete[0](0, nil)
+	defer complete[1](0, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := All(ctx, fs...); !errors.Is(err, context.Canceled) {
+		t.Errorf("All = %v", err)
+	}
+	if _, err := Any(ctx, fs...); !errors.Is(err, context.Canceled) {
+		t.Errorf("Any = %v", err)
+	}
+	if _, err := Race(ctx, fs...); !errors.Is(err, context.Canceled) {
+		t.Errorf("Race = %v", err)
+	}
+}
+
+func ExampleAny() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // stops the replicas that lose
+	replica := func(name string, delay time.Duration, err error) *Future[string] {
+		return Go(ctx, func(ctx context.Context) (string, error) {
+			select {
+			case <-time.After(delay):
+				return name, err
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		})
+	}
+	v, err := Any(ctx,
+		replica("eu", time.Millisecond, errDown),
+		replica("us", 10*time.Millisecond, nil),
+		replica("ap", time.Second, nil),
+	)
+	fmt.Println(v, err)
+	// Output: us <nil>
+}
@@ -0,0 +1,113 @@
+// Package generator turns the channel generators used throughout the
+// pipeline examples into reusable, typed building blocks. Every stage takes
+// a done channel first; closing it stops the stage and closes its output, so
+// a consumer that stops early does not leak the goroutines behind it.
+//
+// A panic in a function passed to Map or Filter is recovered through
+// recovery.Default and ends that stage as if its input had run dry.
+package generator
+
+import (
+	"iter"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// Repeat sends values over and over, in order, until done is closed.
+func Repeat[T any](done <-chan struct{}, values ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		if len(values) == 0 {
+			return
+		}
+		for {
+			for _, v := range values {
+				select {
+				case <-done:
+					return
+				case out <- v:
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Take forwards the first n values from in and then closes its output.
+func Take[T any](done <-chan struct{}, in <-chan T, n int) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			var v T
+			var ok bool
+			select {
+			case <-done:
+				return
+			case v, ok = <-in:
+				if !ok {
+					return
+				}
+			}
+			select {
+			case <-done:
+				return
+			case out <- v:
+			}
+		}
+	}()
+	return out
+}
+
+// Map sends fn(v) for every v received from in.
+func Map[T, U any](done <-chan struct{}, in <-chan T, fn func(T) U) <-chan U {
+	out := make(chan U)
+	go func() {
+		defer close(out)
+		defer recovery.Default.Recover(nil)
+		for v := range in {
+			select {
+			case <-done:
+				return
+			case out <- fn(v):
+			}
+		}
+	}()
+	return out
+}
+
+// Filter forwards the values from in for which keep returns true.
+func Filter[T any](done <-chan struct{}, in <-chan T, keep func(T) bool) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer recovery.Default.Recover(nil)
+		for v := range in {
+			if !keep(v) {
+				continue
+			}
+			select {
+			case <-done:
+				return
+			case out <- v:
+			}
+		}
+	}()
+	return out
+}
+
+// Seq adapts a channel pipeline to an iter.Seq. build is called with a done
+// channel owned by the iterator, which is closed when the range loop ends,
+// including when it ends early with break or return.
+func Seq[T any](build func(done <-chan struct{}) <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		done := make(chan struct{})
+		defer close(done)
+		for v := range build(done) {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func collect[T any](in <-chan T) []T {
+	var out []T
+	for v := range in {
+		out = append(out, v)
+	}
+	return out
+}
+
+func TestRepeatTake(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	got := collect(Take(done, Repeat(done, 1, 2, 3), 7))
+	want := []int{1, 2, 3, 1, 2, 3, 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRepeatNothing(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	if got := collect(Repeat[int](done)); len(got) != 0 {
+		t.Errorf("expected no values, got %v", got)
+	}
+}
+
+func TestMapFilter(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	ints := Take(done, Repeat(done, 1, 2, 3, 4, 5), 5)
+	even := Filter(done, ints, func(v int) bool { return v%2 == 0 })
+	labels := Map(done, even, func(v int) string { return fmt.Sprint("#", v) })
+
+	got := collect(labels)
+	want := []string{"#2", "#4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPanickingStageEnds(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	ints := Take(done, Repeat(done, 1, 2, 3), 3)
+	out := Map(done, ints, func(v int) int {
+		if v == 2 {
+			panic("stage bug")
+		}
+		return v
+	})
+	if got := collect(out); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("got %v, want the values before the panic", got)
+	}
+}
+
+func TestDoneStopsStages(t *testing.T) {
+	done := make(chan struct{})
+	out := Map(done, Repeat(done, 1), func(v int) int { return v })
+	<-out
+	close(done)
+
+	select {
+	case <-drain(out):
+	case <-time.After(time.Second):
+		t.Fatal("stage did not stop after done was closed")
+	}
+}
+
+func drain[T any](in <-chan T) <-chan struct{} {
+	stopped := make(chan struct{})
+	go func() {
+		for range in {
+		}
+		close(stopped)
+	}()
+	return stopped
+}
+
+func TestSeqBreakReleasesPipeline(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	var got []int
+	for v := range Seq(func(done <-chan struct{}) <-chan int {
+		return Map(done, Repeat(done, 1, 2, 3), func(v int) int { return v * 10 })
+	}) {
+		got = append(got, v)
+		if len(got) == 4 {
+			break
+		}
+	}
+	if want := []int{10, 20, 30, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("pipeline goroutines leaked: %d > %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func ExampleSeq() {
+	squares := Seq(func(done <-chan struct{}) <-chan int {
+		ints := Take(done, Repeat(done, 1, 2, 3, 4), 4)
+		return Map(done, ints, func(v int) int { return v * v })
+	})
+	for v := range squares {
+		fmt.Println(v)
+	}
+	// Output:
+	// 1
+	// 4
+	// 9
+	// 16
+}
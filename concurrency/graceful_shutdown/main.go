@@ -0,0 +1,143 @@
+// Graceful shutdown of an HTTP server with background worker pools.
+//
+// On SIGINT or SIGTERM the server stops accepting connections and waits for
+// in-flight requests to finish (http.Server.Shutdown). Only then are the
+// worker pools stopped, one after the other in the order they were
+// registered, so a pool is never stopped while a request or an earlier
+// stage may still hand it work. The whole sequence runs against one hard
+// deadline; whatever has not finished by then is cut off with
+// http.Server.Close and reported.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// ErrHardDeadline is returned by serve when shutdown did not complete in
+// time.
+var ErrHardDeadline = errors.New("graceful_shutdown: hard deadline exceeded")
+
+// stage is something that must be stopped after the server has drained.
+type stage struct {
+	name string
+	stop func()
+}
+
+type app struct {
+	srv      *http.Server
+	stages   []stage
+	deadline time.Duration
+}
+
+func newApp(handler http.Handler, deadline time.Duration) *app {
+	return &app{
+		srv:      &http.Server{Handler: handler},
+		deadline: deadline,
+	}
+}
+
+// addPool registers a pool to be shut down after the server, after every
+// pool registered before it.
+func (a *app) addPool(name string, p *workerpool.Pool) {
+	a.stages = append(a.stages, stage{name: name, stop: p.Shutdown})
+}
+
+// serve serves on ln until ctx is done and then shuts everything down.
+func (a *app) serve(ctx context.Context, ln net.Listener) error {
+	errc := make(chan error, 1)
+	go func() { errc <- a.srv.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	log.Println("shutting down: draining in-flight requests")
+
+	deadline, cancel := context.WithTimeout(context.Background(), a.deadline)
+	defer cancel()
+
+	if err := a.srv.Shutdown(deadline); err != nil {
+		a.srv.Close()
+		return fmt.Errorf("%w: server: %v", ErrHardDeadline, err)
+	}
+	for _, s := range a.stages {
+		log.Printf("shutting down: stopping %s", s.name)
+		if err := stopBy(deadline, s.stop); err != nil {
+			return fmt.Errorf("%w: %s", ErrHardDeadline, s.name)
+		}
+	}
+	log.Println("shutdown complete")
+	return nil
+}
+
+// stopBy runs stop and gives up waiting for it once ctx is done.
+func stopBy(ctx context.Context, stop func()) error {
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// workHandler runs a slow job on pool for every request.
+func workHandler(pool *workerpool.Pool, cost time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := pool.Run(r.Context(), workerpool.WorkerFunc(func(ctx context.Context) error {
+			select {
+			case <-time.After(cost):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "done")
+	})
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	requests := workerpool.New(8)
+	background := workerpool.New(2)
+
+	recovery.OnPanic(func(p *recovery.PanicError) {
+		log.Printf("%v\n%s", p, p.Stack)
+	})
+	a := newApp(recovery.Middleware(workHandler(requests, 2*time.Second)), 10*time.Second)
+	a.addPool("request pool", requests)
+	a.addPool("background pool", background)
+
+	ln, err := net.Listen("tcp", ":8080")
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("listening on", ln.Addr())
+	if err := a.serve(ctx, ln); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// start serves a on an httptest listener and returns its URL, a cancel
+// function standing in for the signal, and the result of serve.
+func start(a *app) (string, context.CancelFunc, <-chan error) {
+	ts := httptest.NewUnstartedServer(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- a.serve(ctx, ts.Listener) }()
+	return "http://" + ts.Listener.Addr().String(), cancel, errc
+}
+
+func TestDrainsInFlightRequests(t *testing.T) {
+	pool := workerpool.New(2)
+	started := make(chan struct{})
+	a := newApp(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "finished")
+	}), time.Second)
+	a.addPool("pool", pool)
+
+	url, cancel, errc := start(a)
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		body <- string(b)
+	}()
+	<-started
+	cancel()
+
+	if got := <-body; got != "finished" {
+		t.Errorf("in-flight request was not drained: %q", got)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("server still accepts connections after shutdown")
+	}
+	if err := pool.Run(context.Background(), workerpool.WorkerFunc(func(context.Context) error { return nil })); err != workerpool.ErrClosed {
+		t.Errorf("pool was not shut down: %v", err)
+	}
+}
+
+func TestStopsStagesInOrder(t *testing.T) {
+	a := newApp(http.NotFoundHandler(), time.Second)
+	var mu sync.Mutex
+	var order []string
+	for _, name := range []string{"first", "second", "third"} {
+		name := name
+		a.stages = append(a.stages, stage{name: name, stop: func() {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}})
+	}
+
+	_, cancel, errc := start(a)
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 3 || order[0] != "first" || order[1] != "second" || order[2] != "third" {
+		t.Errorf("stages stopped out of order: %v", order)
+	}
+}
+
+func TestHardDeadline(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	started := make(chan struct{})
+	a := newApp(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-block
+	}), 50*time.Millisecond)
+
+	url, cancel, errc := start(a)
+	go http.Get(url)
+	<-started
+
+	begin := time.Now()
+	cancel()
+	err := <-errc
+	if !errors.Is(err, ErrHardDeadline) {
+		t.Errorf("expected ErrHardDeadline, got %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("shutdown took %v, the deadline was not enforced", elapsed)
+	}
+}
+
+func TestStuckStageHitsDeadline(t *testing.T) {
+	a := newApp(http.NotFoundHandler(), 50*time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	a.stages = append(a.stages, stage{name: "stuck", stop: func() { <-block }})
+
+	_, cancel, errc := start(a)
+	cancel()
+	if err := <-errc; !errors.Is(err, ErrHardDeadline) {
+		t.Errorf("expected ErrHardDeadline, got %v", err)
+	}
+}
+
+func TestWorkHandler(t *testing.T) {
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+
+	rec := httptest.NewRecorder()
+	workHandler(pool, time.Millisecond).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+}
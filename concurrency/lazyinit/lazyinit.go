@@ -0,0 +1,128 @@
+// Package lazyinit compares ways of creating a value on first use when
+// several goroutines may ask for it at once.
+//
+// Broken is double-checked locking as it is often written: check the
+// field, and only if it is unset lock and check again. The first check
+// reads the field without synchronisation while another goroutine may be
+// writing it under the lock. That is a data race, which the race detector
+// reports, and in Go's memory model a racing reader may see the pointer
+// before the fields of the value it points to.
+//
+// The correct versions are, in order of preference: OnceValue, which is
+// sync.OnceValue; Once, which is what OnceValue does with a sync.Once and
+// a field; and Atomic, double-checked locking done right with an
+// atomic.Pointer, for a value that has to be reset or whose creation can
+// fail and be retried.
+package lazyinit
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Lazy is a value created by the first call to Get.
+type Lazy[T any] interface {
+	Get() T
+}
+
+// Broken is double-checked locking with a plain field. Do not use it: it
+// is here to show the race.
+type Broken[T any] struct {
+	init func() T
+	mu   sync.Mutex
+	v    *T
+}
+
+// NewBroken returns a Broken that creates its value with init.
+func NewBroken[T any](init func() T) *Broken[T] {
+	return &Broken[T]{init: init}
+}
+
+// Get returns the value, creating it on the first call.
+func (b *Broken[T]) Get() T {
+	if b.v == nil { // races with the write below
+		b.mu.Lock()
+		if b.v == nil {
+			v := b.init()
+			b.v = &v
+		}
+		b.mu.Unlock()
+	}
+	return *b.v
+}
+
+// Once creates its value under a sync.Once.
+type Once[T any] struct {
+	init func() T
+	once sync.Once
+	v    T
+}
+
+// NewOnce returns a Once that creates its value with init.
+func NewOnce[T any](init func() T) *Once[T] {
+	return &Once[T]{init: init}
+}
+
+// Get returns the value, creating it on the first call. Callers that
+// arrive while it is being created wait for it.
+func (o *Once[T]) Get() T {
+	o.once.Do(func() { o.v = o.init() })
+	return o.v
+}
+
+// OnceValue wraps sync.OnceValue, which also remembers a panic of init and
+// raises it again on every call, where a sync.Once would leave the zero
+// value behind.
+type OnceValue[T any] struct {
+	get func() T
+}
+
+// NewOnceValue returns a OnceValue that creates its value with init.
+func NewOnceValue[T any](init func() T) OnceValue[T] {
+	return OnceValue[T]{sync.OnceValue(init)}
+}
+
+// Get returns the value, creating it on the first call.
+func (o OnceValue[T]) Get() T {
+	return o.get()
+}
+
+// Atomic is double-checked locking made correct by an atomic.Pointer:
+// the fast path is one atomic load, and the lock is only taken while the
+// value is unset. Unlike the versions built on sync.Once, a failed init is
+// retried by the next Get, and Reset drops the value so that the next Get
+// creates a new one.
+type Atomic[T any] struct {
+	init func() (T, error)
+	mu   sync.Mutex
+	v    atomic.Pointer[T]
+}
+
+// NewAtomic returns an Atomic that creates its value with init.
+func NewAtomic[T any](init func() (T, error)) *Atomic[T] {
+	return &Atomic[T]{init: init}
+}
+
+// Get returns the value, creating it if it is unset. An error from init is
+// returned and nothing is stored.
+func (a *Atomic[T]) Get() (T, error) {
+	if v := a.v.Load(); v != nil {
+		return *v, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if v := a.v.Load(); v != nil {
+		return *v, nil
+	}
+	v, err := a.init()
+	if err != nil {
+		return v, err
+	}
+	a.v.Store(&v)
+	return v, nil
+}
+
+// Reset drops the value.
+func (a *Atomic[T]) Reset() {
+	a.v.Store(nil)
+}
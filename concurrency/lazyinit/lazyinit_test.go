@@ -0,0 +1,164 @@
+package lazyinit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+// envHelper makes the test binary run racyGets instead of the tests.
+const envHelper = "LAZYINIT_TEST_HELPER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envHelper) != "" {
+		racyGets()
+		os.Exit(0)
+	}
+	leaks.VerifyTestMain(m)
+}
+
+// racyGets calls Get on a Broken from two goroutines. Whichever goes
+// second reads the field without synchronising with the write of the
+// first, however they are scheduled.
+func racyGets() {
+	b := NewBroken(func() []int { return []int{1, 2, 3} })
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Get()
+		}()
+	}
+	wg.Wait()
+}
+
+// TestBrokenIsRacy runs racyGets in a child process, where the race it
+// makes can be reported without failing this test, and expects the race
+// detector to catch it.
+func TestBrokenIsRacy(t *testing.T) {
+	if !raceEnabled {
+		t.Skip("needs the race detector: go test -race")
+	}
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), envHelper+"=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "WARNING: DATA RACE") {
+		t.Errorf("no race reported (%v):\n%s", err, out)
+	}
+}
+
+func TestBrokenSequential(t *testing.T) {
+	b := NewBroken(func() string { return "config" })
+	if b.Get() != "config" || b.Get() != "config" {
+		t.Error("wrong value")
+	}
+}
+
+func TestCreatedOnce(t *testing.T) {
+	for name, build := range map[string]func(init func() *int) Lazy[*int]{
+		"Once":      func(init func() *int) Lazy[*int] { return NewOnce(init) },
+		"OnceValue": func(init func() *int) Lazy[*int] { return NewOnceValue(init) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			leaks.Check(t)
+			var calls atomic.Int32
+			lazy := build(func() *int {
+				calls.Add(1)
+				v := 42
+				return &v
+			})
+			got := make([]*int, 50)
+			var wg sync.WaitGroup
+			for i := range got {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					got[i] = lazy.Get()
+				}()
+			}
+			wg.Wait()
+			if n := calls.Load(); n != 1 {
+				t.Errorf("init called %d times", n)
+			}
+			for _, p := range got {
+				if p != got[0] || *p != 42 {
+					t.Fatal("callers got different values")
+				}
+			}
+		})
+	}
+}
+
+func TestAtomic(t *testing.T) {
+	leaks.Check(t)
+	var calls atomic.Int32
+	fail := true
+	a := NewAtomic(func() (int, error) {
+		n := calls.Add(1)
+		if fail {
+			return 0, errors.New("database down")
+		}
+		return int(n), nil
+	})
+	if _, err := a.Get(); err == nil {
+		t.Fatal("no error from a failing init")
+	}
+	fail = false
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := a.Get(); v != 2 || err != nil {
+				t.Errorf("Get = %d, %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	a.Reset()
+	if v, _ := a.Get(); v != 3 {
+		t.Errorf("after Reset Get = %d, want a new value", v)
+	}
+}
+
+func TestOnceValueRepanics(t *testing.T) {
+	o := NewOnceValue(func() int { panic("no config") })
+	for range 2 {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Get did not panic")
+				}
+			}()
+			o.Get()
+		}()
+	}
+}
+
+func Example() {
+	config := NewOnceValue(func() map[string]string {
+		fmt.Println("loading config")
+		return map[string]string{"region": "eu-west-1"}
+	})
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			config.Get()
+		}()
+	}
+	wg.Wait()
+	fmt.Println(config.Get()["region"])
+	// Output:
+	// loading config
+	// eu-west-1
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package lazyinit
+
+const raceEnabled = false
@@ -0,0 +1,224 @@
+// Package leaderelection simulates lease-based leader election, as done
+// against etcd, ZooKeeper or a Kubernetes Lease, with the lock service
+// kept in memory so that the protocol can be run and tested in one
+// process.
+//
+// A leader holds a lease on a key for a TTL and renews it well before the
+// TTL runs out. The other nodes try to take the lease every RetryPeriod,
+// and get it only once it has expired or been released. A leader that
+// cannot renew for RenewDeadline, which is shorter than the TTL, stops
+// leading on its own: by the time the lease expires and another node takes
+// it, the old leader has already stepped down, so two nodes never act as
+// leader at once, as long as their clocks agree.
+//
+// Every new holder gets a higher Term, which a leader can pass to the
+// systems it writes to as a fencing token, so that they reject a deposed
+// leader that has not noticed yet.
+//
+// Time comes from a Clock, so an election can be driven by a clock.Fake
+// in tests.
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// Errors of LockService.
+var (
+	ErrHeld        = errors.New("leaderelection: lease held by another node")
+	ErrPartitioned = errors.New("leaderelection: node cut off from the lock service")
+)
+
+// Clock is the source of time for a LockService and its nodes. Every
+// clock.Clock is one.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Lease is the state of a key in the LockService.
+type Lease struct {
+	Holder  string
+	Term    uint64
+	Expires time.Time
+}
+
+// LockService is an in-memory store of leases. It stands in for the
+// consistent store of a real deployment, and can cut nodes off from it to
+// simulate a network partition or a node that has hung.
+type LockService struct {
+	clock  Clock
+	mu     sync.Mutex
+	leases map[string]Lease
+	cut    map[string]bool
+}
+
+// NewLockService creates a lock service. A nil clock uses clock.Real.
+func NewLockService(c Clock) *LockService {
+	if c == nil {
+		c = clock.Real
+	}
+	return &LockService{clock: c, leases: make(map[string]Lease), cut: make(map[string]bool)}
+}
+
+// Acquire takes the lease on key for holder, or renews it if holder
+// already has it, until ttl from now. It fails with ErrHeld while another
+// holder's lease has not expired. A new holder gets the next Term.
+func (s *LockService) Acquire(key, holder string, ttl time.Duration) (Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cut[holder] {
+		return Lease{}, ErrPartitioned
+	}
+	now := s.clock.Now()
+	l := s.leases[key]
+	if l.Holder != holder {
+		if l.Holder != "" && now.Before(l.Expires) {
+			return l, ErrHeld
+		}
+		l.Holder = holder
+		l.Term++
+	}
+	l.Expires = now.Add(ttl)
+	s.leases[key] = l
+	return l, nil
+}
+
+// Release ends holder's lease on key at once, so that the next node to try
+// does not have to wait for it to expire. It does nothing if holder does
+// not hold the lease.
+func (s *LockService) Release(key, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cut[holder] {
+		return ErrPartitioned
+	}
+	if l := s.leases[key]; l.Holder == holder {
+		l.Holder = ""
+		s.leases[key] = l
+	}
+	return nil
+}
+
+// Get returns the lease on key, and false if nobody holds it.
+func (s *LockService) Get(key string) (Lease, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.leases[key]
+	return l, l.Holder != "" && s.clock.Now().Before(l.Expires)
+}
+
+// Partition cuts holder off from the service, or reconnects it if cut is
+// false. The calls of a node that is cut off fail with ErrPartitioned.
+func (s *LockService) Partition(holder string, cut bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cut[holder] = cut
+}
+
+// Config configures a Node.
+type Config struct {
+	// Key names the lease the nodes compete for.
+	Key string
+	// TTL is how long a lease lasts without renewal.
+	TTL time.Duration
+	// RenewDeadline is how long a leader keeps leading without a
+	// successful renewal. It must be shorter than TTL; two thirds of it
+	// if zero.
+	RenewDeadline time.Duration
+	// RetryPeriod is how often a leader renews and a follower tries to
+	// take the lease; a fifth of TTL if zero.
+	RetryPeriod time.Duration
+	// OnStartedLeading is called on a goroutine of its own when the node
+	// becomes leader. ctx is cancelled when it stops leading, and the
+	// node waits for the call to return before it tries to lead again.
+	OnStartedLeading func(ctx context.Context, term uint64)
+	// OnStoppedLeading is called after OnStartedLeading has returned.
+	OnStoppedLeading func()
+}
+
+// Node is one candidate in an election.
+type Node struct {
+	id     string
+	svc    *LockService
+	cfg    Config
+	leader atomic.Bool
+}
+
+// NewNode creates a node named id that competes for cfg.Key in svc.
+func NewNode(id string, svc *LockService, cfg Config) *Node {
+	if cfg.RenewDeadline == 0 {
+		cfg.RenewDeadline = cfg.TTL * 2 / 3
+	}
+	if cfg.RetryPeriod == 0 {
+		cfg.RetryPeriod = cfg.TTL / 5
+	}
+	return &Node{id: id, svc: svc, cfg: cfg}
+}
+
+// ID returns the node's name.
+func (n *Node) ID() string { return n.id }
+
+// IsLeader reports whether the node is leading.
+func (n *Node) IsLeader() bool { return n.leader.Load() }
+
+// Run takes part in the election until ctx is done. A leader then stops
+// leading and releases the lease, handing over to another node without
+// making it wait for the TTL. Run returns ctx.Err().
+func (n *Node) Run(ctx context.Context) error {
+	for {
+		if lease, err := n.svc.Acquire(n.cfg.Key, n.id, n.cfg.TTL); err == nil {
+			n.lead(ctx, lease)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-n.svc.clock.After(n.cfg.RetryPeriod):
+		}
+	}
+}
+
+// lead runs the leader's side until ctx is done or renewals have failed
+// for RenewDeadline.
+func (n *Node) lead(ctx context.Context, lease Lease) {
+	c := n.svc.clock
+	leading, stop := context.WithCancel(ctx)
+	defer stop()
+	n.leader.Store(true)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if n.cfg.OnStartedLeading != nil {
+			n.cfg.OnStartedLeading(leading, lease.Term)
+		}
+	}()
+
+	renewed := c.Now()
+	for leading.Err() == nil {
+		select {
+		case <-leading.Done():
+		case <-c.After(n.cfg.RetryPeriod):
+			_, err := n.svc.Acquire(n.cfg.Key, n.id, n.cfg.TTL)
+			switch {
+			case err == nil:
+				renewed = c.Now()
+			case errors.Is(err, ErrHeld), c.Now().Sub(renewed) >= n.cfg.RenewDeadline:
+				// Lost, or about to be lost without us being able
+				// to tell.
+				stop()
+			}
+		}
+	}
+	<-done
+	n.leader.Store(false)
+	if n.cfg.OnStoppedLeading != nil {
+		n.cfg.OnStoppedLeading()
+	}
+	n.svc.Release(n.cfg.Key, n.id)
+}
@@ -0,0 +1,284 @@
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestLockService(t *testing.T) {
+	c := clock.NewFake(epoch)
+	s := NewLockService(c)
+	if l, err := s.Acquire("k", "a", 10*time.Second); err != nil || l.Term != 1 {
+		t.Fatalf("Acquire = %+v, %v", l, err)
+	}
+	if _, err := s.Acquire("k", "b", 10*time.Second); !errors.Is(err, ErrHeld) {
+		t.Errorf("b took a held lease: %v", err)
+	}
+	c.Advance(5 * time.Second)
+	if l, err := s.Acquire("k", "a", 10*time.Second); err != nil || l.Term != 1 || !l.Expires.Equal(epoch.Add(15*time.Second)) {
+		t.Errorf("renewal = %+v, %v", l, err)
+	}
+	c.Advance(15 * time.Second)
+	if _, ok := s.Get("k"); ok {
+		t.Error("expired lease still held")
+	}
+	if l, err := s.Acquire("k", "b", 10*time.Second); err != nil || l.Term != 2 {
+		t.Errorf("b after expiry = %+v, %v", l, err)
+	}
+	s.Release("k", "a") // not a's any more
+	if l, ok := s.Get("k"); !ok || l.Holder != "b" {
+		t.Errorf("a released b's lease: %+v", l)
+	}
+	s.Partition("b", true)
+	if err := s.Release("k", "b"); !errors.Is(err, ErrPartitioned) {
+		t.Errorf("Release from a cut-off node = %v", err)
+	}
+	s.Partition("b", false)
+	s.Release("k", "b")
+	if l, err := s.Acquire("k", "a", time.Second); err != nil || l.Term != 3 {
+		t.Errorf("a after release = %+v, %v", l, err)
+	}
+}
+
+// cluster runs nodes on a fake clock. Between steps every running node is
+// waiting for its next retry, so what the test sees is settled.
+type cluster struct {
+	t       *testing.T
+	clock   *clock.Fake
+	svc     *LockService
+	nodes   []*Node
+	cancels map[string]context.CancelFunc
+	exited  map[string]chan struct{}
+
+	mu      sync.Mutex
+	log     []string
+	leading map[string]bool // OnStartedLeading is running
+}
+
+var config = Config{Key: "leader", TTL: 10 * time.Second, RenewDeadline: 6 * time.Second, RetryPeriod: time.Second}
+
+func newCluster(t *testing.T, n int) *cluster {
+	cl := &cluster{
+		t:       t,
+		clock:   clock.NewFake(epoch),
+		cancels: make(map[string]context.CancelFunc),
+		exited:  make(map[string]chan struct{}),
+		leading: make(map[string]bool),
+	}
+	cl.svc = NewLockService(cl.clock)
+	for i := range n {
+		id := fmt.Sprintf("n%d", i+1)
+		cfg := config
+		cfg.OnStartedLeading = func(ctx context.Context, term uint64) {
+			cl.mu.Lock()
+			cl.leading[id] = true
+			cl.log = append(cl.log, fmt.Sprintf("%s leads term %d at %v", id, term, cl.clock.Since(epoch)))
+			cl.mu.Unlock()
+			<-ctx.Done()
+			cl.mu.Lock()
+			cl.leading[id] = false
+			cl.mu.Unlock()
+		}
+		cfg.OnStoppedLeading = func() {
+			cl.record(fmt.Sprintf("%s stops at %v", id, cl.clock.Since(epoch)))
+		}
+		node := NewNode(id, cl.svc, cfg)
+		ctx, cancel := context.WithCancel(context.Background())
+		cl.nodes = append(cl.nodes, node)
+		cl.cancels[id] = cancel
+		exited := make(chan struct{})
+		cl.exited[id] = exited
+		go func() {
+			defer close(exited)
+			node.Run(ctx)
+		}()
+	}
+	cl.settle()
+	t.Cleanup(cl.stop)
+	return cl
+}
+
+func (cl *cluster) record(event string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.log = append(cl.log, event)
+}
+
+func (cl *cluster) events() []string {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return append([]string(nil), cl.log...)
+}
+
+// settle waits until every node is waiting for its next retry and every
+// leader's OnStartedLeading has begun.
+func (cl *cluster) settle() {
+	cl.clock.BlockUntil(len(cl.nodes))
+	for !cl.agreed() {
+		runtime.Gosched()
+	}
+}
+
+func (cl *cluster) agreed() bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for _, n := range cl.nodes {
+		if n.IsLeader() != cl.leading[n.ID()] {
+			return false
+		}
+	}
+	return true
+}
+
+// step moves time on by n retry periods, checking after each that no two
+// nodes lead.
+func (cl *cluster) step(n int) {
+	cl.t.Helper()
+	for range n {
+		cl.clock.Advance(config.RetryPeriod)
+		cl.settle()
+		if leaders := cl.leaders(); len(leaders) > 1 {
+			cl.t.Fatalf("%v lead at once at %v", leaders, cl.clock.Since(epoch))
+		}
+	}
+}
+
+func (cl *cluster) leaders() []*Node {
+	var leaders []*Node
+	for _, n := range cl.nodes {
+		if n.IsLeader() {
+			leaders = append(leaders, n)
+		}
+	}
+	return leaders
+}
+
+func (cl *cluster) leader() *Node {
+	cl.t.Helper()
+	leaders := cl.leaders()
+	if len(leaders) != 1 {
+		cl.t.Fatalf("%d leaders", len(leaders))
+	}
+	return leaders[0]
+}
+
+// shutdown stops node's Run and drops it from the cluster.
+func (cl *cluster) shutdown(node *Node) {
+	cl.cancels[node.ID()]()
+	<-cl.exited[node.ID()]
+	for i, n := range cl.nodes {
+		if n == node {
+			cl.nodes = append(cl.nodes[:i], cl.nodes[i+1:]...)
+		}
+	}
+}
+
+func (cl *cluster) stop() {
+	for id, cancel := range cl.cancels {
+		cancel()
+		<-cl.exited[id]
+	}
+}
+
+func TestStableLeader(t *testing.T) {
+	leaks.Check(t)
+	cl := newCluster(t, 3)
+	first := cl.leader()
+	cl.step(30)
+	if cl.leader() != first {
+		t.Error("leadership moved while the leader kept renewing")
+	}
+	if got, want := fmt.Sprint(cl.events()), fmt.Sprintf("[%s leads term 1 at 0s]", first.ID()); got != want {
+		t.Errorf("events %s, want %s", got, want)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	leaks.Check(t)
+	cl := newCluster(t, 3)
+	cl.step(3)
+	old := cl.leader()
+	// The leader last renewed at 3s. It gives up at 9s, and the lease it
+	// can no longer renew expires at 13s.
+	cl.svc.Partition(old.ID(), true)
+	cl.step(5)
+	if !old.IsLeader() {
+		t.Fatal("stepped down before RenewDeadline")
+	}
+	cl.step(1)
+	if len(cl.leaders()) != 0 {
+		t.Fatal("a node leads while the old lease is still valid")
+	}
+	cl.step(4)
+	next := cl.leader()
+	if next == old {
+		t.Fatal("the cut-off node still leads")
+	}
+	events := cl.events()
+	want := fmt.Sprintf("[%s leads term 1 at 0s %s stops at 9s %s leads term 2 at 13s]", old.ID(), old.ID(), next.ID())
+	if fmt.Sprint(events) != want {
+		t.Errorf("events %v\nwant   %s", events, want)
+	}
+
+	// Back online, the old leader finds the lease taken and follows.
+	cl.svc.Partition(old.ID(), false)
+	cl.step(20)
+	if cl.leader() != next {
+		t.Error("leadership moved after the partition healed")
+	}
+}
+
+func TestHandover(t *testing.T) {
+	leaks.Check(t)
+	cl := newCluster(t, 2)
+	old := cl.leader()
+	cl.step(2)
+	// A leader that shuts down releases the lease: the other node takes
+	// over at its next retry, not when the TTL runs out.
+	cl.shutdown(old)
+	cl.step(1)
+	next := cl.leader()
+	if next == old {
+		t.Fatal("no new leader")
+	}
+	want := fmt.Sprintf("[%s leads term 1 at 0s %s stops at 2s %s leads term 2 at 3s]", old.ID(), old.ID(), next.ID())
+	if got := fmt.Sprint(cl.events()); got != want {
+		t.Errorf("events %s\nwant   %s", got, want)
+	}
+}
+
+func Example() {
+	svc := NewLockService(nil)
+	elected := make(chan string)
+	node := NewNode("web-1", svc, Config{
+		Key: "cron",
+		TTL: time.Second,
+		OnStartedLeading: func(ctx context.Context, term uint64) {
+			elected <- fmt.Sprintf("leading term %d", term)
+			<-ctx.Done()
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- node.Run(ctx) }()
+	fmt.Println(<-elected)
+	cancel()
+	<-done
+	_, held := svc.Get("cron")
+	fmt.Println("held after shutdown:", held)
+	// Output:
+	// leading term 1
+	// held after shutdown: false
+}
@@ -0,0 +1,150 @@
+// Package leaks shows the usual ways a goroutine leaks, each next to a
+// version that does not, and gives tests a way to prove that they leave
+// no goroutine behind.
+//
+// A goroutine leaks when it blocks forever: on a send nobody receives, on
+// a receive nobody sends to or closes, or on a timer that never fires. It
+// is never collected, and neither is anything its stack refers to. Three
+// shapes come up again and again:
+//
+//   - the forgotten sender, FirstLeaky: a caller gives up on an
+//     unbuffered channel, and the goroutines still trying to send on it
+//     wait for a receiver that is gone;
+//   - the blocked receiver, LeakyCounter: a goroutine ranges over a
+//     channel that nothing ever closes;
+//   - the abandoned timer, AfterLeaky: a goroutine waits on the channel
+//     of a timer that was stopped and so will never fire.
+//
+// Check, VerifyNone and VerifyTestMain look for goroutines left running
+// in the manner of go.uber.org/goleak, so that the tests of every
+// concurrency package here can end by proving they cleaned up.
+package leaks
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Search looks query up on one replica.
+type Search func(query string) string
+
+// FirstLeaky returns the first answer of replicas, or ctx's error if none
+// answers in time. It leaks every replica that answers late: their sends
+// on the unbuffered channel block once FirstLeaky has returned.
+func FirstLeaky(ctx context.Context, query string, replicas ...Search) (string, error) {
+	answers := make(chan string)
+	for _, search := range replicas {
+		go func() { answers <- search(query) }()
+	}
+	select {
+	case a := <-answers:
+		return a, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// First is FirstLeaky with a channel buffered for every replica, so that
+// each can send its answer and exit whether or not anyone receives it.
+func First(ctx context.Context, query string, replicas ...Search) (string, error) {
+	answers := make(chan string, len(replicas))
+	for _, search := range replicas {
+		go func() { answers <- search(query) }()
+	}
+	select {
+	case a := <-answers:
+		return a, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// LeakyCounter adds up numbers on a goroutine of its own. Nothing closes
+// its channel, so the goroutine outlives the counter.
+type LeakyCounter struct {
+	adds  chan int
+	total atomic.Int64
+}
+
+// NewLeakyCounter returns a LeakyCounter with its goroutine running.
+func NewLeakyCounter() *LeakyCounter {
+	c := &LeakyCounter{adds: make(chan int)}
+	go func() {
+		for n := range c.adds {
+			c.total.Add(int64(n))
+		}
+	}()
+	return c
+}
+
+// Add adds n to the total.
+func (c *LeakyCounter) Add(n int) { c.adds <- n }
+
+// Total returns the total so far.
+func (c *LeakyCounter) Total() int64 { return c.total.Load() }
+
+// Counter is LeakyCounter with a Close that ends its goroutine.
+type Counter struct {
+	adds  chan int
+	done  chan struct{}
+	once  sync.Once
+	total atomic.Int64
+}
+
+// NewCounter returns a Counter with its goroutine running. The caller
+// must call Close.
+func NewCounter() *Counter {
+	c := &Counter{adds: make(chan int), done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		for n := range c.adds {
+			c.total.Add(int64(n))
+		}
+	}()
+	return c
+}
+
+// Add adds n to the total. It must not be called after Close.
+func (c *Counter) Add(n int) { c.adds <- n }
+
+// Total returns the total so far.
+func (c *Counter) Total() int64 { return c.total.Load() }
+
+// Close stops the goroutine and waits for it to finish.
+func (c *Counter) Close() {
+	c.once.Do(func() { close(c.adds) })
+	<-c.done
+}
+
+// AfterLeaky calls f after d unless stop is called first. Stopping leaks
+// the goroutine: a stopped timer never sends on its channel.
+func AfterLeaky(d time.Duration, f func()) (stop func()) {
+	t := time.NewTimer(d)
+	go func() {
+		<-t.C
+		f()
+	}()
+	return func() { t.Stop() }
+}
+
+// After is AfterLeaky with its goroutine also waiting for stop.
+func After(d time.Duration, f func()) (stop func()) {
+	t := time.NewTimer(d)
+	stopped := make(chan struct{})
+	var once sync.Once
+	go func() {
+		select {
+		case <-t.C:
+			f()
+		case <-stopped:
+		}
+	}()
+	return func() {
+		once.Do(func() {
+			t.Stop()
+			close(stopped)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package leaks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const pkg = "github.com/crazybber/go-patterns/concurrency/leaks."
+
+// The leaky examples leak on purpose; the goroutines they leave behind
+// are expected to be running when the tests end.
+func TestMain(m *testing.M) {
+	VerifyTestMain(m,
+		IgnoreAnyFunction(pkg+"FirstLeaky.func1"),
+		IgnoreAnyFunction(pkg+"NewLeakyCounter.func1"),
+		IgnoreAnyFunction(pkg+"AfterLeaky.func1"),
+	)
+}
+
+// leaked runs fn and returns the goroutines it left running.
+func leaked(t *testing.T, fn func()) []Goroutine {
+	t.Helper()
+	before := IgnoreCurrent()
+	fn()
+	defer func(wait time.Duration) { Wait = wait }(Wait)
+	Wait = 50 * time.Millisecond
+	return Find(before)
+}
+
+func wantLeak(t *testing.T, gs []Goroutine, top, state string) {
+	t.Helper()
+	if len(gs) != 1 || gs[0].Top != pkg+top || gs[0].State != state {
+		t.Fatalf("leaked %v, want one goroutine in %s on %q", gs, top, state)
+	}
+	if !strings.Contains(gs[0].Stack, "created by "+pkg) {
+		t.Errorf("stack does not say where the goroutine was started:\n%s", gs[0].Stack)
+	}
+}
+
+func replicas(gate chan struct{}) []Search {
+	fast := func(q string) string { return "fast " + q }
+	slow := func(q string) string { <-gate; return "slow " + q }
+	return []Search{fast, slow}
+}
+
+func TestForgottenSender(t *testing.T) {
+	gate := make(chan struct{})
+	defer close(gate)
+	var a string
+	gs := leaked(t, func() {
+		a, _ = FirstLeaky(context.Background(), "go", replicas(gate)...)
+		gate <- struct{}{}
+	})
+	if a != "fast go" {
+		t.Errorf("FirstLeaky = %q", a)
+	}
+	wantLeak(t, gs, "FirstLeaky.func1", "chan send")
+
+	Check(t)
+	if a, _ := First(context.Background(), "go", replicas(gate)...); a != "fast go" {
+		t.Errorf("First = %q", a)
+	}
+	gate <- struct{}{}
+}
+
+func TestForgottenSenderTimeout(t *testing.T) {
+	Check(t)
+	gate := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	slow := func(q string) string { <-gate; return q }
+	if _, err := First(ctx, "go", slow, slow); err != context.DeadlineExceeded {
+		t.Errorf("First = %v", err)
+	}
+	close(gate)
+}
+
+func TestBlockedReceiver(t *testing.T) {
+	var leaky *LeakyCounter
+	gs := leaked(t, func() {
+		leaky = NewLeakyCounter()
+		leaky.Add(1)
+		leaky.Add(2)
+	})
+	if leaky.Total() != 3 {
+		t.Errorf("LeakyCounter total = %d", leaky.Total())
+	}
+	wantLeak(t, gs, "NewLeakyCounter.func1", "chan receive")
+
+	Check(t)
+	c := NewCounter()
+	c.Add(1)
+	c.Add(2)
+	c.Close()
+	c.Close()
+	if c.Total() != 3 {
+		t.Errorf("Counter total = %d", c.Total())
+	}
+}
+
+func TestAbandonedTimer(t *testing.T) {
+	gs := leaked(t, func() {
+		stop := AfterLeaky(time.Hour, func() {})
+		stop()
+	})
+	wantLeak(t, gs, "AfterLeaky.func1", "chan receive")
+
+	Check(t)
+	stop := After(time.Hour, func() {})
+	stop()
+	stop()
+	fired := make(chan struct{})
+	After(time.Millisecond, func() { close(fired) })
+	<-fired
+}
+
+func TestParse(t *testing.T) {
+	g, ok := parse(`goroutine 42 [chan send, 3 minutes]:
+example.com/p.f.func1()
+	/src/p/p.go:10 +0x1d
+created by example.com/p.f in goroutine 1
+	/src/p/p.go:8 +0x66`)
+	if !ok || g.ID != 42 || g.State != "chan send" || g.Top != "example.com/p.f.func1" {
+		t.Errorf("parse = %+v, %v", g, ok)
+	}
+	if _, ok := parse("not a trace"); ok {
+		t.Error("parsed garbage")
+	}
+	if !IgnoreAnyFunction("example.com/p.f.func1")(g) || IgnoreTopFunction("example.com/p.f")(g) {
+		t.Error("options do not match the stack")
+	}
+}
+
+func TestSnapshotSkipsCaller(t *testing.T) {
+	for _, g := range Snapshot() {
+		if strings.Contains(g.Stack, "TestSnapshotSkipsCaller") {
+			t.Errorf("Snapshot includes its caller:\n%s", g.Stack)
+		}
+	}
+}
+
+func ExampleFind() {
+	before := IgnoreCurrent()
+	stop := AfterLeaky(time.Hour, func() {})
+	stop()
+	Wait = 10 * time.Millisecond
+	for _, g := range Find(before) {
+		fmt.Println(strings.TrimPrefix(g.Top, pkg), g.State)
+	}
+	Wait = time.Second
+	// Output:
+	// AfterLeaky.func1 chan receive
+}
@@ -0,0 +1,177 @@
+package leaks
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Goroutine is a goroutine as runtime.Stack describes it.
+type Goroutine struct {
+	ID int
+	// State is what it is doing, such as "running" or "chan send".
+	State string
+	// Top is the function it is in, and Stack its whole trace.
+	Top   string
+	Stack string
+}
+
+func (g Goroutine) String() string { return g.Stack }
+
+// Snapshot returns every goroutine but the caller's.
+func Snapshot() []Goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var gs []Goroutine
+	// The caller's goroutine comes first.
+	for i, trace := range bytes.Split(buf, []byte("\n\n")) {
+		if g, ok := parse(string(trace)); ok && i > 0 {
+			gs = append(gs, g)
+		}
+	}
+	return gs
+}
+
+// parse parses a trace of the form
+//
+//	goroutine 7 [chan send, 2 minutes]:
+//	pkg.f(...)
+//		/path/file.go:12 +0x2a
+//	created by pkg.g in goroutine 1
+//		/path/file.go:30 +0x40
+func parse(trace string) (Goroutine, bool) {
+	header, body, _ := strings.Cut(strings.TrimSpace(trace), "\n")
+	rest, ok := strings.CutPrefix(header, "goroutine ")
+	if !ok {
+		return Goroutine{}, false
+	}
+	id, state, _ := strings.Cut(rest, " ")
+	g := Goroutine{Stack: strings.TrimSpace(trace)}
+	g.ID, _ = strconv.Atoi(id)
+	state = strings.TrimSuffix(strings.TrimPrefix(state, "["), "]:")
+	g.State, _, _ = strings.Cut(state, ",")
+	top, _, _ := strings.Cut(body, "\n")
+	if i := strings.LastIndex(top, "("); i > 0 {
+		top = top[:i]
+	}
+	g.Top = top
+	return g, true
+}
+
+// Option filters out goroutines that are expected to be running.
+type Option func(g Goroutine) bool
+
+// IgnoreTopFunction ignores goroutines sitting in the named function,
+// such as "internal/poll.runtime_pollWait".
+func IgnoreTopFunction(name string) Option {
+	return func(g Goroutine) bool { return g.Top == name }
+}
+
+// IgnoreAnyFunction ignores goroutines with the named function anywhere
+// on their stack.
+func IgnoreAnyFunction(name string) Option {
+	return func(g Goroutine) bool { return strings.Contains(g.Stack, "\n"+name+"(") }
+}
+
+// IgnoreCurrent ignores the goroutines running now.
+func IgnoreCurrent() Option {
+	var ids []int
+	for _, g := range Snapshot() {
+		ids = append(ids, g.ID)
+	}
+	return func(g Goroutine) bool { return slices.Contains(ids, g.ID) }
+}
+
+// system are goroutines the runtime and the testing package start.
+var system = []Option{
+	IgnoreAnyFunction("os/signal.signal_recv"),
+	IgnoreAnyFunction("os/signal.loop"),
+	IgnoreTopFunction("runtime.ensureSigM.func1"),
+	IgnoreAnyFunction("testing.(*T).Run"),
+	IgnoreAnyFunction("testing.(*T).Parallel"),
+	IgnoreAnyFunction("testing.tRunner.func1"),
+	IgnoreAnyFunction("testing.runTests"),
+	IgnoreAnyFunction("testing.(*M).startAlarm.func1"),
+}
+
+// Wait is how long Find gives goroutines to finish.
+var Wait = time.Second
+
+// Find returns the goroutines running that no option ignores, after
+// waiting up to Wait for them to finish.
+func Find(opts ...Option) []Goroutine {
+	opts = append(opts, system...)
+	deadline := time.Now().Add(Wait)
+	for delay := 100 * time.Microsecond; ; delay = min(2*delay, 10*time.Millisecond) {
+		var found []Goroutine
+		for _, g := range Snapshot() {
+			if !slices.ContainsFunc(opts, func(ignore Option) bool { return ignore(g) }) {
+				found = append(found, g)
+			}
+		}
+		if len(found) == 0 || time.Now().After(deadline) {
+			return found
+		}
+		time.Sleep(delay)
+	}
+}
+
+// report describes leaked goroutines.
+func report(gs []Goroutine) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "found %d unexpected goroutines:", len(gs))
+	for _, g := range gs {
+		b.WriteString("\n\n")
+		b.WriteString(g.Stack)
+	}
+	return b.String()
+}
+
+// Check fails t at its end if goroutines started since Check was called
+// are still running, listing their stacks.
+func Check(t testing.TB, opts ...Option) {
+	t.Helper()
+	opts = append(opts, IgnoreCurrent())
+	t.Cleanup(func() {
+		if gs := Find(opts...); len(gs) > 0 {
+			t.Error(report(gs))
+		}
+	})
+}
+
+// VerifyNone fails t if goroutines are running other than those of the
+// runtime, the testing package and the ones opts ignore.
+func VerifyNone(t testing.TB, opts ...Option) {
+	t.Helper()
+	if gs := Find(opts...); len(gs) > 0 {
+		t.Error(report(gs))
+	}
+}
+
+// VerifyTestMain runs the tests of m and then fails the test binary if
+// goroutines are left running. It is meant to be called from TestMain:
+//
+//	func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+func VerifyTestMain(m *testing.M, opts ...Option) {
+	code := m.Run()
+	if code == 0 {
+		if gs := Find(opts...); len(gs) > 0 {
+			fmt.Fprintln(os.Stderr, "leaks:", report(gs))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,231 @@
+// Package maintenance runs background chores, such as cache sweeps, log
+// compaction or metrics flushes, without letting them crowd out the work
+// that matters.
+//
+// Chores do their work in small steps. A single Runner goroutine gives
+// them turns round-robin, each turn a time slice of steps, and keeps the
+// total under a CPU budget: a fraction of wall time it may spend on
+// chores. Once the budget is used up, the Runner sleeps until it has
+// earned more. Maintenance therefore never takes more than one CPU, never
+// holds it for more than a slice at a time, and on average takes no more
+// than its budget. Between steps it yields, so foreground goroutines
+// waiting for a CPU get it.
+//
+// Because chores work in steps, a pass over a big cache continues in the
+// next turn where it left off instead of stalling everything until it is
+// done.
+package maintenance
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Chore is a piece of background work split into steps.
+type Chore interface {
+	// Step does a small amount of work, well under a slice, and reports
+	// whether the current pass is complete. After a complete pass the
+	// chore rests until its interval has passed.
+	Step(ctx context.Context) (done bool, err error)
+}
+
+// ChoreFunc adapts a function to a Chore.
+type ChoreFunc func(ctx context.Context) (bool, error)
+
+func (f ChoreFunc) Step(ctx context.Context) (bool, error) { return f(ctx) }
+
+// Clock is the source of time for a Runner.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Options configures a Runner.
+type Options struct {
+	// Budget is the fraction of wall time chores may use. It defaults to
+	// 0.1.
+	Budget float64
+	// Slice is the longest turn a chore gets. It defaults to 2ms.
+	Slice time.Duration
+	// OnError is called with the errors of chores. A failed step ends the
+	// chore's pass.
+	OnError func(chore string, err error)
+	// Clock defaults to the wall clock.
+	Clock Clock
+}
+
+func (o *Options) defaults() {
+	if o.Budget <= 0 || o.Budget > 1 {
+		o.Budget = 0.1
+	}
+	if o.Slice <= 0 {
+		o.Slice = 2 * time.Millisecond
+	}
+	if o.Clock == nil {
+		o.Clock = realClock{}
+	}
+}
+
+// Stats counts what a chore did.
+type Stats struct {
+	Passes, Steps, Errors int
+	// Busy is the time spent in the chore's steps.
+	Busy time.Duration
+}
+
+type entry struct {
+	name   string
+	every  time.Duration
+	chore  Chore
+	next   time.Time // when the next pass is due
+	inPass bool
+	stats  Stats
+}
+
+// Runner gives chores their turns.
+type Runner struct {
+	opts Options
+	wake chan struct{}
+
+	mu      sync.Mutex
+	entries []*entry
+	turn    int // index of the entry to consider first
+}
+
+// New returns a Runner. Register chores and call Run.
+func New(opts Options) *Runner {
+	opts.defaults()
+	return &Runner{opts: opts, wake: make(chan struct{}, 1)}
+}
+
+// Register adds a chore that starts a pass every interval, the first right
+// away. It may be called while Run is running.
+func (r *Runner) Register(name string, every time.Duration, c Chore) {
+	r.mu.Lock()
+	r.entries = append(r.entries, &entry{name: name, every: every, chore: c, next: r.opts.Clock.Now()})
+	r.mu.Unlock()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stats returns the counters of every chore by name.
+func (r *Runner) Stats() map[string]Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := make(map[string]Stats, len(r.entries))
+	for _, e := range r.entries {
+		m[e.name] = e.stats
+	}
+	return m
+}
+
+// pick returns the next chore due, round-robin, or the time the earliest
+// one will be.
+func (r *Runner) pick(now time.Time) (*entry, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var earliest time.Time
+	for i := range r.entries {
+		e := r.entries[(r.turn+i)%len(r.entries)]
+		if e.inPass || !e.next.After(now) {
+			r.turn = (r.turn + i + 1) % len(r.entries)
+			return e, now
+		}
+		if earliest.IsZero() || e.next.Before(earliest) {
+			earliest = e.next
+		}
+	}
+	return nil, earliest
+}
+
+// Run gives chores their turns until ctx is done.
+func (r *Runner) Run(ctx context.Context) error {
+	clock := r.opts.Clock
+	budget, slice := r.opts.Budget, r.opts.Slice
+	// credit is the chore time earned and not yet spent. It starts at one
+	// slice and is capped there, so idle time does not save up for a
+	// long burst later.
+	credit, last := slice, clock.Now()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		now := clock.Now()
+		credit = min(credit+time.Duration(budget*float64(now.Sub(last))), slice)
+		last = now
+
+		if credit < 0 {
+			r.wait(ctx, time.Duration(float64(-credit)/budget))
+			continue
+		}
+		e, at := r.pick(now)
+		if e == nil {
+			d := time.Duration(-1) // nothing registered: wait for Register
+			if !at.IsZero() {
+				d = at.Sub(now)
+			}
+			r.wait(ctx, d)
+			continue
+		}
+		credit -= r.step(ctx, e)
+	}
+}
+
+// wait sleeps for d, or until Register or ctx wakes it up. A negative d
+// waits without a timeout.
+func (r *Runner) wait(ctx context.Context, d time.Duration) {
+	var timeout <-chan time.Time
+	if d >= 0 {
+		timeout = r.opts.Clock.After(d)
+	}
+	select {
+	case <-timeout:
+	case <-r.wake:
+	case <-ctx.Done():
+	}
+}
+
+// step gives e one turn of up to a slice and returns the time used.
+func (r *Runner) step(ctx context.Context, e *entry) time.Duration {
+	clock := r.opts.Clock
+	start := clock.Now()
+	var st Stats
+	done := false
+	for !done && clock.Now().Sub(start) < r.opts.Slice && ctx.Err() == nil {
+		var err error
+		done, err = e.chore.Step(ctx)
+		st.Steps++
+		if err != nil {
+			st.Errors++
+			done = true
+			if r.opts.OnError != nil {
+				r.opts.OnError(e.name, err)
+			}
+		}
+		runtime.Gosched()
+	}
+	end := clock.Now()
+	used := end.Sub(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.inPass = !done
+	if done {
+		st.Passes++
+		e.next = end.Add(e.every)
+	}
+	e.stats.Steps += st.Steps
+	e.stats.Errors += st.Errors
+	e.stats.Passes += st.Passes
+	e.stats.Busy += used
+	return used
+}
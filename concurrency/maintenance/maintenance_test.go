@@ -0,0 +1,243 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// simClock is simulated time. Chores advance it by the work they pretend
+// to do and waiting advances it by the wait, so a run is deterministic and
+// takes no real time. The run is cancelled once the clock passes end.
+type simClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	end    time.Time
+	cancel context.CancelFunc
+}
+
+func newSim(horizon time.Duration) (*simClock, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Unix(0, 0)
+	return &simClock{now: start, end: start.Add(horizon), cancel: cancel}, ctx
+}
+
+func (c *simClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *simClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	over := !c.now.Before(c.end)
+	c.mu.Unlock()
+	if over {
+		c.cancel()
+	}
+}
+
+func (c *simClock) After(d time.Duration) <-chan time.Time {
+	c.advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
+// work is a chore whose steps take cost each and whose passes take steps
+// steps; steps of 0 never finish a pass.
+func work(c *simClock, cost time.Duration, steps int) Chore {
+	n := 0
+	return ChoreFunc(func(context.Context) (bool, error) {
+		c.advance(cost)
+		n++
+		return steps > 0 && n%steps == 0, nil
+	})
+}
+
+func TestBudgetAndFairness(t *testing.T) {
+	clock, ctx := newSim(10 * time.Second)
+	r := New(Options{Budget: 0.1, Slice: 2 * time.Millisecond, Clock: clock})
+	// Three chores that always have work, with different step sizes.
+	r.Register("sweep", 0, work(clock, 100*time.Microsecond, 0))
+	r.Register("compact", 0, work(clock, 700*time.Microsecond, 0))
+	r.Register("flush", 0, work(clock, 2*time.Millisecond, 0))
+	r.Run(ctx)
+
+	var total time.Duration
+	for name, st := range r.Stats() {
+		total += st.Busy
+		// A turn can overrun the slice by up to one step.
+		if st.Busy < 300*time.Millisecond || st.Busy > 450*time.Millisecond {
+			t.Errorf("%s was busy for %v, want about a third of 1s", name, st.Busy)
+		}
+	}
+	if total < 950*time.Millisecond || total > 1050*time.Millisecond {
+		t.Errorf("chores were busy for %v of 10s, want about 1s", total)
+	}
+}
+
+func TestPasses(t *testing.T) {
+	clock, ctx := newSim(10*time.Second + time.Millisecond)
+	r := New(Options{Budget: 0.5, Slice: time.Millisecond, Clock: clock})
+	// A pass takes 25 steps of 200µs: five turns.
+	r.Register("sweep", time.Second, work(clock, 200*time.Microsecond, 25))
+	r.Run(ctx)
+
+	st := r.Stats()["sweep"]
+	if st.Passes != 10 || st.Steps != 250 {
+		t.Errorf("stats %+v, want 10 passes of 25 steps", st)
+	}
+}
+
+func TestErrorEndsPass(t *testing.T) {
+	clock, ctx := newSim(3500 * time.Millisecond)
+	var got []string
+	r := New(Options{
+		Clock:   clock,
+		OnError: func(chore string, err error) { got = append(got, fmt.Sprint(chore, ": ", err)) },
+	})
+	r.Register("flush", time.Second, ChoreFunc(func(context.Context) (bool, error) {
+		clock.advance(time.Millisecond)
+		return false, errors.New("backend down")
+	}))
+	r.Run(ctx)
+
+	want := slices.Repeat([]string{"flush: backend down"}, 4)
+	if !slices.Equal(got, want) {
+		t.Errorf("errors %q, want %q", got, want)
+	}
+	if st := r.Stats()["flush"]; st.Passes != 4 || st.Errors != 4 {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+func TestRegisterWhileIdle(t *testing.T) {
+	r := New(Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	ran := make(chan struct{})
+	r.Register("once", time.Hour, ChoreFunc(func(context.Context) (bool, error) {
+		close(ran)
+		return true, nil
+	}))
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("chore registered during Run never ran")
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v", err)
+	}
+}
+
+// spin burns CPU for d.
+func spin(d time.Duration) {
+	for start := time.Now(); time.Since(start) < d; {
+	}
+}
+
+// lateness runs a foreground loop on one CPU for a while next to
+// maintenance and returns how late its 1ms timers fired, sorted.
+func lateness(maintain func(ctx context.Context)) []time.Duration {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		maintain(ctx)
+	}()
+
+	var late []time.Duration
+	for i := 0; i < 200; i++ {
+		start := time.Now()
+		time.Sleep(time.Millisecond)
+		late = append(late, time.Since(start)-time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+	slices.Sort(late)
+	return late
+}
+
+// TestForegroundLatency compares the foreground on one CPU next to chores
+// run in a plain loop with the same chores under a Runner.
+func TestForegroundLatency(t *testing.T) {
+	if testing.Short() {
+		t.Skip("measures real time")
+	}
+	step := func(context.Context) (bool, error) {
+		spin(500 * time.Microsecond)
+		return false, nil
+	}
+	naive := lateness(func(ctx context.Context) {
+		for ctx.Err() == nil {
+			step(ctx)
+		}
+	})
+	budgeted := lateness(func(ctx context.Context) {
+		r := New(Options{Budget: 0.1, Slice: time.Millisecond})
+		r.Register("sweep", 0, ChoreFunc(step))
+		r.Run(ctx)
+	})
+	p90 := func(l []time.Duration) time.Duration { return l[len(l)*9/10] }
+	t.Logf("p90 foreground lateness: plain loop %v, runner %v", p90(naive), p90(budgeted))
+	if p90(budgeted) >= p90(naive) {
+		t.Errorf("runner p90 lateness %v, plain loop %v", p90(budgeted), p90(naive))
+	}
+}
+
+func Example() {
+	r := New(Options{Budget: 0.05, Slice: time.Millisecond})
+
+	// An incremental sweep over a cache: a few keys per step, resuming
+	// where the last turn stopped.
+	cache := map[int]time.Time{}
+	for i := range 1000 {
+		cache[i] = time.Now().Add(-time.Duration(i%2) * time.Hour)
+	}
+	var mu sync.Mutex
+	cursor := 0
+	r.Register("evict", time.Minute, ChoreFunc(func(context.Context) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for end := cursor + 100; cursor < end && cursor < 1000; cursor++ {
+			if time.Since(cache[cursor]) > 30*time.Minute {
+				delete(cache, cursor)
+			}
+		}
+		if cursor < 1000 {
+			return false, nil
+		}
+		cursor = 0
+		return true, nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for r.Stats()["evict"].Passes == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	r.Run(ctx)
+
+	mu.Lock()
+	fmt.Println(len(cache), "entries left")
+	mu.Unlock()
+	// Output: 500 entries left
+}
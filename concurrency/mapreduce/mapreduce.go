@@ -0,0 +1,83 @@
+// Package mapreduce maps inputs in parallel and reduces the results on one
+// goroutine.
+//
+// MapReduce feeds the inputs to a bounded number of workers, each calling
+// the map function on one input at a time, and folds every mapped value
+// into an accumulator as it arrives. Only the map function runs
+// concurrently: the reduce function runs on the caller's goroutine, so the
+// accumulator needs no locking, but it sees values in the order they
+// finish, not the order of the inputs, and must not depend on it.
+//
+// The workers run in a scope.Scope, so that the first error cancels the
+// others and stops the feeding of inputs, and a panic in the map function
+// is raised again in the caller rather than crashing the process.
+package mapreduce
+
+import (
+	"context"
+	"iter"
+	"runtime"
+	"sync"
+
+	"github.com/crazybber/go-patterns/concurrency/scope"
+)
+
+// MapReduce calls mapper on every input with up to workers calls at once,
+// GOMAXPROCS if workers is not positive, and returns the mapped values
+// folded into initial by reducer. It returns the first error of mapper,
+// or of ctx, and no result.
+func MapReduce[I, M, R any](ctx context.Context, inputs iter.Seq[I], workers int,
+	mapper func(context.Context, I) (M, error), reducer func(acc R, m M) R, initial R) (R, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	s := scope.New(ctx)
+	ins := make(chan I)
+	outs := make(chan M, workers)
+
+	s.Go(func(ctx context.Context) error {
+		defer close(ins)
+		for in := range inputs {
+			select {
+			case ins <- in:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	var mapping sync.WaitGroup
+	for range workers {
+		mapping.Add(1)
+		s.Go(func(ctx context.Context) error {
+			defer mapping.Done()
+			for in := range ins {
+				m, err := mapper(ctx, in)
+				if err != nil {
+					return err
+				}
+				select {
+				case outs <- m:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	}
+	s.Go(func(context.Context) error {
+		mapping.Wait()
+		close(outs)
+		return nil
+	})
+
+	acc := initial
+	for m := range outs {
+		acc = reducer(acc, m)
+	}
+	if err := s.Wait(); err != nil {
+		var zero R
+		return zero, err
+	}
+	return acc, nil
+}
@@ -0,0 +1,237 @@
+package mapreduce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func square(_ context.Context, n int) (int, error) { return n * n, nil }
+func add(acc, n int) int                           { return acc + n }
+
+func TestMapReduce(t *testing.T) {
+	leaks.Check(t)
+	ctx := context.Background()
+	inputs := make([]int, 100)
+	for i := range inputs {
+		inputs[i] = i + 1
+	}
+	for _, workers := range []int{0, 1, 3, 100} {
+		got, err := MapReduce(ctx, slices.Values(inputs), workers, square, add, 0)
+		if err != nil || got != 338350 {
+			t.Errorf("%d workers: %d, %v", workers, got, err)
+		}
+	}
+	got, err := MapReduce(ctx, slices.Values([]int(nil)), 2, square, add, 7)
+	if err != nil || got != 7 {
+		t.Errorf("no inputs: %d, %v", got, err)
+	}
+}
+
+func TestBounded(t *testing.T) {
+	leaks.Check(t)
+	const workers = 4
+	var running, peak atomic.Int64
+	_, err := MapReduce(context.Background(), slices.Values(make([]int, 50)), workers,
+		func(context.Context, int) (int, error) {
+			n := running.Add(1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			return 0, nil
+		}, add, 0)
+	if err != nil || peak.Load() != workers {
+		t.Errorf("peak %d, %v", peak.Load(), err)
+	}
+}
+
+func TestError(t *testing.T) {
+	leaks.Check(t)
+	bad := errors.New("bad input")
+	var mapped atomic.Int64
+	// An endless input: the error must stop the feeding.
+	naturals := func(yield func(int) bool) {
+		for i := 0; yield(i); i++ {
+		}
+	}
+	_, err := MapReduce(context.Background(), naturals, 3, func(ctx context.Context, n int) (int, error) {
+		mapped.Add(1)
+		if n == 10 {
+			return 0, bad
+		}
+		return n, nil
+	}, add, 0)
+	if err != bad {
+		t.Errorf("err = %v", err)
+	}
+	if n := mapped.Load(); n > 20 {
+		t.Errorf("mapped %d inputs after the error", n)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	leaks.Check(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	naturals := func(yield func(int) bool) {
+		for i := 0; yield(i); i++ {
+		}
+	}
+	_, err := MapReduce(ctx, naturals, 2, square, func(acc, n int) int {
+		if acc > 1000 {
+			cancel()
+		}
+		return acc + n
+	}, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	leaks.Check(t)
+	defer func() {
+		var p *recovery.PanicError
+		if err, _ := recover().(error); !errors.As(err, &p) || p.Value != "boom" {
+			t.Errorf("recovered %v", err)
+		}
+	}()
+	MapReduce(context.Background(), slices.Values([]int{1, 2, 3}), 2, func(_ context.Context, n int) (int, error) {
+		if n == 2 {
+			panic("boom")
+		}
+		return n, nil
+	}, add, 0)
+	t.Error("no panic")
+}
+
+// corpus writes n files of words in dir and returns their paths.
+func corpus(tb testing.TB, n, words int) []string {
+	tb.Helper()
+	dir := tb.TempDir()
+	vocabulary := strings.Fields("the quick brown fox jumps over the lazy dog again and again")
+	paths := make([]string, n)
+	for i := range paths {
+		var b strings.Builder
+		for w := range words {
+			b.WriteString(vocabulary[(i+w*7)%len(vocabulary)])
+			if w%10 == 9 {
+				b.WriteString(".\n")
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+		paths[i] = filepath.Join(dir, fmt.Sprint("f", i, ".txt"))
+		if err := os.WriteFile(paths[i], []byte(b.String()), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func TestWordCount(t *testing.T) {
+	leaks.Check(t)
+	dir := t.TempDir()
+	for name, text := range map[string]string{
+		"a.txt": "The cat sat on the mat.",
+		"b.txt": "\"The mat?\" said the CAT -- and sat.",
+		"c.txt": "",
+	} {
+		os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644)
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.txt"))
+	got, err := WordCount(context.Background(), paths, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"the": 4, "cat": 2, "sat": 2, "on": 1, "mat": 2, "said": 1, "and": 1}
+	if !maps.Equal(got, want) {
+		t.Errorf("counts %v", got)
+	}
+
+	_, err = WordCount(context.Background(), append(paths, filepath.Join(dir, "missing.txt")), 2)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: %v", err)
+	}
+
+	// Parallel and sequential counts agree on a larger corpus.
+	paths = corpus(t, 20, 1000)
+	par, err := WordCount(context.Background(), paths, 4)
+	if err != nil || !maps.Equal(par, sequential(paths)) {
+		t.Errorf("parallel counts differ: %v", err)
+	}
+}
+
+// sequential counts the words of paths on one goroutine.
+func sequential(paths []string) map[string]int {
+	acc := map[string]int{}
+	for _, p := range paths {
+		counts, err := countFile(context.Background(), p)
+		if err != nil {
+			panic(err)
+		}
+		acc = merge(acc, counts)
+	}
+	return acc
+}
+
+func BenchmarkWordCount(b *testing.B) {
+	paths := corpus(b, 64, 20000)
+	b.Run("sequential", func(b *testing.B) {
+		for range b.N {
+			sequential(paths)
+		}
+	})
+	for _, workers := range []int{2, 4, runtime.GOMAXPROCS(0)} {
+		b.Run(fmt.Sprint("workers=", workers), func(b *testing.B) {
+			for range b.N {
+				if _, err := WordCount(context.Background(), paths, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkOverhead maps inputs too cheap to be worth parallelizing, to
+// show what the channels and goroutines cost.
+func BenchmarkOverhead(b *testing.B) {
+	inputs := make([]int, 10000)
+	b.Run("sequential", func(b *testing.B) {
+		for range b.N {
+			acc := 0
+			for _, n := range inputs {
+				m, _ := square(context.Background(), n)
+				acc = add(acc, m)
+			}
+		}
+	})
+	b.Run("mapreduce", func(b *testing.B) {
+		for range b.N {
+			MapReduce(context.Background(), slices.Values(inputs), 0, square, add, 0)
+		}
+	})
+}
+
+func ExampleMapReduce() {
+	words := []string{"map", "reduce", "in", "parallel"}
+	longest, _ := MapReduce(context.Background(), slices.Values(words), 2,
+		func(_ context.Context, w string) (int, error) { return len(w), nil },
+		func(acc, n int) int { return max(acc, n) }, 0)
+	fmt.Println(longest)
+	// Output: 8
+}
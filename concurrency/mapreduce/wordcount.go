@@ -0,0 +1,51 @@
+package mapreduce
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// WordCount counts the words of the files at paths, reading up to workers
+// files at once. Words are compared lower-cased, with the punctuation
+// around them trimmed.
+func WordCount(ctx context.Context, paths []string, workers int) (map[string]int, error) {
+	return MapReduce(ctx, slices.Values(paths), workers, countFile, merge, map[string]int{})
+}
+
+func countFile(ctx context.Context, path string) (map[string]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	counts := map[string]int{}
+	sc := bufio.NewScanner(f)
+	sc.Split(bufio.ScanWords)
+	for n := 0; sc.Scan(); n++ {
+		// Large files notice a cancellation before they are read through.
+		if n%4096 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if w := normalize(sc.Text()); w != "" {
+			counts[w]++
+		}
+	}
+	return counts, sc.Err()
+}
+
+func normalize(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}))
+}
+
+func merge(acc, counts map[string]int) map[string]int {
+	for w, n := range counts {
+		acc[w] += n
+	}
+	return acc
+}
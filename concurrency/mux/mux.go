@@ -0,0 +1,209 @@
+// Package mux fans one producer channel out to a changing set of consumers.
+//
+// Consumers subscribe and unsubscribe while values flow. Each one chooses
+// what happens when it falls behind: block the producer, drop the value it
+// is offered, or drop the oldest value it has not read yet. A Mux can also
+// remember the last few values, so a consumer joining late starts with some
+// history instead of an empty channel.
+package mux
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy decides what a full subscription does with a new value.
+type Policy int
+
+const (
+	// Block waits until the consumer has room, holding up every other
+	// consumer meanwhile. The consumer sees every value.
+	Block Policy = iota
+	// DropNewest discards the new value.
+	DropNewest
+	// DropOldest discards the oldest unread value to make room.
+	DropOldest
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropNewest:
+		return "drop newest"
+	case DropOldest:
+		return "drop oldest"
+	}
+	return "unknown"
+}
+
+// Options configures a Mux.
+type Options struct {
+	// History is the number of recent values kept for replay to late
+	// joiners.
+	History int
+}
+
+// SubOptions configures a subscription.
+type SubOptions struct {
+	// Buffer is the capacity of the subscription's channel. The drop
+	// policies need room to drop from, so for them it is at least 1.
+	Buffer int
+	// Policy applies when the buffer is full.
+	Policy Policy
+	// Replay is the number of recent values, up to the Mux's History,
+	// delivered first. The channel gets room for them on top of Buffer.
+	Replay int
+}
+
+// Mux distributes the values of a source channel to its subscriptions.
+type Mux[T any] struct {
+	src <-chan T
+
+	mu      sync.Mutex
+	subs    map[*Subscription[T]]struct{}
+	history *ring[T]
+	closed  bool
+}
+
+// New returns a Mux for src. Values flow once Run is called.
+func New[T any](src <-chan T, opts Options) *Mux[T] {
+	return &Mux[T]{
+		src:     src,
+		subs:    make(map[*Subscription[T]]struct{}),
+		history: newRing[T](opts.History),
+	}
+}
+
+// Run distributes values until src is closed or ctx is done, and then
+// closes every subscription.
+func (m *Mux[T]) Run(ctx context.Context) error {
+	defer m.shutdown()
+	for {
+		select {
+		case v, ok := <-m.src:
+			if !ok {
+				return nil
+			}
+			m.publish(ctx, v)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (m *Mux[T]) publish(ctx context.Context, v T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.history.push(v)
+	for s := range m.subs {
+		s.offer(ctx, v)
+	}
+}
+
+func (m *Mux[T]) shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for s := range m.subs {
+		delete(m.subs, s)
+		close(s.c)
+	}
+}
+
+// Len returns the number of subscriptions.
+func (m *Mux[T]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.subs)
+}
+
+// Subscribe adds a consumer. Its channel receives the replayed values and
+// then every value published from now on, subject to its policy. After Run
+// has returned the channel is closed right away.
+func (m *Mux[T]) Subscribe(opts SubOptions) *Subscription[T] {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if opts.Policy != Block {
+		opts.Buffer = max(opts.Buffer, 1)
+	}
+	replay := m.history.last(opts.Replay)
+	c := make(chan T, max(opts.Buffer, 0)+len(replay))
+	for _, v := range replay {
+		c <- v
+	}
+	s := &Subscription[T]{C: c, c: c, m: m, policy: opts.Policy, done: make(chan struct{})}
+	if m.closed {
+		close(c)
+		return s
+	}
+	m.subs[s] = struct{}{}
+	return s
+}
+
+// Subscription is one consumer of a Mux.
+type Subscription[T any] struct {
+	// C receives the values. It is closed by Close or when the Mux stops.
+	C <-chan T
+
+	c       chan T
+	m       *Mux[T]
+	policy  Policy
+	dropped atomic.Uint64
+	done    chan struct{}
+	once    sync.Once
+}
+
+// offer delivers v according to the policy. It runs with the Mux locked,
+// so it never races with closing c.
+func (s *Subscription[T]) offer(ctx context.Context, v T) {
+	switch s.policy {
+	case DropNewest:
+		select {
+		case s.c <- v:
+		default:
+			s.dropped.Add(1)
+		}
+	case DropOldest:
+		select {
+		case s.c <- v:
+			return
+		default:
+		}
+		select {
+		case <-s.c:
+			s.dropped.Add(1)
+		default:
+			// The consumer took a value meanwhile.
+		}
+		// Only offer sends on c, so there is room now.
+		s.c <- v
+	default:
+		// done lets Close interrupt a blocked send; it cannot take the
+		// lock we hold.
+		select {
+		case s.c <- v:
+		case <-s.done:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// Dropped returns the number of values the policy has discarded.
+func (s *Subscription[T]) Dropped() uint64 { return s.dropped.Load() }
+
+// Close removes the subscription and closes C. Values still buffered in C
+// can be read until it is drained. Close is safe to call more than once
+// and from any goroutine.
+func (s *Subscription[T]) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.m.mu.Lock()
+		defer s.m.mu.Unlock()
+		if _, ok := s.m.subs[s]; ok {
+			delete(s.m.subs, s)
+			close(s.c)
+		}
+	})
+}
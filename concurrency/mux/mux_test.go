@@ -0,0 +1,242 @@
+package mux
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// start runs a Mux over a new source and returns the source. Closing it
+// stops the Mux; wait returns once Run has.
+func start(t *testing.T, opts Options) (src chan int, m *Mux[int], wait func()) {
+	t.Helper()
+	src = make(chan int)
+	m = New[int](src, opts)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(context.Background())
+	}()
+	return src, m, func() { <-done }
+}
+
+func drain(c <-chan int) []int {
+	var got []int
+	for v := range c {
+		got = append(got, v)
+	}
+	return got
+}
+
+func seq(from, to int) []int {
+	var s []int
+	for i := from; i < to; i++ {
+		s = append(s, i)
+	}
+	return s
+}
+
+func TestFanOut(t *testing.T) {
+	src, m, wait := start(t, Options{})
+	var subs []*Subscription[int]
+	for i := 0; i < 3; i++ {
+		subs = append(subs, m.Subscribe(SubOptions{}))
+	}
+	results := make([][]int, len(subs))
+	var wg sync.WaitGroup
+	for i, s := range subs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = drain(s.C)
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		src <- i
+	}
+	close(src)
+	wait()
+	wg.Wait()
+	for i, got := range results {
+		if !slices.Equal(got, seq(0, 100)) {
+			t.Errorf("subscriber %d got %v", i, got)
+		}
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy Policy
+		want   []int
+	}{
+		{DropNewest, []int{0, 1}},
+		{DropOldest, []int{8, 9}},
+	} {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			src, m, wait := start(t, Options{})
+			s := m.Subscribe(SubOptions{Buffer: 2, Policy: tc.policy})
+			for i := 0; i < 10; i++ {
+				src <- i
+			}
+			close(src)
+			wait()
+			if got := drain(s.C); !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+			if s.Dropped() != 8 {
+				t.Errorf("dropped %d, want 8", s.Dropped())
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	src, m, wait := start(t, Options{History: 3})
+	first := m.Subscribe(SubOptions{Buffer: 5})
+	for i := 0; i < 5; i++ {
+		src <- i
+	}
+	// Once the first subscriber has all five, they are in the history.
+	for i := 0; i < 5; i++ {
+		<-first.C
+	}
+
+	late := m.Subscribe(SubOptions{Replay: 10})
+	short := m.Subscribe(SubOptions{Replay: 1, Buffer: 1})
+	go func() {
+		src <- 5
+		close(src)
+	}()
+	if got := drain(late.C); !slices.Equal(got, []int{2, 3, 4, 5}) {
+		t.Errorf("late joiner got %v, want the last 3 then 5", got)
+	}
+	if got := drain(short.C); !slices.Equal(got, []int{4, 5}) {
+		t.Errorf("Replay 1 got %v", got)
+	}
+	wait()
+}
+
+func TestCloseUnblocksProducer(t *testing.T) {
+	src, m, wait := start(t, Options{})
+	stuck := m.Subscribe(SubOptions{})
+	live := m.Subscribe(SubOptions{Buffer: 1})
+	src <- 1
+	// The Mux is now blocked on stuck, which never reads.
+	time.AfterFunc(10*time.Millisecond, stuck.Close)
+	if v := <-live.C; v != 1 {
+		t.Fatalf("got %d", v)
+	}
+	src <- 2
+	if v := <-live.C; v != 2 {
+		t.Fatalf("got %d", v)
+	}
+	// A second Close waits for the first to finish.
+	stuck.Close()
+	if m.Len() != 1 {
+		t.Errorf("Len = %d after Close, want 1", m.Len())
+	}
+	close(src)
+	wait()
+
+	if s := m.Subscribe(SubOptions{}); drain(s.C) != nil {
+		t.Error("subscribing to a stopped Mux should give a closed channel")
+	}
+}
+
+// TestChurn subscribes and unsubscribes consumers while values flow. Every
+// consumer must see increasing values; blocking ones must see a gap-free
+// run of them.
+func TestChurn(t *testing.T) {
+	src, m, wait := start(t, Options{History: 8})
+	stop := make(chan struct{})
+	go func() {
+		defer close(src)
+		for i := 0; ; i++ {
+			select {
+			case src <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(g)))
+			for round := 0; round < 50; round++ {
+				policy := Policy(rng.Intn(3))
+				s := m.Subscribe(SubOptions{Buffer: rng.Intn(4), Policy: policy, Replay: rng.Intn(8)})
+				prev := -1
+				for n := rng.Intn(20); n > 0; n-- {
+					v, ok := <-s.C
+					if !ok {
+						t.Error("channel closed while subscribed")
+						return
+					}
+					if v <= prev || policy == Block && prev >= 0 && v != prev+1 {
+						t.Errorf("%v subscriber got %d after %d", policy, v, prev)
+						return
+					}
+					prev = v
+				}
+				s.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	wait()
+	if m.Len() != 0 {
+		t.Errorf("%d subscriptions left after churn", m.Len())
+	}
+}
+
+func TestRing(t *testing.T) {
+	r := newRing[int](3)
+	if got := r.last(5); len(got) != 0 {
+		t.Errorf("empty ring: %v", got)
+	}
+	for i := 0; i < 5; i++ {
+		r.push(i)
+	}
+	if got := r.last(5); !slices.Equal(got, []int{2, 3, 4}) {
+		t.Errorf("last(5) = %v", got)
+	}
+	if got := r.last(2); !slices.Equal(got, []int{3, 4}) {
+		t.Errorf("last(2) = %v", got)
+	}
+}
+
+func ExampleMux() {
+	src := make(chan string)
+	m := New[string](src, Options{History: 2})
+	go m.Run(context.Background())
+
+	early := m.Subscribe(SubOptions{Buffer: 3})
+	for _, v := range []string{"a", "b", "c"} {
+		src <- v
+	}
+	<-early.C
+	<-early.C
+	<-early.C
+
+	late := m.Subscribe(SubOptions{Replay: 2})
+	close(src)
+	for v := range late.C {
+		fmt.Println(v)
+	}
+	// Output:
+	// b
+	// c
+}
@@ -0,0 +1,35 @@
+package mux
+
+// ring keeps the last len(buf) values pushed into it.
+type ring[T any] struct {
+	buf   []T
+	start int
+	n     int
+}
+
+func newRing[T any](size int) *ring[T] {
+	return &ring[T]{buf: make([]T, size)}
+}
+
+func (r *ring[T]) push(v T) {
+	if len(r.buf) == 0 {
+		return
+	}
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = v
+		r.n++
+		return
+	}
+	r.buf[r.start] = v
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+// last returns up to k of the newest values, oldest first.
+func (r *ring[T]) last(k int) []T {
+	k = min(k, r.n)
+	out := make([]T, k)
+	for i := range out {
+		out[i] = r.buf[(r.start+r.n-k+i)%len(r.buf)]
+	}
+	return out
+}
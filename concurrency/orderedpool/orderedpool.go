@@ -0,0 +1,138 @@
+// Package orderedpool processes a stream of items on several goroutines and
+// emits the results in the order the items came in, which
+// patterns/workerpool, where each task reports on its own, cannot do.
+//
+// Every item gets a sequence number. Results that finish before the ones
+// ahead of them wait in a reorder buffer until those have been emitted. The
+// buffer is bounded: at most Window items are in flight or waiting, so one
+// slow item stalls the intake instead of letting the buffer grow without
+// limit behind it.
+package orderedpool
+
+import (
+	"context"
+	"sync"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// Options configure Map.
+type Options struct {
+	// Workers is the number of items processed at once; at least one.
+	Workers int
+	// Window bounds the items taken from the input and not yet emitted.
+	// It is at least Workers, and twice Workers if zero.
+	Window int
+}
+
+// Result is the outcome of the item with sequence number Seq, counted from
+// zero in input order. A panic in the function is reported in Err as a
+// *recovery.PanicError.
+type Result[R any] struct {
+	Seq   int
+	Value R
+	Err   error
+}
+
+type item[T any] struct {
+	seq int
+	v   T
+}
+
+// Map calls fn for every item received from in, on opts.Workers goroutines,
+// and sends the results in input order. The output is closed once in is
+// closed and every result has been sent, or once ctx is done, possibly
+// before every result has been sent. An error from fn is reported in its
+// Result and does not stop the others.
+func Map[T, R any](ctx context.Context, in <-chan T, opts Options, fn func(context.Context, T) (R, error)) <-chan Result[R] {
+	workers := max(opts.Workers, 1)
+	window := opts.Window
+	if window == 0 {
+		window = 2 * workers
+	}
+	window = max(window, workers)
+
+	// A token is taken for every item read from in and given back when its
+	// result is emitted.
+	tokens := make(chan struct{}, window)
+	jobs := make(chan item[T])
+	results := make(chan Result[R])
+	out := make(chan Result[R])
+
+	go func() {
+		defer close(jobs)
+		for seq := 0; ; seq++ {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			var v T
+			var ok bool
+			select {
+			case v, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- item[T]{seq, v}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range jobs {
+				r := Result[R]{Seq: it.seq}
+				r.Err = recovery.Do(func() error {
+					var err error
+					r.Value, err = fn(ctx, it.v)
+					return err
+				})
+				select {
+				case results <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	go func() {
+		defer close(out)
+		buffer := make(map[int]Result[R], window)
+		next := 0
+		for r := range results {
+			buffer[r.Seq] = r
+			for {
+				r, ok := buffer[next]
+				if !ok {
+					break
+				}
+				delete(buffer, next)
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					for range results {
+					}
+					return
+				}
+				next++
+				<-tokens
+			}
+		}
+	}()
+	return out
+}
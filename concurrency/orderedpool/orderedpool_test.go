@@ -0,0 +1,172 @@
+package orderedpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func feed(n int) <-chan int {
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := range n {
+			in <- i
+		}
+	}()
+	return in
+}
+
+func TestOrder(t *testing.T) {
+	leaks.Check(t)
+	square := func(_ context.Context, v int) (int, error) {
+		time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+		return v * v, nil
+	}
+	n := 0
+	for r := range Map(context.Background(), feed(200), Options{Workers: 8}, square) {
+		if r.Seq != n || r.Value != n*n || r.Err != nil {
+			t.Fatalf("result %d: %+v", n, r)
+		}
+		n++
+	}
+	if n != 200 {
+		t.Errorf("%d results", n)
+	}
+}
+
+func TestSlowItemStallsIntake(t *testing.T) {
+	leaks.Check(t)
+	release := make(chan struct{})
+	var started atomic.Int32
+	fn := func(_ context.Context, v int) (int, error) {
+		started.Add(1)
+		if v == 0 {
+			<-release
+		}
+		return v, nil
+	}
+	out := Map(context.Background(), feed(50), Options{Workers: 4, Window: 8}, fn)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for started.Load() < 8 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// Item 0 holds its token and items 1 to 7 wait in the buffer with
+	// theirs, so nothing else may start.
+	time.Sleep(20 * time.Millisecond)
+	if n := started.Load(); n != 8 {
+		t.Fatalf("%d items started behind the slow one, want 8", n)
+	}
+	select {
+	case r := <-out:
+		t.Fatalf("result %+v emitted before the slow item", r)
+	default:
+	}
+	close(release)
+	n := 0
+	for r := range out {
+		if r.Seq != n {
+			t.Fatalf("result %d has Seq %d", n, r.Seq)
+		}
+		n++
+	}
+	if n != 50 {
+		t.Errorf("%d results", n)
+	}
+}
+
+func TestErrorsAndPanics(t *testing.T) {
+	leaks.Check(t)
+	errOdd := errors.New("odd")
+	fn := func(_ context.Context, v int) (string, error) {
+		switch {
+		case v == 3:
+			panic("three")
+		case v%2 == 1:
+			return "", errOdd
+		}
+		return fmt.Sprint(v), nil
+	}
+	var got []string
+	for r := range Map(context.Background(), feed(5), Options{Workers: 3}, fn) {
+		var p *recovery.PanicError
+		switch {
+		case errors.As(r.Err, &p):
+			got = append(got, "panic")
+		case r.Err != nil:
+			got = append(got, r.Err.Error())
+		default:
+			got = append(got, r.Value)
+		}
+	}
+	if s := strings.Join(got, " "); s != "0 odd 2 panic 4" {
+		t.Errorf("results %s", s)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	leaks.Check(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan int)
+	go func() {
+		// An endless input: only the cancellation ends the stream.
+		for i := 0; ; i++ {
+			select {
+			case in <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	fn := func(ctx context.Context, v int) (int, error) {
+		if v == 10 {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return v, nil
+	}
+	out := Map(ctx, in, Options{Workers: 2}, fn)
+	for r := range out {
+		if r.Seq == 9 {
+			cancel()
+		}
+		if r.Seq > 10 {
+			t.Errorf("result %+v after cancel", r)
+		}
+	}
+}
+
+func ExampleMap() {
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, url := range []string{"/slow", "/a", "/b"} {
+			in <- url
+		}
+	}()
+	fetch := func(_ context.Context, url string) (int, error) {
+		if url == "/slow" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return len(url), nil
+	}
+	for r := range Map(context.Background(), in, Options{Workers: 3}, fetch) {
+		fmt.Println(r.Seq, r.Value)
+	}
+	// Output:
+	// 0 5
+	// 1 2
+	// 2 2
+}
@@ -0,0 +1,99 @@
+// Package parallel processes the items of a slice on several goroutines,
+// the loop that otherwise gets written out by hand around a WaitGroup, a
+// semaphore and a mutex-guarded error every time it is needed.
+//
+// ForEach, Map and Filter all run at most limit items at once, limit
+// being GOMAXPROCS if it is not positive, on as many goroutines that take
+// the items in input order. The first error cancels the context the other
+// calls get and stops the items not started yet from starting; it is the
+// one returned, once every call in flight has returned. A panic is
+// returned as a *recovery.PanicError. Map and Filter return their results
+// in input order, whatever order the calls finish in.
+package parallel
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// ForEach calls fn for every item, at most limit at a time.
+func ForEach[T any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, v T) error) error {
+	return run(ctx, len(items), limit, func(ctx context.Context, i int) error {
+		return fn(ctx, items[i])
+	})
+}
+
+// Map returns fn of every item, at most limit computed at a time. The
+// results are nil if any call fails.
+func Map[T, R any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, v T) (R, error)) ([]R, error) {
+	out := make([]R, len(items))
+	err := run(ctx, len(items), limit, func(ctx context.Context, i int) (err error) {
+		out[i], err = fn(ctx, items[i])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Filter returns the items keep is true for, at most limit tested at a
+// time. The items are nil if any call fails.
+func Filter[T any](ctx context.Context, items []T, limit int, keep func(ctx context.Context, v T) (bool, error)) ([]T, error) {
+	kept, err := Map(ctx, items, limit, keep)
+	if err != nil {
+		return nil, err
+	}
+	var out []T
+	for i, v := range items {
+		if kept[i] {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// run calls fn with the indexes from 0 to n less one on at most limit
+// goroutines, until one of the calls fails or ctx is done.
+func run(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) error {
+	if limit <= 0 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		next  atomic.Int64
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	for range min(limit, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				if err := recovery.Do(func() error { return fn(ctx, i) }); err != nil {
+					// Only the first error is kept: the ones after it may
+					// just be the others giving up on the cancellation.
+					once.Do(func() { first = err })
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if first != nil {
+		return first
+	}
+	return ctx.Err()
+}
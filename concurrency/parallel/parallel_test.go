@@ -0,0 +1,165 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// gauge tracks how many calls are in flight at most.
+type gauge struct {
+	now, peak atomic.Int32
+}
+
+func (g *gauge) enter() {
+	n := g.now.Add(1)
+	for {
+		p := g.peak.Load()
+		if n <= p || g.peak.CompareAndSwap(p, n) {
+			return
+		}
+	}
+}
+
+func (g *gauge) leave() { g.now.Add(-1) }
+
+func TestMapKeepsOrderWithinLimit(t *testing.T) {
+	leaks.Check(t)
+	var g gauge
+	items := make([]int, 50)
+	for i := range items {
+		items[i] = i
+	}
+	got, err := Map(context.Background(), items, 4, func(_ context.Context, v int) (string, error) {
+		g.enter()
+		defer g.leave()
+		// Later items finish first.
+		time.Sleep(time.Duration(50-v) * 20 * time.Microsecond)
+		return fmt.Sprint(v * v), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range got {
+		if s != fmt.Sprint(i*i) {
+			t.Fatalf("result %d is %s", i, s)
+		}
+	}
+	if peak := g.peak.Load(); peak > 4 || peak < 2 {
+		t.Errorf("%d calls at once, limit 4", peak)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	leaks.Check(t)
+	words := []string{"go", "channel", "mutex", "select", "defer", "chan"}
+	got, err := Filter(context.Background(), words, 3, func(_ context.Context, w string) (bool, error) {
+		return len(w) > 4, nil
+	})
+	if want := []string{"channel", "mutex", "select", "defer"}; err != nil || !slices.Equal(got, want) {
+		t.Errorf("Filter = %v, %v; want %v", got, err, want)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	leaks.Check(t)
+	var mu sync.Mutex
+	seen := map[int]bool{}
+	err := ForEach(context.Background(), []int{3, 1, 4, 1, 5}, 0, func(_ context.Context, v int) error {
+		mu.Lock()
+		seen[v] = true
+		mu.Unlock()
+		return nil
+	})
+	if err != nil || len(seen) != 4 {
+		t.Errorf("ForEach = %v, saw %v", err, seen)
+	}
+}
+
+func TestFirstErrorStopsTheRest(t *testing.T) {
+	leaks.Check(t)
+	broken := errors.New("item 5 is broken")
+	var started atomic.Int32
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+	got, err := Map(context.Background(), items, 2, func(ctx context.Context, v int) (int, error) {
+		started.Add(1)
+		switch {
+		case v == 5:
+			return 0, broken
+		case v > 5:
+			// The call in flight with the failing one is cancelled.
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(5 * time.Second):
+			}
+		}
+		return v, nil
+	})
+	if err != broken || got != nil {
+		t.Errorf("Map = %v, %v", got, err)
+	}
+	if n := started.Load(); n > 8 {
+		t.Errorf("%d items started after item 5 failed", n)
+	}
+}
+
+func TestCancelled(t *testing.T) {
+	leaks.Check(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	err := ForEach(ctx, make([]int, 1000), 2, func(context.Context, int) error {
+		if calls.Add(1) == 10 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled || calls.Load() > 12 {
+		t.Errorf("ForEach = %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestPanic(t *testing.T) {
+	leaks.Check(t)
+	_, err := Filter(context.Background(), []int{1, 2, 3}, 2, func(_ context.Context, v int) (bool, error) {
+		if v == 2 {
+			panic("bad item")
+		}
+		return true, nil
+	})
+	var pe *recovery.PanicError
+	if !errors.As(err, &pe) {
+		t.Errorf("Filter = %v", err)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	got, err := Map(context.Background(), nil, 4, func(context.Context, int) (int, error) {
+		t.Error("called")
+		return 0, nil
+	})
+	if err != nil || len(got) != 0 {
+		t.Errorf("Map = %v, %v", got, err)
+	}
+}
+
+func ExampleMap() {
+	lengths, err := Map(context.Background(), []string{"fan", "out", "fan-in"}, 2, func(_ context.Context, s string) (int, error) {
+		return len(s), nil
+	})
+	fmt.Println(lengths, err)
+	// Output: [3 3 6] <nil>
+}
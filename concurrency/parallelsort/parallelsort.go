@@ -0,0 +1,164 @@
+// Package parallelsort sorts slices by divide and conquer, sorting the
+// halves of a split on separate goroutines.
+//
+// Starting a goroutine for every split would start one per element, and
+// for small slices the goroutines cost more than the sorting. Two limits
+// keep the parallelism where it pays: below Threshold elements a part is
+// sorted sequentially, and past MaxDepth levels of splitting no more
+// goroutines are started, which bounds them at 2^MaxDepth. The default
+// depth gives a few goroutines per processor, enough to keep all of them
+// busy when the parts come out uneven.
+//
+// MergeSort always splits in the middle and is stable; it needs a buffer
+// as large as the slice. QuickSort sorts in place but splits where the
+// pivot falls, so its parts, and the work of its goroutines, can be
+// uneven. The benchmarks compare both with sort.Slice and slices.Sort
+// across sizes, to find where the parallel sorts start to pay off on a
+// given machine: small slices never split, and on a single processor the
+// splits only add the cost of the goroutines and of merging.
+package parallelsort
+
+import (
+	"cmp"
+	"math/bits"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// Options bound the parallelism of a sort.
+type Options struct {
+	// Threshold is the length under which a part is sorted sequentially,
+	// default 4096.
+	Threshold int
+	// MaxDepth is how many levels of splits start goroutines, default
+	// enough for four per processor. Zero means the default; a negative
+	// depth sorts sequentially.
+	MaxDepth int
+}
+
+func (o *Options) defaults() {
+	if o.Threshold <= 0 {
+		o.Threshold = 4096
+	}
+	if o.MaxDepth == 0 {
+		o.MaxDepth = bits.Len(uint(runtime.GOMAXPROCS(0))) + 2
+	}
+}
+
+// MergeSort sorts s stably in ascending order.
+func MergeSort[E cmp.Ordered](s []E, opts Options) {
+	MergeSortFunc(s, cmp.Compare[E], opts)
+}
+
+// MergeSortFunc sorts s stably in the order of cmp.
+func MergeSortFunc[E any](s []E, cmp func(a, b E) int, opts Options) {
+	opts.defaults()
+	mergeSort(s, make([]E, len(s)), cmp, opts.Threshold, opts.MaxDepth)
+}
+
+func mergeSort[E any](s, buf []E, cmp func(a, b E) int, threshold, depth int) {
+	if len(s) <= threshold {
+		slices.SortStableFunc(s, cmp)
+		return
+	}
+	mid := len(s) / 2
+	both(depth > 0,
+		func() { mergeSort(s[:mid], buf[:mid], cmp, threshold, depth-1) },
+		func() { mergeSort(s[mid:], buf[mid:], cmp, threshold, depth-1) })
+	merge(s, mid, buf, cmp)
+}
+
+// merge merges the sorted halves s[:mid] and s[mid:] through buf.
+func merge[E any](s []E, mid int, buf []E, cmp func(a, b E) int) {
+	if cmp(s[mid-1], s[mid]) <= 0 {
+		return // already in order
+	}
+	i, j, k := 0, mid, 0
+	for i < mid && j < len(s) {
+		// Taking from the left on ties keeps the sort stable.
+		if cmp(s[j], s[i]) < 0 {
+			buf[k] = s[j]
+			j++
+		} else {
+			buf[k] = s[i]
+			i++
+		}
+		k++
+	}
+	k += copy(buf[k:], s[i:mid])
+	k += copy(buf[k:], s[j:])
+	copy(s, buf[:k])
+}
+
+// QuickSort sorts s in ascending order.
+func QuickSort[E cmp.Ordered](s []E, opts Options) {
+	QuickSortFunc(s, cmp.Compare[E], opts)
+}
+
+// QuickSortFunc sorts s in the order of cmp. It is not stable.
+func QuickSortFunc[E any](s []E, cmp func(a, b E) int, opts Options) {
+	opts.defaults()
+	quickSort(s, cmp, opts.Threshold, opts.MaxDepth)
+}
+
+func quickSort[E any](s []E, cmp func(a, b E) int, threshold, depth int) {
+	if len(s) <= threshold {
+		slices.SortFunc(s, cmp)
+		return
+	}
+	lt, gt := partition(s, cmp)
+	both(depth > 0,
+		func() { quickSort(s[:lt], cmp, threshold, depth-1) },
+		func() { quickSort(s[gt:], cmp, threshold, depth-1) })
+}
+
+// partition arranges s in three parts around a pivot, the median of its
+// first, middle and last elements: s[:lt] less than the pivot, s[lt:gt]
+// equal to it and s[gt:] greater. Grouping the equal elements keeps
+// slices with many duplicates from splitting unevenly.
+func partition[E any](s []E, cmp func(a, b E) int) (lt, gt int) {
+	a, b, c := 0, len(s)/2, len(s)-1
+	if cmp(s[b], s[a]) < 0 {
+		a, b = b, a
+	}
+	if cmp(s[c], s[b]) < 0 {
+		b = c
+		if cmp(s[b], s[a]) < 0 {
+			b = a
+		}
+	}
+	pivot := s[b]
+	lt, i, gt := 0, 0, len(s)
+	for i < gt {
+		switch c := cmp(s[i], pivot); {
+		case c < 0:
+			s[lt], s[i] = s[i], s[lt]
+			lt++
+			i++
+		case c > 0:
+			gt--
+			s[i], s[gt] = s[gt], s[i]
+		default:
+			i++
+		}
+	}
+	return lt, gt
+}
+
+// both runs f and g, at the same time if parallel is set.
+func both(parallel bool, f, g func()) {
+	if !parallel {
+		f()
+		g()
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f()
+	}()
+	g()
+	wg.Wait()
+}
@@ -0,0 +1,110 @@
+package parallelsort
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// inputs returns slices of n elements in shapes that trouble sorts.
+func inputs(n int) map[string][]int {
+	rng := rand.New(rand.NewSource(int64(n)))
+	random, few, sorted, reversed := make([]int, n), make([]int, n), make([]int, n), make([]int, n)
+	for i := range n {
+		random[i] = rng.Int()
+		few[i] = rng.Intn(3)
+		sorted[i] = i
+		reversed[i] = n - i
+	}
+	return map[string][]int{"random": random, "few": few, "sorted": sorted, "reversed": reversed}
+}
+
+var sorts = map[string]func([]int, Options){
+	"merge": MergeSort[int],
+	"quick": QuickSort[int],
+}
+
+func TestSort(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 17, 1000, 50000} {
+		for shape, in := range inputs(n) {
+			want := slices.Clone(in)
+			slices.Sort(want)
+			for name, sort := range sorts {
+				// A small threshold makes even short slices split.
+				for _, opts := range []Options{{}, {Threshold: 8}, {Threshold: 8, MaxDepth: -1}} {
+					got := slices.Clone(in)
+					sort(got, opts)
+					if !slices.Equal(got, want) {
+						t.Errorf("%s sort of %d %s with %+v: not sorted", name, n, shape, opts)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestStable(t *testing.T) {
+	type rec struct{ key, seq int }
+	rng := rand.New(rand.NewSource(1))
+	s := make([]rec, 20000)
+	for i := range s {
+		s[i] = rec{rng.Intn(50), i}
+	}
+	MergeSortFunc(s, func(a, b rec) int { return cmp.Compare(a.key, b.key) }, Options{Threshold: 16})
+	for i := 1; i < len(s); i++ {
+		if s[i-1].key > s[i].key || (s[i-1].key == s[i].key && s[i-1].seq > s[i].seq) {
+			t.Fatalf("%v before %v", s[i-1], s[i])
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	s := []int{5, 1, 5, 9, 3, 5, 7, 5, 0}
+	lt, gt := partition(s, cmp.Compare[int])
+	// The pivot is the median of 5, 3 and 0.
+	if lt != 2 || gt != 3 {
+		t.Fatalf("partition %v at %d, %d", s, lt, gt)
+	}
+	for i, v := range s {
+		if i < lt && v >= 3 || i >= lt && i < gt && v != 3 || i >= gt && v <= 3 {
+			t.Fatalf("partition %v at %d, %d", s, lt, gt)
+		}
+	}
+}
+
+func BenchmarkSort(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000, 1000000} {
+		in := inputs(n)["random"]
+		s := make([]int, n)
+		bench := func(name string, sort func([]int)) {
+			b.Run(fmt.Sprintf("%s/n=%d", name, n), func(b *testing.B) {
+				for range b.N {
+					copy(s, in)
+					sort(s)
+				}
+			})
+		}
+		bench("sort.Slice", func(s []int) { sort.Slice(s, func(i, j int) bool { return s[i] < s[j] }) })
+		bench("slices.Sort", slices.Sort[[]int])
+		bench("merge", func(s []int) { MergeSort(s, Options{}) })
+		bench("quick", func(s []int) { QuickSort(s, Options{}) })
+	}
+}
+
+func ExampleMergeSortFunc() {
+	type person struct {
+		Name string
+		Age  int
+	}
+	people := []person{{"Cy", 30}, {"Ann", 25}, {"Bob", 30}, {"Dee", 25}}
+	MergeSortFunc(people, func(a, b person) int { return cmp.Compare(a.Age, b.Age) }, Options{})
+	fmt.Println(people)
+	// Output: [{Ann 25} {Dee 25} {Cy 30} {Bob 30}]
+}
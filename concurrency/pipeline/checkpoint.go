@@ -0,0 +1,175 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/crazybber/go-patterns/patterns/registry/storage"
+)
+
+// Record is a value of a resumable source with its offset, the position
+// of the value in the source counted from 0.
+type Record[T any] struct {
+	Offset uint64
+	Value  T
+}
+
+// Carry lifts fn to records, so a Map stage between a ResumableSource and
+// a CommitSink keeps each value's offset.
+func Carry[T, U any](fn func(ctx context.Context, v T) (U, error)) func(context.Context, Record[T]) (Record[U], error) {
+	return func(ctx context.Context, r Record[T]) (Record[U], error) {
+		u, err := fn(ctx, r.Value)
+		return Record[U]{r.Offset, u}, err
+	}
+}
+
+// Checkpoint is the progress of a resumable source: the offset below which
+// every record has been committed by the sink. It is kept in a
+// storage.Store under the source's name, so any storage driver can hold
+// it.
+//
+// Records committed out of order are remembered until the ones before
+// them are committed too, so a record that failed, or was still in a Map
+// stage when the pipeline stopped, holds the checkpoint back and is
+// handled again on resume: delivery is at least once.
+type Checkpoint struct {
+	store storage.Store
+	key   string
+	every int
+
+	mu        sync.Mutex
+	next      uint64          // every offset below is committed
+	ahead     map[uint64]bool // committed offsets from next+1 on
+	saved     uint64
+	sinceSave int
+}
+
+// Offset returns the offset the checkpoint has reached: every record
+// before it has been committed.
+func (c *Checkpoint) Offset() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.next
+}
+
+// Saved returns the offset last written to the store.
+func (c *Checkpoint) Saved() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.saved
+}
+
+func (c *Checkpoint) load() (uint64, error) {
+	v, err := c.store.Get(c.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	off, err := strconv.ParseUint(string(v), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("checkpoint %s: %w", c.key, err)
+	}
+	c.mu.Lock()
+	c.next, c.saved = off, off
+	c.mu.Unlock()
+	return off, nil
+}
+
+// commit marks offset done and reports whether enough records have been
+// committed since the last save to save again.
+func (c *Checkpoint) commit(offset uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if offset < c.next {
+		return false
+	}
+	c.ahead[offset] = true
+	for c.ahead[c.next] {
+		delete(c.ahead, c.next)
+		c.next++
+	}
+	c.sinceSave++
+	return c.sinceSave >= c.every
+}
+
+// save writes the offset reached to the store, if it has moved.
+func (c *Checkpoint) save() error {
+	c.mu.Lock()
+	off := c.next
+	c.sinceSave = 0
+	if off == c.saved {
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+	if err := c.store.Put(c.key, []byte(strconv.FormatUint(off, 10))); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.saved = off
+	c.mu.Unlock()
+	return nil
+}
+
+// ResumableSource is a Source that picks up where the last run with the
+// same name and store left off. gen is called with the offset to start
+// from and emits the values from there on, in order; emit numbers them.
+// The checkpoint is saved, every every records and once more when the
+// pipeline ends, by the CommitSink the records end up in. An error loading
+// the checkpoint fails the pipeline.
+func ResumableSource[T any](p *Pipeline, name string, store storage.Store, every int,
+	gen func(ctx context.Context, from uint64, emit func(T) bool) error) (<-chan Record[T], *Checkpoint) {
+	c := &Checkpoint{store: store, key: name, every: max(every, 1), ahead: make(map[uint64]bool)}
+	out := Source(p, name, func(ctx context.Context, emit func(Record[T]) bool) error {
+		from, err := c.load()
+		if err != nil {
+			return err
+		}
+		next := from
+		return gen(ctx, from, func(v T) bool {
+			r := Record[T]{next, v}
+			next++
+			return emit(r)
+		})
+	})
+	return out, c
+}
+
+// CommitSink is Sink for the records of a ResumableSource: once fn has
+// handled a record, its offset is committed to c. An error saving the
+// checkpoint fails the pipeline, also when it happens in the last save,
+// after the pipeline has been cancelled.
+func CommitSink[T any](p *Pipeline, name string, c *Checkpoint, in <-chan Record[T], fn func(ctx context.Context, v T) error) {
+	p.spawn(func() {
+		// Save what was reached however the sink ends. The pipeline may be
+		// cancelled by then, which should not stop the last save.
+		defer func() {
+			if err := c.save(); err != nil {
+				p.record(name, err)
+			}
+		}()
+		var seq uint64
+		for {
+			r, ok := receive(p.ctx, in)
+			if !ok {
+				return
+			}
+			seq++
+			if err := p.handle(name, 0, seq, func(ctx context.Context) error { return fn(ctx, r.Value) }); err != nil {
+				p.fail(name, err)
+				return
+			}
+			if c.commit(r.Offset) {
+				if err := c.save(); err != nil {
+					p.fail(name, err)
+					return
+				}
+			}
+		}
+	})
+}
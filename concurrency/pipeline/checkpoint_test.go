@@ -0,0 +1,204 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/crazybber/go-patterns/patterns/registry/storage"
+	"github.com/crazybber/go-patterns/patterns/registry/storage/memory"
+)
+
+// lines is the source the checkpoint tests read: line i is "line i".
+func lines(n int) func(ctx context.Context, from uint64, emit func(string) bool) error {
+	return func(_ context.Context, from uint64, emit func(string) bool) error {
+		for i := int(from); i < n; i++ {
+			if !emit(fmt.Sprint("line ", i)) {
+				break
+			}
+		}
+		return nil
+	}
+}
+
+func number(_ context.Context, s string) (int, error) {
+	var n int
+	_, err := fmt.Sscanf(s, "line %d", &n)
+	return n, err
+}
+
+// run reads lines(n) through a four-goroutine Map into a CommitSink
+// whose handler is handle, and returns the offset the source started from
+// and the pipeline's error.
+func run(t *testing.T, store storage.Store, n int, handle func(cancel context.CancelFunc, v int) error) (uint64, error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := New(ctx, Options{})
+	var from uint64
+	first := true
+	src, c := ResumableSource(p, "lines", store, 10, func(ctx context.Context, f uint64, emit func(string) bool) error {
+		from = f
+		return lines(n)(ctx, f, emit)
+	})
+	nums := Map(p, "number", 4, src, Carry(number))
+	CommitSink(p, "store", c, nums, func(_ context.Context, v int) error {
+		if first {
+			first = false
+			if uint64(v) < from {
+				t.Errorf("first record %d before the checkpoint %d", v, from)
+			}
+		}
+		return handle(cancel, v)
+	})
+	err := p.Wait()
+	if (err == nil || err == context.Canceled) && c.Saved() != c.Offset() {
+		t.Errorf("saved %d, reached %d", c.Saved(), c.Offset())
+	}
+	return from, err
+}
+
+func TestResumeAfterKill(t *testing.T) {
+	store := memory.New()
+	var mu sync.Mutex
+	handled := map[int]int{}
+	count := func(v int) {
+		mu.Lock()
+		handled[v]++
+		mu.Unlock()
+	}
+
+	// Kill the pipeline halfway through, with records still in the Map
+	// stage.
+	seen := 0
+	from, err := run(t, store, 100, func(cancel context.CancelFunc, v int) error {
+		count(v)
+		if seen++; seen == 37 {
+			cancel()
+		}
+		return nil
+	})
+	if from != 0 || err != context.Canceled {
+		t.Fatalf("first run from %d: %v", from, err)
+	}
+	saved, _ := store.Get("lines")
+	var resumeAt int
+	fmt.Sscan(string(saved), &resumeAt)
+	if resumeAt == 0 || resumeAt > 37 {
+		t.Fatalf("checkpoint after 37 records is %d", resumeAt)
+	}
+	for v := range resumeAt {
+		if handled[v] == 0 {
+			t.Fatalf("checkpoint %d passed record %d, which was not handled", resumeAt, v)
+		}
+	}
+
+	from, err = run(t, store, 100, func(_ context.CancelFunc, v int) error {
+		count(v)
+		return nil
+	})
+	if int(from) != resumeAt || err != nil {
+		t.Fatalf("second run from %d, want %d: %v", from, resumeAt, err)
+	}
+	for v := range 100 {
+		// At least once: records after the checkpoint may come twice.
+		if handled[v] == 0 || v < resumeAt && handled[v] != 1 {
+			t.Errorf("record %d handled %d times", v, handled[v])
+		}
+	}
+
+	// Everything is committed, so a third run has nothing to do.
+	from, err = run(t, store, 100, func(_ context.CancelFunc, v int) error {
+		t.Errorf("record %d handled again", v)
+		return nil
+	})
+	if from != 100 || err != nil {
+		t.Errorf("third run from %d: %v", from, err)
+	}
+}
+
+// A failing record holds the checkpoint back, so it is retried on resume.
+func TestFailedRecordIsRetried(t *testing.T) {
+	store := memory.New()
+	broken := errors.New("disk full")
+	_, err := run(t, store, 50, func(_ context.CancelFunc, v int) error {
+		if v == 23 {
+			return broken
+		}
+		return nil
+	})
+	if !errors.Is(err, broken) {
+		t.Fatalf("first run: %v", err)
+	}
+	var got []int
+	from, err := run(t, store, 50, func(_ context.CancelFunc, v int) error {
+		got = append(got, v)
+		return nil
+	})
+	if err != nil || from > 23 || !slices.Contains(got, 23) {
+		t.Errorf("second run from %d: %v, handled %v", from, err, got)
+	}
+}
+
+func TestCommitOutOfOrder(t *testing.T) {
+	c := &Checkpoint{every: 3, ahead: make(map[uint64]bool)}
+	var steps []string
+	for _, off := range []uint64{1, 2, 0, 4, 3, 3, 6} {
+		due := c.commit(off)
+		steps = append(steps, fmt.Sprintf("%d:%d", off, c.Offset()))
+		if due {
+			c.sinceSave = 0
+			steps = append(steps, "save")
+		}
+	}
+	want := "[1:0 2:0 0:3 save 4:3 3:5 3:5 6:5 save]"
+	if fmt.Sprint(steps) != want {
+		t.Errorf("steps %v, want %v", steps, want)
+	}
+}
+
+// failingStore cannot be written.
+type failingStore struct{ storage.Store }
+
+func (failingStore) Put(string, []byte) error { return errors.New("read-only") }
+
+func TestSaveError(t *testing.T) {
+	_, err := run(t, failingStore{memory.New()}, 30, func(context.CancelFunc, int) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("Wait = %v", err)
+	}
+}
+
+// The last save runs after the pipeline is cancelled, and its error is
+// not mistaken for one caused by the cancellation.
+func TestLastSaveError(t *testing.T) {
+	// Cancel once record 0 is committed, so that the checkpoint has moved
+	// and the last save writes it.
+	committed0 := false
+	_, err := run(t, failingStore{memory.New()}, 30, func(cancel context.CancelFunc, v int) error {
+		if committed0 {
+			cancel()
+		}
+		committed0 = committed0 || v == 0
+		return nil
+	})
+	if err == nil || err.Error() != "pipeline: store: read-only" {
+		t.Errorf("Wait = %v", err)
+	}
+}
+
+func TestBadCheckpoint(t *testing.T) {
+	store := memory.New()
+	store.Put("lines", []byte("twelve"))
+	_, err := run(t, store, 5, func(_ context.CancelFunc, v int) error {
+		t.Errorf("record %d handled", v)
+		return nil
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "pipeline: lines: checkpoint lines: ") {
+		t.Errorf("Wait = %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+// Package pipeline runs stages connected by channels under one context:
+// the pipelines of the examples next to it, grown into a library for
+// stages that can fail.
+//
+// generator builds pipelines of stages that cannot fail and stop when a
+// done channel is closed. Here every stage function takes a context and
+// returns an error. The first error cancels the pipeline's context, which
+// every stage selects on, so the stages before and after the failing one
+// exit too, and Wait returns the error once every goroutine has. A panic
+// in a stage function is recovered through recovery.Default and is the
+// stage's error.
+//
+//	p := pipeline.New(ctx, pipeline.Options{})
+//	lines := pipeline.Source(p, "read", readLines)
+//	parsed := pipeline.Map(p, "parse", 4, lines, parse)
+//	pipeline.Sink(p, "store", parsed, store)
+//	err := p.Wait()
+//
+// Hooks given in Options see every item a Map or Sink stage handles start
+// and end, with the stage's name, the number of the goroutine handling it
+// and the item's sequence number in the stage, and can put what they like
+// in the context the stage function gets.
+//
+// A ResumableSource numbers its values and a CommitSink records, in a
+// storage.Store, the offset below which every value has been handled, so
+// a pipeline that was stopped picks up from there when it runs again.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// Hooks are called on the goroutine that handles an item, around the
+// stage function. Goroutines are numbered from 0 within their stage, and
+// items from 1 in the order the stage's goroutines take them.
+type Hooks interface {
+	// ItemStart is called before the stage function. It, and ItemEnd, get
+	// the context ItemStart returns.
+	ItemStart(ctx context.Context, stage string, worker int, seq uint64) context.Context
+	// ItemEnd is called once the stage function has returned err after d.
+	ItemEnd(ctx context.Context, err error, d time.Duration)
+}
+
+type noHooks struct{}
+
+func (noHooks) ItemStart(ctx context.Context, _ string, _ int, _ uint64) context.Context { return ctx }
+func (noHooks) ItemEnd(context.Context, error, time.Duration)                            {}
+
+// Options configures a Pipeline.
+type Options struct {
+	// Hooks default to none.
+	Hooks Hooks
+}
+
+func (o *Options) defaults() {
+	if o.Hooks == nil {
+		o.Hooks = noHooks{}
+	}
+}
+
+// Pipeline is the context and the goroutines of a set of stages.
+type Pipeline struct {
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+	hooks  Hooks
+	wg     sync.WaitGroup
+
+	once sync.Once
+	err  error
+}
+
+// New returns a Pipeline whose stages run until ctx is done, one of them
+// fails, or Stop is called.
+func New(ctx context.Context, opts Options) *Pipeline {
+	opts.defaults()
+	p := &Pipeline{parent: ctx, hooks: opts.Hooks}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	return p
+}
+
+// Context returns the context the stages run under.
+func (p *Pipeline) Context() context.Context { return p.ctx }
+
+// Stop cancels the pipeline. A consumer that stops reading the last
+// stage's output early calls it, so that the stages still sending exit.
+func (p *Pipeline) Stop() { p.cancel() }
+
+// Wait waits for the goroutines of every stage to exit. It returns the
+// first stage's error, or the error of the context given to New if that
+// ended the pipeline. Errors returned once the pipeline is cancelled, such
+// as a stage giving up with ctx.Err(), are taken to be caused by the
+// cancellation and dropped.
+func (p *Pipeline) Wait() error {
+	p.wg.Wait()
+	p.cancel()
+	if p.err != nil {
+		return p.err
+	}
+	return p.parent.Err()
+}
+
+// fail records err as the pipeline's error, unless it was cancelled
+// first, and cancels it.
+func (p *Pipeline) fail(stage string, err error) {
+	if p.ctx.Err() != nil {
+		return
+	}
+	p.record(stage, err)
+}
+
+// record records err as the pipeline's error, unless there is one, and
+// cancels it. Unlike fail it keeps err when the pipeline is cancelled
+// already, for the errors of cleaning up after the cancellation.
+func (p *Pipeline) record(stage string, err error) {
+	p.once.Do(func() {
+		p.err = fmt.Errorf("pipeline: %s: %w", stage, err)
+		p.cancel()
+	})
+}
+
+func (p *Pipeline) spawn(fn func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		fn()
+	}()
+}
+
+// handle calls fn for item seq of stage, on goroutine worker, inside the
+// hooks and the panic recovery.
+func (p *Pipeline) handle(stage string, worker int, seq uint64, fn func(ctx context.Context) error) error {
+	ctx := p.hooks.ItemStart(p.ctx, stage, worker, seq)
+	start := time.Now()
+	err := recovery.Do(func() error { return fn(ctx) })
+	p.hooks.ItemEnd(ctx, err, time.Since(start))
+	return err
+}
+
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func receive[T any](ctx context.Context, in <-chan T) (T, bool) {
+	select {
+	case v, ok := <-in:
+		return v, ok
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	}
+}
+
+// Source sends the values gen emits. emit returns false once the pipeline
+// is cancelled, and gen should then return. An error from gen fails the
+// pipeline.
+func Source[T any](p *Pipeline, name string, gen func(ctx context.Context, emit func(T) bool) error) <-chan T {
+	out := make(chan T)
+	p.spawn(func() {
+		defer close(out)
+		emit := func(v T) bool { return send(p.ctx, out, v) }
+		if err := recovery.Do(func() error { return gen(p.ctx, emit) }); err != nil {
+			p.fail(name, err)
+		}
+	})
+	return out
+}
+
+// From sends values, in order.
+func From[T any](p *Pipeline, values ...T) <-chan T {
+	return Source(p, "from", func(_ context.Context, emit func(T) bool) error {
+		for _, v := range values {
+			if !emit(v) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Map sends fn(v) for every v received from in, calling fn on workers
+// goroutines. With more than one, the results come out in the order they
+// are ready.
+func Map[T, U any](p *Pipeline, name string, workers int, in <-chan T, fn func(ctx context.Context, v T) (U, error)) <-chan U {
+	out := make(chan U)
+	var seq atomic.Uint64
+	var wg sync.WaitGroup
+	for worker := range max(workers, 1) {
+		wg.Add(1)
+		p.spawn(func() {
+			defer wg.Done()
+			for {
+				v, ok := receive(p.ctx, in)
+				if !ok {
+					return
+				}
+				var u U
+				err := p.handle(name, worker, seq.Add(1), func(ctx context.Context) (err error) {
+					u, err = fn(ctx, v)
+					return err
+				})
+				if err != nil {
+					p.fail(name, err)
+					return
+				}
+				if !send(p.ctx, out, u) {
+					return
+				}
+			}
+		})
+	}
+	p.spawn(func() {
+		wg.Wait()
+		close(out)
+	})
+	return out
+}
+
+// Sink calls fn for every value received from in, on one goroutine, in
+// the order they arrive.
+func Sink[T any](p *Pipeline, name string, in <-chan T, fn func(ctx context.Context, v T) error) {
+	p.spawn(func() {
+		var seq uint64
+		for {
+			v, ok := receive(p.ctx, in)
+			if !ok {
+				return
+			}
+			seq++
+			if err := p.handle(name, 0, seq, func(ctx context.Context) error { return fn(ctx, v) }); err != nil {
+				p.fail(name, err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func square(_ context.Context, v int) (int, error) { return v * v, nil }
+
+func collect[T any](p *Pipeline, in <-chan T) *[]T {
+	var got []T
+	Sink(p, "collect", in, func(_ context.Context, v T) error {
+		got = append(got, v)
+		return nil
+	})
+	return &got
+}
+
+func TestStages(t *testing.T) {
+	p := New(context.Background(), Options{})
+	got := collect(p, Map(p, "square", 3, From(p, 1, 2, 3, 4, 5), square))
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(*got)
+	if fmt.Sprint(*got) != "[1 4 9 16 25]" {
+		t.Errorf("got %v", *got)
+	}
+}
+
+func TestOneWorkerKeepsOrder(t *testing.T) {
+	p := New(context.Background(), Options{})
+	got := collect(p, Map(p, "square", 1, From(p, 3, 1, 2), square))
+	if err := p.Wait(); err != nil || fmt.Sprint(*got) != "[9 1 4]" {
+		t.Errorf("got %v, %v", *got, err)
+	}
+}
+
+// A failing stage stops the ones before it, which would otherwise send
+// forever, and the one after it.
+func TestErrorStopsEveryStage(t *testing.T) {
+	p := New(context.Background(), Options{})
+	endless := Source(p, "count", func(ctx context.Context, emit func(int) bool) error {
+		for i := 0; emit(i); i++ {
+		}
+		return ctx.Err()
+	})
+	boom := errors.New("boom")
+	checked := Map(p, "check", 2, endless, func(_ context.Context, v int) (int, error) {
+		if v == 100 {
+			return 0, boom
+		}
+		return v, nil
+	})
+	collect(p, checked)
+	err := p.Wait()
+	if !errors.Is(err, boom) || err.Error() != "pipeline: check: boom" {
+		t.Errorf("Wait = %v", err)
+	}
+	if p.Context().Err() == nil {
+		t.Error("pipeline context not cancelled")
+	}
+}
+
+func TestPanicFailsStage(t *testing.T) {
+	p := New(context.Background(), Options{})
+	collect(p, Map(p, "explode", 1, From(p, 1), func(context.Context, int) (int, error) {
+		panic("stage bug")
+	}))
+	var pe *recovery.PanicError
+	if err := p.Wait(); !errors.As(err, &pe) || !strings.HasPrefix(err.Error(), "pipeline: explode: ") {
+		t.Errorf("Wait = %v", err)
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	p := New(context.Background(), Options{})
+	bad := errors.New("unreadable")
+	lines := Source(p, "read", func(_ context.Context, emit func(string) bool) error {
+		emit("first")
+		return bad
+	})
+	got := collect(p, lines)
+	if err := p.Wait(); !errors.Is(err, bad) || fmt.Sprint(*got) != "[first]" {
+		t.Errorf("got %v, Wait = %v", *got, err)
+	}
+}
+
+// A consumer that stops early calls Stop, and Wait then reports no error.
+func TestStop(t *testing.T) {
+	p := New(context.Background(), Options{})
+	endless := Source(p, "count", func(ctx context.Context, emit func(int) bool) error {
+		for i := 0; emit(i); i++ {
+		}
+		return ctx.Err()
+	})
+	out := Map(p, "square", 2, endless, square)
+	<-out
+	p.Stop()
+	if err := p.Wait(); err != nil {
+		t.Errorf("Wait = %v", err)
+	}
+}
+
+func TestParentCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New(ctx, Options{})
+	out := Map(p, "square", 1, From(p, 1, 2, 3), square)
+	<-out
+	cancel()
+	if err := p.Wait(); err != context.Canceled {
+		t.Errorf("Wait = %v", err)
+	}
+}
+
+type itemKey struct{}
+
+// itemRecorder is Hooks that tags the context with the item and keeps
+// what ItemEnd is given.
+type itemRecorder struct {
+	mu   sync.Mutex
+	ends []string
+}
+
+func (r *itemRecorder) ItemStart(ctx context.Context, stage string, worker int, seq uint64) context.Context {
+	return context.WithValue(ctx, itemKey{}, fmt.Sprintf("%s/%d/%d", stage, worker, seq))
+}
+
+func (r *itemRecorder) ItemEnd(ctx context.Context, err error, _ time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ends = append(r.ends, fmt.Sprintf("%v:%v", ctx.Value(itemKey{}), err))
+}
+
+func TestHooks(t *testing.T) {
+	hooks := &itemRecorder{}
+	p := New(context.Background(), Options{Hooks: hooks})
+	var seen []any
+	doubled := Map(p, "double", 1, From(p, 1, 2), func(ctx context.Context, v int) (int, error) {
+		seen = append(seen, ctx.Value(itemKey{}))
+		return 2 * v, nil
+	})
+	Sink(p, "print", doubled, func(ctx context.Context, v int) error {
+		if v == 4 {
+			return errors.New("full")
+		}
+		return nil
+	})
+	if err := p.Wait(); err == nil {
+		t.Fatal("no error")
+	}
+	if fmt.Sprint(seen) != "[double/0/1 double/0/2]" {
+		t.Errorf("stage saw %v", seen)
+	}
+	slices.Sort(hooks.ends)
+	want := "[double/0/1:<nil> double/0/2:<nil> print/0/1:<nil> print/0/2:full]"
+	if fmt.Sprint(hooks.ends) != want {
+		t.Errorf("ItemEnd got %v, want %v", hooks.ends, want)
+	}
+}
+
+func Example() {
+	p := New(context.Background(), Options{})
+	words := From(p, "pipelines", "are", "stages", "connected", "by", "channels")
+	lengths := Map(p, "measure", 1, words, func(_ context.Context, w string) (string, error) {
+		return fmt.Sprintf("%s=%d", w, len(w)), nil
+	})
+	Sink(p, "print", lengths, func(_ context.Context, s string) error {
+		fmt.Println(s)
+		return nil
+	})
+	if err := p.Wait(); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// pipelines=9
+	// are=3
+	// stages=6
+	// connected=9
+	// by=2
+	// channels=8
+}
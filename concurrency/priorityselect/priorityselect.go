@@ -0,0 +1,122 @@
+// Package priorityselect receives from two channels, preferring one.
+//
+// A plain select picks uniformly at random among ready cases, so a busy
+// low-priority channel gets half the turns even while urgent work waits.
+// The idiom to bias it is a nested select: first a non-blocking receive on
+// the high-priority channel, and only when it has nothing, a blocking
+// select over both.
+//
+// Strict priority starves the low channel for as long as the high one stays
+// busy. A Selector can bound that: after MaxStreak high values in a row it
+// takes a waiting low value before going back to the high channel.
+package priorityselect
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned once both channels are closed and drained.
+var ErrClosed = errors.New("priorityselect: channels closed")
+
+// Priority tells which channel a value came from.
+type Priority int
+
+const (
+	High Priority = iota
+	Low
+)
+
+func (p Priority) String() string {
+	if p == High {
+		return "high"
+	}
+	return "low"
+}
+
+// Select receives one value, from high whenever it has one ready. It
+// blocks until a value arrives or ctx is done. A closed channel is treated
+// as empty; when both are closed Select returns ErrClosed.
+func Select[T any](ctx context.Context, high, low <-chan T) (T, Priority, error) {
+	s := Selector[T]{high: high, low: low}
+	return s.Next(ctx)
+}
+
+// Options configures a Selector.
+type Options struct {
+	// MaxStreak is the number of high values taken in a row before a
+	// ready low value gets a turn. Zero means strict priority.
+	MaxStreak int
+}
+
+// Selector receives from a high and a low priority channel. It is not safe
+// for concurrent use; run one per consuming goroutine.
+type Selector[T any] struct {
+	high, low <-chan T
+	opts      Options
+	streak    int
+}
+
+// New returns a Selector over high and low.
+func New[T any](high, low <-chan T, opts Options) *Selector[T] {
+	return &Selector[T]{high: high, low: low, opts: opts}
+}
+
+// Next receives the next value, following the rules of Select and the
+// starvation bound of the Selector's Options.
+func (s *Selector[T]) Next(ctx context.Context) (T, Priority, error) {
+	var zero T
+	for {
+		if s.high == nil && s.low == nil {
+			return zero, Low, ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return zero, Low, err
+		}
+
+		// The high channel has had its streak: serve a waiting low value.
+		if s.opts.MaxStreak > 0 && s.streak >= s.opts.MaxStreak {
+			select {
+			case v, ok := <-s.low:
+				if !ok {
+					s.low = nil
+					continue
+				}
+				s.streak = 0
+				return v, Low, nil
+			default:
+			}
+		}
+
+		// Nested select: drain high first, and only then wait on both.
+		select {
+		case v, ok := <-s.high:
+			if !ok {
+				s.high = nil
+				continue
+			}
+			s.streak++
+			return v, High, nil
+		default:
+		}
+
+		select {
+		case v, ok := <-s.high:
+			if !ok {
+				s.high = nil
+				continue
+			}
+			s.streak++
+			return v, High, nil
+		case v, ok := <-s.low:
+			if !ok {
+				s.low = nil
+				continue
+			}
+			s.streak = 0
+			return v, Low, nil
+		case <-ctx.Done():
+			return zero, Low, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package priorityselect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// filled returns a closed channel holding n values.
+func filled(n int) chan int {
+	c := make(chan int, n)
+	for i := 0; i < n; i++ {
+		c <- i
+	}
+	close(c)
+	return c
+}
+
+func TestStrictDrainsHighFirst(t *testing.T) {
+	s := New(filled(100), filled(100), Options{})
+	for i := 0; i < 200; i++ {
+		_, p, err := s.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i >= 100; (p == Low) != want {
+			t.Fatalf("value %d came from %v", i, p)
+		}
+	}
+	if _, _, err := s.Next(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("err = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestMaxStreakAvoidsStarvation(t *testing.T) {
+	s := New(filled(300), filled(300), Options{MaxStreak: 3})
+	var got []Priority
+	for i := 0; i < 100; i++ {
+		_, p, err := s.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	for i, p := range got {
+		if want := i%4 == 3; (p == Low) != want {
+			t.Fatalf("value %d came from %v, want a low value every fourth", i, p)
+		}
+	}
+}
+
+func TestSelectWaits(t *testing.T) {
+	high, low := make(chan int), make(chan int)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		low <- 7
+	}()
+	v, p, err := Select(context.Background(), high, low)
+	if err != nil || v != 7 || p != Low {
+		t.Errorf("Select = %d, %v, %v", v, p, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := Select(ctx, high, low); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// share runs busy producers on both channels and returns the fraction of
+// the first n values that next took from the high channel. The consumer
+// yields after every value, standing in for the work it would do, so that
+// the producers keep both buffers full even on a single CPU.
+func share(n int, next func(ctx context.Context, high, low <-chan int) (Priority, error)) float64 {
+	ctx, cancel := context.WithCancel(context.Background())
+	high, low := make(chan int, 64), make(chan int, 64)
+	var wg sync.WaitGroup
+	for _, c := range []chan int{high, low} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case c <- 1:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	var highs int
+	for i := 0; i < n; i++ {
+		p, err := next(ctx, high, low)
+		if err != nil {
+			panic(err)
+		}
+		if p == High {
+			highs++
+		}
+		runtime.Gosched()
+	}
+	cancel()
+	wg.Wait()
+	return float64(highs) / float64(n)
+}
+
+func TestBiasUnderLoad(t *testing.T) {
+	const n = 20000
+	plain := share(n, func(ctx context.Context, high, low <-chan int) (Priority, error) {
+		select {
+		case <-high:
+			return High, nil
+		case <-low:
+			return Low, nil
+		}
+	})
+	strict := share(n, func(ctx context.Context, high, low <-chan int) (Priority, error) {
+		_, p, err := Select(ctx, high, low)
+		return p, err
+	})
+	var s *Selector[int]
+	bounded := share(n, func(ctx context.Context, high, low <-chan int) (Priority, error) {
+		if s == nil {
+			s = New(high, low, Options{MaxStreak: 3})
+		}
+		_, p, err := s.Next(ctx)
+		return p, err
+	})
+	t.Logf("high share: plain select %.2f, strict %.2f, MaxStreak 3 %.2f", plain, strict, bounded)
+
+	if strict < plain+0.2 {
+		t.Errorf("strict priority share %.2f is not clearly above plain select %.2f", strict, plain)
+	}
+	// With both channels busy every fourth value is a low one; an empty
+	// high buffer only lowers the share.
+	if bounded > 0.76 {
+		t.Errorf("MaxStreak 3 share %.2f, want at most 0.75", bounded)
+	}
+}
+
+func ExampleSelect() {
+	high, low := make(chan string, 1), make(chan string, 1)
+	low <- "report"
+	high <- "alert"
+	for i := 0; i < 2; i++ {
+		v, p, _ := Select(context.Background(), high, low)
+		fmt.Println(p, v)
+	}
+	// Output:
+	// high alert
+	// low report
+}
@@ -0,0 +1,126 @@
+// Package producer_consumer connects any number of producers to a pool of
+// consumers through a bounded buffered channel and measures how the buffer
+// behaves: how deep it gets and how long producers are blocked on it.
+//
+// The buffer is the point of contrast with the unbuffered pool in
+// concurrency/worker_unbuffed and patterns/workerpool. There a send only
+// completes once a goroutine has taken the work, so a returning call means
+// the work is being done. Here Put returns as soon as the item is buffered:
+// producers run ahead of consumers by up to the capacity, and whatever sits
+// in the buffer when the process dies is lost. Backpressure only reaches the
+// producers once the buffer is full, which is what the block-time metrics
+// make visible.
+package producer_consumer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the queue metrics.
+type Stats struct {
+	Capacity int
+	Depth    int // items buffered right now
+	MaxDepth int // deepest the buffer has been
+
+	Produced int64
+	Consumed int64
+
+	// Blocked counts Puts that found the buffer full and had to wait.
+	Blocked int64
+	// BlockTime is the total time producers spent waiting for room.
+	BlockTime time.Duration
+}
+
+// MeanBlockTime returns the average wait of the Puts that blocked.
+func (s Stats) MeanBlockTime() time.Duration {
+	if s.Blocked == 0 {
+		return 0
+	}
+	return s.BlockTime / time.Duration(s.Blocked)
+}
+
+// Queue is a bounded buffer between producers and consumers.
+type Queue struct {
+	items chan interface{}
+
+	produced  int64
+	consumed  int64
+	blocked   int64
+	blockTime int64
+	maxDepth  int64
+}
+
+// New creates a queue that buffers up to capacity items.
+func New(capacity int) *Queue {
+	return &Queue{items: make(chan interface{}, capacity)}
+}
+
+// Put adds item to the queue. It blocks while the queue is full and returns
+// ctx.Err() if ctx is done first.
+func (q *Queue) Put(ctx context.Context, item interface{}) error {
+	select {
+	case q.items <- item:
+	default:
+		atomic.AddInt64(&q.blocked, 1)
+		start := time.Now()
+		select {
+		case q.items <- item:
+			atomic.AddInt64(&q.blockTime, int64(time.Since(start)))
+		case <-ctx.Done():
+			atomic.AddInt64(&q.blockTime, int64(time.Since(start)))
+			return ctx.Err()
+		}
+	}
+	atomic.AddInt64(&q.produced, 1)
+	q.observeDepth()
+	return nil
+}
+
+func (q *Queue) observeDepth() {
+	depth := int64(len(q.items))
+	for {
+		max := atomic.LoadInt64(&q.maxDepth)
+		if depth <= max || atomic.CompareAndSwapInt64(&q.maxDepth, max, depth) {
+			return
+		}
+	}
+}
+
+// Close signals that no more items will be put. Consumers drain what is
+// left in the buffer and then return.
+func (q *Queue) Close() {
+	close(q.items)
+}
+
+// Consume runs n consumers that call handle for every item and blocks until
+// the queue is closed and drained.
+func (q *Queue) Consume(n int, handle func(item interface{})) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for item := range q.items {
+				handle(item)
+				atomic.AddInt64(&q.consumed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Stats returns a snapshot of the queue metrics.
+func (q *Queue) Stats() Stats {
+	return Stats{
+		Capacity:  cap(q.items),
+		Depth:     len(q.items),
+		MaxDepth:  int(atomic.LoadInt64(&q.maxDepth)),
+		Produced:  atomic.LoadInt64(&q.produced),
+		Consumed:  atomic.LoadInt64(&q.consumed),
+		Blocked:   atomic.LoadInt64(&q.blocked),
+		BlockTime: time.Duration(atomic.LoadInt64(&q.blockTime)),
+	}
+}
@@ -0,0 +1,77 @@
+package producer_consumer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func produce(q *Queue, producers, items int) {
+	var wg sync.WaitGroup
+	wg.Add(producers)
+	for p := 0; p < producers; p++ {
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < items; i++ {
+				q.Put(context.Background(), p*items+i)
+			}
+		}(p)
+	}
+	wg.Wait()
+	q.Close()
+}
+
+func TestSlowConsumersBlockProducers(t *testing.T) {
+	q := New(4)
+	go produce(q, 3, 20)
+
+	var sum int64
+	q.Consume(2, func(item interface{}) {
+		time.Sleep(time.Millisecond)
+		atomic.AddInt64(&sum, int64(item.(int)))
+	})
+
+	s := q.Stats()
+	if s.Produced != 60 || s.Consumed != 60 {
+		t.Errorf("expected 60 produced and consumed, got %d and %d", s.Produced, s.Consumed)
+	}
+	if sum != 59*60/2 {
+		t.Errorf("items were lost or duplicated, sum %d", sum)
+	}
+	if s.MaxDepth != s.Capacity {
+		t.Errorf("expected the buffer to fill up to %d, max depth %d", s.Capacity, s.MaxDepth)
+	}
+	if s.Blocked == 0 || s.BlockTime == 0 || s.MeanBlockTime() == 0 {
+		t.Errorf("expected producers to block on a full buffer: %+v", s)
+	}
+}
+
+func TestFastConsumersDoNotBlock(t *testing.T) {
+	q := New(64)
+	go produce(q, 2, 10)
+	q.Consume(2, func(item interface{}) {})
+
+	if s := q.Stats(); s.Blocked != 0 {
+		t.Errorf("a buffer larger than the workload should never block: %+v", s)
+	}
+}
+
+func TestPutContext(t *testing.T) {
+	q := New(1)
+	q.Put(context.Background(), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := q.Put(ctx, 2); err != context.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+	if s := q.Stats(); s.Produced != 1 || s.Blocked != 1 {
+		t.Errorf("unexpected stats after cancelled put: %+v", s)
+	}
+}
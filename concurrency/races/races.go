@@ -0,0 +1,167 @@
+// Package races shows the usual data races, each next to a version
+// without one, so that running its tests with -race teaches what the race
+// detector reports as much as how to avoid it.
+//
+// A data race is two goroutines accessing one variable at once, at least
+// one of them writing, with nothing ordering the accesses. The program's
+// behaviour is then undefined: updates are lost, and a reader may see a
+// value half written. Three shapes come up again and again:
+//
+//   - check-then-act, RacyAccount: a goroutine tests a condition and acts
+//     on it, and another changes the state in between, so two withdrawals
+//     both see enough money and the balance goes negative;
+//   - unsynchronised map writes, RacyTally: maps are not safe for
+//     concurrent use, and the runtime itself may stop the program with
+//     "concurrent map writes" before the race detector gets to;
+//   - loop-variable capture, SquaresRacy: goroutines started in a loop
+//     share a variable the loop keeps assigning.
+//
+// The racy versions are correct when called from one goroutine, which is
+// why such races survive review and ordinary tests. go test -race finds
+// them as soon as a test runs them concurrently, provided the accesses
+// actually happen at once: the detector watches what runs, it does not
+// prove the absence of races in code the tests do not reach.
+package races
+
+import "sync"
+
+// RacyAccount is a bank account whose Withdraw checks the balance and
+// then debits it with nothing held in between. Do not use it: it is here
+// to show the race.
+type RacyAccount struct {
+	balance int
+}
+
+// NewRacyAccount returns an account holding balance.
+func NewRacyAccount(balance int) *RacyAccount {
+	return &RacyAccount{balance: balance}
+}
+
+// Withdraw debits amount if the account holds that much and reports
+// whether it did. Two calls at once can both pass the check.
+func (a *RacyAccount) Withdraw(amount int) bool {
+	if a.balance < amount {
+		return false
+	}
+	a.balance -= amount
+	return true
+}
+
+// Balance returns what the account holds.
+func (a *RacyAccount) Balance() int { return a.balance }
+
+// Account is RacyAccount with the check and the debit made one step under
+// a mutex. Locking each of them separately would not do: the race is
+// between them, not in either.
+type Account struct {
+	mu      sync.Mutex
+	balance int
+}
+
+// NewAccount returns an account holding balance.
+func NewAccount(balance int) *Account {
+	return &Account{balance: balance}
+}
+
+// Withdraw debits amount if the account holds that much and reports
+// whether it did.
+func (a *Account) Withdraw(amount int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.balance < amount {
+		return false
+	}
+	a.balance -= amount
+	return true
+}
+
+// Balance returns what the account holds.
+func (a *Account) Balance() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.balance
+}
+
+// RacyTally counts occurrences of keys in a plain map. Do not use it: it
+// is here to show the race.
+type RacyTally struct {
+	counts map[string]int
+}
+
+// NewRacyTally returns an empty tally.
+func NewRacyTally() *RacyTally {
+	return &RacyTally{counts: make(map[string]int)}
+}
+
+// Add counts one occurrence of key.
+func (t *RacyTally) Add(key string) { t.counts[key]++ }
+
+// Count returns the occurrences of key counted so far.
+func (t *RacyTally) Count(key string) int { return t.counts[key] }
+
+// Tally is RacyTally with the map behind a mutex. For many goroutines
+// counting many keys, shardedmap spreads the lock.
+type Tally struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewTally returns an empty tally.
+func NewTally() *Tally {
+	return &Tally{counts: make(map[string]int)}
+}
+
+// Add counts one occurrence of key.
+func (t *Tally) Add(key string) {
+	t.mu.Lock()
+	t.counts[key]++
+	t.mu.Unlock()
+}
+
+// Count returns the occurrences of key counted so far.
+func (t *Tally) Count(key string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts[key]
+}
+
+// SquaresRacy squares xs, a goroutine per element. Its loop assigns to a
+// variable declared outside it, which every goroutine reads: by the time
+// one runs, i may be another element's index, so some squares are written
+// twice and others never. Go 1.22 gave each iteration of a loop that
+// declares its variables with := a fresh copy, so the classic
+//
+//	for i := 0; i < n; i++ { go func() { use(i) }() }
+//
+// no longer races; assigning to an outer variable, as here, still does.
+func SquaresRacy(xs []int) []int {
+	out := make([]int, len(xs))
+	var wg sync.WaitGroup
+	var i int
+	for i = range xs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i] = xs[i] * xs[i]
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// Squares is SquaresRacy with the index declared by the loop, so that
+// each goroutine has its own. Passing it as an argument, go func(i int),
+// does the same and is what code written before Go 1.22 does.
+func Squares(xs []int) []int {
+	out := make([]int, len(xs))
+	var wg sync.WaitGroup
+	for i := range xs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i] = xs[i] * xs[i]
+		}()
+	}
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,173 @@
+package races
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+// envHelper makes the test binary run the racy program it names instead
+// of the tests.
+const envHelper = "RACES_TEST_HELPER"
+
+// racy are programs that use a racy version from two goroutines. Nothing
+// orders the goroutines' accesses, so the race detector reports them
+// however they are scheduled.
+var racy = map[string]func(){
+	"check-then-act": func() {
+		a := NewRacyAccount(1)
+		twice(func() { a.Withdraw(1) })
+	},
+	"map writes": func() {
+		t := NewRacyTally()
+		twice(func() { t.Add("go") })
+	},
+	"loop variable": func() {
+		SquaresRacy([]int{1, 2, 3})
+	},
+}
+
+func twice(f func()) {
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMain(m *testing.M) {
+	if name := os.Getenv(envHelper); name != "" {
+		racy[name]()
+		os.Exit(0)
+	}
+	leaks.VerifyTestMain(m)
+}
+
+// TestRacyVersionsAreCaught runs each racy program in a child process,
+// where the race it makes can be reported without failing this test, and
+// expects it to be caught: by the race detector, or for the map by the
+// runtime, whichever notices first.
+func TestRacyVersionsAreCaught(t *testing.T) {
+	if !raceEnabled {
+		t.Skip("needs the race detector: go test -race")
+	}
+	for name := range racy {
+		t.Run(name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=NONE")
+			cmd.Env = append(os.Environ(), envHelper+"="+name)
+			out, err := cmd.CombinedOutput()
+			caught := strings.Contains(string(out), "WARNING: DATA RACE") ||
+				strings.Contains(string(out), "concurrent map writes")
+			if err == nil || !caught {
+				t.Errorf("no race reported (%v):\n%s", err, out)
+			}
+		})
+	}
+}
+
+// The racy versions are right as long as one goroutine uses them, which
+// is how they get past ordinary tests.
+func TestRacyVersionsSequential(t *testing.T) {
+	a := NewRacyAccount(10)
+	if !a.Withdraw(7) || a.Withdraw(7) || a.Balance() != 3 {
+		t.Errorf("balance %d", a.Balance())
+	}
+	tally := NewRacyTally()
+	tally.Add("go")
+	tally.Add("go")
+	if n := tally.Count("go"); n != 2 {
+		t.Errorf("count %d", n)
+	}
+}
+
+// The fixed versions are used concurrently here, so that under -race the
+// detector checks them too.
+func TestAccount(t *testing.T) {
+	const balance, clients = 100, 8
+	a := NewAccount(balance)
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		ok int
+	)
+	for range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range balance {
+				if a.Withdraw(1) {
+					mu.Lock()
+					ok++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if ok != balance || a.Balance() != 0 {
+		t.Errorf("%d withdrawals succeeded, balance %d", ok, a.Balance())
+	}
+}
+
+func TestTally(t *testing.T) {
+	tally := NewTally()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				tally.Add("all")
+				tally.Add(fmt.Sprint(i % 2))
+			}
+		}()
+	}
+	wg.Wait()
+	if tally.Count("all") != 800 || tally.Count("0") != 400 || tally.Count("1") != 400 {
+		t.Errorf("counts %d %d %d", tally.Count("all"), tally.Count("0"), tally.Count("1"))
+	}
+}
+
+func TestSquares(t *testing.T) {
+	xs := make([]int, 100)
+	for i := range xs {
+		xs[i] = i
+	}
+	got := Squares(xs)
+	for i, v := range got {
+		if v != i*i {
+			t.Fatalf("square of %d = %d", i, v)
+		}
+	}
+	if !slices.Equal(Squares(nil), []int{}) {
+		t.Error("Squares(nil)")
+	}
+}
+
+func Example() {
+	a := NewAccount(3)
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.Withdraw(1)
+		}()
+	}
+	wg.Wait()
+	fmt.Println("balance", a.Balance())
+	fmt.Println(Squares([]int{1, 2, 3}))
+	// Output:
+	// balance 0
+	// [1 4 9]
+}
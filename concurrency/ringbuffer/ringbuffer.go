@@ -0,0 +1,123 @@
+// Package ringbuffer is a lock-free ring buffer for exactly one producer
+// goroutine and one consumer goroutine.
+//
+// The producer alone writes the tail index and the consumer alone writes
+// the head, so neither needs a lock or a compare-and-swap: each publishes
+// its index with an atomic store after touching the slot, and the other
+// reads it with an atomic load before touching the slot. Those pairs are
+// the happens-before edges the race detector checks. The indexes only
+// grow; a slot is index modulo the capacity, a power of two. Each index
+// sits on its own cache line so that the two goroutines do not slow each
+// other down by writing next to each other.
+//
+// A buffered channel does the same job for any number of goroutines and
+// parks a goroutine that has to wait; the ring buffer buys speed by
+// giving both up. Push and Pop spin while they wait, which pays off only
+// when producer and consumer each have a core to themselves: compare
+//
+//	go test -run NONE -bench . -cpu 1,2,4 ./concurrency/ringbuffer
+//
+// on a machine with fewer cores and the channel wins. Misuse, such as two
+// producers, corrupts the ring silently.
+package ringbuffer
+
+import (
+	"context"
+	"math/bits"
+	"runtime"
+	"sync/atomic"
+)
+
+const cacheLine = 64
+
+// Ring is a single-producer single-consumer queue of fixed capacity.
+type Ring[T any] struct {
+	buf  []T
+	mask uint64
+
+	_    [cacheLine]byte
+	head atomic.Uint64 // next slot to read, written by the consumer
+	_    [cacheLine - 8]byte
+	tail atomic.Uint64 // next slot to write, written by the producer
+	_    [cacheLine - 8]byte
+}
+
+// New returns an empty Ring with room for capacity values, rounded up to
+// a power of two.
+func New[T any](capacity int) *Ring[T] {
+	n := 1 << bits.Len(uint(max(capacity, 1)-1))
+	return &Ring[T]{buf: make([]T, n), mask: uint64(n - 1)}
+}
+
+// Cap returns the capacity.
+func (r *Ring[T]) Cap() int { return len(r.buf) }
+
+// Len returns the number of values queued. Called from a goroutine other
+// than the producer and consumer, it is only an estimate.
+func (r *Ring[T]) Len() int {
+	head := r.head.Load()
+	return int(r.tail.Load() - head)
+}
+
+// TryPush queues v and reports true, or reports false if the ring is
+// full. Only the producer may call it.
+func (r *Ring[T]) TryPush(v T) bool {
+	tail := r.tail.Load()
+	if tail-r.head.Load() == uint64(len(r.buf)) {
+		return false
+	}
+	r.buf[tail&r.mask] = v
+	r.tail.Store(tail + 1)
+	return true
+}
+
+// TryPop removes and returns the oldest value, or reports false if the
+// ring is empty. Only the consumer may call it.
+func (r *Ring[T]) TryPop() (T, bool) {
+	head := r.head.Load()
+	var zero T
+	if head == r.tail.Load() {
+		return zero, false
+	}
+	slot := &r.buf[head&r.mask]
+	v := *slot
+	// Drop the reference so the ring does not keep v alive.
+	*slot = zero
+	r.head.Store(head + 1)
+	return v, true
+}
+
+// spins is how many times Push and Pop retry before yielding the
+// processor.
+const spins = 64
+
+// Push queues v, waiting while the ring is full, or returns ctx's error.
+// Only the producer may call it.
+func (r *Ring[T]) Push(ctx context.Context, v T) error {
+	for i := 0; !r.TryPush(v); i++ {
+		if i%spins == spins-1 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			runtime.Gosched()
+		}
+	}
+	return nil
+}
+
+// Pop removes and returns the oldest value, waiting while the ring is
+// empty, or returns ctx's error. Only the consumer may call it.
+func (r *Ring[T]) Pop(ctx context.Context) (T, error) {
+	for i := 0; ; i++ {
+		if v, ok := r.TryPop(); ok {
+			return v, nil
+		}
+		if i%spins == spins-1 {
+			if err := ctx.Err(); err != nil {
+				var zero T
+				return zero, err
+			}
+			runtime.Gosched()
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package ringbuffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func TestCapacity(t *testing.T) {
+	for _, tc := range []struct{ ask, want int }{{0, 1}, {1, 1}, {3, 4}, {8, 8}, {1000, 1024}} {
+		if got := New[int](tc.ask).Cap(); got != tc.want {
+			t.Errorf("New(%d).Cap() = %d, want %d", tc.ask, got, tc.want)
+		}
+	}
+}
+
+func TestFullAndEmpty(t *testing.T) {
+	r := New[int](4)
+	if _, ok := r.TryPop(); ok {
+		t.Error("popped from an empty ring")
+	}
+	for i := range 4 {
+		if !r.TryPush(i) {
+			t.Fatalf("push %d failed", i)
+		}
+	}
+	if r.TryPush(4) || r.Len() != 4 {
+		t.Errorf("pushed into a full ring, Len %d", r.Len())
+	}
+	// Go round the ring several times to cross the wrap.
+	for i := range 20 {
+		v, ok := r.TryPop()
+		if !ok || v != i {
+			t.Fatalf("pop = %d, %v, want %d", v, ok, i)
+		}
+		if !r.TryPush(i + 4) {
+			t.Fatalf("push %d failed", i+4)
+		}
+	}
+	if r.Len() != 4 {
+		t.Errorf("Len = %d", r.Len())
+	}
+}
+
+func TestPopClearsSlot(t *testing.T) {
+	r := New[*int](2)
+	r.TryPush(new(int))
+	r.TryPop()
+	if r.buf[0] != nil {
+		t.Error("popped slot still refers to its value")
+	}
+}
+
+func TestProducerConsumer(t *testing.T) {
+	const n = 200000
+	ctx := context.Background()
+	r := New[int](64)
+	done := make(chan error, 1)
+	go func() {
+		for i := range n {
+			if err := r.Push(ctx, i); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for i := range n {
+		v, err := r.Pop(ctx)
+		if err != nil || v != i {
+			t.Fatalf("pop %d = %d, %v", i, v, err)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r := New[int](1)
+	if _, err := r.Pop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Pop on empty = %v", err)
+	}
+	r.TryPush(1)
+	if err := r.Push(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Push on full = %v", err)
+	}
+}
+
+// transfer moves b.N values from one goroutine to another.
+func transfer(b *testing.B, push func(int), pop func() int) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range b.N {
+			pop()
+		}
+	}()
+	for i := range b.N {
+		push(i)
+	}
+	<-done
+}
+
+func BenchmarkTransfer(b *testing.B) {
+	for _, size := range []int{64, 1024} {
+		b.Run(fmt.Sprint("ring/", size), func(b *testing.B) {
+			r := New[int](size)
+			ctx := context.Background()
+			transfer(b, func(v int) { r.Push(ctx, v) }, func() int { v, _ := r.Pop(ctx); return v })
+		})
+		b.Run(fmt.Sprint("chan/", size), func(b *testing.B) {
+			c := make(chan int, size)
+			transfer(b, func(v int) { c <- v }, func() int { return <-c })
+		})
+	}
+}
+
+func Example() {
+	r := New[string](4)
+	r.TryPush("a")
+	r.TryPush("b")
+	v, _ := r.TryPop()
+	fmt.Println(v, r.Len(), r.Cap())
+	// Output:
+	// a 1 4
+}
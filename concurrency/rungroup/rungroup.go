@@ -0,0 +1,151 @@
+// Package rungroup ties the lifetimes of a program's long-running parts
+// together, after github.com/oklog/run: an HTTP server, a worker pool, a
+// scheduler and a signal handler are each added as an actor, a pair of
+// functions that runs it and interrupts it. Run starts every actor and,
+// as soon as the first one returns, for whatever reason, shuts the others
+// down and returns the first one's error.
+//
+// Unlike oklog/run, shutdown is ordered: actors are interrupted in the
+// reverse of the order they were added, and each one has returned before
+// the next is interrupted, the way deferred calls unwind. Add what others
+// depend on first, so that, say, the HTTP server stops taking requests
+// before the pool that serves them is drained.
+package rungroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// SignalError is returned by the actor added with Signals when one of its
+// signals arrives.
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return fmt.Sprintf("rungroup: received %v", e.Signal)
+}
+
+type actor struct {
+	name      string
+	execute   func() error
+	interrupt func(error)
+}
+
+// Group is a set of actors. The zero value is an empty group ready to use.
+// A Group runs once.
+type Group struct {
+	actors []actor
+	// OnExit, if set, is called from Run every time an actor returns, with
+	// its error.
+	OnExit func(name string, err error)
+}
+
+// Add adds an actor. execute must block until the actor is done; interrupt
+// must make execute return, and is called with the error that ended the
+// group. interrupt is called even if execute has not started yet, and is
+// not called for the actor whose return ended the group. A panic in
+// execute ends the group with a *recovery.PanicError.
+func (g *Group) Add(name string, execute func() error, interrupt func(error)) {
+	g.actors = append(g.actors, actor{name, execute, interrupt})
+}
+
+// AddContext adds an actor that runs fn until its context is cancelled.
+func (g *Group) AddContext(name string, fn func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g.Add(name, func() error {
+		return fn(ctx)
+	}, func(error) {
+		cancel()
+	})
+}
+
+// Signals adds an actor that returns a *SignalError when the process
+// receives one of sigs. The signals are caught from the moment Signals is
+// called, so one that arrives before Run starts the actor is not missed,
+// and are released when the actor returns.
+func (g *Group) Signals(sigs ...os.Signal) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	stop := make(chan struct{})
+	g.Add("signals", func() error {
+		defer signal.Stop(c)
+		select {
+		case sig := <-c:
+			return &SignalError{sig}
+		case <-stop:
+			return nil
+		}
+	}, func(error) {
+		close(stop)
+	})
+}
+
+// Server adds an actor that serves srv on ln and, when interrupted, shuts
+// srv down gracefully, giving in-flight requests up to grace to finish
+// before closing their connections. A server shut down this way returns
+// nil rather than http.ErrServerClosed.
+func (g *Group) Server(name string, srv *http.Server, ln net.Listener, grace time.Duration) {
+	g.Add(name, func() error {
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}, func(error) {
+		ctx, cancel := context.WithTimeout(context.Background(), grace)
+		defer cancel()
+		if srv.Shutdown(ctx) != nil {
+			srv.Close()
+		}
+	})
+}
+
+type exit struct {
+	i   int
+	err error
+}
+
+// Run starts every actor and blocks until all of them have returned. It
+// returns the error of the actor that returned first, which is nil if that
+// actor returned cleanly. An empty group returns nil at once.
+func (g *Group) Run() error {
+	if len(g.actors) == 0 {
+		return nil
+	}
+	exits := make(chan exit, len(g.actors))
+	for i, a := range g.actors {
+		go func() {
+			exits <- exit{i, recovery.Do(a.execute)}
+		}()
+	}
+
+	done := make([]bool, len(g.actors))
+	record := func(e exit) {
+		done[e.i] = true
+		if g.OnExit != nil {
+			g.OnExit(g.actors[e.i].name, e.err)
+		}
+	}
+	first := <-exits
+	record(first)
+
+	for i := len(g.actors) - 1; i >= 0; i-- {
+		if done[i] {
+			continue
+		}
+		g.actors[i].interrupt(first.err)
+		for !done[i] {
+			record(<-exits)
+		}
+	}
+	return first.err
+}
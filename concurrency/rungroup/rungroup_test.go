@@ -0,0 +1,193 @@
+package rungroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// journal records the order of events across actors.
+type journal struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (j *journal) add(format string, args ...any) {
+	j.mu.Lock()
+	j.events = append(j.events, fmt.Sprintf(format, args...))
+	j.mu.Unlock()
+}
+
+func (j *journal) String() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return strings.Join(j.events, ", ")
+}
+
+// service adds an actor that blocks until interrupted, taking a moment to
+// stop, and journals both.
+func service(g *Group, j *journal, name string) {
+	stop := make(chan struct{})
+	g.Add(name, func() error {
+		<-stop
+		time.Sleep(time.Millisecond)
+		j.add("%s stopped", name)
+		return nil
+	}, func(err error) {
+		j.add("interrupt %s (%v)", name, err)
+		close(stop)
+	})
+}
+
+func TestOrderedShutdown(t *testing.T) {
+	leaks.Check(t)
+	var g Group
+	j := &journal{}
+	errCrash := errors.New("crash")
+	service(&g, j, "db")
+	service(&g, j, "pool")
+	g.Add("worker", func() error { return errCrash }, func(error) { t.Error("the actor that ended the group was interrupted") })
+	service(&g, j, "http")
+
+	if err := g.Run(); !errors.Is(err, errCrash) {
+		t.Errorf("Run = %v", err)
+	}
+	want := "interrupt http (crash), http stopped, interrupt pool (crash), pool stopped, interrupt db (crash), db stopped"
+	if got := j.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestCleanExitEndsGroup(t *testing.T) {
+	leaks.Check(t)
+	var g Group
+	var exits []string
+	g.OnExit = func(name string, err error) { exits = append(exits, fmt.Sprint(name, ":", err)) }
+	g.Add("batch", func() error { return nil }, func(error) {})
+	g.AddContext("loop", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := g.Run(); err != nil {
+		t.Errorf("Run = %v", err)
+	}
+	if got := fmt.Sprint(exits); got != "[batch:<nil> loop:context canceled]" {
+		t.Errorf("exits %s", got)
+	}
+}
+
+func TestPanicEndsGroup(t *testing.T) {
+	leaks.Check(t)
+	var g Group
+	g.Add("buggy", func() error { panic("boom") }, func(error) {})
+	g.AddContext("idle", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	var p *recovery.PanicError
+	if err := g.Run(); !errors.As(err, &p) {
+		t.Errorf("Run = %v, want a panic", err)
+	}
+}
+
+func TestEmptyGroup(t *testing.T) {
+	var g Group
+	if err := g.Run(); err != nil {
+		t.Errorf("Run = %v", err)
+	}
+}
+
+func TestServerDrainsBeforeStopping(t *testing.T) {
+	leaks.Check(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+
+	var g Group
+	g.Server("http", srv, ln, 5*time.Second)
+	errQuit := errors.New("quit")
+	g.Add("trigger", func() error {
+		<-started
+		return errQuit
+	}, func(error) {})
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	run := make(chan error, 1)
+	go func() { run <- g.Run() }()
+
+	<-started
+	select {
+	case err := <-run:
+		t.Fatalf("Run returned %v with a request in flight", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if got := <-body; got != "done" {
+		t.Errorf("in-flight request got %q", got)
+	}
+	if err := <-run; !errors.Is(err, errQuit) {
+		t.Errorf("Run = %v", err)
+	}
+	http.DefaultClient.CloseIdleConnections()
+}
+
+func Example() {
+	var g Group
+	g.OnExit = func(name string, err error) { fmt.Println(name, "exited:", err) }
+
+	jobs := make(chan int)
+	g.AddContext("consumer", func(ctx context.Context) error {
+		done := 0
+		for {
+			select {
+			case <-ctx.Done():
+				fmt.Println("consumed", done, "jobs")
+				return nil
+			case <-jobs:
+				done++
+			}
+		}
+	})
+	g.Add("producer", func() error {
+		for n := range 3 {
+			jobs <- n
+		}
+		return errors.New("out of work")
+	}, func(error) {})
+
+	fmt.Println(g.Run())
+	// Output:
+	// producer exited: out of work
+	// consumed 3 jobs
+	// consumer exited: <nil>
+	// out of work
+}
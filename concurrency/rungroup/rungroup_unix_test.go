@@ -0,0 +1,28 @@
+//go:build unix
+
+package rungroup
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestSignals(t *testing.T) {
+	leaks.Check(t)
+	var g Group
+	g.Signals(syscall.SIGUSR1)
+	g.AddContext("idle", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	// Caught although Run has not started yet.
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	var sig *SignalError
+	if err := g.Run(); !errors.As(err, &sig) || sig.Signal != syscall.SIGUSR1 {
+		t.Errorf("Run = %v", err)
+	}
+}
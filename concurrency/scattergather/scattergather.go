@@ -0,0 +1,130 @@
+// Package scattergather sends a set of requests at once and gathers
+// whatever answers arrive before a deadline.
+//
+// An aggregator, a page built from several backends for instance, is
+// better served by most of its parts in time than by all of them late.
+// Gather starts every request, up to a limit at a time, and returns when
+// all have answered or the context is done, whichever comes first. Each
+// request has its own result: a failure or a timeout of one leaves the
+// others' results intact, and the caller decides what is enough.
+//
+// At the deadline Gather returns without waiting for requests still
+// running; those that honour their context return soon after, and their
+// results are dropped.
+package scattergather
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Request is one of the requests to scatter.
+type Request[T any] struct {
+	Name string
+	Do   func(ctx context.Context) (T, error)
+}
+
+// Result is the answer to a Request.
+type Result[T any] struct {
+	Name  string
+	Value T
+	// Err is the request's error, or the context's if it did not answer
+	// in time.
+	Err     error
+	Elapsed time.Duration
+}
+
+// Gather runs reqs with up to limit at once, every one at once if limit is
+// not positive, and returns their results in the order of reqs.
+func Gather[T any](ctx context.Context, reqs []Request[T], limit int) []Result[T] {
+	if limit <= 0 {
+		limit = len(reqs)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	start := time.Now()
+	sem := make(chan struct{}, limit)
+	type answer struct {
+		i int
+		r Result[T]
+	}
+	// Buffered, so that requests finishing after Gather returned do not
+	// block.
+	answers := make(chan answer, len(reqs))
+	for i, req := range reqs {
+		go func() {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			v, err := req.Do(ctx)
+			answers <- answer{i, Result[T]{Name: req.Name, Value: v, Err: err, Elapsed: time.Since(start)}}
+		}()
+	}
+
+	results := make([]Result[T], len(reqs))
+	done := make([]bool, len(reqs))
+	for range reqs {
+		select {
+		case a := <-answers:
+			results[a.i], done[a.i] = a.r, true
+		case <-ctx.Done():
+			// Keep the answers that made it in time.
+			for len(answers) > 0 {
+				a := <-answers
+				results[a.i], done[a.i] = a.r, true
+			}
+			err := context.Cause(ctx)
+			for i, req := range reqs {
+				if !done[i] {
+					results[i] = Result[T]{Name: req.Name, Err: err, Elapsed: time.Since(start)}
+				}
+			}
+			return results
+		}
+	}
+	return results
+}
+
+// Values returns the values of the results that succeeded, by name, and
+// the results that did not.
+func Values[T any](results []Result[T]) (map[string]T, []Result[T]) {
+	values := map[string]T{}
+	var failed []Result[T]
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+			continue
+		}
+		values[r.Name] = r.Value
+	}
+	return values, failed
+}
+
+// Get returns a Request fetching url with client, http.DefaultClient if
+// nil, whose value is the body of a 200 response.
+func Get(name string, client *http.Client, url string) Request[[]byte] {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return Request[[]byte]{Name: name, Do: func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("scattergather: %s: %s", name, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}}
+}
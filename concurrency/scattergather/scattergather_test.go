@@ -0,0 +1,177 @@
+package scattergather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// after returns a request answering v after d, or failing with err.
+func after(name string, d time.Duration, v int, err error) Request[int] {
+	return Request[int]{Name: name, Do: func(ctx context.Context) (int, error) {
+		select {
+		case <-time.After(d):
+			return v, err
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}}
+}
+
+func TestGather(t *testing.T) {
+	leaks.Check(t)
+	down := errors.New("down")
+	reqs := []Request[int]{
+		after("slow", 20*time.Millisecond, 1, nil),
+		after("fast", 0, 2, nil),
+		after("broken", 0, 0, down),
+	}
+	start := time.Now()
+	results := Gather(context.Background(), reqs, 0)
+	// Concurrent: as slow as the slowest, not the sum.
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("took %v", elapsed)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, fmt.Sprint(r.Name, "=", r.Value, ",", r.Err))
+	}
+	if want := "[slow=1,<nil> fast=2,<nil> broken=0,down]"; fmt.Sprint(got) != want {
+		t.Errorf("got %v", got)
+	}
+	values, failed := Values(results)
+	if fmt.Sprint(values) != "map[fast:2 slow:1]" || len(failed) != 1 || failed[0].Err != down {
+		t.Errorf("Values = %v, %v", values, failed)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	leaks.Check(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	results := Gather(ctx, []Request[int]{
+		after("quick", time.Millisecond, 1, nil),
+		after("late", time.Second, 2, nil),
+		// Ignores its context: Gather must not wait for it.
+		{Name: "stubborn", Do: func(context.Context) (int, error) { time.Sleep(100 * time.Millisecond); return 3, nil }},
+	}, 0)
+	if r := results[0]; r.Err != nil || r.Value != 1 {
+		t.Errorf("quick: %+v", r)
+	}
+	for _, r := range results[1:] {
+		if !errors.Is(r.Err, context.DeadlineExceeded) || r.Elapsed > 90*time.Millisecond {
+			t.Errorf("%s: %+v", r.Name, r)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	leaks.Check(t)
+	var running, peak atomic.Int64
+	reqs := make([]Request[int], 12)
+	for i := range reqs {
+		reqs[i] = Request[int]{Name: fmt.Sprint(i), Do: func(context.Context) (int, error) {
+			n := running.Add(1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(2 * time.Millisecond)
+			running.Add(-1)
+			return i, nil
+		}}
+	}
+	results := Gather(context.Background(), reqs, 3)
+	if peak.Load() != 3 {
+		t.Errorf("%d requests at once", peak.Load())
+	}
+	for i, r := range results {
+		if r.Value != i || r.Err != nil {
+			t.Errorf("result %d: %+v", i, r)
+		}
+	}
+	if got := Gather[int](context.Background(), nil, 3); len(got) != 0 {
+		t.Errorf("no requests: %v", got)
+	}
+}
+
+func TestGet(t *testing.T) {
+	leaks.Check(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			fmt.Fprint(w, "fine")
+			return
+		}
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	results := Gather(context.Background(), []Request[[]byte]{
+		Get("ok", nil, srv.URL+"/ok"),
+		Get("bad", srv.Client(), srv.URL+"/bad"),
+		Get("invalid", nil, "http://[::1"),
+	}, 2)
+	if string(results[0].Value) != "fine" || results[0].Err != nil {
+		t.Errorf("ok: %+v", results[0])
+	}
+	if err := results[1].Err; err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("bad: %v", err)
+	}
+	if results[2].Err == nil {
+		t.Error("invalid URL fetched")
+	}
+	srv.CloseClientConnections()
+}
+
+// ExampleGather builds a dashboard from three backends within a deadline:
+// the slow one is left out and the broken one reported.
+func ExampleGather() {
+	backend := func(delay time.Duration, status int, body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+		}))
+	}
+	weather := backend(0, http.StatusOK, "sunny, 21°C")
+	news := backend(0, http.StatusInternalServerError, "")
+	stocks := backend(time.Second, http.StatusOK, "ACME +3%")
+	for _, s := range []*httptest.Server{weather, news, stocks} {
+		defer s.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	values, failed := Values(Gather(ctx, []Request[[]byte]{
+		Get("weather", nil, weather.URL),
+		Get("news", nil, news.URL),
+		Get("stocks", nil, stocks.URL),
+	}, 0))
+	for name, v := range values {
+		fmt.Printf("%s: %s\n", name, v)
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Name < failed[j].Name })
+	for _, r := range failed {
+		if errors.Is(r.Err, context.DeadlineExceeded) {
+			fmt.Printf("%s timed out\n", r.Name)
+		} else {
+			fmt.Printf("%s failed: %v\n", r.Name, r.Err)
+		}
+	}
+	// Output:
+	// weather: sunny, 21°C
+	// news failed: scattergather: news: 500 Internal Server Error
+	// stocks timed out
+}
@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a job runs next.
+type Schedule interface {
+	// Next returns the first activation strictly after t.
+	Next(t time.Time) time.Time
+}
+
+type every time.Duration
+
+// Every runs a job at a fixed interval, measured from the previous
+// activation.
+func Every(d time.Duration) Schedule {
+	if d <= 0 {
+		panic("scheduler: interval must be positive")
+	}
+	return every(d)
+}
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// cron is a parsed five field spec. Each field is a set of allowed values.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+}
+
+// maxSearch bounds the search for the next activation of a spec such as
+// "0 0 30 2 *" that never fires.
+const maxSearch = 5 * 366 * 24 * 60
+
+// Cron parses a cron-ish spec of five fields: minute, hour, day of month,
+// month and day of week. Fields accept *, numbers, ranges a-b, lists a,b
+// and steps */n, a-b/n or a/n, which runs from a to the field's maximum.
+// Both day fields must match, unlike classic cron
+// which ORs them when both are restricted.
+func Cron(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("scheduler: cron spec %q must have 5 fields", spec)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := parseField(f, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("scheduler: cron spec %q: %v", spec, err)
+		}
+		sets[i] = set
+	}
+	return &cron{minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4]}, nil
+}
+
+// MustCron is like Cron but panics on a bad spec.
+func MustCron(spec string) Schedule {
+	s, err := Cron(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step, stepped := 1, false
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step, stepped, part = n, true, part[:i]
+		}
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("bad range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+			lo, hi = n, n
+			if stepped {
+				// a/n is a-max/n, as in classic cron.
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for i := 0; i < maxSearch; i++ {
+		if c.month&(1<<uint(t.Month())) != 0 &&
+			c.dom&(1<<uint(t.Day())) != 0 &&
+			c.dow&(1<<uint(t.Weekday())) != 0 &&
+			c.hour&(1<<uint(t.Hour())) != 0 &&
+			c.minute&(1<<uint(t.Minute())) != 0 {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
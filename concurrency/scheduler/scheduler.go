@@ -0,0 +1,248 @@
+// Package scheduler runs registered jobs on a schedule, either a fixed
+// interval or a cron-ish spec. Every job has an overlap policy that decides
+// what happens when it is due while its previous run is still going: skip
+// the activation, queue it behind the running one, or run in parallel.
+// Time comes from a Clock, so the scheduler can be driven by a clock.Fake
+// in tests. Hooks see every run start and end, and can put what they like
+// in the context the job gets.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// Clock is the source of time for a Scheduler. Every clock.Clock is one.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Overlap is the policy for an activation that arrives while the job is
+// still running.
+type Overlap int
+
+const (
+	// Skip drops the activation.
+	Skip Overlap = iota
+	// Queue runs the activation once the running one has finished.
+	Queue
+	// Parallel runs the activation right away, next to the running one.
+	Parallel
+)
+
+var (
+	// ErrDuplicate is returned by Add for a name that is already taken.
+	ErrDuplicate = errors.New("scheduler: duplicate job name")
+	// ErrStopped is returned by Add after Stop.
+	ErrStopped = errors.New("scheduler: stopped")
+)
+
+// Hooks are called on the goroutine of a run, around it. Runs of a job
+// are numbered from 1.
+type Hooks interface {
+	// RunStart is called before the job runs. The job, and RunEnd, get
+	// the context it returns.
+	RunStart(ctx context.Context, job string, run int) context.Context
+	// RunEnd is called once the job has returned after d, as measured by
+	// the scheduler's Clock.
+	RunEnd(ctx context.Context, d time.Duration)
+}
+
+type noHooks struct{}
+
+func (noHooks) RunStart(ctx context.Context, _ string, _ int) context.Context { return ctx }
+func (noHooks) RunEnd(context.Context, time.Duration)                         {}
+
+// Options configures a Scheduler.
+type Options struct {
+	// Hooks default to none.
+	Hooks Hooks
+}
+
+func (o *Options) defaults() {
+	if o.Hooks == nil {
+		o.Hooks = noHooks{}
+	}
+}
+
+// JobStats counts what happened to the activations of a job.
+type JobStats struct {
+	Runs    int // runs started
+	Skipped int // activations dropped by Skip
+	Queued  int // activations deferred by Queue
+	Running int // runs in progress right now
+}
+
+type job struct {
+	name     string
+	schedule Schedule
+	overlap  Overlap
+	fn       func(ctx context.Context)
+
+	mu      sync.Mutex
+	running int
+	pending int
+	stats   JobStats
+}
+
+// Scheduler runs jobs on their schedules until it is stopped.
+type Scheduler struct {
+	clock Clock
+	hooks Hooks
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	started bool
+	stopped bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	loops  sync.WaitGroup
+	runs   sync.WaitGroup
+}
+
+// New creates a scheduler. A nil clock uses clock.Real.
+func New(c Clock) *Scheduler {
+	return NewWith(c, Options{})
+}
+
+// NewWith creates a scheduler configured by opts. A nil clock uses
+// clock.Real.
+func NewWith(c Clock, opts Options) *Scheduler {
+	if c == nil {
+		c = clock.Real
+	}
+	opts.defaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		clock:  c,
+		hooks:  opts.Hooks,
+		jobs:   make(map[string]*job),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Add registers fn to run on schedule with the given overlap policy. Jobs
+// added after Start begin right away.
+func (s *Scheduler) Add(name string, schedule Schedule, overlap Overlap, fn func(ctx context.Context)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return ErrStopped
+	}
+	if _, ok := s.jobs[name]; ok {
+		return ErrDuplicate
+	}
+	j := &job{name: name, schedule: schedule, overlap: overlap, fn: fn}
+	s.jobs[name] = j
+	if s.started {
+		s.loops.Add(1)
+		go s.loop(j)
+	}
+	return nil
+}
+
+// Start begins running the registered jobs.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.stopped {
+		return
+	}
+	s.started = true
+	for _, j := range s.jobs {
+		s.loops.Add(1)
+		go s.loop(j)
+	}
+}
+
+// Stop stops scheduling new runs, cancels the context passed to running
+// jobs, drops queued activations and waits for running jobs to return.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	s.cancel()
+	s.loops.Wait()
+	s.runs.Wait()
+}
+
+// Stats returns the counters of the named job.
+func (s *Scheduler) Stats(name string) JobStats {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return JobStats{}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := j.stats
+	st.Running = j.running
+	return st
+}
+
+func (s *Scheduler) loop(j *job) {
+	defer s.loops.Done()
+	next := j.schedule.Next(s.clock.Now())
+	for !next.IsZero() {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.clock.After(next.Sub(s.clock.Now())):
+		}
+		s.fire(j)
+		next = j.schedule.Next(next)
+	}
+}
+
+func (s *Scheduler) fire(j *job) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.running > 0 {
+		switch j.overlap {
+		case Skip:
+			j.stats.Skipped++
+			return
+		case Queue:
+			j.stats.Queued++
+			j.pending++
+			return
+		}
+	}
+	j.running++
+	j.stats.Runs++
+	s.runs.Add(1)
+	go s.run(j, j.stats.Runs)
+}
+
+// run executes j as its run-th run and then, for queued activations, keeps
+// executing it until nothing is pending.
+func (s *Scheduler) run(j *job, run int) {
+	defer s.runs.Done()
+	for {
+		ctx := s.hooks.RunStart(s.ctx, j.name, run)
+		start := s.clock.Now()
+		j.fn(ctx)
+		s.hooks.RunEnd(ctx, s.clock.Now().Sub(start))
+
+		j.mu.Lock()
+		if j.pending == 0 || s.ctx.Err() != nil {
+			j.pending = 0
+			j.running--
+			j.mu.Unlock()
+			return
+		}
+		j.pending--
+		j.stats.Runs++
+		run = j.stats.Runs
+		j.mu.Unlock()
+	}
+}
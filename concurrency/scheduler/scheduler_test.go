@@ -0,0 +1,249 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func newFakeClock() *clock.Fake {
+	return clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// idle waits until no run of the named job is in progress.
+func idle(s *Scheduler, name string) {
+	for s.Stats(name).Running != 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// step advances by d once the job loop is parked on the clock again.
+func step(c *clock.Fake, d time.Duration) {
+	c.BlockUntil(1)
+	c.Advance(d)
+}
+
+func TestEvery(t *testing.T) {
+	clock := newFakeClock()
+	s := New(clock)
+	runs := make(chan struct{}, 10)
+	s.Add("tick", Every(time.Minute), Skip, func(ctx context.Context) { runs <- struct{}{} })
+	s.Start()
+	defer s.Stop()
+
+	for i := 0; i < 3; i++ {
+		step(clock, time.Minute)
+		<-runs
+	}
+	if st := s.Stats("tick"); st.Runs != 3 {
+		t.Errorf("expected 3 runs, got %+v", st)
+	}
+}
+
+type runKey struct{}
+
+// runRecorder is Hooks that tags the context with the run and keeps the
+// runs that ended.
+type runRecorder struct{ ended chan string }
+
+func (r runRecorder) RunStart(ctx context.Context, job string, run int) context.Context {
+	return context.WithValue(ctx, runKey{}, fmt.Sprint(job, "#", run))
+}
+
+func (r runRecorder) RunEnd(ctx context.Context, d time.Duration) {
+	r.ended <- fmt.Sprint(ctx.Value(runKey{}), " ", d)
+}
+
+func TestHooks(t *testing.T) {
+	clock := newFakeClock()
+	hooks := runRecorder{make(chan string, 10)}
+	s := NewWith(clock, Options{Hooks: hooks})
+	saw := make(chan any, 10)
+	s.Add("tick", Every(time.Minute), Skip, func(ctx context.Context) { saw <- ctx.Value(runKey{}) })
+	s.Start()
+	defer s.Stop()
+
+	for i := 1; i <= 2; i++ {
+		step(clock, time.Minute)
+		want := fmt.Sprint("tick#", i)
+		if got := <-saw; got != want {
+			t.Errorf("job saw %v, want %s", got, want)
+		}
+		if got := <-hooks.ended; got != want+" 0s" {
+			t.Errorf("RunEnd got %s", got)
+		}
+	}
+}
+
+// blockingJob signals every start and blocks until released.
+type blockingJob struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingJob() *blockingJob {
+	return &blockingJob{started: make(chan struct{}, 10), release: make(chan struct{}, 10)}
+}
+
+func (b *blockingJob) run(ctx context.Context) {
+	b.started <- struct{}{}
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+	}
+}
+
+func TestOverlapSkip(t *testing.T) {
+	clock := newFakeClock()
+	s := New(clock)
+	j := newBlockingJob()
+	s.Add("job", Every(time.Second), Skip, j.run)
+	s.Start()
+
+	step(clock, time.Second)
+	<-j.started
+	step(clock, time.Second)
+	step(clock, time.Second)
+	clock.BlockUntil(1)
+	j.release <- struct{}{}
+	idle(s, "job")
+
+	step(clock, time.Second)
+	<-j.started
+	j.release <- struct{}{}
+	s.Stop()
+
+	if st := s.Stats("job"); st.Runs != 2 || st.Skipped != 2 || st.Running != 0 {
+		t.Errorf("expected 2 runs and 2 skips, got %+v", st)
+	}
+}
+
+func TestOverlapQueue(t *testing.T) {
+	clock := newFakeClock()
+	s := New(clock)
+	j := newBlockingJob()
+	s.Add("job", Every(time.Second), Queue, j.run)
+	s.Start()
+
+	step(clock, time.Second)
+	<-j.started
+	step(clock, time.Second)
+	step(clock, time.Second)
+	clock.BlockUntil(1)
+
+	// The two queued activations run back to back once released.
+	for i := 0; i < 3; i++ {
+		j.release <- struct{}{}
+		if i < 2 {
+			<-j.started
+		}
+	}
+	s.Stop()
+
+	if st := s.Stats("job"); st.Runs != 3 || st.Queued != 2 {
+		t.Errorf("expected 3 runs with 2 queued, got %+v", st)
+	}
+}
+
+func TestOverlapParallel(t *testing.T) {
+	clock := newFakeClock()
+	s := New(clock)
+	j := newBlockingJob()
+	s.Add("job", Every(time.Second), Parallel, j.run)
+	s.Start()
+
+	for i := 0; i < 3; i++ {
+		step(clock, time.Second)
+		<-j.started
+	}
+	s.Stop()
+
+	if st := s.Stats("job"); st.Runs != 3 || st.Skipped != 0 || st.Queued != 0 {
+		t.Errorf("expected 3 parallel runs, got %+v", st)
+	}
+}
+
+func TestStopCancelsRunningJobs(t *testing.T) {
+	clock := newFakeClock()
+	s := New(clock)
+	cancelled := make(chan struct{})
+	started := make(chan struct{})
+	s.Add("job", Every(time.Second), Skip, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+	})
+	s.Start()
+	step(clock, time.Second)
+	<-started
+
+	s.Stop()
+	select {
+	case <-cancelled:
+	default:
+		t.Error("Stop returned before the running job finished")
+	}
+	if err := s.Add("late", Every(time.Second), Skip, func(context.Context) {}); err != ErrStopped {
+		t.Errorf("expected ErrStopped, got %v", err)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	s := New(nil)
+	s.Add("job", Every(time.Second), Skip, func(context.Context) {})
+	if err := s.Add("job", Every(time.Second), Skip, func(context.Context) {}); err != ErrDuplicate {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2020, 1, 1, 10, 7, 30, 0, time.UTC) // a Wednesday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2020, 1, 1, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{"5/15 * * * *", time.Date(2020, 1, 1, 10, 20, 0, 0, time.UTC)},
+		{"0 1/6 * * *", time.Date(2020, 1, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2020, 1, 2, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 1", time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 8 1,15 * *", time.Date(2020, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{"0 0 1 3 *", time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := MustCron(tt.spec).Next(base); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestCronErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "a * * * *", "*/0 * * * *", "5-1 * * * *", "60/5 * * * *"} {
+		if _, err := Cron(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestCronJob(t *testing.T) {
+	clock := newFakeClock()
+	s := New(clock)
+	runs := make(chan time.Time, 10)
+	s.Add("quarter", MustCron("*/15 * * * *"), Skip, func(ctx context.Context) { runs <- clock.Now() })
+	s.Start()
+	defer s.Stop()
+
+	step(clock, 15*time.Minute)
+	if at := <-runs; at.Minute() != 15 {
+		t.Errorf("ran at %v", at)
+	}
+}
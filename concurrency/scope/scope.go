@@ -0,0 +1,118 @@
+// Package scope provides structured concurrency: goroutines started in a
+// Scope cannot outlive it.
+//
+// A bare go statement lets a goroutine run on after the function that
+// started it has returned, with nobody to wait for it or to see its error.
+// A Scope, like a nursery in Trio, ties its goroutines to a block of code:
+// Wait returns only once every one of them has finished, the first error
+// cancels the others, and a panic in any of them is carried over and
+// raised again in the goroutine that waits, instead of crashing the
+// process from somewhere else. Run wraps the whole lifetime so that it
+// also holds when the body returns early or panics.
+package scope
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// ErrWaited is the panic value of Go on a scope that has been waited for.
+var ErrWaited = errors.New("scope: Go called after Wait")
+
+// Scope owns a group of goroutines.
+type Scope struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	err    error
+	panic  *recovery.PanicError
+	waited bool
+}
+
+// New returns a Scope whose context is derived from ctx. The caller must
+// call Wait; prefer Run, which cannot forget to.
+func New(ctx context.Context) *Scope {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Scope{ctx: ctx, cancel: cancel}
+}
+
+// Context returns the context of the scope. It is cancelled when a
+// goroutine fails or panics, and once Wait returns.
+func (s *Scope) Context() context.Context { return s.ctx }
+
+// Go runs fn in a new goroutine owned by s. It may be called from inside
+// the scope's own goroutines, but not after Wait has returned.
+func (s *Scope) Go(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	if s.waited {
+		s.mu.Unlock()
+		panic(ErrWaited)
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		err := recovery.Do(func() error { return fn(s.ctx) })
+		if err != nil {
+			s.fail(err)
+		}
+	}()
+}
+
+// fail records the first error, and any panic, and cancels the scope.
+func (s *Scope) fail(err error) {
+	s.mu.Lock()
+	var p *recovery.PanicError
+	if errors.As(err, &p) && s.panic == nil {
+		s.panic = p
+	}
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.cancel(err)
+}
+
+// Wait blocks until every goroutine of the scope has returned and then
+// returns the first error. If one of them panicked, Wait panics with its
+// *recovery.PanicError instead.
+func (s *Scope) Wait() error {
+	s.wg.Wait()
+	s.mu.Lock()
+	s.waited = true
+	err, p := s.err, s.panic
+	s.mu.Unlock()
+	s.cancel(context.Canceled)
+	if p != nil {
+		panic(p)
+	}
+	return err
+}
+
+// Run calls body with a new Scope and waits for the scope before
+// returning, however body returns. An error from body cancels the scope
+// and takes precedence over errors of its goroutines. A panic in body is
+// raised again after the goroutines have been cancelled and have finished.
+func Run(ctx context.Context, body func(s *Scope) error) (err error) {
+	s := New(ctx)
+	defer func() {
+		if r := recover(); r != nil {
+			s.cancel(context.Canceled)
+			s.wg.Wait()
+			panic(r)
+		}
+	}()
+	if err = body(s); err != nil {
+		s.cancel(err)
+	}
+	if werr := s.Wait(); err == nil {
+		err = werr
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package scope
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// blocker waits for its context and counts how many have stopped.
+func blocker(stopped *atomic.Int32) func(context.Context) error {
+	return func(ctx context.Context) error {
+		<-ctx.Done()
+		stopped.Add(1)
+		return ctx.Err()
+	}
+}
+
+func TestWaitForAll(t *testing.T) {
+	leaks.Check(t)
+	var done atomic.Int32
+	err := Run(context.Background(), func(s *Scope) error {
+		for i := 0; i < 10; i++ {
+			s.Go(func(ctx context.Context) error {
+				time.Sleep(time.Millisecond)
+				// Goroutines may start siblings in their own scope.
+				s.Go(func(context.Context) error { done.Add(1); return nil })
+				done.Add(1)
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil || done.Load() != 20 {
+		t.Errorf("err = %v, %d of 20 done", err, done.Load())
+	}
+}
+
+func TestFirstErrorCancels(t *testing.T) {
+	leaks.Check(t)
+	boom := errors.New("boom")
+	var stopped atomic.Int32
+	err := Run(context.Background(), func(s *Scope) error {
+		s.Go(blocker(&stopped))
+		s.Go(blocker(&stopped))
+		s.Go(func(context.Context) error { return boom })
+		return nil
+	})
+	if err != boom || stopped.Load() != 2 {
+		t.Errorf("err = %v with %d siblings stopped, want %v and 2", err, stopped.Load(), boom)
+	}
+}
+
+func TestEarlyReturn(t *testing.T) {
+	leaks.Check(t)
+	early := errors.New("validation failed")
+	var stopped atomic.Int32
+	err := Run(context.Background(), func(s *Scope) error {
+		s.Go(blocker(&stopped))
+		s.Go(blocker(&stopped))
+		return early
+	})
+	if err != early {
+		t.Errorf("err = %v, want the body's error", err)
+	}
+	if stopped.Load() != 2 {
+		t.Errorf("Run returned with %d of 2 goroutines still running", 2-stopped.Load())
+	}
+}
+
+func TestChildPanic(t *testing.T) {
+	leaks.Check(t)
+	var stopped atomic.Int32
+	defer func() {
+		p, ok := recover().(*recovery.PanicError)
+		if !ok || p.Value != "child" {
+			t.Errorf("recovered %v, want the child's panic", p)
+		}
+		if stopped.Load() != 1 {
+			t.Errorf("the sibling was not stopped before the panic got here")
+		}
+	}()
+	Run(context.Background(), func(s *Scope) error {
+		s.Go(blocker(&stopped))
+		s.Go(func(context.Context) error { panic("child") })
+		return nil
+	})
+	t.Fatal("Run should have panicked")
+}
+
+func TestBodyPanic(t *testing.T) {
+	leaks.Check(t)
+	var stopped atomic.Int32
+	defer func() {
+		if r := recover(); r != "body" {
+			t.Errorf("recovered %v", r)
+		}
+		if stopped.Load() != 1 {
+			t.Errorf("goroutine outlived the panicking body")
+		}
+	}()
+	Run(context.Background(), func(s *Scope) error {
+		s.Go(blocker(&stopped))
+		panic("body")
+	})
+}
+
+func TestParentCancel(t *testing.T) {
+	leaks.Check(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	var stopped atomic.Int32
+	s := New(ctx)
+	s.Go(blocker(&stopped))
+	cancel()
+	if err := s.Wait(); err != context.Canceled {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestGoAfterWait(t *testing.T) {
+	s := New(context.Background())
+	s.Wait()
+	defer func() {
+		if r := recover(); r != ErrWaited {
+			t.Errorf("recovered %v, want %v", r, ErrWaited)
+		}
+	}()
+	s.Go(func(context.Context) error { return nil })
+}
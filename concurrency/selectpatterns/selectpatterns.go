@@ -0,0 +1,119 @@
+// Package selectpatterns wraps the common shapes of a select statement
+// in small typed helpers.
+//
+// A select with a default case never blocks: TrySend and TryRecv use it
+// to hand a value over only when the other side is ready, such as when
+// dropping metrics rather than stalling a hot path. A select against a
+// timer channel bounds a wait: RecvTimeout and SendTimeout, and their
+// Before forms, which take the timer channel itself so that a test can
+// decide when it fires. First waits on any number of channels, which a
+// select statement cannot do when the number is only known at run time.
+package selectpatterns
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// Errors of the select helpers.
+var (
+	ErrWouldBlock = errors.New("selectpatterns: channel not ready")
+	ErrTimeout    = errors.New("selectpatterns: timed out")
+	ErrClosed     = errors.New("selectpatterns: channel closed")
+)
+
+// TrySend sends v on c if a receiver is ready or c has room, and reports
+// whether it did.
+func TrySend[T any](c chan<- T, v T) bool {
+	select {
+	case c <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+// TryRecv receives from c if a value is ready. It returns ErrWouldBlock
+// if none is, and ErrClosed if c is closed.
+func TryRecv[T any](c <-chan T) (T, error) {
+	select {
+	case v, ok := <-c:
+		if !ok {
+			return v, ErrClosed
+		}
+		return v, nil
+	default:
+		var zero T
+		return zero, ErrWouldBlock
+	}
+}
+
+// RecvBefore receives from c, or returns ErrTimeout once timeout
+// delivers. It returns ErrClosed if c is closed.
+func RecvBefore[T any](c <-chan T, timeout <-chan time.Time) (T, error) {
+	select {
+	case v, ok := <-c:
+		if !ok {
+			return v, ErrClosed
+		}
+		return v, nil
+	case <-timeout:
+		var zero T
+		return zero, ErrTimeout
+	}
+}
+
+// RecvTimeout receives from c, waiting at most d.
+func RecvTimeout[T any](c <-chan T, d time.Duration) (T, error) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	return RecvBefore(c, t.C)
+}
+
+// SendBefore sends v on c, or returns ErrTimeout once timeout delivers.
+func SendBefore[T any](c chan<- T, v T, timeout <-chan time.Time) error {
+	select {
+	case c <- v:
+		return nil
+	case <-timeout:
+		return ErrTimeout
+	}
+}
+
+// SendTimeout sends v on c, waiting at most d.
+func SendTimeout[T any](c chan<- T, v T, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	return SendBefore(c, v, t.C)
+}
+
+// First waits for the first value from any of cs and returns the index
+// of its channel. Closed channels drop out of the wait; once all of them
+// have, First returns ErrClosed. It returns ctx's error if ctx is done
+// first. When several channels are ready, one is chosen at random, as
+// with a select statement.
+func First[T any](ctx context.Context, cs ...<-chan T) (int, T, error) {
+	var zero T
+	cases := make([]reflect.SelectCase, len(cs)+1)
+	cases[0] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+	for i, c := range cs {
+		cases[i+1] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c)}
+	}
+	for open := len(cs); open > 0; open-- {
+		i, v, ok := reflect.Select(cases)
+		if i == 0 {
+			return -1, zero, ctx.Err()
+		}
+		if ok {
+			// The assertion fails only for a nil interface value, which
+			// leaves the zero value, nil, in x.
+			x, _ := v.Interface().(T)
+			return i - 1, x, nil
+		}
+		// A nil channel blocks forever, which takes it out of the select.
+		cases[i].Chan = reflect.ValueOf((<-chan T)(nil))
+	}
+	return -1, zero, ErrClosed
+}
@@ -0,0 +1,157 @@
+package selectpatterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func TestTrySend(t *testing.T) {
+	c := make(chan int, 1)
+	if !TrySend(c, 1) {
+		t.Error("TrySend to an empty buffer failed")
+	}
+	if TrySend(c, 2) {
+		t.Error("TrySend to a full buffer succeeded")
+	}
+	if v := <-c; v != 1 {
+		t.Errorf("received %d", v)
+	}
+	if TrySend(make(chan int), 3) {
+		t.Error("TrySend without a receiver succeeded")
+	}
+}
+
+func TestTryRecv(t *testing.T) {
+	c := make(chan int, 1)
+	if _, err := TryRecv(c); err != ErrWouldBlock {
+		t.Errorf("empty: %v", err)
+	}
+	c <- 4
+	if v, err := TryRecv(c); v != 4 || err != nil {
+		t.Errorf("ready: %d, %v", v, err)
+	}
+	close(c)
+	if _, err := TryRecv(c); err != ErrClosed {
+		t.Errorf("closed: %v", err)
+	}
+}
+
+func TestRecvBefore(t *testing.T) {
+	c := make(chan string, 1)
+	timeout := make(chan time.Time, 1)
+
+	c <- "ready"
+	if v, err := RecvBefore(c, timeout); v != "ready" || err != nil {
+		t.Errorf("value: %q, %v", v, err)
+	}
+	timeout <- time.Time{}
+	if _, err := RecvBefore(c, timeout); err != ErrTimeout {
+		t.Errorf("timeout: %v", err)
+	}
+	close(c)
+	if _, err := RecvBefore(c, nil); err != ErrClosed {
+		t.Errorf("closed: %v", err)
+	}
+}
+
+func TestSendBefore(t *testing.T) {
+	c := make(chan int, 1)
+	timeout := make(chan time.Time, 1)
+	if err := SendBefore(c, 1, timeout); err != nil {
+		t.Errorf("room: %v", err)
+	}
+	timeout <- time.Time{}
+	if err := SendBefore(c, 2, timeout); err != ErrTimeout {
+		t.Errorf("full: %v", err)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	if _, err := RecvTimeout(make(chan int), time.Millisecond); err != ErrTimeout {
+		t.Errorf("RecvTimeout = %v", err)
+	}
+	if err := SendTimeout(make(chan int), 1, time.Millisecond); err != ErrTimeout {
+		t.Errorf("SendTimeout = %v", err)
+	}
+	c := make(chan int, 1)
+	if err := SendTimeout(c, 5, time.Hour); err != nil {
+		t.Errorf("SendTimeout = %v", err)
+	}
+	if v, err := RecvTimeout(c, time.Hour); v != 5 || err != nil {
+		t.Errorf("RecvTimeout = %d, %v", v, err)
+	}
+}
+
+func TestFirst(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := make(chan int, 1), make(chan int, 1), make(chan int, 1)
+
+	b <- 2
+	if i, v, err := First(ctx, a, b, c); i != 1 || v != 2 || err != nil {
+		t.Errorf("First = %d, %d, %v", i, v, err)
+	}
+
+	// Closed channels drop out and the wait goes on.
+	close(a)
+	close(b)
+	c <- 3
+	if i, v, err := First(ctx, a, b, c); i != 2 || v != 3 || err != nil {
+		t.Errorf("after closing: %d, %d, %v", i, v, err)
+	}
+	close(c)
+	if _, _, err := First(ctx, a, b, c); err != ErrClosed {
+		t.Errorf("all closed: %v", err)
+	}
+	if _, _, err := First[int](ctx); err != ErrClosed {
+		t.Errorf("no channels: %v", err)
+	}
+}
+
+func TestFirstCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if i, _, err := First(ctx, make(chan int)); i != -1 || !errors.Is(err, context.Canceled) {
+		t.Errorf("First = %d, %v", i, err)
+	}
+}
+
+func TestFirstNilInterface(t *testing.T) {
+	c := make(chan error, 1)
+	c <- nil
+	if i, v, err := First(context.Background(), c); i != 0 || v != nil || err != nil {
+		t.Errorf("First = %d, %v, %v", i, v, err)
+	}
+}
+
+func ExampleFirst() {
+	mirrors := []<-chan string{
+		make(chan string),
+		func() <-chan string {
+			c := make(chan string, 1)
+			c <- "eu mirror"
+			return c
+		}(),
+	}
+	i, v, err := First(context.Background(), mirrors...)
+	fmt.Println(i, v, err)
+	// Output:
+	// 1 eu mirror <nil>
+}
+
+func ExampleTrySend() {
+	metrics := make(chan string, 1)
+	for _, m := range []string{"latency=3ms", "latency=4ms"} {
+		if !TrySend(metrics, m) {
+			fmt.Println("dropped", m)
+		}
+	}
+	// Output:
+	// dropped latency=4ms
+}
@@ -0,0 +1,179 @@
+// Package shardedmap provides a concurrent map split into shards, each
+// behind a lock of its own.
+//
+// A map behind one sync.RWMutex serialises every write, and on many
+// cores even its readers contend on the lock's reader count. Spreading
+// the keys over N shards by hash lets operations on different shards
+// proceed in parallel, so contention falls roughly by N. sync.Map takes
+// another route: reads of keys that are already stored take no lock at
+// all, which wins when keys are written once and read many times, but
+// every new key goes through a single mutex. The benchmarks compare the
+// three on read-mostly, mixed and write-heavy loads:
+//
+//	go test -run NONE -bench . -cpu 1,4,16 ./concurrency/shardedmap
+//
+// Sharding pays off with writes spread over many keys from many cores.
+// On one core, or with few goroutines, the extra hash costs more than
+// the lock it saves.
+package shardedmap
+
+import (
+	"hash/maphash"
+	"iter"
+	"math/bits"
+	"sync"
+)
+
+// DefaultShards is the number of shards New uses when asked for none.
+const DefaultShards = 32
+
+// Map is a map safe for concurrent use, split into shards. Its methods
+// lock only the shard of the key they are given.
+type Map[K comparable, V any] struct {
+	hash   func(K) uint64
+	mask   uint64
+	shards []shard[K, V]
+}
+
+type shard[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+	// Keep shards on separate cache lines so that writers of neighbouring
+	// shards do not invalidate each other's.
+	_ [64]byte
+}
+
+// New returns an empty Map that places keys with hash. The number of
+// shards is rounded up to a power of two; zero or less means
+// DefaultShards.
+func New[K comparable, V any](hash func(K) uint64, shards int) *Map[K, V] {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	n := 1 << bits.Len(uint(shards-1))
+	m := &Map[K, V]{hash: hash, mask: uint64(n - 1), shards: make([]shard[K, V], n)}
+	for i := range m.shards {
+		m.shards[i].m = map[K]V{}
+	}
+	return m
+}
+
+// StringHash returns a hash function for string keys with a random seed.
+func StringHash() func(string) uint64 {
+	seed := maphash.MakeSeed()
+	return func(s string) uint64 { return maphash.String(seed, s) }
+}
+
+// IntHash hashes integer keys by mixing their bits, so that keys that
+// differ only in high bits still spread over the shards.
+func IntHash[K ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr](k K) uint64 {
+	// The finaliser of SplitMix64.
+	x := uint64(k)
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (m *Map[K, V]) shard(k K) *shard[K, V] {
+	return &m.shards[m.hash(k)&m.mask]
+}
+
+// Shards returns the number of shards.
+func (m *Map[K, V]) Shards() int { return len(m.shards) }
+
+// Load returns the value stored for k, if any.
+func (m *Map[K, V]) Load(k K) (V, bool) {
+	s := m.shard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[k]
+	return v, ok
+}
+
+// Store sets the value for k.
+func (m *Map[K, V]) Store(k K, v V) {
+	s := m.shard(k)
+	s.mu.Lock()
+	s.m[k] = v
+	s.mu.Unlock()
+}
+
+// LoadOrStore returns the value stored for k if there is one. Otherwise
+// it stores v and returns it. loaded reports which happened.
+func (m *Map[K, V]) LoadOrStore(k K, v V) (actual V, loaded bool) {
+	s := m.shard(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.m[k]; ok {
+		return old, true
+	}
+	s.m[k] = v
+	return v, false
+}
+
+// Delete removes k.
+func (m *Map[K, V]) Delete(k K) {
+	s := m.shard(k)
+	s.mu.Lock()
+	delete(s.m, k)
+	s.mu.Unlock()
+}
+
+// Update sets the value for k to what fn returns given the current value
+// and whether there is one, holding the shard's lock throughout so that
+// the read and the write are one step. If fn returns keep false, k is
+// deleted instead. fn must not use m.
+func (m *Map[K, V]) Update(k K, fn func(v V, ok bool) (nv V, keep bool)) {
+	s := m.shard(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.m[k]
+	if nv, keep := fn(v, ok); keep {
+		s.m[k] = nv
+	} else if ok {
+		delete(s.m, k)
+	}
+}
+
+// Len returns the number of keys. With concurrent writers it is a count
+// taken one shard at a time, not a snapshot.
+func (m *Map[K, V]) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// All yields every key and value, a shard at a time. Each shard is
+// copied under its read lock before its entries are yielded, so the loop
+// body may use m; writes made during the loop may or may not be seen.
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		type entry struct {
+			k K
+			v V
+		}
+		var entries []entry
+		for i := range m.shards {
+			s := &m.shards[i]
+			entries = entries[:0]
+			s.mu.RLock()
+			for k, v := range s.m {
+				entries = append(entries, entry{k, v})
+			}
+			s.mu.RUnlock()
+			for _, e := range entries {
+				if !yield(e.k, e.v) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package shardedmap
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func TestBasics(t *testing.T) {
+	m := New[string, int](StringHash(), 4)
+	if _, ok := m.Load("a"); ok {
+		t.Error("empty map has a")
+	}
+	m.Store("a", 1)
+	m.Store("b", 2)
+	if v, ok := m.Load("a"); v != 1 || !ok {
+		t.Errorf("Load(a) = %d, %v", v, ok)
+	}
+	if v, loaded := m.LoadOrStore("a", 9); v != 1 || !loaded {
+		t.Errorf("LoadOrStore(a) = %d, %v", v, loaded)
+	}
+	if v, loaded := m.LoadOrStore("c", 3); v != 3 || loaded {
+		t.Errorf("LoadOrStore(c) = %d, %v", v, loaded)
+	}
+	m.Delete("b")
+	m.Delete("missing")
+	if got := maps.Collect(m.All()); !maps.Equal(got, map[string]int{"a": 1, "c": 3}) || m.Len() != 2 {
+		t.Errorf("All = %v, Len = %d", got, m.Len())
+	}
+}
+
+func TestShards(t *testing.T) {
+	for _, tc := range []struct{ ask, want int }{{0, DefaultShards}, {-1, DefaultShards}, {1, 1}, {5, 8}, {16, 16}} {
+		if got := New[int, int](IntHash[int], tc.ask).Shards(); got != tc.want {
+			t.Errorf("New(%d) has %d shards, want %d", tc.ask, got, tc.want)
+		}
+	}
+}
+
+func TestIntHashSpreads(t *testing.T) {
+	m := New[int, int](IntHash[int], 16)
+	// Keys that differ only above the shard bits must not share a shard.
+	for i := range 1024 {
+		m.Store(i<<20, i)
+	}
+	for i := range m.shards {
+		if n := len(m.shards[i].m); n < 32 || n > 96 {
+			t.Errorf("shard %d holds %d of 1024 keys", i, n)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	m := New[string, int](StringHash(), 0)
+	incr := func(v int, _ bool) (int, bool) { return v + 1, true }
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				m.Update(fmt.Sprint("k", i%10), incr)
+			}
+		}()
+	}
+	wg.Wait()
+	for k, v := range m.All() {
+		if v != 800 {
+			t.Errorf("%s = %d, want 800", k, v)
+		}
+	}
+	m.Update("k0", func(int, bool) (int, bool) { return 0, false })
+	m.Update("none", func(int, bool) (int, bool) { return 0, false })
+	if _, ok := m.Load("k0"); ok || m.Len() != 9 {
+		t.Errorf("Update did not delete: Len = %d", m.Len())
+	}
+}
+
+func TestLoadOrStoreOnce(t *testing.T) {
+	m := New[int, int](IntHash[int], 4)
+	var stored atomic.Int32
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range 100 {
+				if _, loaded := m.LoadOrStore(k, g); !loaded {
+					stored.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if stored.Load() != 100 {
+		t.Errorf("%d stores for 100 keys", stored.Load())
+	}
+}
+
+func TestAllStopsEarly(t *testing.T) {
+	m := New[int, int](IntHash[int], 4)
+	for i := range 100 {
+		m.Store(i, i)
+	}
+	n := 0
+	for k := range m.All() {
+		// The body may write to the map it ranges over.
+		m.Store(k+1000, k)
+		if n++; n == 10 {
+			break
+		}
+	}
+	if n != 10 || m.Len() != 110 {
+		t.Errorf("ranged over %d keys, Len = %d", n, m.Len())
+	}
+}
+
+// store is what the benchmarks need of a concurrent map.
+type store interface {
+	Load(int) (int, bool)
+	Store(int, int)
+}
+
+// locked is a map behind a single sync.RWMutex.
+type locked struct {
+	mu sync.RWMutex
+	m  map[int]int
+}
+
+func (l *locked) Load(k int) (int, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	v, ok := l.m[k]
+	return v, ok
+}
+
+func (l *locked) Store(k, v int) {
+	l.mu.Lock()
+	l.m[k] = v
+	l.mu.Unlock()
+}
+
+// syncMap adapts sync.Map.
+type syncMap struct{ m sync.Map }
+
+func (s *syncMap) Load(k int) (int, bool) {
+	v, ok := s.m.Load(k)
+	if !ok {
+		return 0, false
+	}
+	return v.(int), true
+}
+
+func (s *syncMap) Store(k, v int) { s.m.Store(k, v) }
+
+const keys = 1 << 16
+
+func BenchmarkMaps(b *testing.B) {
+	impls := []struct {
+		name string
+		new  func() store
+	}{
+		{"rwmutex", func() store { return &locked{m: map[int]int{}} }},
+		{"syncmap", func() store { return new(syncMap) }},
+		{"sharded", func() store { return New[int, int](IntHash[int], 0) }},
+	}
+	for _, load := range []struct {
+		name      string
+		writePerc int
+	}{{"read99", 1}, {"read90", 10}, {"read50", 50}, {"write100", 100}} {
+		for _, impl := range impls {
+			b.Run(load.name+"/"+impl.name, func(b *testing.B) {
+				m := impl.new()
+				for k := range keys {
+					m.Store(k, k)
+				}
+				var seed atomic.Uint64
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					// Each goroutine walks its own pseudo-random key sequence.
+					x := seed.Add(0x9e3779b97f4a7c15)
+					for pb.Next() {
+						x = IntHash(x)
+						k := int(x % keys)
+						if int(x>>32%100) < load.writePerc {
+							m.Store(k, k)
+						} else {
+							m.Load(k)
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+func Example() {
+	visits := New[string, int](StringHash(), 16)
+	for _, page := range []string{"/", "/docs", "/", "/blog", "/"} {
+		visits.Update(page, func(n int, _ bool) (int, bool) { return n + 1, true })
+	}
+	for _, page := range slices.Sorted(maps.Keys(maps.Collect(visits.All()))) {
+		n, _ := visits.Load(page)
+		fmt.Println(page, n)
+	}
+	// Output:
+	// / 3
+	// /blog 1
+	// /docs 1
+}
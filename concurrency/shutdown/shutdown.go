@@ -0,0 +1,316 @@
+// Package shutdown takes a program down in phases when it is told to stop:
+// first it stops taking new work, then it drains the pools that still
+// have some, then it flushes what batchers hold, and only then does it
+// close the stores all of them write to.
+//
+// Parts of the program register hooks with a Coordinator for the phase
+// they belong to. The phases run one after another; the hooks of one
+// phase run at the same time and get a context that ends with the phase's
+// timeout. A hook still running then is reported as timed out and left
+// behind, so one stuck hook costs its phase's timeout and no more, and the
+// later phases still run. The Report says which hooks failed and which
+// timed out.
+//
+// Wait shuts down on the first of the signals caught with Signals; a
+// second one cuts the shutdown short. Inside a rungroup.Group the
+// coordinator is one more actor, which shuts down when a signal comes or
+// when the group ends for another reason:
+//
+//	c.Signals(syscall.SIGINT, syscall.SIGTERM)
+//	g.AddContext("shutdown", func(ctx context.Context) error {
+//		return c.Wait(ctx).Err()
+//	})
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// ErrTimeout is the error of a hook that did not return within its
+// phase's timeout.
+var ErrTimeout = errors.New("shutdown: hook timed out")
+
+// Phase is a step of the shutdown. Phases run in the order of their
+// values.
+type Phase int
+
+const (
+	// StopIntake stops servers, listeners and consumers taking new work.
+	StopIntake Phase = iota
+	// DrainPools waits for the work already taken to finish.
+	DrainPools
+	// FlushBatchers writes out what batchers and buffers still hold.
+	FlushBatchers
+	// CloseStores closes databases, files and connections.
+	CloseStores
+
+	phases = iota
+)
+
+func (p Phase) String() string {
+	switch p {
+	case StopIntake:
+		return "stop intake"
+	case DrainPools:
+		return "drain pools"
+	case FlushBatchers:
+		return "flush batchers"
+	case CloseStores:
+		return "close stores"
+	}
+	return fmt.Sprintf("phase %d", int(p))
+}
+
+// Hook stops one part of the program. It should return once ctx is done,
+// even if it has not finished.
+type Hook func(ctx context.Context) error
+
+type hook struct {
+	name string
+	fn   Hook
+}
+
+// HookResult is what became of one hook.
+type HookResult struct {
+	Phase Phase
+	Name  string
+	// Err is the hook's error, ErrTimeout if it did not return in time,
+	// or the error of the context given to Shutdown if the shutdown was
+	// cut short before the hook returned. A panic is a
+	// *recovery.PanicError.
+	Err  error
+	Took time.Duration
+}
+
+// Report is the outcome of a shutdown.
+type Report struct {
+	// Signal is the signal Wait received, nil if there was none.
+	Signal os.Signal
+	// Hooks are the results in the order the hooks ran: by phase, and in
+	// the order they were added within one.
+	Hooks []HookResult
+}
+
+// TimedOut returns the names of the hooks that timed out.
+func (r *Report) TimedOut() []string {
+	var names []string
+	for _, h := range r.Hooks {
+		if errors.Is(h.Err, ErrTimeout) {
+			names = append(names, h.Name)
+		}
+	}
+	return names
+}
+
+// Err joins the errors of the hooks that failed, nil if none did.
+func (r *Report) Err() error {
+	var errs []error
+	for _, h := range r.Hooks {
+		if h.Err != nil {
+			errs = append(errs, fmt.Errorf("%v: %s: %w", h.Phase, h.Name, h.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Coordinator runs the hooks of a shutdown. The zero value has no hooks
+// and is ready to use. A Coordinator shuts down once.
+type Coordinator struct {
+	// Timeout bounds every phase without one of its own. It defaults to
+	// ten seconds.
+	Timeout time.Duration
+	// OnHook, if set, is called as every hook returns or times out.
+	OnHook func(HookResult)
+
+	mu       sync.Mutex
+	hooks    [phases][]hook
+	timeouts [phases]time.Duration
+
+	caught chan os.Signal
+
+	once   sync.Once
+	done   chan struct{}
+	report *Report
+}
+
+func checkPhase(p Phase) {
+	if p < 0 || p >= phases {
+		panic(fmt.Sprintf("shutdown: no %v", p))
+	}
+}
+
+// Add registers fn to run in phase p. Hooks added once the shutdown has
+// begun may not run.
+func (c *Coordinator) Add(p Phase, name string, fn Hook) {
+	checkPhase(p)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks[p] = append(c.hooks[p], hook{name, fn})
+}
+
+// SetTimeout bounds phase p by d instead of Timeout.
+func (c *Coordinator) SetTimeout(p Phase, d time.Duration) {
+	checkPhase(p)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timeouts[p] = d
+}
+
+// init makes the zero value usable. It is called under c.once or before
+// waiting on c.done.
+func (c *Coordinator) init() {
+	c.mu.Lock()
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	c.mu.Unlock()
+}
+
+// Shutdown runs every phase and returns the report. When ctx is done the
+// phase running is cut short, and the hooks of the later phases are not
+// run. Calls after the first wait for it and return its report.
+func (c *Coordinator) Shutdown(ctx context.Context) *Report {
+	return c.shutdown(ctx, nil)
+}
+
+func (c *Coordinator) shutdown(ctx context.Context, sig os.Signal) *Report {
+	c.init()
+	c.once.Do(func() {
+		r := &Report{Signal: sig}
+		for p := range Phase(phases) {
+			c.mu.Lock()
+			hooks, timeout := c.hooks[p], c.timeouts[p]
+			c.mu.Unlock()
+			if timeout <= 0 {
+				timeout = c.Timeout
+			}
+			if timeout <= 0 {
+				timeout = 10 * time.Second
+			}
+			r.Hooks = append(r.Hooks, c.phase(ctx, p, hooks, timeout)...)
+		}
+		c.report = r
+		close(c.done)
+	})
+	<-c.done
+	return c.report
+}
+
+// phase runs the hooks of p together and waits for them until timeout.
+func (c *Coordinator) phase(ctx context.Context, p Phase, hooks []hook, timeout time.Duration) []HookResult {
+	results := make([]HookResult, len(hooks))
+	for i, h := range hooks {
+		results[i] = HookResult{Phase: p, Name: h.name, Err: ctx.Err()}
+	}
+	if len(hooks) == 0 || ctx.Err() != nil {
+		return results
+	}
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type exit struct {
+		i    int
+		err  error
+		took time.Duration
+	}
+	// Buffered, so that the hooks left behind can still send.
+	exits := make(chan exit, len(hooks))
+	start := time.Now()
+	for i, h := range hooks {
+		go func() {
+			err := recovery.Do(func() error { return h.fn(ctx) })
+			exits <- exit{i, err, time.Since(start)}
+		}()
+	}
+	returned := make([]bool, len(hooks))
+	got := func(e exit) {
+		returned[e.i] = true
+		results[e.i].Err, results[e.i].Took = e.err, e.took
+		c.notify(results[e.i])
+	}
+	n := 0
+wait:
+	for ; n < len(hooks); n++ {
+		select {
+		case e := <-exits:
+			got(e)
+		case <-ctx.Done():
+			break wait
+		}
+	}
+	// The select may have picked the deadline over hooks that had
+	// returned by then; their results are not timeouts.
+drain:
+	for ; n < len(hooks); n++ {
+		select {
+		case e := <-exits:
+			got(e)
+		default:
+			break drain
+		}
+	}
+	late := ErrTimeout
+	if parent.Err() != nil {
+		late = parent.Err()
+	}
+	for i := range results {
+		if !returned[i] {
+			results[i].Err, results[i].Took = late, time.Since(start)
+			c.notify(results[i])
+		}
+	}
+	return results
+}
+
+func (c *Coordinator) notify(r HookResult) {
+	if c.OnHook != nil {
+		c.OnHook(r)
+	}
+}
+
+// Signals catches sigs from now on, for Wait. The signals are released
+// when Wait returns.
+func (c *Coordinator) Signals(sigs ...os.Signal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.caught == nil {
+		c.caught = make(chan os.Signal, 1)
+	}
+	signal.Notify(c.caught, sigs...)
+}
+
+// Wait shuts down when one of the signals caught with Signals arrives or
+// ctx is done, whichever comes first, and returns the report. A second
+// signal during the shutdown cuts it short.
+func (c *Coordinator) Wait(ctx context.Context) *Report {
+	c.mu.Lock()
+	caught := c.caught
+	c.mu.Unlock()
+	if caught != nil {
+		defer signal.Stop(caught)
+	}
+
+	var sig os.Signal
+	select {
+	case sig = <-caught:
+	case <-ctx.Done():
+	}
+	force, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-caught:
+			cancel()
+		case <-force.Done():
+		}
+	}()
+	return c.shutdown(force, sig)
+}
@@ -0,0 +1,218 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// journal records the order hooks run in.
+type journal struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (j *journal) hook(name string) Hook {
+	return func(context.Context) error {
+		j.mu.Lock()
+		j.events = append(j.events, name)
+		j.mu.Unlock()
+		return nil
+	}
+}
+
+func (j *journal) String() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return strings.Join(j.events, " ")
+}
+
+func TestPhasesRunInOrder(t *testing.T) {
+	leaks.Check(t)
+	var j journal
+	var c Coordinator
+	// Added out of order on purpose.
+	c.Add(CloseStores, "db", j.hook("db"))
+	c.Add(FlushBatchers, "batcher", j.hook("batcher"))
+	c.Add(DrainPools, "pool", j.hook("pool"))
+	c.Add(StopIntake, "http", j.hook("http"))
+
+	r := c.Shutdown(context.Background())
+	if got := j.String(); got != "http pool batcher db" {
+		t.Errorf("ran %q", got)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Err = %v", err)
+	}
+	var names []string
+	for _, h := range r.Hooks {
+		names = append(names, fmt.Sprintf("%v/%s", h.Phase, h.Name))
+	}
+	if got := strings.Join(names, ", "); got != "stop intake/http, drain pools/pool, flush batchers/batcher, close stores/db" {
+		t.Errorf("report %s", got)
+	}
+}
+
+// The hooks of a phase run at the same time, and the next phase waits for
+// all of them.
+func TestPhaseRunsHooksTogether(t *testing.T) {
+	leaks.Check(t)
+	var c Coordinator
+	var wg sync.WaitGroup
+	wg.Add(3)
+	for i := range 3 {
+		c.Add(DrainPools, fmt.Sprint("pool ", i), func(ctx context.Context) error {
+			wg.Done()
+			// Only returns once all three have started.
+			wg.Wait()
+			return nil
+		})
+	}
+	var after bool
+	c.Add(CloseStores, "db", func(context.Context) error {
+		after = true
+		return nil
+	})
+	c.Timeout = 5 * time.Second
+	if err := c.Shutdown(context.Background()).Err(); err != nil || !after {
+		t.Errorf("Err = %v, close stores ran: %v", err, after)
+	}
+}
+
+func TestTimeoutReportedAndNextPhaseRuns(t *testing.T) {
+	leaks.Check(t)
+	var c Coordinator
+	c.SetTimeout(DrainPools, 20*time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	c.Add(DrainPools, "stuck", func(context.Context) error {
+		// Ignores its context.
+		<-release
+		return nil
+	})
+	c.Add(DrainPools, "quick", func(context.Context) error { return nil })
+	var closed bool
+	c.Add(CloseStores, "db", func(context.Context) error {
+		closed = true
+		return nil
+	})
+	var seen []string
+	c.OnHook = func(r HookResult) { seen = append(seen, r.Name) }
+
+	start := time.Now()
+	r := c.Shutdown(context.Background())
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("took %v", took)
+	}
+	if got := r.TimedOut(); len(got) != 1 || got[0] != "stuck" {
+		t.Errorf("TimedOut = %v", got)
+	}
+	if !closed {
+		t.Error("close stores did not run after the timeout")
+	}
+	if err := r.Err(); !errors.Is(err, ErrTimeout) || err.Error() != "drain pools: stuck: shutdown: hook timed out" {
+		t.Errorf("Err = %v", err)
+	}
+	if fmt.Sprint(seen) != "[quick stuck db]" {
+		t.Errorf("OnHook saw %v", seen)
+	}
+}
+
+func TestHookErrorsAndPanics(t *testing.T) {
+	leaks.Check(t)
+	var c Coordinator
+	broken := errors.New("flush failed")
+	c.Add(FlushBatchers, "batcher", func(context.Context) error { return broken })
+	c.Add(CloseStores, "db", func(context.Context) error { panic("boom") })
+	err := c.Shutdown(context.Background()).Err()
+	var pe *recovery.PanicError
+	if !errors.Is(err, broken) || !errors.As(err, &pe) {
+		t.Errorf("Err = %v", err)
+	}
+}
+
+// A cancelled shutdown stops waiting for the phase running and skips the
+// later ones.
+func TestCancelledShutdown(t *testing.T) {
+	leaks.Check(t)
+	var c Coordinator
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Add(DrainPools, "pool", func(hctx context.Context) error {
+		cancel()
+		<-hctx.Done()
+		return nil
+	})
+	var closed bool
+	c.Add(CloseStores, "db", func(context.Context) error {
+		closed = true
+		return nil
+	})
+	r := c.Shutdown(ctx)
+	if closed {
+		t.Error("close stores ran after the cancellation")
+	}
+	if len(r.Hooks) != 2 || !errors.Is(r.Hooks[1].Err, context.Canceled) || len(r.TimedOut()) != 0 {
+		t.Errorf("report %+v", r.Hooks)
+	}
+}
+
+func TestShutdownOnce(t *testing.T) {
+	leaks.Check(t)
+	var c Coordinator
+	var runs int
+	c.Add(StopIntake, "http", func(context.Context) error {
+		runs++
+		return nil
+	})
+	var wg sync.WaitGroup
+	reports := make([]*Report, 4)
+	for i := range reports {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i] = c.Shutdown(context.Background())
+		}()
+	}
+	wg.Wait()
+	for _, r := range reports[1:] {
+		if r != reports[0] {
+			t.Error("calls got different reports")
+		}
+	}
+	if runs != 1 {
+		t.Errorf("hook ran %d times", runs)
+	}
+}
+
+func TestBadPhase(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Add to phase 7 did not panic")
+		}
+	}()
+	var c Coordinator
+	c.Add(Phase(7), "x", func(context.Context) error { return nil })
+}
+
+// Without a signal, Wait shuts down when its context is done.
+func TestWaitContext(t *testing.T) {
+	leaks.Check(t)
+	var c Coordinator
+	var j journal
+	c.Add(CloseStores, "db", j.hook("db"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := c.Wait(ctx)
+	if r.Signal != nil || j.String() != "db" || r.Err() != nil {
+		t.Errorf("Signal = %v, ran %q, Err = %v", r.Signal, j.String(), r.Err())
+	}
+}
@@ -0,0 +1,47 @@
+//go:build unix
+
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestWaitForSignal(t *testing.T) {
+	leaks.Check(t)
+	var c Coordinator
+	var j journal
+	c.Add(StopIntake, "http", j.hook("http"))
+	c.Signals(syscall.SIGUSR1)
+	// Caught although Wait has not started yet.
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	r := c.Wait(context.Background())
+	if r.Signal != syscall.SIGUSR1 || j.String() != "http" {
+		t.Errorf("Signal = %v, ran %q", r.Signal, j.String())
+	}
+}
+
+func TestSecondSignalForces(t *testing.T) {
+	leaks.Check(t)
+	var c Coordinator
+	c.Signals(syscall.SIGUSR1)
+	c.Add(DrainPools, "pool", func(ctx context.Context) error {
+		syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var closed bool
+	c.Add(CloseStores, "db", func(context.Context) error {
+		closed = true
+		return nil
+	})
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	r := c.Wait(context.Background())
+	if closed || !errors.Is(r.Err(), context.Canceled) {
+		t.Errorf("closed %v, Err = %v", closed, r.Err())
+	}
+}
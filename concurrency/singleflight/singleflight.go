@@ -0,0 +1,135 @@
+// Package singleflight suppresses duplicate calls: while a call for a key is
+// in flight, later callers for the same key wait for it and share its result
+// instead of starting their own. It is a from-scratch take on
+// golang.org/x/sync/singleflight and follows the same semantics.
+package singleflight
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is the value callers panic with when the function of the call
+// they waited on panicked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.Value, p.Stack)
+}
+
+// Result holds the outcome of Do, for callers of DoChan.
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool
+}
+
+type call struct {
+	wg sync.WaitGroup
+
+	// val, err and panicked are written once before wg is done and only
+	// read after wg.Wait.
+	val      interface{}
+	err      error
+	panicked *PanicError
+
+	dups  int
+	chans []chan<- Result
+}
+
+// Group is a namespace of keys. The zero value is ready to use.
+type Group struct {
+	mu sync.Mutex
+	m  map[string]*call
+}
+
+// Do runs fn for key, unless a call for key is already in flight, in which
+// case it waits for that call and returns its results. shared reports
+// whether the result went to more than one caller. If fn panics, every
+// caller panics with a *PanicError.
+func (g *Group) Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+		c.wg.Wait()
+		if c.panicked != nil {
+			panic(c.panicked)
+		}
+		return c.val, c.err, true
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	g.doCall(c, key, fn)
+	if c.panicked != nil {
+		panic(c.panicked)
+	}
+	return c.val, c.err, c.dups > 0
+}
+
+// DoChan is like Do but returns a channel that receives the result. A
+// panic in fn is delivered as a *PanicError in Result.Err, since nobody
+// would be there to recover it.
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+	c := &call{chans: []chan<- Result{ch}}
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(c, key, fn)
+	return ch
+}
+
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	defer func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		c.wg.Done()
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		for _, ch := range c.chans {
+			if c.panicked != nil {
+				ch <- Result{Err: c.panicked, Shared: c.dups > 0}
+				continue
+			}
+			ch <- Result{Val: c.val, Err: c.err, Shared: c.dups > 0}
+		}
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.panicked = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	c.val, c.err = fn()
+}
+
+// Forget makes the next call for key run fn again, even if a call for key
+// is still in flight. Callers already waiting keep waiting on the old call.
+func (g *Group) Forget(key string) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+}
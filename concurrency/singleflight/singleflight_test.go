@@ -0,0 +1,233 @@
+package singleflight
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	xsingleflight "golang.org/x/sync/singleflight"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// group is the API shared by this package and x/sync/singleflight, so the
+// same tests can be run against both.
+type group interface {
+	Do(key string, fn func() (interface{}, error)) (interface{}, error, bool)
+	Forget(key string)
+}
+
+type xgroup struct{ xsingleflight.Group }
+
+var implementations = map[string]func() group{
+	"scratch": func() group { return new(Group) },
+	"x/sync":  func() group { return new(xgroup) },
+}
+
+func forEach(t *testing.T, test func(t *testing.T, g group)) {
+	for name, newGroup := range implementations {
+		t.Run(name, func(t *testing.T) { test(t, newGroup()) })
+	}
+}
+
+func TestDo(t *testing.T) {
+	forEach(t, func(t *testing.T, g group) {
+		v, err, shared := g.Do("key", func() (interface{}, error) { return "bar", nil })
+		if v != "bar" || err != nil || shared {
+			t.Errorf("Do = %v, %v, %v", v, err, shared)
+		}
+	})
+}
+
+func TestDoErr(t *testing.T) {
+	forEach(t, func(t *testing.T, g group) {
+		boom := errors.New("boom")
+		v, err, _ := g.Do("key", func() (interface{}, error) { return nil, boom })
+		if v != nil || err != boom {
+			t.Errorf("Do = %v, %v", v, err)
+		}
+	})
+}
+
+func TestSharedResults(t *testing.T) {
+	forEach(t, func(t *testing.T, g group) {
+		var calls int32
+		release := make(chan struct{})
+		fn := func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return "shared", nil
+		}
+
+		const n = 10
+		var wg sync.WaitGroup
+		var sharedCount int32
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, _, shared := g.Do("key", fn)
+				if v != "shared" {
+					t.Errorf("got %v", v)
+				}
+				if shared {
+					atomic.AddInt32(&sharedCount, 1)
+				}
+			}()
+		}
+		// Give every goroutine the chance to join the in-flight call.
+		for atomic.LoadInt32(&calls) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if calls != 1 {
+			t.Errorf("fn ran %d times, want 1", calls)
+		}
+		if sharedCount != n {
+			t.Errorf("%d callers saw shared=true, want %d", sharedCount, n)
+		}
+	})
+}
+
+func TestForget(t *testing.T) {
+	forEach(t, func(t *testing.T, g group) {
+		firstStarted := make(chan struct{})
+		release := make(chan struct{})
+		firstDone := make(chan struct{})
+		go func() {
+			g.Do("key", func() (interface{}, error) {
+				close(firstStarted)
+				<-release
+				return 1, nil
+			})
+			close(firstDone)
+		}()
+		<-firstStarted
+
+		g.Forget("key")
+		v, _, shared := g.Do("key", func() (interface{}, error) { return 2, nil })
+		if v != 2 || shared {
+			t.Errorf("after Forget, Do = %v, shared %v; want a fresh call", v, shared)
+		}
+		close(release)
+		<-firstDone
+	})
+}
+
+func TestPanicReachesAllCallers(t *testing.T) {
+	forEach(t, func(t *testing.T, g group) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var wg sync.WaitGroup
+		var panics int32
+
+		do := func(fn func() (interface{}, error)) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					atomic.AddInt32(&panics, 1)
+				}
+			}()
+			g.Do("key", fn)
+		}
+
+		wg.Add(1)
+		go do(func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("kaboom")
+		})
+		<-started
+		wg.Add(1)
+		go do(func() (interface{}, error) { return nil, nil })
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if panics != 2 {
+			t.Errorf("%d callers panicked, want 2", panics)
+		}
+		// The group must still be usable afterwards.
+		if v, _, _ := g.Do("key", func() (interface{}, error) { return "ok", nil }); v != "ok" {
+			t.Errorf("group broken after panic: %v", v)
+		}
+	})
+}
+
+func TestPanicValue(t *testing.T) {
+	var g Group
+	defer func() {
+		p, ok := recover().(*PanicError)
+		if !ok || p.Value != "kaboom" || len(p.Stack) == 0 {
+			t.Errorf("expected *PanicError with value and stack, got %#v", p)
+		}
+	}()
+	g.Do("key", func() (interface{}, error) { panic("kaboom") })
+}
+
+func TestDoChan(t *testing.T) {
+	var g Group
+	res := <-g.DoChan("key", func() (interface{}, error) { return 42, nil })
+	if res.Val != 42 || res.Err != nil || res.Shared {
+		t.Errorf("DoChan = %+v", res)
+	}
+
+	res = <-g.DoChan("key", func() (interface{}, error) { panic("kaboom") })
+	if _, ok := res.Err.(*PanicError); !ok {
+		t.Errorf("expected a *PanicError, got %v", res.Err)
+	}
+}
+
+// profileStore pretends to be a slow backend.
+type profileStore struct{ hits int32 }
+
+func (s *profileStore) load(user string) (string, error) {
+	atomic.AddInt32(&s.hits, 1)
+	time.Sleep(10 * time.Millisecond)
+	return "profile of " + user, nil
+}
+
+// profiles wraps the store so concurrent lookups for one user share a call.
+type profiles struct {
+	store *profileStore
+	group Group
+}
+
+func (p *profiles) Get(user string) (string, error) {
+	v, err, _ := p.group.Do(user, func() (interface{}, error) {
+		return p.store.load(user)
+	})
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+func ExampleGroup() {
+	p := &profiles{store: &profileStore{}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Get("gopher")
+		}()
+	}
+	wg.Wait()
+
+	v, _ := p.Get("gopher")
+	fmt.Println(v)
+	fmt.Println(atomic.LoadInt32(&p.store.hits) < 5)
+	// Output:
+	// profile of gopher
+	// true
+}
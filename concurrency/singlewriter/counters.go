@@ -0,0 +1,68 @@
+package singlewriter
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// Counters is a set of named counters owned by a Loop.
+type Counters struct {
+	loop *Loop[map[string]int]
+}
+
+// NewCounters starts an empty set of counters.
+func NewCounters() *Counters {
+	return &Counters{loop: Start(make(map[string]int), 64)}
+}
+
+// Add adds n to the counter key without waiting for it to happen.
+func (c *Counters) Add(ctx context.Context, key string, n int) error {
+	return c.loop.Send(ctx, func(m *map[string]int) { (*m)[key] += n })
+}
+
+// Get returns the counter key, including every Add that returned before.
+func (c *Counters) Get(ctx context.Context, key string) (int, error) {
+	return Call(ctx, c.loop, func(m *map[string]int) int { return (*m)[key] })
+}
+
+// Snapshot returns a copy of all counters.
+func (c *Counters) Snapshot(ctx context.Context) (map[string]int, error) {
+	return Call(ctx, c.loop, func(m *map[string]int) map[string]int { return maps.Clone(*m) })
+}
+
+// Close stops the loop once pending additions are done.
+func (c *Counters) Close() { c.loop.Close() }
+
+// MutexCounters is the same set of counters guarded by a mutex, for
+// comparison.
+type MutexCounters struct {
+	mu sync.Mutex
+	m  map[string]int
+}
+
+// NewMutexCounters returns an empty set of counters.
+func NewMutexCounters() *MutexCounters {
+	return &MutexCounters{m: make(map[string]int)}
+}
+
+// Add adds n to the counter key.
+func (c *MutexCounters) Add(key string, n int) {
+	c.mu.Lock()
+	c.m[key] += n
+	c.mu.Unlock()
+}
+
+// Get returns the counter key.
+func (c *MutexCounters) Get(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.m[key]
+}
+
+// Snapshot returns a copy of all counters.
+func (c *MutexCounters) Snapshot() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.m)
+}
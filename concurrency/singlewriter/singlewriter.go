@@ -0,0 +1,90 @@
+// Package singlewriter funnels every change to a piece of state through one
+// goroutine.
+//
+// Instead of guarding the state with a mutex, a Loop owns it: other
+// goroutines send it commands, functions of the state, over a channel, and
+// the loop runs them one at a time. No two commands ever touch the state
+// at once, so it needs no locks, and its invariants only have to hold
+// between commands. Call turns a command into a request with a response.
+//
+// The price is a channel hand-off per operation and a single goroutine
+// doing all the work; the benchmarks compare it with a mutex. Commands run
+// on the loop goroutine, so they must not block and must not call back into
+// their own Loop.
+package singlewriter
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned for commands sent after Close.
+var ErrClosed = errors.New("singlewriter: loop closed")
+
+// Loop owns a value of type S and applies commands to it in order.
+type Loop[S any] struct {
+	// mu makes Close wait for senders in flight, so that no command is
+	// accepted once cmds is closed.
+	mu     sync.RWMutex
+	closed bool
+	cmds   chan func(*S)
+	done   chan struct{}
+}
+
+// Start starts a Loop owning state. Up to queue commands wait in line
+// before Send blocks.
+func Start[S any](state S, queue int) *Loop[S] {
+	l := &Loop[S]{cmds: make(chan func(*S), queue), done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		for cmd := range l.cmds {
+			cmd(&state)
+		}
+	}()
+	return l
+}
+
+// Send queues cmd to run on the loop and returns without waiting for it.
+// A command that was queued runs even if Close is called meanwhile.
+func (l *Loop[S]) Send(ctx context.Context, cmd func(*S)) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return ErrClosed
+	}
+	select {
+	case l.cmds <- cmd:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Call runs fn on the loop and returns its result.
+func Call[S, R any](ctx context.Context, l *Loop[S], fn func(*S) R) (R, error) {
+	resp := make(chan R, 1)
+	if err := l.Send(ctx, func(s *S) { resp <- fn(s) }); err != nil {
+		var zero R
+		return zero, err
+	}
+	select {
+	case r := <-resp:
+		return r, nil
+	case <-ctx.Done():
+		var zero R
+		return zero, ctx.Err()
+	}
+}
+
+// Close stops accepting commands and returns once the queued ones have
+// run. It is safe to call more than once.
+func (l *Loop[S]) Close() {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.cmds)
+	}
+	l.mu.Unlock()
+	<-l.done
+}
@@ -0,0 +1,157 @@
+package singlewriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func TestCountersConcurrent(t *testing.T) {
+	c := NewCounters()
+	defer c.Close()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				if err := c.Add(ctx, "k"+strconv.Itoa(i%4), 1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	snap, err := c.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if got := snap["k"+strconv.Itoa(i)]; got != 16*125 {
+			t.Errorf("k%d = %d, want %d", i, got, 16*125)
+		}
+	}
+}
+
+// TestReadYourWrites checks that commands from one goroutine run in the
+// order they were sent.
+func TestReadYourWrites(t *testing.T) {
+	c := NewCounters()
+	defer c.Close()
+	ctx := context.Background()
+	for i := 1; i <= 100; i++ {
+		c.Add(ctx, "x", 1)
+		if got, _ := c.Get(ctx, "x"); got != i {
+			t.Fatalf("after %d adds Get = %d", i, got)
+		}
+	}
+}
+
+func TestCloseRunsQueued(t *testing.T) {
+	var ran []int
+	l := Start(&ran, 10)
+	for i := 0; i < 10; i++ {
+		if err := l.Send(context.Background(), func(s **[]int) { **s = append(**s, i) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+	if len(ran) != 10 {
+		t.Errorf("%d of 10 queued commands ran", len(ran))
+	}
+	if err := l.Send(context.Background(), func(**[]int) {}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send after Close: %v, want %v", err, ErrClosed)
+	}
+	if _, err := Call(context.Background(), l, func(**[]int) int { return 0 }); !errors.Is(err, ErrClosed) {
+		t.Errorf("Call after Close: %v, want %v", err, ErrClosed)
+	}
+	l.Close()
+}
+
+func TestCallContext(t *testing.T) {
+	l := Start(0, 0)
+	defer l.Close()
+	block := make(chan struct{})
+	l.Send(context.Background(), func(*int) { <-block })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := Call(ctx, l, func(n *int) int { return *n }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(block)
+}
+
+func BenchmarkCounters(b *testing.B) {
+	ctx := context.Background()
+	keys := []string{"a", "b", "c", "d"}
+	b.Run("loop/add", func(b *testing.B) {
+		c := NewCounters()
+		defer c.Close()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.Add(ctx, keys[i%len(keys)], 1)
+			}
+		})
+	})
+	b.Run("mutex/add", func(b *testing.B) {
+		c := NewMutexCounters()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.Add(keys[i%len(keys)], 1)
+			}
+		})
+	})
+	b.Run("loop/get", func(b *testing.B) {
+		c := NewCounters()
+		defer c.Close()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.Get(ctx, keys[i%len(keys)])
+			}
+		})
+	})
+	b.Run("mutex/get", func(b *testing.B) {
+		c := NewMutexCounters()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.Get(keys[i%len(keys)])
+			}
+		})
+	})
+}
+
+func ExampleCall() {
+	type account struct{ balance int }
+	l := Start(account{balance: 100}, 8)
+	defer l.Close()
+
+	withdraw := func(amount int) func(*account) error {
+		return func(a *account) error {
+			if a.balance < amount {
+				return fmt.Errorf("balance %d, want %d", a.balance, amount)
+			}
+			a.balance -= amount
+			return nil
+		}
+	}
+	ctx := context.Background()
+	for _, amount := range []int{60, 60} {
+		// The first error is the command's, the second the loop's.
+		res, err := Call(ctx, l, withdraw(amount))
+		fmt.Println(res, err)
+	}
+	// Output:
+	// <nil> <nil>
+	// balance 40, want 60 <nil>
+}
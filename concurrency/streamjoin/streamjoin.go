@@ -0,0 +1,189 @@
+// Package streamjoin merges two keyed streams into one, the way an
+// enrichment stage puts clicks together with the impressions they came
+// from, or orders with the latest price of what was ordered.
+//
+// Window joins values from both sides whose keys are equal and which
+// arrived within a time window of each other: an inner join, so a value
+// that finds no partner before the window has passed is dropped. Latest
+// joins every value of the left stream with the most recent value of the
+// right stream for the same key, treating the right stream as a table
+// that is kept up to date.
+//
+// As in concurrency/windows, every operator takes a done channel first,
+// time is the time a value was received, and it comes from a Clock so the
+// operators can be driven by a clock.Fake in tests.
+package streamjoin
+
+import (
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// Clock is the source of time for the joins. Every clock.Clock is one.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) clock.Ticker
+}
+
+// Joined is a left and a right value that share Key.
+type Joined[K comparable, L, R any] struct {
+	Key   K
+	Left  L
+	Right R
+}
+
+// seen is a value with the time it was received.
+type seen[T any] struct {
+	at time.Time
+	v  T
+}
+
+// Window emits a Joined for every pair of a left and a right value with
+// equal keys received at most window apart, as soon as the second of them
+// arrives. A value is matched with every partner inside its window, in
+// the order the partners arrived, and is forgotten once it is older than
+// window. The output is closed when both inputs are closed or done is. A
+// nil Clock uses clock.Real.
+func Window[K comparable, L, R any](done <-chan struct{}, left <-chan L, right <-chan R,
+	leftKey func(L) K, rightKey func(R) K, c Clock, window time.Duration) <-chan Joined[K, L, R] {
+	if window <= 0 {
+		panic("streamjoin: window must be positive")
+	}
+	if c == nil {
+		c = clock.Real
+	}
+	tick := c.NewTicker(window)
+	out := make(chan Joined[K, L, R])
+	go func() {
+		defer close(out)
+		defer tick.Stop()
+		lefts := make(map[K][]seen[L])
+		rights := make(map[K][]seen[R])
+		emit := func(j Joined[K, L, R]) bool {
+			select {
+			case out <- j:
+				return true
+			case <-done:
+				return false
+			}
+		}
+		for left != nil || right != nil {
+			select {
+			case <-done:
+				return
+			case now := <-tick.C():
+				// Matching checks the age of every partner, so this only
+				// frees the memory of keys that stopped arriving.
+				expire(lefts, now, window)
+				expire(rights, now, window)
+			case l, ok := <-left:
+				if !ok {
+					left = nil
+					continue
+				}
+				k, now := leftKey(l), c.Now()
+				rs := fresh(rights, k, now, window)
+				if right != nil {
+					lefts[k] = append(lefts[k], seen[L]{now, l})
+				}
+				for _, r := range rs {
+					if !emit(Joined[K, L, R]{k, l, r.v}) {
+						return
+					}
+				}
+			case r, ok := <-right:
+				if !ok {
+					right = nil
+					continue
+				}
+				k, now := rightKey(r), c.Now()
+				ls := fresh(lefts, k, now, window)
+				if left != nil {
+					rights[k] = append(rights[k], seen[R]{now, r})
+				}
+				for _, l := range ls {
+					if !emit(Joined[K, L, R]{k, l.v, r}) {
+						return
+					}
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// fresh drops the values of k older than window at now and returns the
+// rest, oldest first.
+func fresh[K comparable, T any](m map[K][]seen[T], k K, now time.Time, window time.Duration) []seen[T] {
+	vs := m[k]
+	i := 0
+	for i < len(vs) && now.Sub(vs[i].at) > window {
+		i++
+	}
+	if i == len(vs) {
+		delete(m, k)
+		return nil
+	}
+	// Clip the capacity so the caller's appends do not overwrite the slice
+	// being returned.
+	vs = vs[i:len(vs):len(vs)]
+	m[k] = vs
+	return vs
+}
+
+func expire[K comparable, T any](m map[K][]seen[T], now time.Time, window time.Duration) {
+	for k := range m {
+		fresh(m, k, now, window)
+	}
+}
+
+// Latest emits a Joined for every left value whose key has had a right
+// value, joined with the most recent one. A right value older than maxAge
+// no longer counts; a maxAge of zero keeps right values until they are
+// replaced. Right values do not emit anything on their own. The output is
+// closed when left is closed or done is; right is not read after that, so
+// its sender should watch done too. A closed right input leaves the last
+// values in place. A nil Clock uses clock.Real.
+func Latest[K comparable, L, R any](done <-chan struct{}, left <-chan L, right <-chan R,
+	leftKey func(L) K, rightKey func(R) K, c Clock, maxAge time.Duration) <-chan Joined[K, L, R] {
+	if c == nil {
+		c = clock.Real
+	}
+	out := make(chan Joined[K, L, R])
+	go func() {
+		defer close(out)
+		table := make(map[K]seen[R])
+		for {
+			select {
+			case <-done:
+				return
+			case r, ok := <-right:
+				if !ok {
+					right = nil
+					continue
+				}
+				table[rightKey(r)] = seen[R]{c.Now(), r}
+			case l, ok := <-left:
+				if !ok {
+					return
+				}
+				k := leftKey(l)
+				r, found := table[k]
+				if !found {
+					continue
+				}
+				if maxAge > 0 && c.Now().Sub(r.at) > maxAge {
+					delete(table, k)
+					continue
+				}
+				select {
+				case out <- Joined[K, L, R]{k, l, r.v}:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
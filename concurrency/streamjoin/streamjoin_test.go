@@ -0,0 +1,156 @@
+package streamjoin
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// key is the part of "key:payload" before the colon.
+func key(s string) string { k, _, _ := strings.Cut(s, ":"); return k }
+
+func show(j Joined[string, string, string]) string {
+	return fmt.Sprintf("%s+%s", j.Left, j.Right)
+}
+
+// join drives an operator: values are sent one at a time on unbuffered
+// channels, so each one is received, at the fake time, before the next.
+type join struct {
+	t           *testing.T
+	clock       *clock.Fake
+	left, right chan string
+	out         <-chan Joined[string, string, string]
+}
+
+func newJoin(t *testing.T) *join {
+	return &join{t: t, clock: clock.NewFake(epoch), left: make(chan string), right: make(chan string)}
+}
+
+func (j *join) expect(want ...string) {
+	j.t.Helper()
+	for _, w := range want {
+		if got := show(<-j.out); got != w {
+			j.t.Errorf("joined %s, want %s", got, w)
+		}
+	}
+}
+
+func (j *join) close() {
+	j.t.Helper()
+	close(j.left)
+	close(j.right)
+	if got, ok := <-j.out; ok {
+		j.t.Errorf("joined %s after the inputs closed", show(got))
+	}
+}
+
+func TestWindowJoinsEqualKeys(t *testing.T) {
+	leaks.Check(t)
+	j := newJoin(t)
+	j.out = Window(nil, j.left, j.right, key, key, j.clock, 5*time.Second)
+	j.left <- "a:click"
+	j.right <- "b:view"
+	j.clock.Advance(time.Second)
+	j.right <- "a:view"
+	j.expect("a:click+a:view")
+	// Either side may come first.
+	j.right <- "b:view2"
+	j.left <- "b:click"
+	j.expect("b:click+b:view", "b:click+b:view2")
+	j.close()
+}
+
+func TestWindowExpiry(t *testing.T) {
+	leaks.Check(t)
+	j := newJoin(t)
+	j.out = Window(nil, j.left, j.right, key, key, j.clock, 5*time.Second)
+	j.left <- "a:1"
+	j.clock.Advance(5 * time.Second)
+	// Exactly window apart is still inside it.
+	j.right <- "a:2"
+	j.expect("a:1+a:2")
+
+	j.clock.Advance(time.Second)
+	j.right <- "a:3"
+	// a:1 arrived six seconds before a:3, so a:3 joins nothing; the next
+	// join shows that nothing was emitted for it.
+	j.left <- "a:4"
+	j.expect("a:4+a:2", "a:4+a:3")
+
+	// Past every window the ticker has long evicted the old values.
+	j.clock.Advance(time.Minute)
+	j.right <- "a:5"
+	j.left <- "a:6"
+	j.expect("a:6+a:5")
+	j.close()
+}
+
+func TestWindowStopsOnDone(t *testing.T) {
+	leaks.Check(t)
+	j := newJoin(t)
+	done := make(chan struct{})
+	j.out = Window(done, j.left, j.right, key, key, j.clock, time.Second)
+	j.left <- "a:1"
+	j.right <- "a:2"
+	// Nobody takes the join; done must still end the operator.
+	close(done)
+	for range j.out {
+	}
+}
+
+func TestWindowOneSideClosed(t *testing.T) {
+	leaks.Check(t)
+	j := newJoin(t)
+	j.out = Window(nil, j.left, j.right, key, key, j.clock, time.Second)
+	j.left <- "a:1"
+	close(j.left)
+	j.right <- "a:2"
+	j.expect("a:1+a:2")
+	close(j.right)
+	if got, ok := <-j.out; ok {
+		t.Errorf("joined %s after the inputs closed", show(got))
+	}
+}
+
+func TestLatestEnriches(t *testing.T) {
+	leaks.Check(t)
+	j := newJoin(t)
+	j.out = Latest(nil, j.left, j.right, key, key, j.clock, 0)
+	// No price yet: the order is dropped.
+	j.left <- "apple:order1"
+	j.right <- "apple:1.00"
+	j.left <- "apple:order2"
+	j.expect("apple:order2+apple:1.00")
+	j.right <- "apple:1.20"
+	j.right <- "pear:0.80"
+	j.left <- "apple:order3"
+	j.expect("apple:order3+apple:1.20")
+	j.left <- "pear:order4"
+	j.expect("pear:order4+pear:0.80")
+	j.close()
+}
+
+func TestLatestMaxAge(t *testing.T) {
+	leaks.Check(t)
+	j := newJoin(t)
+	j.out = Latest(nil, j.left, j.right, key, key, j.clock, time.Minute)
+	j.right <- "apple:1.00"
+	j.clock.Advance(time.Minute)
+	j.left <- "apple:order1"
+	j.expect("apple:order1+apple:1.00")
+	j.clock.Advance(time.Second)
+	j.left <- "apple:order2"
+	j.right <- "apple:1.10"
+	j.left <- "apple:order3"
+	// order2 found only a stale price and was dropped.
+	j.expect("apple:order3+apple:1.10")
+	j.close()
+}
@@ -1,23 +1,40 @@
 package fetchers
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/crazybber/go-patterns/concurrency/scope"
 	"github.com/davecgh/go-spew/spew"
 )
 
 type Fetcher interface {
-	Fetch(url string) (string, error)
+	Fetch(ctx context.Context, url string) (string, error)
 	GetName() string
 }
 
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 type GoogleFetcher struct {
 	Name string
 }
 
-func (g *GoogleFetcher) Fetch(url string) (string, error) {
-	time.Sleep(time.Second * 1)
+func (g *GoogleFetcher) Fetch(ctx context.Context, url string) (string, error) {
+	if err := sleep(ctx, time.Second*1); err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%s is fetching %s", g.Name, url), nil
 }
 
@@ -33,8 +50,10 @@ type BingFetcher struct {
 	Name string
 }
 
-func (b *BingFetcher) Fetch(url string) (string, error) {
-	time.Sleep(time.Second * 10)
+func (b *BingFetcher) Fetch(ctx context.Context, url string) (string, error) {
+	if err := sleep(ctx, time.Second*10); err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%s is fetching %s", b.Name, url), nil
 }
 
@@ -50,8 +69,10 @@ type DuckDuckGoFetcher struct {
 	Name string
 }
 
-func (d *DuckDuckGoFetcher) Fetch(url string) (string, error) {
-	time.Sleep(time.Second * 10)
+func (d *DuckDuckGoFetcher) Fetch(ctx context.Context, url string) (string, error) {
+	if err := sleep(ctx, time.Second*10); err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%s is fetching %s", d.Name, url), nil
 }
 
@@ -62,41 +83,41 @@ func (d *DuckDuckGoFetcher) GetName() string {
 func NewDuckDuckGoFetcherFetcher(name string) *DuckDuckGoFetcher {
 	return &DuckDuckGoFetcher{Name: name}
 }
+
+// FetchResults asks every fetcher for url at once and gathers what comes
+// back within timeout. The fetchers run in a scope, so a fetcher that times
+// out is cancelled and has returned by the time FetchResults does, rather
+// than running on in a goroutine nobody waits for.
 func FetchResults(url string, fetchers []Fetcher, timeout time.Duration) ([]string, []error) {
-	chStr := make(chan string, len(fetchers))
-	chErr := make(chan error, len(fetchers))
-	for _, f := range fetchers {
-		go func(f Fetcher) {
-			ch := make(chan string, 1)
-			eCh := make(chan error, 1)
-			go func() {
-				s, err := f.Fetch(url)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var (
+		mu            sync.Mutex
+		stringResults = make([]string, 0, len(fetchers))
+		errorResults  = make([]error, 0, len(fetchers))
+	)
+	scope.Run(ctx, func(s *scope.Scope) error {
+		for _, f := range fetchers {
+			s.Go(func(ctx context.Context) error {
+				r, err := f.Fetch(ctx, url)
+				if ctx.Err() != nil {
+					err = fmt.Errorf("%s timeout after %v on %v", f.GetName(), timeout, url)
+				}
+				mu.Lock()
+				defer mu.Unlock()
 				if err != nil {
-					eCh <- err
-				} else {
-					ch <- s
+					// A failed fetcher must not cancel the others, so
+					// errors are gathered rather than returned.
+					errorResults = append(errorResults, err)
+					return nil
 				}
-			}()
-			select {
-			case r := <-ch:
-				chStr <- r
-			case err := <-eCh:
-				chErr <- err
-			case <-time.After(timeout):
-				chErr <- fmt.Errorf("%s timeout after %v on %v", f.GetName(), timeout, url)
-			}
-		}(f)
-	}
-	stringResults := make([]string, 0, len(fetchers))
-	errorResults := make([]error, 0, len(fetchers))
-	for range fetchers {
-		select {
-		case s := <-chStr:
-			stringResults = append(stringResults, s)
-		case e := <-chErr:
-			errorResults = append(errorResults, e)
+				stringResults = append(stringResults, r)
+				return nil
+			})
 		}
-	}
+		return nil
+	})
 	return stringResults, errorResults
 }
 
@@ -0,0 +1,254 @@
+// Package supervisor keeps long-running goroutines alive the way Erlang/OTP
+// supervisors do: it starts its children, restarts those that fail, and
+// stops them all when it is stopped.
+//
+// Each child has a restart policy: a Permanent child is restarted whenever
+// it returns, a Transient one only when it fails, and a Temporary one
+// never. The strategy decides what a failure restarts: OneForOne restarts
+// the child that failed, OneForAll stops the others and restarts every
+// child, for children that only make sense together. Restarts wait out a
+// backoff, and a supervisor that has to restart more than MaxRestarts
+// times within Window gives up, stops everything and returns
+// ErrTooManyRestarts, because restarting is not going to help.
+//
+// Run has the signature of a child's Run, so a supervisor can be the child
+// of another: that is a supervision tree, in which a subtree that gives up
+// is restarted, or given up on, by its parent. Cancelling the context of
+// the root stops the whole tree, leaves first.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+// ErrTooManyRestarts is returned by Run when children fail faster than
+// the supervisor may restart them.
+var ErrTooManyRestarts = errors.New("supervisor: too many restarts")
+
+// Restart says when a child is restarted.
+type Restart int
+
+const (
+	// Permanent children are restarted whenever they return.
+	Permanent Restart = iota
+	// Transient children are restarted when they return an error or panic.
+	Transient
+	// Temporary children are never restarted.
+	Temporary
+)
+
+// Strategy says which children a failure restarts.
+type Strategy int
+
+const (
+	// OneForOne restarts only the child that returned.
+	OneForOne Strategy = iota
+	// OneForAll stops every other child and restarts them all.
+	OneForAll
+)
+
+// Child is a goroutine under supervision. Run must return soon after its
+// context is cancelled. A panic in Run counts as a failure.
+type Child struct {
+	Name    string
+	Run     func(ctx context.Context) error
+	Restart Restart
+}
+
+// Options configure a Supervisor.
+type Options struct {
+	Strategy Strategy
+	// Backoff computes the delay before each restart, counting the
+	// restarts within Window. It defaults to exponential backoff from
+	// 100ms up to 30s.
+	Backoff retry.Backoff
+	// MaxRestarts is how many restarts are allowed within Window before
+	// the supervisor gives up. It defaults to 3; negative means no limit.
+	MaxRestarts int
+	// Window defaults to 5s.
+	Window time.Duration
+	// OnExit, if set, is called from Run every time a child returns, with
+	// its error, which is nil for a clean return.
+	OnExit func(child string, err error)
+}
+
+func (o *Options) defaults() {
+	if o.Backoff == nil {
+		o.Backoff = retry.Exponential{Initial: 100 * time.Millisecond, Max: 30 * time.Second}
+	}
+	if o.MaxRestarts == 0 {
+		o.MaxRestarts = 3
+	}
+	if o.Window <= 0 {
+		o.Window = 5 * time.Second
+	}
+}
+
+// Supervisor supervises a fixed set of children.
+type Supervisor struct {
+	opts     Options
+	children []Child
+}
+
+// New returns a Supervisor of children.
+func New(opts Options, children ...Child) *Supervisor {
+	opts.defaults()
+	return &Supervisor{opts: opts, children: children}
+}
+
+// exit is a child's return.
+type exit struct {
+	i   int
+	err error
+}
+
+// child is the supervisor's view of a child.
+type child struct {
+	cancel  context.CancelFunc
+	running bool
+	// finished children have returned for good.
+	finished bool
+}
+
+// Run starts the children and supervises them until ctx is done, when it
+// stops them and returns ctx's error, or until it gives up. It returns nil
+// once every child has finished for good.
+func (s *Supervisor) Run(ctx context.Context) error {
+	// Cancelled on return, which also releases restarts still pending.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		kids     = make([]child, len(s.children))
+		exits    = make(chan exit)
+		due      = make(chan int)
+		running  int
+		restarts []time.Time
+		delay    time.Duration
+		// stopping is set while OneForAll waits for the others to stop.
+		stopping bool
+	)
+	start := func(i int) {
+		cctx, cancel := context.WithCancel(ctx)
+		kids[i].cancel, kids[i].running = cancel, true
+		running++
+		run := s.children[i].Run
+		go func() {
+			err := recovery.Do(func() error { return run(cctx) })
+			exits <- exit{i, err}
+		}()
+	}
+	exited := func(e exit) {
+		k := &kids[e.i]
+		k.cancel()
+		k.running = false
+		running--
+		if s.opts.OnExit != nil {
+			s.opts.OnExit(s.children[e.i].Name, e.err)
+		}
+	}
+	stopAll := func() {
+		for i := range kids {
+			if kids[i].running {
+				kids[i].cancel()
+			}
+		}
+		for running > 0 {
+			exited(<-exits)
+		}
+	}
+	// schedule restarts child i, or every child if i is -1, after the
+	// backoff.
+	schedule := func(i int) {
+		delay = s.opts.Backoff.Next(len(restarts), delay)
+		time.AfterFunc(delay, func() {
+			select {
+			case due <- i:
+			case <-ctx.Done():
+			}
+		})
+	}
+
+	for i := range s.children {
+		start(i)
+	}
+	pending := 0
+	for running > 0 || pending > 0 {
+		select {
+		case <-ctx.Done():
+			stopAll()
+			return ctx.Err()
+
+		case i := <-due:
+			pending--
+			if i >= 0 {
+				start(i)
+				continue
+			}
+			stopping = false
+			for i := range kids {
+				if !kids[i].finished {
+					start(i)
+				}
+			}
+
+		case e := <-exits:
+			exited(e)
+			c := s.children[e.i]
+			if stopping {
+				if running == 0 {
+					pending++
+					schedule(-1)
+				}
+				continue
+			}
+			if c.Restart == Temporary || (c.Restart == Transient && e.err == nil) {
+				kids[e.i].finished = true
+				continue
+			}
+
+			now := time.Now()
+			for len(restarts) > 0 && now.Sub(restarts[0]) > s.opts.Window {
+				restarts = restarts[1:]
+			}
+			if len(restarts) == 0 {
+				delay = 0
+			}
+			restarts = append(restarts, now)
+			if s.opts.MaxRestarts >= 0 && len(restarts) > s.opts.MaxRestarts {
+				stopAll()
+				if e.err == nil {
+					return fmt.Errorf("%w: %s returned", ErrTooManyRestarts, c.Name)
+				}
+				return fmt.Errorf("%w: %s: %w", ErrTooManyRestarts, c.Name, e.err)
+			}
+
+			if s.opts.Strategy == OneForOne {
+				pending++
+				schedule(e.i)
+				continue
+			}
+			for i := range kids {
+				if kids[i].running {
+					kids[i].cancel()
+				}
+				if s.children[i].Restart == Temporary {
+					kids[i].finished = true
+				}
+			}
+			kids[e.i].finished = false
+			if running > 0 {
+				stopping = true
+				continue
+			}
+			pending++
+			schedule(-1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/internal/chaos"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+var errInjected = errors.New("injected failure")
+
+// worker is a child whose runs fail, panic or return on demand. Runs not
+// told otherwise block until cancelled.
+type worker struct {
+	starts atomic.Int32
+	// script gives the outcome of each run by its number, from 1.
+	script func(run int32) error
+	// running receives the number of each run once it has started.
+	running chan int32
+}
+
+const block = "block"
+
+func newWorker(script func(run int32) error) *worker {
+	return &worker{script: script, running: make(chan int32, 100)}
+}
+
+func (w *worker) Run(ctx context.Context) error {
+	n := w.starts.Add(1)
+	w.running <- n
+	if err := w.script(n); err == nil || err.Error() != block {
+		if err != nil && err.Error() == "panic" {
+			panic("worker panicked")
+		}
+		return err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// failFirst fails the first n runs and blocks in the others.
+func failFirst(n int32) func(int32) error {
+	return func(run int32) error {
+		if run <= n {
+			return errInjected
+		}
+		return errors.New(block)
+	}
+}
+
+func always(err error) func(int32) error { return func(int32) error { return err } }
+
+// waitRun waits for w to start its nth run.
+func waitRun(t *testing.T, w *worker, n int32) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-w.running:
+			if got >= n {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("run %d never started; %d runs", n, w.starts.Load())
+		}
+	}
+}
+
+func fast(opts Options) Options {
+	opts.Backoff = retry.Constant(time.Millisecond)
+	return opts
+}
+
+// supervise runs s in the background until the test ends.
+func supervise(t *testing.T, s *Supervisor) <-chan error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return done
+}
+
+func TestOneForOne(t *testing.T) {
+	leaks.Check(t)
+	flaky, steady := newWorker(failFirst(2)), newWorker(always(errors.New(block)))
+	supervise(t, New(fast(Options{Strategy: OneForOne}),
+		Child{Name: "flaky", Run: flaky.Run},
+		Child{Name: "steady", Run: steady.Run},
+	))
+	waitRun(t, flaky, 3)
+	if n := steady.starts.Load(); n != 1 {
+		t.Errorf("steady started %d times, want 1", n)
+	}
+}
+
+func TestOneForAll(t *testing.T) {
+	leaks.Check(t)
+	flaky, steady := newWorker(failFirst(1)), newWorker(always(errors.New(block)))
+	supervise(t, New(fast(Options{Strategy: OneForAll}),
+		Child{Name: "flaky", Run: flaky.Run},
+		Child{Name: "steady", Run: steady.Run},
+	))
+	waitRun(t, flaky, 2)
+	waitRun(t, steady, 2)
+}
+
+func TestRestartPolicies(t *testing.T) {
+	leaks.Check(t)
+	var mu sync.Mutex
+	exits := map[string]int{}
+	permanent := newWorker(func(run int32) error {
+		if run == 1 {
+			return nil
+		}
+		return errors.New(block)
+	})
+	transient := newWorker(always(nil))
+	temporary := newWorker(always(errInjected))
+	supervise(t, New(fast(Options{OnExit: func(child string, err error) {
+		mu.Lock()
+		exits[child]++
+		mu.Unlock()
+	}}),
+		Child{Name: "permanent", Run: permanent.Run, Restart: Permanent},
+		Child{Name: "transient", Run: transient.Run, Restart: Transient},
+		Child{Name: "temporary", Run: temporary.Run, Restart: Temporary},
+	))
+	// A Permanent child is restarted even after a clean return.
+	waitRun(t, permanent, 2)
+	time.Sleep(10 * time.Millisecond)
+	if transient.starts.Load() != 1 || temporary.starts.Load() != 1 {
+		t.Errorf("transient started %d times, temporary %d, want 1 and 1",
+			transient.starts.Load(), temporary.starts.Load())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if exits["transient"] != 1 || exits["temporary"] != 1 || exits["permanent"] != 1 {
+		t.Errorf("exits %v", exits)
+	}
+}
+
+func TestTransientFailureIsRestarted(t *testing.T) {
+	leaks.Check(t)
+	w := newWorker(failFirst(1))
+	supervise(t, New(fast(Options{}), Child{Name: "w", Run: w.Run, Restart: Transient}))
+	waitRun(t, w, 2)
+}
+
+func TestGivesUp(t *testing.T) {
+	leaks.Check(t)
+	broken := newWorker(always(errInjected))
+	bystander := newWorker(always(errors.New(block)))
+	s := New(fast(Options{MaxRestarts: 3, Window: time.Minute}),
+		Child{Name: "broken", Run: broken.Run},
+		Child{Name: "bystander", Run: bystander.Run},
+	)
+	err := s.Run(context.Background())
+	if !errors.Is(err, ErrTooManyRestarts) || !errors.Is(err, errInjected) {
+		t.Errorf("Run = %v", err)
+	}
+	// The first run and three restarts.
+	if n := broken.starts.Load(); n != 4 {
+		t.Errorf("broken started %d times, want 4", n)
+	}
+}
+
+func TestPanicIsAFailure(t *testing.T) {
+	leaks.Check(t)
+	var mu sync.Mutex
+	var errs []error
+	w := newWorker(func(run int32) error {
+		if run == 1 {
+			return errors.New("panic")
+		}
+		return errors.New(block)
+	})
+	supervise(t, New(fast(Options{OnExit: func(_ string, err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}}), Child{Name: "w", Run: w.Run, Restart: Transient}))
+	waitRun(t, w, 2)
+	mu.Lock()
+	defer mu.Unlock()
+	var p *recovery.PanicError
+	if len(errs) != 1 || !errors.As(errs[0], &p) {
+		t.Errorf("exits %v, want one panic", errs)
+	}
+}
+
+// Children that fail and panic at random, not on a script, are restarted
+// until each of them gets going, and every failure is reported.
+func TestChaos(t *testing.T) {
+	leaks.Check(t)
+	m := chaos.New(chaos.Config{Seed: 11, ErrorRate: 0.4, PanicRate: 0.2})
+	running := make(chan string, 3)
+	var mu sync.Mutex
+	var errs, panics int
+	opts := fast(Options{MaxRestarts: -1, OnExit: func(_ string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		var p *recovery.PanicError
+		switch {
+		case errors.As(err, &p) && p.Value == chaos.PanicValue:
+			panics++
+		case errors.Is(err, chaos.ErrInjected):
+			errs++
+		}
+	}})
+	var children []Child
+	for _, name := range []string{"a", "b", "c"} {
+		children = append(children, Child{Name: name, Restart: Transient, Run: m.Wrap(func(ctx context.Context) error {
+			running <- name
+			<-ctx.Done()
+			return ctx.Err()
+		})})
+	}
+	supervise(t, New(opts, children...))
+	for range children {
+		select {
+		case <-running:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("children not all running; %+v", m.Stats())
+		}
+	}
+	s := m.Stats()
+	mu.Lock()
+	defer mu.Unlock()
+	if s.Errors+s.Panics == 0 || errs != s.Errors || panics != s.Panics || s.Calls != len(children)+errs+panics {
+		t.Errorf("saw %d errors and %d panics; %+v", errs, panics, s)
+	}
+}
+
+func TestTree(t *testing.T) {
+	leaks.Check(t)
+	var mu sync.Mutex
+	var subtreeExits []error
+	broken := newWorker(always(errInjected))
+	subtree := New(fast(Options{MaxRestarts: 1}), Child{Name: "broken", Run: broken.Run})
+	sibling := newWorker(always(errors.New(block)))
+	supervise(t, New(fast(Options{MaxRestarts: -1, OnExit: func(child string, err error) {
+		if child == "subtree" {
+			mu.Lock()
+			subtreeExits = append(subtreeExits, err)
+			mu.Unlock()
+		}
+	}}),
+		Child{Name: "subtree", Run: subtree.Run},
+		Child{Name: "sibling", Run: sibling.Run},
+	))
+	// The subtree gives up after two runs of broken, and is itself
+	// restarted by the root.
+	waitRun(t, broken, 6)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(subtreeExits) < 2 || !errors.Is(subtreeExits[0], ErrTooManyRestarts) {
+		t.Errorf("subtree exits %v", subtreeExits)
+	}
+	if sibling.starts.Load() != 1 {
+		t.Errorf("sibling restarted under OneForOne")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	leaks.Check(t)
+	a, b := newWorker(always(errors.New(block))), newWorker(failFirst(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- New(Options{Backoff: retry.Constant(time.Hour)},
+			Child{Name: "a", Run: a.Run},
+			Child{Name: "b", Run: b.Run},
+		).Run(ctx)
+	}()
+	waitRun(t, a, 1)
+	waitRun(t, b, 1)
+	// b's restart is pending an hour away; cancelling must not wait for it.
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop")
+	}
+}
+
+func TestAllFinished(t *testing.T) {
+	w := newWorker(always(nil))
+	err := New(Options{}, Child{Name: "once", Run: w.Run, Restart: Transient}).Run(context.Background())
+	if err != nil {
+		t.Errorf("Run = %v", err)
+	}
+}
+
+func Example() {
+	var attempts atomic.Int32
+	connect := func(ctx context.Context) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("connection refused")
+		}
+		fmt.Println("connected after", attempts.Load(), "attempts")
+		return nil
+	}
+	s := New(Options{Backoff: retry.Constant(time.Millisecond), OnExit: func(child string, err error) {
+		fmt.Println(child, "exited:", err)
+	}}, Child{Name: "db", Run: connect, Restart: Transient})
+	fmt.Println(s.Run(context.Background()))
+	// Output:
+	// db exited: connection refused
+	// db exited: connection refused
+	// connected after 3 attempts
+	// db exited: <nil>
+	// <nil>
+}
@@ -0,0 +1,146 @@
+// Package ticker is a ticker that does not drift.
+//
+// The obvious periodic loop, sleep for the period and do the work, drifts:
+// every iteration adds the time the work took, and the scheduling latency,
+// to the period. Ticker instead computes every tick from the epoch, the
+// time it started: tick n is due at epoch + n*period, however late tick
+// n-1 was.
+//
+// When the process stalls, say a long GC pause or a slow consumer, ticks
+// come due while nobody can take them. time.Ticker silently drops those,
+// keeping one in its buffer. Ticker lets a Missed policy decide and reports
+// what it did in every Tick: catch up with a burst of ticks, fire once for
+// all of them, or skip them and wait for the next tick still ahead.
+package ticker
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for a Ticker.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Missed is the policy for ticks that came due while the ticker could not
+// deliver them, because the previous tick was delivered a full period or
+// more late.
+type Missed int
+
+const (
+	// Coalesce delivers one tick for all the missed ones right away, with
+	// Missed set to their number.
+	Coalesce Missed = iota
+	// Burst delivers every missed tick, back to back, to catch up.
+	Burst
+	// Skip drops the missed ticks and waits for the next one due.
+	Skip
+)
+
+// Tick is one activation.
+type Tick struct {
+	// Time is when the tick was due.
+	Time time.Time
+	// Seq is the tick's number: it was due at epoch + Seq*period.
+	Seq int64
+	// Missed is the number of ticks before this one that were coalesced
+	// into it or skipped.
+	Missed int64
+}
+
+// Options configures a Ticker.
+type Options struct {
+	Missed Missed
+	// Clock defaults to the wall clock.
+	Clock Clock
+}
+
+// Ticker delivers ticks on C at a fixed period from its epoch.
+type Ticker struct {
+	C <-chan Tick
+
+	period time.Duration
+	opts   Options
+	epoch  time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New starts a ticker whose first tick is due one period from now. It
+// panics if period is not positive, like time.NewTicker.
+func New(period time.Duration, opts Options) *Ticker {
+	if period <= 0 {
+		panic("ticker: period must be positive")
+	}
+	if opts.Clock == nil {
+		opts.Clock = realClock{}
+	}
+	c := make(chan Tick)
+	t := &Ticker{
+		C:      c,
+		period: period,
+		opts:   opts,
+		epoch:  opts.Clock.Now(),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go t.run(c)
+	return t
+}
+
+// Epoch returns the time the ticker started.
+func (t *Ticker) Epoch() time.Time { return t.epoch }
+
+func (t *Ticker) due(seq int64) time.Time {
+	return t.epoch.Add(time.Duration(seq) * t.period)
+}
+
+func (t *Ticker) run(c chan<- Tick) {
+	defer close(t.done)
+	seq, missed := int64(1), int64(0)
+	for {
+		due := t.due(seq)
+		select {
+		case <-t.opts.Clock.After(due.Sub(t.opts.Clock.Now())):
+		case <-t.stop:
+			return
+		}
+
+		// behind counts the ticks after seq that are due by now.
+		behind := int64(t.opts.Clock.Now().Sub(due) / t.period)
+		if behind > 0 {
+			switch t.opts.Missed {
+			case Coalesce:
+				missed += behind
+				seq += behind
+			case Skip:
+				missed += behind + 1
+				seq += behind + 1
+				continue
+			}
+		}
+
+		select {
+		case c <- Tick{Time: t.due(seq), Seq: seq, Missed: missed}:
+		case <-t.stop:
+			return
+		}
+		seq, missed = seq+1, 0
+	}
+}
+
+// Stop stops the ticker. No tick is delivered after Stop returns. Unlike
+// time.Ticker, C is not drained and stays open.
+func (t *Ticker) Stop() {
+	t.stopOnce.Do(func() { close(t.stop) })
+	<-t.done
+}
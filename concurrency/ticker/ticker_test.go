@@ -0,0 +1,208 @@
+package ticker
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+var start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// pausingClock is a fake clock that wakes every wait later than due by
+// pause(due), as a GC pause would, and records when each was due.
+type pausingClock struct {
+	*clock.Fake
+	pause func(due time.Time) time.Duration
+
+	mu   sync.Mutex
+	dues []time.Time
+}
+
+func (c *pausingClock) After(d time.Duration) <-chan time.Time {
+	due := c.Now().Add(d)
+	c.mu.Lock()
+	c.dues = append(c.dues, due)
+	c.mu.Unlock()
+	return c.Fake.After(d + c.pause(due))
+}
+
+// simulate runs a ticker on a fake clock until it has delivered n ticks,
+// and returns them with the deadlines of the ticker's waits. pause says
+// how much later than due the clock wakes the ticker for the wait that
+// ends at the given time.
+func simulate(n int, src source, pause func(due time.Time) time.Duration) (ticks []Tick, waits []time.Time) {
+	c := &pausingClock{Fake: clock.NewFake(start), pause: pause}
+	ch, stop := src(c)
+	woken := 0
+	for len(ticks) < n {
+		// Wait for the ticker to park on the clock, taking the ticks it
+		// delivers meanwhile.
+		for c.Pending() == 0 {
+			select {
+			case tk := <-ch:
+				ticks = append(ticks, tk)
+			default:
+				runtime.Gosched()
+			}
+		}
+		c.AdvanceToNext()
+		woken++
+	}
+	stop()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ticks[:n], c.dues[:woken]
+}
+
+type source func(Clock) (<-chan Tick, func())
+
+func drift(p Missed, period time.Duration) source {
+	return func(c Clock) (<-chan Tick, func()) {
+		tk := New(period, Options{Missed: p, Clock: c})
+		return tk.C, tk.Stop
+	}
+}
+
+// sleepLoop is the usual loop around time.After: every wait starts when
+// the previous one ended.
+func sleepLoop(period time.Duration) source {
+	return func(c Clock) (<-chan Tick, func()) {
+		ch, quit := make(chan Tick), make(chan struct{})
+		go func() {
+			for seq := int64(1); ; seq++ {
+				select {
+				case <-c.After(period):
+				case <-quit:
+					return
+				}
+				select {
+				case ch <- Tick{Time: c.Now(), Seq: seq}:
+				case <-quit:
+					return
+				}
+			}
+		}()
+		return ch, func() { close(quit) }
+	}
+}
+
+// gcPauses delays every fifth wakeup by 30ms.
+func gcPauses(due time.Time) time.Duration {
+	if due.Sub(start)/(100*time.Millisecond)%5 == 4 {
+		return 30 * time.Millisecond
+	}
+	return 0
+}
+
+func TestNoDrift(t *testing.T) {
+	const period = 100 * time.Millisecond
+	_, ours := simulate(50, drift(Coalesce, period), gcPauses)
+	_, naive := simulate(50, sleepLoop(period), gcPauses)
+
+	// Every wait of the ticker ends on the grid, pauses or not.
+	for i, at := range ours {
+		if want := start.Add(time.Duration(i+1) * period); !at.Equal(want) {
+			t.Fatalf("wait %d ends at %v, want %v", i, at.Sub(start), want.Sub(start))
+		}
+	}
+	// The sleep loop falls behind by every pause.
+	lag := naive[49].Sub(start.Add(50 * period))
+	if lag < 200*time.Millisecond {
+		t.Errorf("sleep loop lag %v; expected it to drift", lag)
+	}
+	t.Logf("after 50 ticks: drift-corrected on time, sleep loop %v late", lag)
+}
+
+// longPause stalls the wakeup for tick 3 by 250ms, so ticks 4 and 5 come
+// due meanwhile.
+func longPause(due time.Time) time.Duration {
+	if due.Equal(start.Add(300 * time.Millisecond)) {
+		return 250 * time.Millisecond
+	}
+	return 0
+}
+
+func TestMissedPolicies(t *testing.T) {
+	const period = 100 * time.Millisecond
+	type tick struct{ seq, missed int64 }
+	for _, tc := range []struct {
+		name   string
+		policy Missed
+		want   []tick
+	}{
+		{"coalesce", Coalesce, []tick{{1, 0}, {2, 0}, {5, 2}, {6, 0}, {7, 0}}},
+		{"burst", Burst, []tick{{1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}}},
+		{"skip", Skip, []tick{{1, 0}, {2, 0}, {6, 3}, {7, 0}, {8, 0}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ticks, _ := simulate(5, drift(tc.policy, period), longPause)
+			for i, tk := range ticks {
+				if got := (tick{tk.Seq, tk.Missed}); got != tc.want[i] {
+					t.Errorf("tick %d = %+v, want %+v", i, got, tc.want[i])
+				}
+				if want := start.Add(time.Duration(tk.Seq) * period); !tk.Time.Equal(want) {
+					t.Errorf("tick %d due %v, want %v", i, tk.Time.Sub(start), want.Sub(start))
+				}
+			}
+		})
+	}
+}
+
+// TestTimeTickerLoses shows what the standard ticker does in the same
+// situation, on the real clock: ticks due during a stall are gone without
+// a trace.
+func TestTimeTickerLoses(t *testing.T) {
+	const period = 10 * time.Millisecond
+	tk := time.NewTicker(period)
+	defer tk.Stop()
+	<-tk.C
+	time.Sleep(6 * period)
+	got := 0
+	deadline := time.After(period / 2)
+loop:
+	for {
+		select {
+		case <-tk.C:
+			got++
+		case <-deadline:
+			break loop
+		}
+	}
+	if got > 1 {
+		t.Errorf("time.Ticker delivered %d ticks after the stall, expected at most 1", got)
+	}
+}
+
+func TestStop(t *testing.T) {
+	c := clock.NewFake(start)
+	tk := New(time.Second, Options{Clock: c})
+	c.BlockUntil(1)
+	tk.Stop()
+	tk.Stop()
+	c.Advance(time.Hour)
+	select {
+	case got := <-tk.C:
+		t.Errorf("tick after Stop: %+v", got)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	const period = 5 * time.Millisecond
+	tk := New(period, Options{})
+	defer tk.Stop()
+	var last int64
+	for i := 0; i < 3; i++ {
+		got := <-tk.C
+		if got.Seq != last+1+got.Missed || !got.Time.Equal(tk.Epoch().Add(time.Duration(got.Seq)*period)) {
+			t.Errorf("tick %+v after %d", got, last)
+		}
+		last = got.Seq
+	}
+}
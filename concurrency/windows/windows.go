@@ -0,0 +1,161 @@
+// Package windows groups the values of a stream into windows, the batches
+// a streaming aggregation works on: the requests of each second, or the
+// last hundred readings of a sensor.
+//
+// Time windows are cut by a ticker. Tumbling windows follow each other
+// without overlap; sliding windows are longer than the interval at which
+// they are emitted, so each value lands in several. Count windows are cut
+// every so many values instead. Every operator takes a done channel first,
+// like the stages of concurrency/generator, and an aggregate is a
+// generator.Map of the windows.
+//
+// Time comes from a Clock, so the operators can be driven by a clock.Fake
+// in tests.
+package windows
+
+import (
+	"slices"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// Clock is the source of time for the time windows. Every clock.Clock is
+// one.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) clock.Ticker
+}
+
+// Window is a batch of values received between Start and End.
+type Window[T any] struct {
+	Start, End time.Time
+	Items      []T
+}
+
+// Tumbling emits the values received in each interval of length size. A
+// window is emitted at every tick, even an empty one, so a consumer sees
+// time pass when nothing arrives. When in is closed, the values received
+// since the last tick are emitted in a final, shorter window. A nil Clock
+// uses clock.Real.
+func Tumbling[T any](done <-chan struct{}, in <-chan T, c Clock, size time.Duration) <-chan Window[T] {
+	return Sliding(done, in, c, size, size)
+}
+
+// Sliding emits, every interval of length every, the values received in
+// the last size. size must be a multiple of every: the values are kept in
+// panes of length every, and each window joins the last size/every panes.
+// Ticks the consumer is too slow to take are dropped, as with time.Ticker,
+// so a pane then covers more than every; Start and End still tell the
+// time a window covers. When in is closed, a final window ends at the
+// time it was closed if a value arrived since the last tick.
+func Sliding[T any](done <-chan struct{}, in <-chan T, c Clock, size, every time.Duration) <-chan Window[T] {
+	if every <= 0 || size < every || size%every != 0 {
+		panic("windows: size must be a positive multiple of every")
+	}
+	if c == nil {
+		c = clock.Real
+	}
+	n := int(size / every)
+	tick := c.NewTicker(every)
+	paneStart := c.Now()
+	out := make(chan Window[T])
+	go func() {
+		defer close(out)
+		defer tick.Stop()
+		var panes [][]T // the last n-1 closed panes, oldest first
+		var current []T
+		emit := func(end time.Time) bool {
+			panes = append(panes, current)
+			if len(panes) > n {
+				panes = slices.Delete(panes, 0, 1)
+			}
+			var items []T
+			for _, p := range panes {
+				items = append(items, p...)
+			}
+			w := Window[T]{Start: paneStart.Add(every - size), End: end, Items: items}
+			current, paneStart = nil, end
+			select {
+			case out <- w:
+				return true
+			case <-done:
+				return false
+			}
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-tick.C():
+				if !emit(now) {
+					return
+				}
+			case v, ok := <-in:
+				if !ok {
+					if len(current) > 0 {
+						emit(c.Now())
+					}
+					return
+				}
+				current = append(current, v)
+			}
+		}
+	}()
+	return out
+}
+
+// Count emits the values of in in batches of size. When in is closed, the
+// values left over are emitted in a final, smaller batch.
+func Count[T any](done <-chan struct{}, in <-chan T, size int) <-chan []T {
+	return CountSliding(done, in, size, size)
+}
+
+// CountSliding emits the last size values every step values, once size
+// values have arrived. step must be between 1 and size. When in is closed,
+// values that arrived since the last window are emitted in a final window
+// that starts step values after the last one began.
+func CountSliding[T any](done <-chan struct{}, in <-chan T, size, step int) <-chan []T {
+	if step <= 0 || size < step {
+		panic("windows: step must be between 1 and size")
+	}
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+		var buf []T
+		fresh := 0 // values not yet at the end of an emitted window
+		for {
+			var v T
+			var ok bool
+			select {
+			case <-done:
+				return
+			case v, ok = <-in:
+			}
+			if !ok {
+				break
+			}
+			if buf = append(buf, v); len(buf) > size {
+				buf = slices.Delete(buf, 0, 1)
+			}
+			if fresh++; len(buf) < size || fresh < step {
+				continue
+			}
+			fresh = 0
+			select {
+			case out <- slices.Clone(buf):
+			case <-done:
+				return
+			}
+		}
+		if fresh == 0 {
+			return
+		}
+		last := buf[max(0, len(buf)-(size-step+fresh)):]
+		select {
+		case out <- last:
+		case <-done:
+		}
+	}()
+	return out
+}
@@ -0,0 +1,198 @@
+package windows
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/generator"
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// show formats a window as its items and its span in seconds since epoch.
+func show[T any](w Window[T]) string {
+	return fmt.Sprintf("%v@%v-%v", w.Items, w.Start.Sub(epoch).Seconds(), w.End.Sub(epoch).Seconds())
+}
+
+// stream is a scripted input: values are sent one at a time and the clock
+// is moved between them, so every value is received before the tick that
+// follows it.
+type stream struct {
+	t     *testing.T
+	clock *clock.Fake
+	in    chan int
+	out   <-chan Window[int]
+}
+
+func (s *stream) send(vs ...int) {
+	for _, v := range vs {
+		s.in <- v
+	}
+}
+
+// tick advances the clock by d and returns the window it cuts.
+func (s *stream) tick(d time.Duration) string {
+	s.clock.Advance(d)
+	return show(<-s.out)
+}
+
+func (s *stream) expect(got, want string) {
+	s.t.Helper()
+	if got != want {
+		s.t.Errorf("window %s, want %s", got, want)
+	}
+}
+
+func newStream(t *testing.T, size, every time.Duration) *stream {
+	s := &stream{t: t, clock: clock.NewFake(epoch), in: make(chan int)}
+	s.out = Sliding(nil, s.in, s.clock, size, every)
+	return s
+}
+
+func TestTumbling(t *testing.T) {
+	leaks.Check(t)
+	s := newStream(t, time.Second, time.Second)
+	s.send(1, 2)
+	s.expect(s.tick(time.Second), "[1 2]@0-1")
+	s.expect(s.tick(time.Second), "[]@1-2")
+	s.send(3)
+	s.clock.Advance(500 * time.Millisecond)
+	close(s.in)
+	s.expect(show(<-s.out), "[3]@2-2.5")
+	if w, ok := <-s.out; ok {
+		t.Errorf("window %s after the input closed", show(w))
+	}
+}
+
+func TestSliding(t *testing.T) {
+	leaks.Check(t)
+	s := newStream(t, 3*time.Second, time.Second)
+	for i, want := range []string{"[1]@-2-1", "[1 2]@-1-2", "[1 2 3]@0-3"} {
+		s.send(i + 1)
+		s.expect(s.tick(time.Second), want)
+	}
+	s.expect(s.tick(time.Second), "[2 3]@1-4")
+	s.send(4)
+	close(s.in)
+	s.expect(show(<-s.out), "[3 4]@2-4")
+	if _, ok := <-s.out; ok {
+		t.Error("output not closed")
+	}
+}
+
+func TestSlowConsumerWidensPanes(t *testing.T) {
+	leaks.Check(t)
+	s := newStream(t, time.Second, time.Second)
+	s.send(1)
+	// Nobody takes the first window, so of the ticks at 2s and 3s one is
+	// dropped and the pane after the one it cuts covers the time it would
+	// have cut.
+	s.clock.Advance(time.Second)
+	s.clock.Advance(2 * time.Second)
+	s.expect(show(<-s.out), "[1]@0-1")
+	s.expect(show(<-s.out), "[]@1-2")
+	s.send(2)
+	s.expect(s.tick(time.Second), "[2]@2-4")
+	close(s.in)
+	for range s.out {
+	}
+}
+
+func TestDoneStops(t *testing.T) {
+	leaks.Check(t)
+	done := make(chan struct{})
+	f := clock.NewFake(epoch)
+	in := make(chan int)
+	out := Tumbling(done, in, f, time.Second)
+	counts := Count(done, in, 2)
+	in <- 1
+	f.Advance(time.Second)
+	close(done)
+	for range out {
+	}
+	for range counts {
+	}
+	if f.Pending() != 0 {
+		t.Error("ticker not stopped")
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, fn := range []func(){
+		func() { Sliding[int](nil, nil, clock.NewFake(epoch), 3*time.Second, 2*time.Second) },
+		func() { Tumbling[int](nil, nil, clock.NewFake(epoch), 0) },
+		func() { CountSliding[int](nil, nil, 2, 3) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func feed(n int) <-chan int {
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := 1; i <= n; i++ {
+			in <- i
+		}
+	}()
+	return in
+}
+
+func collect[T any](in <-chan T) []T {
+	var out []T
+	for v := range in {
+		out = append(out, v)
+	}
+	return out
+}
+
+func TestCount(t *testing.T) {
+	leaks.Check(t)
+	for _, tc := range []struct {
+		n, size, step int
+		want          string
+	}{
+		{7, 3, 3, "[[1 2 3] [4 5 6] [7]]"},
+		{6, 3, 3, "[[1 2 3] [4 5 6]]"},
+		{2, 3, 3, "[[1 2]]"},
+		{0, 3, 3, "[]"},
+		{7, 4, 2, "[[1 2 3 4] [3 4 5 6] [5 6 7]]"},
+		{5, 3, 1, "[[1 2 3] [2 3 4] [3 4 5]]"},
+		{2, 3, 1, "[[1 2]]"},
+	} {
+		got := fmt.Sprint(collect(CountSliding(nil, feed(tc.n), tc.size, tc.step)))
+		if got != tc.want {
+			t.Errorf("%d values, size %d, step %d: %s, want %s", tc.n, tc.size, tc.step, got, tc.want)
+		}
+	}
+}
+
+func ExampleCount() {
+	done := make(chan struct{})
+	defer close(done)
+	sum := func(batch []int) (total int) {
+		for _, v := range batch {
+			total += v
+		}
+		return total
+	}
+	for total := range generator.Map(done, Count(done, feed(10), 4), sum) {
+		fmt.Println(total)
+	}
+	// Output:
+	// 10
+	// 26
+	// 19
+}
@@ -0,0 +1,74 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildDefaults(t *testing.T) {
+	cfg, err := NewServerBuilder("localhost", 8080).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr() != "localhost:8080" || cfg.ReadTimeout() != 5*time.Second || cfg.MaxConns() != 1024 || cfg.TLS() {
+		t.Errorf("unexpected defaults %+v", cfg)
+	}
+}
+
+func TestBuildCollectsAllErrors(t *testing.T) {
+	_, err := NewServerBuilder("", 0).
+		Timeouts(0, time.Second).
+		MaxConns(-1).
+		TLS("cert.pem", "").
+		Build()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"host is required", "port 0", "timeouts", "max conns", "tls needs"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) || len(joined.Unwrap()) != 5 {
+		t.Errorf("expected 5 joined errors, got %v", err)
+	}
+}
+
+func TestBuildCrossFieldValidation(t *testing.T) {
+	_, err := NewServerBuilder("example.com", 80).TLS("cert.pem", "key.pem").Build()
+	if err == nil || !strings.Contains(err.Error(), "port 80") {
+		t.Errorf("expected the tls/port check to fail, got %v", err)
+	}
+}
+
+func TestProductIsImmutable(t *testing.T) {
+	b := NewServerBuilder("localhost", 8080).Tag("a")
+	cfg, _ := b.Build()
+
+	b.Tag("b").MaxConns(1)
+	cfg.Tags()[0] = "changed"
+
+	if got := cfg.Tags(); len(got) != 1 || got[0] != "a" || cfg.MaxConns() != 1024 {
+		t.Errorf("built config changed: tags %v, max conns %d", got, cfg.MaxConns())
+	}
+}
+
+func ExampleServerBuilder() {
+	cfg, err := NewServerBuilder("0.0.0.0", 8443).
+		TLS("cert.pem", "key.pem").
+		Timeouts(2*time.Second, 5*time.Second).
+		Tag("public").
+		Build()
+	fmt.Println(cfg.Addr(), cfg.TLS(), cfg.Tags(), err)
+
+	_, err = NewServerBuilder("", 70000).Build()
+	fmt.Println(err)
+	// Output:
+	// 0.0.0.0:8443 true [public] <nil>
+	// builder: invalid server config: host is required
+	// port 70000 out of range 1-65535
+}
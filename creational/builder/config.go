@@ -0,0 +1,110 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ServerConfig is the product of a ServerBuilder. Its fields are unexported
+// so a built config cannot be changed; the accessors return copies.
+type ServerConfig struct {
+	host         string
+	port         int
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	maxConns     int
+	certFile     string
+	keyFile      string
+	tags         []string
+}
+
+func (c *ServerConfig) Addr() string                { return fmt.Sprintf("%s:%d", c.host, c.port) }
+func (c *ServerConfig) ReadTimeout() time.Duration  { return c.readTimeout }
+func (c *ServerConfig) WriteTimeout() time.Duration { return c.writeTimeout }
+func (c *ServerConfig) MaxConns() int               { return c.maxConns }
+func (c *ServerConfig) TLS() bool                   { return c.certFile != "" }
+func (c *ServerConfig) Tags() []string              { return append([]string(nil), c.tags...) }
+
+// ServerBuilder builds a ServerConfig step by step. Host and port are
+// required and taken by NewServerBuilder; everything else is optional and
+// has a default.
+//
+// The setters do not stop at the first mistake. Every problem is recorded
+// and Build reports all of them at once, so a caller fixing a config does
+// not have to go round the loop once per field.
+type ServerBuilder struct {
+	cfg  ServerConfig
+	errs []error
+}
+
+// NewServerBuilder starts a config for host:port.
+func NewServerBuilder(host string, port int) *ServerBuilder {
+	b := &ServerBuilder{cfg: ServerConfig{
+		host:         host,
+		port:         port,
+		readTimeout:  5 * time.Second,
+		writeTimeout: 10 * time.Second,
+		maxConns:     1024,
+	}}
+	if host == "" {
+		b.fail("host is required")
+	}
+	if port < 1 || port > 65535 {
+		b.fail("port %d out of range 1-65535", port)
+	}
+	return b
+}
+
+func (b *ServerBuilder) fail(format string, args ...interface{}) {
+	b.errs = append(b.errs, fmt.Errorf(format, args...))
+}
+
+// Timeouts sets the read and write timeouts.
+func (b *ServerBuilder) Timeouts(read, write time.Duration) *ServerBuilder {
+	if read <= 0 || write <= 0 {
+		b.fail("timeouts must be positive, got read=%v write=%v", read, write)
+	}
+	b.cfg.readTimeout, b.cfg.writeTimeout = read, write
+	return b
+}
+
+// MaxConns limits the number of concurrent connections.
+func (b *ServerBuilder) MaxConns(n int) *ServerBuilder {
+	if n < 1 {
+		b.fail("max conns must be at least 1, got %d", n)
+	}
+	b.cfg.maxConns = n
+	return b
+}
+
+// TLS serves TLS with the given certificate and key.
+func (b *ServerBuilder) TLS(certFile, keyFile string) *ServerBuilder {
+	if certFile == "" || keyFile == "" {
+		b.fail("tls needs both a certificate and a key")
+	}
+	b.cfg.certFile, b.cfg.keyFile = certFile, keyFile
+	return b
+}
+
+// Tag adds a label to the config.
+func (b *ServerBuilder) Tag(tags ...string) *ServerBuilder {
+	b.cfg.tags = append(b.cfg.tags, tags...)
+	return b
+}
+
+// Build validates the config as a whole and returns it, or all the errors
+// found along the way joined into one. The builder can be reused; later
+// changes do not affect configs already built.
+func (b *ServerBuilder) Build() (*ServerConfig, error) {
+	errs := b.errs
+	if b.cfg.TLS() && b.cfg.port == 80 {
+		errs = append(errs, errors.New("tls on port 80 is almost certainly a mistake"))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("builder: invalid server config: %w", errors.Join(errs...))
+	}
+	cfg := b.cfg
+	cfg.tags = append([]string(nil), b.cfg.tags...)
+	return &cfg, nil
+}
@@ -0,0 +1,102 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewProviderSelectsType(t *testing.T) {
+	tests := []struct {
+		cfg  PaymentConfig
+		want string
+	}{
+		{PaymentConfig{Provider: "card", APIKey: "sk_test"}, "*factory.Card"},
+		{PaymentConfig{Provider: "paypal", Account: "shop"}, "*factory.PayPal"},
+		{PaymentConfig{Provider: "bank"}, "factory.BankTransfer"},
+	}
+	for _, tt := range tests {
+		p, err := NewProvider(tt.cfg)
+		if err != nil {
+			t.Errorf("%s: %v", tt.cfg.Provider, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", p); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.cfg.Provider, got, tt.want)
+		}
+		if p.Name() != tt.cfg.Provider {
+			t.Errorf("name %q, want %q", p.Name(), tt.cfg.Provider)
+		}
+	}
+}
+
+func TestNewProviderErrors(t *testing.T) {
+	if _, err := NewProvider(PaymentConfig{Provider: "bitcoin"}); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := NewProvider(PaymentConfig{Provider: "card"}); err == nil {
+		t.Error("card without an API key should fail")
+	}
+	p, _ := NewProvider(PaymentConfig{Provider: "bank"})
+	if _, err := p.Charge(100, "USD"); err == nil {
+		t.Error("bank transfers in USD should fail")
+	}
+}
+
+type giftCard struct{}
+
+func (giftCard) Name() string                         { return "gift" }
+func (giftCard) Charge(int64, string) (string, error) { return "GIFT", nil }
+
+func TestRegister(t *testing.T) {
+	Register("gift", func(PaymentConfig) (Provider, error) { return giftCard{}, nil })
+	p, err := NewProvider(PaymentConfig{Provider: "gift"})
+	if _, ok := p.(giftCard); !ok || err != nil {
+		t.Errorf("got %T, %v", p, err)
+	}
+}
+
+func TestInfrastructureFamilies(t *testing.T) {
+	tests := []struct {
+		env          string
+		store, queue string
+	}{
+		{"local", "*factory.MemoryStore", "*factory.ChanQueue"},
+		{"test", "*factory.MemoryStore", "*factory.ChanQueue"},
+		{"production", "*factory.BucketStore", "*factory.ManagedQueue"},
+	}
+	for _, tt := range tests {
+		infra, err := NewInfrastructure(tt.env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store, queue := infra.NewBlobStore(), infra.NewQueue()
+		if got := fmt.Sprintf("%T", store); got != tt.store {
+			t.Errorf("%s store: got %s, want %s", tt.env, got, tt.store)
+		}
+		if got := fmt.Sprintf("%T", queue); got != tt.queue {
+			t.Errorf("%s queue: got %s, want %s", tt.env, got, tt.queue)
+		}
+
+		store.Put("report", []byte("ok"))
+		queue.Send("report")
+		key, _ := queue.Receive()
+		if data, err := store.Get(key); err != nil || string(data) != "ok" {
+			t.Errorf("%s: family does not work together: %q, %v", tt.env, data, err)
+		}
+	}
+	if _, err := NewInfrastructure("mars"); !errors.Is(err, ErrUnknownEnvironment) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func ExampleNewProvider() {
+	p, err := NewProvider(PaymentConfig{Provider: "paypal", Account: "shop"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	ref, _ := p.Charge(1999, "EUR")
+	fmt.Println(p.Name(), ref)
+	// Output: paypal PAY-shop-1999EUR
+}
@@ -0,0 +1,153 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownEnvironment is returned for an environment without a family.
+var ErrUnknownEnvironment = errors.New("factory: unknown environment")
+
+// BlobStore keeps named blobs.
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+}
+
+// Queue passes messages between services.
+type Queue interface {
+	Send(msg string) error
+	Receive() (string, bool)
+}
+
+// Infrastructure is the abstract factory. Every implementation creates a
+// matching family of products.
+type Infrastructure interface {
+	NewBlobStore() BlobStore
+	NewQueue() Queue
+}
+
+// NewInfrastructure picks the family for an environment.
+func NewInfrastructure(env string) (Infrastructure, error) {
+	switch env {
+	case "local", "test":
+		return Local{}, nil
+	case "cloud", "production":
+		return Cloud{Region: "eu-west-1"}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownEnvironment, env)
+}
+
+// Local builds in-process products for development and tests.
+type Local struct{}
+
+func (Local) NewBlobStore() BlobStore { return &MemoryStore{blobs: make(map[string][]byte)} }
+func (Local) NewQueue() Queue         { return &ChanQueue{ch: make(chan string, 64)} }
+
+// Cloud builds products that talk to a cloud provider. The clients here
+// are simulated and keep everything in memory.
+type Cloud struct {
+	Region string
+}
+
+func (c Cloud) NewBlobStore() BlobStore {
+	return &BucketStore{Region: c.Region, objects: make(map[string][]byte)}
+}
+
+func (c Cloud) NewQueue() Queue { return &ManagedQueue{Region: c.Region} }
+
+// MemoryStore is the local BlobStore.
+type MemoryStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (m *MemoryStore) Put(key string, data []byte) error {
+	m.mu.Lock()
+	m.blobs[key] = append([]byte(nil), data...)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryStore) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.blobs[key]
+	if !ok {
+		return nil, fmt.Errorf("factory: no blob %q", key)
+	}
+	return b, nil
+}
+
+// ChanQueue is the local Queue.
+type ChanQueue struct {
+	ch chan string
+}
+
+func (q *ChanQueue) Send(msg string) error {
+	select {
+	case q.ch <- msg:
+		return nil
+	default:
+		return errors.New("factory: queue full")
+	}
+}
+
+func (q *ChanQueue) Receive() (string, bool) {
+	select {
+	case msg := <-q.ch:
+		return msg, true
+	default:
+		return "", false
+	}
+}
+
+// BucketStore is the cloud BlobStore.
+type BucketStore struct {
+	Region  string
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *BucketStore) Put(key string, data []byte) error {
+	b.mu.Lock()
+	b.objects[b.Region+"/"+key] = append([]byte(nil), data...)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *BucketStore) Get(key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, ok := b.objects[b.Region+"/"+key]
+	if !ok {
+		return nil, fmt.Errorf("factory: no object %q in %s", key, b.Region)
+	}
+	return data, nil
+}
+
+// ManagedQueue is the cloud Queue.
+type ManagedQueue struct {
+	Region string
+	mu     sync.Mutex
+	msgs   []string
+}
+
+func (q *ManagedQueue) Send(msg string) error {
+	q.mu.Lock()
+	q.msgs = append(q.msgs, msg)
+	q.mu.Unlock()
+	return nil
+}
+
+func (q *ManagedQueue) Receive() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.msgs) == 0 {
+		return "", false
+	}
+	msg := q.msgs[0]
+	q.msgs = q.msgs[1:]
+	return msg, true
+}
@@ -0,0 +1,130 @@
+// Package factory holds runnable versions of the two factory patterns.
+//
+// NewProvider is a factory method: callers ask for a payment Provider by
+// configuration and get an interface back, never the concrete type, so a
+// new provider is added by registering a constructor rather than by
+// changing every caller.
+//
+// Infrastructure is an abstract factory: it creates a whole family of
+// related objects, a blob store and a queue, that are meant to be used
+// together. Choosing the family once guarantees that a cloud queue is never
+// paired with a local store.
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownProvider is returned for a provider name nobody registered.
+var ErrUnknownProvider = errors.New("factory: unknown payment provider")
+
+// Provider charges payments.
+type Provider interface {
+	Name() string
+	// Charge takes amount cents and returns a transaction reference.
+	Charge(amount int64, currency string) (string, error)
+}
+
+// PaymentConfig selects and configures a Provider.
+type PaymentConfig struct {
+	Provider string
+	APIKey   string
+	Account  string
+}
+
+// Constructor builds a Provider from its configuration.
+type Constructor func(PaymentConfig) (Provider, error)
+
+var (
+	mu           sync.RWMutex
+	constructors = map[string]Constructor{
+		"card":   newCard,
+		"paypal": newPayPal,
+		"bank":   newBankTransfer,
+	}
+)
+
+// Register makes a provider available to NewProvider under name.
+func Register(name string, c Constructor) {
+	mu.Lock()
+	constructors[name] = c
+	mu.Unlock()
+}
+
+// Providers returns the registered provider names.
+func Providers() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(constructors))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProvider is the factory method.
+func NewProvider(cfg PaymentConfig) (Provider, error) {
+	mu.RLock()
+	c, ok := constructors[cfg.Provider]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, cfg.Provider)
+	}
+	return c(cfg)
+}
+
+// Card charges credit cards through a card processor.
+type Card struct {
+	apiKey string
+	seq    int
+}
+
+func newCard(cfg PaymentConfig) (Provider, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("factory: card payments need an API key")
+	}
+	return &Card{apiKey: cfg.APIKey}, nil
+}
+
+func (c *Card) Name() string { return "card" }
+
+func (c *Card) Charge(amount int64, currency string) (string, error) {
+	c.seq++
+	return fmt.Sprintf("ch_%d_%d%s", c.seq, amount, currency), nil
+}
+
+// PayPal charges a PayPal account.
+type PayPal struct {
+	account string
+}
+
+func newPayPal(cfg PaymentConfig) (Provider, error) {
+	if cfg.Account == "" {
+		return nil, errors.New("factory: paypal payments need an account")
+	}
+	return &PayPal{account: cfg.Account}, nil
+}
+
+func (p *PayPal) Name() string { return "paypal" }
+
+func (p *PayPal) Charge(amount int64, currency string) (string, error) {
+	return fmt.Sprintf("PAY-%s-%d%s", p.account, amount, currency), nil
+}
+
+// BankTransfer only supports euros, as SEPA transfers do.
+type BankTransfer struct{}
+
+func newBankTransfer(PaymentConfig) (Provider, error) { return BankTransfer{}, nil }
+
+func (BankTransfer) Name() string { return "bank" }
+
+func (BankTransfer) Charge(amount int64, currency string) (string, error) {
+	if currency != "EUR" {
+		return "", fmt.Errorf("factory: bank transfers only support EUR, got %s", currency)
+	}
+	return fmt.Sprintf("SEPA-%d", amount), nil
+}
@@ -0,0 +1,146 @@
+// Package prototype creates objects by copying a configured instance
+// instead of building them from scratch.
+//
+// The pattern stands or falls with Clone. Assigning a struct copies its
+// fields, but a slice, map or pointer field still points at the storage of
+// the original, so writing through the copy changes the prototype and every
+// other copy made from it. Clone here copies each of them explicitly, and
+// keeps back references such as Section.Parent pointing into the new graph
+// rather than into the old one.
+package prototype
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ErrUnknownPrototype is returned by Registry.New for unregistered names.
+var ErrUnknownPrototype = errors.New("prototype: unknown prototype")
+
+// Cloner is implemented by types that can produce an independent copy of
+// themselves.
+type Cloner[T any] interface {
+	Clone() T
+}
+
+// Person is the author of a document.
+type Person struct {
+	Name   string
+	Emails []string
+}
+
+// Clone returns a deep copy of p.
+func (p *Person) Clone() *Person {
+	if p == nil {
+		return nil
+	}
+	return &Person{Name: p.Name, Emails: slices.Clone(p.Emails)}
+}
+
+// Section is a node of a document outline.
+type Section struct {
+	Heading     string
+	Body        string
+	Parent      *Section
+	Children    []*Section
+	Attachments map[string][]byte
+}
+
+// Document is a nested graph of values, slices, maps and pointers.
+type Document struct {
+	Title    string
+	Tags     []string
+	Meta     map[string]string
+	Author   *Person
+	Sections []*Section
+}
+
+// ShallowCopy copies only the top level struct. It is here to show what
+// goes wrong: the copy shares its tags, metadata, author and sections with
+// d.
+func (d *Document) ShallowCopy() *Document {
+	c := *d
+	return &c
+}
+
+// Clone returns a deep copy of d that shares no mutable state with it.
+func (d *Document) Clone() *Document {
+	if d == nil {
+		return nil
+	}
+	c := &Document{
+		Title:  d.Title,
+		Tags:   slices.Clone(d.Tags),
+		Meta:   maps.Clone(d.Meta),
+		Author: d.Author.Clone(),
+	}
+	// seen maps every original section to its copy, so a section
+	// reachable twice is copied once and Parent links stay inside the
+	// clone.
+	seen := make(map[*Section]*Section)
+	if d.Sections != nil {
+		c.Sections = make([]*Section, len(d.Sections))
+		for i, s := range d.Sections {
+			c.Sections[i] = cloneSection(s, seen)
+		}
+	}
+	return c
+}
+
+func cloneSection(s *Section, seen map[*Section]*Section) *Section {
+	if s == nil {
+		return nil
+	}
+	if c, ok := seen[s]; ok {
+		return c
+	}
+	c := &Section{Heading: s.Heading, Body: s.Body}
+	seen[s] = c
+	c.Parent = cloneSection(s.Parent, seen)
+	if s.Children != nil {
+		c.Children = make([]*Section, len(s.Children))
+		for i, child := range s.Children {
+			c.Children[i] = cloneSection(child, seen)
+		}
+	}
+	if s.Attachments != nil {
+		c.Attachments = make(map[string][]byte, len(s.Attachments))
+		for name, data := range s.Attachments {
+			c.Attachments[name] = slices.Clone(data)
+		}
+	}
+	return c
+}
+
+// Registry hands out copies of named prototypes.
+type Registry[T Cloner[T]] struct {
+	mu     sync.RWMutex
+	protos map[string]T
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry[T Cloner[T]]() *Registry[T] {
+	return &Registry[T]{protos: make(map[string]T)}
+}
+
+// Register stores a copy of proto under name, so later changes to proto do
+// not leak into the registry.
+func (r *Registry[T]) Register(name string, proto T) {
+	r.mu.Lock()
+	r.protos[name] = proto.Clone()
+	r.mu.Unlock()
+}
+
+// New returns a fresh copy of the prototype registered under name.
+func (r *Registry[T]) New(name string) (T, error) {
+	r.mu.RLock()
+	proto, ok := r.protos[name]
+	r.mu.RUnlock()
+	if !ok {
+		var zero T
+		return zero, ErrUnknownPrototype
+	}
+	return proto.Clone(), nil
+}
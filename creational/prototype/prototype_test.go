@@ -0,0 +1,132 @@
+package prototype
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func sample() *Document {
+	intro := &Section{Heading: "Intro", Body: "hello", Attachments: map[string][]byte{"logo.png": {1, 2, 3}}}
+	usage := &Section{Heading: "Usage", Parent: intro}
+	intro.Children = []*Section{usage}
+	return &Document{
+		Title:    "Guide",
+		Tags:     []string{"draft", "go"},
+		Meta:     map[string]string{"lang": "en"},
+		Author:   &Person{Name: "Ann", Emails: []string{"ann@example.com"}},
+		Sections: []*Section{intro},
+	}
+}
+
+// mutate writes through every reference field of d.
+func mutate(d *Document) {
+	d.Title = "changed"
+	d.Tags[0] = "changed"
+	d.Meta["lang"] = "changed"
+	d.Author.Name = "changed"
+	d.Author.Emails[0] = "changed"
+	d.Sections[0].Heading = "changed"
+	d.Sections[0].Attachments["logo.png"][0] = 0
+	d.Sections[0].Children[0].Body = "changed"
+}
+
+func TestCloneIsolation(t *testing.T) {
+	orig := sample()
+	clone := orig.Clone()
+	if !reflect.DeepEqual(orig, clone) {
+		t.Fatalf("clone differs from the original")
+	}
+
+	mutate(clone)
+	if !reflect.DeepEqual(orig, sample()) {
+		t.Errorf("mutating the clone changed the original: %+v", orig)
+	}
+
+	clone = orig.Clone()
+	mutate(orig)
+	if !reflect.DeepEqual(clone, sample()) {
+		t.Errorf("mutating the original changed the clone: %+v", clone)
+	}
+}
+
+func TestShallowCopySharesState(t *testing.T) {
+	orig := sample()
+	shallow := orig.ShallowCopy()
+	mutate(shallow)
+
+	// Only the plain string field is isolated.
+	if orig.Title != "Guide" {
+		t.Errorf("title should be copied by value")
+	}
+	shared := []struct {
+		name string
+		got  string
+	}{
+		{"slice", orig.Tags[0]},
+		{"map", orig.Meta["lang"]},
+		{"pointer", orig.Author.Name},
+		{"slice behind pointer", orig.Author.Emails[0]},
+		{"nested pointer", orig.Sections[0].Children[0].Body},
+	}
+	for _, s := range shared {
+		if s.got != "changed" {
+			t.Errorf("%s: a shallow copy should share it, got %q", s.name, s.got)
+		}
+	}
+}
+
+func TestCloneKeepsGraphShape(t *testing.T) {
+	orig := sample()
+	clone := orig.Clone()
+
+	intro, usage := clone.Sections[0], clone.Sections[0].Children[0]
+	if usage.Parent != intro {
+		t.Errorf("parent link points outside the clone")
+	}
+	if usage.Parent == orig.Sections[0] {
+		t.Errorf("parent link still points at the original")
+	}
+}
+
+func TestCloneNilFields(t *testing.T) {
+	d := (&Document{Title: "empty"}).Clone()
+	if d.Tags != nil || d.Meta != nil || d.Author != nil || d.Sections != nil {
+		t.Errorf("nil fields should stay nil: %+v", d)
+	}
+	if (*Document)(nil).Clone() != nil {
+		t.Errorf("clone of nil should be nil")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry[*Document]()
+	proto := sample()
+	r.Register("guide", proto)
+	proto.Title = "edited after registering"
+
+	a, err := r.New("guide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Tags[0] = "published"
+	b, _ := r.New("guide")
+	if b.Title != "Guide" || b.Tags[0] != "draft" {
+		t.Errorf("registry copies are not independent: %+v", b)
+	}
+	if _, err := r.New("missing"); !errors.Is(err, ErrUnknownPrototype) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func ExampleRegistry() {
+	r := NewRegistry[*Document]()
+	r.Register("report", &Document{Title: "Weekly report", Tags: []string{"team"}})
+
+	doc, _ := r.New("report")
+	doc.Tags = append(doc.Tags, "week-42")
+	fresh, _ := r.New("report")
+	fmt.Println(doc.Tags, fresh.Tags)
+	// Output: [team week-42] [team]
+}
@@ -0,0 +1,134 @@
+// Package resourcepool is the object pool of creational/object-pool.md
+// grown up for resources that are expensive to open and must be bounded,
+// such as database or network connections.
+//
+// Resources are created lazily by a factory up to a fixed maximum and kept
+// in a buffered channel while idle. Acquire waits for a resource with a
+// context, Release puts it back, Discard throws a broken one away to make
+// room for a fresh one, and Close destroys them all.
+//
+// sync.Pool solves a different problem: it caches garbage-collectable
+// buffers to save allocations, may drop them at any GC, and never bounds
+// how many exist. It is the wrong tool when every resource holds a socket.
+package resourcepool
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned by Acquire once the pool is closed.
+var ErrClosed = errors.New("resourcepool: pool is closed")
+
+// Factory opens a new resource.
+type Factory[T any] func(ctx context.Context) (T, error)
+
+// Pool is a bounded pool of reusable resources.
+type Pool[T any] struct {
+	factory Factory[T]
+	destroy func(T)
+
+	idle  chan T
+	slots chan struct{} // one token per open resource
+	done  chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// New returns a pool of at most size resources opened by factory. destroy,
+// if not nil, is called for every resource the pool lets go of.
+func New[T any](size int, factory Factory[T], destroy func(T)) *Pool[T] {
+	return &Pool[T]{
+		factory: factory,
+		destroy: destroy,
+		idle:    make(chan T, size),
+		slots:   make(chan struct{}, size),
+		done:    make(chan struct{}),
+	}
+}
+
+// Acquire returns an idle resource, opens a new one if the pool is not yet
+// full, or waits for one to be released. It fails with ctx.Err() or
+// ErrClosed.
+func (p *Pool[T]) Acquire(ctx context.Context) (T, error) {
+	var zero T
+	// Prefer an idle resource over opening a new one.
+	select {
+	case r := <-p.idle:
+		return r, nil
+	default:
+	}
+	select {
+	case r := <-p.idle:
+		return r, nil
+	case p.slots <- struct{}{}:
+		r, err := p.factory(ctx)
+		if err != nil {
+			<-p.slots
+			return zero, err
+		}
+		return r, nil
+	case <-p.done:
+		return zero, ErrClosed
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// Release returns r to the pool. After Close it destroys r instead.
+func (p *Pool[T]) Release(r T) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.drop(r)
+		return
+	}
+	// Never blocks: there are never more resources than slots.
+	p.idle <- r
+}
+
+// Discard destroys r instead of returning it, making room for a new one.
+// Use it for resources found broken.
+func (p *Pool[T]) Discard(r T) {
+	p.drop(r)
+}
+
+func (p *Pool[T]) drop(r T) {
+	if p.destroy != nil {
+		p.destroy(r)
+	}
+	<-p.slots
+}
+
+// Open returns the number of resources currently open, idle or in use.
+func (p *Pool[T]) Open() int {
+	return len(p.slots)
+}
+
+// Idle returns the number of idle resources.
+func (p *Pool[T]) Idle() int {
+	return len(p.idle)
+}
+
+// Close destroys the idle resources and makes waiting and future Acquire
+// calls fail with ErrClosed. Resources in use are destroyed when they are
+// released.
+func (p *Pool[T]) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.done)
+	for {
+		select {
+		case r := <-p.idle:
+			p.drop(r)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package resourcepool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type conn struct {
+	id     int
+	closed bool
+}
+
+func connFactory(opened *int32) Factory[*conn] {
+	return func(context.Context) (*conn, error) {
+		return &conn{id: int(atomic.AddInt32(opened, 1))}, nil
+	}
+}
+
+func TestBounded(t *testing.T) {
+	var opened int32
+	p := New(2, connFactory(&opened), nil)
+	ctx := context.Background()
+
+	a, _ := p.Acquire(ctx)
+	b, _ := p.Acquire(ctx)
+	if p.Open() != 2 || a == b {
+		t.Fatalf("expected two distinct connections, open=%d", p.Open())
+	}
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Acquire(short); err != context.DeadlineExceeded {
+		t.Errorf("a full pool should make Acquire wait, got %v", err)
+	}
+
+	got := make(chan *conn)
+	go func() {
+		c, _ := p.Acquire(ctx)
+		got <- c
+	}()
+	p.Release(a)
+	if c := <-got; c != a {
+		t.Error("the waiter should get the released connection")
+	}
+	if opened != 2 {
+		t.Errorf("opened %d connections, want 2", opened)
+	}
+}
+
+func TestReuse(t *testing.T) {
+	var opened int32
+	p := New(4, connFactory(&opened), nil)
+	for i := 0; i < 10; i++ {
+		c, _ := p.Acquire(context.Background())
+		p.Release(c)
+	}
+	if opened != 1 {
+		t.Errorf("sequential use opened %d connections, want 1", opened)
+	}
+}
+
+func TestDiscardAndFactoryError(t *testing.T) {
+	var opened int32
+	p := New(1, connFactory(&opened), func(c *conn) { c.closed = true })
+	c, _ := p.Acquire(context.Background())
+	p.Discard(c)
+	if !c.closed || p.Open() != 0 {
+		t.Errorf("discard should destroy and free the slot, open=%d", p.Open())
+	}
+
+	errDial := errors.New("dial failed")
+	failing := New(1, func(context.Context) (*conn, error) { return nil, errDial }, nil)
+	for i := 0; i < 3; i++ {
+		if _, err := failing.Acquire(context.Background()); err != errDial {
+			t.Fatalf("got %v", err)
+		}
+	}
+	if failing.Open() != 0 {
+		t.Error("a failed open must not use up a slot")
+	}
+}
+
+func TestClose(t *testing.T) {
+	var opened int32
+	var mu sync.Mutex
+	var destroyed []int
+	p := New(2, connFactory(&opened), func(c *conn) {
+		mu.Lock()
+		destroyed = append(destroyed, c.id)
+		mu.Unlock()
+	})
+	ctx := context.Background()
+	a, _ := p.Acquire(ctx)
+	b, _ := p.Acquire(ctx)
+	p.Release(a)
+
+	waiting := make(chan error)
+	go func() {
+		p.Acquire(ctx) // takes a
+		_, err := p.Acquire(ctx)
+		waiting <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Close()
+	if err := <-waiting; err != ErrClosed {
+		t.Errorf("waiting Acquire got %v, want ErrClosed", err)
+	}
+	p.Release(b)
+	p.Release(a)
+	if len(destroyed) != 2 || p.Open() != 0 {
+		t.Errorf("destroyed %v, open %d", destroyed, p.Open())
+	}
+}
+
+func BenchmarkResourcePool(b *testing.B) {
+	p := New(64, func(context.Context) (*bytes.Buffer, error) { return new(bytes.Buffer), nil }, nil)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buf, _ := p.Acquire(ctx)
+			buf.WriteString("x")
+			buf.Reset()
+			p.Release(buf)
+		}
+	})
+}
+
+func BenchmarkSyncPool(b *testing.B) {
+	p := sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buf := p.Get().(*bytes.Buffer)
+			buf.WriteString("x")
+			buf.Reset()
+			p.Put(buf)
+		}
+	})
+}
+
+// Example simulates eight goroutines querying a database through a pool
+// of three connections: no more than three are ever opened.
+func Example() {
+	var opened int32
+	p := New(3, func(ctx context.Context) (*conn, error) {
+		time.Sleep(time.Millisecond) // dialing is slow
+		return &conn{id: int(atomic.AddInt32(&opened, 1))}, nil
+	}, func(c *conn) { c.closed = true })
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	var queries int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				c, err := p.Acquire(context.Background())
+				if err != nil {
+					return
+				}
+				atomic.AddInt32(&queries, 1) // run a query on c
+				p.Release(c)
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Println("queries:", queries, "at most 3 connections:", opened <= 3)
+	// Output: queries: 80 at most 3 connections: true
+}
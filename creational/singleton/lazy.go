@@ -0,0 +1,31 @@
+package singleton
+
+import "sync"
+
+// Lazy is a value that is computed on first use and memoized. It is safe
+// for concurrent use; init runs at most once.
+//
+// If init panics, the panic reaches the first caller and every later Get
+// returns the zero value, as with sync.Once. sync.OnceValue is the standard
+// library version of the same idea.
+type Lazy[T any] struct {
+	once  sync.Once
+	init  func() T
+	value T
+}
+
+// NewLazy returns a Lazy that computes its value with init.
+func NewLazy[T any](init func() T) *Lazy[T] {
+	return &Lazy[T]{init: init}
+}
+
+// Get returns the value, computing it on the first call. Concurrent first
+// callers block until it is ready.
+func (l *Lazy[T]) Get() T {
+	l.once.Do(func() {
+		l.value = l.init()
+		// Let the closure and whatever it captured be collected.
+		l.init = nil
+	})
+	return l.value
+}
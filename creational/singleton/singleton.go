@@ -1,23 +1,38 @@
+// Package singleton restricts a type to one instance that is created on
+// first use.
+//
+// Checking for nil and creating the instance is a data race when two
+// goroutines get there at once, and both may end up with an instance of
+// their own. sync.Once runs the initialisation exactly once and makes every
+// caller wait for it to finish. Lazy wraps the idiom for any type.
 package singleton
 
+import "sync"
+
 type Singleton interface {
 	AddOne() int
 }
 
 type singleton struct {
+	mu    sync.Mutex
 	count int
 }
 
 func (s *singleton) AddOne() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.count++
 	return s.count
 }
 
-var instance *singleton
+var (
+	once     sync.Once
+	instance *singleton
+)
 
 func GetInstance() Singleton {
-	if instance == nil {
+	once.Do(func() {
 		instance = new(singleton)
-	}
+	})
 	return instance
 }
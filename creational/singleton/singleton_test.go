@@ -0,0 +1,87 @@
+package singleton
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetInstanceConcurrent(t *testing.T) {
+	const n = 100
+	// The instance lives as long as the process, so count from where an
+	// earlier run, as with -count, left it.
+	before := GetInstance().AddOne()
+	instances := make([]Singleton, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			instances[i] = GetInstance()
+			instances[i].AddOne()
+		}()
+	}
+	wg.Wait()
+
+	for _, s := range instances {
+		if s != instances[0] {
+			t.Fatal("GetInstance returned different instances")
+		}
+	}
+	if got := GetInstance().AddOne(); got != before+n+1 {
+		t.Errorf("count = %d, want %d", got, before+n+1)
+	}
+}
+
+func TestLazyRunsOnce(t *testing.T) {
+	var calls atomic.Int32
+	l := NewLazy(func() map[string]int {
+		calls.Add(1)
+		return map[string]int{"answer": 42}
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l.Get()["answer"] != 42 {
+				t.Error("Get returned before init finished")
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("init ran %d times", calls.Load())
+	}
+}
+
+func TestLazyPanic(t *testing.T) {
+	l := NewLazy(func() int { panic("boom") })
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the first Get should see the panic")
+			}
+		}()
+		l.Get()
+	}()
+	if got := l.Get(); got != 0 {
+		t.Errorf("Get after a panic = %d, want the zero value", got)
+	}
+}
+
+func ExampleLazy() {
+	config := NewLazy(func() string {
+		fmt.Println("loading config")
+		return "debug=true"
+	})
+	fmt.Println(config.Get())
+	fmt.Println(config.Get())
+	// Output:
+	// loading config
+	// debug=true
+	// debug=true
+}
@@ -1,12 +1,31 @@
 module github.com/crazybber/go-patterns
 
-go 1.14
+go 1.23
 
 require (
 	github.com/davecgh/go-spew v1.1.1
+	github.com/google/uuid v1.6.0
 	github.com/graphql-go/graphql v0.7.9
 	github.com/labstack/gommon v0.3.0
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/stretchr/testify v1.5.1
 	github.com/urfave/cli v1.22.4
 	go.uber.org/zap v1.15.0
+	golang.org/x/sync v0.10.0
+)
+
+require (
+	github.com/cpuguy83/go-md2man/v2 v2.0.0-20190314233015-f79a8a8ca69d // indirect
+	github.com/mattn/go-colorable v0.1.2 // indirect
+	github.com/mattn/go-isatty v0.0.9 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/russross/blackfriday/v2 v2.0.1 // indirect
+	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
+	github.com/stretchr/objx v0.1.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.0.1 // indirect
+	go.uber.org/atomic v1.6.0 // indirect
+	go.uber.org/multierr v1.5.0 // indirect
+	golang.org/x/sys v0.0.0-20190813064441-fde4db37ae7a // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
@@ -0,0 +1,172 @@
+// Package callback2chan turns callback APIs into channels and iterators,
+// and back.
+//
+// Many libraries deliver values by calling a function you hand them: an
+// event handler, a visitor, an onData/onError pair. Go code composes better
+// with channels, select, context and range, but the conversion has traps.
+// The library calls from its own goroutine, so a channel send there can
+// block it forever once nobody reads. Closing the channel while a callback
+// is sending panics. Forgetting to unsubscribe leaks the library's
+// goroutine, and an error reported through a second callback is easily
+// lost.
+//
+// A Source describes the callback API once. Chan and Seq consume it with a
+// context, so the subscription ends, the library is told to stop, and the
+// channel is closed exactly once, whatever the order of events. Subscribe
+// and FromSeq go the other way, for APIs that must offer callbacks.
+package callback2chan
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// Source is the shape of a callback API. It starts delivering, calling
+// emit for each value and then done once with the final error, nil when it
+// ran out of values. It returns stop, which ends the delivery early or
+// releases the subscription after done; once stop has returned it makes no
+// more calls.
+//
+// Most libraries take a little glue to fit this, see the package example.
+type Source[T any] func(emit func(T), done func(error)) (stop func())
+
+// Chan subscribes to src and delivers its values on the returned channel,
+// which holds up to buffer values. When src is done or ctx is cancelled the
+// channel is closed; the error function then returns src's error or
+// ctx.Err().
+//
+// A full channel blocks the callback, and so the library, until the
+// consumer catches up or ctx is cancelled. The consumer must read until the
+// channel is closed or cancel ctx.
+func Chan[T any](ctx context.Context, src Source[T], buffer int) (<-chan T, func() error) {
+	out := make(chan T, buffer)
+	closed := make(chan struct{})
+	var (
+		// mu keeps emit from sending on out while it is being closed.
+		mu     sync.RWMutex
+		ended  bool
+		quit   = make(chan struct{})
+		once   sync.Once
+		result error
+	)
+	finish := func(err error) {
+		once.Do(func() {
+			result = err
+			close(quit)
+		})
+	}
+	emit := func(v T) {
+		mu.RLock()
+		defer mu.RUnlock()
+		if ended {
+			return
+		}
+		select {
+		case out <- v:
+		case <-quit:
+		}
+	}
+
+	stop := src(emit, finish)
+	go func() {
+		select {
+		case <-quit:
+		case <-ctx.Done():
+			finish(ctx.Err())
+		}
+		// quit is closed, so a blocked emit has returned and cannot
+		// hold up stop.
+		stop()
+		mu.Lock()
+		ended = true
+		close(out)
+		mu.Unlock()
+		close(closed)
+	}()
+	return out, func() error {
+		<-closed
+		return result
+	}
+}
+
+// Seq returns an iterator over the values of src. A final pair carries the
+// error of src or of ctx, if any. Breaking out of the loop stops src.
+func Seq[T any](ctx context.Context, src Source[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		values, wait := Chan(ctx, src, 0)
+		for v := range values {
+			if !yield(v, nil) {
+				cancel()
+				for range values {
+				}
+				return
+			}
+		}
+		if err := wait(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// Subscribe calls handler for each value received from ch, on a goroutine
+// of its own, until ch is closed or unsubscribe is called. Once unsubscribe
+// has returned, handler is not running and will not be called again, so it
+// must not be called from handler itself.
+func Subscribe[T any](ch <-chan T, handler func(T)) (unsubscribe func()) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok {
+					return
+				}
+				handler(v)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stop) })
+		<-done
+	}
+}
+
+// FromSeq offers an iterator as a Source, for code that expects callbacks.
+// The iterator runs on a goroutine of its own; a non-nil error from it
+// ends the delivery and is passed to done.
+func FromSeq[T any](seq iter.Seq2[T, error]) Source[T] {
+	return func(emit func(T), done func(error)) func() {
+		stop := make(chan struct{})
+		exited := make(chan struct{})
+		go func() {
+			defer close(exited)
+			for v, err := range seq {
+				if err != nil {
+					done(err)
+					return
+				}
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				emit(v)
+			}
+			done(nil)
+		}()
+		var once sync.Once
+		return func() {
+			once.Do(func() { close(stop) })
+			<-exited
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package callback2chan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// noLeaks fails the test if goroutines started during it are still
+// running at the end.
+func noLeaks(t *testing.T) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		if n := runtime.NumGoroutine(); n > before {
+			t.Errorf("%d goroutines leaked", n-before)
+		}
+	})
+}
+
+// feed is shaped like a third-party event client: handlers are registered
+// and removed by id, and called on the feed's own goroutine. It sends the
+// given ticks, then reports err, or nothing when err is nil, to its error
+// handlers. With ticks empty it sends forever.
+type feed struct {
+	ticks []int
+	err   error
+
+	mu      sync.Mutex
+	onTick  map[int]func(int)
+	onErr   map[int]func(error)
+	next    int
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func newFeed(err error, ticks ...int) *feed {
+	return &feed{ticks: ticks, err: err, onTick: map[int]func(int){}, onErr: map[int]func(error){}}
+}
+
+func (f *feed) OnTick(h func(int)) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	f.onTick[f.next] = h
+	return f.next
+}
+
+func (f *feed) OnError(h func(error)) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	f.onErr[f.next] = h
+	return f.next
+}
+
+// Off removes a handler. It does not wait for a call in progress.
+func (f *feed) Off(id int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.onTick, id)
+	delete(f.onErr, id)
+}
+
+func (f *feed) handlers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.onTick) + len(f.onErr)
+}
+
+func (f *feed) Start() {
+	f.stop, f.stopped = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(f.stopped)
+		for i := 0; len(f.ticks) == 0 || i < len(f.ticks); i++ {
+			select {
+			case <-f.stop:
+				return
+			default:
+			}
+			v := i
+			if len(f.ticks) > 0 {
+				v = f.ticks[i]
+			}
+			f.mu.Lock()
+			hs := make([]func(int), 0, len(f.onTick))
+			for _, h := range f.onTick {
+				hs = append(hs, h)
+			}
+			f.mu.Unlock()
+			for _, h := range hs {
+				h(v)
+			}
+		}
+		if f.err != nil {
+			f.mu.Lock()
+			for _, h := range f.onErr {
+				h(f.err)
+			}
+			f.mu.Unlock()
+		}
+	}()
+}
+
+func (f *feed) Stop() {
+	close(f.stop)
+	<-f.stopped
+}
+
+// source is the glue that fits the feed to a Source.
+func (f *feed) source() Source[int] {
+	return func(emit func(int), done func(error)) func() {
+		tick := f.OnTick(emit)
+		errs := f.OnError(done)
+		f.Start()
+		go func() {
+			<-f.stopped
+			// The feed has ended on its own; a nil err means no error
+			// callback came.
+			done(nil)
+		}()
+		return func() {
+			f.Off(tick)
+			f.Off(errs)
+			select {
+			case <-f.stop:
+			default:
+				f.Stop()
+			}
+		}
+	}
+}
+
+func TestChan(t *testing.T) {
+	noLeaks(t)
+	f := newFeed(nil, 1, 2, 3, 4, 5)
+	values, wait := Chan(context.Background(), f.source(), 2)
+	var got []int
+	for v := range values {
+		got = append(got, v)
+	}
+	if err := wait(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("got %v", got)
+	}
+	if f.handlers() != 0 {
+		t.Errorf("%d handlers still registered", f.handlers())
+	}
+}
+
+func TestChanError(t *testing.T) {
+	noLeaks(t)
+	boom := errors.New("connection reset")
+	values, wait := Chan(context.Background(), newFeed(boom, 1, 2).source(), 0)
+	var n int
+	for range values {
+		n++
+	}
+	if err := wait(); !errors.Is(err, boom) || n != 2 {
+		t.Errorf("got %d values and %v, want 2 and %v", n, err, boom)
+	}
+}
+
+// TestChanCancel stops reading from an endless feed. Cancelling must
+// unblock the feed's goroutine, remove the handlers and close the channel.
+func TestChanCancel(t *testing.T) {
+	noLeaks(t)
+	f := newFeed(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	values, wait := Chan(ctx, f.source(), 0)
+	for v := range values {
+		if v == 3 {
+			break
+		}
+	}
+	// The feed is now blocked handing over tick 4.
+	cancel()
+	if err := wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if f.handlers() != 0 {
+		t.Errorf("%d handlers still registered", f.handlers())
+	}
+	for range values {
+	}
+}
+
+// TestLateCallbacks uses a source that ignores stop and keeps calling
+// emit and done after the channel has been closed.
+func TestLateCallbacks(t *testing.T) {
+	noLeaks(t)
+	finished := make(chan struct{})
+	rogue := func(emit func(int), done func(error)) func() {
+		go func() {
+			defer close(finished)
+			for i := 0; i < 1000; i++ {
+				emit(i)
+				if i == 10 {
+					done(nil)
+				}
+			}
+			done(errors.New("ignored"))
+		}()
+		return func() {}
+	}
+	values, wait := Chan(context.Background(), Source[int](rogue), 4)
+	for range values {
+	}
+	if err := wait(); err != nil {
+		t.Errorf("err = %v, want the first done to win", err)
+	}
+	<-finished
+}
+
+func TestSeq(t *testing.T) {
+	noLeaks(t)
+	boom := errors.New("boom")
+	var got []int
+	var gotErr error
+	for v, err := range Seq(context.Background(), newFeed(boom, 1, 2, 3).source()) {
+		if err != nil {
+			gotErr = err
+			break
+		}
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{1, 2, 3}) || !errors.Is(gotErr, boom) {
+		t.Errorf("got %v, %v", got, gotErr)
+	}
+
+	f := newFeed(nil)
+	for v := range Seq(context.Background(), f.source()) {
+		if v == 5 {
+			break
+		}
+	}
+	if f.handlers() != 0 {
+		t.Errorf("break left %d handlers registered", f.handlers())
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	noLeaks(t)
+	ch := make(chan int)
+	var calls atomic.Int64
+	unsubscribe := Subscribe(ch, func(int) { calls.Add(1) })
+	ch <- 1
+	ch <- 2
+	unsubscribe()
+	n := calls.Load()
+	select {
+	case ch <- 3:
+		t.Error("a value was received after unsubscribe")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if n != 2 || calls.Load() != 2 {
+		t.Errorf("handler called %d times, want 2", calls.Load())
+	}
+	unsubscribe()
+
+	// Closing the channel ends the subscription as well.
+	ch2 := make(chan int, 1)
+	ch2 <- 1
+	close(ch2)
+	Subscribe(ch2, func(int) {})()
+}
+
+func numbers(n int, err error) iter.Seq2[int, error] {
+	return func(yield func(int, error) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(0, err)
+		}
+	}
+}
+
+func TestFromSeq(t *testing.T) {
+	noLeaks(t)
+	boom := errors.New("boom")
+	values, wait := Chan(context.Background(), FromSeq(numbers(5, boom)), 0)
+	var got []int
+	for v := range values {
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{0, 1, 2, 3, 4}) || !errors.Is(wait(), boom) {
+		t.Errorf("got %v, %v", got, wait())
+	}
+
+	// Stopping an endless iterator ends its goroutine.
+	ctx, cancel := context.WithCancel(context.Background())
+	values, wait = Chan(ctx, FromSeq(numbers(1<<62, nil)), 0)
+	<-values
+	cancel()
+	if !errors.Is(wait(), context.Canceled) {
+		t.Errorf("err = %v", wait())
+	}
+}
+
+// Example adapts a visitor-style API, which calls fn synchronously for
+// every item, so that it can be ranged over.
+func Example() {
+	walk := func(fn func(name string) error) error {
+		for _, name := range []string{"a.go", "b.go", "c.go"} {
+			if err := fn(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	errStopped := errors.New("stopped")
+	src := func(emit func(string), done func(error)) func() {
+		stop := make(chan struct{})
+		exited := make(chan struct{})
+		go func() {
+			defer close(exited)
+			err := walk(func(name string) error {
+				select {
+				case <-stop:
+					return errStopped
+				default:
+				}
+				emit(name)
+				return nil
+			})
+			done(err)
+		}()
+		return func() {
+			select {
+			case <-stop:
+			default:
+				close(stop)
+			}
+			<-exited
+		}
+	}
+
+	for name, err := range Seq(context.Background(), Source[string](src)) {
+		fmt.Println(name, err)
+	}
+	// Output:
+	// a.go <nil>
+	// b.go <nil>
+	// c.go <nil>
+}
@@ -0,0 +1,248 @@
+// Package clock makes time injectable. Code that reads the time or waits
+// for it through a Clock can be given Real in production and a Fake in
+// tests, where time stands still until the test moves it, so a test of a
+// ten-minute backoff runs in microseconds and never depends on how busy
+// the machine is.
+//
+// The packages that wait for time declare the small part of Clock they
+// use, as scheduler.Clock and retry.Clock do, and both Real and *Fake
+// satisfy all of them.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time.
+type Clock interface {
+	Now() time.Time
+	// After is time.After.
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc is time.AfterFunc.
+	AfterFunc(d time.Duration, f func()) Timer
+	// NewTicker is time.NewTicker.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the part of *time.Timer returned by AfterFunc.
+type Timer interface {
+	Stop() bool
+}
+
+// Ticker is a *time.Ticker whose channel is a method.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the wall clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                            { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time    { return time.After(d) }
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+func (realClock) NewTicker(d time.Duration) Ticker          { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// Fake is a Clock that only moves when told to. Channels of After and
+// tickers receive, and AfterFunc callbacks run, from Advance, in the order
+// they fall due; while one fires, Now returns the time it was due.
+// AfterFunc callbacks run on the goroutine that called Advance, not on one
+// of their own as with time.AfterFunc.
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	seq     int
+	pending []*fakeTimer
+}
+
+// fakeTimer is anything waiting on a Fake. A ticker stays pending after
+// it fires, due again one period later.
+type fakeTimer struct {
+	clock  *Fake
+	at     time.Time
+	seq    int // breaks ties in the order timers were set
+	period time.Duration
+	fire   func(now time.Time)
+	c      chan time.Time
+}
+
+// NewFake returns a fake clock that reads start.
+func NewFake(start time.Time) *Fake {
+	f := &Fake{now: start}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the fake time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Since returns the fake time elapsed since t.
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) add(d, period time.Duration, fire func(time.Time), c chan time.Time) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	t := &fakeTimer{clock: f, at: f.now.Add(d), seq: f.seq, period: period, fire: fire, c: c}
+	f.pending = append(f.pending, t)
+	f.cond.Broadcast()
+	return t
+}
+
+// send delivers now the way the runtime does for timers: it drops the
+// value when the one before has not been received yet.
+func send(c chan time.Time) func(time.Time) {
+	return func(now time.Time) {
+		select {
+		case c <- now:
+		default:
+		}
+	}
+}
+
+// After returns a channel that receives the fake time once d has been
+// advanced past.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	c := make(chan time.Time, 1)
+	f.add(d, 0, send(c), c)
+	return c
+}
+
+// AfterFunc calls fn from Advance once d has been advanced past.
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	return f.add(d, 0, func(time.Time) { fn() }, nil)
+}
+
+// NewTicker returns a ticker that ticks every d of fake time. It panics if
+// d is not positive, as time.NewTicker does.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	c := make(chan time.Time, 1)
+	return fakeTicker{f.add(d, d, send(c), c)}
+}
+
+// Stop cancels the timer and reports whether it was still pending.
+func (t *fakeTimer) Stop() bool {
+	f := t.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, p := range f.pending {
+		if p == t {
+			f.pending = append(f.pending[:i], f.pending[i+1:]...)
+			f.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTicker struct{ t *fakeTimer }
+
+func (t fakeTicker) C() <-chan time.Time { return t.t.c }
+func (t fakeTicker) Stop()               { t.t.Stop() }
+
+// sort orders the pending timers by when they are due. f.mu must be held.
+func (f *Fake) sort() {
+	sort.Slice(f.pending, func(i, j int) bool {
+		a, b := f.pending[i], f.pending[j]
+		if !a.at.Equal(b.at) {
+			return a.at.Before(b.at)
+		}
+		return a.seq < b.seq
+	})
+}
+
+// next removes and returns the earliest timer due by end, rescheduling it
+// if it is a ticker, and moves the clock to its time. f.mu must be held.
+func (f *Fake) next(end time.Time) *fakeTimer {
+	if len(f.pending) == 0 {
+		return nil
+	}
+	f.sort()
+	t := f.pending[0]
+	if t.at.After(end) {
+		return nil
+	}
+	f.now = t.at
+	if t.period > 0 {
+		t.at = t.at.Add(t.period)
+	} else {
+		f.pending = f.pending[1:]
+		f.cond.Broadcast()
+	}
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer that falls due
+// on the way, including those set by the callbacks it runs.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	end := f.now.Add(d)
+	for {
+		t := f.next(end)
+		if t == nil {
+			break
+		}
+		now := f.now
+		f.mu.Unlock()
+		t.fire(now)
+		f.mu.Lock()
+	}
+	f.now = end
+	f.mu.Unlock()
+}
+
+// AdvanceToNext moves the clock to the earliest pending timer, fires it,
+// and returns how far the clock moved. It returns 0 when nothing is
+// pending.
+func (f *Fake) AdvanceToNext() time.Duration {
+	f.mu.Lock()
+	start := f.now
+	if len(f.pending) == 0 {
+		f.mu.Unlock()
+		return 0
+	}
+	f.sort()
+	t := f.next(f.pending[0].at)
+	now := f.now
+	f.mu.Unlock()
+	t.fire(now)
+	return now.Sub(start)
+}
+
+// Pending returns the number of timers, tickers and After channels
+// waiting on the clock.
+func (f *Fake) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.pending)
+}
+
+// BlockUntil waits until at least n timers are pending, which is how a
+// test knows that the goroutine it drives has started waiting before it
+// advances the clock.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.pending) < n {
+		f.cond.Wait()
+	}
+}
@@ -0,0 +1,129 @@
+package clock
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func received(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestAfter(t *testing.T) {
+	f := NewFake(epoch)
+	c := f.After(time.Minute)
+	f.Advance(59 * time.Second)
+	if _, ok := received(c); ok {
+		t.Fatal("fired early")
+	}
+	f.Advance(2 * time.Second)
+	if at, ok := received(c); !ok || !at.Equal(epoch.Add(time.Minute)) {
+		t.Errorf("received %v, %v; want the due time", at, ok)
+	}
+	if got := f.Since(epoch); got != 61*time.Second {
+		t.Errorf("Since = %v", got)
+	}
+	if f.Pending() != 0 {
+		t.Errorf("%d timers left", f.Pending())
+	}
+}
+
+func TestAfterFuncOrder(t *testing.T) {
+	f := NewFake(epoch)
+	var log []string
+	at := func(name string) func() {
+		return func() { log = append(log, fmt.Sprint(name, "@", f.Since(epoch))) }
+	}
+	f.AfterFunc(3*time.Second, at("c"))
+	f.AfterFunc(time.Second, at("a"))
+	f.AfterFunc(time.Second, at("b"))
+	// A callback can set a timer that falls due within the same Advance.
+	f.AfterFunc(2*time.Second, func() { f.AfterFunc(500*time.Millisecond, at("d")) })
+	stopped := f.AfterFunc(2*time.Second, at("never"))
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Stop should report true once")
+	}
+	f.Advance(10 * time.Second)
+	if got, want := fmt.Sprint(log), "[a@1s b@1s d@2.5s c@3s]"; got != want {
+		t.Errorf("fired %s, want %s", got, want)
+	}
+}
+
+func TestTicker(t *testing.T) {
+	f := NewFake(epoch)
+	tk := f.NewTicker(time.Second)
+	f.Advance(time.Second)
+	if at, ok := received(tk.C()); !ok || !at.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("first tick %v, %v", at, ok)
+	}
+	// Ticks nobody receives are dropped, as with time.Ticker.
+	f.Advance(5 * time.Second)
+	if at, _ := received(tk.C()); !at.Equal(epoch.Add(2 * time.Second)) {
+		t.Errorf("kept tick %v", at)
+	}
+	if _, ok := received(tk.C()); ok {
+		t.Error("more than one tick buffered")
+	}
+	tk.Stop()
+	f.Advance(time.Hour)
+	if _, ok := received(tk.C()); ok {
+		t.Error("tick after Stop")
+	}
+}
+
+func TestAdvanceToNext(t *testing.T) {
+	f := NewFake(epoch)
+	if d := f.AdvanceToNext(); d != 0 {
+		t.Errorf("nothing pending: moved %v", d)
+	}
+	c := f.After(90 * time.Second)
+	if d := f.AdvanceToNext(); d != 90*time.Second {
+		t.Errorf("moved %v", d)
+	}
+	if _, ok := received(c); !ok {
+		t.Error("timer did not fire")
+	}
+}
+
+func TestBlockUntil(t *testing.T) {
+	f := NewFake(epoch)
+	done := make(chan time.Time)
+	go func() { done <- <-f.After(time.Hour) }()
+	f.BlockUntil(1)
+	f.Advance(time.Hour)
+	if at := <-done; !at.Equal(epoch.Add(time.Hour)) {
+		t.Errorf("woke at %v", at)
+	}
+}
+
+func TestReal(t *testing.T) {
+	start := Real.Now()
+	<-Real.After(time.Millisecond)
+	fired := make(chan struct{})
+	Real.AfterFunc(time.Millisecond, func() { close(fired) })
+	<-fired
+	tk := Real.NewTicker(time.Millisecond)
+	<-tk.C()
+	tk.Stop()
+	if time.Since(start) < 2*time.Millisecond {
+		t.Error("the real clock did not wait")
+	}
+}
+
+func ExampleFake() {
+	f := NewFake(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	f.AfterFunc(time.Hour, func() { fmt.Println("reminder at", f.Now().Format("15:04")) })
+	f.Advance(3 * time.Hour)
+	fmt.Println("now", f.Now().Format("15:04"))
+	// Output:
+	// reminder at 10:00
+	// now 12:00
+}
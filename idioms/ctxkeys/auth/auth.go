@@ -0,0 +1,27 @@
+// Package auth carries the authenticated principal through a context
+// using a generic ctxkeys.Key.
+package auth
+
+import (
+	"context"
+
+	"github.com/crazybber/go-patterns/idioms/ctxkeys"
+)
+
+// Principal is the caller a request is made on behalf of.
+type Principal struct {
+	ID    string
+	Roles []string
+}
+
+var principalKey = ctxkeys.NewKey[Principal]("auth.principal")
+
+// With returns a copy of ctx carrying p.
+func With(ctx context.Context, p Principal) context.Context {
+	return principalKey.WithValue(ctx, p)
+}
+
+// Get returns the principal in ctx and whether there was one.
+func Get(ctx context.Context) (Principal, bool) {
+	return principalKey.Get(ctx)
+}
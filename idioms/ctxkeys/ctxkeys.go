@@ -0,0 +1,61 @@
+// Package ctxkeys shows how to keep context.Value type safe.
+//
+// context.WithValue compares keys with ==, so two packages that both use
+// the string "user" as a key silently overwrite each other, and every
+// reader has to type-assert the interface{} it gets back. The cure is a key
+// whose type nobody else can name, plus an accessor that does the type
+// assertion once:
+//
+//   - A package that owns a value declares an unexported key type and
+//     exports only With and From style functions; see the requestid
+//     subpackage.
+//   - Key does the same generically: every NewKey call returns a distinct
+//     key, and Get hands back a T instead of an interface{}; see the auth
+//     subpackage.
+//
+// The analyzer in analysis/ctxkey reports calls to context.WithValue whose
+// key has a built-in type such as string.
+package ctxkeys
+
+import (
+	"context"
+	"fmt"
+)
+
+// Key is a typed context key. Keys are compared by identity, so two keys
+// with the same name never collide.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns a new key. The name is only used for printing.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// WithValue returns a copy of ctx carrying v under k.
+func (k *Key[T]) WithValue(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, k, v)
+}
+
+// Get returns the value stored under k and whether there was one.
+func (k *Key[T]) Get(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
+
+// MustGet is like Get but panics if ctx carries no value for k. Use it
+// only where a missing value is a programming error, such as behind a
+// middleware that always sets it.
+func (k *Key[T]) MustGet(ctx context.Context) T {
+	v, ok := k.Get(ctx)
+	if !ok {
+		panic(fmt.Sprintf("ctxkeys: no value for key %s in context", k.name))
+	}
+	return v
+}
+
+// String returns the key name in the form used by context's String methods.
+func (k *Key[T]) String() string {
+	return "ctxkeys.Key(" + k.name + ")"
+}
@@ -0,0 +1,70 @@
+package ctxkeys_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/crazybber/go-patterns/idioms/ctxkeys"
+	"github.com/crazybber/go-patterns/idioms/ctxkeys/auth"
+	"github.com/crazybber/go-patterns/idioms/ctxkeys/requestid"
+)
+
+func TestStringKeysCollide(t *testing.T) {
+	// Two packages that independently picked "id" as their key.
+	ctx := context.WithValue(context.Background(), "id", "request-42")
+	ctx = context.WithValue(ctx, "id", 7)
+	if _, ok := ctx.Value("id").(string); ok {
+		t.Fatal("expected the second string key to shadow the first")
+	}
+}
+
+func TestKeysDoNotCollide(t *testing.T) {
+	request := ctxkeys.NewKey[string]("id")
+	user := ctxkeys.NewKey[int]("id")
+
+	ctx := request.WithValue(context.Background(), "request-42")
+	ctx = user.WithValue(ctx, 7)
+
+	if v, ok := request.Get(ctx); !ok || v != "request-42" {
+		t.Errorf("request id = %q, %v", v, ok)
+	}
+	if v, ok := user.Get(ctx); !ok || v != 7 {
+		t.Errorf("user id = %d, %v", v, ok)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	k := ctxkeys.NewKey[string]("missing")
+	if _, ok := k.Get(context.Background()); ok {
+		t.Error("empty context reported a value")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustGet did not panic")
+		}
+	}()
+	k.MustGet(context.Background())
+}
+
+func TestPackages(t *testing.T) {
+	ctx := requestid.With(context.Background(), "r-1")
+	ctx = auth.With(ctx, auth.Principal{ID: "alice"})
+
+	if id, _ := requestid.Get(ctx); id != "r-1" {
+		t.Errorf("request id = %q", id)
+	}
+	if p, _ := auth.Get(ctx); p.ID != "alice" {
+		t.Errorf("principal = %+v", p)
+	}
+}
+
+func Example() {
+	ctx := requestid.With(context.Background(), "r-1")
+	ctx = auth.With(ctx, auth.Principal{ID: "alice", Roles: []string{"admin"}})
+
+	id, _ := requestid.Get(ctx)
+	p, ok := auth.Get(ctx)
+	fmt.Println(id, p.ID, p.Roles, ok)
+	// Output: r-1 alice [admin] true
+}
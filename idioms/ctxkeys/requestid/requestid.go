@@ -0,0 +1,21 @@
+// Package requestid carries a request id through a context using the
+// classic unexported key type. Nothing outside this package can build a
+// key, so nothing outside can read or clobber the value except through
+// With and Get.
+package requestid
+
+import "context"
+
+// key is unexported; its zero value is the only key ever used.
+type key struct{}
+
+// With returns a copy of ctx carrying id.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// Get returns the request id in ctx and whether there was one.
+func Get(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(key{}).(string)
+	return id, ok
+}
@@ -0,0 +1,143 @@
+package di
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Errors of the Container.
+var (
+	ErrBadConstructor = errors.New("di: not a constructor")
+	ErrDuplicate      = errors.New("di: type provided twice")
+	ErrNoProvider     = errors.New("di: no provider")
+	ErrCycle          = errors.New("di: dependency cycle")
+)
+
+var errorType = reflect.TypeFor[error]()
+
+// Container builds values from constructors, calling each at most once.
+// A constructor is a function returning one value, optionally followed by
+// an error; its parameters are the values it depends on, which the
+// Container builds first from the constructors returning their types.
+// It is safe for concurrent use.
+type Container struct {
+	mu        sync.Mutex
+	providers map[reflect.Type]reflect.Value
+	built     map[reflect.Type]reflect.Value
+}
+
+// NewContainer returns an empty Container.
+func NewContainer() *Container {
+	return &Container{providers: map[reflect.Type]reflect.Value{}, built: map[reflect.Type]reflect.Value{}}
+}
+
+// Provide registers constructor as the way to build the type it returns.
+// To provide a concrete value as an interface, wrap its constructor in a
+// function returning the interface.
+func (c *Container) Provide(constructor any) error {
+	fn := reflect.ValueOf(constructor)
+	if fn.Kind() != reflect.Func {
+		return fmt.Errorf("%w: %T", ErrBadConstructor, constructor)
+	}
+	t := fn.Type()
+	if t.IsVariadic() || t.NumOut() < 1 || t.NumOut() > 2 ||
+		(t.NumOut() == 2 && t.Out(1) != errorType) {
+		return fmt.Errorf("%w: %s", ErrBadConstructor, t)
+	}
+	out := t.Out(0)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.providers[out]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicate, out)
+	}
+	c.providers[out] = fn
+	return nil
+}
+
+// Invoke calls fn with its parameters built by the Container, and returns
+// the error fn returns, if it returns one.
+func (c *Container) Invoke(fn any) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Errorf("%w: %T", ErrBadConstructor, fn)
+	}
+	t := v.Type()
+	if t.NumOut() > 1 || (t.NumOut() == 1 && t.Out(0) != errorType) {
+		return fmt.Errorf("%w: %s", ErrBadConstructor, t)
+	}
+	c.mu.Lock()
+	args, err := c.args(t, nil)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if out := v.Call(args); len(out) == 1 && !out[0].IsNil() {
+		return out[0].Interface().(error)
+	}
+	return nil
+}
+
+// Resolve returns the value of type T built by c.
+func Resolve[T any](c *Container) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, err := c.build(reflect.TypeFor[T](), nil)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.Interface().(T), nil
+}
+
+// build returns the value of type t, calling its constructor if needed.
+// path holds the types being built, to report cycles.
+func (c *Container) build(t reflect.Type, path []reflect.Type) (reflect.Value, error) {
+	if v, ok := c.built[t]; ok {
+		return v, nil
+	}
+	for i, p := range path {
+		if p == t {
+			return reflect.Value{}, fmt.Errorf("%w: %s", ErrCycle, chain(append(path[i:], t)))
+		}
+	}
+	fn, ok := c.providers[t]
+	if !ok {
+		if len(path) == 0 {
+			return reflect.Value{}, fmt.Errorf("%w for %s", ErrNoProvider, t)
+		}
+		return reflect.Value{}, fmt.Errorf("%w for %s, needed by %s", ErrNoProvider, t, chain(path))
+	}
+	args, err := c.args(fn.Type(), append(path, t))
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	out := fn.Call(args)
+	if len(out) == 2 && !out[1].IsNil() {
+		return reflect.Value{}, fmt.Errorf("di: building %s: %w", t, out[1].Interface().(error))
+	}
+	c.built[t] = out[0]
+	return out[0], nil
+}
+
+func (c *Container) args(t reflect.Type, path []reflect.Type) ([]reflect.Value, error) {
+	args := make([]reflect.Value, t.NumIn())
+	for i := range args {
+		v, err := c.build(t.In(i), path)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+func chain(types []reflect.Type) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return strings.Join(names, " -> ")
+}
@@ -0,0 +1,146 @@
+// Package di shows dependency injection in Go three ways, on one small
+// signup service.
+//
+//   - Constructor injection: NewSignup takes the interfaces it depends on,
+//     Users, Mailer and Clock, as arguments. It never builds them, so a
+//     test hands it fakes and production hands it the real thing.
+//   - A composition root: Wire, in wire.go, is the one place that picks
+//     the implementations and calls the constructors in order. It is plain
+//     code, checked by the compiler, and reads top to bottom.
+//   - A container: Container, in container.go, is given constructors and
+//     calls them itself, matching their parameters to the types other
+//     constructors return by reflection.
+//
+// The container is here for comparison. It saves writing the order of the
+// calls down, and loses what the compiler knew: a missing or cyclic
+// dependency surfaces as an error when the program runs rather than when
+// it builds. Most Go programs are better off with constructors and a
+// composition root, which is what code generators such as Wire write.
+package di
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors of the Signup service.
+var (
+	ErrExists  = errors.New("di: user exists")
+	ErrInvalid = errors.New("di: invalid email")
+)
+
+// User is a signed up user.
+type User struct {
+	Email  string
+	Joined time.Time
+}
+
+// Users stores users.
+type Users interface {
+	Add(ctx context.Context, u User) error
+	Get(ctx context.Context, email string) (User, bool, error)
+}
+
+// Mailer sends mail.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// Clock tells the time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Signup signs users up and welcomes them.
+type Signup struct {
+	users  Users
+	mailer Mailer
+	clock  Clock
+}
+
+// NewSignup returns a Signup using the given dependencies.
+func NewSignup(users Users, mailer Mailer, clock Clock) *Signup {
+	return &Signup{users: users, mailer: mailer, clock: clock}
+}
+
+// Register signs up email and sends it a welcome mail. It fails with
+// ErrInvalid for a malformed address and ErrExists for a known one.
+func (s *Signup) Register(ctx context.Context, email string) (User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if at := strings.IndexByte(email, '@'); at <= 0 || at == len(email)-1 {
+		return User{}, fmt.Errorf("%w: %q", ErrInvalid, email)
+	}
+	if _, ok, err := s.users.Get(ctx, email); err != nil {
+		return User{}, err
+	} else if ok {
+		return User{}, fmt.Errorf("%w: %s", ErrExists, email)
+	}
+	u := User{Email: email, Joined: s.clock.Now()}
+	if err := s.users.Add(ctx, u); err != nil {
+		return User{}, err
+	}
+	if err := s.mailer.Send(ctx, email, "Welcome", "Thanks for signing up."); err != nil {
+		return u, fmt.Errorf("di: welcome mail: %w", err)
+	}
+	return u, nil
+}
+
+// MemUsers keeps users in memory.
+type MemUsers struct {
+	mu    sync.Mutex
+	users map[string]User
+}
+
+// NewMemUsers returns an empty MemUsers.
+func NewMemUsers() *MemUsers {
+	return &MemUsers{users: map[string]User{}}
+}
+
+// Add stores u, failing with ErrExists if its email is taken.
+func (m *MemUsers) Add(_ context.Context, u User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[u.Email]; ok {
+		return fmt.Errorf("%w: %s", ErrExists, u.Email)
+	}
+	m.users[u.Email] = u
+	return nil
+}
+
+// Get returns the user with the given email.
+func (m *MemUsers) Get(_ context.Context, email string) (User, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[email]
+	return u, ok, nil
+}
+
+// WriterMailer writes mail to a Writer instead of sending it.
+type WriterMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterMailer returns a Mailer writing to w.
+func NewWriterMailer(w io.Writer) *WriterMailer {
+	return &WriterMailer{w: w}
+}
+
+// Send writes the mail as one line.
+func (m *WriterMailer) Send(_ context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := fmt.Fprintf(m.w, "to %s: %s: %s\n", to, subject, body)
+	return err
+}
+
+// SystemClock is the Clock of the machine.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,188 @@
+package di
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeMailer records mail and fails when told to.
+type fakeMailer struct {
+	sent []string
+	err  error
+}
+
+func (m *fakeMailer) Send(_ context.Context, to, subject, _ string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, to+": "+subject)
+	return nil
+}
+
+// fakeUsers is a Users whose lookups fail when told to.
+type fakeUsers struct {
+	*MemUsers
+	err error
+}
+
+func (u fakeUsers) Get(ctx context.Context, email string) (User, bool, error) {
+	if u.err != nil {
+		return User{}, false, u.err
+	}
+	return u.MemUsers.Get(ctx, email)
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+var noon = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	mailer := &fakeMailer{}
+	s := NewSignup(NewMemUsers(), mailer, fixedClock(noon))
+
+	u, err := s.Register(ctx, " Ann@Example.com ")
+	if err != nil || u != (User{"ann@example.com", noon}) {
+		t.Fatalf("Register = %+v, %v", u, err)
+	}
+	if _, err := s.Register(ctx, "ann@example.com"); !errors.Is(err, ErrExists) {
+		t.Errorf("second Register: %v", err)
+	}
+	for _, bad := range []string{"", "ann", "@example.com", "ann@"} {
+		if _, err := s.Register(ctx, bad); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Register(%q): %v", bad, err)
+		}
+	}
+	if fmt.Sprint(mailer.sent) != "[ann@example.com: Welcome]" {
+		t.Errorf("sent %q", mailer.sent)
+	}
+}
+
+func TestRegisterFailures(t *testing.T) {
+	ctx := context.Background()
+	down := errors.New("down")
+
+	s := NewSignup(fakeUsers{NewMemUsers(), down}, &fakeMailer{}, fixedClock(noon))
+	if _, err := s.Register(ctx, "ann@example.com"); err != down {
+		t.Errorf("store down: %v", err)
+	}
+
+	users := NewMemUsers()
+	s = NewSignup(users, &fakeMailer{err: down}, fixedClock(noon))
+	u, err := s.Register(ctx, "ann@example.com")
+	if !errors.Is(err, down) || u.Email == "" {
+		t.Errorf("mail down: %+v, %v", u, err)
+	}
+	// The user is signed up even though the mail was not sent.
+	if _, ok, _ := users.Get(ctx, "ann@example.com"); !ok {
+		t.Error("user not stored")
+	}
+}
+
+func TestWire(t *testing.T) {
+	var out strings.Builder
+	app := Wire(&out)
+	if _, err := app.Signup.Register(context.Background(), "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := app.Users.Get(context.Background(), "bob@example.com"); !ok {
+		t.Error("user not stored")
+	}
+	if out.String() != "to bob@example.com: Welcome: Thanks for signing up.\n" {
+		t.Errorf("mailed %q", out.String())
+	}
+}
+
+func TestContainer(t *testing.T) {
+	var out strings.Builder
+	c := NewContainer()
+	users := 0
+	for _, ctor := range []any{
+		func() (Users, error) { users++; return NewMemUsers(), nil },
+		func() Mailer { return NewWriterMailer(&out) },
+		func() Clock { return fixedClock(noon) },
+		NewSignup,
+	} {
+		if err := c.Provide(ctor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := c.Invoke(func(s *Signup, u Users) error {
+		_, err := s.Register(context.Background(), "cy@example.com")
+		if _, ok, _ := u.Get(context.Background(), "cy@example.com"); !ok {
+			t.Error("the Signup and the Users given do not share a store")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve[*Signup](c); err != nil || users != 1 {
+		t.Errorf("Resolve = %v after %d Users built", err, users)
+	}
+	if !strings.HasPrefix(out.String(), "to cy@example.com") {
+		t.Errorf("mailed %q", out.String())
+	}
+}
+
+func TestContainerErrors(t *testing.T) {
+	down := errors.New("down")
+	for _, tc := range []struct {
+		name    string
+		ctors   []any
+		want    error
+		message string
+	}{
+		{"missing", []any{NewSignup, func() Clock { return SystemClock{} }},
+			ErrNoProvider, "no provider for di.Users, needed by *di.Signup"},
+		{"cycle", []any{
+			func(Mailer) Users { return nil },
+			func(Users) Mailer { return nil },
+			func() Clock { return SystemClock{} },
+			NewSignup,
+		}, ErrCycle, "cycle: di.Users -> di.Mailer -> di.Users"},
+		{"failing", []any{
+			func() (Users, error) { return nil, down },
+			func() Mailer { return nil },
+			func() Clock { return nil },
+			NewSignup,
+		}, down, "building di.Users: down"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewContainer()
+			for _, ctor := range tc.ctors {
+				if err := c.Provide(ctor); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := Resolve[*Signup](c)
+			if !errors.Is(err, tc.want) || !strings.Contains(err.Error(), tc.message) {
+				t.Errorf("Resolve = %v", err)
+			}
+		})
+	}
+
+	c := NewContainer()
+	for _, bad := range []any{nil, 42, func() {}, func() (int, int) { return 0, 0 }, func(...int) int { return 0 }} {
+		if err := c.Provide(bad); !errors.Is(err, ErrBadConstructor) {
+			t.Errorf("Provide(%T) = %v", bad, err)
+		}
+	}
+	c.Provide(func() Clock { return SystemClock{} })
+	if err := c.Provide(func() Clock { return nil }); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("second Provide = %v", err)
+	}
+}
+
+func ExampleWire() {
+	app := Wire(os.Stdout)
+	app.Signup.Register(context.Background(), "dee@example.com")
+	// Output: to dee@example.com: Welcome: Thanks for signing up.
+}
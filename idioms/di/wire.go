@@ -0,0 +1,19 @@
+package di
+
+import "io"
+
+// App is everything the program needs, assembled.
+type App struct {
+	Users  *MemUsers
+	Signup *Signup
+}
+
+// Wire is the composition root: it picks an implementation for every
+// dependency and passes them to the constructors that need them. Mail goes
+// to out.
+func Wire(out io.Writer) *App {
+	users := NewMemUsers()
+	mailer := NewWriterMailer(out)
+	clock := SystemClock{}
+	return &App{Users: users, Signup: NewSignup(users, mailer, clock)}
+}
@@ -0,0 +1,98 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// ErrorList collects errors from concurrent work. The zero value is an
+// empty list, safe for concurrent use.
+//
+// Unlike errors.Join, which takes its errors all at once, an ErrorList is
+// added to as failures happen, and Err turns it into a single error at the
+// end. errors.Is and errors.As look through every error in that error.
+type ErrorList struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// Add appends err to the list. It ignores nil, so the result of a call
+// can be added unchecked.
+func (l *ErrorList) Add(err error) {
+	if err == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, err)
+}
+
+// Len returns the number of errors added.
+func (l *ErrorList) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.errs)
+}
+
+// Err returns nil if no error was added, and otherwise an error holding
+// the errors added so far.
+func (l *ErrorList) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.errs) == 0 {
+		return nil
+	}
+	return &listError{errs: append([]error(nil), l.errs...)}
+}
+
+type listError struct {
+	errs []error
+}
+
+func (e *listError) Error() string {
+	if len(e.errs) == 1 {
+		return e.errs[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d errors:", len(e.errs))
+	for _, err := range e.errs {
+		b.WriteString("\n\t")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+func (e *listError) Unwrap() []error { return e.errs }
+
+// TaskError is the failure of a named task.
+type TaskError struct {
+	Task string
+	Err  error
+}
+
+func (e *TaskError) Error() string { return e.Task + ": " + e.Err.Error() }
+
+func (e *TaskError) Unwrap() error { return e.Err }
+
+// RunAll runs every task on p and waits for all of them. It returns nil if
+// they all succeed, and otherwise the failures as TaskErrors in one
+// ErrorList error, in the order they happened.
+func RunAll(ctx context.Context, p *workerpool.Pool, tasks map[string]workerpool.Worker) error {
+	var errs ErrorList
+	var wg sync.WaitGroup
+	for name, w := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.Run(ctx, w); err != nil {
+				errs.Add(&TaskError{Task: name, Err: err})
+			}
+		}()
+	}
+	wg.Wait()
+	return errs.Err()
+}
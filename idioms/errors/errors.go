@@ -0,0 +1,127 @@
+// Package errors shows the ways Go code reports errors, on a small bank
+// ledger, and adds an ErrorList for collecting the failures of many
+// concurrent tasks.
+//
+//   - A sentinel error is a package-level value, such as ErrNoAccount,
+//     that callers compare against with errors.Is. It says what went wrong
+//     and nothing more.
+//   - A typed error, such as *FieldError, carries details. Callers get at
+//     them with errors.As.
+//   - Wrapping with fmt.Errorf and %w adds context on the way up, as
+//     Withdraw does, while errors.Is and errors.As still see the error
+//     underneath.
+//   - errors.Join reports several problems at once, as Validate does, so
+//     a caller fixes them all in one go instead of one per round trip.
+//
+// The package is named after the standard errors package, which it
+// imports; import it under another name next to the standard one.
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Sentinel errors of the Ledger.
+var (
+	ErrNoAccount         = errors.New("ledger: no such account")
+	ErrInsufficientFunds = errors.New("ledger: insufficient funds")
+	ErrInvalid           = errors.New("ledger: invalid transfer")
+)
+
+// FieldError is a problem with one field of a Transfer. It matches
+// ErrInvalid, so errors.Is(err, ErrInvalid) holds for it.
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("ledger: %s %s", e.Field, e.Reason)
+}
+
+// Is reports whether target is ErrInvalid.
+func (e *FieldError) Is(target error) bool { return target == ErrInvalid }
+
+// Transfer moves Amount cents between accounts.
+type Transfer struct {
+	From, To string
+	Amount   int64
+}
+
+// Validate returns every problem with t, joined, or nil.
+func (t Transfer) Validate() error {
+	var errs []error
+	if t.From == "" {
+		errs = append(errs, &FieldError{"from", "is empty"})
+	}
+	if t.To == "" {
+		errs = append(errs, &FieldError{"to", "is empty"})
+	}
+	if t.From == t.To && t.From != "" {
+		errs = append(errs, &FieldError{"to", "is the same account as from"})
+	}
+	if t.Amount <= 0 {
+		errs = append(errs, &FieldError{"amount", fmt.Sprintf("is %d, not positive", t.Amount)})
+	}
+	return errors.Join(errs...)
+}
+
+// Ledger holds account balances in cents.
+type Ledger struct {
+	mu       sync.Mutex
+	balances map[string]int64
+}
+
+// NewLedger returns a Ledger with the given balances.
+func NewLedger(balances map[string]int64) *Ledger {
+	l := &Ledger{balances: map[string]int64{}}
+	for a, b := range balances {
+		l.balances[a] = b
+	}
+	return l
+}
+
+// Balance returns the balance of account.
+func (l *Ledger) Balance(account string) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.balances[account]
+	if !ok {
+		return 0, fmt.Errorf("balance of %q: %w", account, ErrNoAccount)
+	}
+	return b, nil
+}
+
+// Withdraw takes amount from account.
+func (l *Ledger) Withdraw(account string, amount int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.balances[account]
+	if !ok {
+		return fmt.Errorf("withdraw from %q: %w", account, ErrNoAccount)
+	}
+	if b < amount {
+		return fmt.Errorf("withdraw %d from %q holding %d: %w", amount, account, b, ErrInsufficientFunds)
+	}
+	l.balances[account] = b - amount
+	return nil
+}
+
+// Transfer validates t and carries it out.
+func (l *Ledger) Transfer(t Transfer) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if _, err := l.Balance(t.To); err != nil {
+		return fmt.Errorf("transfer: %w", err)
+	}
+	if err := l.Withdraw(t.From, t.Amount); err != nil {
+		return fmt.Errorf("transfer: %w", err)
+	}
+	l.mu.Lock()
+	l.balances[t.To] += t.Amount
+	l.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,159 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+func ledger() *Ledger {
+	return NewLedger(map[string]int64{"ann": 100, "bob": 20})
+}
+
+func TestSentinelThroughWrapping(t *testing.T) {
+	l := ledger()
+	err := l.Transfer(Transfer{From: "bob", To: "ann", Amount: 50})
+	if !errors.Is(err, ErrInsufficientFunds) || errors.Is(err, ErrNoAccount) {
+		t.Errorf("got %v", err)
+	}
+	if want := `transfer: withdraw 50 from "bob" holding 20: ledger: insufficient funds`; err.Error() != want {
+		t.Errorf("message %q, want %q", err, want)
+	}
+	if err := l.Transfer(Transfer{From: "ann", To: "eve", Amount: 5}); !errors.Is(err, ErrNoAccount) {
+		t.Errorf("to unknown account: %v", err)
+	}
+	if b, _ := l.Balance("ann"); b != 100 {
+		t.Errorf("failed transfers moved money: ann has %d", b)
+	}
+}
+
+func TestValidateJoins(t *testing.T) {
+	err := Transfer{From: "ann", To: "ann", Amount: -5}.Validate()
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("not ErrInvalid: %v", err)
+	}
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "to" {
+		t.Errorf("first FieldError %+v", fe)
+	}
+	var fields []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		fields = append(fields, e.(*FieldError).Field)
+	}
+	if fmt.Sprint(fields) != "[to amount]" {
+		t.Errorf("problems with %v", fields)
+	}
+	if err := (Transfer{From: "a", To: "b", Amount: 1}).Validate(); err != nil {
+		t.Errorf("valid transfer: %v", err)
+	}
+}
+
+var errDisk = errors.New("disk full")
+
+func TestErrorList(t *testing.T) {
+	var l ErrorList
+	l.Add(nil)
+	if l.Err() != nil {
+		t.Fatal("empty list is an error")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%10 == 0 {
+				l.Add(&FieldError{"x", fmt.Sprint(i)})
+			} else {
+				l.Add(fmt.Errorf("write %d: %w", i, errDisk))
+			}
+		}()
+	}
+	wg.Wait()
+
+	err := l.Err()
+	if l.Len() != 50 || !errors.Is(err, errDisk) || !errors.Is(err, ErrInvalid) {
+		t.Errorf("%d errors, Is errDisk %v, Is ErrInvalid %v", l.Len(), errors.Is(err, errDisk), errors.Is(err, ErrInvalid))
+	}
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Error("As found no FieldError")
+	}
+
+	// Err is a snapshot: later additions do not change it.
+	l.Add(errors.New("late"))
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 50 {
+		t.Errorf("earlier Err now holds %d errors", n)
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	p := workerpool.New(2)
+	defer p.Shutdown()
+	l := ledger()
+	tasks := map[string]workerpool.Worker{}
+	for _, tr := range []Transfer{
+		{From: "ann", To: "bob", Amount: 10},
+		{From: "bob", To: "ann", Amount: 500},
+		{From: "eve", To: "ann", Amount: 1},
+		{From: "ann", To: "bob", Amount: 0},
+	} {
+		tasks[fmt.Sprintf("%s->%s %d", tr.From, tr.To, tr.Amount)] = workerpool.WorkerFunc(func(context.Context) error {
+			return l.Transfer(tr)
+		})
+	}
+	tasks["crash"] = workerpool.WorkerFunc(func(context.Context) error { panic("bug") })
+
+	err := RunAll(context.Background(), p, tasks)
+	failed := map[string]bool{}
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var te *TaskError
+		if !errors.As(e, &te) {
+			t.Fatalf("%v is not a TaskError", e)
+		}
+		failed[te.Task] = true
+	}
+	want := map[string]bool{"bob->ann 500": true, "eve->ann 1": true, "ann->bob 0": true, "crash": true}
+	if fmt.Sprint(failed) != fmt.Sprint(want) {
+		t.Errorf("failed %v, want %v", failed, want)
+	}
+	var pe *recovery.PanicError
+	if !errors.Is(err, ErrInsufficientFunds) || !errors.Is(err, ErrNoAccount) || !errors.Is(err, ErrInvalid) || !errors.As(err, &pe) {
+		t.Errorf("cannot see every cause in %v", err)
+	}
+
+	if err := RunAll(context.Background(), p, map[string]workerpool.Worker{
+		"ok": workerpool.WorkerFunc(func(context.Context) error { return nil }),
+	}); err != nil {
+		t.Errorf("all succeeded: %v", err)
+	}
+}
+
+func Example() {
+	l := NewLedger(map[string]int64{"ann": 100, "bob": 20})
+
+	err := l.Transfer(Transfer{From: "bob", To: "ann", Amount: 50})
+	switch {
+	case errors.Is(err, ErrInsufficientFunds):
+		fmt.Println("declined:", err)
+	case err != nil:
+		fmt.Println("failed:", err)
+	}
+
+	err = l.Transfer(Transfer{From: "ann", Amount: 0})
+	fmt.Println(err)
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		fmt.Println("first bad field:", fe.Field)
+	}
+	// Output:
+	// declined: transfer: withdraw 50 from "bob" holding 20: ledger: insufficient funds
+	// ledger: to is empty
+	// ledger: amount is 0, not positive
+	// first bad field: to
+}
@@ -0,0 +1,109 @@
+// Package memoize wraps a function so that it runs once per argument and
+// later calls with the same argument get the remembered result.
+//
+// The wrapper is safe for concurrent callers: while the function runs for
+// a key, other callers with that key wait for the same run rather than
+// starting their own, so an expensive call is never duplicated. Errors are
+// handed to everyone waiting on the run but are not remembered; the next
+// call tries again. Options bound how long a result is remembered and how
+// many results are kept, evicting the least recently used first.
+package memoize
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/cache"
+)
+
+// ErrPanicked is the error of callers that waited on a run of the
+// function that panicked. The caller whose run it was gets the panic.
+var ErrPanicked = errors.New("memoize: function panicked")
+
+// Options bound what a memoized function remembers.
+type Options struct {
+	// TTL is how long a result is remembered; zero means forever.
+	TTL time.Duration
+	// MaxSize is how many results are remembered; zero means no limit.
+	MaxSize int
+	// Now is the clock TTL is measured with, time.Now by default.
+	Now func() time.Time
+}
+
+// Option sets an Option.
+type Option func(*Options)
+
+// TTL sets how long results are remembered.
+func TTL(d time.Duration) Option {
+	return func(o *Options) { o.TTL = d }
+}
+
+// MaxSize sets how many results are remembered.
+func MaxSize(n int) Option {
+	return func(o *Options) { o.MaxSize = n }
+}
+
+// WithClock replaces time.Now, for tests.
+func WithClock(now func() time.Time) Option {
+	return func(o *Options) { o.Now = now }
+}
+
+// call is a run of the function for one key. done is closed once val and
+// err are set.
+type call[V any] struct {
+	done    chan struct{}
+	val     V
+	err     error
+	expires time.Time
+}
+
+// Memoize returns a function that calls fn at most once per key at a
+// time, and remembers its successful results within the bounds of opts.
+func Memoize[K comparable, V any](fn func(K) (V, error), opts ...Option) func(K) (V, error) {
+	o := Options{Now: time.Now}
+	for _, set := range opts {
+		set(&o)
+	}
+	size := o.MaxSize
+	if size <= 0 {
+		size = math.MaxInt
+	}
+	var (
+		mu       sync.Mutex
+		inFlight = map[K]*call[V]{}
+		results  = cache.NewLRU[K, *call[V]](size, nil)
+	)
+	return func(key K) (V, error) {
+		mu.Lock()
+		if c, ok := inFlight[key]; ok {
+			mu.Unlock()
+			<-c.done
+			return c.val, c.err
+		}
+		if c, ok := results.Get(key); ok {
+			if o.TTL <= 0 || o.Now().Before(c.expires) {
+				mu.Unlock()
+				return c.val, nil
+			}
+			results.Remove(key)
+		}
+		c := &call[V]{done: make(chan struct{}), err: ErrPanicked}
+		inFlight[key] = c
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			delete(inFlight, key)
+			if c.err == nil {
+				c.expires = o.Now().Add(o.TTL)
+				results.Put(key, c)
+			}
+			mu.Unlock()
+			close(c.done)
+		}()
+		c.val, c.err = fn(key)
+		return c.val, c.err
+	}
+}
@@ -0,0 +1,172 @@
+package memoize
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counted wraps fn with a count of its calls per key.
+type counted struct {
+	mu    sync.Mutex
+	calls map[int]int
+}
+
+func (c *counted) fn(gate <-chan struct{}, err error) func(int) (string, error) {
+	c.calls = map[int]int{}
+	return func(k int) (string, error) {
+		c.mu.Lock()
+		c.calls[k]++
+		c.mu.Unlock()
+		<-gate
+		return fmt.Sprint("v", k), err
+	}
+}
+
+func (c *counted) of(k int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[k]
+}
+
+func open() chan struct{} {
+	gate := make(chan struct{})
+	close(gate)
+	return gate
+}
+
+func TestOncePerKeyUnderConcurrency(t *testing.T) {
+	var c counted
+	gate := make(chan struct{})
+	m := Memoize(c.fn(gate, nil))
+	var wg sync.WaitGroup
+	var wrong atomic.Int32
+	for i := range 60 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			k := i % 3
+			if v, err := m(k); v != fmt.Sprint("v", k) || err != nil {
+				wrong.Add(1)
+			}
+		}()
+	}
+	// Let every key's first run start, then release them all at once.
+	for c.of(0) == 0 || c.of(1) == 0 || c.of(2) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(gate)
+	wg.Wait()
+	if wrong.Load() > 0 {
+		t.Errorf("%d callers got a wrong result", wrong.Load())
+	}
+	for k := range 3 {
+		if n := c.of(k); n != 1 {
+			t.Errorf("key %d ran %d times", k, n)
+		}
+	}
+}
+
+func TestErrorsAreNotRemembered(t *testing.T) {
+	var c counted
+	boom := errors.New("boom")
+	m := Memoize(c.fn(open(), boom))
+	for range 3 {
+		if _, err := m(1); err != boom {
+			t.Errorf("err = %v", err)
+		}
+	}
+	if c.of(1) != 3 {
+		t.Errorf("failing key ran %d times, want 3", c.of(1))
+	}
+}
+
+func TestTTL(t *testing.T) {
+	var c counted
+	now := time.Unix(0, 0)
+	m := Memoize(c.fn(open(), nil), TTL(time.Minute), WithClock(func() time.Time { return now }))
+	m(1)
+	now = now.Add(59 * time.Second)
+	m(1)
+	if c.of(1) != 1 {
+		t.Errorf("ran %d times within the TTL", c.of(1))
+	}
+	now = now.Add(time.Second)
+	m(1)
+	if c.of(1) != 2 {
+		t.Errorf("ran %d times after the TTL, want 2", c.of(1))
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	var c counted
+	m := Memoize(c.fn(open(), nil), MaxSize(2))
+	m(1)
+	m(2)
+	m(1) // 1 is now the most recently used
+	m(3) // evicts 2
+	m(1)
+	m(2)
+	if c.of(1) != 1 || c.of(2) != 2 || c.of(3) != 1 {
+		t.Errorf("calls %v, want map[1:1 2:2 3:1]", c.calls)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	gate := make(chan struct{})
+	var runs atomic.Int32
+	m := Memoize(func(k int) (int, error) {
+		if runs.Add(1) == 1 {
+			<-gate
+			panic("first run fails")
+		}
+		return k, nil
+	})
+	waiter := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the caller whose run panicked did not panic")
+			}
+		}()
+		m(7)
+	}()
+	for runs.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		_, err := m(7)
+		waiter <- err
+	}()
+	close(gate)
+	// The waiter may come in before or after the panic.
+	if err := <-waiter; err != nil && err != ErrPanicked {
+		t.Errorf("waiter got %v", err)
+	}
+	if v, err := m(7); v != 7 || err != nil {
+		t.Errorf("after the panic: %d, %v", v, err)
+	}
+}
+
+func ExampleMemoize() {
+	fib := func(n int) (int, error) {
+		fmt.Println("computing", n)
+		a, b := 0, 1
+		for range n {
+			a, b = b, a+b
+		}
+		return a, nil
+	}
+	memo := Memoize(fib, MaxSize(100))
+	v, _ := memo(40)
+	fmt.Println(v)
+	v, _ = memo(40)
+	fmt.Println(v)
+	// Output:
+	// computing 40
+	// 102334155
+	// 102334155
+}
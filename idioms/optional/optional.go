@@ -0,0 +1,171 @@
+// Package optional is an Optional type for Go: a value that may be
+// absent, without a pointer.
+//
+// Go's own answers to "maybe a T" are a *T, a comma-ok pair, or the zero
+// value. The zero value is fine when it cannot be a real value, an empty
+// name, a zero ID, and falls short when it can: a discount of 0% is not a
+// discount left unset. The comma-ok pair is the idiom for returning one.
+// A *T is the idiom for storing one, and has costs an Optional does not:
+// it allocates, it aliases, so a caller that keeps it sees later changes
+// and can make them, and a nil one panics where a dereference forgot to
+// check.
+//
+// Prefer an Optional for a field that may be unset in a struct that is
+// copied around, such as a config value or a PATCH request body, where it
+// marshals as JSON null or the value. Keep a *T where sharing is the point,
+// or where the value is large and copying it would cost more. And keep the
+// comma-ok return: a function returning an Optional makes every caller
+// unpack it, where (v, ok) is already what they expect. Get, FromPtr, Ptr
+// and NonZero convert at the boundaries.
+package optional
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Optional holds either a value or nothing. The zero Optional is None.
+type Optional[T any] struct {
+	v  T
+	ok bool
+}
+
+// Some returns an Optional holding v.
+func Some[T any](v T) Optional[T] { return Optional[T]{v: v, ok: true} }
+
+// None returns an empty Optional.
+func None[T any]() Optional[T] { return Optional[T]{} }
+
+// Of returns Some(v) if ok and None otherwise, turning a comma-ok result
+// into an Optional.
+func Of[T any](v T, ok bool) Optional[T] {
+	if !ok {
+		return None[T]()
+	}
+	return Some(v)
+}
+
+// FromPtr returns None for a nil p and Some of a copy of *p otherwise.
+func FromPtr[T any](p *T) Optional[T] {
+	if p == nil {
+		return None[T]()
+	}
+	return Some(*p)
+}
+
+// NonZero returns None for the zero value of T and Some(v) otherwise, for
+// values whose zero means unset.
+func NonZero[T comparable](v T) Optional[T] {
+	var zero T
+	return Of(v, v != zero)
+}
+
+// IsSome reports whether o holds a value.
+func (o Optional[T]) IsSome() bool { return o.ok }
+
+// Get returns the value of o and whether it has one, the comma-ok way.
+func (o Optional[T]) Get() (T, bool) { return o.v, o.ok }
+
+// Ptr returns a pointer to a copy of the value of o, or nil.
+func (o Optional[T]) Ptr() *T {
+	if !o.ok {
+		return nil
+	}
+	v := o.v
+	return &v
+}
+
+// OrZero returns the value of o, or the zero value of T.
+func (o Optional[T]) OrZero() T { return o.v }
+
+// OrElse returns the value of o, or fallback.
+func (o Optional[T]) OrElse(fallback T) T {
+	if !o.ok {
+		return fallback
+	}
+	return o.v
+}
+
+// OrElseFunc returns the value of o, or the result of fallback, which is
+// only called if o is None.
+func (o Optional[T]) OrElseFunc(fallback func() T) T {
+	if !o.ok {
+		return fallback()
+	}
+	return o.v
+}
+
+// Unwrap returns the value of o and panics if it has none.
+func (o Optional[T]) Unwrap() T {
+	if !o.ok {
+		panic("optional: Unwrap of None")
+	}
+	return o.v
+}
+
+// Or returns o if it holds a value, and other otherwise.
+func (o Optional[T]) Or(other Optional[T]) Optional[T] {
+	if !o.ok {
+		return other
+	}
+	return o
+}
+
+// String formats o as Some(v) or None.
+func (o Optional[T]) String() string {
+	if !o.ok {
+		return "None"
+	}
+	return fmt.Sprintf("Some(%v)", o.v)
+}
+
+// MarshalJSON encodes None as null and Some(v) as v.
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if !o.ok {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.v)
+}
+
+// UnmarshalJSON decodes null as None and anything else as Some. A field
+// absent from the input is not touched, and stays None if it was never
+// set, so absent and null both read as unset.
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*o = None[T]()
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*o = Some(v)
+	return nil
+}
+
+// Map applies f to the value of o, passing None through.
+func Map[T, U any](o Optional[T], f func(T) U) Optional[U] {
+	if !o.ok {
+		return None[U]()
+	}
+	return Some(f(o.v))
+}
+
+// Then applies f, which may itself find nothing, to the value of o,
+// passing None through.
+func Then[T, U any](o Optional[T], f func(T) Optional[U]) Optional[U] {
+	if !o.ok {
+		return None[U]()
+	}
+	return f(o.v)
+}
+
+// Filter returns o if it holds a value that keep accepts, and None
+// otherwise.
+func Filter[T any](o Optional[T], keep func(T) bool) Optional[T] {
+	if !o.ok || !keep(o.v) {
+		return None[T]()
+	}
+	return o
+}
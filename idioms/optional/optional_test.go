@@ -0,0 +1,200 @@
+package optional
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestConstructors(t *testing.T) {
+	n := 5
+	zero := 0
+	for _, tt := range []struct {
+		name string
+		o    Optional[int]
+		want string
+	}{
+		{"Some", Some(5), "Some(5)"},
+		{"Some of zero", Some(0), "Some(0)"},
+		{"None", None[int](), "None"},
+		{"zero Optional", Optional[int]{}, "None"},
+		{"Of ok", Of(5, true), "Some(5)"},
+		{"Of not ok", Of(5, false), "None"},
+		{"FromPtr", FromPtr(&n), "Some(5)"},
+		{"FromPtr to zero", FromPtr(&zero), "Some(0)"},
+		{"FromPtr nil", FromPtr[int](nil), "None"},
+		{"NonZero", NonZero(5), "Some(5)"},
+		{"NonZero of zero", NonZero(0), "None"},
+	} {
+		if got := tt.o.String(); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	for _, tt := range []struct {
+		o        Optional[string]
+		some     bool
+		orZero   string
+		orElse   string
+		elseCall bool
+		or       string
+	}{
+		{Some("a"), true, "a", "a", false, "Some(a)"},
+		{Some(""), true, "", "", false, "Some()"},
+		{None[string](), false, "", "fallback", true, "Some(other)"},
+	} {
+		if v, ok := tt.o.Get(); ok != tt.some || v != tt.orZero {
+			t.Errorf("%v.Get() = %q, %v", tt.o, v, ok)
+		}
+		if tt.o.IsSome() != tt.some {
+			t.Errorf("%v.IsSome() = %v", tt.o, !tt.some)
+		}
+		if got := tt.o.OrZero(); got != tt.orZero {
+			t.Errorf("%v.OrZero() = %q", tt.o, got)
+		}
+		if got := tt.o.OrElse("fallback"); got != tt.orElse {
+			t.Errorf("%v.OrElse() = %q", tt.o, got)
+		}
+		called := false
+		got := tt.o.OrElseFunc(func() string { called = true; return "fallback" })
+		if got != tt.orElse || called != tt.elseCall {
+			t.Errorf("%v.OrElseFunc() = %q, called %v", tt.o, got, called)
+		}
+		if got := tt.o.Or(Some("other")).String(); got != tt.or {
+			t.Errorf("%v.Or() = %s", tt.o, got)
+		}
+	}
+}
+
+func TestPtr(t *testing.T) {
+	if p := None[int]().Ptr(); p != nil {
+		t.Errorf("None.Ptr() = %v", *p)
+	}
+	o := Some(5)
+	p := o.Ptr()
+	if p == nil || *p != 5 {
+		t.Fatalf("Some(5).Ptr() = %v", p)
+	}
+	// The pointer is to a copy: writing through it leaves o alone, which
+	// is what a *T field cannot promise.
+	*p = 6
+	if o.Unwrap() != 5 {
+		t.Errorf("o changed to %v through its Ptr", o)
+	}
+	n := 7
+	from := FromPtr(&n)
+	n = 8
+	if from.Unwrap() != 7 {
+		t.Errorf("FromPtr aliased its argument: %v", from)
+	}
+}
+
+func TestUnwrapPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "optional: Unwrap of None" {
+			t.Errorf("recovered %v", r)
+		}
+	}()
+	None[int]().Unwrap()
+}
+
+func TestCombinators(t *testing.T) {
+	parse := func(s string) Optional[int] {
+		n, err := strconv.Atoi(s)
+		return Of(n, err == nil)
+	}
+	even := func(n int) bool { return n%2 == 0 }
+	for _, tt := range []struct {
+		in                 Optional[string]
+		mapped, then, kept string
+	}{
+		{Some("42"), "Some(2)", "Some(42)", "Some(42)"},
+		{Some("7"), "Some(1)", "Some(7)", "None"},
+		{Some("x"), "Some(1)", "None", "None"},
+		{None[string](), "None", "None", "None"},
+	} {
+		if got := Map(tt.in, func(s string) int { return len(s) }).String(); got != tt.mapped {
+			t.Errorf("Map(%v) = %s, want %s", tt.in, got, tt.mapped)
+		}
+		then := Then(tt.in, parse)
+		if got := then.String(); got != tt.then {
+			t.Errorf("Then(%v) = %s, want %s", tt.in, got, tt.then)
+		}
+		if got := Filter(then, even).String(); got != tt.kept {
+			t.Errorf("Filter(%v) = %s, want %s", then, got, tt.kept)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type patch struct {
+		Name     Optional[string] `json:"name"`
+		Discount Optional[int]    `json:"discount"`
+	}
+	for _, tt := range []struct {
+		in, want string
+		err      bool
+	}{
+		{`{"name":"tea","discount":0}`, `{"name":"tea","discount":0}`, false},
+		{`{"name":null,"discount":10}`, `{"name":null,"discount":10}`, false},
+		{`{"discount": null }`, `{"name":null,"discount":null}`, false},
+		{`{}`, `{"name":null,"discount":null}`, false},
+		{`{"discount":"ten"}`, ``, true},
+	} {
+		var p patch
+		err := json.Unmarshal([]byte(tt.in), &p)
+		if (err != nil) != tt.err {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		out, err := json.Marshal(p)
+		if err != nil || string(out) != tt.want {
+			t.Errorf("%s round-tripped to %s, %v, want %s", tt.in, out, err, tt.want)
+		}
+	}
+}
+
+// A discount of 0% is not the same as no discount given, and an int field
+// cannot tell them apart.
+func Example() {
+	type Order struct {
+		Total    int
+		Discount Optional[int] // percent
+	}
+	for _, o := range []Order{{Total: 100}, {Total: 100, Discount: Some(0)}, {Total: 100, Discount: Some(20)}} {
+		price := Map(o.Discount, func(d int) int { return o.Total * (100 - d) / 100 })
+		fmt.Println(o.Discount, price.OrElse(o.Total))
+	}
+	// Output:
+	// None 100
+	// Some(0) 100
+	// Some(20) 80
+}
+
+// At the boundary with code that uses *T or comma-ok, convert once.
+func Example_interop() {
+	env := map[string]string{"PORT": "8080"}
+	lookup := func(key string) Optional[string] {
+		v, ok := env[key]
+		return Of(v, ok)
+	}
+	port := Then(lookup("PORT"), func(s string) Optional[int] {
+		n, err := strconv.Atoi(s)
+		return Of(n, err == nil)
+	})
+	host := lookup("HOST").OrElse("localhost")
+	fmt.Println(host, port.OrElse(80))
+
+	var timeout *int // a legacy config field
+	fmt.Println(FromPtr(timeout).OrElse(30), NonZero(strings.TrimSpace("  ")))
+	// Output:
+	// localhost 8080
+	// 30 None
+}
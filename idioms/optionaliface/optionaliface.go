@@ -0,0 +1,69 @@
+// Package optionaliface demonstrates the optional interface pattern and
+// the trap that comes with it.
+//
+// Functions in the standard library accept a small interface and check
+// with a type assertion whether the value they got can do more: io.Copy
+// looks for io.WriterTo and io.ReaderFrom, a streaming handler looks for
+// http.Flusher. The extra capability is discovered at run time, so the
+// small interface never has to grow.
+//
+// The trap is wrapping. A type that embeds an http.ResponseWriter to count
+// bytes only has the methods of the interface it embeds, so the Flush of
+// the writer inside it is hidden and every type assertion on the wrapper
+// fails. Adding every optional method to the wrapper is no cure: it then
+// claims capabilities the wrapped value may not have, and the number of
+// combinations grows with every interface.
+//
+// The convention since Go 1.20 is an Unwrap method returning the wrapped
+// value, which http.ResponseController follows. As does the same for any
+// capability, the way errors.As walks a chain of errors; idioms/unwrap
+// generalises it to readers, handlers and anything else that wraps.
+package optionaliface
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/crazybber/go-patterns/idioms/unwrap"
+)
+
+// Flush flushes w if it can be flushed, looking through wrappers, and
+// reports whether it could. Writers with Flush() error, such as
+// bufio.Writer, and writers with Flush(), such as http.ResponseWriter,
+// both count.
+func Flush(w io.Writer) (bool, error) {
+	if f, ok := As[interface{ Flush() error }](w); ok {
+		return true, f.Flush()
+	}
+	if f, ok := As[http.Flusher](w); ok {
+		f.Flush()
+		return true, nil
+	}
+	return false, nil
+}
+
+// As returns the first writer in the chain starting at w that implements
+// T, following Unwrap methods as unwrap.As does.
+func As[T any](w io.Writer) (T, bool) {
+	return unwrap.As[T](w)
+}
+
+// CountingWriter counts the bytes written to the response. Because it
+// embeds http.ResponseWriter it hides every optional interface of the
+// writer it wraps; Unwrap lets As and http.ResponseController find them
+// again.
+type CountingWriter struct {
+	http.ResponseWriter
+	N int64
+}
+
+func (c *CountingWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.N += int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped writer.
+func (c *CountingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
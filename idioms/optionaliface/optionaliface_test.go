@@ -0,0 +1,87 @@
+package optionaliface
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// opaqueWriter wraps like CountingWriter but offers no Unwrap.
+type opaqueWriter struct {
+	http.ResponseWriter
+}
+
+// bufferedResponse wraps a ResponseWriter in a bufio.Writer.
+type bufferedResponse struct {
+	*bufio.Writer
+	rw http.ResponseWriter
+}
+
+func (b bufferedResponse) Unwrap() io.Writer { return b.rw }
+
+func TestEmbeddingHidesFlusher(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var w http.ResponseWriter = opaqueWriter{rec}
+	if _, ok := w.(http.Flusher); ok {
+		t.Fatal("embedding an interface should not promote methods of the dynamic value")
+	}
+	if _, ok := As[http.Flusher](w); ok {
+		t.Error("As cannot look through a wrapper without Unwrap")
+	}
+	if err := http.NewResponseController(w).Flush(); err == nil {
+		t.Error("ResponseController cannot look through it either")
+	}
+}
+
+func TestAsThroughWrappers(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &CountingWriter{ResponseWriter: &CountingWriter{ResponseWriter: rec}}
+
+	f, ok := As[http.Flusher](w)
+	if !ok {
+		t.Fatal("As did not find the Flusher two wrappers down")
+	}
+	fmt.Fprint(w, "hello")
+	f.Flush()
+	if !rec.Flushed || w.N != 5 {
+		t.Errorf("flushed=%v n=%d", rec.Flushed, w.N)
+	}
+
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Errorf("ResponseController should follow Unwrap: %v", err)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	var buf bytes.Buffer
+	if ok, _ := Flush(&buf); ok {
+		t.Error("a bytes.Buffer cannot be flushed")
+	}
+
+	rec := httptest.NewRecorder()
+	bw := bufferedResponse{Writer: bufio.NewWriter(rec), rw: rec}
+	fmt.Fprint(bw, "buffered")
+	if ok, err := Flush(bw); !ok || err != nil {
+		t.Fatalf("Flush = %v, %v", ok, err)
+	}
+	if rec.Body.String() != "buffered" {
+		t.Errorf("body %q, the bufio.Writer should have been flushed first", rec.Body.String())
+	}
+	if rec.Flushed {
+		t.Error("As stops at the first match, the recorder should not be flushed")
+	}
+}
+
+func ExampleAs() {
+	rec := httptest.NewRecorder()
+	var w http.ResponseWriter = &CountingWriter{ResponseWriter: rec}
+
+	_, direct := w.(http.Flusher)
+	_, unwrapped := As[http.Flusher](w)
+	fmt.Println(direct, unwrapped)
+	// Output: false true
+}
@@ -0,0 +1,39 @@
+package requestscope
+
+import (
+	"context"
+	"net/http"
+)
+
+// Deps is the dependency bag. Anything a handler might want ends up here.
+type Deps struct {
+	RequestID string
+	Store     UserStore
+	Audit     Audit
+}
+
+type depsKey struct{}
+
+// WithDeps is the middleware that fills the bag for every request.
+func WithDeps(next http.Handler, store UserStore, audit Audit) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deps := &Deps{RequestID: nextRequestID(), Store: store, Audit: audit}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), depsKey{}, deps)))
+	})
+}
+
+// BagHandler serves a user, taking everything it needs from the context.
+// Mounted without WithDeps it compiles fine and fails on every request.
+var BagHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	deps, ok := r.Context().Value(depsKey{}).(*Deps)
+	if !ok {
+		http.Error(w, "requestscope: dependencies missing from context", http.StatusInternalServerError)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	u, err := deps.Store.User(r.Context(), id)
+	if err == nil {
+		deps.Audit.Record(deps.RequestID, "viewed "+id)
+	}
+	writeUser(w, u, err)
+})
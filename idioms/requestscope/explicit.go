@@ -0,0 +1,33 @@
+package requestscope
+
+import "net/http"
+
+// Server holds the dependencies that live as long as the process.
+type Server struct {
+	Store UserStore
+	Audit Audit
+}
+
+// Request holds everything that lives as long as one request. It is built
+// fresh for every request and thrown away afterwards.
+type Request struct {
+	ID    string
+	Store UserStore
+	Audit Audit
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &Request{ID: nextRequestID(), Store: s.Store, Audit: s.Audit}
+	req.ServeUser(w, r)
+}
+
+// ServeUser serves a user.
+func (req *Request) ServeUser(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	u, err := req.Store.User(r.Context(), id)
+	if err == nil {
+		req.Audit.Record(req.ID, "viewed "+id)
+	}
+	writeUser(w, u, err)
+}
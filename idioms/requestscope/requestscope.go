@@ -0,0 +1,75 @@
+// Package requestscope contrasts two ways of giving a handler the
+// dependencies it needs for one request.
+//
+// The anti-pattern, BagHandler, puts a bag of dependencies into the request
+// context in a middleware and pulls it out again in the handler. The
+// handler's signature says nothing about what it needs, the compiler cannot
+// check that the middleware ran, and a test has to know which hidden values
+// to plant in the context before anything works.
+//
+// The alternative, Server, holds the long-lived dependencies in fields and
+// builds a small request struct for every request, carrying those
+// dependencies together with the request-scoped values such as the request
+// id. Everything the handler uses is visible in a type, and a test builds
+// the struct with fakes like any other value. The context goes back to
+// carrying what it was made for: cancellation, deadlines and
+// request-scoped data that crosses API boundaries.
+package requestscope
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// ErrNoUser is returned by a UserStore that does not know the user.
+var ErrNoUser = errors.New("requestscope: no such user")
+
+// User is what the handlers look up.
+type User struct {
+	ID   string
+	Name string
+}
+
+// UserStore looks users up.
+type UserStore interface {
+	User(ctx context.Context, id string) (User, error)
+}
+
+// Audit records what a request did.
+type Audit interface {
+	Record(requestID, event string)
+}
+
+// MapStore is an in-memory UserStore.
+type MapStore map[string]User
+
+// User implements UserStore.
+func (m MapStore) User(_ context.Context, id string) (User, error) {
+	u, ok := m[id]
+	if !ok {
+		return User{}, ErrNoUser
+	}
+	return u, nil
+}
+
+var lastID uint64
+
+func nextRequestID() string {
+	return strconv.FormatUint(atomic.AddUint64(&lastID, 1), 10)
+}
+
+// writeUser is the business logic both variants share.
+func writeUser(w http.ResponseWriter, u User, err error) {
+	switch {
+	case errors.Is(err, ErrNoUser):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		fmt.Fprintln(w, u.Name)
+	}
+}
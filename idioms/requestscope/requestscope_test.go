@@ -0,0 +1,84 @@
+package requestscope
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type recordingAudit struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (a *recordingAudit) Record(requestID, event string) {
+	a.mu.Lock()
+	a.events = append(a.events, requestID+": "+event)
+	a.mu.Unlock()
+}
+
+type discardAudit struct{}
+
+func (discardAudit) Record(string, string) {}
+
+var users = MapStore{"42": {ID: "42", Name: "Gopher"}}
+
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+	return rec
+}
+
+// With explicit dependencies the test builds the request struct with
+// fakes and calls the method; the compiler checks nothing is missing.
+func TestExplicit(t *testing.T) {
+	audit := &recordingAudit{}
+	req := &Request{ID: "r-1", Store: users, Audit: audit}
+
+	rec := httptest.NewRecorder()
+	req.ServeUser(rec, httptest.NewRequest("GET", "/?id=42", nil))
+	if rec.Body.String() != "Gopher\n" {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+	if len(audit.events) != 1 || audit.events[0] != "r-1: viewed 42" {
+		t.Errorf("audit = %v", audit.events)
+	}
+
+	if rec := get(&Server{Store: users, Audit: audit}, "/?id=7"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status %d", rec.Code)
+	}
+}
+
+// With the bag the test has to know that the handler reads from the
+// context, and which middleware puts the values there. Forgetting it is
+// only caught at run time.
+func TestBag(t *testing.T) {
+	if rec := get(BagHandler, "/?id=42"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("without the middleware: status %d, want 500", rec.Code)
+	}
+
+	audit := &recordingAudit{}
+	rec := get(WithDeps(BagHandler, users, audit), "/?id=42")
+	if rec.Body.String() != "Gopher\n" || len(audit.events) != 1 {
+		t.Errorf("body = %q, audit = %v", rec.Body.String(), audit.events)
+	}
+}
+
+func benchmark(b *testing.B, h http.Handler) {
+	r := httptest.NewRequest("GET", "/?id=42", nil)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			h.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	})
+}
+
+func BenchmarkBag(b *testing.B) {
+	benchmark(b, WithDeps(BagHandler, users, discardAudit{}))
+}
+
+func BenchmarkExplicit(b *testing.B) {
+	benchmark(b, &Server{Store: users, Audit: discardAudit{}})
+}
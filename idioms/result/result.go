@@ -0,0 +1,114 @@
+// Package result is a Result type for Go: a value or an error in one
+// value, with combinators in the style of other languages.
+//
+// Where it helps is where a value and its error have to travel together
+// through something that holds one value: a channel, a slice, the stages of
+// a pipeline. A chan Result[T] carries successes and failures in order
+// without a second error channel, and Map and Then let each stage skip the
+// items that already failed. Stream shows this.
+//
+// Where it fights Go is everywhere else. Go has no ? operator, so code
+// built from Map and Then closures reads worse than the two values and an
+// if err != nil it replaces; Unwrap hides a panic behind what looks like
+// an accessor; and the standard library and everyone else's code return
+// (T, error), so a Result is converted at every boundary anyway. Keep it at
+// the edges of such containers: turn (T, error) into a Result with Of going
+// in, and back with Get coming out.
+package result
+
+import "fmt"
+
+// Result holds either a value or an error.
+type Result[T any] struct {
+	v   T
+	err error
+}
+
+// Ok returns a successful Result holding v.
+func Ok[T any](v T) Result[T] { return Result[T]{v: v} }
+
+// Err returns a failed Result holding err.
+func Err[T any](err error) Result[T] { return Result[T]{err: err} }
+
+// Of returns Err(err) if err is not nil and Ok(v) otherwise, turning the
+// results of an ordinary call into a Result.
+func Of[T any](v T, err error) Result[T] {
+	if err != nil {
+		return Err[T](err)
+	}
+	return Ok(v)
+}
+
+// IsOk reports whether r holds a value.
+func (r Result[T]) IsOk() bool { return r.err == nil }
+
+// Err returns the error of r, or nil.
+func (r Result[T]) Err() error { return r.err }
+
+// Get returns the value and error of r, the ordinary Go way.
+func (r Result[T]) Get() (T, error) { return r.v, r.err }
+
+// Unwrap returns the value of r and panics if r failed.
+func (r Result[T]) Unwrap() T {
+	if r.err != nil {
+		panic(fmt.Sprintf("result: Unwrap of a failed result: %v", r.err))
+	}
+	return r.v
+}
+
+// OrElse returns the value of r, or fallback if r failed.
+func (r Result[T]) OrElse(fallback T) T {
+	if r.err != nil {
+		return fallback
+	}
+	return r.v
+}
+
+// String formats r as Ok(v) or Err(err).
+func (r Result[T]) String() string {
+	if r.err != nil {
+		return fmt.Sprintf("Err(%v)", r.err)
+	}
+	return fmt.Sprintf("Ok(%v)", r.v)
+}
+
+// Map applies f to the value of r, passing a failure through untouched.
+func Map[T, U any](r Result[T], f func(T) U) Result[U] {
+	if r.err != nil {
+		return Err[U](r.err)
+	}
+	return Ok(f(r.v))
+}
+
+// Then applies f, which may fail, to the value of r, passing a failure
+// through untouched.
+func Then[T, U any](r Result[T], f func(T) (U, error)) Result[U] {
+	if r.err != nil {
+		return Err[U](r.err)
+	}
+	return Of(f(r.v))
+}
+
+// Must returns v and panics if err is not nil. It is for values that
+// cannot fail unless the program is wrong, such as a regular expression
+// literal or an embedded template, at package initialization.
+func Must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Stream applies f to every value received from in, on a goroutine, and
+// sends the results in order. Failed inputs pass through without calling
+// f. The returned channel is closed after in is.
+func Stream[T, U any](in <-chan Result[T], f func(T) (U, error)) <-chan Result[U] {
+	out := make(chan Result[U])
+	go func() {
+		defer close(out)
+		for r := range in {
+			out <- Then(r, f)
+		}
+	}()
+	return out
+}
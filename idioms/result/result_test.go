@@ -0,0 +1,155 @@
+package result
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var errBoom = errors.New("boom")
+
+func TestBasics(t *testing.T) {
+	ok, bad := Ok(2), Err[int](errBoom)
+	if !ok.IsOk() || bad.IsOk() || ok.Err() != nil || bad.Err() != errBoom {
+		t.Error("IsOk or Err wrong")
+	}
+	if ok.OrElse(7) != 2 || bad.OrElse(7) != 7 {
+		t.Error("OrElse wrong")
+	}
+	if v, err := Of(strconv.Atoi("12")).Get(); v != 12 || err != nil {
+		t.Errorf("Of(Atoi) = %v, %v", v, err)
+	}
+	if r := Of(strconv.Atoi("x")); r.IsOk() {
+		t.Errorf("Of(Atoi(x)) = %v", r)
+	}
+	if ok.String() != "Ok(2)" || bad.String() != "Err(boom)" {
+		t.Errorf("String: %s, %s", ok, bad)
+	}
+}
+
+func TestMapAndThen(t *testing.T) {
+	calls := 0
+	double := func(n int) int {
+		calls++
+		return 2 * n
+	}
+	if r := Map(Ok(3), double); r.Unwrap() != 6 {
+		t.Errorf("Map(Ok(3)) = %v", r)
+	}
+	if r := Map(Err[int](errBoom), double); r.Err() != errBoom || calls != 1 {
+		t.Errorf("Map(Err) = %v after %d calls", r, calls)
+	}
+	half := func(n int) (int, error) {
+		if n%2 != 0 {
+			return 0, fmt.Errorf("%d is odd", n)
+		}
+		return n / 2, nil
+	}
+	if r := Then(Ok(8), half); r.Unwrap() != 4 {
+		t.Errorf("Then(Ok(8)) = %v", r)
+	}
+	if r := Then(Then(Ok(6), half), half); r.IsOk() || r.Err().Error() != "3 is odd" {
+		t.Errorf("Then(Then(Ok(6))) = %v", r)
+	}
+}
+
+func TestPanics(t *testing.T) {
+	for name, f := range map[string]func(){
+		"Unwrap": func() { Err[int](errBoom).Unwrap() },
+		"Must":   func() { Must(strconv.Atoi("x")) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+	if Must(strconv.Atoi("5")) != 5 {
+		t.Error("Must of a success")
+	}
+}
+
+// source sends the lines as Results, failing the empty ones.
+func source(lines ...string) <-chan Result[string] {
+	out := make(chan Result[string])
+	go func() {
+		defer close(out)
+		for _, l := range lines {
+			if l == "" {
+				out <- Err[string](errors.New("empty line"))
+				continue
+			}
+			out <- Ok(l)
+		}
+	}()
+	return out
+}
+
+func TestStream(t *testing.T) {
+	parsed := Stream(source("1", "", "x", "4"), strconv.Atoi)
+	squared := Stream(parsed, func(n int) (int, error) { return n * n, nil })
+	var got []string
+	for r := range squared {
+		got = append(got, r.String())
+	}
+	want := `[Ok(1) Err(empty line) Err(strconv.Atoi: parsing "x": invalid syntax) Ok(16)]`
+	if fmt.Sprint(got) != want {
+		t.Errorf("got %v\nwant %s", got, want)
+	}
+}
+
+// Where Result helps: a pipeline whose channel carries values and errors
+// together, in order.
+func ExampleStream() {
+	prices := Stream(source("3.50", "oops", "", "12"), func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
+	})
+	withTax := Stream(prices, func(p float64) (string, error) {
+		return fmt.Sprintf("%.2f", p*1.2), nil
+	})
+	for r := range withTax {
+		fmt.Println(r.OrElse("n/a"))
+	}
+	// Output:
+	// 4.20
+	// n/a
+	// n/a
+	// 14.40
+}
+
+// Where Result fights Go: the same three steps as nested closures, and as
+// the plain code every Go reader expects.
+func Example_plainGo() {
+	input := "  42 "
+
+	withResult := Map(
+		Then(Ok(strings.TrimSpace(input)), strconv.Atoi),
+		func(n int) string { return strconv.Itoa(n * 2) },
+	)
+	fmt.Println(withResult)
+
+	plain := func(input string) (string, error) {
+		n, err := strconv.Atoi(strings.TrimSpace(input))
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(n * 2), nil
+	}
+	fmt.Println(plain(input))
+	// Output:
+	// Ok(84)
+	// 84 <nil>
+}
+
+func ExampleMust() {
+	// A pattern that is a literal cannot fail unless the program is wrong.
+	var sku = Must(regexp.Compile(`^[A-Z]{3}-\d{4}$`))
+	fmt.Println(sku.MatchString("ABC-1234"), sku.MatchString("abc"))
+	// Output: true false
+}
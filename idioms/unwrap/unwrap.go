@@ -0,0 +1,81 @@
+// Package unwrap finds capabilities hidden behind decorators.
+//
+// A decorator such as a counting io.Writer or a logging http.Handler has
+// only the methods of its own type, so a type assertion on it cannot see
+// what the value it wraps can do. If every decorator exposes the wrapped
+// value through an Unwrap method, As can walk down the chain and find the
+// first value of the type asked for, just as errors.As does for errors.
+//
+// Unwrap may return any single value, whatever its static type (io.Writer,
+// http.ResponseWriter, http.Handler, ...), or a slice of values for
+// decorators that fan out to several; slices are searched depth first.
+package unwrap
+
+import "reflect"
+
+// Wrapper is a value that may wrap another through an Unwrap method. It is
+// an alias for any because the result type of Unwrap differs between
+// decorators.
+type Wrapper = any
+
+// As returns the first value in the Unwrap chain starting at v, v itself
+// included, that has type T, or implements T if T is an interface.
+func As[T any](v Wrapper) (T, bool) {
+	if v == nil {
+		var zero T
+		return zero, false
+	}
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+	for _, next := range Unwrap(v) {
+		if t, ok := As[T](next); ok {
+			return t, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Chain returns v and every value below it, in the order As visits them.
+func Chain(v Wrapper) []any {
+	if v == nil {
+		return nil
+	}
+	chain := []any{v}
+	for _, next := range Unwrap(v) {
+		chain = append(chain, Chain(next)...)
+	}
+	return chain
+}
+
+// Unwrap returns the values v wraps directly: none, one, or the elements
+// of the slice its Unwrap method returns. Nil results are left out.
+func Unwrap(v Wrapper) []any {
+	m := reflect.ValueOf(v).MethodByName("Unwrap")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil
+	}
+	out := m.Call(nil)[0]
+	if out.Kind() == reflect.Slice {
+		var all []any
+		for i := 0; i < out.Len(); i++ {
+			if e := out.Index(i); !isNil(e) {
+				all = append(all, e.Interface())
+			}
+		}
+		return all
+	}
+	if isNil(out) {
+		return nil
+	}
+	return []any{out.Interface()}
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
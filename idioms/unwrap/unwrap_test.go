@@ -0,0 +1,148 @@
+package unwrap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// countingReader decorates an io.Reader.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func (c *countingReader) Unwrap() io.Reader { return c.r }
+
+// prefixWriter decorates an io.Writer.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	if _, err := io.WriteString(p.w, p.prefix); err != nil {
+		return 0, err
+	}
+	return p.w.Write(b)
+}
+
+func (p *prefixWriter) Unwrap() io.Writer { return p.w }
+
+// teeWriter fans out to several writers.
+type teeWriter struct{ ws []io.Writer }
+
+func (t *teeWriter) Write(b []byte) (int, error) {
+	for _, w := range t.ws {
+		w.Write(b)
+	}
+	return len(b), nil
+}
+
+func (t *teeWriter) Unwrap() []io.Writer { return t.ws }
+
+// logged decorates an http.Handler.
+type logged struct {
+	next http.Handler
+	log  *bytes.Buffer
+}
+
+func (l *logged) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(l.log, r.Method, r.URL.Path)
+	l.next.ServeHTTP(w, r)
+}
+
+func (l *logged) Unwrap() http.Handler { return l.next }
+
+type healthHandler struct{ ready bool }
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if !h.ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func TestDeepReaderChain(t *testing.T) {
+	var r io.Reader = strings.NewReader("payload")
+	for i := 0; i < 100; i++ {
+		r = &countingReader{r: r}
+	}
+
+	sr, ok := As[*strings.Reader](r)
+	if !ok {
+		t.Fatal("did not find the strings.Reader 100 decorators down")
+	}
+	if sr.Len() != 7 {
+		t.Errorf("unexpected reader %v", sr)
+	}
+	if _, ok := As[io.Seeker](r); !ok {
+		t.Error("did not find the io.Seeker capability")
+	}
+	if n := len(Chain(r)); n != 101 {
+		t.Errorf("chain has %d links, want 101", n)
+	}
+	if top, _ := As[*countingReader](r); top != r {
+		t.Error("As should return the outermost match first")
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	var file, net bytes.Buffer
+	rec := httptest.NewRecorder()
+	w := &prefixWriter{w: &teeWriter{ws: []io.Writer{&file, &prefixWriter{w: rec}, &net}}}
+
+	f, ok := As[http.Flusher](w)
+	if !ok || f != rec {
+		t.Fatalf("expected the recorder behind the tee, got %v", f)
+	}
+	if got := len(Chain(w)); got != 6 {
+		t.Errorf("chain has %d links, want 6", got)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	health := &healthHandler{}
+	var h http.Handler = &logged{next: &logged{next: health, log: &bytes.Buffer{}}, log: &bytes.Buffer{}}
+
+	hh, ok := As[*healthHandler](h)
+	if !ok {
+		t.Fatal("did not find the health handler under the middleware")
+	}
+	hh.ready = true
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status %d after marking ready", rec.Code)
+	}
+}
+
+func TestNilAndMissing(t *testing.T) {
+	if _, ok := As[io.Reader](nil); ok {
+		t.Error("nil has no capabilities")
+	}
+	if _, ok := As[io.Seeker](&countingReader{}); ok {
+		t.Error("a nil wrapped reader must end the chain")
+	}
+	if _, ok := As[http.Flusher](&bytes.Buffer{}); ok {
+		t.Error("bytes.Buffer is not a Flusher")
+	}
+}
+
+func ExampleAs() {
+	var r io.Reader = &countingReader{r: &countingReader{r: strings.NewReader("hello")}}
+
+	_, direct := r.(io.Seeker)
+	_, found := As[io.Seeker](r)
+	fmt.Println(direct, found)
+	// Output: false true
+}
@@ -0,0 +1,185 @@
+// Package chaos injects faults into the functions the examples hand to
+// their concurrency and resilience packages: it delays calls, fails them
+// and makes them panic, at random, so that tests can check that retries,
+// breakers and supervisors cope with failures they did not script.
+//
+// The randomness is seeded. A Monkey draws its decisions from its own
+// source, one call after another, so the same seed makes the same
+// sequence of faults; a test that fails prints its seed and fails again
+// with it. When several goroutines share a Monkey the sequence is the
+// same, though which goroutine gets which fault depends on the order in
+// which they call.
+//
+//	m := chaos.New(chaos.Config{Seed: 1, ErrorRate: 0.2, PanicRate: 0.05, MaxLatency: 10 * time.Millisecond})
+//	p.Run(ctx, workerpool.WorkerFunc(m.Wrap(w.Task)))
+//	out := pipeline.Map(p, "parse", 4, in, chaos.Stage(m, parse))
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is the error of the calls a Monkey fails, unless Config.Err
+// says otherwise.
+var ErrInjected = errors.New("chaos: injected failure")
+
+// PanicValue is the value of the panics a Monkey injects.
+const PanicValue = "chaos: injected panic"
+
+// Config says how often a Monkey injects each fault. Rates are
+// probabilities from 0 to 1; a zero Config injects nothing.
+type Config struct {
+	Seed int64
+	// LatencyRate is the probability that a call is delayed, by a
+	// duration drawn evenly from MinLatency to MaxLatency. If it is zero
+	// and a latency is set, every call is delayed.
+	LatencyRate            float64
+	MinLatency, MaxLatency time.Duration
+	// ErrorRate is the probability that a call fails with Err instead of
+	// running.
+	ErrorRate float64
+	// Err defaults to ErrInjected.
+	Err error
+	// PanicRate is the probability that a call panics instead of running.
+	PanicRate float64
+}
+
+func (c *Config) defaults() {
+	if c.MaxLatency < c.MinLatency {
+		c.MaxLatency = c.MinLatency
+	}
+	if c.LatencyRate == 0 && c.MaxLatency > 0 {
+		c.LatencyRate = 1
+	}
+	if c.Err == nil {
+		c.Err = ErrInjected
+	}
+}
+
+// Fault is what a Monkey does to a call after delaying it.
+type Fault int
+
+const (
+	// None lets the call run.
+	None Fault = iota
+	// Error fails the call.
+	Error
+	// Panic makes the call panic.
+	Panic
+)
+
+func (f Fault) String() string {
+	switch f {
+	case Error:
+		return "error"
+	case Panic:
+		return "panic"
+	}
+	return "none"
+}
+
+// Stats count what a Monkey has done.
+type Stats struct {
+	Calls, Delayed, Errors, Panics int
+}
+
+// Monkey injects faults into the calls it wraps. It is safe for
+// concurrent use.
+type Monkey struct {
+	cfg Config
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	stats Stats
+}
+
+// New returns a Monkey injecting the faults of cfg.
+func New(cfg Config) *Monkey {
+	cfg.defaults()
+	return &Monkey{cfg: cfg, rng: rand.New(rand.NewSource(cfg.Seed))}
+}
+
+// Next decides the delay and fault of the next call and counts them.
+// Every decision takes the same number of draws from the source, so
+// changing one rate does not shift the decisions of the other faults.
+func (m *Monkey) Next() (time.Duration, Fault) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delayed := m.rng.Float64() < m.cfg.LatencyRate
+	spread := m.rng.Int63n(int64(m.cfg.MaxLatency-m.cfg.MinLatency) + 1)
+	roll := m.rng.Float64()
+
+	m.stats.Calls++
+	var d time.Duration
+	if delayed {
+		d = m.cfg.MinLatency + time.Duration(spread)
+		m.stats.Delayed++
+	}
+	f := None
+	switch {
+	case roll < m.cfg.PanicRate:
+		f = Panic
+		m.stats.Panics++
+	case roll < m.cfg.PanicRate+m.cfg.ErrorRate:
+		f = Error
+		m.stats.Errors++
+	}
+	return d, f
+}
+
+// Stats returns what m has done so far.
+func (m *Monkey) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// inject delays the call and applies its fault. It returns the error to
+// fail the call with, ctx's if ctx ends during the delay, or nil to let
+// it run.
+func (m *Monkey) inject(ctx context.Context) error {
+	d, f := m.Next()
+	if d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+	switch f {
+	case Error:
+		return m.cfg.Err
+	case Panic:
+		panic(PanicValue)
+	}
+	return nil
+}
+
+// Wrap returns fn with faults injected. It fits a workerpool.WorkerFunc,
+// a circuit breaker's circuit, a retried operation and a supervised
+// child's Run.
+func (m *Monkey) Wrap(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := m.inject(ctx); err != nil {
+			return err
+		}
+		return fn(ctx)
+	}
+}
+
+// Stage returns the function of a pipeline stage with faults injected.
+func Stage[T, U any](m *Monkey, fn func(ctx context.Context, v T) (U, error)) func(ctx context.Context, v T) (U, error) {
+	return func(ctx context.Context, v T) (U, error) {
+		if err := m.inject(ctx); err != nil {
+			var zero U
+			return zero, err
+		}
+		return fn(ctx, v)
+	}
+}
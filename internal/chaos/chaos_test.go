@@ -0,0 +1,174 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/concurrency/pipeline"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func faults(m *Monkey, n int) []Fault {
+	fs := make([]Fault, n)
+	for i := range fs {
+		_, fs[i] = m.Next()
+	}
+	return fs
+}
+
+func TestSeedReplays(t *testing.T) {
+	cfg := Config{Seed: 42, ErrorRate: 0.3, PanicRate: 0.1, MaxLatency: time.Second}
+	a, b := New(cfg), New(cfg)
+	if fa, fb := faults(a, 100), faults(b, 100); !slices.Equal(fa, fb) {
+		t.Errorf("same seed, different faults:\n%v\n%v", fa, fb)
+	}
+	cfg.Seed = 43
+	if slices.Equal(faults(New(cfg), 100), faults(New(Config{Seed: 42, ErrorRate: 0.3, PanicRate: 0.1}), 100)) {
+		t.Error("different seeds, same faults")
+	}
+}
+
+func TestRates(t *testing.T) {
+	const n = 10000
+	m := New(Config{Seed: 1, ErrorRate: 0.2, PanicRate: 0.05, LatencyRate: 0.5, MinLatency: time.Millisecond, MaxLatency: 3 * time.Millisecond})
+	for range n {
+		if d, _ := m.Next(); d != 0 && (d < time.Millisecond || d > 3*time.Millisecond) {
+			t.Fatalf("delay %v out of range", d)
+		}
+	}
+	s := m.Stats()
+	near := func(got int, rate float64) bool { return math.Abs(float64(got)/n-rate) < 0.02 }
+	if s.Calls != n || !near(s.Errors, 0.2) || !near(s.Panics, 0.05) || !near(s.Delayed, 0.5) {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestZeroConfigInjectsNothing(t *testing.T) {
+	m := New(Config{})
+	for range 100 {
+		if d, f := m.Next(); d != 0 || f != None {
+			t.Fatalf("got %v, %v", d, f)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	m := New(Config{Seed: 7, ErrorRate: 0.3, PanicRate: 0.2})
+	var ran int
+	fn := m.Wrap(func(context.Context) error { ran++; return nil })
+	var errs, panics int
+	for range 200 {
+		err := recovery.Do(func() error { return fn(context.Background()) })
+		var pe *recovery.PanicError
+		switch {
+		case errors.As(err, &pe) && pe.Value == PanicValue:
+			panics++
+		case errors.Is(err, ErrInjected):
+			errs++
+		case err != nil:
+			t.Fatalf("unexpected %v", err)
+		}
+	}
+	s := m.Stats()
+	if errs != s.Errors || panics != s.Panics || ran != s.Calls-s.Errors-s.Panics {
+		t.Errorf("%d errors, %d panics, %d ran; stats %+v", errs, panics, ran, s)
+	}
+}
+
+func TestDelayEndsWithContext(t *testing.T) {
+	m := New(Config{MinLatency: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var ran bool
+	err := m.Wrap(func(context.Context) error { ran = true; return nil })(ctx)
+	if err != context.DeadlineExceeded || ran {
+		t.Errorf("got %v, ran %v", err, ran)
+	}
+}
+
+func TestCustomErr(t *testing.T) {
+	unavailable := errors.New("503")
+	m := New(Config{ErrorRate: 1, Err: unavailable})
+	if err := m.Wrap(func(context.Context) error { return nil })(context.Background()); err != unavailable {
+		t.Errorf("got %v", err)
+	}
+}
+
+// A pool survives tasks that panic and fail at random: every task is
+// accounted for and the pool keeps running.
+func TestPool(t *testing.T) {
+	m := New(Config{Seed: 3, ErrorRate: 0.2, PanicRate: 0.1, MaxLatency: time.Millisecond})
+	p := workerpool.New(4)
+	defer p.Shutdown()
+	var ok atomic.Int32
+	task := workerpool.WorkerFunc(m.Wrap(func(context.Context) error { ok.Add(1); return nil }))
+	var failed, panicked int
+	for range 100 {
+		err := p.Run(context.Background(), task)
+		var pe *recovery.PanicError
+		switch {
+		case errors.As(err, &pe):
+			panicked++
+		case err != nil:
+			failed++
+		}
+	}
+	s := m.Stats()
+	if failed != s.Errors || panicked != s.Panics || int(ok.Load())+failed+panicked != 100 {
+		t.Errorf("%d ok, %d failed, %d panicked; stats %+v", ok.Load(), failed, panicked, s)
+	}
+}
+
+// An injected fault fails the stage and so the pipeline, with the stage's
+// name on the error.
+func TestStage(t *testing.T) {
+	m := New(Config{Seed: 5, ErrorRate: 0.1})
+	p := pipeline.New(context.Background(), pipeline.Options{})
+	nums := pipeline.Source(p, "count", func(_ context.Context, emit func(int) bool) error {
+		for i := 0; i < 1000 && emit(i); i++ {
+		}
+		return nil
+	})
+	double := pipeline.Map(p, "double", 2, nums, Stage(m, func(_ context.Context, v int) (int, error) {
+		return 2 * v, nil
+	}))
+	pipeline.Sink(p, "drop", double, func(context.Context, int) error { return nil })
+	err := p.Wait()
+	if !errors.Is(err, ErrInjected) || err.Error() != "pipeline: double: "+ErrInjected.Error() {
+		t.Errorf("Wait = %v", err)
+	}
+}
+
+func Example() {
+	m := New(Config{Seed: 1, ErrorRate: 0.25, PanicRate: 0.25})
+	fetch := m.Wrap(func(context.Context) error { return nil })
+	for range 8 {
+		err := recovery.Do(func() error { return fetch(context.Background()) })
+		var pe *recovery.PanicError
+		if errors.As(err, &pe) {
+			err = fmt.Errorf("panic: %v", pe.Value)
+		}
+		fmt.Println(err)
+	}
+	fmt.Printf("%+v\n", m.Stats())
+	// Output:
+	// <nil>
+	// <nil>
+	// panic: chaos: injected panic
+	// <nil>
+	// chaos: injected failure
+	// chaos: injected failure
+	// panic: chaos: injected panic
+	// <nil>
+	// {Calls:8 Delayed:0 Errors:2 Panics:2}
+}
@@ -0,0 +1,170 @@
+// Package obs is the structured logging the examples share: a log/slog
+// logger whose records carry the attributes found in their context, such
+// as the task and the worker a line was logged from, and hooks for
+// workerpool, scheduler and pipeline that put those attributes there.
+//
+// Code running as a pool task, a scheduled job or a pipeline stage logs
+// with the context it was given and gets the attributes without passing
+// them along:
+//
+//	log := obs.New(os.Stderr, slog.LevelInfo)
+//	p := workerpool.NewWith(4, workerpool.Options{Hooks: obs.PoolHooks{Logger: log}})
+//	...
+//	log.InfoContext(ctx, "resized", "file", name) // ... file=a.png task_id=7 worker_id=2
+//
+// The hooks log every task, run or item starting and ending at debug
+// level, and failing at error level.
+package obs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/pipeline"
+	"github.com/crazybber/go-patterns/concurrency/scheduler"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// The attribute keys the hooks use.
+const (
+	TaskKey   = "task_id"
+	WorkerKey = "worker_id"
+	JobKey    = "job"
+	RunKey    = "run"
+	StageKey  = "stage"
+	ItemKey   = "item"
+)
+
+type attrsKey struct{}
+
+// With returns a copy of ctx carrying attrs after the ones ctx already
+// carries.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, attrsKey{}, append(slices.Clip(Attrs(ctx)), attrs...))
+}
+
+// Attrs returns the attributes ctx carries.
+func Attrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// WithTask returns a copy of ctx carrying the task ID.
+func WithTask(ctx context.Context, id uint64) context.Context {
+	return With(ctx, slog.Uint64(TaskKey, id))
+}
+
+// WithWorker returns a copy of ctx carrying the worker ID.
+func WithWorker(ctx context.Context, id int) context.Context {
+	return With(ctx, slog.Int(WorkerKey, id))
+}
+
+// Handler adds the attributes of a record's context to the record before
+// passing it on.
+type Handler struct {
+	next slog.Handler
+}
+
+// NewHandler wraps next.
+func NewHandler(next slog.Handler) *Handler { return &Handler{next} }
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := Attrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{h.next.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{h.next.WithGroup(name)}
+}
+
+// New returns a logger writing text records of level and above to w,
+// with the attributes of their context.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(NewHandler(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+func logEnd(ctx context.Context, l *slog.Logger, what string, err error, d time.Duration) {
+	if err != nil {
+		l.ErrorContext(ctx, what+" failed", "err", err, "duration", d)
+		return
+	}
+	l.DebugContext(ctx, what+" done", "duration", d)
+}
+
+// PoolHooks are workerpool.Hooks that tag every task's context with its
+// task and worker IDs and log it.
+type PoolHooks struct {
+	Logger *slog.Logger
+}
+
+var _ workerpool.Hooks = PoolHooks{}
+
+// TaskStart implements workerpool.Hooks.
+func (h PoolHooks) TaskStart(ctx context.Context, worker int, task uint64) context.Context {
+	ctx = WithWorker(WithTask(ctx, task), worker)
+	h.Logger.DebugContext(ctx, "task started")
+	return ctx
+}
+
+// TaskEnd implements workerpool.Hooks.
+func (h PoolHooks) TaskEnd(ctx context.Context, err error, d time.Duration) {
+	logEnd(ctx, h.Logger, "task", err, d)
+}
+
+// SchedulerHooks are scheduler.Hooks that tag every run's context with
+// the job's name and the run's number and log it.
+type SchedulerHooks struct {
+	Logger *slog.Logger
+}
+
+var _ scheduler.Hooks = SchedulerHooks{}
+
+// RunStart implements scheduler.Hooks.
+func (h SchedulerHooks) RunStart(ctx context.Context, job string, run int) context.Context {
+	ctx = With(ctx, slog.String(JobKey, job), slog.Int(RunKey, run))
+	h.Logger.DebugContext(ctx, "run started")
+	return ctx
+}
+
+// RunEnd implements scheduler.Hooks.
+func (h SchedulerHooks) RunEnd(ctx context.Context, d time.Duration) {
+	logEnd(ctx, h.Logger, "run", nil, d)
+}
+
+// PipelineHooks are pipeline.Hooks that tag every item's context with the
+// stage, the worker and the item's number and log it.
+type PipelineHooks struct {
+	Logger *slog.Logger
+}
+
+var _ pipeline.Hooks = PipelineHooks{}
+
+// ItemStart implements pipeline.Hooks.
+func (h PipelineHooks) ItemStart(ctx context.Context, stage string, worker int, seq uint64) context.Context {
+	ctx = With(ctx, slog.String(StageKey, stage), slog.Int(WorkerKey, worker), slog.Uint64(ItemKey, seq))
+	h.Logger.DebugContext(ctx, "item started")
+	return ctx
+}
+
+// ItemEnd implements pipeline.Hooks.
+func (h PipelineHooks) ItemEnd(ctx context.Context, err error, d time.Duration) {
+	logEnd(ctx, h.Logger, "item", err, d)
+}
@@ -0,0 +1,140 @@
+package obs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/concurrency/pipeline"
+	"github.com/crazybber/go-patterns/concurrency/scheduler"
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+// newTest returns a logger writing debug records to w without the time
+// and durations, which change from run to run.
+func newTest(w *bytes.Buffer) *slog.Logger {
+	return slog.New(NewHandler(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+}
+
+func TestContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTest(&buf).With("app", "demo")
+	ctx := WithWorker(WithTask(context.Background(), 7), 2)
+	child := With(ctx, slog.String("file", "a.png"))
+	With(ctx, slog.String("file", "b.png")) // must not change child
+	log.InfoContext(child, "resized", "px", 64)
+	log.WithGroup("g").InfoContext(context.Background(), "plain", "k", 1)
+	want := "level=INFO msg=resized app=demo px=64 task_id=7 worker_id=2 file=a.png\n" +
+		"level=INFO msg=plain app=demo g.k=1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+}
+
+func TestPoolHooks(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTest(&buf)
+	p := workerpool.NewWith(1, workerpool.Options{Hooks: PoolHooks{log}})
+	ctx := context.Background()
+	p.Run(ctx, workerpool.WorkerFunc(func(ctx context.Context) error {
+		log.InfoContext(ctx, "working")
+		return nil
+	}))
+	p.Run(ctx, workerpool.WorkerFunc(func(context.Context) error { return errors.New("disk full") }))
+	p.Shutdown()
+	want := `level=DEBUG msg="task started" task_id=1 worker_id=0
+level=INFO msg=working task_id=1 worker_id=0
+level=DEBUG msg="task done" task_id=1 worker_id=0
+level=DEBUG msg="task started" task_id=2 worker_id=0
+level=ERROR msg="task failed" err="disk full" task_id=2 worker_id=0
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+}
+
+func TestSchedulerHooks(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTest(&buf)
+	c := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := scheduler.NewWith(c, scheduler.Options{Hooks: SchedulerHooks{log}})
+	ran := make(chan struct{})
+	s.Add("cleanup", scheduler.Every(time.Minute), scheduler.Skip, func(ctx context.Context) {
+		log.InfoContext(ctx, "cleaning")
+		close(ran)
+	})
+	s.Start()
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	<-ran
+	s.Stop()
+	want := `level=DEBUG msg="run started" job=cleanup run=1
+level=INFO msg=cleaning job=cleanup run=1
+level=DEBUG msg="run done" job=cleanup run=1
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+}
+
+func TestPipelineHooks(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTest(&buf)
+	p := pipeline.New(context.Background(), pipeline.Options{Hooks: PipelineHooks{log}})
+	pipeline.Sink(p, "store", pipeline.From(p, "a"), func(ctx context.Context, v string) error {
+		log.InfoContext(ctx, "storing", "v", v)
+		return nil
+	})
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, "level=INFO msg=storing v=a stage=store worker_id=0 item=1\n") {
+		t.Errorf("got\n%s", got)
+	}
+}
+
+// Every line a task logs says which task and worker it came from,
+// without the task passing them along.
+func Example() {
+	// New(os.Stdout, slog.LevelInfo), without the time.
+	log := slog.New(NewHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+
+	p := workerpool.NewWith(1, workerpool.Options{Hooks: PoolHooks{log}})
+	defer p.Shutdown()
+	for _, file := range []string{"a.png", "b.png"} {
+		p.Run(context.Background(), workerpool.WorkerFunc(func(ctx context.Context) error {
+			log.InfoContext(ctx, "resized", "file", file)
+			return nil
+		}))
+	}
+	fmt.Println("done")
+	// Output:
+	// level=INFO msg=resized file=a.png task_id=1 worker_id=0
+	// level=INFO msg=resized file=b.png task_id=2 worker_id=0
+	// done
+}
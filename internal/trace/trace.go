@@ -0,0 +1,280 @@
+// Package trace is a small span model in the manner of OpenTelemetry, for
+// the examples to show how trace context travels with the work across
+// goroutines, without the dependency.
+//
+// A Span is an operation with a start and an end. Tracer.Start makes the
+// span in a context the parent of the new one, and returns a context
+// carrying the new span, so that passing contexts down calls, and along
+// with the work handed to other goroutines, builds the tree. The hooks
+// for workerpool, pipeline and saga start a span for every task, item and
+// step, as a child of whatever span the context the work came with holds:
+// a task's span hangs under the span of the code that called Run, on
+// whatever pool goroutine it ran.
+//
+//	tr := trace.New(rec)
+//	p := workerpool.NewWith(4, workerpool.Options{Hooks: trace.PoolHooks{Tracer: tr}})
+//	ctx, span := tr.Start(ctx, "resize album")
+//	p.Run(ctx, resize) // its span is a child of "resize album"
+//	span.End()
+//
+// Ended spans go to an Exporter. Recorder keeps them in memory and prints
+// them as a tree, which is what tests compare.
+package trace
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/saga"
+	"github.com/crazybber/go-patterns/concurrency/pipeline"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// Attr is a key and value describing a span.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// Span is one operation of a trace.
+type Span struct {
+	Name string
+	// TraceID is the ID of the trace's root span. Parent is zero for a
+	// root.
+	TraceID, ID, Parent uint64
+	StartTime, EndTime  time.Time
+
+	tracer *Tracer
+	mu     sync.Mutex
+	attrs  []Attr
+	err    error
+	ended  bool
+}
+
+// SetAttr adds an attribute to s.
+func (s *Span) SetAttr(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, Attr{key, value})
+}
+
+// SetError records that the operation failed with err. A nil err leaves
+// s as it was.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Attrs returns the attributes of s in the order they were set.
+func (s *Span) Attrs() []Attr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.attrs)
+}
+
+// Err returns the error of s.
+func (s *Span) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// End ends s and exports it. Calls after the first do nothing.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = s.tracer.now()
+	s.mu.Unlock()
+	s.tracer.exporter.Export(s)
+}
+
+// Exporter receives every span once it has ended.
+type Exporter interface {
+	Export(s *Span)
+}
+
+// Tracer starts spans. Span IDs count from 1 in the order spans start.
+type Tracer struct {
+	exporter Exporter
+	now      func() time.Time
+	ids      atomic.Uint64
+}
+
+// New returns a Tracer exporting to e.
+func New(e Exporter) *Tracer {
+	return &Tracer{exporter: e, now: time.Now}
+}
+
+type spanKey struct{}
+
+// FromContext returns the span ctx carries, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWith returns a copy of ctx carrying s, for handing a span to
+// work whose context does not come from the one the span was started in,
+// such as a goroutine started with context.Background.
+func ContextWith(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// Start starts a span named name, the child of the span ctx carries, if
+// any, and returns it with a copy of ctx carrying it.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	s := &Span{Name: name, ID: t.ids.Add(1), StartTime: t.now(), tracer: t, attrs: attrs}
+	s.TraceID = s.ID
+	if parent := FromContext(ctx); parent != nil {
+		s.TraceID, s.Parent = parent.TraceID, parent.ID
+	}
+	return ContextWith(ctx, s), s
+}
+
+// end records err in the span ctx carries and finishes it.
+func end(ctx context.Context, err error) {
+	if s := FromContext(ctx); s != nil {
+		s.SetError(err)
+		s.End()
+	}
+}
+
+// Recorder is an Exporter that keeps the spans in memory.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// Export implements Exporter.
+func (r *Recorder) Export(s *Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+// Spans returns the spans exported so far, in the order they started.
+func (r *Recorder) Spans() []*Span {
+	r.mu.Lock()
+	spans := slices.Clone(r.spans)
+	r.mu.Unlock()
+	slices.SortFunc(spans, func(a, b *Span) int { return cmp.Compare(a.ID, b.ID) })
+	return spans
+}
+
+// WriteTree prints the spans exported so far as trees, a span per line
+// indented under its parent, siblings in the order they started. A span
+// whose parent has not ended yet is printed as a root. Times are left
+// out, so that the output of a test is the same from run to run.
+func (r *Recorder) WriteTree(w io.Writer) error {
+	spans := r.Spans()
+	ended := make(map[uint64]bool, len(spans))
+	for _, s := range spans {
+		ended[s.ID] = true
+	}
+	children := make(map[uint64][]*Span)
+	var roots []*Span
+	for _, s := range spans {
+		if s.Parent == 0 || !ended[s.Parent] {
+			roots = append(roots, s)
+			continue
+		}
+		children[s.Parent] = append(children[s.Parent], s)
+	}
+	var b strings.Builder
+	var write func(s *Span, depth int)
+	write = func(s *Span, depth int) {
+		b.WriteString(strings.Repeat("  ", depth))
+		b.WriteString(s.Name)
+		for _, a := range s.Attrs() {
+			fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		}
+		if err := s.Err(); err != nil {
+			fmt.Fprintf(&b, " error=%q", err)
+		}
+		b.WriteByte('\n')
+		for _, c := range children[s.ID] {
+			write(c, depth+1)
+		}
+	}
+	for _, s := range roots {
+		write(s, 0)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Tree returns what WriteTree prints.
+func (r *Recorder) Tree() string {
+	var b strings.Builder
+	r.WriteTree(&b)
+	return b.String()
+}
+
+// PoolHooks are workerpool.Hooks that start a span for every task.
+type PoolHooks struct {
+	Tracer *Tracer
+}
+
+var _ workerpool.Hooks = PoolHooks{}
+
+// TaskStart implements workerpool.Hooks.
+func (h PoolHooks) TaskStart(ctx context.Context, worker int, task uint64) context.Context {
+	ctx, _ = h.Tracer.Start(ctx, "task", Attr{"task", task}, Attr{"worker", worker})
+	return ctx
+}
+
+// TaskEnd implements workerpool.Hooks.
+func (h PoolHooks) TaskEnd(ctx context.Context, err error, _ time.Duration) { end(ctx, err) }
+
+// PipelineHooks are pipeline.Hooks that start a span for every item a
+// stage handles. The spans of a pipeline are children of the span in the
+// context it was created with.
+type PipelineHooks struct {
+	Tracer *Tracer
+}
+
+var _ pipeline.Hooks = PipelineHooks{}
+
+// ItemStart implements pipeline.Hooks.
+func (h PipelineHooks) ItemStart(ctx context.Context, stage string, worker int, seq uint64) context.Context {
+	ctx, _ = h.Tracer.Start(ctx, stage, Attr{"item", seq}, Attr{"worker", worker})
+	return ctx
+}
+
+// ItemEnd implements pipeline.Hooks.
+func (h PipelineHooks) ItemEnd(ctx context.Context, err error, _ time.Duration) { end(ctx, err) }
+
+// SagaHooks are saga.Hooks that start a span for every step and
+// compensation.
+type SagaHooks struct {
+	Tracer *Tracer
+}
+
+var _ saga.Hooks = SagaHooks{}
+
+// StepStart implements saga.Hooks.
+func (h SagaHooks) StepStart(ctx context.Context, step string, undo bool) context.Context {
+	if undo {
+		step = "undo " + step
+	}
+	ctx, _ = h.Tracer.Start(ctx, step)
+	return ctx
+}
+
+// StepEnd implements saga.Hooks.
+func (h SagaHooks) StepEnd(ctx context.Context, err error) { end(ctx, err) }
@@ -0,0 +1,166 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/crazybber/go-patterns/architecture/saga"
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/concurrency/pipeline"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+func TestMain(m *testing.M) { leaks.VerifyTestMain(m) }
+
+func TestParentPropagation(t *testing.T) {
+	rec := &Recorder{}
+	tr := New(rec)
+	ctx, root := tr.Start(context.Background(), "request", Attr{"path", "/orders"})
+	ctx2, child := tr.Start(ctx, "load")
+	_, grandchild := tr.Start(ctx2, "query")
+	grandchild.SetError(errors.New("timeout"))
+	grandchild.End()
+	child.End()
+	child.End() // ends once
+	// A goroutine started without the request's context is given its span.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, s := tr.Start(ContextWith(context.Background(), root), "audit")
+		s.SetAttr("async", true)
+		s.End()
+	}()
+	<-done
+	_, other := tr.Start(context.Background(), "tick")
+	other.End()
+	root.End()
+
+	want := `request path=/orders
+  load
+    query error="timeout"
+  audit async=true
+tick
+`
+	if got := rec.Tree(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+	spans := rec.Spans()
+	if len(spans) != 5 || spans[2].TraceID != root.ID || other.TraceID != other.ID || other.Parent != 0 {
+		t.Errorf("IDs %+v", spans)
+	}
+	if root.EndTime.Before(root.StartTime) {
+		t.Errorf("times %v %v", root.StartTime, root.EndTime)
+	}
+}
+
+// A span whose parent has not ended is printed as a root, not lost.
+func TestUnendedParent(t *testing.T) {
+	rec := &Recorder{}
+	tr := New(rec)
+	ctx, _ := tr.Start(context.Background(), "open")
+	_, s := tr.Start(ctx, "child")
+	s.End()
+	if got := rec.Tree(); got != "child\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// Tasks run on pool goroutines, yet their spans hang under the span of
+// the code that submitted them.
+func TestPoolHooks(t *testing.T) {
+	rec := &Recorder{}
+	tr := New(rec)
+	p := workerpool.NewWith(1, workerpool.Options{Hooks: PoolHooks{tr}})
+	defer p.Shutdown()
+	ctx, root := tr.Start(context.Background(), "resize album")
+	for _, name := range []string{"a.png", "b.png"} {
+		p.Run(ctx, workerpool.WorkerFunc(func(ctx context.Context) error {
+			_, s := tr.Start(ctx, "decode", Attr{"file", name})
+			s.End()
+			if name == "b.png" {
+				return errors.New("corrupt")
+			}
+			return nil
+		}))
+	}
+	root.End()
+	want := `resize album
+  task task=1 worker=0
+    decode file=a.png
+  task task=2 worker=0 error="corrupt"
+    decode file=b.png
+`
+	if got := rec.Tree(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+}
+
+func TestPipelineHooks(t *testing.T) {
+	rec := &Recorder{}
+	tr := New(rec)
+	ctx, root := tr.Start(context.Background(), "import")
+	p := pipeline.New(ctx, pipeline.Options{Hooks: PipelineHooks{tr}})
+	parsed := pipeline.Map(p, "parse", 1, pipeline.From(p, "1", "x"), func(_ context.Context, s string) (int, error) {
+		return strconv.Atoi(s)
+	})
+	pipeline.Sink(p, "store", parsed, func(context.Context, int) error { return nil })
+	p.Wait()
+	root.End()
+	got := rec.Tree()
+	for _, line := range []string{
+		"import\n",
+		"  parse item=1 worker=0\n",
+		"  store item=1 worker=0\n",
+		"  parse item=2 worker=0 error=\"strconv.Atoi: parsing \\\"x\\\": invalid syntax\"\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("no %q in\n%s", line, got)
+		}
+	}
+}
+
+func TestSagaHooks(t *testing.T) {
+	rec := &Recorder{}
+	tr := New(rec)
+	step := func(name string, err error) saga.Step[int] {
+		nop := func(context.Context, int) error { return nil }
+		return saga.Step[int]{Name: name, Do: func(context.Context, int) error { return err }, Undo: nop}
+	}
+	s := saga.New(saga.Options{Hooks: SagaHooks{tr}}, step("reserve", nil), step("charge", errors.New("declined")))
+	ctx, root := tr.Start(context.Background(), "place order")
+	s.Run(ctx, 0)
+	root.End()
+	want := `place order
+  reserve
+  charge error="declined"
+  undo charge
+  undo reserve
+`
+	if got := rec.Tree(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+}
+
+func Example() {
+	rec := &Recorder{}
+	tr := New(rec)
+	p := workerpool.NewWith(1, workerpool.Options{Hooks: PoolHooks{tr}})
+	defer p.Shutdown()
+
+	ctx, span := tr.Start(context.Background(), "handle request")
+	p.Run(ctx, workerpool.WorkerFunc(func(ctx context.Context) error {
+		_, s := tr.Start(ctx, "query")
+		defer s.End()
+		return nil
+	}))
+	span.End()
+	fmt.Print(rec.Tree())
+	// Output:
+	// handle request
+	//   task task=1 worker=0
+	//     query
+}
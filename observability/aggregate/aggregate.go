@@ -0,0 +1,117 @@
+// Package aggregate keeps high-frequency counters cheap to update. Every
+// writer goroutine gets its own shard, padded to a cache line, so updates
+// never contend; a background flusher folds the shards into the total on
+// an interval. Reads see the total as of the last flush, or force a flush
+// with Snapshot. Freshness is what is traded for the missing contention.
+package aggregate
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheLine is the assumed size of a CPU cache line.
+const cacheLine = 64
+
+// Shard is one writer's share of a Counter. It must only be updated by a
+// single goroutine at a time, which is what makes it contention free.
+type Shard struct {
+	v int64
+	_ [cacheLine - 8]byte
+}
+
+// Add adds n to the shard.
+func (s *Shard) Add(n int64) {
+	atomic.AddInt64(&s.v, n)
+}
+
+// Inc adds one to the shard.
+func (s *Shard) Inc() {
+	atomic.AddInt64(&s.v, 1)
+}
+
+// Counter is a sharded counter with a periodic flusher.
+type Counter struct {
+	mu      sync.Mutex
+	shards  []*Shard
+	total   int64
+	flushes int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a counter. Call Start to flush it in the background.
+func New() *Counter {
+	return &Counter{}
+}
+
+// Shard registers and returns a new shard for one writer goroutine.
+func (c *Counter) Shard() *Shard {
+	s := new(Shard)
+	c.mu.Lock()
+	c.shards = append(c.shards, s)
+	c.mu.Unlock()
+	return s
+}
+
+// Start flushes the shards every interval until Stop is called.
+func (c *Counter) Start(interval time.Duration) {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.flush()
+			case <-c.stop:
+				c.flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the flusher after a final flush.
+func (c *Counter) Stop() {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+	c.stop = nil
+}
+
+// flush moves every shard's value into the total.
+func (c *Counter) flush() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.shards {
+		c.total += atomic.SwapInt64(&s.v, 0)
+	}
+	c.flushes++
+	return c.total
+}
+
+// Value returns the total as of the last flush.
+func (c *Counter) Value() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// Snapshot flushes the shards and returns the up to date total.
+func (c *Counter) Snapshot() int64 {
+	return c.flush()
+}
+
+// Flushes returns how many times the shards have been merged.
+func (c *Counter) Flushes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flushes
+}
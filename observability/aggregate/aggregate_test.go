@@ -0,0 +1,112 @@
+package aggregate
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSnapshotSeesEveryUpdate(t *testing.T) {
+	c := New()
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(s *Shard) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				s.Inc()
+			}
+		}(c.Shard())
+	}
+	wg.Wait()
+
+	if v := c.Value(); v != 0 {
+		t.Errorf("nothing was flushed yet, Value = %d", v)
+	}
+	if v := c.Snapshot(); v != 8000 {
+		t.Errorf("Snapshot = %d, want 8000", v)
+	}
+}
+
+func TestBackgroundFlush(t *testing.T) {
+	c := New()
+	s := c.Shard()
+	c.Start(time.Millisecond)
+
+	s.Add(5)
+	deadline := time.Now().Add(time.Second)
+	for c.Value() != 5 {
+		if time.Now().After(deadline) {
+			t.Fatal("flusher never merged the shard")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	s.Add(2)
+	c.Stop()
+	if v := c.Value(); v != 7 {
+		t.Errorf("Stop must flush once more, Value = %d", v)
+	}
+}
+
+func TestFlushDuringWrites(t *testing.T) {
+	c := New()
+	c.Start(100 * time.Microsecond)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(s *Shard) {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				s.Add(2)
+			}
+		}(c.Shard())
+	}
+	wg.Wait()
+	c.Stop()
+
+	if v := c.Value(); v != 80000 {
+		t.Errorf("Value = %d, want 80000", v)
+	}
+	if c.Flushes() < 2 {
+		t.Errorf("expected several flushes, got %d", c.Flushes())
+	}
+}
+
+const writers = 64
+
+// runWriters splits b.N updates over 64 goroutines.
+func runWriters(b *testing.B, add func(w int) func()) {
+	per := b.N/writers + 1
+	var wg sync.WaitGroup
+	b.ResetTimer()
+	for w := 0; w < writers; w++ {
+		inc := add(w)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < per; i++ {
+				inc()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkAtomicCounter64Writers(b *testing.B) {
+	var v int64
+	runWriters(b, func(int) func() {
+		return func() { atomic.AddInt64(&v, 1) }
+	})
+}
+
+func BenchmarkShardedCounter64Writers(b *testing.B) {
+	c := New()
+	c.Start(10 * time.Millisecond)
+	defer c.Stop()
+	runWriters(b, func(int) func() {
+		return c.Shard().Inc
+	})
+}
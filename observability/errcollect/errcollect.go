@@ -0,0 +1,248 @@
+// Package errcollect is a small in-process error tracker. Subsystems hand
+// their errors to a Collector, which groups them by fingerprint, counts
+// them and keeps a few recent exemplars per group. The report is available
+// as a value and, through ServeHTTP, as JSON.
+//
+// A fingerprint is derived from the shape of the wrapped error chain and
+// from the function names on the stack where the error was collected (or
+// where it was created, when the error carries its own stack). Messages
+// only contribute with their digits masked, so "timeout after 31ms" and
+// "timeout after 250ms" from the same place land in the same group.
+package errcollect
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures a Collector.
+type Options struct {
+	// Exemplars is the number of recent occurrences kept per group.
+	// Defaults to 5.
+	Exemplars int
+	// StackDepth limits how many frames take part in the fingerprint.
+	// Defaults to 16.
+	StackDepth int
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Exemplar is one recorded occurrence of an error.
+type Exemplar struct {
+	Source  string    `json:"source"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Group aggregates all errors that share a fingerprint.
+type Group struct {
+	Fingerprint string         `json:"fingerprint"`
+	Chain       []string       `json:"chain"`
+	Stack       []string       `json:"stack"`
+	Count       int            `json:"count"`
+	Sources     map[string]int `json:"sources"`
+	FirstSeen   time.Time      `json:"first_seen"`
+	LastSeen    time.Time      `json:"last_seen"`
+	Exemplars   []Exemplar     `json:"exemplars"`
+}
+
+// Collector groups errors by fingerprint. It is safe for concurrent use.
+type Collector struct {
+	opts Options
+
+	mu     sync.Mutex
+	groups map[string]*Group
+}
+
+// New returns an empty collector.
+func New(opts Options) *Collector {
+	if opts.Exemplars <= 0 {
+		opts.Exemplars = 5
+	}
+	if opts.StackDepth <= 0 {
+		opts.StackDepth = 16
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Collector{opts: opts, groups: make(map[string]*Group)}
+}
+
+// Collect records err as reported by source and returns its fingerprint.
+// A nil error is ignored and yields an empty fingerprint.
+func (c *Collector) Collect(source string, err error) string {
+	if err == nil {
+		return ""
+	}
+	return c.collect(source, err)
+}
+
+// Reporter returns a function that collects errors for source. It fits
+// the error hooks of the other packages in this repository.
+func (c *Collector) Reporter(source string) func(error) {
+	return func(err error) {
+		if err != nil {
+			c.collect(source, err)
+		}
+	}
+}
+
+// collect must be called directly from an exported method so that the
+// stack starts at that method's caller.
+func (c *Collector) collect(source string, err error) string {
+	chain := Chain(err)
+	stack := frames(callers(err), c.opts.StackDepth)
+	fp := fingerprint(chain, stack)
+	now := c.opts.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g, ok := c.groups[fp]
+	if !ok {
+		g = &Group{
+			Fingerprint: fp,
+			Chain:       chain,
+			Stack:       stack,
+			Sources:     make(map[string]int),
+			FirstSeen:   now,
+		}
+		c.groups[fp] = g
+	}
+	g.Count++
+	g.Sources[source]++
+	g.LastSeen = now
+	g.Exemplars = append(g.Exemplars, Exemplar{Source: source, Message: err.Error(), Time: now})
+	if len(g.Exemplars) > c.opts.Exemplars {
+		g.Exemplars = g.Exemplars[len(g.Exemplars)-c.opts.Exemplars:]
+	}
+	return fp
+}
+
+// Report returns a copy of all groups, most frequent first.
+func (c *Collector) Report() []Group {
+	c.mu.Lock()
+	report := make([]Group, 0, len(c.groups))
+	for _, g := range c.groups {
+		cp := *g
+		cp.Sources = make(map[string]int, len(g.Sources))
+		for s, n := range g.Sources {
+			cp.Sources[s] = n
+		}
+		cp.Exemplars = append([]Exemplar(nil), g.Exemplars...)
+		report = append(report, cp)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Count != report[j].Count {
+			return report[i].Count > report[j].Count
+		}
+		return report[i].Fingerprint < report[j].Fingerprint
+	})
+	return report
+}
+
+// Reset drops all groups.
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	c.groups = make(map[string]*Group)
+	c.mu.Unlock()
+}
+
+// Chain describes the wrapped chain of err, outermost first. Each link is
+// its dynamic type; links without further wrapping also carry their
+// message with digits masked. Joined errors are walked depth first.
+func Chain(err error) []string {
+	var chain []string
+	var walk func(error)
+	walk = func(err error) {
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			chain = append(chain, fmt.Sprintf("%T", err))
+			if next := u.Unwrap(); next != nil {
+				walk(next)
+			}
+		case interface{ Unwrap() []error }:
+			chain = append(chain, fmt.Sprintf("%T", err))
+			for _, next := range u.Unwrap() {
+				walk(next)
+			}
+		default:
+			chain = append(chain, fmt.Sprintf("%T: %s", err, mask(err.Error())))
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// mask replaces every run of digits with a single '#'.
+func mask(s string) string {
+	var b strings.Builder
+	digits := false
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			if !digits {
+				b.WriteByte('#')
+			}
+			digits = true
+			continue
+		}
+		digits = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Callers is implemented by errors that record where they were created.
+// Such a stack is preferred over the one at the point of collection.
+type Callers interface {
+	Callers() []uintptr
+}
+
+func callers(err error) []uintptr {
+	var withStack Callers
+	if errors.As(err, &withStack) {
+		return withStack.Callers()
+	}
+	pcs := make([]uintptr, 64)
+	// Skip runtime.Callers, callers, collect and the exported method.
+	return pcs[:runtime.Callers(4, pcs)]
+}
+
+// frames returns the function names of up to depth frames, leaving out the
+// runtime.
+func frames(pcs []uintptr, depth int) []string {
+	var stack []string
+	it := runtime.CallersFrames(pcs)
+	for len(stack) < depth {
+		f, more := it.Next()
+		if f.Function != "" && !strings.HasPrefix(f.Function, "runtime.") {
+			stack = append(stack, f.Function)
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+func fingerprint(chain, stack []string) string {
+	h := sha1.New()
+	for _, s := range chain {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{0})
+	for _, s := range stack {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
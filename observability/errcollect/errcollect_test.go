@@ -0,0 +1,176 @@
+package errcollect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	errTimeout  = errors.New("timeout")
+	errRejected = errors.New("rejected")
+)
+
+func newCollector() *Collector {
+	now := time.Unix(0, 0)
+	return New(Options{Exemplars: 2, Now: func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}})
+}
+
+// fetch reports a wrapped sentinel from a single call site.
+func fetch(c *Collector, id int, sentinel error) string {
+	return c.Collect("fetch", fmt.Errorf("fetch item %d: %w", id, sentinel))
+}
+
+func TestFingerprintStable(t *testing.T) {
+	c := newCollector()
+	first := fetch(c, 1, errTimeout)
+	for i := 2; i < 50; i++ {
+		if fp := fetch(c, i, errTimeout); fp != first {
+			t.Fatalf("fingerprint changed between occurrences: %s != %s", fp, first)
+		}
+	}
+	if got := c.Report(); len(got) != 1 || got[0].Count != 49 {
+		t.Fatalf("expected one group of 49, got %+v", got)
+	}
+
+	// A fresh collector must produce the same fingerprint for the same
+	// error from the same place.
+	if fp := fetch(newCollector(), 99, errTimeout); fp != first {
+		t.Errorf("fingerprint differs across collectors: %s != %s", fp, first)
+	}
+}
+
+func TestFingerprintMasksDigits(t *testing.T) {
+	c := newCollector()
+	a := c.Collect("db", errors.New("timeout after 31ms"))
+	b := c.Collect("db", errors.New("timeout after 250ms"))
+	if a != b {
+		t.Errorf("messages differing only in numbers should share a group")
+	}
+}
+
+func TestFingerprintDistinguishes(t *testing.T) {
+	c := newCollector()
+	base := fetch(c, 1, errTimeout)
+
+	if fp := fetch(c, 1, errRejected); fp == base {
+		t.Error("different sentinels must not share a fingerprint")
+	}
+	if fp := c.Collect("fetch", fmt.Errorf("fetch item %d: %w", 1, errTimeout)); fp == base {
+		t.Error("different call sites must not share a fingerprint")
+	}
+	if fp := fetch(c, 1, &wrapped{errTimeout}); fp == base {
+		t.Error("a different chain shape must not share a fingerprint")
+	}
+	if n := len(c.Report()); n != 4 {
+		t.Errorf("expected 4 groups, got %d", n)
+	}
+}
+
+type wrapped struct{ err error }
+
+func (w *wrapped) Error() string { return "wrapped: " + w.err.Error() }
+func (w *wrapped) Unwrap() error { return w.err }
+
+type stackErr struct {
+	error
+	pcs []uintptr
+}
+
+func (e *stackErr) Callers() []uintptr { return e.pcs }
+func (e *stackErr) Unwrap() error      { return e.error }
+
+func newStackErr() error {
+	pcs := make([]uintptr, 32)
+	return &stackErr{error: errTimeout, pcs: pcs[:runtime.Callers(1, pcs)]}
+}
+
+func TestErrorStackPreferred(t *testing.T) {
+	c := newCollector()
+	err := newStackErr()
+	a := c.Collect("a", err)
+	b := c.Reporter("b")
+	b(err)
+	report := c.Report()
+	if len(report) != 1 {
+		t.Fatalf("the error's own stack should be used at every collection site, got %d groups", len(report))
+	}
+	if !strings.HasSuffix(report[0].Stack[0], "newStackErr") {
+		t.Errorf("stack should start where the error was created: %v", report[0].Stack)
+	}
+	if report[0].Fingerprint != a || report[0].Sources["b"] != 1 {
+		t.Errorf("unexpected group %+v", report[0])
+	}
+}
+
+func TestChain(t *testing.T) {
+	err := fmt.Errorf("op 7: %w", errors.Join(errTimeout, &wrapped{errRejected}))
+	want := []string{
+		"*fmt.wrapError",
+		"*errors.joinError",
+		"*errors.errorString: timeout",
+		"*errcollect.wrapped",
+		"*errors.errorString: rejected",
+	}
+	if got := Chain(err); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExemplars(t *testing.T) {
+	c := newCollector()
+	for i := 1; i <= 3; i++ {
+		fetch(c, i, errTimeout)
+	}
+	g := c.Report()[0]
+	if len(g.Exemplars) != 2 || g.Exemplars[1].Message != "fetch item 3: timeout" {
+		t.Errorf("expected the last two exemplars, got %+v", g.Exemplars)
+	}
+	if !g.FirstSeen.Before(g.LastSeen) {
+		t.Errorf("first seen %v should be before last seen %v", g.FirstSeen, g.LastSeen)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	c := newCollector()
+	fetch(c, 1, errTimeout)
+	fetch(c, 2, errTimeout)
+	fetch(c, 1, errRejected)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/errors?limit=1", nil))
+	var body struct{ Groups []Group }
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Groups) != 1 || body.Groups[0].Count != 2 {
+		t.Errorf("expected the most frequent group only, got %+v", body.Groups)
+	}
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("POST", "/errors", nil))
+	if rec.Code != 405 {
+		t.Errorf("POST should be rejected, got %d", rec.Code)
+	}
+}
+
+func ExampleCollector() {
+	c := New(Options{})
+	report := c.Reporter("worker")
+	for i := 0; i < 3; i++ {
+		report(fmt.Errorf("job %d: %w", i, errTimeout))
+	}
+	for _, g := range c.Report() {
+		fmt.Println(g.Count, g.Chain, g.Exemplars[len(g.Exemplars)-1].Message)
+	}
+	// Output:
+	// 3 [*fmt.wrapError *errors.errorString: timeout] job 2: timeout
+}
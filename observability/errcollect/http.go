@@ -0,0 +1,32 @@
+package errcollect
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// ServeHTTP writes the report as JSON. The optional limit query parameter
+// caps the number of groups returned.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	report := c.Report()
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n < len(report) {
+			report = report[:n]
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Groups []Group `json:"groups"`
+	}{report})
+}
@@ -0,0 +1,159 @@
+// Package logsample wraps a slog.Handler to suppress bursts of identical
+// log messages. Within every period, the first N records with the same
+// level and message are passed through, after that only every Mth. When a
+// period ends with records dropped, a summary record reports how many were
+// suppressed. Each level can have its own policy, so errors can be kept in
+// full while debug chatter is thinned out.
+package logsample
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Policy decides which of the identical records in a period are kept.
+type Policy struct {
+	// First records per period are always kept.
+	First int
+	// Thereafter every Thereafter-th record is kept; zero drops the rest.
+	Thereafter int
+	// Period is the window counts are kept for.
+	Period time.Duration
+}
+
+// Options configures a Handler.
+type Options struct {
+	// Default applies to levels without their own policy.
+	Default Policy
+	// Levels overrides the policy per level. A zero Policy for a level
+	// disables sampling for it.
+	Levels map[slog.Level]Policy
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+type key struct {
+	level slog.Level
+	msg   string
+}
+
+type counter struct {
+	start      time.Time
+	seen       int
+	suppressed int
+}
+
+// state is shared by a handler and the handlers derived from it with
+// WithAttrs and WithGroup, so duplicates are counted across all of them.
+type state struct {
+	mu       sync.Mutex
+	counters map[key]*counter
+}
+
+// Handler samples records before passing them to the wrapped handler.
+type Handler struct {
+	next  slog.Handler
+	opts  Options
+	state *state
+}
+
+// New wraps next.
+func New(next slog.Handler, opts Options) *Handler {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Handler{next: next, opts: opts, state: &state{counters: make(map[key]*counter)}}
+}
+
+func (h *Handler) policy(level slog.Level) Policy {
+	if p, ok := h.opts.Levels[level]; ok {
+		return p
+	}
+	return h.opts.Default
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	p := h.policy(r.Level)
+	if p.Period <= 0 || p.First <= 0 {
+		return h.next.Handle(ctx, r)
+	}
+
+	now := h.opts.Now()
+	k := key{level: r.Level, msg: r.Message}
+
+	h.state.mu.Lock()
+	c, ok := h.state.counters[k]
+	var summary int
+	if !ok || now.Sub(c.start) >= p.Period {
+		if ok {
+			summary = c.suppressed
+		}
+		c = &counter{start: now}
+		h.state.counters[k] = c
+	}
+	c.seen++
+	keep := c.seen <= p.First || (p.Thereafter > 0 && (c.seen-p.First)%p.Thereafter == 0)
+	if !keep {
+		c.suppressed++
+	}
+	h.state.mu.Unlock()
+
+	if summary > 0 {
+		if err := h.next.Handle(ctx, suppressed(r.Level, r.Message, summary, now)); err != nil {
+			return err
+		}
+	}
+	if !keep {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// Flush emits the summaries of all periods that dropped records, without
+// waiting for another duplicate to arrive, and resets the counters.
+func (h *Handler) Flush(ctx context.Context) error {
+	now := h.opts.Now()
+
+	h.state.mu.Lock()
+	var pending []key
+	var counts []int
+	for k, c := range h.state.counters {
+		if c.suppressed > 0 {
+			pending = append(pending, k)
+			counts = append(counts, c.suppressed)
+		}
+	}
+	h.state.counters = make(map[key]*counter)
+	h.state.mu.Unlock()
+
+	for i, k := range pending {
+		if err := h.next.Handle(ctx, suppressed(k.level, k.msg, counts[i], now)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func suppressed(level slog.Level, msg string, n int, at time.Time) slog.Record {
+	r := slog.NewRecord(at, level, "suppressed duplicates", 0)
+	r.AddAttrs(slog.String("duplicate", msg), slog.Int("count", n))
+	return r
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs), opts: h.opts, state: h.state}
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), opts: h.opts, state: h.state}
+}
@@ -0,0 +1,122 @@
+package logsample
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func setup(opts Options) (*slog.Logger, *Handler, *bytes.Buffer, *clock) {
+	var buf bytes.Buffer
+	c := &clock{now: time.Unix(0, 0)}
+	opts.Now = c.Now
+	h := New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}), opts)
+	return slog.New(h), h, &buf, c
+}
+
+func lines(buf *bytes.Buffer) []string {
+	s := strings.TrimSpace(buf.String())
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func TestFirstThenEveryMth(t *testing.T) {
+	log, _, buf, _ := setup(Options{Default: Policy{First: 2, Thereafter: 3, Period: time.Second}})
+
+	for i := 0; i < 10; i++ {
+		log.Info("disk full", "i", i)
+	}
+	got := lines(buf)
+	// Kept: 1st, 2nd, then the 5th and 8th.
+	want := []string{"i=0", "i=1", "i=4", "i=7"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines:\n%s", len(got), buf)
+	}
+	for i := range want {
+		if !strings.HasSuffix(got[i], want[i]) {
+			t.Errorf("line %d = %q, want suffix %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSummaryAtNextPeriod(t *testing.T) {
+	log, _, buf, c := setup(Options{Default: Policy{First: 1, Period: time.Second}})
+
+	for i := 0; i < 5; i++ {
+		log.Warn("retrying")
+	}
+	c.now = c.now.Add(time.Second)
+	buf.Reset()
+	log.Warn("retrying")
+
+	got := lines(buf)
+	if len(got) != 2 {
+		t.Fatalf("expected a summary and the record:\n%s", buf)
+	}
+	if got[0] != `level=WARN msg="suppressed duplicates" duplicate=retrying count=4` {
+		t.Errorf("unexpected summary %q", got[0])
+	}
+	if got[1] != "level=WARN msg=retrying" {
+		t.Errorf("unexpected record %q", got[1])
+	}
+}
+
+func TestDistinctMessagesAreIndependent(t *testing.T) {
+	log, _, buf, _ := setup(Options{Default: Policy{First: 1, Period: time.Second}})
+
+	log.Info("a")
+	log.Info("b")
+	log.Info("a")
+	log.Error("a")
+	if n := len(lines(buf)); n != 3 {
+		t.Errorf("expected 3 lines, got %d:\n%s", n, buf)
+	}
+}
+
+func TestPerLevelPolicy(t *testing.T) {
+	log, _, buf, _ := setup(Options{
+		Default: Policy{First: 1, Period: time.Second},
+		Levels:  map[slog.Level]Policy{slog.LevelError: {}},
+	})
+
+	for i := 0; i < 3; i++ {
+		log.Error("boom")
+		log.Debug("tick")
+	}
+	out := buf.String()
+	if strings.Count(out, "msg=boom") != 3 || strings.Count(out, "msg=tick") != 1 {
+		t.Errorf("errors must not be sampled, debug must be:\n%s", out)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	log, h, buf, _ := setup(Options{Default: Policy{First: 1, Period: time.Minute}})
+
+	for i := 0; i < 3; i++ {
+		log.With("component", "db").Info("slow query")
+	}
+	buf.Reset()
+	if err := h.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != `level=INFO msg="suppressed duplicates" duplicate="slow query" count=2` {
+		t.Errorf("unexpected flush output %q", got)
+	}
+}
@@ -0,0 +1,296 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/crazybber/go-patterns/architecture/clean/adapter/controller"
+	"github.com/crazybber/go-patterns/architecture/clean/adapter/gateway"
+	"github.com/crazybber/go-patterns/architecture/clean/usecase"
+	"github.com/crazybber/go-patterns/architecture/cqrs"
+	"github.com/crazybber/go-patterns/architecture/eventsourcing"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/adapters/cli"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/adapters/memstore"
+	"github.com/crazybber/go-patterns/architecture/hexagonal/core"
+	"github.com/crazybber/go-patterns/architecture/idempotency"
+	"github.com/crazybber/go-patterns/architecture/outbox"
+	"github.com/crazybber/go-patterns/architecture/repository"
+	"github.com/crazybber/go-patterns/architecture/saga"
+	"github.com/crazybber/go-patterns/architecture/twophasecommit"
+	"github.com/crazybber/go-patterns/architecture/unitofwork"
+	"github.com/crazybber/go-patterns/behavioral/observer/eventbus"
+)
+
+func init() {
+	register("architecture/clean", "adds and completes todos through the controller, use cases and presenter", runClean)
+	register("architecture/cqrs", "projects order events into a read model and waits for it to catch up", runCQRS)
+	register("architecture/eventsourcing", "rebuilds an account from its events and refuses a stale save", runEventSourcing)
+	register("architecture/hexagonal", "drives the to-do core from its command-line adapter", runHexagonal)
+	register("architecture/idempotency", "charges each payment once although the broker delivers some of them twice", runIdempotency)
+	register("architecture/outbox", "relays committed messages through a lossy broker to a deduplicating consumer", runOutbox)
+	register("architecture/repository", "stores and finds users through a repository", runRepository)
+	register("architecture/saga", "places an order that fails to ship and compensates the earlier steps", runSaga)
+	register("architecture/twophasecommit", "commits a transfer across two stores, aborts one a store cannot prepare in time, and resends a lost commit", runTwoPhaseCommit)
+	register("architecture/unitofwork", "settles a batch of transfers that fails halfway and rolls it all back", runUnitOfWork)
+}
+
+func runClean(ctx context.Context, w io.Writer) error {
+	h := controller.Handler(usecase.New(gateway.NewMemory(), systemClock{}))
+	for _, req := range []struct{ method, path, body string }{
+		{"POST", "/todos", `{"title": "draw the rings", "priority": "low"}`},
+		{"POST", "/todos", `{"title": "point imports inwards", "priority": "high"}`},
+		{"POST", "/todos", `{"title": ""}`},
+		{"POST", "/todos/1/done", ""},
+		{"GET", "/todos?all=1", ""},
+	} {
+		r := httptest.NewRequestWithContext(ctx, req.method, req.path, strings.NewReader(req.body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		fmt.Fprintf(w, "%s %s -> %d %s", req.method, req.path, rec.Code, rec.Body)
+	}
+	return ctx.Err()
+}
+
+func runCQRS(ctx context.Context, w io.Writer) error {
+	bus := eventbus.New[cqrs.Event](eventbus.Options{Mode: eventbus.Async})
+	defer bus.Close()
+	orders := cqrs.NewOrders(bus)
+	view := cqrs.NewProjection(bus, nil)
+	defer view.Close()
+
+	orders.Place(cqrs.PlaceOrder{OrderID: "o1", Customer: "ann", Lines: []cqrs.Line{{SKU: "tea", Qty: 2, Price: 450}}})
+	orders.Place(cqrs.PlaceOrder{OrderID: "o2", Customer: "bob", Lines: []cqrs.Line{{SKU: "mug", Qty: 1, Price: 1200}}})
+	seq, err := orders.Ship("o1")
+	if err != nil {
+		return err
+	}
+	if err := view.WaitFor(ctx, seq); err != nil {
+		return err
+	}
+	for _, c := range view.TopCustomers(2) {
+		fmt.Fprintf(w, "%s: %d orders, %d open, %d cents\n", c.Customer, c.Orders, c.Open, c.Spent)
+	}
+	return nil
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func runEventSourcing(ctx context.Context, w io.Writer) error {
+	accounts := eventsourcing.NewRepository(eventsourcing.NewMemoryStore(), eventsourcing.NewAccount)
+	a := accounts.New("acc-42")
+	a.Open("ann")
+	a.Deposit(1000)
+	if err := accounts.Save(ctx, a); err != nil {
+		return err
+	}
+	x, err := accounts.Load(ctx, "acc-42")
+	if err != nil {
+		return err
+	}
+	y, err := accounts.Load(ctx, "acc-42")
+	if err != nil {
+		return err
+	}
+	x.Withdraw(600)
+	y.Withdraw(600)
+	fmt.Fprintln(w, accounts.Save(ctx, x))
+	fmt.Fprintln(w, accounts.Save(ctx, y))
+	a, err = accounts.Load(ctx, "acc-42")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "balance", a.Balance(), "at version", a.Version())
+	return nil
+}
+
+func runHexagonal(ctx context.Context, w io.Writer) error {
+	tasks := core.New(memstore.New(), systemClock{})
+	for _, args := range [][]string{
+		{"add", "write", "the", "domain"},
+		{"add", "plug", "in", "adapters"},
+		{"done", "1"},
+		{"list", "-a"},
+	} {
+		if err := cli.Run(ctx, tasks, args, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runRepository(ctx context.Context, w io.Writer) error {
+	var users repository.UserRepository = repository.NewMemory()
+	u := &repository.User{Email: "ann@example.com", Name: "Ann"}
+	if err := users.Create(ctx, u); err != nil {
+		return err
+	}
+	got, err := users.ByEmail(ctx, "ann@example.com")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, got.ID, got.Name)
+	fmt.Fprintln(w, users.Create(ctx, &repository.User{Email: "ann@example.com"}))
+	return nil
+}
+
+func runSaga(ctx context.Context, w io.Writer) error {
+	inv := saga.NewInventory(map[string]int{"tea": 5})
+	pay := saga.NewPayments(1000)
+	ship := saga.NewShipping()
+	defer inv.Close()
+	defer pay.Close()
+	defer ship.Close()
+	place := saga.PlaceOrder(inv, pay, ship, saga.Options{})
+
+	if err := place.Run(ctx, saga.Order{ID: "o1", SKU: "tea", Qty: 2, Amount: 900, Address: "1 Main St"}); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "o1 placed: %d tea left, charged %d\n", inv.Stock("tea"), pay.Charged("o1"))
+	err := place.Run(ctx, saga.Order{ID: "o2", SKU: "tea", Qty: 1, Amount: 450})
+	var e *saga.Error
+	if !errors.As(err, &e) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return ctx.Err()
+	}
+	fmt.Fprintf(w, "o2 %v\n%v\n", err, e.Log)
+	fmt.Fprintf(w, "%d tea left, o2 charged %d\n", inv.Stock("tea"), pay.Charged("o2"))
+	return nil
+}
+
+func runTwoPhaseCommit(ctx context.Context, w io.Writer) error {
+	a, b := twophasecommit.NewParticipant("bank-a"), twophasecommit.NewParticipant("bank-b")
+	defer a.Close()
+	defer b.Close()
+	c := twophasecommit.NewCoordinator(twophasecommit.Options{Timeout: 20 * time.Millisecond}, a, b)
+	transfer := func(tx, ann, bob string) error {
+		err := c.Run(ctx, tx, map[string]map[string]string{"bank-a": {"ann": ann}, "bank-b": {"bob": bob}})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ann, _ = a.Get("ann")
+		bob, _ = b.Get("bob")
+		fmt.Fprintf(w, "%s: %v; ann=%s bob=%s\n", tx, err, ann, bob)
+		return nil
+	}
+	if err := transfer("t1", "90", "110"); err != nil {
+		return err
+	}
+	b.Inject(twophasecommit.Prepare, twophasecommit.Drop)
+	if err := transfer("t2", "80", "120"); err != nil {
+		return err
+	}
+	b.Inject(twophasecommit.Commit, twophasecommit.Lose)
+	if err := transfer("t3", "70", "130"); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "bank-b handled %v\n", b.Log())
+	return nil
+}
+
+func runUnitOfWork(ctx context.Context, w io.Writer) error {
+	store := unitofwork.NewMemory(
+		unitofwork.Account{ID: "ann", Owner: "Ann", Balance: 100},
+		unitofwork.Account{ID: "bob", Owner: "Bob", Balance: 20},
+	)
+	balances := func() error {
+		return unitofwork.Do(ctx, store, func(uow unitofwork.UnitOfWork) error {
+			for _, id := range []string{"ann", "bob"} {
+				a, err := uow.Accounts().Get(ctx, id)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "  %s: %d\n", a.Owner, a.Balance)
+			}
+			return nil
+		})
+	}
+	err := unitofwork.Settle(ctx, store, []unitofwork.Transfer{
+		{From: "ann", To: "bob", Amount: 50, Memo: "rent"},
+		{From: "bob", To: "ann", Amount: 30, Memo: "refund"},
+	})
+	fmt.Fprintln(w, "first batch:", err)
+	if err := balances(); err != nil {
+		return err
+	}
+	err = unitofwork.Settle(ctx, store, []unitofwork.Transfer{
+		{From: "ann", To: "bob", Amount: 50, Memo: "rent"},
+		{From: "bob", To: "ann", Amount: 500, Memo: "overdraft"},
+	})
+	fmt.Fprintln(w, "second batch:", err)
+	return balances()
+}
+
+func runIdempotency(ctx context.Context, w io.Writer) error {
+	type payment struct {
+		Key    string
+		Amount int
+	}
+	total := 0
+	c := idempotency.NewConsumer(idempotency.NewMemory(nil), func(p payment) string { return p.Key },
+		func(_ context.Context, p payment) error {
+			total += p.Amount
+			return nil
+		}, idempotency.Options{TTL: time.Hour})
+	bus := eventbus.New[payment](eventbus.Options{})
+	defer bus.Close()
+	c.Subscribe(bus)
+	// The broker redelivers pay-2 and pay-3, whose acknowledgements it
+	// did not get.
+	for _, key := range []string{"pay-1", "pay-2", "pay-2", "pay-3", "pay-2", "pay-3"} {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		bus.Publish(payment{key, 10})
+	}
+	fmt.Fprintf(w, "6 deliveries: %d charged, %d duplicates acknowledged, total %d\n", c.Handled(), c.Duplicates(), total)
+	return nil
+}
+
+func runOutbox(ctx context.Context, w io.Writer) error {
+	db := outbox.NewDB()
+	for _, id := range []string{"o1", "o2", "o3"} {
+		db.Update(func(tx *outbox.Tx) error {
+			tx.Put("order/"+id, []byte("placed"))
+			tx.Publish("order.placed", []byte(id))
+			return nil
+		})
+	}
+	bus := eventbus.New[outbox.Message](eventbus.Options{})
+	defer bus.Close()
+	c := outbox.NewConsumer(func(m outbox.Message) error {
+		fmt.Fprintf(w, "handled %s %s\n", m.Topic, m.Payload)
+		return nil
+	})
+	bus.Subscribe(func(m outbox.Message) { c.Handle(m) })
+
+	// The broker's acknowledgement of the second message is lost once, so
+	// the relay publishes it again.
+	lost := false
+	relay := outbox.NewRelay(db, func(_ context.Context, m outbox.Message) error {
+		bus.Publish(m)
+		if m.ID == 2 && !lost {
+			lost = true
+			return fmt.Errorf("ack for message %d lost", m.ID)
+		}
+		return nil
+	}, 10)
+	for len(db.Pending(1)) > 0 {
+		if _, err := relay.Flush(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintln(w, "flush:", err)
+		}
+	}
+	fmt.Fprintf(w, "%d duplicate dropped\n", c.Duplicates())
+	return nil
+}
@@ -0,0 +1,319 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/crazybber/go-patterns/behavioral/chain"
+	"github.com/crazybber/go-patterns/behavioral/command/undo"
+	"github.com/crazybber/go-patterns/behavioral/interpreter/filter"
+	"github.com/crazybber/go-patterns/behavioral/iterator"
+	"github.com/crazybber/go-patterns/behavioral/mediator/chatroom"
+	"github.com/crazybber/go-patterns/behavioral/memento/editor"
+	"github.com/crazybber/go-patterns/behavioral/nullobject"
+	"github.com/crazybber/go-patterns/behavioral/observer/eventbus"
+	"github.com/crazybber/go-patterns/behavioral/registry"
+	"github.com/crazybber/go-patterns/behavioral/specification"
+	"github.com/crazybber/go-patterns/behavioral/strategy/cache"
+	"github.com/crazybber/go-patterns/behavioral/templatemethod"
+	"github.com/crazybber/go-patterns/behavioral/visitor/expr"
+)
+
+func init() {
+	register("behavioral/chain", "routes support tickets through a chain of handlers", runChain)
+	register("behavioral/command/undo", "edits a document with commands that can be undone", runUndo)
+	register("behavioral/interpreter/filter", "compiles and evaluates a filter expression", runFilter)
+	register("behavioral/iterator", "walks a tree by key range and merges two lists", runIterator)
+	register("behavioral/mediator/chatroom", "routes direct messages, broadcasts and announcements between chat members who never see each other", runChatroom)
+	register("behavioral/memento/editor", "restores an editor from a checkpoint", runEditor)
+	register("behavioral/nullobject", "imports rows with and without a logger, calling it unconditionally", runNullObject)
+	register("behavioral/observer/eventbus", "publishes events to subscribers that come and go", runEventBus)
+	register("behavioral/registry", "keeps handlers under generated and chosen IDs and refuses a duplicate name", runIDRegistry)
+	register("behavioral/specification", "selects orders with composed business rules", runSpecification)
+	register("behavioral/strategy/cache", "builds a cache from compression and eviction strategies named in a config", runCache)
+	register("behavioral/templatemethod", "exports users as CSV and JSON lines, overriding single steps of one export algorithm", runTemplateMethod)
+	register("behavioral/visitor/expr", "prints, folds and evaluates an expression tree with visitors", runExpr)
+}
+
+func runChain(ctx context.Context, w io.Writer) error {
+	c := chain.New(
+		chain.Validate(),
+		chain.Enrich(map[string]chain.Tier{"acme": chain.Enterprise}, map[string]string{"refund": "billing"}),
+		chain.Escalate(chain.Enterprise, "account-manager"),
+		chain.Route("billing", "billing"),
+		chain.Fallback("tier1"),
+	)
+	for _, tk := range []*chain.Ticket{
+		{Customer: "bob", Subject: "Refund for order 42"},
+		{Customer: "acme", Subject: "Refund for order 43"},
+		{Customer: "bob", Subject: "How do I log in?"},
+	} {
+		if err := c.Handle(ctx, tk); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s: %s\n", tk.Subject, tk.AssignedTo)
+	}
+	return nil
+}
+
+func runUndo(_ context.Context, w io.Writer) error {
+	d := &undo.Document{}
+	h := undo.NewHistory(100)
+	if err := h.Do(&undo.Insert{Doc: d, Pos: 0, Text: "Hello, world"}); err != nil {
+		return err
+	}
+	if err := h.Do(undo.Replace(d, 7, 5, "Gophers")); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, d)
+	if err := h.Undo(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, d)
+	return nil
+}
+
+func runFilter(_ context.Context, w io.Writer) error {
+	f, err := filter.Compile(`age > 30 && name == "bob"`)
+	if err != nil {
+		return err
+	}
+	for _, user := range []filter.Vars{
+		{"name": "bob", "age": 42},
+		{"name": "alice", "age": 42},
+	} {
+		ok, err := f.Match(user)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, user["name"], user["age"], ok)
+	}
+	return nil
+}
+
+func runIterator(_ context.Context, w io.Writer) error {
+	var prices iterator.Tree[string, float64]
+	prices.Set("pear", 1.20)
+	prices.Set("apple", 0.50)
+	prices.Set("fig", 2.10)
+	prices.Set("banana", 0.25)
+	for fruit, price := range prices.Range("b", "g") {
+		fmt.Fprintf(w, "%s %.2f\n", fruit, price)
+	}
+	var morning, evening iterator.List[int]
+	for _, v := range []int{1, 4, 9} {
+		morning.PushBack(v)
+		evening.PushBack(v + 1)
+	}
+	fmt.Fprintln(w, slices.Collect(iterator.Merge(morning.All(), evening.All())))
+	return nil
+}
+
+func runEditor(_ context.Context, w io.Writer) error {
+	e := editor.New("Dear Sir,")
+	h := editor.NewHistory(e, 10)
+	h.Checkpoint()
+	if err := e.MoveTo(editor.Pos{Line: 0, Col: 9}); err != nil {
+		return err
+	}
+	e.Insert("\nI regret to inform you")
+	fmt.Fprintf(w, "%q\n", e.Text())
+	if err := h.Undo(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%q\n", e.Text())
+	return nil
+}
+
+type orderPlaced struct {
+	ID    string
+	Total int
+}
+
+func runEventBus(_ context.Context, w io.Writer) error {
+	orders := eventbus.New[orderPlaced](eventbus.Options{})
+	orders.Subscribe(func(o orderPlaced) { fmt.Fprintln(w, "email receipt for", o.ID) })
+	audit := orders.Subscribe(func(o orderPlaced) { fmt.Fprintln(w, "audit", o.ID, o.Total) })
+	orders.Publish(orderPlaced{ID: "A1", Total: 30})
+	audit.Unsubscribe()
+	orders.Publish(orderPlaced{ID: "A2", Total: 12})
+	return nil
+}
+
+func runSpecification(_ context.Context, w io.Writer) error {
+	orders := []specification.Order{
+		{ID: "o1", Total: 80_000, Country: "DE", Status: "paid"},
+		{ID: "o2", Total: 90_000, Country: "FR", Status: "cancelled"},
+		{ID: "o3", Total: 2_000, Country: "DE", Status: "paid", Express: true},
+	}
+	spec := specification.And(specification.MinTotal(50_000), specification.Not(specification.HasStatus("cancelled")))
+	fmt.Fprintln(w, spec)
+	for _, o := range specification.Filter(orders, spec) {
+		fmt.Fprintln(w, o.ID, o.Total)
+	}
+	return nil
+}
+
+func runCache(_ context.Context, w io.Writer) error {
+	c, err := cache.New(cache.Config{Compression: "gzip", Eviction: "lfu", Capacity: 1 << 20})
+	if err != nil {
+		return err
+	}
+	if err := c.Set("greeting", []byte("hello")); err != nil {
+		return err
+	}
+	v, ok, err := c.Get("greeting")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, string(v), ok, c.Stats().Bytes, "bytes stored")
+	return nil
+}
+
+func runExpr(_ context.Context, w io.Writer) error {
+	e := expr.B(expr.Mul, expr.B(expr.Add, expr.V("price"), expr.N(5)), expr.B(expr.Add, expr.N(1), expr.N(0.25)))
+	fmt.Fprintln(w, expr.String(e))
+	fmt.Fprintln(w, expr.String(expr.Fold(e)))
+	v, err := expr.Eval(e, map[string]float64{"price": 15})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, v)
+	return nil
+}
+
+func runChatroom(_ context.Context, w io.Writer) error {
+	room := chatroom.NewRoom(16)
+	ann, err := room.Join("ann")
+	if err != nil {
+		return err
+	}
+	bob, err := room.Join("bob")
+	if err != nil {
+		return err
+	}
+	if _, err := room.Join("ann"); err != nil {
+		fmt.Fprintln(w, "second ann:", err)
+	}
+	ann.Broadcast("morning!")
+	bob.Send("ann", "hi ann")
+	if err := bob.Send("cy", "are you there?"); err != nil {
+		fmt.Fprintln(w, "bob to cy:", err)
+	}
+	bob.Leave()
+	ann.Leave()
+	fmt.Fprintln(w, "ann's inbox:")
+	for msg := range ann.Inbox() {
+		if msg.From == "" {
+			fmt.Fprintln(w, "  *", msg.Text)
+		} else {
+			fmt.Fprintf(w, "  %s: %s\n", msg.From, msg.Text)
+		}
+	}
+	return nil
+}
+
+// importer takes an optional logger and calls it without checking for nil.
+type importer struct{ log nullobject.Logger }
+
+func (im importer) run(rows []string) int {
+	n := 0
+	for i, r := range rows {
+		if r == "" {
+			im.log.Printf("skipping empty row %d", i+1)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// writerLogger is a nullobject.Logger writing lines to w.
+type writerLogger struct{ w io.Writer }
+
+func (l writerLogger) Printf(format string, args ...any) {
+	fmt.Fprintf(l.w, "  log: "+format+"\n", args...)
+}
+
+func runNullObject(_ context.Context, w io.Writer) error {
+	rows := []string{"ann", "", "bob", ""}
+	for _, l := range []struct {
+		name string
+		log  nullobject.Logger
+	}{{"no logger", nil}, {"a logger", writerLogger{w}}} {
+		fmt.Fprintf(w, "with %s:\n", l.name)
+		n := importer{log: nullobject.LoggerOr(l.log)}.run(rows)
+		fmt.Fprintf(w, "  imported %d of %d rows\n", n, len(rows))
+	}
+	return nil
+}
+
+func runIDRegistry(_ context.Context, w io.Writer) error {
+	reg := registry.New()
+	id := reg.Register("resize handler")
+	if err := reg.RegisterName("thumbnail handler", "thumbnail"); err != nil {
+		return err
+	}
+	err := reg.RegisterName("another thumbnail handler", "thumbnail")
+	fmt.Fprintln(w, "second thumbnail:", err)
+	for _, key := range []string{id, "thumbnail"} {
+		v, err := reg.Get(key)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "got", v)
+	}
+	reg.Deregister(id)
+	_, err = reg.Get(id)
+	fmt.Fprintln(w, "after Deregister:", strings.Replace(err.Error(), id, "<generated ID>", 1))
+	return nil
+}
+
+// userExport fetches users for behavioral/templatemethod and keeps the
+// default steps.
+type userExport struct {
+	templatemethod.Defaults
+}
+
+func (userExport) Fetch(context.Context) ([]templatemethod.Record, error) {
+	return []templatemethod.Record{
+		{"name": "Ann", "email": "ann@example.com", "active": "true"},
+		{"name": "Bob", "email": "bob@example.com", "active": "false"},
+		{"name": "Cy", "email": "cy@example.org", "active": "true"},
+	}, nil
+}
+
+// activeMasked overrides Transform: active users only, emails masked.
+type activeMasked struct{ userExport }
+
+func (activeMasked) Transform(r templatemethod.Record) (templatemethod.Record, error) {
+	if r["active"] != "true" {
+		return nil, templatemethod.ErrSkip
+	}
+	user, domain, _ := strings.Cut(r["email"], "@")
+	return templatemethod.Record{"name": r["name"], "email": user[:1] + "***@" + domain}, nil
+}
+
+// activeJSON overrides Write too, through the embedded JSONLines.
+type activeJSON struct {
+	activeMasked
+	templatemethod.JSONLines
+}
+
+func runTemplateMethod(ctx context.Context, w io.Writer) error {
+	for _, e := range []struct {
+		name  string
+		steps templatemethod.Steps
+	}{{"defaults", userExport{}}, {"active, masked", activeMasked{}}, {"active, masked, as JSON", activeJSON{}}} {
+		fmt.Fprintf(w, "%s:\n", e.name)
+		st, err := templatemethod.Export(ctx, e.steps, w)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "(%d fetched, %d skipped, %d written)\n", st.Fetched, st.Skipped, st.Written)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// Package catalog registers a pattern.Runner for each library package of
+// the repository. Import it for its side effect:
+//
+//	import _ "github.com/crazybber/go-patterns/pattern/catalog"
+//
+// Each runner is a short program using the package the way its Example
+// does, and writes what it shows. A runner may take options, registered
+// with registerFlags, whose defaults keep it short. A new library package
+// adds its runner here: the conformance test fails for a package without
+// one, unless it is listed as exempt there with the reason. It runs every
+// runner with a deadline and checks that it leaves no goroutine behind.
+package catalog
+
+import (
+	"context"
+	"io"
+
+	"github.com/crazybber/go-patterns/pattern"
+)
+
+func register(name, description string, run func(ctx context.Context, w io.Writer) error) {
+	pattern.Register(pattern.New(name, description, run))
+}
+
+// registerFlags registers a runner whose options are the tagged fields of
+// the struct flags points to, settable from the command line:
+//
+//	patterns run patterns/workerpool -n 1000 -workers 8
+func registerFlags(name, description string, flags any, run func(ctx context.Context, w io.Writer) error) {
+	pattern.Register(pattern.NewConfigurable(name, description, flags, run))
+}
@@ -0,0 +1,179 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go/build"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/pattern"
+	"github.com/crazybber/go-patterns/patterns/cli"
+)
+
+// TestConformance checks that every library package has a runner, then
+// runs every registered pattern under a deadline and checks what any
+// runner must do: name a package of the repository, describe itself in
+// one line, write something, succeed, and leave no goroutine running.
+// Run it with -race to check the runners for races too.
+func TestConformance(t *testing.T) {
+	all := pattern.All()
+	checkCoverage(t, all)
+	for _, r := range all {
+		t.Run(r.Name(), func(t *testing.T) {
+			if fi, err := os.Stat(filepath.Join("..", "..", filepath.FromSlash(r.Name()))); err != nil || !fi.IsDir() {
+				t.Errorf("no package directory %s", r.Name())
+			}
+			if d := r.Describe(); d == "" || strings.Contains(d, "\n") {
+				t.Errorf("Describe = %q, want one line", d)
+			}
+			if c, ok := r.(pattern.Configurable); ok {
+				cmd := &cli.Command{Name: r.Name(), Flags: c.Flags(), Run: func(context.Context, []string) error { return nil }}
+				if err := cmd.Execute(context.Background(), nil); err != nil {
+					t.Errorf("flags: %v", err)
+				}
+			}
+
+			before := runtime.NumGoroutine()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			var out bytes.Buffer
+			done := make(chan error, 1)
+			go func() { done <- r.Run(ctx, &out) }()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Run: %v", err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Run did not return after its deadline")
+			}
+			if out.Len() == 0 {
+				t.Error("Run wrote nothing")
+			}
+			checkLeaks(t, before)
+		})
+	}
+}
+
+// TestCancelled checks that every runner returns promptly given a context
+// that is already cancelled, with nil or the context's error.
+func TestCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range pattern.All() {
+		before := runtime.NumGoroutine()
+		done := make(chan error, 1)
+		go func() { done <- r.Run(ctx, new(bytes.Buffer)) }()
+		select {
+		case err := <-done:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				t.Errorf("%s: Run = %v", r.Name(), err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: Run ignores cancellation", r.Name())
+		}
+		checkLeaks(t, before)
+	}
+}
+
+// checkLeaks waits a while for the goroutine count to fall back to
+// before, and fails with the stacks of all goroutines if it does not.
+func checkLeaks(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Errorf("%d goroutines left running:\n%s", runtime.NumGoroutine()-before, buf[:runtime.Stack(buf, true)])
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// unregistered are the library packages that have no runner, and why.
+// A name ending in /... stands for every package below it.
+var unregistered = map[string]string{
+	"pattern":                           "the registry the runners are registered with",
+	"pattern/catalog":                   "the runners themselves",
+	"playground/...":                    "scratch code rather than patterns",
+	"creational":                        "mixes package main with package data, so it cannot be imported",
+	"creational/factorymethod/shape":    "part of the creational/factorymethod program; its shapes dump to os.Stdout",
+	"concurrency/concurrency_in_go/ch1": "prints to os.Stdout and fetches live URLs",
+	"messaging":                         "its workers share a running flag without synchronization and spin",
+}
+
+// checkCoverage walks the repository for library packages and fails for
+// each one that neither has a runner, nor has one for a directory above
+// it, nor is listed in unregistered. It fails for stale entries of
+// unregistered too. Packages below an internal
+// directory are shown by the runners of the packages using them, and
+// nested modules are left to their own tests.
+func checkCoverage(t *testing.T, all []pattern.Runner) {
+	t.Helper()
+	root := filepath.Join("..", "..")
+	registered := make(map[string]bool, len(all))
+	for _, r := range all {
+		registered[r.Name()] = true
+		if reason, ok := unregistered[r.Name()]; ok {
+			t.Errorf("%s has a runner but is listed as unregistered (%s)", r.Name(), reason)
+		}
+	}
+	used := make(map[string]bool)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if name == "." {
+			return nil
+		}
+		switch base := d.Name(); {
+		case base == "testdata" || base == "internal" || base == "vendor" || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_"):
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+			return filepath.SkipDir
+		}
+		pkg, err := build.ImportDir(path, 0)
+		var noGo *build.NoGoError
+		if errors.As(err, &noGo) || pkg.Name == "main" {
+			return nil
+		}
+		if _, ok := unregistered[name]; ok {
+			used[name] = true
+			return nil
+		}
+		for dir := name; dir != "."; dir = pathpkg.Dir(dir) {
+			if registered[dir] {
+				return nil
+			}
+			if _, ok := unregistered[dir+"/..."]; ok {
+				used[dir+"/..."] = true
+				return nil
+			}
+		}
+		t.Errorf("library package %s has no runner; register one in pattern/catalog", name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range unregistered {
+		if !used[name] {
+			t.Errorf("%s is listed as unregistered but is no library package", name)
+		}
+	}
+}
@@ -0,0 +1,1764 @@
+package catalog
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	parallelism "github.com/crazybber/go-patterns/concurrency"
+	"github.com/crazybber/go-patterns/concurrency/barrier/cyclic"
+	bounded_parallelism "github.com/crazybber/go-patterns/concurrency/bounded"
+	"github.com/crazybber/go-patterns/concurrency/boundedqueue"
+	"github.com/crazybber/go-patterns/concurrency/broadcast"
+	"github.com/crazybber/go-patterns/concurrency/cache"
+	"github.com/crazybber/go-patterns/concurrency/contextpatterns"
+	"github.com/crazybber/go-patterns/concurrency/cooperative"
+	"github.com/crazybber/go-patterns/concurrency/copyonwrite"
+	"github.com/crazybber/go-patterns/concurrency/crawler"
+	"github.com/crazybber/go-patterns/concurrency/dagrunner"
+	"github.com/crazybber/go-patterns/concurrency/deadlocks"
+	"github.com/crazybber/go-patterns/concurrency/debounce"
+	"github.com/crazybber/go-patterns/concurrency/donechannel"
+	"github.com/crazybber/go-patterns/concurrency/eventloop"
+	"github.com/crazybber/go-patterns/concurrency/filewalker"
+	"github.com/crazybber/go-patterns/concurrency/futures"
+	"github.com/crazybber/go-patterns/concurrency/generator"
+	"github.com/crazybber/go-patterns/concurrency/lazyinit"
+	"github.com/crazybber/go-patterns/concurrency/leaderelection"
+	"github.com/crazybber/go-patterns/concurrency/leaks"
+	"github.com/crazybber/go-patterns/concurrency/maintenance"
+	"github.com/crazybber/go-patterns/concurrency/mapreduce"
+	"github.com/crazybber/go-patterns/concurrency/mux"
+	"github.com/crazybber/go-patterns/concurrency/orderedpool"
+	"github.com/crazybber/go-patterns/concurrency/parallel"
+	"github.com/crazybber/go-patterns/concurrency/parallelsort"
+	"github.com/crazybber/go-patterns/concurrency/pipeline"
+	"github.com/crazybber/go-patterns/concurrency/priorityselect"
+	"github.com/crazybber/go-patterns/concurrency/producer_consumer"
+	"github.com/crazybber/go-patterns/concurrency/races"
+	"github.com/crazybber/go-patterns/concurrency/ringbuffer"
+	"github.com/crazybber/go-patterns/concurrency/rungroup"
+	"github.com/crazybber/go-patterns/concurrency/scattergather"
+	"github.com/crazybber/go-patterns/concurrency/scheduler"
+	"github.com/crazybber/go-patterns/concurrency/scope"
+	"github.com/crazybber/go-patterns/concurrency/selectpatterns"
+	"github.com/crazybber/go-patterns/concurrency/shardedmap"
+	"github.com/crazybber/go-patterns/concurrency/shutdown"
+	"github.com/crazybber/go-patterns/concurrency/singleflight"
+	"github.com/crazybber/go-patterns/concurrency/singlewriter"
+	"github.com/crazybber/go-patterns/concurrency/streamjoin"
+	"github.com/crazybber/go-patterns/concurrency/subtasks/divide_and_conquer"
+	"github.com/crazybber/go-patterns/concurrency/subtasks/fetchers"
+	"github.com/crazybber/go-patterns/concurrency/supervisor"
+	"github.com/crazybber/go-patterns/concurrency/ticker"
+	"github.com/crazybber/go-patterns/concurrency/windows"
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/idioms/ctxkeys"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+	"github.com/crazybber/go-patterns/pkg/pool"
+	"github.com/crazybber/go-patterns/pkg/runner"
+	"github.com/crazybber/go-patterns/resiliency/ratelimit"
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+func init() {
+	register("concurrency", "digests the files of a tree with a goroutine per file", runMD5All)
+	register("concurrency/barrier/cyclic", "runs a stencil in phases that wait for each other at a barrier", runBarrier)
+	register("concurrency/boundedqueue", "feeds jobs to three consumers through a small blocking queue and closes it when done", runBoundedQueue)
+	register("concurrency/bounded", "digests the files of a tree on a fixed number of goroutines", runBoundedMD5All)
+	register("concurrency/broadcast", "sends five config versions to three reloaders that each receive every one", runBroadcast)
+	register("concurrency/cache", "serves twenty concurrent misses of one key with a single backend query", runReadThroughCache)
+	register("concurrency/contextpatterns", "carries a request ID into pool tasks, keeps part of a deadline in reserve and writes an audit record after cancellation", runContextPatterns)
+	register("concurrency/copyonwrite", "routes requests from a table that is replaced atomically while readers use it without locks", runCopyOnWrite)
+	register("concurrency/cooperative", "counts primes in a loop that checks for cancellation, then cancels one from inside", runCooperative)
+	register("concurrency/crawler", "crawls a small local site to a depth limit, one request at a time per host", runCrawler)
+	register("concurrency/dagrunner", "builds a small project whose steps wait for their dependencies, then keeps going past a failing test", runDAGRunner)
+	register("concurrency/debounce", "sends a search once typing pauses and redraws a scrolled page at most every 200ms, on a fake clock", runDebounce)
+	register("concurrency/deadlocks", "transfers both ways, gathers results and shuts a pool down with the deadlock-free versions of four programs that hang", runDeadlocks)
+	register("concurrency/donechannel", "reads from a never-ending producer until a quit channel closes, then cancels a context with it", runDoneChannel)
+	register("concurrency/eventloop", "keeps a chat room's state on one goroutine that runs posted and delayed tasks in turn", runEventLoop)
+	register("concurrency/filewalker", "adds up the files under a temporary tree read by parallel workers", runFileWalker)
+	register("concurrency/futures", "fetches a page's parts as futures on a pool and combines them with All, Any and Race", runFutures)
+	registerFlags("concurrency/generator", "chains channel generators and ranges over the result", &generatorFlags, runGenerator)
+	register("concurrency/lazyinit", "loads a config once for ten goroutines and retries a failed connection that sync.Once would have kept", runLazyInit)
+	register("concurrency/leaderelection", "elects one of three nodes through a lease, cuts it off and watches another take over once the lease expires", runLeaderElection)
+	register("concurrency/maintenance", "sweeps expired cache entries in small steps under a CPU budget", runMaintenance)
+	register("concurrency/leaks", "takes the fastest replica's answer, counts and stops a timer, then checks no goroutine is left", runLeaks)
+	register("concurrency/mapreduce", "counts words of several texts on parallel workers and merges the counts", runMapReduce)
+	register("concurrency/mux", "fans a channel out to a buffered, a dropping and a late subscriber that gets the recent values replayed", runMux)
+	register("concurrency/orderedpool", "converts subtitle lines on four goroutines, long ones finishing last, and prints them in input order", runOrderedPool)
+	register("concurrency/parallel", "checks page sizes, keeps the primes and stops at the first bad upload, a few items at a time", runParallel)
+	register("concurrency/parallelsort", "sorts a slice with parallel merge sort and quicksort", runParallelSort)
+	register("concurrency/pipeline", "parses and sums numbers through three stages, then stops them all when one line fails to parse", runPipeline)
+	register("concurrency/priorityselect", "receives from two channels, preferring one", runPrioritySelect)
+	register("concurrency/producer_consumer", "feeds three producers into two slow consumers through a small buffer and reports how full it got", runProducerConsumer)
+	register("concurrency/races", "withdraws, counts and squares from many goroutines with the race-free versions of three racy programs", runRaces)
+	register("concurrency/ringbuffer", "passes values from one producer to one consumer through a lock-free ring", runRingBuffer)
+	register("concurrency/rungroup", "runs a server, a pool and a scheduler together and stops them in order when one of them exits", runRunGroup)
+	register("concurrency/scattergather", "asks three backends at once and keeps what answers within a deadline", runScatterGather)
+	register("concurrency/scheduler", "runs an export that overruns its interval under each overlap policy, on a fake clock", runScheduler)
+	register("concurrency/scope", "runs three fetches in a scope and cancels the slow one when another fails", runScope)
+	register("concurrency/selectpatterns", "drops what a full queue cannot take, times out a wait and takes the first of several replies", runSelectPatterns)
+	register("concurrency/shardedmap", "counts words from several goroutines in a map split into locked shards", runShardedMap)
+	register("concurrency/shutdown", "stops intake, drains a pool, flushes a buffer and closes a store in phases, reporting the hook that timed out", runShutdown)
+	register("concurrency/singlewriter", "counts page hits and withdraws money through commands run by the goroutine that owns the state", runSingleWriter)
+	register("concurrency/singleflight", "collapses concurrent loads of one key into one call", runSingleflight)
+	register("concurrency/streamjoin", "joins clicks to the ads shown ten seconds before them and orders to the latest price, on a fake clock", runStreamJoin)
+	register("concurrency/subtasks/divide_and_conquer", "applies four evaluators to the same input and gives up on the slow one", runDivideAndConquer)
+	register("concurrency/subtasks/fetchers", "asks three search backends at once and keeps what arrives before the timeout", runFetchers)
+	register("concurrency/supervisor", "restarts a crashing consumer with backoff until it settles, then exits cleanly", runSupervisor)
+	register("concurrency/ticker", "stalls a ticker for two periods and shows what each missed-tick policy delivers, on a fake clock", runTicker)
+	register("concurrency/windows", "reports a moving three-second request rate from sliding windows on a fake clock", runWindows)
+	registerFlags("patterns/workerpool", "runs tasks on a bounded pool of goroutines", &workerPoolFlags, runWorkerPool)
+	register("pkg/pool", "runs twenty queries from four goroutines on connections from a pool that keeps two idle", runResourcePool)
+	register("pkg/runner", "runs a nightly job's tasks within a time limit, and stops one that runs out of time between tasks", runRunner)
+}
+
+func runBarrier(_ context.Context, w io.Writer) error {
+	cur := []float64{0, 0, 0, 90, 0, 0, 0, 0}
+	next := make([]float64, len(cur))
+	const workers, phases = 4, 3
+	b := cyclic.NewWithAction(workers, func() { cur, next = next, cur })
+
+	var wg sync.WaitGroup
+	chunk := len(cur) / workers
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			for phase := 0; phase < phases; phase++ {
+				for i := lo; i < hi; i++ {
+					sum, n := cur[i], 1.0
+					if i > 0 {
+						sum, n = sum+cur[i-1], n+1
+					}
+					if i < len(cur)-1 {
+						sum, n = sum+cur[i+1], n+1
+					}
+					next[i] = sum / n
+				}
+				b.Await()
+			}
+		}(i*chunk, (i+1)*chunk)
+	}
+	wg.Wait()
+	for _, v := range cur {
+		fmt.Fprintf(w, "%.1f ", v)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+func runBoundedQueue(ctx context.Context, w io.Writer) error {
+	q := boundedqueue.New[int](2)
+	var (
+		wg   sync.WaitGroup
+		done [3]int
+	)
+	for i := range done {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, err := q.PopContext(ctx); err != nil {
+					return
+				}
+				done[i]++
+			}
+		}()
+	}
+	pushed := 0
+	for job := range 30 {
+		if err := q.PushContext(ctx, job); err != nil {
+			break
+		}
+		pushed++
+	}
+	q.Close()
+	wg.Wait()
+	fmt.Fprintf(w, "%d jobs pushed through a queue of %d, %d handled\n", pushed, q.Cap(), done[0]+done[1]+done[2])
+	return ctx.Err()
+}
+
+func runBroadcast(ctx context.Context, w io.Writer) error {
+	config := broadcast.New[int]()
+	var wg sync.WaitGroup
+	seen := make([]int, 3)
+	for i := range seen {
+		s := config.Subscribe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, err := s.Next(ctx); err != nil {
+					return
+				}
+				seen[i]++
+			}
+		}()
+	}
+	for v := range 5 {
+		config.Broadcast(v)
+	}
+	config.Close()
+	wg.Wait()
+	fmt.Fprintln(w, "versions seen by each reloader:", seen)
+	return ctx.Err()
+}
+
+func runReadThroughCache(ctx context.Context, w io.Writer) error {
+	var queries atomic.Int32
+	prices := cache.New(func(ctx context.Context, sku string) (int, error) {
+		queries.Add(1)
+		select {
+		case <-time.After(10 * time.Millisecond):
+			return 1299, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	})
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prices.Get(ctx, "sku-42")
+		}()
+	}
+	wg.Wait()
+	price, err := prices.Get(ctx, "sku-42")
+	if err != nil {
+		return err
+	}
+	s := prices.Stats()
+	fmt.Fprintf(w, "price %d after %d gets, %d misses and backend queries: %d\n", price, s.Hits+s.Misses, s.Misses, queries.Load())
+	return nil
+}
+
+func runContextPatterns(ctx context.Context, w io.Writer) error {
+	requestID := ctxkeys.NewKey[string]("request ID")
+	part := ctxkeys.NewKey[int]("part")
+	pool := workerpool.New(2)
+	defer pool.Shutdown()
+
+	req, cancel := context.WithTimeout(requestID.WithValue(ctx, "req-42"), time.Second)
+	defer cancel()
+	call, cancelCall := contextpatterns.WithReserve(req, 200*time.Millisecond)
+	defer cancelCall()
+	var mu sync.Mutex
+	var done []string
+	for i := range 3 {
+		task := workerpool.WorkerFunc(func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			done = append(done, fmt.Sprintf("%s/%d", requestID.MustGet(ctx), part.MustGet(ctx)))
+			return nil
+		})
+		if err := pool.Run(call, contextpatterns.Inject(task, contextpatterns.Bind(part, i))); err != nil {
+			return err
+		}
+	}
+	cancel()
+	audit := <-contextpatterns.Go(req, time.Second, func(ctx context.Context) error {
+		fmt.Fprintf(w, "tasks %v; audit for %s written after the request was cancelled\n", done, requestID.MustGet(ctx))
+		return nil
+	})
+	return audit
+}
+
+func runCopyOnWrite(_ context.Context, w io.Writer) error {
+	r := copyonwrite.NewRouter(copyonwrite.Table{"/": "web", "/api/": "api-v1"})
+	var (
+		wg     sync.WaitGroup
+		routed atomic.Int32
+		swap   = make(chan struct{})
+	)
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				if i == 0 && j == 50 {
+					close(swap)
+				}
+				// Each lookup sees the old table or the new, never neither.
+				if _, ok := r.Route("/api/orders"); ok {
+					routed.Add(1)
+				}
+			}
+		}()
+	}
+	<-swap
+	r.Set("/api/", "api-v2")
+	wg.Wait()
+	fmt.Fprintln(w, "requests routed during the swap:", routed.Load())
+	fmt.Fprintln(w, "now routing /api/orders to", r.Table()["/api/"])
+	return nil
+}
+
+func runCrawler(ctx context.Context, w io.Writer) error {
+	pages := map[string]string{
+		"/":         `<a href="/docs">docs</a> <a href="/blog">blog</a>`,
+		"/docs":     `<a href="/docs/api">api</a> <a href="/">home</a>`,
+		"/blog":     `<a href="/blog/2024">2024</a>`,
+		"/docs/api": `<a href="/docs">docs</a>`,
+	}
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer site.Close()
+	var found []string
+	for p := range crawler.Crawl(ctx, []string{site.URL}, crawler.Options{
+		Workers:  2,
+		MaxDepth: 2,
+		Limiter:  func(string) ratelimit.Limiter { return ratelimit.NewTokenBucket(100, 1) },
+	}) {
+		found = append(found, fmt.Sprintf("%s depth %d: %d", strings.TrimPrefix(p.URL, site.URL), p.Depth, p.Status))
+	}
+	slices.Sort(found)
+	for _, f := range found {
+		fmt.Fprintln(w, f)
+	}
+	return ctx.Err()
+}
+
+func runDAGRunner(ctx context.Context, w io.Writer) error {
+	var mu sync.Mutex
+	var order []string
+	step := func(name string, err error) func(context.Context) error {
+		return func(ctx context.Context) error {
+			select {
+			case <-time.After(5 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return err
+		}
+	}
+	build := func(test error) []dagrunner.Job {
+		return []dagrunner.Job{
+			{Name: "fetch", Run: step("fetch", nil)},
+			{Name: "gen", Deps: []string{"fetch"}, Run: step("gen", nil)},
+			{Name: "compile", Deps: []string{"gen"}, Run: step("compile", nil)},
+			{Name: "lint", Deps: []string{"fetch"}, Run: step("lint", nil)},
+			{Name: "test", Deps: []string{"compile"}, Run: step("test", test)},
+			{Name: "docs", Deps: []string{"compile"}, Run: step("docs", nil)},
+			{Name: "package", Deps: []string{"test", "lint", "docs"}, Run: step("package", nil)},
+		}
+	}
+	if _, err := dagrunner.Run(ctx, dagrunner.Options{MaxParallel: 2}, build(nil)...); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "built in %d steps, packaged last: %v\n", len(order), order[len(order)-1] == "package")
+
+	results, err := dagrunner.Run(ctx, dagrunner.Options{Policy: dagrunner.ContinueOnError}, build(errors.New("TestParse failed"))...)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintf(w, "with a failing test: %v; docs %v; package %v\n", err, results["docs"], results["package"])
+	return nil
+}
+
+func runDeadlocks(_ context.Context, w io.Writer) error {
+	// Only the fixed versions run here; go test ./concurrency/deadlocks
+	// shows the others hang.
+	a, b := deadlocks.NewAccount(1, 100), deadlocks.NewAccount(2, 100)
+	var wg sync.WaitGroup
+	for _, pair := range [][2]*deadlocks.Account{{a, b}, {b, a}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				deadlocks.Transfer(pair[0], pair[1], 1)
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintf(w, "2000 transfers both ways, balances %d and %d\n", a.Balance(), b.Balance())
+
+	var handled []string
+	m := deadlocks.NewMailbox(func(msg string, post func(string)) {
+		handled = append(handled, msg)
+		if msg == "ping" {
+			post("pong")
+		}
+	})
+	m.Post("ping")
+	m.Close()
+	fmt.Fprintln(w, "mailbox handled", handled)
+
+	squares := deadlocks.Gather([]int{1, 2, 3, 4}, func(n int) int { return n * n })
+	slices.Sort(squares)
+	fmt.Fprintln(w, "gathered", squares)
+
+	p := deadlocks.NewPool(2)
+	var ran atomic.Int32
+	for range 5 {
+		p.Run(func() { ran.Add(1) })
+	}
+	p.Shutdown()
+	fmt.Fprintf(w, "pool ran %d tasks and shut down\n", ran.Load())
+	return nil
+}
+
+func runDoneChannel(ctx context.Context, w io.Writer) error {
+	quit := donechannel.NewQuit()
+	// The caller's context counts as a done channel too.
+	done := donechannel.Or(quit.Done(), ctx.Done())
+	naturals := donechannel.Produce(done, func(yield func(int) bool) {
+		for i := 1; yield(i); i++ {
+		}
+	})
+	sum := 0
+	for n := range donechannel.OrDone(done, naturals) {
+		if sum += n; sum > 100 {
+			quit.Close()
+		}
+	}
+	fmt.Fprintln(w, "sum when quit:", sum)
+
+	qctx, cancel := donechannel.Context(context.Background(), quit.Done())
+	defer cancel()
+	quit.Close()
+	<-qctx.Done()
+	fmt.Fprintln(w, "context:", context.Cause(qctx))
+	return ctx.Err()
+}
+
+func runEventLoop(ctx context.Context, w io.Writer) error {
+	loop := eventloop.New()
+	done := make(chan error, 1)
+	go func() { done <- loop.Run(ctx) }()
+
+	// members and log belong to the loop; only tasks touch them.
+	members := map[string]bool{}
+	var log []string
+	var wg sync.WaitGroup
+	for _, name := range []string{"ann", "bob", "cy"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loop.Post(func() {
+				members[name] = true
+				log = append(log, name+" joined")
+			})
+		}()
+	}
+	wg.Wait()
+	fired := make(chan struct{})
+	loop.PostAfter(5*time.Millisecond, func() {
+		defer close(fired)
+		log = append(log, fmt.Sprintf("reminder to %d members", len(members)))
+	})
+	select {
+	case <-fired:
+	case err := <-done:
+		return err
+	}
+	if err := loop.Do(ctx, func() {
+		fmt.Fprintln(w, len(log), "events, last:", log[len(log)-1])
+	}); err != nil {
+		return err
+	}
+	loop.Stop()
+	return <-done
+}
+
+func runFileWalker(ctx context.Context, w io.Writer) error {
+	root, err := os.MkdirTemp("", "filewalker")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"a", "a/b", "c"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, "file"), make([]byte, 512), 0o644); err != nil {
+			return err
+		}
+	}
+	u, err := filewalker.DiskUsage(ctx, filewalker.Options{Workers: 2}, root)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d files in %d directories, %d bytes\n", u.Files, u.Dirs, u.Bytes)
+	return nil
+}
+
+func runFutures(ctx context.Context, w io.Writer) error {
+	pool := workerpool.New(4)
+	defer pool.Shutdown()
+	// Cancelled before the pool shuts down, to stop the futures that lost.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	fetch := func(part string, delay time.Duration, err error) *futures.Future[string] {
+		return futures.Submit(ctx, pool, func(ctx context.Context) (string, error) {
+			select {
+			case <-time.After(delay):
+				return part, err
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		})
+	}
+	parts, err := futures.All(ctx, fetch("header", time.Millisecond, nil), fetch("body", 2*time.Millisecond, nil))
+	if err != nil {
+		return err
+	}
+	ad, err := futures.Any(ctx, fetch("ad from a", time.Millisecond, errors.New("no fill")), fetch("ad from b", 3*time.Millisecond, nil))
+	if err != nil {
+		return err
+	}
+	_, err = futures.Race(ctx, fetch("recommendations", time.Second, nil), futures.Go(ctx, func(ctx context.Context) (string, error) {
+		return "", errors.New("recommendations: deadline")
+	}))
+	fmt.Fprintf(w, "all: %v; any: %s; race: %v\n", parts, ad, err)
+	return nil
+}
+
+var generatorFlags = struct {
+	N int `flag:"n" usage:"number of values to take"`
+}{N: 6}
+
+func runGenerator(_ context.Context, w io.Writer) error {
+	squares := generator.Seq(func(done <-chan struct{}) <-chan int {
+		ints := generator.Take(done, generator.Repeat(done, 1, 2, 3, 4), generatorFlags.N)
+		return generator.Map(done, ints, func(v int) int { return v * v })
+	})
+	for v := range squares {
+		fmt.Fprint(w, v, " ")
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+func runLazyInit(_ context.Context, w io.Writer) error {
+	var loads atomic.Int32
+	config := lazyinit.NewOnceValue(func() string {
+		loads.Add(1)
+		return "eu-west-1"
+	})
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			config.Get()
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintf(w, "config %s loaded %d time(s) for 10 goroutines\n", config.Get(), loads.Load())
+
+	dials := 0
+	conn := lazyinit.NewAtomic(func() (string, error) {
+		if dials++; dials == 1 {
+			return "", errors.New("connection refused")
+		}
+		return fmt.Sprintf("conn#%d", dials), nil
+	})
+	_, err := conn.Get()
+	c, _ := conn.Get()
+	conn.Reset()
+	fresh, _ := conn.Get()
+	fmt.Fprintf(w, "first dial: %v; retried: %s; after Reset: %s\n", err, c, fresh)
+	return nil
+}
+
+func runLeaderElection(ctx context.Context, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+	svc := leaderelection.NewLockService(nil)
+	elected := make(chan string, 3)
+	for _, id := range []string{"node-a", "node-b", "node-c"} {
+		node := leaderelection.NewNode(id, svc, leaderelection.Config{
+			Key:           "scheduler",
+			TTL:           60 * time.Millisecond,
+			RenewDeadline: 40 * time.Millisecond,
+			RetryPeriod:   10 * time.Millisecond,
+			OnStartedLeading: func(ctx context.Context, term uint64) {
+				elected <- fmt.Sprintf("%s leads term %d", id, term)
+				<-ctx.Done()
+			},
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			node.Run(ctx)
+		}()
+	}
+	await := func() error {
+		select {
+		case e := <-elected:
+			fmt.Fprintln(w, e)
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := await(); err != nil {
+		return err
+	}
+	lease, _ := svc.Get("scheduler")
+	svc.Partition(lease.Holder, true)
+	fmt.Fprintln(w, lease.Holder, "cut off from the lock service")
+	return await()
+}
+
+func runLeaks(ctx context.Context, w io.Writer) error {
+	before := leaks.IgnoreCurrent()
+	gate := make(chan struct{})
+	a, err := leaks.First(ctx, "gophers",
+		func(q string) string { return "fast " + q },
+		func(q string) string { <-gate; return "slow " + q })
+	// The slow replica answers after First has returned, into the buffer.
+	close(gate)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "first answer: %q\n", a)
+
+	c := leaks.NewCounter()
+	for i := 1; i <= 4; i++ {
+		c.Add(i)
+	}
+	c.Close()
+	fmt.Fprintln(w, "counted:", c.Total())
+
+	stop := leaks.After(time.Hour, func() {})
+	stop()
+
+	left := leaks.Find(before)
+	fmt.Fprintln(w, len(left), "goroutines left behind")
+	for _, g := range left {
+		fmt.Fprintf(w, "  %s [%s]\n", g.Top, g.State)
+	}
+	return nil
+}
+
+func runMapReduce(ctx context.Context, w io.Writer) error {
+	texts := []string{
+		"the map step runs in parallel",
+		"the reduce step runs on one goroutine",
+		"the workers are bounded",
+	}
+	counts, err := mapreduce.MapReduce(ctx, slices.Values(texts), 2,
+		func(_ context.Context, text string) (map[string]int, error) {
+			counts := map[string]int{}
+			for _, word := range strings.Fields(text) {
+				counts[word]++
+			}
+			return counts, nil
+		},
+		func(acc, counts map[string]int) map[string]int {
+			for word, n := range counts {
+				acc[word] += n
+			}
+			return acc
+		}, map[string]int{})
+	if err != nil {
+		return err
+	}
+	for _, word := range slices.Sorted(maps.Keys(counts)) {
+		if counts[word] > 1 {
+			fmt.Fprintf(w, "%s: %d\n", word, counts[word])
+		}
+	}
+	return nil
+}
+
+func runOrderedPool(ctx context.Context, w io.Writer) error {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		for _, l := range []string{"hello", "how are you", "fine", "and you", "bye"} {
+			select {
+			case lines <- l:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	translate := func(ctx context.Context, l string) (string, error) {
+		// Longer lines take longer, so they finish out of order.
+		select {
+		case <-time.After(time.Duration(len(l)) * time.Millisecond):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		return strings.ToUpper(l), nil
+	}
+	for r := range orderedpool.Map(ctx, lines, orderedpool.Options{Workers: 4}, translate) {
+		if r.Err != nil {
+			return r.Err
+		}
+		fmt.Fprintf(w, "%d: %s\n", r.Seq+1, r.Value)
+	}
+	return ctx.Err()
+}
+
+func runParallel(ctx context.Context, w io.Writer) error {
+	pages := []string{"/", "/docs", "/blog", "/about"}
+	sizes, err := parallel.Map(ctx, pages, 2, func(ctx context.Context, page string) (int, error) {
+		// Longer paths take longer, so they finish out of order.
+		select {
+		case <-time.After(time.Duration(len(page)) * time.Millisecond):
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		return 1000 * len(page), nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, page := range pages {
+		fmt.Fprintf(w, "%-6s %5d bytes\n", page, sizes[i])
+	}
+
+	primes, err := parallel.Filter(ctx, []int{2, 9, 11, 15, 17, 21, 23}, 3, func(_ context.Context, n int) (bool, error) {
+		for d := 2; d*d <= n; d++ {
+			if n%d == 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "primes:", primes)
+
+	err = parallel.ForEach(ctx, []string{"a.png", "b.png", "broken.png", "c.png", "d.png", "e.png"}, 2, func(_ context.Context, file string) error {
+		if file == "broken.png" {
+			return fmt.Errorf("upload %s: bad header", file)
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintln(w, "uploads stopped:", err)
+	return nil
+}
+
+func runParallelSort(_ context.Context, w io.Writer) error {
+	rng := rand.New(rand.NewSource(1))
+	in := make([]int, 100000)
+	for i := range in {
+		in[i] = rng.Intn(1000)
+	}
+	for _, sort := range []struct {
+		name string
+		fn   func([]int, parallelsort.Options)
+	}{{"merge", parallelsort.MergeSort[int]}, {"quick", parallelsort.QuickSort[int]}} {
+		s := slices.Clone(in)
+		sort.fn(s, parallelsort.Options{Threshold: 1024, MaxDepth: 3})
+		fmt.Fprintf(w, "%s sort of %d ints: sorted=%v, first %v, last %v\n",
+			sort.name, len(s), slices.IsSorted(s), s[:3], s[len(s)-3:])
+	}
+	return nil
+}
+
+func runPipeline(ctx context.Context, w io.Writer) error {
+	for _, lines := range [][]string{{"1", "2", "3", "4"}, {"1", "two", "3", "4"}} {
+		p := pipeline.New(ctx, pipeline.Options{})
+		parsed := pipeline.Map(p, "parse", 2, pipeline.From(p, lines...), func(_ context.Context, s string) (int, error) {
+			return strconv.Atoi(s)
+		})
+		sum := 0
+		pipeline.Sink(p, "sum", parsed, func(_ context.Context, n int) error {
+			sum += n
+			return nil
+		})
+		if err := p.Wait(); err != nil {
+			fmt.Fprintf(w, "%q: %v\n", lines, err)
+			continue
+		}
+		fmt.Fprintf(w, "%q: sum %d\n", lines, sum)
+	}
+	return ctx.Err()
+}
+
+func runPrioritySelect(ctx context.Context, w io.Writer) error {
+	high, low := make(chan string, 1), make(chan string, 1)
+	low <- "report"
+	high <- "alert"
+	for i := 0; i < 2; i++ {
+		v, p, err := priorityselect.Select(ctx, high, low)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, p, v)
+	}
+	return nil
+}
+
+func runRaces(_ context.Context, w io.Writer) error {
+	// Only the fixed versions run here: the catalog's tests run under
+	// -race, and go test -race ./concurrency/races shows the others caught.
+	account := races.NewAccount(50)
+	tally := races.NewTally()
+	var withdrawn atomic.Int32
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if account.Withdraw(1) {
+					withdrawn.Add(1)
+				}
+				tally.Add("attempts")
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintf(w, "%d withdrawals of 1 from 50 in %d attempts, balance %d\n",
+		withdrawn.Load(), tally.Count("attempts"), account.Balance())
+	fmt.Fprintln(w, "squares:", races.Squares([]int{1, 2, 3, 4, 5}))
+	return nil
+}
+
+func runRingBuffer(ctx context.Context, w io.Writer) error {
+	r := ringbuffer.New[int](8)
+	const n = 1000
+	produced := make(chan error, 1)
+	go func() {
+		for i := 1; i <= n; i++ {
+			if err := r.Push(ctx, i); err != nil {
+				produced <- err
+				return
+			}
+		}
+		produced <- nil
+	}()
+	sum := 0
+	for range n {
+		v, err := r.Pop(ctx)
+		if err != nil {
+			<-produced
+			return err
+		}
+		sum += v
+	}
+	if err := <-produced; err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "moved %d values through a ring of %d slots, sum %d\n", n, r.Cap(), sum)
+	return nil
+}
+
+func runRunGroup(ctx context.Context, w io.Writer) error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	pool := workerpool.New(2)
+	var handled atomic.Int32
+	srv := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		pool.Run(r.Context(), workerpool.WorkerFunc(func(context.Context) error {
+			handled.Add(1)
+			return nil
+		}))
+	})}
+	sched := scheduler.New(nil)
+	var ticks atomic.Int32
+	sched.Add("tick", scheduler.Every(time.Millisecond), scheduler.Skip, func(context.Context) { ticks.Add(1) })
+
+	var g rungroup.Group
+	var order []string
+	g.OnExit = func(name string, _ error) { order = append(order, name) }
+	g.AddContext("scheduler", func(stop context.Context) error {
+		sched.Start()
+		<-stop.Done()
+		sched.Stop()
+		return nil
+	})
+	g.AddContext("pool", func(stop context.Context) error {
+		<-stop.Done()
+		pool.Shutdown()
+		return nil
+	})
+	g.Server("http", srv, ln, time.Second)
+	g.Add("client", func() error {
+		client := &http.Client{}
+		defer client.CloseIdleConnections()
+		for range 3 {
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+ln.Addr().String(), nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+		}
+		return errors.New("client finished")
+	}, func(error) {})
+	err = g.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintf(w, "%v after %d requests; stopped %s\n", err, handled.Load(), strings.Join(order, ", "))
+	return nil
+}
+
+func runScatterGather(ctx context.Context, w io.Writer) error {
+	backend := func(name string, delay time.Duration, err error) scattergather.Request[string] {
+		return scattergather.Request[string]{Name: name, Do: func(ctx context.Context) (string, error) {
+			select {
+			case <-time.After(delay):
+				return name + " data", err
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	results := scattergather.Gather(ctx, []scattergather.Request[string]{
+		backend("profile", time.Millisecond, nil),
+		backend("orders", 5*time.Millisecond, nil),
+		backend("recommendations", time.Second, nil),
+		backend("ads", time.Millisecond, errors.New("ad server down")),
+	}, 0)
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "%-15s  failed: %v\n", r.Name, r.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%-15s  %s\n", r.Name, r.Value)
+	}
+	return nil
+}
+
+func runSelectPatterns(ctx context.Context, w io.Writer) error {
+	queue := make(chan int, 2)
+	dropped := 0
+	for i := range 5 {
+		if !selectpatterns.TrySend(queue, i) {
+			dropped++
+		}
+	}
+	fmt.Fprintf(w, "queued %d, dropped %d\n", len(queue), dropped)
+
+	never := make(chan string)
+	_, err := selectpatterns.RecvTimeout(never, 5*time.Millisecond)
+	fmt.Fprintln(w, "waiting on a silent channel:", err)
+
+	replies := make([]<-chan string, 3)
+	for i := range replies {
+		c := make(chan string, 1)
+		go func() {
+			time.Sleep(time.Duration(i+1) * 10 * time.Millisecond)
+			c <- fmt.Sprint("reply from server ", i)
+		}()
+		replies[i] = c
+	}
+	_, reply, err := selectpatterns.First(ctx, replies...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, reply)
+	return nil
+}
+
+func runShardedMap(_ context.Context, w io.Writer) error {
+	counts := shardedmap.New[string, int](shardedmap.StringHash(), 8)
+	lines := []string{
+		"a map behind one lock serialises every write",
+		"a sharded map locks only the shard of the key",
+		"writes to different shards run in parallel",
+	}
+	var wg sync.WaitGroup
+	for _, line := range lines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, word := range strings.Fields(line) {
+				counts.Update(word, func(n int, _ bool) (int, bool) { return n + 1, true })
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintf(w, "%d distinct words in %d shards\n", counts.Len(), counts.Shards())
+	all := maps.Collect(counts.All())
+	for _, word := range slices.Sorted(maps.Keys(all)) {
+		if all[word] > 1 {
+			fmt.Fprintf(w, "%s: %d\n", word, all[word])
+		}
+	}
+	return nil
+}
+
+func runShutdown(ctx context.Context, w io.Writer) error {
+	var accepting atomic.Bool
+	accepting.Store(true)
+	pool := workerpool.New(2)
+	defer pool.Shutdown()
+	var buffered []string
+	var mu sync.Mutex
+	// Two jobs are running when the shutdown begins.
+	var started sync.WaitGroup
+	for i := range 2 {
+		started.Add(1)
+		go pool.Run(context.Background(), workerpool.WorkerFunc(func(context.Context) error {
+			started.Done()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			buffered = append(buffered, fmt.Sprint("job ", i))
+			mu.Unlock()
+			return nil
+		}))
+	}
+	started.Wait()
+	var written []string
+
+	var c shutdown.Coordinator
+	c.SetTimeout(shutdown.FlushBatchers, 20*time.Millisecond)
+	c.Add(shutdown.StopIntake, "listener", func(context.Context) error {
+		accepting.Store(false)
+		return nil
+	})
+	c.Add(shutdown.DrainPools, "pool", func(context.Context) error {
+		pool.Shutdown()
+		return nil
+	})
+	c.Add(shutdown.FlushBatchers, "buffer", func(context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		written = append(written, buffered...)
+		return nil
+	})
+	c.Add(shutdown.FlushBatchers, "metrics", func(ctx context.Context) error {
+		// A remote collector that does not answer.
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	c.Add(shutdown.CloseStores, "store", func(context.Context) error { return nil })
+
+	r := c.Shutdown(ctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	for _, h := range r.Hooks {
+		status := "ok"
+		if h.Err != nil {
+			status = h.Err.Error()
+		}
+		fmt.Fprintf(w, "%-14v %-8s %s\n", h.Phase, h.Name, status)
+	}
+	fmt.Fprintf(w, "accepting %v, wrote %d jobs, timed out: %v\n", accepting.Load(), len(written), r.TimedOut())
+	return nil
+}
+
+func runSingleflight(_ context.Context, w io.Writer) error {
+	var (
+		g     singleflight.Group
+		calls atomic.Int32
+		wg    sync.WaitGroup
+		gate  = make(chan struct{})
+	)
+	load := func() (interface{}, error) {
+		calls.Add(1)
+		<-gate
+		return "profile of gopher", nil
+	}
+	results := make([]interface{}, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _, _ = g.Do("gopher", load)
+		}()
+	}
+	// Let the callers pile up on the first load before it finishes.
+	for calls.Load() == 0 {
+		runtime.Gosched()
+	}
+	close(gate)
+	wg.Wait()
+	fmt.Fprintf(w, "%v: %d callers, %d loads\n", results[0], len(results), calls.Load())
+	return nil
+}
+
+func runStreamJoin(ctx context.Context, w io.Writer) error {
+	c := clock.NewFake(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	ad := func(s string) string { k, _, _ := strings.Cut(s, " "); return k }
+	send := func(ch chan<- string, v string) error {
+		select {
+		case ch <- v:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	// show writes the joins of out until it is closed.
+	show := func(out <-chan streamjoin.Joined[string, string, string]) <-chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for j := range out {
+				fmt.Fprintf(w, "  %s <- %s\n", j.Left, j.Right)
+			}
+		}()
+		return done
+	}
+
+	fmt.Fprintln(w, "clicks joined to impressions of the same ad within 10s:")
+	shown, clicks := make(chan string), make(chan string)
+	printed := show(streamjoin.Window(ctx.Done(), clicks, shown, ad, ad, c, 10*time.Second))
+	for _, ev := range []struct {
+		after time.Duration
+		ch    chan<- string
+		v     string
+	}{
+		{0, shown, "ad1 shown to ann"},
+		{0, shown, "ad2 shown to bob"},
+		{3 * time.Second, clicks, "ad1 clicked by ann"},
+		{15 * time.Second, clicks, "ad2 clicked by bob"},
+	} {
+		c.Advance(ev.after)
+		if err := send(ev.ch, ev.v); err != nil {
+			return err
+		}
+	}
+	close(shown)
+	close(clicks)
+	<-printed
+
+	fmt.Fprintln(w, "orders joined to the latest price:")
+	prices, orders := make(chan string), make(chan string)
+	printed = show(streamjoin.Latest(ctx.Done(), orders, prices, ad, ad, c, 0))
+	for _, ev := range []struct {
+		ch chan<- string
+		v  string
+	}{
+		{prices, "apple 1.00"},
+		{orders, "apple x3"},
+		{prices, "apple 1.20"},
+		{orders, "apple x1"},
+		{orders, "pear x2"},
+	} {
+		if err := send(ev.ch, ev.v); err != nil {
+			return err
+		}
+	}
+	close(orders)
+	<-printed
+	close(prices)
+	return nil
+}
+
+func runSupervisor(ctx context.Context, w io.Writer) error {
+	var crashes atomic.Int32
+	consumer := func(ctx context.Context) error {
+		if crashes.Add(1) <= 2 {
+			panic("nil message")
+		}
+		return nil
+	}
+	var mu sync.Mutex
+	exits := map[string]int{}
+	s := supervisor.New(supervisor.Options{
+		Backoff: retry.Constant(time.Millisecond),
+		OnExit: func(child string, _ error) {
+			mu.Lock()
+			exits[child]++
+			mu.Unlock()
+		},
+	},
+		supervisor.Child{Name: "consumer", Run: consumer, Restart: supervisor.Transient},
+		supervisor.Child{Name: "warmup", Run: func(context.Context) error { return nil }, Restart: supervisor.Temporary},
+	)
+	if err := s.Run(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "consumer ran %d times, warmup %d\n", exits["consumer"], exits["warmup"])
+	return nil
+}
+
+func runWindows(ctx context.Context, w io.Writer) error {
+	c := clock.NewFake(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	requests := make(chan string)
+	defer close(requests)
+	rates := windows.Sliding(ctx.Done(), requests, c, 3*time.Second, time.Second)
+	for sec, n := range []int{5, 9, 2, 7, 0} {
+		for i := range n {
+			select {
+			case requests <- fmt.Sprintf("GET /item/%d", i):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		c.Advance(time.Second)
+		select {
+		case win := <-rates:
+			fmt.Fprintf(w, "%ds: %d requests this second, %.1f/s over the last 3s\n",
+				sec+1, n, float64(len(win.Items))/win.End.Sub(win.Start).Seconds())
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+var workerPoolFlags = struct {
+	Tasks   int `flag:"n" usage:"number of tasks"`
+	Workers int `flag:"workers" usage:"number of goroutines in the pool"`
+}{Tasks: 10, Workers: 3}
+
+func runWorkerPool(ctx context.Context, w io.Writer) error {
+	p := workerpool.New(workerPoolFlags.Workers)
+	defer p.Shutdown()
+	var (
+		wg   sync.WaitGroup
+		done atomic.Int32
+	)
+	for range workerPoolFlags.Tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Run(ctx, workerpool.WorkerFunc(func(context.Context) error {
+				done.Add(1)
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintln(w, done.Load(), "tasks on", p.Size(), "goroutines")
+	return ctx.Err()
+}
+
+// conn is a connection for runResourcePool.
+type conn struct{ id int32 }
+
+func (*conn) Close() error { return nil }
+
+func runResourcePool(ctx context.Context, w io.Writer) error {
+	var made atomic.Int32
+	p, err := pool.New(func() (*conn, error) { return &conn{made.Add(1)}, nil }, 2)
+	// More than two connections are made only while more than two queries
+	// run at once; the extra ones are closed when released.
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		uses = map[int32]int{}
+	)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 5 {
+				c, err := p.Acquire()
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				uses[c.id]++
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				p.Release(c)
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintf(w, "20 queries on %d connections, %d kept idle\n", len(uses), p.Idle())
+	return ctx.Err()
+}
+
+func runRunner(ctx context.Context, w io.Writer) error {
+	for _, limit := range []time.Duration{time.Second, 25 * time.Millisecond} {
+		var (
+			mu   sync.Mutex
+			done []string
+		)
+		r := runner.New(limit)
+		// A cancelled ctx interrupts the runner between tasks.
+		stop := context.AfterFunc(ctx, r.Interrupt)
+		for _, step := range []string{"dump the database", "compress the dump", "upload it"} {
+			r.Add(func(id int) {
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				defer mu.Unlock()
+				done = append(done, step)
+			})
+		}
+		err := r.Start()
+		stop()
+		mu.Lock()
+		// A task running at the timeout is left to finish on its own.
+		fmt.Fprintf(w, "limit %v: %q, err %v\n", limit, done, err)
+		mu.Unlock()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runMD5All(ctx context.Context, w io.Writer) error {
+	return digestTree(ctx, w, parallelism.MD5All)
+}
+
+func runBoundedMD5All(ctx context.Context, w io.Writer) error {
+	return digestTree(ctx, w, bounded_parallelism.MD5All)
+}
+
+// digestTree writes a few files to a temporary directory and prints the
+// digests md5All computes for them.
+func digestTree(ctx context.Context, w io.Writer, md5All func(root string) (map[string][md5.Size]byte, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	root, err := os.MkdirTemp("", "md5all")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{"a.txt": "alpha", "b.txt": "beta", filepath.Join("sub", "c.txt"): "gamma"}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	sums, err := md5All(root)
+	if err != nil {
+		return err
+	}
+	for _, path := range slices.Sorted(maps.Keys(sums)) {
+		rel, _ := filepath.Rel(root, path)
+		fmt.Fprintf(w, "%x  %s\n", sums[path], filepath.ToSlash(rel))
+	}
+	return nil
+}
+
+func runCooperative(ctx context.Context, w io.Writer) error {
+	n, err := cooperative.CountPrimes(ctx, 10000, 100)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, n, "primes below 10000")
+
+	// The body cancels the loop itself; the loop notices at its next check.
+	loopCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done, err := cooperative.Loop(loopCtx, 1_000_000, 1000, func(i int) {
+		if i == 2500 {
+			cancel()
+		}
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintf(w, "cancelled at 2500, stopped after %d of 1000000 iterations: %v\n", done, err)
+	return nil
+}
+
+func runDebounce(_ context.Context, w io.Writer) error {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	c := clock.NewFake(start)
+	search := debounce.Debounce(func() {
+		fmt.Fprintln(w, "search sent at", c.Since(start))
+	}, 300*time.Millisecond, debounce.WithClock(c))
+	redraw := debounce.Throttle(func() {
+		fmt.Fprintln(w, "redrawn at", c.Since(start))
+	}, 200*time.Millisecond, debounce.WithClock(c))
+	// A keystroke and a scroll event every 50ms for half a second.
+	for range 10 {
+		search()
+		redraw()
+		c.Advance(50 * time.Millisecond)
+	}
+	c.Advance(time.Second)
+	return nil
+}
+
+func runDivideAndConquer(_ context.Context, w io.Writer) error {
+	type pair struct{ a, b int }
+	eval := func(name string, delay time.Duration, op func(a, b int) int) divide_and_conquer.Evaluator {
+		return divide_and_conquer.EvaluatorFunc(func(in interface{}) (interface{}, error) {
+			time.Sleep(delay)
+			p := in.(pair)
+			return fmt.Sprintf("%s %d", name, op(p.a, p.b)), nil
+		})
+	}
+	results, errs := divide_and_conquer.DivideAndConquer(pair{6, 3}, []divide_and_conquer.Evaluator{
+		eval("sum", 0, func(a, b int) int { return a + b }),
+		eval("product", 0, func(a, b int) int { return a * b }),
+		eval("quotient", 0, func(a, b int) int { return a / b }),
+		eval("slow difference", 50*time.Millisecond, func(a, b int) int { return a - b }),
+	}, 10*time.Millisecond)
+	var got []string
+	for _, r := range results {
+		got = append(got, r.(string))
+	}
+	slices.Sort(got)
+	fmt.Fprintln(w, strings.Join(got, ", "))
+	fmt.Fprintln(w, len(errs), "evaluator timed out")
+	return nil
+}
+
+// searchBackend is a concurrency/subtasks/fetchers.Fetcher that answers
+// after a delay.
+type searchBackend struct {
+	name  string
+	delay time.Duration
+}
+
+func (f searchBackend) GetName() string { return f.name }
+
+func (f searchBackend) Fetch(ctx context.Context, url string) (string, error) {
+	t := time.NewTimer(f.delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return fmt.Sprintf("%s results for %s", f.name, url), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func runFetchers(_ context.Context, w io.Writer) error {
+	results, errs := fetchers.FetchResults("golang.org", []fetchers.Fetcher{
+		searchBackend{"web", 5 * time.Millisecond},
+		searchBackend{"images", 10 * time.Millisecond},
+		searchBackend{"video", time.Second},
+	}, 100*time.Millisecond)
+	slices.Sort(results)
+	for _, r := range results {
+		fmt.Fprintln(w, r)
+	}
+	for _, err := range errs {
+		fmt.Fprintln(w, err)
+	}
+	return nil
+}
+
+func runMaintenance(ctx context.Context, w io.Writer) error {
+	r := maintenance.New(maintenance.Options{Budget: 0.05, Slice: time.Millisecond})
+	// An incremental sweep over a cache, a hundred keys a step, resuming
+	// where the last turn stopped.
+	now := time.Now()
+	var mu sync.Mutex
+	cache := map[int]time.Time{}
+	for i := range 1000 {
+		cache[i] = now.Add(-time.Duration(i%2) * time.Hour)
+	}
+	cursor := 0
+	r.Register("evict", time.Minute, maintenance.ChoreFunc(func(context.Context) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for end := cursor + 100; cursor < end && cursor < 1000; cursor++ {
+			if now.Sub(cache[cursor]) > 30*time.Minute {
+				delete(cache, cursor)
+			}
+		}
+		if cursor < 1000 {
+			return false, nil
+		}
+		cursor = 0
+		return true, nil
+	}))
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(runCtx)
+	}()
+	for r.Stats()["evict"].Passes == 0 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	st := r.Stats()["evict"]
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Fprintf(w, "one pass in %d steps, %d of 1000 entries left\n", st.Steps, len(cache))
+	return nil
+}
+
+func runMux(ctx context.Context, w io.Writer) error {
+	src := make(chan string)
+	m := mux.New[string](src, mux.Options{History: 2})
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+	defer func() { <-done }()
+
+	early := m.Subscribe(mux.SubOptions{Buffer: 3})
+	slow := m.Subscribe(mux.SubOptions{Buffer: 1, Policy: mux.DropOldest})
+	for _, v := range []string{"v1", "v2", "v3"} {
+		select {
+		case src <- v:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	var got []string
+	for range 3 {
+		got = append(got, <-early.C)
+	}
+	fmt.Fprintln(w, "early subscriber:", strings.Join(got, " "))
+
+	late := m.Subscribe(mux.SubOptions{Replay: 2})
+	close(src)
+	got = nil
+	for v := range late.C {
+		got = append(got, v)
+	}
+	fmt.Fprintln(w, "late subscriber replayed:", strings.Join(got, " "))
+	got = nil
+	for v := range slow.C {
+		got = append(got, v)
+	}
+	fmt.Fprintf(w, "slow subscriber kept %s, dropped %d\n", strings.Join(got, " "), slow.Dropped())
+	return nil
+}
+
+func runProducerConsumer(ctx context.Context, w io.Writer) error {
+	q := producer_consumer.New(4)
+	var consumed sync.WaitGroup
+	consumed.Add(1)
+	go func() {
+		defer consumed.Done()
+		q.Consume(2, func(interface{}) { time.Sleep(time.Millisecond) })
+	}()
+
+	var produced sync.WaitGroup
+	errs := make([]error, 3)
+	for p := range errs {
+		produced.Add(1)
+		go func() {
+			defer produced.Done()
+			for i := range 10 {
+				if err := q.Put(ctx, fmt.Sprint(p, "-", i)); err != nil {
+					errs[p] = err
+					return
+				}
+			}
+		}()
+	}
+	produced.Wait()
+	q.Close()
+	consumed.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	st := q.Stats()
+	fmt.Fprintf(w, "%d produced, %d consumed, buffer peaked at %d of %d\n", st.Produced, st.Consumed, st.MaxDepth, st.Capacity)
+	fmt.Fprintf(w, "producers waited for room %d times, %v on average\n", st.Blocked, st.MeanBlockTime().Round(10*time.Microsecond))
+	return nil
+}
+
+func runScheduler(ctx context.Context, w io.Writer) error {
+	for _, policy := range []struct {
+		name    string
+		overlap scheduler.Overlap
+	}{{"skip", scheduler.Skip}, {"queue", scheduler.Queue}, {"parallel", scheduler.Parallel}} {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		s := scheduler.New(c)
+		started := make(chan struct{}, 3)
+		release := make(chan struct{})
+		// The export takes longer than its one minute interval until it
+		// is released.
+		s.Add("export", scheduler.Every(time.Minute), policy.overlap, func(ctx context.Context) {
+			started <- struct{}{}
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+		})
+		s.Start()
+		for range 3 {
+			c.BlockUntil(1)
+			c.Advance(time.Minute)
+			if policy.overlap == scheduler.Parallel {
+				<-started
+			}
+		}
+		c.BlockUntil(1)
+		close(release)
+		for st := s.Stats("export"); st.Runs+st.Skipped < 3 || st.Running > 0; st = s.Stats("export") {
+			time.Sleep(time.Millisecond)
+		}
+		s.Stop()
+		st := s.Stats("export")
+		fmt.Fprintf(w, "%-8s 3 activations: %d runs, %d skipped, %d queued\n", policy.name, st.Runs, st.Skipped, st.Queued)
+	}
+	return nil
+}
+
+func runScope(ctx context.Context, w io.Writer) error {
+	var mu sync.Mutex
+	status := map[string]string{}
+	report := func(name, s string) {
+		mu.Lock()
+		status[name] = s
+		mu.Unlock()
+	}
+	fetch := func(name string, d time.Duration, fail bool) func(context.Context) error {
+		return func(ctx context.Context) error {
+			t := time.NewTimer(d)
+			defer t.Stop()
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				report(name, "cancelled")
+				return ctx.Err()
+			}
+			if fail {
+				report(name, "failed")
+				return fmt.Errorf("%s: backend unavailable", name)
+			}
+			report(name, "done")
+			return nil
+		}
+	}
+	err := scope.Run(ctx, func(s *scope.Scope) error {
+		s.Go(fetch("profile", time.Millisecond, false))
+		s.Go(fetch("orders", 5*time.Millisecond, true))
+		s.Go(fetch("recommendations", time.Second, false))
+		return nil
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	for _, name := range slices.Sorted(maps.Keys(status)) {
+		fmt.Fprintf(w, "%-15s %s\n", name, status[name])
+	}
+	fmt.Fprintln(w, "scope:", err)
+	return nil
+}
+
+func runSingleWriter(ctx context.Context, w io.Writer) error {
+	counts := singlewriter.NewCounters()
+	defer counts.Close()
+	var wg sync.WaitGroup
+	for _, path := range []string{"/", "/about", "/", "/", "/about", "/blog"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				counts.Add(ctx, path, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	snap, err := counts.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	for _, path := range slices.Sorted(maps.Keys(snap)) {
+		fmt.Fprintf(w, "%-6s %d hits\n", path, snap[path])
+	}
+
+	// Call returns the result of a command: the loop owns the balance,
+	// so the check and the withdrawal cannot interleave with another.
+	type account struct{ balance int }
+	l := singlewriter.Start(account{balance: 100}, 8)
+	defer l.Close()
+	for _, amount := range []int{60, 60} {
+		ok, err := singlewriter.Call(ctx, l, func(a *account) bool {
+			if a.balance < amount {
+				return false
+			}
+			a.balance -= amount
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "withdraw %d: %v\n", amount, ok)
+	}
+	return nil
+}
+
+// stallingClock is a clock.Fake that wakes up the given wait late, as a
+// stalled process would.
+type stallingClock struct {
+	*clock.Fake
+	waits, late int
+	by          time.Duration
+}
+
+func (c *stallingClock) After(d time.Duration) <-chan time.Time {
+	if c.waits++; c.waits == c.late {
+		d += c.by
+	}
+	return c.Fake.After(d)
+}
+
+func runTicker(ctx context.Context, w io.Writer) error {
+	for _, policy := range []struct {
+		name   string
+		missed ticker.Missed
+	}{{"coalesce", ticker.Coalesce}, {"burst", ticker.Burst}, {"skip", ticker.Skip}} {
+		// The second wait, for the tick due at 2s, ends at 4.5s.
+		c := &stallingClock{Fake: clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)), late: 2, by: 2500 * time.Millisecond}
+		t := ticker.New(time.Second, ticker.Options{Missed: policy.missed, Clock: c})
+		var ticks []string
+		for len(ticks) < 4 {
+			select {
+			case tk := <-t.C:
+				s := fmt.Sprintf("#%d at %v", tk.Seq, tk.Time.Sub(t.Epoch()))
+				if tk.Missed > 0 {
+					s += fmt.Sprintf(" (%d missed)", tk.Missed)
+				}
+				ticks = append(ticks, s)
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			default:
+				// Move the clock on once the ticker waits for it.
+				if c.Pending() > 0 {
+					c.AdvanceToNext()
+				} else {
+					runtime.Gosched()
+				}
+			}
+		}
+		t.Stop()
+		fmt.Fprintf(w, "%-8s %s\n", policy.name, strings.Join(ticks, ", "))
+	}
+	return nil
+}
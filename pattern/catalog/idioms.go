@@ -0,0 +1,278 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/debounce"
+	"github.com/crazybber/go-patterns/idioms/callback2chan"
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/idioms/ctxkeys/auth"
+	"github.com/crazybber/go-patterns/idioms/ctxkeys/requestid"
+	"github.com/crazybber/go-patterns/idioms/di"
+	ledger "github.com/crazybber/go-patterns/idioms/errors"
+	"github.com/crazybber/go-patterns/idioms/memoize"
+	"github.com/crazybber/go-patterns/idioms/optional"
+	"github.com/crazybber/go-patterns/idioms/optionaliface"
+	"github.com/crazybber/go-patterns/idioms/requestscope"
+	"github.com/crazybber/go-patterns/idioms/result"
+	"github.com/crazybber/go-patterns/idioms/unwrap"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+func init() {
+	register("idioms/callback2chan", "ranges over a callback-driven directory walk and stops it halfway", runCallback2Chan)
+	register("idioms/clock", "debounces a burst of keystrokes on a fake clock, without waiting", runClock)
+	register("idioms/ctxkeys", "carries a request ID and a principal through a context under keys no other package can name", runCtxKeys)
+	register("idioms/di", "wires a signup service in a composition root", runDI)
+	register("idioms/errors", "declines transfers with sentinel, typed and joined errors, then collects the failures of a batch", runErrors)
+	register("idioms/memoize", "remembers an expensive lookup per key, running it once for concurrent callers", runMemoize)
+	register("idioms/optional", "tells a discount of 0% from no discount, and reads both from JSON", runOptional)
+	register("idioms/optionaliface", "finds the Flusher hidden behind a counting response writer", runOptionalIface)
+	register("idioms/requestscope", "serves a user with dependencies from a request struct, and fails without the middleware that fills a context bag", runRequestScope)
+	register("idioms/result", "chains fallible steps on Result values", runResult)
+	register("idioms/unwrap", "looks through two decorators for the Seeker they hide", runUnwrap)
+}
+
+func runCallback2Chan(ctx context.Context, w io.Writer) error {
+	// walk is the callback API: it calls fn for each file until fn fails.
+	walk := func(fn func(name string) error) error {
+		for i := 1; ; i++ {
+			if err := fn(fmt.Sprintf("file%d.go", i)); err != nil {
+				return err
+			}
+		}
+	}
+	src := func(emit func(string), done func(error)) func() {
+		stop := make(chan struct{})
+		exited := make(chan struct{})
+		go func() {
+			defer close(exited)
+			done(walk(func(name string) error {
+				select {
+				case <-stop:
+					return context.Canceled
+				default:
+				}
+				emit(name)
+				return nil
+			}))
+		}()
+		return func() {
+			select {
+			case <-stop:
+			default:
+				close(stop)
+			}
+			<-exited
+		}
+	}
+	n := 0
+	for name, err := range callback2chan.Seq(ctx, callback2chan.Source[string](src)) {
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, name)
+		if n++; n == 3 {
+			break
+		}
+	}
+	fmt.Fprintln(w, "stopped the walk after", n, "files")
+	return nil
+}
+
+func runCtxKeys(_ context.Context, w io.Writer) error {
+	ctx := requestid.With(context.Background(), "r-1")
+	ctx = auth.With(ctx, auth.Principal{ID: "alice", Roles: []string{"admin"}})
+
+	id, _ := requestid.Get(ctx)
+	p, ok := auth.Get(ctx)
+	fmt.Fprintln(w, "request", id, "principal", p.ID, p.Roles, ok)
+	return nil
+}
+
+func runErrors(ctx context.Context, w io.Writer) error {
+	l := ledger.NewLedger(map[string]int64{"ann": 100, "bob": 20})
+	if err := l.Transfer(ledger.Transfer{From: "bob", To: "ann", Amount: 50}); errors.Is(err, ledger.ErrInsufficientFunds) {
+		fmt.Fprintln(w, "declined:", err)
+	}
+	err := l.Transfer(ledger.Transfer{From: "ann", Amount: 0})
+	var fe *ledger.FieldError
+	if errors.As(err, &fe) {
+		fmt.Fprintf(w, "invalid (%s first): %s\n", fe.Field, strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
+
+	pool := workerpool.New(2)
+	defer pool.Shutdown()
+	tasks := map[string]workerpool.Worker{}
+	for _, t := range []ledger.Transfer{
+		{From: "ann", To: "bob", Amount: 10},
+		{From: "eve", To: "ann", Amount: 1},
+		{From: "bob", To: "ann", Amount: 500},
+	} {
+		tasks[fmt.Sprintf("%s->%s", t.From, t.To)] = workerpool.WorkerFunc(func(context.Context) error {
+			return l.Transfer(t)
+		})
+	}
+	err = ledger.RunAll(ctx, pool, tasks)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var failed []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		failed = append(failed, e.Error())
+	}
+	sort.Strings(failed)
+	fmt.Fprintf(w, "batch: %d of %d transfers failed\n", len(failed), len(tasks))
+	for _, f := range failed {
+		fmt.Fprintln(w, " ", f)
+	}
+	return nil
+}
+
+func runClock(_ context.Context, w io.Writer) error {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	c := clock.NewFake(start)
+	save := debounce.Debounce(func() {
+		fmt.Fprintln(w, "saved", c.Since(start), "after the first keystroke")
+	}, 500*time.Millisecond, debounce.WithClock(c))
+	for range 5 {
+		save()
+		c.Advance(100 * time.Millisecond)
+	}
+	c.Advance(time.Hour)
+	return nil
+}
+
+func runDI(ctx context.Context, w io.Writer) error {
+	app := di.Wire(w)
+	_, err := app.Signup.Register(ctx, "dee@example.com")
+	return err
+}
+
+func runMemoize(_ context.Context, w io.Writer) error {
+	var lookups atomic.Int32
+	geocode := memoize.Memoize(func(city string) (string, error) {
+		lookups.Add(1)
+		time.Sleep(5 * time.Millisecond)
+		return fmt.Sprintf("coordinates of %s", city), nil
+	}, memoize.TTL(time.Minute), memoize.MaxSize(100))
+
+	var wg sync.WaitGroup
+	for _, city := range []string{"Oslo", "Lima", "Oslo", "Oslo", "Lima"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			geocode(city)
+		}()
+	}
+	wg.Wait()
+	v, err := geocode("Oslo")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s; 6 calls, %d lookups\n", v, lookups.Load())
+	return nil
+}
+
+func runOptional(_ context.Context, w io.Writer) error {
+	type patch struct {
+		Discount optional.Optional[int] `json:"discount"`
+	}
+	for _, body := range []string{`{}`, `{"discount":0}`, `{"discount":15}`} {
+		var p patch
+		if err := json.Unmarshal([]byte(body), &p); err != nil {
+			return err
+		}
+		price := optional.Map(p.Discount, func(d int) int { return 200 * (100 - d) / 100 })
+		fmt.Fprintf(w, "%-16s discount %-9v price %d\n", body, p.Discount, price.OrElse(200))
+	}
+	return nil
+}
+
+func runOptionalIface(_ context.Context, w io.Writer) error {
+	var rw http.ResponseWriter = &optionaliface.CountingWriter{ResponseWriter: httptest.NewRecorder()}
+	_, direct := rw.(http.Flusher)
+	_, unwrapped := optionaliface.As[http.Flusher](rw)
+	fmt.Fprintln(rw, "hello")
+	flushed, err := optionaliface.Flush(rw)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "type assertion finds a Flusher: %v; As finds one: %v; flushed: %v\n", direct, unwrapped, flushed)
+	return nil
+}
+
+func runRequestScope(_ context.Context, w io.Writer) error {
+	users := requestscope.MapStore{"42": {ID: "42", Name: "Gopher"}}
+	audit := auditLog{w}
+	for _, h := range []struct {
+		name string
+		h    http.Handler
+	}{
+		{"request struct", &requestscope.Server{Store: users, Audit: audit}},
+		{"context bag", requestscope.WithDeps(requestscope.BagHandler, users, audit)},
+		{"bag without middleware", requestscope.BagHandler},
+	} {
+		rec := httptest.NewRecorder()
+		h.h.ServeHTTP(rec, httptest.NewRequest("GET", "/?id=42", nil))
+		fmt.Fprintf(w, "%-22s %d %s", h.name, rec.Code, rec.Body)
+	}
+	return nil
+}
+
+// auditLog writes the events of idioms/requestscope to w.
+type auditLog struct{ w io.Writer }
+
+func (a auditLog) Record(requestID, event string) {
+	fmt.Fprintf(a.w, "audit: %q %s\n", requestID, event)
+}
+
+func runResult(_ context.Context, w io.Writer) error {
+	for _, input := range []string{" 42 ", "forty-two"} {
+		r := result.Map(
+			result.Then(result.Ok(strings.TrimSpace(input)), strconv.Atoi),
+			func(n int) string { return strconv.Itoa(n * 2) },
+		)
+		fmt.Fprintln(w, r)
+	}
+	return nil
+}
+
+func runUnwrap(_ context.Context, w io.Writer) error {
+	var r io.Reader = &countingReader{r: &countingReader{r: strings.NewReader("hello, world")}}
+	_, direct := r.(io.Seeker)
+	s, found := unwrap.As[io.Seeker](r)
+	fmt.Fprintf(w, "type assertion finds a Seeker: %v; As finds one: %v, %d wrappers down\n", direct, found, len(unwrap.Chain(r))-1)
+	if found {
+		s.Seek(7, io.SeekStart)
+		b, _ := io.ReadAll(r)
+		fmt.Fprintf(w, "read %q after seeking past the wrappers\n", b)
+	}
+	return nil
+}
+
+// countingReader is a decorator that exposes what it wraps through Unwrap.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func (c *countingReader) Unwrap() io.Reader { return c.r }
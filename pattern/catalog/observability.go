@@ -0,0 +1,202 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/benchmarks/backpressure"
+	"github.com/crazybber/go-patterns/benchmarks/pooling"
+	"github.com/crazybber/go-patterns/benchmarks/sharedstate"
+	poolbench "github.com/crazybber/go-patterns/benchmarks/workerpool"
+	"github.com/crazybber/go-patterns/observability/aggregate"
+	"github.com/crazybber/go-patterns/observability/errcollect"
+	"github.com/crazybber/go-patterns/observability/logsample"
+	"github.com/crazybber/go-patterns/performance/freelist"
+	profile "github.com/crazybber/go-patterns/profiling"
+)
+
+func init() {
+	register("observability/aggregate", "counts requests on a shard per goroutine and merges the shards for a snapshot", runAggregate)
+	register("observability/errcollect", "groups the errors two workers report by fingerprint, most frequent first", runErrCollect)
+	register("observability/logsample", "keeps the first lines of a burst of identical logs and summarises the rest", runLogSample)
+	register("performance/freelist", "reuses message payloads by size class and catches a task put back twice", runFreeList)
+	register("profiling", "times a big factorial with a deferred call that logs how long it lasted", runProfiling)
+	register("benchmarks/backpressure", "offers more tasks than a pool and a queue can run and samples how each pushes back", runBackpressure)
+	register("benchmarks/pooling", "counts the allocations per request of a new buffer, a free list and a sync.Pool", runPooling)
+	register("benchmarks/sharedstate", "times increments of a counter behind a mutex, a channel and an atomic", runSharedState)
+	register("benchmarks/workerpool", "runs a batch of each task profile on every executor design and tabulates latencies", runPoolBench)
+}
+
+func runAggregate(ctx context.Context, w io.Writer) error {
+	requests := aggregate.New()
+	requests.Start(time.Millisecond)
+	defer requests.Stop()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		shard := requests.Shard()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				shard.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintln(w, "requests:", requests.Snapshot())
+	return nil
+}
+
+var errDiskFull = errors.New("disk full")
+
+func runErrCollect(ctx context.Context, w io.Writer) error {
+	c := errcollect.New(errcollect.Options{})
+	uploads, thumbs := c.Reporter("upload"), c.Reporter("thumbnail")
+	for i := range 3 {
+		uploads(fmt.Errorf("write chunk %d: %w", i, errDiskFull))
+	}
+	thumbs(fmt.Errorf("write chunk 9: %w", errDiskFull))
+	thumbs(errors.New("decode: unknown format"))
+	for _, g := range c.Report() {
+		fmt.Fprintln(w, g.Count, g.Chain, g.Exemplars[len(g.Exemplars)-1].Message)
+	}
+	return nil
+}
+
+func runLogSample(ctx context.Context, w io.Writer) error {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	h := logsample.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}), logsample.Options{
+		Default: logsample.Policy{First: 2, Thereafter: 5, Period: time.Second},
+		Now:     func() time.Time { return now },
+	})
+	logger := slog.New(h)
+	for i := range 12 {
+		logger.WarnContext(ctx, "retrying", "attempt", i)
+	}
+	now = now.Add(time.Second)
+	logger.WarnContext(ctx, "retrying", "attempt", 12)
+	logger.ErrorContext(ctx, "giving up")
+	logger.ErrorContext(ctx, "giving up")
+	logger.ErrorContext(ctx, "giving up")
+	return h.Flush(ctx)
+}
+
+func runFreeList(ctx context.Context, w io.Writer) error {
+	messages := freelist.NewMessages(true)
+	small := messages.Get(100)
+	large := messages.Get(3000)
+	fmt.Fprintln(w, "payload capacities:", cap(small.Payload), cap(large.Payload), "outstanding:", messages.Outstanding())
+	small.Payload = append(small.Payload, "hello"...)
+	messages.Put(small)
+	messages.Put(large)
+	again := messages.Get(200)
+	fmt.Fprintln(w, "reused:", again == small, "empty:", len(again.Payload) == 0, "outstanding:", messages.Outstanding())
+	messages.Put(again)
+
+	tasks := freelist.New(func() *freelist.Task { return new(freelist.Task) }, freelist.Options[freelist.Task]{
+		Debug:       true,
+		OnViolation: func(err error) { fmt.Fprintln(w, "double put caught:", errors.Is(err, freelist.ErrDoublePut)) },
+	})
+	t := tasks.Get()
+	t.ID = 1
+	tasks.Put(t)
+	tasks.Put(t)
+	return nil
+}
+
+func runProfiling(_ context.Context, w io.Writer) error {
+	// Duration logs to the standard logger; show it here, without the
+	// timestamp.
+	defer log.SetFlags(log.Flags())
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(w)
+	log.SetFlags(0)
+
+	var n big.Int
+	n.SetInt64(30)
+	fmt.Fprintln(w, "30! =", profile.BigIntFactorial(n))
+	return nil
+}
+
+func runBackpressure(ctx context.Context, w io.Writer) error {
+	samples, err := backpressure.Compare(ctx, backpressure.Config{
+		Rate:     800,
+		Duration: 200 * time.Millisecond,
+		Queue:    20,
+	})
+	if err != nil {
+		return err
+	}
+	return backpressure.WriteCSV(w, samples)
+}
+
+func runPooling(ctx context.Context, w io.Writer) error {
+	const size = 64 << 10
+	for _, b := range []struct {
+		name string
+		bufs pooling.Buffers
+	}{
+		{"alloc", pooling.Alloc{Size: size}},
+		{"freelist", pooling.NewFreeList(size, 4)},
+		{"syncpool", pooling.NewSyncPool(size)},
+	} {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		allocs := testing.AllocsPerRun(100, func() { pooling.Handle(b.bufs, 1) })
+		fmt.Fprintf(w, "%-8s %.0f allocs/request\n", b.name, allocs)
+	}
+	return nil
+}
+
+func runSharedState(ctx context.Context, w io.Writer) error {
+	const n = 20000
+	var results []sharedstate.Result
+	for _, impl := range sharedstate.Impls {
+		for _, g := range []int{1, 8} {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c, release := impl.New()
+			start := time.Now()
+			sharedstate.Hammer(c, g, n)
+			elapsed := time.Since(start)
+			release()
+			results = append(results, sharedstate.Result{Impl: impl.Name, Goroutines: g, NsPerOp: float64(elapsed.Nanoseconds()) / n})
+		}
+	}
+	return sharedstate.WriteTable(w, results)
+}
+
+func runPoolBench(ctx context.Context, w io.Writer) error {
+	var results []poolbench.Result
+	for _, p := range poolbench.Profiles {
+		for _, d := range poolbench.Designs {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			e := d.New(p.Workers)
+			r := poolbench.Run(e, p, 8*p.Workers)
+			e.Close()
+			r.Design = d.Name
+			results = append(results, r)
+		}
+	}
+	return poolbench.WriteTable(w, results)
+}
@@ -0,0 +1,1104 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/patterns/buildinfo"
+	"github.com/crazybber/go-patterns/patterns/cache"
+	"github.com/crazybber/go-patterns/patterns/cli"
+	"github.com/crazybber/go-patterns/patterns/codec"
+	"github.com/crazybber/go-patterns/patterns/diff"
+	"github.com/crazybber/go-patterns/patterns/eventbus"
+	patternsexec "github.com/crazybber/go-patterns/patterns/exec"
+	"github.com/crazybber/go-patterns/patterns/filelock"
+	"github.com/crazybber/go-patterns/patterns/filequeue"
+	"github.com/crazybber/go-patterns/patterns/handshake"
+	"github.com/crazybber/go-patterns/patterns/httpstream"
+	"github.com/crazybber/go-patterns/patterns/httpworker"
+	"github.com/crazybber/go-patterns/patterns/idgen"
+	"github.com/crazybber/go-patterns/patterns/jobqueue"
+	"github.com/crazybber/go-patterns/patterns/journal"
+	"github.com/crazybber/go-patterns/patterns/migrate"
+	"github.com/crazybber/go-patterns/patterns/mmap"
+	"github.com/crazybber/go-patterns/patterns/offlinequeue"
+	"github.com/crazybber/go-patterns/patterns/paralleldl"
+	"github.com/crazybber/go-patterns/patterns/pollpush"
+	"github.com/crazybber/go-patterns/patterns/reconcile"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/patterns/registry"
+	"github.com/crazybber/go-patterns/patterns/registry/storage"
+	_ "github.com/crazybber/go-patterns/patterns/registry/storage/file"
+	_ "github.com/crazybber/go-patterns/patterns/registry/storage/memory"
+	"github.com/crazybber/go-patterns/patterns/repl"
+	"github.com/crazybber/go-patterns/patterns/revproxy"
+	"github.com/crazybber/go-patterns/patterns/sandbox"
+	"github.com/crazybber/go-patterns/patterns/swr"
+	"github.com/crazybber/go-patterns/patterns/taskcache"
+	"github.com/crazybber/go-patterns/patterns/tuidash"
+	"github.com/crazybber/go-patterns/patterns/watcher"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+	"github.com/crazybber/go-patterns/patterns/workerpool/exporter"
+	"github.com/crazybber/go-patterns/patterns/zerodowntime"
+	"github.com/crazybber/go-patterns/resiliency/ratelimit"
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+func init() {
+	register("patterns/buildinfo", "reports the version of the running binary and which features it enables", runBuildInfo)
+	register("patterns/cache", "evicts the least recently used page, expires old sessions and caches a missing user", runPatternsCache)
+	register("patterns/cli", "parses a subcommand's flags from struct tags and prints the usage of a bad command line", runCLI)
+	register("patterns/codec", "round-trips one event through every registered codec and compares their sizes", runCodec)
+	register("patterns/diff", "diffs two versions of a todo into a patch and applies its inverse", runDiff)
+	register("patterns/eventbus", "replays the last failed deploys to a late subscriber, then the live ones", runReplayBus)
+	register("patterns/exec", "streams a command's output line by line and restarts a crashing one until it gives up", runExec)
+	register("patterns/filelock", "keeps a second holder out of a lock file until the first lets go", runFileLock)
+	register("patterns/filequeue", "reopens a durable queue after a restart and drains it in order", runFileQueue)
+	register("patterns/handshake", "agrees on a protocol version and features over a pipe, and fails on a missing required one", runHandshake)
+	register("patterns/httpstream", "streams the rows of a pipeline as newline-delimited JSON, flushing each", runHTTPStream)
+	register("patterns/httpworker", "turns a request away with 503 while the one worker is busy", runHTTPWorker)
+	register("patterns/idgen", "simulates id schemes on a skewed cluster and counts collisions and disorder", runIDGen)
+	register("patterns/jobqueue", "reclaims the job of a crashed worker when its lease runs out and retries it on another", runJobQueue)
+	register("patterns/journal", "recovers page visits from a snapshot and the journal after a restart", runJournal)
+	register("patterns/migrate", "plans and applies schema migrations and refuses to undo one without a down step", runMigrate)
+	register("patterns/mmap", "views and reads a memory mapped file and checks its bounds", runMmap)
+	register("patterns/offlinequeue", "queues edits while offline and rebases a conflicting one when it reconnects", runOfflineQueue)
+	register("patterns/paralleldl", "downloads a file in ranged parts on a worker pool", runParallelDL)
+	register("patterns/pollpush", "turns polls of a config with etags into a stream of changes", runPollPush)
+	register("patterns/reconcile", "shows local todo edits at once and reconciles them with the server's answers", runReconcile)
+	register("patterns/recovery", "turns a panic into an error and a 500, reporting it to a hook", runRecovery)
+	register("patterns/registry", "opens a storage driver by the name it registered under at init", runRegistry)
+	register("patterns/repl", "reads commands from a script, with help and completion", runREPL)
+	register("patterns/revproxy", "rewrites headers, sends a share of requests to a canary and retries a 503", runRevProxy)
+	register("patterns/sandbox", "runs a plugin on a read-only store and stops one that overruns its budget", runSandbox)
+	register("patterns/swr", "serves a stale price while it is refreshed in the background", runSWR)
+	register("patterns/taskcache", "renders a thumbnail asked for five times at once only once, and again when it expires", runTaskCache)
+	register("patterns/tuidash", "records dashboard frames of a pipeline stage, a pool and a rate limiter", runTUIDash)
+	register("patterns/watcher", "polls a tree and batches an editor's quick saves into one rebuild", runWatcher)
+	register("patterns/workerpool/exporter", "scrapes the stats of a worker pool from a Prometheus-style /metrics endpoint", runExporter)
+	register("patterns/zerodowntime", "serves a request on a listener it could hand over, then drains", runZeroDowntime)
+}
+
+func runPatternsCache(ctx context.Context, w io.Writer) error {
+	recent := cache.NewLRU(2, func(page string, _ int) { fmt.Fprintln(w, "evict", page) })
+	recent.Put("/", 1)
+	recent.Put("/docs", 2)
+	recent.Get("/")
+	recent.Put("/blog", 3)
+	fmt.Fprintln(w, "recent pages:", recent.Keys())
+
+	clk := clock.NewFake(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	sessions := cache.NewTTL[string, string](time.Minute)
+	sessions.SetClock(clk)
+	sessions.Put("s1", "ann")
+	sessions.PutTTL("s2", "bob", time.Hour)
+	clk.Advance(2 * time.Minute)
+	_, ok := sessions.Get("s1")
+	fmt.Fprintln(w, "s1 live:", ok, "expired and freed:", sessions.Expire(), "left:", sessions.Len())
+
+	loads := 0
+	users := cache.New(func(_ context.Context, id string) (string, error) {
+		loads++
+		return "", fmt.Errorf("user %s: %w", id, cache.ErrNotFound)
+	}, cache.Policy{TTL: time.Minute, NegativeTTL: 10 * time.Second})
+	users.SetClock(clk)
+	for range 3 {
+		_, err := users.Get(ctx, "7")
+		fmt.Fprintln(w, err)
+	}
+	fmt.Fprintf(w, "loads: %d, stats: %+v\n", loads, users.Stats())
+	return nil
+}
+
+func runCodec(_ context.Context, w io.Writer) error {
+	type event struct {
+		Kind string
+		At   int64
+	}
+	for _, name := range codec.Names() {
+		c, err := codec.Get(name)
+		if err != nil {
+			return err
+		}
+		data, err := codec.Marshal(c, event{"login", 1700000000})
+		if err != nil {
+			return err
+		}
+		var e event
+		if err := codec.Unmarshal(c, data, &e); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%-6s %-24s %3d bytes %+v\n", name, c.ContentType(), len(data), e)
+	}
+	return nil
+}
+
+func runReplayBus(_ context.Context, w io.Writer) error {
+	type deploy struct {
+		Service string
+		OK      bool
+	}
+	b := eventbus.New[deploy](eventbus.Options{History: 4})
+	for _, svc := range []string{"api", "web", "worker", "api"} {
+		b.Publish(deploy{svc, svc != "web"})
+	}
+	b.Publish(deploy{"web", false})
+
+	var mu sync.Mutex
+	var seen []string
+	b.Subscribe(func(ev eventbus.Event[deploy]) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, fmt.Sprintf("#%d %s", ev.Seq, ev.Value.Service))
+	}, eventbus.SubscribeOptions[deploy]{
+		Replay: 2,
+		Filter: func(d deploy) bool { return !d.OK },
+	})
+	b.Publish(deploy{"worker", false})
+	b.Publish(deploy{"api", true})
+	// Close waits for the subscriber to handle what it was sent.
+	b.Close()
+	fmt.Fprintln(w, "failed deploys:", seen)
+	return nil
+}
+
+func runHTTPWorker(ctx context.Context, w io.Writer) error {
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	h := httpworker.New(pool, func(ctx context.Context, r *http.Request) ([]byte, error) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return []byte("rendered " + r.URL.Path + "\n"), nil
+	})
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx))
+		return rec
+	}
+
+	slow := make(chan *httptest.ResponseRecorder, 1)
+	go func() { slow <- serve("/slow") }()
+	select {
+	case <-started:
+	case rec := <-slow:
+		// Only when ctx is done before the task starts.
+		fmt.Fprintln(w, rec.Code)
+		return ctx.Err()
+	}
+	rec := serve("/fast")
+	fmt.Fprintln(w, "while busy:", rec.Code, "Retry-After", rec.Header().Get("Retry-After"))
+	close(release)
+	rec = <-slow
+	fmt.Fprint(w, rec.Code, " ", rec.Body)
+	rec = serve("/fast")
+	fmt.Fprint(w, "once free: ", rec.Code, " ", rec.Body)
+	return nil
+}
+
+func runIDGen(_ context.Context, w io.Writer) error {
+	cfg := idgen.DefaultSimConfig()
+	cfg.Duration = 200 * time.Millisecond
+	cfg.BurstSize = 500
+	return idgen.WriteReport(w, idgen.Simulate(cfg))
+}
+
+func runJobQueue(_ context.Context, w io.Writer) error {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	q := jobqueue.New(jobqueue.Options{Clock: clk})
+	id := q.Enqueue("email", []byte("welcome"))
+
+	j, _ := q.Claim("worker-1", 30*time.Second)
+	fmt.Fprintln(w, j.Worker, "claimed", j.Kind, "attempt", j.Attempt, "and crashed")
+	clk.Advance(31 * time.Second)
+	fmt.Fprintln(w, "reclaimed:", q.Reclaim())
+	j, _ = q.Get(id)
+	fmt.Fprintln(w, j.State, j.Err)
+
+	clk.Advance(time.Second)
+	j, ok := q.Claim("worker-2", 30*time.Second)
+	if !ok {
+		return fmt.Errorf("job %d was not due for its retry", id)
+	}
+	if err := q.Complete(j); err != nil {
+		return err
+	}
+	j, _ = q.Get(id)
+	fmt.Fprintln(w, "worker-2 ran attempt", j.Attempt, "and the job is", j.State)
+	return nil
+}
+
+func runRegistry(_ context.Context, w io.Writer) error {
+	fmt.Fprintln(w, "storage drivers:", storage.Drivers())
+	s, err := storage.Open("memory", "")
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.Put("greeting", []byte("hello")); err != nil {
+		return err
+	}
+	v, err := s.Get("greeting")
+	fmt.Fprintln(w, string(v), err)
+
+	_, err = storage.Open("s3", "bucket")
+	fmt.Fprintln(w, err)
+	codecs := registry.New[string]("codec")
+	codecs.Register("json", "application/json")
+	fmt.Fprintln(w, codecs.Add("json", "text/json"))
+	return nil
+}
+
+func runSWR(ctx context.Context, w io.Writer) error {
+	var mu sync.Mutex
+	clk := clock.NewFake(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	price := 100
+	c := swr.New(func(context.Context, string) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		price++
+		return price, nil
+	}, swr.Options{
+		TTL:                  time.Minute,
+		StaleWhileRevalidate: time.Hour,
+		Now:                  clk.Now,
+	})
+	defer c.Wait()
+
+	get := func(when string) error {
+		v, err := c.Get(ctx, "widget")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, when, v)
+		return nil
+	}
+	if err := get("first read loads:"); err != nil {
+		return err
+	}
+	clk.Advance(2 * time.Minute)
+	if err := get("stale read:"); err != nil {
+		return err
+	}
+	c.Wait()
+	return get("after the refresh:")
+}
+
+// thumbnail is a taskcache.Task for runTaskCache that waits for gate
+// before it renders, so that the callers asking for it overlap.
+type thumbnail struct {
+	path string
+	gate <-chan struct{}
+}
+
+func (t thumbnail) Key() string { return t.path }
+
+func (t thumbnail) Run(ctx context.Context) (string, error) {
+	select {
+	case <-t.gate:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	return t.path + " at 128px", nil
+}
+
+func runTaskCache(ctx context.Context, w io.Writer) error {
+	pool := workerpool.New(2)
+	defer pool.Shutdown()
+	clk := clock.NewFake(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	c := taskcache.New[string](pool, taskcache.Options{TTL: time.Minute, Clock: clk})
+
+	gate := make(chan struct{})
+	results := make([]<-chan taskcache.Result[string], 5)
+	for i := range results {
+		results[i] = c.Go(ctx, thumbnail{"cat.png", gate})
+	}
+	close(gate)
+	for _, ch := range results {
+		if r := <-ch; r.Err != nil {
+			return r.Err
+		}
+	}
+	fmt.Fprintf(w, "five at once: %+v\n", c.Stats())
+
+	v, err := c.Do(ctx, thumbnail{"cat.png", gate})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s from the cache: %+v\n", v, c.Stats())
+	clk.Advance(2 * time.Minute)
+	if _, err := c.Do(ctx, thumbnail{"cat.png", gate}); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "after the TTL: %+v\n", c.Stats())
+	return nil
+}
+
+func runExporter(ctx context.Context, w io.Writer) error {
+	pool := workerpool.New(2)
+	defer pool.Shutdown()
+	for i := range 3 {
+		pool.Run(ctx, workerpool.WorkerFunc(func(context.Context) error {
+			if i == 2 {
+				return errors.New("thumbnail too large")
+			}
+			return nil
+		}))
+	}
+
+	var e exporter.Exporter
+	e.Add("thumbnails", pool)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", &e)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	fmt.Fprintln(w, rec.Code, rec.Header().Get("Content-Type"))
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if strings.HasPrefix(line, "workerpool_tasks_") {
+			fmt.Fprintln(w, line)
+		}
+	}
+	return nil
+}
+
+func runBuildInfo(_ context.Context, w io.Writer) error {
+	v := buildinfo.Get()
+	fmt.Fprintln(w, "version:", v.Version, "go:", v.GoVersion != "")
+	tracing := buildinfo.Feature{Name: "tracing", Since: "v1.2.0"}
+	fmt.Fprintf(w, "%s since %s enabled: %v\n", tracing.Name, tracing.Since, v.Enabled(tracing))
+	for _, built := range []string{"v1.1.9", "v1.2.0", "v2.0.0-rc.1"} {
+		fmt.Fprintf(w, "%s at least v1.2.0: %v\n", built, buildinfo.Version{Version: built}.AtLeast("v1.2.0"))
+	}
+	rec := httptest.NewRecorder()
+	buildinfo.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	fmt.Fprintln(w, "GET /version:", rec.Code, rec.Header().Get("Content-Type"))
+	return nil
+}
+
+func runCLI(ctx context.Context, w io.Writer) error {
+	type greetFlags struct {
+		Name  string `flag:"name" usage:"who to greet"`
+		Times int    `flag:"times" usage:"how often"`
+	}
+	flags := &greetFlags{Name: "world", Times: 1}
+	root := &cli.Command{
+		Name: "hello",
+		Commands: []*cli.Command{{
+			Name:  "greet",
+			Usage: "says hello",
+			Flags: flags,
+			Run: func(ctx context.Context, args []string) error {
+				for range flags.Times {
+					fmt.Fprintln(w, "hello,", flags.Name)
+				}
+				return nil
+			},
+		}},
+	}
+	var usage *cli.UsageError
+	if err := root.Execute(ctx, []string{"greet", "-loud"}); errors.As(err, &usage) {
+		fmt.Fprintln(w, "error:", usage.Err)
+		fmt.Fprint(w, usage.Usage)
+	}
+	return root.Execute(ctx, []string{"greet", "-name", "gopher", "-times", "2"})
+}
+
+type todoItem struct {
+	Title string   `json:"title"`
+	Done  bool     `json:"done"`
+	Tags  []string `json:"tags"`
+}
+
+func runDiff(_ context.Context, w io.Writer) error {
+	before := todoItem{Title: "milk", Tags: []string{"shop"}}
+	after := todoItem{Title: "oat milk", Done: true, Tags: []string{"shop", "today"}}
+	p, err := diff.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, p)
+	undone, err := diff.Apply(after, p.Invert())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "undone: %+v\n", undone)
+	_, err = diff.Apply(before, p.Invert())
+	fmt.Fprintln(w, "undo on the old value:", err)
+	return nil
+}
+
+func runExec(ctx context.Context, w io.Writer) error {
+	var mu sync.Mutex
+	printer := func(stream string) func(string) {
+		return func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(w, "%s: %s\n", stream, line)
+		}
+	}
+	cmd := patternsexec.Command("sh", "-c", "echo converting; echo bad frame >&2; exit 3")
+	cmd.Stdout, cmd.Stderr = printer("out"), printer("err")
+	err := cmd.Run(ctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintln(w, "run:", err)
+
+	crashing := patternsexec.Command("sh", "-c", "exit 1")
+	err = patternsexec.Supervise(ctx, crashing, patternsexec.Restart{
+		Backoff:     retry.Constant(time.Millisecond),
+		MaxRestarts: 2,
+		OnExit:      func(err error) { fmt.Fprintln(w, "exited:", err) },
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintln(w, "supervise:", err)
+	return nil
+}
+
+func runFileLock(ctx context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "filelock")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deploy.lock")
+
+	first, second := filelock.New(path), filelock.New(path)
+	if err := first.TryLock(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "second try:", second.TryLock())
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	fmt.Fprintln(w, "second wait:", second.Lock(waitCtx))
+	if err := first.Unlock(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "after unlock:", second.TryLock())
+	return second.Unlock()
+}
+
+func runFileQueue(_ context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "filequeue")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "uploads")
+
+	q, err := filequeue.Open(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		if err := q.Push([]byte(name)); err != nil {
+			return err
+		}
+	}
+	if err := q.Close(); err != nil {
+		return err
+	}
+
+	// After a restart the records are still queued.
+	if q, err = filequeue.Open(path); err != nil {
+		return err
+	}
+	defer q.Close()
+	fmt.Fprintln(w, "reopened with", q.Len(), "records")
+	for {
+		rec, err := q.Peek()
+		if errors.Is(err, filequeue.ErrEmpty) {
+			fmt.Fprintln(w, "empty")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "upload", string(rec))
+		if err := q.Pop(); err != nil {
+			return err
+		}
+	}
+}
+
+func runHandshake(ctx context.Context, w io.Writer) error {
+	client := handshake.Hello{Name: "client", MinVersion: 1, MaxVersion: 3, Features: []string{"gzip", "stream"}}
+	server := handshake.Hello{Name: "server", MinVersion: 1, MaxVersion: 2, Features: []string{"gzip"}}
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	done := make(chan error, 1)
+	go func() {
+		_, err := handshake.Handshake(ctx, b, server)
+		done <- err
+	}()
+	ag, err := handshake.Handshake(ctx, a, client)
+	if serverErr := <-done; err == nil {
+		err = serverErr
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintln(w, "over the wire:", ag.Version, ag.Features, "stream:", ag.Has("stream"), err)
+
+	server.Require = []string{"auth"}
+	_, err = handshake.Negotiate(client, server)
+	fmt.Fprintln(w, err)
+	return nil
+}
+
+func runHTTPStream(ctx context.Context, w io.Writer) error {
+	h := httpstream.New(func(done <-chan struct{}, r *http.Request) <-chan []byte {
+		out := make(chan []byte)
+		go func() {
+			defer close(out)
+			for i := range 3 {
+				select {
+				case out <- []byte(fmt.Sprintf(`{"row":%d}`+"\n", i)):
+				case <-done:
+					return
+				}
+			}
+		}()
+		return out
+	})
+	h.ContentType = "application/x-ndjson"
+	h.OnFinish = func(n int, err error) { fmt.Fprintln(w, "streamed", n, "rows:", err) }
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export", nil).WithContext(ctx))
+	fmt.Fprintln(w, rec.Header().Get("Content-Type"), "flushed:", rec.Flushed)
+	fmt.Fprint(w, rec.Body)
+	return nil
+}
+
+type visits map[string]int
+
+func runJournal(_ context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "journal")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	count := func(v *visits, page string) error {
+		(*v)[page]++
+		return nil
+	}
+
+	j, err := journal.Open(dir, visits{}, count, journal.Options{SnapshotEvery: 2})
+	if err != nil {
+		return err
+	}
+	for _, page := range []string{"/", "/docs", "/"} {
+		if err := j.Do(page); err != nil {
+			return err
+		}
+	}
+	if err := j.Close(); err != nil {
+		return err
+	}
+
+	// After a restart the snapshot and the log after it restore the state.
+	j, err = journal.Open(dir, visits{}, count, journal.Options{SnapshotEvery: 2})
+	if err != nil {
+		return err
+	}
+	defer j.Close()
+	j.Read(func(v *visits) { fmt.Fprintln(w, "visits after restart:", *v) })
+	return nil
+}
+
+func runMigrate(ctx context.Context, w io.Writer) error {
+	schema := map[string][]string{}
+	var reg migrate.Registry[map[string][]string]
+	reg.MustRegister(migrate.Migration[map[string][]string]{
+		Version: 1, Name: "create orders", Source: "CREATE TABLE orders (id)",
+		Up: func(_ context.Context, s map[string][]string) error {
+			s["orders"] = []string{"id"}
+			return nil
+		},
+		Down: func(_ context.Context, s map[string][]string) error {
+			delete(s, "orders")
+			return nil
+		},
+	})
+	reg.MustRegister(migrate.Migration[map[string][]string]{
+		Version: 2, Name: "add total", Source: "ALTER TABLE orders ADD total",
+		Up: func(_ context.Context, s map[string][]string) error {
+			s["orders"] = append(s["orders"], "total")
+			return nil
+		},
+	})
+
+	r := migrate.NewRunner(&reg, migrate.NewMemStore(), schema, migrate.Options{})
+	plan, err := r.Plan(ctx, migrate.Latest)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "plan:", plan)
+	if _, err := r.Migrate(ctx, migrate.Latest); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, schema)
+	_, err = r.Migrate(ctx, 1)
+	fmt.Fprintln(w, err)
+	return nil
+}
+
+func runMmap(_ context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "mmap")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "index")
+	if err := os.WriteFile(path, []byte("apple\nbanana\ncherry\n"), 0o644); err != nil {
+		return err
+	}
+
+	f, err := mmap.Open(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "mapped", f.Len(), "bytes")
+	err = f.View(6, 6, func(b []byte) error {
+		fmt.Fprintf(w, "view at 6: %s\n", b)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 6)
+	if _, err := f.ReadAt(buf, 13); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "read at 13: %s\n", buf)
+	fmt.Fprintln(w, "view past the end:", f.View(18, 10, func([]byte) error { return nil }))
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "after close:", f.View(0, 1, func([]byte) error { return nil }))
+	return nil
+}
+
+// flakyServer applies operations on versioned keys while it is reachable.
+type flakyServer struct {
+	online  bool
+	applied map[string]bool
+	values  map[string]string
+	version map[string]int
+}
+
+func (s *flakyServer) Apply(_ context.Context, op offlinequeue.Op) error {
+	if !s.online {
+		return fmt.Errorf("dial: %w", offlinequeue.ErrOffline)
+	}
+	if s.applied[op.ID] {
+		return nil
+	}
+	if v := s.version[op.Key]; v != op.BaseVersion {
+		return &offlinequeue.ConflictError{Op: op, CurrentVersion: v, CurrentValue: s.values[op.Key]}
+	}
+	s.applied[op.ID] = true
+	s.values[op.Key] = op.Value
+	s.version[op.Key]++
+	return nil
+}
+
+func runOfflineQueue(ctx context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "offlinequeue")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	server := &flakyServer{applied: map[string]bool{}, values: map[string]string{}, version: map[string]int{}}
+	c, err := offlinequeue.Open(filepath.Join(dir, "outbox"), server, offlinequeue.Rebase)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for i, title := range []string{"draft", "final"} {
+		if err := c.Do(ctx, offlinequeue.Op{ID: fmt.Sprint("op", i), Key: "title", Value: title, BaseVersion: i}); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(w, "offline, pending:", c.Pending())
+
+	// Meanwhile another client changed the title.
+	server.values["title"], server.version["title"] = "theirs", 1
+	server.online = true
+	n, err := c.Flush(ctx)
+	fmt.Fprintln(w, "flushed", n, err, "pending:", c.Pending())
+	fmt.Fprintf(w, "server: %q at version %d\n", server.values["title"], server.version["title"])
+	return nil
+}
+
+// rangeServer answers requests with an http.Handler in memory.
+type rangeServer struct{ h http.Handler }
+
+func (s rangeServer) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	s.h.ServeHTTP(rec, r)
+	return rec.Result(), nil
+}
+
+func runParallelDL(ctx context.Context, w io.Writer) error {
+	content := strings.Repeat("0123456789", 10)
+	var mu sync.Mutex
+	var ranges []string
+	files := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if rng := r.Header.Get("Range"); rng != "" {
+			mu.Lock()
+			ranges = append(ranges, rng)
+			mu.Unlock()
+		}
+		http.ServeContent(rw, r, "data.bin", time.Time{}, strings.NewReader(content))
+	})
+
+	pool := workerpool.New(3)
+	defer pool.Shutdown()
+	d := paralleldl.New(pool)
+	d.Client = &http.Client{Transport: rangeServer{files}}
+	d.PartSize = 30
+
+	var out bytes.Buffer
+	n, err := d.Download(ctx, "http://files.example/data.bin", &out, 0)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	sort.Strings(ranges)
+	fmt.Fprintln(w, "downloaded", n, "bytes, intact:", out.String() == content)
+	fmt.Fprintln(w, "ranges:", ranges)
+	return nil
+}
+
+func runPollPush(ctx context.Context, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	version := 0
+	config := func(ctx context.Context, etag string) (pollpush.Result[string], error) {
+		version++
+		if version > 2 {
+			// The config stopped changing.
+			return pollpush.Result[string]{NotModified: true}, nil
+		}
+		return pollpush.Result[string]{Value: fmt.Sprintf("config v%d", version), Token: fmt.Sprint(version)}, nil
+	}
+	updates := pollpush.Watch(ctx, config, pollpush.Options[string]{Min: time.Millisecond, Max: 10 * time.Millisecond})
+	for range 2 {
+		select {
+		case v := <-updates:
+			fmt.Fprintln(w, "pushed:", v)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	cancel()
+	for range updates {
+	}
+	return nil
+}
+
+func todoTitles(l reconcile.List) []string {
+	var out []string
+	for _, t := range l {
+		mark := " "
+		if t.Done {
+			mark = "x"
+		}
+		out = append(out, mark+t.Title)
+	}
+	return out
+}
+
+func runReconcile(_ context.Context, w io.Writer) error {
+	s := reconcile.New(reconcile.List{{ID: "t1", Title: "milk"}})
+	defer s.Close()
+	s.Subscribe(func(c reconcile.Change[reconcile.List]) {
+		fmt.Fprintln(w, c.Cause, c.ID, todoTitles(c.View), c.RolledBack)
+	})
+
+	if err := s.Do(reconcile.SetDone("op1", "t1", true)); err != nil {
+		return err
+	}
+	if err := s.Do(reconcile.AddTodo("op2", "t2", "bread")); err != nil {
+		return err
+	}
+	// The server accepts the toggle, then another client deletes milk.
+	if err := s.Confirm("op1", reconcile.List{{ID: "t1", Title: "milk", Done: true}}); err != nil {
+		return err
+	}
+	s.Sync(reconcile.List{})
+	return s.Reject("op2")
+}
+
+func runRecovery(_ context.Context, w io.Writer) error {
+	b := recovery.New()
+	b.OnPanic(func(p *recovery.PanicError) { fmt.Fprintln(w, "hook:", p.Value) })
+
+	err := b.Do(func() error {
+		var m map[string]int
+		m["x"] = 1
+		return nil
+	})
+	fmt.Fprintln(w, err)
+
+	h := b.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("template missing")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report", nil))
+	fmt.Fprintln(w, "GET /report:", rec.Code)
+	return nil
+}
+
+func runREPL(ctx context.Context, w io.Writer) error {
+	authors := map[string]string{"1": "Björk", "2": "Deftones"}
+	r := repl.New(strings.NewReader("get author 2\nget author 9\nhelp\n"), w)
+	r.Prompt = "albums> "
+	r.Register(repl.Command{
+		Name: "get",
+		Help: "get author ID prints an author",
+		Run: func(_ context.Context, w io.Writer, args []string) error {
+			if len(args) != 2 || args[0] != "author" {
+				return errors.New("usage: get author ID")
+			}
+			name, ok := authors[args[1]]
+			if !ok {
+				return fmt.Errorf("no author %s", args[1])
+			}
+			fmt.Fprintln(w, name)
+			return nil
+		},
+		Complete: repl.Prefixed("author", "album"),
+	})
+	if err := r.Run(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "completions of \"get al\":", r.Complete("get al"))
+	return nil
+}
+
+// backends serves the requests of a proxy in memory, by host.
+type backends map[string]http.Handler
+
+func (b backends) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	b[r.URL.Host].ServeHTTP(rec, r)
+	return rec.Result(), nil
+}
+
+func runRevProxy(_ context.Context, w io.Writer) error {
+	stable, canary := &url.URL{Scheme: "http", Host: "stable"}, &url.URL{Scheme: "http", Host: "canary"}
+	overloaded, calls := 1, map[string]int{}
+	echo := func(name string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			calls[name]++
+			if name == "stable" && overloaded > 0 {
+				overloaded--
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprintf(rw, "%s %s env=%s cookie=%q", name, r.URL.Path, r.Header.Get("X-Env"), r.Header.Get("Cookie"))
+		})
+	}
+
+	rolls := []int{90, 10}
+	proxy := revproxy.New(stable,
+		revproxy.SetHeaders(map[string]string{"X-Env": "prod"}, "Cookie"),
+		revproxy.Canary(canary, 20, func() int {
+			r := rolls[0]
+			rolls = rolls[1:]
+			return r
+		}),
+	)
+	retrying := revproxy.NewRetry(backends{"stable": echo("stable"), "canary": echo("canary")}, 3)
+	retrying.Backoff = time.Millisecond
+	proxy.Transport = retrying
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set("Cookie", "session=1")
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		fmt.Fprintln(w, rec.Code, rec.Body)
+	}
+	fmt.Fprintln(w, "backend calls:", calls)
+	return nil
+}
+
+func runSandbox(ctx context.Context, w io.Writer) error {
+	store := sandbox.NewStore()
+	store.Set("orders/1", []byte("12.50"))
+	store.Set("orders/2", []byte("7.25"))
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	h := sandbox.NewHost(store, sandbox.Options{
+		Budget: 50 * time.Millisecond,
+		Clock:  clock.NewFake(now),
+		Log:    func(plugin, line string) { fmt.Fprintf(w, "[%s] %s\n", plugin, line) },
+	})
+	defer h.Close()
+
+	report := sandbox.PluginFunc(func(ctx context.Context, c sandbox.Caps) (any, error) {
+		var total float64
+		for _, k := range c.Store.Keys() {
+			v, _ := c.Store.Get(k)
+			var amount float64
+			fmt.Sscan(string(v), &amount)
+			total += amount
+		}
+		c.Log.Printf("summed %d orders", len(c.Store.Keys()))
+		return fmt.Sprintf("%s total %.2f", c.Clock.Now().Format(time.DateOnly), total), nil
+	})
+	out, err := h.Run(ctx, "report", report)
+	fmt.Fprintln(w, out, err)
+
+	spin := sandbox.PluginFunc(func(ctx context.Context, _ sandbox.Caps) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	_, err = h.Run(ctx, "spin", spin)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintln(w, err)
+	return nil
+}
+
+func runTUIDash(_ context.Context, w io.Writer) error {
+	pool := workerpool.New(4)
+	defer pool.Shutdown()
+	stage := make(chan int, 10)
+	for i := range 7 {
+		stage <- i
+	}
+	bucket := ratelimit.NewTokenBucket(5, 10)
+	bucket.SetClock(clock.NewFake(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)))
+	for range 6 {
+		bucket.Allow()
+	}
+
+	d := tuidash.NewHeadless("ingest")
+	d.Width = 10
+	d.Add(tuidash.Section{Title: "pipeline", Gauges: []tuidash.Gauge{
+		tuidash.ChannelGauge("parse", stage),
+		tuidash.PoolGauge("workers", pool),
+	}})
+	d.Add(tuidash.Section{Title: "limits", Gauges: []tuidash.Gauge{
+		tuidash.TokenGauge("api", bucket),
+	}})
+	if err := d.Draw(); err != nil {
+		return err
+	}
+	<-stage
+	<-stage
+	if err := d.Draw(); err != nil {
+		return err
+	}
+	for _, f := range d.Frames() {
+		fmt.Fprintln(w, f)
+	}
+	return nil
+}
+
+func runWatcher(_ context.Context, w io.Writer) error {
+	epoch := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	file := func(data string, mod time.Duration) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(data), ModTime: epoch.Add(mod)}
+	}
+	fsys := fstest.MapFS{
+		"main.go":       file("package main", 0),
+		"tmpl/a.html":   file("<a>", 0),
+		"static/ok.css": file("a{}", 0),
+	}
+	c := clock.NewFake(epoch)
+	watch, err := watcher.New(fsys, ".", func(batch []watcher.Event) {
+		fmt.Fprintln(w, "rebuild for", batch)
+	}, watcher.Options{Debounce: 100 * time.Millisecond, Clock: c})
+	if err != nil {
+		return err
+	}
+
+	// An editor saves twice in quick succession; one batch follows.
+	fsys["main.go"] = file("package main // edited", time.Second)
+	if _, err := watch.Poll(); err != nil {
+		return err
+	}
+	c.Advance(50 * time.Millisecond)
+	fsys["tmpl/b.html"] = file("<b>", 2*time.Second)
+	delete(fsys, "static/ok.css")
+	n, err := watch.Poll()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "second poll found", n, "changes")
+	c.Advance(100 * time.Millisecond)
+	return nil
+}
+
+func runZeroDowntime(ctx context.Context, w io.Writer) error {
+	ln, err := zerodowntime.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(rw, "hello")
+	})}
+	serveCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	served := make(chan error, 1)
+	go func() {
+		served <- zerodowntime.Serve(serveCtx, srv, ln, nil, zerodowntime.Options{DrainTimeout: time.Second})
+	}()
+
+	transport := &http.Transport{}
+	defer transport.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+ln.Addr().String()+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err == nil {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		fmt.Fprint(w, resp.Status, " ", string(body))
+	}
+	transport.CloseIdleConnections()
+	stop()
+	fmt.Fprintln(w, "drained:", <-served)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
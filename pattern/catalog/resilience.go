@@ -0,0 +1,189 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/resiliency/circuitbreaker"
+	"github.com/crazybber/go-patterns/resiliency/fallback"
+	"github.com/crazybber/go-patterns/resiliency/hedging"
+	"github.com/crazybber/go-patterns/resiliency/loadshed"
+	"github.com/crazybber/go-patterns/resiliency/ratelimit"
+	"github.com/crazybber/go-patterns/resiliency/retry"
+	"github.com/crazybber/go-patterns/stability/deadline"
+)
+
+func init() {
+	register("resiliency/circuitbreaker", "opens a circuit after failures and closes it once calls succeed again", runBreaker)
+	register("resiliency/fallback", "serves a cached quote when the remote one times out", runFallback)
+	register("resiliency/hedging", "asks a second replica when the first is slow and takes whichever answers first", runHedging)
+	register("resiliency/loadshed", "admits two requests at a time and sheds the rest until one finishes", runLoadShed)
+	register("resiliency/ratelimit", "lets a burst through a token bucket and a sliding window, then refills on a fake clock", runRateLimit)
+	register("resiliency/retry", "retries a flaky call with exponential backoff", runRetry)
+	register("stability/deadline", "gives up on a slow report and tells it to stop through its stopper channel", runDeadline)
+}
+
+var errOverloaded = errors.New("overloaded")
+
+func runBreaker(ctx context.Context, w io.Writer) error {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	b := circuitbreaker.New(circuitbreaker.Settings{FailureThreshold: 2, ResetTimeout: time.Minute})
+	b.SetClock(clk)
+
+	healthy := false
+	call := func(context.Context) error {
+		if !healthy {
+			return errOverloaded
+		}
+		return nil
+	}
+	for i := 0; i < 3; i++ {
+		fmt.Fprintln(w, b.Do(ctx, call), b.State())
+	}
+	healthy = true
+	clk.Advance(2 * time.Minute)
+	fmt.Fprintln(w, b.Do(ctx, call), b.State())
+	return nil
+}
+
+func runRetry(ctx context.Context, w io.Writer) error {
+	attempts := 0
+	err := retry.Do(ctx, retry.Policy{
+		Backoff:     retry.Exponential{Initial: time.Millisecond, Jitter: 0.2},
+		MaxAttempts: 5,
+	}, func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errOverloaded
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintln(w, attempts, "attempts:", err)
+	return err
+}
+
+func runFallback(ctx context.Context, w io.Writer) error {
+	remote := func(ctx context.Context) (string, error) {
+		// The remote never answers in time.
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	cached := func(context.Context) (string, error) { return "cached quote", nil }
+	get := fallback.WithFallback(fallback.WithTimeout(remote, 5*time.Millisecond), cached)
+	quote, err := get(ctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintln(w, quote, err)
+	return nil
+}
+
+func runHedging(ctx context.Context, w io.Writer) error {
+	replicas := []time.Duration{time.Second, time.Millisecond}
+	var next int
+	var mu sync.Mutex
+	v, err := hedging.Do(ctx, hedging.Policy{Delay: 10 * time.Millisecond}, func(ctx context.Context) (string, error) {
+		mu.Lock()
+		i := next
+		next++
+		mu.Unlock()
+		select {
+		case <-time.After(replicas[i]):
+			return fmt.Sprintf("replica %d", i), nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintln(w, v, err)
+	return nil
+}
+
+func runLoadShed(_ context.Context, w io.Writer) error {
+	c := loadshed.New(loadshed.Options{MaxInFlight: 2})
+	var running []func()
+	for i := range 4 {
+		done, err := c.Admit()
+		if err != nil {
+			fmt.Fprintf(w, "request %d: %v\n", i, err)
+			continue
+		}
+		fmt.Fprintf(w, "request %d: admitted\n", i)
+		running = append(running, done)
+	}
+	running[0]()
+	if done, err := c.Admit(); err == nil {
+		fmt.Fprintln(w, "request 4: admitted once request 0 finished")
+		running[0] = done
+	}
+	for _, done := range running {
+		done()
+	}
+	s := c.Stats()
+	fmt.Fprintf(w, "admitted %d, shed %d\n", s.Admitted, s.Shed)
+	return nil
+}
+
+func runRateLimit(_ context.Context, w io.Writer) error {
+	limiters := []struct {
+		name string
+		l    interface {
+			ratelimit.Limiter
+			SetClock(clock.Clock)
+		}
+	}{
+		{"token bucket, 2/s with bursts of 5", ratelimit.NewTokenBucket(2, 5)},
+		{"sliding window, 5 per second", ratelimit.NewSlidingWindow(5, time.Second)},
+	}
+	for _, lim := range limiters {
+		c := clock.NewFake(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+		lim.l.SetClock(c)
+		allowed := func() (n int) {
+			for range 10 {
+				if lim.l.Allow() {
+					n++
+				}
+			}
+			return n
+		}
+		burst := allowed()
+		c.Advance(time.Second)
+		fmt.Fprintf(w, "%s: %d of a burst of 10, %d of the next a second later\n", lim.name, burst, allowed())
+	}
+	return nil
+}
+
+func runDeadline(ctx context.Context, w io.Writer) error {
+	stopped := make(chan struct{})
+	report := func(stopper <-chan struct{}) error {
+		defer close(stopped)
+		select {
+		case <-time.After(time.Second):
+			return nil
+		case <-stopper:
+			return errors.New("report abandoned")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	quick := func(<-chan struct{}) error { return nil }
+
+	fmt.Fprintln(w, "quick:", deadline.New(10*time.Millisecond).Run(quick))
+	err := deadline.New(10 * time.Millisecond).Run(report)
+	<-stopped
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Fprintln(w, "report:", err)
+	return nil
+}
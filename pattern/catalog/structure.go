@@ -0,0 +1,223 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/creational/builder"
+	"github.com/crazybber/go-patterns/creational/factory"
+	"github.com/crazybber/go-patterns/creational/prototype"
+	"github.com/crazybber/go-patterns/creational/resourcepool"
+	"github.com/crazybber/go-patterns/creational/singleton"
+	"github.com/crazybber/go-patterns/idioms/ctxkeys/auth"
+	"github.com/crazybber/go-patterns/structural/adapter"
+	"github.com/crazybber/go-patterns/structural/bridge/notify"
+	"github.com/crazybber/go-patterns/structural/composite/fstree"
+	"github.com/crazybber/go-patterns/structural/decorator/middleware"
+	"github.com/crazybber/go-patterns/structural/facade/store"
+	"github.com/crazybber/go-patterns/structural/flyweight/intern"
+	"github.com/crazybber/go-patterns/structural/proxy/fetcher"
+)
+
+func init() {
+	register("creational/builder", "builds a server config step by step and reports every invalid field", runBuilder)
+	register("creational/factory", "picks a payment provider by the name in its config", runFactory)
+	register("creational/prototype", "stamps out documents by cloning registered prototypes", runPrototype)
+	register("creational/resourcepool", "shares three connections among four goroutines", runCreationalPool)
+	register("creational/singleton", "loads a config once however often it is asked for", runSingleton)
+	register("structural/adapter", "logs through slog into a legacy logger via an adapter", runAdapter)
+	register("structural/bridge/notify", "sends one alert down an SMS and an email channel", runNotify)
+	register("structural/composite/fstree", "sizes a directory tree, files and directories alike", runFSTree)
+	register("structural/decorator/middleware", "wraps a handler in logging and bearer auth", runMiddleware)
+	register("structural/facade/store", "buys through one service in front of stock, billing and mail", runStore)
+	register("structural/flyweight/intern", "shares one copy of equal values", runIntern)
+	register("structural/proxy/fetcher", "fetches a report once through a caching proxy", runFetcher)
+}
+
+func runBuilder(_ context.Context, w io.Writer) error {
+	cfg, err := builder.NewServerBuilder("0.0.0.0", 8443).
+		TLS("cert.pem", "key.pem").
+		Timeouts(2*time.Second, 5*time.Second).
+		Build()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, cfg.Addr(), "tls:", cfg.TLS())
+	_, err = builder.NewServerBuilder("", 70000).Build()
+	fmt.Fprintln(w, err)
+	return nil
+}
+
+func runFactory(_ context.Context, w io.Writer) error {
+	for _, cfg := range []factory.PaymentConfig{
+		{Provider: "card", APIKey: "sk_test"},
+		{Provider: "paypal", Account: "shop"},
+	} {
+		p, err := factory.NewProvider(cfg)
+		if err != nil {
+			return err
+		}
+		ref, err := p.Charge(1999, "EUR")
+		fmt.Fprintln(w, p.Name(), ref, err)
+	}
+	return nil
+}
+func runPrototype(_ context.Context, w io.Writer) error {
+	r := prototype.NewRegistry[*prototype.Document]()
+	r.Register("report", &prototype.Document{Title: "Weekly report", Tags: []string{"team"}})
+	doc, err := r.New("report")
+	if err != nil {
+		return err
+	}
+	doc.Tags = append(doc.Tags, "week-42")
+	fresh, err := r.New("report")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, doc.Tags, fresh.Tags)
+	return nil
+}
+
+func runCreationalPool(ctx context.Context, w io.Writer) error {
+	var mu sync.Mutex
+	opened := 0
+	p := resourcepool.New(3, func(context.Context) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		opened++
+		return opened, nil
+	}, nil)
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	queries := make([]int, 4)
+	for i := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				conn, err := p.Acquire(ctx)
+				if err != nil {
+					return
+				}
+				time.Sleep(time.Millisecond) // a query on conn
+				queries[i]++
+				p.Release(conn)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "queries per goroutine:", queries, "connections opened:", opened)
+	return nil
+}
+
+func runSingleton(_ context.Context, w io.Writer) error {
+	config := singleton.NewLazy(func() string {
+		fmt.Fprintln(w, "loading config")
+		return "debug=true"
+	})
+	fmt.Fprintln(w, config.Get())
+	fmt.Fprintln(w, config.Get())
+	return nil
+}
+
+func runAdapter(_ context.Context, w io.Writer) error {
+	log := slog.New(adapter.NewHandler(adapter.NewTextLogger(w), nil)).With("service", "billing")
+	log.Warn("retrying", "attempt", 2)
+	return nil
+}
+
+func runNotify(ctx context.Context, w io.Writer) error {
+	down := notify.Alert{Service: "web", Severity: notify.Warning, Text: "5xx rate 2%"}
+	if err := notify.Send(ctx, notify.SMS{W: w}, down, "+15550100"); err != nil {
+		return err
+	}
+	return notify.Send(ctx, notify.Email{From: "ops@example.com", W: w}, down, "oncall@example.com")
+}
+
+func runFSTree(_ context.Context, w io.Writer) error {
+	root := fstree.NewDir("static",
+		fstree.NewDir("js", fstree.NewFile("app.js", 90), fstree.NewFile("vendor.js", 10)),
+		fstree.NewDir("css", fstree.NewFile("site.css", 5)),
+		fstree.NewFile("index.html", 2),
+	)
+	for path, n := range fstree.All(root) {
+		fmt.Fprintf(w, "%-20s %3d bytes\n", path, n.Size())
+	}
+	return nil
+}
+
+func runMiddleware(_ context.Context, w io.Writer) error {
+	log := slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		// Drop the time and duration so the output reads the same every run.
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	verify := func(token string) (auth.Principal, bool) {
+		return auth.Principal{ID: "ann"}, token == "secret"
+	}
+	h := middleware.Chain(middleware.Logging(log), middleware.Auth(verify))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, _ := auth.Get(r.Context())
+			fmt.Fprintln(w, "hello", p.ID)
+		}))
+	for _, token := range []string{"", "Bearer secret"} {
+		r := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		if token != "" {
+			r.Header.Set("Authorization", token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		fmt.Fprintf(w, "%d %s", rec.Code, rec.Body)
+	}
+	return nil
+}
+
+func runStore(_ context.Context, w io.Writer) error {
+	shop := store.New([]store.Item{{SKU: "flour", Price: 299, Stock: 5}}, map[string]int64{"ann": 1200})
+	for _, qty := range []int{4, 1} {
+		if _, err := shop.Buy("ann", "flour", qty); err != nil {
+			fmt.Fprintln(w, err)
+		}
+	}
+	fmt.Fprintln(w, shop.Messages("ann"), "stock:", shop.Stock("flour"), "balance:", shop.Balance("ann"))
+	return nil
+}
+
+func runIntern(_ context.Context, w io.Writer) error {
+	styles := intern.NewPool[string]()
+	a, b := styles.Intern("bold"), styles.Intern("bold")
+	styles.Intern("italic")
+	fmt.Fprintln(w, a == b, styles.Len())
+	return nil
+}
+
+func runFetcher(ctx context.Context, w io.Writer) error {
+	slow := fetcher.Func(func(_ context.Context, key string) ([]byte, error) {
+		fmt.Fprintln(w, "fetching", key)
+		return []byte(strings.ToUpper(key)), nil
+	})
+	f := fetcher.Caching(slow, time.Hour)
+	for range 2 {
+		data, err := f.Fetch(ctx, "report")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/crazybber/go-patterns/analysis/ctxkey"
+	"github.com/crazybber/go-patterns/testing/factories"
+	"github.com/crazybber/go-patterns/testing/linearize"
+	"github.com/crazybber/go-patterns/testing/simstack"
+	"github.com/crazybber/go-patterns/testing/stress"
+)
+
+func init() {
+	register("analysis/ctxkey", "flags the context values keyed by a built-in type in a small package", runCtxKeyCheck)
+	register("testing/factories", "builds test customers from defaults, traits and overrides", runFactories)
+	register("testing/linearize", "accepts a history of a counter from three goroutines and rejects one with a stale read", runLinearize)
+	register("testing/simstack", "simulates orders through a faulty broker and checks the invariants once it settles", runSimStack)
+	register("testing/stress", "hammers a set whose Remove miscounts and shrinks the failure to one step", runStress)
+}
+
+const ctxKeySource = `package session
+
+import "context"
+
+type userKey struct{}
+
+func WithUser(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, userKey{}, name)
+}
+
+func WithTenant(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, "tenant", id)
+}
+`
+
+func runCtxKeyCheck(ctx context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "ctxkey")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "session.go"), []byte(ctxKeySource), 0o644); err != nil {
+		return err
+	}
+	diags, err := ctxkey.CheckDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, d := range diags {
+		fmt.Fprintf(w, "session.go:%d: %s\n", d.Pos.Line, d.Message)
+	}
+	return nil
+}
+
+type customer struct {
+	Name    string
+	Balance int
+	Admin   bool
+}
+
+func runFactories(ctx context.Context, w io.Writer) error {
+	customers := factories.New(func(n int) customer {
+		return customer{Name: fmt.Sprintf("user%d", n), Balance: 500}
+	})
+	fmt.Fprintln(w, customers.Build())
+	fmt.Fprintln(w, customers.Build(func(c *customer) { c.Balance = 0 }))
+	admins := customers.With(func(c *customer) { c.Admin = true })
+	fmt.Fprintln(w, admins.List(2))
+	customers.Sequence().Reset()
+	fmt.Fprintln(w, customers.Build())
+	return nil
+}
+
+func runLinearize(ctx context.Context, w io.Writer) error {
+	var rec linearize.Recorder[linearize.CounterInput, int64]
+	var mu sync.Mutex
+	var n int64
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec.Do(linearize.CounterInput{Delta: 1}, func() int64 {
+				mu.Lock()
+				defer mu.Unlock()
+				n++
+				return 0
+			})
+			rec.Do(linearize.CounterInput{Read: true}, func() int64 {
+				mu.Lock()
+				defer mu.Unlock()
+				return n
+			})
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintln(w, "locked counter linearizable:", linearize.Check(linearize.Counter, rec.History()))
+
+	// The second add returned before the read was called, yet the read
+	// sees only the first.
+	stale := []linearize.Op[linearize.CounterInput, int64]{
+		{Input: linearize.CounterInput{Delta: 1}, Call: 0, Return: 1},
+		{Input: linearize.CounterInput{Delta: 1}, Call: 2, Return: 3},
+		{Input: linearize.CounterInput{Read: true}, Output: 1, Call: 4, Return: 5},
+	}
+	fmt.Fprintln(w, "stale read linearizable:", linearize.Check(linearize.Counter, stale))
+	return nil
+}
+
+func runSimStack(ctx context.Context, w io.Writer) error {
+	r, err := simstack.Run(7, simstack.Config{
+		Orders: 10,
+		Faults: simstack.Faults{Drop: 0.2, Duplicate: 0.2, Crash: 0.05},
+	})
+	fmt.Fprintf(w, "%d orders completed, %d cancelled; %d deliveries, %d redelivered, %d deduplicated, %d crashes\n",
+		r.Completed, r.Cancelled, r.Deliveries, r.Redeliveries, r.Deduplicated, r.Crashes)
+	fmt.Fprintln(w, "invariants:", err)
+	return nil
+}
+
+// countedSet keeps its size in a counter of its own, which Remove
+// decrements even for a member that is not there.
+type countedSet struct {
+	mu      sync.Mutex
+	members map[int64]bool
+	size    int
+}
+
+func (s *countedSet) Add(v int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.members[v] {
+		s.members[v] = true
+		s.size++
+	}
+}
+
+func (s *countedSet) Remove(v int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.members, v)
+	s.size--
+}
+
+func (s *countedSet) check() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size != len(s.members) {
+		return fmt.Errorf("size %d, %d members", s.size, len(s.members))
+	}
+	return nil
+}
+
+func runStress(ctx context.Context, w io.Writer) error {
+	spec := stress.Spec[*countedSet]{
+		Setup: func() *countedSet { return &countedSet{members: map[int64]bool{}} },
+		Ops: []stress.Op[*countedSet]{
+			{Name: "add", Weight: 3, Do: func(s *countedSet, _ int, arg int64) error {
+				s.Add(arg % 8)
+				return nil
+			}},
+			{Name: "remove", Do: func(s *countedSet, _ int, arg int64) error {
+				s.Remove(arg % 8)
+				return nil
+			}},
+		},
+		Invariants: []stress.Invariant[*countedSet]{{Name: "size", Check: (*countedSet).check}},
+		Replayable: true,
+	}
+	fmt.Fprintln(w, stress.Run(spec, stress.Config{Goroutines: 4, Steps: 200, Seed: 1}))
+	return nil
+}
@@ -0,0 +1,107 @@
+// Package pattern gives the runnable examples of this repository one
+// interface, Runner, and a registry of them, so that a tool can list and
+// run every example and a test can hold them all to the same rules.
+//
+// Runners register themselves from an init function, the way database/sql
+// drivers do; pattern/catalog registers one for each library package of
+// the repository. The examples written as package main cannot be imported
+// and are not in the registry.
+package pattern
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Runner is a runnable example of a pattern.
+type Runner interface {
+	// Name is the path of the pattern's package in the repository, such
+	// as "behavioral/chain".
+	Name() string
+	// Describe returns a one line summary of what the example shows.
+	Describe() string
+	// Run runs the example, writing what it shows to w. It returns once
+	// every goroutine it started is done, and early with ctx.Err() if ctx
+	// is cancelled.
+	Run(ctx context.Context, w io.Writer) error
+}
+
+// Configurable is implemented by Runners that take options. Flags returns
+// a pointer to a struct whose fields, tagged as patterns/cli reads them,
+// hold the options: their values when the Runner is registered are the
+// defaults, and a command line may change them before Run.
+type Configurable interface {
+	Runner
+	Flags() any
+}
+
+// New returns a Runner calling run.
+func New(name, description string, run func(ctx context.Context, w io.Writer) error) Runner {
+	return &funcRunner{name, description, run}
+}
+
+type funcRunner struct {
+	name, description string
+	run               func(ctx context.Context, w io.Writer) error
+}
+
+// NewConfigurable returns a Configurable calling run, which reads its
+// options from flags.
+func NewConfigurable(name, description string, flags any, run func(ctx context.Context, w io.Writer) error) Configurable {
+	return &flagRunner{funcRunner{name, description, run}, flags}
+}
+
+type flagRunner struct {
+	funcRunner
+	flags any
+}
+
+func (r *flagRunner) Flags() any { return r.flags }
+
+func (r *funcRunner) Name() string                               { return r.name }
+func (r *funcRunner) Describe() string                           { return r.description }
+func (r *funcRunner) Run(ctx context.Context, w io.Writer) error { return r.run(ctx, w) }
+
+var (
+	mu      sync.RWMutex
+	runners = map[string]Runner{}
+)
+
+// Register makes r available by its name. It panics if the name is empty,
+// has spaces, or is already registered.
+func Register(r Runner) {
+	name := r.Name()
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		panic(fmt.Sprintf("pattern: bad runner name %q", name))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := runners[name]; dup {
+		panic("pattern: Register called twice for " + name)
+	}
+	runners[name] = r
+}
+
+// Lookup returns the runner registered under name.
+func Lookup(name string) (Runner, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	r, ok := runners[name]
+	return r, ok
+}
+
+// All returns the registered runners sorted by name.
+func All() []Runner {
+	mu.RLock()
+	defer mu.RUnlock()
+	all := make([]Runner, 0, len(runners))
+	for _, r := range runners {
+		all = append(all, r)
+	}
+	slices.SortFunc(all, func(a, b Runner) int { return strings.Compare(a.Name(), b.Name()) })
+	return all
+}
@@ -0,0 +1,94 @@
+package pattern
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+func reset(t *testing.T) {
+	saved := runners
+	runners = map[string]Runner{}
+	t.Cleanup(func() { runners = saved })
+}
+
+func TestRegistry(t *testing.T) {
+	reset(t)
+	for _, name := range []string{"b/two", "a/one", "c"} {
+		Register(New(name, "shows "+name, func(_ context.Context, w io.Writer) error {
+			_, err := fmt.Fprint(w, name)
+			return err
+		}))
+	}
+	var names []string
+	for _, r := range All() {
+		names = append(names, r.Name())
+	}
+	if fmt.Sprint(names) != "[a/one b/two c]" {
+		t.Errorf("All = %v", names)
+	}
+
+	r, ok := Lookup("b/two")
+	var buf bytes.Buffer
+	if !ok || r.Describe() != "shows b/two" || r.Run(context.Background(), &buf) != nil || buf.String() != "b/two" {
+		t.Errorf("Lookup = %v, %v; ran %q", r, ok, buf.String())
+	}
+	if _, ok := Lookup("d"); ok {
+		t.Error("found an unregistered runner")
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	reset(t)
+	Register(New("a", "", nil))
+	for _, name := range []string{"a", "", "a b"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", name)
+				}
+			}()
+			Register(New(name, "", nil))
+		}()
+	}
+}
+
+func TestConfigurable(t *testing.T) {
+	reset(t)
+	opts := &struct {
+		N int `flag:"n"`
+	}{N: 3}
+	Register(NewConfigurable("a/counted", "counts", opts, func(_ context.Context, w io.Writer) error {
+		_, err := fmt.Fprint(w, opts.N)
+		return err
+	}))
+	r, _ := Lookup("a/counted")
+	c, ok := r.(Configurable)
+	if !ok || c.Flags() != opts {
+		t.Fatalf("Lookup = %T", r)
+	}
+	opts.N = 1000 // as a command line would
+	var buf bytes.Buffer
+	if r.Run(context.Background(), &buf); buf.String() != "1000" {
+		t.Errorf("ran %q", buf.String())
+	}
+	if _, ok := New("b", "", nil).(Configurable); ok {
+		t.Error("a Runner without flags is Configurable")
+	}
+}
+
+func Example() {
+	Register(New("idioms/hello", "prints a greeting", func(_ context.Context, w io.Writer) error {
+		_, err := fmt.Fprintln(w, "hello, patterns")
+		return err
+	}))
+	r, _ := Lookup("idioms/hello")
+	fmt.Println(r.Describe())
+	r.Run(context.Background(), os.Stdout)
+	// Output:
+	// prints a greeting
+	// hello, patterns
+}
@@ -0,0 +1,219 @@
+// Package buildinfo lets a binary describe itself. The version can be
+// stamped at link time,
+//
+//	go build -ldflags "-X github.com/crazybber/go-patterns/patterns/buildinfo.version=v1.4.0"
+//
+// and everything else, the commit, its time, whether the tree was dirty
+// and the Go version, is read from the build information the go command
+// embeds in every binary. Without ldflags a binary installed with
+// go install module@v1.4.0 still knows its version from the module.
+//
+// The same Version answers -version on the command line, serves an HTTP
+// endpoint, and gates features that need a minimum version.
+package buildinfo
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Set with -ldflags "-X <package path>.version=..." and so on.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// readBuildInfo is replaced in tests.
+var readBuildInfo = debug.ReadBuildInfo
+
+// Devel is the version of a binary built from a working tree.
+const Devel = "(devel)"
+
+// Version describes a build.
+type Version struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+	Module    string `json:"module,omitempty"`
+}
+
+// Get returns the version of the running binary. Values set with ldflags
+// take precedence over the embedded build information.
+func Get() Version {
+	v := Version{Version: Devel}
+	if bi, ok := readBuildInfo(); ok {
+		v.GoVersion = bi.GoVersion
+		v.Module = bi.Main.Path
+		if bi.Main.Version != "" {
+			v.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				v.Commit = s.Value
+			case "vcs.time":
+				v.Date = s.Value
+			case "vcs.modified":
+				v.Modified = s.Value == "true"
+			}
+		}
+	}
+	if version != "" {
+		v.Version = version
+	}
+	if commit != "" {
+		v.Commit = commit
+	}
+	if date != "" {
+		v.Date = date
+	}
+	return v
+}
+
+// String returns a one line description such as
+// "v1.4.0 (3f2a9c1, 2024-05-01T10:00:00Z) go1.23.0".
+func (v Version) String() string {
+	var b strings.Builder
+	b.WriteString(v.Version)
+	if v.Commit != "" {
+		c := v.Commit
+		if len(c) > 7 {
+			c = c[:7]
+		}
+		if v.Modified {
+			c += "-dirty"
+		}
+		b.WriteString(" (" + c)
+		if v.Date != "" {
+			b.WriteString(", " + v.Date)
+		}
+		b.WriteString(")")
+	}
+	if v.GoVersion != "" {
+		b.WriteString(" " + v.GoVersion)
+	}
+	return b.String()
+}
+
+// Handler serves the version as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Get())
+	})
+}
+
+// RegisterFlag defines a -version flag on fs. The caller prints Get()
+// and exits when it is set.
+func RegisterFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("version", false, "print version information and exit")
+}
+
+// Feature is something that needs at least a certain version.
+type Feature struct {
+	Name  string
+	Since string
+}
+
+// Enabled reports whether f is available in v. Development builds have
+// every feature.
+func (v Version) Enabled(f Feature) bool {
+	return v.Version == Devel || v.AtLeast(f.Since)
+}
+
+// AtLeast reports whether v is the semantic version min or later. A
+// version that does not parse is never at least anything.
+func (v Version) AtLeast(min string) bool {
+	a, ok1 := parse(v.Version)
+	b, ok2 := parse(min)
+	return ok1 && ok2 && compare(a, b) >= 0
+}
+
+type semver struct {
+	num [3]int
+	pre []string
+}
+
+// parse reads vMAJOR[.MINOR[.PATCH]][-PRERELEASE][+BUILD].
+func parse(s string) (semver, bool) {
+	var v semver
+	if !strings.HasPrefix(s, "v") {
+		return v, false
+	}
+	s = s[1:]
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.pre = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.num[i] = n
+	}
+	return v, true
+}
+
+func compare(a, b semver) int {
+	for i := range a.num {
+		if a.num[i] != b.num[i] {
+			return sign(a.num[i] - b.num[i])
+		}
+	}
+	// A pre-release sorts before the release itself.
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		if c := comparePre(a.pre[i], b.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(a.pre) - len(b.pre))
+}
+
+// comparePre orders numeric identifiers numerically and before
+// alphanumeric ones, which are ordered lexically.
+func comparePre(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return sign(na - nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,114 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http/httptest"
+	"runtime/debug"
+	"testing"
+)
+
+func stub(t *testing.T, bi *debug.BuildInfo, ldVersion string) {
+	t.Helper()
+	oldRead, oldVersion := readBuildInfo, version
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return bi, bi != nil }
+	version = ldVersion
+	t.Cleanup(func() { readBuildInfo, version = oldRead, oldVersion })
+}
+
+var built = &debug.BuildInfo{
+	GoVersion: "go1.23.4",
+	Main:      debug.Module{Path: "github.com/crazybber/go-patterns", Version: "v1.4.0"},
+	Settings: []debug.BuildSetting{
+		{Key: "vcs.revision", Value: "3f2a9c1d0e8b7a6f"},
+		{Key: "vcs.time", Value: "2024-05-01T10:00:00Z"},
+		{Key: "vcs.modified", Value: "true"},
+	},
+}
+
+func TestGetFromBuildInfo(t *testing.T) {
+	stub(t, built, "")
+	v := Get()
+	want := Version{
+		Version:   "v1.4.0",
+		Commit:    "3f2a9c1d0e8b7a6f",
+		Date:      "2024-05-01T10:00:00Z",
+		Modified:  true,
+		GoVersion: "go1.23.4",
+		Module:    "github.com/crazybber/go-patterns",
+	}
+	if v != want {
+		t.Errorf("got %+v", v)
+	}
+	if s := v.String(); s != "v1.4.0 (3f2a9c1-dirty, 2024-05-01T10:00:00Z) go1.23.4" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestLdflagsWin(t *testing.T) {
+	stub(t, built, "v2.0.0-rc.1")
+	if v := Get(); v.Version != "v2.0.0-rc.1" || v.Commit == "" {
+		t.Errorf("got %+v", v)
+	}
+}
+
+func TestNoBuildInfo(t *testing.T) {
+	stub(t, nil, "")
+	if v := Get(); v.Version != Devel || v.String() != Devel {
+		t.Errorf("got %+v", v)
+	}
+}
+
+func TestAtLeast(t *testing.T) {
+	tests := []struct {
+		v, min string
+		want   bool
+	}{
+		{"v1.4.0", "v1.4.0", true},
+		{"v1.4.1", "v1.4", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v1.3.9", "v1.4.0", false},
+		{"v1.4.0-rc.1", "v1.4.0", false},
+		{"v1.4.0", "v1.4.0-rc.1", true},
+		{"v1.4.0-rc.10", "v1.4.0-rc.2", true},
+		{"v1.4.0-beta", "v1.4.0-alpha", true},
+		{"v1.4.0-1", "v1.4.0-alpha", false},
+		{"v1.4.0+build.5", "v1.4.0", true},
+		{"1.4.0", "v1.0.0", false},
+		{Devel, "v0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := (Version{Version: tt.v}).AtLeast(tt.min); got != tt.want {
+			t.Errorf("%s at least %s = %v, want %v", tt.v, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	streaming := Feature{Name: "streaming", Since: "v1.5.0"}
+	if (Version{Version: "v1.4.0"}).Enabled(streaming) {
+		t.Error("v1.4.0 should not have a v1.5.0 feature")
+	}
+	if !(Version{Version: "v1.5.2"}).Enabled(streaming) {
+		t.Error("v1.5.2 should have a v1.5.0 feature")
+	}
+	if !(Version{Version: Devel}).Enabled(streaming) {
+		t.Error("development builds have every feature")
+	}
+}
+
+func TestHandlerAndFlag(t *testing.T) {
+	stub(t, built, "")
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/version", nil))
+	var v Version
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil || v.Version != "v1.4.0" {
+		t.Errorf("got %+v, %v", v, err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	show := RegisterFlag(fs)
+	if err := fs.Parse([]string{"-version"}); err != nil || !*show {
+		t.Errorf("-version not recognised: %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Package cache is a read-through TTL cache whose policy also covers the
+// answers that are not values: "not found" results are cached for a shorter
+// negative TTL, and failures are cached for a TTL that grows exponentially
+// with every consecutive failure of the same key. Both keep a cache from
+// hammering a source that keeps answering the same bad news.
+//
+// Two plain caches come with it, which store only what they are given:
+// LRU bounds its size by evicting the least recently used entry, and TTL
+// bounds the age of its entries, freeing expired ones from a scheduled
+// job.
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// ErrNotFound is returned, possibly wrapped, by a Loader when the key does
+// not exist. Such results are negatively cached.
+var ErrNotFound = errors.New("cache: not found")
+
+// Loader fetches the value for key from the source of truth.
+type Loader[V any] func(ctx context.Context, key string) (V, error)
+
+// Policy configures how long each kind of result is cached. A zero TTL
+// disables caching of that kind.
+type Policy struct {
+	TTL time.Duration
+	// NegativeTTL applies to results wrapping ErrNotFound.
+	NegativeTTL time.Duration
+	// ErrorTTL applies to the first failure of a key and doubles with every
+	// consecutive failure, up to MaxErrorTTL.
+	ErrorTTL    time.Duration
+	MaxErrorTTL time.Duration
+}
+
+// errorTTL returns the TTL after the given number of consecutive failures.
+func (p Policy) errorTTL(failures int) time.Duration {
+	ttl := p.ErrorTTL
+	for i := 1; i < failures && ttl > 0; i++ {
+		ttl *= 2
+		if p.MaxErrorTTL > 0 && ttl >= p.MaxErrorTTL {
+			return p.MaxErrorTTL
+		}
+	}
+	if p.MaxErrorTTL > 0 && ttl > p.MaxErrorTTL {
+		return p.MaxErrorTTL
+	}
+	return ttl
+}
+
+// Stats counts how lookups were answered.
+type Stats struct {
+	Hits         int64 // served a cached value
+	NegativeHits int64 // served a cached "not found"
+	ErrorHits    int64 // served a cached failure
+	Misses       int64 // went to the loader
+}
+
+type kind int
+
+const (
+	value kind = iota
+	negative
+	failure
+)
+
+type entry[V any] struct {
+	kind    kind
+	val     V
+	err     error
+	expires time.Time
+}
+
+// Cache is a read-through cache in front of a Loader.
+type Cache[V any] struct {
+	load   Loader[V]
+	policy Policy
+	clock  clock.Clock
+
+	mu       sync.Mutex
+	entries  map[string]*entry[V]
+	failures map[string]int
+
+	hits, negativeHits, errorHits, misses int64
+}
+
+// New creates a cache with the given policy.
+func New[V any](load Loader[V], policy Policy) *Cache[V] {
+	return &Cache[V]{
+		load:     load,
+		policy:   policy,
+		clock:    clock.Real,
+		entries:  make(map[string]*entry[V]),
+		failures: make(map[string]int),
+	}
+}
+
+// SetClock sets the clock that values, misses and failures expire by.
+// Set it before the first Get.
+func (c *Cache[V]) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// Get returns the cached result for key, or loads and caches it.
+func (c *Cache[V]) Get(ctx context.Context, key string) (V, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && c.clock.Now().Before(e.expires) {
+		c.mu.Unlock()
+		switch e.kind {
+		case negative:
+			atomic.AddInt64(&c.negativeHits, 1)
+		case failure:
+			atomic.AddInt64(&c.errorHits, 1)
+		default:
+			atomic.AddInt64(&c.hits, 1)
+		}
+		return e.val, e.err
+	}
+	c.mu.Unlock()
+
+	atomic.AddInt64(&c.misses, 1)
+	v, err := c.load(ctx, key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err == nil:
+		delete(c.failures, key)
+		c.put(key, &entry[V]{kind: value, val: v}, c.policy.TTL)
+	case errors.Is(err, ErrNotFound):
+		delete(c.failures, key)
+		c.put(key, &entry[V]{kind: negative, err: err}, c.policy.NegativeTTL)
+	default:
+		c.failures[key]++
+		c.put(key, &entry[V]{kind: failure, err: err}, c.policy.errorTTL(c.failures[key]))
+	}
+	return v, err
+}
+
+func (c *Cache[V]) put(key string, e *entry[V], ttl time.Duration) {
+	if ttl <= 0 {
+		delete(c.entries, key)
+		return
+	}
+	e.expires = c.clock.Now().Add(ttl)
+	c.entries[key] = e
+}
+
+// Invalidate drops whatever is cached for key and forgets its failures.
+func (c *Cache[V]) Invalidate(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	delete(c.failures, key)
+	c.mu.Unlock()
+}
+
+// Stats returns a snapshot of the lookup counters.
+func (c *Cache[V]) Stats() Stats {
+	return Stats{
+		Hits:         atomic.LoadInt64(&c.hits),
+		NegativeHits: atomic.LoadInt64(&c.negativeHits),
+		ErrorHits:    atomic.LoadInt64(&c.errorHits),
+		Misses:       atomic.LoadInt64(&c.misses),
+	}
+}
@@ -0,0 +1,304 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/scheduler"
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+type script struct {
+	calls int
+	err   error
+}
+
+func (s *script) load(ctx context.Context, key string) (string, error) {
+	s.calls++
+	if s.err != nil {
+		return "", s.err
+	}
+	return "value of " + key, nil
+}
+
+func newCache(s *script, c *clock.Fake) *Cache[string] {
+	cache := New(s.load, Policy{
+		TTL:         time.Minute,
+		NegativeTTL: 10 * time.Second,
+		ErrorTTL:    time.Second,
+		MaxErrorTTL: 5 * time.Second,
+	})
+	cache.SetClock(c)
+	return cache
+}
+
+func TestValueTTL(t *testing.T) {
+	s, c := &script{}, clock.NewFake(time.Unix(0, 0))
+	cache := newCache(s, c)
+
+	cache.Get(context.Background(), "a")
+	c.Advance(59 * time.Second)
+	if v, err := cache.Get(context.Background(), "a"); v != "value of a" || err != nil {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+	c.Advance(time.Second)
+	cache.Get(context.Background(), "a")
+
+	if s.calls != 2 {
+		t.Errorf("expected 2 loads, got %d", s.calls)
+	}
+	if st := cache.Stats(); st.Hits != 1 || st.Misses != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestNegativeCaching(t *testing.T) {
+	s, c := &script{err: fmt.Errorf("user 7: %w", ErrNotFound)}, clock.NewFake(time.Unix(0, 0))
+	cache := newCache(s, c)
+
+	for i := 0; i < 5; i++ {
+		if _, err := cache.Get(context.Background(), "7"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if s.calls != 1 {
+		t.Errorf("not found was looked up %d times, want 1", s.calls)
+	}
+
+	c.Advance(10 * time.Second)
+	cache.Get(context.Background(), "7")
+	if s.calls != 2 {
+		t.Errorf("negative entry outlived its TTL")
+	}
+	if st := cache.Stats(); st.NegativeHits != 4 || st.Misses != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestErrorTTLGrowsExponentially(t *testing.T) {
+	s, c := &script{err: errors.New("connection refused")}, clock.NewFake(time.Unix(0, 0))
+	cache := newCache(s, c)
+
+	// Each failure is cached for 1s, 2s, 4s and then the 5s cap.
+	for i, ttl := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		calls := s.calls
+		cache.Get(context.Background(), "k")
+		if s.calls != calls+1 {
+			t.Fatalf("failure %d was not reloaded", i)
+		}
+		c.Advance(ttl - time.Millisecond)
+		cache.Get(context.Background(), "k")
+		if s.calls != calls+1 {
+			t.Fatalf("failure %d expired before %v", i, ttl)
+		}
+		c.Advance(time.Millisecond)
+	}
+	if st := cache.Stats(); st.ErrorHits != 5 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestRecoveryResetsBackoff(t *testing.T) {
+	s, c := &script{err: errors.New("down")}, clock.NewFake(time.Unix(0, 0))
+	cache := newCache(s, c)
+
+	cache.Get(context.Background(), "k")
+	c.Advance(time.Second)
+	cache.Get(context.Background(), "k")
+	c.Advance(2 * time.Second)
+
+	s.err = nil
+	if _, err := cache.Get(context.Background(), "k"); err != nil {
+		t.Fatal(err)
+	}
+	cache.Invalidate("k")
+
+	s.err = errors.New("down again")
+	cache.Get(context.Background(), "k")
+	c.Advance(time.Second)
+	calls := s.calls
+	cache.Get(context.Background(), "k")
+	if s.calls != calls+1 {
+		t.Error("a success should reset the error TTL to its initial value")
+	}
+}
+
+func TestZeroTTLDisablesKind(t *testing.T) {
+	s := &script{err: ErrNotFound}
+	cache := New(s.load, Policy{TTL: time.Minute})
+	cache.Get(context.Background(), "k")
+	cache.Get(context.Background(), "k")
+	if s.calls != 2 {
+		t.Errorf("negative caching should be off without a NegativeTTL")
+	}
+}
+
+func TestLRUEvictionOrder(t *testing.T) {
+	var evicted []string
+	c := NewLRU(3, func(k string, v int) { evicted = append(evicted, fmt.Sprint(k, "=", v)) })
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("c", 3)
+	c.Get("a")     // a is now the most recently used
+	c.Peek("b")    // Peek does not count as a use
+	c.Put("c", 30) // neither does it evict when the key exists
+	c.Put("d", 4)  // evicts b
+	c.Put("e", 5)  // evicts a
+	if got, want := fmt.Sprint(evicted), "[b=2 a=1]"; got != want {
+		t.Errorf("evicted %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(c.Keys()), "[e d c]"; got != want {
+		t.Errorf("keys %s, want %s", got, want)
+	}
+	if v, ok := c.Get("c"); v != 30 || !ok {
+		t.Errorf("Get(c) = %d, %v", v, ok)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("evicted key still cached")
+	}
+	if !c.Remove("d") || c.Remove("d") || c.Len() != 2 {
+		t.Errorf("Remove: Len = %d", c.Len())
+	}
+}
+
+func TestLRUMinimumCapacity(t *testing.T) {
+	c := NewLRU[int, int](0, nil)
+	c.Put(1, 1)
+	c.Put(2, 2)
+	if c.Len() != 1 {
+		t.Errorf("Len = %d, want 1", c.Len())
+	}
+}
+
+func TestLRUConcurrent(t *testing.T) {
+	c := NewLRU[int, int](64, nil)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				k := (g*1000 + i) % 100
+				if v, ok := c.Get(k); ok && v != k {
+					t.Errorf("Get(%d) = %d", k, v)
+				}
+				c.Put(k, k)
+			}
+		}()
+	}
+	wg.Wait()
+	if c.Len() != 64 || len(c.Keys()) != 64 {
+		t.Errorf("Len = %d", c.Len())
+	}
+}
+
+func TestTTLExpiry(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	c := NewTTL[string, int](time.Minute)
+	c.SetClock(clk)
+	c.Put("a", 1)
+	c.PutTTL("b", 2, time.Hour)
+	clk.Advance(59 * time.Second)
+	if v, ok := c.Get("a"); v != 1 || !ok {
+		t.Errorf("Get(a) before expiry = %d, %v", v, ok)
+	}
+	clk.Advance(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get(a) returned an expired entry")
+	}
+	// Expired entries stay until swept.
+	if c.Len() != 2 {
+		t.Errorf("Len before Expire = %d", c.Len())
+	}
+	if n := c.Expire(); n != 1 || c.Len() != 1 {
+		t.Errorf("Expire = %d, Len = %d", n, c.Len())
+	}
+	c.Remove("b")
+	if _, ok := c.Get("b"); ok || c.Len() != 0 {
+		t.Error("Remove left b")
+	}
+}
+
+func TestTTLSchedule(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	c := NewTTL[int, string](time.Minute)
+	c.SetClock(clk)
+	for i := range 10 {
+		c.Put(i, "v")
+	}
+	s := scheduler.New(nil)
+	defer s.Stop()
+	if err := c.Schedule(s, "expire", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	clk.Advance(time.Hour)
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d entries left after the sweeps", c.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func BenchmarkLRU(b *testing.B) {
+	c := NewLRU[int, int](1024, nil)
+	for i := range 1024 {
+		c.Put(i, i)
+	}
+	b.Run("get", func(b *testing.B) {
+		for i := range b.N {
+			c.Get(i & 1023)
+		}
+	})
+	b.Run("put-evict", func(b *testing.B) {
+		for i := range b.N {
+			c.Put(i+1024, i)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				if _, ok := c.Get(i & 2047); !ok {
+					c.Put(i&2047, i)
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkTTL(b *testing.B) {
+	c := NewTTL[int, int](time.Hour)
+	for i := range 1024 {
+		c.Put(i, i)
+	}
+	b.Run("get", func(b *testing.B) {
+		for i := range b.N {
+			c.Get(i & 1023)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.Get(i & 1023)
+			}
+		})
+	})
+}
+
+func ExampleLRU() {
+	recent := NewLRU(2, func(page string, _ int) { fmt.Println("evict", page) })
+	recent.Put("/", 1)
+	recent.Put("/docs", 2)
+	recent.Get("/")
+	recent.Put("/blog", 3)
+	fmt.Println(recent.Keys())
+	// Output:
+	// evict /docs
+	// [/blog /]
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a cache of at most a fixed number of entries that evicts the
+// least recently used one to make room. A map finds entries and a doubly
+// linked list keeps them in order of use, so every operation is O(1). It
+// is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	capacity int
+	onEvict  func(K, V)
+
+	mu    sync.Mutex
+	order *list.List // of *lruEntry, most recently used first
+	items map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key K
+	val V
+}
+
+// NewLRU returns an LRU holding up to capacity entries, at least one.
+// onEvict, if not nil, is called with every entry evicted to make room,
+// after the cache's lock has been released.
+func NewLRU[K comparable, V any](capacity int, onEvict func(K, V)) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: max(capacity, 1),
+		onEvict:  onEvict,
+		order:    list.New(),
+		items:    make(map[K]*list.Element),
+	}
+}
+
+// Get returns the value of key and marks it as the most recently used.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry[K, V]).val, true
+}
+
+// Peek returns the value of key without marking it as used.
+func (c *LRU[K, V]) Peek(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return el.Value.(*lruEntry[K, V]).val, true
+}
+
+// Put stores val for key as the most recently used entry, evicting the
+// least recently used one if the cache is full.
+func (c *LRU[K, V]) Put(key K, val V) {
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry[K, V]).val = val
+		c.order.MoveToFront(el)
+		c.mu.Unlock()
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key, val})
+	var evicted *lruEntry[K, V]
+	if c.order.Len() > c.capacity {
+		evicted = c.order.Remove(c.order.Back()).(*lruEntry[K, V])
+		delete(c.items, evicted.key)
+	}
+	c.mu.Unlock()
+	if evicted != nil && c.onEvict != nil {
+		c.onEvict(evicted.key, evicted.val)
+	}
+}
+
+// Remove drops key and reports whether it was there.
+func (c *LRU[K, V]) Remove(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+	return ok
+}
+
+// Len returns the number of entries.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Keys returns the keys from the most to the least recently used.
+func (c *LRU[K, V]) Keys() []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]K, 0, c.order.Len())
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(*lruEntry[K, V]).key)
+	}
+	return keys
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"testing/quick"
+)
+
+// lruOp is a step of a random history run against an LRU and a model.
+type lruOp struct {
+	kind string // "put", "get", "peek" or "remove"
+	key  int
+	val  int
+}
+
+func (o lruOp) String() string {
+	if o.kind == "put" {
+		return fmt.Sprintf("put(%d,%d)", o.key, o.val)
+	}
+	return fmt.Sprintf("%s(%d)", o.kind, o.key)
+}
+
+// lruHistory is a random sequence of lruOps for testing/quick, on few
+// enough keys that they hit, miss and evict each other often.
+type lruHistory struct {
+	capacity int
+	ops      []lruOp
+}
+
+func (lruHistory) Generate(r *rand.Rand, size int) reflect.Value {
+	h := lruHistory{capacity: 1 + r.Intn(4)}
+	keys := h.capacity * 2
+	for i := range r.Intn(size * 4) {
+		o := lruOp{key: r.Intn(keys), val: i}
+		o.kind = []string{"put", "put", "get", "peek", "remove"}[r.Intn(5)]
+		h.ops = append(h.ops, o)
+	}
+	return reflect.ValueOf(h)
+}
+
+// GoString keeps the input testing/quick reports on failure readable.
+func (h lruHistory) GoString() string { return fmt.Sprintf("cap %d: %v", h.capacity, h.ops) }
+
+// lruModel is an LRU too simple to be wrong: a slice of entries, most
+// recently used first, searched from the front.
+type lruModel struct {
+	capacity int
+	entries  [][2]int
+	evicted  [][2]int
+}
+
+func (m *lruModel) find(key int) int {
+	return slices.IndexFunc(m.entries, func(e [2]int) bool { return e[0] == key })
+}
+
+func (m *lruModel) touch(i int) {
+	e := m.entries[i]
+	m.entries = slices.Insert(slices.Delete(m.entries, i, i+1), 0, e)
+}
+
+func (m *lruModel) apply(o lruOp) string {
+	i := m.find(o.key)
+	switch o.kind {
+	case "put":
+		if i >= 0 {
+			m.entries[i][1] = o.val
+			m.touch(i)
+			break
+		}
+		m.entries = slices.Insert(m.entries, 0, [2]int{o.key, o.val})
+		if len(m.entries) > m.capacity {
+			m.evicted = append(m.evicted, m.entries[len(m.entries)-1])
+			m.entries = m.entries[:len(m.entries)-1]
+		}
+	case "get", "peek":
+		if i < 0 {
+			return fmt.Sprint(0, false)
+		}
+		v := m.entries[i][1]
+		if o.kind == "get" {
+			m.touch(i)
+		}
+		return fmt.Sprint(v, true)
+	case "remove":
+		if i >= 0 {
+			m.entries = slices.Delete(m.entries, i, i+1)
+		}
+		return fmt.Sprint(i >= 0)
+	}
+	return ""
+}
+
+func (m *lruModel) keys() []int {
+	keys := make([]int, len(m.entries))
+	for i, e := range m.entries {
+		keys[i] = e[0]
+	}
+	return keys
+}
+
+// Every history gives the results, the order of use and the evictions the
+// model predicts.
+func TestPropertyLRU(t *testing.T) {
+	check := func(h lruHistory) bool {
+		var evicted [][2]int
+		c := NewLRU(h.capacity, func(k, v int) { evicted = append(evicted, [2]int{k, v}) })
+		m := &lruModel{capacity: h.capacity}
+		for i, o := range h.ops {
+			var got string
+			switch o.kind {
+			case "put":
+				c.Put(o.key, o.val)
+			case "get":
+				got = fmt.Sprint(c.Get(o.key))
+			case "peek":
+				got = fmt.Sprint(c.Peek(o.key))
+			case "remove":
+				got = fmt.Sprint(c.Remove(o.key))
+			}
+			want := m.apply(o)
+			if got != want || !slices.Equal(c.Keys(), m.keys()) || c.Len() != len(m.entries) ||
+				!slices.Equal(evicted, m.evicted) {
+				t.Logf("cap %d: %v gave %s, keys %v, evicted %v; want %s, keys %v, evicted %v",
+					h.capacity, h.ops[:i+1], got, c.Keys(), evicted, want, m.keys(), m.evicted)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(check, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// Histories run concurrently, reading with Get and writing everything else
+// with Put, lose no entry: the cache ends up full, or holding every key
+// put, with values put for those keys, and every other key put was
+// evicted.
+func TestPropertyLRUConcurrent(t *testing.T) {
+	check := func(histories [4]lruHistory) bool {
+		capacity := histories[0].capacity
+		var mu sync.Mutex
+		evicted := map[int]bool{}
+		c := NewLRU(capacity, func(k, _ int) {
+			mu.Lock()
+			evicted[k] = true
+			mu.Unlock()
+		})
+		put := map[int]bool{}
+		var wg sync.WaitGroup
+		for g, h := range histories {
+			for _, o := range h.ops {
+				if o.kind != "get" && o.kind != "peek" {
+					put[o.key] = true
+				}
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for _, o := range h.ops {
+					if o.kind == "get" || o.kind == "peek" {
+						c.Get(o.key)
+						continue
+					}
+					// The value says whose key it is, so a value filed
+					// under the wrong key shows.
+					c.Put(o.key, o.key*1000+g)
+				}
+			}()
+		}
+		wg.Wait()
+
+		keys := c.Keys()
+		if len(keys) != min(capacity, len(put)) || c.Len() != len(keys) {
+			t.Logf("cap %d, %d keys put: cached %v, Len %d", capacity, len(put), keys, c.Len())
+			return false
+		}
+		for _, k := range keys {
+			if v, _ := c.Peek(k); v/1000 != k {
+				t.Logf("key %d has value %d", k, v)
+				return false
+			}
+		}
+		for k := range put {
+			if !slices.Contains(keys, k) && !evicted[k] {
+				t.Logf("key %d lost: neither cached nor evicted", k)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 50}); err != nil {
+		t.Error(err)
+	}
+}
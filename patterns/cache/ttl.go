@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/scheduler"
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// TTL is a cache whose entries expire a fixed time after they were
+// stored. Expired entries are never returned, but they are only freed by
+// Expire, which a scheduler job runs in the background; see Schedule. It
+// is safe for concurrent use.
+type TTL[K comparable, V any] struct {
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu      sync.RWMutex
+	entries map[K]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	val     V
+	expires time.Time
+}
+
+// NewTTL returns a TTL cache whose entries live for ttl.
+func NewTTL[K comparable, V any](ttl time.Duration) *TTL[K, V] {
+	return &TTL[K, V]{ttl: ttl, clock: clock.Real, entries: make(map[K]ttlEntry[V])}
+}
+
+// SetClock makes the entries age by c, such as a clock.Fake, rather than
+// by the wall clock.
+func (c *TTL[K, V]) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// Get returns the value of key unless it is missing or has expired.
+func (c *TTL[K, V]) Get(key K) (V, bool) {
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+	if !ok || !c.clock.Now().Before(e.expires) {
+		var zero V
+		return zero, false
+	}
+	return e.val, true
+}
+
+// Put stores val for key for the cache's TTL.
+func (c *TTL[K, V]) Put(key K, val V) { c.PutTTL(key, val, c.ttl) }
+
+// PutTTL stores val for key for ttl.
+func (c *TTL[K, V]) PutTTL(key K, val V, ttl time.Duration) {
+	c.mu.Lock()
+	c.entries[key] = ttlEntry[V]{val, c.clock.Now().Add(ttl)}
+	c.mu.Unlock()
+}
+
+// Remove drops key.
+func (c *TTL[K, V]) Remove(key K) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// Len returns the number of entries, counting expired ones not yet freed.
+func (c *TTL[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// Expire frees the expired entries and returns how many there were.
+func (c *TTL[K, V]) Expire() int {
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+// Schedule adds a job with the given name to s that runs Expire every
+// interval. A sweep still running when the next is due makes that one
+// skip.
+func (c *TTL[K, V]) Schedule(s *scheduler.Scheduler, name string, every time.Duration) error {
+	return s.Add(name, scheduler.Every(every), scheduler.Skip, func(context.Context) {
+		c.Expire()
+	})
+}
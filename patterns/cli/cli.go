@@ -0,0 +1,234 @@
+// Package cli is a small subcommand framework on top of the flag package.
+//
+// A program is a tree of Commands. Each command declares its flags as a
+// struct whose fields carry flag and usage tags; the defaults are whatever
+// the fields hold when the command is built. Flags of a command are shared
+// with everything below it, so a global -v works before and after the
+// subcommand name. Usage text is generated from the tree and the tags.
+//
+//	type globals struct {
+//		Verbose bool `flag:"v" usage:"log more"`
+//	}
+//
+//	root := &cli.Command{
+//		Name:  "patterns",
+//		Flags: &globals{},
+//		Commands: []*cli.Command{
+//			{Name: "list", Usage: "list the examples", Run: list},
+//		},
+//	}
+//	cli.Main(root)
+//
+// Main cancels the context passed to Run on SIGINT or SIGTERM.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Command is a node in the command tree.
+type Command struct {
+	// Name is the word that selects the command.
+	Name string
+	// Usage is a one line summary shown in the parent's command list.
+	Usage string
+	// Args describes the positional arguments in the usage line.
+	Args string
+	// Flags is a pointer to a struct with tagged fields, or nil.
+	Flags interface{}
+	// Run is called with the arguments left after the flags. A command
+	// without Run must be given a subcommand.
+	Run func(ctx context.Context, args []string) error
+	// Commands are the subcommands.
+	Commands []*Command
+}
+
+// UsageError is returned for command lines that do not parse. Its Usage
+// field holds the usage text of the command concerned.
+type UsageError struct {
+	Err   error
+	Usage string
+}
+
+func (e *UsageError) Error() string { return e.Err.Error() }
+func (e *UsageError) Unwrap() error { return e.Err }
+
+// Usagef lets Run report a bad command line. The usage text of the command
+// is filled in by Execute.
+func Usagef(format string, args ...interface{}) error {
+	return &UsageError{Err: fmt.Errorf(format, args...)}
+}
+
+// Execute parses args against the tree below c and runs the selected
+// command. -h and -help return a *UsageError wrapping flag.ErrHelp.
+func (c *Command) Execute(ctx context.Context, args []string) error {
+	return c.execute(ctx, args, nil, nil)
+}
+
+// execute handles c with the flag structs and path of its ancestors.
+func (c *Command) execute(ctx context.Context, args []string, inherited []interface{}, path []string) error {
+	path = append(path, c.Name)
+	all := inherited
+	if c.Flags != nil {
+		all = append(append([]interface{}(nil), inherited...), c.Flags)
+	}
+
+	fs := flag.NewFlagSet(strings.Join(path, " "), flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	for _, v := range all {
+		if err := register(fs, v); err != nil {
+			return err
+		}
+	}
+	usageErr := func(err error) error {
+		return &UsageError{Err: err, Usage: c.usage(path, fs)}
+	}
+	if err := fs.Parse(args); err != nil {
+		return usageErr(err)
+	}
+	rest := fs.Args()
+
+	if len(rest) > 0 {
+		for _, sub := range c.Commands {
+			if sub.Name == rest[0] {
+				return sub.execute(ctx, rest[1:], all, path)
+			}
+		}
+	}
+	if c.Run == nil {
+		if len(rest) == 0 {
+			return usageErr(errors.New("missing command"))
+		}
+		return usageErr(fmt.Errorf("unknown command %q", rest[0]))
+	}
+	err := c.Run(ctx, rest)
+	var ue *UsageError
+	if errors.As(err, &ue) && ue.Usage == "" {
+		ue.Usage = c.usage(path, fs)
+	}
+	return err
+}
+
+// usage renders the help text for c.
+func (c *Command) usage(path []string, fs *flag.FlagSet) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "usage: %s", strings.Join(path, " "))
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		b.WriteString(" [flags]")
+	}
+	if len(c.Commands) > 0 {
+		b.WriteString(" <command>")
+	}
+	if c.Args != "" {
+		b.WriteString(" " + c.Args)
+	}
+	b.WriteString("\n")
+	if c.Usage != "" {
+		b.WriteString("\n" + c.Usage + "\n")
+	}
+	if len(c.Commands) > 0 {
+		b.WriteString("\ncommands:\n")
+		width := 0
+		for _, sub := range c.Commands {
+			if len(sub.Name) > width {
+				width = len(sub.Name)
+			}
+		}
+		for _, sub := range c.Commands {
+			fmt.Fprintf(&b, "  %-*s  %s\n", width, sub.Name, sub.Usage)
+		}
+	}
+	if hasFlags {
+		b.WriteString("\nflags:\n")
+		fs.SetOutput(&b)
+		fs.PrintDefaults()
+		fs.SetOutput(io.Discard)
+	}
+	return b.String()
+}
+
+// Main executes root with the command line arguments and exits. The
+// context is cancelled on SIGINT or SIGTERM. Usage errors exit with status
+// 2, other errors with 1.
+func Main(root *Command) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := root.Execute(ctx, os.Args[1:])
+	stop()
+	os.Exit(report(err, os.Stdout, os.Stderr))
+}
+
+// report prints err and returns the exit status for it.
+func report(err error, stdout, stderr io.Writer) int {
+	var ue *UsageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp) && errors.As(err, &ue):
+		fmt.Fprint(stdout, ue.Usage)
+		return 0
+	case errors.As(err, &ue):
+		fmt.Fprintf(stderr, "%v\n\n%s", ue.Err, ue.Usage)
+		return 2
+	default:
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+}
+
+// register defines a flag on fs for every tagged field of the struct v
+// points to. The current field values become the defaults.
+func register(fs *flag.FlagSet, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cli: Flags must be a pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		name, ok := field.Tag.Lookup("flag")
+		if !ok {
+			continue
+		}
+		if !field.IsExported() {
+			return fmt.Errorf("cli: flag field %s must be exported", field.Name)
+		}
+		usage := field.Tag.Get("usage")
+		ptr := rv.Field(i).Addr().Interface()
+		switch p := ptr.(type) {
+		case flag.Value:
+			fs.Var(p, name, usage)
+		case *time.Duration:
+			fs.DurationVar(p, name, *p, usage)
+		case *string:
+			fs.StringVar(p, name, *p, usage)
+		case *bool:
+			fs.BoolVar(p, name, *p, usage)
+		case *int:
+			fs.IntVar(p, name, *p, usage)
+		case *int64:
+			fs.Int64Var(p, name, *p, usage)
+		case *uint:
+			fs.UintVar(p, name, *p, usage)
+		case *uint64:
+			fs.Uint64Var(p, name, *p, usage)
+		case *float64:
+			fs.Float64Var(p, name, *p, usage)
+		default:
+			return fmt.Errorf("cli: unsupported flag type %s for field %s", field.Type, field.Name)
+		}
+	}
+	return nil
+}
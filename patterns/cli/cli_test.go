@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type globals struct {
+	Verbose bool   `flag:"v" usage:"log more"`
+	Config  string `flag:"config" usage:"config file"`
+}
+
+type runFlags struct {
+	N       int           `flag:"n" usage:"number of items"`
+	Timeout time.Duration `flag:"timeout" usage:"give up after"`
+	Rate    float64       `flag:"rate" usage:"items per second"`
+	ignored string
+}
+
+type call struct {
+	cmd  string
+	args []string
+}
+
+func tree(g *globals, r *runFlags, calls *[]call) *Command {
+	record := func(name string) func(context.Context, []string) error {
+		return func(_ context.Context, args []string) error {
+			*calls = append(*calls, call{name, args})
+			return nil
+		}
+	}
+	return &Command{
+		Name:  "patterns",
+		Flags: g,
+		Commands: []*Command{
+			{Name: "list", Usage: "list the examples", Run: record("list")},
+			{
+				Name:  "run",
+				Usage: "run an example",
+				Args:  "<name>",
+				Flags: r,
+				Run:   record("run"),
+				Commands: []*Command{
+					{Name: "all", Usage: "run every example", Run: record("run all")},
+				},
+			},
+		},
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    call
+		globals globals
+		run     runFlags
+	}{
+		{[]string{"list"}, call{"list", []string{}}, globals{}, runFlags{N: 10}},
+		{[]string{"-v", "list", "x"}, call{"list", []string{"x"}}, globals{Verbose: true}, runFlags{N: 10}},
+		{[]string{"run", "-n", "5", "-timeout", "2s", "pipeline"}, call{"run", []string{"pipeline"}},
+			globals{}, runFlags{N: 5, Timeout: 2 * time.Second}},
+		// Global flags are shared with subcommands and may follow them.
+		{[]string{"run", "-v", "-config=c.json", "-rate", "1.5", "all"}, call{"run all", []string{}},
+			globals{Verbose: true, Config: "c.json"}, runFlags{N: 10, Rate: 1.5}},
+	}
+	for _, tt := range tests {
+		var calls []call
+		g, r := globals{}, runFlags{N: 10}
+		if err := tree(&g, &r, &calls).Execute(context.Background(), tt.args); err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+		if len(calls) != 1 || calls[0].cmd != tt.want.cmd || !reflect.DeepEqual(calls[0].args, tt.want.args) {
+			t.Errorf("%v: calls %v, want %v", tt.args, calls, tt.want)
+		}
+		if g != tt.globals || r != tt.run {
+			t.Errorf("%v: flags %+v %+v, want %+v %+v", tt.args, g, r, tt.globals, tt.run)
+		}
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	var calls []call
+	root := tree(&globals{}, &runFlags{}, &calls)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "missing command"},
+		{[]string{"deploy"}, `unknown command "deploy"`},
+		{[]string{"run", "-n", "many"}, "invalid value"},
+		{[]string{"list", "-n", "1"}, "flag provided but not defined: -n"},
+	}
+	for _, tt := range tests {
+		err := root.Execute(context.Background(), tt.args)
+		var ue *UsageError
+		if !errors.As(err, &ue) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got %v, want a usage error containing %q", tt.args, err, tt.want)
+		}
+	}
+	if len(calls) != 0 {
+		t.Errorf("nothing should have run, got %v", calls)
+	}
+}
+
+func TestUsageText(t *testing.T) {
+	var calls []call
+	err := tree(&globals{}, &runFlags{N: 10}, &calls).Execute(context.Background(), []string{"run", "-h"})
+	var ue *UsageError
+	if !errors.Is(err, flag.ErrHelp) || !errors.As(err, &ue) {
+		t.Fatalf("expected help, got %v", err)
+	}
+	for _, want := range []string{
+		"usage: patterns run [flags] <command> <name>",
+		"run an example",
+		"all  run every example",
+		"-n int\n", "number of items (default 10)",
+		"-config string", "-timeout duration",
+	} {
+		if !strings.Contains(ue.Usage, want) {
+			t.Errorf("usage does not contain %q:\n%s", want, ue.Usage)
+		}
+	}
+}
+
+func TestUsagef(t *testing.T) {
+	cmd := &Command{Name: "x", Args: "<file>", Run: func(_ context.Context, args []string) error {
+		if len(args) != 1 {
+			return Usagef("want one file, got %d", len(args))
+		}
+		return nil
+	}}
+	err := cmd.Execute(context.Background(), nil)
+	var ue *UsageError
+	if !errors.As(err, &ue) {
+		t.Fatalf("expected a usage error, got %v", err)
+	}
+	if !strings.HasPrefix(ue.Usage, "usage: x <file>") {
+		t.Errorf("usage not filled in: %q", ue.Usage)
+	}
+}
+
+func TestReport(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	help := &UsageError{Err: flag.ErrHelp, Usage: "usage: x\n"}
+	if code := report(help, &stdout, &stderr); code != 0 || stdout.String() != "usage: x\n" {
+		t.Errorf("help: code %d, stdout %q", code, stdout.String())
+	}
+	if code := report(&UsageError{Err: errors.New("bad"), Usage: "usage: x\n"}, &stdout, &stderr); code != 2 {
+		t.Errorf("usage error: code %d", code)
+	}
+	if code := report(errors.New("failed"), &stdout, &stderr); code != 1 {
+		t.Errorf("run error: code %d", code)
+	}
+}
+
+func TestBadFlagsStruct(t *testing.T) {
+	cmd := &Command{Name: "x", Flags: globals{}, Run: func(context.Context, []string) error { return nil }}
+	if err := cmd.Execute(context.Background(), nil); err == nil {
+		t.Error("a non-pointer Flags should be rejected")
+	}
+	type bad struct {
+		Items []string `flag:"item"`
+	}
+	cmd.Flags = &bad{}
+	if err := cmd.Execute(context.Background(), nil); err == nil {
+		t.Error("an unsupported field type should be rejected")
+	}
+}
+
+func ExampleCommand() {
+	type greetFlags struct {
+		Name  string `flag:"name" usage:"who to greet"`
+		Times int    `flag:"times" usage:"how often"`
+	}
+	flags := &greetFlags{Name: "world", Times: 1}
+	root := &Command{
+		Name: "hello",
+		Commands: []*Command{{
+			Name:  "greet",
+			Flags: flags,
+			Run: func(ctx context.Context, args []string) error {
+				for i := 0; i < flags.Times; i++ {
+					fmt.Println("hello,", flags.Name)
+				}
+				return nil
+			},
+		}},
+	}
+	root.Execute(context.Background(), []string{"greet", "-name", "gopher", "-times", "2"})
+	// Output:
+	// hello, gopher
+	// hello, gopher
+}
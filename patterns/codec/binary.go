@@ -0,0 +1,576 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+func init() { Register(Binary{}) }
+
+// Binary is a compact self-describing codec in the manner of MessagePack.
+// Every value in the stream is a frame, its length as a uvarint and then
+// its body, so that a decoder can refuse an oversized value before reading
+// it and a value that fails to decode does not desynchronize the stream.
+//
+// A body is a tag byte followed by the tag's payload:
+//
+//	nil, false, true  nothing
+//	int               zig-zag varint
+//	uint              uvarint
+//	float             8 bytes, IEEE 754, big-endian
+//	string, bytes     uvarint length, then the bytes
+//	array             uvarint count, then the elements
+//	map               uvarint count, then key and value for each entry
+//
+// A struct is a map from field name to value. The name can be changed
+// with a `codec:"name"` tag, and `codec:"-"` leaves a field out; fields
+// not found in the target are skipped when decoding. Map entries are
+// sorted by their encoded key, so equal values encode to equal bytes.
+type Binary struct{}
+
+// MaxFrame bounds the size of a value the Binary decoder accepts.
+const MaxFrame = 64 << 20
+
+const (
+	tagNil byte = iota
+	tagFalse
+	tagTrue
+	tagInt
+	tagUint
+	tagFloat
+	tagString
+	tagBytes
+	tagArray
+	tagMap
+)
+
+var tagNames = [...]string{"nil", "false", "true", "int", "uint", "float", "string", "bytes", "array", "map"}
+
+// Name implements Codec.
+func (Binary) Name() string { return "binary" }
+
+// ContentType implements Codec.
+func (Binary) ContentType() string { return "application/x-binary" }
+
+// NewEncoder implements Codec.
+func (Binary) NewEncoder(w io.Writer) Encoder { return &binaryEncoder{w: w} }
+
+// NewDecoder implements Codec.
+func (Binary) NewDecoder(r io.Reader) Decoder { return &binaryDecoder{r: bufio.NewReader(r)} }
+
+type binaryEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (e *binaryEncoder) Encode(v any) error {
+	body, err := appendValue(nil, reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	e.buf = append(binary.AppendUvarint(e.buf[:0], uint64(len(body))), body...)
+	_, err = e.w.Write(e.buf)
+	return err
+}
+
+func appendValue(b []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(b, tagNil), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, tagTrue), nil
+		}
+		return append(b, tagFalse), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(append(b, tagInt), v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(append(b, tagUint), v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return binary.BigEndian.AppendUint64(append(b, tagFloat), math.Float64bits(v.Float())), nil
+	case reflect.String:
+		return appendString(append(b, tagString), v.String()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(b, tagNil), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendString(append(b, tagBytes), string(v.Bytes())), nil
+		}
+		fallthrough
+	case reflect.Array:
+		b = binary.AppendUvarint(append(b, tagArray), uint64(v.Len()))
+		for i := range v.Len() {
+			var err error
+			if b, err = appendValue(b, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return append(b, tagNil), nil
+		}
+		return appendMap(b, v)
+	case reflect.Struct:
+		fields := fieldsOf(v.Type())
+		b = binary.AppendUvarint(append(b, tagMap), uint64(len(fields)))
+		for _, f := range fields {
+			b = appendString(append(b, tagString), f.name)
+			var err error
+			if b, err = appendValue(b, v.Field(f.index)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(b, tagNil), nil
+		}
+		return appendValue(b, v.Elem())
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, v.Type())
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(binary.AppendUvarint(b, uint64(len(s))), s...)
+}
+
+// appendMap encodes the entries of v sorted by their encoded keys.
+func appendMap(b []byte, v reflect.Value) ([]byte, error) {
+	type entry struct{ key, value []byte }
+	entries := make([]entry, 0, v.Len())
+	for it := v.MapRange(); it.Next(); {
+		k, err := appendValue(nil, it.Key())
+		if err != nil {
+			return nil, err
+		}
+		e, err := appendValue(nil, it.Value())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{k, e})
+	}
+	slices.SortFunc(entries, func(x, y entry) int { return bytes.Compare(x.key, y.key) })
+	b = binary.AppendUvarint(append(b, tagMap), uint64(len(entries)))
+	for _, e := range entries {
+		b = append(append(b, e.key...), e.value...)
+	}
+	return b, nil
+}
+
+type field struct {
+	name  string
+	index int
+}
+
+var fieldCache sync.Map // reflect.Type to []field
+
+// fieldsOf returns the encoded fields of struct type t.
+func fieldsOf(t reflect.Type) []field {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.([]field)
+	}
+	var fs []field
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name := f.Tag.Get("codec")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs = append(fs, field{name, i})
+	}
+	fieldCache.Store(t, fs)
+	return fs
+}
+
+type binaryDecoder struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func (d *binaryDecoder) Decode(v any) error {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err // io.EOF between values, io.ErrUnexpectedEOF within one
+	}
+	if n > MaxFrame {
+		return fmt.Errorf("%w: frame of %d bytes", ErrCorrupt, n)
+	}
+	d.buf = slices.Grow(d.buf[:0], int(n))[:n]
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("codec: Decode needs a non-nil pointer, not %T", v)
+	}
+	p := parser{b: d.buf}
+	if err := p.value(rv.Elem()); err != nil {
+		return err
+	}
+	if len(p.b) != 0 {
+		return fmt.Errorf("%w: %d bytes after the value", ErrCorrupt, len(p.b))
+	}
+	return nil
+}
+
+// parser decodes one frame body.
+type parser struct {
+	b []byte
+}
+
+var errTruncated = fmt.Errorf("%w: truncated value", ErrCorrupt)
+
+func (p *parser) tag() (byte, error) {
+	if len(p.b) == 0 {
+		return 0, errTruncated
+	}
+	t := p.b[0]
+	p.b = p.b[1:]
+	if int(t) >= len(tagNames) {
+		return 0, fmt.Errorf("%w: tag %d", ErrCorrupt, t)
+	}
+	return t, nil
+}
+
+func (p *parser) uvarint() (uint64, error) {
+	n, size := binary.Uvarint(p.b)
+	if size <= 0 {
+		return 0, errTruncated
+	}
+	p.b = p.b[size:]
+	return n, nil
+}
+
+func (p *parser) varint() (int64, error) {
+	n, size := binary.Varint(p.b)
+	if size <= 0 {
+		return 0, errTruncated
+	}
+	p.b = p.b[size:]
+	return n, nil
+}
+
+func (p *parser) take(n uint64) ([]byte, error) {
+	if n > uint64(len(p.b)) {
+		return nil, errTruncated
+	}
+	s := p.b[:n]
+	p.b = p.b[n:]
+	return s, nil
+}
+
+// count reads the length of an array or map. Every element takes a byte
+// at least, so a count beyond the bytes left is corrupt, and is caught
+// before anything is allocated for it.
+func (p *parser) count() (int, error) {
+	n, err := p.uvarint()
+	if err == nil && n > uint64(len(p.b)) {
+		err = errTruncated
+	}
+	return int(n), err
+}
+
+func (p *parser) value(v reflect.Value) error {
+	t, err := p.tag()
+	if err != nil {
+		return err
+	}
+	return p.decode(t, v)
+}
+
+func mismatch(t byte, v reflect.Value) error {
+	return fmt.Errorf("%w: %s into %s", ErrType, tagNames[t], v.Type())
+}
+
+// decode decodes the value of tag t into v.
+func (p *parser) decode(t byte, v reflect.Value) error {
+	if t == tagNil {
+		v.SetZero()
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return p.decode(t, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("%w: %s", ErrUnsupported, v.Type())
+		}
+		x, err := p.any(t)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(&x).Elem())
+		return nil
+	case reflect.Bool:
+		if t != tagFalse && t != tagTrue {
+			return mismatch(t, v)
+		}
+		v.SetBool(t == tagTrue)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := p.integer(t, v)
+		if err != nil {
+			return err
+		}
+		if n.neg || n.u <= math.MaxInt64 {
+			if x := int64(n.u); !v.OverflowInt(x) {
+				v.SetInt(x)
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s overflows %s", ErrType, n, v.Type())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := p.integer(t, v)
+		if err != nil {
+			return err
+		}
+		if n.neg || v.OverflowUint(n.u) {
+			return fmt.Errorf("%w: %s overflows %s", ErrType, n, v.Type())
+		}
+		v.SetUint(n.u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if t != tagFloat {
+			return mismatch(t, v)
+		}
+		b, err := p.take(8)
+		if err != nil {
+			return err
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(b))
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("%w: %g overflows %s", ErrType, f, v.Type())
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.String:
+		if t != tagString && t != tagBytes {
+			return mismatch(t, v)
+		}
+		s, err := p.bytes()
+		v.SetString(string(s))
+		return err
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (t == tagBytes || t == tagString) {
+			s, err := p.bytes()
+			v.SetBytes(bytes.Clone(s))
+			return err
+		}
+		if t != tagArray {
+			return mismatch(t, v)
+		}
+		n, err := p.count()
+		if err != nil {
+			return err
+		}
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := range n {
+			if err := p.value(s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Array:
+		if t != tagArray {
+			return mismatch(t, v)
+		}
+		n, err := p.count()
+		if err != nil {
+			return err
+		}
+		if n > v.Len() {
+			return fmt.Errorf("%w: %d elements into %s", ErrType, n, v.Type())
+		}
+		v.SetZero()
+		for i := range n {
+			if err := p.value(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if t != tagMap {
+			return mismatch(t, v)
+		}
+		n, err := p.count()
+		if err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), n))
+		}
+		for range n {
+			k := reflect.New(v.Type().Key()).Elem()
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := p.value(k); err != nil {
+				return err
+			}
+			if err := p.value(e); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, e)
+		}
+		return nil
+	case reflect.Struct:
+		if t != tagMap {
+			return mismatch(t, v)
+		}
+		return p.structure(v)
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupported, v.Type())
+}
+
+// number is a decoded integer: its magnitude, and its sign.
+type number struct {
+	u   uint64
+	neg bool
+}
+
+func (n number) String() string {
+	if n.neg {
+		return fmt.Sprint(int64(n.u))
+	}
+	return fmt.Sprint(n.u)
+}
+
+// integer reads an int or uint, whichever t is, for v.
+func (p *parser) integer(t byte, v reflect.Value) (number, error) {
+	switch t {
+	case tagInt:
+		x, err := p.varint()
+		return number{uint64(x), x < 0}, err
+	case tagUint:
+		x, err := p.uvarint()
+		return number{u: x}, err
+	}
+	return number{}, mismatch(t, v)
+}
+
+func (p *parser) bytes() ([]byte, error) {
+	n, err := p.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	return p.take(n)
+}
+
+// structure decodes a map into the fields of struct v, skipping keys that
+// name no field.
+func (p *parser) structure(v reflect.Value) error {
+	n, err := p.count()
+	if err != nil {
+		return err
+	}
+	fields := fieldsOf(v.Type())
+	for range n {
+		t, err := p.tag()
+		if err != nil {
+			return err
+		}
+		if t != tagString {
+			return fmt.Errorf("%w: %s field name", ErrCorrupt, tagNames[t])
+		}
+		name, err := p.bytes()
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(fields, func(f field) bool { return f.name == string(name) })
+		if i < 0 {
+			_, err = p.next()
+		} else {
+			err = p.value(v.Field(fields[i].index))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// any decodes the value of tag t as nil, bool, int64, uint64, float64,
+// string, []byte, []any or map[string]any, whose keys that are not
+// strings are formatted as JSON's are.
+func (p *parser) any(t byte) (any, error) {
+	switch t {
+	case tagNil:
+		return nil, nil
+	case tagFalse, tagTrue:
+		return t == tagTrue, nil
+	case tagInt:
+		return p.varint()
+	case tagUint:
+		return p.uvarint()
+	case tagFloat:
+		b, err := p.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case tagString:
+		s, err := p.bytes()
+		return string(s), err
+	case tagBytes:
+		s, err := p.bytes()
+		return bytes.Clone(s), err
+	case tagArray:
+		n, err := p.count()
+		if err != nil {
+			return nil, err
+		}
+		s := make([]any, n)
+		for i := range s {
+			if s[i], err = p.next(); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	}
+	n, err := p.count()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any, n)
+	for range n {
+		k, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		if m[key], err = p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// next reads the next value whatever it is, returning it as any does.
+func (p *parser) next() (any, error) {
+	t, err := p.tag()
+	if err != nil {
+		return nil, err
+	}
+	return p.any(t)
+}
@@ -0,0 +1,85 @@
+// Package codec is the strategy pattern applied to serialization: code
+// that stores or sends values is written against a Codec, and the format,
+// JSON, gob or a compact binary one, is picked by name at run time, from a
+// configuration file or a Content-Type header.
+//
+// The codecs live in a patterns/registry Registry. Each registers itself
+// from an init function, and a codec defined elsewhere can join them with
+// Register. They all stream: an Encoder writes a sequence of values to a
+// writer and a Decoder reads them back in order, ending with io.EOF. The
+// conformance test runs the same round trips through every registered
+// codec, so a new one is held to the same rules as the others.
+//
+// The formats are not interchangeable in everything. JSON is text anyone
+// can read, but it turns integer map keys into strings and loses the
+// difference between an int and a float in an interface value. Gob
+// describes its types once per stream, which makes a long stream compact
+// and a single value large, and cannot be read outside Go. Binary, in
+// binary.go, is self-describing like MessagePack, frames every value with
+// its length, and names struct fields by their name, like JSON.
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/crazybber/go-patterns/patterns/registry"
+)
+
+var (
+	// ErrUnsupported is returned for a value of a type a codec cannot
+	// encode, such as a channel or a function.
+	ErrUnsupported = errors.New("codec: unsupported type")
+	// ErrType is returned when decoding a value into a variable of a type
+	// that cannot hold it.
+	ErrType = errors.New("codec: type mismatch")
+	// ErrCorrupt is returned for input that is not in the codec's format.
+	ErrCorrupt = errors.New("codec: corrupt input")
+)
+
+// Encoder writes values to a stream.
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads values from a stream into the variables v points to. It
+// returns io.EOF at the end of the stream.
+type Decoder interface {
+	Decode(v any) error
+}
+
+// Codec is a serialization format.
+type Codec interface {
+	// Name is the name the codec is registered under.
+	Name() string
+	// ContentType is the media type of the encoded stream.
+	ContentType() string
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+var codecs = registry.New[Codec]("codec")
+
+// Register makes c available by its name. It panics if the name is taken.
+func Register(c Codec) { codecs.Register(c.Name(), c) }
+
+// Get returns the codec registered under name.
+func Get(name string) (Codec, error) { return codecs.Get(name) }
+
+// Names returns the names of the registered codecs.
+func Names() []string { return codecs.Names() }
+
+// Marshal encodes v alone with c.
+func Marshal(c Codec, v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a value encoded by Marshal into v.
+func Unmarshal(c Codec, data []byte, v any) error {
+	return c.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,254 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	Street string
+	Zip    *int
+}
+
+type customer struct {
+	ID      uint64
+	Name    string
+	Balance float64
+	Active  bool
+	Tags    []string
+	Photo   []byte
+	Home    *address
+	Orders  map[string]int
+	Scores  [3]int8
+	secret  string
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// conformance is a round trip every codec must pass: decoding into a
+// fresh variable of the same type gives back a value equal to the
+// original. Empty slices and maps are left out, since gob does not tell
+// them from nil ones.
+var conformance = []any{
+	true,
+	int64(-1 << 40),
+	uint8(255),
+	uint64(math.MaxUint64),
+	3.25,
+	"",
+	"héllo, 世界",
+	[]byte{0, 1, 2, 255},
+	[]int{1, -2, 3},
+	map[string]bool{"a": true, "b": false},
+	map[int]string{1: "one", -2: "minus two"},
+	[2]string{"x", "y"},
+	ptr(42),
+	address{Street: "1 Main St", Zip: ptr(12345)},
+	customer{
+		ID: 7, Name: "Ada", Balance: -12.5, Active: true,
+		Tags: []string{"vip"}, Photo: []byte("png"),
+		Home:   &address{Street: "2 Side St"},
+		Orders: map[string]int{"o1": 2, "o2": 1},
+		Scores: [3]int8{-1, 0, 127},
+	},
+	[]customer{{ID: 1, Name: "a"}, {ID: 2, Name: "b", Home: &address{}}},
+}
+
+func TestConformance(t *testing.T) {
+	for _, name := range Names() {
+		c, err := Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(name, func(t *testing.T) {
+			for _, want := range conformance {
+				data, err := Marshal(c, want)
+				if err != nil {
+					t.Errorf("Marshal(%#v): %v", want, err)
+					continue
+				}
+				got := reflect.New(reflect.TypeOf(want))
+				if err := Unmarshal(c, data, got.Interface()); err != nil {
+					t.Errorf("Unmarshal(%#v): %v", want, err)
+					continue
+				}
+				if !reflect.DeepEqual(got.Elem().Interface(), want) {
+					t.Errorf("round trip of %#v gave %#v", want, got.Elem().Interface())
+				}
+			}
+		})
+	}
+}
+
+// Every codec streams: values written one after another come back in
+// order, then io.EOF.
+func TestConformanceStream(t *testing.T) {
+	for _, name := range Names() {
+		c, _ := Get(name)
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := c.NewEncoder(&buf)
+			for i := range 3 {
+				if err := enc.Encode(address{Street: fmt.Sprint(i, " Main St")}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dec := c.NewDecoder(&buf)
+			for i := range 3 {
+				var a address
+				if err := dec.Decode(&a); err != nil || a.Street != fmt.Sprint(i, " Main St") {
+					t.Fatalf("value %d: %+v, %v", i, a, err)
+				}
+			}
+			if err := dec.Decode(new(address)); err != io.EOF {
+				t.Errorf("after the last value: %v", err)
+			}
+		})
+	}
+}
+
+// A stream cut short is an error, never a silently partial value.
+func TestConformanceTruncated(t *testing.T) {
+	for _, name := range Names() {
+		c, _ := Get(name)
+		t.Run(name, func(t *testing.T) {
+			data, _ := Marshal(c, conformance[len(conformance)-2])
+			for _, n := range []int{1, len(data) / 2, len(data) - 2} {
+				var v customer
+				if err := Unmarshal(c, data[:n], &v); err == nil || err == io.EOF {
+					t.Errorf("%d of %d bytes: %v", n, len(data), err)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	if got := fmt.Sprint(Names()); got != "[binary gob json]" {
+		t.Errorf("Names = %s", got)
+	}
+	if _, err := Get("msgpack"); err == nil || !strings.Contains(err.Error(), "registered: binary, gob, json") {
+		t.Errorf("Get(msgpack) = %v", err)
+	}
+}
+
+func TestBinaryEncoding(t *testing.T) {
+	for _, tt := range []struct {
+		v    any
+		want []byte
+	}{
+		{nil, []byte{1, tagNil}},
+		{true, []byte{1, tagTrue}},
+		{-1, []byte{2, tagInt, 1}},
+		{uint(300), []byte{3, tagUint, 0xac, 0x02}},
+		{"hi", []byte{4, tagString, 2, 'h', 'i'}},
+		{[]byte("hi"), []byte{4, tagBytes, 2, 'h', 'i'}},
+		{[]int{1}, []byte{4, tagArray, 1, tagInt, 2}},
+		{map[string]int{"b": 2, "a": 1}, []byte{12, tagMap, 2, tagString, 1, 'a', tagInt, 2, tagString, 1, 'b', tagInt, 4}},
+		{struct {
+			N int `codec:"n"`
+			X int `codec:"-"`
+		}{1, 2}, []byte{7, tagMap, 1, tagString, 1, 'n', tagInt, 2}},
+	} {
+		got, err := Marshal(Binary{}, tt.v)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("Marshal(%#v) = %v, %v, want %v", tt.v, got, err, tt.want)
+		}
+	}
+}
+
+func TestBinaryDecodeIntoAny(t *testing.T) {
+	data, _ := Marshal(Binary{}, customer{ID: 1, Name: "Ada", Tags: []string{"vip"}, Orders: map[string]int{"o1": -2}})
+	var v any
+	if err := Unmarshal(Binary{}, data, &v); err != nil {
+		t.Fatal(err)
+	}
+	m := v.(map[string]any)
+	if m["ID"] != uint64(1) || m["Name"] != "Ada" || m["Home"] != nil ||
+		!reflect.DeepEqual(m["Tags"], []any{"vip"}) || !reflect.DeepEqual(m["Orders"], map[string]any{"o1": int64(-2)}) {
+		t.Errorf("decoded %#v", m)
+	}
+}
+
+// A newer writer's extra fields are skipped, and an older writer's missing
+// ones left alone.
+func TestBinarySchemaEvolution(t *testing.T) {
+	type v2 struct {
+		Street string
+		Extra  map[int][]string
+	}
+	data, _ := Marshal(Binary{}, v2{Street: "1 Main St", Extra: map[int][]string{1: {"x"}}})
+	a := address{Zip: ptr(99)}
+	if err := Unmarshal(Binary{}, data, &a); err != nil || a.Street != "1 Main St" || *a.Zip != 99 {
+		t.Errorf("decoded %+v, %v", a, err)
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	encode := func(v any) []byte {
+		data, err := Marshal(Binary{}, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	for _, tt := range []struct {
+		name string
+		data []byte
+		into any
+		want error
+	}{
+		{"wrong type", encode("x"), new(int), ErrType},
+		{"int overflow", encode(300), new(int8), ErrType},
+		{"negative into uint", encode(-1), new(uint), ErrType},
+		{"uint overflow", encode(uint64(math.MaxUint64)), new(int64), ErrType},
+		{"float overflow", encode(1e300), new(float32), ErrType},
+		{"array too long", encode([]int{1, 2, 3}), new([2]int), ErrType},
+		{"unknown tag", []byte{1, 200}, new(any), ErrCorrupt},
+		{"huge count", []byte{3, tagArray, 0xff, 0x7f}, new([]int), ErrCorrupt},
+		{"trailing bytes", []byte{2, tagTrue, tagTrue}, new(bool), ErrCorrupt},
+		{"oversized frame", binary.AppendUvarint(nil, MaxFrame+1), new(any), ErrCorrupt},
+		{"cut frame", []byte{5, tagString, 3}, new(string), io.ErrUnexpectedEOF},
+		{"cut value", []byte{2, tagString, 3}, new(string), ErrCorrupt},
+	} {
+		if err := Unmarshal(Binary{}, tt.data, tt.into); !errors.Is(err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, err := Marshal(Binary{}, make(chan int)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Marshal(chan) = %v", err)
+	}
+	if err := Unmarshal(Binary{}, encode(1), 0); err == nil {
+		t.Error("Unmarshal into a non-pointer")
+	}
+}
+
+// The format is picked by name, as from a config file; the code that
+// writes and reads is the same for all of them.
+func Example() {
+	type event struct {
+		Kind string
+		At   int64
+	}
+	for _, name := range []string{"json", "binary"} {
+		c, err := Get(name)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		data, _ := Marshal(c, event{"login", 1700000000})
+		var e event
+		Unmarshal(c, data, &e)
+		fmt.Printf("%-6s %-24s %2d bytes %+v\n", name, c.ContentType(), len(data), e)
+	}
+	// Output:
+	// json   application/json         33 bytes {Kind:login At:1700000000}
+	// binary application/x-binary     26 bytes {Kind:login At:1700000000}
+}
@@ -0,0 +1,24 @@
+package codec
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+func init() { Register(Gob{}) }
+
+// Gob is the codec of encoding/gob. A value of a concrete type stored in
+// an interface must be registered with gob.Register first.
+type Gob struct{}
+
+// Name implements Codec.
+func (Gob) Name() string { return "gob" }
+
+// ContentType implements Codec.
+func (Gob) ContentType() string { return "application/x-gob" }
+
+// NewEncoder implements Codec.
+func (Gob) NewEncoder(w io.Writer) Encoder { return gob.NewEncoder(w) }
+
+// NewDecoder implements Codec.
+func (Gob) NewDecoder(r io.Reader) Decoder { return gob.NewDecoder(r) }
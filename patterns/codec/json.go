@@ -0,0 +1,23 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+func init() { Register(JSON{}) }
+
+// JSON is the codec of encoding/json, a value per line.
+type JSON struct{}
+
+// Name implements Codec.
+func (JSON) Name() string { return "json" }
+
+// ContentType implements Codec.
+func (JSON) ContentType() string { return "application/json" }
+
+// NewEncoder implements Codec.
+func (JSON) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+
+// NewDecoder implements Codec.
+func (JSON) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
@@ -0,0 +1,304 @@
+// Package diff computes structural differences between values and applies
+// them as patches.
+//
+// Values are compared in their JSON form, so structs, maps, slices and
+// scalars all become one tree of objects, arrays and leaves, and a
+// difference is addressed by a JSON Pointer (RFC 6901) such as
+// "/todos/2/title". Fields that do not marshal, unexported ones for
+// instance, are not compared.
+//
+// A Patch records the old value next to the new one for every change. That
+// lets Apply refuse a patch whose base does not match, and makes every
+// patch invertible: Invert returns the patch that undoes it.
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrConflict is returned by Apply when the value does not hold what
+	// the patch expects to replace or remove.
+	ErrConflict = errors.New("diff: patch does not match value")
+	// ErrPath is returned by Apply for a path that does not exist.
+	ErrPath = errors.New("diff: invalid path")
+)
+
+// Op is the kind of a Change.
+type Op string
+
+const (
+	Add    Op = "add"
+	Remove Op = "remove"
+	Change Op = "change"
+)
+
+// Edit is one difference: the value at Path was added, removed or changed.
+type Edit struct {
+	Op   Op     `json:"op"`
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+func (e Edit) String() string {
+	switch e.Op {
+	case Add:
+		return fmt.Sprintf("+ %s: %s", e.Path, show(e.New))
+	case Remove:
+		return fmt.Sprintf("- %s: %s", e.Path, show(e.Old))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", e.Path, show(e.Old), show(e.New))
+}
+
+func show(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// Patch is a sequence of edits, applied in order.
+type Patch []Edit
+
+// String returns the edits, one per line.
+func (p Patch) String() string {
+	var b strings.Builder
+	for _, e := range p {
+		b.WriteString(e.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Invert returns the patch that undoes p.
+func (p Patch) Invert() Patch {
+	inv := make(Patch, len(p))
+	for i, e := range p {
+		switch e.Op {
+		case Add:
+			e.Op = Remove
+		case Remove:
+			e.Op = Add
+		}
+		e.Old, e.New = e.New, e.Old
+		inv[len(p)-1-i] = e
+	}
+	return inv
+}
+
+// Diff returns the patch that turns a into b.
+func Diff(a, b any) (Patch, error) {
+	ta, err := tree(a)
+	if err != nil {
+		return nil, err
+	}
+	tb, err := tree(b)
+	if err != nil {
+		return nil, err
+	}
+	var p Patch
+	walk(&p, "", ta, tb)
+	return p, nil
+}
+
+// tree converts v to its JSON tree. Numbers are kept as json.Number, so
+// they compare exactly.
+func tree(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var t any
+	err = d.Decode(&t)
+	return t, err
+}
+
+func walk(p *Patch, path string, a, b any) {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			walkObject(p, path, a, b)
+			return
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			walkArray(p, path, a, b)
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*p = append(*p, Edit{Op: Change, Path: path, Old: a, New: b})
+	}
+}
+
+func walkObject(p *Patch, path string, a, b map[string]any) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		va, ina := a[k]
+		vb, inb := b[k]
+		sub := path + "/" + escape(k)
+		switch {
+		case !inb:
+			*p = append(*p, Edit{Op: Remove, Path: sub, Old: va})
+		case !ina:
+			*p = append(*p, Edit{Op: Add, Path: sub, New: vb})
+		default:
+			walk(p, sub, va, vb)
+		}
+	}
+}
+
+// walkArray compares elements by index. Surplus elements are removed from
+// the end backwards, missing ones appended, so that every edit's index is
+// valid when it is applied.
+func walkArray(p *Patch, path string, a, b []any) {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		walk(p, path+"/"+strconv.Itoa(i), a[i], b[i])
+	}
+	for i := len(a) - 1; i >= n; i-- {
+		*p = append(*p, Edit{Op: Remove, Path: path + "/" + strconv.Itoa(i), Old: a[i]})
+	}
+	for i := n; i < len(b); i++ {
+		*p = append(*p, Edit{Op: Add, Path: path + "/" + strconv.Itoa(i), New: b[i]})
+	}
+}
+
+var escaper = strings.NewReplacer("~", "~0", "/", "~1")
+var unescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+func escape(key string) string { return escaper.Replace(key) }
+
+// Apply returns v with p applied. v is not modified. The result is built
+// by unmarshaling the patched tree into a new T.
+func Apply[T any](v T, p Patch) (T, error) {
+	var zero T
+	t, err := tree(v)
+	if err != nil {
+		return zero, err
+	}
+	for _, e := range p {
+		if t, err = apply(t, e); err != nil {
+			return zero, fmt.Errorf("%s: %w", e.Path, err)
+		}
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return zero, err
+	}
+	var out T
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
+
+func apply(root any, e Edit) (any, error) {
+	// Copy the new value, so later edits below it leave the patch alone.
+	val, err := tree(e.New)
+	if err != nil {
+		return nil, err
+	}
+	e.New = val
+	if e.Path == "" {
+		if e.Op != Change {
+			return nil, ErrPath
+		}
+		if !equal(root, e.Old) {
+			return nil, ErrConflict
+		}
+		return e.New, nil
+	}
+	if !strings.HasPrefix(e.Path, "/") {
+		return nil, ErrPath
+	}
+	tokens := strings.Split(e.Path[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = unescaper.Replace(t)
+	}
+	return edit(root, tokens, e)
+}
+
+// edit applies e at the path tokens below node and returns the new node.
+func edit(node any, tokens []string, e Edit) (any, error) {
+	key, last := tokens[0], len(tokens) == 1
+	switch n := node.(type) {
+	case map[string]any:
+		cur, ok := n[key]
+		if !last {
+			if !ok {
+				return nil, ErrPath
+			}
+			sub, err := edit(cur, tokens[1:], e)
+			n[key] = sub
+			return n, err
+		}
+		switch e.Op {
+		case Add:
+			if ok {
+				return nil, ErrConflict
+			}
+			n[key] = e.New
+		case Remove:
+			if !ok || !equal(cur, e.Old) {
+				return nil, ErrConflict
+			}
+			delete(n, key)
+		default:
+			if !ok || !equal(cur, e.Old) {
+				return nil, ErrConflict
+			}
+			n[key] = e.New
+		}
+		return n, nil
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i > len(n) || (i == len(n) && !(last && e.Op == Add)) {
+			return nil, ErrPath
+		}
+		if !last {
+			sub, err := edit(n[i], tokens[1:], e)
+			n[i] = sub
+			return n, err
+		}
+		switch e.Op {
+		case Add:
+			return slices.Insert(n, i, e.New), nil
+		case Remove:
+			if !equal(n[i], e.Old) {
+				return nil, ErrConflict
+			}
+			return slices.Delete(n, i, i+1), nil
+		default:
+			if !equal(n[i], e.Old) {
+				return nil, ErrConflict
+			}
+			n[i] = e.New
+			return n, nil
+		}
+	}
+	return nil, ErrPath
+}
+
+// equal compares two tree values. Patches read back from JSON hold
+// float64 where Diff produced json.Number, so both are normalized.
+func equal(a, b any) bool {
+	ta, err1 := tree(a)
+	tb, err2 := tree(b)
+	return err1 == nil && err2 == nil && reflect.DeepEqual(ta, tb)
+}
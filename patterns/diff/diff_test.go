@@ -0,0 +1,213 @@
+package diff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type Todo struct {
+	Title string   `json:"title"`
+	Done  bool     `json:"done"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+type Board struct {
+	Name  string         `json:"name"`
+	Todos []Todo         `json:"todos"`
+	Meta  map[string]int `json:"meta"`
+}
+
+func TestDiffStructs(t *testing.T) {
+	a := Board{Name: "home", Todos: []Todo{{Title: "milk"}, {Title: "bread"}}, Meta: map[string]int{"v": 1}}
+	b := Board{Name: "home", Todos: []Todo{{Title: "milk", Done: true}}, Meta: map[string]int{"v": 2, "a/b": 0}}
+	p, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `+ /meta/a~1b: 0
+~ /meta/v: 1 -> 2
+~ /todos/0/done: false -> true
+- /todos/1: {"done":false,"title":"bread"}
+`
+	if p.String() != want {
+		t.Errorf("patch:\n%s\nwant:\n%s", p, want)
+	}
+	got, err := Apply(a, p)
+	if err != nil || !reflect.DeepEqual(got, b) {
+		t.Errorf("Apply = %+v, %v", got, err)
+	}
+	back, err := Apply(b, p.Invert())
+	if err != nil || !reflect.DeepEqual(back, a) {
+		t.Errorf("Apply(Invert) = %+v, %v", back, err)
+	}
+}
+
+func TestEqualValues(t *testing.T) {
+	p, _ := Diff(map[string]int{"a": 1}, map[string]int{"a": 1})
+	if len(p) != 0 {
+		t.Errorf("patch between equal values: %v", p)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	a := Todo{Title: "milk"}
+	p, _ := Diff(a, Todo{Title: "oat milk"})
+	if _, err := Apply(Todo{Title: "soy milk"}, p); !errors.Is(err, ErrConflict) {
+		t.Errorf("Apply to another base = %v", err)
+	}
+	p = Patch{{Op: Change, Path: "/nope/x", New: 1}}
+	if _, err := Apply(a, p); !errors.Is(err, ErrPath) {
+		t.Errorf("Apply to a missing path = %v", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	a := Board{Name: "a", Todos: []Todo{{Title: "x", Tags: []string{"t"}}}}
+	b := Board{Name: "b", Meta: map[string]int{"n": 7}}
+	p, _ := Diff(a, b)
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var q Patch
+	if err := json.Unmarshal(data, &q); err != nil {
+		t.Fatal(err)
+	}
+	// Numbers read back as float64; Apply does not mind.
+	got, err := Apply(a, q)
+	if err != nil || !reflect.DeepEqual(got, b) {
+		t.Errorf("Apply of decoded patch = %+v, %v\n%s", got, err, data)
+	}
+}
+
+// randomValue returns a random JSON tree.
+func randomValue(rng *rand.Rand, depth int) any {
+	r := rng.Intn(6)
+	if depth == 0 {
+		r = rng.Intn(4)
+	}
+	switch r {
+	case 0:
+		return nil
+	case 1:
+		return rng.Intn(2) == 0
+	case 2:
+		return rng.Intn(5)
+	case 3:
+		return []string{"", "a", "b/c", "~d"}[rng.Intn(4)]
+	case 4:
+		arr := make([]any, rng.Intn(4))
+		for i := range arr {
+			arr[i] = randomValue(rng, depth-1)
+		}
+		return arr
+	default:
+		obj := map[string]any{}
+		for i := rng.Intn(4); i > 0; i-- {
+			obj[[]string{"a", "b", "x/y", "m~n"}[rng.Intn(4)]] = randomValue(rng, depth-1)
+		}
+		return obj
+	}
+}
+
+// mutate returns a copy of v with a few random changes, so pairs share
+// most of their structure as they do in practice.
+func mutate(rng *rand.Rand, v any, depth int) any {
+	if rng.Intn(8) == 0 {
+		return randomValue(rng, depth)
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		out := map[string]any{}
+		for k, x := range v {
+			if rng.Intn(6) != 0 {
+				out[k] = mutate(rng, x, depth-1)
+			}
+		}
+		if rng.Intn(3) == 0 {
+			out["new"+strconv.Itoa(rng.Intn(3))] = randomValue(rng, depth-1)
+		}
+		return out
+	case []any:
+		var out []any
+		for _, x := range v {
+			if rng.Intn(6) != 0 {
+				out = append(out, mutate(rng, x, depth-1))
+			}
+		}
+		if rng.Intn(3) == 0 {
+			out = append(out, randomValue(rng, depth-1))
+		}
+		return out
+	}
+	return v
+}
+
+func same(t *testing.T, a, b any) bool {
+	t.Helper()
+	ta, err := tree(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb, err := tree(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(ta, tb)
+}
+
+// TestRoundTrip checks on random pairs that the patch turns a into b, its
+// inverse turns b back into a, and both survive a trip through JSON.
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		a := randomValue(rng, 4)
+		b := mutate(rng, a, 4)
+		if i%4 == 0 {
+			b = randomValue(rng, 4)
+		}
+		p, err := Diff(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(p)
+		var decoded Patch
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range []Patch{p, decoded} {
+			got, err := Apply(a, q)
+			if err != nil || !same(t, got, b) {
+				t.Fatalf("Apply(%s, %s) = %s, %v; want %s", show(a), q, show(got), err, show(b))
+			}
+			back, err := Apply(b, q.Invert())
+			if err != nil || !same(t, back, a) {
+				t.Fatalf("Apply(%s, inverse of %s) = %s, %v", show(b), q, show(back), err)
+			}
+		}
+	}
+}
+
+func Example() {
+	before := Todo{Title: "milk", Tags: []string{"shop"}}
+	after := Todo{Title: "oat milk", Done: true, Tags: []string{"shop", "today"}}
+
+	p, _ := Diff(before, after)
+	fmt.Print(p)
+	out, _ := json.Marshal(p[:1])
+	fmt.Println(string(out))
+
+	undone, _ := Apply(after, p.Invert())
+	fmt.Printf("%+v\n", undone)
+	// Output:
+	// ~ /done: false -> true
+	// + /tags/1: "today"
+	// ~ /title: "milk" -> "oat milk"
+	// [{"op":"change","path":"/done","old":false,"new":true}]
+	// {Title:milk Done:false Tags:[shop]}
+}
@@ -0,0 +1,279 @@
+// Package eventbus is a typed publish/subscribe bus that remembers its
+// recent past.
+//
+// Like the observer bus in behavioral/observer/eventbus it hands typed
+// events to subscribed handlers. It adds what a late subscriber needs to
+// catch up: the bus keeps a bounded history
+// of the last events, and a subscriber may ask for the last N of them to be
+// replayed before the live ones. Subscribers may also pass a predicate, so
+// they only see the events they care about.
+//
+// Every event gets a sequence number when it is published. Each subscriber
+// receives its events on its own goroutine, in sequence order, replayed
+// events first, without gaps and without duplicates: an event published
+// while Subscribe runs is either in the replay or among the live events,
+// never in both and never in neither. Subscriber queues are unbounded, so a
+// slow handler only delays itself, at the cost of memory.
+package eventbus
+
+import (
+	"sync"
+)
+
+// Event is a published value with its place in the bus's history.
+type Event[T any] struct {
+	// Seq numbers the events of a bus from 1 without gaps.
+	Seq   uint64
+	Value T
+}
+
+// Options configures a Bus.
+type Options struct {
+	// History is the number of past events the bus keeps for replay. Zero
+	// keeps none.
+	History int
+}
+
+// Bus delivers events of type T to subscribers and keeps the last of them.
+type Bus[T any] struct {
+	mu sync.Mutex
+	// history is a ring of the last len(history) events; next is where the
+	// following one goes and count how many slots are used.
+	history []Event[T]
+	next    int
+	count   int
+	seq     uint64
+	subs    []*Subscription[T]
+	closed  bool
+	// workers counts the goroutines of the subscribers.
+	workers sync.WaitGroup
+}
+
+// New returns an empty Bus.
+func New[T any](opts Options) *Bus[T] {
+	return &Bus[T]{history: make([]Event[T], max(opts.History, 0))}
+}
+
+// SubscribeOptions configures one subscription.
+type SubscribeOptions[T any] struct {
+	// Replay is the number of events from the history delivered before the
+	// live ones. They are the last Replay events that pass Filter, oldest
+	// first. A negative Replay replays the whole history.
+	Replay int
+	// Filter, when set, selects the events, replayed and live, the handler
+	// is called with. It runs inside Publish and must not call the bus.
+	Filter func(T) bool
+}
+
+// Subscription is the registration of one handler.
+type Subscription[T any] struct {
+	bus     *Bus[T]
+	handler func(Event[T])
+	filter  func(T) bool
+
+	mu      sync.Mutex
+	pending []Event[T]
+	// ready has room for one wake-up of the worker.
+	ready chan struct{}
+	// draining is set by Close: the worker returns once pending is empty.
+	draining bool
+	quit     chan struct{}
+	once     sync.Once
+}
+
+// Subscribe registers handler for the replayed events and every matching
+// event published from now on. After Close it returns a subscription that
+// is already done.
+func (b *Bus[T]) Subscribe(handler func(Event[T]), opts SubscribeOptions[T]) *Subscription[T] {
+	s := &Subscription[T]{
+		bus:     b,
+		handler: handler,
+		filter:  opts.Filter,
+		ready:   make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.quit)
+		return s
+	}
+	// Taking the replay and joining the subscribers under one lock is what
+	// keeps Publish from slipping an event in between.
+	s.pending = b.replay(opts.Replay, opts.Filter)
+	if len(s.pending) > 0 {
+		s.ready <- struct{}{}
+	}
+	b.subs = append(b.subs, s)
+	b.workers.Add(1)
+	go s.work()
+	return s
+}
+
+// replay returns the last n events of the history that pass filter, oldest
+// first. The caller holds b.mu.
+func (b *Bus[T]) replay(n int, filter func(T) bool) []Event[T] {
+	if n == 0 {
+		return nil
+	}
+	var out []Event[T]
+	// Walk backwards from the newest event so that n bounds the work.
+	for i := 0; i < b.count && (n < 0 || len(out) < n); i++ {
+		ev := b.history[(b.next-1-i+2*len(b.history))%len(b.history)]
+		if filter == nil || filter(ev.Value) {
+			out = append(out, ev)
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+func (s *Subscription[T]) work() {
+	defer s.bus.workers.Done()
+	for {
+		select {
+		case <-s.ready:
+		case <-s.quit:
+			return
+		}
+		for {
+			s.mu.Lock()
+			if len(s.pending) == 0 {
+				done := s.draining
+				s.mu.Unlock()
+				if done {
+					return
+				}
+				break
+			}
+			ev := s.pending[0]
+			s.pending[0] = Event[T]{}
+			s.pending = s.pending[1:]
+			s.mu.Unlock()
+			// Unsubscribe may have come in while the lock was free.
+			if !s.active() {
+				return
+			}
+			s.handler(ev)
+		}
+	}
+}
+
+func (s *Subscription[T]) push(ev Event[T]) {
+	s.mu.Lock()
+	s.pending = append(s.pending, ev)
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *Subscription[T]) wake() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+		// The worker has a wake-up waiting already.
+	}
+}
+
+func (s *Subscription[T]) active() bool {
+	select {
+	case <-s.quit:
+		return false
+	default:
+		return true
+	}
+}
+
+// Unsubscribe removes the subscription. It may be called more than once,
+// and from any goroutine, including the handler's. A handler call already
+// running is not waited for; events still queued are discarded.
+func (s *Subscription[T]) Unsubscribe() {
+	s.once.Do(func() {
+		b := s.bus
+		b.mu.Lock()
+		for i, other := range b.subs {
+			if other == s {
+				b.subs = append(b.subs[:i], b.subs[i+1:]...)
+				break
+			}
+		}
+		b.mu.Unlock()
+		close(s.quit)
+		s.mu.Lock()
+		s.pending = nil
+		s.mu.Unlock()
+	})
+}
+
+// Done is closed once the subscription has ended, by Unsubscribe or by
+// Close.
+func (s *Subscription[T]) Done() <-chan struct{} { return s.quit }
+
+// Pending returns the number of events queued for the handler.
+func (s *Subscription[T]) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Publish records v in the history and queues it for every subscriber
+// whose filter accepts it. It never waits for a handler and returns the
+// event it published. After Close it returns the zero Event.
+func (b *Bus[T]) Publish(v T) Event[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return Event[T]{}
+	}
+	b.seq++
+	ev := Event[T]{Seq: b.seq, Value: v}
+	if len(b.history) > 0 {
+		b.history[b.next] = ev
+		b.next = (b.next + 1) % len(b.history)
+		b.count = min(b.count+1, len(b.history))
+	}
+	// Pushing under b.mu orders the queues of all subscribers the same way
+	// as the history.
+	for _, s := range b.subs {
+		if s.filter == nil || s.filter(v) {
+			s.push(ev)
+		}
+	}
+	return ev
+}
+
+// History returns the events the bus remembers, oldest first.
+func (b *Bus[T]) History() []Event[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.replay(-1, nil)
+}
+
+// Len returns the number of subscribers.
+func (b *Bus[T]) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close stops the bus from taking events and subscribers, lets every
+// subscriber work through the events queued for it and waits for the
+// handlers to return. It must not be called from a handler.
+func (b *Bus[T]) Close() {
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+	for _, s := range subs {
+		s.mu.Lock()
+		s.draining = true
+		s.mu.Unlock()
+		s.wake()
+	}
+	b.workers.Wait()
+	for _, s := range subs {
+		s.once.Do(func() { close(s.quit) })
+	}
+}
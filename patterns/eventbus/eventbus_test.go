@@ -0,0 +1,196 @@
+package eventbus
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+// recorder collects the events one handler is called with.
+type recorder struct {
+	mu  sync.Mutex
+	evs []Event[int]
+}
+
+func (r *recorder) handle(ev Event[int]) {
+	r.mu.Lock()
+	r.evs = append(r.evs, ev)
+	r.mu.Unlock()
+}
+
+func (r *recorder) values() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]int, len(r.evs))
+	for i, ev := range r.evs {
+		out[i] = ev.Value
+	}
+	return out
+}
+
+func (r *recorder) seqs() []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]uint64, len(r.evs))
+	for i, ev := range r.evs {
+		out[i] = ev.Seq
+	}
+	return out
+}
+
+func publish(b *Bus[int], from, to int) {
+	for n := from; n <= to; n++ {
+		b.Publish(n)
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	b := New[int](Options{History: 3})
+	publish(b, 1, 5)
+	var got []int
+	for _, ev := range b.History() {
+		got = append(got, ev.Value)
+	}
+	if want := []int{3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("history = %v, want %v", got, want)
+	}
+	if ev := b.Publish(6); ev.Seq != 6 {
+		t.Errorf("seq of sixth event = %d", ev.Seq)
+	}
+	b.Close()
+}
+
+func TestReplayThenLive(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		replay int
+		want   []int
+	}{
+		{"none", 0, []int{6, 7}},
+		{"last two", 2, []int{4, 5, 6, 7}},
+		{"more than kept", 10, []int{2, 3, 4, 5, 6, 7}},
+		{"all", -1, []int{2, 3, 4, 5, 6, 7}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := New[int](Options{History: 4})
+			publish(b, 1, 5)
+			var r recorder
+			b.Subscribe(r.handle, SubscribeOptions[int]{Replay: tc.replay})
+			publish(b, 6, 7)
+			b.Close()
+			if got := r.values(); !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterAppliesToReplayAndLive(t *testing.T) {
+	b := New[int](Options{History: 10})
+	publish(b, 1, 10)
+	var r recorder
+	even := func(n int) bool { return n%2 == 0 }
+	b.Subscribe(r.handle, SubscribeOptions[int]{Replay: 2, Filter: even})
+	publish(b, 11, 14)
+	b.Close()
+	// The replay is the last two even events, not the even ones among the
+	// last two.
+	if got, want := r.values(), []int{8, 10, 12, 14}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestReplayHasNoGapsUnderConcurrentPublish subscribes while events are
+// being published and checks that every subscriber sees one contiguous run
+// of sequence numbers that ends with the last event.
+func TestReplayHasNoGapsUnderConcurrentPublish(t *testing.T) {
+	const events = 2000
+	b := New[int](Options{History: 64})
+	recs := make([]*recorder, 50)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		publish(b, 1, events)
+	}()
+	for i := range recs {
+		recs[i] = &recorder{}
+		b.Subscribe(recs[i].handle, SubscribeOptions[int]{Replay: 16})
+	}
+	wg.Wait()
+	b.Close()
+	for i, r := range recs {
+		seqs := r.seqs()
+		if len(seqs) == 0 || seqs[len(seqs)-1] != events {
+			t.Fatalf("subscriber %d did not get the last event: %v", i, seqs)
+		}
+		for j := 1; j < len(seqs); j++ {
+			if seqs[j] != seqs[j-1]+1 {
+				t.Fatalf("subscriber %d got %d after %d", i, seqs[j], seqs[j-1])
+			}
+		}
+	}
+}
+
+func TestSlowSubscriberDoesNotBlockPublish(t *testing.T) {
+	b := New[int](Options{})
+	release := make(chan struct{})
+	var slow, fast recorder
+	b.Subscribe(func(ev Event[int]) {
+		<-release
+		slow.handle(ev)
+	}, SubscribeOptions[int]{})
+	b.Subscribe(fast.handle, SubscribeOptions[int]{})
+	publish(b, 1, 100)
+	close(release)
+	b.Close()
+	want := make([]int, 100)
+	for i := range want {
+		want[i] = i + 1
+	}
+	for name, r := range map[string]*recorder{"slow": &slow, "fast": &fast} {
+		if got := r.values(); !slices.Equal(got, want) {
+			t.Errorf("%s got %v", name, got)
+		}
+	}
+}
+
+func TestUnsubscribeFromHandler(t *testing.T) {
+	b := New[int](Options{})
+	var r recorder
+	var s *Subscription[int]
+	got3 := make(chan struct{})
+	s = b.Subscribe(func(ev Event[int]) {
+		r.handle(ev)
+		if ev.Value == 3 {
+			s.Unsubscribe()
+			close(got3)
+		}
+	}, SubscribeOptions[int]{})
+	publish(b, 1, 3)
+	<-got3
+	publish(b, 4, 6)
+	<-s.Done()
+	if b.Len() != 0 {
+		t.Errorf("Len = %d after Unsubscribe", b.Len())
+	}
+	b.Close()
+	if got, want := r.values(), []int{1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAfterClose(t *testing.T) {
+	b := New[int](Options{History: 2})
+	b.Publish(1)
+	b.Close()
+	if ev := b.Publish(2); ev.Seq != 0 {
+		t.Errorf("Publish after Close returned %+v", ev)
+	}
+	s := b.Subscribe(func(Event[int]) { t.Error("handler called after Close") }, SubscribeOptions[int]{Replay: -1})
+	select {
+	case <-s.Done():
+	default:
+		t.Error("subscription after Close is not done")
+	}
+}
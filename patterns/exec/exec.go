@@ -0,0 +1,192 @@
+// Package exec runs external commands robustly on top of os/exec.
+//
+// A Cmd is stopped politely when its context is done: it gets SIGTERM, and
+// SIGKILL only if it has not exited after a grace period. The signals go to
+// the whole process group, so children the command started do not outlive
+// it. Output is streamed line by line to callbacks while the command runs
+// instead of being buffered until it exits. The process is always waited
+// for, whatever happens, so it never lingers as a zombie. Supervise restarts
+// a command that crashes.
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	osexec "os/exec"
+	"sync"
+	"time"
+)
+
+// ErrStarted is returned when a Cmd is run twice.
+var ErrStarted = errors.New("exec: command already started")
+
+// Cmd is an external command.
+type Cmd struct {
+	Path string
+	Args []string
+	// Env is the environment of the command; nil means the environment of
+	// the current process.
+	Env []string
+	Dir string
+
+	// Stdout and Stderr receive the output one line at a time, without the
+	// line ending. They are called from different goroutines.
+	Stdout func(line string)
+	Stderr func(line string)
+
+	// GracePeriod is how long the command gets between SIGTERM and
+	// SIGKILL. It defaults to 5s.
+	GracePeriod time.Duration
+
+	mu      sync.Mutex
+	started bool
+	pid     int
+}
+
+// Command returns a Cmd running name with args.
+func Command(name string, args ...string) *Cmd {
+	return &Cmd{Path: name, Args: args}
+}
+
+// Pid returns the process id of the running command, or 0.
+func (c *Cmd) Pid() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pid
+}
+
+// clone returns a copy of c that has not been started, for restarts.
+func (c *Cmd) clone() *Cmd {
+	return &Cmd{
+		Path: c.Path, Args: c.Args, Env: c.Env, Dir: c.Dir,
+		Stdout: c.Stdout, Stderr: c.Stderr, GracePeriod: c.GracePeriod,
+	}
+}
+
+// Run starts the command and waits for it to exit. When ctx is done first
+// the command is stopped and the returned error wraps ctx.Err() as well as
+// the exit status. A command that fails wraps an *os/exec.ExitError.
+func (c *Cmd) Run(ctx context.Context) error {
+	c.mu.Lock()
+	if c.started {
+		c.mu.Unlock()
+		return ErrStarted
+	}
+	c.started = true
+	c.mu.Unlock()
+
+	grace := c.GracePeriod
+	if grace <= 0 {
+		grace = 5 * time.Second
+	}
+
+	cmd := osexec.Command(c.Path, c.Args...)
+	cmd.Env, cmd.Dir = c.Env, c.Dir
+	stdout, stderr := newLineWriter(c.Stdout), newLineWriter(c.Stderr)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	setGroup(cmd)
+	// A grandchild holding the output open must not keep Wait from
+	// returning once the command itself is gone.
+	cmd.WaitDelay = grace
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("exec: %s: %w", c.Path, err)
+	}
+	c.mu.Lock()
+	c.pid = cmd.Process.Pid
+	c.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = stop(cmd, done, grace)
+	}
+	stdout.flush()
+	stderr.flush()
+
+	c.mu.Lock()
+	c.pid = 0
+	c.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("exec: %s stopped: %w: %w", c.Path, ctx.Err(), orExited(err))
+	}
+	if err != nil {
+		return fmt.Errorf("exec: %s: %w", c.Path, err)
+	}
+	return nil
+}
+
+var errExited = errors.New("exited")
+
+func orExited(err error) error {
+	if err == nil {
+		return errExited
+	}
+	return err
+}
+
+// stop terminates the process group and escalates to SIGKILL after grace.
+// It always waits for the process.
+func stop(cmd *osexec.Cmd, done <-chan error, grace time.Duration) error {
+	terminate(cmd)
+	t := time.NewTimer(grace)
+	defer t.Stop()
+	select {
+	case err := <-done:
+		// Children that ignore SIGTERM go down with the leader.
+		kill(cmd)
+		return err
+	case <-t.C:
+		kill(cmd)
+		return <-done
+	}
+}
+
+// lineWriter calls fn for every complete line written to it.
+type lineWriter struct {
+	mu  sync.Mutex
+	fn  func(string)
+	buf []byte
+}
+
+func newLineWriter(fn func(string)) *lineWriter {
+	return &lineWriter{fn: fn}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	if w.fn == nil {
+		return len(p), nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush passes on a last line that had no line ending.
+func (w *lineWriter) flush() {
+	if w.fn == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.fn(string(w.buf))
+		w.buf = nil
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package exec
+
+import osexec "os/exec"
+
+// There are no process groups or SIGTERM; the command is killed outright.
+
+func setGroup(*osexec.Cmd) {}
+
+func terminate(cmd *osexec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func kill(cmd *osexec.Cmd) {
+	cmd.Process.Kill()
+}
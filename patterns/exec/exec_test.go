@@ -0,0 +1,261 @@
+//go:build unix
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+// envHelper selects what the test binary does when a test runs it.
+const envHelper = "EXEC_TEST_HELPER"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(envHelper); mode != "" {
+		os.Exit(helper(mode))
+	}
+	os.Exit(m.Run())
+}
+
+func helper(mode string) int {
+	switch mode {
+	case "lines":
+		fmt.Print("one\ntwo\r\nthree")
+		fmt.Fprintln(os.Stderr, "oops")
+	case "term":
+		term := make(chan os.Signal, 1)
+		signal.Notify(term, syscall.SIGTERM)
+		fmt.Println("ready")
+		<-term
+		fmt.Println("bye")
+	case "stubborn":
+		signal.Ignore(syscall.SIGTERM)
+		fmt.Println("ready")
+		time.Sleep(time.Minute)
+	case "parent":
+		child := osexec.Command(os.Args[0])
+		child.Env = append(os.Environ(), envHelper+"=stubborn")
+		if err := child.Start(); err != nil {
+			return 1
+		}
+		fmt.Println(child.Process.Pid)
+		child.Wait()
+	case "flaky":
+		// Crashes until it has been run three times.
+		path := os.Getenv("EXEC_TEST_COUNTER")
+		b, _ := os.ReadFile(path)
+		n, _ := strconv.Atoi(string(b))
+		n++
+		os.WriteFile(path, []byte(strconv.Itoa(n)), 0o644)
+		if n < 3 {
+			return 3
+		}
+	case "crash":
+		return 3
+	}
+	return 0
+}
+
+// helperCmd returns a Cmd running the test binary in mode.
+func helperCmd(mode string) *Cmd {
+	c := Command(os.Args[0])
+	c.Env = append(os.Environ(), envHelper+"="+mode)
+	return c
+}
+
+// lines collects output lines and signals the first one.
+type lines struct {
+	mu    sync.Mutex
+	got   []string
+	first chan string
+}
+
+func newLines() *lines { return &lines{first: make(chan string, 1)} }
+
+func (l *lines) add(s string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.got) == 0 {
+		l.first <- s
+	}
+	l.got = append(l.got, s)
+}
+
+func (l *lines) all() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.got...)
+}
+
+// reaped reports whether pid has been waited for.
+func reaped(pid int) bool {
+	_, err := syscall.Wait4(pid, nil, syscall.WNOHANG, nil)
+	return errors.Is(err, syscall.ECHILD)
+}
+
+func TestStreamLines(t *testing.T) {
+	stdout, stderr := newLines(), newLines()
+	c := helperCmd("lines")
+	c.Stdout, c.Stderr = stdout.add, stderr.add
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(stdout.all(), "|"); got != "one|two|three" {
+		t.Errorf("stdout lines = %q", got)
+	}
+	if got := strings.Join(stderr.all(), "|"); got != "oops" {
+		t.Errorf("stderr lines = %q", got)
+	}
+	if err := c.Run(context.Background()); err != ErrStarted {
+		t.Errorf("second Run = %v, want %v", err, ErrStarted)
+	}
+}
+
+func TestExitError(t *testing.T) {
+	err := helperCmd("crash").Run(context.Background())
+	var exit *osexec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 3 {
+		t.Errorf("err = %v, want exit status 3", err)
+	}
+}
+
+func TestGracefulStop(t *testing.T) {
+	out := newLines()
+	c := helperCmd("term")
+	c.Stdout = out.add
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-out.first
+		cancel()
+	}()
+
+	err := c.Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want it to wrap %v", err, context.Canceled)
+	}
+	if got := strings.Join(out.all(), "|"); got != "ready|bye" {
+		t.Errorf("the command should shut down cleanly on SIGTERM, output %q", got)
+	}
+}
+
+func TestKillEscalation(t *testing.T) {
+	out := newLines()
+	c := helperCmd("stubborn")
+	c.Stdout = out.add
+	c.GracePeriod = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	var pid int
+	go func() {
+		<-out.first
+		pid = c.Pid()
+		cancel()
+	}()
+
+	start := time.Now()
+	err := c.Run(ctx)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("Run took %v", elapsed)
+	}
+	var exit *osexec.ExitError
+	if !errors.As(err, &exit) || exit.Sys().(syscall.WaitStatus).Signal() != syscall.SIGKILL {
+		t.Errorf("err = %v, want the command killed", err)
+	}
+	if !reaped(pid) {
+		t.Errorf("process %d was not waited for", pid)
+	}
+}
+
+func TestGroupKill(t *testing.T) {
+	out := newLines()
+	c := helperCmd("parent")
+	c.Stdout = out.add
+	c.GracePeriod = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	var child int
+	go func() {
+		child, _ = strconv.Atoi(<-out.first)
+		cancel()
+	}()
+	c.Run(ctx)
+
+	// The grandchild ignores SIGTERM and belongs to some other parent now,
+	// so wait for SIGKILL to land.
+	deadline := time.Now().Add(5 * time.Second)
+	for alive(child) {
+		if time.Now().After(deadline) {
+			syscall.Kill(child, syscall.SIGKILL)
+			t.Fatalf("grandchild %d outlived the command", child)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// alive reports whether pid is running; zombies waiting for their new
+// parent to reap them count as gone.
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+func TestSuperviseRestartsCrashes(t *testing.T) {
+	c := helperCmd("flaky")
+	c.Env = append(c.Env, "EXEC_TEST_COUNTER="+t.TempDir()+"/count")
+
+	var exits []error
+	err := Supervise(context.Background(), c, Restart{
+		Backoff: retry.Constant(time.Millisecond),
+		OnExit:  func(err error) { exits = append(exits, err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exits) != 3 || exits[0] == nil || exits[1] == nil || exits[2] != nil {
+		t.Errorf("exits = %v, want two crashes and a clean exit", exits)
+	}
+}
+
+func TestSuperviseGivesUp(t *testing.T) {
+	runs := 0
+	err := Supervise(context.Background(), helperCmd("crash"), Restart{
+		Backoff:     retry.Constant(time.Millisecond),
+		MaxRestarts: 2,
+		OnExit:      func(error) { runs++ },
+	})
+	if !errors.Is(err, ErrTooManyRestarts) || runs != 3 {
+		t.Errorf("err = %v after %d runs, want %v after 3", err, runs, ErrTooManyRestarts)
+	}
+}
+
+func TestSuperviseStops(t *testing.T) {
+	out := newLines()
+	c := helperCmd("term")
+	c.Stdout = out.add
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-out.first
+		cancel()
+	}()
+	if err := Supervise(ctx, c, Restart{}); err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
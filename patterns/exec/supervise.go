@@ -0,0 +1,74 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+// ErrTooManyRestarts is returned by Supervise when a command keeps crashing.
+var ErrTooManyRestarts = errors.New("exec: too many restarts")
+
+// Restart configures Supervise.
+type Restart struct {
+	// Backoff computes the delay before each restart. It defaults to
+	// exponential backoff from 100ms up to 30s.
+	Backoff retry.Backoff
+	// MaxRestarts gives up after that many crashes in a row; zero means
+	// no limit.
+	MaxRestarts int
+	// Healthy is how long a run must last for the crashes before it to be
+	// forgotten. It defaults to one minute.
+	Healthy time.Duration
+	// OnExit, if set, is called after every run with its error.
+	OnExit func(err error)
+}
+
+// Supervise runs a copy of c, and runs it again whenever it crashes, until
+// it exits cleanly or ctx is done. Stopping works as in Cmd.Run.
+func Supervise(ctx context.Context, c *Cmd, r Restart) error {
+	if r.Backoff == nil {
+		r.Backoff = retry.Exponential{Initial: 100 * time.Millisecond, Max: 30 * time.Second}
+	}
+	if r.Healthy <= 0 {
+		r.Healthy = time.Minute
+	}
+
+	var (
+		crashes int
+		delay   time.Duration
+	)
+	for {
+		start := time.Now()
+		err := c.clone().Run(ctx)
+		if r.OnExit != nil {
+			r.OnExit(err)
+		}
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err == nil:
+			return nil
+		}
+
+		if time.Since(start) >= r.Healthy {
+			crashes, delay = 0, 0
+		}
+		crashes++
+		if r.MaxRestarts > 0 && crashes > r.MaxRestarts {
+			return fmt.Errorf("%w: %w", ErrTooManyRestarts, err)
+		}
+		delay = r.Backoff.Next(crashes, delay)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package filelock provides advisory locks on files that work across
+// processes.
+//
+// The lock is tied to an open file: flock on unix, LockFileEx on Windows.
+// The operating system drops it when the holder closes the file or dies,
+// so a crashed process never leaves a stale lock behind, unlike a lock file
+// whose mere existence means "locked". Being advisory, it only excludes
+// processes that take the same lock; it does not stop anyone from reading
+// or writing the file.
+//
+// The typical uses are making sure only one instance of a daemon runs, see
+// SingleInstance, and handing out ownership of files such as the segments
+// of an on-disk queue.
+package filelock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLocked is returned when the lock is held elsewhere.
+	ErrLocked = errors.New("filelock: locked by another holder")
+	// ErrNotLocked is returned by Unlock when the lock is not held.
+	ErrNotLocked = errors.New("filelock: not locked")
+)
+
+// Lock is an advisory lock on a file, created if it does not exist. A Lock
+// is not reentrant, and two Locks on the same path exclude each other even
+// within one process.
+type Lock struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// New returns a Lock on path. It does not touch the file yet.
+func New(path string) *Lock {
+	return &Lock{path: path}
+}
+
+// Path returns the path of the locked file.
+func (l *Lock) Path() string { return l.path }
+
+// TryLock takes the lock if it is free and returns ErrLocked otherwise. It
+// never blocks.
+func (l *Lock) TryLock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		return ErrLocked
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := tryLock(f); err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	return nil
+}
+
+// Lock waits for the lock until ctx is done. The system calls cannot be
+// interrupted, so it polls TryLock, backing off from 1ms to 100ms.
+func (l *Lock) Lock(ctx context.Context) error {
+	delay := time.Millisecond
+	for {
+		err := l.TryLock()
+		if !errors.Is(err, ErrLocked) {
+			return err
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+		delay = min(2*delay, 100*time.Millisecond)
+	}
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return ErrNotLocked
+	}
+	err := unlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+// SingleInstance takes the lock on path for the lifetime of the process and
+// records the process id in the file. If another process holds it, the
+// error wraps ErrLocked and names that process.
+func SingleInstance(path string) (*Lock, error) {
+	l := New(path)
+	if err := l.TryLock(); err != nil {
+		if errors.Is(err, ErrLocked) {
+			if pid, ok := readPID(path); ok {
+				return nil, fmt.Errorf("%w: pid %d", ErrLocked, pid)
+			}
+		}
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.f.Truncate(0); err != nil {
+		return l, err
+	}
+	_, err := l.f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return l, err
+}
+
+func readPID(path string) (int, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(b)))
+	return pid, err == nil
+}
@@ -0,0 +1,180 @@
+package filelock
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// envHelper makes the test binary take the lock named by envPath.
+const (
+	envHelper = "FILELOCK_TEST_HELPER"
+	envPath   = "FILELOCK_TEST_PATH"
+)
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(envHelper); mode != "" {
+		os.Exit(helper(mode, os.Getenv(envPath)))
+	}
+	os.Exit(m.Run())
+}
+
+// helper takes the lock, says so on stdout and holds it until stdin is
+// closed.
+func helper(mode, path string) int {
+	switch mode {
+	case "single":
+		if _, err := SingleInstance(path); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	case "hold":
+		if err := New(path).TryLock(); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	fmt.Println("locked")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+	return 0
+}
+
+type holder struct {
+	cmd   *exec.Cmd
+	stdin interface{ Close() error }
+
+	once sync.Once
+	err  error
+}
+
+func (h *holder) wait() error {
+	h.once.Do(func() { h.err = h.cmd.Wait() })
+	return h.err
+}
+
+// hold starts a process holding the lock on path.
+func hold(t *testing.T, mode, path string) *holder {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), envHelper+"="+mode, envPath+"="+path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	h := &holder{cmd: cmd, stdin: stdin}
+	t.Cleanup(func() { h.cmd.Process.Kill(); h.wait() })
+
+	line, _ := bufio.NewReader(stdout).ReadString('\n')
+	if strings.TrimSpace(line) != "locked" {
+		t.Fatalf("helper did not take the lock: %q", line)
+	}
+	return h
+}
+
+// release lets the holder exit normally.
+func (h *holder) release(t *testing.T) {
+	h.stdin.Close()
+	if err := h.wait(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	a, b := New(path), New(path)
+
+	if err := a.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.TryLock(); !errors.Is(err, ErrLocked) {
+		t.Errorf("a Lock is not reentrant, got %v", err)
+	}
+	if err := b.TryLock(); !errors.Is(err, ErrLocked) {
+		t.Errorf("second Lock on the same file: %v", err)
+	}
+	if err := a.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Unlock(); !errors.Is(err, ErrNotLocked) {
+		t.Errorf("double Unlock = %v", err)
+	}
+	if err := b.TryLock(); err != nil {
+		t.Errorf("lock not free after Unlock: %v", err)
+	}
+	b.Unlock()
+}
+
+func TestAcrossProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	h := hold(t, "hold", path)
+
+	l := New(path)
+	if err := l.TryLock(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("TryLock while another process holds it = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Lock(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Lock = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		h.release(t)
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := l.Lock(ctx); err != nil {
+		t.Fatalf("Lock after the holder exited = %v", err)
+	}
+	l.Unlock()
+}
+
+func TestReleasedOnCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	h := hold(t, "hold", path)
+	h.cmd.Process.Kill()
+	h.wait()
+
+	l := New(path)
+	if err := l.TryLock(); err != nil {
+		t.Errorf("the lock of a killed process should be free: %v", err)
+	}
+	l.Unlock()
+}
+
+func TestSingleInstance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.pid")
+	h := hold(t, "single", path)
+
+	_, err := SingleInstance(path)
+	if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), strconv.Itoa(h.cmd.Process.Pid)) {
+		t.Errorf("err = %v, want %v naming pid %d", err, ErrLocked, h.cmd.Process.Pid)
+	}
+
+	h.release(t)
+	l, err := SingleInstance(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+	if pid, _ := readPID(path); pid != os.Getpid() {
+		t.Errorf("pid file holds %d, want %d", pid, os.Getpid())
+	}
+}
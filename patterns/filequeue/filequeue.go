@@ -0,0 +1,232 @@
+// Package filequeue is a durable FIFO queue of byte records in a file.
+//
+// Records are appended to a data file, each framed by its length and a
+// CRC-32 checksum, and synced before Push returns. The position of the
+// first record not yet popped lives in a small head file, replaced
+// atomically on every Pop. After a crash the queue reopens where it was:
+// a record torn by the crash fails its checksum and is cut off, together
+// with anything after it, and a record popped but not yet recorded as such
+// comes back, so consumers see every record at least once.
+//
+// Once everything has been popped, the data file is truncated, so the queue
+// does not grow without bound as long as it is drained now and then.
+//
+// A Queue is safe for concurrent use within one process. Use
+// patterns/filelock to keep other processes out.
+package filequeue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrEmpty is returned by Peek and Pop on an empty queue.
+	ErrEmpty = errors.New("filequeue: empty")
+	// ErrClosed is returned after Close.
+	ErrClosed = errors.New("filequeue: closed")
+	// ErrTooLarge is returned by Push for records over MaxRecord bytes.
+	ErrTooLarge = errors.New("filequeue: record too large")
+)
+
+// MaxRecord is the largest record Push accepts.
+const MaxRecord = 16 << 20
+
+const frame = 8 // length and checksum
+
+// Queue is a durable FIFO queue.
+type Queue struct {
+	path string
+
+	mu      sync.Mutex
+	f       *os.File
+	head    int64   // offset of the first record
+	offsets []int64 // offsets of the records from head on
+	end     int64
+}
+
+// Open opens the queue stored at path, and path+".head", creating it if
+// needed.
+func Open(path string) (*Queue, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue{path: path, f: f}
+	if err := q.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// load reads the head and scans the records after it, cutting off a torn
+// tail.
+func (q *Queue) load() error {
+	b, err := os.ReadFile(q.headPath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	case len(b) == 8:
+		q.head = int64(binary.LittleEndian.Uint64(b))
+	}
+
+	info, err := q.f.Stat()
+	if err != nil {
+		return err
+	}
+	if q.head > info.Size() {
+		// The data file was truncated after the head was written.
+		q.head = info.Size()
+	}
+	r := bufio.NewReader(io.NewSectionReader(q.f, q.head, info.Size()-q.head))
+	off := q.head
+	for {
+		n, err := readRecord(r, nil)
+		if err != nil {
+			break
+		}
+		q.offsets = append(q.offsets, off)
+		off += frame + int64(n)
+	}
+	q.end = off
+	if off < info.Size() {
+		return q.f.Truncate(off)
+	}
+	return nil
+}
+
+func (q *Queue) headPath() string { return q.path + ".head" }
+
+// readRecord reads one record, into buf when it is large enough, and
+// returns its length. A short or corrupt record is an error.
+func readRecord(r io.Reader, buf []byte) (int, error) {
+	var hdr [frame]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, err
+	}
+	n := binary.LittleEndian.Uint32(hdr[:4])
+	if n > MaxRecord {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if uint32(cap(buf)) < n {
+		buf = make([]byte, n)
+	}
+	buf = buf[:n]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	if crc32.ChecksumIEEE(buf) != binary.LittleEndian.Uint32(hdr[4:]) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(n), nil
+}
+
+// Push appends data to the queue and syncs it to disk.
+func (q *Queue) Push(data []byte) error {
+	if len(data) > MaxRecord {
+		return ErrTooLarge
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return ErrClosed
+	}
+	rec := make([]byte, frame+len(data))
+	binary.LittleEndian.PutUint32(rec, uint32(len(data)))
+	binary.LittleEndian.PutUint32(rec[4:], crc32.ChecksumIEEE(data))
+	copy(rec[frame:], data)
+	if _, err := q.f.WriteAt(rec, q.end); err != nil {
+		return err
+	}
+	if err := q.f.Sync(); err != nil {
+		return err
+	}
+	q.offsets = append(q.offsets, q.end)
+	q.end += int64(len(rec))
+	return nil
+}
+
+// Peek returns the first record without removing it.
+func (q *Queue) Peek() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return nil, ErrClosed
+	}
+	if len(q.offsets) == 0 {
+		return nil, ErrEmpty
+	}
+	off := q.offsets[0]
+	next := q.end
+	if len(q.offsets) > 1 {
+		next = q.offsets[1]
+	}
+	buf := make([]byte, next-off)
+	n, err := readRecord(io.NewSectionReader(q.f, off, next-off), buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// Pop removes the first record. Once the queue is empty, the data file is
+// truncated.
+func (q *Queue) Pop() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return ErrClosed
+	}
+	if len(q.offsets) == 0 {
+		return ErrEmpty
+	}
+	q.offsets = q.offsets[1:]
+	if len(q.offsets) > 0 {
+		q.head = q.offsets[0]
+	} else {
+		// Drained: start over. A crash before the new head is written
+		// leaves the old one past the end of the file, which load clamps.
+		if err := q.f.Truncate(0); err != nil {
+			return err
+		}
+		q.head, q.end = 0, 0
+	}
+	return q.writeHead(q.head)
+}
+
+// writeHead replaces the head file atomically.
+func (q *Queue) writeHead(head int64) error {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(head))
+	tmp := q.headPath() + ".tmp"
+	if err := os.WriteFile(tmp, b[:], 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.headPath())
+}
+
+// Len returns the number of records in the queue.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.offsets)
+}
+
+// Close closes the queue.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return ErrClosed
+	}
+	err := q.f.Close()
+	q.f = nil
+	return err
+}
@@ -0,0 +1,115 @@
+package filequeue
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func open(t *testing.T, path string) *Queue {
+	t.Helper()
+	q, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func pop(t *testing.T, q *Queue) string {
+	t.Helper()
+	b, err := q.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Pop(); err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFIFOAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := open(t, path)
+	for i := 0; i < 5; i++ {
+		if err := q.Push([]byte(fmt.Sprint("rec", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := pop(t, q); got != "rec0" {
+		t.Errorf("got %q", got)
+	}
+	q.Close()
+
+	q = open(t, path)
+	defer q.Close()
+	if q.Len() != 4 {
+		t.Fatalf("Len after reopen = %d, want 4", q.Len())
+	}
+	for i := 1; i < 5; i++ {
+		if got, want := pop(t, q), fmt.Sprint("rec", i); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if _, err := q.Peek(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Peek on empty queue: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Size() != 0 {
+		t.Errorf("drained queue is %d bytes, want 0", info.Size())
+	}
+}
+
+// TestTornTail simulates a crash in the middle of a Push.
+func TestTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := open(t, path)
+	q.Push([]byte("complete"))
+	q.Push([]byte("torn record"))
+	q.Close()
+
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+	q = open(t, path)
+	if q.Len() != 1 {
+		t.Fatalf("Len = %d, want the complete record only", q.Len())
+	}
+	q.Push([]byte("after"))
+	q.Close()
+
+	q = open(t, path)
+	defer q.Close()
+	if a, b := pop(t, q), pop(t, q); a != "complete" || b != "after" {
+		t.Errorf("got %q, %q", a, b)
+	}
+}
+
+func TestCorruptRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := open(t, path)
+	q.Push([]byte("good"))
+	q.Push([]byte("flipped"))
+	q.Close()
+
+	f, _ := os.OpenFile(path, os.O_RDWR, 0)
+	f.WriteAt([]byte("X"), frame+4+frame)
+	f.Close()
+	q = open(t, path)
+	defer q.Close()
+	if q.Len() != 1 {
+		t.Errorf("Len = %d, want records up to the corrupt one", q.Len())
+	}
+}
+
+func TestClosed(t *testing.T) {
+	q := open(t, filepath.Join(t.TempDir(), "q"))
+	q.Close()
+	if err := q.Push(nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Push after Close: %v", err)
+	}
+	if err := q.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+// Package handshake lets two components that may run different releases
+// agree on how to talk to each other before they start.
+//
+// Each side describes itself in a Hello: the range of protocol versions it
+// speaks, the optional features it supports, and the features it cannot
+// do without. Negotiate settles on the highest version both speak and the
+// features both support. It is a pure function of the two Hellos, so both
+// sides, each calling it with the Hellos the other way round, reach the
+// same Agreement without another round trip. Handshake exchanges the
+// Hellos over a connection and then calls Negotiate.
+//
+// A newer component talking to an older one thus downgrades to the older
+// protocol and switches off the features the older one lacks, rather than
+// failing or sending messages the other cannot read. It fails only when
+// the version ranges do not overlap, or a feature one side requires is
+// missing on the other.
+package handshake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+var (
+	// ErrNoCommonVersion is returned when the version ranges do not
+	// overlap.
+	ErrNoCommonVersion = errors.New("handshake: no common protocol version")
+	// ErrMissingFeature is returned when a side lacks a feature the other
+	// requires.
+	ErrMissingFeature = errors.New("handshake: required feature missing")
+	// ErrBadHello is returned for a Hello that is malformed.
+	ErrBadHello = errors.New("handshake: malformed hello")
+)
+
+// maxHello bounds the size of an encoded Hello.
+const maxHello = 64 << 10
+
+// Hello is what a side says about itself.
+type Hello struct {
+	Name string `json:"name"`
+	// MinVersion and MaxVersion are the range of protocol versions spoken.
+	MinVersion int `json:"min_version"`
+	MaxVersion int `json:"max_version"`
+	// Features are the optional features supported.
+	Features []string `json:"features,omitempty"`
+	// Require are the features the peer must support. They need not be
+	// listed in Features as well.
+	Require []string `json:"require,omitempty"`
+}
+
+func (h Hello) validate() error {
+	if h.MinVersion < 1 || h.MaxVersion < h.MinVersion {
+		return fmt.Errorf("%w: %q speaks versions %d to %d", ErrBadHello, h.Name, h.MinVersion, h.MaxVersion)
+	}
+	return nil
+}
+
+// features returns everything h supports, including what it requires.
+func (h Hello) features() []string {
+	return append(slices.Clone(h.Features), h.Require...)
+}
+
+// Agreement is the outcome of a handshake.
+type Agreement struct {
+	Version int
+	// Features are the features both sides support, sorted.
+	Features []string
+	// Peer is the name of the other side.
+	Peer string
+}
+
+// Has reports whether feature f was agreed on.
+func (a Agreement) Has(f string) bool {
+	_, ok := slices.BinarySearch(a.Features, f)
+	return ok
+}
+
+// Negotiate returns the agreement between local and remote. It gives the
+// same Version and Features when called with the two swapped.
+func Negotiate(local, remote Hello) (Agreement, error) {
+	if err := local.validate(); err != nil {
+		return Agreement{}, err
+	}
+	if err := remote.validate(); err != nil {
+		return Agreement{}, err
+	}
+	v := min(local.MaxVersion, remote.MaxVersion)
+	if v < max(local.MinVersion, remote.MinVersion) {
+		return Agreement{}, fmt.Errorf("%w: %q speaks %d to %d, %q speaks %d to %d", ErrNoCommonVersion,
+			local.Name, local.MinVersion, local.MaxVersion, remote.Name, remote.MinVersion, remote.MaxVersion)
+	}
+
+	mine, theirs := local.features(), remote.features()
+	for _, side := range []struct {
+		who     string
+		require []string
+		has     []string
+		lacks   string
+	}{
+		{local.Name, local.Require, theirs, remote.Name},
+		{remote.Name, remote.Require, mine, local.Name},
+	} {
+		for _, f := range side.require {
+			if !slices.Contains(side.has, f) {
+				return Agreement{}, fmt.Errorf("%w: %q requires %q, which %q lacks", ErrMissingFeature, side.who, f, side.lacks)
+			}
+		}
+	}
+
+	var common []string
+	for _, f := range mine {
+		if slices.Contains(theirs, f) {
+			common = append(common, f)
+		}
+	}
+	slices.Sort(common)
+	return Agreement{Version: v, Features: slices.Compact(common), Peer: remote.Name}, nil
+}
+
+// Handshake sends local over rw, reads the peer's Hello and negotiates.
+// Both sides call it at the same time. It reads no further than the end of
+// the peer's Hello, so rw can carry the protocol afterwards.
+//
+// If ctx ends first, Handshake returns its error, but it cannot interrupt
+// a read or write in progress; close rw to do that.
+func Handshake(ctx context.Context, rw io.ReadWriter, local Hello) (Agreement, error) {
+	if err := local.validate(); err != nil {
+		return Agreement{}, err
+	}
+	out, err := json.Marshal(local)
+	if err != nil {
+		return Agreement{}, err
+	}
+	sent := make(chan error, 1)
+	go func() {
+		_, err := rw.Write(append(out, '\n'))
+		sent <- err
+	}()
+	type hello struct {
+		h   Hello
+		err error
+	}
+	got := make(chan hello, 1)
+	go func() {
+		h, err := readHello(rw)
+		got <- hello{h, err}
+	}()
+
+	var remote hello
+	for waiting := 2; waiting > 0; waiting-- {
+		select {
+		case err := <-sent:
+			if err != nil {
+				return Agreement{}, fmt.Errorf("handshake: send hello: %w", err)
+			}
+		case remote = <-got:
+			if remote.err != nil {
+				return Agreement{}, remote.err
+			}
+		case <-ctx.Done():
+			return Agreement{}, ctx.Err()
+		}
+	}
+	return Negotiate(local, remote.h)
+}
+
+// readHello reads one line a byte at a time, so as not to consume what
+// follows it, and decodes it.
+func readHello(r io.Reader) (Hello, error) {
+	var line bytes.Buffer
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return Hello{}, fmt.Errorf("handshake: read hello: %w", err)
+		}
+		if b[0] == '\n' {
+			break
+		}
+		if line.Len() == maxHello {
+			return Hello{}, fmt.Errorf("%w: longer than %d bytes", ErrBadHello, maxHello)
+		}
+		line.WriteByte(b[0])
+	}
+	var h Hello
+	if err := json.Unmarshal(line.Bytes(), &h); err != nil {
+		return Hello{}, fmt.Errorf("%w: %v", ErrBadHello, err)
+	}
+	return h, nil
+}
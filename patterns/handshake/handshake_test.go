@@ -0,0 +1,175 @@
+package handshake
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// releases are successive releases of a component. Version 3 added
+// streaming; version 4 dropped version 1 and made streaming mandatory.
+var releases = []Hello{
+	{Name: "r1", MinVersion: 1, MaxVersion: 1, Features: []string{"gzip"}},
+	{Name: "r2", MinVersion: 1, MaxVersion: 2, Features: []string{"gzip", "batch"}},
+	{Name: "r3", MinVersion: 1, MaxVersion: 3, Features: []string{"batch", "gzip", "stream"}},
+	{Name: "r4", MinVersion: 2, MaxVersion: 4, Features: []string{"batch", "zstd"}, Require: []string{"stream"}},
+}
+
+// pair runs a handshake between a and b over an in-memory connection.
+func pair(t *testing.T, a, b Hello) (Agreement, error, Agreement, error) {
+	t.Helper()
+	ca, cb := net.Pipe()
+	defer ca.Close()
+	defer cb.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	type res struct {
+		ag  Agreement
+		err error
+	}
+	done := make(chan res)
+	go func() {
+		ag, err := Handshake(ctx, cb, b)
+		done <- res{ag, err}
+	}()
+	agA, errA := Handshake(ctx, ca, a)
+	rb := <-done
+	return agA, errA, rb.ag, rb.err
+}
+
+func TestDowngrade(t *testing.T) {
+	agA, errA, agB, errB := pair(t, releases[2], releases[1])
+	if errA != nil || errB != nil {
+		t.Fatal(errA, errB)
+	}
+	want := Agreement{Version: 2, Features: []string{"batch", "gzip"}, Peer: "r2"}
+	if agA.Version != want.Version || !slices.Equal(agA.Features, want.Features) || agA.Peer != "r2" {
+		t.Errorf("r3 agreed %+v, want %+v", agA, want)
+	}
+	if agB.Version != 2 || !slices.Equal(agB.Features, want.Features) || agB.Peer != "r3" {
+		t.Errorf("r2 agreed %+v", agB)
+	}
+	if agA.Has("stream") || !agA.Has("gzip") {
+		t.Errorf("Has on %v", agA.Features)
+	}
+}
+
+// TestMatrix checks every pair of releases against a brute-force answer.
+func TestMatrix(t *testing.T) {
+	for _, a := range releases {
+		for _, b := range releases {
+			t.Run(a.Name+"-"+b.Name, func(t *testing.T) {
+				want := 0
+				for v := 1; v <= 4; v++ {
+					if v >= a.MinVersion && v <= a.MaxVersion && v >= b.MinVersion && v <= b.MaxVersion {
+						want = v
+					}
+				}
+				missing := false
+				for _, req := range [][2]Hello{{a, b}, {b, a}} {
+					for _, f := range req[0].Require {
+						missing = missing || !slices.Contains(req[1].features(), f)
+					}
+				}
+
+				agA, errA, agB, errB := pair(t, a, b)
+				switch {
+				case want == 0:
+					if !errors.Is(errA, ErrNoCommonVersion) || !errors.Is(errB, ErrNoCommonVersion) {
+						t.Errorf("errors %v, %v; want ErrNoCommonVersion", errA, errB)
+					}
+				case missing:
+					if !errors.Is(errA, ErrMissingFeature) || !errors.Is(errB, ErrMissingFeature) {
+						t.Errorf("errors %v, %v; want ErrMissingFeature", errA, errB)
+					}
+				case errA != nil || errB != nil:
+					t.Errorf("errors %v, %v", errA, errB)
+				default:
+					if agA.Version != want || agB.Version != want {
+						t.Errorf("versions %d and %d, want %d", agA.Version, agB.Version, want)
+					}
+					if !slices.Equal(agA.Features, agB.Features) {
+						t.Errorf("features %v and %v", agA.Features, agB.Features)
+					}
+					for _, f := range agA.Features {
+						if !slices.Contains(a.features(), f) || !slices.Contains(b.features(), f) {
+							t.Errorf("agreed on %q, which is not on both sides", f)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestConnectionUsableAfter(t *testing.T) {
+	ca, cb := net.Pipe()
+	defer ca.Close()
+	defer cb.Close()
+	ctx := context.Background()
+	go func() {
+		if _, err := Handshake(ctx, ca, releases[1]); err == nil {
+			io.WriteString(ca, "PING\n")
+		}
+	}()
+	if _, err := Handshake(ctx, cb, releases[2]); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(cb).ReadString('\n')
+	if err != nil || line != "PING\n" {
+		t.Errorf("read %q, %v after the handshake", line, err)
+	}
+}
+
+func TestBadPeer(t *testing.T) {
+	for name, peer := range map[string]string{
+		"garbage":  "hello there\n",
+		"versions": `{"name":"x","min_version":3,"max_version":2}` + "\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			ca, cb := net.Pipe()
+			defer ca.Close()
+			defer cb.Close()
+			go func() {
+				io.WriteString(ca, peer)
+				io.Copy(io.Discard, ca)
+			}()
+			if _, err := Handshake(context.Background(), cb, releases[0]); !errors.Is(err, ErrBadHello) {
+				t.Errorf("Handshake = %v, want ErrBadHello", err)
+			}
+		})
+	}
+}
+
+func TestSilentPeer(t *testing.T) {
+	ca, cb := net.Pipe()
+	defer ca.Close()
+	defer cb.Close()
+	go io.Copy(io.Discard, ca) // reads our hello but never answers
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := Handshake(ctx, cb, releases[0]); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Handshake = %v", err)
+	}
+}
+
+func ExampleNegotiate() {
+	client := Hello{Name: "client", MinVersion: 1, MaxVersion: 3, Features: []string{"gzip", "stream"}}
+	server := Hello{Name: "server", MinVersion: 1, MaxVersion: 2, Features: []string{"gzip"}}
+
+	ag, err := Negotiate(client, server)
+	fmt.Println(ag.Version, ag.Features, ag.Has("stream"), err)
+
+	server.Require = []string{"auth"}
+	_, err = Negotiate(client, server)
+	fmt.Println(err)
+	// Output:
+	// 2 [gzip] false <nil>
+	// handshake: required feature missing: "server" requires "auth", which "client" lacks
+}
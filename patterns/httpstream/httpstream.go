@@ -0,0 +1,107 @@
+// Package httpstream streams a pipeline to an HTTP client as a chunked
+// response. Every item is flushed as soon as it is written, the pipeline is
+// stopped when the client disconnects, and a bounded buffer lets the
+// pipeline run ahead of a slow client by a fixed number of items and no
+// more.
+package httpstream
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrNotFlusher is returned by Stream when the ResponseWriter cannot flush.
+var ErrNotFlusher = errors.New("httpstream: response writer does not support flushing")
+
+// Pipeline builds the item stream for a request. It must stop and close its
+// output once done is closed.
+type Pipeline func(done <-chan struct{}, r *http.Request) <-chan []byte
+
+// Handler streams the items of a Pipeline to the client.
+type Handler struct {
+	pipeline Pipeline
+
+	// Buffer is the number of items the pipeline may produce ahead of the
+	// client. Zero keeps the pipeline in lock step with the writes.
+	Buffer int
+	// ContentType is sent with the response, if set.
+	ContentType string
+	// OnFinish, if set, is called with the outcome of every stream.
+	OnFinish func(n int, err error)
+}
+
+// New constructs a Handler for pipeline.
+func New(pipeline Pipeline) *Handler {
+	return &Handler{
+		pipeline:    pipeline,
+		Buffer:      16,
+		ContentType: "application/x-ndjson",
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, ErrNotFlusher.Error(), http.StatusInternalServerError)
+		return
+	}
+	if h.ContentType != "" {
+		w.Header().Set("Content-Type", h.ContentType)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	n, err := Stream(w, r, buffer(done, h.pipeline(done, r), h.Buffer))
+	if h.OnFinish != nil {
+		h.OnFinish(n, err)
+	}
+}
+
+// buffer decouples the pipeline from the writer by up to size items.
+func buffer(done <-chan struct{}, in <-chan []byte, size int) <-chan []byte {
+	if size <= 0 {
+		return in
+	}
+	out := make(chan []byte, size)
+	go func() {
+		defer close(out)
+		for item := range in {
+			select {
+			case <-done:
+				return
+			case out <- item:
+			}
+		}
+	}()
+	return out
+}
+
+// Stream writes and flushes every item from items until the channel is
+// closed. It returns the number of items written, and stops early with the
+// request context error if the client goes away, or with the write error if
+// a write fails.
+func Stream(w http.ResponseWriter, r *http.Request, items <-chan []byte) (int, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return 0, ErrNotFlusher
+	}
+
+	ctx := r.Context()
+	var n int
+	for {
+		select {
+		case <-ctx.Done():
+			return n, ctx.Err()
+		case item, ok := <-items:
+			if !ok {
+				return n, nil
+			}
+			if _, err := w.Write(item); err != nil {
+				return n, err
+			}
+			flusher.Flush()
+			n++
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package httpstream
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func lines(count int) Pipeline {
+	return func(done <-chan struct{}, r *http.Request) <-chan []byte {
+		out := make(chan []byte)
+		go func() {
+			defer close(out)
+			for i := 0; count < 0 || i < count; i++ {
+				select {
+				case <-done:
+					return
+				case out <- []byte(fmt.Sprintf("%d\n", i)):
+				}
+			}
+		}()
+		return out
+	}
+}
+
+func TestStreamToRecorder(t *testing.T) {
+	h := New(lines(3))
+	var written int
+	h.OnFinish = func(n int, err error) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		written = n
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Body.String() != "0\n1\n2\n" {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+	if !rec.Flushed {
+		t.Error("response was never flushed")
+	}
+	if written != 3 {
+		t.Errorf("expected 3 items, got %d", written)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+}
+
+type countingFlusher struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (c *countingFlusher) Flush() {
+	c.flushes++
+	c.ResponseRecorder.Flush()
+}
+
+func TestFlushPerItem(t *testing.T) {
+	w := &countingFlusher{ResponseRecorder: httptest.NewRecorder()}
+	items := make(chan []byte, 5)
+	for i := 0; i < 5; i++ {
+		items <- []byte("x")
+	}
+	close(items)
+
+	n, err := Stream(w, httptest.NewRequest("GET", "/", nil), items)
+	if err != nil || n != 5 || w.flushes != 5 {
+		t.Errorf("expected 5 items and 5 flushes, got %d items, %d flushes, err %v", n, w.flushes, err)
+	}
+}
+
+type plainWriter struct{ http.ResponseWriter }
+
+func TestRequiresFlusher(t *testing.T) {
+	rec := httptest.NewRecorder()
+	New(lines(1)).ServeHTTP(plainWriter{rec}, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 without a flusher, got %d", rec.Code)
+	}
+}
+
+func TestClientDisconnect(t *testing.T) {
+	stopped := make(chan struct{})
+	finished := make(chan error, 1)
+	h := New(func(done <-chan struct{}, r *http.Request) <-chan []byte {
+		in := lines(-1)(done, r)
+		go func() {
+			<-done
+			close(stopped)
+		}()
+		return in
+	})
+	h.Buffer = 4
+	h.OnFinish = func(n int, err error) { finished <- err }
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(resp.Body)
+	for i := 0; i < 3; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("%d\n", i); line != want {
+			t.Fatalf("got %q, want %q", line, want)
+		}
+	}
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case err := <-finished:
+		if err == nil {
+			t.Error("expected the stream to end with an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not notice the disconnect")
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("pipeline was not stopped")
+	}
+}
+
+func TestBoundedBuffer(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	in := make(chan []byte)
+	out := buffer(done, in, 4)
+
+	// Nobody reads out, so the upstream can hand over the four buffered
+	// items plus the one held by the copying goroutine, and then blocks.
+	var sent int
+	for sent < 10 {
+		select {
+		case in <- []byte("x"):
+			sent++
+			continue
+		case <-time.After(20 * time.Millisecond):
+		}
+		break
+	}
+	if sent != 5 {
+		t.Errorf("expected the pipeline to run 5 items ahead, got %d", sent)
+	}
+	if len(out) != 4 {
+		t.Errorf("expected 4 buffered items, got %d", len(out))
+	}
+}
@@ -0,0 +1,95 @@
+// Package httpworker shows how HTTP handlers hand heavy work to a bounded
+// worker pool. When the pool is saturated the handler sheds the request
+// with 503 and a Retry-After hint instead of queueing it, as it does when
+// the pool's Shedding middleware skips the task, and the request context
+// is passed to the task so a client hanging up stops the work.
+package httpworker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// WorkFunc does the heavy part of a request and returns the response body.
+type WorkFunc func(ctx context.Context, r *http.Request) ([]byte, error)
+
+// Handler serves requests by running Work on a worker pool.
+type Handler struct {
+	pool *workerpool.Pool
+	work WorkFunc
+
+	// RetryAfter is advertised to clients that are turned away because the
+	// pool is saturated or shed their task.
+	RetryAfter time.Duration
+	// Log gets the errors of the work, which clients only see as a 500.
+	// Nil logs to slog.Default.
+	Log *slog.Logger
+}
+
+// New constructs a Handler that runs work on pool.
+func New(pool *workerpool.Pool, work WorkFunc) *Handler {
+	return &Handler{
+		pool:       pool,
+		work:       work,
+		RetryAfter: time.Second,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	err := h.pool.TryRun(r.Context(), workerpool.WorkerFunc(func(ctx context.Context) error {
+		var err error
+		body, err = h.work(ctx, r)
+		return err
+	}))
+
+	switch {
+	case err == nil:
+		w.Write(body)
+	case errors.Is(err, workerpool.ErrSaturated) || errors.Is(err, workerpool.ErrShed) || errors.Is(err, workerpool.ErrClosed):
+		w.Header().Set("Retry-After", retryAfter(h.RetryAfter))
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	case errors.Is(r.Context().Err(), context.Canceled):
+		// The client is gone, nobody is left to read a response.
+	case errors.Is(err, context.DeadlineExceeded):
+		http.Error(w, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+	default:
+		// The error may say more than a client should learn, so it is
+		// logged and the client only learns the work failed. A panic has
+		// been reported to the recovery hooks already.
+		var p *recovery.PanicError
+		if !errors.As(err, &p) {
+			h.logger().ErrorContext(r.Context(), "httpworker: work failed",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Any("err", err),
+			)
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) logger() *slog.Logger {
+	if h.Log != nil {
+		return h.Log
+	}
+	return slog.Default()
+}
+
+// retryAfter formats d as whole seconds, rounding up so a short hint never
+// becomes zero.
+func retryAfter(d time.Duration) string {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.Itoa(secs)
+}
@@ -0,0 +1,178 @@
+package httpworker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+func TestSaturation(t *testing.T) {
+	pool := workerpool.New(2)
+	defer pool.Shutdown()
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	h := New(pool, func(ctx context.Context, r *http.Request) ([]byte, error) {
+		started <- struct{}{}
+		<-release
+		return []byte("done"), nil
+	})
+	h.RetryAfter = 1500 * time.Millisecond
+
+	busy := make(chan *httptest.ResponseRecorder, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			busy <- rec
+		}()
+	}
+	<-started
+	<-started
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when saturated, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("expected Retry-After 2, got %q", got)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if rec := <-busy; rec.Code != http.StatusOK || rec.Body.String() != "done" {
+			t.Errorf("expected 200 done, got %d %q", rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestSaturationOverHTTP(t *testing.T) {
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(New(pool, func(ctx context.Context, r *http.Request) ([]byte, error) {
+		close(started)
+		<-release
+		return nil, nil
+	}))
+	defer srv.Close()
+
+	go http.Get(srv.URL)
+	<-started
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After, got %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	close(release)
+}
+
+func TestCancellationReachesTask(t *testing.T) {
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+
+	taskErr := make(chan error, 1)
+	started := make(chan struct{})
+	h := New(pool, func(ctx context.Context, r *http.Request) ([]byte, error) {
+		close(started)
+		<-ctx.Done()
+		taskErr <- ctx.Err()
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(rec, req)
+		close(done)
+	}()
+	<-started
+	cancel()
+
+	select {
+	case err := <-taskErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("task saw %v, expected context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task did not observe cancellation")
+	}
+	<-done
+	if pool.Active() != 0 {
+		t.Errorf("worker still busy after cancellation")
+	}
+}
+
+func TestWorkError(t *testing.T) {
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+
+	h := New(pool, func(ctx context.Context, r *http.Request) ([]byte, error) {
+		return nil, errors.New("db password rejected")
+	})
+	var log bytes.Buffer
+	h.Log = slog.New(slog.NewTextHandler(&log, nil))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/report", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rec.Code)
+	}
+	// The client gets a generic body, the log gets the error.
+	if body := rec.Body.String(); strings.Contains(body, "password") {
+		t.Errorf("error leaked to the client: %q", body)
+	}
+	if !strings.Contains(log.String(), `err="db password rejected"`) || !strings.Contains(log.String(), "path=/report") {
+		t.Errorf("log = %q", log.String())
+	}
+}
+
+// A task the pool sheds is turned away like a saturated pool's.
+func TestShed(t *testing.T) {
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+	pool.Use(workerpool.Shedding(pool, workerpool.ShedOptions{MaxQueueWait: time.Nanosecond}))
+
+	h := New(pool, func(ctx context.Context, r *http.Request) ([]byte, error) {
+		t.Error("shed work ran")
+		return nil, nil
+	})
+	h.RetryAfter = 3 * time.Second
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "3" {
+		t.Errorf("expected 503 with Retry-After 3, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+// Errors wrapping ErrSaturated are still answered with 503.
+func TestWrappedSaturation(t *testing.T) {
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+
+	h := New(pool, func(ctx context.Context, r *http.Request) ([]byte, error) {
+		return nil, fmt.Errorf("downstream pool: %w", workerpool.ErrSaturated)
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected 503 with Retry-After, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,188 @@
+// Package idgen implements k-sortable identifier schemes and a simulation
+// harness that compares them under clock skew and traffic bursts.
+package idgen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ID is a 128 bit identifier. Schemes with a shorter layout are stored
+// big-endian in the low-order bytes so that byte order equals sort order.
+type ID [16]byte
+
+// Less reports whether id sorts before other.
+func (id ID) Less(other ID) bool {
+	return bytes.Compare(id[:], other[:]) < 0
+}
+
+// String returns the hex encoding of the ID.
+func (id ID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Clock returns the current time as seen by a generator.
+type Clock func() time.Time
+
+// Generator produces identifiers.
+type Generator interface {
+	Next() ID
+}
+
+// Scheme describes how to build a generator for a node in a cluster.
+type Scheme struct {
+	Name string
+	New  func(node int, clock Clock, rnd *rand.Rand) Generator
+}
+
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeSeqMask  = 1<<snowflakeSeqBits - 1
+)
+
+// Snowflake lays out 41 bits of milliseconds, 10 bits of node id and
+// 12 bits of per-millisecond sequence.
+type Snowflake struct {
+	mu    sync.Mutex
+	node  uint64
+	clock Clock
+	// last is the highest timestamp handed out, which may be ahead of
+	// the clock after the sequence of a millisecond ran out, and read is
+	// the clock's last reading.
+	last int64
+	read int64
+	seq  uint64
+
+	// Monotonic makes the generator hold on to its last timestamp when
+	// the clock steps backwards, instead of trusting the new reading.
+	Monotonic bool
+}
+
+// NewSnowflake constructs a Snowflake generator for the given node id.
+func NewSnowflake(node int, clock Clock) *Snowflake {
+	return &Snowflake{
+		node:  uint64(node) & (1<<snowflakeNodeBits - 1),
+		clock: clock,
+		last:  -1,
+		read:  -1,
+	}
+}
+
+// Next returns the next identifier.
+func (s *Snowflake) Next() ID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock().UnixNano() / int64(time.Millisecond)
+	ts := now
+	// Behind last without the clock having stepped back means the
+	// millisecond was borrowed below: stay on it, or the IDs of the
+	// borrowed millisecond are handed out again.
+	if ts < s.last && (s.Monotonic || now >= s.read) {
+		ts = s.last
+	}
+	s.read = now
+
+	switch {
+	case ts == s.last:
+		s.seq = (s.seq + 1) & snowflakeSeqMask
+		if s.seq == 0 {
+			// The sequence is exhausted for this millisecond, so borrow
+			// the next one rather than blocking on the clock.
+			ts++
+		}
+	default:
+		s.seq = 0
+	}
+	s.last = ts
+
+	var id ID
+	v := uint64(ts)<<(snowflakeNodeBits+snowflakeSeqBits) | s.node<<snowflakeSeqBits | s.seq
+	binary.BigEndian.PutUint64(id[8:], v)
+	return id
+}
+
+// ULID lays out 48 bits of milliseconds followed by 80 random bits.
+type ULID struct {
+	mu    sync.Mutex
+	clock Clock
+	rnd   *rand.Rand
+}
+
+// NewULID constructs a ULID generator that draws entropy from rnd.
+func NewULID(clock Clock, rnd *rand.Rand) *ULID {
+	return &ULID{clock: clock, rnd: rnd}
+}
+
+// Next returns the next identifier.
+func (u *ULID) Next() ID {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var id ID
+	ms := uint64(u.clock().UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint64(id[:8], ms<<16)
+	u.rnd.Read(id[6:])
+	return id
+}
+
+// Random is a UUIDv4-like generator. It carries no ordering at all and
+// serves as the baseline for the collision figures.
+type Random struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewRandom constructs a Random generator that draws entropy from rnd.
+func NewRandom(rnd *rand.Rand) *Random {
+	return &Random{rnd: rnd}
+}
+
+// Next returns the next identifier.
+func (r *Random) Next() ID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var id ID
+	r.rnd.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+// Schemes returns the built-in schemes in the order they are reported.
+func Schemes() []Scheme {
+	return []Scheme{
+		{
+			Name: "snowflake",
+			New: func(node int, clock Clock, _ *rand.Rand) Generator {
+				return NewSnowflake(node, clock)
+			},
+		},
+		{
+			Name: "snowflake-monotonic",
+			New: func(node int, clock Clock, _ *rand.Rand) Generator {
+				s := NewSnowflake(node, clock)
+				s.Monotonic = true
+				return s
+			},
+		},
+		{
+			Name: "ulid",
+			New: func(_ int, clock Clock, rnd *rand.Rand) Generator {
+				return NewULID(clock, rnd)
+			},
+		},
+		{
+			Name: "random",
+			New: func(_ int, _ Clock, rnd *rand.Rand) Generator {
+				return NewRandom(rnd)
+			},
+		},
+	}
+}
@@ -0,0 +1,126 @@
+package idgen
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fixedClock(t time.Time) (Clock, func(time.Duration)) {
+	return func() time.Time { return t }, func(d time.Duration) { t = t.Add(d) }
+}
+
+func TestSnowflakeOrdering(t *testing.T) {
+	clock, advance := fixedClock(time.Unix(1600000000, 0))
+	s := NewSnowflake(1, clock)
+
+	prev := s.Next()
+	for i := 0; i < 10000; i++ {
+		if i%100 == 0 {
+			advance(time.Millisecond)
+		}
+		id := s.Next()
+		if !prev.Less(id) {
+			t.Fatalf("id %d: %s does not sort after %s", i, id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestSnowflakeClockStepBack(t *testing.T) {
+	clock, advance := fixedClock(time.Unix(1600000000, 0))
+
+	naive := NewSnowflake(1, clock)
+	mono := NewSnowflake(1, clock)
+	mono.Monotonic = true
+
+	first := naive.Next()
+	mono.Next()
+	advance(-time.Second)
+
+	if id := naive.Next(); !id.Less(first) {
+		t.Errorf("naive snowflake should follow the clock backwards")
+	}
+	if id := mono.Next(); id.Less(first) {
+		t.Errorf("monotonic snowflake went backwards")
+	}
+}
+
+// A clock that does not move makes the generator borrow milliseconds
+// ahead of it, and never hand out an ID twice.
+func TestSnowflakeFrozenClockUnique(t *testing.T) {
+	clock, _ := fixedClock(time.Unix(1600000000, 0))
+	for _, monotonic := range []bool{false, true} {
+		s := NewSnowflake(1, clock)
+		s.Monotonic = monotonic
+		seen := make(map[ID]bool)
+		var prev ID
+		for i := range 5000 {
+			id := s.Next()
+			if seen[id] || i > 0 && !prev.Less(id) {
+				t.Fatalf("monotonic %v: id %d %s repeats or sorts before %s", monotonic, i, id, prev)
+			}
+			seen[id], prev = true, id
+		}
+	}
+}
+
+func TestULIDTimePrefix(t *testing.T) {
+	clock, advance := fixedClock(time.Unix(1600000000, 0))
+	u := NewULID(clock, rand.New(rand.NewSource(1)))
+
+	a := u.Next()
+	advance(time.Millisecond)
+	b := u.Next()
+	if !a.Less(b) {
+		t.Errorf("ulid from a later millisecond sorts first: %s, %s", a, b)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	cfg := DefaultSimConfig()
+	cfg.Duration = 200 * time.Millisecond
+	cfg.BurstProbability = 0.05
+
+	reports := Simulate(cfg)
+	if len(reports) != len(Schemes()) {
+		t.Fatalf("expected %d reports, got %d", len(Schemes()), len(reports))
+	}
+
+	byName := make(map[string]Report)
+	for _, r := range reports {
+		if r.Generated == 0 {
+			t.Errorf("%s generated no ids", r.Scheme)
+		}
+		byName[r.Scheme] = r
+	}
+
+	if r := byName["snowflake-monotonic"]; r.Collisions != 0 || r.NodeViolations != 0 {
+		t.Errorf("monotonic snowflake must be unique and node-ordered: %v", r)
+	}
+	if r := byName["snowflake"]; r.NodeViolations == 0 {
+		t.Errorf("clock jumps should break naive snowflake ordering: %v", r)
+	}
+	if r := byName["random"]; r.GlobalViolationRate() < 0.25 {
+		t.Errorf("random ids should be mostly unordered: %v", r)
+	}
+
+	again := Simulate(cfg)
+	for i := range reports {
+		if reports[i] != again[i] {
+			t.Errorf("simulation is not deterministic: %v != %v", reports[i], again[i])
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, []Report{{Scheme: "ulid", Generated: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "ulid") {
+		t.Errorf("report is missing scheme name:\n%s", buf.String())
+	}
+}
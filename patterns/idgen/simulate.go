@@ -0,0 +1,199 @@
+package idgen
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"text/tabwriter"
+	"time"
+)
+
+// SimConfig describes a simulated cluster. All time is virtual, so a run
+// is fully determined by its Seed.
+type SimConfig struct {
+	Nodes    int           // number of generating nodes
+	Duration time.Duration // virtual time covered by the run
+	Tick     time.Duration // virtual time step
+	Rate     int           // ids generated by every node on every tick
+
+	// MaxSkew bounds the fixed offset of each node clock from true time.
+	MaxSkew time.Duration
+	// JumpProbability is the per tick chance that a node clock is stepped
+	// backwards by up to MaxJump, as an NTP correction would do.
+	JumpProbability float64
+	MaxJump         time.Duration
+
+	// BurstProbability is the per tick chance that a node generates
+	// BurstSize extra ids on top of Rate.
+	BurstProbability float64
+	BurstSize        int
+
+	// Tolerance is the K in k-sortable: an id only counts as a global
+	// ordering violation if it sorts before one issued more than
+	// Tolerance earlier in true time.
+	Tolerance time.Duration
+
+	Seed int64
+}
+
+// DefaultSimConfig returns a small cluster with moderate skew and bursts.
+func DefaultSimConfig() SimConfig {
+	return SimConfig{
+		Nodes:            8,
+		Duration:         2 * time.Second,
+		Tick:             time.Millisecond,
+		Rate:             4,
+		MaxSkew:          5 * time.Millisecond,
+		JumpProbability:  0.002,
+		MaxJump:          20 * time.Millisecond,
+		BurstProbability: 0.01,
+		BurstSize:        5000,
+		Tolerance:        50 * time.Millisecond,
+		Seed:             1,
+	}
+}
+
+// Report summarises how a scheme behaved in a simulation run.
+type Report struct {
+	Scheme    string
+	Generated int
+	// Collisions counts ids that had already been issued by any node.
+	Collisions int
+	// NodeViolations counts ids that sort before the previous id of the
+	// same node.
+	NodeViolations int
+	// GlobalViolations counts ids that sort before an id issued by any
+	// node more than SimConfig.Tolerance earlier in true time.
+	GlobalViolations int
+}
+
+// CollisionRate returns the fraction of generated ids that collided.
+func (r Report) CollisionRate() float64 {
+	if r.Generated == 0 {
+		return 0
+	}
+	return float64(r.Collisions) / float64(r.Generated)
+}
+
+// GlobalViolationRate returns the fraction of generated ids that were out
+// of true-time order.
+func (r Report) GlobalViolationRate() float64 {
+	if r.Generated == 0 {
+		return 0
+	}
+	return float64(r.GlobalViolations) / float64(r.Generated)
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%s: generated=%d collisions=%d node-violations=%d global-violations=%d",
+		r.Scheme, r.Generated, r.Collisions, r.NodeViolations, r.GlobalViolations)
+}
+
+type simNode struct {
+	gen    Generator
+	offset time.Duration
+	last   ID
+	issued bool
+}
+
+// Simulate runs every scheme against the same cluster and returns one
+// report per scheme. Each scheme sees identical clocks, jumps and bursts.
+func Simulate(cfg SimConfig, schemes ...Scheme) []Report {
+	if len(schemes) == 0 {
+		schemes = Schemes()
+	}
+	reports := make([]Report, 0, len(schemes))
+	for _, s := range schemes {
+		reports = append(reports, simulate(cfg, s))
+	}
+	return reports
+}
+
+func simulate(cfg SimConfig, scheme Scheme) Report {
+	// Events and entropy use separate sources so that the cluster timeline
+	// does not depend on how much randomness a scheme consumes.
+	events := rand.New(rand.NewSource(cfg.Seed))
+	entropy := rand.New(rand.NewSource(cfg.Seed + 1))
+
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var now time.Duration
+
+	nodes := make([]*simNode, cfg.Nodes)
+	for i := range nodes {
+		n := &simNode{offset: randDuration(events, -cfg.MaxSkew, cfg.MaxSkew)}
+		clock := func() time.Time { return epoch.Add(now + n.offset) }
+		n.gen = scheme.New(i, clock, entropy)
+		nodes[i] = n
+	}
+
+	type tickMax struct {
+		at  time.Duration
+		max ID
+	}
+	var (
+		report  = Report{Scheme: scheme.Name}
+		seen    = make(map[ID]struct{})
+		pending []tickMax
+		settled ID
+		settle  bool
+	)
+	for ; now < cfg.Duration; now += cfg.Tick {
+		// Fold the maxima of ticks that are now beyond the tolerance
+		// window into the reference every new id is checked against.
+		for len(pending) > 0 && now-pending[0].at > cfg.Tolerance {
+			if settled.Less(pending[0].max) {
+				settled = pending[0].max
+			}
+			settle = true
+			pending = pending[1:]
+		}
+		cur := tickMax{at: now}
+		for _, n := range nodes {
+			if cfg.MaxJump > 0 && events.Float64() < cfg.JumpProbability {
+				n.offset -= randDuration(events, 0, cfg.MaxJump)
+			}
+			count := cfg.Rate
+			if events.Float64() < cfg.BurstProbability {
+				count += cfg.BurstSize
+			}
+			for i := 0; i < count; i++ {
+				id := n.gen.Next()
+				report.Generated++
+				if _, ok := seen[id]; ok {
+					report.Collisions++
+				}
+				seen[id] = struct{}{}
+				if n.issued && id.Less(n.last) {
+					report.NodeViolations++
+				}
+				if settle && id.Less(settled) {
+					report.GlobalViolations++
+				}
+				if cur.max.Less(id) {
+					cur.max = id
+				}
+				n.last, n.issued = id, true
+			}
+		}
+		pending = append(pending, cur)
+	}
+	return report
+}
+
+func randDuration(rnd *rand.Rand, min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rnd.Int63n(int64(max-min)+1))
+}
+
+// WriteReport writes reports as an aligned table.
+func WriteReport(w io.Writer, reports []Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCHEME\tGENERATED\tCOLLISIONS\tNODE VIOLATIONS\tGLOBAL VIOLATIONS\tGLOBAL RATE")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%.4f\n",
+			r.Scheme, r.Generated, r.Collisions, r.NodeViolations, r.GlobalViolations, r.GlobalViolationRate())
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,263 @@
+// Package jobqueue is a job queue whose jobs move through states, in the
+// manner of the queue tables of Sidekiq, Que or River: pending, running,
+// and then done, or retrying after a backoff, or failed once out of
+// attempts.
+//
+// A worker claims a job for a visibility timeout, a lease it extends while
+// it works. A worker that crashes stops extending it, and once it runs out
+// the job is reclaimed and run again by someone else. Every claim is an
+// attempt with its own number, and a late Complete or Fail of an attempt
+// that was reclaimed is refused, so a slow worker cannot overwrite the
+// outcome of the attempt that replaced it. A job may therefore run more
+// than once, never at the same time under a live lease: handlers should be
+// idempotent.
+//
+// The Queue keeps its jobs in memory, finished ones included, as a table
+// would. A Processor runs them: it claims jobs on a schedule of
+// concurrency/scheduler, runs them on a patterns/workerpool Pool, keeps
+// their leases alive and reclaims the expired leases of others.
+package jobqueue
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+var (
+	// ErrLeaseLost is returned for an attempt that is no longer running,
+	// because its lease expired and the job was reclaimed.
+	ErrLeaseLost = errors.New("jobqueue: lease lost")
+	// ErrLeaseExpired is recorded as the error of an attempt that was
+	// reclaimed.
+	ErrLeaseExpired = errors.New("jobqueue: lease expired")
+)
+
+// State is where a job stands.
+type State int
+
+const (
+	Pending State = iota
+	Running
+	// Retrying is a job whose last attempt failed, waiting for RunAt.
+	Retrying
+	Done
+	// Failed is a job out of attempts.
+	Failed
+)
+
+func (s State) String() string {
+	return [...]string{"pending", "running", "retrying", "done", "failed"}[s]
+}
+
+// Job is a unit of work and its state. The Queue hands out copies.
+type Job struct {
+	ID      int64
+	Kind    string
+	Payload []byte
+
+	State State
+	// Attempt counts the claims, the current one included.
+	Attempt int
+	// RunAt is when a pending or retrying job may be claimed.
+	RunAt time.Time
+	// Worker holds the lease of a running job until LeaseUntil.
+	Worker     string
+	LeaseUntil time.Time
+	// Err is the error of the last failed attempt.
+	Err string
+}
+
+// Clock is the source of time for a Queue. Every clock.Clock is one.
+type Clock interface {
+	Now() time.Time
+}
+
+// Options configure a Queue.
+type Options struct {
+	// MaxAttempts bounds the attempts of a job, default 3.
+	MaxAttempts int
+	// Backoff computes the wait before a retry, default exponential from
+	// one second up to a minute.
+	Backoff retry.Backoff
+	// Clock defaults to clock.Real.
+	Clock Clock
+}
+
+func (o *Options) defaults() {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 3
+	}
+	if o.Backoff == nil {
+		o.Backoff = retry.Exponential{Initial: time.Second, Max: time.Minute}
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real
+	}
+}
+
+// Queue holds jobs. It is safe for concurrent use.
+type Queue struct {
+	opts Options
+
+	mu     sync.Mutex
+	jobs   map[int64]*Job
+	nextID int64
+}
+
+// New returns an empty Queue.
+func New(opts Options) *Queue {
+	opts.defaults()
+	return &Queue{opts: opts, jobs: make(map[int64]*Job)}
+}
+
+// Enqueue adds a pending job and returns its ID.
+func (q *Queue) Enqueue(kind string, payload []byte) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	q.jobs[q.nextID] = &Job{ID: q.nextID, Kind: kind, Payload: payload, RunAt: q.opts.Clock.Now()}
+	return q.nextID
+}
+
+// Claim leases the job that has been due longest to worker for
+// visibility, and returns false if none is due.
+func (q *Queue) Claim(worker string, visibility time.Duration) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.opts.Clock.Now()
+	var next *Job
+	for _, j := range q.jobs {
+		if (j.State != Pending && j.State != Retrying) || j.RunAt.After(now) {
+			continue
+		}
+		if next == nil || cmp.Or(j.RunAt.Compare(next.RunAt), cmp.Compare(j.ID, next.ID)) < 0 {
+			next = j
+		}
+	}
+	if next == nil {
+		return Job{}, false
+	}
+	next.State = Running
+	next.Attempt++
+	next.Worker = worker
+	next.LeaseUntil = now.Add(visibility)
+	return *next, true
+}
+
+// running returns the job of attempt j if it still holds its lease.
+// q.mu must be held.
+func (q *Queue) running(j Job) (*Job, error) {
+	cur, ok := q.jobs[j.ID]
+	if !ok || cur.State != Running || cur.Attempt != j.Attempt {
+		return nil, fmt.Errorf("%w: job %d attempt %d", ErrLeaseLost, j.ID, j.Attempt)
+	}
+	return cur, nil
+}
+
+// Extend renews the lease of attempt j for visibility from now.
+func (q *Queue) Extend(j Job, visibility time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cur, err := q.running(j)
+	if err != nil {
+		return err
+	}
+	cur.LeaseUntil = q.opts.Clock.Now().Add(visibility)
+	return nil
+}
+
+// Complete marks the job of attempt j done.
+func (q *Queue) Complete(j Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cur, err := q.running(j)
+	if err != nil {
+		return err
+	}
+	cur.State, cur.Worker, cur.Err = Done, "", ""
+	return nil
+}
+
+// Fail records the failure of attempt j, scheduling a retry unless the
+// job is out of attempts.
+func (q *Queue) Fail(j Job, cause error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cur, err := q.running(j)
+	if err != nil {
+		return err
+	}
+	q.fail(cur, cause, q.opts.Clock.Now())
+	return nil
+}
+
+// fail moves a running job on after a failed attempt. q.mu must be held.
+func (q *Queue) fail(j *Job, cause error, now time.Time) {
+	j.Worker, j.Err = "", cause.Error()
+	if j.Attempt >= q.opts.MaxAttempts {
+		j.State = Failed
+		return
+	}
+	j.State = Retrying
+	j.RunAt = now.Add(q.opts.Backoff.Next(j.Attempt, 0))
+}
+
+// Reclaim fails the running attempts whose lease has expired, with
+// ErrLeaseExpired, and returns how many it found.
+func (q *Queue) Reclaim() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.opts.Clock.Now()
+	n := 0
+	for _, j := range q.jobs {
+		if j.State == Running && !now.Before(j.LeaseUntil) {
+			q.fail(j, fmt.Errorf("%w: held by %s", ErrLeaseExpired, j.Worker), now)
+			n++
+		}
+	}
+	return n
+}
+
+// Get returns the job with id.
+func (q *Queue) Get(id int64) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// Jobs returns every job in the given states, all if none are given, in
+// the order they were enqueued.
+func (q *Queue) Jobs(states ...State) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var jobs []Job
+	for _, j := range q.jobs {
+		if len(states) == 0 || slices.Contains(states, j.State) {
+			jobs = append(jobs, *j)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b Job) int { return cmp.Compare(a.ID, b.ID) })
+	return jobs
+}
+
+// Counts returns the number of jobs in each state.
+func (q *Queue) Counts() map[State]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	counts := make(map[State]int)
+	for _, j := range q.jobs {
+		counts[j.State]++
+	}
+	return counts
+}
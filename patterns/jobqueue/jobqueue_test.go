@@ -0,0 +1,300 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestLifecycle(t *testing.T) {
+	f := clock.NewFake(epoch)
+	q := New(Options{MaxAttempts: 2, Backoff: retry.Constant(time.Second), Clock: f})
+	id := q.Enqueue("email", []byte("hello"))
+
+	j, ok := q.Claim("w1", time.Minute)
+	if !ok || j.ID != id || j.State != Running || j.Attempt != 1 || j.Worker != "w1" {
+		t.Fatalf("claimed %+v, %v", j, ok)
+	}
+	if _, ok := q.Claim("w2", time.Minute); ok {
+		t.Fatal("claimed a running job")
+	}
+	if err := q.Fail(j, errors.New("smtp down")); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := q.Get(id); j.State != Retrying || j.Err != "smtp down" || !j.RunAt.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("after a failure: %+v", j)
+	}
+	if _, ok := q.Claim("w1", time.Minute); ok {
+		t.Fatal("claimed a retry before its backoff")
+	}
+
+	f.Advance(time.Second)
+	j, ok = q.Claim("w2", time.Minute)
+	if !ok || j.Attempt != 2 {
+		t.Fatalf("claimed %+v, %v", j, ok)
+	}
+	if err := q.Complete(j); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := q.Get(id); j.State != Done || j.Err != "" {
+		t.Fatalf("after success: %+v", j)
+	}
+	if err := q.Complete(j); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("second Complete = %v", err)
+	}
+}
+
+func TestOutOfAttempts(t *testing.T) {
+	f := clock.NewFake(epoch)
+	q := New(Options{MaxAttempts: 2, Backoff: retry.Constant(time.Second), Clock: f})
+	id := q.Enqueue("email", nil)
+	for range 2 {
+		j, ok := q.Claim("w1", time.Minute)
+		if !ok {
+			t.Fatal("nothing to claim")
+		}
+		q.Fail(j, errors.New("bounced"))
+		f.Advance(time.Second)
+	}
+	if j, _ := q.Get(id); j.State != Failed || j.Attempt != 2 {
+		t.Fatalf("%+v", j)
+	}
+	if _, ok := q.Claim("w1", time.Minute); ok {
+		t.Error("claimed a failed job")
+	}
+}
+
+func TestClaimOrder(t *testing.T) {
+	f := clock.NewFake(epoch)
+	q := New(Options{Backoff: retry.Constant(time.Second), Clock: f})
+	a := q.Enqueue("a", nil)
+	j, _ := q.Claim("w", time.Minute)
+	q.Fail(j, errors.New("retry me"))
+	f.Advance(time.Second)
+	b := q.Enqueue("b", nil)
+	c := q.Enqueue("c", nil)
+
+	// a has been due since its backoff ended, before b and c were enqueued.
+	var got []int64
+	for {
+		j, ok := q.Claim("w", time.Minute)
+		if !ok {
+			break
+		}
+		got = append(got, j.ID)
+	}
+	if fmt.Sprint(got) != fmt.Sprint([]int64{a, b, c}) {
+		t.Errorf("claimed %v", got)
+	}
+}
+
+func TestReclaim(t *testing.T) {
+	f := clock.NewFake(epoch)
+	q := New(Options{Backoff: retry.Constant(0), Clock: f})
+	id := q.Enqueue("report", nil)
+	stale, _ := q.Claim("crashed", 10*time.Second)
+
+	f.Advance(5 * time.Second)
+	if n := q.Reclaim(); n != 0 {
+		t.Fatalf("reclaimed %d live leases", n)
+	}
+	f.Advance(5 * time.Second)
+	if n := q.Reclaim(); n != 1 {
+		t.Fatalf("reclaimed %d, want 1", n)
+	}
+	j, _ := q.Get(id)
+	if j.State != Retrying || j.Err != "jobqueue: lease expired: held by crashed" {
+		t.Fatalf("%+v", j)
+	}
+
+	fresh, ok := q.Claim("w2", 10*time.Second)
+	if !ok || fresh.Attempt != 2 {
+		t.Fatalf("claimed %+v, %v", fresh, ok)
+	}
+	// The first worker was only slow, and comes back too late.
+	for _, err := range []error{q.Complete(stale), q.Fail(stale, errors.New("x")), q.Extend(stale, time.Minute)} {
+		if !errors.Is(err, ErrLeaseLost) {
+			t.Errorf("stale attempt: %v", err)
+		}
+	}
+	if err := q.Complete(fresh); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtend(t *testing.T) {
+	f := clock.NewFake(epoch)
+	q := New(Options{Clock: f})
+	q.Enqueue("report", nil)
+	j, _ := q.Claim("w", 10*time.Second)
+	for range 3 {
+		f.Advance(5 * time.Second)
+		if err := q.Extend(j, 10*time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if n := q.Reclaim(); n != 0 {
+			t.Fatal("reclaimed an extended lease")
+		}
+	}
+}
+
+// drive advances the clock of a started processor a poll at a time until
+// done returns true.
+func drive(t *testing.T, f *clock.Fake, done func() bool) {
+	t.Helper()
+	for range 2000 {
+		if done() {
+			return
+		}
+		f.BlockUntil(3) // the claim, extend and reap loops
+		f.Advance(100 * time.Millisecond)
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("jobs did not finish")
+}
+
+func TestProcessor(t *testing.T) {
+	f := clock.NewFake(epoch)
+	q := New(Options{Backoff: retry.Constant(time.Second), Clock: f})
+	pool := workerpool.New(2)
+	defer pool.Shutdown()
+
+	var (
+		mu              sync.Mutex
+		running, most   int
+		flaky, panicked bool
+	)
+	p := NewProcessor(q, pool, func(ctx context.Context, j Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		running++
+		most = max(most, running)
+		defer func() { running-- }()
+		switch {
+		case j.Kind == "flaky" && !flaky:
+			flaky = true
+			return errors.New("try again")
+		case j.Kind == "panics" && !panicked:
+			panicked = true
+			panic("boom")
+		}
+		return nil
+	}, ProcessorOptions{Clock: f})
+	for _, kind := range []string{"a", "flaky", "b", "panics", "c"} {
+		q.Enqueue(kind, nil)
+	}
+	p.Start()
+	defer p.Stop()
+
+	drive(t, f, func() bool { return q.Counts()[Done] == 5 })
+	for _, j := range q.Jobs() {
+		want := 1
+		if j.Kind == "flaky" || j.Kind == "panics" {
+			want = 2
+		}
+		if j.Attempt != want {
+			t.Errorf("%s: %d attempts, want %d", j.Kind, j.Attempt, want)
+		}
+	}
+	if most > 2 {
+		t.Errorf("%d jobs at once on a pool of 2", most)
+	}
+}
+
+func TestProcessorReclaimsCrashedWorker(t *testing.T) {
+	f := clock.NewFake(epoch)
+	q := New(Options{Backoff: retry.Constant(0), Clock: f})
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+	id := q.Enqueue("report", nil)
+	// Another worker claimed the job and died without a word.
+	q.Claim("crashed", 10*time.Second)
+
+	p := NewProcessor(q, pool, func(context.Context, Job) error { return nil },
+		ProcessorOptions{ID: "p1", Visibility: 10 * time.Second, Clock: f})
+	p.Start()
+	defer p.Stop()
+
+	drive(t, f, func() bool { j, _ := q.Get(id); return j.State == Done })
+	if elapsed := f.Since(epoch); elapsed < 10*time.Second {
+		t.Errorf("reclaimed after %v, within the lease", elapsed)
+	}
+	if j, _ := q.Get(id); j.Attempt != 2 {
+		t.Errorf("%+v", j)
+	}
+}
+
+func TestProcessorKeepsLeaseOfLongJob(t *testing.T) {
+	f := clock.NewFake(epoch)
+	q := New(Options{Clock: f})
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+	id := q.Enqueue("long", nil)
+
+	// The job outlasts its visibility several times over; the extend loop
+	// must keep the reaper off it.
+	started := f.Now()
+	p := NewProcessor(q, pool, func(ctx context.Context, j Job) error {
+		for f.Since(started) < 30*time.Second {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+		return nil
+	}, ProcessorOptions{Visibility: 3 * time.Second, Clock: f})
+	p.Start()
+	defer p.Stop()
+
+	drive(t, f, func() bool { j, _ := q.Get(id); return j.State == Done })
+	if j, _ := q.Get(id); j.Attempt != 1 {
+		t.Errorf("%d attempts: the lease was lost", j.Attempt)
+	}
+}
+
+func TestStopFailsRunningJobs(t *testing.T) {
+	f := clock.NewFake(epoch)
+	q := New(Options{Backoff: retry.Constant(0), Clock: f})
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+	id := q.Enqueue("long", nil)
+
+	started := make(chan struct{})
+	p := NewProcessor(q, pool, func(ctx context.Context, j Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, ProcessorOptions{Clock: f})
+	p.Start()
+	f.BlockUntil(3)
+	f.Advance(100 * time.Millisecond)
+	<-started
+	p.Stop()
+
+	if j, _ := q.Get(id); j.State != Retrying || j.Err != context.Canceled.Error() {
+		t.Errorf("%+v", j)
+	}
+}
+
+func Example() {
+	q := New(Options{})
+	q.Enqueue("email", []byte("welcome"))
+
+	j, _ := q.Claim("worker-1", time.Minute)
+	fmt.Println(j.Kind, j.State, "attempt", j.Attempt)
+	q.Complete(j)
+	j, _ = q.Get(j.ID)
+	fmt.Println(j.Kind, j.State)
+	// Output:
+	// email running attempt 1
+	// email done
+}
@@ -0,0 +1,129 @@
+package jobqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/scheduler"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// Handler runs a job. An error, or a panic, fails the attempt.
+type Handler func(ctx context.Context, j Job) error
+
+// ProcessorOptions configure a Processor.
+type ProcessorOptions struct {
+	// ID names the processor as the holder of its leases, default
+	// "processor".
+	ID string
+	// Visibility is the lease of a claim, default 30s. It is extended
+	// every third of it while the job runs.
+	Visibility time.Duration
+	// Poll is how often free goroutines of the pool claim jobs, default
+	// 100ms.
+	Poll time.Duration
+	// Reap is how often expired leases are reclaimed, default half of
+	// Visibility.
+	Reap time.Duration
+	// Clock is the scheduler's; nil uses clock.Real.
+	Clock scheduler.Clock
+}
+
+func (o *ProcessorOptions) defaults() {
+	if o.ID == "" {
+		o.ID = "processor"
+	}
+	if o.Visibility <= 0 {
+		o.Visibility = 30 * time.Second
+	}
+	if o.Poll <= 0 {
+		o.Poll = 100 * time.Millisecond
+	}
+	if o.Reap <= 0 {
+		o.Reap = o.Visibility / 2
+	}
+}
+
+// Processor runs the jobs of a Queue on a Pool, at most as many at once as
+// the pool has goroutines.
+type Processor struct {
+	q       *Queue
+	pool    *workerpool.Pool
+	handler Handler
+	opts    ProcessorOptions
+	sched   *scheduler.Scheduler
+
+	mu       sync.Mutex
+	inflight map[int64]Job
+	runs     sync.WaitGroup
+}
+
+// NewProcessor returns a Processor running the jobs of q with handler on
+// pool. The pool is the caller's to shut down.
+func NewProcessor(q *Queue, pool *workerpool.Pool, handler Handler, opts ProcessorOptions) *Processor {
+	opts.defaults()
+	p := &Processor{q: q, pool: pool, handler: handler, opts: opts, inflight: make(map[int64]Job)}
+	p.sched = scheduler.New(opts.Clock)
+	p.sched.Add("claim", scheduler.Every(opts.Poll), scheduler.Skip, p.claim)
+	p.sched.Add("extend", scheduler.Every(opts.Visibility/3), scheduler.Skip, p.extend)
+	p.sched.Add("reap", scheduler.Every(opts.Reap), scheduler.Skip, func(context.Context) { q.Reclaim() })
+	return p
+}
+
+// Start begins processing.
+func (p *Processor) Start() { p.sched.Start() }
+
+// Stop stops claiming, cancels the context of the running jobs and waits
+// for them to return. Their attempts fail and are retried, by this
+// processor once started again or by another.
+func (p *Processor) Stop() {
+	p.sched.Stop()
+	p.runs.Wait()
+}
+
+// claim takes as many jobs as the pool has room for.
+func (p *Processor) claim(ctx context.Context) {
+	for ctx.Err() == nil {
+		p.mu.Lock()
+		if len(p.inflight) >= p.pool.Size() {
+			p.mu.Unlock()
+			return
+		}
+		j, ok := p.q.Claim(p.opts.ID, p.opts.Visibility)
+		if !ok {
+			p.mu.Unlock()
+			return
+		}
+		p.inflight[j.ID] = j
+		p.mu.Unlock()
+		p.runs.Add(1)
+		go p.run(ctx, j)
+	}
+}
+
+func (p *Processor) run(ctx context.Context, j Job) {
+	defer p.runs.Done()
+	err := p.pool.Run(ctx, workerpool.WorkerFunc(func(ctx context.Context) error {
+		return p.handler(ctx, j)
+	}))
+	p.mu.Lock()
+	delete(p.inflight, j.ID)
+	p.mu.Unlock()
+	// A refusal means the lease was lost and the job is someone else's
+	// now; there is nothing left to record.
+	if err != nil {
+		p.q.Fail(j, err)
+	} else {
+		p.q.Complete(j)
+	}
+}
+
+// extend renews the leases of the running jobs.
+func (p *Processor) extend(context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, j := range p.inflight {
+		p.q.Extend(j, p.opts.Visibility)
+	}
+}
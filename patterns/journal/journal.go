@@ -0,0 +1,317 @@
+// Package journal persists in-memory state as periodic snapshots plus a
+// journal of the operations since, a write-ahead log.
+//
+// Every operation is appended to the journal and synced before Do returns,
+// so none is lost in a crash. Replaying a journal from the beginning of
+// time gets slow, so now and then the whole state is written as a
+// snapshot and the journal is emptied. Restoring is then: load the
+// snapshot, replay the tail of the journal.
+//
+// The two steps of a snapshot cannot happen atomically, so every record
+// carries a sequence number and the snapshot records the last one it
+// includes. A crash after the snapshot is in place but before the journal
+// is truncated leaves records the snapshot already covers; Open skips
+// them instead of applying them twice. A crash while the snapshot is being
+// written leaves only a temporary file, and the old snapshot with the full
+// journal still describe the state. A record torn by a crash during Do
+// fails its checksum and is cut off.
+//
+// State and operations are stored as JSON.
+package journal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	// ErrClosed is returned after Close.
+	ErrClosed = errors.New("journal: closed")
+	// ErrReplay is returned by Open when a journaled operation fails to
+	// apply.
+	ErrReplay = errors.New("journal: replay failed")
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	journalFile  = "journal"
+	frame        = 8 // length and checksum
+)
+
+// Apply applies op to state. It must be deterministic, since it runs again
+// on every restore, and leave state unchanged when it returns an error.
+type Apply[S, O any] func(state *S, op O) error
+
+// Options configures a Journal.
+type Options struct {
+	// SnapshotEvery takes a snapshot after that many operations. With 0
+	// snapshots are only taken by calling Snapshot.
+	SnapshotEvery int
+}
+
+type snapshot[S any] struct {
+	Seq   uint64 `json:"seq"`
+	State S      `json:"state"`
+}
+
+type record[O any] struct {
+	Seq uint64 `json:"seq"`
+	Op  O      `json:"op"`
+}
+
+// Journal holds state of type S changed by operations of type O. It is
+// safe for concurrent use.
+type Journal[S, O any] struct {
+	dir   string
+	apply Apply[S, O]
+	opts  Options
+
+	mu     sync.Mutex
+	state  S
+	seq    uint64 // last operation applied
+	since  int    // operations since the last snapshot
+	f      *os.File
+	end    int64
+	broken error
+}
+
+// Open restores the state stored in dir, creating dir if needed. Without
+// a snapshot the state starts from initial.
+func Open[S, O any](dir string, initial S, apply Apply[S, O], opts Options) (*Journal[S, O], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	j := &Journal[S, O]{dir: dir, apply: apply, opts: opts, state: initial}
+	if err := j.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	j.f = f
+	if err := j.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Journal[S, O]) loadSnapshot() error {
+	b, err := os.ReadFile(filepath.Join(j.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Decode into a zero S: decoding into initial would merge maps.
+	var snap snapshot[S]
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return fmt.Errorf("journal: snapshot: %w", err)
+	}
+	j.state, j.seq = snap.State, snap.Seq
+	return nil
+}
+
+// replay applies the records after the snapshot and cuts off a torn tail.
+func (j *Journal[S, O]) replay() error {
+	info, err := j.f.Stat()
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(io.NewSectionReader(j.f, 0, info.Size()))
+	var off int64
+	for {
+		data, err := readRecord(r)
+		if err != nil {
+			break
+		}
+		var rec record[O]
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("%w: %v", ErrReplay, err)
+		}
+		off += frame + int64(len(data))
+		if rec.Seq <= j.seq {
+			// Covered by the snapshot, the journal was not truncated.
+			continue
+		}
+		if err := j.apply(&j.state, rec.Op); err != nil {
+			return fmt.Errorf("%w: operation %d: %v", ErrReplay, rec.Seq, err)
+		}
+		j.seq = rec.Seq
+		j.since++
+	}
+	j.end = off
+	if off < info.Size() {
+		return j.f.Truncate(off)
+	}
+	return nil
+}
+
+func readRecord(r io.Reader) ([]byte, error) {
+	var hdr [frame]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(hdr[:4])
+	if n > 64<<20 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(hdr[4:]) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+// Do applies op and journals it. An operation that fails to apply is not
+// journaled. If journaling fails, the state in memory is ahead of the disk
+// and the Journal refuses further operations. An error from an automatic
+// snapshot does not undo op, which is journaled by then.
+func (j *Journal[S, O]) Do(op O) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return ErrClosed
+	}
+	if j.broken != nil {
+		return j.broken
+	}
+	data, err := json.Marshal(record[O]{Seq: j.seq + 1, Op: op})
+	if err != nil {
+		return err
+	}
+	if err := j.apply(&j.state, op); err != nil {
+		return err
+	}
+	j.seq++
+	if err := j.append(data); err != nil {
+		j.broken = fmt.Errorf("journal: %w", err)
+		return j.broken
+	}
+	j.since++
+	if j.opts.SnapshotEvery > 0 && j.since >= j.opts.SnapshotEvery {
+		return j.snapshot()
+	}
+	return nil
+}
+
+func (j *Journal[S, O]) append(data []byte) error {
+	rec := make([]byte, frame+len(data))
+	binary.LittleEndian.PutUint32(rec, uint32(len(data)))
+	binary.LittleEndian.PutUint32(rec[4:], crc32.ChecksumIEEE(data))
+	copy(rec[frame:], data)
+	if _, err := j.f.WriteAt(rec, j.end); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	j.end += int64(len(rec))
+	return nil
+}
+
+// Snapshot writes the state as a snapshot and empties the journal.
+func (j *Journal[S, O]) Snapshot() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return ErrClosed
+	}
+	return j.snapshot()
+}
+
+func (j *Journal[S, O]) snapshot() error {
+	if err := j.writeSnapshot(); err != nil {
+		return err
+	}
+	return j.truncate()
+}
+
+// writeSnapshot replaces the snapshot atomically.
+func (j *Journal[S, O]) writeSnapshot() error {
+	data, err := json.Marshal(snapshot[S]{Seq: j.seq, State: j.state})
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(j.dir, snapshotFile)
+	tmp, err := os.CreateTemp(j.dir, snapshotFile+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(j.dir)
+}
+
+func (j *Journal[S, O]) truncate() error {
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	j.end, j.since = 0, 0
+	return nil
+}
+
+// syncDir makes a rename in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Read calls fn with the state. fn must not keep or modify it.
+func (j *Journal[S, O]) Read(fn func(state *S)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.state)
+}
+
+// Seq returns the sequence number of the last operation applied.
+func (j *Journal[S, O]) Seq() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seq
+}
+
+// Close closes the journal. It does not take a snapshot.
+func (j *Journal[S, O]) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return ErrClosed
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
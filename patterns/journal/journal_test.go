@@ -0,0 +1,248 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// KV is a key-value store, the kind of state a cache or a read model
+// keeps in memory.
+type KV map[string]int
+
+// Op sets Key to Value, or adds Value to it with Add. Adding is not
+// idempotent, so an operation applied twice shows.
+type Op struct {
+	Key   string `json:"key"`
+	Value int    `json:"value"`
+	Add   bool   `json:"add,omitempty"`
+}
+
+func apply(kv *KV, op Op) error {
+	if op.Key == "" {
+		return errors.New("empty key")
+	}
+	if *kv == nil {
+		*kv = KV{}
+	}
+	if op.Add {
+		(*kv)[op.Key] += op.Value
+	} else {
+		(*kv)[op.Key] = op.Value
+	}
+	return nil
+}
+
+func open(t *testing.T, dir string, opts Options) *Journal[KV, Op] {
+	t.Helper()
+	j, err := Open(dir, KV{}, apply, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func state(j *Journal[KV, Op]) KV {
+	var kv KV
+	j.Read(func(s *KV) { kv = maps.Clone(*s) })
+	return kv
+}
+
+func do(t *testing.T, j *Journal[KV, Op], ops ...Op) {
+	t.Helper()
+	for _, op := range ops {
+		if err := j.Do(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func records(t *testing.T, dir string) int {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, journalFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	n := 0
+	for {
+		if _, err := readRecord(f); err != nil {
+			return n
+		}
+		n++
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	dir := t.TempDir()
+	j := open(t, dir, Options{})
+	do(t, j, Op{Key: "a", Value: 1}, Op{Key: "a", Value: 2, Add: true}, Op{Key: "b", Value: 5})
+	j.Close()
+
+	j = open(t, dir, Options{})
+	defer j.Close()
+	if got := state(j); !maps.Equal(got, KV{"a": 3, "b": 5}) || j.Seq() != 3 {
+		t.Errorf("restored %v at %d", got, j.Seq())
+	}
+}
+
+func TestSnapshotThenTail(t *testing.T) {
+	dir := t.TempDir()
+	j := open(t, dir, Options{})
+	do(t, j, Op{Key: "a", Value: 1}, Op{Key: "b", Value: 2})
+	if err := j.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	do(t, j, Op{Key: "a", Value: 10, Add: true})
+	j.Close()
+
+	if n := records(t, dir); n != 1 {
+		t.Errorf("journal holds %d records after the snapshot, want 1", n)
+	}
+	j = open(t, dir, Options{})
+	defer j.Close()
+	if got := state(j); !maps.Equal(got, KV{"a": 11, "b": 2}) || j.Seq() != 3 {
+		t.Errorf("restored %v at %d", got, j.Seq())
+	}
+}
+
+func TestDeletedKeysStayDeleted(t *testing.T) {
+	// A snapshot is the whole state, not merged into initial.
+	dir := t.TempDir()
+	del := func(kv *KV, key string) error { delete(*kv, key); return nil }
+	j, _ := Open(dir, KV{"seed": 1}, del, Options{})
+	if err := j.Do("seed"); err != nil {
+		t.Fatal(err)
+	}
+	j.Snapshot()
+	j.Close()
+	j, _ = Open(dir, KV{"seed": 1}, del, Options{})
+	defer j.Close()
+	var kv KV
+	j.Read(func(s *KV) { kv = *s })
+	if len(kv) != 0 {
+		t.Errorf("restored %v", kv)
+	}
+}
+
+// TestCrashBeforeTruncate stops a snapshot between writing it and
+// truncating the journal, as a crash would.
+func TestCrashBeforeTruncate(t *testing.T) {
+	dir := t.TempDir()
+	j := open(t, dir, Options{})
+	do(t, j, Op{Key: "n", Value: 1, Add: true}, Op{Key: "n", Value: 1, Add: true})
+	j.mu.Lock()
+	if err := j.writeSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	j.mu.Unlock()
+	j.Close()
+	if records(t, dir) != 2 {
+		t.Fatal("the journal should still hold the covered records")
+	}
+
+	j = open(t, dir, Options{})
+	if got := state(j); got["n"] != 2 || j.Seq() != 2 {
+		t.Fatalf("restored %v at %d: records applied twice", got, j.Seq())
+	}
+	// New operations continue the sequence and survive the next restart.
+	do(t, j, Op{Key: "n", Value: 1, Add: true})
+	j.Close()
+	j = open(t, dir, Options{})
+	defer j.Close()
+	if got := state(j); got["n"] != 3 || j.Seq() != 3 {
+		t.Errorf("restored %v at %d", got, j.Seq())
+	}
+}
+
+func TestCrashWhileWritingSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	j := open(t, dir, Options{})
+	do(t, j, Op{Key: "a", Value: 1})
+	j.Snapshot()
+	do(t, j, Op{Key: "a", Value: 2})
+	j.Close()
+	// A half-written temporary snapshot is left behind.
+	os.WriteFile(filepath.Join(dir, snapshotFile+".tmp123"), []byte(`{"seq":9,"sta`), 0o644)
+
+	j = open(t, dir, Options{})
+	defer j.Close()
+	if got := state(j); got["a"] != 2 {
+		t.Errorf("restored %v", got)
+	}
+}
+
+func TestTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	j := open(t, dir, Options{})
+	do(t, j, Op{Key: "a", Value: 1}, Op{Key: "b", Value: 2})
+	j.Close()
+
+	// Cut the last record short.
+	path := filepath.Join(dir, journalFile)
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()-3)
+
+	j = open(t, dir, Options{})
+	if got := state(j); !maps.Equal(got, KV{"a": 1}) {
+		t.Fatalf("restored %v", got)
+	}
+	do(t, j, Op{Key: "c", Value: 3})
+	j.Close()
+	j = open(t, dir, Options{})
+	defer j.Close()
+	if got := state(j); !maps.Equal(got, KV{"a": 1, "c": 3}) {
+		t.Errorf("after append past the cut: %v", got)
+	}
+}
+
+func TestSnapshotEvery(t *testing.T) {
+	dir := t.TempDir()
+	j := open(t, dir, Options{SnapshotEvery: 10})
+	for i := 0; i < 25; i++ {
+		do(t, j, Op{Key: "n", Value: 1, Add: true})
+	}
+	j.Close()
+	if n := records(t, dir); n != 5 {
+		t.Errorf("journal holds %d records, want 5", n)
+	}
+	j = open(t, dir, Options{SnapshotEvery: 10})
+	defer j.Close()
+	if got := state(j); got["n"] != 25 {
+		t.Errorf("restored %v", got)
+	}
+}
+
+func TestFailedOpNotJournaled(t *testing.T) {
+	dir := t.TempDir()
+	j := open(t, dir, Options{})
+	if err := j.Do(Op{Value: 1}); err == nil {
+		t.Fatal("Do accepted an empty key")
+	}
+	j.Close()
+	if records(t, dir) != 0 {
+		t.Error("a failed operation was journaled")
+	}
+	if err := j.Do(Op{Key: "a"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Do after Close = %v", err)
+	}
+}
+
+func Example() {
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+
+	j, _ := Open(dir, KV{}, apply, Options{SnapshotEvery: 100})
+	j.Do(Op{Key: "visits", Value: 1, Add: true})
+	j.Do(Op{Key: "visits", Value: 1, Add: true})
+	j.Close()
+
+	// After a restart the state is back.
+	j, _ = Open(dir, KV{}, apply, Options{SnapshotEvery: 100})
+	defer j.Close()
+	j.Read(func(kv *KV) { fmt.Println((*kv)["visits"]) })
+	// Output: 2
+}
@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// MemStore is a Store in memory.
+type MemStore struct {
+	mu    sync.Mutex
+	owner string
+	recs  map[int]Record
+}
+
+// NewMemStore returns an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{recs: map[int]Record{}}
+}
+
+func (s *MemStore) Lock(_ context.Context, owner string) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner != "" {
+		return nil, fmt.Errorf("%w: held by %s", ErrLocked, s.owner)
+	}
+	s.owner = owner
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.owner = ""
+			s.mu.Unlock()
+		})
+	}, nil
+}
+
+func (s *MemStore) Applied(context.Context) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recs := make([]Record, 0, len(s.recs))
+	for _, r := range s.recs {
+		recs = append(recs, r)
+	}
+	slices.SortFunc(recs, func(a, b Record) int { return a.Version - b.Version })
+	return recs, nil
+}
+
+func (s *MemStore) Put(_ context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recs[rec.Version] = rec
+	return nil
+}
+
+func (s *MemStore) Delete(_ context.Context, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.recs, version)
+	return nil
+}
@@ -0,0 +1,336 @@
+// Package migrate applies ordered schema migrations and rolls them back.
+//
+// Migrations are registered in a Registry with increasing versions. Each
+// has an Up and, optionally, a Down step run against a data handle D, and a
+// Source, such as its SQL, whose checksum is recorded when it is applied.
+// A Runner compares the registry with the records in a Store and works out
+// a Plan: the steps up or down to reach a target version. Plan only
+// reports that; Migrate carries it out.
+//
+// The Runner refuses to go ahead when the history does not add up:
+//
+//   - a migration that was applied has since been edited (ErrChecksum) or
+//     removed (ErrUnknown);
+//   - a migration older than the newest applied one was never applied,
+//     typically because it arrived in a late merge (ErrOutOfOrder, unless
+//     Options.AllowOutOfOrder is set);
+//   - an earlier run failed half way through a step (ErrDirty).
+//
+// A step is recorded as dirty before it runs and clean after. If Up fails,
+// the Runner runs Down to undo whatever part of it took effect and drops
+// the record. Only if there is no Down, or it fails too, does the record
+// stay dirty, and then the next run stops with ErrDirty until Force
+// settles it by hand.
+//
+// A Runner holds the Store's lock for the whole of Migrate, so two
+// deployments starting at once do not both run the same steps; the second
+// gets ErrLocked.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrDuplicate is returned by Register for a version already taken.
+	ErrDuplicate = errors.New("migrate: duplicate version")
+	// ErrChecksum is returned when an applied migration has changed since.
+	ErrChecksum = errors.New("migrate: checksum mismatch")
+	// ErrUnknown is returned when an applied migration is not registered.
+	ErrUnknown = errors.New("migrate: applied migration not registered")
+	// ErrOutOfOrder is returned for a pending migration older than the
+	// newest applied one.
+	ErrOutOfOrder = errors.New("migrate: migration out of order")
+	// ErrDirty is returned when an earlier run failed part way through a
+	// step.
+	ErrDirty = errors.New("migrate: dirty migration")
+	// ErrIrreversible is returned when rolling back a migration with no
+	// Down step.
+	ErrIrreversible = errors.New("migrate: migration has no down step")
+	// ErrLocked is returned when another runner holds the lock.
+	ErrLocked = errors.New("migrate: locked by another runner")
+)
+
+// Latest is the target version meaning every registered migration.
+const Latest = math.MaxInt
+
+// Migration is one step of a schema's history.
+type Migration[D any] struct {
+	Version int
+	Name    string
+	// Source is what the migration does, such as its SQL. Its checksum is
+	// recorded, so editing an applied migration is caught.
+	Source string
+	Up     func(ctx context.Context, db D) error
+	// Down undoes Up. It must also cope with an Up that failed part way.
+	Down func(ctx context.Context, db D) error
+}
+
+// Checksum returns the checksum recorded for m.
+func (m Migration[D]) Checksum() string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d\x00%s\x00%s", m.Version, m.Name, m.Source))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Registry holds migrations in version order.
+type Registry[D any] struct {
+	ms []Migration[D]
+}
+
+// Register adds m.
+func (r *Registry[D]) Register(m Migration[D]) error {
+	i, found := slices.BinarySearchFunc(r.ms, m.Version, func(m Migration[D], v int) int { return m.Version - v })
+	if found {
+		return fmt.Errorf("%w %d: %q and %q", ErrDuplicate, m.Version, r.ms[i].Name, m.Name)
+	}
+	if m.Version <= 0 || m.Up == nil {
+		return fmt.Errorf("migrate: migration %d %q needs a positive version and an Up step", m.Version, m.Name)
+	}
+	r.ms = slices.Insert(r.ms, i, m)
+	return nil
+}
+
+// MustRegister is like Register but panics on error.
+func (r *Registry[D]) MustRegister(m Migration[D]) {
+	if err := r.Register(m); err != nil {
+		panic(err)
+	}
+}
+
+// Record is what a Store keeps about an applied migration.
+type Record struct {
+	Version   int
+	Name      string
+	Checksum  string
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+// Store keeps the records of applied migrations.
+type Store interface {
+	// Lock takes the migration lock for owner, or fails with ErrLocked.
+	Lock(ctx context.Context, owner string) (unlock func(), err error)
+	// Applied returns the records in version order.
+	Applied(ctx context.Context) ([]Record, error)
+	// Put adds or replaces the record of rec.Version.
+	Put(ctx context.Context, rec Record) error
+	// Delete removes the record of version.
+	Delete(ctx context.Context, version int) error
+}
+
+// Direction says whether a Step applies or rolls back a migration.
+type Direction int
+
+const (
+	Up Direction = iota
+	Down
+)
+
+func (d Direction) String() string {
+	if d == Down {
+		return "down"
+	}
+	return "up"
+}
+
+// Step is one migration to run.
+type Step struct {
+	Version int
+	Name    string
+	Dir     Direction
+}
+
+func (s Step) String() string { return fmt.Sprintf("%s %d %s", s.Dir, s.Version, s.Name) }
+
+// Plan is the steps to reach a target version, in order.
+type Plan []Step
+
+// Clock tells the time recorded with migrations.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Options configures a Runner.
+type Options struct {
+	// Owner identifies the runner to the lock. It defaults to "migrate".
+	Owner string
+	// AllowOutOfOrder applies pending migrations older than the newest
+	// applied one instead of failing with ErrOutOfOrder.
+	AllowOutOfOrder bool
+	// Clock defaults to the wall clock.
+	Clock Clock
+}
+
+func (o *Options) defaults() {
+	if o.Owner == "" {
+		o.Owner = "migrate"
+	}
+	if o.Clock == nil {
+		o.Clock = realClock{}
+	}
+}
+
+// Runner migrates db according to a registry, keeping records in a store.
+type Runner[D any] struct {
+	reg   *Registry[D]
+	store Store
+	db    D
+	opts  Options
+}
+
+// NewRunner returns a Runner.
+func NewRunner[D any](reg *Registry[D], store Store, db D, opts Options) *Runner[D] {
+	opts.defaults()
+	return &Runner[D]{reg: reg, store: store, db: db, opts: opts}
+}
+
+// Plan returns the steps Migrate would run to reach target, without
+// running them or taking the lock.
+func (r *Runner[D]) Plan(ctx context.Context, target int) (Plan, error) {
+	recs, err := r.store.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.plan(recs, target)
+}
+
+func (r *Runner[D]) plan(recs []Record, target int) (Plan, error) {
+	applied := map[int]bool{}
+	newest := 0
+	for _, rec := range recs {
+		if rec.Dirty {
+			return nil, fmt.Errorf("%w: %d %s", ErrDirty, rec.Version, rec.Name)
+		}
+		m, ok := r.find(rec.Version)
+		if !ok {
+			return nil, fmt.Errorf("%w: %d %s", ErrUnknown, rec.Version, rec.Name)
+		}
+		if m.Checksum() != rec.Checksum {
+			return nil, fmt.Errorf("%w: %d %s was edited after it was applied", ErrChecksum, rec.Version, rec.Name)
+		}
+		applied[rec.Version] = true
+		newest = max(newest, rec.Version)
+	}
+
+	var plan Plan
+	for _, m := range r.reg.ms {
+		if m.Version > target || applied[m.Version] {
+			continue
+		}
+		if m.Version < newest && !r.opts.AllowOutOfOrder {
+			return nil, fmt.Errorf("%w: %d %s is pending but %d is applied", ErrOutOfOrder, m.Version, m.Name, newest)
+		}
+		plan = append(plan, Step{m.Version, m.Name, Up})
+	}
+	for i := len(recs) - 1; i >= 0; i-- {
+		if v := recs[i].Version; v > target {
+			m, _ := r.find(v)
+			if m.Down == nil {
+				return nil, fmt.Errorf("%w: %d %s", ErrIrreversible, m.Version, m.Name)
+			}
+			plan = append(plan, Step{m.Version, m.Name, Down})
+		}
+	}
+	return plan, nil
+}
+
+func (r *Runner[D]) find(v int) (Migration[D], bool) {
+	i, ok := slices.BinarySearchFunc(r.reg.ms, v, func(m Migration[D], v int) int { return m.Version - v })
+	if !ok {
+		return Migration[D]{}, false
+	}
+	return r.reg.ms[i], true
+}
+
+// Migrate brings db to target, which is a version or Latest, and returns
+// the steps it completed. It stops at the first step that fails.
+func (r *Runner[D]) Migrate(ctx context.Context, target int) (Plan, error) {
+	unlock, err := r.store.Lock(ctx, r.opts.Owner)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	plan, err := r.Plan(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	var done Plan
+	for _, s := range plan {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		m, _ := r.find(s.Version)
+		if s.Dir == Up {
+			err = r.up(ctx, m)
+		} else {
+			err = r.down(ctx, m)
+		}
+		if err != nil {
+			return done, fmt.Errorf("migrate: %s: %w", s, err)
+		}
+		done = append(done, s)
+	}
+	return done, nil
+}
+
+func (r *Runner[D]) up(ctx context.Context, m Migration[D]) error {
+	rec := Record{Version: m.Version, Name: m.Name, Checksum: m.Checksum(), Dirty: true, AppliedAt: r.opts.Clock.Now()}
+	if err := r.store.Put(ctx, rec); err != nil {
+		return err
+	}
+	if err := m.Up(ctx, r.db); err != nil {
+		if m.Down == nil {
+			return err
+		}
+		if derr := m.Down(ctx, r.db); derr != nil {
+			return fmt.Errorf("%w; undoing it failed too: %v", err, derr)
+		}
+		if derr := r.store.Delete(ctx, m.Version); derr != nil {
+			return fmt.Errorf("%w; undone, but the record stays: %v", err, derr)
+		}
+		return err
+	}
+	rec.Dirty = false
+	return r.store.Put(ctx, rec)
+}
+
+func (r *Runner[D]) down(ctx context.Context, m Migration[D]) error {
+	rec := Record{Version: m.Version, Name: m.Name, Checksum: m.Checksum(), Dirty: true, AppliedAt: r.opts.Clock.Now()}
+	if err := r.store.Put(ctx, rec); err != nil {
+		return err
+	}
+	if err := m.Down(ctx, r.db); err != nil {
+		return err
+	}
+	return r.store.Delete(ctx, m.Version)
+}
+
+// Force settles a dirty record after it has been dealt with by hand: as
+// applied if applied is true, otherwise as not applied.
+func (r *Runner[D]) Force(ctx context.Context, version int, applied bool) error {
+	unlock, err := r.store.Lock(ctx, r.opts.Owner)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if !applied {
+		return r.store.Delete(ctx, version)
+	}
+	m, ok := r.find(version)
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknown, version)
+	}
+	return r.store.Put(ctx, Record{Version: m.Version, Name: m.Name, Checksum: m.Checksum(), AppliedAt: r.opts.Clock.Now()})
+}
@@ -0,0 +1,315 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// db is an in-memory database of tables of rows.
+type db struct {
+	tables map[string][]map[string]string
+}
+
+func newDB() *db { return &db{tables: map[string][]map[string]string{}} }
+
+func (d *db) column(table, col string) []string {
+	var vs []string
+	for _, row := range d.tables[table] {
+		vs = append(vs, row[col])
+	}
+	return vs
+}
+
+func registry() *Registry[*db] {
+	var r Registry[*db]
+	r.MustRegister(Migration[*db]{
+		Version: 1, Name: "create users", Source: "CREATE TABLE users (name)",
+		Up: func(_ context.Context, d *db) error {
+			d.tables["users"] = []map[string]string{{"name": "ann"}, {"name": "bob"}}
+			return nil
+		},
+		Down: func(_ context.Context, d *db) error {
+			delete(d.tables, "users")
+			return nil
+		},
+	})
+	r.MustRegister(Migration[*db]{
+		Version: 2, Name: "add email", Source: "ALTER TABLE users ADD email",
+		Up: func(_ context.Context, d *db) error {
+			for _, row := range d.tables["users"] {
+				row["email"] = row["name"] + "@example.com"
+			}
+			return nil
+		},
+		Down: func(_ context.Context, d *db) error {
+			for _, row := range d.tables["users"] {
+				delete(row, "email")
+			}
+			return nil
+		},
+	})
+	return &r
+}
+
+// upper uppercases names. Its Up fails at row failAt, if there is one, and
+// its Down fails if downFails is set.
+func upper(failAt int, downFails bool) Migration[*db] {
+	return Migration[*db]{
+		Version: 3, Name: "uppercase names", Source: "UPDATE users SET name = upper(name)",
+		Up: func(_ context.Context, d *db) error {
+			for i, row := range d.tables["users"] {
+				if i == failAt {
+					return errors.New("disk full")
+				}
+				row["name"] = strings.ToUpper(row["name"])
+			}
+			return nil
+		},
+		Down: func(_ context.Context, d *db) error {
+			if downFails {
+				return errors.New("still full")
+			}
+			for _, row := range d.tables["users"] {
+				row["name"] = strings.ToLower(row["name"])
+			}
+			return nil
+		},
+	}
+}
+
+func versions(t *testing.T, s Store) []int {
+	t.Helper()
+	recs, err := s.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vs []int
+	for _, r := range recs {
+		vs = append(vs, r.Version)
+	}
+	return vs
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	d, store := newDB(), NewMemStore()
+	r := NewRunner(registry(), store, d, Options{})
+
+	done, err := r.Migrate(ctx, Latest)
+	if err != nil || fmt.Sprint(done) != "[up 1 create users up 2 add email]" {
+		t.Fatalf("Migrate = %v, %v", done, err)
+	}
+	if got := d.column("users", "email"); !slices.Equal(got, []string{"ann@example.com", "bob@example.com"}) {
+		t.Errorf("emails %q", got)
+	}
+	if plan, _ := r.Plan(ctx, Latest); len(plan) != 0 {
+		t.Errorf("plan after migrating: %v", plan)
+	}
+
+	done, err = r.Migrate(ctx, 0)
+	if err != nil || fmt.Sprint(done) != "[down 2 add email down 1 create users]" {
+		t.Fatalf("Migrate down = %v, %v", done, err)
+	}
+	if len(d.tables) != 0 || len(versions(t, store)) != 0 {
+		t.Errorf("after rolling back: tables %v, records %v", d.tables, versions(t, store))
+	}
+}
+
+func TestPlanIsDry(t *testing.T) {
+	d, store := newDB(), NewMemStore()
+	r := NewRunner(registry(), store, d, Options{})
+	plan, err := r.Plan(context.Background(), 1)
+	if err != nil || fmt.Sprint(plan) != "[up 1 create users]" {
+		t.Fatalf("Plan = %v, %v", plan, err)
+	}
+	if len(d.tables) != 0 || len(versions(t, store)) != 0 {
+		t.Error("Plan changed something")
+	}
+}
+
+func TestFailedStepIsUndone(t *testing.T) {
+	ctx := context.Background()
+	d, store := newDB(), NewMemStore()
+	reg := registry()
+	reg.MustRegister(upper(1, false))
+	r := NewRunner(reg, store, d, Options{})
+
+	done, err := r.Migrate(ctx, Latest)
+	if err == nil || len(done) != 2 {
+		t.Fatalf("Migrate = %v, %v; want two steps then an error", done, err)
+	}
+	// The first row was changed before the failure, and changed back.
+	if got := d.column("users", "name"); !slices.Equal(got, []string{"ann", "bob"}) {
+		t.Errorf("names %q", got)
+	}
+	if got := versions(t, store); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("records %v", got)
+	}
+}
+
+func TestDirtyUntilForced(t *testing.T) {
+	ctx := context.Background()
+	d, store := newDB(), NewMemStore()
+	reg := registry()
+	reg.MustRegister(upper(1, true))
+	r := NewRunner(reg, store, d, Options{})
+
+	if _, err := r.Migrate(ctx, Latest); err == nil {
+		t.Fatal("Migrate succeeded")
+	}
+	if _, err := r.Migrate(ctx, Latest); !errors.Is(err, ErrDirty) {
+		t.Fatalf("second Migrate = %v, want ErrDirty", err)
+	}
+
+	// An operator restores the names by hand and marks 3 as not applied.
+	d.tables["users"][0]["name"] = "ann"
+	if err := r.Force(ctx, 3, false); err != nil {
+		t.Fatal(err)
+	}
+	fixed := registry()
+	fixed.MustRegister(upper(-1, false))
+	if _, err := NewRunner(fixed, store, d, Options{}).Migrate(ctx, Latest); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.column("users", "name"); !slices.Equal(got, []string{"ANN", "BOB"}) {
+		t.Errorf("names %q", got)
+	}
+}
+
+func TestOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+	d, store := newDB(), NewMemStore()
+	early := registry()
+	early.MustRegister(upper(-1, false))
+	// 2 is not yet merged when 1 and 3 are applied.
+	early.ms = slices.DeleteFunc(early.ms, func(m Migration[*db]) bool { return m.Version == 2 })
+	if _, err := NewRunner(early, store, d, Options{}).Migrate(ctx, Latest); err != nil {
+		t.Fatal(err)
+	}
+
+	full := registry()
+	full.MustRegister(upper(-1, false))
+	if _, err := NewRunner(full, store, d, Options{}).Migrate(ctx, Latest); !errors.Is(err, ErrOutOfOrder) {
+		t.Fatalf("Migrate = %v, want ErrOutOfOrder", err)
+	}
+	done, err := NewRunner(full, store, d, Options{AllowOutOfOrder: true}).Migrate(ctx, Latest)
+	if err != nil || fmt.Sprint(done) != "[up 2 add email]" {
+		t.Errorf("Migrate allowing out of order = %v, %v", done, err)
+	}
+}
+
+func TestHistoryMismatch(t *testing.T) {
+	ctx := context.Background()
+	d, store := newDB(), NewMemStore()
+	if _, err := NewRunner(registry(), store, d, Options{}).Migrate(ctx, Latest); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := registry()
+	edited.ms[1].Source = "ALTER TABLE users ADD email NOT NULL"
+	if _, err := NewRunner(edited, store, d, Options{}).Plan(ctx, Latest); !errors.Is(err, ErrChecksum) {
+		t.Errorf("edited migration: %v, want ErrChecksum", err)
+	}
+	removed := registry()
+	removed.ms = removed.ms[:1]
+	if _, err := NewRunner(removed, store, d, Options{}).Plan(ctx, Latest); !errors.Is(err, ErrUnknown) {
+		t.Errorf("removed migration: %v, want ErrUnknown", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	r := registry()
+	if err := r.Register(Migration[*db]{Version: 2, Name: "again", Up: upper(-1, false).Up}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate: %v", err)
+	}
+	if err := r.Register(Migration[*db]{Version: 9, Name: "no up"}); err == nil {
+		t.Error("registered a migration without Up")
+	}
+}
+
+func TestIrreversible(t *testing.T) {
+	ctx := context.Background()
+	reg := registry()
+	m := upper(-1, false)
+	m.Down = nil
+	reg.MustRegister(m)
+	r := NewRunner(reg, NewMemStore(), newDB(), Options{})
+	if _, err := r.Migrate(ctx, Latest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Migrate(ctx, 1); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("rolling back past 3 = %v, want ErrIrreversible", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	d, store := newDB(), NewMemStore()
+	started, release := make(chan struct{}), make(chan struct{})
+	reg := registry()
+	reg.MustRegister(Migration[*db]{
+		Version: 3, Name: "slow", Up: func(context.Context, *db) error {
+			close(started)
+			<-release
+			return nil
+		},
+	})
+
+	first := make(chan error)
+	go func() {
+		_, err := NewRunner(reg, store, d, Options{Owner: "deploy-a"}).Migrate(ctx, Latest)
+		first <- err
+	}()
+	<-started
+	_, err := NewRunner(reg, store, d, Options{Owner: "deploy-b"}).Migrate(ctx, Latest)
+	if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), "deploy-a") {
+		t.Errorf("second runner: %v, want ErrLocked by deploy-a", err)
+	}
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	if done, err := NewRunner(reg, store, d, Options{}).Migrate(ctx, Latest); err != nil || len(done) != 0 {
+		t.Errorf("after the first runner: %v, %v", done, err)
+	}
+}
+
+func Example() {
+	ctx := context.Background()
+	schema := map[string][]string{}
+	var reg Registry[map[string][]string]
+	reg.MustRegister(Migration[map[string][]string]{
+		Version: 1, Name: "create orders", Source: "CREATE TABLE orders (id)",
+		Up: func(_ context.Context, s map[string][]string) error {
+			s["orders"] = []string{"id"}
+			return nil
+		},
+		Down: func(_ context.Context, s map[string][]string) error {
+			delete(s, "orders")
+			return nil
+		},
+	})
+	reg.MustRegister(Migration[map[string][]string]{
+		Version: 2, Name: "add total", Source: "ALTER TABLE orders ADD total",
+		Up: func(_ context.Context, s map[string][]string) error {
+			s["orders"] = append(s["orders"], "total")
+			return nil
+		},
+	})
+
+	r := NewRunner(&reg, NewMemStore(), schema, Options{})
+	plan, _ := r.Plan(ctx, Latest)
+	fmt.Println("plan:", plan)
+	r.Migrate(ctx, Latest)
+	fmt.Println(schema)
+	_, err := r.Migrate(ctx, 1)
+	fmt.Println(err)
+	// Output:
+	// plan: [up 1 create orders up 2 add total]
+	// map[orders:[id total]]
+	// migrate: migration has no down step: 2 add total
+}
@@ -0,0 +1,120 @@
+// Package mmap maps files into memory read-only.
+//
+// A mapped file is read through the page cache without a system call or a
+// copy per read, which suits random access to large files, such as point
+// lookups in an SSTable, where buffered readers keep throwing away their
+// buffer on every seek.
+//
+// The catch is lifetime: a slice into the mapping becomes invalid once the
+// file is unmapped, and touching it then crashes the process rather than
+// panicking. File therefore never hands out the mapping itself. ReadAt
+// copies, and View lends the bytes to a callback while holding off Close.
+package mmap
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrClosed is returned for operations on a closed File.
+	ErrClosed = errors.New("mmap: file closed")
+	// ErrRange is returned by View for ranges outside the file.
+	ErrRange = errors.New("mmap: range out of bounds")
+)
+
+// File is a read-only memory mapped file. It is safe for concurrent use.
+type File struct {
+	mu     sync.RWMutex
+	data   []byte
+	closed bool
+}
+
+// Open maps the file at path. The file itself is closed again right away;
+// the mapping stays valid until Close.
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size != int64(int(size)) {
+		return nil, &os.PathError{Op: "mmap", Path: path, Err: errors.New("file too large")}
+	}
+	if size == 0 {
+		// Empty mappings are not allowed; there is nothing to map.
+		return &File{}, nil
+	}
+	data, err := mapFile(f, int(size))
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	return &File{data: data}, nil
+}
+
+// Len returns the size of the file.
+func (f *File) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.data)
+}
+
+// ReadAt implements io.ReaderAt by copying from the mapping.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return 0, ErrClosed
+	}
+	if off < 0 {
+		return 0, ErrRange
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// View calls fn with the n mapped bytes at off. The slice is only valid
+// during the call: fn must not keep it or anything pointing into it. Close
+// waits for running views.
+func (f *File) View(off, n int, fn func([]byte) error) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return ErrClosed
+	}
+	if off < 0 || n < 0 || off > len(f.data)-n {
+		return ErrRange
+	}
+	// Cap the slice so fn cannot append into the rest of the mapping.
+	return fn(f.data[off : off+n : off+n])
+}
+
+// Close unmaps the file. Later calls return ErrClosed.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrClosed
+	}
+	f.closed = true
+	data := f.data
+	f.data = nil
+	if data == nil {
+		return nil
+	}
+	return unmap(data)
+}
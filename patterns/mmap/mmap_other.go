@@ -0,0 +1,19 @@
+//go:build !unix
+
+package mmap
+
+import (
+	"io"
+	"os"
+)
+
+// Without mmap the file is read into memory. The API and its guarantees
+// stay the same; only the memory use differs.
+
+func mapFile(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	_, err := io.ReadFull(f, data)
+	return data, err
+}
+
+func unmap([]byte) error { return nil }
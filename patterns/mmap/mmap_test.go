@@ -0,0 +1,211 @@
+package mmap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeFile(t testing.TB, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadAt(t *testing.T) {
+	f, err := Open(writeFile(t, []byte("hello, mapped world")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.Len() != 19 {
+		t.Errorf("Len = %d", f.Len())
+	}
+	buf := make([]byte, 6)
+	if n, err := f.ReadAt(buf, 7); n != 6 || err != nil || string(buf) != "mapped" {
+		t.Errorf("ReadAt = %d, %v, %q", n, err, buf)
+	}
+	if n, err := f.ReadAt(buf, 15); n != 4 || err != io.EOF {
+		t.Errorf("short ReadAt = %d, %v", n, err)
+	}
+	if _, err := f.ReadAt(buf, 19); err != io.EOF {
+		t.Errorf("ReadAt at the end = %v", err)
+	}
+	if _, err := f.ReadAt(buf, -1); !errors.Is(err, ErrRange) {
+		t.Errorf("negative offset = %v", err)
+	}
+	// The SectionReader shows File is a drop-in io.ReaderAt.
+	all, _ := io.ReadAll(io.NewSectionReader(f, 0, int64(f.Len())))
+	if string(all) != "hello, mapped world" {
+		t.Errorf("read back %q", all)
+	}
+}
+
+func TestView(t *testing.T) {
+	f, err := Open(writeFile(t, []byte("0123456789")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	err = f.View(2, 3, func(b []byte) error {
+		if string(b) != "234" || cap(b) != 3 {
+			t.Errorf("view = %q with cap %d", b, cap(b))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range [][2]int{{-1, 1}, {8, 3}, {0, 11}, {5, -1}} {
+		if err := f.View(r[0], r[1], func([]byte) error { return nil }); !errors.Is(err, ErrRange) {
+			t.Errorf("View(%d, %d) = %v", r[0], r[1], err)
+		}
+	}
+	sentinel := errors.New("stop")
+	if err := f.View(0, 1, func([]byte) error { return sentinel }); err != sentinel {
+		t.Errorf("View should return the callback error, got %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	f, err := Open(writeFile(t, bytes.Repeat([]byte("x"), 1<<16)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Close must wait for the view: unmapping under it would crash.
+	inView, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f.View(0, f.Len(), func(b []byte) error {
+			close(inView)
+			<-release
+			if b[len(b)-1] != 'x' {
+				t.Error("mapping changed under the view")
+			}
+			return nil
+		})
+	}()
+	<-inView
+	closed := make(chan error)
+	go func() { closed <- f.Close() }()
+	close(release)
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if _, err := f.ReadAt(make([]byte, 1), 0); err != ErrClosed {
+		t.Errorf("ReadAt after Close = %v", err)
+	}
+	if err := f.View(0, 1, nil); err != ErrClosed {
+		t.Errorf("View after Close = %v", err)
+	}
+	if err := f.Close(); err != ErrClosed {
+		t.Errorf("second Close = %v", err)
+	}
+}
+
+func TestEmptyFile(t *testing.T) {
+	f, err := Open(writeFile(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.ReadAt(make([]byte, 1), 0); err != io.EOF {
+		t.Errorf("ReadAt on an empty file = %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+// The benchmarks read 4KiB blocks at random offsets of a 32MiB file, the
+// access pattern of point lookups in a sorted table.
+const (
+	benchSize  = 32 << 20
+	benchBlock = 4 << 10
+)
+
+func benchFile(b *testing.B) (string, []int64) {
+	data := make([]byte, benchSize)
+	rnd := rand.New(rand.NewSource(1))
+	rnd.Read(data)
+	offs := make([]int64, 1024)
+	for i := range offs {
+		offs[i] = rnd.Int63n(benchSize - benchBlock)
+	}
+	return writeFile(b, data), offs
+}
+
+func BenchmarkRandomRead(b *testing.B) {
+	path, offs := benchFile(b)
+	buf := make([]byte, benchBlock)
+
+	b.Run("mmap-ReadAt", func(b *testing.B) {
+		f, err := Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer f.Close()
+		b.SetBytes(benchBlock)
+		for i := 0; i < b.N; i++ {
+			f.ReadAt(buf, offs[i%len(offs)])
+		}
+	})
+	b.Run("mmap-View", func(b *testing.B) {
+		f, err := Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer f.Close()
+		b.SetBytes(benchBlock)
+		var sum byte
+		for i := 0; i < b.N; i++ {
+			f.View(int(offs[i%len(offs)]), benchBlock, func(p []byte) error {
+				sum ^= p[0] ^ p[len(p)-1]
+				return nil
+			})
+		}
+		_ = sum
+	})
+	b.Run("file-ReadAt", func(b *testing.B) {
+		f, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer f.Close()
+		b.SetBytes(benchBlock)
+		for i := 0; i < b.N; i++ {
+			f.ReadAt(buf, offs[i%len(offs)])
+		}
+	})
+	b.Run("bufio-Seek", func(b *testing.B) {
+		f, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer f.Close()
+		r := bufio.NewReaderSize(f, 64<<10)
+		b.SetBytes(benchBlock)
+		for i := 0; i < b.N; i++ {
+			// Every seek invalidates the buffer, so bufio reads 64KiB to
+			// return 4KiB.
+			f.Seek(offs[i%len(offs)], io.SeekStart)
+			r.Reset(f)
+			io.ReadFull(r, buf)
+		}
+	})
+}
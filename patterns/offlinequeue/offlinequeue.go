@@ -0,0 +1,194 @@
+// Package offlinequeue lets a client keep working while the network is
+// down.
+//
+// Every operation goes through a local outbox, a filequeue.Queue, before it
+// reaches the server. While the server is reachable the outbox is empty and
+// operations pass straight through; when it is not, they pile up on disk,
+// survive restarts of the client, and are replayed in their original order
+// once the connection is back.
+//
+// Replaying is where the pattern earns its keep. An operation may have
+// reached the server although the reply got lost, so every operation
+// carries an ID the server uses to ignore duplicates. And the data may have
+// changed on the server in the meantime: operations carry the version they
+// were based on, and a Resolver decides what to do with one that conflicts,
+// rebase it onto the current version or drop it.
+package offlinequeue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/filequeue"
+)
+
+// ErrOffline is wrapped by transport errors that mean the server was not
+// reached, or its reply was lost. The operation is kept and retried.
+var ErrOffline = errors.New("offlinequeue: offline")
+
+// Op is an operation on the server's data: set Key to Value, provided the
+// key is still at BaseVersion.
+type Op struct {
+	ID          string `json:"id"`
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	BaseVersion int    `json:"base"`
+}
+
+// ConflictError is returned by a Transport when the key is no longer at
+// the operation's BaseVersion.
+type ConflictError struct {
+	Op             Op
+	CurrentVersion int
+	CurrentValue   string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("offlinequeue: %s conflicts on %q: based on version %d, now %d",
+		e.Op.ID, e.Op.Key, e.Op.BaseVersion, e.CurrentVersion)
+}
+
+// Transport sends an operation to the server. Applying an ID twice must
+// have no further effect.
+type Transport interface {
+	Apply(ctx context.Context, op Op) error
+}
+
+// Resolver decides about a conflicting operation: it returns the operation
+// to retry in its place, or false to drop it.
+type Resolver func(c *ConflictError) (Op, bool)
+
+// Rebase is a Resolver that lets the client win: the operation is retried
+// on top of the current version.
+func Rebase(c *ConflictError) (Op, bool) {
+	op := c.Op
+	op.BaseVersion = c.CurrentVersion
+	return op, true
+}
+
+// Discard is a Resolver that lets the server win.
+func Discard(*ConflictError) (Op, bool) { return Op{}, false }
+
+// Client sends operations through a durable outbox.
+type Client struct {
+	transport Transport
+	resolve   Resolver
+
+	// mu serializes sending, so operations reach the server in order.
+	mu     sync.Mutex
+	outbox *filequeue.Queue
+}
+
+// Open opens a Client whose outbox is stored at path. Operations left there
+// by an earlier run are sent by the next Flush. A nil resolve means
+// Discard.
+func Open(path string, t Transport, resolve Resolver) (*Client, error) {
+	q, err := filequeue.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if resolve == nil {
+		resolve = Discard
+	}
+	return &Client{transport: t, resolve: resolve, outbox: q}, nil
+}
+
+// Do sends op, or queues it when the server is unreachable. Operations
+// queued earlier are sent first. It only returns errors other than
+// ErrOffline, such as a failure to write the outbox.
+func (c *Client) Do(ctx context.Context, op Op) error {
+	b, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.outbox.Push(b); err != nil {
+		return err
+	}
+	_, err = c.flush(ctx)
+	if errors.Is(err, ErrOffline) {
+		return nil
+	}
+	return err
+}
+
+// Flush sends the queued operations in order and returns how many went
+// out. It stops at the first one that fails; errors wrapping ErrOffline
+// mean the rest is still queued.
+func (c *Client) Flush(ctx context.Context) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flush(ctx)
+}
+
+func (c *Client) flush(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		b, err := c.outbox.Peek()
+		if errors.Is(err, filequeue.ErrEmpty) {
+			return sent, nil
+		}
+		if err != nil {
+			return sent, err
+		}
+		var op Op
+		if err := json.Unmarshal(b, &op); err != nil {
+			return sent, err
+		}
+
+		err = c.apply(ctx, op)
+		if err != nil {
+			return sent, err
+		}
+		// A crash before Pop sends op again; the server ignores it by ID.
+		if err := c.outbox.Pop(); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+}
+
+// apply sends op, resolving conflicts until it is applied or dropped.
+func (c *Client) apply(ctx context.Context, op Op) error {
+	for {
+		err := c.transport.Apply(ctx, op)
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) {
+			return err
+		}
+		next, ok := c.resolve(conflict)
+		if !ok {
+			return nil
+		}
+		op = next
+	}
+}
+
+// Pending returns the number of queued operations.
+func (c *Client) Pending() int { return c.outbox.Len() }
+
+// Run flushes every interval until ctx is done.
+func (c *Client) Run(ctx context.Context, every time.Duration) error {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.Flush(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close closes the outbox.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.outbox.Close()
+}
@@ -0,0 +1,215 @@
+package offlinequeue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+type entry struct {
+	version int
+	value   string
+}
+
+// server is a versioned key-value store that ignores repeated op IDs.
+type server struct {
+	mu      sync.Mutex
+	data    map[string]entry
+	seen    map[string]bool
+	applied []string
+}
+
+func newServer() *server {
+	return &server{data: map[string]entry{}, seen: map[string]bool{}}
+}
+
+func (s *server) Apply(_ context.Context, op Op) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[op.ID] {
+		return nil
+	}
+	cur := s.data[op.Key]
+	if op.BaseVersion != cur.version {
+		return &ConflictError{Op: op, CurrentVersion: cur.version, CurrentValue: cur.value}
+	}
+	s.data[op.Key] = entry{cur.version + 1, op.Value}
+	s.seen[op.ID] = true
+	s.applied = append(s.applied, op.ID)
+	return nil
+}
+
+func (s *server) get(key string) entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key]
+}
+
+// network sits between client and server. Offline, nothing gets through;
+// online, a request is lost with probability lose and a reply with
+// probability loseReply.
+type network struct {
+	srv             *server
+	online          bool
+	rng             *rand.Rand
+	lose, loseReply float64
+}
+
+func (n *network) Apply(ctx context.Context, op Op) error {
+	if !n.online || n.rng.Float64() < n.lose {
+		return fmt.Errorf("%w: request lost", ErrOffline)
+	}
+	err := n.srv.Apply(ctx, op)
+	if n.rng.Float64() < n.loseReply {
+		return fmt.Errorf("%w: reply lost", ErrOffline)
+	}
+	return err
+}
+
+func openClient(t *testing.T, path string, tr Transport, r Resolver) *Client {
+	t.Helper()
+	c, err := Open(path, tr, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestReplayAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox")
+	srv := newServer()
+	net := &network{srv: srv, rng: rand.New(rand.NewSource(1))}
+
+	c := openClient(t, path, net, nil)
+	for i := 0; i < 3; i++ {
+		op := Op{ID: fmt.Sprint("op", i), Key: "title", Value: fmt.Sprint("draft ", i), BaseVersion: i}
+		if err := c.Do(ctx, op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Pending() != 3 || srv.get("title").version != 0 {
+		t.Fatalf("offline: %d pending, server at version %d", c.Pending(), srv.get("title").version)
+	}
+	c.Close()
+
+	// The app restarts and the network comes back.
+	c = openClient(t, path, net, nil)
+	defer c.Close()
+	net.online = true
+	n, err := c.Flush(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("Flush = %d, %v", n, err)
+	}
+	if got := srv.get("title"); got != (entry{3, "draft 2"}) {
+		t.Errorf("server has %+v", got)
+	}
+	if !slices.Equal(srv.applied, []string{"op0", "op1", "op2"}) {
+		t.Errorf("applied %v", srv.applied)
+	}
+	// Online, operations pass straight through.
+	c.Do(ctx, Op{ID: "op3", Key: "title", Value: "final", BaseVersion: 3})
+	if c.Pending() != 0 || srv.get("title").value != "final" {
+		t.Errorf("online Do: %d pending, server has %+v", c.Pending(), srv.get("title"))
+	}
+}
+
+func TestConflict(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		resolve Resolver
+		want    entry
+	}{
+		{"rebase", Rebase, entry{2, "mine"}},
+		{"discard", Discard, entry{1, "theirs"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			srv := newServer()
+			net := &network{srv: srv, rng: rand.New(rand.NewSource(1))}
+			c := openClient(t, filepath.Join(t.TempDir(), "outbox"), net, tc.resolve)
+			defer c.Close()
+
+			c.Do(ctx, Op{ID: "mine", Key: "k", Value: "mine"})
+			// Someone else writes the key while we are offline.
+			srv.Apply(ctx, Op{ID: "theirs", Key: "k", Value: "theirs"})
+
+			net.online = true
+			if _, err := c.Flush(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if got := srv.get("k"); got != tc.want {
+				t.Errorf("server has %+v, want %+v", got, tc.want)
+			}
+			if c.Pending() != 0 {
+				t.Errorf("%d still pending", c.Pending())
+			}
+		})
+	}
+}
+
+// TestFlakyNetwork writes through a connection that comes and goes and
+// loses requests and replies. Every operation must be applied exactly
+// once, in order.
+func TestFlakyNetwork(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer()
+	rng := rand.New(rand.NewSource(42))
+	net := &network{srv: srv, rng: rng, lose: 0.3, loseReply: 0.2}
+	path := filepath.Join(t.TempDir(), "outbox")
+	c := openClient(t, path, net, nil)
+
+	versions := map[string]int{}
+	var ids []string
+	for i := 0; i < 200; i++ {
+		if i%20 == 0 {
+			net.online = !net.online
+		}
+		if i%50 == 49 {
+			// Restart the app now and then.
+			c.Close()
+			c = openClient(t, path, net, nil)
+		}
+		key := fmt.Sprint("k", i%3)
+		op := Op{ID: fmt.Sprint("op", i), Key: key, Value: fmt.Sprint(i), BaseVersion: versions[key]}
+		versions[key]++
+		ids = append(ids, op.ID)
+		if err := c.Do(ctx, op); err != nil {
+			t.Fatal(err)
+		}
+		if rng.Intn(5) == 0 {
+			c.Flush(ctx)
+		}
+	}
+	defer c.Close()
+
+	net.online = true
+	for tries := 0; c.Pending() > 0; tries++ {
+		if tries > 1000 {
+			t.Fatalf("%d operations never got through", c.Pending())
+		}
+		c.Flush(ctx)
+	}
+	if !slices.Equal(srv.applied, ids) {
+		t.Errorf("applied %d operations out of order or twice", len(srv.applied))
+	}
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprint("k", i)
+		if got := srv.get(key); got.version != versions[key] {
+			t.Errorf("%s at version %d, want %d", key, got.version, versions[key])
+		}
+	}
+}
+
+func TestDoReportsLocalErrors(t *testing.T) {
+	c := openClient(t, filepath.Join(t.TempDir(), "outbox"), &network{srv: newServer()}, nil)
+	c.Close()
+	if err := c.Do(context.Background(), Op{ID: "x"}); err == nil || errors.Is(err, ErrOffline) {
+		t.Errorf("Do on a closed outbox: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+// Package paralleldl downloads a resource in ranged parts through a worker
+// pool. The size comes from a HEAD request, every part is fetched with its
+// own Range request and retried on its own, and the parts are written back
+// in order so the destination can be a plain io.Writer. Because only a
+// contiguous prefix is ever written, a failed download can be resumed from
+// the number of bytes it reports.
+package paralleldl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+var (
+	// ErrNoRanges is returned when the server does not advertise byte ranges.
+	ErrNoRanges = errors.New("paralleldl: server does not accept byte ranges")
+	// ErrUnknownSize is returned when the server does not report a length.
+	ErrUnknownSize = errors.New("paralleldl: server did not report a content length")
+)
+
+// CorruptPartError reports a part whose response did not match its range.
+type CorruptPartError struct {
+	Part   int
+	Reason string
+}
+
+func (e *CorruptPartError) Error() string {
+	return fmt.Sprintf("paralleldl: part %d is corrupt: %s", e.Part, e.Reason)
+}
+
+// Downloader fetches resources in parallel ranged parts.
+type Downloader struct {
+	pool *workerpool.Pool
+
+	Client   *http.Client
+	PartSize int64
+	// Retries is the number of extra attempts made for a failing part.
+	Retries int
+	// Backoff is the wait before the first retry; it doubles every retry.
+	Backoff time.Duration
+}
+
+// New constructs a Downloader that fetches parts on pool.
+func New(pool *workerpool.Pool) *Downloader {
+	return &Downloader{
+		pool:     pool,
+		Client:   http.DefaultClient,
+		PartSize: 1 << 20,
+		Retries:  3,
+		Backoff:  50 * time.Millisecond,
+	}
+}
+
+// Size issues a HEAD request and returns the length of the resource.
+func (d *Downloader) Size(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("paralleldl: HEAD %s: %s", url, resp.Status)
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		return 0, ErrNoRanges
+	}
+	if resp.ContentLength < 0 {
+		return 0, ErrUnknownSize
+	}
+	return resp.ContentLength, nil
+}
+
+type part struct {
+	index      int
+	start, end int64 // inclusive, as in a Range header
+}
+
+type result struct {
+	index int
+	data  []byte
+	err   error
+}
+
+// Download writes the resource at url to w, starting at offset. It returns
+// the offset reached, which on error is where a later call should resume.
+func (d *Downloader) Download(ctx context.Context, url string, w io.Writer, offset int64) (int64, error) {
+	size, err := d.Size(ctx, url)
+	if err != nil {
+		return offset, err
+	}
+
+	var parts []part
+	for start := offset; start < size; start += d.PartSize {
+		end := start + d.PartSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		parts = append(parts, part{index: len(parts), start: start, end: end})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Every part has its own context, so that a failure can cancel the
+	// parts after it and leave the ones before it running.
+	cancels := make([]context.CancelFunc, len(parts))
+	results := make(chan result, len(parts))
+	for i, p := range parts {
+		pctx, pcancel := context.WithCancel(ctx)
+		cancels[i] = pcancel
+		go func(p part) {
+			var data []byte
+			err := d.pool.Run(pctx, workerpool.WorkerFunc(func(ctx context.Context) error {
+				var err error
+				data, err = d.fetchWithRetry(ctx, url, p)
+				return err
+			}))
+			results <- result{index: p.index, data: data, err: err}
+		}(p)
+	}
+
+	// Parts finish in any order; hold the early ones back until every part
+	// before them has been written. The first part to fail, by index, ends
+	// the download: the parts after it are cancelled and the ones before it
+	// are still waited for and written, so the offset returned does not
+	// depend on which parts happened to finish first.
+	pending := make(map[int][]byte)
+	next, failed := 0, len(parts)
+	var failure error
+	for range parts {
+		r := <-results
+		if r.err != nil {
+			if r.index < failed {
+				failed, failure = r.index, r.err
+				for _, c := range cancels[failed+1:] {
+					c()
+				}
+			}
+			continue
+		}
+		pending[r.index] = r.data
+		for data, ok := pending[next]; ok && next < failed; data, ok = pending[next] {
+			if _, err := w.Write(data); err != nil {
+				failed, failure = next, err
+				cancel()
+				break
+			}
+			offset += int64(len(data))
+			delete(pending, next)
+			next++
+		}
+	}
+	return offset, failure
+}
+
+func (d *Downloader) fetchWithRetry(ctx context.Context, url string, p part) ([]byte, error) {
+	policy := retry.Policy{
+		Backoff:     retry.Exponential{Initial: d.Backoff},
+		MaxAttempts: d.Retries + 1,
+	}
+	return retry.DoValue(ctx, policy, func(ctx context.Context) ([]byte, error) {
+		return d.fetch(ctx, url, p)
+	})
+}
+
+func (d *Downloader) fetch(ctx context.Context, url string, p part) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", p.start, p.end))
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, &CorruptPartError{Part: p.index, Reason: "unexpected status " + resp.Status}
+	}
+	var start, end, total int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil ||
+		start != p.start || end != p.end {
+		return nil, &CorruptPartError{Part: p.index, Reason: "content range " + resp.Header.Get("Content-Range")}
+	}
+
+	want := p.end - p.start + 1
+	data, err := io.ReadAll(io.LimitReader(resp.Body, want+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != want {
+		return nil, &CorruptPartError{Part: p.index, Reason: fmt.Sprintf("got %d bytes, want %d", len(data), want)}
+	}
+	return data, nil
+}
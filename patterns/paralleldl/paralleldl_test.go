@@ -0,0 +1,193 @@
+package paralleldl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+func payload(n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(b)
+	return b
+}
+
+// rangeServer serves content with Range support. fault, if set, may take
+// over a ranged GET and returns true when it did.
+func rangeServer(content []byte, fault func(w http.ResponseWriter, r *http.Request) bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && fault != nil && fault(w, r) {
+			return
+		}
+		http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+func newDownloader(pool *workerpool.Pool) *Downloader {
+	d := New(pool)
+	d.PartSize = 1000
+	d.Backoff = time.Millisecond
+	return d
+}
+
+func TestDownload(t *testing.T) {
+	content := payload(10500)
+	srv := rangeServer(content, nil)
+	defer srv.Close()
+
+	pool := workerpool.New(4)
+	defer pool.Shutdown()
+
+	var buf bytes.Buffer
+	n, err := newDownloader(pool).Download(context.Background(), srv.URL, &buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) || !bytes.Equal(buf.Bytes(), content) {
+		t.Errorf("downloaded %d bytes that do not match the source", n)
+	}
+}
+
+func TestCorruptPartsAreRetried(t *testing.T) {
+	content := payload(5000)
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	srv := rangeServer(content, func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		seen[r.Header.Get("Range")]++
+		attempt := seen[r.Header.Get("Range")]
+		mu.Unlock()
+		if attempt > 1 {
+			return false
+		}
+		switch r.Header.Get("Range") {
+		case "bytes=1000-1999":
+			// Truncated body.
+			w.Header().Set("Content-Range", "bytes 1000-1999/5000")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[1000:1500])
+			return true
+		case "bytes=3000-3999":
+			// Range ignored, whole body sent.
+			w.Write(content)
+			return true
+		}
+		return false
+	})
+	defer srv.Close()
+
+	pool := workerpool.New(3)
+	defer pool.Shutdown()
+
+	var buf bytes.Buffer
+	if _, err := newDownloader(pool).Download(context.Background(), srv.URL, &buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Error("corrupt parts made it into the output")
+	}
+	if seen["bytes=1000-1999"] != 2 || seen["bytes=3000-3999"] != 2 {
+		t.Errorf("expected one retry per corrupt part, got %v", seen)
+	}
+}
+
+func TestResume(t *testing.T) {
+	content := payload(8000)
+	var broken = true
+	var mu sync.Mutex
+	srv := rangeServer(content, func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if broken && strings.HasPrefix(r.Header.Get("Range"), "bytes=5000-") {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	})
+	defer srv.Close()
+
+	pool := workerpool.New(2)
+	defer pool.Shutdown()
+	d := newDownloader(pool)
+	d.Retries = 1
+
+	var buf bytes.Buffer
+	n, err := d.Download(context.Background(), srv.URL, &buf, 0)
+	var corrupt *CorruptPartError
+	if !errors.As(err, &corrupt) || corrupt.Part != 5 {
+		t.Fatalf("expected part 5 to fail, got %v", err)
+	}
+	if n != 5000 || !bytes.Equal(buf.Bytes(), content[:5000]) {
+		t.Fatalf("expected the 5000 byte prefix to be written, got %d", n)
+	}
+
+	mu.Lock()
+	broken = false
+	mu.Unlock()
+
+	n, err = d.Download(context.Background(), srv.URL, &buf, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) || !bytes.Equal(buf.Bytes(), content) {
+		t.Error("resumed download does not match the source")
+	}
+}
+
+// A part that finishes after a later one failed is still written.
+func TestSlowPartBeforeFailure(t *testing.T) {
+	content := payload(4000)
+	failed := make(chan struct{})
+	srv := rangeServer(content, func(w http.ResponseWriter, r *http.Request) bool {
+		switch {
+		case strings.HasPrefix(r.Header.Get("Range"), "bytes=0-"):
+			// Answer well after the failure has reached the downloader.
+			<-failed
+			time.Sleep(50 * time.Millisecond)
+		case strings.HasPrefix(r.Header.Get("Range"), "bytes=2000-"):
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			close(failed)
+			return true
+		}
+		return false
+	})
+	defer srv.Close()
+
+	pool := workerpool.New(4)
+	defer pool.Shutdown()
+	d := newDownloader(pool)
+	d.Retries = 0
+
+	var buf bytes.Buffer
+	n, err := d.Download(context.Background(), srv.URL, &buf, 0)
+	var corrupt *CorruptPartError
+	if !errors.As(err, &corrupt) || corrupt.Part != 2 {
+		t.Fatalf("expected part 2 to fail, got %v", err)
+	}
+	if n != 2000 || !bytes.Equal(buf.Bytes(), content[:2000]) {
+		t.Errorf("expected the 2000 byte prefix to be written, got %d", n)
+	}
+}
+
+func TestNoRanges(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+	defer srv.Close()
+
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+
+	if _, err := newDownloader(pool).Download(context.Background(), srv.URL, &bytes.Buffer{}, 0); err != ErrNoRanges {
+		t.Errorf("expected ErrNoRanges, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+// Package pollpush turns a source that can only be polled into a channel
+// that pushes its changes.
+//
+// A fixed polling interval is either too slow to notice changes or too
+// busy while nothing happens. Watch adapts it: after a change it polls
+// again after Min, and every poll that finds nothing new stretches the
+// interval by Backoff up to Max. Jitter spreads the polls of many watchers
+// so they do not hit the source in step.
+//
+// Polls are conditional where the source allows it. Every poll passes the
+// token of the last value seen, like an HTTP ETag, and the source answers
+// NotModified instead of sending the value again. For sources without
+// tokens an Equal function detects unchanged values instead.
+package pollpush
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+// Result is the answer to one poll.
+type Result[T any] struct {
+	Value T
+	// Token identifies Value, for the next conditional poll.
+	Token string
+	// NotModified reports that the value still matches the token passed
+	// in; Value is not set.
+	NotModified bool
+}
+
+// Source polls for the current value. token is the Token of the last
+// value delivered, empty before the first.
+type Source[T any] func(ctx context.Context, token string) (Result[T], error)
+
+// Options configures Watch.
+type Options[T any] struct {
+	// Min is the interval after a change, Max the longest interval. They
+	// default to 1s and 1m.
+	Min, Max time.Duration
+	// Backoff multiplies the interval after a poll without change. It
+	// defaults to 2.
+	Backoff float64
+	// Jitter varies every wait by up to this fraction either way.
+	Jitter float64
+	// Equal, if set, reports values that did not change, for sources that
+	// do not support tokens.
+	Equal func(a, b T) bool
+	// OnError is called for failed polls, which count as unchanged.
+	OnError func(error)
+
+	// Clock and Rand default to the real clock and rand.Float64.
+	Clock retry.Clock
+	Rand  func() float64
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (o *Options[T]) defaults() {
+	if o.Min <= 0 {
+		o.Min = time.Second
+	}
+	if o.Max < o.Min {
+		o.Max = max(time.Minute, o.Min)
+	}
+	if o.Backoff < 1 {
+		o.Backoff = 2
+	}
+	if o.Clock == nil {
+		o.Clock = realClock{}
+	}
+	if o.Rand == nil {
+		o.Rand = rand.Float64
+	}
+}
+
+// Watch polls src, first right away, and sends every new value on the
+// returned channel. Sending waits for the consumer, so a slow consumer
+// slows down the polling too. The channel is closed when ctx is done.
+func Watch[T any](ctx context.Context, src Source[T], opts Options[T]) <-chan T {
+	opts.defaults()
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		var (
+			token    string
+			last     T
+			seen     bool
+			interval = opts.Min
+		)
+		for {
+			res, err := src(ctx, token)
+			changed := false
+			switch {
+			case err != nil:
+				if ctx.Err() != nil {
+					return
+				}
+				if opts.OnError != nil {
+					opts.OnError(err)
+				}
+			case res.NotModified:
+			case seen && opts.Equal != nil && opts.Equal(last, res.Value):
+				token = res.Token
+			default:
+				changed = true
+				token, last, seen = res.Token, res.Value, true
+			}
+
+			if changed {
+				interval = opts.Min
+				select {
+				case out <- last:
+				case <-ctx.Done():
+					return
+				}
+			} else {
+				interval = min(time.Duration(float64(interval)*opts.Backoff), opts.Max)
+			}
+
+			select {
+			case <-opts.Clock.After(jitter(interval, opts.Jitter, opts.Rand)):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// jitter returns d varied by up to frac of it either way.
+func jitter(d time.Duration, frac float64, rnd func() float64) time.Duration {
+	if frac <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + frac*(2*rnd()-1)))
+}
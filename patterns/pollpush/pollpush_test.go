@@ -0,0 +1,190 @@
+package pollpush
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// drive advances c through every wait as soon as it starts. The stop it
+// returns ends the driving and returns the waits.
+func drive(c *clock.Fake) (stop func() []time.Duration) {
+	done := make(chan struct{})
+	driven := make(chan []time.Duration)
+	go func() {
+		var waits []time.Duration
+		for {
+			c.BlockUntil(1)
+			select {
+			case <-done:
+				driven <- waits
+				return
+			default:
+			}
+			waits = append(waits, c.AdvanceToNext())
+		}
+	}()
+	return func() []time.Duration {
+		close(done)
+		c.After(0) // wakes the driver
+		return <-driven
+	}
+}
+
+// script is a fake source. Every poll returns the next version, or fails
+// for "!", and is answered NotModified when the version matches the
+// token. After the last step it cancels the watch.
+type script struct {
+	steps  []string
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (s *script) poll(ctx context.Context, token string) (Result[string], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.tokens) == len(s.steps) {
+		s.cancel()
+		return Result[string]{}, ctx.Err()
+	}
+	step := s.steps[len(s.tokens)]
+	s.tokens = append(s.tokens, token)
+	switch {
+	case step == "!":
+		return Result[string]{}, errors.New("unavailable")
+	case step == token:
+		return Result[string]{NotModified: true}, nil
+	}
+	return Result[string]{Value: "value " + step, Token: step}, nil
+}
+
+func run(t *testing.T, steps []string, opts Options[string]) (got []string, waits []time.Duration, s *script) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s = &script{steps: steps, cancel: cancel}
+	c := clock.NewFake(time.Time{})
+	opts.Clock = c
+	stop := drive(c)
+	for v := range Watch(ctx, s.poll, opts) {
+		got = append(got, v)
+	}
+	return got, stop(), s
+}
+
+const sec = time.Second
+
+func TestAdaptiveInterval(t *testing.T) {
+	var errs int
+	got, waits, s := run(t,
+		[]string{"v1", "v1", "v1", "!", "v1", "v1", "v2", "v2", "v3"},
+		Options[string]{Min: sec, Max: 8 * sec, OnError: func(error) { errs++ }})
+
+	if want := []string{"value v1", "value v2", "value v3"}; !slices.Equal(got, want) {
+		t.Errorf("values = %q, want %q", got, want)
+	}
+	// Back off while nothing changes, errors included, capped at Max;
+	// start over at Min after a change.
+	want := []time.Duration{1 * sec, 2 * sec, 4 * sec, 8 * sec, 8 * sec, 8 * sec, 1 * sec, 2 * sec, 1 * sec}
+	if !slices.Equal(waits, want) {
+		t.Errorf("waits = %v, want %v", waits, want)
+	}
+	if errs != 1 {
+		t.Errorf("%d errors reported, want 1", errs)
+	}
+	// Every poll after the first was conditional on the last value seen.
+	wantTokens := []string{"", "v1", "v1", "v1", "v1", "v1", "v1", "v2", "v2"}
+	if !slices.Equal(s.tokens, wantTokens) {
+		t.Errorf("tokens = %q, want %q", s.tokens, wantTokens)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for _, tc := range []struct {
+		rand float64
+		want time.Duration
+	}{
+		{0, 900 * time.Millisecond},
+		{0.5, sec},
+		{1, 1100 * time.Millisecond},
+	} {
+		_, waits, _ := run(t, []string{"v1"},
+			Options[string]{Min: sec, Jitter: 0.1, Rand: func() float64 { return tc.rand }})
+		if len(waits) != 1 || waits[0] != tc.want {
+			t.Errorf("rand %v: waits = %v, want [%v]", tc.rand, waits, tc.want)
+		}
+	}
+}
+
+// TestEqual watches a source without tokens.
+func TestEqual(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	values := []string{"a", "a", "b", "b", "b", "a"}
+	var polls int
+	src := func(ctx context.Context, token string) (Result[string], error) {
+		if polls == len(values) {
+			cancel()
+			return Result[string]{}, ctx.Err()
+		}
+		polls++
+		return Result[string]{Value: values[polls-1]}, nil
+	}
+	var got []string
+	c := clock.NewFake(time.Time{})
+	opts := Options[string]{Equal: func(a, b string) bool { return a == b }, Clock: c}
+	stop := drive(c)
+	for v := range Watch(ctx, src, opts) {
+		got = append(got, v)
+	}
+	stop()
+	if want := []string{"a", "b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCancelWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := func(context.Context, string) (Result[int], error) { return Result[int]{Value: 1, Token: "1"}, nil }
+	values := Watch(ctx, src, Options[int]{Min: time.Hour})
+	if v := <-values; v != 1 {
+		t.Fatalf("got %d", v)
+	}
+	cancel()
+	select {
+	case _, ok := <-values:
+		if ok {
+			t.Error("value after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Error("channel not closed after cancel")
+	}
+}
+
+func ExampleWatch() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	version := 0
+	config := func(ctx context.Context, etag string) (Result[string], error) {
+		version++
+		if version > 2 {
+			// The config stopped changing.
+			return Result[string]{NotModified: true}, nil
+		}
+		return Result[string]{Value: fmt.Sprintf("config v%d", version), Token: fmt.Sprint(version)}, nil
+	}
+	updates := Watch(ctx, config, Options[string]{Min: time.Millisecond, Max: 10 * time.Millisecond})
+	fmt.Println(<-updates)
+	fmt.Println(<-updates)
+	// Output:
+	// config v1
+	// config v2
+}
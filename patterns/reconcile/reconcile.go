@@ -0,0 +1,230 @@
+// Package reconcile implements optimistic, local-first updates.
+//
+// A Store keeps two things: the last state confirmed by the server and the
+// local operations the server has not answered yet. What the user sees is
+// the confirmed state with the pending operations replayed on top, so a
+// change shows up the moment it is made instead of after a round trip.
+//
+// Server answers reconcile the two:
+//
+//   - Confirm: the server applied the operation. Its state, which may hold
+//     changes from other clients too, becomes the confirmed state.
+//   - Reject: the server refused the operation. It is dropped, which rolls
+//     it back in the view.
+//   - Sync: the server pushed a newer state, with other clients' changes.
+//
+// After each, the pending operations are rebased: replayed on the new
+// confirmed state. An operation that no longer applies, say a toggle on a
+// todo another client deleted, is left out of the view, but it stays
+// pending: it is already on its way to the server, which has the last word
+// on it. Every change of the view is published as a Change.
+package reconcile
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/crazybber/go-patterns/behavioral/observer/eventbus"
+)
+
+// ErrUnknownOp is returned for answers about operations that are not
+// pending.
+var ErrUnknownOp = errors.New("reconcile: unknown operation")
+
+// Op is an operation on a state of type S. Apply must not modify its
+// argument: it returns a new state, or an error when it does not apply.
+type Op[S any] struct {
+	ID    string
+	Apply func(S) (S, error)
+}
+
+// Cause tells what changed the view.
+type Cause int
+
+const (
+	// Local is an operation applied optimistically.
+	Local Cause = iota
+	// Confirmed is a confirmation from the server.
+	Confirmed
+	// Rejected is a rejection from the server, rolled back.
+	Rejected
+	// Remote is a state pushed by the server.
+	Remote
+)
+
+func (c Cause) String() string {
+	switch c {
+	case Local:
+		return "local"
+	case Confirmed:
+		return "confirmed"
+	case Rejected:
+		return "rejected"
+	case Remote:
+		return "remote"
+	}
+	return "unknown"
+}
+
+// Change is published whenever the view is recomputed.
+type Change[S any] struct {
+	Cause Cause
+	// ID is the operation concerned, empty for Remote.
+	ID string
+	// View is the state shown to the user now.
+	View S
+	// RolledBack lists pending operations that were part of the view
+	// before and no longer apply after a rebase.
+	RolledBack []string
+}
+
+// Store holds the confirmed state and the pending operations. It is safe
+// for concurrent use.
+type Store[S any] struct {
+	// pub is taken before mu is released, so changes are published in the
+	// order they happened.
+	mu, pub   sync.Mutex
+	confirmed S
+	pending   []pending[S]
+	view      S
+
+	bus *eventbus.EventBus[Change[S]]
+}
+
+type pending[S any] struct {
+	Op[S]
+	// applied reports whether the operation is part of the view.
+	applied bool
+}
+
+// New returns a Store whose confirmed state is initial.
+func New[S any](initial S) *Store[S] {
+	return &Store[S]{
+		confirmed: initial,
+		view:      initial,
+		bus:       eventbus.New[Change[S]](eventbus.Options{Mode: eventbus.Sync}),
+	}
+}
+
+// Subscribe calls handler with every Change, in order. Handlers may read
+// the Store but must not change it.
+func (s *Store[S]) Subscribe(handler func(Change[S])) *eventbus.Subscription[Change[S]] {
+	return s.bus.Subscribe(handler)
+}
+
+// Do applies op to the view and keeps it pending until the server answers.
+// An op that does not apply to the view is refused right away.
+func (s *Store[S]) Do(op Op[S]) error {
+	s.mu.Lock()
+	next, err := op.Apply(s.view)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.pending = append(s.pending, pending[S]{op, true})
+	s.view = next
+	s.publish(Change[S]{Cause: Local, ID: op.ID, View: next})
+	return nil
+}
+
+// Confirm records that the server applied the operation id, leaving it in
+// state.
+func (s *Store[S]) Confirm(id string, state S) error {
+	s.mu.Lock()
+	if !s.remove(id) {
+		s.mu.Unlock()
+		return ErrUnknownOp
+	}
+	s.confirmed = state
+	s.rebase(Change[S]{Cause: Confirmed, ID: id})
+	return nil
+}
+
+// Reject records that the server refused the operation id.
+func (s *Store[S]) Reject(id string) error {
+	s.mu.Lock()
+	if !s.remove(id) {
+		s.mu.Unlock()
+		return ErrUnknownOp
+	}
+	s.rebase(Change[S]{Cause: Rejected, ID: id})
+	return nil
+}
+
+// Sync replaces the confirmed state with a newer one from the server.
+func (s *Store[S]) Sync(state S) {
+	s.mu.Lock()
+	s.confirmed = state
+	s.rebase(Change[S]{Cause: Remote})
+}
+
+// remove drops the pending operation id.
+func (s *Store[S]) remove(id string) bool {
+	for i, p := range s.pending {
+		if p.ID == id {
+			s.pending = append(s.pending[:i:i], s.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// rebase replays the pending operations on the confirmed state, then
+// publishes ch. It is called with mu held and releases it.
+func (s *Store[S]) rebase(ch Change[S]) {
+	view := s.confirmed
+	for i, p := range s.pending {
+		next, err := p.Apply(view)
+		if err != nil {
+			if p.applied {
+				ch.RolledBack = append(ch.RolledBack, p.ID)
+			}
+			s.pending[i].applied = false
+			continue
+		}
+		view = next
+		s.pending[i].applied = true
+	}
+	s.view = view
+	ch.View = view
+	s.publish(ch)
+}
+
+// publish hands ch to the subscribers. It is called with mu held and
+// releases it.
+func (s *Store[S]) publish(ch Change[S]) {
+	s.pub.Lock()
+	s.mu.Unlock()
+	defer s.pub.Unlock()
+	s.bus.Publish(ch)
+}
+
+// View returns the state shown to the user: the confirmed state with the
+// pending operations applied.
+func (s *Store[S]) View() S {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.view
+}
+
+// Confirmed returns the last state confirmed by the server.
+func (s *Store[S]) Confirmed() S {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.confirmed
+}
+
+// Pending returns the IDs of the operations awaiting an answer, oldest
+// first.
+func (s *Store[S]) Pending() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, len(s.pending))
+	for i, p := range s.pending {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// Close unsubscribes all handlers.
+func (s *Store[S]) Close() { s.bus.Close() }
@@ -0,0 +1,261 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/crazybber/go-patterns/patterns/diff"
+)
+
+func titles(l List) []string {
+	var s []string
+	for _, t := range l {
+		mark := " "
+		if t.Done {
+			mark = "x"
+		}
+		s = append(s, mark+t.Title)
+	}
+	return s
+}
+
+func record(s *Store[List]) *[]Change[List] {
+	var changes []Change[List]
+	s.Subscribe(func(c Change[List]) { changes = append(changes, c) })
+	return &changes
+}
+
+func TestOptimisticThenConfirm(t *testing.T) {
+	s := New(List{})
+	changes := record(s)
+
+	s.Do(AddTodo("op1", "t1", "milk"))
+	if len(s.View()) != 1 || len(s.Confirmed()) != 0 {
+		t.Fatalf("view %v, confirmed %v", s.View(), s.Confirmed())
+	}
+	s.Confirm("op1", List{{ID: "t1", Title: "milk"}})
+	if len(s.Pending()) != 0 || !reflect.DeepEqual(s.View(), s.Confirmed()) {
+		t.Errorf("after Confirm: pending %v, view %v", s.Pending(), s.View())
+	}
+	if len(*changes) != 2 || (*changes)[0].Cause != Local || (*changes)[1].Cause != Confirmed {
+		t.Errorf("changes %+v", *changes)
+	}
+	if err := s.Confirm("op1", nil); !errors.Is(err, ErrUnknownOp) {
+		t.Errorf("second Confirm = %v", err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	s := New(List{{ID: "t1", Title: "milk"}})
+	s.Do(Rename("op1", "t1", "oat milk"))
+	s.Do(SetDone("op2", "t1", true))
+	if got := titles(s.View()); !slices.Equal(got, []string{"xoat milk"}) {
+		t.Fatalf("view %v", got)
+	}
+	s.Reject("op1")
+	// The rename is rolled back, the later toggle survives.
+	if got := titles(s.View()); !slices.Equal(got, []string{"xmilk"}) {
+		t.Errorf("after Reject: %v", got)
+	}
+	if !slices.Equal(s.Pending(), []string{"op2"}) {
+		t.Errorf("pending %v", s.Pending())
+	}
+}
+
+func TestRebaseOnRemoteChange(t *testing.T) {
+	s := New(List{{ID: "t1", Title: "milk"}})
+	s.Do(SetDone("op1", "t1", true))
+
+	// Another client added bread.
+	s.Sync(List{{ID: "t1", Title: "milk"}, {ID: "t2", Title: "bread"}})
+	if got := titles(s.View()); !slices.Equal(got, []string{"xmilk", " bread"}) {
+		t.Errorf("view %v", got)
+	}
+	if len(s.Pending()) != 1 {
+		t.Errorf("pending %v", s.Pending())
+	}
+}
+
+func TestDropOnRebase(t *testing.T) {
+	s := New(List{{ID: "t1", Title: "milk"}, {ID: "t2", Title: "bread"}})
+	changes := record(s)
+	s.Do(SetDone("op1", "t1", true))
+	s.Do(Rename("op2", "t2", "rye bread"))
+
+	// Another client deleted milk: the toggle no longer applies.
+	s.Sync(List{{ID: "t2", Title: "bread"}})
+	last := (*changes)[len(*changes)-1]
+	if last.Cause != Remote || !slices.Equal(last.RolledBack, []string{"op1"}) {
+		t.Errorf("change %+v", last)
+	}
+	if got := titles(s.View()); !slices.Equal(got, []string{" rye bread"}) {
+		t.Errorf("view %v", got)
+	}
+	// The toggle is still on its way; the server rejects it.
+	if !slices.Equal(s.Pending(), []string{"op1", "op2"}) {
+		t.Errorf("pending %v", s.Pending())
+	}
+	if err := s.Reject("op1"); err != nil {
+		t.Error(err)
+	}
+	if last := (*changes)[len(*changes)-1]; len(last.RolledBack) != 0 {
+		t.Errorf("op1 rolled back twice: %+v", last)
+	}
+}
+
+func TestDoRefusesInvalidOp(t *testing.T) {
+	s := New(List{})
+	if err := s.Do(SetDone("op1", "nope", true)); !errors.Is(err, ErrNoTodo) {
+		t.Errorf("Do = %v", err)
+	}
+	if len(s.Pending()) != 0 {
+		t.Error("a refused op must not be pending")
+	}
+}
+
+// message is an answer from the server to one client.
+type message struct {
+	cause Cause
+	id    string
+	state List
+}
+
+// server is the authority. It handles requests in arrival order, refuses
+// titles it does not accept, and pushes every change to the other clients.
+type server struct {
+	state   List
+	inbox   []request
+	outboxs map[string][]message
+}
+
+type request struct {
+	from string
+	op   Op[List]
+}
+
+func (s *server) handle() {
+	req := s.inbox[0]
+	s.inbox = s.inbox[1:]
+	next, err := req.op.Apply(s.state)
+	if err == nil && slices.ContainsFunc(next, func(t Todo) bool { return t.Title == "forbidden" }) {
+		err = errors.New("forbidden title")
+	}
+	if err != nil {
+		s.outboxs[req.from] = append(s.outboxs[req.from], message{cause: Rejected, id: req.op.ID})
+		return
+	}
+	s.state = next
+	for name := range s.outboxs {
+		m := message{cause: Remote, state: next}
+		if name == req.from {
+			m = message{cause: Confirmed, id: req.op.ID, state: next}
+		}
+		s.outboxs[name] = append(s.outboxs[name], m)
+	}
+}
+
+type client struct {
+	name  string
+	store *Store[List]
+	n     int
+}
+
+func (c *client) deliver(srv *server) error {
+	m := srv.outboxs[c.name][0]
+	srv.outboxs[c.name] = srv.outboxs[c.name][1:]
+	switch m.cause {
+	case Confirmed:
+		return c.store.Confirm(m.id, m.state)
+	case Rejected:
+		return c.store.Reject(m.id)
+	default:
+		c.store.Sync(m.state)
+		return nil
+	}
+}
+
+func (c *client) randomOp(rng *rand.Rand) Op[List] {
+	c.n++
+	opID := fmt.Sprintf("%s-op%d", c.name, c.n)
+	view := c.store.View()
+	if len(view) == 0 || rng.Intn(4) == 0 {
+		return AddTodo(opID, fmt.Sprintf("%s-t%d", c.name, c.n), "new")
+	}
+	id := view[rng.Intn(len(view))].ID
+	switch rng.Intn(4) {
+	case 0:
+		return RemoveTodo(opID, id)
+	case 1:
+		return SetDone(opID, id, rng.Intn(2) == 0)
+	default:
+		return Rename(opID, id, []string{"a", "b", "forbidden"}[rng.Intn(3)])
+	}
+}
+
+// TestConvergence lets two clients edit the same list while the server's
+// answers are delayed and interleaved at random. Once everything is
+// delivered, both clients must show exactly the server's state.
+func TestConvergence(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		srv := &server{outboxs: map[string][]message{"a": nil, "b": nil}}
+		clients := []*client{{name: "a", store: New(List{})}, {name: "b", store: New(List{})}}
+
+		for step := 0; step < 200; step++ {
+			c := clients[rng.Intn(len(clients))]
+			switch r := rng.Intn(3); {
+			case r == 0:
+				op := c.randomOp(rng)
+				if c.store.Do(op) == nil {
+					srv.inbox = append(srv.inbox, request{c.name, op})
+				}
+			case r == 1 && len(srv.inbox) > 0:
+				srv.handle()
+			case len(srv.outboxs[c.name]) > 0:
+				if err := c.deliver(srv); err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+			}
+		}
+		for len(srv.inbox) > 0 {
+			srv.handle()
+		}
+		for _, c := range clients {
+			for len(srv.outboxs[c.name]) > 0 {
+				if err := c.deliver(srv); err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+			}
+			if len(c.store.Pending()) != 0 {
+				t.Fatalf("seed %d: %s still has %v pending", seed, c.name, c.store.Pending())
+			}
+			if p, _ := diff.Diff(c.store.View(), srv.state); len(p) > 0 {
+				t.Fatalf("seed %d: %s diverged from the server:\n%s", seed, c.name, p)
+			}
+		}
+	}
+}
+
+func Example() {
+	s := New(List{{ID: "t1", Title: "milk"}})
+	s.Subscribe(func(c Change[List]) {
+		fmt.Println(c.Cause, c.ID, titles(c.View), c.RolledBack)
+	})
+
+	s.Do(SetDone("op1", "t1", true))
+	s.Do(AddTodo("op2", "t2", "bread"))
+	// The server accepts the toggle, then another client deletes milk.
+	s.Confirm("op1", List{{ID: "t1", Title: "milk", Done: true}})
+	s.Sync(List{})
+	s.Reject("op2")
+	// Output:
+	// local op1 [xmilk] []
+	// local op2 [xmilk  bread] []
+	// confirmed op1 [xmilk  bread] []
+	// remote  [ bread] []
+	// rejected op2 [] []
+}
@@ -0,0 +1,67 @@
+package reconcile
+
+import (
+	"errors"
+	"slices"
+)
+
+// ErrNoTodo is returned by todo operations on an ID not in the list.
+var ErrNoTodo = errors.New("reconcile: no such todo")
+
+// Todo is an item of a todo list.
+type Todo struct {
+	ID    string
+	Title string
+	Done  bool
+}
+
+// List is a todo list, the example state for a Store.
+type List []Todo
+
+func (l List) find(id string) int {
+	return slices.IndexFunc(l, func(t Todo) bool { return t.ID == id })
+}
+
+// AddTodo returns an operation that appends a todo. Adding an ID that is
+// already in the list leaves it alone.
+func AddTodo(opID, id, title string) Op[List] {
+	return Op[List]{ID: opID, Apply: func(l List) (List, error) {
+		if l.find(id) >= 0 {
+			return l, nil
+		}
+		return append(slices.Clip(l), Todo{ID: id, Title: title}), nil
+	}}
+}
+
+// SetDone returns an operation that marks a todo done or not done.
+func SetDone(opID, id string, done bool) Op[List] {
+	return update(opID, id, func(t *Todo) { t.Done = done })
+}
+
+// Rename returns an operation that changes the title of a todo.
+func Rename(opID, id, title string) Op[List] {
+	return update(opID, id, func(t *Todo) { t.Title = title })
+}
+
+func update(opID, id string, f func(*Todo)) Op[List] {
+	return Op[List]{ID: opID, Apply: func(l List) (List, error) {
+		i := l.find(id)
+		if i < 0 {
+			return nil, ErrNoTodo
+		}
+		l = slices.Clone(l)
+		f(&l[i])
+		return l, nil
+	}}
+}
+
+// RemoveTodo returns an operation that deletes a todo.
+func RemoveTodo(opID, id string) Op[List] {
+	return Op[List]{ID: opID, Apply: func(l List) (List, error) {
+		i := l.find(id)
+		if i < 0 {
+			return nil, ErrNoTodo
+		}
+		return slices.Delete(slices.Clone(l), i, i+1), nil
+	}}
+}
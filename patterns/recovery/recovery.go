@@ -0,0 +1,170 @@
+// Package recovery is the single place where panics are turned into errors.
+// A Boundary recovers a panic, wraps it in a *PanicError carrying the stack
+// of the panicking goroutine, hands it to the registered hooks and either
+// returns it as an ordinary error or, for panics it considers fatal, panics
+// again once the hooks have seen it.
+//
+// The worker pool, the generator stages and HTTP handlers all recover
+// through the Default boundary, so a program registers its hooks once with
+// OnPanic and sees every panic the patterns in this repository contain.
+package recovery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is a recovered panic.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the formatted stack of the goroutine that panicked.
+	Stack []byte
+
+	pcs []uintptr
+}
+
+func newPanicError(v interface{}) *PanicError {
+	pcs := make([]uintptr, 64)
+	pcs = pcs[:runtime.Callers(0, pcs)]
+	// Drop the frames of the boundary itself so that the trace starts at
+	// the function that panicked.
+	for i, pc := range pcs {
+		if fn := runtime.FuncForPC(pc - 1); fn != nil && fn.Name() == "runtime.gopanic" {
+			pcs = pcs[i+1:]
+			break
+		}
+	}
+	return &PanicError{Value: v, Stack: debug.Stack(), pcs: pcs}
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("recovery: panic: %v", p.Value)
+}
+
+// Unwrap returns the panic value if it is an error, so errors.Is and
+// errors.As see through the panic.
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// Callers returns the program counters of the panicking goroutine, starting
+// at the function that panicked.
+func (p *PanicError) Callers() []uintptr {
+	return p.pcs
+}
+
+// IsFatal is the default Fatal predicate. It only re-raises
+// http.ErrAbortHandler, which net/http relies on to abort a response.
+func IsFatal(p *PanicError) bool {
+	err, ok := p.Value.(error)
+	return ok && errors.Is(err, http.ErrAbortHandler)
+}
+
+// Boundary recovers panics and reports them to its hooks.
+type Boundary struct {
+	// Fatal reports whether a panic must be raised again after the hooks
+	// have run. A nil Fatal recovers everything.
+	Fatal func(*PanicError) bool
+
+	mu    sync.RWMutex
+	hooks []func(*PanicError)
+}
+
+// New returns a boundary that uses IsFatal and has no hooks.
+func New() *Boundary {
+	return &Boundary{Fatal: IsFatal}
+}
+
+// Default is the boundary used by the package level functions and by the
+// other packages in this repository.
+var Default = New()
+
+// OnPanic registers hook to be called with every panic b recovers. Hooks
+// run on the panicking goroutine, in registration order.
+func (b *Boundary) OnPanic(hook func(*PanicError)) {
+	b.mu.Lock()
+	b.hooks = append(b.hooks, hook)
+	b.mu.Unlock()
+}
+
+// Recover must be deferred directly. It stops a panic in progress and
+// stores it in *errp as a *PanicError; errp may be nil when only the hooks
+// are of interest.
+//
+//	func work() (err error) {
+//		defer recovery.Default.Recover(&err)
+//		...
+//	}
+func (b *Boundary) Recover(errp *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	p := b.handle(r)
+	if errp != nil {
+		*errp = p
+	}
+}
+
+// handle reports r to the hooks and re-panics if it is fatal.
+func (b *Boundary) handle(r interface{}) *PanicError {
+	p := newPanicError(r)
+	b.mu.RLock()
+	hooks := b.hooks
+	b.mu.RUnlock()
+	for _, hook := range hooks {
+		hook(p)
+	}
+	if b.Fatal != nil && b.Fatal(p) {
+		panic(r)
+	}
+	return p
+}
+
+// Do calls fn and returns its error, or a *PanicError if fn panicked.
+func (b *Boundary) Do(fn func() error) (err error) {
+	defer b.Recover(&err)
+	return fn()
+}
+
+// Go runs fn in a new goroutine behind the boundary. A recovered panic only
+// reaches the hooks.
+func (b *Boundary) Go(fn func()) {
+	go func() {
+		defer b.Recover(nil)
+		fn()
+	}()
+}
+
+// Middleware recovers panics in next and answers 500 Internal Server Error
+// instead of letting net/http drop the connection.
+func (b *Boundary) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		defer func() {
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		defer b.Recover(&err)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OnPanic registers hook with the Default boundary.
+func OnPanic(hook func(*PanicError)) { Default.OnPanic(hook) }
+
+// Do calls fn behind the Default boundary.
+func Do(fn func() error) error { return Default.Do(fn) }
+
+// Go runs fn in a new goroutine behind the Default boundary.
+func Go(fn func()) { Default.Go(fn) }
+
+// Middleware wraps next with the Default boundary.
+func Middleware(next http.Handler) http.Handler { return Default.Middleware(next) }
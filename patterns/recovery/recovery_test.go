@@ -0,0 +1,106 @@
+package recovery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var errBoom = errors.New("boom")
+
+func explode() error { panic(errBoom) }
+
+func TestDo(t *testing.T) {
+	b := New()
+	var hooked []*PanicError
+	b.OnPanic(func(p *PanicError) { hooked = append(hooked, p) })
+
+	err := b.Do(explode)
+	var p *PanicError
+	if !errors.As(err, &p) {
+		t.Fatalf("expected a *PanicError, got %v", err)
+	}
+	if !errors.Is(err, errBoom) {
+		t.Error("PanicError should unwrap to the panic value")
+	}
+	if len(hooked) != 1 || hooked[0] != p {
+		t.Errorf("hook should see the same PanicError, got %v", hooked)
+	}
+	if !strings.Contains(string(p.Stack), "explode") {
+		t.Errorf("stack does not mention the panicking function:\n%s", p.Stack)
+	}
+	frames := runtime.CallersFrames(p.Callers())
+	if f, _ := frames.Next(); !strings.HasSuffix(f.Function, ".explode") {
+		t.Errorf("Callers should start at the panicking function, got %s", f.Function)
+	}
+
+	if err := b.Do(func() error { return errBoom }); err != errBoom {
+		t.Errorf("plain errors must pass through, got %v", err)
+	}
+}
+
+func TestFatalRepanics(t *testing.T) {
+	b := New()
+	var hooked int
+	b.OnPanic(func(*PanicError) { hooked++ })
+
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Errorf("expected ErrAbortHandler to be re-raised, got %v", r)
+		}
+		if hooked != 1 {
+			t.Errorf("hooks should run before re-panicking, ran %d times", hooked)
+		}
+	}()
+	b.Do(func() error { panic(http.ErrAbortHandler) })
+	t.Error("Do returned for a fatal panic")
+}
+
+func TestGo(t *testing.T) {
+	b := New()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.OnPanic(func(p *PanicError) {
+		if p.Value != "lost" {
+			t.Errorf("unexpected value %v", p.Value)
+		}
+		wg.Done()
+	})
+	b.Go(func() { panic("lost") })
+	wg.Wait()
+}
+
+func TestMiddleware(t *testing.T) {
+	b := New()
+	var hooked int
+	b.OnPanic(func(*PanicError) { hooked++ })
+	h := b.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("handler bug")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusInternalServerError || hooked != 1 {
+		t.Errorf("got status %d and %d hook calls", rec.Code, hooked)
+	}
+}
+
+func ExampleBoundary_Do() {
+	b := New()
+	b.OnPanic(func(p *PanicError) { fmt.Println("hook:", p.Value) })
+
+	err := b.Do(func() error {
+		var m map[string]int
+		m["x"] = 1
+		return nil
+	})
+	fmt.Println(err)
+	// Output:
+	// hook: assignment to entry in nil map
+	// recovery: panic: assignment to entry in nil map
+}
@@ -0,0 +1,114 @@
+// Package registry is the driver registration pattern of database/sql and
+// image: implementations register a factory under a name from an init
+// function, and the program picks one by name at run time, typically from
+// its configuration.
+//
+//	import _ "example.com/app/storage/s3" // registers "s3"
+//
+//	store, err := storage.Open(cfg.Backend, cfg.DSN)
+//
+// The package that defines the interface owns a Registry and exports a
+// Register function for drivers to call, and an Open or Lookup for
+// programs. A driver is compiled in by importing it, for its side effect
+// alone if nothing else refers to it; the storage subpackage shows the
+// whole arrangement.
+//
+// Registering a name twice is a programming error, two drivers claiming
+// one name or a package initialized under two import paths, so Register
+// panics, as sql.Register does: it runs at init, where there is nobody to
+// return an error to and failing at once is the useful outcome. Add is the
+// same with an error, for registration at run time, such as of plugins
+// found on disk. A Registry is safe for concurrent use, though lookups
+// after init, all reads, are the common case.
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrDuplicate is returned by Add for a name already registered.
+	ErrDuplicate = errors.New("registry: name already registered")
+	// ErrNotFound is returned by Get for a name nobody registered.
+	ErrNotFound = errors.New("registry: name not registered")
+	// ErrName is returned by Add for an empty name or one with spaces.
+	ErrName = errors.New("registry: bad name")
+)
+
+// Registry maps names to values of type T, usually factory functions.
+type Registry[T any] struct {
+	kind string
+
+	mu      sync.RWMutex
+	entries map[string]T
+}
+
+// New returns an empty Registry. kind names what it holds, such as
+// "storage driver", in its errors.
+func New[T any](kind string) *Registry[T] {
+	return &Registry[T]{kind: kind, entries: make(map[string]T)}
+}
+
+// Add registers v under name.
+func (r *Registry[T]) Add(name string, v T) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("%w: %s %q", ErrName, r.kind, name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.entries[name]; dup {
+		return fmt.Errorf("%w: %s %q", ErrDuplicate, r.kind, name)
+	}
+	r.entries[name] = v
+	return nil
+}
+
+// Register is like Add but panics on error. It is meant for init
+// functions.
+func (r *Registry[T]) Register(name string, v T) {
+	if err := r.Add(name, v); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the value registered under name.
+func (r *Registry[T]) Lookup(name string) (T, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.entries[name]
+	return v, ok
+}
+
+// Get is like Lookup, but its error for a missing name lists the names
+// that are registered, which is usually what the person who misspelled it
+// in a config file needs, or a reminder of the blank import they left
+// out.
+func (r *Registry[T]) Get(name string) (T, error) {
+	v, ok := r.Lookup(name)
+	if !ok {
+		return v, fmt.Errorf("%w: %s %q (registered: %s)", ErrNotFound, r.kind, name, strings.Join(r.Names(), ", "))
+	}
+	return v, nil
+}
+
+// Names returns the registered names in order.
+func (r *Registry[T]) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Sorted(maps.Keys(r.entries))
+}
+
+// Remove unregisters name and reports whether it was registered. Tests
+// use it to undo a registration of their own.
+func (r *Registry[T]) Remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.entries[name]
+	delete(r.entries, name)
+	return ok
+}
@@ -0,0 +1,115 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type factory func() string
+
+func TestAddAndLookup(t *testing.T) {
+	r := New[factory]("greeter")
+	if err := r.Add("en", func() string { return "hello" }); err != nil {
+		t.Fatal(err)
+	}
+	r.Register("fr", func() string { return "bonjour" })
+
+	for _, tt := range []struct{ name, want string }{{"en", "hello"}, {"fr", "bonjour"}} {
+		f, ok := r.Lookup(tt.name)
+		if !ok || f() != tt.want {
+			t.Errorf("Lookup(%s) = %v", tt.name, ok)
+		}
+	}
+	if _, ok := r.Lookup("de"); ok {
+		t.Error("found an unregistered name")
+	}
+	if got := fmt.Sprint(r.Names()); got != "[en fr]" {
+		t.Errorf("Names = %s", got)
+	}
+}
+
+func TestAddErrors(t *testing.T) {
+	r := New[int]("number")
+	r.Register("one", 1)
+	for _, tt := range []struct {
+		name string
+		want error
+		msg  string
+	}{
+		{"one", ErrDuplicate, `registry: name already registered: number "one"`},
+		{"", ErrName, `registry: bad name: number ""`},
+		{"two words", ErrName, `registry: bad name: number "two words"`},
+	} {
+		err := r.Add(tt.name, 2)
+		if !errors.Is(err, tt.want) || err.Error() != tt.msg {
+			t.Errorf("Add(%q) = %v", tt.name, err)
+		}
+	}
+	if v, _ := r.Lookup("one"); v != 1 {
+		t.Errorf("a duplicate replaced the first registration: %d", v)
+	}
+}
+
+func TestRegisterPanicsOnDuplicate(t *testing.T) {
+	r := New[int]("number")
+	r.Register("one", 1)
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, ErrDuplicate) {
+			t.Errorf("recovered %v", err)
+		}
+	}()
+	r.Register("one", 1)
+}
+
+func TestGetListsNames(t *testing.T) {
+	r := New[int]("codec")
+	r.Register("json", 1)
+	r.Register("gob", 2)
+	_, err := r.Get("jsno")
+	if !errors.Is(err, ErrNotFound) || !strings.HasSuffix(err.Error(), `codec "jsno" (registered: gob, json)`) {
+		t.Errorf("Get = %v", err)
+	}
+	if v, err := r.Get("gob"); v != 2 || err != nil {
+		t.Errorf("Get(gob) = %d, %v", v, err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	r := New[int]("number")
+	r.Register("one", 1)
+	if !r.Remove("one") || r.Remove("one") {
+		t.Error("Remove reported wrong")
+	}
+	r.Register("one", 1) // free again
+}
+
+func TestConcurrent(t *testing.T) {
+	r := New[int]("number")
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	dups := 0
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				if err := r.Add(fmt.Sprint(j), i); errors.Is(err, ErrDuplicate) {
+					mu.Lock()
+					dups++
+					mu.Unlock()
+				}
+				r.Lookup(fmt.Sprint(j))
+				r.Names()
+			}
+		}()
+	}
+	wg.Wait()
+	// Every name was registered once, by whichever goroutine came first.
+	if n := len(r.Names()); n != 50 || dups != 7*50 {
+		t.Errorf("%d names, %d duplicates", n, dups)
+	}
+}
@@ -0,0 +1,81 @@
+// Package file is a storage driver keeping each value in a file of a
+// directory. Importing it registers it as "file"; its DSN is the
+// directory, which is created if missing.
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/crazybber/go-patterns/patterns/registry/storage"
+)
+
+func init() {
+	storage.Register("file", func(dsn string) (storage.Store, error) { return Open(dsn) })
+}
+
+// Store is a storage.Store in a directory.
+type Store struct {
+	dir string
+}
+
+// Open returns a Store in dir.
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, errors.New("file: empty directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("file: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// path escapes key so that any key names one file inside the directory.
+func (s *Store) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." {
+		return "", fmt.Errorf("file: bad key %q", key)
+	}
+	return filepath.Join(s.dir, url.PathEscape(key)), nil
+}
+
+// Get implements storage.Store.
+func (s *Store) Get(key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	v, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, storage.ErrNotFound
+	}
+	return v, err
+}
+
+// Put implements storage.Store. It writes a temporary file and renames it,
+// so a reader sees the old value or the new one.
+func (s *Store) Put(key string, value []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Close implements storage.Store.
+func (s *Store) Close() error { return nil }
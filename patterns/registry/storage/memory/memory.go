@@ -0,0 +1,45 @@
+// Package memory is a storage driver keeping values in a map. Importing it
+// registers it as "memory"; its DSN is ignored.
+package memory
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/crazybber/go-patterns/patterns/registry/storage"
+)
+
+func init() {
+	storage.Register("memory", func(string) (storage.Store, error) { return New(), nil })
+}
+
+// Store is a storage.Store in memory.
+type Store struct {
+	mu sync.RWMutex
+	m  map[string][]byte
+}
+
+// New returns an empty Store.
+func New() *Store { return &Store{m: make(map[string][]byte)} }
+
+// Get implements storage.Store.
+func (s *Store) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return bytes.Clone(v), nil
+}
+
+// Put implements storage.Store.
+func (s *Store) Put(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = bytes.Clone(value)
+	return nil
+}
+
+// Close implements storage.Store.
+func (s *Store) Close() error { return nil }
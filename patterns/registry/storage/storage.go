@@ -0,0 +1,42 @@
+// Package storage is the interface side of the registration pattern: it
+// defines a key-value Store, keeps a registry of drivers that open one,
+// and opens the one a program names. It depends on no driver. The memory
+// and file subpackages are drivers, registering themselves when imported.
+package storage
+
+import (
+	"errors"
+
+	"github.com/crazybber/go-patterns/patterns/registry"
+)
+
+// ErrNotFound is returned by Get for a missing key.
+var ErrNotFound = errors.New("storage: key not found")
+
+// Store is a key-value store.
+type Store interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+	Close() error
+}
+
+// Driver opens a Store described by dsn, whose form is the driver's.
+type Driver func(dsn string) (Store, error)
+
+var drivers = registry.New[Driver]("storage driver")
+
+// Register makes a driver available by name. Drivers call it from init.
+// It panics if the name is taken.
+func Register(name string, d Driver) { drivers.Register(name, d) }
+
+// Open opens a Store with the driver registered under name.
+func Open(name, dsn string) (Store, error) {
+	d, err := drivers.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return d(dsn)
+}
+
+// Drivers returns the names of the registered drivers.
+func Drivers() []string { return drivers.Names() }
@@ -0,0 +1,83 @@
+package storage_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/crazybber/go-patterns/patterns/registry"
+	"github.com/crazybber/go-patterns/patterns/registry/storage"
+	_ "github.com/crazybber/go-patterns/patterns/registry/storage/file"
+	_ "github.com/crazybber/go-patterns/patterns/registry/storage/memory"
+)
+
+func TestDriversRegistered(t *testing.T) {
+	if got := fmt.Sprint(storage.Drivers()); got != "[file memory]" {
+		t.Errorf("Drivers = %s", got)
+	}
+}
+
+// Every driver must behave the same behind the interface.
+func TestDrivers(t *testing.T) {
+	for _, name := range storage.Drivers() {
+		t.Run(name, func(t *testing.T) {
+			s, err := storage.Open(name, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if _, err := s.Get("a"); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Get of a missing key = %v", err)
+			}
+			for _, key := range []string{"a", "a/b", "../c", "d e"} {
+				if err := s.Put(key, []byte("v1")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s.Put("a", []byte("v2"))
+			for key, want := range map[string]string{"a": "v2", "a/b": "v1", "../c": "v1", "d e": "v1"} {
+				if v, err := s.Get(key); string(v) != want || err != nil {
+					t.Errorf("Get(%q) = %q, %v", key, v, err)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownDriver(t *testing.T) {
+	_, err := storage.Open("s3", "bucket")
+	if !errors.Is(err, registry.ErrNotFound) || !strings.Contains(err.Error(), "registered: file, memory") {
+		t.Errorf("Open(s3) = %v", err)
+	}
+}
+
+func TestDuplicateDriverPanics(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, registry.ErrDuplicate) {
+			t.Errorf("recovered %v", err)
+		}
+	}()
+	storage.Register("memory", nil)
+}
+
+func Example() {
+	// The blank import of a driver registers it; the program only names
+	// it, here as if from its configuration.
+	backend := "memory"
+	s, err := storage.Open(backend, "")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer s.Close()
+	s.Put("greeting", []byte("hello"))
+	v, _ := s.Get("greeting")
+	fmt.Println(string(v))
+
+	_, err = storage.Open("redis", "localhost:6379")
+	fmt.Println(err)
+	// Output:
+	// hello
+	// registry: name not registered: storage driver "redis" (registered: file, memory)
+}
@@ -0,0 +1,218 @@
+// Package repl runs a read-eval-print loop over registered commands.
+//
+// It is deliberately readline-lite: it reads whole lines, so it works the
+// same on a terminal in cooked mode, through a pipe and from a test script.
+// History is kept and recalled with !! and !n, and a line ending in a tab
+// asks for completions instead of being run; command names complete
+// themselves, and a command can complete its arguments with a hook.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Command is a REPL command.
+type Command struct {
+	Name string
+	// Help is a one line description shown by the help command.
+	Help string
+	// Run executes the command. Output goes to w.
+	Run func(ctx context.Context, w io.Writer, args []string) error
+	// Complete, if set, returns candidates for the last, possibly empty,
+	// argument in args.
+	Complete func(args []string) []string
+}
+
+// REPL is a read-eval-print loop.
+type REPL struct {
+	// Prompt is printed before every line. Defaults to "> ".
+	Prompt string
+	// MaxHistory bounds the history. Defaults to 100.
+	MaxHistory int
+
+	in       *bufio.Scanner
+	out      io.Writer
+	commands map[string]Command
+	history  []string
+	quit     bool
+}
+
+// New returns a REPL reading from in and writing to out, with the built-in
+// commands help, history and exit.
+func New(in io.Reader, out io.Writer) *REPL {
+	r := &REPL{
+		Prompt:     "> ",
+		MaxHistory: 100,
+		in:         bufio.NewScanner(in),
+		out:        out,
+		commands:   make(map[string]Command),
+	}
+	r.Register(Command{Name: "help", Help: "list the commands", Run: r.help})
+	r.Register(Command{Name: "history", Help: "list the previous lines", Run: r.showHistory})
+	r.Register(Command{Name: "exit", Help: "leave", Run: func(context.Context, io.Writer, []string) error {
+		r.quit = true
+		return nil
+	}})
+	return r
+}
+
+// Register adds c, replacing a command of the same name.
+func (r *REPL) Register(c Command) {
+	r.commands[c.Name] = c
+}
+
+// Run loops until the input ends, exit is entered or ctx is done. Errors
+// of commands are printed and the loop goes on.
+func (r *REPL) Run(ctx context.Context) error {
+	for !r.quit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fmt.Fprint(r.out, r.Prompt)
+		if !r.in.Scan() {
+			fmt.Fprintln(r.out)
+			return r.in.Err()
+		}
+		line := r.in.Text()
+		if strings.HasSuffix(line, "\t") {
+			r.printCompletions(strings.TrimSuffix(line, "\t"))
+			continue
+		}
+		line, err := r.expand(strings.TrimSpace(line))
+		if err != nil {
+			fmt.Fprintln(r.out, "error:", err)
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		r.remember(line)
+		if err := r.eval(ctx, line); err != nil {
+			fmt.Fprintln(r.out, "error:", err)
+		}
+	}
+	return nil
+}
+
+func (r *REPL) eval(ctx context.Context, line string) error {
+	fields := strings.Fields(line)
+	c, ok := r.commands[fields[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, try help", fields[0])
+	}
+	return c.Run(ctx, r.out, fields[1:])
+}
+
+// expand replaces !! with the last line and !n with line n of the history.
+// The expanded line is echoed like a shell does.
+func (r *REPL) expand(line string) (string, error) {
+	if !strings.HasPrefix(line, "!") {
+		return line, nil
+	}
+	var n int
+	if line == "!!" {
+		n = len(r.history)
+	} else {
+		var err error
+		if n, err = strconv.Atoi(line[1:]); err != nil {
+			return "", fmt.Errorf("bad history reference %q", line)
+		}
+	}
+	if n < 1 || n > len(r.history) {
+		return "", errors.New("no such history entry")
+	}
+	line = r.history[n-1]
+	fmt.Fprintln(r.out, line)
+	return line, nil
+}
+
+func (r *REPL) remember(line string) {
+	r.history = append(r.history, line)
+	if over := len(r.history) - r.MaxHistory; r.MaxHistory > 0 && over > 0 {
+		r.history = r.history[over:]
+	}
+}
+
+// History returns the lines entered so far, oldest first.
+func (r *REPL) History() []string {
+	return append([]string(nil), r.history...)
+}
+
+// Complete returns the candidates for the last word of line: command names
+// for the first word, the command's own hook after that.
+func (r *REPL) Complete(line string) []string {
+	fields := strings.Fields(line)
+	if line == "" || strings.HasSuffix(line, " ") {
+		fields = append(fields, "")
+	}
+	if len(fields) == 1 {
+		var names []string
+		for name := range r.commands {
+			if strings.HasPrefix(name, fields[0]) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+	c, ok := r.commands[fields[0]]
+	if !ok || c.Complete == nil {
+		return nil
+	}
+	return c.Complete(fields[1:])
+}
+
+// printCompletions shows the candidates for line, or the completed line
+// when there is exactly one.
+func (r *REPL) printCompletions(line string) {
+	candidates := r.Complete(line)
+	switch len(candidates) {
+	case 0:
+	case 1:
+		i := strings.LastIndexAny(line, " ") + 1
+		fmt.Fprintln(r.out, line[:i]+candidates[0])
+	default:
+		fmt.Fprintln(r.out, strings.Join(candidates, "  "))
+	}
+}
+
+func (r *REPL) help(_ context.Context, w io.Writer, _ []string) error {
+	names := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, r.commands[name].Help)
+	}
+	return nil
+}
+
+func (r *REPL) showHistory(_ context.Context, w io.Writer, _ []string) error {
+	for i, line := range r.history {
+		fmt.Fprintf(w, "%4d  %s\n", i+1, line)
+	}
+	return nil
+}
+
+// Prefixed returns a completion hook that offers the words starting with
+// the argument being completed.
+func Prefixed(words ...string) func(args []string) []string {
+	return func(args []string) []string {
+		last := args[len(args)-1]
+		var out []string
+		for _, w := range words {
+			if strings.HasPrefix(w, last) {
+				out = append(out, w)
+			}
+		}
+		return out
+	}
+}
@@ -0,0 +1,149 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// albums is a tiny interpreter in the spirit of behavioral/interpreter:
+// "album 2" or "author album 1" look fields of an entry up.
+var albums = []struct{ name, author string }{
+	{"Untouchables", "Korn"},
+	{"Adrenaline", "Deftones"},
+}
+
+func lookup(_ context.Context, w io.Writer, args []string) error {
+	var fields []string
+	var n int
+	for _, a := range args {
+		if v, err := strconv.Atoi(a); err == nil {
+			n = v
+			continue
+		}
+		fields = append(fields, a)
+	}
+	if n < 1 || n > len(albums) {
+		return fmt.Errorf("no album %d", n)
+	}
+	var out []string
+	for _, f := range fields {
+		switch f {
+		case "album":
+			out = append(out, albums[n-1].name)
+		case "author":
+			out = append(out, albums[n-1].author)
+		default:
+			return fmt.Errorf("unknown field %q", f)
+		}
+	}
+	fmt.Fprintln(w, strings.Join(out, " "))
+	return nil
+}
+
+func newREPL(script string, out io.Writer) *REPL {
+	r := New(strings.NewReader(script), out)
+	r.Register(Command{
+		Name:     "get",
+		Help:     "look up album fields: get album author 1",
+		Run:      lookup,
+		Complete: Prefixed("album", "author"),
+	})
+	return r
+}
+
+func TestScript(t *testing.T) {
+	var out bytes.Buffer
+	r := newREPL("get album 2\n\nget author album 1\n!!\n!1\nnope\nget 9\nhistory\n", &out)
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := `> Adrenaline
+> > Korn Untouchables
+> get author album 1
+Korn Untouchables
+> get album 2
+Adrenaline
+> error: unknown command "nope", try help
+> error: no album 9
+>    1  get album 2
+   2  get author album 1
+   3  get author album 1
+   4  get album 2
+   5  nope
+   6  get 9
+   7  history
+> 
+`
+	if out.String() != want {
+		t.Errorf("transcript:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	r := newREPL("", io.Discard)
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", []string{"exit", "get", "help", "history"}},
+		{"h", []string{"help", "history"}},
+		{"get a", []string{"album", "author"}},
+		{"get al", []string{"album"}},
+		{"get album ", []string{"album", "author"}},
+		{"history ", nil},
+	}
+	for _, tt := range tests {
+		if got := r.Complete(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+
+	var out bytes.Buffer
+	newREPL("get al\t\nhi\t\n", &out).Run(context.Background())
+	if want := "> get album\n> history\n> \n"; out.String() != want {
+		t.Errorf("tab completion printed %q, want %q", out.String(), want)
+	}
+}
+
+func TestExitAndHistoryLimit(t *testing.T) {
+	var out bytes.Buffer
+	r := newREPL("get album 1\nget album 2\nexit\nget album 1\n", &out)
+	r.MaxHistory = 2
+	r.Run(context.Background())
+	if strings.Count(out.String(), "Untouchables") != 1 {
+		t.Errorf("lines after exit were run:\n%s", out.String())
+	}
+	if got := r.History(); !reflect.DeepEqual(got, []string{"get album 2", "exit"}) {
+		t.Errorf("history %v", got)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := New(strings.NewReader("stop\nhelp\n"), io.Discard)
+	r.Register(Command{Name: "stop", Run: func(context.Context, io.Writer, []string) error {
+		cancel()
+		return nil
+	}})
+	if err := r.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func Example() {
+	r := newREPL("get author 2\nget al\t\n", os.Stdout)
+	r.Prompt = "albums> "
+	r.Run(context.Background())
+	// Output:
+	// albums> Deftones
+	// albums> get album
+	// albums>
+}
@@ -0,0 +1,158 @@
+// Package revproxy builds an httputil.ReverseProxy whose director is
+// assembled from small middlewares, in the same way http handlers are
+// chained. Header rewriting and canary routing are directors; retrying
+// idempotent requests is done one layer down, in the transport.
+package revproxy
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// Director rewrites an outgoing request, as httputil.ReverseProxy.Director.
+type Director func(*http.Request)
+
+// Middleware wraps a Director.
+type Middleware func(next Director) Director
+
+// New returns a reverse proxy to target whose director runs mws in order
+// after the default single-host rewrite. The first middleware sees the
+// request first, like an http middleware chain.
+func New(target *url.URL, mws ...Middleware) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Director = Chain(Director(proxy.Director), mws...)
+	return proxy
+}
+
+// Chain applies mws to base so that mws[0] runs first, then mws[1] and so
+// on, with each one deciding when to call the rest of the chain.
+func Chain(base Director, mws ...Middleware) Director {
+	d := base
+	for i := len(mws) - 1; i >= 0; i-- {
+		d = mws[i](d)
+	}
+	return d
+}
+
+// after wraps fn into a middleware that runs once the rest of the chain,
+// including the base director, has rewritten the request.
+func after(fn func(*http.Request)) Middleware {
+	return func(next Director) Director {
+		return func(r *http.Request) {
+			next(r)
+			fn(r)
+		}
+	}
+}
+
+// SetHeaders sets the given headers on every outgoing request and removes
+// the ones listed in del.
+func SetHeaders(set map[string]string, del ...string) Middleware {
+	return after(func(r *http.Request) {
+		for k, v := range set {
+			r.Header.Set(k, v)
+		}
+		for _, k := range del {
+			r.Header.Del(k)
+		}
+	})
+}
+
+// CanaryHeader is set on requests routed to the canary backend. Clients
+// can also send it with the value "always" or "never" to pin a request.
+const CanaryHeader = "X-Canary"
+
+// Canary sends percent of the requests to backend instead of the primary
+// target. roll returns a number in [0, 100); nil uses math/rand.
+func Canary(backend *url.URL, percent int, roll func() int) Middleware {
+	if roll == nil {
+		roll = func() int { return rand.Intn(100) }
+	}
+	return after(func(r *http.Request) {
+		switch r.Header.Get(CanaryHeader) {
+		case "never":
+			r.Header.Del(CanaryHeader)
+			return
+		case "always":
+		default:
+			if roll() >= percent {
+				r.Header.Del(CanaryHeader)
+				return
+			}
+		}
+		r.URL.Scheme = backend.Scheme
+		r.URL.Host = backend.Host
+		r.Host = backend.Host
+		r.Header.Set(CanaryHeader, "1")
+	})
+}
+
+// Retry is a RoundTripper that retries idempotent requests when the backend
+// cannot be reached or answers 502, 503 or 504.
+type Retry struct {
+	Base     http.RoundTripper
+	Attempts int
+	Backoff  time.Duration
+}
+
+// NewRetry wraps base, making up to attempts tries per request.
+func NewRetry(base http.RoundTripper, attempts int) *Retry {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Retry{Base: base, Attempts: attempts, Backoff: 10 * time.Millisecond}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Retry) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !retryable(r) {
+		return t.Base.RoundTrip(r)
+	}
+	backoff := t.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := t.Base.RoundTrip(r)
+		if attempt >= t.Attempts || !shouldRetry(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+		select {
+		case <-time.After(backoff):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+		backoff *= 2
+	}
+}
+
+func retryable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	// A body can only be sent again if it can be recreated.
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
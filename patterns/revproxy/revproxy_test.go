@@ -0,0 +1,160 @@
+package revproxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// backend answers with its name and echoes a header back.
+func backend(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen-Env", r.Header.Get("X-Env"))
+		w.Header().Set("X-Seen-Secret", r.Header.Get("X-Secret"))
+		io.WriteString(w, name)
+	}))
+}
+
+func mustURL(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func get(t *testing.T, h http.Handler, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/path", nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSetHeaders(t *testing.T) {
+	primary := backend("primary")
+	defer primary.Close()
+
+	proxy := New(mustURL(t, primary.URL), SetHeaders(map[string]string{"X-Env": "prod"}, "X-Secret"))
+	rec := get(t, proxy, map[string]string{"X-Secret": "hunter2"})
+
+	if rec.Body.String() != "primary" {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+	if rec.Header().Get("X-Seen-Env") != "prod" || rec.Header().Get("X-Seen-Secret") != "" {
+		t.Errorf("headers were not rewritten: %v", rec.Header())
+	}
+}
+
+func TestCanaryPercentage(t *testing.T) {
+	primary, canary := backend("primary"), backend("canary")
+	defer primary.Close()
+	defer canary.Close()
+
+	var n int
+	roll := func() int { n++; return n % 100 }
+	proxy := New(mustURL(t, primary.URL), Canary(mustURL(t, canary.URL), 10, roll))
+
+	counts := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		counts[get(t, proxy, nil).Body.String()]++
+	}
+	if counts["canary"] != 20 || counts["primary"] != 180 {
+		t.Errorf("expected a 10%% canary split, got %v", counts)
+	}
+}
+
+func TestCanaryPinning(t *testing.T) {
+	primary, canary := backend("primary"), backend("canary")
+	defer primary.Close()
+	defer canary.Close()
+
+	proxy := New(mustURL(t, primary.URL), Canary(mustURL(t, canary.URL), 50, func() int { return 0 }))
+	if body := get(t, proxy, map[string]string{CanaryHeader: "never"}).Body.String(); body != "primary" {
+		t.Errorf("never pin went to %s", body)
+	}
+	proxy = New(mustURL(t, primary.URL), Canary(mustURL(t, canary.URL), 0, nil))
+	if body := get(t, proxy, map[string]string{CanaryHeader: "always"}).Body.String(); body != "canary" {
+		t.Errorf("always pin went to %s", body)
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next Director) Director {
+			return func(r *http.Request) {
+				order = append(order, name)
+				next(r)
+			}
+		}
+	}
+	Chain(func(*http.Request) { order = append(order, "base") }, mw("a"), mw("b"))(httptest.NewRequest("GET", "/", nil))
+	if got := strings.Join(order, ","); got != "a,b,base" {
+		t.Errorf("unexpected order %s", got)
+	}
+}
+
+// flaky fails the first failures requests with 503.
+func flaky(failures int32, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= failures {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	var calls int32
+	srv := flaky(2, &calls)
+	defer srv.Close()
+
+	proxy := New(mustURL(t, srv.URL))
+	retry := NewRetry(nil, 3)
+	retry.Backoff = time.Millisecond
+	proxy.Transport = retry
+
+	rec := get(t, proxy, nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" || calls != 3 {
+		t.Errorf("expected success on the third attempt, got %d %q after %d calls", rec.Code, rec.Body.String(), calls)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var calls int32
+	srv := flaky(10, &calls)
+	defer srv.Close()
+
+	proxy := New(mustURL(t, srv.URL))
+	retry := NewRetry(nil, 2)
+	retry.Backoff = time.Millisecond
+	proxy.Transport = retry
+
+	if rec := get(t, proxy, nil); rec.Code != http.StatusServiceUnavailable || calls != 2 {
+		t.Errorf("expected 503 after 2 calls, got %d after %d", rec.Code, calls)
+	}
+}
+
+func TestNoRetryForPost(t *testing.T) {
+	var calls int32
+	srv := flaky(1, &calls)
+	defer srv.Close()
+
+	proxy := New(mustURL(t, srv.URL))
+	proxy.Transport = NewRetry(nil, 3)
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader("payload")))
+	if rec.Code != http.StatusServiceUnavailable || calls != 1 {
+		t.Errorf("POST must not be retried, got %d after %d calls", rec.Code, calls)
+	}
+}
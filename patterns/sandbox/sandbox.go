@@ -0,0 +1,267 @@
+// Package sandbox runs plugins with capabilities instead of ambient
+// authority.
+//
+// A plugin gets exactly what is in its Caps: a read-only view of the
+// store, a clock and a logger. It has no reference to the Store itself, so
+// it cannot write to it, and what it reads is a copy. The view is an
+// unexported type, so a type assertion cannot reach the store behind it.
+// None of this is checked at run time; the plugin simply has no way to
+// name anything else.
+//
+// The Host also bounds a run in time. A plugin gets a context that ends
+// when its budget is spent or the host closes. A plugin that ignores the
+// context is abandoned: Run returns ErrBudget, and the plugin's
+// capabilities are revoked, so from then on it reads nothing and logs
+// nothing. Neither a slow plugin nor a stuck one can hold up Close.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrBudget is returned by Run when a plugin overran its budget.
+	ErrBudget = errors.New("sandbox: plugin overran its budget")
+	// ErrPanic is returned by Run when a plugin panicked.
+	ErrPanic = errors.New("sandbox: plugin panicked")
+	// ErrClosed is returned by Run when the host is closed.
+	ErrClosed = errors.New("sandbox: host closed")
+)
+
+// ReadOnlyStore is the store as plugins see it.
+type ReadOnlyStore interface {
+	// Get returns a copy of the value of key.
+	Get(key string) ([]byte, bool)
+	// Keys returns the keys in order.
+	Keys() []string
+}
+
+// Clock tells plugins the time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Logger lets plugins write to the host's log.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// Caps are everything a plugin is given.
+type Caps struct {
+	Store ReadOnlyStore
+	Clock Clock
+	Log   Logger
+}
+
+// Plugin is code run by a Host.
+type Plugin interface {
+	Run(ctx context.Context, caps Caps) (any, error)
+}
+
+// PluginFunc adapts a function to a Plugin.
+type PluginFunc func(ctx context.Context, caps Caps) (any, error)
+
+func (f PluginFunc) Run(ctx context.Context, caps Caps) (any, error) { return f(ctx, caps) }
+
+// Store is a key-value store. Its owner has full access; plugins get a
+// ReadOnlyStore.
+type Store struct {
+	mu sync.RWMutex
+	m  map[string][]byte
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{m: map[string][]byte{}}
+}
+
+// Set stores a copy of value under key.
+func (s *Store) Set(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = slices.Clone(value)
+}
+
+// Get returns a copy of the value of key.
+func (s *Store) Get(key string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[key]
+	return slices.Clone(v), ok
+}
+
+// Keys returns the keys in order.
+func (s *Store) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.m))
+	for k := range s.m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Options configures a Host.
+type Options struct {
+	// Budget is how long a run may take. It defaults to one second.
+	Budget time.Duration
+	// LogLimit is the number of lines a run may log; the rest are
+	// dropped. It defaults to 100.
+	LogLimit int
+	// Log receives the lines plugins log. It defaults to the standard
+	// logger.
+	Log func(plugin, line string)
+	// Clock is the clock given to plugins. It defaults to the wall clock.
+	Clock Clock
+}
+
+func (o *Options) defaults() {
+	if o.Budget <= 0 {
+		o.Budget = time.Second
+	}
+	if o.LogLimit <= 0 {
+		o.LogLimit = 100
+	}
+	if o.Log == nil {
+		o.Log = func(plugin, line string) { log.Printf("%s: %s", plugin, line) }
+	}
+	if o.Clock == nil {
+		o.Clock = realClock{}
+	}
+}
+
+// Host runs plugins against a store.
+type Host struct {
+	store *Store
+	opts  Options
+	ctx   context.Context
+	close context.CancelFunc
+
+	mu      sync.Mutex
+	closed  bool
+	runs    sync.WaitGroup
+	running atomic.Int64
+}
+
+// NewHost returns a Host whose plugins may read store.
+func NewHost(store *Store, opts Options) *Host {
+	opts.defaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Host{store: store, opts: opts, ctx: ctx, close: cancel}
+}
+
+// grant is the capabilities of one run. Revoking it cuts the plugin off.
+type grant struct {
+	h       *Host
+	name    string
+	revoked atomic.Bool
+	logged  atomic.Int64
+}
+
+// view is the ReadOnlyStore of a grant.
+type view struct{ g *grant }
+
+func (v view) Get(key string) ([]byte, bool) {
+	if v.g.revoked.Load() {
+		return nil, false
+	}
+	return v.g.h.store.Get(key)
+}
+
+func (v view) Keys() []string {
+	if v.g.revoked.Load() {
+		return nil
+	}
+	return v.g.h.store.Keys()
+}
+
+// logger is the Logger of a grant.
+type logger struct{ g *grant }
+
+func (l logger) Printf(format string, args ...any) {
+	g := l.g
+	if g.revoked.Load() || g.logged.Add(1) > int64(g.h.opts.LogLimit) {
+		return
+	}
+	g.h.opts.Log(g.name, fmt.Sprintf(format, args...))
+}
+
+type result struct {
+	v   any
+	err error
+}
+
+// Run runs p under name and returns its result. It returns ErrBudget if p
+// has not returned by the end of its budget, ErrClosed if the host closes
+// first, and the error of ctx if ctx ends first. In each of those cases p
+// is left running without its capabilities.
+func (h *Host) Run(ctx context.Context, name string, p Plugin) (any, error) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, ErrClosed
+	}
+	h.runs.Add(1)
+	h.mu.Unlock()
+	defer h.runs.Done()
+
+	ctx, cancel := context.WithTimeoutCause(ctx, h.opts.Budget, ErrBudget)
+	defer cancel()
+	stop := context.AfterFunc(h.ctx, func() { cancel() })
+	defer stop()
+
+	g := &grant{h: h, name: name}
+	caps := Caps{Store: view{g}, Clock: h.opts.Clock, Log: logger{g}}
+	done := make(chan result, 1)
+	h.running.Add(1)
+	go func() {
+		defer h.running.Add(-1)
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("%w: %v", ErrPanic, r)}
+			}
+		}()
+		v, err := p.Run(ctx, caps)
+		done <- result{v, err}
+	}()
+
+	select {
+	case r := <-done:
+		g.revoked.Store(true)
+		return r.v, r.err
+	case <-ctx.Done():
+		g.revoked.Store(true)
+		if h.ctx.Err() != nil {
+			return nil, ErrClosed
+		}
+		return nil, context.Cause(ctx)
+	}
+}
+
+// Running returns the number of plugins still running, including those
+// abandoned by Run.
+func (h *Host) Running() int {
+	return int(h.running.Load())
+}
+
+// Close ends every run and waits for the calls to Run to return, which
+// they do at once. It does not wait for plugins that ignore their context.
+func (h *Host) Close() {
+	h.mu.Lock()
+	h.closed = true
+	h.mu.Unlock()
+	h.close()
+	h.runs.Wait()
+}
@@ -0,0 +1,195 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newStore() *Store {
+	s := NewStore()
+	s.Set("config/name", []byte("prod"))
+	s.Set("config/replicas", []byte("3"))
+	return s
+}
+
+// lines collects what plugins log.
+type lines struct {
+	mu sync.Mutex
+	l  []string
+}
+
+func (l *lines) log(plugin, line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.l = append(l.l, plugin+": "+line)
+}
+
+func (l *lines) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.l)
+}
+
+func TestPluginCannotMutate(t *testing.T) {
+	store := newStore()
+	h := NewHost(store, Options{})
+	defer h.Close()
+
+	_, err := h.Run(context.Background(), "evil", PluginFunc(func(_ context.Context, c Caps) (any, error) {
+		// Scribble over what it read.
+		v, _ := c.Store.Get("config/name")
+		copy(v, "hack")
+		// Look for a way to write.
+		if _, ok := c.Store.(*Store); ok {
+			return nil, errors.New("reached the store")
+		}
+		if w, ok := c.Store.(interface{ Set(string, []byte) }); ok {
+			w.Set("config/name", []byte("hacked"))
+			return nil, errors.New("found a setter")
+		}
+		return nil, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.Get("config/name"); string(v) != "prod" {
+		t.Errorf("store has %q", v)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	var log lines
+	h := NewHost(newStore(), Options{Budget: 20 * time.Millisecond, Log: log.log})
+	defer h.Close()
+
+	release := make(chan struct{})
+	after := make(chan []string)
+	start := time.Now()
+	_, err := h.Run(context.Background(), "stuck", PluginFunc(func(_ context.Context, c Caps) (any, error) {
+		<-release // ignores its context
+		c.Log.Printf("still here")
+		after <- c.Store.Keys()
+		return nil, nil
+	}))
+	if !errors.Is(err, ErrBudget) {
+		t.Fatalf("Run = %v, want ErrBudget", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Run took %v with a 20ms budget", d)
+	}
+	if n := h.Running(); n != 1 {
+		t.Errorf("Running = %d, want the abandoned plugin", n)
+	}
+
+	// Once it wakes up, it has lost its capabilities.
+	close(release)
+	if keys := <-after; keys != nil {
+		t.Errorf("abandoned plugin read %q", keys)
+	}
+	if l := log.get(); len(l) != 0 {
+		t.Errorf("abandoned plugin logged %q", l)
+	}
+}
+
+func TestCloseDoesNotWaitForStuckPlugins(t *testing.T) {
+	h := NewHost(newStore(), Options{Budget: time.Hour})
+	release := make(chan struct{})
+	defer close(release)
+
+	errs := make(chan error)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := h.Run(context.Background(), fmt.Sprint("stuck", i), PluginFunc(func(context.Context, Caps) (any, error) {
+				<-release
+				return nil, nil
+			}))
+			errs <- err
+		}()
+	}
+	for h.Running() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	h.Close()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Close took %v", d)
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-errs; !errors.Is(err, ErrClosed) {
+			t.Errorf("Run = %v, want ErrClosed", err)
+		}
+	}
+	if _, err := h.Run(context.Background(), "late", PluginFunc(func(context.Context, Caps) (any, error) {
+		return nil, nil
+	})); !errors.Is(err, ErrClosed) {
+		t.Errorf("Run after Close = %v", err)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	h := NewHost(newStore(), Options{})
+	defer h.Close()
+	_, err := h.Run(context.Background(), "crash", PluginFunc(func(context.Context, Caps) (any, error) {
+		var m map[string]int
+		m["x"]++
+		return nil, nil
+	}))
+	if !errors.Is(err, ErrPanic) {
+		t.Errorf("Run = %v, want ErrPanic", err)
+	}
+}
+
+func TestLogLimit(t *testing.T) {
+	var log lines
+	h := NewHost(newStore(), Options{LogLimit: 3, Log: log.log})
+	defer h.Close()
+	h.Run(context.Background(), "chatty", PluginFunc(func(_ context.Context, c Caps) (any, error) {
+		for i := 0; i < 1000; i++ {
+			c.Log.Printf("line %d", i)
+		}
+		return nil, nil
+	}))
+	want := []string{"chatty: line 0", "chatty: line 1", "chatty: line 2"}
+	if got := log.get(); !slices.Equal(got, want) {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+func Example() {
+	store := NewStore()
+	store.Set("orders/1", []byte("12.50"))
+	store.Set("orders/2", []byte("7.25"))
+
+	h := NewHost(store, Options{
+		Clock: fixedClock(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)),
+		Log:   func(plugin, line string) { fmt.Printf("[%s] %s\n", plugin, line) },
+	})
+	defer h.Close()
+
+	report := PluginFunc(func(ctx context.Context, c Caps) (any, error) {
+		var total float64
+		for _, k := range c.Store.Keys() {
+			v, _ := c.Store.Get(k)
+			var amount float64
+			fmt.Sscan(string(v), &amount)
+			total += amount
+		}
+		c.Log.Printf("summed %d orders", len(c.Store.Keys()))
+		return fmt.Sprintf("%s total %.2f", c.Clock.Now().Format(time.DateOnly), total), nil
+	})
+	out, err := h.Run(context.Background(), "report", report)
+	fmt.Println(out, err)
+	// Output:
+	// [report] summed 2 orders
+	// 2024-03-01 total 19.75 <nil>
+}
@@ -0,0 +1,189 @@
+// Package swr is a read-through cache with the stale-while-revalidate and
+// stale-if-error semantics known from HTTP caching and DNS resolvers:
+//
+//   - a fresh entry is served as is;
+//   - a stale entry inside the revalidate window is served immediately while
+//     a refresh runs in the background on a worker pool;
+//   - past that window the caller waits for a load, and if the load fails an
+//     entry still inside the stale-if-error window is served instead.
+//
+// Loads for the same key are collapsed with singleflight, and every entry
+// gets a jittered TTL so keys loaded together do not expire together. A
+// shared load belongs to no caller: it runs without their cancellation,
+// under LoadTimeout, and a caller that goes away only stops waiting.
+package swr
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/singleflight"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// Loader fetches the value for key from the source of truth.
+type Loader[V any] func(ctx context.Context, key string) (V, error)
+
+// Options configures a Cache.
+type Options struct {
+	// TTL is how long an entry is fresh.
+	TTL time.Duration
+	// StaleWhileRevalidate is how long after TTL an entry is still served
+	// while it is refreshed in the background.
+	StaleWhileRevalidate time.Duration
+	// StaleIfError is how long after TTL an entry may be served when
+	// loading a new value fails.
+	StaleIfError time.Duration
+	// Jitter shortens every TTL by a random fraction up to Jitter.
+	Jitter float64
+	// LoadTimeout bounds every load. It defaults to 30 seconds.
+	LoadTimeout time.Duration
+
+	// Pool runs background refreshes. When it is saturated the refresh is
+	// skipped and tried again on a later read. Nil refreshes on a plain
+	// goroutine.
+	Pool *workerpool.Pool
+	// Now and Rand default to time.Now and rand.Float64.
+	Now  func() time.Time
+	Rand func() float64
+}
+
+type entry[V any] struct {
+	val     V
+	fresh   time.Time // fresh until
+	revalid time.Time // servable while revalidating until
+	onError time.Time // servable on error until
+}
+
+// Cache is a stale-while-revalidate cache in front of a Loader.
+type Cache[V any] struct {
+	load Loader[V]
+	opts Options
+
+	mu      sync.Mutex
+	entries map[string]*entry[V]
+
+	group      singleflight.Group
+	refreshing sync.WaitGroup
+	// inflight holds the keys being refreshed, guarded by mu.
+	inflight map[string]bool
+}
+
+// New creates a cache that loads missing and expired values with load.
+func New[V any](load Loader[V], opts Options) *Cache[V] {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Rand == nil {
+		opts.Rand = rand.Float64
+	}
+	if opts.LoadTimeout <= 0 {
+		opts.LoadTimeout = 30 * time.Second
+	}
+	return &Cache[V]{load: load, opts: opts, entries: make(map[string]*entry[V]), inflight: make(map[string]bool)}
+}
+
+// Get returns the value for key according to the cache semantics.
+func (c *Cache[V]) Get(ctx context.Context, key string) (V, error) {
+	now := c.opts.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && now.Before(e.fresh) {
+		return e.val, nil
+	}
+	if ok && now.Before(e.revalid) {
+		c.refresh(key)
+		return e.val, nil
+	}
+
+	v, err := c.fetch(ctx, key)
+	if err != nil && ok && c.opts.Now().Before(e.onError) {
+		return e.val, nil
+	}
+	return v, err
+}
+
+// fetch loads key, sharing the call with concurrent fetches of the key, and
+// stores the result on success. It returns ctx.Err() if ctx is done before
+// the load, which goes on for the others.
+func (c *Cache[V]) fetch(ctx context.Context, key string) (V, error) {
+	detached := context.WithoutCancel(ctx)
+	shared := c.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(detached, c.opts.LoadTimeout)
+		defer cancel()
+		v, err := c.load(ctx, key)
+		if err == nil {
+			c.store(key, v)
+		}
+		return v, err
+	})
+	select {
+	case r := <-shared:
+		// Not r.Val.(V), which panics on a nil interface value.
+		v, _ := r.Val.(V)
+		return v, r.Err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (c *Cache[V]) store(key string, v V) {
+	now := c.opts.Now()
+	ttl := c.opts.TTL
+	if c.opts.Jitter > 0 {
+		ttl -= time.Duration(float64(ttl) * c.opts.Jitter * c.opts.Rand())
+	}
+	fresh := now.Add(ttl)
+
+	c.mu.Lock()
+	c.entries[key] = &entry[V]{
+		val:     v,
+		fresh:   fresh,
+		revalid: fresh.Add(c.opts.StaleWhileRevalidate),
+		onError: fresh.Add(c.opts.StaleIfError),
+	}
+	c.mu.Unlock()
+}
+
+// refresh reloads key in the background, unless a refresh of key is
+// already under way, so that stale reads do not take pool goroutines only
+// to wait for the same load.
+func (c *Cache[V]) refresh(key string) {
+	c.mu.Lock()
+	if c.inflight[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.inflight[key] = true
+	c.mu.Unlock()
+
+	c.refreshing.Add(1)
+	task := func(ctx context.Context) error {
+		_, err := c.fetch(ctx, key)
+		return err
+	}
+	go func() {
+		defer c.refreshing.Done()
+		defer func() {
+			c.mu.Lock()
+			delete(c.inflight, key)
+			c.mu.Unlock()
+		}()
+		if c.opts.Pool == nil {
+			task(context.Background())
+			return
+		}
+		c.opts.Pool.TryRun(context.Background(), workerpool.WorkerFunc(task))
+	}()
+}
+
+// Wait blocks until background refreshes started so far have finished.
+func (c *Cache[V]) Wait() {
+	c.refreshing.Wait()
+}
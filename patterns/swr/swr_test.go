@@ -0,0 +1,234 @@
+package swr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// source is a loader whose answers and failures are scripted by the test.
+type source struct {
+	calls   int32
+	version int32
+	fail    int32
+}
+
+func (s *source) load(ctx context.Context, key string) (int, error) {
+	atomic.AddInt32(&s.calls, 1)
+	if atomic.LoadInt32(&s.fail) != 0 {
+		return 0, errors.New("source down")
+	}
+	return int(atomic.AddInt32(&s.version, 1)), nil
+}
+
+func newCache(src *source, clk *clock.Fake, pool *workerpool.Pool) *Cache[int] {
+	return New(src.load, Options{
+		TTL:                  10 * time.Second,
+		StaleWhileRevalidate: 5 * time.Second,
+		StaleIfError:         time.Minute,
+		Pool:                 pool,
+		Now:                  clk.Now,
+	})
+}
+
+func mustGet(t *testing.T, c *Cache[int], want int) {
+	t.Helper()
+	v, err := c.Get(context.Background(), "k")
+	if err != nil || v != want {
+		t.Fatalf("Get = %d, %v; want %d", v, err, want)
+	}
+}
+
+func TestFreshHit(t *testing.T) {
+	src, clk := &source{}, clock.NewFake(time.Unix(0, 0))
+	c := newCache(src, clk, nil)
+
+	mustGet(t, c, 1)
+	clk.Advance(9 * time.Second)
+	mustGet(t, c, 1)
+	if src.calls != 1 {
+		t.Errorf("expected one load, got %d", src.calls)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	src, clk := &source{}, clock.NewFake(time.Unix(0, 0))
+	pool := workerpool.New(1)
+	defer pool.Shutdown()
+	c := newCache(src, clk, pool)
+
+	mustGet(t, c, 1)
+	clk.Advance(12 * time.Second)
+
+	// The stale value comes back right away and the refresh lands later.
+	mustGet(t, c, 1)
+	c.Wait()
+	mustGet(t, c, 2)
+	if src.calls != 2 {
+		t.Errorf("expected two loads, got %d", src.calls)
+	}
+}
+
+func TestExpiredLoadsSynchronously(t *testing.T) {
+	src, clk := &source{}, clock.NewFake(time.Unix(0, 0))
+	c := newCache(src, clk, nil)
+
+	mustGet(t, c, 1)
+	clk.Advance(16 * time.Second)
+	mustGet(t, c, 2)
+}
+
+func TestStaleIfError(t *testing.T) {
+	src, clk := &source{}, clock.NewFake(time.Unix(0, 0))
+	c := newCache(src, clk, nil)
+
+	mustGet(t, c, 1)
+	atomic.StoreInt32(&src.fail, 1)
+
+	clk.Advance(30 * time.Second)
+	mustGet(t, c, 1)
+
+	clk.Advance(time.Minute)
+	if _, err := c.Get(context.Background(), "k"); err == nil {
+		t.Error("expected the error once the stale-if-error window has passed")
+	}
+}
+
+func TestMissError(t *testing.T) {
+	src, clk := &source{fail: 1}, clock.NewFake(time.Unix(0, 0))
+	c := newCache(src, clk, nil)
+	if _, err := c.Get(context.Background(), "k"); err == nil {
+		t.Error("expected an error without any cached value")
+	}
+}
+
+func TestSingleflight(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	load := func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "v", nil
+	}
+	c := New(load, Options{TTL: time.Minute})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Get(context.Background(), "k")
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("concurrent misses caused %d loads, want 1", calls)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	src, clk := &source{}, clock.NewFake(time.Unix(0, 0))
+	c := New(src.load, Options{
+		TTL:    10 * time.Second,
+		Jitter: 0.5,
+		Now:    clk.Now,
+		Rand:   func() float64 { return 1 },
+	})
+
+	mustGet(t, c, 1)
+	clk.Advance(6 * time.Second)
+	mustGet(t, c, 2)
+}
+
+func TestNilInterfaceValue(t *testing.T) {
+	c := New(func(context.Context, string) (error, error) { return nil, nil }, Options{TTL: time.Minute})
+	if v, err := c.Get(context.Background(), "k"); v != nil || err != nil {
+		t.Errorf("Get = %v, %v", v, err)
+	}
+	failing := New(func(context.Context, string) (any, error) { return nil, errors.New("down") }, Options{})
+	if _, err := failing.Get(context.Background(), "k"); err == nil {
+		t.Error("the error was lost")
+	}
+}
+
+// Stale reads while a refresh is under way do not submit more refreshes.
+func TestOneRefreshInFlight(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	release := make(chan struct{})
+	var calls atomic.Int32
+	load := func(context.Context, string) (int, error) {
+		if calls.Add(1) > 1 {
+			<-release
+		}
+		return int(calls.Load()), nil
+	}
+	pool := workerpool.New(4)
+	defer pool.Shutdown()
+	c := New(load, Options{TTL: 10 * time.Second, StaleWhileRevalidate: time.Minute, Pool: pool, Now: clk.Now})
+	mustGet(t, c, 1)
+	clk.Advance(20 * time.Second)
+	for range 10 {
+		mustGet(t, c, 1)
+	}
+	close(release)
+	c.Wait()
+	if n := pool.Stats().Started; n != 1 {
+		t.Errorf("%d refreshes ran on the pool, want 1", n)
+	}
+	mustGet(t, c, 2)
+}
+
+// A caller that gives up does not fail the others waiting for the load.
+func TestCancelledCallerDoesNotFailOthers(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	load := func(ctx context.Context, _ string) (string, error) {
+		close(started)
+		select {
+		case <-release:
+			return "v", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	c := New(load, Options{TTL: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.Get(ctx, "k")
+		first <- err
+	}()
+	<-started
+	second := make(chan string, 1)
+	go func() {
+		v, _ := c.Get(context.Background(), "k")
+		second <- v
+	}()
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Errorf("cancelled caller got %v", err)
+	}
+	close(release)
+	if v := <-second; v != "v" {
+		t.Errorf("other caller got %q", v)
+	}
+}
+
+func TestLoadTimeout(t *testing.T) {
+	load := func(ctx context.Context, _ string) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	c := New(load, Options{LoadTimeout: 10 * time.Millisecond})
+	if _, err := c.Get(context.Background(), "k"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get = %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Package taskcache puts a result cache in front of a worker pool, so
+// that work asked for twice is only done once.
+//
+// It is three patterns of this repository stacked up, the way memoize
+// stacks two of them for a plain function:
+//
+//   - a cache.TTL remembers the result of every Task that succeeded, under
+//     the Task's Key, for a while;
+//   - a singleflight.Group makes concurrent Tasks with the same Key share
+//     one execution while it is in flight;
+//   - a workerpool.Pool runs that execution, so however many different
+//     Tasks are asked for at once, no more than the pool's size run.
+//
+// Errors are handed to every caller waiting on the execution but are not
+// remembered, so the next caller tries again. The shared execution does
+// not belong to any one caller: it runs under the first caller's context
+// without its cancellation, and a caller that goes away stops waiting
+// without failing the others.
+package taskcache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/concurrency/singleflight"
+	"github.com/crazybber/go-patterns/patterns/cache"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// Task is work whose result can be shared. Tasks with the same Key must
+// compute the same result.
+type Task[V any] interface {
+	Key() string
+	Run(ctx context.Context) (V, error)
+}
+
+// Options configures a Cache.
+type Options struct {
+	// TTL is how long a result is remembered. Zero only shares the
+	// executions in flight.
+	TTL time.Duration
+	// Clock ages the remembered results. It defaults to clock.Real.
+	Clock clock.Clock
+}
+
+// Result is the outcome of Go.
+type Result[V any] struct {
+	Val V
+	Err error
+}
+
+// Stats counts how the Tasks asked for were answered.
+type Stats struct {
+	// Hits were answered from remembered results.
+	Hits int64
+	// Shared waited for an execution another caller had started.
+	Shared int64
+	// Runs are the executions on the pool.
+	Runs int64
+}
+
+// Cache runs Tasks on a pool, sharing and remembering their results. It
+// is safe for concurrent use.
+type Cache[V any] struct {
+	pool    *workerpool.Pool
+	ttl     time.Duration
+	group   singleflight.Group
+	results *cache.TTL[string, V]
+
+	hits, shared, runs atomic.Int64
+}
+
+// New returns a Cache that runs Tasks on p.
+func New[V any](p *workerpool.Pool, opts Options) *Cache[V] {
+	c := &Cache[V]{pool: p, ttl: opts.TTL, results: cache.NewTTL[string, V](opts.TTL)}
+	if opts.Clock != nil {
+		c.results.SetClock(opts.Clock)
+	}
+	return c
+}
+
+// Do returns the result of t: a remembered one, the one of an execution
+// of a Task with the same Key in flight, or the one of a new execution on
+// the pool. It returns ctx.Err() if ctx is done first.
+func (c *Cache[V]) Do(ctx context.Context, t Task[V]) (V, error) {
+	r := <-c.Go(ctx, t)
+	return r.Val, r.Err
+}
+
+// Go is Do without waiting: the channel receives the result.
+func (c *Cache[V]) Go(ctx context.Context, t Task[V]) <-chan Result[V] {
+	out := make(chan Result[V], 1)
+	key := t.Key()
+	if v, ok := c.results.Get(key); ok {
+		c.hits.Add(1)
+		out <- Result[V]{Val: v}
+		return out
+	}
+	detached := context.WithoutCancel(ctx)
+	// led is set when the group runs this caller's function rather than
+	// sharing another caller's.
+	var led atomic.Bool
+	shared := c.group.DoChan(key, func() (interface{}, error) {
+		led.Store(true)
+		// The execution before this one may have finished between the
+		// miss above and joining the group.
+		if v, ok := c.results.Get(key); ok {
+			c.hits.Add(1)
+			return v, nil
+		}
+		c.runs.Add(1)
+		var v V
+		err := c.pool.Run(detached, workerpool.WorkerFunc(func(ctx context.Context) (err error) {
+			v, err = t.Run(ctx)
+			return err
+		}))
+		if err == nil && c.ttl > 0 {
+			c.results.Put(key, v)
+		}
+		return v, err
+	})
+	go func() {
+		select {
+		case r := <-shared:
+			if !led.Load() {
+				c.shared.Add(1)
+			}
+			v, _ := r.Val.(V)
+			out <- Result[V]{Val: v, Err: r.Err}
+		case <-ctx.Done():
+			out <- Result[V]{Err: ctx.Err()}
+		}
+	}()
+	return out
+}
+
+// Forget drops the remembered result of key, so that the next Task with
+// that Key runs again.
+func (c *Cache[V]) Forget(key string) {
+	c.results.Remove(key)
+}
+
+// Expire frees the results that have expired and returns how many there
+// were. Expired results are never returned, but they take memory until
+// then, so a long-lived Cache runs Expire now and again, from a
+// scheduler job for instance.
+func (c *Cache[V]) Expire() int {
+	return c.results.Expire()
+}
+
+// Stats returns the counters.
+func (c *Cache[V]) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Shared: c.shared.Load(), Runs: c.runs.Load()}
+}
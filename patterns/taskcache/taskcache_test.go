@@ -0,0 +1,176 @@
+package taskcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// lookup is a Task that counts its runs and, when gate is set, waits for
+// it to close before answering.
+type lookup struct {
+	key  string
+	runs *atomic.Int32
+	gate chan struct{}
+	err  error
+}
+
+func (l lookup) Key() string { return l.key }
+
+func (l lookup) Run(ctx context.Context) (string, error) {
+	n := l.runs.Add(1)
+	if l.gate != nil {
+		<-l.gate
+	}
+	if l.err != nil {
+		return "", l.err
+	}
+	return l.key + " v" + string(rune('0'+n)), nil
+}
+
+func newCache(t *testing.T, ttl time.Duration) (*Cache[string], *clock.Fake) {
+	p := workerpool.New(2)
+	t.Cleanup(p.Shutdown)
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	return New[string](p, Options{TTL: ttl, Clock: clk}), clk
+}
+
+func TestConcurrentTasksShareOneRun(t *testing.T) {
+	c, _ := newCache(t, time.Minute)
+	var runs atomic.Int32
+	gate := make(chan struct{})
+	results := make([]<-chan Result[string], 10)
+	for i := range results {
+		results[i] = c.Go(context.Background(), lookup{key: "user:1", runs: &runs, gate: gate})
+	}
+	close(gate)
+	for i, ch := range results {
+		if r := <-ch; r.Err != nil || r.Val != "user:1 v1" {
+			t.Errorf("caller %d got %+v", i, r)
+		}
+	}
+	if s := c.Stats(); runs.Load() != 1 || s.Runs != 1 || s.Shared != 9 || s.Hits != 0 {
+		t.Errorf("%d runs, stats %+v", runs.Load(), s)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c, clk := newCache(t, time.Minute)
+	var runs atomic.Int32
+	task := lookup{key: "user:1", runs: &runs}
+	ctx := context.Background()
+	for _, step := range []struct {
+		advance time.Duration
+		want    string
+	}{
+		{0, "user:1 v1"},
+		{30 * time.Second, "user:1 v1"},
+		{30*time.Second - time.Nanosecond, "user:1 v1"},
+		{time.Nanosecond, "user:1 v2"},
+		{59 * time.Second, "user:1 v2"},
+	} {
+		clk.Advance(step.advance)
+		if v, err := c.Do(ctx, task); err != nil || v != step.want {
+			t.Errorf("after %v: %q, %v; want %q", step.advance, v, err, step.want)
+		}
+	}
+	if s := c.Stats(); s.Runs != 2 || s.Hits != 3 {
+		t.Errorf("stats %+v", s)
+	}
+	c.Forget("user:1")
+	if v, _ := c.Do(ctx, task); v != "user:1 v3" {
+		t.Errorf("after Forget: %q", v)
+	}
+	clk.Advance(time.Hour)
+	if n := c.Expire(); n != 1 {
+		t.Errorf("Expire = %d", n)
+	}
+}
+
+func TestZeroTTLOnlyShares(t *testing.T) {
+	c, _ := newCache(t, 0)
+	var runs atomic.Int32
+	task := lookup{key: "k", runs: &runs}
+	c.Do(context.Background(), task)
+	c.Do(context.Background(), task)
+	if runs.Load() != 2 {
+		t.Errorf("%d runs", runs.Load())
+	}
+}
+
+func TestErrorsAreNotRemembered(t *testing.T) {
+	c, _ := newCache(t, time.Minute)
+	var runs atomic.Int32
+	down := errors.New("backend down")
+	if _, err := c.Do(context.Background(), lookup{key: "k", runs: &runs, err: down}); err != down {
+		t.Fatalf("Do = %v", err)
+	}
+	if v, err := c.Do(context.Background(), lookup{key: "k", runs: &runs}); err != nil || v != "k v2" {
+		t.Errorf("retry got %q, %v", v, err)
+	}
+}
+
+// A caller that gives up does not cancel the run the others wait for.
+func TestCallerGivesUp(t *testing.T) {
+	c, _ := newCache(t, time.Minute)
+	var runs atomic.Int32
+	gate := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	first := c.Go(ctx, lookup{key: "k", runs: &runs, gate: gate})
+	second := c.Go(context.Background(), lookup{key: "k", runs: &runs, gate: gate})
+	cancel()
+	if r := <-first; r.Err != context.Canceled {
+		t.Errorf("cancelled caller got %+v", r)
+	}
+	close(gate)
+	if r := <-second; r.Err != nil || r.Val != "k v1" {
+		t.Errorf("other caller got %+v", r)
+	}
+}
+
+// Different keys run on the pool, no more of them at once than its size.
+func TestPoolBoundsDistinctTasks(t *testing.T) {
+	c, _ := newCache(t, time.Minute)
+	var running, most atomic.Int32
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Do(context.Background(), slowTask{key, &running, &most})
+		}()
+	}
+	wg.Wait()
+	if most.Load() > 2 {
+		t.Errorf("%d tasks ran at once on a pool of 2", most.Load())
+	}
+	if s := c.Stats(); s.Runs != 6 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+type slowTask struct {
+	key           string
+	running, most *atomic.Int32
+}
+
+func (s slowTask) Key() string { return s.key }
+
+func (s slowTask) Run(context.Context) (string, error) {
+	n := s.running.Add(1)
+	defer s.running.Add(-1)
+	for {
+		m := s.most.Load()
+		if n <= m || s.most.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return s.key, nil
+}
@@ -0,0 +1,210 @@
+// Package tuidash renders a small terminal dashboard of live gauges: worker
+// pool utilisation, pipeline stage depths, rate limiter tokens, or anything
+// else that can report a current and a maximum value.
+//
+// It uses plain ANSI escapes and no terminal library. Each redraw moves the
+// cursor home and overwrites the previous frame line by line, clearing only
+// what is left over at the end of each line and below the last one, so the
+// screen is never blanked and does not flicker. A headless Dashboard writes
+// nothing and keeps the plain text frames instead, which tests compare
+// against golden files.
+package tuidash
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+	"github.com/crazybber/go-patterns/resiliency/ratelimit"
+)
+
+// ANSI control sequences used for redraws.
+const (
+	home       = "\x1b[H"
+	clearLine  = "\x1b[K"
+	clearBelow = "\x1b[J"
+	hideCursor = "\x1b[?25l"
+	showCursor = "\x1b[?25h"
+)
+
+// Gauge is one line of the dashboard.
+type Gauge struct {
+	Label string
+	// Value reports the current and the maximum value. A max of 0 renders
+	// the current value without a bar.
+	Value func() (cur, max float64)
+}
+
+// Section is a titled group of gauges.
+type Section struct {
+	Title  string
+	Gauges []Gauge
+}
+
+// Dashboard draws its sections to a terminal, or records them headless.
+type Dashboard struct {
+	// Title is printed on the first line of every frame.
+	Title string
+	// Width is the width of the bars in cells. It defaults to 30.
+	Width int
+
+	mu       sync.Mutex
+	out      io.Writer
+	sections []Section
+	frames   []string
+	started  bool
+}
+
+// New returns a Dashboard drawing to out with ANSI redraws.
+func New(out io.Writer, title string) *Dashboard {
+	return &Dashboard{Title: title, Width: 30, out: out}
+}
+
+// NewHeadless returns a Dashboard that draws nowhere and records every
+// frame, see Frames.
+func NewHeadless(title string) *Dashboard {
+	return &Dashboard{Title: title, Width: 30}
+}
+
+// Add appends a section.
+func (d *Dashboard) Add(s Section) {
+	d.mu.Lock()
+	d.sections = append(d.sections, s)
+	d.mu.Unlock()
+}
+
+// Frame renders the current values as plain text, one line per gauge.
+func (d *Dashboard) Frame() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.frame()
+}
+
+func (d *Dashboard) frame() string {
+	width := d.Width
+	if width <= 0 {
+		width = 30
+	}
+	label := 0
+	for _, s := range d.sections {
+		for _, g := range s.Gauges {
+			label = max(label, len(g.Label))
+		}
+	}
+
+	var b strings.Builder
+	if d.Title != "" {
+		b.WriteString(d.Title + "\n")
+	}
+	for _, s := range d.sections {
+		b.WriteString("\n" + s.Title + "\n")
+		for _, g := range s.Gauges {
+			cur, hi := g.Value()
+			if hi <= 0 {
+				fmt.Fprintf(&b, "  %-*s %g\n", label, g.Label, cur)
+				continue
+			}
+			fmt.Fprintf(&b, "  %-*s %s %g/%g\n", label, g.Label, bar(cur, hi, width), cur, hi)
+		}
+	}
+	return b.String()
+}
+
+// bar renders cur out of hi as a bar of width cells.
+func bar(cur, hi float64, width int) string {
+	n := min(max(int(cur/hi*float64(width)), 0), width)
+	return "[" + strings.Repeat("#", n) + strings.Repeat(".", width-n) + "]"
+}
+
+// Draw renders a frame. A headless Dashboard records it; otherwise it
+// overwrites the previous frame on the terminal.
+func (d *Dashboard) Draw() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f := d.frame()
+	if d.out == nil {
+		d.frames = append(d.frames, f)
+		return nil
+	}
+	var b strings.Builder
+	if !d.started {
+		b.WriteString(hideCursor)
+		d.started = true
+	}
+	b.WriteString(home)
+	for _, line := range strings.SplitAfter(f, "\n") {
+		if line == "" {
+			continue
+		}
+		b.WriteString(strings.TrimSuffix(line, "\n") + clearLine + "\n")
+	}
+	b.WriteString(clearBelow)
+	_, err := io.WriteString(d.out, b.String())
+	return err
+}
+
+// Frames returns the frames recorded by a headless Dashboard.
+func (d *Dashboard) Frames() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.frames...)
+}
+
+// Run draws a frame immediately and then every interval until ctx is done.
+// It draws a final frame and restores the cursor before returning.
+func (d *Dashboard) Run(ctx context.Context, every time.Duration) error {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	defer d.restore()
+
+	for {
+		if err := d.Draw(); err != nil {
+			return err
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			if err := d.Draw(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+func (d *Dashboard) restore() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.started {
+		io.WriteString(d.out, showCursor)
+		d.started = false
+	}
+}
+
+// PoolGauge reports the busy workers of a pool out of its size.
+func PoolGauge(label string, p *workerpool.Pool) Gauge {
+	return Gauge{Label: label, Value: func() (float64, float64) {
+		return float64(p.Active()), float64(p.Size())
+	}}
+}
+
+// ChannelGauge reports the items buffered in a pipeline stage channel out of
+// its capacity.
+func ChannelGauge[T any](label string, ch chan T) Gauge {
+	return Gauge{Label: label, Value: func() (float64, float64) {
+		return float64(len(ch)), float64(cap(ch))
+	}}
+}
+
+// TokenGauge reports the tokens left in a bucket out of its burst, rounded
+// down to whole tokens.
+func TokenGauge(label string, b *ratelimit.TokenBucket) Gauge {
+	return Gauge{Label: label, Value: func() (float64, float64) {
+		return float64(int(b.Tokens())), float64(b.Burst())
+	}}
+}
@@ -0,0 +1,122 @@
+package tuidash
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+	"github.com/crazybber/go-patterns/resiliency/ratelimit"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from %s:\n%s", name, path, got)
+	}
+}
+
+func TestHeadlessFrames(t *testing.T) {
+	bucket := ratelimit.NewTokenBucket(10, 20)
+	bucket.SetClock(clock.NewFake(time.Unix(0, 0)))
+
+	pool := workerpool.New(4)
+	defer pool.Shutdown()
+	parse := make(chan int, 8)
+	store := make(chan int, 4)
+
+	d := NewHeadless("pipeline")
+	d.Width = 10
+	d.Add(Section{Title: "pools", Gauges: []Gauge{PoolGauge("workers", pool)}})
+	d.Add(Section{Title: "stages", Gauges: []Gauge{
+		ChannelGauge("parse", parse),
+		ChannelGauge("store", store),
+	}})
+	d.Add(Section{Title: "limits", Gauges: []Gauge{
+		TokenGauge("api", bucket),
+		{Label: "dropped", Value: func() (float64, float64) { return 3, 0 }},
+	}})
+
+	d.Draw()
+	for i := 0; i < 6; i++ {
+		parse <- i
+		bucket.Allow()
+	}
+	store <- 1
+	d.Draw()
+	for i := 0; i < 14; i++ {
+		bucket.Allow()
+	}
+	parse <- 6
+	parse <- 7
+	store <- 2
+	store <- 3
+	store <- 4
+	d.Draw()
+
+	frames := d.Frames()
+	if len(frames) != 3 {
+		t.Fatalf("recorded %d frames, want 3", len(frames))
+	}
+	for i, f := range frames {
+		golden(t, "frame"+string(rune('0'+i)), f)
+	}
+}
+
+func TestDrawANSI(t *testing.T) {
+	var buf bytes.Buffer
+	d := New(&buf, "ansi")
+	d.Add(Section{Title: "s", Gauges: []Gauge{{Label: "g", Value: func() (float64, float64) { return 1, 2 }}}})
+
+	d.Draw()
+	d.Draw()
+	out := buf.String()
+	if strings.Count(out, hideCursor) != 1 {
+		t.Errorf("the cursor should be hidden once: %q", out)
+	}
+	if strings.Count(out, home) != 2 || strings.Contains(out, "\x1b[2J") {
+		t.Errorf("redraws must overwrite from home without clearing the screen: %q", out)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(out, clearBelow), "\n") {
+		if line != "" && !strings.HasSuffix(line, clearLine) && !strings.HasSuffix(line, clearBelow) {
+			t.Errorf("line %q does not clear its tail", line)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	var buf bytes.Buffer
+	d := New(&buf, "run")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	if err := d.Run(ctx, 5*time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatalf("Run = %v", err)
+	}
+	out := buf.String()
+	if strings.Count(out, home) < 3 {
+		t.Errorf("expected several redraws, got %d", strings.Count(out, home))
+	}
+	if !strings.HasSuffix(out, showCursor) {
+		t.Errorf("cursor not restored: %q", out[max(0, len(out)-20):])
+	}
+}
@@ -0,0 +1,228 @@
+// Package watcher is a portable, polling file watcher.
+//
+// Every poll walks a directory tree of an fs.FS and hashes each file's
+// modification time, size and mode. Comparing the hashes with the previous
+// poll yields create, write and remove events. There are no inotify or
+// kqueue dependencies, so it works the same everywhere, including on
+// network and in-memory file systems, at the cost of a walk per interval.
+//
+// Editors and build tools tend to touch a file several times, or many files
+// at once, in quick succession. Events are therefore collected into a batch
+// that is handed over only once the polls have found nothing new for the
+// debounce wait, with each path appearing once in it.
+package watcher
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/concurrency/debounce"
+)
+
+// Op is the kind of change to a file.
+type Op int
+
+// Changes reported in events.
+const (
+	Create Op = iota + 1
+	Write
+	Remove
+)
+
+func (o Op) String() string {
+	switch o {
+	case Create:
+		return "create"
+	case Write:
+		return "write"
+	case Remove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// Event is a change to one file.
+type Event struct {
+	Path string
+	Op   Op
+}
+
+func (e Event) String() string { return e.Op.String() + " " + e.Path }
+
+// Options configures a Watcher.
+type Options struct {
+	// Interval between polls in Run. It defaults to 500ms.
+	Interval time.Duration
+	// Debounce is how long the polls must stay quiet before a batch is
+	// delivered. It defaults to 100ms.
+	Debounce time.Duration
+	// Clock schedules the debounced delivery, for tests.
+	Clock debounce.Clock
+}
+
+// Watcher polls a tree for changes.
+type Watcher struct {
+	fsys   fs.FS
+	root   string
+	opts   Options
+	handle func([]Event)
+
+	mu      sync.Mutex
+	files   map[string]uint64
+	pending map[string]Op
+	flush   func()
+}
+
+// New watches the tree below root in fsys and calls handle with every
+// batch of changes. The current content of the tree is the baseline and
+// produces no events.
+func New(fsys fs.FS, root string, handle func([]Event), opts Options) (*Watcher, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = 500 * time.Millisecond
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = 100 * time.Millisecond
+	}
+	w := &Watcher{
+		fsys:    fsys,
+		root:    root,
+		opts:    opts,
+		handle:  handle,
+		pending: make(map[string]Op),
+	}
+	var setters []debounce.Option
+	if opts.Clock != nil {
+		setters = append(setters, debounce.WithClock(opts.Clock))
+	}
+	w.flush = debounce.Debounce(w.deliver, opts.Debounce, setters...)
+
+	files, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	w.files = files
+	return w, nil
+}
+
+// snapshot hashes every regular file below the root.
+func (w *Watcher) snapshot() (map[string]uint64, error) {
+	files := make(map[string]uint64)
+	err := fs.WalkDir(w.fsys, w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			// Removed between the listing and the stat.
+			return nil
+		}
+		files[path] = sum(info)
+		return nil
+	})
+	return files, err
+}
+
+func sum(info fs.FileInfo) uint64 {
+	var buf [20]byte
+	binary.LittleEndian.PutUint64(buf[0:], uint64(info.ModTime().UnixNano()))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(info.Size()))
+	binary.LittleEndian.PutUint32(buf[16:], uint32(info.Mode()))
+	h := fnv.New64a()
+	h.Write(buf[:])
+	return h.Sum64()
+}
+
+// Poll walks the tree once and queues what changed since the last poll. It
+// reports the number of changes found. Run calls it on every tick.
+func (w *Watcher) Poll() (int, error) {
+	files, err := w.snapshot()
+	if err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	n := 0
+	for path, h := range files {
+		old, ok := w.files[path]
+		switch {
+		case !ok:
+			w.queue(path, Create)
+		case old != h:
+			w.queue(path, Write)
+		default:
+			continue
+		}
+		n++
+	}
+	for path := range w.files {
+		if _, ok := files[path]; !ok {
+			w.queue(path, Remove)
+			n++
+		}
+	}
+	w.files = files
+	w.mu.Unlock()
+
+	if n > 0 {
+		w.flush()
+	}
+	return n, nil
+}
+
+// queue merges op into the pending change of path. w.mu must be held.
+func (w *Watcher) queue(path string, op Op) {
+	prev, ok := w.pending[path]
+	switch {
+	case !ok:
+		w.pending[path] = op
+	case prev == Create && op == Remove:
+		// Came and went within one batch.
+		delete(w.pending, path)
+	case prev == Create:
+		// Still new to the receiver, however often it was written.
+	case prev == Remove && op == Create:
+		w.pending[path] = Write
+	default:
+		w.pending[path] = op
+	}
+}
+
+func (w *Watcher) deliver() {
+	w.mu.Lock()
+	batch := make([]Event, 0, len(w.pending))
+	for path, op := range w.pending {
+		batch = append(batch, Event{Path: path, Op: op})
+	}
+	w.pending = make(map[string]Op)
+	w.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].Path < batch[j].Path })
+	w.handle(batch)
+}
+
+// Run polls every Interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	t := time.NewTicker(w.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if _, err := w.Poll(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func file(data string, mod time.Duration) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(data), ModTime: epoch.Add(mod)}
+}
+
+type recorder struct {
+	batches [][]Event
+}
+
+func (r *recorder) handle(b []Event) { r.batches = append(r.batches, b) }
+
+func setup(t *testing.T, fsys fstest.MapFS) (*Watcher, *clock.Fake, *recorder) {
+	t.Helper()
+	c := clock.NewFake(epoch)
+	r := &recorder{}
+	w, err := New(fsys, ".", r.handle, Options{Debounce: 100 * time.Millisecond, Clock: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, c, r
+}
+
+func poll(t *testing.T, w *Watcher) int {
+	t.Helper()
+	n, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestEvents(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.go":       file("package main", 0),
+		"tmpl/a.html":   file("<a>", 0),
+		"static/ok.css": file("a{}", 0),
+	}
+	w, c, r := setup(t, fsys)
+
+	if n := poll(t, w); n != 0 {
+		t.Fatalf("baseline poll found %d changes", n)
+	}
+
+	fsys["main.go"] = file("package main // edited", time.Second)
+	fsys["tmpl/b.html"] = file("<b>", time.Second)
+	delete(fsys, "static/ok.css")
+	if n := poll(t, w); n != 3 {
+		t.Fatalf("found %d changes, want 3", n)
+	}
+	c.Advance(100 * time.Millisecond)
+
+	want := [][]Event{{
+		{"main.go", Write},
+		{"static/ok.css", Remove},
+		{"tmpl/b.html", Create},
+	}}
+	if !reflect.DeepEqual(r.batches, want) {
+		t.Errorf("batches = %v, want %v", r.batches, want)
+	}
+}
+
+func TestSameSizeTouch(t *testing.T) {
+	fsys := fstest.MapFS{"a": file("x", 0)}
+	w, _, _ := setup(t, fsys)
+
+	fsys["a"] = file("y", time.Millisecond)
+	if n := poll(t, w); n != 1 {
+		t.Errorf("a new mod time with the same size must count as a write, found %d", n)
+	}
+}
+
+func TestDebounceBatches(t *testing.T) {
+	fsys := fstest.MapFS{"a": file("1", 0), "gone": file("", 0)}
+	w, c, r := setup(t, fsys)
+
+	// A save storm: every poll finds something within the debounce wait.
+	for i := 1; i <= 5; i++ {
+		fsys["a"] = file(fmt.Sprint(i), time.Duration(i)*time.Second)
+		poll(t, w)
+		c.Advance(50 * time.Millisecond)
+	}
+	fsys["tmp~"] = file("", 0)
+	poll(t, w)
+	delete(fsys, "tmp~")
+	delete(fsys, "gone")
+	poll(t, w)
+	fsys["gone"] = file("back", 0)
+	poll(t, w)
+
+	if len(r.batches) != 0 {
+		t.Fatalf("delivered before the polls went quiet: %v", r.batches)
+	}
+	c.Advance(100 * time.Millisecond)
+
+	want := [][]Event{{{"a", Write}, {"gone", Write}}}
+	if !reflect.DeepEqual(r.batches, want) {
+		t.Errorf("batches = %v, want %v", r.batches, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("a: 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan []Event, 1)
+	w, err := New(os.DirFS(dir), ".", func(b []Event) { got <- b },
+		Options{Interval: 5 * time.Millisecond, Debounce: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	if err := os.WriteFile(path, []byte("a: 22"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-got:
+		if len(b) != 1 || b[0] != (Event{"config.yaml", Write}) {
+			t.Errorf("batch = %v", b)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+}
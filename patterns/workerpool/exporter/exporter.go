@@ -0,0 +1,183 @@
+// Package exporter publishes the Stats of worker pools for monitoring:
+// as an expvar variable, for /debug/vars, and as a /metrics page in the
+// Prometheus text exposition format, for anything that scrapes one.
+//
+// Both read Pool.Stats when they are asked, so an idle exporter costs
+// nothing, and the pools need no Metrics configured for it. Every pool is
+// a label value of the same metrics:
+//
+//	# HELP workerpool_active Goroutines running a task.
+//	# TYPE workerpool_active gauge
+//	workerpool_active{pool="thumbnails"} 3
+//	workerpool_active{pool="uploads"} 0
+//
+// The text format is written out by hand; it is simple enough that a
+// client library is not needed to serve it.
+package exporter
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metric is one of the numbers of workerpool.Stats.
+type metric struct {
+	name, kind, help string
+	value            func(workerpool.Stats) float64
+}
+
+var metrics = []metric{
+	{"size", "gauge", "Goroutines in the pool.",
+		func(s workerpool.Stats) float64 { return float64(s.Size) }},
+	{"active", "gauge", "Goroutines running a task.",
+		func(s workerpool.Stats) float64 { return float64(s.Active) }},
+	{"waiting", "gauge", "Callers waiting for a goroutine.",
+		func(s workerpool.Stats) float64 { return float64(s.Waiting) }},
+	{"tasks_started_total", "counter", "Tasks started.",
+		func(s workerpool.Stats) float64 { return float64(s.Started) }},
+	{"tasks_failed_total", "counter", "Tasks that returned an error or panicked.",
+		func(s workerpool.Stats) float64 { return float64(s.Failed) }},
+	{"tasks_panicked_total", "counter", "Tasks that panicked.",
+		func(s workerpool.Stats) float64 { return float64(s.Panicked) }},
+	{"tasks_rejected_total", "counter", "Tasks rejected because every goroutine was busy.",
+		func(s workerpool.Stats) float64 { return float64(s.Rejected) }},
+	{"tasks_shed_total", "counter", "Tasks skipped because the pool was overloaded.",
+		func(s workerpool.Stats) float64 { return float64(s.Shed) }},
+	{"busy_seconds_total", "counter", "Time spent running tasks.",
+		func(s workerpool.Stats) float64 { return s.Busy.Seconds() }},
+}
+
+type pool struct {
+	name string
+	p    *workerpool.Pool
+}
+
+// Exporter publishes the Stats of the pools added to it. The zero value
+// has no pools and is ready to use. It is safe for concurrent use.
+type Exporter struct {
+	// Namespace prefixes the metric names. It defaults to "workerpool".
+	Namespace string
+
+	mu    sync.Mutex
+	pools []pool
+}
+
+// Add exports the Stats of p under name, in place of any pool added with
+// the same name before.
+func (e *Exporter) Add(name string, p *workerpool.Pool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.pools {
+		if e.pools[i].name == name {
+			e.pools[i].p = p
+			return
+		}
+	}
+	e.pools = append(e.pools, pool{name, p})
+}
+
+// Remove stops exporting the pool added under name.
+func (e *Exporter) Remove(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.pools {
+		if e.pools[i].name == name {
+			e.pools = append(e.pools[:i], e.pools[i+1:]...)
+			return
+		}
+	}
+}
+
+// snapshot returns the Stats of every pool, in the order they were added.
+func (e *Exporter) snapshot() ([]string, []workerpool.Stats) {
+	e.mu.Lock()
+	pools := append([]pool(nil), e.pools...)
+	e.mu.Unlock()
+	names := make([]string, len(pools))
+	stats := make([]workerpool.Stats, len(pools))
+	for i, p := range pools {
+		names[i], stats[i] = p.name, p.p.Stats()
+	}
+	return names, stats
+}
+
+func (e *Exporter) namespace() string {
+	if e.Namespace == "" {
+		return "workerpool"
+	}
+	return e.Namespace
+}
+
+// WriteText writes the metrics of every pool to w in the text exposition
+// format.
+func (e *Exporter) WriteText(w io.Writer) error {
+	names, stats := e.snapshot()
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		name := e.namespace() + "_" + m.name
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, m.help, name, m.kind)
+		for i, s := range stats {
+			fmt.Fprintf(bw, "%s{pool=%s} %s\n", name, quote(names[i]), strconv.FormatFloat(m.value(s), 'g', -1, 64))
+		}
+	}
+	return bw.Flush()
+}
+
+// quote quotes a label value: backslashes, double quotes and newlines are
+// escaped, and nothing else.
+func quote(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ServeHTTP serves the metrics in the text exposition format, for
+// mounting on /metrics.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	if r.Method == http.MethodHead {
+		return
+	}
+	e.WriteText(w)
+}
+
+// Var returns the metrics as an expvar variable: a JSON object with a
+// member for every pool, mapping the metric names, without the namespace,
+// to their values.
+func (e *Exporter) Var() expvar.Var {
+	return expvar.Func(func() any {
+		names, stats := e.snapshot()
+		out := make(map[string]map[string]float64, len(names))
+		for i, s := range stats {
+			values := make(map[string]float64, len(metrics))
+			for _, m := range metrics {
+				values[m.name] = m.value(s)
+			}
+			out[names[i]] = values
+		}
+		return out
+	})
+}
+
+// Publish publishes Var under the Namespace in expvar, so it shows in
+// /debug/vars. Like expvar.Publish, it panics if the name is taken, so an
+// Exporter is published once.
+func (e *Exporter) Publish() {
+	expvar.Publish(e.namespace(), e.Var())
+}
@@ -0,0 +1,214 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/workerpool"
+)
+
+// busyPool returns a pool of size goroutines that has run ok tasks that
+// succeeded and failed tasks that did not, and has one task still running
+// until the returned function is called.
+func busyPool(t *testing.T, size, ok, failed int) (*workerpool.Pool, func()) {
+	t.Helper()
+	p := workerpool.New(size)
+	ctx := context.Background()
+	for range ok {
+		p.Run(ctx, workerpool.WorkerFunc(func(context.Context) error { return nil }))
+	}
+	for range failed {
+		p.Run(ctx, workerpool.WorkerFunc(func(context.Context) error { return errors.New("failed") }))
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	go p.Run(ctx, workerpool.WorkerFunc(func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	<-started
+	return p, func() {
+		close(release)
+		p.Shutdown()
+	}
+}
+
+func scrape(t *testing.T, url string) (string, http.Header) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %v %s", url, err, resp.Status)
+	}
+	return string(body), resp.Header
+}
+
+func TestScrapeMetrics(t *testing.T) {
+	thumbs, stopThumbs := busyPool(t, 4, 3, 1)
+	defer stopThumbs()
+	uploads, stopUploads := busyPool(t, 2, 0, 0)
+	defer stopUploads()
+
+	var e Exporter
+	e.Add("thumbnails", thumbs)
+	e.Add("uploads", uploads)
+	srv := httptest.NewServer(&e)
+	defer srv.Close()
+
+	body, header := scrape(t, srv.URL)
+	if ct := header.Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type %q", ct)
+	}
+	for _, want := range []string{
+		"# HELP workerpool_size Goroutines in the pool.\n# TYPE workerpool_size gauge\n" +
+			"workerpool_size{pool=\"thumbnails\"} 4\nworkerpool_size{pool=\"uploads\"} 2\n",
+		"# TYPE workerpool_tasks_started_total counter\n",
+		`workerpool_active{pool="thumbnails"} 1` + "\n",
+		`workerpool_tasks_started_total{pool="thumbnails"} 5` + "\n",
+		`workerpool_tasks_failed_total{pool="thumbnails"} 1` + "\n",
+		`workerpool_tasks_started_total{pool="uploads"} 1` + "\n",
+		`workerpool_busy_seconds_total{pool="uploads"} `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q:\n%s", want, body)
+		}
+	}
+	// Every line is a comment or a sample with a value.
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if !strings.HasPrefix(line, "# ") && len(strings.Fields(line)) != 2 {
+			t.Errorf("bad line %q", line)
+		}
+	}
+
+	// A replaced pool is scraped in its old place, a removed one not at all.
+	e.Remove("uploads")
+	e.Add("thumbnails", uploads)
+	body, _ = scrape(t, srv.URL)
+	if strings.Contains(body, `pool="uploads"`) || !strings.Contains(body, `workerpool_size{pool="thumbnails"} 2`) {
+		t.Errorf("after Remove and Add:\n%s", body)
+	}
+}
+
+func TestNamespaceAndEscaping(t *testing.T) {
+	p, stop := busyPool(t, 1, 0, 0)
+	defer stop()
+	e := Exporter{Namespace: "app_pool"}
+	e.Add("a \"quoted\"\\name\n", p)
+	var b strings.Builder
+	if err := e.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	if want := `app_pool_size{pool="a \"quoted\"\\name\n"} 1`; !strings.Contains(b.String(), want) {
+		t.Errorf("metrics lack %s:\n%s", want, b.String())
+	}
+}
+
+// A shed task is counted as shed only, not as started and failed.
+func TestShedCounted(t *testing.T) {
+	p := workerpool.New(1)
+	defer p.Shutdown()
+	p.Use(workerpool.Shedding(p, workerpool.ShedOptions{MaxQueueWait: time.Nanosecond}))
+	err := p.Run(context.Background(), workerpool.WorkerFunc(func(context.Context) error { return nil }))
+	if err != workerpool.ErrShed {
+		t.Fatalf("Run = %v", err)
+	}
+	var e Exporter
+	e.Add("jobs", p)
+	var b strings.Builder
+	if err := e.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`workerpool_tasks_shed_total{pool="jobs"} 1`,
+		`workerpool_tasks_started_total{pool="jobs"} 0`,
+		`workerpool_tasks_failed_total{pool="jobs"} 0`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("metrics lack %s:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	var e Exporter
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+// published is published once, as expvar names cannot be reused, however
+// often the tests run.
+var (
+	published     = Exporter{Namespace: "exporter_test_pools"}
+	publishedOnce sync.Once
+)
+
+func TestExpvar(t *testing.T) {
+	p, stop := busyPool(t, 3, 2, 0)
+	defer stop()
+	publishedOnce.Do(published.Publish)
+	published.Add("jobs", p)
+	if expvar.Get("exporter_test_pools") == nil {
+		t.Fatal("not published")
+	}
+
+	// Read back through /debug/vars, the way it is monitored.
+	srv := httptest.NewServer(expvar.Handler())
+	defer srv.Close()
+	body, _ := scrape(t, srv.URL)
+	var vars struct {
+		Pools map[string]map[string]float64 `json:"exporter_test_pools"`
+	}
+	if err := json.Unmarshal([]byte(body), &vars); err != nil {
+		t.Fatal(err)
+	}
+	jobs := vars.Pools["jobs"]
+	if jobs["size"] != 3 || jobs["active"] != 1 || jobs["tasks_started_total"] != 3 || len(jobs) != 9 {
+		t.Errorf("jobs = %v", jobs)
+	}
+}
+
+func ExampleExporter() {
+	pool := workerpool.New(2)
+	defer pool.Shutdown()
+	pool.Run(context.Background(), workerpool.WorkerFunc(func(context.Context) error { return nil }))
+
+	var e Exporter
+	e.Add("thumbnails", pool)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", &e)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "workerpool_size") || strings.HasPrefix(line, "workerpool_tasks_started") {
+			fmt.Println(line)
+		}
+	}
+	// Output:
+	// workerpool_size{pool="thumbnails"} 2
+	// workerpool_tasks_started_total{pool="thumbnails"} 1
+}
@@ -0,0 +1,72 @@
+package workerpool
+
+import (
+	"context"
+	"time"
+)
+
+// TaskFunc is a Task as a middleware sees it.
+type TaskFunc func(ctx context.Context) error
+
+// TaskMiddleware wraps the execution of every Task a pool runs, for
+// concerns that cut across Workers, such as logging, timing and tracing.
+// It runs on the pool goroutine that runs the Task, inside the pool's
+// panic recovery.
+type TaskMiddleware func(next TaskFunc) TaskFunc
+
+// Use adds middleware to the Tasks submitted from now on. The first
+// middleware added is the outermost: it sees a Task before and after all
+// the others.
+func (p *Pool) Use(mw ...TaskMiddleware) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mw = append(p.mw, mw...)
+}
+
+// chain wraps w in the middleware. p.mu must be held. The Task is counted
+// as started when the middleware calls w, not before, so that the Tasks
+// the middleware skips are not.
+func (p *Pool) chain(w Worker) TaskFunc {
+	task := TaskFunc(func(ctx context.Context) error {
+		p.started.Add(1)
+		p.opts.Metrics.Add("tasks_started", 1)
+		return w.Task(ctx)
+	})
+	for i := len(p.mw) - 1; i >= 0; i-- {
+		task = p.mw[i](task)
+	}
+	return task
+}
+
+// Logging returns middleware that logs the duration and the error of
+// every failing Task to l.
+func Logging(l Logger) TaskMiddleware {
+	return func(next TaskFunc) TaskFunc {
+		return func(ctx context.Context) error {
+			start := time.Now()
+			err := next(ctx)
+			if err != nil {
+				l.Printf("workerpool: task failed after %v: %v", time.Since(start), err)
+			}
+			return err
+		}
+	}
+}
+
+// Timing returns middleware that reports to m the duration of every Task
+// as the distribution name_seconds, and counts those that fail as
+// name_failed, so that the Tasks of different pools, or of different
+// stages around one pool, can be told apart.
+func Timing(m Metrics, name string) TaskMiddleware {
+	return func(next TaskFunc) TaskFunc {
+		return func(ctx context.Context) error {
+			start := time.Now()
+			err := next(ctx)
+			m.Observe(name+"_seconds", time.Since(start).Seconds())
+			if err != nil {
+				m.Add(name+"_failed", 1)
+			}
+			return err
+		}
+	}
+}
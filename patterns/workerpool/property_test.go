@@ -0,0 +1,162 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// poolHistory is a random sequence of steps for testing/quick: "try"
+// submits with TryRun a task that holds its goroutine until released,
+// "release" lets the oldest held task finish.
+type poolHistory struct {
+	size  int
+	steps []string
+}
+
+func (poolHistory) Generate(r *rand.Rand, size int) reflect.Value {
+	h := poolHistory{size: 1 + r.Intn(4)}
+	for range r.Intn(size * 2) {
+		h.steps = append(h.steps, []string{"try", "release"}[r.Intn(2)])
+	}
+	return reflect.ValueOf(h)
+}
+
+// GoString keeps the input testing/quick reports on failure readable.
+func (h poolHistory) GoString() string { return fmt.Sprintf("size %d: %v", h.size, h.steps) }
+
+// held is a task running in the pool until release is closed; done
+// receives what its TryRun returned.
+type held struct {
+	release chan struct{}
+	done    chan error
+}
+
+// Every history is admitted as a counter of busy goroutines predicts:
+// TryRun succeeds exactly when fewer than Size tasks are held, Active
+// agrees, every admitted task runs once, and nothing runs after Shutdown.
+func TestPropertyTryRun(t *testing.T) {
+	check := func(h poolHistory) bool {
+		p := New(h.size)
+		var ran atomic.Int32
+		var queue []held
+		admitted := 0
+		fail := func(i int, format string, args ...any) bool {
+			t.Logf("size %d: %v: "+format, append([]any{h.size, h.steps[:i+1]}, args...)...)
+			for _, task := range queue {
+				close(task.release)
+			}
+			p.Shutdown()
+			return false
+		}
+		for i, step := range h.steps {
+			switch step {
+			case "try":
+				task := held{release: make(chan struct{}), done: make(chan error, 1)}
+				started := make(chan struct{})
+				go func() {
+					task.done <- p.TryRun(context.Background(), WorkerFunc(func(context.Context) error {
+						ran.Add(1)
+						close(started)
+						<-task.release
+						return nil
+					}))
+				}()
+				want := len(queue) < h.size
+				select {
+				case <-started:
+					if !want {
+						close(task.release)
+						return fail(i, "admitted with %d held", len(queue))
+					}
+					queue = append(queue, task)
+					admitted++
+				case err := <-task.done:
+					if want || err != ErrSaturated {
+						return fail(i, "TryRun = %v with %d held", err, len(queue))
+					}
+				case <-time.After(5 * time.Second):
+					return fail(i, "TryRun neither started nor returned")
+				}
+			case "release":
+				if len(queue) == 0 {
+					continue
+				}
+				task := queue[0]
+				queue = queue[1:]
+				close(task.release)
+				if err := <-task.done; err != nil {
+					return fail(i, "released task returned %v", err)
+				}
+			}
+			if p.Active() != len(queue) {
+				return fail(i, "Active %d with %d held", p.Active(), len(queue))
+			}
+		}
+		for _, task := range queue {
+			close(task.release)
+		}
+		p.Shutdown()
+		if err := p.TryRun(context.Background(), WorkerFunc(func(context.Context) error {
+			ran.Add(1)
+			return nil
+		})); err != ErrClosed || int(ran.Load()) != admitted {
+			t.Logf("%#v: after Shutdown TryRun = %v, %d ran of %d admitted", h, err, ran.Load(), admitted)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 50}); err != nil {
+		t.Error(err)
+	}
+}
+
+// Run under concurrent callers never lets more than Size tasks run at
+// once, and runs every task exactly once.
+func TestPropertyRunBound(t *testing.T) {
+	check := func(size, callers, tasks uint8) bool {
+		n, c, k := 1+int(size%4), 1+int(callers%8), int(tasks%32)
+		p := New(n)
+		defer p.Shutdown()
+		var running, peak atomic.Int32
+		counts := make([]atomic.Int32, c*k)
+		var wg sync.WaitGroup
+		for caller := range c {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range k {
+					p.Run(context.Background(), WorkerFunc(func(context.Context) error {
+						now := running.Add(1)
+						for old := peak.Load(); now > old && !peak.CompareAndSwap(old, now); old = peak.Load() {
+						}
+						counts[caller*k+i].Add(1)
+						running.Add(-1)
+						return nil
+					}))
+				}
+			}()
+		}
+		wg.Wait()
+		for i := range counts {
+			if counts[i].Load() != 1 {
+				t.Logf("size %d, %d callers of %d tasks: task %d ran %d times", n, c, k, i, counts[i].Load())
+				return false
+			}
+		}
+		if int(peak.Load()) > n {
+			t.Logf("size %d: %d tasks ran at once", n, peak.Load())
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 50}); err != nil {
+		t.Error(err)
+	}
+}
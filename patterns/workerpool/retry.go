@@ -0,0 +1,16 @@
+package workerpool
+
+import (
+	"context"
+
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+// Retry decorates w so that a failing Task is retried according to policy.
+// The retries happen inside the Task, so they keep the goroutine that was
+// given to w rather than going back to the end of the queue.
+func Retry(w Worker, policy retry.Policy) Worker {
+	return WorkerFunc(func(ctx context.Context) error {
+		return retry.Do(ctx, policy, w.Task)
+	})
+}
@@ -0,0 +1,114 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrShed is returned by Run for a Task that Shedding rejected.
+var ErrShed = errors.New("workerpool: overloaded, task shed")
+
+// Priority ranks Tasks for Shedding. A Task without one is Normal.
+type Priority int
+
+const (
+	Low Priority = iota + 1
+	Normal
+	High
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context that submits Tasks at priority pr.
+func WithPriority(ctx context.Context, pr Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, pr)
+}
+
+// PriorityOf returns the priority ctx submits Tasks at.
+func PriorityOf(ctx context.Context) Priority {
+	if pr, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return pr
+	}
+	return Normal
+}
+
+type shedKey struct{}
+
+// markShed tells the pool goroutine running the Task that got ctx that it
+// was shed.
+func markShed(ctx context.Context) {
+	if shed, ok := ctx.Value(shedKey{}).(*bool); ok {
+		*shed = true
+	}
+}
+
+type submittedKey struct{}
+
+// withSubmitted stamps ctx with the time a Task is handed to the pool.
+func withSubmitted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, submittedKey{}, time.Now())
+}
+
+// Submitted returns the time the Task that got ctx was handed to Run or
+// TryRun, so middleware can tell how long it waited for a goroutine.
+func Submitted(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(submittedKey{}).(time.Time)
+	return t, ok
+}
+
+// ShedOptions configures Shedding. A zero limit disables that check.
+type ShedOptions struct {
+	// MaxUtilization is the share of the pool in demand, the goroutines
+	// running Tasks and the callers of Run waiting for one, above which
+	// Tasks are shed. 1 sheds as soon as anybody has to wait.
+	MaxUtilization float64
+	// MaxQueueWait is the time a Task may have waited for a goroutine
+	// before it is shed.
+	MaxQueueWait time.Duration
+	// Below is the priority from which Tasks are always run. It defaults
+	// to High, so Low and Normal Tasks are shed.
+	Below Priority
+	// Drop makes a shed Task return nil instead of ErrShed, for work whose
+	// callers do not care whether it ran.
+	Drop bool
+}
+
+// Shedding returns middleware that skips Tasks of a priority below
+// opts.Below while p is over a limit, and always runs the others. A Task
+// reaches the middleware once it has a goroutine, so shedding it frees
+// that goroutine at once for the Tasks waiting behind it, and a Task that
+// waited longer than its caller can use is not run late. The pool counts
+// a shed Task as shed, in Stats and as tasks_shed, and not as started or
+// failed.
+func Shedding(p *Pool, opts ShedOptions) TaskMiddleware {
+	if opts.Below == 0 {
+		opts.Below = High
+	}
+	overloaded := func(ctx context.Context) bool {
+		if opts.MaxUtilization > 0 {
+			demand := float64(p.Active()+p.Waiting()) / float64(p.Size())
+			if demand > opts.MaxUtilization {
+				return true
+			}
+		}
+		if opts.MaxQueueWait > 0 {
+			if at, ok := Submitted(ctx); ok && time.Since(at) > opts.MaxQueueWait {
+				return true
+			}
+		}
+		return false
+	}
+	return func(next TaskFunc) TaskFunc {
+		return func(ctx context.Context) error {
+			if PriorityOf(ctx) >= opts.Below || !overloaded(ctx) {
+				return next(ctx)
+			}
+			markShed(ctx)
+			if opts.Drop {
+				return nil
+			}
+			return ErrShed
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Package workerpool provides a pool of goroutines fed through an unbuffered
+// channel. Submitting work blocks until a goroutine picks it up, so the pool
+// never queues work it has not started, and callers that cannot wait can
+// ask for the work to be rejected instead.
+//
+// A Worker that panics does not take the process down: the panic is
+// recovered through recovery.Default and Run returns it as a
+// *recovery.PanicError.
+//
+// A pool reports what it does to a Logger and a Metrics given in Options,
+// and keeps its own counters for Stats.
+// Both default to the null objects of behavioral/nullobject, so the pool
+// calls them unconditionally and costs nothing extra when they are unset.
+// Middleware added with Use wraps every Task the pool runs, for what a
+// pool-wide Logger and Metrics cannot say, such as per-stage timings or
+// tracing, without changing the Workers. Hooks see every Task start and
+// end with the number of the goroutine that runs it, and can put what
+// they like in the context the Task gets, such as the attributes of a
+// structured logger. The Shedding middleware skips low priority Tasks while
+// the pool is overloaded.
+//
+// A slot is reserved for every submission before it is handed over, which
+// keeps TryRun exact: it only fails when all goroutines really are taken,
+// not when one of them is merely between two tasks.
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crazybber/go-patterns/behavioral/nullobject"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+var (
+	// ErrSaturated is returned by TryRun when every goroutine is busy.
+	ErrSaturated = errors.New("workerpool: all workers are busy")
+	// ErrClosed is returned when work is submitted after Shutdown.
+	ErrClosed = errors.New("workerpool: pool has been shut down")
+)
+
+// Worker must be implemented by types that want to use the pool. The
+// context passed to Task is derived from the one given to Run, so a caller
+// going away can stop the work it submitted.
+type Worker interface {
+	Task(ctx context.Context) error
+}
+
+// WorkerFunc adapts an ordinary function to the Worker interface.
+type WorkerFunc func(ctx context.Context) error
+
+// Task calls f(ctx).
+func (f WorkerFunc) Task(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Logger receives the panics recovered from Workers.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// Metrics receives the pool's counters: tasks_started, tasks_failed,
+// tasks_panicked, tasks_rejected and tasks_shed, and the task_seconds
+// distribution of task durations.
+type Metrics interface {
+	Add(name string, delta float64)
+	Observe(name string, value float64)
+}
+
+// Hooks are called on the pool goroutine that runs a Task, around it.
+// Workers are numbered from 0 to the pool's size less one, Tasks from 1 in
+// the order goroutines take them.
+type Hooks interface {
+	// TaskStart is called before the Task runs. The Task, and TaskEnd,
+	// get the context it returns.
+	TaskStart(ctx context.Context, worker int, task uint64) context.Context
+	// TaskEnd is called once the Task has returned err after d.
+	TaskEnd(ctx context.Context, err error, d time.Duration)
+}
+
+type noHooks struct{}
+
+func (noHooks) TaskStart(ctx context.Context, _ int, _ uint64) context.Context { return ctx }
+func (noHooks) TaskEnd(context.Context, error, time.Duration)                  {}
+
+// Options configures a Pool.
+type Options struct {
+	// Logger defaults to a nullobject.NopLogger.
+	Logger Logger
+	// Metrics defaults to a nullobject.NopMetrics.
+	Metrics Metrics
+	// Hooks default to none.
+	Hooks Hooks
+}
+
+func (o *Options) defaults() {
+	o.Logger = nullobject.LoggerOr(o.Logger)
+	o.Metrics = nullobject.MetricsOr(o.Metrics)
+	if o.Hooks == nil {
+		o.Hooks = noHooks{}
+	}
+}
+
+type job struct {
+	ctx  context.Context
+	w    Worker
+	task TaskFunc
+	done chan error
+}
+
+// Pool provides a fixed number of goroutines that execute submitted Workers.
+type Pool struct {
+	work   chan job
+	slots  chan struct{}
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+	size   int
+	active int32
+	// waiting counts the callers of Run waiting for a goroutine.
+	waiting int32
+	tasks   atomic.Uint64
+	opts    Options
+	mw      []TaskMiddleware
+
+	// The counters of Stats; tasks numbers the Tasks the goroutines take,
+	// shed ones included, and started counts those whose Worker ran.
+	started, failed, panicked, rejected, shed atomic.Uint64
+	busy                                      atomic.Int64
+}
+
+// New creates a pool with maxGoroutines goroutines.
+func New(maxGoroutines int) *Pool {
+	return NewWith(maxGoroutines, Options{})
+}
+
+// NewWith creates a pool with maxGoroutines goroutines configured by opts.
+func NewWith(maxGoroutines int, opts Options) *Pool {
+	opts.defaults()
+	p := &Pool{
+		work:  make(chan job),
+		slots: make(chan struct{}, maxGoroutines),
+		size:  maxGoroutines,
+		opts:  opts,
+	}
+	p.wg.Add(maxGoroutines)
+	for i := 0; i < maxGoroutines; i++ {
+		go p.loop(i)
+	}
+	return p
+}
+
+func (p *Pool) loop(worker int) {
+	defer p.wg.Done()
+	m, h := p.opts.Metrics, p.opts.Hooks
+	for j := range p.work {
+		atomic.AddInt32(&p.active, 1)
+		ctx := h.TaskStart(j.ctx, worker, p.tasks.Add(1))
+		var shed bool
+		ctx = context.WithValue(ctx, shedKey{}, &shed)
+		start := time.Now()
+		err := recovery.Do(func() error { return j.task(ctx) })
+		d := time.Since(start)
+		h.TaskEnd(ctx, err, d)
+		m.Observe("task_seconds", d.Seconds())
+		p.busy.Add(int64(d))
+		atomic.AddInt32(&p.active, -1)
+		switch {
+		case shed:
+			// Neither started nor failed: the Worker never ran.
+			p.shed.Add(1)
+			m.Add("tasks_shed", 1)
+		case err != nil:
+			p.failed.Add(1)
+			m.Add("tasks_failed", 1)
+			var pe *recovery.PanicError
+			if errors.As(err, &pe) {
+				p.panicked.Add(1)
+				m.Add("tasks_panicked", 1)
+				p.opts.Logger.Printf("workerpool: %v", pe)
+			}
+		}
+		j.done <- err
+	}
+}
+
+// Run submits w and waits for it to finish. It blocks while the pool is
+// busy and gives up with ctx.Err() if ctx is done before a goroutine takes
+// the work. Once started, w runs to completion and its error is returned.
+func (p *Pool) Run(ctx context.Context, w Worker) error {
+	j := job{ctx: withSubmitted(ctx), w: w, done: make(chan error, 1)}
+
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrClosed
+	}
+	atomic.AddInt32(&p.waiting, 1)
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		atomic.AddInt32(&p.waiting, -1)
+		p.mu.RUnlock()
+		return ctx.Err()
+	}
+	atomic.AddInt32(&p.waiting, -1)
+	return p.dispatch(j)
+}
+
+// TryRun is like Run but returns ErrSaturated instead of waiting when no
+// goroutine is idle.
+func (p *Pool) TryRun(ctx context.Context, w Worker) error {
+	j := job{ctx: withSubmitted(ctx), w: w, done: make(chan error, 1)}
+
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrClosed
+	}
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		p.mu.RUnlock()
+		p.rejected.Add(1)
+		p.opts.Metrics.Add("tasks_rejected", 1)
+		return ErrSaturated
+	}
+	return p.dispatch(j)
+}
+
+// dispatch hands j to a goroutine once the caller holds a slot and the read
+// lock, and waits for the result. A free goroutine is guaranteed to show up
+// because there are never more slots than goroutines.
+func (p *Pool) dispatch(j job) error {
+	j.task = p.chain(j.w)
+	p.work <- j
+	p.mu.RUnlock()
+	err := <-j.done
+	<-p.slots
+	return err
+}
+
+// Size returns the number of goroutines in the pool.
+func (p *Pool) Size() int {
+	return p.size
+}
+
+// Active returns the number of goroutines currently running a Worker.
+func (p *Pool) Active() int {
+	return int(atomic.LoadInt32(&p.active))
+}
+
+// Waiting returns the number of callers of Run waiting for a goroutine to
+// become free.
+func (p *Pool) Waiting() int {
+	return int(atomic.LoadInt32(&p.waiting))
+}
+
+// Stats is a snapshot of a pool: its gauges and the counters of the
+// Tasks it has run since it was created.
+type Stats struct {
+	Size    int
+	Active  int
+	Waiting int
+	// Started, Failed and Panicked count Tasks as their Worker starts,
+	// returns an error and panics; a panic is also a failure.
+	Started  uint64
+	Failed   uint64
+	Panicked uint64
+	// Rejected counts the Tasks TryRun turned away.
+	Rejected uint64
+	// Shed counts the Tasks Shedding skipped, which are neither started
+	// nor failed.
+	Shed uint64
+	// Busy is the time the goroutines have spent running Tasks.
+	Busy time.Duration
+}
+
+// Stats returns what p is doing and has done, without a Metrics: the
+// counters are kept by the pool itself, for exporters and dashboards to
+// read. The fields are read one by one, so they may be a Task apart.
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Size:     p.size,
+		Active:   p.Active(),
+		Waiting:  p.Waiting(),
+		Started:  p.started.Load(),
+		Failed:   p.failed.Load(),
+		Panicked: p.panicked.Load(),
+		Rejected: p.rejected.Load(),
+		Shed:     p.shed.Load(),
+		Busy:     time.Duration(p.busy.Load()),
+	}
+}
+
+// Shutdown stops accepting work and waits for running Workers to finish.
+func (p *Pool) Shutdown() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.work)
+	p.mu.Unlock()
+	p.wg.Wait()
+}
@@ -0,0 +1,507 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+func TestRunBoundsConcurrency(t *testing.T) {
+	p := New(3)
+	defer p.Shutdown()
+
+	var (
+		mu       sync.Mutex
+		running  int
+		maxSeen  int
+		wg       sync.WaitGroup
+		failures int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.Run(context.Background(), WorkerFunc(func(ctx context.Context) error {
+				mu.Lock()
+				running++
+				if running > maxSeen {
+					maxSeen = running
+				}
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			}))
+			if err != nil {
+				mu.Lock()
+				failures++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failures != 0 {
+		t.Errorf("%d runs failed", failures)
+	}
+	if maxSeen > p.Size() {
+		t.Errorf("saw %d concurrent tasks in a pool of %d", maxSeen, p.Size())
+	}
+}
+
+func TestTryRunSaturated(t *testing.T) {
+	p := New(1)
+	defer p.Shutdown()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go p.Run(context.Background(), WorkerFunc(func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	<-started
+
+	err := p.TryRun(context.Background(), WorkerFunc(func(ctx context.Context) error { return nil }))
+	if err != ErrSaturated {
+		t.Errorf("expected ErrSaturated, got %v", err)
+	}
+	close(release)
+}
+
+func TestRunContext(t *testing.T) {
+	p := New(1)
+	defer p.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+	err := p.Run(ctx, WorkerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	p := New(2)
+	p.Shutdown()
+	p.Shutdown()
+
+	if err := p.Run(context.Background(), WorkerFunc(func(ctx context.Context) error { return nil })); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestPanickingWorker(t *testing.T) {
+	p := New(1)
+	defer p.Shutdown()
+
+	err := p.Run(context.Background(), WorkerFunc(func(context.Context) error {
+		panic("worker bug")
+	}))
+	var pe *recovery.PanicError
+	if !errors.As(err, &pe) || pe.Value != "worker bug" {
+		t.Fatalf("expected the panic as an error, got %v", err)
+	}
+	if p.Active() != 0 {
+		t.Errorf("active = %d after a panic", p.Active())
+	}
+	if err := p.Run(context.Background(), WorkerFunc(func(context.Context) error { return nil })); err != nil {
+		t.Errorf("pool unusable after a panic: %v", err)
+	}
+}
+
+// recorder is a Logger and Metrics that keeps what it is given.
+type recorder struct {
+	mu       sync.Mutex
+	counters map[string]float64
+	observed int
+	lines    []string
+}
+
+func (r *recorder) Printf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Add(name string, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[name] += delta
+}
+
+func (r *recorder) Observe(name string, _ float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "task_seconds" {
+		r.observed++
+	}
+}
+
+func TestLoggerAndMetrics(t *testing.T) {
+	rec := &recorder{counters: map[string]float64{}}
+	p := NewWith(1, Options{Logger: rec, Metrics: rec})
+	defer p.Shutdown()
+	ctx := context.Background()
+
+	p.Run(ctx, WorkerFunc(func(context.Context) error { return nil }))
+	p.Run(ctx, WorkerFunc(func(context.Context) error { return errors.New("failed") }))
+	p.Run(ctx, WorkerFunc(func(context.Context) error { panic("worker bug") }))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go p.Run(ctx, WorkerFunc(func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	<-started
+	if err := p.TryRun(ctx, WorkerFunc(func(context.Context) error { return nil })); err != ErrSaturated {
+		t.Fatalf("TryRun = %v", err)
+	}
+	close(release)
+	p.Shutdown()
+
+	want := map[string]float64{"tasks_started": 4, "tasks_failed": 2, "tasks_panicked": 1, "tasks_rejected": 1}
+	if fmt.Sprint(rec.counters) != fmt.Sprint(want) || rec.observed != 4 {
+		t.Errorf("counters %v and %d durations, want %v and 4", rec.counters, rec.observed, want)
+	}
+	if len(rec.lines) != 1 || !strings.Contains(rec.lines[0], "worker bug") {
+		t.Errorf("logged %q", rec.lines)
+	}
+	// The pool counts the same without a Metrics.
+	st := p.Stats()
+	st.Busy = 0
+	if st != (Stats{Size: 1, Started: 4, Failed: 2, Panicked: 1, Rejected: 1}) {
+		t.Errorf("Stats = %+v", st)
+	}
+}
+
+type hookKey struct{}
+
+// hookRecorder is Hooks that tags the context with the task number and
+// keeps what TaskEnd is given.
+type hookRecorder struct {
+	mu      sync.Mutex
+	workers map[int]bool
+	ends    []string
+}
+
+func (h *hookRecorder) TaskStart(ctx context.Context, worker int, task uint64) context.Context {
+	h.mu.Lock()
+	h.workers[worker] = true
+	h.mu.Unlock()
+	return context.WithValue(ctx, hookKey{}, task)
+}
+
+func (h *hookRecorder) TaskEnd(ctx context.Context, err error, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ends = append(h.ends, fmt.Sprintf("%d:%v", ctx.Value(hookKey{}), err))
+}
+
+func TestHooks(t *testing.T) {
+	h := &hookRecorder{workers: map[int]bool{}}
+	p := NewWith(2, Options{Hooks: h})
+	ctx := context.Background()
+
+	var seen []any
+	for i := range 3 {
+		p.Run(ctx, WorkerFunc(func(ctx context.Context) error {
+			seen = append(seen, ctx.Value(hookKey{}))
+			if i == 1 {
+				return errors.New("failed")
+			}
+			return nil
+		}))
+	}
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Run(ctx, WorkerFunc(func(context.Context) error {
+				<-release
+				return nil
+			}))
+		}()
+	}
+	for p.Active() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	p.Shutdown()
+
+	if fmt.Sprint(seen) != "[1 2 3]" {
+		t.Errorf("Tasks saw %v", seen)
+	}
+	if got := strings.Join(h.ends[:3], " "); got != "1:<nil> 2:failed 3:<nil>" {
+		t.Errorf("TaskEnd got %s", got)
+	}
+	if len(h.ends) != 5 || !h.workers[0] || !h.workers[1] {
+		t.Errorf("%d ends on workers %v", len(h.ends), h.workers)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	p := New(1)
+	defer p.Shutdown()
+
+	var calls int
+	w := Retry(WorkerFunc(func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	}), retry.Policy{MaxAttempts: 5})
+	if err := p.Run(context.Background(), w); err != nil || calls != 3 {
+		t.Errorf("got %v after %d calls", err, calls)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	p := New(1)
+	defer p.Shutdown()
+	var trace []string
+	tag := func(name string) TaskMiddleware {
+		return func(next TaskFunc) TaskFunc {
+			return func(ctx context.Context) error {
+				trace = append(trace, name+" in")
+				err := next(ctx)
+				trace = append(trace, name+" out")
+				return err
+			}
+		}
+	}
+	task := WorkerFunc(func(context.Context) error {
+		trace = append(trace, "task")
+		return nil
+	})
+
+	p.Run(context.Background(), task)
+	p.Use(tag("outer"), tag("middle"))
+	p.Use(tag("inner"))
+	p.Run(context.Background(), task)
+	want := "[task outer in middle in inner in task inner out middle out outer out]"
+	if got := fmt.Sprint(trace); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestMiddlewareCanShortCircuit(t *testing.T) {
+	p := New(1)
+	defer p.Shutdown()
+	errMaintenance := errors.New("down for maintenance")
+	maintenance := true
+	p.Use(func(next TaskFunc) TaskFunc {
+		return func(ctx context.Context) error {
+			if maintenance {
+				return errMaintenance
+			}
+			return next(ctx)
+		}
+	})
+	ran := false
+	err := p.Run(context.Background(), WorkerFunc(func(context.Context) error {
+		ran = true
+		return nil
+	}))
+	if err != errMaintenance || ran {
+		t.Errorf("Run = %v, ran %v", err, ran)
+	}
+}
+
+// timings is a Metrics that keeps the names it is given.
+type timings struct {
+	mu       sync.Mutex
+	observed map[string]int
+	counted  map[string]float64
+}
+
+func (m *timings) Add(name string, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counted[name] += delta
+}
+
+func (m *timings) Observe(name string, _ float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observed[name]++
+}
+
+func TestLoggingAndTiming(t *testing.T) {
+	log := &recorder{}
+	m := &timings{observed: map[string]int{}, counted: map[string]float64{}}
+	p := New(2)
+	p.Use(Logging(log), Timing(m, "resize"))
+	ctx := context.Background()
+	p.Run(ctx, WorkerFunc(func(context.Context) error { return nil }))
+	p.Run(ctx, WorkerFunc(func(context.Context) error { return errors.New("corrupt image") }))
+	var pe *recovery.PanicError
+	if err := p.Run(ctx, WorkerFunc(func(context.Context) error { panic("decoder bug") })); !errors.As(err, &pe) {
+		t.Errorf("panic through middleware: %v", err)
+	}
+	p.Shutdown()
+
+	if fmt.Sprint(m.observed) != "map[resize_seconds:2]" || fmt.Sprint(m.counted) != "map[resize_failed:1]" {
+		t.Errorf("observed %v, counted %v", m.observed, m.counted)
+	}
+	if len(log.lines) != 1 || !strings.Contains(log.lines[0], "corrupt image") {
+		t.Errorf("logged %q", log.lines)
+	}
+}
+
+// saturate occupies every goroutine of p but the ones free leaves with
+// Tasks that return once release is closed.
+func saturate(t *testing.T, p *Pool, free int) (release chan struct{}) {
+	t.Helper()
+	release = make(chan struct{})
+	for range p.Size() - free {
+		started := make(chan struct{})
+		go p.Run(context.Background(), WorkerFunc(func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		}))
+		<-started
+	}
+	return release
+}
+
+// waitFor waits until n callers of Run are waiting for p.
+func waitFor(p *Pool, n int) {
+	for p.Waiting() < n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSheddingByQueueWait(t *testing.T) {
+	m := &timings{observed: map[string]int{}, counted: map[string]float64{}}
+	p := NewWith(1, Options{Metrics: m})
+	defer p.Shutdown()
+	p.Use(Shedding(p, ShedOptions{MaxQueueWait: 20 * time.Millisecond}))
+	ran := make(map[Priority]bool)
+	var mu sync.Mutex
+	submit := func(pr Priority) error {
+		return p.Run(WithPriority(context.Background(), pr), WorkerFunc(func(context.Context) error {
+			mu.Lock()
+			ran[pr] = true
+			mu.Unlock()
+			return nil
+		}))
+	}
+
+	// Nobody waits long on an idle pool, so low priority work runs.
+	if err := submit(Low); err != nil {
+		t.Fatalf("idle pool: %v", err)
+	}
+	delete(ran, Low)
+
+	release := saturate(t, p, 0)
+	errs := make(map[Priority]chan error)
+	for _, pr := range []Priority{Low, Normal, High} {
+		ch := make(chan error, 1)
+		errs[pr] = ch
+		go func() { ch <- submit(pr) }()
+	}
+	waitFor(p, 3)
+	time.Sleep(40 * time.Millisecond)
+	close(release)
+	for pr, want := range map[Priority]error{Low: ErrShed, Normal: ErrShed, High: nil} {
+		err := <-errs[pr]
+		mu.Lock()
+		if err != want || ran[pr] != (want == nil) {
+			t.Errorf("priority %d: Run = %v, ran %v", pr, err, ran[pr])
+		}
+		mu.Unlock()
+	}
+	// The shed Tasks are neither started nor failed.
+	if m.counted["tasks_shed"] != 2 || m.counted["tasks_started"] != 3 || m.counted["tasks_failed"] != 0 {
+		t.Errorf("counted %v", m.counted)
+	}
+	if st := p.Stats(); st.Shed != 2 || st.Started != 3 || st.Failed != 0 {
+		t.Errorf("Stats = %+v", st)
+	}
+}
+
+// Under saturation high priority Tasks all run, while the low priority
+// ones are shed for as long as there are others waiting behind them.
+func TestSheddingByUtilization(t *testing.T) {
+	p := New(2)
+	defer p.Shutdown()
+	p.Use(Shedding(p, ShedOptions{MaxUtilization: 1, Drop: true}))
+	release := saturate(t, p, 1)
+	defer close(release)
+
+	var (
+		mu  sync.Mutex
+		ran = map[Priority]int{}
+		wg  sync.WaitGroup
+		// gate holds the free goroutine until everybody is waiting.
+		gate = make(chan struct{})
+	)
+	started := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.Run(WithPriority(context.Background(), High), WorkerFunc(func(context.Context) error {
+			close(started)
+			<-gate
+			return nil
+		}))
+	}()
+	<-started
+	for _, pr := range []Priority{Low, High, Low, High, Low, High, Low, High} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.Run(WithPriority(context.Background(), pr), WorkerFunc(func(context.Context) error {
+				mu.Lock()
+				ran[pr]++
+				mu.Unlock()
+				return nil
+			}))
+			if err != nil {
+				t.Errorf("dropped Task returned %v", err)
+			}
+		}()
+	}
+	waitFor(p, 8)
+	close(gate)
+	wg.Wait()
+	// The last Task to get the goroutine has nobody behind it.
+	if ran[High] != 4 || ran[Low] > 1 {
+		t.Errorf("ran %d high and %d low priority Tasks", ran[High], ran[Low])
+	}
+}
+
+func TestSheddingBelow(t *testing.T) {
+	p := New(1)
+	defer p.Shutdown()
+	p.Use(Shedding(p, ShedOptions{MaxQueueWait: time.Nanosecond, Below: Normal}))
+	noop := WorkerFunc(func(context.Context) error { return nil })
+	if err := p.Run(context.Background(), noop); err != nil {
+		t.Errorf("Normal Task: %v", err)
+	}
+	if err := p.Run(WithPriority(context.Background(), Low), noop); err != ErrShed {
+		t.Errorf("Low Task: %v", err)
+	}
+}
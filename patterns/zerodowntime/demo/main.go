@@ -0,0 +1,43 @@
+// Demo server for zero-downtime restarts.
+//
+// Run it, keep a load generator pointed at :8080 and send SIGHUP: a new
+// process takes over the socket and the old one drains and exits, without
+// a failed request. Rebuild the binary before the SIGHUP to deploy a new
+// version the same way. SIGINT or SIGTERM stop the server.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/zerodowntime"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	restart := make(chan os.Signal, 1)
+	signal.Notify(restart, syscall.SIGHUP)
+
+	ln, err := zerodowntime.Listen("tcp", ":8080")
+	if err != nil {
+		log.Fatal(err)
+	}
+	pid := os.Getpid()
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprintf(w, "served by %d\n", pid)
+	})}
+
+	log.Printf("pid %d serving on %s", pid, ln.Addr())
+	if err := zerodowntime.Serve(ctx, srv, ln, restart, zerodowntime.Options{}); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("pid %d drained", pid)
+}
@@ -0,0 +1,284 @@
+// Package zerodowntime restarts a server process without refusing or
+// dropping a single connection.
+//
+// The listening socket outlives the process. On restart the old process
+// starts a new copy of its binary and hands the socket over as an inherited
+// file (exec.Cmd.ExtraFiles). Both processes now accept from the same
+// kernel queue. The old process waits until the new one reports, over a
+// pipe, that it is serving, and only then stops accepting and drains its
+// in-flight requests. Connections that arrive in
+// between are queued by the kernel and picked up by whichever process
+// accepts first; none are refused because the socket is never closed.
+//
+// Unlike SO_REUSEPORT, fd passing keeps a single socket, so no connection
+// sitting in the queue of a closing listener is lost.
+package zerodowntime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables naming the inherited descriptors.
+const (
+	EnvListener = "ZERODOWNTIME_LISTENER_FD"
+	EnvReady    = "ZERODOWNTIME_READY_FD"
+)
+
+var (
+	// ErrNotReady is returned by Handover when the new process exits or
+	// times out before reporting ready.
+	ErrNotReady = errors.New("zerodowntime: new process did not become ready")
+	// ErrNoFile is returned for listeners that cannot be turned into a file.
+	ErrNoFile = errors.New("zerodowntime: listener has no file descriptor")
+)
+
+// Options configures Handover and Serve.
+type Options struct {
+	// Path of the binary to start. It defaults to os.Executable.
+	Path string
+	// Args for the new process, not including the program name. They
+	// default to os.Args[1:].
+	Args []string
+	// ReadyTimeout bounds the wait for the new process. It defaults to
+	// 10s.
+	ReadyTimeout time.Duration
+	// DrainTimeout bounds the wait for in-flight requests when the
+	// server stops. It defaults to 30s.
+	DrainTimeout time.Duration
+}
+
+func (o *Options) defaults() error {
+	if o.Path == "" {
+		path, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		o.Path = path
+	}
+	if o.Args == nil {
+		o.Args = os.Args[1:]
+	}
+	if o.ReadyTimeout <= 0 {
+		o.ReadyTimeout = 10 * time.Second
+	}
+	if o.DrainTimeout <= 0 {
+		o.DrainTimeout = 30 * time.Second
+	}
+	return nil
+}
+
+// Listen returns the listener inherited from the parent process, or a new
+// one on addr when there is none.
+func Listen(network, addr string) (net.Listener, error) {
+	fd, ok := inherited(EnvListener)
+	if !ok {
+		return net.Listen(network, addr)
+	}
+	os.Unsetenv(EnvListener)
+	f := os.NewFile(fd, "listener")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// Ready tells the parent process that this one is serving. It does nothing
+// when the process was not started by Handover.
+func Ready() error {
+	fd, ok := inherited(EnvReady)
+	if !ok {
+		return nil
+	}
+	os.Unsetenv(EnvReady)
+	f := os.NewFile(fd, "ready")
+	defer f.Close()
+	_, err := f.Write([]byte{1})
+	return err
+}
+
+func inherited(env string) (uintptr, bool) {
+	fd, err := strconv.Atoi(os.Getenv(env))
+	if err != nil || fd < 3 {
+		return 0, false
+	}
+	return uintptr(fd), true
+}
+
+// Handover starts a new process that inherits ln and returns once it has
+// called Ready. The caller stays responsible for ln and should close it by
+// draining its server.
+func Handover(ln net.Listener, opts Options) (*os.Process, error) {
+	if err := opts.defaults(); err != nil {
+		return nil, err
+	}
+	filer, ok := ln.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, ErrNoFile
+	}
+	lf, err := filer.File()
+	if err != nil {
+		return nil, err
+	}
+	defer lf.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cmd := exec.Command(opts.Path, opts.Args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	// ExtraFiles[i] becomes descriptor 3+i in the child.
+	cmd.ExtraFiles = []*os.File{lf, w}
+	cmd.Env = append(environ(), EnvListener+"=3", EnvReady+"=4")
+	err = cmd.Start()
+	// Our copy of the write end must go, or a child that dies would never
+	// produce EOF on r.
+	w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		_, err := r.Read(b[:])
+		ready <- err
+	}()
+	select {
+	case err := <-ready:
+		if err == nil {
+			return cmd.Process, nil
+		}
+		cmd.Wait()
+		return nil, fmt.Errorf("%w: %v", ErrNotReady, err)
+	case <-time.After(opts.ReadyTimeout):
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("%w: timeout after %v", ErrNotReady, opts.ReadyTimeout)
+	}
+}
+
+// environ returns the environment without descriptors inherited by this
+// process.
+func environ() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, EnvListener+"=") || strings.HasPrefix(kv, EnvReady+"=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+// Serve runs srv on ln and reports ready to a parent process. On a value
+// from restart it hands ln over to a new process and drains srv once the
+// new process is ready; if the handover fails it keeps serving. When ctx is
+// done it drains srv without a handover.
+//
+// Serve installs its own srv.ConnState hook, calling any hook already set.
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, restart <-chan os.Signal, opts Options) error {
+	if err := opts.defaults(); err != nil {
+		return err
+	}
+	conns := track(srv)
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	if err := Ready(); err != nil {
+		srv.Close()
+		return err
+	}
+
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+			return drain(srv, ln, errc, conns, opts.DrainTimeout)
+		case <-restart:
+			if _, err := Handover(ln, opts); err != nil {
+				if srv.ErrorLog != nil {
+					srv.ErrorLog.Printf("zerodowntime: restart failed, still serving: %v", err)
+				}
+				continue
+			}
+			return drain(srv, ln, errc, conns, opts.DrainTimeout)
+		}
+	}
+}
+
+// tracker counts the open connections of a server.
+type tracker struct {
+	mu   sync.Mutex
+	open int
+}
+
+func track(srv *http.Server) *tracker {
+	t := &tracker{}
+	hook := srv.ConnState
+	srv.ConnState = func(c net.Conn, st http.ConnState) {
+		t.mu.Lock()
+		switch st {
+		case http.StateNew:
+			t.open++
+		case http.StateClosed, http.StateHijacked:
+			t.open--
+		}
+		t.mu.Unlock()
+		if hook != nil {
+			hook(c, st)
+		}
+	}
+	return t
+}
+
+func (t *tracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.open
+}
+
+// drain stops srv accepting and waits for its connections to finish.
+//
+// It cannot use http.Server.Shutdown: once that has started, a connection
+// that was accepted but whose request has not been read yet is closed
+// without an answer, and under load there always is one. Instead drain
+// turns keep-alives off, so every connection closes after its current
+// request and idle ones close right away, closes the listener and waits for
+// the connection count to drop to zero.
+func drain(srv *http.Server, ln net.Listener, served <-chan error, conns *tracker, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	defer srv.Close()
+
+	srv.SetKeepAlivesEnabled(false)
+	ln.Close()
+	// Serve registers every connection it accepts before accepting the
+	// next, so after it returns the count is complete.
+	select {
+	case <-served:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	t := time.NewTicker(5 * time.Millisecond)
+	defer t.Stop()
+	for conns.count() > 0 {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
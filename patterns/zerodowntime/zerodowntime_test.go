@@ -0,0 +1,193 @@
+//go:build !windows
+
+package zerodowntime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// envHelper turns the test binary into the demo server, or into a process
+// that fails on startup.
+const envHelper = "ZERODOWNTIME_TEST_HELPER"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(envHelper) {
+	case "serve":
+		os.Exit(helper())
+	case "fail":
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// helper serves its pid on the inherited listener, restarting on SIGHUP and
+// stopping on SIGTERM.
+func helper() int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+	restart := make(chan os.Signal, 1)
+	signal.Notify(restart, syscall.SIGHUP)
+
+	ln, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Long enough for requests to be in flight during every drain.
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprint(w, os.Getpid())
+	})}
+	if err := Serve(ctx, srv, ln, restart, Options{}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func get(c *http.Client, url string) (int, error) {
+	resp, err := c.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status %s", resp.Status)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(body)))
+}
+
+func TestRestartUnderLoad(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts several processes")
+	}
+	t.Setenv(envHelper, "serve")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + ln.Addr().String()
+	first, err := Handover(ln, Options{Path: os.Args[0], Args: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go first.Wait()
+	// The test process never accepts; the socket lives on in the server.
+	ln.Close()
+
+	// Every request uses a new connection, so each one goes through accept.
+	client := &http.Client{
+		Transport: &http.Transport{DisableKeepAlives: true},
+		Timeout:   5 * time.Second,
+	}
+	var (
+		ok, failed atomic.Int64
+		mu         sync.Mutex
+		errs       []error
+		stop       = make(chan struct{})
+		wg         sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := get(client, url); err != nil {
+					failed.Add(1)
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+					continue
+				}
+				ok.Add(1)
+			}
+		}()
+	}
+
+	pid, err := get(client, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The servers are not children of the test; stop the last one even
+	// when the test fails.
+	defer func() { syscall.Kill(pid, syscall.SIGTERM) }()
+	for i := 0; i < 3; i++ {
+		if err := syscall.Kill(pid, syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(10 * time.Second)
+		next := pid
+		for next == pid {
+			if time.Now().After(deadline) {
+				t.Fatalf("restart %d: still served by %d", i, pid)
+			}
+			time.Sleep(5 * time.Millisecond)
+			if next, err = get(client, url); err != nil {
+				t.Fatalf("restart %d: %v", i, err)
+			}
+		}
+		t.Logf("restart %d: %d -> %d", i, pid, next)
+		pid = next
+	}
+	// Let the last drain finish under load as well.
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	if failed.Load() > 0 {
+		t.Errorf("%d of %d requests failed across restarts, first: %v",
+			failed.Load(), failed.Load()+ok.Load(), errs[0])
+	}
+	if ok.Load() == 0 {
+		t.Errorf("no requests were served")
+	}
+}
+
+func TestHandoverNotReady(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	t.Setenv(envHelper, "fail")
+	_, err = Handover(ln, Options{Path: os.Args[0], Args: []string{}})
+	if !errors.Is(err, ErrNotReady) {
+		t.Errorf("err = %v, want %v", err, ErrNotReady)
+	}
+}
+
+func TestListenWithoutParent(t *testing.T) {
+	ln, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	if err := Ready(); err != nil {
+		t.Errorf("Ready without a parent: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package freelist
+
+import "sort"
+
+// Classes keeps objects with a variable-size part in one List per size
+// class.
+type Classes[T any] struct {
+	sizes  []int
+	lists  []*List[T]
+	alloc  func(size int) *T
+	sizeOf func(*T) int
+}
+
+// NewClasses returns free lists for the given size classes, in ascending
+// order. alloc allocates an object of a size, sizeOf returns the size an
+// object was allocated with, its capacity rather than its length.
+func NewClasses[T any](sizes []int, alloc func(size int) *T, sizeOf func(*T) int, opts Options[T]) *Classes[T] {
+	sizes = append([]int(nil), sizes...)
+	sort.Ints(sizes)
+	c := &Classes[T]{sizes: sizes, alloc: alloc, sizeOf: sizeOf}
+	for _, size := range sizes {
+		c.lists = append(c.lists, New(func() *T { return alloc(size) }, opts))
+	}
+	return c
+}
+
+// class returns the index of the smallest class holding n, or -1.
+func (c *Classes[T]) class(n int) int {
+	i := sort.SearchInts(c.sizes, n)
+	if i == len(c.sizes) {
+		return -1
+	}
+	return i
+}
+
+// Get returns an object of at least size n. Sizes above the largest class
+// are allocated and not pooled.
+func (c *Classes[T]) Get(n int) *T {
+	i := c.class(n)
+	if i < 0 {
+		return c.alloc(n)
+	}
+	return c.lists[i].Get()
+}
+
+// Put returns p to the list of its size class. Objects whose size matches
+// no class, because they were allocated above the largest or grew, are
+// dropped.
+func (c *Classes[T]) Put(p *T) {
+	size := c.sizeOf(p)
+	if i := c.class(size); i >= 0 && c.sizes[i] == size {
+		c.lists[i].Put(p)
+	}
+}
+
+// Outstanding returns the objects taken and not returned, over all
+// classes.
+func (c *Classes[T]) Outstanding() int {
+	n := 0
+	for _, l := range c.lists {
+		n += l.Outstanding()
+	}
+	return n
+}
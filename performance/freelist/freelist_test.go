@@ -0,0 +1,277 @@
+package freelist
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReuse(t *testing.T) {
+	allocs := 0
+	l := New(func() *Task { allocs++; return new(Task) }, Options[Task]{
+		Max:   1,
+		Reset: func(t *Task) { *t = Task{} },
+	})
+	a := l.Get()
+	a.ID = 7
+	l.Put(a)
+	if b := l.Get(); b != a || b.ID != 0 {
+		t.Errorf("Get = %p (id %d), want the reset %p", b, b.ID, a)
+	}
+	b := l.Get()
+	l.Put(a)
+	l.Put(b) // over Max, dropped
+	if l.Len() != 1 || allocs != 2 {
+		t.Errorf("%d idle, %d allocations", l.Len(), allocs)
+	}
+}
+
+func TestClasses(t *testing.T) {
+	msgs := NewMessages(false)
+	for _, tc := range []struct{ n, cap int }{
+		{0, 256}, {256, 256}, {257, 1 << 10}, {64 << 10, 64 << 10}, {100 << 10, 100 << 10},
+	} {
+		m := msgs.Get(tc.n)
+		if cap(m.Payload) != tc.cap || len(m.Payload) != 0 {
+			t.Errorf("Get(%d): payload len %d cap %d, want cap %d", tc.n, len(m.Payload), cap(m.Payload), tc.cap)
+		}
+		msgs.Put(m)
+	}
+
+	// A payload that outgrew its class is not put back into it.
+	m := msgs.Get(10)
+	m.Payload = append(m.Payload, make([]byte, 300)...)
+	msgs.Put(m)
+	if got := msgs.Get(10); got == m {
+		t.Error("a grown message went back into the small class")
+	}
+}
+
+// violations collects what debug mode reports instead of panicking.
+type violations struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (v *violations) report(err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.errs = append(v.errs, err)
+}
+
+func debugMessages(v *violations) *Classes[Message] {
+	msgs := NewMessages(true)
+	for _, l := range msgs.lists {
+		l.opts.OnViolation = v.report
+	}
+	return msgs
+}
+
+func TestUseAfterPut(t *testing.T) {
+	for name, misuse := range map[string]func(m *Message, payload []byte){
+		"field":   func(m *Message, _ []byte) { m.Seq = 42 },
+		"payload": func(_ *Message, payload []byte) { payload[0] = 'x' },
+	} {
+		t.Run(name, func(t *testing.T) {
+			v := &violations{}
+			msgs := debugMessages(v)
+			m := msgs.Get(10)
+			m.Payload = append(m.Payload, "hello"...)
+			stale := m.Payload
+			msgs.Put(m)
+
+			misuse(m, stale)
+			got := msgs.Get(10)
+			if len(v.errs) != 1 || !errors.Is(v.errs[0], ErrUseAfterPut) {
+				t.Fatalf("violations %v", v.errs)
+			}
+			if got == m {
+				t.Error("the damaged object was handed out again")
+			}
+		})
+	}
+}
+
+func TestCleanReuseInDebugMode(t *testing.T) {
+	v := &violations{}
+	msgs := debugMessages(v)
+	m := msgs.Get(10)
+	m.Seq, m.Key = 1, "k"
+	m.Payload = append(m.Payload, "hello"...)
+	msgs.Put(m)
+	got := msgs.Get(10)
+	if got != m || len(v.errs) != 0 {
+		t.Fatalf("reuse: same %v, violations %v", got == m, v.errs)
+	}
+	if got.Seq != 0 || len(got.Payload) != 0 {
+		t.Errorf("reused message not reset: %+v", got)
+	}
+}
+
+func TestDoublePut(t *testing.T) {
+	v := &violations{}
+	tasks := NewTasks(true)
+	tasks.opts.OnViolation = v.report
+	task := tasks.Get()
+	tasks.Put(task)
+	tasks.Put(task)
+	if len(v.errs) != 1 || !errors.Is(v.errs[0], ErrDoublePut) {
+		t.Errorf("violations %v", v.errs)
+	}
+	if tasks.Len() != 1 || tasks.Outstanding() != 0 {
+		t.Errorf("%d idle, %d outstanding", tasks.Len(), tasks.Outstanding())
+	}
+}
+
+func TestDebugPanicsByDefault(t *testing.T) {
+	tasks := NewTasks(true)
+	task := tasks.Get()
+	tasks.Put(task)
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrDoublePut) {
+			t.Errorf("recovered %v", err)
+		}
+	}()
+	tasks.Put(task)
+}
+
+// pipeline passes messages through a stage and returns them at the end.
+// With leak set, it forgets to return every tenth.
+func pipeline(msgs *Classes[Message], n int, leak bool) {
+	in, out := make(chan *Message), make(chan *Message)
+	go func() {
+		defer close(out)
+		for m := range in {
+			m.Payload = append(m.Payload, byte(m.Seq))
+			out <- m
+		}
+	}()
+	go func() {
+		defer close(in)
+		for i := 0; i < n; i++ {
+			m := msgs.Get(64)
+			m.Seq = uint64(i)
+			in <- m
+		}
+	}()
+	for m := range out {
+		if leak && m.Seq%10 == 0 {
+			continue
+		}
+		msgs.Put(m)
+	}
+}
+
+func TestLeak(t *testing.T) {
+	v := &violations{}
+	msgs := debugMessages(v)
+	pipeline(msgs, 1000, false)
+	if n := msgs.Outstanding(); n != 0 || len(v.errs) != 0 {
+		t.Errorf("%d outstanding, violations %v", n, v.errs)
+	}
+	pipeline(msgs, 1000, true)
+	if n := msgs.Outstanding(); n != 100 {
+		t.Errorf("%d outstanding, want the 100 leaked", n)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	v := &violations{}
+	tasks := NewTasks(true)
+	tasks.opts.OnViolation = v.report
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				task := tasks.Get()
+				task.Fn = func(context.Context) error { return nil }
+				task.Err = task.Fn(context.Background())
+				tasks.Put(task)
+			}
+		}()
+	}
+	wg.Wait()
+	if tasks.Outstanding() != 0 || len(v.errs) != 0 {
+		t.Errorf("%d outstanding, violations %v", tasks.Outstanding(), v.errs)
+	}
+}
+
+var sink atomic.Pointer[Message]
+
+// fill uses m the way a pipeline stage would, and keeps it from being
+// allocated on the stack.
+func fill(m *Message, n int) {
+	m.Payload = m.Payload[:n]
+	m.Payload[0] = 1
+	sink.Store(m)
+}
+
+func BenchmarkMessages(b *testing.B) {
+	const size = 1 << 10
+	msgs := NewMessages(false)
+	pool := sync.Pool{New: func() any { return &Message{Payload: make([]byte, 0, size)} }}
+
+	b.Run("alloc", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fill(&Message{Payload: make([]byte, 0, size)}, size)
+		}
+	})
+	b.Run("freelist", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m := msgs.Get(size)
+			fill(m, size)
+			msgs.Put(m)
+		}
+	})
+	b.Run("syncpool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m := pool.Get().(*Message)
+			fill(m, size)
+			*m = Message{Payload: m.Payload[:0]}
+			pool.Put(m)
+		}
+	})
+}
+
+func BenchmarkMessagesParallel(b *testing.B) {
+	const size = 1 << 10
+	msgs := NewMessages(false)
+	pool := sync.Pool{New: func() any { return &Message{Payload: make([]byte, 0, size)} }}
+
+	b.Run("alloc", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				fill(&Message{Payload: make([]byte, 0, size)}, size)
+			}
+		})
+	})
+	b.Run("freelist", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				m := msgs.Get(size)
+				fill(m, size)
+				msgs.Put(m)
+			}
+		})
+	})
+	b.Run("syncpool", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				m := pool.Get().(*Message)
+				fill(m, size)
+				*m = Message{Payload: m.Payload[:0]}
+				pool.Put(m)
+			}
+		})
+	})
+}
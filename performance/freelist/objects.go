@@ -0,0 +1,64 @@
+package freelist
+
+import "context"
+
+// Message is a pipeline message, the kind of object a pipeline allocates
+// per item.
+type Message struct {
+	Seq     uint64
+	Key     string
+	Payload []byte
+}
+
+// poison is the byte freed payloads are filled with in debug mode.
+const poison = 0xDD
+
+// MessageSizes are the payload size classes of NewMessages.
+var MessageSizes = []int{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10}
+
+// NewMessages returns size-classed free lists of messages. Get(n) returns
+// a message with an empty payload of capacity n or more. In debug mode
+// freed payloads are filled with a poison byte, so writes through a stale
+// payload slice are caught too.
+func NewMessages(debug bool) *Classes[Message] {
+	return NewClasses(MessageSizes,
+		func(size int) *Message { return &Message{Payload: make([]byte, 0, size)} },
+		func(m *Message) int { return cap(m.Payload) },
+		Options[Message]{
+			Debug: debug,
+			Reset: func(m *Message) { *m = Message{Payload: m.Payload[:0]} },
+			Poison: func(m *Message) {
+				p := m.Payload[:cap(m.Payload)]
+				for i := range p {
+					p[i] = poison
+				}
+				*m = Message{Seq: ^uint64(0), Payload: p[:0]}
+			},
+			Poisoned: func(m *Message) bool {
+				if m.Seq != ^uint64(0) || m.Key != "" || len(m.Payload) != 0 {
+					return false
+				}
+				for _, b := range m.Payload[:cap(m.Payload)] {
+					if b != poison {
+						return false
+					}
+				}
+				return true
+			},
+		})
+}
+
+// Task wraps a function submitted to a worker pool with its result.
+type Task struct {
+	ID  uint64
+	Fn  func(context.Context) error
+	Err error
+}
+
+// NewTasks returns a free list of tasks.
+func NewTasks(debug bool) *List[Task] {
+	return New(func() *Task { return new(Task) }, Options[Task]{
+		Debug: debug,
+		Reset: func(t *Task) { *t = Task{} },
+	})
+}
@@ -0,0 +1,91 @@
+// Package pool shares a set of resources, such as database connections,
+// between goroutines. It is the resource pool of concurrency/pool, from
+// Go in Action, made a library.
+//
+// Idle resources wait in a buffered channel. Acquire takes one, or makes
+// a new one with the factory when none is idle, so a burst of callers is
+// never refused; Release puts it back, or closes it when size resources
+// are idle already, so the pool keeps no more than size of them between
+// bursts. Close closes the idle resources, and any released after it.
+//
+// The pool bounds what it keeps, not what is in use. To bound that too,
+// put a semaphore or a patterns/workerpool Pool in front of it.
+package pool
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	// ErrClosed is returned by Acquire on a closed pool.
+	ErrClosed = errors.New("pool: closed")
+	// ErrSize is returned by New for a size that is not positive.
+	ErrSize = errors.New("pool: size must be positive")
+)
+
+// Pool holds idle resources of type T.
+type Pool[T io.Closer] struct {
+	factory func() (T, error)
+
+	// mu keeps Release from sending on the channel Close closes.
+	mu     sync.Mutex
+	idle   chan T
+	closed bool
+}
+
+// New returns a pool keeping up to size idle resources, made by factory.
+func New[T io.Closer](factory func() (T, error), size int) (*Pool[T], error) {
+	if size <= 0 {
+		return nil, ErrSize
+	}
+	return &Pool[T]{factory: factory, idle: make(chan T, size)}, nil
+}
+
+// Acquire returns an idle resource, or a new one if none is idle.
+func (p *Pool[T]) Acquire() (T, error) {
+	select {
+	case r, ok := <-p.idle:
+		if !ok {
+			return r, ErrClosed
+		}
+		return r, nil
+	default:
+		return p.factory()
+	}
+}
+
+// Release returns r to the pool, or closes it if the pool is full or
+// closed.
+func (p *Pool[T]) Release(r T) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		r.Close()
+		return
+	}
+	select {
+	case p.idle <- r:
+	default:
+		r.Close()
+	}
+}
+
+// Idle returns the number of idle resources.
+func (p *Pool[T]) Idle() int { return len(p.idle) }
+
+// Close closes the pool and its idle resources. It is safe to call more
+// than once.
+func (p *Pool[T]) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.idle)
+	for r := range p.idle {
+		r.Close()
+	}
+}
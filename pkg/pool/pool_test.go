@@ -0,0 +1,153 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type conn struct {
+	id     int32
+	closed atomic.Bool
+}
+
+func (c *conn) Close() error {
+	if c.closed.Swap(true) {
+		return errors.New("closed twice")
+	}
+	return nil
+}
+
+// factory makes numbered conns and remembers them.
+type factory struct {
+	mu    sync.Mutex
+	conns []*conn
+}
+
+func (f *factory) new() (*conn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := &conn{id: int32(len(f.conns) + 1)}
+	f.conns = append(f.conns, c)
+	return c, nil
+}
+
+func (f *factory) closed() (n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		if c.closed.Load() {
+			n++
+		}
+	}
+	return n
+}
+
+func TestReuse(t *testing.T) {
+	var f factory
+	p, err := New(f.new, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := p.Acquire()
+	p.Release(a)
+	if b, _ := p.Acquire(); b != a {
+		t.Errorf("acquired conn %d, want the idle conn %d", b.id, a.id)
+	}
+	if len(f.conns) != 1 {
+		t.Errorf("made %d conns", len(f.conns))
+	}
+}
+
+func TestReleaseWhenFullCloses(t *testing.T) {
+	var f factory
+	p, _ := New(f.new, 2)
+	var held []*conn
+	for range 3 {
+		c, _ := p.Acquire()
+		held = append(held, c)
+	}
+	for _, c := range held {
+		p.Release(c)
+	}
+	if p.Idle() != 2 || !held[2].closed.Load() || f.closed() != 1 {
+		t.Errorf("%d idle, %d closed", p.Idle(), f.closed())
+	}
+}
+
+func TestClose(t *testing.T) {
+	var f factory
+	p, _ := New(f.new, 2)
+	a, _ := p.Acquire()
+	b, _ := p.Acquire()
+	p.Release(a)
+	p.Close()
+	p.Close()
+	if !a.closed.Load() {
+		t.Error("idle conn left open")
+	}
+	// A conn in use when the pool closed is closed on release.
+	p.Release(b)
+	if !b.closed.Load() {
+		t.Error("conn released after Close left open")
+	}
+	if _, err := p.Acquire(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Acquire after Close = %v", err)
+	}
+}
+
+func TestNewRejectsSize(t *testing.T) {
+	var f factory
+	if _, err := New(f.new, 0); !errors.Is(err, ErrSize) {
+		t.Errorf("New(0) = %v", err)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	var f factory
+	p, _ := New(f.new, 4)
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				c, err := p.Acquire()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				p.Release(c)
+			}
+		}()
+	}
+	wg.Wait()
+	p.Close()
+	// Every conn made is closed once, and none is closed while in use.
+	if n := f.closed(); n != len(f.conns) {
+		t.Errorf("%d of %d conns closed", n, len(f.conns))
+	}
+}
+
+func ExamplePool() {
+	var made int
+	p, _ := New(func() (*conn, error) {
+		made++
+		return &conn{id: int32(made)}, nil
+	}, 2)
+	defer p.Close()
+
+	for range 3 {
+		c, _ := p.Acquire()
+		fmt.Println("query on conn", c.id)
+		p.Release(c)
+	}
+	fmt.Println("made", made, "idle", p.Idle())
+	// Output:
+	// query on conn 1
+	// query on conn 1
+	// query on conn 1
+	// made 1 idle 1
+}
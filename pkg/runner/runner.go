@@ -0,0 +1,95 @@
+// Package runner runs a program's tasks in order within a time limit,
+// stopping early on an interrupt. It is the runner of concurrency/runner,
+// from Go in Action, made a library, for programs that run unattended,
+// such as a cron job, and have three ways to end: they finish in time,
+// they run out of time, or they are told to stop.
+//
+// The tasks run on a goroutine of their own while Start waits for them or
+// for the time limit. An interrupt, a call to Interrupt or one of the
+// signals given to NotifyOn, is checked between tasks, so the task running finishes first. A timeout
+// returns at once and leaves the running task behind, which is fine for a
+// program about to exit and is not for anything else; code that must not
+// leave work behind passes a context to its tasks instead.
+package runner
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"time"
+)
+
+var (
+	// ErrTimeout is returned by Start when the tasks run out of time.
+	ErrTimeout = errors.New("runner: timeout")
+	// ErrInterrupt is returned by Start when the runner is interrupted.
+	ErrInterrupt = errors.New("runner: interrupted")
+)
+
+// Runner runs tasks in order within a time limit.
+type Runner struct {
+	timeout   time.Duration
+	interrupt chan os.Signal
+	signals   []os.Signal
+	tasks     []func(id int)
+}
+
+// New returns a Runner allowing its tasks d to run.
+func New(d time.Duration) *Runner {
+	return &Runner{timeout: d, interrupt: make(chan os.Signal, 1)}
+}
+
+// Add appends tasks. Each is called with its position among the tasks.
+func (r *Runner) Add(tasks ...func(id int)) {
+	r.tasks = append(r.tasks, tasks...)
+}
+
+// NotifyOn makes the signals, typically os.Interrupt, interrupt the runner
+// while it runs. Signal handling belongs to a program's main, which is why
+// a Runner does not watch any signal unless told to.
+func (r *Runner) NotifyOn(sigs ...os.Signal) {
+	r.signals = append(r.signals, sigs...)
+}
+
+// Interrupt stops the runner before its next task.
+func (r *Runner) Interrupt() {
+	select {
+	case r.interrupt <- os.Interrupt:
+	default: // one is pending already
+	}
+}
+
+// Start runs the tasks and returns nil once all of them are done,
+// ErrTimeout if the time limit comes first, or ErrInterrupt if the runner
+// is interrupted before the last task. A Runner is started once.
+func (r *Runner) Start() error {
+	if len(r.signals) > 0 {
+		signal.Notify(r.interrupt, r.signals...)
+		defer signal.Stop(r.interrupt)
+	}
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+
+	complete := make(chan error, 1)
+	go func() { complete <- r.run() }()
+	select {
+	case err := <-complete:
+		return err
+	case <-timer.C:
+		// Stop the tasks left behind before their next one.
+		r.Interrupt()
+		return ErrTimeout
+	}
+}
+
+func (r *Runner) run() error {
+	for id, task := range r.tasks {
+		select {
+		case <-r.interrupt:
+			return ErrInterrupt
+		default:
+		}
+		task(id)
+	}
+	return nil
+}
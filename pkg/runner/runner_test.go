@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the ids of the tasks that ran.
+type recorder struct {
+	mu  sync.Mutex
+	ids []int
+}
+
+func (r *recorder) task(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, id)
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fmt.Sprint(r.ids)
+}
+
+func TestComplete(t *testing.T) {
+	var rec recorder
+	r := New(time.Minute)
+	r.Add(rec.task, rec.task)
+	r.Add(rec.task)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.String(); got != "[0 1 2]" {
+		t.Errorf("ran %s", got)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var rec recorder
+	release := make(chan struct{})
+	done := make(chan struct{})
+	r := New(10 * time.Millisecond)
+	r.Add(rec.task, func(int) { <-release }, func(id int) {
+		rec.task(id)
+		close(done)
+	})
+	if err := r.Start(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Start = %v", err)
+	}
+	// The slow task is left running, and the tasks after it do not start
+	// once it returns.
+	close(release)
+	select {
+	case <-done:
+		t.Error("a task ran after the timeout")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if got := rec.String(); got != "[0]" {
+		t.Errorf("ran %s", got)
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	var rec recorder
+	r := New(time.Minute)
+	r.Add(rec.task, func(id int) {
+		rec.task(id)
+		r.Interrupt()
+		r.Interrupt() // a second one does not block
+	}, rec.task)
+	if err := r.Start(); !errors.Is(err, ErrInterrupt) {
+		t.Fatalf("Start = %v", err)
+	}
+	// The task that was running when the interrupt came finished.
+	if got := rec.String(); got != "[0 1]" {
+		t.Errorf("ran %s", got)
+	}
+}
+
+func ExampleRunner() {
+	r := New(time.Second)
+	r.Add(
+		func(id int) { fmt.Println("task", id, "backs up the database") },
+		func(id int) { fmt.Println("task", id, "rotates the logs") },
+	)
+	switch err := r.Start(); {
+	case errors.Is(err, ErrTimeout):
+		fmt.Println("took too long")
+	case errors.Is(err, ErrInterrupt):
+		fmt.Println("stopped")
+	default:
+		fmt.Println("done")
+	}
+	// Output:
+	// task 0 backs up the database
+	// task 1 rotates the logs
+	// done
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package runner
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNotifyOn(t *testing.T) {
+	var rec recorder
+	r := New(time.Minute)
+	r.NotifyOn(syscall.SIGUSR1)
+	r.Add(rec.task, func(id int) {
+		rec.task(id)
+		syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+		// Wait for the signal to be delivered.
+		time.Sleep(50 * time.Millisecond)
+	}, rec.task)
+	if err := r.Start(); !errors.Is(err, ErrInterrupt) {
+		t.Fatalf("Start = %v", err)
+	}
+	if got := rec.String(); got != "[0 1]" {
+		t.Errorf("ran %s", got)
+	}
+}
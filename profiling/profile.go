@@ -18,8 +18,8 @@ func BigIntFactorial(x big.Int) *big.Int {
 	defer Duration(time.Now(), "IntFactorial")
 
 	y := big.NewInt(1)
-	for one := big.NewInt(1); x.Sign() > 0; x.Sub(x, one) {
-		y.Mul(y, x)
+	for one := big.NewInt(1); x.Sign() > 0; x.Sub(&x, one) {
+		y.Mul(y, &x)
 	}
 
 	return x.Set(y)
@@ -0,0 +1,245 @@
+// Package circuitbreaker implements the circuit breaker stability pattern
+// described in stability/circuit-breaker.md.
+//
+// A Breaker starts closed and passes calls through. After a number of
+// consecutive failures it opens and fails every call fast with ErrOpen.
+// Once the reset timeout has passed it lets a limited number of trial calls
+// through (half-open): if they all succeed the breaker closes again, and the
+// first failure opens it for another timeout.
+//
+// Only the outcome of a call admitted in the current state counts: a slow
+// call let through while closed that returns once the breaker is
+// half-open says nothing about the trials. A call cancelled by its caller
+// says nothing either way and is not counted, and a call that panics is a
+// failure.
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+var (
+	// ErrOpen is returned without calling the circuit while it is open.
+	ErrOpen = errors.New("circuitbreaker: circuit is open")
+	// ErrTooManyRequests is returned while half-open when the trial calls
+	// are already taken.
+	ErrTooManyRequests = errors.New("circuitbreaker: too many requests while half-open")
+)
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Circuit is the operation protected by a Breaker.
+type Circuit func(ctx context.Context) error
+
+// Settings configures a Breaker.
+type Settings struct {
+	// FailureThreshold is the number of consecutive failures that opens
+	// the breaker.
+	FailureThreshold uint32
+	// ResetTimeout is how long the breaker stays open before trying again.
+	ResetTimeout time.Duration
+	// HalfOpenCalls is the number of trial calls let through while
+	// half-open; all of them must succeed to close the breaker.
+	HalfOpenCalls uint32
+	// IsFailure decides which errors count against the circuit. By default
+	// every error does. context.Canceled is never asked about: a cancelled
+	// call is no result.
+	IsFailure func(err error) bool
+	// OnStateChange is called, with the breaker locked, on every transition.
+	OnStateChange func(from, to State)
+}
+
+// Breaker guards a circuit.
+type Breaker struct {
+	settings Settings
+	clock    clock.Clock
+
+	mu        sync.Mutex
+	state     State
+	failures  uint32
+	openedAt  time.Time
+	trials    uint32 // trial calls admitted while half-open
+	successes uint32 // trial calls that succeeded
+	// gen counts the transitions, so that the outcome of a call admitted
+	// before one is told apart.
+	gen uint64
+}
+
+// outcome is what a call tells about the circuit.
+type outcome int
+
+const (
+	noResult outcome = iota
+	succeeded
+	failed
+)
+
+// New creates a closed breaker. Zero settings get sensible defaults.
+func New(s Settings) *Breaker {
+	if s.FailureThreshold == 0 {
+		s.FailureThreshold = 5
+	}
+	if s.ResetTimeout == 0 {
+		s.ResetTimeout = 30 * time.Second
+	}
+	if s.HalfOpenCalls == 0 {
+		s.HalfOpenCalls = 1
+	}
+	if s.IsFailure == nil {
+		s.IsFailure = func(err error) bool { return err != nil }
+	}
+	return &Breaker{settings: s, clock: clock.Real}
+}
+
+// SetClock times the reset timeout on c instead of the wall clock, so
+// that a simulation can open the breaker and skip past the timeout.
+func (b *Breaker) SetClock(c clock.Clock) {
+	b.mu.Lock()
+	b.clock = c
+	b.mu.Unlock()
+}
+
+// State returns the current state, moving from open to half-open if the
+// reset timeout has passed.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tick()
+	return b.state
+}
+
+// Do calls c unless the breaker rejects the call, and records the outcome.
+// A panic in c is recorded as a failure and goes on up.
+func (b *Breaker) Do(ctx context.Context, c Circuit) error {
+	gen, err := b.admit()
+	if err != nil {
+		return err
+	}
+	returned := false
+	defer func() {
+		if !returned {
+			b.record(gen, failed)
+		}
+	}()
+	err = c(ctx)
+	returned = true
+	b.record(gen, b.outcome(err))
+	return err
+}
+
+// Wrap returns c guarded by the breaker.
+func (b *Breaker) Wrap(c Circuit) Circuit {
+	return func(ctx context.Context) error {
+		return b.Do(ctx, c)
+	}
+}
+
+// admit lets a call through or rejects it, and returns the generation it
+// is admitted in.
+func (b *Breaker) admit() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tick()
+	switch b.state {
+	case Open:
+		return 0, ErrOpen
+	case HalfOpen:
+		if b.trials >= b.settings.HalfOpenCalls {
+			return 0, ErrTooManyRequests
+		}
+		b.trials++
+	}
+	return b.gen, nil
+}
+
+func (b *Breaker) outcome(err error) outcome {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return noResult
+	case b.settings.IsFailure(err):
+		return failed
+	}
+	return succeeded
+}
+
+// record counts the outcome of a call admitted in generation gen.
+func (b *Breaker) record(gen uint64, o outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if gen != b.gen {
+		// Admitted in an earlier state.
+		return
+	}
+	if o == noResult {
+		if b.state == HalfOpen {
+			// Give the trial slot back to another call.
+			b.trials--
+		}
+		return
+	}
+	switch b.state {
+	case Closed:
+		if o == succeeded {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			b.setState(Open)
+		}
+	case HalfOpen:
+		if o == failed {
+			b.setState(Open)
+			return
+		}
+		b.successes++
+		if b.successes >= b.settings.HalfOpenCalls {
+			b.setState(Closed)
+		}
+	}
+}
+
+// tick moves an open breaker to half-open once its timeout has passed.
+func (b *Breaker) tick() {
+	if b.state == Open && b.clock.Now().Sub(b.openedAt) >= b.settings.ResetTimeout {
+		b.setState(HalfOpen)
+	}
+}
+
+func (b *Breaker) setState(to State) {
+	from := b.state
+	b.state = to
+	b.failures, b.trials, b.successes = 0, 0, 0
+	b.gen++
+	if to == Open {
+		b.openedAt = b.clock.Now()
+	}
+	if b.settings.OnStateChange != nil && from != to {
+		b.settings.OnStateChange(from, to)
+	}
+}
@@ -0,0 +1,320 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/internal/chaos"
+)
+
+var errBoom = errors.New("boom")
+
+func fail(context.Context) error    { return errBoom }
+func succeed(context.Context) error { return nil }
+
+func newBreaker(c *clock.Fake, transitions *[]string) *Breaker {
+	b := New(Settings{
+		FailureThreshold: 3,
+		ResetTimeout:     time.Second,
+		HalfOpenCalls:    2,
+		OnStateChange: func(from, to State) {
+			*transitions = append(*transitions, fmt.Sprintf("%s->%s", from, to))
+		},
+	})
+	b.SetClock(c)
+	return b
+}
+
+func TestOpensAfterThreshold(t *testing.T) {
+	var tr []string
+	c := clock.NewFake(time.Unix(0, 0))
+	b := newBreaker(c, &tr)
+	ctx := context.Background()
+
+	b.Do(ctx, fail)
+	b.Do(ctx, fail)
+	b.Do(ctx, succeed) // resets the consecutive count
+	b.Do(ctx, fail)
+	b.Do(ctx, fail)
+	if b.State() != Closed {
+		t.Fatalf("breaker opened before 3 consecutive failures")
+	}
+	b.Do(ctx, fail)
+	if b.State() != Open {
+		t.Fatalf("breaker should be open, is %s", b.State())
+	}
+
+	var called bool
+	if err := b.Do(ctx, func(context.Context) error { called = true; return nil }); err != ErrOpen || called {
+		t.Errorf("open breaker must fail fast, got %v (called %v)", err, called)
+	}
+}
+
+func TestHalfOpenCloses(t *testing.T) {
+	var tr []string
+	c := clock.NewFake(time.Unix(0, 0))
+	b := newBreaker(c, &tr)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		b.Do(ctx, fail)
+	}
+
+	c.Advance(time.Second)
+	if b.State() != HalfOpen {
+		t.Fatalf("expected half-open after the reset timeout, got %s", b.State())
+	}
+	b.Do(ctx, succeed)
+	b.Do(ctx, succeed)
+	if b.State() != Closed {
+		t.Errorf("expected closed after 2 successful trials, got %s", b.State())
+	}
+	want := "[closed->open open->half-open half-open->closed]"
+	if got := fmt.Sprint(tr); got != want {
+		t.Errorf("transitions %s, want %s", got, want)
+	}
+}
+
+func TestHalfOpenFailureReopens(t *testing.T) {
+	var tr []string
+	c := clock.NewFake(time.Unix(0, 0))
+	b := newBreaker(c, &tr)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		b.Do(ctx, fail)
+	}
+	c.Advance(time.Second)
+	b.Do(ctx, fail)
+	if b.State() != Open {
+		t.Errorf("a failed trial must reopen the breaker, got %s", b.State())
+	}
+	c.Advance(999 * time.Millisecond)
+	if b.State() != Open {
+		t.Errorf("the reset timeout restarts when reopening")
+	}
+}
+
+func TestHalfOpenLimitsTrials(t *testing.T) {
+	var tr []string
+	c := clock.NewFake(time.Unix(0, 0))
+	b := newBreaker(c, &tr)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		b.Do(ctx, fail)
+	}
+	c.Advance(time.Second)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	slow := func(context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	go b.Do(ctx, slow)
+	go b.Do(ctx, slow)
+	<-started
+	<-started
+	if err := b.Do(ctx, succeed); err != ErrTooManyRequests {
+		t.Errorf("expected ErrTooManyRequests, got %v", err)
+	}
+	close(release)
+}
+
+// Under failures at a random rate the breaker opens only after
+// FailureThreshold failures in a row, and while it is open the circuit
+// is not called.
+func TestChaos(t *testing.T) {
+	var tr []string
+	c := clock.NewFake(time.Unix(0, 0))
+	b := newBreaker(c, &tr)
+	m := chaos.New(chaos.Config{Seed: 9, ErrorRate: 0.5})
+	circuit := m.Wrap(succeed)
+	var streak, opened int
+	for i := range 2000 {
+		before, transitions := m.Stats().Calls, len(tr)
+		err := b.Do(context.Background(), circuit)
+		called := m.Stats().Calls > before
+		switch {
+		case err == ErrOpen || err == ErrTooManyRequests:
+			if called {
+				t.Fatalf("call %d: circuit called, then %v", i, err)
+			}
+		case err != nil:
+			streak++
+		default:
+			streak = 0
+		}
+		if len(tr) > transitions && tr[len(tr)-1] == "closed->open" {
+			if streak < 3 {
+				t.Fatalf("call %d: opened after %d failures in a row", i, streak)
+			}
+			opened++
+		}
+		if b.State() != Closed {
+			streak = 0
+		}
+		c.Advance(100 * time.Millisecond)
+	}
+	if opened == 0 {
+		t.Error("never opened")
+	}
+}
+
+func TestCancellationIsNotAFailure(t *testing.T) {
+	b := New(Settings{FailureThreshold: 1})
+	b.Do(context.Background(), func(context.Context) error { return context.Canceled })
+	if b.State() != Closed {
+		t.Error("a cancelled call should not open the breaker")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{Breaker: New(Settings{FailureThreshold: 2, ResetTimeout: time.Minute})}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("the 5xx response should reach the caller, got %d", resp.StatusCode)
+		}
+	}
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrOpen) {
+		t.Errorf("expected ErrOpen, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("server was called %d times, want 2", calls)
+	}
+}
+
+// ExampleTransport wraps an http.Client talking to a flaky backend. After
+// two failures the client stops hammering it and fails fast until the
+// reset timeout has passed.
+func ExampleTransport() {
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	breaker := New(Settings{FailureThreshold: 2, ResetTimeout: 50 * time.Millisecond})
+	client := &http.Client{Transport: &Transport{Breaker: breaker}}
+
+	get := func() {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			fmt.Println("error:", errors.Unwrap(err))
+			return
+		}
+		resp.Body.Close()
+		fmt.Println(resp.Status)
+	}
+
+	get()
+	get()
+	get()
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	get()
+	fmt.Println(breaker.State())
+	// Output:
+	// 503 Service Unavailable
+	// 503 Service Unavailable
+	// error: circuitbreaker: circuit is open
+	// 200 OK
+	// closed
+}
+
+// halfOpen trips b, built by newBreaker, and moves c past the reset
+// timeout.
+func halfOpen(t *testing.T, b *Breaker, c *clock.Fake) {
+	t.Helper()
+	for range 3 {
+		b.Do(context.Background(), fail)
+	}
+	c.Advance(time.Second)
+	if b.State() != HalfOpen {
+		t.Fatalf("state %s, want half-open", b.State())
+	}
+}
+
+func TestPanickingTrialReopens(t *testing.T) {
+	var tr []string
+	c := clock.NewFake(time.Unix(0, 0))
+	b := newBreaker(c, &tr)
+	halfOpen(t, b, c)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the panic was swallowed")
+			}
+		}()
+		b.Do(context.Background(), func(context.Context) error { panic("bug") })
+	}()
+	if b.State() != Open {
+		t.Errorf("a panicking trial left the breaker %s", b.State())
+	}
+}
+
+// A call admitted while closed that returns once the breaker is half-open
+// is not taken for a trial.
+func TestLateResultIgnored(t *testing.T) {
+	var tr []string
+	c := clock.NewFake(time.Unix(0, 0))
+	b := newBreaker(c, &tr)
+	started, release, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Do(context.Background(), func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	halfOpen(t, b, c)
+	close(release)
+	<-done
+	b.Do(context.Background(), succeed)
+	if b.State() != HalfOpen {
+		t.Errorf("one trial and a late success left the breaker %s", b.State())
+	}
+	b.Do(context.Background(), succeed)
+	if b.State() != Closed {
+		t.Errorf("two trials left the breaker %s", b.State())
+	}
+}
+
+func TestCancelledTrialIsNoResult(t *testing.T) {
+	var tr []string
+	c := clock.NewFake(time.Unix(0, 0))
+	b := newBreaker(c, &tr)
+	halfOpen(t, b, c)
+	for range 3 {
+		if err := b.Do(context.Background(), func(context.Context) error { return context.Canceled }); err != context.Canceled {
+			t.Fatalf("Do = %v; the trial slot was not given back", err)
+		}
+	}
+	if b.State() != HalfOpen {
+		t.Errorf("cancelled trials left the breaker %s", b.State())
+	}
+}
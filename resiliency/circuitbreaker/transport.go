@@ -0,0 +1,48 @@
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// StatusError reports a response the Transport counted as a failure.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("circuitbreaker: server answered %d", e.StatusCode)
+}
+
+// Transport is an http.RoundTripper that sends requests through a Breaker.
+// Transport errors and 5xx responses count as failures; the 5xx response
+// itself is still returned to the caller.
+type Transport struct {
+	Base    http.RoundTripper
+	Breaker *Breaker
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	var resp *http.Response
+	err := t.Breaker.Do(r.Context(), func(ctx context.Context) error {
+		var err error
+		resp, err = base.RoundTrip(r)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 500 {
+			return &StatusError{StatusCode: resp.StatusCode}
+		}
+		return nil
+	})
+	if _, ok := err.(*StatusError); ok {
+		return resp, nil
+	}
+	return resp, err
+}
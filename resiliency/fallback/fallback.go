@@ -0,0 +1,88 @@
+// Package fallback provides function combinators for calls that may be slow
+// or fail. WithTimeout bounds how long a call may take, WithFallback
+// answers from a second source when the first one fails.
+//
+// Both work on Func, which has the shape retry.DoValue expects, so the
+// combinators nest with retry and with a circuit breaker in any order:
+//
+//	call := fallback.WithFallback(
+//		func(ctx context.Context) (Quote, error) {
+//			return retry.DoValue(ctx, policy, fallback.WithTimeout(remote, 100*time.Millisecond))
+//		},
+//		fromCache,
+//	)
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// ErrTimeout is returned by a call wrapped with WithTimeout that did not
+// finish in time. It deliberately does not match context.DeadlineExceeded,
+// which callers such as retry.Do treat as the end of their own budget.
+var ErrTimeout = errors.New("fallback: call timed out")
+
+// Func is a call that returns a value.
+type Func[T any] func(ctx context.Context) (T, error)
+
+// WithTimeout returns fn bounded to d. fn gets a context that is cancelled
+// after d; if fn does not return by then, the wrapper returns ErrTimeout
+// without waiting for it. A panic in fn is returned as a
+// *recovery.PanicError.
+func WithTimeout[T any](fn Func[T], d time.Duration) Func[T] {
+	type result struct {
+		v   T
+		err error
+	}
+	return func(parent context.Context) (T, error) {
+		ctx, cancel := context.WithTimeout(parent, d)
+		defer cancel()
+
+		done := make(chan result, 1)
+		go func() {
+			var r result
+			r.err = recovery.Do(func() error {
+				var err error
+				r.v, err = fn(ctx)
+				return err
+			})
+			done <- r
+		}()
+
+		var zero T
+		select {
+		case r := <-done:
+			if r.err != nil && parent.Err() == nil && ctx.Err() == context.DeadlineExceeded {
+				return r.v, ErrTimeout
+			}
+			return r.v, r.err
+		case <-ctx.Done():
+			if err := parent.Err(); err != nil {
+				return zero, err
+			}
+			return zero, ErrTimeout
+		}
+	}
+}
+
+// WithFallback returns a Func that calls primary and, if it fails, calls
+// secondary. Nothing falls back once ctx itself is done. When both fail
+// the returned error wraps both errors.
+func WithFallback[T any](primary, secondary Func[T]) Func[T] {
+	return func(ctx context.Context) (T, error) {
+		v, err := primary(ctx)
+		if err == nil || ctx.Err() != nil {
+			return v, err
+		}
+		v, err2 := secondary(ctx)
+		if err2 != nil {
+			return v, fmt.Errorf("fallback: primary: %w; secondary: %w", err, err2)
+		}
+		return v, nil
+	}
+}
@@ -0,0 +1,141 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+	"github.com/crazybber/go-patterns/resiliency/circuitbreaker"
+	"github.com/crazybber/go-patterns/resiliency/retry"
+)
+
+var errRemote = errors.New("remote unavailable")
+
+func value(v string) Func[string] {
+	return func(context.Context) (string, error) { return v, nil }
+}
+
+func failure(err error) Func[string] {
+	return func(context.Context) (string, error) { return "", err }
+}
+
+// hang ignores its context, as badly behaved calls do.
+func hang(release <-chan struct{}) Func[string] {
+	return func(context.Context) (string, error) {
+		<-release
+		return "late", nil
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	start := time.Now()
+	_, err := WithTimeout(hang(release), 20*time.Millisecond)(context.Background())
+	if err != ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("WithTimeout waited for a call that ignores its context")
+	}
+
+	if v, err := WithTimeout(value("ok"), time.Second)(context.Background()); v != "ok" || err != nil {
+		t.Errorf("got %q, %v", v, err)
+	}
+}
+
+func TestWithTimeoutCallHonoringContext(t *testing.T) {
+	fn := func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	if _, err := WithTimeout(fn, 10*time.Millisecond)(context.Background()); err != ErrTimeout {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := WithTimeout(fn, time.Second)(ctx); err != context.Canceled {
+		t.Errorf("the caller's cancellation should pass through, got %v", err)
+	}
+}
+
+func TestWithTimeoutPanic(t *testing.T) {
+	fn := func(context.Context) (string, error) { panic("bug") }
+	_, err := WithTimeout(fn, time.Second)(context.Background())
+	var p *recovery.PanicError
+	if !errors.As(err, &p) {
+		t.Errorf("expected a PanicError, got %v", err)
+	}
+}
+
+func TestWithFallback(t *testing.T) {
+	if v, _ := WithFallback(value("primary"), value("secondary"))(context.Background()); v != "primary" {
+		t.Errorf("got %q", v)
+	}
+	if v, err := WithFallback(failure(errRemote), value("secondary"))(context.Background()); v != "secondary" || err != nil {
+		t.Errorf("got %q, %v", v, err)
+	}
+
+	errCache := errors.New("cache miss")
+	_, err := WithFallback(failure(errRemote), failure(errCache))(context.Background())
+	if !errors.Is(err, errRemote) || !errors.Is(err, errCache) {
+		t.Errorf("both errors should be wrapped, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	secondary := func(context.Context) (string, error) { called = true; return "", nil }
+	WithFallback(failure(context.Canceled), secondary)(ctx)
+	if called {
+		t.Error("fell back although the caller gave up")
+	}
+}
+
+// Example chains a timeout, retries and a circuit breaker around a slow
+// remote call and falls back to a cached value when all of it fails.
+func Example() {
+	release := make(chan struct{})
+	defer close(release)
+
+	var calls atomic.Int32
+	remote := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		<-release // the remote never answers in time
+		return "fresh quote", nil
+	}
+	cached := func(context.Context) (string, error) { return "cached quote", nil }
+
+	breaker := circuitbreaker.New(circuitbreaker.Settings{FailureThreshold: 3, ResetTimeout: time.Minute})
+	policy := retry.Policy{Backoff: retry.Constant(time.Millisecond), MaxAttempts: 2}
+
+	guarded := func(ctx context.Context) (string, error) {
+		var quote string
+		err := breaker.Do(ctx, func(ctx context.Context) error {
+			var err error
+			quote, err = retry.DoValue(ctx, policy, WithTimeout(remote, 5*time.Millisecond))
+			return err
+		})
+		return quote, err
+	}
+	get := WithFallback(guarded, cached)
+
+	for i := 0; i < 5; i++ {
+		quote, err := get(context.Background())
+		fmt.Println(quote, err)
+	}
+	fmt.Println("remote calls:", calls.Load(), "breaker:", breaker.State())
+	// Output:
+	// cached quote <nil>
+	// cached quote <nil>
+	// cached quote <nil>
+	// cached quote <nil>
+	// cached quote <nil>
+	// remote calls: 6 breaker: open
+}
@@ -0,0 +1,94 @@
+// Package hedging sends hedged requests. The call is started once; if it
+// has not returned after a delay, a second copy is started next to it, and
+// possibly a third, and the first answer wins. The losers are cancelled
+// through their context.
+//
+// Most calls finish before the delay and cost nothing extra. The slow tail,
+// a GC pause or a busy replica, is cut off at roughly the delay plus a
+// typical latency, which is where hedging pays: choose the delay near the
+// 95th percentile and the extra load stays around five percent.
+package hedging
+
+import (
+	"context"
+	"time"
+
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// Policy describes when to hedge.
+type Policy struct {
+	// Delay is the wait before each further attempt.
+	Delay time.Duration
+	// MaxAttempts bounds the number of attempts, including the first.
+	// Defaults to 2.
+	MaxAttempts int
+}
+
+type result[T any] struct {
+	v   T
+	err error
+}
+
+// Do calls fn and hedges it according to p. It returns the first
+// successful result. An attempt that fails makes room for the next one
+// right away instead of after the delay; if every attempt fails, the error
+// of the last one is returned. fn must honour its context, which is
+// cancelled as soon as Do returns. A panic in fn counts as a failed
+// attempt with a *recovery.PanicError.
+func Do[T any](ctx context.Context, p Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 2
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so that losers finishing after Do returned do not block.
+	results := make(chan result[T], attempts)
+	launch := func() {
+		go func() {
+			var r result[T]
+			r.err = recovery.Do(func() error {
+				var err error
+				r.v, err = fn(ctx)
+				return err
+			})
+			results <- r
+		}()
+	}
+
+	launch()
+	launched, failed := 1, 0
+	timer := time.NewTimer(p.Delay)
+	defer timer.Stop()
+
+	var zero T
+	for {
+		var hedge <-chan time.Time
+		if launched < attempts {
+			hedge = timer.C
+		}
+		select {
+		case r := <-results:
+			if r.err == nil {
+				return r.v, nil
+			}
+			failed++
+			if failed == attempts {
+				return zero, r.err
+			}
+			if failed == launched {
+				launch()
+				launched++
+				timer.Reset(p.Delay)
+			}
+		case <-hedge:
+			launch()
+			launched++
+			timer.Reset(p.Delay)
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package hedging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// replica answers after the latency given for each attempt, in order.
+func replica(calls *int32, cancelled *int32, latencies ...time.Duration) func(context.Context) (int, error) {
+	return func(ctx context.Context) (int, error) {
+		n := atomic.AddInt32(calls, 1)
+		select {
+		case <-time.After(latencies[n-1]):
+			return int(n), nil
+		case <-ctx.Done():
+			atomic.AddInt32(cancelled, 1)
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func TestFastCallIsNotHedged(t *testing.T) {
+	var calls, cancelled int32
+	v, err := Do(context.Background(), Policy{Delay: 50 * time.Millisecond},
+		replica(&calls, &cancelled, time.Millisecond, time.Millisecond))
+	if v != 1 || err != nil || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("got %d, %v after %d calls", v, err, calls)
+	}
+}
+
+func TestHedgeWins(t *testing.T) {
+	var calls, cancelled int32
+	start := time.Now()
+	v, err := Do(context.Background(), Policy{Delay: 10 * time.Millisecond, MaxAttempts: 3},
+		replica(&calls, &cancelled, time.Second, 5*time.Millisecond, time.Second))
+	if err != nil || v != 2 {
+		t.Fatalf("expected the second attempt to win, got %d, %v", v, err)
+	}
+	if took := time.Since(start); took > 500*time.Millisecond {
+		t.Errorf("hedged call took %v", took)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("%d attempts started, want 2", n)
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&cancelled) != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&cancelled) != 1 {
+		t.Error("the losing attempt was not cancelled")
+	}
+}
+
+func TestFailureHedgesImmediately(t *testing.T) {
+	errReplica := errors.New("replica down")
+	var calls int32
+	fn := func(ctx context.Context) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return 0, errReplica
+		}
+		return 2, nil
+	}
+	start := time.Now()
+	v, err := Do(context.Background(), Policy{Delay: time.Hour}, fn)
+	if v != 2 || err != nil || time.Since(start) > time.Second {
+		t.Errorf("got %d, %v", v, err)
+	}
+
+	calls = 0
+	all := func(context.Context) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		return 0, errReplica
+	}
+	if _, err := Do(context.Background(), Policy{Delay: time.Hour, MaxAttempts: 3}, all); err != errReplica || calls != 3 {
+		t.Errorf("got %v after %d calls", err, calls)
+	}
+}
+
+func TestCallerCancels(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var calls, cancelled int32
+	_, err := Do(ctx, Policy{Delay: time.Millisecond, MaxAttempts: 3},
+		replica(&calls, &cancelled, time.Hour, time.Hour, time.Hour))
+	if err != context.DeadlineExceeded {
+		t.Errorf("got %v", err)
+	}
+}
+
+// benchLatency draws from a long-tailed distribution: most calls take
+// 1ms, one in twenty takes 30ms.
+func benchLatency(rnd *rand.Rand) time.Duration {
+	if rnd.Intn(20) == 0 {
+		return 30 * time.Millisecond
+	}
+	return time.Millisecond
+}
+
+func benchmarkLatency(b *testing.B, p Policy) {
+	rnd := rand.New(rand.NewSource(1))
+	lock := make(chan struct{}, 1) // guards rnd
+	fn := func(ctx context.Context) (struct{}, error) {
+		lock <- struct{}{}
+		d := benchLatency(rnd)
+		<-lock
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+		}
+		return struct{}{}, ctx.Err()
+	}
+
+	took := make([]time.Duration, 0, b.N)
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		Do(context.Background(), p, fn)
+		took = append(took, time.Since(start))
+	}
+	sort.Slice(took, func(i, j int) bool { return took[i] < took[j] })
+	pct := func(q float64) float64 {
+		return float64(took[int(q*float64(len(took)-1))]) / float64(time.Millisecond)
+	}
+	b.ReportMetric(pct(0.50), "p50-ms")
+	b.ReportMetric(pct(0.99), "p99-ms")
+}
+
+// BenchmarkLatency compares the latency distribution of plain and hedged
+// calls. Hedging after 3ms cuts the 99th percentile from about 30ms to
+// well under 10ms while leaving the median alone.
+func BenchmarkLatency(b *testing.B) {
+	b.Run("single", func(b *testing.B) { benchmarkLatency(b, Policy{MaxAttempts: 1}) })
+	b.Run("hedged", func(b *testing.B) { benchmarkLatency(b, Policy{Delay: 3 * time.Millisecond, MaxAttempts: 3}) })
+}
+
+func ExampleDo() {
+	replicas := []time.Duration{time.Second, time.Millisecond}
+	var next int32
+	v, err := Do(context.Background(), Policy{Delay: 10 * time.Millisecond},
+		func(ctx context.Context) (string, error) {
+			i := atomic.AddInt32(&next, 1) - 1
+			select {
+			case <-time.After(replicas[i]):
+				return fmt.Sprintf("replica %d", i), nil
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		})
+	fmt.Println(v, err)
+	// Output: replica 1 <nil>
+}
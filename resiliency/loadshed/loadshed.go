@@ -0,0 +1,147 @@
+// Package loadshed implements admission control. A Controller turns work
+// away before it starts when the number of requests in flight or the
+// recently measured latency is above a limit, so a service that is already
+// behind spends its capacity on the requests it can still serve in time
+// instead of queueing everything and serving nobody in time.
+//
+// Latency is an exponentially weighted moving average of the time admitted
+// work took. While it is above the limit only one request at a time is let
+// through; those requests keep measuring, and once they are fast again the
+// average falls and normal admission resumes.
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrShed is returned for work that was not admitted.
+var ErrShed = errors.New("loadshed: overloaded, request shed")
+
+// Options configures a Controller. A zero limit disables that check.
+type Options struct {
+	// MaxInFlight is the number of admitted requests that may run at once.
+	MaxInFlight int
+	// MaxLatency is the average latency above which requests are shed.
+	MaxLatency time.Duration
+	// Weight is the weight of a new sample in the moving average, between
+	// 0 and 1. Defaults to 0.2.
+	Weight float64
+	// RetryAfter is the hint sent with a shed HTTP request. Defaults to
+	// one second.
+	RetryAfter time.Duration
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Stats counts the decisions of a Controller.
+type Stats struct {
+	Admitted int64
+	Shed     int64
+	InFlight int
+	Latency  time.Duration
+}
+
+// Controller decides which work to admit. It is safe for concurrent use.
+type Controller struct {
+	opts Options
+
+	mu       sync.Mutex
+	inFlight int
+	latency  float64 // moving average in nanoseconds
+	admitted int64
+	shed     int64
+}
+
+// New returns a Controller.
+func New(opts Options) *Controller {
+	if opts.Weight <= 0 || opts.Weight > 1 {
+		opts.Weight = 0.2
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = time.Second
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Controller{opts: opts}
+}
+
+// Admit asks for admission. If the work is admitted, done must be called
+// once it has finished; otherwise err is ErrShed.
+func (c *Controller) Admit() (done func(), err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.overloaded() {
+		c.shed++
+		return nil, ErrShed
+	}
+	c.inFlight++
+	c.admitted++
+	start := c.opts.Now()
+	var once sync.Once
+	return func() { once.Do(func() { c.finish(start) }) }, nil
+}
+
+func (c *Controller) overloaded() bool {
+	if c.opts.MaxInFlight > 0 && c.inFlight >= c.opts.MaxInFlight {
+		return true
+	}
+	// Too slow: let a single probe through so the average can recover.
+	return c.opts.MaxLatency > 0 && c.latency > float64(c.opts.MaxLatency) && c.inFlight > 0
+}
+
+func (c *Controller) finish(start time.Time) {
+	took := float64(c.opts.Now().Sub(start))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight--
+	if c.latency == 0 {
+		c.latency = took
+	} else {
+		c.latency += c.opts.Weight * (took - c.latency)
+	}
+}
+
+// Do runs fn if it is admitted and returns its error, or ErrShed.
+func (c *Controller) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	done, err := c.Admit()
+	if err != nil {
+		return err
+	}
+	defer done()
+	return fn(ctx)
+}
+
+// Stats returns the current counters.
+func (c *Controller) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Admitted: c.admitted,
+		Shed:     c.shed,
+		InFlight: c.inFlight,
+		Latency:  time.Duration(c.latency),
+	}
+}
+
+// Middleware sheds HTTP requests with 503 Service Unavailable and a
+// Retry-After header.
+func (c *Controller) Middleware(next http.Handler) http.Handler {
+	retryAfter := strconv.Itoa(int((c.opts.RetryAfter + time.Second - 1) / time.Second))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done, err := c.Admit()
+		if err != nil {
+			w.Header().Set("Retry-After", retryAfter)
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		defer done()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,139 @@
+package loadshed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func TestMaxInFlight(t *testing.T) {
+	c := New(Options{MaxInFlight: 2})
+	d1, err1 := c.Admit()
+	d2, err2 := c.Admit()
+	if err1 != nil || err2 != nil {
+		t.Fatalf("first two should be admitted: %v, %v", err1, err2)
+	}
+	if _, err := c.Admit(); err != ErrShed {
+		t.Errorf("third should be shed, got %v", err)
+	}
+	d1()
+	d1() // done is idempotent
+	if _, err := c.Admit(); err != nil {
+		t.Errorf("a slot was freed, got %v", err)
+	}
+	d2()
+	if s := c.Stats(); s.Admitted != 3 || s.Shed != 1 || s.InFlight != 1 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestMaxLatency(t *testing.T) {
+	clk := &clock{now: time.Unix(0, 0)}
+	c := New(Options{MaxLatency: 100 * time.Millisecond, Weight: 0.5, Now: clk.Now})
+
+	run := func(took time.Duration) {
+		done, err := c.Admit()
+		if err != nil {
+			t.Fatalf("expected admission, got %v", err)
+		}
+		clk.now = clk.now.Add(took)
+		done()
+	}
+
+	run(400 * time.Millisecond)
+	if got := c.Stats().Latency; got != 400*time.Millisecond {
+		t.Fatalf("latency %v after the first sample", got)
+	}
+
+	// Over the limit only one request at a time gets through.
+	probe, err := c.Admit()
+	if err != nil {
+		t.Fatal("the probe should be admitted")
+	}
+	if _, err := c.Admit(); err != ErrShed {
+		t.Errorf("expected shedding while slow, got %v", err)
+	}
+	clk.now = clk.now.Add(20 * time.Millisecond)
+	probe()
+
+	// Fast probes bring the average down until normal admission resumes.
+	for c.Stats().Latency > 100*time.Millisecond {
+		run(20 * time.Millisecond)
+	}
+	d1, _ := c.Admit()
+	if _, err := c.Admit(); err != nil {
+		t.Errorf("concurrent admission should resume, got %v", err)
+	}
+	d1()
+}
+
+func TestMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	c := New(Options{MaxInFlight: 1, RetryAfter: 1500 * time.Millisecond})
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("got %d with Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	close(release)
+	wg.Wait()
+}
+
+// Example puts the controller in front of a slow handler. A burst of ten
+// requests meets room for three: seven are answered 503 right away instead
+// of queueing behind the three being served.
+func Example() {
+	release := make(chan struct{})
+	c := New(Options{MaxInFlight: 3})
+	srv := httptest.NewServer(c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, "done")
+	})))
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := make(map[int]int)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+			mu.Lock()
+			codes[resp.StatusCode]++
+			mu.Unlock()
+		}()
+	}
+	for s := c.Stats(); s.Admitted+s.Shed < 10; s = c.Stats() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	fmt.Println("200:", codes[200], "503:", codes[503])
+	// Output: 200: 3 503: 7
+}
@@ -0,0 +1,200 @@
+// Package ratelimit provides two rate limiters behind one interface.
+//
+// TokenBucket refills tokens at a steady rate up to a burst size. It is O(1)
+// in time and memory and allows short bursts. SlidingWindow keeps a log of
+// the admitted events and allows at most a fixed number of them in any
+// window of time. It is exact at the window edges, where a fixed window
+// counter lets through twice the limit, at the cost of memory proportional
+// to the limit.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// Limiter admits or delays events.
+type Limiter interface {
+	// Allow reports whether an event may happen now, consuming the
+	// allowance if so.
+	Allow() bool
+	// Wait blocks until an event may happen or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// wait loops on reserve until it admits an event, sleeping on c for the
+// delay it suggests in between.
+func wait(ctx context.Context, c clock.Clock, reserve func() (bool, time.Duration)) error {
+	for {
+		ok, delay := reserve()
+		if ok {
+			return nil
+		}
+		if dl, has := ctx.Deadline(); has && dl.Sub(c.Now()) < delay {
+			return context.DeadlineExceeded
+		}
+		select {
+		case <-c.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// TokenBucket is a token bucket limiter.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  clock.Clock
+}
+
+// NewTokenBucket allows rate events per second with bursts of up to burst
+// events. The bucket starts full. It panics unless rate is positive and
+// burst at least 1: such a bucket would never let an event through.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if !(rate > 0) {
+		panic("ratelimit: rate must be positive")
+	}
+	if burst < 1 {
+		panic("ratelimit: burst must be at least 1")
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		clock:  clock.Real,
+	}
+}
+
+// SetClock makes b refill by c. The bucket starts counting from the
+// first call after it.
+func (b *TokenBucket) SetClock(c clock.Clock) {
+	b.mu.Lock()
+	b.clock = c
+	b.last = time.Time{}
+	b.mu.Unlock()
+}
+
+// refill adds the tokens accumulated since the last call. b.mu must be
+// held.
+func (b *TokenBucket) refill() {
+	now := b.clock.Now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+func (b *TokenBucket) reserve() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Tokens returns the number of tokens currently in the bucket.
+func (b *TokenBucket) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	return b.tokens
+}
+
+// Burst returns the capacity of the bucket.
+func (b *TokenBucket) Burst() int {
+	return int(b.burst)
+}
+
+// Allow implements Limiter.
+func (b *TokenBucket) Allow() bool {
+	ok, _ := b.reserve()
+	return ok
+}
+
+// Wait implements Limiter.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	c := b.clock
+	b.mu.Unlock()
+	return wait(ctx, c, b.reserve)
+}
+
+// SlidingWindow is a sliding window log limiter.
+type SlidingWindow struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	// log is a ring of the admission times inside the window.
+	log   []time.Time
+	head  int
+	count int
+	clock clock.Clock
+}
+
+// NewSlidingWindow allows at most limit events in any window. A limit of
+// zero or less allows none: Allow is always false and Wait returns only
+// when ctx is done.
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	limit = max(limit, 0)
+	return &SlidingWindow{
+		limit:  limit,
+		window: window,
+		log:    make([]time.Time, limit),
+		clock:  clock.Real,
+	}
+}
+
+// SetClock makes the window slide with c, such as a clock.Fake.
+func (w *SlidingWindow) SetClock(c clock.Clock) {
+	w.mu.Lock()
+	w.clock = c
+	w.mu.Unlock()
+}
+
+func (w *SlidingWindow) reserve() (bool, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.limit == 0 {
+		return false, w.window
+	}
+	now := w.clock.Now()
+	for w.count > 0 && now.Sub(w.log[w.head]) >= w.window {
+		w.head = (w.head + 1) % w.limit
+		w.count--
+	}
+	if w.count < w.limit {
+		w.log[(w.head+w.count)%w.limit] = now
+		w.count++
+		return true, 0
+	}
+	return false, w.log[w.head].Add(w.window).Sub(now)
+}
+
+// Allow implements Limiter.
+func (w *SlidingWindow) Allow() bool {
+	ok, _ := w.reserve()
+	return ok
+}
+
+// Wait implements Limiter.
+func (w *SlidingWindow) Wait(ctx context.Context) error {
+	w.mu.Lock()
+	c := w.clock
+	w.mu.Unlock()
+	return wait(ctx, c, w.reserve)
+}
@@ -0,0 +1,247 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// adjustable is a limiter whose clock can be replaced.
+type adjustable interface {
+	Limiter
+	SetClock(c clock.Clock)
+}
+
+func allowed(l Limiter, n int) int {
+	var ok int
+	for i := 0; i < n; i++ {
+		if l.Allow() {
+			ok++
+		}
+	}
+	return ok
+}
+
+func TestTokenBucket(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	b := NewTokenBucket(10, 5)
+	b.SetClock(c)
+
+	if n := allowed(b, 10); n != 5 {
+		t.Errorf("a full bucket should allow a burst of 5, got %d", n)
+	}
+	c.Advance(300 * time.Millisecond)
+	if n := allowed(b, 10); n != 3 {
+		t.Errorf("300ms at 10/s should refill 3 tokens, got %d", n)
+	}
+	c.Advance(time.Hour)
+	if n := allowed(b, 10); n != 5 {
+		t.Errorf("refill must be capped at the burst, got %d", n)
+	}
+}
+
+func TestTokenBucketTokens(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	b := NewTokenBucket(10, 5)
+	b.SetClock(c)
+
+	allowed(b, 4)
+	if got := b.Tokens(); got != 1 {
+		t.Errorf("tokens = %v, want 1", got)
+	}
+	c.Advance(200 * time.Millisecond)
+	if got := b.Tokens(); got != 3 {
+		t.Errorf("tokens = %v after 200ms, want 3", got)
+	}
+	if n := allowed(b, 10); n != 3 || b.Burst() != 5 {
+		t.Errorf("reading the tokens must not consume them, allowed %d", n)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	w := NewSlidingWindow(3, time.Second)
+	w.SetClock(c)
+
+	if n := allowed(w, 5); n != 3 {
+		t.Errorf("expected 3 events in the window, got %d", n)
+	}
+	c.Advance(999 * time.Millisecond)
+	if w.Allow() {
+		t.Error("the window has not moved past the first events yet")
+	}
+	c.Advance(time.Millisecond)
+	if n := allowed(w, 5); n != 3 {
+		t.Errorf("all 3 events left the window, got %d", n)
+	}
+}
+
+func TestSlidingWindowEdge(t *testing.T) {
+	// A fixed window would admit 3 at 0.9s and 3 more at 1.0s.
+	c := clock.NewFake(time.Unix(0, 900*int64(time.Millisecond)))
+	w := NewSlidingWindow(3, time.Second)
+	w.SetClock(c)
+
+	allowed(w, 3)
+	c.Advance(100 * time.Millisecond)
+	if w.Allow() {
+		t.Error("sliding window let a burst through at the window edge")
+	}
+}
+
+func TestSlidingWindowZeroLimit(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		w := NewSlidingWindow(limit, time.Second)
+		if w.Allow() {
+			t.Errorf("limit %d allowed an event", limit)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if err := w.Wait(ctx); err != context.DeadlineExceeded {
+			t.Errorf("limit %d: Wait = %v", limit, err)
+		}
+		cancel()
+	}
+}
+
+func TestTokenBucketBadSettings(t *testing.T) {
+	for _, tc := range []struct {
+		rate  float64
+		burst int
+	}{{0, 1}, {-1, 1}, {math.NaN(), 1}, {1, 0}, {1, -1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewTokenBucket(%v, %d) did not panic", tc.rate, tc.burst)
+				}
+			}()
+			NewTokenBucket(tc.rate, tc.burst)
+		}()
+	}
+}
+
+func TestWait(t *testing.T) {
+	for name, l := range map[string]adjustable{
+		"token bucket":   NewTokenBucket(100, 1),
+		"sliding window": NewSlidingWindow(1, 10*time.Millisecond),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := clock.NewFake(time.Unix(0, 0))
+			l.SetClock(c)
+			// Advance the clock through every wait as soon as it starts.
+			stop, driven := make(chan struct{}), make(chan struct{})
+			go func() {
+				defer close(driven)
+				for {
+					c.BlockUntil(1)
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					c.AdvanceToNext()
+				}
+			}()
+			for i := 0; i < 3; i++ {
+				if err := l.Wait(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			close(stop)
+			c.After(0)
+			<-driven
+			if elapsed := c.Since(time.Unix(0, 0)); elapsed < 20*time.Millisecond || elapsed > 21*time.Millisecond {
+				t.Errorf("3 events at 100/s took %v, want 20ms", elapsed)
+			}
+		})
+	}
+}
+
+func TestWaitDeadline(t *testing.T) {
+	for name, l := range map[string]Limiter{
+		"token bucket":   NewTokenBucket(1, 1),
+		"sliding window": NewSlidingWindow(1, time.Second),
+	} {
+		t.Run(name, func(t *testing.T) {
+			l.Allow()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			if err := l.Wait(ctx); err != context.DeadlineExceeded {
+				t.Errorf("expected DeadlineExceeded, got %v", err)
+			}
+			if time.Since(start) > 500*time.Millisecond {
+				t.Error("Wait should fail fast when the deadline is too close")
+			}
+		})
+	}
+}
+
+// The deadline is measured against the limiter's clock, not the wall
+// clock: on a clock an hour ahead, a deadline ten minutes away has passed.
+func TestWaitDeadlineOnClock(t *testing.T) {
+	c := clock.NewFake(time.Now().Add(time.Hour))
+	w := NewSlidingWindow(1, time.Second)
+	w.SetClock(c)
+	w.Allow()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- w.Wait(ctx) }()
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Wait = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait measured the deadline on the wall clock")
+	}
+}
+
+func TestConcurrentAllow(t *testing.T) {
+	w := NewSlidingWindow(100, time.Hour)
+	b := NewTokenBucket(1e-9, 100)
+	var wg sync.WaitGroup
+	counts := make(chan [2]int, 10)
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counts <- [2]int{allowed(w, 50), allowed(b, 50)}
+		}()
+	}
+	wg.Wait()
+	close(counts)
+	var sw, tb int
+	for c := range counts {
+		sw += c[0]
+		tb += c[1]
+	}
+	if sw != 100 || tb != 100 {
+		t.Errorf("limits exceeded under contention: window %d, bucket %d", sw, tb)
+	}
+}
+
+func benchmarkContention(b *testing.B, l Limiter) {
+	b.SetParallelism(16)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Allow()
+		}
+	})
+}
+
+func BenchmarkTokenBucketContended(b *testing.B) {
+	benchmarkContention(b, NewTokenBucket(1e9, 1000))
+}
+
+func BenchmarkSlidingWindowContended(b *testing.B) {
+	benchmarkContention(b, NewSlidingWindow(1000, time.Millisecond))
+}
+
+func BenchmarkSlidingWindowLargeLog(b *testing.B) {
+	benchmarkContention(b, NewSlidingWindow(100000, time.Second))
+}
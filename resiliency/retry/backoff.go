@@ -0,0 +1,80 @@
+package retry
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff computes the wait before the next attempt. attempt is 1 before
+// the second call, 2 before the third and so on; prev is the wait that was
+// returned for the previous attempt, zero the first time.
+type Backoff interface {
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// BackoffFunc adapts an ordinary function to the Backoff interface.
+type BackoffFunc func(attempt int, prev time.Duration) time.Duration
+
+// Next calls f(attempt, prev).
+func (f BackoffFunc) Next(attempt int, prev time.Duration) time.Duration {
+	return f(attempt, prev)
+}
+
+// Constant waits d between all attempts.
+func Constant(d time.Duration) Backoff {
+	return BackoffFunc(func(int, time.Duration) time.Duration { return d })
+}
+
+// Exponential waits Initial, then Initial*Multiplier, Initial*Multiplier²
+// and so on, capped at Max.
+type Exponential struct {
+	Initial time.Duration
+	// Multiplier defaults to 2.
+	Multiplier float64
+	// Max caps the wait; zero means no cap.
+	Max time.Duration
+	// Jitter, between 0 and 1, takes a random fraction of up to Jitter off
+	// every wait so that clients failing together do not retry together.
+	Jitter float64
+}
+
+// Next implements Backoff.
+func (e Exponential) Next(attempt int, _ time.Duration) time.Duration {
+	m := e.Multiplier
+	if m == 0 {
+		m = 2
+	}
+	d := float64(e.Initial) * math.Pow(m, float64(attempt-1))
+	if e.Max > 0 && d > float64(e.Max) {
+		d = float64(e.Max)
+	}
+	if e.Jitter > 0 {
+		d -= d * e.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// DecorrelatedJitter is the backoff from the AWS architecture blog post
+// "Exponential Backoff And Jitter": every wait is drawn between Base and
+// three times the previous wait, capped at Cap. It spreads retries out
+// better than jittered exponential backoff while growing about as fast.
+type DecorrelatedJitter struct {
+	Base time.Duration
+	Cap  time.Duration
+}
+
+// Next implements Backoff.
+func (j DecorrelatedJitter) Next(_ int, prev time.Duration) time.Duration {
+	if prev < j.Base {
+		prev = j.Base
+	}
+	d := j.Base
+	if hi := 3 * prev; hi > j.Base {
+		d += rand.N(hi - j.Base)
+	}
+	if j.Cap > 0 && d > j.Cap {
+		d = j.Cap
+	}
+	return d
+}
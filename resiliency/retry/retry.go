@@ -0,0 +1,121 @@
+// Package retry calls a function until it succeeds, its error is not worth
+// retrying, or the attempt or time budget of a Policy is used up. The wait
+// between attempts comes from a Backoff: constant, exponential or
+// decorrelated jitter.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+)
+
+// Clock is the source of time for Do. Every clock.Clock is one.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Policy describes how often and how long to retry.
+type Policy struct {
+	// Backoff computes the waits; nil retries immediately.
+	Backoff Backoff
+	// MaxAttempts bounds the number of calls, including the first; zero
+	// means no bound.
+	MaxAttempts int
+	// MaxElapsed bounds the time from the first call. No attempt is
+	// started whose wait would end past the budget; zero means no bound.
+	MaxElapsed time.Duration
+	// RetryOn decides whether an error is worth another attempt. Nil
+	// retries every error. Errors marked with Permanent and errors of the
+	// context are never retried.
+	RetryOn func(err error) bool
+	// Clock defaults to clock.Real.
+	Clock Clock
+}
+
+// Error is returned when the budget of a Policy is used up. It wraps the
+// error of the last attempt.
+type Error struct {
+	Attempts int
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("retry: giving up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+type permanent struct{ err error }
+
+func (p permanent) Error() string { return p.err.Error() }
+func (p permanent) Unwrap() error { return p.err }
+
+// Permanent marks err as not worth retrying. Do returns err itself, not
+// the wrapper.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanent{err}
+}
+
+// Do calls fn until it returns nil or p says to stop. The error of the last
+// attempt is returned as is when it was not retryable, and wrapped in an
+// *Error when the budget ran out. ctx being done ends the wait between
+// attempts with ctx.Err().
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	c := p.Clock
+	if c == nil {
+		c = clock.Real
+	}
+	start := c.Now()
+	var wait time.Duration
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		var perm permanent
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		if p.RetryOn != nil && !p.RetryOn(err) {
+			return err
+		}
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return &Error{Attempts: attempt, Err: err}
+		}
+		if p.Backoff != nil {
+			wait = p.Backoff.Next(attempt, wait)
+		}
+		if p.MaxElapsed > 0 && c.Now().Add(wait).Sub(start) > p.MaxElapsed {
+			return &Error{Attempts: attempt, Err: err}
+		}
+		if wait > 0 {
+			select {
+			case <-c.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// DoValue is Do for functions that return a value with their error.
+func DoValue[T any](ctx context.Context, p Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	var v T
+	err := Do(ctx, p, func(ctx context.Context) error {
+		var err error
+		v, err = fn(ctx)
+		return err
+	})
+	return v, err
+}
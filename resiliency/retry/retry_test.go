@@ -0,0 +1,192 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/internal/chaos"
+)
+
+var errFlaky = errors.New("flaky")
+
+// do calls Do with a fake clock that is advanced through every wait as
+// soon as Do starts it, and returns the waits, the fake time they took
+// and Do's error.
+func do(p Policy, fn func(context.Context) error) ([]time.Duration, time.Duration, error) {
+	c := clock.NewFake(time.Time{})
+	p.Clock = c
+	stop := make(chan struct{})
+	driven := make(chan []time.Duration)
+	go func() {
+		var waits []time.Duration
+		for {
+			c.BlockUntil(1)
+			select {
+			case <-stop:
+				driven <- waits
+				return
+			default:
+			}
+			waits = append(waits, c.AdvanceToNext())
+		}
+	}()
+	err := Do(context.Background(), p, fn)
+	elapsed := c.Since(time.Time{})
+	close(stop)
+	c.After(0) // wakes the driver
+	return <-driven, elapsed, err
+}
+
+// failing returns an fn that fails n times and then succeeds.
+func failing(n int, calls *int) func(context.Context) error {
+	return func(context.Context) error {
+		*calls++
+		if *calls <= n {
+			return errFlaky
+		}
+		return nil
+	}
+}
+
+func TestExponential(t *testing.T) {
+	var calls int
+	p := Policy{Backoff: Exponential{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond}}
+	waits, _, err := do(p, failing(4, &calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond}
+	if !reflect.DeepEqual(waits, want) || calls != 5 {
+		t.Errorf("waits %v after %d calls, want %v after 5", waits, calls, want)
+	}
+}
+
+func TestExponentialJitter(t *testing.T) {
+	e := Exponential{Initial: 100 * time.Millisecond, Jitter: 0.5}
+	for i := 0; i < 1000; i++ {
+		if d := e.Next(2, 0); d < 100*time.Millisecond || d > 200*time.Millisecond {
+			t.Fatalf("jittered wait %v outside [100ms, 200ms]", d)
+		}
+	}
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	j := DecorrelatedJitter{Base: 10 * time.Millisecond, Cap: time.Second}
+	var prev time.Duration
+	for i := 1; i < 1000; i++ {
+		d := j.Next(i, prev)
+		lo, hi := j.Base, 3*prev
+		if hi < j.Base {
+			hi = 3 * j.Base
+		}
+		if hi > j.Cap {
+			hi = j.Cap
+		}
+		if d < lo || d > hi {
+			t.Fatalf("wait %v after %v outside [%v, %v]", d, prev, lo, hi)
+		}
+		prev = d
+	}
+}
+
+func TestMaxAttempts(t *testing.T) {
+	var calls int
+	p := Policy{Backoff: Constant(time.Second), MaxAttempts: 3}
+	_, _, err := do(p, failing(10, &calls))
+	var e *Error
+	if !errors.As(err, &e) || e.Attempts != 3 || !errors.Is(err, errFlaky) {
+		t.Errorf("expected *Error after 3 attempts wrapping errFlaky, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+// Against failures at a random rate rather than a script, Do keeps
+// trying until a call gets through, and waits once between attempts.
+func TestChaos(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		m := chaos.New(chaos.Config{Seed: seed, ErrorRate: 0.6})
+		var ran int
+		waits, _, err := do(Policy{Backoff: Constant(time.Second), MaxAttempts: 50},
+			m.Wrap(func(context.Context) error { ran++; return nil }))
+		s := m.Stats()
+		if err != nil || ran != 1 || s.Calls != s.Errors+1 || len(waits) != s.Errors {
+			t.Errorf("seed %d: %v after %d waits, ran %d; %+v", seed, err, len(waits), ran, s)
+		}
+	}
+}
+
+func TestMaxElapsed(t *testing.T) {
+	var calls int
+	p := Policy{Backoff: Constant(time.Second), MaxElapsed: 2500 * time.Millisecond}
+	_, elapsed, err := do(p, failing(10, &calls))
+	if !errors.Is(err, errFlaky) || calls != 3 {
+		t.Errorf("got %v after %d calls, want errFlaky after 3", err, calls)
+	}
+	if elapsed > p.MaxElapsed {
+		t.Errorf("slept %v, past the %v budget", elapsed, p.MaxElapsed)
+	}
+}
+
+func TestClassification(t *testing.T) {
+	errFatal := errors.New("fatal")
+	var calls int
+	p := Policy{
+		RetryOn: func(err error) bool { return !errors.Is(err, errFatal) },
+	}
+	_, _, err := do(p, func(context.Context) error {
+		calls++
+		if calls == 2 {
+			return fmt.Errorf("step: %w", errFatal)
+		}
+		return errFlaky
+	})
+	if !errors.Is(err, errFatal) || calls != 2 {
+		t.Errorf("got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = Do(context.Background(), Policy{}, func(context.Context) error {
+		calls++
+		return Permanent(errFatal)
+	})
+	if err != errFatal || calls != 1 {
+		t.Errorf("permanent error: got %v after %d calls", err, calls)
+	}
+}
+
+func TestContextStopsWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := clock.NewFake(time.Time{})
+	var calls int
+	go func() {
+		c.BlockUntil(1)
+		cancel()
+	}()
+	err := Do(ctx, Policy{Backoff: Constant(time.Hour), Clock: c}, failing(10, &calls))
+	if err != context.Canceled || calls != 1 {
+		t.Errorf("got %v after %d calls", err, calls)
+	}
+}
+
+func ExampleDo() {
+	attempts := 0
+	err := Do(context.Background(), Policy{
+		Backoff:     Exponential{Initial: time.Millisecond, Jitter: 0.2},
+		MaxAttempts: 5,
+	}, func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errFlaky
+		}
+		return nil
+	})
+	fmt.Println(attempts, err)
+	// Output: 3 <nil>
+}
@@ -0,0 +1,117 @@
+// Package adapter converts the interface of an existing type into the one
+// its clients expect.
+//
+// Here the client is log/slog and the existing type an in-house
+// LegacyLogger. Handler implements slog.Handler on top of a LegacyLogger,
+// so new code logs through slog while the entries still end up in the
+// legacy format. The adapter owns the translation: slog levels map to
+// legacy levels, and attributes, including groups and attributes added with
+// Logger.With, are flattened into the legacy string fields with dotted
+// keys.
+package adapter
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler adapts a LegacyLogger to slog.Handler.
+type Handler struct {
+	logger LegacyLogger
+	level  slog.Leveler
+	// attrs are the fields added by WithAttrs, already flattened.
+	attrs map[string]string
+	// prefix is the dotted group path for attributes added later.
+	prefix string
+}
+
+// NewHandler returns a Handler logging to l. Records below level are
+// dropped; a nil level means slog.LevelInfo.
+func NewHandler(l LegacyLogger, level slog.Leveler) *Handler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &Handler{logger: l, level: level}
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	fields := make(map[string]string, len(h.attrs)+r.NumAttrs())
+	for k, v := range h.attrs {
+		fields[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		flatten(fields, h.prefix, a)
+		return true
+	})
+	h.logger.Log(legacyLevel(r.Level), r.Message, fields)
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	c := *h
+	c.attrs = make(map[string]string, len(h.attrs)+len(attrs))
+	for k, v := range h.attrs {
+		c.attrs[k] = v
+	}
+	for _, a := range attrs {
+		flatten(c.attrs, h.prefix, a)
+	}
+	return &c
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.prefix = h.prefix + name + "."
+	return &c
+}
+
+// flatten adds a to fields under prefix, descending into groups.
+func flatten(fields map[string]string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		fields[prefix+a.Key] = a.Value.String()
+		return
+	}
+	group := a.Value.Group()
+	if len(group) == 0 {
+		return
+	}
+	// An inline group without a key adds its attributes to this level.
+	if a.Key != "" {
+		prefix += a.Key + "."
+	}
+	for _, g := range group {
+		flatten(fields, prefix, g)
+	}
+}
+
+// legacyLevel maps slog levels, which are open ended, onto the four legacy
+// ones.
+func legacyLevel(l slog.Level) Level {
+	switch {
+	case l >= slog.LevelError:
+		return LevelError
+	case l >= slog.LevelWarn:
+		return LevelWarn
+	case l >= slog.LevelInfo:
+		return LevelInfo
+	}
+	return LevelDebug
+}
@@ -0,0 +1,95 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type entry struct {
+	level  Level
+	msg    string
+	fields map[string]string
+}
+
+// recorder is a LegacyLogger that keeps what it is given.
+type recorder struct {
+	entries []entry
+}
+
+func (r *recorder) Log(level Level, msg string, fields map[string]string) {
+	r.entries = append(r.entries, entry{level, msg, fields})
+}
+
+func TestHandlerRecords(t *testing.T) {
+	rec := &recorder{}
+	log := slog.New(NewHandler(rec, slog.LevelDebug))
+
+	log.Debug("starting", "port", 8080)
+	log.With("service", "api").WithGroup("req").
+		Error("failed", "id", "r1", slog.Group("user", "name", "ann", "admin", true))
+	log.Warn("slow", slog.Group("", slog.Int("ms", 900)), slog.Group("empty"), slog.Attr{})
+
+	want := []entry{
+		{LevelDebug, "starting", map[string]string{"port": "8080"}},
+		{LevelError, "failed", map[string]string{
+			"service":        "api",
+			"req.id":         "r1",
+			"req.user.name":  "ann",
+			"req.user.admin": "true",
+		}},
+		{LevelWarn, "slow", map[string]string{"ms": "900"}},
+	}
+	if !reflect.DeepEqual(rec.entries, want) {
+		t.Errorf("entries =\n%v\nwant\n%v", rec.entries, want)
+	}
+}
+
+func TestHandlerLevels(t *testing.T) {
+	var level slog.LevelVar
+	rec := &recorder{}
+	log := slog.New(NewHandler(rec, &level))
+
+	log.Debug("hidden")
+	level.Set(slog.LevelDebug)
+	log.Debug("shown")
+	log.Log(context.Background(), slog.LevelError+4, "fatal")
+	log.Log(context.Background(), slog.LevelInfo+2, "notice")
+
+	var got []Level
+	for _, e := range rec.entries {
+		got = append(got, e.level)
+	}
+	if want := []Level{LevelDebug, LevelError, LevelInfo}; !reflect.DeepEqual(got, want) {
+		t.Errorf("levels = %v, want %v", got, want)
+	}
+}
+
+func TestWithAttrsDoesNotLeak(t *testing.T) {
+	rec := &recorder{}
+	base := slog.New(NewHandler(rec, nil))
+	base.With("a", 1).Info("one")
+	base.Info("two")
+	if _, ok := rec.entries[1].fields["a"]; ok {
+		t.Errorf("attributes added with With leaked into the parent logger")
+	}
+}
+
+func TestLegacyFormat(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewHandler(NewTextLogger(&buf), nil))
+	log.Info("user created", "id", 7, "email", "ann@example.com")
+
+	if got, want := buf.String(), "[INFO] user created email=ann@example.com id=7\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func Example() {
+	log := slog.New(NewHandler(NewTextLogger(os.Stdout), nil)).With("service", "billing")
+	log.Warn("retrying", "attempt", 2)
+	// Output: [WARN] retrying attempt=2 service=billing
+}
@@ -0,0 +1,76 @@
+package adapter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Level is the severity of a legacy log entry.
+type Level int
+
+// Legacy levels.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	}
+	return "ERROR"
+}
+
+// LegacyLogger is the API of an in-house logging library that predates
+// log/slog. Lots of code writes through it and its output format feeds
+// existing dashboards, so it cannot simply be replaced.
+type LegacyLogger interface {
+	Log(level Level, msg string, fields map[string]string)
+}
+
+// TextLogger is the legacy implementation. It writes one line per entry:
+//
+//	[INFO] message key=value key=value
+type TextLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	Min Level
+}
+
+// NewTextLogger returns a TextLogger writing to w.
+func NewTextLogger(w io.Writer) *TextLogger {
+	return &TextLogger{w: w}
+}
+
+// Log implements LegacyLogger. Fields are written sorted by key.
+func (l *TextLogger) Log(level Level, msg string, fields map[string]string) {
+	if level < l.Min {
+		return
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", level, msg)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, fields[k])
+	}
+	b.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, b.String())
+}
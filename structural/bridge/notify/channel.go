@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Message is what a notification looks like to a Channel.
+type Message struct {
+	Subject string
+	Body    string
+	Urgent  bool
+}
+
+// Channel is the implementation side of the bridge: it delivers a message
+// to one recipient and knows nothing about what kind of notification it
+// carries.
+type Channel interface {
+	Deliver(ctx context.Context, to string, m Message) error
+}
+
+// Email writes messages as plain text mail to W, standing in for an SMTP
+// connection.
+type Email struct {
+	From string
+	W    io.Writer
+}
+
+// Deliver writes m as a mail to to.
+func (e Email) Deliver(_ context.Context, to string, m Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\nTo: %s\n", e.From, to)
+	if m.Urgent {
+		b.WriteString("Importance: high\n")
+	}
+	fmt.Fprintf(&b, "Subject: %s\n\n%s\n", m.Subject, m.Body)
+	_, err := io.WriteString(e.W, b.String())
+	return err
+}
+
+// SMSLimit is the length of a single text message.
+const SMSLimit = 160
+
+// SMS writes messages as single text messages to W, standing in for a
+// gateway. Texts have no subject line, so it leads the text, and anything
+// beyond SMSLimit runes is cut.
+type SMS struct {
+	W io.Writer
+}
+
+// Deliver writes m as a text to the number to.
+func (s SMS) Deliver(_ context.Context, to string, m Message) error {
+	text := m.Subject + ": " + m.Body
+	if m.Urgent {
+		text = "!" + text
+	}
+	if r := []rune(text); len(r) > SMSLimit {
+		text = string(r[:SMSLimit-1]) + "…"
+	}
+	_, err := fmt.Fprintf(s.W, "%s %s\n", to, text)
+	return err
+}
+
+// Webhook posts messages as JSON to URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Deliver posts m, addressed to to.
+func (w Webhook) Deliver(ctx context.Context, to string, m Message) error {
+	body, err := json.Marshal(struct {
+		To      string `json:"to"`
+		Subject string `json:"subject"`
+		Body    string `json:"body"`
+		Urgent  bool   `json:"urgent,omitempty"`
+	}{to, m.Subject, m.Body, m.Urgent})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notify: webhook %s: %s", w.URL, resp.Status)
+	}
+	return nil
+}
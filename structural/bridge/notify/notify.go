@@ -0,0 +1,95 @@
+// Package notify is the bridge pattern applied to notifications.
+//
+// There are two independent hierarchies. Notifications, the abstraction,
+// decide what to say: an Alert fires on an incident, a Report summarizes
+// figures. Channels, the implementation, decide how it travels: Email,
+// SMS or Webhook. Each notification holds a Channel instead of being
+// written once per channel, so adding a kind of notification or a channel
+// is one new type, not a new row or column of the matrix.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Notifier is the abstraction side of the bridge.
+type Notifier interface {
+	// Message renders the notification.
+	Message() Message
+}
+
+// Send delivers n over ch to every recipient. It tries all of them and
+// returns the joined errors.
+func Send(ctx context.Context, ch Channel, n Notifier, to ...string) error {
+	m := n.Message()
+	var errs []error
+	for _, r := range to {
+		if err := ch.Deliver(ctx, r, m); err != nil {
+			errs = append(errs, fmt.Errorf("notify: %s: %w", r, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Severity grades an Alert.
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Critical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "INFO"
+	case Warning:
+		return "WARNING"
+	}
+	return "CRITICAL"
+}
+
+// Alert reports an incident in a service.
+type Alert struct {
+	Service  string
+	Severity Severity
+	Text     string
+}
+
+// Message renders the alert. Critical alerts are urgent.
+func (a Alert) Message() Message {
+	return Message{
+		Subject: fmt.Sprintf("[%s] %s", a.Severity, a.Service),
+		Body:    a.Text,
+		Urgent:  a.Severity == Critical,
+	}
+}
+
+// Row is one line of a Report.
+type Row struct {
+	Name  string
+	Value float64
+}
+
+// Report summarizes figures for a period.
+type Report struct {
+	Title  string
+	Period string
+	Rows   []Row
+}
+
+// Message renders the report as one line per row.
+func (r Report) Message() Message {
+	var b strings.Builder
+	for i, row := range r.Rows {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s: %g", row.Name, row.Value)
+	}
+	return Message{Subject: r.Title + " " + r.Period, Body: b.String()}
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// hook is a webhook receiver that records what it was sent.
+type hook struct {
+	mu   sync.Mutex
+	got  []string
+	fail bool
+}
+
+func (h *hook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.fail {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+	h.got = append(h.got, string(b))
+}
+
+func (h *hook) output() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return strings.Join(h.got, "\n")
+}
+
+var (
+	alert  = Alert{Service: "db", Severity: Critical, Text: "replica lag 30s"}
+	report = Report{Title: "Weekly sales", Period: "2024-W10", Rows: []Row{
+		{"orders", 1204}, {"revenue", 18250.5},
+	}}
+)
+
+// TestMatrix sends every kind of notification over every channel.
+func TestMatrix(t *testing.T) {
+	notifiers := map[string]Notifier{"alert": alert, "report": report}
+	want := map[string]map[string]string{
+		"alert": {
+			"email":   "From: ops@example.com\nTo: ann@example.com\nImportance: high\nSubject: [CRITICAL] db\n\nreplica lag 30s\n",
+			"sms":     "ann@example.com ![CRITICAL] db: replica lag 30s\n",
+			"webhook": `{"to":"ann@example.com","subject":"[CRITICAL] db","body":"replica lag 30s","urgent":true}`,
+		},
+		"report": {
+			"email":   "From: ops@example.com\nTo: ann@example.com\nSubject: Weekly sales 2024-W10\n\norders: 1204\nrevenue: 18250.5\n",
+			"sms":     "ann@example.com Weekly sales 2024-W10: orders: 1204\nrevenue: 18250.5\n",
+			"webhook": `{"to":"ann@example.com","subject":"Weekly sales 2024-W10","body":"orders: 1204\nrevenue: 18250.5"}`,
+		},
+	}
+	for nname, n := range notifiers {
+		for _, cname := range []string{"email", "sms", "webhook"} {
+			t.Run(nname+"/"+cname, func(t *testing.T) {
+				var buf strings.Builder
+				h := &hook{}
+				srv := httptest.NewServer(h)
+				defer srv.Close()
+				ch := map[string]Channel{
+					"email":   Email{From: "ops@example.com", W: &buf},
+					"sms":     SMS{W: &buf},
+					"webhook": Webhook{URL: srv.URL, Client: srv.Client()},
+				}[cname]
+
+				if err := Send(context.Background(), ch, n, "ann@example.com"); err != nil {
+					t.Fatal(err)
+				}
+				got := buf.String() + h.output()
+				if got != want[nname][cname] {
+					t.Errorf("got\n%s\nwant\n%s", got, want[nname][cname])
+				}
+			})
+		}
+	}
+}
+
+func TestSMSLimit(t *testing.T) {
+	var buf strings.Builder
+	long := Alert{Service: "api", Text: strings.Repeat("ü", 300)}
+	if err := Send(context.Background(), SMS{W: &buf}, long, "+15550100"); err != nil {
+		t.Fatal(err)
+	}
+	text := strings.TrimSuffix(strings.TrimPrefix(buf.String(), "+15550100 "), "\n")
+	if n := utf8.RuneCountInString(text); n != SMSLimit {
+		t.Errorf("text is %d runes, want %d", n, SMSLimit)
+	}
+}
+
+func TestSendJoinsErrors(t *testing.T) {
+	h := &hook{fail: true}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	err := Send(context.Background(), Webhook{URL: srv.URL, Client: srv.Client()}, alert, "a", "b")
+	if err == nil || !strings.Contains(err.Error(), "notify: a:") || !strings.Contains(err.Error(), "notify: b:") {
+		t.Errorf("err = %v, want one error per recipient", err)
+	}
+
+	// The webhook body is valid JSON for any text.
+	h.fail = false
+	odd := Alert{Service: `"q"`, Text: "line\nbreak"}
+	if err := Send(context.Background(), Webhook{URL: srv.URL, Client: srv.Client()}, odd, "c"); err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(h.output()), &m); err != nil || m["body"] != "line\nbreak" {
+		t.Errorf("payload %s: %v", h.output(), err)
+	}
+}
+
+func Example() {
+	sms := SMS{W: os.Stdout}
+	email := Email{From: "ops@example.com", W: os.Stdout}
+	down := Alert{Service: "web", Severity: Warning, Text: "5xx rate 2%"}
+
+	Send(context.Background(), sms, down, "+15550100")
+	Send(context.Background(), email, down, "oncall@example.com")
+	// Output:
+	// +15550100 [WARNING] web: 5xx rate 2%
+	// From: ops@example.com
+	// To: oncall@example.com
+	// Subject: [WARNING] web
+	//
+	// 5xx rate 2%
+}
@@ -0,0 +1,121 @@
+// Package fstree models a file system tree with the composite pattern.
+//
+// Files and directories both implement Node, so code that sizes or walks a
+// tree does not care whether it holds a single file or a directory of
+// thousands: a directory answers by asking its children, which may be
+// directories in turn. All exposes the same traversal as a Go 1.23
+// iterator for use with range.
+package fstree
+
+import (
+	"errors"
+	"iter"
+	"path"
+)
+
+// SkipDir can be returned by a WalkFunc to skip the directory it was
+// called for.
+var SkipDir = errors.New("fstree: skip this directory")
+
+// WalkFunc is called for every node with its slash separated path from the
+// root of the walk.
+type WalkFunc func(path string, n Node) error
+
+// Node is a file or a directory.
+type Node interface {
+	Name() string
+	// Size is the size of a file, or the total size of the files below a
+	// directory.
+	Size() int64
+	// Walk calls fn for the node and, for directories, everything below
+	// it in depth first order.
+	Walk(fn WalkFunc) error
+}
+
+// File is a leaf.
+type File struct {
+	name string
+	size int64
+}
+
+// NewFile returns a file of size bytes.
+func NewFile(name string, size int64) *File {
+	return &File{name: name, size: size}
+}
+
+func (f *File) Name() string { return f.name }
+func (f *File) Size() int64  { return f.size }
+
+func (f *File) Walk(fn WalkFunc) error {
+	return walk(f, f.name, fn)
+}
+
+// Dir is a composite of other nodes.
+type Dir struct {
+	name     string
+	children []Node
+}
+
+// NewDir returns a directory holding children.
+func NewDir(name string, children ...Node) *Dir {
+	return &Dir{name: name, children: children}
+}
+
+// Add appends children to d.
+func (d *Dir) Add(children ...Node) {
+	d.children = append(d.children, children...)
+}
+
+// Children returns the nodes directly below d.
+func (d *Dir) Children() []Node {
+	return append([]Node(nil), d.children...)
+}
+
+func (d *Dir) Name() string { return d.name }
+
+func (d *Dir) Size() int64 {
+	var total int64
+	for _, c := range d.children {
+		total += c.Size()
+	}
+	return total
+}
+
+func (d *Dir) Walk(fn WalkFunc) error {
+	return walk(d, d.name, fn)
+}
+
+// errStop ends a walk early without an error, for All.
+var errStop = errors.New("stop")
+
+func walk(n Node, p string, fn WalkFunc) error {
+	if err := fn(p, n); err != nil {
+		if err == SkipDir {
+			return nil
+		}
+		return err
+	}
+	d, ok := n.(*Dir)
+	if !ok {
+		return nil
+	}
+	for _, c := range d.children {
+		if err := walk(c, path.Join(p, c.Name()), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// All iterates over n and every node below it, depth first, with their
+// paths. Breaking out of the loop stops the walk.
+func All(n Node) iter.Seq2[string, Node] {
+	return func(yield func(string, Node) bool) {
+		n.Walk(func(p string, n Node) error {
+			if !yield(p, n) {
+				return errStop
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package fstree
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func sample() *Dir {
+	return NewDir("static",
+		NewDir("js",
+			NewFile("jquery.js", 90),
+			NewFile("app.js", 10),
+		),
+		NewDir("css", NewFile("site.css", 5)),
+		NewDir("empty"),
+		NewFile("index.html", 2),
+	)
+}
+
+func TestSize(t *testing.T) {
+	root := sample()
+	if got := root.Size(); got != 107 {
+		t.Errorf("root size = %d, want 107", got)
+	}
+	// A single file and a directory answer the same question.
+	for _, n := range []Node{NewFile("a", 3), NewDir("d", NewFile("a", 3))} {
+		if n.Size() != 3 {
+			t.Errorf("%s: size %d", n.Name(), n.Size())
+		}
+	}
+	root.Add(NewFile("robots.txt", 1))
+	if root.Size() != 108 {
+		t.Errorf("size after Add = %d", root.Size())
+	}
+}
+
+func TestWalk(t *testing.T) {
+	var paths []string
+	err := sample().Walk(func(p string, n Node) error {
+		paths = append(paths, p)
+		if n.Name() == "js" {
+			return SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"static", "static/js", "static/css", "static/css/site.css", "static/empty", "static/index.html"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = sample().Walk(func(string, Node) error { calls++; return stop })
+	if err != stop || calls != 1 {
+		t.Errorf("walk should stop at the first error: %v after %d calls", err, calls)
+	}
+}
+
+func TestAll(t *testing.T) {
+	var files []string
+	for p, n := range All(sample()) {
+		if _, ok := n.(*File); ok {
+			files = append(files, p)
+		}
+	}
+	want := []string{"static/js/jquery.js", "static/js/app.js", "static/css/site.css", "static/index.html"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	seen := 0
+	for range All(sample()) {
+		seen++
+		if seen == 3 {
+			break
+		}
+	}
+	if seen != 3 {
+		t.Errorf("break did not stop the iteration")
+	}
+}
+
+func ExampleAll() {
+	for p, n := range All(sample()) {
+		if d, ok := n.(*Dir); ok {
+			fmt.Printf("%-12s %3d bytes in %d entries\n", p, d.Size(), len(d.Children()))
+		}
+	}
+	// Output:
+	// static       107 bytes in 4 entries
+	// static/js    100 bytes in 2 entries
+	// static/css     5 bytes in 1 entries
+	// static/empty   0 bytes in 0 entries
+}
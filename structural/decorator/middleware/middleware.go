@@ -0,0 +1,157 @@
+// Package middleware applies the decorator pattern to http.Handler.
+//
+// A Middleware takes a handler and returns one that does something before
+// and after calling it, with the same interface, so decorators stack in
+// any combination. Chain composes them; the first one listed is the
+// outermost and sees the request first and the response last. Order
+// matters: Logging outside Recovery logs the 500 a panic turns into, while
+// Logging inside it never finishes; Auth outside Compression rejects
+// requests before any compression is set up.
+package middleware
+
+import (
+	"compress/gzip"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/ctxkeys/auth"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// Middleware decorates a handler.
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares into one. Chain(a, b, c)(h) is a(b(c(h))).
+func Chain(handlers ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(handlers) - 1; i >= 0; i-- {
+			next = handlers[i](next)
+		}
+		return next
+	}
+}
+
+// statusWriter records the status and size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the writer underneath.
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// Logging logs every request with its status, response size and duration.
+func Logging(log *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			log.LogAttrs(r.Context(), slog.LevelInfo, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", sw.status),
+				slog.Int("bytes", sw.bytes),
+				slog.Duration("duration", time.Since(start)),
+			)
+		})
+	}
+}
+
+// Auth admits requests with a bearer token that verify accepts and puts the
+// principal in the request context, see auth.Get. Other requests get 401.
+func Auth(verify func(token string) (auth.Principal, bool)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			p, valid := verify(token)
+			if !ok || !valid {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.With(r.Context(), p)))
+		})
+	}
+}
+
+// Recovery turns panics into 500 responses, reporting them to the hooks of
+// b; a nil b means recovery.Default.
+func Recovery(b *recovery.Boundary) Middleware {
+	if b == nil {
+		b = recovery.Default
+	}
+	return b.Middleware
+}
+
+// gzipWriter compresses the body written through it.
+type gzipWriter struct {
+	http.ResponseWriter
+	zw *gzip.Writer
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	// The length of the uncompressed body is wrong now.
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	w.Header().Del("Content-Length")
+	return w.zw.Write(b)
+}
+
+// Flush sends what has been compressed so far.
+func (w *gzipWriter) Flush() {
+	w.zw.Flush()
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *gzipWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// Compression gzips responses for clients that accept it.
+func Compression() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			defer zw.Close()
+			next.ServeHTTP(&gzipWriter{ResponseWriter: w, zw: zw}, r)
+		})
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, q, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(q, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/crazybber/go-patterns/idioms/ctxkeys/auth"
+	"github.com/crazybber/go-patterns/patterns/recovery"
+)
+
+// trace records when it is entered and left.
+func trace(name string, log *[]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*log = append(*log, ">"+name)
+			next.ServeHTTP(w, r)
+			*log = append(*log, "<"+name)
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var log []string
+	h := Chain(trace("a", &log), trace("b", &log), trace("c", &log))(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) { log = append(log, "handler") }))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if got, want := strings.Join(log, " "), ">a >b >c handler <c <b <a"; got != want {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+}
+
+func TestChainEmpty(t *testing.T) {
+	called := false
+	Chain()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true })).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !called {
+		t.Error("an empty chain must call the handler")
+	}
+}
+
+func verify(token string) (auth.Principal, bool) {
+	return auth.Principal{ID: "ann"}, token == "secret"
+}
+
+func TestAuth(t *testing.T) {
+	var log []string
+	h := Chain(Auth(verify), trace("inner", &log))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := auth.Get(r.Context())
+		io.WriteString(w, p.ID)
+	}))
+
+	for _, tc := range []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"secret", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	} {
+		log = nil
+		r := httptest.NewRequest("GET", "/", nil)
+		if tc.header != "" {
+			r.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Errorf("%q: status %d, want %d", tc.header, w.Code, tc.status)
+		}
+		if rejected := tc.status != http.StatusOK; rejected != (log == nil) {
+			t.Errorf("%q: inner middleware ran = %v", tc.header, log != nil)
+		}
+		if tc.status == http.StatusOK && w.Body.String() != "ann" {
+			t.Errorf("principal not passed on: %q", w.Body.String())
+		}
+	}
+}
+
+func panicking(http.ResponseWriter, *http.Request) { panic("boom") }
+
+func TestLoggingOutsideRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, nil))
+	h := Chain(Logging(log), Recovery(recovery.New()))(http.HandlerFunc(panicking))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/x", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status %d", w.Code)
+	}
+	if !strings.Contains(buf.String(), "status=500") {
+		t.Errorf("the logger outside recovery should see the 500: %q", buf.String())
+	}
+}
+
+func TestLoggingInsideRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, nil))
+	h := Chain(Recovery(recovery.New()), Logging(log))(http.HandlerFunc(panicking))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/x", nil))
+	if buf.Len() != 0 {
+		t.Errorf("the panic unwinds past the inner logger, yet it logged %q", buf.String())
+	}
+}
+
+func TestCompression(t *testing.T) {
+	body := strings.Repeat("hello ", 100)
+	h := Compression()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "600")
+		io.WriteString(w, body)
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Length") != "" {
+		t.Fatalf("headers = %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Errorf("decompressed body differs")
+	}
+
+	for _, enc := range []string{"", "gzip;q=0", "identity"} {
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", enc)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Header().Get("Content-Encoding") != "" || w.Body.String() != body {
+			t.Errorf("%q: response should not be compressed", enc)
+		}
+	}
+}
+
+func TestLoggingSeesUncompressedSize(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, nil))
+	h := Chain(Compression(), Logging(log))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("a", 1000))
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.Contains(buf.String(), "bytes=1000") {
+		t.Errorf("a logger inside compression counts the plain body: %q", buf.String())
+	}
+}
@@ -0,0 +1,75 @@
+// Package billing keeps customer accounts and charges them.
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Errors returned by the Ledger.
+var (
+	ErrUnknownAccount     = errors.New("billing: unknown account")
+	ErrInsufficientFunds  = errors.New("billing: insufficient funds")
+	ErrUnknownTransaction = errors.New("billing: unknown transaction")
+)
+
+// Ledger holds account balances in cents.
+type Ledger struct {
+	mu       sync.Mutex
+	balances map[string]int64
+	charges  map[string]charge
+	next     int
+}
+
+type charge struct {
+	account string
+	amount  int64
+}
+
+// New returns a Ledger with the given opening balances.
+func New(balances map[string]int64) *Ledger {
+	l := &Ledger{balances: make(map[string]int64), charges: make(map[string]charge)}
+	for acct, b := range balances {
+		l.balances[acct] = b
+	}
+	return l
+}
+
+// Balance returns the balance of account.
+func (l *Ledger) Balance(account string) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.balances[account]
+}
+
+// Charge debits amount from account and returns the transaction id.
+func (l *Ledger) Charge(account string, amount int64) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.balances[account]
+	if !ok {
+		return "", ErrUnknownAccount
+	}
+	if b < amount {
+		return "", fmt.Errorf("%w: %s owes %d, has %d", ErrInsufficientFunds, account, amount, b)
+	}
+	l.balances[account] = b - amount
+	l.next++
+	id := fmt.Sprintf("T%d", l.next)
+	l.charges[id] = charge{account, amount}
+	return id, nil
+}
+
+// Refund reverses a charge.
+func (l *Ledger) Refund(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.charges[id]
+	if !ok {
+		return ErrUnknownTransaction
+	}
+	delete(l.charges, id)
+	l.balances[c.account] += c.amount
+	return nil
+}
@@ -0,0 +1,97 @@
+// Package inventory tracks stock and reservations.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Errors returned by the Store.
+var (
+	ErrUnknownSKU         = errors.New("inventory: unknown sku")
+	ErrOutOfStock         = errors.New("inventory: out of stock")
+	ErrUnknownReservation = errors.New("inventory: unknown reservation")
+)
+
+// Item is a product on the shelf.
+type Item struct {
+	SKU   string
+	Price int64 // in cents
+	Stock int
+}
+
+// Store holds the stock. Stock that is reserved is no longer available,
+// and goes back on the shelf when the reservation is released.
+type Store struct {
+	mu       sync.Mutex
+	items    map[string]*Item
+	reserved map[string]reservation
+	next     int
+}
+
+type reservation struct {
+	sku string
+	qty int
+}
+
+// New returns a Store stocked with items.
+func New(items ...Item) *Store {
+	s := &Store{items: make(map[string]*Item), reserved: make(map[string]reservation)}
+	for _, it := range items {
+		s.items[it.SKU] = &it
+	}
+	return s
+}
+
+// Price returns the unit price of sku.
+func (s *Store) Price(sku string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[sku]
+	if !ok {
+		return 0, ErrUnknownSKU
+	}
+	return it.Price, nil
+}
+
+// Available returns the stock of sku that is not reserved.
+func (s *Store) Available(sku string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if it, ok := s.items[sku]; ok {
+		return it.Stock
+	}
+	return 0
+}
+
+// Reserve takes qty of sku off the shelf and returns the reservation id.
+func (s *Store) Reserve(sku string, qty int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[sku]
+	if !ok {
+		return "", ErrUnknownSKU
+	}
+	if qty <= 0 || it.Stock < qty {
+		return "", fmt.Errorf("%w: %d of %s wanted, %d left", ErrOutOfStock, qty, sku, it.Stock)
+	}
+	it.Stock -= qty
+	s.next++
+	id := fmt.Sprintf("R%d", s.next)
+	s.reserved[id] = reservation{sku, qty}
+	return id, nil
+}
+
+// Release puts a reservation back on the shelf.
+func (s *Store) Release(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.reserved[id]
+	if !ok {
+		return ErrUnknownReservation
+	}
+	delete(s.reserved, id)
+	s.items[r.sku].Stock += r.qty
+	return nil
+}
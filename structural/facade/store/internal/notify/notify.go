@@ -0,0 +1,29 @@
+// Package notify delivers messages to customers.
+package notify
+
+import "sync"
+
+// Outbox keeps the messages sent to each customer in memory.
+type Outbox struct {
+	mu    sync.Mutex
+	boxes map[string][]string
+}
+
+// New returns an empty Outbox.
+func New() *Outbox {
+	return &Outbox{boxes: make(map[string][]string)}
+}
+
+// Send delivers msg to a customer.
+func (o *Outbox) Send(to, msg string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.boxes[to] = append(o.boxes[to], msg)
+}
+
+// Messages returns the messages sent to a customer, oldest first.
+func (o *Outbox) Messages(to string) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.boxes[to]...)
+}
@@ -0,0 +1,134 @@
+// Package store is a facade over a small shop back end.
+//
+// Selling something touches three subsystems, each in its own internal
+// package: inventory reserves the stock, billing charges the customer and
+// notify tells them about it. Doing that by hand means knowing their order,
+// their ids and how to undo a step when a later one fails. Service knows
+// all that and offers Buy and Cancel instead. Because the subsystems are
+// internal, the facade is the only way in; it re-exports what callers need,
+// such as the errors.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/crazybber/go-patterns/structural/facade/store/internal/billing"
+	"github.com/crazybber/go-patterns/structural/facade/store/internal/inventory"
+	"github.com/crazybber/go-patterns/structural/facade/store/internal/notify"
+)
+
+// Errors returned by Service. Errors from the subsystems are wrapped, so
+// errors.Is works with these.
+var (
+	ErrUnknownSKU        = inventory.ErrUnknownSKU
+	ErrOutOfStock        = inventory.ErrOutOfStock
+	ErrUnknownAccount    = billing.ErrUnknownAccount
+	ErrInsufficientFunds = billing.ErrInsufficientFunds
+	ErrUnknownOrder      = errors.New("store: unknown order")
+)
+
+// Item is a product for sale, with its price in cents.
+type Item = inventory.Item
+
+// Order is a completed purchase.
+type Order struct {
+	ID       string
+	Customer string
+	SKU      string
+	Qty      int
+	Total    int64 // in cents
+
+	reservation string
+	charge      string
+}
+
+// Service sells items to customers.
+type Service struct {
+	stock  *inventory.Store
+	ledger *billing.Ledger
+	outbox *notify.Outbox
+
+	mu     sync.Mutex
+	orders map[string]*Order
+	next   int
+}
+
+// New returns a Service selling items to customers with the given
+// balances in cents.
+func New(items []Item, balances map[string]int64) *Service {
+	return &Service{
+		stock:  inventory.New(items...),
+		ledger: billing.New(balances),
+		outbox: notify.New(),
+		orders: make(map[string]*Order),
+	}
+}
+
+// Buy sells qty of sku to customer. Either all of it happens, stock
+// reserved, customer charged and notified, or none of it does.
+func (s *Service) Buy(customer, sku string, qty int) (Order, error) {
+	price, err := s.stock.Price(sku)
+	if err != nil {
+		return Order{}, fmt.Errorf("store: buy %s: %w", sku, err)
+	}
+	res, err := s.stock.Reserve(sku, qty)
+	if err != nil {
+		return Order{}, fmt.Errorf("store: buy %s: %w", sku, err)
+	}
+	total := price * int64(qty)
+	charge, err := s.ledger.Charge(customer, total)
+	if err != nil {
+		s.stock.Release(res)
+		return Order{}, fmt.Errorf("store: buy %s: %w", sku, err)
+	}
+
+	s.mu.Lock()
+	s.next++
+	o := &Order{
+		ID:          fmt.Sprintf("O%d", s.next),
+		Customer:    customer,
+		SKU:         sku,
+		Qty:         qty,
+		Total:       total,
+		reservation: res,
+		charge:      charge,
+	}
+	s.orders[o.ID] = o
+	s.mu.Unlock()
+
+	s.outbox.Send(customer, fmt.Sprintf("order %s: %d x %s for %s", o.ID, qty, sku, cents(total)))
+	return *o, nil
+}
+
+// Cancel undoes an order: the customer is refunded and notified, and the
+// stock goes back on the shelf.
+func (s *Service) Cancel(id string) error {
+	s.mu.Lock()
+	o, ok := s.orders[id]
+	delete(s.orders, id)
+	s.mu.Unlock()
+	if !ok {
+		return ErrUnknownOrder
+	}
+	if err := s.ledger.Refund(o.charge); err != nil {
+		return fmt.Errorf("store: cancel %s: %w", id, err)
+	}
+	if err := s.stock.Release(o.reservation); err != nil {
+		return fmt.Errorf("store: cancel %s: %w", id, err)
+	}
+	s.outbox.Send(o.Customer, fmt.Sprintf("order %s cancelled, %s refunded", id, cents(o.Total)))
+	return nil
+}
+
+// Stock returns the number of sku left for sale.
+func (s *Service) Stock(sku string) int { return s.stock.Available(sku) }
+
+// Balance returns the balance of customer in cents.
+func (s *Service) Balance(customer string) int64 { return s.ledger.Balance(customer) }
+
+// Messages returns the notifications sent to customer, oldest first.
+func (s *Service) Messages(customer string) []string { return s.outbox.Messages(customer) }
+
+func cents(c int64) string { return fmt.Sprintf("$%d.%02d", c/100, c%100) }
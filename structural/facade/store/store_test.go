@@ -0,0 +1,102 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func newService() *Service {
+	return New(
+		[]Item{{SKU: "egg", Price: 25, Stock: 12}, {SKU: "milk", Price: 150, Stock: 2}},
+		map[string]int64{"ann": 1000, "bob": 100},
+	)
+}
+
+func TestBuy(t *testing.T) {
+	s := newService()
+	o, err := s.Buy("ann", "egg", 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Total != 150 || o.Qty != 6 || o.Customer != "ann" {
+		t.Errorf("order = %+v", o)
+	}
+	if s.Stock("egg") != 6 {
+		t.Errorf("stock = %d, want 6", s.Stock("egg"))
+	}
+	if s.Balance("ann") != 850 {
+		t.Errorf("balance = %d, want 850", s.Balance("ann"))
+	}
+	want := []string{"order O1: 6 x egg for $1.50"}
+	if got := s.Messages("ann"); !slices.Equal(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+// TestBuyRollsBack checks that a failing step leaves no trace in the
+// subsystems that already ran.
+func TestBuyRollsBack(t *testing.T) {
+	for _, tc := range []struct {
+		customer, sku string
+		qty           int
+		want          error
+	}{
+		{"bob", "milk", 1, ErrInsufficientFunds},
+		{"eve", "milk", 1, ErrUnknownAccount},
+		{"ann", "milk", 3, ErrOutOfStock},
+		{"ann", "tea", 1, ErrUnknownSKU},
+	} {
+		s := newService()
+		_, err := s.Buy(tc.customer, tc.sku, tc.qty)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("Buy(%s, %s, %d) = %v, want %v", tc.customer, tc.sku, tc.qty, err, tc.want)
+		}
+		if s.Stock("milk") != 2 || s.Balance("ann") != 1000 || s.Balance("bob") != 100 {
+			t.Errorf("Buy(%s, %s, %d) left stock %d, balances %d and %d", tc.customer, tc.sku, tc.qty,
+				s.Stock("milk"), s.Balance("ann"), s.Balance("bob"))
+		}
+		if msgs := s.Messages(tc.customer); len(msgs) != 0 {
+			t.Errorf("failed purchase notified %s: %q", tc.customer, msgs)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s := newService()
+	o, err := s.Buy("ann", "milk", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Buy("ann", "milk", 1); !errors.Is(err, ErrOutOfStock) {
+		t.Fatalf("sold more milk than there is: %v", err)
+	}
+	if err := s.Cancel(o.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.Stock("milk") != 2 || s.Balance("ann") != 1000 {
+		t.Errorf("after cancel: stock %d, balance %d", s.Stock("milk"), s.Balance("ann"))
+	}
+	if err := s.Cancel(o.ID); !errors.Is(err, ErrUnknownOrder) {
+		t.Errorf("second cancel: %v, want %v", err, ErrUnknownOrder)
+	}
+	msgs := s.Messages("ann")
+	if len(msgs) != 2 || msgs[1] != "order O1 cancelled, $3.00 refunded" {
+		t.Errorf("messages = %q", msgs)
+	}
+}
+
+func Example() {
+	shop := New([]Item{{SKU: "flour", Price: 299, Stock: 5}}, map[string]int64{"ann": 1200})
+	if _, err := shop.Buy("ann", "flour", 4); err != nil {
+		fmt.Println(err)
+	}
+	if _, err := shop.Buy("ann", "flour", 1); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(shop.Messages("ann"), shop.Stock("flour"), shop.Balance("ann"))
+	// Output:
+	// store: buy flour: billing: insufficient funds: ann owes 299, has 4
+	// [order O1: 4 x flour for $11.96] 1 4
+}
@@ -0,0 +1,40 @@
+package intern
+
+// TreeKind is the intrinsic state of a tree: what every tree of one kind
+// looks like. It is comparable so that a Pool can intern it.
+type TreeKind struct {
+	Name    string
+	Color   [3]byte
+	Texture [1024]byte
+}
+
+// Tree is a flyweight: its position is its own, its kind is shared.
+type Tree struct {
+	X, Y int32
+	Kind *TreeKind
+}
+
+// NaiveTree carries its own copy of the kind.
+type NaiveTree struct {
+	X, Y int32
+	Kind TreeKind
+}
+
+// Forest plants trees whose kinds come from a Pool.
+type Forest struct {
+	kinds *Pool[TreeKind]
+	Trees []Tree
+}
+
+// NewForest returns an empty Forest.
+func NewForest() *Forest {
+	return &Forest{kinds: NewPool[TreeKind]()}
+}
+
+// Plant adds a tree of kind at x, y.
+func (f *Forest) Plant(x, y int32, kind TreeKind) {
+	f.Trees = append(f.Trees, Tree{X: x, Y: y, Kind: f.kinds.Intern(kind)})
+}
+
+// Kinds returns the number of distinct kinds in the forest.
+func (f *Forest) Kinds() int { return f.kinds.Len() }
@@ -0,0 +1,75 @@
+// Package intern shares immutable state between many objects, the
+// flyweight pattern.
+//
+// Objects are split into their intrinsic state, which is the same for many
+// of them and never changes, and their extrinsic state, which is their
+// own. The intrinsic state is interned: a Pool keeps one canonical copy of
+// each distinct value and hands out pointers to it, so ten thousand trees
+// of three kinds carry three textures instead of ten thousand.
+//
+// Interned values live as long as the Pool. Go 1.23's unique package does
+// the same with weak references, so values nobody uses any more can be
+// collected; a Pool is the explicit, easy to inspect version.
+package intern
+
+import "sync"
+
+// Pool interns values of type T. It is safe for concurrent use.
+type Pool[T comparable] struct {
+	mu     sync.RWMutex
+	values map[T]*T
+}
+
+// NewPool returns an empty Pool.
+func NewPool[T comparable]() *Pool[T] {
+	return &Pool[T]{values: make(map[T]*T)}
+}
+
+// Intern returns the canonical copy of v. Equal values get the same
+// pointer, which callers must treat as read-only.
+func (p *Pool[T]) Intern(v T) *T {
+	p.mu.RLock()
+	ptr, ok := p.values[v]
+	p.mu.RUnlock()
+	if ok {
+		return ptr
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ptr, ok := p.values[v]; ok {
+		return ptr
+	}
+	ptr = new(T)
+	*ptr = v
+	p.values[v] = ptr
+	return ptr
+}
+
+// Len returns the number of distinct values interned.
+func (p *Pool[T]) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.values)
+}
+
+// Strings interns strings, so that equal strings share one backing array.
+// The zero value is ready to use.
+type Strings struct {
+	mu      sync.Mutex
+	strings map[string]string
+}
+
+// Intern returns the canonical copy of s.
+func (p *Strings) Intern(s string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.strings == nil {
+		p.strings = make(map[string]string)
+	}
+	if c, ok := p.strings[s]; ok {
+		return c
+	}
+	p.strings[s] = s
+	return s
+}
@@ -0,0 +1,138 @@
+package intern
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestPoolShares(t *testing.T) {
+	p := NewPool[TreeKind]()
+	a := p.Intern(TreeKind{Name: "oak"})
+	b := p.Intern(TreeKind{Name: "oak"})
+	c := p.Intern(TreeKind{Name: "pine"})
+	if a != b {
+		t.Error("equal values should share one pointer")
+	}
+	if a == c || p.Len() != 2 {
+		t.Errorf("distinct values must stay apart, %d interned", p.Len())
+	}
+}
+
+func TestPoolConcurrent(t *testing.T) {
+	p := NewPool[string]()
+	ptrs := make([]*string, 64)
+	var wg sync.WaitGroup
+	for i := range ptrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ptrs[i] = p.Intern("same")
+		}()
+	}
+	wg.Wait()
+	for _, ptr := range ptrs {
+		if ptr != ptrs[0] {
+			t.Fatal("concurrent interning produced two copies")
+		}
+	}
+}
+
+func TestStringsShareBacking(t *testing.T) {
+	var p Strings
+	a := p.Intern(strings.Repeat("x", 64))
+	b := p.Intern(strings.Repeat("x", 64))
+	if unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Error("interned strings should share their bytes")
+	}
+}
+
+var kinds = []TreeKind{
+	{Name: "oak", Color: [3]byte{34, 139, 34}},
+	{Name: "pine", Color: [3]byte{1, 121, 111}},
+	{Name: "birch", Color: [3]byte{240, 240, 230}},
+}
+
+func TestForest(t *testing.T) {
+	f := NewForest()
+	for i := 0; i < 1000; i++ {
+		f.Plant(int32(i), int32(i*2), kinds[i%len(kinds)])
+	}
+	if f.Kinds() != 3 {
+		t.Errorf("kinds = %d, want 3", f.Kinds())
+	}
+	if f.Trees[0].Kind != f.Trees[3].Kind {
+		t.Error("trees of one kind should share it")
+	}
+}
+
+func naiveForest(n int) any {
+	forest := make([]NaiveTree, 0, n)
+	for i := 0; i < n; i++ {
+		forest = append(forest, NaiveTree{X: int32(i), Kind: kinds[i%len(kinds)]})
+	}
+	return forest
+}
+
+func internedForest(n int) any {
+	f := NewForest()
+	f.Trees = make([]Tree, 0, n)
+	for i := 0; i < n; i++ {
+		f.Plant(int32(i), 0, kinds[i%len(kinds)])
+	}
+	return f
+}
+
+// heapGrowth returns the bytes the heap grew by to hold the result of
+// build.
+func heapGrowth(build func() any) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	v := build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+func TestMemoryReduction(t *testing.T) {
+	naive := heapGrowth(func() any { return naiveForest(1000) })
+	interned := heapGrowth(func() any { return internedForest(1000) })
+	t.Logf("naive %d bytes, interned %d bytes", naive, interned)
+	if interned*10 > naive {
+		t.Errorf("interning saved too little: %d bytes against %d", interned, naive)
+	}
+}
+
+// BenchmarkMemory reports the heap held per tree with and without
+// interning the tree kinds.
+func BenchmarkMemory(b *testing.B) {
+	const trees = 10000
+	for _, bc := range []struct {
+		name  string
+		build func(int) any
+	}{
+		{"naive", naiveForest},
+		{"interned", internedForest},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var bytes uint64
+			for i := 0; i < b.N; i++ {
+				bytes += heapGrowth(func() any { return bc.build(trees) })
+			}
+			b.ReportMetric(float64(bytes)/float64(b.N*trees), "B/tree")
+		})
+	}
+}
+
+func ExamplePool() {
+	styles := NewPool[string]()
+	a, b := styles.Intern("bold"), styles.Intern("bold")
+	fmt.Println(a == b, styles.Len())
+	// Output: true 1
+}
@@ -0,0 +1,114 @@
+// Package fetcher puts proxies in front of an expensive Fetcher.
+//
+// A proxy implements the same interface as the object it stands in for, so
+// callers cannot tell the two apart, and controls access to it. Caching
+// answers repeated requests without calling the real fetcher, and
+// Authorizing turns away callers before they reach it. Both take and
+// return a Fetcher, so they stack: Authorizing(Caching(remote)) checks
+// access before looking in the cache, which keeps a denied caller from
+// reading what an allowed one has fetched.
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/idioms/ctxkeys/auth"
+)
+
+// ErrDenied is returned by Authorizing for callers that may not fetch a key.
+var ErrDenied = errors.New("fetcher: access denied")
+
+// Fetcher loads the content stored under a key.
+type Fetcher interface {
+	Fetch(ctx context.Context, key string) ([]byte, error)
+}
+
+// Func adapts a function to the Fetcher interface.
+type Func func(ctx context.Context, key string) ([]byte, error)
+
+// Fetch calls f(ctx, key).
+func (f Func) Fetch(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+type entry struct {
+	data    []byte
+	expires time.Time
+}
+
+// CachingProxy remembers successful fetches for a while. Errors are not
+// cached.
+type CachingProxy struct {
+	next  Fetcher
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+// Caching returns a proxy for next that caches results for ttl.
+func Caching(next Fetcher, ttl time.Duration) *CachingProxy {
+	return &CachingProxy{
+		next:    next,
+		ttl:     ttl,
+		clock:   clock.Real,
+		entries: make(map[string]entry),
+	}
+}
+
+// SetClock lets a test expire entries by advancing a fake clock.
+func (p *CachingProxy) SetClock(c clock.Clock) {
+	p.mu.Lock()
+	p.clock = c
+	p.mu.Unlock()
+}
+
+// Fetch implements Fetcher. Callers get their own copy of the data, so
+// modifying it does not corrupt the cache.
+func (p *CachingProxy) Fetch(ctx context.Context, key string) ([]byte, error) {
+	p.mu.Lock()
+	e, ok := p.entries[key]
+	now := p.clock.Now()
+	p.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return clone(e.data), nil
+	}
+
+	data, err := p.next.Fetch(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.entries[key] = entry{data: clone(data), expires: now.Add(p.ttl)}
+	p.mu.Unlock()
+	return data, nil
+}
+
+// Invalidate drops key from the cache.
+func (p *CachingProxy) Invalidate(key string) {
+	p.mu.Lock()
+	delete(p.entries, key)
+	p.mu.Unlock()
+}
+
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
+
+// Authorizing returns a proxy for next that only lets through callers whose
+// principal, see auth.Get, allow accepts for the key. Requests without a
+// principal are denied.
+func Authorizing(next Fetcher, allow func(p auth.Principal, key string) bool) Fetcher {
+	return Func(func(ctx context.Context, key string) ([]byte, error) {
+		p, ok := auth.Get(ctx)
+		if !ok || !allow(p, key) {
+			return nil, ErrDenied
+		}
+		return next.Fetch(ctx, key)
+	})
+}
@@ -0,0 +1,147 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/idioms/clock"
+	"github.com/crazybber/go-patterns/idioms/ctxkeys/auth"
+)
+
+// remote is the expensive Fetcher; it counts its calls.
+type remote struct {
+	calls int
+	fail  bool
+}
+
+func (r *remote) Fetch(_ context.Context, key string) ([]byte, error) {
+	r.calls++
+	if r.fail {
+		return nil, errors.New("remote down")
+	}
+	return []byte("content of " + key), nil
+}
+
+// render only knows the Fetcher interface.
+func render(f Fetcher, ctx context.Context, key string) string {
+	data, err := f.Fetch(ctx, key)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return string(data)
+}
+
+func TestCachingHits(t *testing.T) {
+	r := &remote{}
+	clk := clock.NewFake(time.Unix(0, 0))
+	c := Caching(r, time.Minute)
+	c.SetClock(clk)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if got := render(c, ctx, "a"); got != "content of a" {
+			t.Fatalf("got %q", got)
+		}
+	}
+	render(c, ctx, "b")
+	if r.calls != 2 {
+		t.Errorf("remote called %d times, want once per key", r.calls)
+	}
+
+	clk.Advance(time.Minute)
+	render(c, ctx, "a")
+	if r.calls != 3 {
+		t.Errorf("expired entry was served from the cache")
+	}
+	c.Invalidate("a")
+	render(c, ctx, "a")
+	if r.calls != 4 {
+		t.Errorf("invalidated entry was served from the cache")
+	}
+}
+
+func TestCachingErrorsAndCopies(t *testing.T) {
+	r := &remote{fail: true}
+	c := Caching(r, time.Minute)
+	ctx := context.Background()
+
+	c.Fetch(ctx, "a")
+	r.fail = false
+	data, err := c.Fetch(ctx, "a")
+	if err != nil || r.calls != 2 {
+		t.Fatalf("errors must not be cached: %v after %d calls", err, r.calls)
+	}
+
+	data[0] = 'X'
+	again, _ := c.Fetch(ctx, "a")
+	if string(again) != "content of a" {
+		t.Errorf("caller modified the cached data: %q", again)
+	}
+}
+
+func onlyOwnKeys(p auth.Principal, key string) bool {
+	return strings.HasPrefix(key, p.ID+"/") || slices.Contains(p.Roles, "admin")
+}
+
+func TestAuthorizing(t *testing.T) {
+	r := &remote{}
+	f := Authorizing(r, onlyOwnKeys)
+	ann := auth.With(context.Background(), auth.Principal{ID: "ann"})
+	root := auth.With(context.Background(), auth.Principal{ID: "root", Roles: []string{"admin"}})
+
+	for _, tc := range []struct {
+		ctx  context.Context
+		key  string
+		want error
+	}{
+		{ann, "ann/notes", nil},
+		{ann, "bob/notes", ErrDenied},
+		{root, "bob/notes", nil},
+		{context.Background(), "ann/notes", ErrDenied},
+	} {
+		if _, err := f.Fetch(tc.ctx, tc.key); err != tc.want {
+			t.Errorf("%s: err = %v, want %v", tc.key, err, tc.want)
+		}
+	}
+	if r.calls != 2 {
+		t.Errorf("denied calls reached the remote: %d calls", r.calls)
+	}
+}
+
+func TestStackedProxies(t *testing.T) {
+	r := &remote{}
+	f := Authorizing(Caching(r, time.Minute), onlyOwnKeys)
+	ann := auth.With(context.Background(), auth.Principal{ID: "ann"})
+	bob := auth.With(context.Background(), auth.Principal{ID: "bob"})
+
+	render(f, ann, "ann/notes")
+	if got := render(f, bob, "ann/notes"); got != "error: "+ErrDenied.Error() {
+		t.Errorf("bob read ann's cached notes: %q", got)
+	}
+	render(f, ann, "ann/notes")
+	if r.calls != 1 {
+		t.Errorf("remote called %d times, want 1", r.calls)
+	}
+}
+
+func Example() {
+	slow := Func(func(ctx context.Context, key string) ([]byte, error) {
+		fmt.Println("fetching", key)
+		return []byte(strings.ToUpper(key)), nil
+	})
+	f := Caching(slow, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		data, _ := f.Fetch(context.Background(), "report")
+		fmt.Println(string(data))
+	}
+	// Output:
+	// fetching report
+	// REPORT
+	// REPORT
+}
@@ -0,0 +1,82 @@
+// Package factories builds test data.
+//
+// A test that spells out every field of every value it needs drowns the
+// one field it is about among the ones it is not, and breaks each time the
+// type gains a field. A Factory instead knows how to build a valid value
+// with sensible defaults; a test asks for one and overrides only what it
+// cares about:
+//
+//	items := factories.New(func(n int) store.Item {
+//		return store.Item{SKU: fmt.Sprint("sku-", n), Price: 100, Stock: 10}
+//	})
+//	cheap := items.Build(func(it *store.Item) { it.Price = 1 })
+//
+// The n passed to the defaults function comes from the factory's Sequence,
+// so values that must be unique, such as IDs, are. Factories derived with
+// With share the sequence of their parent and add traits, overrides
+// applied before the caller's. An association is one factory's defaults
+// calling Build on another.
+package factories
+
+import "sync/atomic"
+
+// Sequence hands out 1, 2, 3 and so on. It is safe for concurrent use.
+type Sequence struct {
+	n atomic.Int64
+}
+
+// Next returns the next number.
+func (s *Sequence) Next() int {
+	return int(s.n.Add(1))
+}
+
+// Reset starts the sequence again from 1.
+func (s *Sequence) Reset() {
+	s.n.Store(0)
+}
+
+// Factory builds values of T.
+type Factory[T any] struct {
+	seq      *Sequence
+	defaults func(n int) T
+	traits   []func(*T)
+}
+
+// New returns a factory building values with defaults, called with the
+// next number of the factory's sequence.
+func New[T any](defaults func(n int) T) *Factory[T] {
+	return &Factory[T]{seq: &Sequence{}, defaults: defaults}
+}
+
+// Build returns a new value with f's traits and then overrides applied.
+func (f *Factory[T]) Build(overrides ...func(*T)) T {
+	v := f.defaults(f.seq.Next())
+	for _, o := range f.traits {
+		o(&v)
+	}
+	for _, o := range overrides {
+		o(&v)
+	}
+	return v
+}
+
+// List returns n values built with overrides.
+func (f *Factory[T]) List(n int, overrides ...func(*T)) []T {
+	vs := make([]T, n)
+	for i := range vs {
+		vs[i] = f.Build(overrides...)
+	}
+	return vs
+}
+
+// With returns a factory that applies traits to every value it builds,
+// after those of f. It shares f's sequence.
+func (f *Factory[T]) With(traits ...func(*T)) *Factory[T] {
+	all := append(append([]func(*T){}, f.traits...), traits...)
+	return &Factory[T]{seq: f.seq, defaults: f.defaults, traits: all}
+}
+
+// Sequence returns the sequence of f, to reset it between tests.
+func (f *Factory[T]) Sequence() *Sequence {
+	return f.seq
+}
@@ -0,0 +1,136 @@
+package factories
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/crazybber/go-patterns/structural/facade/store"
+)
+
+type customer struct {
+	Name    string
+	Balance int64 // in cents
+}
+
+// purchase is a customer buying an item: an association of two factories.
+type purchase struct {
+	Customer customer
+	Item     store.Item
+	Qty      int
+}
+
+var (
+	items = New(func(n int) store.Item {
+		return store.Item{SKU: fmt.Sprint("sku-", n), Price: 100, Stock: 10}
+	})
+	customers = New(func(n int) customer {
+		return customer{Name: fmt.Sprint("customer-", n), Balance: 10_000}
+	})
+	purchases = New(func(int) purchase {
+		return purchase{Customer: customers.Build(), Item: items.Build(), Qty: 1}
+	})
+
+	// Traits.
+	broke     = customers.With(func(c *customer) { c.Balance = 0 })
+	soldOut   = items.With(func(it *store.Item) { it.Stock = 0 })
+	pricey    = items.With(func(it *store.Item) { it.Price = 1_000_000 })
+	wholesale = purchases.With(func(p *purchase) { p.Qty = p.Item.Stock })
+)
+
+// shop opens a store with the purchase's item and customer in it.
+func (p purchase) shop() *store.Service {
+	return store.New([]store.Item{p.Item}, map[string]int64{p.Customer.Name: p.Customer.Balance})
+}
+
+// TestStoreFixtures runs the store's purchase rules over scenarios built
+// from fixtures. Each scenario states only what makes it different.
+func TestStoreFixtures(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		p    purchase
+		want error
+	}{
+		{"default", purchases.Build(), nil},
+		{"whole stock", wholesale.Build(), nil},
+		{"too many", purchases.Build(func(p *purchase) { p.Qty = p.Item.Stock + 1 }), store.ErrOutOfStock},
+		{"sold out", purchases.Build(func(p *purchase) { p.Item = soldOut.Build() }), store.ErrOutOfStock},
+		{"broke", purchases.Build(func(p *purchase) { p.Customer = broke.Build() }), store.ErrInsufficientFunds},
+		{"pricey", purchases.Build(func(p *purchase) { p.Item = pricey.Build() }), store.ErrInsufficientFunds},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := tc.p.shop()
+			o, err := s.Buy(tc.p.Customer.Name, tc.p.Item.SKU, tc.p.Qty)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Buy = %v, want %v", err, tc.want)
+			}
+			wantStock, wantBalance := tc.p.Item.Stock, tc.p.Customer.Balance
+			if err == nil {
+				wantStock -= tc.p.Qty
+				wantBalance -= o.Total
+				if o.Total != tc.p.Item.Price*int64(tc.p.Qty) {
+					t.Errorf("total %d", o.Total)
+				}
+			}
+			if s.Stock(tc.p.Item.SKU) != wantStock || s.Balance(tc.p.Customer.Name) != wantBalance {
+				t.Errorf("stock %d, balance %d; want %d, %d",
+					s.Stock(tc.p.Item.SKU), s.Balance(tc.p.Customer.Name), wantStock, wantBalance)
+			}
+		})
+	}
+}
+
+func TestSequenceUnique(t *testing.T) {
+	f := New(func(n int) int { return n })
+	var mu sync.Mutex
+	var got []int
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vs := f.List(100)
+			mu.Lock()
+			got = append(got, vs...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	slices.Sort(got)
+	if len(slices.Compact(got)) != 800 {
+		t.Errorf("built %d distinct values of 800", len(slices.Compact(got)))
+	}
+}
+
+func TestTraitsAndOverrides(t *testing.T) {
+	f := New(func(n int) customer { return customer{Name: fmt.Sprint("c", n), Balance: 1} })
+	vip := f.With(func(c *customer) { c.Balance = 100 })
+	vipBroke := vip.With(func(c *customer) { c.Balance -= 100 })
+
+	a := f.Build()
+	b := vip.Build()
+	c := vipBroke.Build(func(c *customer) { c.Name += "!" })
+	if a != (customer{"c1", 1}) || b != (customer{"c2", 100}) || c != (customer{"c3!", 0}) {
+		t.Errorf("built %v, %v, %v", a, b, c)
+	}
+
+	f.Sequence().Reset()
+	if got := f.Build(); got.Name != "c1" {
+		t.Errorf("after Reset built %v", got)
+	}
+}
+
+func Example() {
+	users := New(func(n int) customer {
+		return customer{Name: fmt.Sprintf("user%d", n), Balance: 500}
+	})
+	fmt.Println(users.Build())
+	fmt.Println(users.Build(func(c *customer) { c.Balance = 0 }))
+	fmt.Println(users.With(func(c *customer) { c.Name = "admin" }).List(2))
+	// Output:
+	// {user1 500}
+	// {user2 0}
+	// [{admin 500} {admin 500}]
+}
@@ -0,0 +1,224 @@
+// Package linearize checks that a concurrent data structure behaves as if
+// each operation took effect at a single instant between its call and its
+// return, in an order consistent with a sequential model: that it is
+// linearizable.
+//
+// A test records a History of operations run from many goroutines, each
+// with its input, output and the times it was called and returned. Check
+// then searches for an order of the operations that respects real time (an
+// operation that returned before another was called comes first) and that
+// the Model accepts step by step. Finding one proves that history
+// linearizable; finding none proves the structure wrong, whatever the
+// interleaving that produced it.
+//
+// The search is the Wing and Gong algorithm with Lowe's memoization of
+// visited (linearized set, state) pairs. It is exponential in the worst
+// case, so histories should be small: a few goroutines doing some dozens of
+// operations each. A Model with a Partition is checked one partition at a
+// time, such as one key of a map at a time, which keeps the search small
+// for much longer histories.
+package linearize
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Op is one operation of a history. Call and Return are times on any
+// clock shared by all operations, such as a Recorder's.
+type Op[I, O any] struct {
+	Input        I
+	Output       O
+	Call, Return int64
+}
+
+// Model is the sequential specification of a data structure with states
+// S, taking inputs I and giving outputs O.
+type Model[S, I, O any] struct {
+	Init func() S
+	// Step applies in to state and reports whether the model could have
+	// returned out, and the state after.
+	Step func(state S, in I, out O) (bool, S)
+	// Key identifies a state, for memoization. States with the same key
+	// must behave alike.
+	Key func(state S) string
+	// Partition, if set, splits operations into independent groups that
+	// are checked separately.
+	Partition func(in I) string
+}
+
+// Recorder collects a history from concurrent goroutines.
+type Recorder[I, O any] struct {
+	clock atomic.Int64
+	mu    sync.Mutex
+	ops   []Op[I, O]
+}
+
+// Do records the operation f performs with input in, and returns its
+// output.
+func (r *Recorder[I, O]) Do(in I, f func() O) O {
+	call := r.clock.Add(1)
+	out := f()
+	ret := r.clock.Add(1)
+	r.mu.Lock()
+	r.ops = append(r.ops, Op[I, O]{in, out, call, ret})
+	r.mu.Unlock()
+	return out
+}
+
+// History returns the operations recorded so far.
+func (r *Recorder[I, O]) History() []Op[I, O] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.ops)
+}
+
+// Check reports whether history is linearizable with respect to m.
+func Check[S, I, O any](m Model[S, I, O], history []Op[I, O]) bool {
+	if m.Partition == nil {
+		_, ok := check(m, history)
+		return ok
+	}
+	parts := map[string][]Op[I, O]{}
+	for _, op := range history {
+		k := m.Partition(op.Input)
+		parts[k] = append(parts[k], op)
+	}
+	for _, p := range parts {
+		if _, ok := check(m, p); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Linearization returns an order of history, as indexes into it, that
+// shows it linearizable, or false if there is none. It ignores
+// m.Partition.
+func Linearization[S, I, O any](m Model[S, I, O], history []Op[I, O]) ([]int, bool) {
+	return check(m, history)
+}
+
+// entry is a call or return event in the doubly linked list the search
+// works on.
+type entry struct {
+	op         int
+	call       bool
+	time       int64
+	match      *entry // the return entry of a call entry
+	prev, next *entry
+}
+
+func check[S, I, O any](m Model[S, I, O], history []Op[I, O]) ([]int, bool) {
+	events := make([]*entry, 0, 2*len(history))
+	for i, op := range history {
+		ret := &entry{op: i, time: op.Return}
+		events = append(events, &entry{op: i, call: true, time: op.Call, match: ret}, ret)
+	}
+	// In time order; on a tie, calls first, so the operations overlap.
+	slices.SortStableFunc(events, func(a, b *entry) int {
+		if a.time != b.time {
+			return cmp.Compare(a.time, b.time)
+		}
+		if a.call == b.call {
+			return 0
+		}
+		if a.call {
+			return -1
+		}
+		return 1
+	})
+	head := &entry{}
+	prev := head
+	for _, e := range events {
+		prev.next, e.prev = e, prev
+		prev = e
+	}
+
+	type frame struct {
+		e     *entry
+		state S
+	}
+	var stack []frame
+	state := m.Init()
+	done := make(bitset, (len(history)+63)/64)
+	seen := map[string]bool{}
+
+	e := head.next
+	for head.next != nil {
+		if e.call {
+			op := history[e.op]
+			if ok, next := m.Step(state, op.Input, op.Output); ok {
+				done.set(e.op)
+				key := done.key() + "\x00" + m.Key(next)
+				if !seen[key] {
+					seen[key] = true
+					stack = append(stack, frame{e, state})
+					state = next
+					lift(e)
+					e = head.next
+					continue
+				}
+				done.clear(e.op)
+			}
+			e = e.next
+			continue
+		}
+		// A return: its operation must already have taken effect, so the
+		// last choice was wrong.
+		if len(stack) == 0 {
+			return nil, false
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		state = top.state
+		done.clear(top.e.op)
+		unlift(top.e)
+		e = top.e.next
+	}
+
+	order := make([]int, len(stack))
+	for i, f := range stack {
+		order[i] = f.e.op
+	}
+	return order, true
+}
+
+// lift takes call entry e and its return out of the list.
+func lift(e *entry) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	r := e.match
+	r.prev.next = r.next
+	if r.next != nil {
+		r.next.prev = r.prev
+	}
+}
+
+// unlift puts back what lift took out.
+func unlift(e *entry) {
+	r := e.match
+	r.prev.next = r
+	if r.next != nil {
+		r.next.prev = r
+	}
+	e.prev.next = e
+	e.next.prev = e
+}
+
+type bitset []uint64
+
+func (b bitset) set(i int)   { b[i/64] |= 1 << (i % 64) }
+func (b bitset) clear(i int) { b[i/64] &^= 1 << (i % 64) }
+
+func (b bitset) key() string {
+	buf := make([]byte, 0, 8*len(b))
+	for _, w := range b {
+		for i := 0; i < 8; i++ {
+			buf = append(buf, byte(w>>(8*i)))
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,249 @@
+package linearize
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/crazybber/go-patterns/behavioral/strategy/cache"
+	"github.com/crazybber/go-patterns/observability/aggregate"
+	"github.com/crazybber/go-patterns/patterns/filequeue"
+)
+
+func kv(op, key, value string, out KVOutput, call, ret int64) Op[KVInput, KVOutput] {
+	return Op[KVInput, KVOutput]{KVInput{op, key, value}, out, call, ret}
+}
+
+func TestHandwrittenHistories(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		ok   bool
+		h    []Op[KVInput, KVOutput]
+	}{
+		{"get after put", true, []Op[KVInput, KVOutput]{
+			kv("put", "x", "1", KVOutput{}, 1, 2),
+			kv("get", "x", "", KVOutput{"1", true}, 3, 4),
+		}},
+		{"stale get after put", false, []Op[KVInput, KVOutput]{
+			kv("put", "x", "1", KVOutput{}, 1, 2),
+			kv("get", "x", "", KVOutput{}, 3, 4),
+		}},
+		{"overlapping get may see either", true, []Op[KVInput, KVOutput]{
+			kv("put", "x", "1", KVOutput{}, 1, 6),
+			kv("get", "x", "", KVOutput{}, 2, 3),
+			kv("get", "x", "", KVOutput{"1", true}, 4, 5),
+		}},
+		{"reads go back in time", false, []Op[KVInput, KVOutput]{
+			kv("put", "x", "1", KVOutput{}, 1, 10),
+			kv("get", "x", "", KVOutput{"1", true}, 2, 3),
+			kv("get", "x", "", KVOutput{}, 4, 5),
+		}},
+		{"keys are independent", true, []Op[KVInput, KVOutput]{
+			kv("put", "x", "1", KVOutput{}, 1, 2),
+			kv("put", "y", "2", KVOutput{}, 3, 4),
+			kv("get", "y", "", KVOutput{"2", true}, 5, 6),
+			kv("delete", "x", "", KVOutput{}, 5, 8),
+			kv("get", "x", "", KVOutput{"1", true}, 6, 7),
+		}},
+	} {
+		if got := Check(KV, tc.h); got != tc.ok {
+			t.Errorf("%s: Check = %v, want %v", tc.name, got, tc.ok)
+		}
+	}
+}
+
+func TestLostUpdate(t *testing.T) {
+	// Two overlapping increments, then a read that saw only one of them:
+	// the signature of a read-modify-write race.
+	h := []Op[CounterInput, int64]{
+		{CounterInput{Delta: 1}, 0, 1, 4},
+		{CounterInput{Delta: 1}, 0, 2, 3},
+		{CounterInput{Read: true}, 1, 5, 6},
+	}
+	if Check(Counter, h) {
+		t.Error("lost update accepted")
+	}
+	h[2].Output = 2
+	if !Check(Counter, h) {
+		t.Error("correct count rejected")
+	}
+}
+
+func TestLinearization(t *testing.T) {
+	h := []Op[QueueInput, QueueOutput]{
+		{QueueInput{"peek", ""}, QueueOutput{"b", true}, 5, 8},
+		{QueueInput{"push", "a"}, QueueOutput{}, 1, 2},
+		{QueueInput{"pop", ""}, QueueOutput{OK: true}, 3, 6},
+		{QueueInput{"push", "b"}, QueueOutput{}, 4, 7},
+	}
+	order, ok := Linearization(Queue, h)
+	if !ok || !slices.Equal(order, []int{1, 2, 3, 0}) {
+		t.Errorf("Linearization = %v, %v", order, ok)
+	}
+	h[0].Output = QueueOutput{"a", true}
+	h[0].Call = 7
+	if _, ok := Linearization(Queue, h); ok {
+		t.Error("peek of a popped head accepted")
+	}
+}
+
+// hammer runs ops goroutines times each, from goroutines goroutines.
+func hammer(goroutines, ops int, op func(rng *rand.Rand)) {
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < ops; i++ {
+				op(rng)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStrategyCache(t *testing.T) {
+	c, err := cache.New(cache.Config{Capacity: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec Recorder[KVInput, KVOutput]
+	hammer(4, 200, func(rng *rand.Rand) {
+		in := KVInput{Key: fmt.Sprint("k", rng.Intn(5))}
+		switch rng.Intn(3) {
+		case 0:
+			in.Op = "get"
+			rec.Do(in, func() KVOutput {
+				v, ok, _ := c.Get(in.Key)
+				return KVOutput{string(v), ok}
+			})
+		case 1:
+			in.Op, in.Value = "put", fmt.Sprint(rng.Intn(100))
+			rec.Do(in, func() KVOutput {
+				c.Set(in.Key, []byte(in.Value))
+				return KVOutput{}
+			})
+		case 2:
+			in.Op = "delete"
+			rec.Do(in, func() KVOutput {
+				c.Delete(in.Key)
+				return KVOutput{}
+			})
+		}
+	})
+	if !Check(KV, rec.History()) {
+		t.Error("cache history is not linearizable")
+	}
+}
+
+func TestFileQueue(t *testing.T) {
+	q, err := filequeue.Open(filepath.Join(t.TempDir(), "q"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	var rec Recorder[QueueInput, QueueOutput]
+	var n sync.Mutex
+	next := 0
+	hammer(3, 15, func(rng *rand.Rand) {
+		switch rng.Intn(3) {
+		case 0:
+			n.Lock()
+			v := fmt.Sprint(next)
+			next++
+			n.Unlock()
+			rec.Do(QueueInput{"push", v}, func() QueueOutput {
+				q.Push([]byte(v))
+				return QueueOutput{}
+			})
+		case 1:
+			rec.Do(QueueInput{Op: "peek"}, func() QueueOutput {
+				v, err := q.Peek()
+				return QueueOutput{string(v), err == nil}
+			})
+		case 2:
+			rec.Do(QueueInput{Op: "pop"}, func() QueueOutput {
+				return QueueOutput{OK: !errors.Is(q.Pop(), filequeue.ErrEmpty)}
+			})
+		}
+	})
+	if !Check(Queue, rec.History()) {
+		t.Error("file queue history is not linearizable")
+	}
+}
+
+// TestAggregateCounter checks the claims of observability/aggregate:
+// Snapshot is up to date, so a history of adds and snapshots is
+// linearizable, while Value is only as fresh as the last flush.
+func TestAggregateCounter(t *testing.T) {
+	c := aggregate.New()
+	var rec Recorder[CounterInput, int64]
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := c.Shard()
+			for i := 0; i < 30; i++ {
+				if i%3 == 0 {
+					rec.Do(CounterInput{Read: true}, c.Snapshot)
+					continue
+				}
+				rec.Do(CounterInput{Delta: 1}, func() int64 {
+					s.Add(1)
+					return 0
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if !Check(Counter, rec.History()) {
+		t.Error("Snapshot history is not linearizable")
+	}
+
+	// A fresh counter that is never flushed: Value misses an add that
+	// returned before it was called.
+	c = aggregate.New()
+	s := c.Shard()
+	var stale Recorder[CounterInput, int64]
+	stale.Do(CounterInput{Delta: 1}, func() int64 {
+		s.Add(1)
+		return 0
+	})
+	stale.Do(CounterInput{Read: true}, c.Value)
+	if Check(Counter, stale.History()) {
+		t.Error("Value without a flush passed as linearizable")
+	}
+}
+
+func Example() {
+	var rec Recorder[CounterInput, int64]
+	var mu sync.Mutex
+	var n int64
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec.Do(CounterInput{Delta: 1}, func() int64 {
+				mu.Lock()
+				defer mu.Unlock()
+				n++
+				return 0
+			})
+			rec.Do(CounterInput{Read: true}, func() int64 {
+				mu.Lock()
+				defer mu.Unlock()
+				return n
+			})
+		}()
+	}
+	wg.Wait()
+	fmt.Println(Check(Counter, rec.History()))
+	// Output: true
+}
@@ -0,0 +1,101 @@
+package linearize
+
+import "fmt"
+
+// KVInput is an operation on a key-value store: Get, Put or Delete.
+type KVInput struct {
+	Op         string
+	Key, Value string
+}
+
+// KVOutput is what a Get saw; other operations leave it zero.
+type KVOutput struct {
+	Value string
+	Found bool
+}
+
+// KV models a key-value store, partitioned by key.
+var KV = Model[map[string]string, KVInput, KVOutput]{
+	Init: func() map[string]string { return map[string]string{} },
+	Step: func(s map[string]string, in KVInput, out KVOutput) (bool, map[string]string) {
+		switch in.Op {
+		case "get":
+			v, ok := s[in.Key]
+			return out == KVOutput{v, ok}, s
+		case "put":
+			next := clone(s)
+			next[in.Key] = in.Value
+			return true, next
+		case "delete":
+			next := clone(s)
+			delete(next, in.Key)
+			return true, next
+		}
+		return false, s
+	},
+	Key:       func(s map[string]string) string { return fmt.Sprint(s) },
+	Partition: func(in KVInput) string { return in.Key },
+}
+
+func clone(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// QueueInput is an operation on a FIFO queue: Push, Peek or Pop.
+type QueueInput struct {
+	Op    string
+	Value string
+}
+
+// QueueOutput is the head a Peek saw, and whether a Peek or Pop found the
+// queue non-empty.
+type QueueOutput struct {
+	Value string
+	OK    bool
+}
+
+// Queue models a FIFO queue whose Pop removes the head that Peek shows.
+var Queue = Model[[]string, QueueInput, QueueOutput]{
+	Init: func() []string { return nil },
+	Step: func(s []string, in QueueInput, out QueueOutput) (bool, []string) {
+		switch in.Op {
+		case "push":
+			return true, append(s[:len(s):len(s)], in.Value)
+		case "peek":
+			if len(s) == 0 {
+				return out == QueueOutput{}, s
+			}
+			return out == QueueOutput{s[0], true}, s
+		case "pop":
+			if len(s) == 0 {
+				return !out.OK, s
+			}
+			return out.OK, s[1:]
+		}
+		return false, s
+	},
+	Key: func(s []string) string { return fmt.Sprintf("%q", s) },
+}
+
+// CounterInput adds Delta to a counter, or reads it if Read is set.
+type CounterInput struct {
+	Delta int64
+	Read  bool
+}
+
+// Counter models a counter. The output of a read is the value read; that
+// of an add is ignored.
+var Counter = Model[int64, CounterInput, int64]{
+	Init: func() int64 { return 0 },
+	Step: func(s int64, in CounterInput, out int64) (bool, int64) {
+		if in.Read {
+			return out == s, s
+		}
+		return true, s + in.Delta
+	},
+	Key: func(s int64) string { return fmt.Sprint(s) },
+}
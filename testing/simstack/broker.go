@@ -0,0 +1,100 @@
+package simstack
+
+// message is what services send each other through the broker.
+type message struct {
+	ID       string
+	Topic    string
+	Kind     string
+	Order    string
+	Customer string
+	Amount   int64
+	Qty      int
+}
+
+// subscriber is a service consuming a topic. handle processes a message
+// and commits its effects before the broker hears the ack.
+type subscriber struct {
+	node   *node
+	handle func(message)
+}
+
+// delivery is one message on its way to one subscriber.
+type delivery struct {
+	m         message
+	sub       *subscriber
+	delivered bool
+	acked     bool
+}
+
+// broker delivers at least once. It keeps every message it accepts and
+// redelivers it until the subscriber acks. It does not crash, but it loses
+// and duplicates deliveries, reorders them with random delays, and loses
+// the replies to publishers and the acks of subscribers.
+type broker struct {
+	w       *world
+	subs    map[string][]*subscriber
+	unacked int
+}
+
+func (b *broker) subscribe(topic string, n *node, handle func(message)) {
+	b.subs[topic] = append(b.subs[topic], &subscriber{node: n, handle: handle})
+}
+
+// publish stores m for its subscribers and reports whether the publisher
+// heard back. A publisher that did not will publish m again.
+func (b *broker) publish(m message) bool {
+	for _, sub := range b.subs[m.Topic] {
+		b.unacked++
+		b.attempt(&delivery{m: m, sub: sub})
+	}
+	if b.w.fault(b.w.cfg.Faults.Drop) {
+		b.w.report.LostReplies++
+		return false
+	}
+	return true
+}
+
+// attempt sends d on its way, perhaps twice, and checks for its ack later.
+func (b *broker) attempt(d *delivery) {
+	w := b.w
+	w.sim.after(w.latency(), func() { b.deliver(d) })
+	if w.fault(w.cfg.Faults.Duplicate) {
+		w.sim.after(w.latency(), func() { b.deliver(d) })
+	}
+	w.sim.after(w.cfg.AckTimeout, func() {
+		if !d.acked {
+			w.report.Redeliveries++
+			b.attempt(d)
+		}
+	})
+}
+
+func (b *broker) deliver(d *delivery) {
+	w := b.w
+	if w.fault(w.cfg.Faults.Drop) {
+		w.report.Dropped++
+		return
+	}
+	if !d.sub.node.up {
+		return
+	}
+	w.report.Deliveries++
+	if d.delivered {
+		w.report.Duplicates++
+	}
+	d.delivered = true
+	d.sub.handle(d.m)
+	if w.fault(w.cfg.Faults.Crash) {
+		// Crashed after committing but before acking.
+		d.sub.node.crash()
+		return
+	}
+	if w.fault(w.cfg.Faults.Drop) {
+		w.report.LostAcks++
+		return
+	}
+	if !d.acked {
+		d.acked = true
+		b.unacked--
+	}
+}
@@ -0,0 +1,76 @@
+package simstack
+
+import "fmt"
+
+// node is a service. What it keeps in its fields survives a crash, as if
+// in a database; a crash only takes it down for a while.
+//
+// A node writes the messages it sends into its outbox in the same step as
+// the rest of its writes, and a relay publishes them afterwards, so a
+// crash cannot separate a state change from the messages announcing it.
+// It remembers the IDs of the messages it consumed, in the same step
+// again, and ignores those it sees a second time.
+type node struct {
+	w      *world
+	name   string
+	up     bool
+	outbox []message
+	nextID int
+	seen   map[string]bool
+}
+
+func newNode(w *world, name string) *node {
+	n := &node{w: w, name: name, up: true, seen: map[string]bool{}}
+	w.sim.every(w.cfg.RelayEvery, func() bool {
+		n.relay()
+		return true
+	})
+	w.sim.every(crashTick, func() bool {
+		if n.up && w.fault(w.cfg.Faults.Crash) {
+			n.crash()
+		}
+		return w.sim.now < w.cfg.Window
+	})
+	return n
+}
+
+// emit adds m to the outbox.
+func (n *node) emit(m message) {
+	n.nextID++
+	m.ID = fmt.Sprintf("%s-%d", n.name, n.nextID)
+	n.outbox = append(n.outbox, m)
+}
+
+// relay publishes the outbox. A row leaves it only once the broker
+// confirmed it; if the confirmation is lost, the row goes out again.
+func (n *node) relay() {
+	for n.up && len(n.outbox) > 0 {
+		if !n.w.broker.publish(n.outbox[0]) {
+			return
+		}
+		if n.w.fault(n.w.cfg.Faults.Crash) {
+			// Crashed between publishing and deleting the row.
+			n.crash()
+			return
+		}
+		n.outbox = n.outbox[1:]
+	}
+}
+
+// consume subscribes handle to topic, skipping messages already consumed.
+func (n *node) consume(topic string, handle func(message)) {
+	n.w.broker.subscribe(topic, n, func(m message) {
+		if n.seen[m.ID] && !n.w.cfg.noDedup {
+			n.w.report.Deduplicated++
+			return
+		}
+		handle(m)
+		n.seen[m.ID] = true
+	})
+}
+
+func (n *node) crash() {
+	n.up = false
+	n.w.report.Crashes++
+	n.w.sim.after(n.w.sim.between(minDown, maxDown), func() { n.up = true })
+}
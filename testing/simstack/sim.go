@@ -0,0 +1,90 @@
+package simstack
+
+import (
+	"container/heap"
+	"math/rand"
+	"time"
+)
+
+// sim is a discrete event loop over virtual time. Everything in a run
+// happens in events it schedules, one at a time, so a run is a function of
+// its seed.
+type sim struct {
+	now time.Duration
+	rng *rand.Rand
+	q   events
+	seq int
+}
+
+type event struct {
+	at  time.Duration
+	seq int // breaks ties in scheduling order
+	fn  func()
+}
+
+type events []event
+
+func (q events) Len() int { return len(q) }
+func (q events) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+func (q events) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *events) Push(x any)   { *q = append(*q, x.(event)) }
+func (q *events) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+func newSim(seed int64) *sim {
+	return &sim{rng: rand.New(rand.NewSource(seed))}
+}
+
+// after schedules fn to run d from now.
+func (s *sim) after(d time.Duration, fn func()) {
+	s.seq++
+	heap.Push(&s.q, event{at: s.now + d, seq: s.seq, fn: fn})
+}
+
+// every runs fn every d until fn returns false.
+func (s *sim) every(d time.Duration, fn func() bool) {
+	s.after(d, func() {
+		if fn() {
+			s.every(d, fn)
+		}
+	})
+}
+
+// run runs events until done reports true or the time passes until. It
+// reports whether done was reached.
+func (s *sim) run(until time.Duration, done func() bool) bool {
+	for s.q.Len() > 0 {
+		if done() {
+			return true
+		}
+		e := heap.Pop(&s.q).(event)
+		if e.at > until {
+			return false
+		}
+		s.now = e.at
+		e.fn()
+	}
+	return done()
+}
+
+// chance reports true with probability p.
+func (s *sim) chance(p float64) bool {
+	return p > 0 && s.rng.Float64() < p
+}
+
+// between returns a duration in [lo, hi].
+func (s *sim) between(lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+	return lo + time.Duration(s.rng.Int63n(int64(hi-lo)+1))
+}
@@ -0,0 +1,327 @@
+// Package simstack is a deterministic simulation of a small messaging
+// stack, for finding the interleavings of failures that break it.
+//
+// Three services process orders. Orders runs a saga for each: it asks
+// inventory to reserve the stock, then payments to charge the customer,
+// and on a refusal cancels the order, releasing the stock if it was
+// reserved. They talk through a broker that delivers at least once. Each
+// service writes the messages it sends to an outbox in the same step as
+// its other writes, and consumes idempotently by remembering message IDs.
+//
+// Everything runs on virtual time in one goroutine, driven by a random
+// source seeded by the caller. Within a fault window, deliveries, acks and
+// publisher confirmations are lost, deliveries are duplicated and
+// reordered, and services crash, including between committing a step and
+// acking it or between publishing and clearing an outbox row. Run then
+// lets the stack settle and checks that every order ended up completed or
+// cancelled, that no customer was charged twice or charged for a
+// cancelled order, and that not a unit of stock or a cent went missing.
+//
+// The same seed gives the same run, so a seed that breaks an invariant is
+// a reproducible bug report.
+package simstack
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	crashTick = 10 * time.Millisecond
+	minDown   = 5 * time.Millisecond
+	maxDown   = 50 * time.Millisecond
+	maxPrice  = 100
+)
+
+// Faults are the probabilities of failures within the fault window.
+type Faults struct {
+	// Drop is the chance that a delivery, an ack or the broker's reply to
+	// a publisher is lost.
+	Drop float64
+	// Duplicate is the chance that a delivery is made twice.
+	Duplicate float64
+	// Crash is the chance that a service crashes after a step, and every
+	// 10ms.
+	Crash float64
+	// MaxDelay is the longest a delivery takes. Deliveries take between
+	// 1ms and MaxDelay, so they overtake each other.
+	MaxDelay time.Duration
+}
+
+// Config configures a run.
+type Config struct {
+	Orders, Customers int
+	// Stock is the units inventory starts with.
+	Stock int
+	// Balance is what each customer starts with, in cents.
+	Balance int64
+	Faults  Faults
+	// Window is how long faults happen for. Orders are placed in its
+	// first half.
+	Window time.Duration
+	// Horizon is how long the stack has to settle.
+	Horizon time.Duration
+	// AckTimeout is when the broker redelivers an unacked message.
+	AckTimeout time.Duration
+	// RelayEvery is how often outboxes are published.
+	RelayEvery time.Duration
+
+	// noDedup turns idempotent consumption off, to check that the
+	// simulation notices.
+	noDedup bool
+}
+
+func (c *Config) defaults() {
+	if c.Orders <= 0 {
+		c.Orders = 20
+	}
+	if c.Customers <= 0 {
+		c.Customers = 3
+	}
+	if c.Stock <= 0 {
+		c.Stock = 30
+	}
+	if c.Balance <= 0 {
+		c.Balance = 500
+	}
+	if c.Window <= 0 {
+		c.Window = time.Second
+	}
+	if c.Horizon <= 0 {
+		c.Horizon = time.Minute
+	}
+	if c.AckTimeout <= 0 {
+		c.AckTimeout = 30 * time.Millisecond
+	}
+	if c.RelayEvery <= 0 {
+		c.RelayEvery = 5 * time.Millisecond
+	}
+	if c.Faults.MaxDelay <= 0 {
+		c.Faults.MaxDelay = 20 * time.Millisecond
+	}
+}
+
+// Report describes a run.
+type Report struct {
+	Seed                  int64
+	Completed, Cancelled  int
+	Deliveries            int
+	Duplicates            int // deliveries of a message already delivered
+	Deduplicated          int // messages ignored as already consumed
+	Redeliveries          int
+	Dropped               int
+	LostAcks, LostReplies int
+	Crashes               int
+	// Settled is the virtual time the stack took to settle.
+	Settled time.Duration
+}
+
+// ErrInvariant is returned when a run breaks an invariant.
+var ErrInvariant = errors.New("simstack: invariant broken")
+
+type order struct {
+	id, customer string
+	qty          int
+	amount       int64
+	state        string
+}
+
+// Saga states.
+const (
+	pending   = "pending"
+	reserved  = "reserved"
+	completed = "completed"
+	cancelled = "cancelled"
+)
+
+type world struct {
+	cfg    Config
+	sim    *sim
+	broker *broker
+	report *Report
+
+	orders, inventory, payments *node
+
+	placed map[string]*order // orders' database
+
+	stock int            // inventory's database
+	held  map[string]int // units reserved per order
+
+	balances map[string]int64 // payments' database
+	charges  map[string]int   // charges per order
+}
+
+func (w *world) fault(p float64) bool {
+	return w.sim.now < w.cfg.Window && w.sim.chance(p)
+}
+
+func (w *world) latency() time.Duration {
+	if w.sim.now >= w.cfg.Window {
+		return time.Millisecond
+	}
+	return w.sim.between(time.Millisecond, w.cfg.Faults.MaxDelay)
+}
+
+// Run simulates the stack with the given seed and returns what happened
+// and, joined with ErrInvariant, every invariant it broke.
+func Run(seed int64, cfg Config) (Report, error) {
+	cfg.defaults()
+	w := &world{
+		cfg:      cfg,
+		sim:      newSim(seed),
+		report:   &Report{Seed: seed},
+		placed:   map[string]*order{},
+		stock:    cfg.Stock,
+		held:     map[string]int{},
+		balances: map[string]int64{},
+		charges:  map[string]int{},
+	}
+	w.broker = &broker{w: w, subs: map[string][]*subscriber{}}
+	w.orders = newNode(w, "orders")
+	w.inventory = newNode(w, "inventory")
+	w.payments = newNode(w, "payments")
+	w.orders.consume("orders", w.onOrderReply)
+	w.inventory.consume("inventory", w.onInventory)
+	w.payments.consume("payments", w.onPayment)
+
+	var want []*order
+	for i := range cfg.Orders {
+		qty := 1 + w.sim.rng.Intn(3)
+		o := &order{
+			id:       fmt.Sprint("order-", i),
+			customer: fmt.Sprint("customer-", w.sim.rng.Intn(cfg.Customers)),
+			qty:      qty,
+			amount:   int64(qty * (1 + w.sim.rng.Intn(maxPrice))),
+		}
+		w.balances[o.customer] = cfg.Balance
+		want = append(want, o)
+		w.sim.after(w.sim.between(0, cfg.Window/2), func() { w.place(*o) })
+	}
+
+	w.sim.run(cfg.Horizon, func() bool { return w.settled(len(want)) })
+	w.report.Settled = w.sim.now
+	return *w.report, w.check(want)
+}
+
+// place is the client placing o, trying again while orders is down.
+func (w *world) place(o order) {
+	if !w.orders.up {
+		w.sim.after(minDown, func() { w.place(o) })
+		return
+	}
+	if _, ok := w.placed[o.id]; ok {
+		return
+	}
+	o.state = pending
+	w.placed[o.id] = &o
+	w.orders.emit(message{Topic: "inventory", Kind: "reserve", Order: o.id, Qty: o.qty})
+}
+
+// onOrderReply moves an order's saga along.
+func (w *world) onOrderReply(m message) {
+	o := w.placed[m.Order]
+	switch {
+	case m.Kind == "reserved" && o.state == pending:
+		o.state = reserved
+		w.orders.emit(message{Topic: "payments", Kind: "charge", Order: o.id, Customer: o.customer, Amount: o.amount})
+	case m.Kind == "out_of_stock" && o.state == pending:
+		o.state = cancelled
+	case m.Kind == "charged" && o.state == reserved:
+		o.state = completed
+	case m.Kind == "charge_failed" && o.state == reserved:
+		o.state = cancelled
+		w.orders.emit(message{Topic: "inventory", Kind: "release", Order: o.id})
+	}
+}
+
+func (w *world) onInventory(m message) {
+	switch m.Kind {
+	case "reserve":
+		if w.stock < m.Qty {
+			w.inventory.emit(message{Topic: "orders", Kind: "out_of_stock", Order: m.Order})
+			return
+		}
+		w.stock -= m.Qty
+		w.held[m.Order] += m.Qty
+		w.inventory.emit(message{Topic: "orders", Kind: "reserved", Order: m.Order})
+	case "release":
+		w.stock += w.held[m.Order]
+		delete(w.held, m.Order)
+	}
+}
+
+func (w *world) onPayment(m message) {
+	if w.balances[m.Customer] < m.Amount {
+		w.payments.emit(message{Topic: "orders", Kind: "charge_failed", Order: m.Order})
+		return
+	}
+	w.balances[m.Customer] -= m.Amount
+	w.charges[m.Order]++
+	w.payments.emit(message{Topic: "orders", Kind: "charged", Order: m.Order})
+}
+
+// settled reports whether every order is placed and finished and no
+// message is in flight.
+func (w *world) settled(orders int) bool {
+	if len(w.placed) < orders || w.broker.unacked > 0 {
+		return false
+	}
+	for _, n := range []*node{w.orders, w.inventory, w.payments} {
+		if len(n.outbox) > 0 {
+			return false
+		}
+	}
+	for _, o := range w.placed {
+		if o.state != completed && o.state != cancelled {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *world) check(want []*order) error {
+	var errs []error
+	broken := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: seed %d: %s", ErrInvariant, w.report.Seed, fmt.Sprintf(format, args...)))
+	}
+	stock, spent := w.stock, map[string]int64{}
+	for _, o := range want {
+		got, ok := w.placed[o.id]
+		if !ok {
+			broken("%s was never placed", o.id)
+			continue
+		}
+		stock += w.held[o.id]
+		switch got.state {
+		case completed:
+			w.report.Completed++
+			spent[o.customer] += o.amount
+			if w.charges[o.id] != 1 || w.held[o.id] != o.qty {
+				broken("completed %s charged %d times, holding %d of %d units", o.id, w.charges[o.id], w.held[o.id], o.qty)
+			}
+		case cancelled:
+			w.report.Cancelled++
+			if w.charges[o.id] != 0 || w.held[o.id] != 0 {
+				broken("cancelled %s charged %d times, holding %d units", o.id, w.charges[o.id], w.held[o.id])
+			}
+		default:
+			broken("%s lost: stuck %s after %v", o.id, got.state, w.sim.now)
+		}
+	}
+	if stock != w.cfg.Stock {
+		broken("%d units of stock accounted for, want %d", stock, w.cfg.Stock)
+	}
+	customers := make([]string, 0, len(w.balances))
+	for c := range w.balances {
+		customers = append(customers, c)
+	}
+	slices.Sort(customers)
+	for _, c := range customers {
+		if got := w.balances[c] + spent[c]; got != w.cfg.Balance {
+			broken("%s balance %d plus completed orders %d is %d, want %d", c, w.balances[c], spent[c], got, w.cfg.Balance)
+		}
+	}
+	return errors.Join(errs...)
+}
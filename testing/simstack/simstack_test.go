@@ -0,0 +1,83 @@
+package simstack
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var rough = Faults{Drop: 0.1, Duplicate: 0.1, Crash: 0.02}
+
+func runs(t *testing.T) int {
+	if testing.Short() {
+		return 200
+	}
+	return 2000
+}
+
+func TestInvariants(t *testing.T) {
+	var total Report
+	for seed := int64(0); seed < int64(runs(t)); seed++ {
+		r, err := Run(seed, Config{Faults: rough})
+		if err != nil {
+			t.Fatal(err)
+		}
+		total.Completed += r.Completed
+		total.Cancelled += r.Cancelled
+		total.Duplicates += r.Duplicates
+		total.Deduplicated += r.Deduplicated
+		total.Redeliveries += r.Redeliveries
+		total.LostAcks += r.LostAcks
+		total.LostReplies += r.LostReplies
+		total.Crashes += r.Crashes
+	}
+	t.Logf("totals: %+v", total)
+	// The faults must actually have been exercised, and both ends of the saga.
+	for name, n := range map[string]int{
+		"completed": total.Completed, "cancelled": total.Cancelled,
+		"duplicates": total.Duplicates, "deduplicated": total.Deduplicated,
+		"redeliveries": total.Redeliveries, "lost acks": total.LostAcks,
+		"lost replies": total.LostReplies, "crashes": total.Crashes,
+	} {
+		if n == 0 {
+			t.Errorf("no %s in any run", name)
+		}
+	}
+}
+
+func TestNoFaults(t *testing.T) {
+	r, err := Run(1, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Duplicates+r.Dropped+r.Crashes+r.Redeliveries != 0 {
+		t.Errorf("faults without faults: %+v", r)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	a, errA := Run(42, Config{Faults: rough})
+	b, errB := Run(42, Config{Faults: rough})
+	if a != b || fmt.Sprint(errA) != fmt.Sprint(errB) {
+		t.Errorf("same seed, different runs:\n%+v\n%+v", a, b)
+	}
+}
+
+// TestCatchesDoubleProcessing turns deduplication off and expects the
+// invariants to catch the double reservations and charges that follow.
+func TestCatchesDoubleProcessing(t *testing.T) {
+	for seed := int64(0); seed < 100; seed++ {
+		_, err := Run(seed, Config{Faults: rough, noDedup: true})
+		if errors.Is(err, ErrInvariant) {
+			t.Logf("caught: %v", err)
+			return
+		}
+	}
+	t.Error("no run without deduplication broke an invariant")
+}
+
+func Example() {
+	r, err := Run(7, Config{Orders: 10, Faults: Faults{Drop: 0.2, Duplicate: 0.2, Crash: 0.05}})
+	fmt.Println(r.Completed+r.Cancelled, "orders finished;", err)
+	// Output: 10 orders finished; <nil>
+}
@@ -0,0 +1,388 @@
+// Package stress runs concurrent operations against a data structure and
+// checks that its invariants hold while they run.
+//
+// A Spec declares the structure under test in three parts: Setup builds a
+// fresh one, Ops are the things goroutines may do to it, and Invariants
+// are properties that must hold whatever they did. Run starts the
+// configured number of goroutines, each picking weighted operations from
+// its own seeded random source for a duration or a number of steps, while
+// a checker goroutine evaluates the invariants. Yield is a hint to perturb
+// the interleaving: the probability of calling runtime.Gosched between
+// two operations, which shakes out orders the scheduler rarely picks.
+//
+// Every operation run is logged with its goroutine, its name and the
+// random argument it was given. When an operation or an invariant fails,
+// Run minimizes that log: for a Spec whose operations never block, it
+// replays subsets of the log on one goroutine against a fresh structure,
+// dropping operations for as long as the failure persists, and reports the
+// shortest sequence that still breaks the structure. A failure that needs
+// true concurrency to show does not reproduce on one goroutine; Run then
+// reports the tail of the log that led to it.
+package stress
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ErrNoOps is returned by Run for a Spec without operations.
+var ErrNoOps = errors.New("stress: spec has no operations")
+
+// Op is an operation goroutines may run. Do is given the goroutine number
+// and a random argument to pick keys or values from; given the same ones,
+// it must do the same thing, so that a log can be replayed. An error from
+// Do is a failed postcondition.
+type Op[S any] struct {
+	Name string
+	// Weight is the relative frequency of the operation, default 1.
+	Weight int
+	Do     func(s S, g int, arg int64) error
+}
+
+// Invariant is a property of the structure. Check runs concurrently with
+// the operations and must be safe to call at any time.
+type Invariant[S any] struct {
+	Name  string
+	Check func(s S) error
+}
+
+// Spec declares a structure under test.
+type Spec[S any] struct {
+	Setup      func() S
+	Ops        []Op[S]
+	Invariants []Invariant[S]
+	// Stop, if set, is called when the run ends to unblock operations
+	// still waiting, such as by breaking a barrier.
+	Stop func(s S)
+	// Replayable says that no operation ever blocks, so that a failing
+	// log can be replayed on one goroutine to minimize it.
+	Replayable bool
+}
+
+// Config says how hard to run a Spec.
+type Config struct {
+	// Goroutines defaults to 4.
+	Goroutines int
+	// Duration bounds the run in time and Steps in operations per
+	// goroutine. The run ends at the first of the two; with neither set
+	// Steps defaults to 1000.
+	Duration time.Duration
+	Steps    int
+	// Seed seeds goroutine g's random source with Seed+g.
+	Seed int64
+	// Yield is the probability of yielding the processor between two
+	// operations.
+	Yield float64
+	// CheckEvery is how often invariants are checked during the run,
+	// default 100µs. They are always checked once more at the end.
+	CheckEvery time.Duration
+	// MaxReplays bounds the replays spent minimizing, default 2000.
+	MaxReplays int
+}
+
+func (c *Config) defaults() {
+	if c.Goroutines <= 0 {
+		c.Goroutines = 4
+	}
+	if c.Duration <= 0 && c.Steps <= 0 {
+		c.Steps = 1000
+	}
+	if c.CheckEvery <= 0 {
+		c.CheckEvery = 100 * time.Microsecond
+	}
+	if c.MaxReplays <= 0 {
+		c.MaxReplays = 2000
+	}
+}
+
+// Step is one logged operation.
+type Step struct {
+	G   int
+	Op  string
+	Arg int64
+
+	seq uint64
+	op  int
+}
+
+func (s Step) String() string { return fmt.Sprintf("g%d:%s(%d)", s.G, s.Op, s.Arg) }
+
+// tail is how many steps are reported for a failure that did not replay.
+const tail = 20
+
+// Failure describes a broken invariant or postcondition.
+type Failure struct {
+	// Check names the invariant, or the operation whose postcondition
+	// failed.
+	Check string
+	Err   error
+	// Steps are the operations that lead to the failure. When Minimized
+	// they are a shortest sequence found that breaks a fresh structure on
+	// one goroutine; otherwise they are the last steps of the run.
+	Steps     []Step
+	Minimized bool
+	// Ran counts the operations run before the failure was seen.
+	Ran int
+}
+
+func (f *Failure) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "stress: %s: %v after %d operations\n", f.Check, f.Err, f.Ran)
+	if f.Minimized {
+		fmt.Fprintf(&b, "minimal sequence of %d operations:", len(f.Steps))
+	} else {
+		fmt.Fprintf(&b, "did not reproduce on one goroutine; last %d operations:", len(f.Steps))
+	}
+	for _, s := range f.Steps {
+		b.WriteString("\n\t")
+		b.WriteString(s.String())
+	}
+	return b.String()
+}
+
+// Check runs spec and fails t with the minimized sequence on a violation.
+func Check[S any](t testing.TB, spec Spec[S], cfg Config) {
+	t.Helper()
+	if err := Run(spec, cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Run runs spec as configured. It returns nil if every operation and
+// invariant held, a *Failure if one did not, and ErrNoOps for a Spec
+// without operations.
+func Run[S any](spec Spec[S], cfg Config) error {
+	cfg.defaults()
+	if len(spec.Ops) == 0 {
+		return ErrNoOps
+	}
+	r := &run[S]{spec: spec, cfg: cfg, s: spec.Setup(), stop: make(chan struct{})}
+	r.weights = make([]int, len(spec.Ops))
+	for i, op := range spec.Ops {
+		r.weights[i] = max(op.Weight, 1)
+		r.total += r.weights[i]
+	}
+	logs := make([][]Step, cfg.Goroutines)
+
+	var wg sync.WaitGroup
+	for g := range logs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logs[g] = r.worker(g)
+		}()
+	}
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+		r.checker()
+	}()
+	if cfg.Duration > 0 {
+		timer := time.AfterFunc(cfg.Duration, r.end)
+		defer timer.Stop()
+	}
+	wg.Wait()
+	r.end()
+	<-checked
+	if r.failure == nil {
+		r.checkAll()
+	}
+	if r.failure == nil {
+		return nil
+	}
+
+	var log []Step
+	for _, l := range logs {
+		log = append(log, l...)
+	}
+	slices.SortFunc(log, func(a, b Step) int {
+		switch {
+		case a.seq < b.seq:
+			return -1
+		case a.seq > b.seq:
+			return 1
+		}
+		return 0
+	})
+	f := r.failure
+	f.Ran = len(log)
+	if spec.Replayable {
+		m := &minimizer[S]{spec: spec, check: f.Check, budget: cfg.MaxReplays}
+		if steps, ok := m.minimize(log); ok {
+			f.Steps, f.Minimized = steps, true
+			return f
+		}
+	}
+	f.Steps = log[max(len(log)-tail, 0):]
+	return f
+}
+
+type run[S any] struct {
+	spec    Spec[S]
+	cfg     Config
+	s       S
+	weights []int
+	total   int
+
+	seq      atomic.Uint64
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu      sync.Mutex
+	failure *Failure
+}
+
+func (r *run[S]) end() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		if r.spec.Stop != nil {
+			r.spec.Stop(r.s)
+		}
+	})
+}
+
+func (r *run[S]) fail(check string, err error) {
+	r.mu.Lock()
+	if r.failure == nil {
+		r.failure = &Failure{Check: check, Err: err}
+	}
+	r.mu.Unlock()
+	r.end()
+}
+
+func (r *run[S]) stopped() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *run[S]) worker(g int) []Step {
+	rng := rand.New(rand.NewSource(r.cfg.Seed + int64(g)))
+	var log []Step
+	for n := 0; r.cfg.Steps <= 0 || n < r.cfg.Steps; n++ {
+		if r.stopped() {
+			break
+		}
+		i := pick(rng, r.weights, r.total)
+		op := r.spec.Ops[i]
+		step := Step{G: g, Op: op.Name, Arg: rng.Int63(), op: i}
+		step.seq = r.seq.Add(1)
+		log = append(log, step)
+		if err := op.Do(r.s, g, step.Arg); err != nil {
+			r.fail(op.Name, err)
+			break
+		}
+		if r.cfg.Yield > 0 && rng.Float64() < r.cfg.Yield {
+			runtime.Gosched()
+		}
+	}
+	return log
+}
+
+func (r *run[S]) checker() {
+	if len(r.spec.Invariants) == 0 {
+		return
+	}
+	tick := time.NewTicker(r.cfg.CheckEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-tick.C:
+			r.checkAll()
+		}
+	}
+}
+
+func (r *run[S]) checkAll() {
+	for _, inv := range r.spec.Invariants {
+		if err := inv.Check(r.s); err != nil {
+			r.fail(inv.Name, err)
+			return
+		}
+	}
+}
+
+func pick(rng *rand.Rand, weights []int, total int) int {
+	n := rng.Intn(total)
+	for i, w := range weights {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	return len(weights) - 1
+}
+
+// minimizer shrinks a failing log by delta debugging: it tries dropping
+// ever smaller chunks of the log and keeps any smaller log that still
+// fails the same check when replayed.
+type minimizer[S any] struct {
+	spec   Spec[S]
+	check  string
+	budget int
+}
+
+func (m *minimizer[S]) minimize(log []Step) ([]Step, bool) {
+	// Replay only up to the first failure, which may come earlier than it
+	// was seen in the run.
+	n, ok := m.replay(log)
+	if !ok {
+		return nil, false
+	}
+	log = log[:n]
+	for chunks := 2; len(log) > 1 && m.budget > 0; {
+		size := (len(log) + chunks - 1) / chunks
+		reduced := false
+		for start := 0; start < len(log) && m.budget > 0; start += size {
+			cand := slices.Concat(log[:start], log[min(start+size, len(log)):])
+			if n, ok := m.replay(cand); ok {
+				log = cand[:n]
+				chunks = max(chunks-1, 2)
+				reduced = true
+				break
+			}
+		}
+		if !reduced {
+			if size == 1 {
+				break
+			}
+			chunks = min(chunks*2, len(log))
+		}
+	}
+	return log, true
+}
+
+// replay runs steps in order on a fresh structure, checking every
+// invariant after each. It reports whether the minimizer's check failed,
+// and the number of steps it took.
+func (m *minimizer[S]) replay(steps []Step) (int, bool) {
+	m.budget--
+	s := m.spec.Setup()
+	if m.spec.Stop != nil {
+		defer m.spec.Stop(s)
+	}
+	for i, step := range steps {
+		if err := m.spec.Ops[step.op].Do(s, step.G, step.Arg); err != nil {
+			return i + 1, step.Op == m.check
+		}
+		for _, inv := range m.spec.Invariants {
+			if err := inv.Check(s); err != nil {
+				return i + 1, inv.Name == m.check
+			}
+		}
+	}
+	return 0, false
+}
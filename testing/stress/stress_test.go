@@ -0,0 +1,282 @@
+package stress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazybber/go-patterns/behavioral/strategy/cache"
+	"github.com/crazybber/go-patterns/concurrency/barrier/cyclic"
+	"github.com/crazybber/go-patterns/creational/resourcepool"
+)
+
+// set is a thread-safe set with a bug: adding a key twice counts it twice.
+type set struct {
+	mu    sync.Mutex
+	keys  map[int64]bool
+	count int
+}
+
+func (s *set) add(k int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k] = true
+	s.count++
+}
+
+func (s *set) remove(k int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys[k] {
+		delete(s.keys, k)
+		s.count--
+	}
+}
+
+func setSpec() Spec[*set] {
+	return Spec[*set]{
+		Setup: func() *set { return &set{keys: map[int64]bool{}} },
+		Ops: []Op[*set]{
+			{Name: "add", Do: func(s *set, _ int, arg int64) error { s.add(arg % 8); return nil }},
+			{Name: "remove", Weight: 3, Do: func(s *set, _ int, arg int64) error { s.remove(arg % 8); return nil }},
+		},
+		Invariants: []Invariant[*set]{{Name: "count", Check: func(s *set) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.count != len(s.keys) {
+				return fmt.Errorf("count %d for %d keys", s.count, len(s.keys))
+			}
+			return nil
+		}}},
+		Replayable: true,
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		err := Run(setSpec(), Config{Seed: seed, Yield: 0.1})
+		var f *Failure
+		if !errors.As(err, &f) {
+			t.Fatalf("seed %d: Run = %v, want a failure", seed, err)
+		}
+		if f.Check != "count" || !f.Minimized {
+			t.Fatalf("seed %d: %v", seed, f)
+		}
+		// Adding one key twice is the shortest way to break the set.
+		if len(f.Steps) != 2 || f.Steps[0].Op != "add" || f.Steps[1].Op != "add" ||
+			f.Steps[0].Arg%8 != f.Steps[1].Arg%8 {
+			t.Errorf("seed %d: minimized to %v", seed, f.Steps)
+		}
+		if !strings.Contains(f.Error(), "minimal sequence of 2 operations") {
+			t.Errorf("seed %d: %s", seed, f)
+		}
+	}
+}
+
+func TestPostcondition(t *testing.T) {
+	spec := Spec[*atomic.Int64]{
+		Setup: func() *atomic.Int64 { return new(atomic.Int64) },
+		Ops: []Op[*atomic.Int64]{{Name: "inc", Do: func(n *atomic.Int64, _ int, _ int64) error {
+			if n.Add(1) > 50 {
+				return errors.New("over 50")
+			}
+			return nil
+		}}},
+		Replayable: true,
+	}
+	var f *Failure
+	if err := Run(spec, Config{Goroutines: 2, Steps: 100}); !errors.As(err, &f) {
+		t.Fatalf("Run = %v", err)
+	}
+	if f.Check != "inc" || !f.Minimized || len(f.Steps) != 51 {
+		t.Errorf("got %s with %d steps", f.Check, len(f.Steps))
+	}
+}
+
+func TestNoOps(t *testing.T) {
+	if err := Run(Spec[int]{Setup: func() int { return 0 }}, Config{}); err != ErrNoOps {
+		t.Errorf("Run = %v", err)
+	}
+}
+
+// cacheSpec stores under each key a value naming the key, so that a Get
+// can check it was handed the right value.
+func cacheSpec(eviction string) Spec[*cache.Cache] {
+	const capacity = 64
+	value := func(arg int64) (string, []byte) {
+		key := fmt.Sprint("k", arg%16)
+		return key, []byte(strings.Repeat(key, 1+int(arg>>8)%3))
+	}
+	return Spec[*cache.Cache]{
+		Setup: func() *cache.Cache {
+			c, err := cache.New(cache.Config{Eviction: eviction, Capacity: capacity})
+			if err != nil {
+				panic(err)
+			}
+			return c
+		},
+		Ops: []Op[*cache.Cache]{
+			{Name: "set", Weight: 2, Do: func(c *cache.Cache, _ int, arg int64) error {
+				key, v := value(arg)
+				return c.Set(key, v)
+			}},
+			{Name: "get", Weight: 3, Do: func(c *cache.Cache, _ int, arg int64) error {
+				key, _ := value(arg)
+				v, ok, err := c.Get(key)
+				if ok && !strings.HasPrefix(string(v), key) {
+					return fmt.Errorf("Get(%s) = %q", key, v)
+				}
+				return err
+			}},
+			{Name: "delete", Do: func(c *cache.Cache, _ int, arg int64) error {
+				key, _ := value(arg)
+				c.Delete(key)
+				return nil
+			}},
+		},
+		Invariants: []Invariant[*cache.Cache]{
+			{Name: "capacity", Check: func(c *cache.Cache) error {
+				if b := c.Stats().Bytes; b < 0 || b > capacity {
+					return fmt.Errorf("%d bytes stored in %d", b, capacity)
+				}
+				return nil
+			}},
+			{Name: "len", Check: func(c *cache.Cache) error {
+				// Every value is at least two bytes.
+				if n := c.Len(); n > capacity/2 {
+					return fmt.Errorf("%d entries", n)
+				}
+				return nil
+			}},
+		},
+		Replayable: true,
+	}
+}
+
+func TestCache(t *testing.T) {
+	for _, eviction := range cache.Evictors() {
+		t.Run(eviction, func(t *testing.T) {
+			Check(t, cacheSpec(eviction), Config{Goroutines: 8, Steps: 2000, Yield: 0.2})
+		})
+	}
+}
+
+// sem counts the holders of a resourcepool.Pool, which is a counting
+// semaphore over its resources.
+type sem struct {
+	pool    *resourcepool.Pool[int]
+	holders atomic.Int64
+}
+
+const permits = 3
+
+func TestSemaphore(t *testing.T) {
+	spec := Spec[*sem]{
+		Setup: func() *sem {
+			var n atomic.Int64
+			return &sem{pool: resourcepool.New(permits, func(context.Context) (int, error) {
+				return int(n.Add(1)), nil
+			}, nil)}
+		},
+		Ops: []Op[*sem]{{Name: "hold", Do: func(s *sem, _ int, arg int64) error {
+			r, err := s.pool.Acquire(context.Background())
+			if errors.Is(err, resourcepool.ErrClosed) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			defer s.pool.Release(r)
+			defer s.holders.Add(-1)
+			if h := s.holders.Add(1); h > permits {
+				return fmt.Errorf("%d holders", h)
+			}
+			if arg%4 == 0 {
+				time.Sleep(time.Microsecond)
+			}
+			return nil
+		}}},
+		Invariants: []Invariant[*sem]{{Name: "open", Check: func(s *sem) error {
+			if n := s.pool.Open(); n > permits {
+				return fmt.Errorf("%d resources open", n)
+			}
+			return nil
+		}}},
+		Stop: func(s *sem) { s.pool.Close() },
+	}
+	Check(t, spec, Config{Goroutines: 8, Duration: 50 * time.Millisecond, Yield: 0.5})
+}
+
+// phases counts the arrivals of each party at a cyclic barrier.
+type phases struct {
+	b        *cyclic.Barrier
+	arrivals []atomic.Int64
+}
+
+func TestBarrier(t *testing.T) {
+	const parties = 5
+	spec := Spec[*phases]{
+		Setup: func() *phases {
+			return &phases{b: cyclic.New(parties), arrivals: make([]atomic.Int64, parties)}
+		},
+		Ops: []Op[*phases]{{Name: "await", Do: func(p *phases, g int, _ int64) error {
+			mine := p.arrivals[g].Add(1)
+			if err := p.b.Await(); errors.Is(err, cyclic.ErrBroken) {
+				return nil
+			}
+			// Nobody passes a phase before everybody reached it.
+			for i := range p.arrivals {
+				if n := p.arrivals[i].Load(); n < mine {
+					return fmt.Errorf("passed phase %d with party %d at %d", mine, i, n)
+				}
+			}
+			return nil
+		}}},
+		Invariants: []Invariant[*phases]{{Name: "lockstep", Check: func(p *phases) error {
+			// Counts only grow, so reading the highest before the lowest
+			// never sees a spread larger than there was at some instant.
+			var hi int64
+			for i := range p.arrivals {
+				hi = max(hi, p.arrivals[i].Load())
+			}
+			lo := hi
+			for i := range p.arrivals {
+				lo = min(lo, p.arrivals[i].Load())
+			}
+			if hi-lo > 1 {
+				return fmt.Errorf("parties %d phases apart", hi-lo)
+			}
+			return nil
+		}}},
+		Stop: func(p *phases) { p.b.Break() },
+	}
+	Check(t, spec, Config{Goroutines: parties, Steps: 300, Yield: 0.3})
+	// A run cut short by time leaves parties waiting, which Stop frees.
+	Check(t, spec, Config{Goroutines: parties, Duration: 20 * time.Millisecond})
+}
+
+func ExampleRun() {
+	spec := Spec[*sync.Map]{
+		Setup: func() *sync.Map { return new(sync.Map) },
+		Ops: []Op[*sync.Map]{
+			{Name: "store", Do: func(m *sync.Map, _ int, arg int64) error {
+				m.Store(arg%10, arg)
+				return nil
+			}},
+			{Name: "load", Do: func(m *sync.Map, _ int, arg int64) error {
+				if v, ok := m.Load(arg % 10); ok && v.(int64)%10 != arg%10 {
+					return fmt.Errorf("key %d holds %d", arg%10, v)
+				}
+				return nil
+			}},
+		},
+		Replayable: true,
+	}
+	fmt.Println(Run(spec, Config{Goroutines: 4, Steps: 500}))
+	// Output: <nil>
+}